@@ -400,11 +400,17 @@ pub fn build(b: *std.Build) void {
         .target = target,
     });
 
+    const cli_profiling_mod = b.addModule("cli_profiling", .{
+        .root_source_file = b.path("src/cli/profiling.zig"),
+        .target = target,
+    });
+
     const cli_output_mod = b.addModule("cli_output", .{
         .root_source_file = b.path("src/cli/output.zig"),
         .target = target,
     });
     cli_output_mod.addImport("ananke", ananke_mod);
+    cli_output_mod.addImport("cli_profiling", cli_profiling_mod);
 
     const security_mod = b.addModule("security", .{
         .root_source_file = b.path("src/security/secure_string.zig"),
@@ -440,7 +446,481 @@ pub fn build(b: *std.Build) void {
     cli_error_help_mod.addImport("cli_output", cli_output_mod);
     cli_error_help_mod.addImport("cli_error", cli_error_mod);
 
+    const cli_summary_mod = b.addModule("cli_summary", .{
+        .root_source_file = b.path("src/cli/summary.zig"),
+        .target = target,
+    });
+    cli_summary_mod.addImport("ananke", ananke_mod);
+    cli_summary_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_prompt_pack_mod = b.addModule("cli_prompt_pack", .{
+        .root_source_file = b.path("src/cli/prompt_pack.zig"),
+        .target = target,
+    });
+    cli_prompt_pack_mod.addImport("ananke", ananke_mod);
+    cli_prompt_pack_mod.addImport("cli_summary", cli_summary_mod);
+
+    const cli_cyclonedx_mod = b.addModule("cli_cyclonedx", .{
+        .root_source_file = b.path("src/cli/cyclonedx.zig"),
+        .target = target,
+    });
+    cli_cyclonedx_mod.addImport("ananke", ananke_mod);
+    cli_cyclonedx_mod.addImport("cli_output", cli_output_mod);
+    cli_cyclonedx_mod.addImport("cli_summary", cli_summary_mod);
+
+    const cli_annotate_mod = b.addModule("cli_annotate", .{
+        .root_source_file = b.path("src/cli/annotate.zig"),
+        .target = target,
+    });
+    cli_annotate_mod.addImport("ananke", ananke_mod);
+
+    const cli_messages_mod = b.addModule("cli_messages", .{
+        .root_source_file = b.path("src/cli/messages.zig"),
+        .target = target,
+    });
+
+    const cli_report_mod = b.addModule("cli_report", .{
+        .root_source_file = b.path("src/cli/report.zig"),
+        .target = target,
+    });
+    cli_report_mod.addImport("ananke", ananke_mod);
+    cli_report_mod.addImport("cli_messages", cli_messages_mod);
+    cli_report_mod.addImport("cli_summary", cli_summary_mod);
+
+    const cli_discovery_mod = b.addModule("cli_discovery", .{
+        .root_source_file = b.path("src/cli/discovery.zig"),
+        .target = target,
+    });
+
+    const cli_git_mod = b.addModule("cli_git", .{
+        .root_source_file = b.path("src/cli/git.zig"),
+        .target = target,
+    });
+    cli_git_mod.addImport("cli_discovery", cli_discovery_mod);
+
+    const cli_blame_mod = b.addModule("cli_blame", .{
+        .root_source_file = b.path("src/cli/blame.zig"),
+        .target = target,
+    });
+    cli_blame_mod.addImport("ananke", ananke_mod);
+    cli_blame_mod.addImport("cli_git", cli_git_mod);
+    cli_output_mod.addImport("cli_blame", cli_blame_mod);
+
+    const cli_build_graph_mod = b.addModule("cli_build_graph", .{
+        .root_source_file = b.path("src/cli/build_graph.zig"),
+        .target = target,
+    });
+
+    const cli_summarize_mod = b.addModule("cli_summarize", .{
+        .root_source_file = b.path("src/cli/summarize.zig"),
+        .target = target,
+    });
+    cli_summarize_mod.addImport("ananke", ananke_mod);
+    cli_output_mod.addImport("cli_summarize", cli_summarize_mod);
+
+    const cli_constraint_diff_mod = b.addModule("cli_constraint_diff", .{
+        .root_source_file = b.path("src/cli/constraint_diff.zig"),
+        .target = target,
+    });
+    cli_constraint_diff_mod.addImport("ananke", ananke_mod);
+    cli_constraint_diff_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_approvals_mod = b.addModule("cli_approvals", .{
+        .root_source_file = b.path("src/cli/approvals.zig"),
+        .target = target,
+    });
+    cli_approvals_mod.addImport("ananke", ananke_mod);
+    cli_approvals_mod.addImport("cli_output", cli_output_mod);
+    cli_approvals_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+
+    const cli_constraint_verify_mod = b.addModule("cli_constraint_verify", .{
+        .root_source_file = b.path("src/cli/constraint_verify.zig"),
+        .target = target,
+    });
+    cli_constraint_verify_mod.addImport("ananke", ananke_mod);
+    cli_constraint_verify_mod.addImport("cli_output", cli_output_mod);
+    cli_constraint_verify_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+    cli_constraint_verify_mod.addImport("cli_approvals", cli_approvals_mod);
+
+    const cli_drift_report_mod = b.addModule("cli_drift_report", .{
+        .root_source_file = b.path("src/cli/drift_report.zig"),
+        .target = target,
+    });
+    cli_drift_report_mod.addImport("ananke", ananke_mod);
+    cli_drift_report_mod.addImport("cli_output", cli_output_mod);
+    cli_drift_report_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+    cli_drift_report_mod.addImport("cli_summary", cli_summary_mod);
+    cli_drift_report_mod.addImport("cli_report", cli_report_mod);
+
+    const cli_policy_mod = b.addModule("cli_policy", .{
+        .root_source_file = b.path("src/cli/policy.zig"),
+        .target = target,
+    });
+    cli_policy_mod.addImport("ananke", ananke_mod);
+    cli_policy_mod.addImport("cli_output", cli_output_mod);
+    cli_policy_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+
+    const cli_codequality_mod = b.addModule("cli_codequality", .{
+        .root_source_file = b.path("src/cli/codequality.zig"),
+        .target = target,
+    });
+    cli_codequality_mod.addImport("ananke", ananke_mod);
+    cli_codequality_mod.addImport("cli_output", cli_output_mod);
+    cli_codequality_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+
+    const cli_sonarqube_mod = b.addModule("cli_sonarqube", .{
+        .root_source_file = b.path("src/cli/sonarqube.zig"),
+        .target = target,
+    });
+    cli_sonarqube_mod.addImport("ananke", ananke_mod);
+    cli_sonarqube_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_techdocs_mod = b.addModule("cli_techdocs", .{
+        .root_source_file = b.path("src/cli/techdocs.zig"),
+        .target = target,
+    });
+    cli_techdocs_mod.addImport("ananke", ananke_mod);
+    cli_techdocs_mod.addImport("cli_messages", cli_messages_mod);
+    cli_techdocs_mod.addImport("cli_output", cli_output_mod);
+    cli_techdocs_mod.addImport("cli_report", cli_report_mod);
+    cli_techdocs_mod.addImport("cli_summary", cli_summary_mod);
+
+    const cli_sidecar_mod = b.addModule("cli_sidecar", .{
+        .root_source_file = b.path("src/cli/sidecar.zig"),
+        .target = target,
+    });
+    cli_sidecar_mod.addImport("ananke", ananke_mod);
+    cli_sidecar_mod.addImport("cli_output", cli_output_mod);
+    cli_sidecar_mod.addImport("cli_summary", cli_summary_mod);
+
+    const cli_github_mod = b.addModule("cli_github", .{
+        .root_source_file = b.path("src/cli/github.zig"),
+        .target = target,
+    });
+    cli_github_mod.addImport("ananke", ananke_mod);
+    cli_github_mod.addImport("cli_git", cli_git_mod);
+    cli_github_mod.addImport("cli_output", cli_output_mod);
+    cli_github_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+
+    const cli_metrics_mod = b.addModule("cli_metrics", .{
+        .root_source_file = b.path("src/cli/metrics.zig"),
+        .target = target,
+    });
+
+    const cli_webhook_mod = b.addModule("cli_webhook", .{
+        .root_source_file = b.path("src/cli/webhook.zig"),
+        .target = target,
+    });
+    cli_webhook_mod.addImport("cli_config", cli_config_mod);
+    cli_webhook_mod.addImport("cli_error", cli_error_mod);
+    cli_webhook_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_tracing_mod = b.addModule("cli_tracing", .{
+        .root_source_file = b.path("src/cli/tracing.zig"),
+        .target = target,
+    });
+    cli_tracing_mod.addImport("cli_error", cli_error_mod);
+    cli_tracing_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_notify_mod = b.addModule("cli_notify", .{
+        .root_source_file = b.path("src/cli/notify.zig"),
+        .target = target,
+    });
+    cli_notify_mod.addImport("ananke", ananke_mod);
+    cli_notify_mod.addImport("cli_config", cli_config_mod);
+    cli_notify_mod.addImport("cli_error", cli_error_mod);
+    cli_notify_mod.addImport("cli_output", cli_output_mod);
+    cli_notify_mod.addImport("cli_git", cli_git_mod);
+    cli_notify_mod.addImport("cli_webhook", cli_webhook_mod);
+
+    const cli_json_api_mod = b.addModule("cli_json_api", .{
+        .root_source_file = b.path("src/cli/json_api.zig"),
+        .target = target,
+    });
+
+    const cli_issues_mod = b.addModule("cli_issues", .{
+        .root_source_file = b.path("src/cli/issues.zig"),
+        .target = target,
+    });
+    cli_issues_mod.addImport("ananke", ananke_mod);
+    cli_issues_mod.addImport("cli_output", cli_output_mod);
+    cli_issues_mod.addImport("cli_json_api", cli_json_api_mod);
+
+    const cli_checks_mod = b.addModule("cli_checks", .{
+        .root_source_file = b.path("src/cli/checks.zig"),
+        .target = target,
+    });
+    cli_checks_mod.addImport("ananke", ananke_mod);
+    cli_checks_mod.addImport("cli_output", cli_output_mod);
+    cli_checks_mod.addImport("cli_issues", cli_issues_mod);
+    cli_checks_mod.addImport("cli_json_api", cli_json_api_mod);
+
+    const cli_package_docs_mod = b.addModule("cli_package_docs", .{
+        .root_source_file = b.path("src/cli/package_docs.zig"),
+        .target = target,
+    });
+    cli_package_docs_mod.addImport("ananke", ananke_mod);
+    cli_package_docs_mod.addImport("cli_messages", cli_messages_mod);
+    cli_package_docs_mod.addImport("cli_report", cli_report_mod);
+    cli_package_docs_mod.addImport("cli_summary", cli_summary_mod);
+
+    const cli_go_rules_mod = b.addModule("cli_go_rules", .{
+        .root_source_file = b.path("src/cli/go_rules.zig"),
+        .target = target,
+    });
+
+    const cli_go_http_mod = b.addModule("cli_go_http", .{
+        .root_source_file = b.path("src/cli/go_http.zig"),
+        .target = target,
+    });
+    cli_go_http_mod.addImport("cli_go_rules", cli_go_rules_mod);
+
+    const cli_go_api_mod = b.addModule("cli_go_api", .{
+        .root_source_file = b.path("src/cli/go_api.zig"),
+        .target = target,
+    });
+    cli_go_api_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_layering_mod = b.addModule("cli_layering", .{
+        .root_source_file = b.path("src/cli/layering.zig"),
+        .target = target,
+    });
+    cli_layering_mod.addImport("cli_go_api", cli_go_api_mod);
+    cli_layering_mod.addImport("cli_output", cli_output_mod);
+    cli_constraint_verify_mod.addImport("cli_layering", cli_layering_mod);
+
+    const cli_gotests_mod = b.addModule("cli_gotests", .{
+        .root_source_file = b.path("src/cli/gotests.zig"),
+        .target = target,
+    });
+    cli_gotests_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_gotests_mod.addImport("cli_go_http", cli_go_http_mod);
+
+    const cli_govalidate_mod = b.addModule("cli_govalidate", .{
+        .root_source_file = b.path("src/cli/govalidate.zig"),
+        .target = target,
+    });
+    cli_govalidate_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_govalidate_mod.addImport("cli_go_http", cli_go_http_mod);
+
+    const cli_goassert_mod = b.addModule("cli_goassert", .{
+        .root_source_file = b.path("src/cli/goassert.zig"),
+        .target = target,
+    });
+    cli_goassert_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_goassert_mod.addImport("cli_go_http", cli_go_http_mod);
+    cli_goassert_mod.addImport("cli_govalidate", cli_govalidate_mod);
+
+    const cli_goscaffold_mod = b.addModule("cli_goscaffold", .{
+        .root_source_file = b.path("src/cli/goscaffold.zig"),
+        .target = target,
+    });
+    cli_goscaffold_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_goscaffold_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_ts_rules_mod = b.addModule("cli_ts_rules", .{
+        .root_source_file = b.path("src/cli/ts_rules.zig"),
+        .target = target,
+    });
+    cli_ts_rules_mod.addImport("cli_go_rules", cli_go_rules_mod);
+
+    const cli_dto_consistency_mod = b.addModule("cli_dto_consistency", .{
+        .root_source_file = b.path("src/cli/dto_consistency.zig"),
+        .target = target,
+    });
+    cli_dto_consistency_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_dto_consistency_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_resilience_mod = b.addModule("cli_resilience", .{
+        .root_source_file = b.path("src/cli/resilience.zig"),
+        .target = target,
+    });
+    cli_resilience_mod.addImport("cli_go_http", cli_go_http_mod);
+
+    const cli_policy_packs_mod = b.addModule("cli_policy_packs", .{
+        .root_source_file = b.path("src/cli/policy_packs.zig"),
+        .target = target,
+    });
+    cli_policy_packs_mod.addImport("cli_go_http", cli_go_http_mod);
+    cli_policy_packs_mod.addImport("cli_resilience", cli_resilience_mod);
+    cli_policy_packs_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_code_weakening_mod = b.addModule("cli_code_weakening", .{
+        .root_source_file = b.path("src/cli/code_weakening.zig"),
+        .target = target,
+    });
+    cli_code_weakening_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_code_weakening_mod.addImport("cli_go_http", cli_go_http_mod);
+    cli_code_weakening_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+
+    const cli_requirements_mod = b.addModule("cli_requirements", .{
+        .root_source_file = b.path("src/cli/requirements.zig"),
+        .target = target,
+    });
+    cli_requirements_mod.addImport("ananke", ananke_mod);
+    cli_requirements_mod.addImport("cli_go_http", cli_go_http_mod);
+    cli_requirements_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_requirements_mod.addImport("cli_report", cli_report_mod);
+    cli_requirements_mod.addImport("cli_summary", cli_summary_mod);
+
+    const cli_test_coverage_mod = b.addModule("cli_test_coverage", .{
+        .root_source_file = b.path("src/cli/test_coverage.zig"),
+        .target = target,
+    });
+    cli_test_coverage_mod.addImport("ananke", ananke_mod);
+    cli_test_coverage_mod.addImport("cli_output", cli_output_mod);
+    cli_test_coverage_mod.addImport("cli_summary", cli_summary_mod);
+
+    const cli_quickfix_mod = b.addModule("cli_quickfix", .{
+        .root_source_file = b.path("src/cli/quickfix.zig"),
+        .target = target,
+    });
+    cli_quickfix_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_baseline_mod = b.addModule("cli_baseline", .{
+        .root_source_file = b.path("src/cli/baseline.zig"),
+        .target = target,
+    });
+    cli_baseline_mod.addImport("ananke", ananke_mod);
+    cli_baseline_mod.addImport("cli_output", cli_output_mod);
+    cli_baseline_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+
+    const cli_results_mod = b.addModule("cli_results", .{
+        .root_source_file = b.path("src/cli/results.zig"),
+        .target = target,
+    });
+    cli_results_mod.addImport("ananke", ananke_mod);
+    cli_results_mod.addImport("cli_profiling", cli_profiling_mod);
+
+    const cli_result_index_mod = b.addModule("cli_result_index", .{
+        .root_source_file = b.path("src/cli/result_index.zig"),
+        .target = target,
+    });
+    cli_result_index_mod.addImport("ananke", ananke_mod);
+    cli_result_index_mod.addImport("cli_error", cli_error_mod);
+    cli_result_index_mod.addImport("cli_results", cli_results_mod);
+
+    const cli_similarity_mod = b.addModule("cli_similarity", .{
+        .root_source_file = b.path("src/cli/similarity.zig"),
+        .target = target,
+    });
+
+    const cli_jobs_mod = b.addModule("cli_jobs", .{
+        .root_source_file = b.path("src/cli/jobs.zig"),
+        .target = target,
+    });
+
+    const cli_plan_mod = b.addModule("cli_plan", .{
+        .root_source_file = b.path("src/cli/plan.zig"),
+        .target = target,
+    });
+    cli_plan_mod.addImport("ananke", ananke_mod);
+    cli_plan_mod.addImport("cli_discovery", cli_discovery_mod);
+
+    const cli_remote_cache_mod = b.addModule("cli_remote_cache", .{
+        .root_source_file = b.path("src/cli/remote_cache.zig"),
+        .target = target,
+    });
+
+    const cli_lz4_mod = b.addModule("cli_lz4", .{
+        .root_source_file = b.path("src/cli/lz4.zig"),
+        .target = target,
+    });
+
+    const cli_protobuf_mod = b.addModule("cli_protobuf", .{
+        .root_source_file = b.path("src/cli/protobuf.zig"),
+        .target = target,
+    });
+
+    const cli_action_mod = b.addModule("cli_action", .{
+        .root_source_file = b.path("src/cli/action.zig"),
+        .target = target,
+    });
+    cli_action_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_cache_store_mod = b.addModule("cli_cache_store", .{
+        .root_source_file = b.path("src/cli/cache_store.zig"),
+        .target = target,
+    });
+    cli_cache_store_mod.addImport("ananke", ananke_mod);
+    cli_cache_store_mod.addImport("cli_output", cli_output_mod);
+    cli_cache_store_mod.addImport("cli_remote_cache", cli_remote_cache_mod);
+    cli_cache_store_mod.addImport("cli_lz4", cli_lz4_mod);
+    cli_cache_store_mod.addImport("cli_results", cli_results_mod);
+
+    const cli_rule_history_mod = b.addModule("cli_rule_history", .{
+        .root_source_file = b.path("src/cli/rule_history.zig"),
+        .target = target,
+    });
+    cli_rule_history_mod.addImport("ananke", ananke_mod);
+    cli_rule_history_mod.addImport("cli_cache_store", cli_cache_store_mod);
+
+    const cli_warm_state_mod = b.addModule("cli_warm_state", .{
+        .root_source_file = b.path("src/cli/warm_state.zig"),
+        .target = target,
+    });
+    cli_warm_state_mod.addImport("cli_cache_store", cli_cache_store_mod);
+    cli_warm_state_mod.addImport("cli_discovery", cli_discovery_mod);
+
+    const cli_archive_mod = b.addModule("cli_archive", .{
+        .root_source_file = b.path("src/cli/archive.zig"),
+        .target = target,
+    });
+
     // CLI command modules
+    const cli_version_mod = b.addModule("cli_version", .{
+        .root_source_file = b.path("src/cli/commands/version.zig"),
+        .target = target,
+    });
+    cli_version_mod.addImport("cli_args", cli_args_mod);
+    cli_version_mod.addImport("cli_config", cli_config_mod);
+    cli_version_mod.addImport("cli_output", cli_output_mod);
+
+    const cli_incremental_mod = b.addModule("cli_incremental", .{
+        .root_source_file = b.path("src/cli/incremental.zig"),
+        .target = target,
+    });
+    cli_incremental_mod.addImport("cli_cache_store", cli_cache_store_mod);
+    cli_incremental_mod.addImport("cli_git", cli_git_mod);
+    cli_incremental_mod.addImport("cli_plan", cli_plan_mod);
+
+    const cli_extract_cache_mod = b.addModule("cli_extract_cache", .{
+        .root_source_file = b.path("src/cli/extract_cache.zig"),
+        .target = target,
+    });
+    cli_extract_cache_mod.addImport("ananke", ananke_mod);
+    cli_extract_cache_mod.addImport("cli_args", cli_args_mod);
+    cli_extract_cache_mod.addImport("cli_config", cli_config_mod);
+    cli_extract_cache_mod.addImport("cli_error", cli_error_mod);
+    cli_extract_cache_mod.addImport("cli_cache_store", cli_cache_store_mod);
+    cli_extract_cache_mod.addImport("cli_remote_cache", cli_remote_cache_mod);
+    cli_extract_cache_mod.addImport("cli_rule_history", cli_rule_history_mod);
+    cli_extract_cache_mod.addImport("cli_results", cli_results_mod);
+    cli_extract_cache_mod.addImport("cli_jobs", cli_jobs_mod);
+    cli_extract_cache_mod.addImport("cli_plan", cli_plan_mod);
+    cli_extract_cache_mod.addImport("cli_profiling", cli_profiling_mod);
+    cli_extract_cache_mod.addImport("cli_version", cli_version_mod);
+
+    const cli_extract_pipeline_mod = b.addModule("cli_extract_pipeline", .{
+        .root_source_file = b.path("src/cli/extract_pipeline.zig"),
+        .target = target,
+    });
+    cli_extract_pipeline_mod.addImport("ananke", ananke_mod);
+    cli_extract_pipeline_mod.addImport("cli_args", cli_args_mod);
+    cli_extract_pipeline_mod.addImport("cli_config", cli_config_mod);
+    cli_extract_pipeline_mod.addImport("cli_error", cli_error_mod);
+    cli_extract_pipeline_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_extract_pipeline_mod.addImport("cli_jobs", cli_jobs_mod);
+
+    const cli_extract_trace_mod = b.addModule("cli_extract_trace", .{
+        .root_source_file = b.path("src/cli/extract_trace.zig"),
+        .target = target,
+    });
+    cli_extract_trace_mod.addImport("ananke", ananke_mod);
+    cli_extract_trace_mod.addImport("cli_error", cli_error_mod);
+    cli_extract_trace_mod.addImport("cli_profiling", cli_profiling_mod);
+    cli_extract_trace_mod.addImport("cli_tracing", cli_tracing_mod);
+
     const cli_extract_mod = b.addModule("cli_extract", .{
         .root_source_file = b.path("src/cli/commands/extract.zig"),
         .target = target,
@@ -452,6 +932,497 @@ pub fn build(b: *std.Build) void {
     cli_extract_mod.addImport("cli_error", cli_error_mod);
     cli_extract_mod.addImport("cli_error_help", cli_error_help_mod);
     cli_extract_mod.addImport("path_validator", path_validator_mod);
+    cli_extract_mod.addImport("cli_summary", cli_summary_mod);
+    cli_extract_mod.addImport("cli_prompt_pack", cli_prompt_pack_mod);
+    cli_extract_mod.addImport("cli_cyclonedx", cli_cyclonedx_mod);
+    cli_extract_mod.addImport("cli_codequality", cli_codequality_mod);
+    cli_extract_mod.addImport("cli_sonarqube", cli_sonarqube_mod);
+    cli_extract_mod.addImport("cli_techdocs", cli_techdocs_mod);
+    cli_extract_mod.addImport("cli_requirements", cli_requirements_mod);
+    cli_extract_mod.addImport("cli_sidecar", cli_sidecar_mod);
+    cli_extract_mod.addImport("cli_blame", cli_blame_mod);
+    cli_extract_mod.addImport("cli_summarize", cli_summarize_mod);
+    cli_extract_mod.addImport("cli_build_graph", cli_build_graph_mod);
+    cli_extract_mod.addImport("cli_webhook", cli_webhook_mod);
+    cli_extract_mod.addImport("cli_tracing", cli_tracing_mod);
+    cli_extract_mod.addImport("cli_notify", cli_notify_mod);
+    cli_extract_mod.addImport("cli_annotate", cli_annotate_mod);
+    cli_extract_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_extract_mod.addImport("cli_git", cli_git_mod);
+    cli_extract_mod.addImport("cli_messages", cli_messages_mod);
+    cli_extract_mod.addImport("cli_report", cli_report_mod);
+    cli_extract_mod.addImport("cli_version", cli_version_mod);
+    cli_extract_mod.addImport("cli_baseline", cli_baseline_mod);
+    cli_extract_mod.addImport("cli_jobs", cli_jobs_mod);
+    cli_extract_mod.addImport("cli_plan", cli_plan_mod);
+    cli_extract_mod.addImport("cli_cache_store", cli_cache_store_mod);
+    cli_extract_mod.addImport("cli_action", cli_action_mod);
+    cli_extract_mod.addImport("cli_remote_cache", cli_remote_cache_mod);
+    cli_extract_mod.addImport("cli_similarity", cli_similarity_mod);
+    cli_extract_mod.addImport("cli_warm_state", cli_warm_state_mod);
+    cli_extract_mod.addImport("cli_incremental", cli_incremental_mod);
+    cli_extract_mod.addImport("cli_results", cli_results_mod);
+    cli_extract_mod.addImport("cli_profiling", cli_profiling_mod);
+    cli_extract_mod.addImport("cli_extract_cache", cli_extract_cache_mod);
+    cli_extract_mod.addImport("cli_extract_pipeline", cli_extract_pipeline_mod);
+    cli_extract_mod.addImport("cli_extract_trace", cli_extract_trace_mod);
+
+    const cli_extract_ref_mod = b.addModule("cli_extract_ref", .{
+        .root_source_file = b.path("src/cli/commands/extract_ref.zig"),
+        .target = target,
+    });
+    cli_extract_ref_mod.addImport("ananke", ananke_mod);
+    cli_extract_ref_mod.addImport("cli_args", cli_args_mod);
+    cli_extract_ref_mod.addImport("cli_output", cli_output_mod);
+    cli_extract_ref_mod.addImport("cli_config", cli_config_mod);
+    cli_extract_ref_mod.addImport("cli_error", cli_error_mod);
+    cli_extract_ref_mod.addImport("cli_git", cli_git_mod);
+    cli_extract_ref_mod.addImport("cli/commands/extract", cli_extract_mod);
+    cli_extract_ref_mod.addImport("cli_extract_cache", cli_extract_cache_mod);
+
+    const cli_diff_mod = b.addModule("cli_diff", .{
+        .root_source_file = b.path("src/cli/commands/diff.zig"),
+        .target = target,
+    });
+    cli_diff_mod.addImport("ananke", ananke_mod);
+    cli_diff_mod.addImport("cli_args", cli_args_mod);
+    cli_diff_mod.addImport("cli_output", cli_output_mod);
+    cli_diff_mod.addImport("cli_config", cli_config_mod);
+    cli_diff_mod.addImport("cli_error", cli_error_mod);
+    cli_diff_mod.addImport("cli_git", cli_git_mod);
+    cli_diff_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_diff_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+    cli_diff_mod.addImport("cli_code_weakening", cli_code_weakening_mod);
+    cli_diff_mod.addImport("cli_github", cli_github_mod);
+    cli_diff_mod.addImport("cli/commands/extract", cli_extract_mod);
+    cli_diff_mod.addImport("cli_extract_cache", cli_extract_cache_mod);
+    cli_diff_mod.addImport("cli_extract_pipeline", cli_extract_pipeline_mod);
+
+    const cli_explain_mod = b.addModule("cli_explain", .{
+        .root_source_file = b.path("src/cli/commands/explain.zig"),
+        .target = target,
+    });
+    cli_explain_mod.addImport("ananke", ananke_mod);
+    cli_explain_mod.addImport("cli_args", cli_args_mod);
+    cli_explain_mod.addImport("cli_config", cli_config_mod);
+    cli_explain_mod.addImport("cli_error", cli_error_mod);
+    cli_explain_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_explain_mod.addImport("cli_results", cli_results_mod);
+    cli_explain_mod.addImport("cli_result_index", cli_result_index_mod);
+
+    const cli_query_mod = b.addModule("cli_query", .{
+        .root_source_file = b.path("src/cli/commands/query.zig"),
+        .target = target,
+    });
+    cli_query_mod.addImport("ananke", ananke_mod);
+    cli_query_mod.addImport("cli_args", cli_args_mod);
+    cli_query_mod.addImport("cli_output", cli_output_mod);
+    cli_query_mod.addImport("cli_config", cli_config_mod);
+    cli_query_mod.addImport("cli_error", cli_error_mod);
+    cli_query_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_query_mod.addImport("cli_summary", cli_summary_mod);
+    cli_query_mod.addImport("cli_results", cli_results_mod);
+    cli_query_mod.addImport("cli_result_index", cli_result_index_mod);
+    cli_query_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_profile_mod = b.addModule("cli_profile", .{
+        .root_source_file = b.path("src/cli/commands/profile.zig"),
+        .target = target,
+    });
+    cli_profile_mod.addImport("ananke", ananke_mod);
+    cli_profile_mod.addImport("cli_args", cli_args_mod);
+    cli_profile_mod.addImport("cli_output", cli_output_mod);
+    cli_profile_mod.addImport("cli_config", cli_config_mod);
+    cli_profile_mod.addImport("cli_error", cli_error_mod);
+    cli_profile_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_profile_mod.addImport("cli_profiling", cli_profiling_mod);
+    cli_profile_mod.addImport("cli_version", cli_version_mod);
+    cli_profile_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_cache_mod = b.addModule("cli_cache", .{
+        .root_source_file = b.path("src/cli/commands/cache.zig"),
+        .target = target,
+    });
+    cli_cache_mod.addImport("cli_args", cli_args_mod);
+    cli_cache_mod.addImport("cli_config", cli_config_mod);
+    cli_cache_mod.addImport("cli_output", cli_output_mod);
+    cli_cache_mod.addImport("cli_error", cli_error_mod);
+    cli_cache_mod.addImport("cli_profiling", cli_profiling_mod);
+    cli_cache_mod.addImport("cli_cache_store", cli_cache_store_mod);
+    cli_cache_mod.addImport("cli_jobs", cli_jobs_mod);
+
+    const cli_merge_mod = b.addModule("cli_merge", .{
+        .root_source_file = b.path("src/cli/commands/merge.zig"),
+        .target = target,
+    });
+    cli_merge_mod.addImport("ananke", ananke_mod);
+    cli_merge_mod.addImport("cli_args", cli_args_mod);
+    cli_merge_mod.addImport("cli_output", cli_output_mod);
+    cli_merge_mod.addImport("cli_config", cli_config_mod);
+    cli_merge_mod.addImport("cli_error", cli_error_mod);
+    cli_merge_mod.addImport("cli_results", cli_results_mod);
+    cli_merge_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_stats_mod = b.addModule("cli_stats", .{
+        .root_source_file = b.path("src/cli/commands/stats.zig"),
+        .target = target,
+    });
+    cli_stats_mod.addImport("ananke", ananke_mod);
+    cli_stats_mod.addImport("cli_args", cli_args_mod);
+    cli_stats_mod.addImport("cli_output", cli_output_mod);
+    cli_stats_mod.addImport("cli_config", cli_config_mod);
+    cli_stats_mod.addImport("cli_error", cli_error_mod);
+    cli_stats_mod.addImport("cli_results", cli_results_mod);
+    cli_stats_mod.addImport("cli_profiling", cli_profiling_mod);
+    cli_stats_mod.addImport("cli_cyclonedx", cli_cyclonedx_mod);
+    cli_stats_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_lint_rules_mod = b.addModule("cli_lint_rules", .{
+        .root_source_file = b.path("src/cli/commands/lint_rules.zig"),
+        .target = target,
+    });
+    cli_lint_rules_mod.addImport("ananke", ananke_mod);
+    cli_lint_rules_mod.addImport("cli_args", cli_args_mod);
+    cli_lint_rules_mod.addImport("cli_output", cli_output_mod);
+    cli_lint_rules_mod.addImport("cli_config", cli_config_mod);
+    cli_lint_rules_mod.addImport("cli_error", cli_error_mod);
+    cli_lint_rules_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_export_bundle_mod = b.addModule("cli_export_bundle", .{
+        .root_source_file = b.path("src/cli/commands/export_bundle.zig"),
+        .target = target,
+    });
+    cli_export_bundle_mod.addImport("ananke", ananke_mod);
+    cli_export_bundle_mod.addImport("cli_args", cli_args_mod);
+    cli_export_bundle_mod.addImport("cli_output", cli_output_mod);
+    cli_export_bundle_mod.addImport("cli_config", cli_config_mod);
+    cli_export_bundle_mod.addImport("cli_error", cli_error_mod);
+    cli_export_bundle_mod.addImport("cli_results", cli_results_mod);
+    cli_export_bundle_mod.addImport("cli_archive", cli_archive_mod);
+    cli_export_bundle_mod.addImport("cli_plan", cli_plan_mod);
+    cli_export_bundle_mod.addImport("cli_cyclonedx", cli_cyclonedx_mod);
+    cli_export_bundle_mod.addImport("cli_version", cli_version_mod);
+    cli_export_bundle_mod.addImport("cli/commands/lint_rules", cli_lint_rules_mod);
+
+    const cli_serve_mod = b.addModule("cli_serve", .{
+        .root_source_file = b.path("src/cli/commands/serve.zig"),
+        .target = target,
+    });
+    cli_serve_mod.addImport("ananke", ananke_mod);
+    cli_serve_mod.addImport("cli_args", cli_args_mod);
+    cli_serve_mod.addImport("cli_config", cli_config_mod);
+    cli_serve_mod.addImport("cli_error", cli_error_mod);
+    cli_serve_mod.addImport("cli_messages", cli_messages_mod);
+    cli_serve_mod.addImport("cli_report", cli_report_mod);
+    cli_serve_mod.addImport("cli_results", cli_results_mod);
+    cli_serve_mod.addImport("cli/commands/query", cli_query_mod);
+
+    const cli_bench_mod = b.addModule("cli_bench", .{
+        .root_source_file = b.path("src/cli/commands/bench.zig"),
+        .target = target,
+    });
+    cli_bench_mod.addImport("ananke", ananke_mod);
+    cli_bench_mod.addImport("cli_args", cli_args_mod);
+    cli_bench_mod.addImport("cli_config", cli_config_mod);
+    cli_bench_mod.addImport("cli_error", cli_error_mod);
+    cli_bench_mod.addImport("cli_profiling", cli_profiling_mod);
+    cli_bench_mod.addImport("cli_output", cli_output_mod);
+    cli_bench_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_bench_mod.addImport("cli_cyclonedx", cli_cyclonedx_mod);
+    cli_bench_mod.addImport("cli_version", cli_version_mod);
+    cli_bench_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_index_mod = b.addModule("cli_index", .{
+        .root_source_file = b.path("src/cli/commands/index.zig"),
+        .target = target,
+    });
+    cli_index_mod.addImport("cli_args", cli_args_mod);
+    cli_index_mod.addImport("cli_config", cli_config_mod);
+    cli_index_mod.addImport("cli_error", cli_error_mod);
+    cli_index_mod.addImport("cli_result_index", cli_result_index_mod);
+
+    const cli_daemon_mod = b.addModule("cli_daemon", .{
+        .root_source_file = b.path("src/cli/commands/daemon.zig"),
+        .target = target,
+    });
+    cli_daemon_mod.addImport("ananke", ananke_mod);
+    cli_daemon_mod.addImport("cli_args", cli_args_mod);
+    cli_daemon_mod.addImport("cli_config", cli_config_mod);
+    cli_daemon_mod.addImport("cli_error", cli_error_mod);
+    cli_daemon_mod.addImport("cli_output", cli_output_mod);
+    cli_daemon_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_daemon_mod.addImport("cli_cache_store", cli_cache_store_mod);
+    cli_daemon_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+    cli_daemon_mod.addImport("cli_incremental", cli_incremental_mod);
+    cli_daemon_mod.addImport("cli_warm_state", cli_warm_state_mod);
+    cli_daemon_mod.addImport("cli_results", cli_results_mod);
+    cli_daemon_mod.addImport("cli_metrics", cli_metrics_mod);
+    cli_daemon_mod.addImport("cli_version", cli_version_mod);
+    cli_daemon_mod.addImport("cli/commands/extract", cli_extract_mod);
+    cli_daemon_mod.addImport("cli/commands/query", cli_query_mod);
+
+    const cli_mcp_mod = b.addModule("cli_mcp", .{
+        .root_source_file = b.path("src/cli/commands/mcp.zig"),
+        .target = target,
+    });
+    cli_mcp_mod.addImport("ananke", ananke_mod);
+    cli_mcp_mod.addImport("cli_args", cli_args_mod);
+    cli_mcp_mod.addImport("cli_config", cli_config_mod);
+    cli_mcp_mod.addImport("cli_error", cli_error_mod);
+    cli_mcp_mod.addImport("cli_output", cli_output_mod);
+    cli_mcp_mod.addImport("cli_version", cli_version_mod);
+    cli_mcp_mod.addImport("cli/commands/extract", cli_extract_mod);
+    cli_mcp_mod.addImport("cli/commands/daemon", cli_daemon_mod);
+
+    const cli_rpc_mod = b.addModule("cli_rpc", .{
+        .root_source_file = b.path("src/cli/commands/rpc.zig"),
+        .target = target,
+    });
+    cli_rpc_mod.addImport("ananke", ananke_mod);
+    cli_rpc_mod.addImport("cli_args", cli_args_mod);
+    cli_rpc_mod.addImport("cli_config", cli_config_mod);
+    cli_rpc_mod.addImport("cli_error", cli_error_mod);
+    cli_rpc_mod.addImport("cli_cache_store", cli_cache_store_mod);
+    cli_rpc_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+    cli_rpc_mod.addImport("cli_protobuf", cli_protobuf_mod);
+    cli_rpc_mod.addImport("cli/commands/extract", cli_extract_mod);
+    cli_rpc_mod.addImport("cli/commands/daemon", cli_daemon_mod);
+    cli_rpc_mod.addImport("cli/commands/serve", cli_serve_mod);
+
+    const cli_semgrep_mod = b.addModule("cli_semgrep", .{
+        .root_source_file = b.path("src/cli/semgrep.zig"),
+        .target = target,
+    });
+
+    const cli_import_rules_mod = b.addModule("cli_import_rules", .{
+        .root_source_file = b.path("src/cli/commands/import_rules.zig"),
+        .target = target,
+    });
+    cli_import_rules_mod.addImport("cli_args", cli_args_mod);
+    cli_import_rules_mod.addImport("cli_config", cli_config_mod);
+    cli_import_rules_mod.addImport("cli_error", cli_error_mod);
+    cli_import_rules_mod.addImport("cli_semgrep", cli_semgrep_mod);
+    cli_import_rules_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_embeddings_mod = b.addModule("cli_embeddings", .{
+        .root_source_file = b.path("src/cli/embeddings.zig"),
+        .target = target,
+    });
+    cli_embeddings_mod.addImport("ananke", ananke_mod);
+    cli_embeddings_mod.addImport("cli_output", cli_output_mod);
+    cli_embeddings_mod.addImport("cli_json_api", cli_json_api_mod);
+
+    const cli_export_embeddings_mod = b.addModule("cli_export_embeddings", .{
+        .root_source_file = b.path("src/cli/commands/export_embeddings.zig"),
+        .target = target,
+    });
+    cli_export_embeddings_mod.addImport("ananke", ananke_mod);
+    cli_export_embeddings_mod.addImport("cli_args", cli_args_mod);
+    cli_export_embeddings_mod.addImport("cli_config", cli_config_mod);
+    cli_export_embeddings_mod.addImport("cli_error", cli_error_mod);
+    cli_export_embeddings_mod.addImport("cli_results", cli_results_mod);
+    cli_export_embeddings_mod.addImport("cli_embeddings", cli_embeddings_mod);
+    cli_export_embeddings_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_verify_mod = b.addModule("cli_verify", .{
+        .root_source_file = b.path("src/cli/commands/verify.zig"),
+        .target = target,
+    });
+    cli_verify_mod.addImport("ananke", ananke_mod);
+    cli_verify_mod.addImport("cli_args", cli_args_mod);
+    cli_verify_mod.addImport("cli_output", cli_output_mod);
+    cli_verify_mod.addImport("cli_config", cli_config_mod);
+    cli_verify_mod.addImport("cli_error", cli_error_mod);
+    cli_verify_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_verify_mod.addImport("cli_results", cli_results_mod);
+    cli_verify_mod.addImport("cli_constraint_verify", cli_constraint_verify_mod);
+    cli_verify_mod.addImport("cli_approvals", cli_approvals_mod);
+    cli_verify_mod.addImport("cli/commands/extract", cli_extract_mod);
+    cli_verify_mod.addImport("cli_extract_cache", cli_extract_cache_mod);
+    cli_verify_mod.addImport("cli_extract_pipeline", cli_extract_pipeline_mod);
+
+    const cli_drift_mod = b.addModule("cli_drift", .{
+        .root_source_file = b.path("src/cli/commands/drift.zig"),
+        .target = target,
+    });
+    cli_drift_mod.addImport("ananke", ananke_mod);
+    cli_drift_mod.addImport("cli_args", cli_args_mod);
+    cli_drift_mod.addImport("cli_config", cli_config_mod);
+    cli_drift_mod.addImport("cli_error", cli_error_mod);
+    cli_drift_mod.addImport("cli_results", cli_results_mod);
+    cli_drift_mod.addImport("cli_drift_report", cli_drift_report_mod);
+    cli_drift_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_enforce_mod = b.addModule("cli_enforce", .{
+        .root_source_file = b.path("src/cli/commands/enforce.zig"),
+        .target = target,
+    });
+    cli_enforce_mod.addImport("ananke", ananke_mod);
+    cli_enforce_mod.addImport("cli_args", cli_args_mod);
+    cli_enforce_mod.addImport("cli_config", cli_config_mod);
+    cli_enforce_mod.addImport("cli_error", cli_error_mod);
+    cli_enforce_mod.addImport("cli_results", cli_results_mod);
+    cli_enforce_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+    cli_enforce_mod.addImport("cli_policy", cli_policy_mod);
+    cli_enforce_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_gen_tests_mod = b.addModule("cli_gen_tests", .{
+        .root_source_file = b.path("src/cli/commands/gen_tests.zig"),
+        .target = target,
+    });
+    cli_gen_tests_mod.addImport("cli_args", cli_args_mod);
+    cli_gen_tests_mod.addImport("cli_config", cli_config_mod);
+    cli_gen_tests_mod.addImport("cli_error", cli_error_mod);
+    cli_gen_tests_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_gen_tests_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_gen_tests_mod.addImport("cli_go_http", cli_go_http_mod);
+    cli_gen_tests_mod.addImport("cli_gotests", cli_gotests_mod);
+    cli_gen_tests_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_gen_validators_mod = b.addModule("cli_gen_validators", .{
+        .root_source_file = b.path("src/cli/commands/gen_validators.zig"),
+        .target = target,
+    });
+    cli_gen_validators_mod.addImport("cli_args", cli_args_mod);
+    cli_gen_validators_mod.addImport("cli_config", cli_config_mod);
+    cli_gen_validators_mod.addImport("cli_error", cli_error_mod);
+    cli_gen_validators_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_gen_validators_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_gen_validators_mod.addImport("cli_go_http", cli_go_http_mod);
+    cli_gen_validators_mod.addImport("cli_govalidate", cli_govalidate_mod);
+    cli_gen_validators_mod.addImport("cli/commands/gen_tests", cli_gen_tests_mod);
+    cli_gen_validators_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_inject_asserts_mod = b.addModule("cli_inject_asserts", .{
+        .root_source_file = b.path("src/cli/commands/inject_asserts.zig"),
+        .target = target,
+    });
+    cli_inject_asserts_mod.addImport("cli_args", cli_args_mod);
+    cli_inject_asserts_mod.addImport("cli_config", cli_config_mod);
+    cli_inject_asserts_mod.addImport("cli_error", cli_error_mod);
+    cli_inject_asserts_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_inject_asserts_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_inject_asserts_mod.addImport("cli_go_http", cli_go_http_mod);
+    cli_inject_asserts_mod.addImport("cli_govalidate", cli_govalidate_mod);
+    cli_inject_asserts_mod.addImport("cli_goassert", cli_goassert_mod);
+    cli_inject_asserts_mod.addImport("cli/commands/gen_tests", cli_gen_tests_mod);
+    cli_inject_asserts_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_constraints_doc_mod = b.addModule("cli_constraints_doc", .{
+        .root_source_file = b.path("src/cli/commands/constraints_doc.zig"),
+        .target = target,
+    });
+    cli_constraints_doc_mod.addImport("cli_args", cli_args_mod);
+    cli_constraints_doc_mod.addImport("cli_config", cli_config_mod);
+    cli_constraints_doc_mod.addImport("cli_error", cli_error_mod);
+    cli_constraints_doc_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_constraints_doc_mod.addImport("cli_messages", cli_messages_mod);
+    cli_constraints_doc_mod.addImport("cli_results", cli_results_mod);
+    cli_constraints_doc_mod.addImport("cli_package_docs", cli_package_docs_mod);
+
+    const cli_api_diff_mod = b.addModule("cli_api_diff", .{
+        .root_source_file = b.path("src/cli/commands/api_diff.zig"),
+        .target = target,
+    });
+    cli_api_diff_mod.addImport("cli_args", cli_args_mod);
+    cli_api_diff_mod.addImport("cli_config", cli_config_mod);
+    cli_api_diff_mod.addImport("cli_error", cli_error_mod);
+    cli_api_diff_mod.addImport("cli_git", cli_git_mod);
+    cli_api_diff_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_api_diff_mod.addImport("cli_go_api", cli_go_api_mod);
+    cli_api_diff_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_coverage_mod = b.addModule("cli_coverage", .{
+        .root_source_file = b.path("src/cli/commands/coverage.zig"),
+        .target = target,
+    });
+    cli_coverage_mod.addImport("cli_args", cli_args_mod);
+    cli_coverage_mod.addImport("cli_config", cli_config_mod);
+    cli_coverage_mod.addImport("cli_error", cli_error_mod);
+    cli_coverage_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_coverage_mod.addImport("cli_results", cli_results_mod);
+    cli_coverage_mod.addImport("cli_policy", cli_policy_mod);
+    cli_coverage_mod.addImport("cli_test_coverage", cli_test_coverage_mod);
+    cli_coverage_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_scaffold_mod = b.addModule("cli_scaffold", .{
+        .root_source_file = b.path("src/cli/commands/scaffold.zig"),
+        .target = target,
+    });
+    cli_scaffold_mod.addImport("cli_args", cli_args_mod);
+    cli_scaffold_mod.addImport("cli_config", cli_config_mod);
+    cli_scaffold_mod.addImport("cli_error", cli_error_mod);
+    cli_scaffold_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_scaffold_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_scaffold_mod.addImport("cli_goscaffold", cli_goscaffold_mod);
+    cli_scaffold_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_dto_check_mod = b.addModule("cli_dto_check", .{
+        .root_source_file = b.path("src/cli/commands/dto_check.zig"),
+        .target = target,
+    });
+    cli_dto_check_mod.addImport("cli_args", cli_args_mod);
+    cli_dto_check_mod.addImport("cli_config", cli_config_mod);
+    cli_dto_check_mod.addImport("cli_error", cli_error_mod);
+    cli_dto_check_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_dto_check_mod.addImport("cli_go_rules", cli_go_rules_mod);
+    cli_dto_check_mod.addImport("cli_ts_rules", cli_ts_rules_mod);
+    cli_dto_check_mod.addImport("cli_dto_consistency", cli_dto_consistency_mod);
+    cli_dto_check_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_policy_check_mod = b.addModule("cli_policy_check", .{
+        .root_source_file = b.path("src/cli/commands/policy_check.zig"),
+        .target = target,
+    });
+    cli_policy_check_mod.addImport("cli_args", cli_args_mod);
+    cli_policy_check_mod.addImport("cli_config", cli_config_mod);
+    cli_policy_check_mod.addImport("cli_error", cli_error_mod);
+    cli_policy_check_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_policy_check_mod.addImport("cli_policy_packs", cli_policy_packs_mod);
+    cli_policy_check_mod.addImport("cli_test_coverage", cli_test_coverage_mod);
+    cli_policy_check_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_review_mod = b.addModule("cli_review", .{
+        .root_source_file = b.path("src/cli/commands/review.zig"),
+        .target = target,
+    });
+    cli_review_mod.addImport("ananke", ananke_mod);
+    cli_review_mod.addImport("cli_args", cli_args_mod);
+    cli_review_mod.addImport("cli_config", cli_config_mod);
+    cli_review_mod.addImport("cli_error", cli_error_mod);
+    cli_review_mod.addImport("cli_git", cli_git_mod);
+    cli_review_mod.addImport("cli_results", cli_results_mod);
+    cli_review_mod.addImport("cli_approvals", cli_approvals_mod);
+    cli_review_mod.addImport("cli_constraint_diff", cli_constraint_diff_mod);
+    cli_review_mod.addImport("cli/commands/extract", cli_extract_mod);
+
+    const cli_architecture_mod = b.addModule("cli_architecture", .{
+        .root_source_file = b.path("src/cli/commands/architecture.zig"),
+        .target = target,
+    });
+    cli_architecture_mod.addImport("cli_args", cli_args_mod);
+    cli_architecture_mod.addImport("cli_config", cli_config_mod);
+    cli_architecture_mod.addImport("cli_error", cli_error_mod);
+    cli_architecture_mod.addImport("cli_discovery", cli_discovery_mod);
+    cli_architecture_mod.addImport("cli_layering", cli_layering_mod);
+    cli_architecture_mod.addImport("cli/commands/extract", cli_extract_mod);
+    cli_verify_mod.addImport("cli_layering", cli_layering_mod);
+    cli_verify_mod.addImport("cli/commands/architecture", cli_architecture_mod);
+
+    const cli_fixture_gen_mod = b.addModule("cli_fixture_gen", .{
+        .root_source_file = b.path("src/cli/fixture_gen.zig"),
+        .target = target,
+    });
+
+    const cli_genfixture_mod = b.addModule("cli_genfixture", .{
+        .root_source_file = b.path("src/cli/commands/genfixture.zig"),
+        .target = target,
+    });
+    cli_genfixture_mod.addImport("cli_args", cli_args_mod);
+    cli_genfixture_mod.addImport("cli_config", cli_config_mod);
+    cli_genfixture_mod.addImport("cli_error", cli_error_mod);
+    cli_genfixture_mod.addImport("cli_fixture_gen", cli_fixture_gen_mod);
+    cli_genfixture_mod.addImport("cli/commands/extract", cli_extract_mod);
 
     const cli_compile_mod = b.addModule("cli_compile", .{
         .root_source_file = b.path("src/cli/commands/compile.zig"),
@@ -497,22 +1468,23 @@ pub fn build(b: *std.Build) void {
     cli_validate_mod.addImport("cli_error", cli_error_mod);
     cli_validate_mod.addImport("cli_error_help", cli_error_help_mod);
     cli_validate_mod.addImport("path_validator", path_validator_mod);
+    cli_validate_mod.addImport("cli_webhook", cli_webhook_mod);
+    cli_validate_mod.addImport("cli_version", cli_version_mod);
+    cli_validate_mod.addImport("cli_issues", cli_issues_mod);
+    cli_validate_mod.addImport("cli_github", cli_github_mod);
+    cli_validate_mod.addImport("cli_checks", cli_checks_mod);
+    cli_validate_mod.addImport("cli_git", cli_git_mod);
+    cli_validate_mod.addImport("cli_quickfix", cli_quickfix_mod);
 
     const cli_init_mod = b.addModule("cli_init", .{
         .root_source_file = b.path("src/cli/commands/init.zig"),
         .target = target,
     });
+    cli_init_mod.addImport("ananke", ananke_mod);
     cli_init_mod.addImport("cli_args", cli_args_mod);
     cli_init_mod.addImport("cli_config", cli_config_mod);
     cli_init_mod.addImport("cli_error", cli_error_mod);
-
-    const cli_version_mod = b.addModule("cli_version", .{
-        .root_source_file = b.path("src/cli/commands/version.zig"),
-        .target = target,
-    });
-    cli_version_mod.addImport("cli_args", cli_args_mod);
-    cli_version_mod.addImport("cli_config", cli_config_mod);
-    cli_version_mod.addImport("cli_output", cli_output_mod);
+    cli_init_mod.addImport("cli_discovery", cli_discovery_mod);
 
     const cli_help_mod = b.addModule("cli_help", .{
         .root_source_file = b.path("src/cli/commands/help.zig"),
@@ -522,6 +1494,39 @@ pub fn build(b: *std.Build) void {
     cli_help_mod.addImport("cli_config", cli_config_mod);
     cli_help_mod.addImport("cli_output", cli_output_mod);
     cli_help_mod.addImport("cli/commands/extract", cli_extract_mod);
+    cli_help_mod.addImport("cli/commands/extract_ref", cli_extract_ref_mod);
+    cli_help_mod.addImport("cli/commands/diff", cli_diff_mod);
+    cli_help_mod.addImport("cli/commands/explain", cli_explain_mod);
+    cli_help_mod.addImport("cli/commands/query", cli_query_mod);
+    cli_help_mod.addImport("cli/commands/profile", cli_profile_mod);
+    cli_help_mod.addImport("cli/commands/cache", cli_cache_mod);
+    cli_help_mod.addImport("cli/commands/merge", cli_merge_mod);
+    cli_help_mod.addImport("cli/commands/stats", cli_stats_mod);
+    cli_help_mod.addImport("cli/commands/lint_rules", cli_lint_rules_mod);
+    cli_help_mod.addImport("cli/commands/export_bundle", cli_export_bundle_mod);
+    cli_help_mod.addImport("cli/commands/serve", cli_serve_mod);
+    cli_help_mod.addImport("cli/commands/bench", cli_bench_mod);
+    cli_help_mod.addImport("cli/commands/index", cli_index_mod);
+    cli_help_mod.addImport("cli/commands/daemon", cli_daemon_mod);
+    cli_help_mod.addImport("cli/commands/mcp", cli_mcp_mod);
+    cli_help_mod.addImport("cli/commands/rpc", cli_rpc_mod);
+    cli_help_mod.addImport("cli/commands/import_rules", cli_import_rules_mod);
+    cli_help_mod.addImport("cli/commands/export_embeddings", cli_export_embeddings_mod);
+    cli_help_mod.addImport("cli/commands/verify", cli_verify_mod);
+    cli_help_mod.addImport("cli/commands/drift", cli_drift_mod);
+    cli_help_mod.addImport("cli/commands/enforce", cli_enforce_mod);
+    cli_help_mod.addImport("cli/commands/gen_tests", cli_gen_tests_mod);
+    cli_help_mod.addImport("cli/commands/gen_validators", cli_gen_validators_mod);
+    cli_help_mod.addImport("cli/commands/inject_asserts", cli_inject_asserts_mod);
+    cli_help_mod.addImport("cli/commands/constraints_doc", cli_constraints_doc_mod);
+    cli_help_mod.addImport("cli/commands/api_diff", cli_api_diff_mod);
+    cli_help_mod.addImport("cli/commands/coverage", cli_coverage_mod);
+    cli_help_mod.addImport("cli/commands/scaffold", cli_scaffold_mod);
+    cli_help_mod.addImport("cli/commands/dto_check", cli_dto_check_mod);
+    cli_help_mod.addImport("cli/commands/policy_check", cli_policy_check_mod);
+    cli_help_mod.addImport("cli/commands/review", cli_review_mod);
+    cli_help_mod.addImport("cli/commands/architecture", cli_architecture_mod);
+    cli_help_mod.addImport("cli/commands/genfixture", cli_genfixture_mod);
     cli_help_mod.addImport("cli/commands/compile", cli_compile_mod);
     cli_help_mod.addImport("cli/commands/generate", cli_generate_mod);
     cli_help_mod.addImport("cli/commands/export_spec", cli_export_spec_mod);
@@ -616,6 +1621,39 @@ pub fn build(b: *std.Build) void {
                 .{ .name = "cli/error", .module = cli_error_mod },
                 .{ .name = "cli/error_help", .module = cli_error_help_mod },
                 .{ .name = "cli/commands/extract", .module = cli_extract_mod },
+                .{ .name = "cli/commands/extract_ref", .module = cli_extract_ref_mod },
+                .{ .name = "cli/commands/diff", .module = cli_diff_mod },
+                .{ .name = "cli/commands/explain", .module = cli_explain_mod },
+                .{ .name = "cli/commands/query", .module = cli_query_mod },
+                .{ .name = "cli/commands/profile", .module = cli_profile_mod },
+                .{ .name = "cli/commands/cache", .module = cli_cache_mod },
+                .{ .name = "cli/commands/merge", .module = cli_merge_mod },
+                .{ .name = "cli/commands/stats", .module = cli_stats_mod },
+                .{ .name = "cli/commands/lint_rules", .module = cli_lint_rules_mod },
+                .{ .name = "cli/commands/export_bundle", .module = cli_export_bundle_mod },
+                .{ .name = "cli/commands/serve", .module = cli_serve_mod },
+                .{ .name = "cli/commands/bench", .module = cli_bench_mod },
+                .{ .name = "cli/commands/index", .module = cli_index_mod },
+                .{ .name = "cli/commands/daemon", .module = cli_daemon_mod },
+                .{ .name = "cli/commands/mcp", .module = cli_mcp_mod },
+                .{ .name = "cli/commands/rpc", .module = cli_rpc_mod },
+                .{ .name = "cli/commands/import_rules", .module = cli_import_rules_mod },
+                .{ .name = "cli/commands/export_embeddings", .module = cli_export_embeddings_mod },
+                .{ .name = "cli/commands/verify", .module = cli_verify_mod },
+                .{ .name = "cli/commands/drift", .module = cli_drift_mod },
+                .{ .name = "cli/commands/enforce", .module = cli_enforce_mod },
+                .{ .name = "cli/commands/gen_tests", .module = cli_gen_tests_mod },
+                .{ .name = "cli/commands/gen_validators", .module = cli_gen_validators_mod },
+                .{ .name = "cli/commands/inject_asserts", .module = cli_inject_asserts_mod },
+                .{ .name = "cli/commands/constraints_doc", .module = cli_constraints_doc_mod },
+                .{ .name = "cli/commands/api_diff", .module = cli_api_diff_mod },
+                .{ .name = "cli/commands/coverage", .module = cli_coverage_mod },
+                .{ .name = "cli/commands/scaffold", .module = cli_scaffold_mod },
+                .{ .name = "cli/commands/dto_check", .module = cli_dto_check_mod },
+                .{ .name = "cli/commands/policy_check", .module = cli_policy_check_mod },
+                .{ .name = "cli/commands/review", .module = cli_review_mod },
+                .{ .name = "cli/commands/architecture", .module = cli_architecture_mod },
+                .{ .name = "cli/commands/genfixture", .module = cli_genfixture_mod },
                 .{ .name = "cli/commands/compile", .module = cli_compile_mod },
                 .{ .name = "cli/commands/generate", .module = cli_generate_mod },
                 .{ .name = "cli/commands/export_spec", .module = cli_export_spec_mod },
@@ -1397,6 +2435,121 @@ pub fn build(b: *std.Build) void {
     test_step.dependOn(&run_phase6_new_languages_tests.step);
     test_step.dependOn(&run_extractor_inline_tests.step);
 
+    // Inline tests of the CLI modules. Each is a named module of its own, so
+    // none of them is reachable from the executable's test root.
+    const cli_test_modules = [_]*std.Build.Module{
+        cli_profiling_mod,
+        cli_output_mod,
+        cli_config_mod,
+        cli_summary_mod,
+        cli_prompt_pack_mod,
+        cli_cyclonedx_mod,
+        cli_annotate_mod,
+        cli_messages_mod,
+        cli_report_mod,
+        cli_discovery_mod,
+        cli_git_mod,
+        cli_blame_mod,
+        cli_build_graph_mod,
+        cli_summarize_mod,
+        cli_constraint_diff_mod,
+        cli_approvals_mod,
+        cli_constraint_verify_mod,
+        cli_drift_report_mod,
+        cli_policy_mod,
+        cli_codequality_mod,
+        cli_sonarqube_mod,
+        cli_techdocs_mod,
+        cli_sidecar_mod,
+        cli_github_mod,
+        cli_metrics_mod,
+        cli_webhook_mod,
+        cli_tracing_mod,
+        cli_notify_mod,
+        cli_json_api_mod,
+        cli_issues_mod,
+        cli_checks_mod,
+        cli_package_docs_mod,
+        cli_go_rules_mod,
+        cli_go_http_mod,
+        cli_go_api_mod,
+        cli_layering_mod,
+        cli_gotests_mod,
+        cli_govalidate_mod,
+        cli_goassert_mod,
+        cli_goscaffold_mod,
+        cli_ts_rules_mod,
+        cli_dto_consistency_mod,
+        cli_resilience_mod,
+        cli_policy_packs_mod,
+        cli_code_weakening_mod,
+        cli_requirements_mod,
+        cli_test_coverage_mod,
+        cli_quickfix_mod,
+        cli_baseline_mod,
+        cli_results_mod,
+        cli_result_index_mod,
+        cli_similarity_mod,
+        cli_jobs_mod,
+        cli_plan_mod,
+        cli_remote_cache_mod,
+        cli_lz4_mod,
+        cli_protobuf_mod,
+        cli_action_mod,
+        cli_cache_store_mod,
+        cli_rule_history_mod,
+        cli_warm_state_mod,
+        cli_archive_mod,
+        cli_incremental_mod,
+        cli_extract_cache_mod,
+        cli_extract_pipeline_mod,
+        cli_extract_trace_mod,
+        cli_extract_mod,
+        cli_extract_ref_mod,
+        cli_diff_mod,
+        cli_explain_mod,
+        cli_query_mod,
+        cli_profile_mod,
+        cli_cache_mod,
+        cli_merge_mod,
+        cli_stats_mod,
+        cli_lint_rules_mod,
+        cli_export_bundle_mod,
+        cli_serve_mod,
+        cli_bench_mod,
+        cli_index_mod,
+        cli_daemon_mod,
+        cli_mcp_mod,
+        cli_rpc_mod,
+        cli_semgrep_mod,
+        cli_import_rules_mod,
+        cli_embeddings_mod,
+        cli_export_embeddings_mod,
+        cli_verify_mod,
+        cli_drift_mod,
+        cli_enforce_mod,
+        cli_gen_tests_mod,
+        cli_gen_validators_mod,
+        cli_inject_asserts_mod,
+        cli_constraints_doc_mod,
+        cli_api_diff_mod,
+        cli_coverage_mod,
+        cli_scaffold_mod,
+        cli_dto_check_mod,
+        cli_policy_check_mod,
+        cli_review_mod,
+        cli_architecture_mod,
+        cli_fixture_gen_mod,
+        cli_genfixture_mod,
+    };
+    for (cli_test_modules) |mod| {
+        const cli_mod_tests = b.addTest(.{ .root_module = mod });
+        cli_mod_tests.root_module.link_libc = true;
+        cli_mod_tests.linkSystemLibrary("tree-sitter");
+        inline for (parser_libs) |pl| cli_mod_tests.linkLibrary(pl);
+        test_step.dependOn(&b.addRunArtifact(cli_mod_tests).step);
+    }
+
     // Property-based fuzz test step (run with: zig build test-fuzz -- -ffuzz for continuous fuzzing)
     const fuzz_test_step = b.step("test-fuzz", "Run property-based fuzz tests");
     fuzz_test_step.dependOn(&run_fuzz_tests.step);
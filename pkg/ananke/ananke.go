@@ -0,0 +1,271 @@
+package ananke
+
+/*
+#cgo LDFLAGS: -lananke_shared
+#include <stdlib.h>
+#include "ananke.h"
+
+extern int anankeStreamCallback(void* user_data, size_t source_index, StreamedConstraintFFI* constraint);
+
+static int extract_stream(const char* const* sources, const size_t* source_lens, const char* const* languages, size_t count, uintptr_t handle) {
+	return ananke_extract_stream(sources, source_lens, languages, count,
+		(AnankeStreamCallback)anankeStreamCallback, (void*)handle);
+}
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/cgo"
+	"sync"
+	"unsafe"
+)
+
+// Errors reported by the engine, matching the AnankeError codes of the C interface
+var (
+	ErrNullPointer       = errors.New("ananke: null pointer")
+	ErrAllocationFailure = errors.New("ananke: allocation failure")
+	ErrInvalidInput      = errors.New("ananke: invalid input")
+	ErrExtractionFailed  = errors.New("ananke: extraction failed")
+)
+
+func codeError(code C.int) error {
+	switch code {
+	case 0:
+		return nil
+	case 1:
+		return ErrNullPointer
+	case 2:
+		return ErrAllocationFailure
+	case 3:
+		return ErrInvalidInput
+	case 4:
+		return ErrExtractionFailed
+	default:
+		return fmt.Errorf("ananke: error code %d", int(code))
+	}
+}
+
+// engine serializes calls: the C interface shares one global allocator
+var (
+	engine     sync.Mutex
+	engineInit sync.Once
+	engineErr  error
+)
+
+func lockEngine() error {
+	engine.Lock()
+	engineInit.Do(func() { engineErr = codeError(C.ananke_init()) })
+	if engineErr != nil {
+		engine.Unlock()
+	}
+	return engineErr
+}
+
+// Version of the linked engine
+func Version() string {
+	return C.GoString(C.ananke_version())
+}
+
+// Source is one piece of code to extract from
+type Source struct {
+	// Name identifies the source to the caller, typically its path
+	Name string
+	Code []byte
+	// Language such as "go" or "typescript"; Options.Language when empty
+	Language string
+}
+
+// Options control which constraints are extracted
+type Options struct {
+	// Language of sources that do not name one
+	Language string
+	// Constraints below this confidence (0.0 to 1.0) are dropped
+	MinConfidence float64
+	// Constraint kinds to keep (e.g. "security", "type_safety"); all when empty
+	Kinds []string
+}
+
+func (o Options) keep(c Constraint) bool {
+	if c.Confidence < o.MinConfidence {
+		return false
+	}
+	if len(o.Kinds) == 0 {
+		return true
+	}
+	for _, kind := range o.Kinds {
+		if kind == c.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Constraint is an extracted constraint
+type Constraint struct {
+	// Index of the source in the slice given to Stream
+	Source      int
+	Name        string
+	Description string
+	// Kind name, e.g. "type_safety"
+	Kind string
+	// 1-based line, or 0 when unknown
+	Line       int
+	Confidence float64
+}
+
+// Results iterates over streamed constraints, in the manner of sql.Rows
+type Results interface {
+	// Next waits for the next constraint and reports whether there is one
+	Next() bool
+	// Constraint returned by the last successful Next
+	Constraint() Constraint
+	// Err is the error that ended the stream, if any, once Next returns false
+	Err() error
+	// Close stops the stream and releases the engine; it is safe to call twice
+	Close() error
+}
+
+// Extract returns the constraints of one source
+func Extract(ctx context.Context, source Source, opts Options) ([]Constraint, error) {
+	results := Stream(ctx, []Source{source}, opts)
+	defer results.Close()
+
+	var constraints []Constraint
+	for results.Next() {
+		constraints = append(constraints, results.Constraint())
+	}
+	return constraints, results.Err()
+}
+
+// Stream extracts constraints from the sources in order, delivering each
+// source's constraints as soon as it has been analyzed
+func Stream(ctx context.Context, sources []Source, opts Options) Results {
+	s := &stream{
+		ctx:         ctx,
+		opts:        opts,
+		constraints: make(chan Constraint),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, source := range sources {
+		if source.Language == "" && opts.Language == "" {
+			s.err = fmt.Errorf("%w: no language for source %q", ErrInvalidInput, source.Name)
+			close(s.constraints)
+			close(s.done)
+			return s
+		}
+	}
+	if len(sources) == 0 {
+		close(s.constraints)
+		close(s.done)
+		return s
+	}
+	go s.run(sources)
+	return s
+}
+
+type stream struct {
+	ctx         context.Context
+	opts        Options
+	constraints chan Constraint
+	stop        chan struct{}
+	stopOnce    sync.Once
+	done        chan struct{}
+	current     Constraint
+	// err is written by run before constraints is closed
+	err error
+}
+
+func (s *stream) run(sources []Source) {
+	defer close(s.done)
+	defer close(s.constraints)
+
+	if err := lockEngine(); err != nil {
+		s.err = err
+		return
+	}
+	defer engine.Unlock()
+
+	// The C side keeps the arrays for the whole call, so they live in C memory.
+	// Code is passed with its length, so NUL bytes in it do not cut it short.
+	count := len(sources)
+	size := C.size_t(count) * C.size_t(unsafe.Sizeof(uintptr(0)))
+	codes := unsafe.Slice((**C.char)(C.malloc(size)), count)
+	lens := unsafe.Slice((*C.size_t)(C.malloc(C.size_t(count)*C.size_t(unsafe.Sizeof(C.size_t(0))))), count)
+	languages := unsafe.Slice((**C.char)(C.malloc(size)), count)
+	defer C.free(unsafe.Pointer(&codes[0]))
+	defer C.free(unsafe.Pointer(&lens[0]))
+	defer C.free(unsafe.Pointer(&languages[0]))
+	for i, source := range sources {
+		language := source.Language
+		if language == "" {
+			language = s.opts.Language
+		}
+		codes[i] = (*C.char)(C.CBytes(source.Code))
+		lens[i] = C.size_t(len(source.Code))
+		languages[i] = C.CString(language)
+	}
+	defer func() {
+		for i := range sources {
+			C.free(unsafe.Pointer(codes[i]))
+			C.free(unsafe.Pointer(languages[i]))
+		}
+	}()
+
+	handle := cgo.NewHandle(s)
+	defer handle.Delete()
+	code := C.extract_stream(&codes[0], &lens[0], &languages[0], C.size_t(count), C.uintptr_t(handle))
+	if err := codeError(code); err != nil {
+		s.err = err
+	} else if err := s.ctx.Err(); err != nil {
+		s.err = err
+	}
+}
+
+// deliver hands a constraint to the consumer; false stops the stream
+func (s *stream) deliver(c Constraint) bool {
+	if !s.opts.keep(c) {
+		return s.ctx.Err() == nil
+	}
+	select {
+	case s.constraints <- c:
+		return true
+	case <-s.stop:
+		return false
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+func (s *stream) Next() bool {
+	c, ok := <-s.constraints
+	if ok {
+		s.current = c
+	}
+	return ok
+}
+
+func (s *stream) Constraint() Constraint {
+	return s.current
+}
+
+func (s *stream) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+func (s *stream) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	// Drain so run can finish and release the engine
+	for range s.constraints {
+	}
+	<-s.done
+	return nil
+}
@@ -0,0 +1,33 @@
+// Declarations of the src/ffi/zig_ffi.zig exports used by this package
+#ifndef ANANKE_GO_H
+#define ANANKE_GO_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+typedef struct {
+    const char* name;
+    const char* description;
+    const char* kind;
+    uint32_t line;
+    float confidence;
+} StreamedConstraintFFI;
+
+typedef int (*AnankeStreamCallback)(
+    void* user_data,
+    size_t source_index,
+    const StreamedConstraintFFI* constraint
+);
+
+int ananke_init(void);
+int ananke_extract_stream(
+    const char* const* sources,
+    const size_t* source_lens,
+    const char* const* languages,
+    size_t count,
+    AnankeStreamCallback callback,
+    void* user_data
+);
+const char* ananke_version(void);
+
+#endif // ANANKE_GO_H
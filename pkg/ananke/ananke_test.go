@@ -0,0 +1,143 @@
+//go:build ananke
+
+// These tests call the engine, so they need the shared library:
+//
+//	zig build -Doptimize=ReleaseFast
+//	CGO_LDFLAGS="-L$ANANKE/zig-out/lib -Wl,-rpath,$ANANKE/zig-out/lib" go test -tags ananke ./...
+package ananke_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/rand/ananke/pkg/ananke"
+)
+
+const typedPython = "def add(a: int, b: int) -> int:\n    return a + b\n"
+
+func ExampleExtract() {
+	source := ananke.Source{Name: "add.py", Code: []byte(typedPython), Language: "python"}
+	constraints, err := ananke.Extract(context.Background(), source, ananke.Options{MinConfidence: 0.5})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(constraints) > 0)
+	// Output: true
+}
+
+func TestStreamDeliversSourcesInOrder(t *testing.T) {
+	sources := []ananke.Source{
+		{Name: "greet.ts", Code: []byte("function greet(name: string): string { return name; }"), Language: "typescript"},
+		{Name: "add.py", Code: []byte(typedPython)},
+	}
+	results := ananke.Stream(context.Background(), sources, ananke.Options{Language: "python"})
+	defer results.Close()
+
+	seen := make([]int, len(sources))
+	last := 0
+	for results.Next() {
+		c := results.Constraint()
+		if c.Source < last {
+			t.Fatalf("constraint of source %d after source %d", c.Source, last)
+		}
+		last = c.Source
+		seen[c.Source]++
+	}
+	if err := results.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range seen {
+		if n == 0 {
+			t.Errorf("no constraints for %s", sources[i].Name)
+		}
+	}
+	if err := results.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := results.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
+
+func TestStreamCloseReleasesEngine(t *testing.T) {
+	sources := []ananke.Source{{Name: "add.py", Code: []byte(typedPython), Language: "python"}}
+	results := ananke.Stream(context.Background(), sources, ananke.Options{})
+	if !results.Next() {
+		t.Fatalf("no constraints: %v", results.Err())
+	}
+	if err := results.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if results.Next() {
+		t.Fatal("Next after Close returned a constraint")
+	}
+
+	// A stream closed early no longer holds the engine
+	constraints, err := ananke.Extract(context.Background(), sources[0], ananke.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(constraints) == 0 {
+		t.Fatal("no constraints after an earlier stream was closed")
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	results := ananke.Stream(context.Background(), []ananke.Source{{Name: "x", Code: []byte("x = 1\n")}}, ananke.Options{})
+	if results.Next() {
+		t.Fatal("Next returned a constraint for a source without a language")
+	}
+	if err := results.Err(); !errors.Is(err, ananke.ErrInvalidInput) {
+		t.Fatalf("Err() = %v, want ErrInvalidInput", err)
+	}
+	results.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = ananke.Stream(ctx, []ananke.Source{{Name: "add.py", Code: []byte(typedPython), Language: "python"}}, ananke.Options{})
+	for results.Next() {
+	}
+	if err := results.Err(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Err() = %v, want context.Canceled", err)
+	}
+	results.Close()
+}
+
+func TestExtractFiltersByOptions(t *testing.T) {
+	source := ananke.Source{Name: "add.py", Code: []byte(typedPython), Language: "python"}
+	none, err := ananke.Extract(context.Background(), source, ananke.Options{MinConfidence: 1.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(none) != 0 {
+		t.Fatalf("%d constraints above confidence 1.1", len(none))
+	}
+
+	typed, err := ananke.Extract(context.Background(), source, ananke.Options{Kinds: []string{"type_safety"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range typed {
+		if c.Kind != "type_safety" {
+			t.Errorf("constraint %q of kind %s", c.Name, c.Kind)
+		}
+	}
+}
+
+func TestExtractReadsPastNULBytes(t *testing.T) {
+	prefix := "x = 1\n"
+	truncated, err := ananke.Extract(context.Background(), ananke.Source{Name: "a.py", Code: []byte(prefix), Language: "python"}, ananke.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole, err := ananke.Extract(context.Background(), ananke.Source{Name: "a.py", Code: []byte(prefix + "\x00\n" + typedPython), Language: "python"}, ananke.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(whole) <= len(truncated) {
+		t.Fatalf("%d constraints with code after a NUL byte, %d without it", len(whole), len(truncated))
+	}
+}
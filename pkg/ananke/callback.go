@@ -0,0 +1,32 @@
+package ananke
+
+// Exported functions live apart from ananke.go: a cgo preamble may only
+// declare C functions in a file that uses //export.
+
+/*
+#include "ananke.h"
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"unsafe"
+)
+
+//export anankeStreamCallback
+func anankeStreamCallback(userData unsafe.Pointer, sourceIndex C.size_t, constraint *C.StreamedConstraintFFI) C.int {
+	s := cgo.Handle(uintptr(userData)).Value().(*stream)
+	// The strings are only valid until we return, so GoString copies them
+	keep := s.deliver(Constraint{
+		Source:      int(sourceIndex),
+		Name:        C.GoString(constraint.name),
+		Description: C.GoString(constraint.description),
+		Kind:        C.GoString(constraint.kind),
+		Line:        int(constraint.line),
+		Confidence:  float64(constraint.confidence),
+	})
+	if keep {
+		return 0
+	}
+	return 1
+}
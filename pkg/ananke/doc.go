@@ -0,0 +1,27 @@
+// Package ananke embeds Ananke constraint extraction in Go programs.
+//
+// It calls the engine through the C interface of src/ffi/zig_ffi.zig, so
+// services can extract constraints in-process instead of running the
+// ananke CLI. Build the shared library first and point cgo at it:
+//
+//	zig build -Doptimize=ReleaseFast
+//	export CGO_LDFLAGS="-L$ANANKE/zig-out/lib -Wl,-rpath,$ANANKE/zig-out/lib"
+//
+// Extract returns every constraint of one source; Stream delivers the
+// constraints of several sources as each one is analyzed:
+//
+//	results := ananke.Stream(ctx, sources, ananke.Options{MinConfidence: 0.5})
+//	defer results.Close()
+//	for results.Next() {
+//		c := results.Constraint()
+//		fmt.Println(sources[c.Source].Name, c.Line, c.Name)
+//	}
+//	if err := results.Err(); err != nil {
+//		return err
+//	}
+//
+// The engine is not thread-safe, so calls from different goroutines are
+// serialized. A stream holds the engine until it is drained or closed.
+// Cancelling the context stops a stream between constraints; a source that
+// is being analyzed is finished first.
+package ananke
@@ -0,0 +1,3 @@
+module github.com/rand/ananke/pkg/ananke
+
+go 1.22
@@ -28,6 +28,10 @@ pub const tree_sitter = @import("tree_sitter");
 // Hybrid extractor combining tree-sitter AST with pattern-based fallback
 pub const hybrid_extractor = @import("hybrid_extractor.zig");
 
+// Parse results shared by every pass over one file
+pub const parsed_source = @import("parsed_source.zig");
+const ParsedSource = parsed_source.ParsedSource;
+
 // Convention mining → soft constraints (CLaSH soft-tier)
 pub const conventions = @import("conventions.zig");
 
@@ -43,20 +47,37 @@ const structural_parsing_enabled = true;
 /// Configuration for Clew extraction engine
 pub const Config = struct {
     enable_semantic_detection: bool = false, // opt-in for semantic hole detection
+    /// Constraint kinds to extract. Passes that only produce disabled kinds
+    /// are skipped, so a run without type_safety never walks the AST for
+    /// type information.
+    kinds: std.EnumSet(ConstraintKind) = std.EnumSet(ConstraintKind).initFull(),
+    /// Function-body scans shared across files and engines, so re-extracting
+    /// an edited file rescans only the bodies that changed. Must outlive
+    /// every extraction that uses it.
+    bodies: ?*patterns.BodyCache = null,
 };
 
+/// Scratch memory kept between extractions; a file that needed more releases
+/// the excess
+const scratch_retain_bytes = 4 * 1024 * 1024;
+
 /// Main Clew extraction engine
 pub const Clew = struct {
     allocator: std.mem.Allocator,
     arena: std.heap.ArenaAllocator,
+    /// Temporaries of one extractFromCode call; see scratchAllocator
+    scratch: std.heap.ArenaAllocator,
     claude_client: ?*claude_api.ClaudeClient = null,
     cache: ConstraintCache,
     config: Config,
+    /// Type passes run so far; cache hits and runs without type_safety skip it
+    type_passes: usize = 0,
 
     pub fn init(allocator: std.mem.Allocator) !Clew {
         return .{
             .allocator = allocator,
             .arena = std.heap.ArenaAllocator.init(allocator),
+            .scratch = std.heap.ArenaAllocator.init(allocator),
             .cache = try ConstraintCache.init(allocator),
             .config = .{}, // default config
         };
@@ -66,6 +87,7 @@ pub const Clew = struct {
         return .{
             .allocator = allocator,
             .arena = std.heap.ArenaAllocator.init(allocator),
+            .scratch = std.heap.ArenaAllocator.init(allocator),
             .cache = try ConstraintCache.init(allocator),
             .config = config,
         };
@@ -74,6 +96,7 @@ pub const Clew = struct {
     pub fn deinit(self: *Clew) void {
         self.cache.deinit();
         self.arena.deinit(); // Frees all arena allocations
+        self.scratch.deinit();
     }
 
     /// Get allocator for temporary constraint strings
@@ -81,6 +104,15 @@ pub const Clew = struct {
         return self.arena.allocator();
     }
 
+    /// Allocator for the temporaries of one extraction: the parse tree's
+    /// bookkeeping, pattern matches, and intermediate constraint lists. They
+    /// used to be thousands of individual allocations and frees per large
+    /// file; now they are bump-allocated and released together when
+    /// extractFromCode returns. Nothing returned to callers may live here.
+    fn scratchAllocator(self: *Clew) std.mem.Allocator {
+        return self.scratch.allocator();
+    }
+
     /// Set Claude client for semantic analysis
     pub fn setClaudeClient(self: *Clew, client: *claude_api.ClaudeClient) void {
         self.claude_client = client;
@@ -104,18 +136,29 @@ pub const Clew = struct {
         // Cache miss - extract constraints
         var constraint_set = ConstraintSet.init(self.allocator, "code_constraints");
 
+        // Keep the scratch pages for the next file unless this one was huge
+        defer _ = self.scratch.reset(.{ .retain_with_limit = scratch_retain_bytes });
+
+        // Parse once; the syntactic and type passes share the tree and matches
+        var parsed = ParsedSource.init(self.scratchAllocator(), source);
+        defer parsed.deinit();
+        parsed.bodies = self.config.bodies;
+
         // 1. Tree-sitter parsing for syntactic constraints
-        const syntax_constraints = try self.extractSyntacticConstraints(source, language);
-        defer self.allocator.free(syntax_constraints);
+        const syntax_constraints = try self.extractSyntacticConstraints(&parsed, language);
+        defer self.scratchAllocator().free(syntax_constraints);
         for (syntax_constraints) |constraint| {
-            try constraint_set.add(constraint);
+            if (self.config.kinds.contains(constraint.kind)) try constraint_set.add(constraint);
         }
 
-        // 2. Type-level constraint discovery
-        const type_constraints = try self.extractTypeConstraints(source, language);
-        defer self.allocator.free(type_constraints);
-        for (type_constraints) |constraint| {
-            try constraint_set.add(constraint);
+        // 2. Type-level constraint discovery, only when type constraints are wanted
+        if (self.config.kinds.contains(.type_safety)) {
+            self.type_passes += 1;
+            const type_constraints = try self.extractTypeConstraints(&parsed, language);
+            defer self.scratchAllocator().free(type_constraints);
+            for (type_constraints) |constraint| {
+                try constraint_set.add(constraint);
+            }
         }
 
         // 3. Optional: Use Claude for semantic understanding
@@ -138,7 +181,7 @@ pub const Clew = struct {
                     .source = .LLM_Analysis,
                     .confidence = claude_constraint.confidence,
                 };
-                try constraint_set.add(ananke_constraint);
+                if (self.config.kinds.contains(ananke_constraint.kind)) try constraint_set.add(ananke_constraint);
             }
         }
 
@@ -259,49 +302,51 @@ pub const Clew = struct {
 
     fn extractSyntacticConstraints(
         self: *Clew,
-        source: []const u8,
+        parsed: *ParsedSource,
         language: []const u8,
     ) ![]Constraint {
         // Use structural extractors for supported languages (TypeScript, Python)
         // Falls back to pattern matching for other languages
         if (structural_parsing_enabled) {
             const structural_constraints = extractors.extract(
-                self.allocator,
+                self.scratchAllocator(),
                 self.constraintAllocator(),
-                source,
+                parsed,
                 language,
+                self.config.kinds,
             ) catch |err| {
                 std.log.warn("Structural extraction failed: {}, falling back to pattern matching", .{err});
-                return try self.extractSyntacticConstraintsFallback(source, language);
+                return try self.extractSyntacticConstraintsFallback(parsed, language);
             };
 
             // If we got structural constraints, combine with pattern-based ones for completeness
             if (structural_constraints.len > 0) {
-                const pattern_constraints = try self.extractSyntacticConstraintsFallback(source, language);
-                defer self.allocator.free(pattern_constraints);
+                const pattern_constraints = try self.extractSyntacticConstraintsFallback(parsed, language);
+                defer self.scratchAllocator().free(pattern_constraints);
 
                 // Merge both sets (structural parsing + pattern matching)
                 var combined = std.ArrayList(Constraint){};
-                defer combined.deinit(self.allocator);
+                defer combined.deinit(self.scratchAllocator());
 
-                try combined.appendSlice(self.allocator, structural_constraints);
-                try combined.appendSlice(self.allocator, pattern_constraints);
+                try combined.appendSlice(self.scratchAllocator(), structural_constraints);
+                try combined.appendSlice(self.scratchAllocator(), pattern_constraints);
 
-                return try combined.toOwnedSlice(self.allocator);
+                return try combined.toOwnedSlice(self.scratchAllocator());
             }
         }
 
         // Fallback to pattern-based extraction
-        return try self.extractSyntacticConstraintsFallback(source, language);
+        return try self.extractSyntacticConstraintsFallback(parsed, language);
     }
 
     fn extractSyntacticConstraintsFallback(
         self: *Clew,
-        source: []const u8,
+        parsed: *ParsedSource,
         language: []const u8,
     ) ![]Constraint {
+        const source = parsed.source;
         var constraints = std.ArrayList(Constraint){};
-        defer constraints.deinit(self.allocator);
+        defer constraints.deinit(self.scratchAllocator());
 
         // Get patterns for the specified language
         const lang_patterns = patterns.getPatternsForLanguage(language);
@@ -311,7 +356,7 @@ pub const Clew = struct {
                 std.mem.indexOf(u8, source, "fn") != null or
                 std.mem.indexOf(u8, source, "def") != null)
             {
-                try constraints.append(self.allocator, Constraint{
+                try constraints.append(self.scratchAllocator(), Constraint{
                     .kind = .syntactic,
                     .severity = .info,
                     .name = "has_functions",
@@ -319,50 +364,29 @@ pub const Clew = struct {
                     .source = .AST_Pattern,
                 });
             }
-            return try constraints.toOwnedSlice(self.allocator);
+            return try constraints.toOwnedSlice(self.scratchAllocator());
         }
 
-        // Find all pattern matches
-        const matches = try patterns.findPatternMatches(
-            self.allocator,
-            source,
-            lang_patterns.?,
-            language,
-        );
-        defer self.allocator.free(matches);
+        // Find all pattern matches (owned by `parsed`)
+        const matches = try parsed.patternMatches(lang_patterns.?, language);
 
-        // Track unique constraint types to avoid duplicates
-        var seen_patterns = std.StringHashMap(void).init(self.allocator);
+        // Track unique constraint types (description and kind) to avoid
+        // duplicates. Keys borrow the static rule descriptions, so matching
+        // allocates nothing per match.
+        var seen_patterns = std.StringHashMap(std.EnumSet(ConstraintKind)).init(self.scratchAllocator());
         defer seen_patterns.deinit();
 
-        // Track allocated keys to free them later
-        var pattern_keys = std.ArrayList([]const u8){};
-        defer {
-            for (pattern_keys.items) |key| {
-                self.allocator.free(key);
-            }
-            pattern_keys.deinit(self.allocator);
-        }
-
         // Convert matches to constraints
         for (matches) |match| {
-            // Create unique key for this pattern
-            const key = try std.fmt.allocPrint(
-                self.allocator,
-                "{s}_{s}",
-                .{ match.rule.description, @tagName(match.rule.constraint_kind) },
-            );
-
             // Skip if we've already seen this pattern type
-            if (seen_patterns.contains(key)) {
-                self.allocator.free(key); // Free immediately if duplicate
-                continue;
-            }
-            try seen_patterns.put(key, {});
-            try pattern_keys.append(self.allocator, key); // Track for later cleanup
-
-            // Create constraint from pattern match
-            const name = try self.constraintAllocator().dupe(u8, match.rule.description);
+            const seen = try seen_patterns.getOrPut(match.rule.description);
+            if (!seen.found_existing) seen.value_ptr.* = std.EnumSet(ConstraintKind).initEmpty();
+            if (seen.value_ptr.contains(match.rule.constraint_kind)) continue;
+            seen.value_ptr.insert(match.rule.constraint_kind);
+
+            // Create constraint from pattern match; rule descriptions are
+            // static, so the name is borrowed rather than copied
+            const name = match.rule.description;
             const description = try std.fmt.allocPrint(
                 self.constraintAllocator(),
                 "{s} detected at line {d} in {s} code",
@@ -379,14 +403,15 @@ pub const Clew = struct {
                 .confidence = 0.85, // Pattern-based matching has good but not perfect confidence
             };
 
-            try constraints.append(self.allocator, constraint);
+            try constraints.append(self.scratchAllocator(), constraint);
         }
 
         // Generate summary constraints based on pattern frequency
-        const function_count = countPatternOccurrences(matches, "function");
-        const type_count = countPatternOccurrences(matches, "type");
-        const async_count = countPatternOccurrences(matches, "async");
-        const error_count = countPatternOccurrences(matches, "error");
+        const counts = countPatternOccurrences(matches);
+        const function_count = counts.functions;
+        const type_count = counts.types;
+        const async_count = counts.async_patterns;
+        const error_count = counts.errors;
 
         // Add high-level constraints based on analysis
         if (function_count > 0) {
@@ -395,7 +420,7 @@ pub const Clew = struct {
                 "Code contains {d} function-related constructs",
                 .{function_count},
             );
-            try constraints.append(self.allocator, Constraint{
+            try constraints.append(self.scratchAllocator(), Constraint{
                 .kind = .syntactic,
                 .severity = .info,
                 .name = "function_structure",
@@ -411,7 +436,7 @@ pub const Clew = struct {
                 "Strong type safety with {d} type annotations",
                 .{type_count},
             );
-            try constraints.append(self.allocator, Constraint{
+            try constraints.append(self.scratchAllocator(), Constraint{
                 .kind = .type_safety,
                 .severity = .info,
                 .name = "type_annotations",
@@ -428,7 +453,7 @@ pub const Clew = struct {
                 "Asynchronous code with {d} async patterns",
                 .{async_count},
             );
-            try constraints.append(self.allocator, Constraint{
+            try constraints.append(self.scratchAllocator(), Constraint{
                 .kind = .semantic,
                 .severity = .info,
                 .name = "async_patterns",
@@ -444,7 +469,7 @@ pub const Clew = struct {
                 "Explicit error handling with {d} error patterns",
                 .{error_count},
             );
-            try constraints.append(self.allocator, Constraint{
+            try constraints.append(self.scratchAllocator(), Constraint{
                 .kind = .semantic,
                 .severity = .info,
                 .name = "error_handling",
@@ -454,93 +479,81 @@ pub const Clew = struct {
             });
         }
 
-        return try constraints.toOwnedSlice(self.allocator);
+        return try constraints.toOwnedSlice(self.scratchAllocator());
     }
 
     fn extractTypeConstraints(
         self: *Clew,
-        source: []const u8,
+        parsed: *ParsedSource,
         language: []const u8,
     ) ![]Constraint {
+        const source = parsed.source;
         var constraints = std.ArrayList(Constraint){};
-        errdefer constraints.deinit(self.allocator);
+        errdefer constraints.deinit(self.scratchAllocator());
 
         // Try AST-based extraction first (high confidence)
-        const ast_constraints = self.extractTypeConstraintsFromAST(source, language) catch |err| {
+        const ast_constraints = self.extractTypeConstraintsFromAST(parsed, language) catch |err| {
             // Log the error and fall back to string matching only
             std.log.debug("AST-based type extraction failed: {}, using fallback only", .{err});
             return try self.extractTypeConstraintsFallback(source, language);
         };
-        defer self.allocator.free(ast_constraints);
+        defer self.scratchAllocator().free(ast_constraints);
 
         // Add AST constraints
-        for (ast_constraints) |c| {
-            try constraints.append(self.allocator, c);
-        }
+        var seen_names = std.StringHashMap(void).init(self.scratchAllocator());
+        defer seen_names.deinit();
+        try constraints.appendSlice(self.scratchAllocator(), ast_constraints);
+        for (ast_constraints) |c| try seen_names.put(c.name, {});
 
         // ALWAYS run fallback for patterns AST might miss (like ?. and ?? operators)
         // The fallback uses lower confidence (0.75) so AST results are preferred
         const fallback_constraints = try self.extractTypeConstraintsFallback(source, language);
-        defer self.allocator.free(fallback_constraints);
+        defer self.scratchAllocator().free(fallback_constraints);
 
         // Add fallback constraints, avoiding duplicates by name
         for (fallback_constraints) |fc| {
-            var is_duplicate = false;
-            for (constraints.items) |existing| {
-                if (std.mem.eql(u8, existing.name, fc.name)) {
-                    is_duplicate = true;
-                    break;
-                }
-            }
-            if (!is_duplicate) {
-                try constraints.append(self.allocator, fc);
+            const entry = try seen_names.getOrPut(fc.name);
+            if (!entry.found_existing) {
+                try constraints.append(self.scratchAllocator(), fc);
             }
         }
 
-        return try constraints.toOwnedSlice(self.allocator);
+        return try constraints.toOwnedSlice(self.scratchAllocator());
     }
 
     /// AST-based type constraint extraction using Tree-sitter
     fn extractTypeConstraintsFromAST(
         self: *Clew,
-        source: []const u8,
+        parsed: *ParsedSource,
         language: []const u8,
     ) ![]Constraint {
         var constraints = std.ArrayList(Constraint){};
-        errdefer constraints.deinit(self.allocator);
+        errdefer constraints.deinit(self.scratchAllocator());
 
         // Map language name to tree-sitter Language enum
         const lang = languageFromName(language) orelse {
             // Language not supported by tree-sitter, return empty
-            return try constraints.toOwnedSlice(self.allocator);
+            return try constraints.toOwnedSlice(self.scratchAllocator());
         };
 
-        // Parse source with tree-sitter
-        var parser = tree_sitter.TreeSitterParser.init(self.allocator, lang) catch |err| {
-            std.log.debug("Failed to initialize tree-sitter parser: {}", .{err});
-            return try constraints.toOwnedSlice(self.allocator);
+        // Reuse the tree the syntactic pass already parsed
+        const tree = parsed.syntaxTree(lang) orelse {
+            return try constraints.toOwnedSlice(self.scratchAllocator());
         };
-        defer parser.deinit();
-
-        var tree = parser.parse(source) catch |err| {
-            std.log.debug("Tree-sitter parsing failed: {}", .{err});
-            return try constraints.toOwnedSlice(self.allocator);
-        };
-        defer tree.deinit();
 
         const root_node = tree.rootNode();
 
         // Extract type constraint info using AST traversal
         const type_info = try tree_sitter.traversal.extractTypeConstraintInfo(
-            self.allocator,
+            self.scratchAllocator(),
             root_node,
-            source,
+            parsed.source,
             language,
         );
 
         // Convert TypeConstraintInfo to Constraint objects
         if (type_info.has_any_types) {
-            try constraints.append(self.allocator, Constraint{
+            try constraints.append(self.scratchAllocator(), Constraint{
                 .kind = .type_safety,
                 .severity = .warning,
                 .name = "avoid_any_type",
@@ -551,7 +564,7 @@ pub const Clew = struct {
         }
 
         if (type_info.has_optional_types or type_info.has_null_types) {
-            try constraints.append(self.allocator, Constraint{
+            try constraints.append(self.scratchAllocator(), Constraint{
                 .kind = .type_safety,
                 .severity = .info,
                 .name = "null_safety",
@@ -562,7 +575,7 @@ pub const Clew = struct {
         }
 
         if (type_info.has_union_types) {
-            try constraints.append(self.allocator, Constraint{
+            try constraints.append(self.scratchAllocator(), Constraint{
                 .kind = .type_safety,
                 .severity = .info,
                 .name = "union_types",
@@ -572,7 +585,7 @@ pub const Clew = struct {
             });
         }
 
-        return try constraints.toOwnedSlice(self.allocator);
+        return try constraints.toOwnedSlice(self.scratchAllocator());
     }
 
     /// Fallback type constraint extraction using string matching (lower confidence)
@@ -583,7 +596,7 @@ pub const Clew = struct {
         language: []const u8,
     ) ![]Constraint {
         var constraints = std.ArrayList(Constraint){};
-        defer constraints.deinit(self.allocator);
+        defer constraints.deinit(self.scratchAllocator());
 
         // Only apply TypeScript-specific patterns to TypeScript/JavaScript
         const is_typescript = std.mem.eql(u8, language, "typescript") or
@@ -596,7 +609,7 @@ pub const Clew = struct {
             if (std.mem.indexOf(u8, source, ": any") != null or
                 std.mem.indexOf(u8, source, ": unknown") != null)
             {
-                try constraints.append(self.allocator, Constraint{
+                try constraints.append(self.scratchAllocator(), Constraint{
                     .kind = .type_safety,
                     .severity = .warning,
                     .name = "avoid_any_type",
@@ -615,7 +628,7 @@ pub const Clew = struct {
                 std.mem.indexOf(u8, source, "?.") != null or
                 std.mem.indexOf(u8, source, "??") != null)
             {
-                try constraints.append(self.allocator, Constraint{
+                try constraints.append(self.scratchAllocator(), Constraint{
                     .kind = .type_safety,
                     .severity = .info,
                     .name = "null_safety",
@@ -632,7 +645,7 @@ pub const Clew = struct {
             if (std.mem.indexOf(u8, source, ": Any") != null or
                 std.mem.indexOf(u8, source, "-> Any") != null)
             {
-                try constraints.append(self.allocator, Constraint{
+                try constraints.append(self.scratchAllocator(), Constraint{
                     .kind = .type_safety,
                     .severity = .warning,
                     .name = "avoid_any_type",
@@ -645,7 +658,7 @@ pub const Clew = struct {
             if (std.mem.indexOf(u8, source, "Optional[") != null or
                 std.mem.indexOf(u8, source, "| None") != null)
             {
-                try constraints.append(self.allocator, Constraint{
+                try constraints.append(self.scratchAllocator(), Constraint{
                     .kind = .type_safety,
                     .severity = .info,
                     .name = "null_safety",
@@ -656,10 +669,11 @@ pub const Clew = struct {
             }
         }
 
-        return try constraints.toOwnedSlice(self.allocator);
+        return try constraints.toOwnedSlice(self.scratchAllocator());
     }
 
-    /// Build cache key that includes source content and Claude availability
+    /// Build cache key that includes source content, Claude availability, and
+    /// the enabled constraint kinds
     fn buildCacheKey(self: *Clew, source: []const u8, claude_enabled: bool) ![]const u8 {
         // Create a hash-based key to handle large source files efficiently
         var hasher = std.hash.Wyhash.init(0);
@@ -671,8 +685,8 @@ pub const Clew = struct {
 
         return try std.fmt.allocPrint(
             self.allocator,
-            "{s}{x:0>16}",
-            .{ prefix, source_hash },
+            "{s}{x:0>2}_{x:0>16}",
+            .{ prefix, self.config.kinds.bits.mask, source_hash },
         );
     }
 
@@ -725,6 +739,8 @@ const ConstraintCache = struct {
     allocator: std.mem.Allocator,
     cache: std.StringHashMap(ConstraintSet),
     arena: std.heap.ArenaAllocator,
+    hits: usize = 0,
+    misses: usize = 0,
 
     pub fn init(allocator: std.mem.Allocator) !ConstraintCache {
         return .{
@@ -751,9 +767,11 @@ const ConstraintCache = struct {
     /// Returns null if not found.
     pub fn get(self: *ConstraintCache, key: []const u8) !?ConstraintSet {
         if (self.cache.get(key)) |cached_set| {
+            self.hits += 1;
             // Return a clone using arena allocator (arena owns the cloned data)
             return try cached_set.clone(self.arena.allocator());
         }
+        self.misses += 1;
         return null;
     }
 
@@ -836,15 +854,27 @@ const TestAssertion = struct {
     expected: []const u8,
 };
 
-/// Count how many pattern matches contain a keyword in their description
-fn countPatternOccurrences(matches: []const patterns.PatternMatch, keyword: []const u8) u32 {
-    var count: u32 = 0;
+/// Matches per summary keyword (function, type, async, error) found in
+/// their rule descriptions
+const PatternCounts = struct {
+    functions: u32 = 0,
+    types: u32 = 0,
+    async_patterns: u32 = 0,
+    errors: u32 = 0,
+};
+
+/// Count matches whose rule description mentions each summary keyword, in
+/// one pass over the matches
+fn countPatternOccurrences(matches: []const patterns.PatternMatch) PatternCounts {
+    var counts = PatternCounts{};
     for (matches) |match| {
-        if (std.mem.indexOf(u8, match.rule.description, keyword) != null) {
-            count += 1;
-        }
+        const description = match.rule.description;
+        if (std.mem.indexOf(u8, description, "function") != null) counts.functions += 1;
+        if (std.mem.indexOf(u8, description, "type") != null) counts.types += 1;
+        if (std.mem.indexOf(u8, description, "async") != null) counts.async_patterns += 1;
+        if (std.mem.indexOf(u8, description, "error") != null) counts.errors += 1;
     }
-    return count;
+    return counts;
 }
 
 // Export hole detectors
@@ -19,6 +19,8 @@ pub const swift = @import("extractors/swift.zig");
 
 const HybridExtractor = @import("hybrid_extractor.zig").HybridExtractor;
 const ExtractionStrategy = @import("hybrid_extractor.zig").ExtractionStrategy;
+const ParsedSource = @import("parsed_source.zig").ParsedSource;
+const ConstraintKind = @import("ananke").types.constraint.ConstraintKind;
 
 /// Extract SyntaxStructure from source code for rich context serialization.
 /// Caller owns the returned structure and must call deinit().
@@ -59,12 +61,15 @@ pub fn extractSyntaxStructure(
         return base.SyntaxStructure.init(allocator);
 }
 
-/// Extract structural constraints from source code using hybrid AST+pattern approach
+/// Extract structural constraints from source code using hybrid AST+pattern approach.
+/// `parsed` holds the tree and pattern matches shared with the caller's other passes.
+/// AST walks that only yield kinds outside `kinds` are skipped.
 pub fn extract(
     allocator: std.mem.Allocator,
     constraint_allocator: std.mem.Allocator,
-    source: []const u8,
+    parsed: *ParsedSource,
     language: []const u8,
+    kinds: std.EnumSet(ConstraintKind),
 ) ![]@import("ananke").types.constraint.Constraint {
     // Use tree-sitter with fallback for all supported languages
     const use_hybrid = std.mem.eql(u8, language, "typescript") or
@@ -92,7 +97,7 @@ pub fn extract(
         std.mem.eql(u8, language, "swift");
 
     if (use_hybrid) {
-        return try extractHybrid(allocator, constraint_allocator, source, language);
+        return try extractHybrid(allocator, constraint_allocator, parsed, language, kinds);
     }
 
     // For unsupported languages, return empty constraints
@@ -103,9 +108,11 @@ pub fn extract(
 fn extractHybrid(
     allocator: std.mem.Allocator,
     constraint_allocator: std.mem.Allocator,
-    source: []const u8,
+    parsed: *ParsedSource,
     language: []const u8,
+    kinds: std.EnumSet(ConstraintKind),
 ) ![]@import("ananke").types.constraint.Constraint {
+    const source = parsed.source;
     var all_constraints = std.ArrayList(@import("ananke").types.constraint.Constraint){};
     // Note: constraint strings are allocated with constraint_allocator (arena)
     // On error, the arena will be freed by Clew, so we only need to free the ArrayList
@@ -114,6 +121,8 @@ fn extractHybrid(
     // 1. Extract AST-based constraints using HybridExtractor
     var hybrid_extractor = try HybridExtractor.init(allocator, .tree_sitter_with_fallback);
     defer hybrid_extractor.deinit();
+    hybrid_extractor.shared = parsed;
+    hybrid_extractor.kinds = kinds;
 
     // Normalize language name for tree-sitter
     const ts_language = if (std.mem.eql(u8, language, "ts"))
@@ -140,6 +149,12 @@ fn extractHybrid(
     // Don't free individual strings - they'll be freed when hybrid_extractor.deinit() is called
     defer ast_result.deinit(allocator);
 
+    // Names already collected, for the duplicate check below (a linear scan
+    // per constraint made large files quadratic)
+    var seen_names = std.StringHashMap(void).init(allocator);
+    defer seen_names.deinit();
+    try all_constraints.ensureTotalCapacity(constraint_allocator, ast_result.constraints.len);
+
     // Add AST constraints to our collection (duplicate strings to constraint_allocator for ownership)
     for (ast_result.constraints) |ast_constraint| {
         const new_constraint = @import("ananke").types.constraint.Constraint{
@@ -152,7 +167,8 @@ fn extractHybrid(
             .frequency = ast_constraint.frequency,
             .origin_line = ast_constraint.origin_line,
         };
-        try all_constraints.append(constraint_allocator, new_constraint);
+        all_constraints.appendAssumeCapacity(new_constraint);
+        try seen_names.put(new_constraint.name, {});
     }
 
     // 2. Extract pattern-based constraints using language-specific extractors
@@ -194,19 +210,12 @@ fn extractHybrid(
     // The arena will be freed when Clew.deinit() is called, so we don't manually free
     const pattern_constraints = try structure.toConstraints(constraint_allocator);
 
-    // Merge pattern constraints, avoiding duplicates
+    // Merge pattern constraints, avoiding duplicates by name
+    try all_constraints.ensureUnusedCapacity(constraint_allocator, pattern_constraints.len);
     for (pattern_constraints) |pattern_constraint| {
-        // Simple duplicate check based on name
-        var is_duplicate = false;
-        for (all_constraints.items) |existing| {
-            if (std.mem.eql(u8, existing.name, pattern_constraint.name)) {
-                is_duplicate = true;
-                break;
-            }
-        }
-
-        if (!is_duplicate) {
-            try all_constraints.append(constraint_allocator, pattern_constraint);
+        const entry = try seen_names.getOrPut(pattern_constraint.name);
+        if (!entry.found_existing) {
+            all_constraints.appendAssumeCapacity(pattern_constraint);
         }
     }
 
@@ -13,6 +13,7 @@ const Traversal = tree_sitter.Traversal;
 
 // Import pattern-based extractors
 const patterns = @import("patterns.zig");
+const ParsedSource = @import("parsed_source.zig").ParsedSource;
 
 /// Extraction strategy for hybrid approach
 pub const ExtractionStrategy = enum {
@@ -56,6 +57,12 @@ pub const HybridExtractor = struct {
     allocator: Allocator,
     strategy: ExtractionStrategy,
     interner: StringInterner,
+    /// Parse results shared with the caller's other passes over the same
+    /// source; when null, each extraction parses and scans on its own
+    shared: ?*ParsedSource = null,
+    /// Kinds the caller keeps; type declarations are only walked when
+    /// type_safety is among them
+    kinds: std.EnumSet(ConstraintKind) = std.EnumSet(ConstraintKind).initFull(),
 
     pub fn init(allocator: Allocator, strategy: ExtractionStrategy) !HybridExtractor {
         return .{
@@ -209,23 +216,25 @@ pub const HybridExtractor = struct {
             try pattern_constraints_used.append(self.allocator, false);
         }
 
-        // Merge pattern constraints, avoiding duplicates
+        // Merge pattern constraints, skipping any whose name and kind an
+        // earlier constraint already has. Names seen so far map to their
+        // kinds, so templated code with thousands of matches is not compared
+        // pairwise.
+        var seen = std.StringHashMap(std.EnumSet(ConstraintKind)).init(self.allocator);
+        defer seen.deinit();
+        for (all_constraints.items) |existing| {
+            const entry = try seen.getOrPut(existing.name);
+            if (!entry.found_existing) entry.value_ptr.* = std.EnumSet(ConstraintKind).initEmpty();
+            entry.value_ptr.insert(existing.kind);
+        }
         for (pattern_constraints, 0..) |pattern_constraint, idx| {
-            // Simple duplicate check based on name and kind
-            var is_duplicate = false;
-            for (all_constraints.items) |existing| {
-                if (std.mem.eql(u8, existing.name, pattern_constraint.name) and
-                    existing.kind == pattern_constraint.kind)
-                {
-                    is_duplicate = true;
-                    break;
-                }
-            }
+            const entry = try seen.getOrPut(pattern_constraint.name);
+            if (!entry.found_existing) entry.value_ptr.* = std.EnumSet(ConstraintKind).initEmpty();
+            if (entry.value_ptr.contains(pattern_constraint.kind)) continue;
+            entry.value_ptr.insert(pattern_constraint.kind);
 
-            if (!is_duplicate) {
-                try all_constraints.append(self.allocator, pattern_constraint);
-                pattern_constraints_used.items[idx] = true;
-            }
+            try all_constraints.append(self.allocator, pattern_constraint);
+            pattern_constraints_used.items[idx] = true;
         }
 
         // NOTE: With string interning, we don't free unused constraint strings
@@ -245,12 +254,26 @@ pub const HybridExtractor = struct {
         source: []const u8,
         language: Language,
     ) ![]Constraint {
+        if (self.shared) |shared| {
+            const tree = shared.syntaxTree(language) orelse return error.ParseFailed;
+            return try self.extractFromTree(tree, source);
+        }
+
         var parser = try TreeSitterParser.init(self.allocator, language);
         defer parser.deinit();
 
-        var tree = try parser.parse(source);
+        const tree = try parser.parse(source);
         defer tree.deinit();
 
+        return try self.extractFromTree(tree, source);
+    }
+
+    /// Extract constraints from an already parsed tree
+    fn extractFromTree(
+        self: *HybridExtractor,
+        tree: *tree_sitter.Tree,
+        source: []const u8,
+    ) ![]Constraint {
         const root = tree.rootNode();
 
         // Check for parse errors
@@ -300,7 +323,10 @@ pub const HybridExtractor = struct {
         }
 
         // Extract individual type identifiers (classes, interfaces, etc.)
-        const type_ids = try tree_sitter.traversal.extractTypeIdentifiers(self.allocator, root, source);
+        const type_ids = if (self.kinds.contains(.type_safety))
+            try tree_sitter.traversal.extractTypeIdentifiers(self.allocator, root, source)
+        else
+            &.{};
         defer {
             for (type_ids) |*type_id| {
                 var mut_type_id = type_id.*;
@@ -380,14 +406,12 @@ pub const HybridExtractor = struct {
             return try constraints.toOwnedSlice(self.allocator);
         };
 
-        // Find all pattern matches
-        const matches = try patterns.findPatternMatches(
-            self.allocator,
-            source,
-            lang_patterns,
-            language_name,
-        );
-        defer self.allocator.free(matches);
+        // Find all pattern matches; shared matches are owned by the ParsedSource
+        const matches = if (self.shared) |shared|
+            try shared.patternMatches(lang_patterns, language_name)
+        else
+            try patterns.findPatternMatches(self.allocator, source, lang_patterns, language_name);
+        defer if (self.shared == null) self.allocator.free(matches);
 
         // Track unique constraint types to avoid duplicates
         var seen_patterns = std.StringHashMap(void).init(self.allocator);
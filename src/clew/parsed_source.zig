@@ -0,0 +1,114 @@
+// Per-file parse results shared across extraction passes
+// The syntactic, hybrid, and type passes each used to build their own
+// tree-sitter tree and pattern scan of the same source, so every enabled pass
+// paid for a full parse. ParsedSource builds each artifact on first use and
+// hands the same one to every later pass for that file.
+const std = @import("std");
+const Allocator = std.mem.Allocator;
+
+const tree_sitter = @import("tree_sitter");
+const patterns = @import("patterns.zig");
+
+pub const ParsedSource = struct {
+    allocator: Allocator,
+    source: []const u8,
+
+    tree_state: TreeState = .unparsed,
+    parser: ?tree_sitter.TreeSitterParser = null,
+
+    matches: ?[]patterns.PatternMatch = null,
+    /// Language the cached matches were scanned with; comment and string
+    /// rules depend on it, so a different name rescans
+    matches_language: []const u8 = "",
+
+    /// Number of tree-sitter parses and pattern scans actually performed
+    parse_count: usize = 0,
+    scan_count: usize = 0,
+    /// Function bodies whose matches were copied from an identical earlier
+    /// body instead of scanned, in the last scan
+    cloned_bodies: usize = 0,
+    /// Body scans from earlier files and runs; see patterns.BodyCache
+    bodies: ?*patterns.BodyCache = null,
+
+    const TreeState = union(enum) {
+        unparsed,
+        /// Parsing failed or the grammar is unavailable; not retried
+        failed: tree_sitter.Language,
+        parsed: struct {
+            language: tree_sitter.Language,
+            tree: *tree_sitter.Tree,
+        },
+    };
+
+    pub fn init(allocator: Allocator, source: []const u8) ParsedSource {
+        return .{ .allocator = allocator, .source = source };
+    }
+
+    pub fn deinit(self: *ParsedSource) void {
+        self.releaseTree();
+        if (self.matches) |matches| self.allocator.free(matches);
+        self.* = undefined;
+    }
+
+    /// Tree-sitter tree for the source, parsed on the first call. Returns null
+    /// when the grammar cannot be loaded or parsing fails; the failure is
+    /// remembered so later passes go straight to their fallbacks.
+    pub fn syntaxTree(self: *ParsedSource, language: tree_sitter.Language) ?*tree_sitter.Tree {
+        switch (self.tree_state) {
+            .parsed => |parsed| if (parsed.language == language) return parsed.tree,
+            .failed => |failed| if (failed == language) return null,
+            .unparsed => {},
+        }
+        self.releaseTree();
+
+        self.parse_count += 1;
+        self.parser = tree_sitter.TreeSitterParser.init(self.allocator, language) catch |err| {
+            std.log.debug("Failed to initialize tree-sitter parser: {}", .{err});
+            self.tree_state = .{ .failed = language };
+            return null;
+        };
+        const tree = self.parser.?.parse(self.source) catch |err| {
+            std.log.debug("Tree-sitter parsing failed: {}", .{err});
+            self.tree_state = .{ .failed = language };
+            return null;
+        };
+        self.tree_state = .{ .parsed = .{ .language = language, .tree = tree } };
+        return tree;
+    }
+
+    /// Pattern matches for the source, scanned on the first call per language.
+    /// Repeated function bodies are scanned once, and bodies found in
+    /// `bodies` not at all; see findPatternMatchesCached.
+    pub fn patternMatches(
+        self: *ParsedSource,
+        lang_patterns: patterns.LanguagePatterns,
+        language: []const u8,
+    ) ![]const patterns.PatternMatch {
+        if (self.matches) |matches| {
+            if (std.mem.eql(u8, self.matches_language, language)) return matches;
+            self.allocator.free(matches);
+            self.matches = null;
+        }
+        self.scan_count += 1;
+        const bodies = try patterns.findBodySpans(self.allocator, self.source, lang_patterns);
+        defer self.allocator.free(bodies);
+        self.cloned_bodies = 0;
+        for (bodies) |body| {
+            if (body.original != null) self.cloned_bodies += 1;
+        }
+        const matches = try patterns.findPatternMatchesCached(self.allocator, self.source, lang_patterns, language, bodies, self.bodies);
+        self.matches = matches;
+        self.matches_language = language;
+        return matches;
+    }
+
+    fn releaseTree(self: *ParsedSource) void {
+        switch (self.tree_state) {
+            .parsed => |parsed| parsed.tree.deinit(),
+            else => {},
+        }
+        if (self.parser) |*parser| parser.deinit();
+        self.parser = null;
+        self.tree_state = .unparsed;
+    }
+};
@@ -1,5 +1,7 @@
 // Pattern-based constraint extraction for multiple languages
-// Provides comprehensive regex patterns for TypeScript, Python, Rust, and Zig
+// Provides comprehensive regex patterns for TypeScript, Python, Rust, and Zig.
+// Each language's rules are compiled once per process into a shared pool
+// (see `compile`) instead of being re-indexed for every file scanned.
 const std = @import("std");
 
 const root = @import("ananke");
@@ -2637,6 +2639,162 @@ pub const ContextRules = struct {
     }
 };
 
+// ============================================================================
+// Compiled rule pool
+// ============================================================================
+
+/// A language's rules flattened in scan order and bucketed by first byte, so
+/// a code position is compared only against rules that can start there.
+/// Built once per language by `compile` and shared by every scan and thread.
+pub const CompiledPatterns = struct {
+    source: LanguagePatterns,
+    /// Language name the rules were first compiled for (reporting only)
+    language: []const u8,
+    rules: []const *const PatternRule,
+    /// Indexes into `rules` by first byte, in scan order; rules with an
+    /// empty pattern are listed under every byte
+    buckets: [256][]const u16,
+    /// Work per rule, indexed like `rules`; only recorded while rule timing
+    /// is enabled (see setRuleTiming)
+    stats: []RuleStats,
+    /// Identifies the rules and their order, so stored scans that refer to
+    /// rules by index are only replayed against the same list
+    digest: [16]u8,
+
+    fn isFor(self: *const CompiledPatterns, other: LanguagePatterns) bool {
+        inline for (std.meta.fields(LanguagePatterns)) |field| {
+            const a = @field(self.source, field.name);
+            const b = @field(other, field.name);
+            if (a.ptr != b.ptr or a.len != b.len) return false;
+        }
+        return true;
+    }
+};
+
+pub const RuleStats = struct {
+    compares: std.atomic.Value(u64) = std.atomic.Value(u64).init(0),
+    matches: std.atomic.Value(u64) = std.atomic.Value(u64).init(0),
+    ns: std.atomic.Value(u64) = std.atomic.Value(u64).init(0),
+};
+
+/// Compiled rule sets live for the whole process; a handful of languages
+/// are ever compiled, so the pool is a short list
+var pool_mutex: std.Thread.Mutex = .{};
+var pool: std.ArrayList(*CompiledPatterns) = .{};
+const pool_allocator = std.heap.page_allocator;
+
+/// Reading the clock around every comparison slows scanning several times
+/// over, so per-rule timing is opt-in
+var rule_timing = std.atomic.Value(bool).init(false);
+
+/// Start or stop recording per-rule comparison counts and time
+pub fn setRuleTiming(enabled: bool) void {
+    rule_timing.store(enabled, .monotonic);
+}
+
+/// The compiled form of `lang_patterns`, built on first use
+pub fn compile(lang_patterns: LanguagePatterns, language: []const u8) !*const CompiledPatterns {
+    pool_mutex.lock();
+    defer pool_mutex.unlock();
+    for (pool.items) |compiled| {
+        if (compiled.isFor(lang_patterns)) return compiled;
+    }
+
+    const all_patterns = [_][]const PatternRule{
+        lang_patterns.function_decl,
+        lang_patterns.type_annotation,
+        lang_patterns.async_pattern,
+        lang_patterns.error_handling,
+        lang_patterns.imports,
+        lang_patterns.class_struct,
+        lang_patterns.metadata,
+        lang_patterns.memory_management,
+    };
+    var rules = std.ArrayList(*const PatternRule){};
+    for (all_patterns) |pattern_set| {
+        for (pattern_set) |*rule| try rules.append(pool_allocator, rule);
+    }
+
+    var counts = [_]usize{0} ** 256;
+    for (rules.items) |rule| {
+        if (rule.pattern.len == 0) {
+            for (&counts) |*count| count.* += 1;
+        } else {
+            counts[rule.pattern[0]] += 1;
+        }
+    }
+    var buckets: [256][]u16 = undefined;
+    for (&buckets, counts) |*bucket, count| bucket.* = try pool_allocator.alloc(u16, count);
+    var filled = [_]usize{0} ** 256;
+    for (rules.items, 0..) |rule, index| {
+        for (0..256) |byte| {
+            if (rule.pattern.len != 0 and rule.pattern[0] != byte) continue;
+            buckets[byte][filled[byte]] = @intCast(index);
+            filled[byte] += 1;
+        }
+    }
+
+    const stats = try pool_allocator.alloc(RuleStats, rules.items.len);
+    @memset(stats, .{});
+    var hasher = std.crypto.hash.sha2.Sha256.init(.{});
+    for (rules.items) |rule| {
+        hasher.update(rule.pattern);
+        hasher.update(&.{0});
+        hasher.update(@tagName(rule.constraint_kind));
+        hasher.update(&.{0});
+        hasher.update(rule.description);
+        hasher.update(&.{0});
+    }
+    const compiled = try pool_allocator.create(CompiledPatterns);
+    compiled.* = .{
+        .source = lang_patterns,
+        .language = try pool_allocator.dupe(u8, language),
+        .rules = try rules.toOwnedSlice(pool_allocator),
+        .buckets = undefined,
+        .stats = stats,
+        .digest = hasher.finalResult()[0..16].*,
+    };
+    for (&compiled.buckets, buckets) |*bucket, built| bucket.* = built;
+    try pool.append(pool_allocator, compiled);
+    return compiled;
+}
+
+/// Recorded work of one rule
+pub const RuleTiming = struct {
+    language: []const u8,
+    rule: *const PatternRule,
+    compares: u64,
+    matches: u64,
+    ns: u64,
+};
+
+/// Every rule with recorded comparisons, slowest first. Caller owns the slice.
+pub fn ruleTimings(allocator: std.mem.Allocator) ![]RuleTiming {
+    pool_mutex.lock();
+    defer pool_mutex.unlock();
+    var timings = std.ArrayList(RuleTiming){};
+    errdefer timings.deinit(allocator);
+    for (pool.items) |compiled| {
+        for (compiled.rules, compiled.stats) |rule, *stats| {
+            const compares = stats.compares.load(.monotonic);
+            if (compares == 0) continue;
+            try timings.append(allocator, .{
+                .language = compiled.language,
+                .rule = rule,
+                .compares = compares,
+                .matches = stats.matches.load(.monotonic),
+                .ns = stats.ns.load(.monotonic),
+            });
+        }
+    }
+    std.mem.sort(RuleTiming, timings.items, {}, struct {
+        fn slower(_: void, a: RuleTiming, b: RuleTiming) bool {
+            return a.ns > b.ns;
+        }
+    }.slower);
+    return timings.toOwnedSlice(allocator);
+}
+
 /// Lexer state for tracking context (inside comments, strings, etc.)
 const LexerState = enum {
     code,
@@ -2656,6 +2814,244 @@ fn startsWithAt(source: []const u8, pos: usize, prefix: []const u8) bool {
     return std.mem.eql(u8, source[pos .. pos + prefix.len], prefix);
 }
 
+/// A function body: the lines after a function declaration line up to the
+/// next declaration line (or the end of the source)
+pub const BodySpan = struct {
+    start: usize,
+    end: usize,
+    /// Index of the earlier span with identical bytes, if any
+    original: ?usize = null,
+};
+
+/// Split `source` into function bodies and link each body to the first
+/// earlier body with the same bytes. Generated code often repeats one body
+/// under many names (the OperationN fixtures), and a clone's matches can be
+/// copied from its original instead of being scanned again.
+pub fn findBodySpans(allocator: std.mem.Allocator, source: []const u8, lang_patterns: LanguagePatterns) ![]BodySpan {
+    var spans = std.ArrayList(BodySpan){};
+    errdefer spans.deinit(allocator);
+
+    var line_start: usize = 0;
+    while (line_start < source.len) {
+        const line_end = std.mem.indexOfScalarPos(u8, source, line_start, '\n') orelse source.len;
+        const line = std.mem.trimLeft(u8, source[line_start..line_end], " \t");
+        const next = @min(line_end + 1, source.len);
+        for (lang_patterns.function_decl) |rule| {
+            if (rule.pattern.len == 0 or !std.mem.startsWith(u8, line, rule.pattern)) continue;
+            // The previous body ends where this declaration starts
+            if (spans.items.len > 0) spans.items[spans.items.len - 1].end = line_start;
+            try spans.append(allocator, .{ .start = next, .end = source.len });
+            break;
+        }
+        line_start = next;
+    }
+
+    var first_by_hash = std.AutoHashMap(u64, usize).init(allocator);
+    defer first_by_hash.deinit();
+    for (spans.items, 0..) |*span, index| {
+        const body = source[span.start..span.end];
+        if (body.len == 0) continue;
+        const entry = try first_by_hash.getOrPut(std.hash.Wyhash.hash(0, body));
+        if (!entry.found_existing) {
+            entry.value_ptr.* = index;
+            continue;
+        }
+        const first = spans.items[entry.value_ptr.*];
+        if (std.mem.eql(u8, source[first.start..first.end], body)) span.original = entry.value_ptr.*;
+    }
+    return spans.toOwnedSlice(allocator);
+}
+
+/// Identifies one body scan: the body's bytes, the language (comment and
+/// string rules), and the compiled rule set
+pub const BodyKey = [16]u8;
+
+fn bodyKey(compiled: *const CompiledPatterns, language: []const u8, body: []const u8) BodyKey {
+    var hasher = std.crypto.hash.sha2.Sha256.init(.{});
+    hasher.update(&compiled.digest);
+    hasher.update(language);
+    hasher.update(&.{0});
+    hasher.update(body);
+    return hasher.finalResult()[0..16].*;
+}
+
+/// A match inside a stored body scan
+pub const BodyMatch = struct {
+    /// Index into the compiled rules
+    rule: u16,
+    /// Line within the body, from 0
+    line: u32,
+    column: u32,
+};
+
+/// What scanning one body produced, independent of where the body sits
+pub const BodyScan = struct {
+    lines: u32,
+    end_state: LexerState,
+    matches: []const BodyMatch,
+    /// Replayed or stored since the cache was created; `save` writes these first
+    used: bool = false,
+};
+
+/// Scans of function bodies kept across files, extractions, and (through
+/// `save` and `load`) runs. Re-extracting a file with one edited function
+/// scans that function's body and replays every other body from here, so
+/// the cost of an edit no longer grows with the size of the file.
+/// Thread-safe; the engines of concurrent workers share one cache.
+pub const BodyCache = struct {
+    allocator: std.mem.Allocator,
+    mutex: std.Thread.Mutex = .{},
+    entries: std.AutoHashMapUnmanaged(BodyKey, BodyScan) = .{},
+    /// Bodies replayed, looked up in vain, and stored since init
+    hits: usize = 0,
+    misses: usize = 0,
+    added: usize = 0,
+
+    /// Entries written by `save`, the ones used since init first
+    pub const max_saved_entries = 256 * 1024;
+
+    pub fn init(allocator: std.mem.Allocator) BodyCache {
+        return .{ .allocator = allocator };
+    }
+
+    pub fn deinit(self: *BodyCache) void {
+        var it = self.entries.valueIterator();
+        while (it.next()) |scan| self.allocator.free(scan.matches);
+        self.entries.deinit(self.allocator);
+    }
+
+    /// The stored scan for `key`. Match slices are never freed before
+    /// deinit, so the copy stays valid while other threads add entries.
+    fn get(self: *BodyCache, key: *const BodyKey) ?BodyScan {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        const scan = self.entries.getPtr(key.*) orelse {
+            self.misses += 1;
+            return null;
+        };
+        scan.used = true;
+        self.hits += 1;
+        return scan.*;
+    }
+
+    /// Store a scan; running out of memory only means it is scanned again
+    fn put(self: *BodyCache, key: *const BodyKey, scan: BodyScan) void {
+        const matches = self.allocator.dupe(BodyMatch, scan.matches) catch return;
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        const entry = self.entries.getOrPut(self.allocator, key.*) catch {
+            self.allocator.free(matches);
+            return;
+        };
+        if (entry.found_existing) {
+            self.allocator.free(matches);
+            return;
+        }
+        entry.value_ptr.* = .{ .lines = scan.lines, .end_state = scan.end_state, .matches = matches, .used = true };
+        self.added += 1;
+    }
+
+    /// Write one line per entry: key, line count, lexer state at the end,
+    /// then `rule:line:column` per match
+    pub fn save(self: *BodyCache, writer: anytype) !void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        var written: usize = 0;
+        for ([_]bool{ true, false }) |used| {
+            var it = self.entries.iterator();
+            while (it.next()) |entry| {
+                if (entry.value_ptr.used != used) continue;
+                if (written == max_saved_entries) return;
+                written += 1;
+                const scan = entry.value_ptr.*;
+                try writer.print("{s} {d} {d}", .{ &std.fmt.bytesToHex(entry.key_ptr.*, .lower), scan.lines, @intFromEnum(scan.end_state) });
+                for (scan.matches) |match| try writer.print(" {d}:{d}:{d}", .{ match.rule, match.line, match.column });
+                try writer.writeAll("\n");
+            }
+        }
+    }
+
+    /// Add the entries of text written by `save`. Malformed lines are
+    /// skipped; a stored scan that does not fit its rules is never replayed.
+    pub fn load(self: *BodyCache, text: []const u8) !void {
+        var matches = std.ArrayList(BodyMatch){};
+        defer matches.deinit(self.allocator);
+        var lines = std.mem.tokenizeScalar(u8, text, '\n');
+        next_line: while (lines.next()) |line| {
+            var fields = std.mem.tokenizeScalar(u8, line, ' ');
+            const hex = fields.next() orelse continue;
+            var key: BodyKey = undefined;
+            if (hex.len != key.len * 2) continue;
+            _ = std.fmt.hexToBytes(&key, hex) catch continue;
+            const line_count = std.fmt.parseInt(u32, fields.next() orelse continue, 10) catch continue;
+            const state_tag = std.fmt.parseInt(u8, fields.next() orelse continue, 10) catch continue;
+            const end_state = std.meta.intToEnum(LexerState, state_tag) catch continue;
+
+            matches.clearRetainingCapacity();
+            while (fields.next()) |field| {
+                var parts = std.mem.splitScalar(u8, field, ':');
+                try matches.append(self.allocator, .{
+                    .rule = std.fmt.parseInt(u16, parts.next().?, 10) catch continue :next_line,
+                    .line = std.fmt.parseInt(u32, parts.next() orelse continue :next_line, 10) catch continue :next_line,
+                    .column = std.fmt.parseInt(u32, parts.next() orelse continue :next_line, 10) catch continue :next_line,
+                });
+            }
+            const entry = try self.entries.getOrPut(self.allocator, key);
+            if (entry.found_existing) continue;
+            entry.value_ptr.* = .{
+                .lines = line_count,
+                .end_state = end_state,
+                .matches = self.allocator.dupe(BodyMatch, matches.items) catch |err| {
+                    self.entries.removeByPtr(entry.key_ptr);
+                    return err;
+                },
+            };
+        }
+    }
+};
+
+/// Append the matches of a stored scan for the body starting at byte
+/// `start` on line `first_line`. False, with nothing appended, when the
+/// scan refers to rules `compiled` does not have.
+fn replayBody(
+    allocator: std.mem.Allocator,
+    matches: *std.ArrayList(PatternMatch),
+    source: []const u8,
+    start: usize,
+    first_line: u32,
+    scan: BodyScan,
+    compiled: *const CompiledPatterns,
+) !bool {
+    for (scan.matches) |match| {
+        if (match.rule >= compiled.rules.len) return false;
+    }
+    try matches.ensureUnusedCapacity(allocator, scan.matches.len);
+    var line: u32 = 0;
+    var line_start = start;
+    for (scan.matches) |match| {
+        while (line < match.line) : (line += 1) {
+            const end = std.mem.indexOfScalarPos(u8, source, line_start, '\n') orelse source.len;
+            line_start = @min(end + 1, source.len);
+        }
+        const line_end = std.mem.indexOfScalarPos(u8, source, line_start, '\n') orelse source.len;
+        matches.appendAssumeCapacity(.{
+            .rule = compiled.rules[match.rule],
+            .line = first_line + match.line,
+            .column = match.column,
+            .context = source[line_start..line_end],
+        });
+    }
+    return true;
+}
+
+/// Index of `rule` in the compiled list
+fn ruleIndex(compiled: *const CompiledPatterns, rule: *const PatternRule) u16 {
+    for (compiled.rules, 0..) |candidate, index| {
+        if (candidate == rule) return @intCast(index);
+    }
+    unreachable;
+}
+
 /// Find all pattern matches in source code, skipping matches inside
 /// comments and string literals.
 pub fn findPatternMatches(
@@ -2663,30 +3059,159 @@ pub fn findPatternMatches(
     source: []const u8,
     lang_patterns: LanguagePatterns,
     language: []const u8,
+) ![]PatternMatch {
+    return findPatternMatchesDeduped(allocator, source, lang_patterns, language, &.{});
+}
+
+/// Like findPatternMatches, but a body in `bodies` whose original was already
+/// scanned gets a copy of the original's matches, moved to its own lines,
+/// instead of a scan. Results equal a full scan, except that the `context`
+/// of a copied match is the original's (byte-identical) line.
+pub fn findPatternMatchesDeduped(
+    allocator: std.mem.Allocator,
+    source: []const u8,
+    lang_patterns: LanguagePatterns,
+    language: []const u8,
+    bodies: []const BodySpan,
+) ![]PatternMatch {
+    return findPatternMatchesCached(allocator, source, lang_patterns, language, bodies, null);
+}
+
+/// Like findPatternMatchesDeduped, and a body scanned before (in any file)
+/// is replayed from `cache` instead of scanned. Bodies scanned here are
+/// added to it.
+pub fn findPatternMatchesCached(
+    allocator: std.mem.Allocator,
+    source: []const u8,
+    lang_patterns: LanguagePatterns,
+    language: []const u8,
+    bodies: []const BodySpan,
+    cache: ?*BodyCache,
 ) ![]PatternMatch {
     var matches = std.ArrayList(PatternMatch){};
     errdefer matches.deinit(allocator);
 
+    // What scanning each body produced, for replay at its clones
+    const Scanned = struct {
+        first_match: usize = 0,
+        end_match: usize = 0,
+        line: u32 = 0,
+        lines: u32 = 0,
+        end_state: LexerState = .code,
+        /// Entered in code state and left exactly at its end, so a clone
+        /// entered in code state scans the same way
+        replayable: bool = false,
+    };
+    const scanned = try allocator.alloc(Scanned, bodies.len);
+    defer allocator.free(scanned);
+    @memset(scanned, .{});
+    var next_body: usize = 0;
+    var open_body: ?usize = null;
+
     const rules = ContextRules.forLanguage(language);
 
-    // Combine all pattern categories
-    const all_patterns = [_][]const PatternRule{
-        lang_patterns.function_decl,
-        lang_patterns.type_annotation,
-        lang_patterns.async_pattern,
-        lang_patterns.error_handling,
-        lang_patterns.imports,
-        lang_patterns.class_struct,
-        lang_patterns.metadata,
-        lang_patterns.memory_management,
+    // Most code positions start no rule and skip straight past the bucket
+    const compiled = try compile(lang_patterns, language);
+
+    // Keys of the bodies being scanned, to store their scans once complete
+    const keys = try allocator.alloc(BodyKey, if (cache != null) bodies.len else 0);
+    defer allocator.free(keys);
+    const Store = struct {
+        fn scan(c: *BodyCache, key: *const BodyKey, all: []const PatternMatch, body: Scanned, table: *const CompiledPatterns, scratch: std.mem.Allocator) void {
+            const stored = scratch.alloc(BodyMatch, body.end_match - body.first_match) catch return;
+            defer scratch.free(stored);
+            for (all[body.first_match..body.end_match], stored) |match, *out| {
+                out.* = .{ .rule = ruleIndex(table, match.rule), .line = match.line - body.line, .column = match.column };
+            }
+            c.put(key, .{ .lines = body.lines, .end_state = body.end_state, .matches = stored });
+        }
+    };
+
+    // Counted per scan and added to the shared totals once, at the end
+    const Local = struct { compares: u64 = 0, matches: u64 = 0, ns: u64 = 0 };
+    const local: []Local = if (rule_timing.load(.monotonic))
+        try allocator.alloc(Local, compiled.rules.len)
+    else
+        &.{};
+    defer if (local.len > 0) {
+        for (local, compiled.stats) |counted, *stats| {
+            if (counted.compares == 0) continue;
+            _ = stats.compares.fetchAdd(counted.compares, .monotonic);
+            _ = stats.matches.fetchAdd(counted.matches, .monotonic);
+            _ = stats.ns.fetchAdd(counted.ns, .monotonic);
+        }
+        allocator.free(local);
     };
+    @memset(local, .{});
 
     var line_num: u32 = 1;
     var line_start: usize = 0;
+    // End of the current line, found on its first match and shared by the rest
+    var line_end: ?usize = null;
     var state: LexerState = .code;
     var i: usize = 0;
 
     while (i < source.len) {
+        if (open_body) |b| {
+            if (i >= bodies[b].end) {
+                scanned[b].end_match = matches.items.len;
+                scanned[b].lines = line_num - scanned[b].line;
+                scanned[b].end_state = state;
+                scanned[b].replayable = i == bodies[b].end;
+                open_body = null;
+                if (cache) |c| {
+                    if (scanned[b].replayable) Store.scan(c, &keys[b], matches.items, scanned[b], compiled, allocator);
+                }
+            }
+        }
+        if (open_body == null and next_body < bodies.len and i >= bodies[next_body].start) {
+            const b = next_body;
+            next_body += 1;
+            if (i == bodies[b].start and state == .code) {
+                const original = if (bodies[b].original) |o| scanned[o] else Scanned{};
+                if (original.replayable) {
+                    const copied = original.end_match - original.first_match;
+                    try matches.ensureUnusedCapacity(allocator, copied);
+                    for (original.first_match..original.end_match) |m| {
+                        var match = matches.items[m];
+                        match.line = match.line - original.line + line_num;
+                        matches.appendAssumeCapacity(match);
+                    }
+                    line_num += original.lines;
+                    state = original.end_state;
+                    i = bodies[b].end;
+                    line_start = i;
+                    line_end = null;
+                    continue;
+                }
+                if (cache) |c| {
+                    keys[b] = bodyKey(compiled, language, source[bodies[b].start..bodies[b].end]);
+                    const first_match = matches.items.len;
+                    if (c.get(&keys[b])) |stored| {
+                        if (try replayBody(allocator, &matches, source, i, line_num, stored, compiled)) {
+                            // Clones later in this file replay it like a scanned body
+                            scanned[b] = .{
+                                .first_match = first_match,
+                                .end_match = matches.items.len,
+                                .line = line_num,
+                                .lines = stored.lines,
+                                .end_state = stored.end_state,
+                                .replayable = true,
+                            };
+                            line_num += stored.lines;
+                            state = stored.end_state;
+                            i = bodies[b].end;
+                            line_start = i;
+                            line_end = null;
+                            continue;
+                        }
+                    }
+                }
+                scanned[b] = .{ .first_match = matches.items.len, .line = line_num };
+                open_body = b;
+            }
+        }
+
         const c = source[i];
 
         // Track line numbers
@@ -2697,6 +3222,7 @@ pub fn findPatternMatches(
             }
             line_num += 1;
             line_start = i + 1;
+            line_end = null;
             i += 1;
             continue;
         }
@@ -2758,24 +3284,33 @@ pub fn findPatternMatches(
                     continue;
                 }
 
-                // In code context: check all patterns
-                for (all_patterns) |pattern_set| {
-                    for (pattern_set) |*pattern| {
-                        if (i + pattern.pattern.len <= source.len) {
-                            if (std.mem.eql(u8, source[i .. i + pattern.pattern.len], pattern.pattern)) {
-                                const line_end = std.mem.indexOfScalarPos(u8, source, i, '\n') orelse source.len;
-                                const context = source[line_start..line_end];
-
-                                const match = PatternMatch{
-                                    .rule = pattern,
-                                    .line = line_num,
-                                    .column = @intCast(i - line_start),
-                                    .context = context,
-                                };
-                                try matches.append(allocator, match);
-                            }
+                // In code context: check the rules that start with this byte
+                for (compiled.buckets[c]) |index| {
+                    const pattern = compiled.rules[index];
+                    const started = if (local.len > 0) std.time.Instant.now() catch null else null;
+                    const hit = i + pattern.pattern.len <= source.len and
+                        std.mem.eql(u8, source[i .. i + pattern.pattern.len], pattern.pattern);
+                    if (local.len > 0) {
+                        local[index].compares += 1;
+                        local[index].matches += @intFromBool(hit);
+                        if (started) |start| {
+                            const now = std.time.Instant.now() catch start;
+                            local[index].ns += now.since(start);
                         }
                     }
+                    if (hit) {
+                        const end = line_end orelse std.mem.indexOfScalarPos(u8, source, i, '\n') orelse source.len;
+                        line_end = end;
+                        const context = source[line_start..end];
+
+                        const match = PatternMatch{
+                            .rule = pattern,
+                            .line = line_num,
+                            .column = @intCast(i - line_start),
+                            .context = context,
+                        };
+                        try matches.append(allocator, match);
+                    }
                 }
                 i += 1;
             },
@@ -2848,6 +3383,18 @@ pub fn findPatternMatches(
         }
     }
 
+    // The last body runs to the end of the source
+    if (open_body) |b| {
+        if (cache) |c| {
+            if (i == bodies[b].end) {
+                scanned[b].end_match = matches.items.len;
+                scanned[b].lines = line_num - scanned[b].line;
+                scanned[b].end_state = state;
+                Store.scan(c, &keys[b], matches.items, scanned[b], compiled, allocator);
+            }
+        }
+    }
+
     return matches.toOwnedSlice(allocator);
 }
 
@@ -2916,6 +3463,28 @@ test "findPatternMatches: python hash comments" {
     try std.testing.expectEqual(@as(usize, 1), def_count);
 }
 
+test "findPatternMatches: matches on one line share the whole line as context" {
+    const allocator = std.testing.allocator;
+    const source =
+        \\let x = 1; fn a() {} fn b() {}
+        \\fn c() {}
+    ;
+    const lang_patterns = getPatternsForLanguage("rust") orelse return error.TestUnexpectedResult;
+    const matches = try findPatternMatches(allocator, source, lang_patterns, "rust");
+    defer allocator.free(matches);
+    var lines = [_]u32{ 0, 0, 0 };
+    var fn_count: usize = 0;
+    for (matches) |m| {
+        if (!std.mem.eql(u8, m.rule.pattern, "fn ")) continue;
+        const expected = if (m.line == 1) "let x = 1; fn a() {} fn b() {}" else "fn c() {}";
+        try std.testing.expectEqualStrings(expected, m.context);
+        if (fn_count < lines.len) lines[fn_count] = m.line;
+        fn_count += 1;
+    }
+    try std.testing.expectEqual(@as(usize, 3), fn_count);
+    try std.testing.expectEqualSlices(u32, &.{ 1, 1, 2 }, &lines);
+}
+
 // ============================================================================
 // Property-based tests
 // ============================================================================
@@ -3106,3 +3675,123 @@ test "property: pattern matching is deterministic" {
         }
     }
 }
+
+test "compile: rules are shared and bucketed by first byte" {
+    const testing = std.testing;
+    const rust = getPatternsForLanguage("rust") orelse return error.TestUnexpectedResult;
+
+    const first = try compile(rust, "rust");
+    const again = try compile(rust, "rs");
+    try testing.expectEqual(first, again);
+    for (first.buckets['f']) |index| {
+        try testing.expectEqual(@as(u8, 'f'), first.rules[index].pattern[0]);
+    }
+
+    setRuleTiming(true);
+    defer setRuleTiming(false);
+    const matches = try findPatternMatches(testing.allocator, "fn main() {}\n", rust, "rust");
+    defer testing.allocator.free(matches);
+
+    const timings = try ruleTimings(testing.allocator);
+    defer testing.allocator.free(timings);
+    var fn_matches: u64 = 0;
+    for (timings) |timing| {
+        if (std.mem.eql(u8, timing.rule.pattern, "fn ")) fn_matches += timing.matches;
+    }
+    try testing.expect(fn_matches >= 1);
+}
+
+test "findPatternMatchesDeduped: cloned bodies match a full scan" {
+    const allocator = std.testing.allocator;
+    const body =
+        \\    result, err := s.db.Query(ctx, "SELECT 1")
+        \\    if err != nil {
+        \\        return nil, fmt.Errorf("query: %w", err) // func in comment
+        \\    }
+        \\    return result, nil
+        \\}
+        \\
+        \\
+    ;
+    var source = std.ArrayList(u8){};
+    defer source.deinit(allocator);
+    try source.appendSlice(allocator, "package service\n\n");
+    for (0..20) |n| {
+        try source.writer(allocator).print("func (s *Service) Operation{d}(ctx context.Context) (*Row, error) {{\n", .{n});
+        try source.appendSlice(allocator, body);
+    }
+
+    const lang_patterns = getPatternsForLanguage("go") orelse return error.TestUnexpectedResult;
+    const bodies = try findBodySpans(allocator, source.items, lang_patterns);
+    defer allocator.free(bodies);
+    try std.testing.expectEqual(@as(usize, 20), bodies.len);
+    for (bodies[1..]) |span| try std.testing.expectEqual(@as(?usize, 0), span.original);
+
+    const full = try findPatternMatches(allocator, source.items, lang_patterns, "go");
+    defer allocator.free(full);
+    const deduped = try findPatternMatchesDeduped(allocator, source.items, lang_patterns, "go", bodies);
+    defer allocator.free(deduped);
+
+    try std.testing.expectEqual(full.len, deduped.len);
+    for (full, deduped) |a, b| {
+        try std.testing.expectEqual(a.rule, b.rule);
+        try std.testing.expectEqual(a.line, b.line);
+        try std.testing.expectEqual(a.column, b.column);
+        try std.testing.expectEqualStrings(a.context, b.context);
+    }
+}
+
+test "findPatternMatchesCached: an edited body is the only one scanned" {
+    const allocator = std.testing.allocator;
+    const lang_patterns = getPatternsForLanguage("go") orelse return error.TestUnexpectedResult;
+
+    var source = std.ArrayList(u8){};
+    defer source.deinit(allocator);
+    try source.appendSlice(allocator, "package service\n\n");
+    for (0..5) |n| {
+        try source.writer(allocator).print("func Operation{d}(ctx context.Context) error {{\n", .{n});
+        try source.writer(allocator).print("    if err := step{d}(ctx); err != nil {{\n        return fmt.Errorf(\"step: %w\", err)\n    }}\n    return nil\n}}\n\n", .{n});
+    }
+
+    var cache = BodyCache.init(allocator);
+    defer cache.deinit();
+    const bodies = try findBodySpans(allocator, source.items, lang_patterns);
+    defer allocator.free(bodies);
+    const first = try findPatternMatchesCached(allocator, source.items, lang_patterns, "go", bodies, &cache);
+    defer allocator.free(first);
+    try std.testing.expectEqual(@as(usize, 5), cache.added);
+
+    // Edit the third body; only it is scanned again
+    const at = std.mem.indexOf(u8, source.items, "step2(ctx)").?;
+    try source.replaceRange(allocator, at, "step2(ctx)".len, "step2(ctx, defer_close)");
+    const edited_bodies = try findBodySpans(allocator, source.items, lang_patterns);
+    defer allocator.free(edited_bodies);
+    const hits_before = cache.hits;
+    const edited = try findPatternMatchesCached(allocator, source.items, lang_patterns, "go", edited_bodies, &cache);
+    defer allocator.free(edited);
+    try std.testing.expectEqual(hits_before + 4, cache.hits);
+    try std.testing.expectEqual(@as(usize, 6), cache.added);
+
+    const full = try findPatternMatches(allocator, source.items, lang_patterns, "go");
+    defer allocator.free(full);
+    try std.testing.expectEqual(full.len, edited.len);
+    for (full, edited) |a, b| {
+        try std.testing.expectEqual(a.rule, b.rule);
+        try std.testing.expectEqual(a.line, b.line);
+        try std.testing.expectEqual(a.column, b.column);
+        try std.testing.expectEqualStrings(a.context, b.context);
+    }
+
+    // Scans survive a save and load
+    var text = std.ArrayList(u8){};
+    defer text.deinit(allocator);
+    try cache.save(text.writer(allocator));
+    var loaded = BodyCache.init(allocator);
+    defer loaded.deinit();
+    try loaded.load(text.items);
+    try std.testing.expectEqual(cache.entries.count(), loaded.entries.count());
+    const replayed = try findPatternMatchesCached(allocator, source.items, lang_patterns, "go", edited_bodies, &loaded);
+    defer allocator.free(replayed);
+    try std.testing.expectEqual(@as(usize, 5), loaded.hits);
+    try std.testing.expectEqual(full.len, replayed.len);
+}
@@ -0,0 +1,140 @@
+// Build-system action keys
+// Bazel and Buck cache an action's outputs under a digest of its inputs and
+// command line, and only run it (locally or on a remote executor) on a miss.
+// `extract --action-key` prints that digest for an extraction, computed from
+// what actually determines its output: the tool version, the settings that
+// shape the output, and each input's path and cache key (content, language,
+// rule set, and extracted kinds). Settings that only change how the work is
+// done (workers, cache location, verbosity) are left out, so a key computed
+// on a laptop matches one computed on a remote executor.
+//
+// `extract --action-record` writes the other half of the mapping, the digest
+// of every file the run wrote:
+//
+//   {"action_key": "<sha256>", "outputs": [{"path": "...", "sha256": "...", "size": N}]}
+const std = @import("std");
+const output = @import("cli_output");
+
+const Sha256 = std.crypto.hash.sha2.Sha256;
+
+const magic = "ananke-action 1";
+
+pub const Key = [Sha256.digest_length * 2]u8;
+
+/// Accumulates an action key. Settings and inputs must be added in a fixed
+/// order; inputs sorted by path.
+pub const Builder = struct {
+    hasher: Sha256,
+
+    pub fn init(tool_version: []const u8) Builder {
+        var hasher = Sha256.init(.{});
+        hasher.update(magic);
+        hasher.update(&.{0});
+        hasher.update(tool_version);
+        hasher.update(&.{0});
+        return .{ .hasher = hasher };
+    }
+
+    /// Record one setting that shapes the output
+    pub fn setting(self: *Builder, name: []const u8, value: []const u8) void {
+        self.field("setting", name, value);
+    }
+
+    /// Record one input by its path and what its content extracts under
+    pub fn input(self: *Builder, path: []const u8, key: []const u8) void {
+        self.field("input", normalize(path), key);
+    }
+
+    pub fn final(self: *Builder) Key {
+        return std.fmt.bytesToHex(self.hasher.finalResult(), .lower);
+    }
+
+    fn field(self: *Builder, tag: []const u8, name: []const u8, value: []const u8) void {
+        // Lengths keep "a" + "bc" apart from "ab" + "c"
+        var lengths: [16]u8 = undefined;
+        std.mem.writeInt(u64, lengths[0..8], name.len, .little);
+        std.mem.writeInt(u64, lengths[8..16], value.len, .little);
+        self.hasher.update(tag);
+        self.hasher.update(&lengths);
+        self.hasher.update(name);
+        self.hasher.update(value);
+    }
+};
+
+/// Paths in keys are spelled without a leading "./", as build systems pass them
+fn normalize(path: []const u8) []const u8 {
+    var rest = path;
+    while (std.mem.startsWith(u8, rest, "./")) rest = rest[2..];
+    return rest;
+}
+
+/// One file an action wrote
+pub const Output = struct {
+    path: []const u8,
+    sha256: Key,
+    size: u64,
+};
+
+/// Hash the file at `path` without loading it whole
+pub fn digestFile(path: []const u8) !Output {
+    const file = try std.fs.cwd().openFile(path, .{});
+    defer file.close();
+    var hasher = Sha256.init(.{});
+    var buf: [64 * 1024]u8 = undefined;
+    var size: u64 = 0;
+    while (true) {
+        const n = try file.read(&buf);
+        if (n == 0) break;
+        hasher.update(buf[0..n]);
+        size += n;
+    }
+    return .{ .path = path, .sha256 = std.fmt.bytesToHex(hasher.finalResult(), .lower), .size = size };
+}
+
+/// Render the record mapping `key` to `outputs`
+pub fn formatRecord(allocator: std.mem.Allocator, key: *const Key, outputs: []const Output) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    try writer.print("{{\n  \"action_key\": \"{s}\",\n  \"outputs\": [", .{key});
+    for (outputs, 0..) |out, i| {
+        try writer.writeAll(if (i == 0) "\n    {\"path\": \"" else ",\n    {\"path\": \"");
+        try output.writeJsonEscaped(writer, normalize(out.path));
+        try writer.print("\", \"sha256\": \"{s}\", \"size\": {d}}}", .{ out.sha256, out.size });
+    }
+    try writer.writeAll(if (outputs.len > 0) "\n  ]\n}\n" else "]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+test "action keys depend on settings and inputs, not on how paths are spelled" {
+    const testing = std.testing;
+
+    var a = Builder.init("1.0.0");
+    a.setting("format", "json");
+    a.input("./src/a.go", "0123");
+    const key = a.final();
+
+    var b = Builder.init("1.0.0");
+    b.setting("format", "json");
+    b.input("src/a.go", "0123");
+    try testing.expectEqualStrings(&key, &b.final());
+
+    var c = Builder.init("1.0.0");
+    c.setting("format", "yaml");
+    c.input("src/a.go", "0123");
+    try testing.expect(!std.mem.eql(u8, &key, &c.final()));
+
+    // Field boundaries are part of the key
+    var d = Builder.init("1.0.0");
+    d.setting("format", "json");
+    d.input("src/a.go0", "123");
+    try testing.expect(!std.mem.eql(u8, &key, &d.final()));
+
+    const record = try formatRecord(testing.allocator, &key, &.{.{ .path = "./out/c.json", .sha256 = key, .size = 12 }});
+    defer testing.allocator.free(record);
+    const parsed = try std.json.parseFromSlice(std.json.Value, testing.allocator, record, .{});
+    defer parsed.deinit();
+    const outputs = parsed.value.object.get("outputs").?.array.items;
+    try testing.expectEqualStrings("out/c.json", outputs[0].object.get("path").?.string);
+    try testing.expectEqual(@as(i64, 12), outputs[0].object.get("size").?.integer);
+}
@@ -0,0 +1,246 @@
+// Source annotation patch generation
+// Produces a unified diff that inserts structured constraint comments above the
+// code each constraint was extracted from, so constraints can be materialized
+// back into the source with `git apply` or `patch -p1`.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+
+/// Lines of unchanged context around each insertion
+const context_lines = 3;
+
+const Insertion = struct {
+    /// 1-based line the comment is inserted above
+    line: usize,
+    /// Index into the constraint set (keeps ordering stable)
+    index: usize,
+
+    fn lessThan(_: void, a: Insertion, b: Insertion) bool {
+        if (a.line != b.line) return a.line < b.line;
+        return a.index < b.index;
+    }
+};
+
+/// Comment leader for a language
+pub fn commentPrefix(language: []const u8) []const u8 {
+    if (std.mem.eql(u8, language, "python") or std.mem.eql(u8, language, "ruby")) return "#";
+    return "//";
+}
+
+/// Human label used in the comment, mirroring examples/01-simple-extraction
+fn kindLabel(kind: constraint.ConstraintKind) []const u8 {
+    return switch (kind) {
+        .syntactic => "Syntactic",
+        .type_safety => "Type",
+        .semantic => "Semantic",
+        .architectural => "Architectural",
+        .operational => "Operational",
+        .security => "Security",
+    };
+}
+
+/// Write the structured comment for one constraint (without indentation or newline)
+pub fn writeAnnotation(writer: anytype, language: []const u8, c: constraint.Constraint) !void {
+    try writer.print("{s} {s} constraint: {s} - ", .{ commentPrefix(language), kindLabel(c.kind), c.name });
+    // Keep the annotation on one line
+    for (c.description) |ch| {
+        try writer.writeByte(if (ch == '\n' or ch == '\r') ' ' else ch);
+    }
+    try writer.print(" [ananke:{d}]", .{c.id});
+}
+
+/// `path` relative to the root of the git checkout containing it, as the
+/// a/ and b/ headers read by `git apply` and `patch -p1` need it; outside a
+/// checkout, relative to the working directory
+pub fn patchPath(allocator: std.mem.Allocator, path: []const u8) ![]u8 {
+    const cwd = try std.process.getCwdAlloc(allocator);
+    defer allocator.free(cwd);
+    const absolute = try std.fs.path.resolve(allocator, &.{ cwd, path });
+    defer allocator.free(absolute);
+    const root = try findRepoRoot(allocator, absolute) orelse try allocator.dupe(u8, cwd);
+    defer allocator.free(root);
+    const relative = try std.fs.path.relative(allocator, root, absolute);
+    std.mem.replaceScalar(u8, relative, '\\', '/');
+    return relative;
+}
+
+/// The nearest directory above `absolute` holding a `.git` entry
+fn findRepoRoot(allocator: std.mem.Allocator, absolute: []const u8) !?[]u8 {
+    var dir = std.fs.path.dirname(absolute);
+    while (dir) |d| : (dir = std.fs.path.dirname(d)) {
+        const git_path = try std.fs.path.join(allocator, &.{ d, ".git" });
+        defer allocator.free(git_path);
+        std.fs.accessAbsolute(git_path, .{}) catch continue;
+        return try allocator.dupe(u8, d);
+    }
+    return null;
+}
+
+/// Generate a unified diff inserting constraint comments into `source`.
+/// Constraints without an origin line, from another file, or whose marker is
+/// already present in the file, are skipped. Returns an empty string when there is nothing to insert.
+/// Headers name the file by `patchPath`.
+pub fn formatAnnotationPatch(
+    allocator: std.mem.Allocator,
+    constraint_set: constraint.ConstraintSet,
+    path: []const u8,
+    source: []const u8,
+    language: []const u8,
+) ![]u8 {
+    var lines = std.ArrayList([]const u8){};
+    defer lines.deinit(allocator);
+    var it = std.mem.splitScalar(u8, source, '\n');
+    while (it.next()) |line| try lines.append(allocator, line);
+    const ends_with_newline = source.len > 0 and source[source.len - 1] == '\n';
+    if (ends_with_newline) _ = lines.pop();
+    const line_count = lines.items.len;
+
+    // Collect insertion points
+    var insertions = std.ArrayList(Insertion){};
+    defer insertions.deinit(allocator);
+    var marker_buf: [48]u8 = undefined;
+    for (constraint_set.constraints.items, 0..) |c, i| {
+        const line = c.origin_line orelse continue;
+        if (c.origin_file) |file| {
+            if (!std.mem.eql(u8, file, path)) continue;
+        }
+        if (line == 0 or line > line_count) continue;
+        const marker = try std.fmt.bufPrint(&marker_buf, "[ananke:{d}]", .{c.id});
+        if (std.mem.indexOf(u8, source, marker) != null) continue;
+        try insertions.append(allocator, .{ .line = line, .index = i });
+    }
+    std.mem.sort(Insertion, insertions.items, {}, Insertion.lessThan);
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    if (insertions.items.len == 0) return list.toOwnedSlice(allocator);
+
+    const writer = list.writer(allocator);
+    const header_path = try patchPath(allocator, path);
+    defer allocator.free(header_path);
+    try writer.print("--- a/{s}\n+++ b/{s}\n", .{ header_path, header_path });
+
+    // Group insertions whose context windows overlap into a single hunk
+    var added_so_far: usize = 0;
+    var group_start: usize = 0;
+    while (group_start < insertions.items.len) {
+        var group_end = group_start + 1;
+        while (group_end < insertions.items.len and
+            insertions.items[group_end].line - insertions.items[group_end - 1].line <= 2 * context_lines) : (group_end += 1)
+        {}
+        const group = insertions.items[group_start..group_end];
+
+        const first = group[0].line;
+        const last = group[group.len - 1].line;
+        const old_start = if (first > context_lines) first - context_lines else 1;
+        const old_end = @min(line_count, last + context_lines - 1);
+        const old_len = old_end - old_start + 1;
+
+        try writer.print("@@ -{d},{d} +{d},{d} @@\n", .{
+            old_start,
+            old_len,
+            old_start + added_so_far,
+            old_len + group.len,
+        });
+
+        var next: usize = 0;
+        var line_no = old_start;
+        while (line_no <= old_end) : (line_no += 1) {
+            const text = lines.items[line_no - 1];
+            while (next < group.len and group[next].line == line_no) : (next += 1) {
+                try writer.writeByte('+');
+                try writer.writeAll(leadingWhitespace(text));
+                try writeAnnotation(writer, language, constraint_set.constraints.items[group[next].index]);
+                try writer.writeByte('\n');
+            }
+            try writer.print(" {s}\n", .{text});
+            if (line_no == line_count and !ends_with_newline) {
+                try writer.writeAll("\\ No newline at end of file\n");
+            }
+        }
+
+        added_so_far += group.len;
+        group_start = group_end;
+    }
+
+    return list.toOwnedSlice(allocator);
+}
+
+fn leadingWhitespace(line: []const u8) []const u8 {
+    var i: usize = 0;
+    while (i < line.len and (line[i] == ' ' or line[i] == '\t')) : (i += 1) {}
+    return line[0..i];
+}
+
+test "annotation patch inserts indented comment with context" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var set = constraint.ConstraintSet.init(allocator, "test");
+    defer set.deinit();
+    try set.add(.{
+        .id = 7,
+        .name = "email_format",
+        .description = "Email must match regex",
+        .kind = .semantic,
+        .severity = .info,
+        .origin_line = 3,
+    });
+
+    const source = "package main\nimport \"fmt\"\nfunc f() {\n    return\n}\n";
+    const patch = try formatAnnotationPatch(allocator, set, "main.go", source, "go");
+    defer allocator.free(patch);
+
+    const expected =
+        \\--- a/main.go
+        \\+++ b/main.go
+        \\@@ -1,5 +1,6 @@
+        \\ package main
+        \\ import "fmt"
+        \\+// Semantic constraint: email_format - Email must match regex [ananke:7]
+        \\ func f() {
+        \\     return
+        \\ }
+        \\
+    ;
+    try testing.expectEqualStrings(expected, patch);
+}
+
+test "annotation patch is idempotent" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var set = constraint.ConstraintSet.init(allocator, "test");
+    defer set.deinit();
+    try set.add(.{ .id = 7, .name = "n", .description = "d", .kind = .semantic, .severity = .info, .origin_line = 1 });
+
+    const patch = try formatAnnotationPatch(allocator, set, "a.py", "# [ananke:7]\nx = 1\n", "python");
+    defer allocator.free(patch);
+    try testing.expectEqual(@as(usize, 0), patch.len);
+}
+
+test "patch headers name absolute paths relative to the checkout" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.makePath(".git");
+    try tmp.dir.makePath("svc/api");
+    try tmp.dir.writeFile(.{ .sub_path = "svc/api/main.go", .data = "package api\n" });
+    const root = try tmp.dir.realpathAlloc(allocator, ".");
+    defer allocator.free(root);
+    const absolute = try std.fs.path.join(allocator, &.{ root, "svc", "api", "main.go" });
+    defer allocator.free(absolute);
+
+    const relative = try patchPath(allocator, absolute);
+    defer allocator.free(relative);
+    try testing.expectEqualStrings("svc/api/main.go", relative);
+
+    var set = constraint.ConstraintSet.init(allocator, "test");
+    defer set.deinit();
+    try set.add(.{ .id = 7, .name = "n", .description = "d", .kind = .semantic, .severity = .info, .origin_line = 1 });
+    const patch = try formatAnnotationPatch(allocator, set, absolute, "package api\n", "go");
+    defer allocator.free(patch);
+    try testing.expect(std.mem.startsWith(u8, patch, "--- a/svc/api/main.go\n+++ b/svc/api/main.go\n"));
+}
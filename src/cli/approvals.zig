@@ -0,0 +1,334 @@
+// Constraint review approvals
+// Records the review decision on constraints of a stored set: reviewed
+// (seen, no verdict), accepted (an intended contract), or rejected (not a
+// real constraint), with the reviewer, the date, and an optional note. The
+// file is JSON meant to be committed next to the stored set. Entries are
+// keyed by the line-independent fingerprint of constraint_diff (file, kind,
+// name), so decisions survive code moving, and sorted so the file diffs
+// cleanly. `ananke verify` skips rejected constraints and fails on new
+// critical (error-severity) constraints nobody has reviewed yet.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const constraint_diff = @import("cli_constraint_diff");
+
+pub const format_version: u32 = 1;
+
+/// Where `review` and `verify` look for approvals without --approvals
+pub const default_path = ".ananke-approvals.json";
+
+/// Largest approvals file read into memory
+const max_approvals_bytes = 16 * 1024 * 1024;
+
+pub const ApprovalsError = error{
+    UnsupportedVersion,
+    InvalidFingerprint,
+};
+
+pub const Decision = enum {
+    reviewed,
+    accepted,
+    rejected,
+};
+
+pub const Entry = struct {
+    fingerprint: u64,
+    /// File, kind, and name of the constraint, for human review of the file
+    file: []const u8,
+    kind: []const u8,
+    name: []const u8,
+    decision: Decision,
+    reviewer: []const u8,
+    /// YYYY-MM-DD (UTC)
+    date: []const u8,
+    note: ?[]const u8 = null,
+};
+
+/// On-disk layout
+const FileFormat = struct {
+    version: u32,
+    entries: []const struct {
+        fingerprint: []const u8,
+        file: []const u8 = "",
+        kind: []const u8 = "",
+        name: []const u8 = "",
+        decision: Decision,
+        reviewer: []const u8,
+        date: []const u8 = "",
+        note: ?[]const u8 = null,
+    },
+};
+
+/// Constraints whose unreviewed appearance fails verification
+pub fn isCritical(c: constraint.Constraint) bool {
+    return c.severity == .err;
+}
+
+pub const Approvals = struct {
+    arena: std.heap.ArenaAllocator,
+    entries: std.ArrayList(Entry),
+
+    pub fn init(allocator: std.mem.Allocator) Approvals {
+        return .{
+            .arena = std.heap.ArenaAllocator.init(allocator),
+            .entries = std.ArrayList(Entry){},
+        };
+    }
+
+    pub fn deinit(self: *Approvals) void {
+        self.arena.deinit();
+    }
+
+    pub fn loadFile(allocator: std.mem.Allocator, path: []const u8) !Approvals {
+        const text = try std.fs.cwd().readFileAlloc(allocator, path, max_approvals_bytes);
+        defer allocator.free(text);
+        return parse(allocator, text);
+    }
+
+    pub fn parse(allocator: std.mem.Allocator, text: []const u8) !Approvals {
+        var approvals = Approvals.init(allocator);
+        errdefer approvals.deinit();
+        const arena = approvals.arena.allocator();
+
+        const parsed = try std.json.parseFromSliceLeaky(FileFormat, arena, text, .{ .ignore_unknown_fields = true, .allocate = .alloc_always });
+        if (parsed.version != format_version) return ApprovalsError.UnsupportedVersion;
+        for (parsed.entries) |entry| {
+            try approvals.entries.append(arena, .{
+                .fingerprint = std.fmt.parseInt(u64, entry.fingerprint, 16) catch return ApprovalsError.InvalidFingerprint,
+                .file = entry.file,
+                .kind = entry.kind,
+                .name = entry.name,
+                .decision = entry.decision,
+                .reviewer = entry.reviewer,
+                .date = entry.date,
+                .note = entry.note,
+            });
+        }
+        return approvals;
+    }
+
+    /// The decision recorded for `c`, if any
+    pub fn get(self: *const Approvals, c: constraint.Constraint) ?Entry {
+        const fingerprint = constraint_diff.identityHash(c);
+        for (self.entries.items) |entry| {
+            if (entry.fingerprint == fingerprint) return entry;
+        }
+        return null;
+    }
+
+    /// Record a decision on `c`, replacing an earlier one. Strings are copied.
+    pub fn record(
+        self: *Approvals,
+        c: constraint.Constraint,
+        decision: Decision,
+        reviewer: []const u8,
+        date: []const u8,
+        note: ?[]const u8,
+    ) !void {
+        const arena = self.arena.allocator();
+        const entry = Entry{
+            .fingerprint = constraint_diff.identityHash(c),
+            .file = try arena.dupe(u8, c.origin_file orelse ""),
+            .kind = @tagName(c.kind),
+            .name = try arena.dupe(u8, c.name),
+            .decision = decision,
+            .reviewer = try arena.dupe(u8, reviewer),
+            .date = try arena.dupe(u8, date),
+            .note = if (note) |text| try arena.dupe(u8, text) else null,
+        };
+        for (self.entries.items) |*existing| {
+            if (existing.fingerprint == entry.fingerprint) {
+                existing.* = entry;
+                return;
+            }
+        }
+        try self.entries.append(arena, entry);
+    }
+
+    pub fn count(self: *const Approvals, decision: Decision) usize {
+        var n: usize = 0;
+        for (self.entries.items) |entry| {
+            if (entry.decision == decision) n += 1;
+        }
+        return n;
+    }
+};
+
+fn entryLessThan(_: void, a: Entry, b: Entry) bool {
+    const file_order = std.mem.order(u8, a.file, b.file);
+    if (file_order != .eq) return file_order == .lt;
+    const kind_order = std.mem.order(u8, a.kind, b.kind);
+    if (kind_order != .eq) return kind_order == .lt;
+    return std.mem.lessThan(u8, a.name, b.name);
+}
+
+/// Serialize approvals, sorted by file, kind, and name
+pub fn formatApprovals(allocator: std.mem.Allocator, approvals: *const Approvals) ![]u8 {
+    const sorted = try allocator.dupe(Entry, approvals.entries.items);
+    defer allocator.free(sorted);
+    std.mem.sort(Entry, sorted, {}, entryLessThan);
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\n  \"version\": {d},\n  \"entries\": [", .{format_version});
+    for (sorted, 0..) |entry, i| {
+        try writer.writeAll(if (i == 0) "\n" else ",\n");
+        try writer.print("    {{\"fingerprint\": \"{x:0>16}\", \"file\": \"", .{entry.fingerprint});
+        try output.writeJsonEscaped(writer, entry.file);
+        try writer.print("\", \"kind\": \"{s}\", \"name\": \"", .{entry.kind});
+        try output.writeJsonEscaped(writer, entry.name);
+        try writer.print("\", \"decision\": \"{s}\", \"reviewer\": \"", .{@tagName(entry.decision)});
+        try output.writeJsonEscaped(writer, entry.reviewer);
+        try writer.print("\", \"date\": \"{s}\"", .{entry.date});
+        if (entry.note) |note| {
+            try writer.writeAll(", \"note\": \"");
+            try output.writeJsonEscaped(writer, note);
+            try writer.writeAll("\"");
+        }
+        try writer.writeAll("}");
+    }
+    try writer.writeAll(if (sorted.len == 0) "]\n}\n" else "\n  ]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// `timestamp` as YYYY-MM-DD (UTC)
+pub fn formatDate(buf: *[10]u8, timestamp: i64) []const u8 {
+    const epoch_seconds = std.time.epoch.EpochSeconds{ .secs = @intCast(@max(timestamp, 0)) };
+    const year_day = epoch_seconds.getEpochDay().calculateYearDay();
+    const month_day = year_day.calculateMonthDay();
+    return std.fmt.bufPrint(buf, "{d:0>4}-{d:0>2}-{d:0>2}", .{ year_day.year, month_day.month.numeric(), month_day.day_index + 1 }) catch unreachable;
+}
+
+/// Review state of each of `constraints`, grouped by file after a summary.
+/// With `pending_only`, only critical constraints without a decision.
+pub fn formatText(
+    allocator: std.mem.Allocator,
+    constraints: []const constraint.Constraint,
+    approvals: *const Approvals,
+    pending_only: bool,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    var counts = [_]usize{0} ** @typeInfo(Decision).@"enum".fields.len;
+    var unreviewed: usize = 0;
+    var pending: usize = 0;
+    for (constraints) |c| {
+        if (approvals.get(c)) |entry| {
+            counts[@intFromEnum(entry.decision)] += 1;
+        } else {
+            unreviewed += 1;
+            if (isCritical(c)) pending += 1;
+        }
+    }
+    try writer.print("Review state of {d} constraints\n", .{constraints.len});
+    try writer.print("  {d} accepted, {d} rejected, {d} reviewed, {d} unreviewed ({d} critical)\n", .{
+        counts[@intFromEnum(Decision.accepted)],
+        counts[@intFromEnum(Decision.rejected)],
+        counts[@intFromEnum(Decision.reviewed)],
+        unreviewed,
+        pending,
+    });
+
+    var current_file: ?[]const u8 = null;
+    for (constraints) |c| {
+        const entry = approvals.get(c);
+        if (pending_only and (entry != null or !isCritical(c))) continue;
+        const file = c.origin_file orelse "(unknown file)";
+        if (current_file == null or !std.mem.eql(u8, current_file.?, file)) {
+            try writer.print("\n{s}\n", .{file});
+            current_file = file;
+        }
+        const state = if (entry) |e| @tagName(e.decision) else if (isCritical(c)) "pending" else "unreviewed";
+        try writer.print("  {s:<10} [{s}] {s} ({s}, {x:0>16})", .{ state, @tagName(c.kind), c.name, constraint_diff.severityLabel(c.severity), constraint_diff.identityHash(c) });
+        if (entry) |e| {
+            try writer.print(" by {s} on {s}", .{ e.reviewer, e.date });
+            if (e.note) |note| try writer.print(": {s}", .{note});
+        }
+        try writer.writeAll("\n");
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+pub fn formatJson(
+    allocator: std.mem.Allocator,
+    constraints: []const constraint.Constraint,
+    approvals: *const Approvals,
+    pending_only: bool,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("{\n  \"constraints\": [");
+    var written: usize = 0;
+    for (constraints) |c| {
+        const entry = approvals.get(c);
+        if (pending_only and (entry != null or !isCritical(c))) continue;
+        try writer.writeAll(if (written == 0) "\n    {\"constraint\": " else ",\n    {\"constraint\": ");
+        try constraint_diff.writeConstraintJson(writer, c);
+        try writer.print(", \"fingerprint\": \"{x:0>16}\", \"critical\": {s}", .{
+            constraint_diff.identityHash(c),
+            if (isCritical(c)) "true" else "false",
+        });
+        if (entry) |e| {
+            try writer.print(", \"decision\": \"{s}\", \"reviewer\": \"", .{@tagName(e.decision)});
+            try output.writeJsonEscaped(writer, e.reviewer);
+            try writer.print("\", \"date\": \"{s}\"", .{e.date});
+        } else {
+            try writer.writeAll(", \"decision\": null");
+        }
+        try writer.writeAll("}");
+        written += 1;
+    }
+    try writer.writeAll(if (written == 0) "]\n}\n" else "\n  ]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+test "approvals record decisions and round trip" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const constraints = [_]constraint.Constraint{
+        .{ .name = "sql_params", .description = "x", .kind = .security, .severity = .err, .origin_file = "a.go", .origin_line = 3 },
+        .{ .name = "retry_budget", .description = "x", .kind = .operational, .severity = .warning, .origin_file = "a.go" },
+        .{ .name = "auth_required", .description = "x", .kind = .security, .severity = .err, .origin_file = "b.go" },
+    };
+
+    var approvals = Approvals.init(allocator);
+    defer approvals.deinit();
+    try approvals.record(constraints[0], .reviewed, "alice", "2026-10-01", null);
+    try approvals.record(constraints[0], .accepted, "bob", "2026-10-02", "matches the API contract");
+    try approvals.record(constraints[1], .rejected, "alice", "2026-10-02", null);
+    try testing.expectEqual(@as(usize, 2), approvals.entries.items.len);
+
+    const text = try formatApprovals(allocator, &approvals);
+    defer allocator.free(text);
+    var loaded = try Approvals.parse(allocator, text);
+    defer loaded.deinit();
+
+    // Moved to another line: same decision
+    var moved = constraints[0];
+    moved.origin_line = 40;
+    const entry = loaded.get(moved).?;
+    try testing.expectEqual(Decision.accepted, entry.decision);
+    try testing.expectEqualStrings("bob", entry.reviewer);
+    try testing.expectEqualStrings("matches the API contract", entry.note.?);
+    try testing.expectEqual(Decision.rejected, loaded.get(constraints[1]).?.decision);
+    try testing.expect(loaded.get(constraints[2]) == null);
+
+    const listing = try formatText(allocator, &constraints, &loaded, true);
+    defer allocator.free(listing);
+    try testing.expect(std.mem.indexOf(u8, listing, "1 accepted, 1 rejected, 0 reviewed, 1 unreviewed (1 critical)") != null);
+    try testing.expect(std.mem.indexOf(u8, listing, "auth_required") != null);
+    try testing.expect(std.mem.indexOf(u8, listing, "sql_params") == null);
+
+    var buf: [10]u8 = undefined;
+    try testing.expectEqualStrings("2026-10-15", formatDate(&buf, 1792022400));
+
+    try testing.expectError(ApprovalsError.UnsupportedVersion, Approvals.parse(allocator, "{\"version\": 9, \"entries\": []}"));
+}
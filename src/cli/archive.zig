@@ -0,0 +1,115 @@
+// Tar archives
+// Minimal POSIX ustar writer for bundling regular files. Archives contain
+// only files (directories are implied by paths) and can be read by any tar.
+const std = @import("std");
+
+const block_size = 512;
+
+pub const ArchiveError = error{
+    NameTooLong,
+};
+
+pub const TarWriter = struct {
+    allocator: std.mem.Allocator,
+    buffer: std.ArrayList(u8),
+    /// Modification time recorded for every entry (Unix seconds)
+    mtime: i64,
+
+    pub fn init(allocator: std.mem.Allocator, mtime: i64) TarWriter {
+        return .{
+            .allocator = allocator,
+            .buffer = std.ArrayList(u8){},
+            .mtime = mtime,
+        };
+    }
+
+    pub fn deinit(self: *TarWriter) void {
+        self.buffer.deinit(self.allocator);
+    }
+
+    /// Append a regular file with mode 0644
+    pub fn addFile(self: *TarWriter, path: []const u8, data: []const u8) !void {
+        var header = [_]u8{0} ** block_size;
+        try setPath(&header, path);
+        writeOctal(header[100..108], 0o644);
+        writeOctal(header[108..116], 0);
+        writeOctal(header[116..124], 0);
+        writeOctal(header[124..136], data.len);
+        writeOctal(header[136..148], @intCast(@max(self.mtime, 0)));
+        header[156] = '0';
+        @memcpy(header[257..263], "ustar\x00");
+        @memcpy(header[263..265], "00");
+
+        // The checksum is computed with its own field filled with spaces
+        @memset(header[148..156], ' ');
+        var checksum: usize = 0;
+        for (header) |byte| checksum += byte;
+        writeOctal(header[148..155], checksum);
+        header[155] = ' ';
+
+        try self.buffer.appendSlice(self.allocator, &header);
+        try self.buffer.appendSlice(self.allocator, data);
+        try self.buffer.appendNTimes(self.allocator, 0, padding(data.len));
+    }
+
+    /// Terminate the archive and return its bytes; the writer is left empty
+    pub fn finish(self: *TarWriter) ![]u8 {
+        try self.buffer.appendNTimes(self.allocator, 0, 2 * block_size);
+        return self.buffer.toOwnedSlice(self.allocator);
+    }
+};
+
+fn padding(len: usize) usize {
+    return (block_size - len % block_size) % block_size;
+}
+
+/// Store `path` in the name field, splitting long paths into prefix and name
+fn setPath(header: *[block_size]u8, path: []const u8) ArchiveError!void {
+    if (path.len <= 100) {
+        @memcpy(header[0..path.len], path);
+        return;
+    }
+    // The prefix must end at a '/' and hold at most 155 bytes
+    var split = @min(path.len - 1, 155);
+    while (split > 0 and path[split] != '/') split -= 1;
+    if (split == 0 or path.len - split - 1 > 100) return ArchiveError.NameTooLong;
+    @memcpy(header[345..][0..split], path[0..split]);
+    @memcpy(header[0 .. path.len - split - 1], path[split + 1 ..]);
+}
+
+/// Zero-padded octal filling all but the last byte of `field`, which stays NUL
+fn writeOctal(field: []u8, value: u64) void {
+    var v = value;
+    var i = field.len - 1;
+    field[i] = 0;
+    while (i > 0) {
+        i -= 1;
+        field[i] = '0' + @as(u8, @intCast(v & 7));
+        v >>= 3;
+    }
+}
+
+test "tar writer produces ustar headers" {
+    const testing = std.testing;
+
+    var tar = TarWriter.init(testing.allocator, 1_700_000_000);
+    defer tar.deinit();
+    try tar.addFile("bundle/manifest.json", "{}\n");
+    try tar.addFile("bundle/" ++ "r" ** 120 ++ "/x.ariadne", "");
+    const bytes = try tar.finish();
+    defer testing.allocator.free(bytes);
+
+    // Two headers, one data block, two end-of-archive blocks
+    try testing.expectEqual(@as(usize, 5 * block_size), bytes.len);
+    try testing.expectEqualStrings("bundle/manifest.json", std.mem.sliceTo(bytes[0..100], 0));
+    try testing.expectEqualStrings("00000000003", bytes[124..135]);
+    try testing.expectEqualStrings("ustar\x00", bytes[257..263]);
+
+    var sum: usize = 0;
+    for (bytes[0..block_size], 0..) |byte, i| sum += if (i >= 148 and i < 156) ' ' else byte;
+    try testing.expectEqual(sum, try std.fmt.parseInt(usize, bytes[148..154], 8));
+
+    const second = bytes[2 * block_size ..][0..block_size];
+    try testing.expectEqualStrings("x.ariadne", std.mem.sliceTo(second[0..100], 0));
+    try testing.expect(std.mem.startsWith(u8, second[345..], "bundle/rrr"));
+}
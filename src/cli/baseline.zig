@@ -0,0 +1,173 @@
+// Constraint baselines
+// A baseline records the constraints accepted in a codebase today so later runs
+// report only findings that are new, letting large legacy repos adopt Ananke
+// incrementally. Entries are keyed by a line-independent fingerprint (file,
+// kind, name), so unrelated edits that shift code do not resurface old findings.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const constraint_diff = @import("cli_constraint_diff");
+
+pub const format_version: u32 = 1;
+
+/// Largest baseline file read into memory
+const max_baseline_bytes = 64 * 1024 * 1024;
+
+pub const BaselineError = error{
+    UnsupportedVersion,
+    InvalidFingerprint,
+};
+
+/// On-disk layout, one entry per accepted constraint (repeated fingerprints allowed)
+const FileFormat = struct {
+    version: u32,
+    entries: []const Entry,
+
+    const Entry = struct {
+        fingerprint: []const u8,
+    };
+};
+
+pub const FilterStats = struct {
+    /// Constraints dropped because the baseline already accepts them
+    suppressed: usize = 0,
+    /// Baseline entries with no matching constraint (fixed or renamed)
+    stale: usize = 0,
+};
+
+pub const Baseline = struct {
+    allocator: std.mem.Allocator,
+    /// Fingerprint -> number of accepted occurrences
+    counts: std.AutoHashMap(u64, u32),
+
+    pub fn init(allocator: std.mem.Allocator) Baseline {
+        return .{
+            .allocator = allocator,
+            .counts = std.AutoHashMap(u64, u32).init(allocator),
+        };
+    }
+
+    pub fn deinit(self: *Baseline) void {
+        self.counts.deinit();
+    }
+
+    pub fn loadFile(allocator: std.mem.Allocator, path: []const u8) !Baseline {
+        const text = try std.fs.cwd().readFileAlloc(allocator, path, max_baseline_bytes);
+        defer allocator.free(text);
+        return parse(allocator, text);
+    }
+
+    pub fn parse(allocator: std.mem.Allocator, text: []const u8) !Baseline {
+        const parsed = try std.json.parseFromSlice(FileFormat, allocator, text, .{ .ignore_unknown_fields = true });
+        defer parsed.deinit();
+        if (parsed.value.version != format_version) return BaselineError.UnsupportedVersion;
+
+        var baseline = Baseline.init(allocator);
+        errdefer baseline.deinit();
+        for (parsed.value.entries) |entry| {
+            const fingerprint = std.fmt.parseInt(u64, entry.fingerprint, 16) catch return BaselineError.InvalidFingerprint;
+            const gop = try baseline.counts.getOrPut(fingerprint);
+            gop.value_ptr.* = if (gop.found_existing) gop.value_ptr.* + 1 else 1;
+        }
+        return baseline;
+    }
+
+    /// Remove constraints covered by the baseline, keeping only new findings
+    pub fn filter(self: *const Baseline, items: *std.ArrayList(constraint.Constraint)) !FilterStats {
+        var remaining = try self.counts.clone();
+        defer remaining.deinit();
+
+        var stats = FilterStats{};
+        var i: usize = 0;
+        while (i < items.items.len) {
+            if (remaining.getPtr(constraint_diff.identityHash(items.items[i]))) |count| {
+                if (count.* > 0) {
+                    count.* -= 1;
+                    _ = items.orderedRemove(i);
+                    stats.suppressed += 1;
+                    continue;
+                }
+            }
+            i += 1;
+        }
+
+        var it = remaining.valueIterator();
+        while (it.next()) |count| stats.stale += count.*;
+        return stats;
+    }
+};
+
+fn entryLessThan(_: void, a: constraint.Constraint, b: constraint.Constraint) bool {
+    const file_order = std.mem.order(u8, a.origin_file orelse "", b.origin_file orelse "");
+    if (file_order != .eq) return file_order == .lt;
+    if (a.kind != b.kind) return @intFromEnum(a.kind) < @intFromEnum(b.kind);
+    return std.mem.lessThan(u8, a.name, b.name);
+}
+
+/// Serialize constraints as a baseline. Entries are sorted so the file diffs
+/// cleanly when committed; file, kind, and name are kept for human review.
+pub fn formatBaseline(allocator: std.mem.Allocator, items: []const constraint.Constraint) ![]u8 {
+    const sorted = try allocator.dupe(constraint.Constraint, items);
+    defer allocator.free(sorted);
+    std.mem.sort(constraint.Constraint, sorted, {}, entryLessThan);
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\n  \"version\": {d},\n  \"entries\": [\n", .{format_version});
+    for (sorted, 0..) |c, i| {
+        try writer.print("    {{\"fingerprint\": \"{x:0>16}\", \"file\": \"", .{constraint_diff.identityHash(c)});
+        try output.writeJsonEscaped(writer, c.origin_file orelse "");
+        try writer.print("\", \"kind\": \"{s}\", \"name\": \"", .{@tagName(c.kind)});
+        try output.writeJsonEscaped(writer, c.name);
+        try writer.writeAll("\"}");
+        if (i + 1 < sorted.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("  ]\n}\n");
+
+    return list.toOwnedSlice(allocator);
+}
+
+test "baseline round trip suppresses accepted constraints" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const accepted = [_]constraint.Constraint{
+        .{ .name = "null_safety", .description = "at line 3", .kind = .type_safety, .origin_file = "a.ts", .origin_line = 3 },
+        .{ .name = "sql_params", .description = "x", .kind = .security, .origin_file = "a.ts" },
+        .{ .name = "retry_budget", .description = "x", .kind = .operational, .origin_file = "b.go" },
+    };
+    const text = try formatBaseline(allocator, &accepted);
+    defer allocator.free(text);
+
+    var baseline = try Baseline.parse(allocator, text);
+    defer baseline.deinit();
+
+    var current = std.ArrayList(constraint.Constraint){};
+    defer current.deinit(allocator);
+    try current.appendSlice(allocator, &.{
+        // Moved to a new line: still accepted
+        .{ .name = "null_safety", .description = "at line 12", .kind = .type_safety, .origin_file = "a.ts", .origin_line = 12 },
+        .{ .name = "sql_params", .description = "x", .kind = .security, .origin_file = "a.ts" },
+        // Second occurrence exceeds the accepted count: new
+        .{ .name = "sql_params", .description = "y", .kind = .security, .origin_file = "a.ts" },
+        .{ .name = "auth_required", .description = "x", .kind = .security, .origin_file = "b.go" },
+    });
+
+    const stats = try baseline.filter(&current);
+    try testing.expectEqual(@as(usize, 2), stats.suppressed);
+    try testing.expectEqual(@as(usize, 1), stats.stale);
+    try testing.expectEqual(@as(usize, 2), current.items.len);
+    try testing.expectEqualStrings("sql_params", current.items[0].name);
+    try testing.expectEqualStrings("auth_required", current.items[1].name);
+}
+
+test "baseline rejects unknown versions" {
+    try std.testing.expectError(
+        BaselineError.UnsupportedVersion,
+        Baseline.parse(std.testing.allocator, "{\"version\": 99, \"entries\": []}"),
+    );
+}
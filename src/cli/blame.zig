@@ -0,0 +1,140 @@
+// Git blame enrichment
+// Looks up the commit and author that last changed each constraint's origin
+// line, so output can show who owns a constraint and how long it has held.
+// Runs one `git blame --line-porcelain` per file, limited with `-L` to the
+// lines that carry constraints.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const git = @import("cli_git");
+
+/// Last change to a line
+pub const Authorship = struct {
+    commit: []const u8,
+    author: []const u8,
+    email: []const u8,
+    /// Author time, seconds since the epoch
+    time: i64,
+
+    /// Write the author date as YYYY-MM-DD (UTC)
+    pub fn writeDate(self: Authorship, writer: anytype) !void {
+        const epoch_seconds = std.time.epoch.EpochSeconds{ .secs = @intCast(@max(self.time, 0)) };
+        const year_day = epoch_seconds.getEpochDay().calculateYearDay();
+        const month_day = year_day.calculateMonthDay();
+        try writer.print("{d:0>4}-{d:0>2}-{d:0>2}", .{ year_day.year, month_day.month.numeric(), month_day.day_index + 1 });
+    }
+};
+
+/// Commit git reports for lines that are not committed yet
+const uncommitted = "0000000000000000000000000000000000000000";
+
+/// Authorship of each constraint's origin line, parallel to `constraints`.
+/// Null for constraints without a line, for files git does not track, and
+/// for lines changed since the last commit. Strings live in `arena`.
+pub fn annotate(arena: std.mem.Allocator, constraints: []const constraint.Constraint) ![]?Authorship {
+    const authorship = try arena.alloc(?Authorship, constraints.len);
+    @memset(authorship, null);
+
+    var by_file = std.StringArrayHashMap(std.ArrayList(usize)).init(arena);
+    for (constraints, 0..) |c, i| {
+        const file = c.origin_file orelse continue;
+        const line = c.origin_line orelse continue;
+        if (line == 0) continue;
+        const gop = try by_file.getOrPut(file);
+        if (!gop.found_existing) gop.value_ptr.* = std.ArrayList(usize){};
+        try gop.value_ptr.append(arena, i);
+    }
+
+    for (by_file.keys(), by_file.values()) |file, indices| {
+        var argv = std.ArrayList([]const u8){};
+        try argv.appendSlice(arena, &.{ "git", "blame", "--line-porcelain" });
+        for (indices.items) |i| {
+            const line = constraints[i].origin_line.?;
+            try argv.appendSlice(arena, &.{ "-L", try std.fmt.allocPrint(arena, "{d},{d}", .{ line, line }) });
+        }
+        try argv.appendSlice(arena, &.{ "--", file });
+
+        const text = git.runGit(arena, argv.items) catch |err| switch (err) {
+            // Untracked, outside any repository, or shorter than a stale line number
+            git.GitError.GitFailed => continue,
+            else => return err,
+        };
+        var lines = try parseLinePorcelain(arena, text);
+        for (indices.items) |i| authorship[i] = lines.get(constraints[i].origin_line.?);
+    }
+    return authorship;
+}
+
+/// Parse `git blame --line-porcelain` output into the authorship of each final
+/// line number. Uncommitted lines are left out.
+pub fn parseLinePorcelain(arena: std.mem.Allocator, text: []const u8) !std.AutoHashMap(u32, Authorship) {
+    var entries = std.AutoHashMap(u32, Authorship).init(arena);
+    var current = Authorship{ .commit = "", .author = "", .email = "", .time = 0 };
+    var final_line: u32 = 0;
+    var in_entry = false;
+
+    var lines = std.mem.splitScalar(u8, text, '\n');
+    while (lines.next()) |line| {
+        if (line.len == 0) continue;
+        // The line's content ends each entry
+        if (line[0] == '\t') {
+            if (!in_entry) return git.GitError.MalformedOutput;
+            if (!std.mem.eql(u8, current.commit, uncommitted)) try entries.put(final_line, current);
+            in_entry = false;
+            continue;
+        }
+        if (!in_entry) {
+            // "<commit> <original line> <final line> [<lines in group>]"
+            var fields = std.mem.tokenizeScalar(u8, line, ' ');
+            const commit = fields.next() orelse return git.GitError.MalformedOutput;
+            _ = fields.next() orelse return git.GitError.MalformedOutput;
+            final_line = std.fmt.parseInt(u32, fields.next() orelse return git.GitError.MalformedOutput, 10) catch
+                return git.GitError.MalformedOutput;
+            current = .{ .commit = commit, .author = "", .email = "", .time = 0 };
+            in_entry = true;
+        } else if (std.mem.startsWith(u8, line, "author ")) {
+            current.author = line["author ".len..];
+        } else if (std.mem.startsWith(u8, line, "author-mail ")) {
+            current.email = std.mem.trim(u8, line["author-mail ".len..], "<>");
+        } else if (std.mem.startsWith(u8, line, "author-time ")) {
+            current.time = std.fmt.parseInt(i64, line["author-time ".len..], 10) catch return git.GitError.MalformedOutput;
+        }
+    }
+    return entries;
+}
+
+test "parse git blame line porcelain" {
+    var arena = std.heap.ArenaAllocator.init(std.testing.allocator);
+    defer arena.deinit();
+
+    var lines = try parseLinePorcelain(arena.allocator(),
+        \\9f2c1e4b7a0d3c5e8f1a2b3c4d5e6f7a8b9c0d1e 10 12 1
+        \\author Ada Lovelace
+        \\author-mail <ada@example.com>
+        \\author-time 1700000000
+        \\author-tz +0000
+        \\committer Ada Lovelace
+        \\summary Bind query parameters
+        \\filename db/query.go
+        \\	rows, err := db.Query(q, id)
+        \\0000000000000000000000000000000000000000 40 40 1
+        \\author Not Committed Yet
+        \\author-mail <not.committed.yet>
+        \\author-time 1710000000
+        \\filename db/query.go
+        \\	return nil
+        \\
+    );
+    const entry = lines.get(12).?;
+    try std.testing.expectEqualStrings("9f2c1e4b7a0d3c5e8f1a2b3c4d5e6f7a8b9c0d1e", entry.commit);
+    try std.testing.expectEqualStrings("Ada Lovelace", entry.author);
+    try std.testing.expectEqualStrings("ada@example.com", entry.email);
+    try std.testing.expectEqual(@as(i64, 1700000000), entry.time);
+    try std.testing.expect(lines.get(40) == null);
+
+    var date = std.ArrayList(u8){};
+    try entry.writeDate(date.writer(arena.allocator()));
+    try std.testing.expectEqualStrings("2023-11-14", date.items);
+
+    try std.testing.expectError(git.GitError.MalformedOutput, parseLinePorcelain(arena.allocator(), "\tstray\n"));
+}
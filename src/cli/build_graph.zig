@@ -0,0 +1,167 @@
+// Build graph scoping
+// Lists the source files a build target depends on, directly or transitively,
+// by asking the build system (`bazel query` or `go list -deps`), so extraction
+// can cover exactly what a target is built from instead of directory globs.
+// Only files of the main workspace are listed: external repositories, module
+// dependencies, and the Go standard library are left out.
+const std = @import("std");
+
+pub const BuildGraphError = error{
+    /// The build tool is not installed or not in PATH
+    ToolNotFound,
+    /// The query failed; the tool's own message went to stderr
+    QueryFailed,
+};
+
+/// Largest query output buffered in memory
+const max_output_bytes = 256 * 1024 * 1024;
+
+pub const System = enum {
+    bazel,
+    go,
+
+    pub fn fromString(text: []const u8) ?System {
+        return std.meta.stringToEnum(System, text);
+    }
+
+    /// Build system of the workspace rooted at `dir`
+    pub fn detect(dir: std.fs.Dir) ?System {
+        for ([_][]const u8{ "MODULE.bazel", "WORKSPACE.bazel", "WORKSPACE" }) |name| {
+            if (dir.access(name, .{})) |_| return .bazel else |_| {}
+        }
+        for ([_][]const u8{ "go.work", "go.mod" }) |name| {
+            if (dir.access(name, .{})) |_| return .go else |_| {}
+        }
+        return null;
+    }
+};
+
+/// Go template printing the Go and cgo files of main-module packages, one
+/// absolute path per line
+const go_list_template =
+    "{{if and .Module .Module.Main}}" ++
+    "{{range .GoFiles}}{{$.Dir}}/{{.}}\n{{end}}" ++
+    "{{range .CgoFiles}}{{$.Dir}}/{{.}}\n{{end}}" ++
+    "{{end}}";
+
+/// Sources of `targets` (comma-separated Bazel labels or Go package patterns)
+/// and everything they depend on, relative to the current directory, which
+/// must be the workspace root. Paths live in `arena`.
+pub fn transitiveSources(arena: std.mem.Allocator, system: System, targets: []const u8) ![]const []const u8 {
+    var names = std.ArrayList([]const u8){};
+    var it = std.mem.tokenizeAny(u8, targets, ", ");
+    while (it.next()) |target| try names.append(arena, target);
+
+    switch (system) {
+        .bazel => {
+            var expression = std.ArrayList(u8){};
+            try expression.appendSlice(arena, "kind(\"source file\", deps(");
+            for (names.items, 0..) |target, i| {
+                if (i > 0) try expression.appendSlice(arena, " + ");
+                try expression.appendSlice(arena, target);
+            }
+            try expression.appendSlice(arena, "))");
+            const text = try run(arena, &.{ "bazel", "query", "--output=label", "--keep_going", "--noshow_progress", expression.items });
+            return parseBazelLabels(arena, text);
+        },
+        .go => {
+            var argv = std.ArrayList([]const u8){};
+            try argv.appendSlice(arena, &.{ "go", "list", "-deps", "-f", go_list_template, "--" });
+            try argv.appendSlice(arena, names.items);
+            const text = try run(arena, argv.items);
+            const cwd = try std.process.getCwdAlloc(arena);
+            return parseAbsolutePaths(arena, cwd, text);
+        },
+    }
+}
+
+/// Run a query and return its stdout; stderr goes to the terminal, where
+/// the tool explains unknown targets and broken builds
+fn run(arena: std.mem.Allocator, argv: []const []const u8) ![]const u8 {
+    var child = std.process.Child.init(argv, arena);
+    child.stdin_behavior = .Ignore;
+    child.stdout_behavior = .Pipe;
+    child.stderr_behavior = .Inherit;
+    child.spawn() catch |err| switch (err) {
+        error.FileNotFound => return BuildGraphError.ToolNotFound,
+        else => return err,
+    };
+    const stdout = try child.stdout.?.readToEndAlloc(arena, max_output_bytes);
+    const term = try child.wait();
+    const ok = switch (term) {
+        .Exited => |code| code == 0,
+        else => false,
+    };
+    if (!ok) return BuildGraphError.QueryFailed;
+    return stdout;
+}
+
+/// Workspace-relative paths of `bazel query --output=label` source file
+/// labels (`//pkg/sub:dir/file.go` is `pkg/sub/dir/file.go`). Labels of
+/// external repositories are left out.
+pub fn parseBazelLabels(arena: std.mem.Allocator, text: []const u8) ![]const []const u8 {
+    var paths = std.ArrayList([]const u8){};
+    var lines = std.mem.tokenizeAny(u8, text, "\r\n");
+    while (lines.next()) |line| {
+        var label = std.mem.trim(u8, line, " \t");
+        // The main repository may be spelled out under Bzlmod
+        for ([_][]const u8{ "@@//", "@//" }) |main_prefix| {
+            if (std.mem.startsWith(u8, label, main_prefix)) {
+                label = label[main_prefix.len - 2 ..];
+                break;
+            }
+        }
+        if (!std.mem.startsWith(u8, label, "//")) continue;
+        const colon = std.mem.indexOfScalar(u8, label, ':') orelse continue;
+        const package = label[2..colon];
+        const name = label[colon + 1 ..];
+        if (name.len == 0) continue;
+        try paths.append(arena, if (package.len == 0) name else try std.fmt.allocPrint(arena, "{s}/{s}", .{ package, name }));
+    }
+    return paths.items;
+}
+
+/// Absolute paths, one per line, made relative to `cwd`; paths outside it
+/// are left out
+pub fn parseAbsolutePaths(arena: std.mem.Allocator, cwd: []const u8, text: []const u8) ![]const []const u8 {
+    var paths = std.ArrayList([]const u8){};
+    var lines = std.mem.tokenizeAny(u8, text, "\r\n");
+    while (lines.next()) |line| {
+        const rel = try std.fs.path.relative(arena, cwd, line);
+        if (rel.len == 0 or std.mem.eql(u8, rel, "..") or std.mem.startsWith(u8, rel, "../") or std.fs.path.isAbsolute(rel)) continue;
+        try paths.append(arena, rel);
+    }
+    return paths.items;
+}
+
+test "parse build graph query output" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const labels = try parseBazelLabels(arena,
+        \\//svc/api:main.go
+        \\//svc/api:handlers/auth.go
+        \\//:version.go
+        \\@@//lib/db:query.go
+        \\@com_github_lib_pq//:conn.go
+        \\@@rules_go++go_sdk+go_sdk//:src/fmt/print.go
+        \\
+    );
+    try testing.expectEqual(@as(usize, 4), labels.len);
+    try testing.expectEqualStrings("svc/api/main.go", labels[0]);
+    try testing.expectEqualStrings("svc/api/handlers/auth.go", labels[1]);
+    try testing.expectEqualStrings("version.go", labels[2]);
+    try testing.expectEqualStrings("lib/db/query.go", labels[3]);
+
+    const files = try parseAbsolutePaths(arena, "/work/repo",
+        \\/work/repo/cmd/server/main.go
+        \\/work/repo/internal/db/query.go
+        \\/work/other/shared.go
+        \\
+    );
+    try testing.expectEqual(@as(usize, 2), files.len);
+    try testing.expectEqualStrings("cmd/server/main.go", files[0]);
+    try testing.expectEqualStrings("internal/db/query.go", files[1]);
+}
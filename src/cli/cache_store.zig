@@ -0,0 +1,905 @@
+// On-disk extraction cache
+// Stores the constraints extracted from each source file under a digest of
+// the file's content, language, and tool version, the digest of the
+// language's rule set, and the constraint kinds enabled when they are
+// restricted, so unchanged files are not re-extracted on later runs and a
+// rule or kind change re-extracts the files it affects. Entries hold the `extract --format json` layout,
+// LZ4-compressed behind a 4-byte little-endian uncompressed length.
+//
+//   <dir>/entries/ab/cdef....lz4    one entry per digest, sharded by prefix
+//   <dir>/stats.json                hit/miss counters accumulated over runs
+//   <dir>/runs.jsonl                counters of the most recent runs
+//   <dir>/warm/<key>.state          discovery snapshots (see warm_state.zig)
+//   <dir>/rules/                    earlier rule sets (see rule_history.zig)
+//   <dir>/incremental/<key>.state   --incremental manifests (see incremental.zig)
+//   <dir>/bodies.scan               pattern scans of function bodies
+//   <dir>/summaries/<digest>.json   LLM package summaries (see summarize.zig)
+//
+// A cache can be backed by a shared remote cache (see remote_cache.zig):
+// local misses are fetched from it, and stored entries are uploaded to it.
+//
+// Loading an entry refreshes its modification time, so `ananke cache gc`
+// evicts the least recently used entries. A cache opened with `max_bytes`
+// does the same at the end of each run once its entries outgrow the limit.
+// stats.json tracks their size between runs, so the entries are only walked
+// when the limit may have been reached.
+//
+// Entries cover whole files. Below them, bodies.scan keeps the pattern scan
+// of every function body seen (see patterns.BodyCache), so a file that
+// misses because one function changed rescans only that function.
+const std = @import("std");
+const ananke = @import("ananke");
+const lz4 = @import("cli_lz4");
+const output = @import("cli_output");
+const remote_cache = @import("cli_remote_cache");
+const results = @import("cli_results");
+
+const constraint = ananke.types.constraint;
+const patterns = ananke.clew.patterns;
+
+pub const default_dir = ".ananke-cache";
+/// Size of the entries beyond which a run evicts the least recently used
+pub const default_max_bytes: u64 = 2 << 30;
+
+const entries_dir = "entries";
+pub const warm_dir = "warm";
+pub const rules_dir = "rules";
+pub const incremental_dir = "incremental";
+/// Must match summarize.cache_subdir
+pub const summaries_dir = "summaries";
+const bodies_file = "bodies.scan";
+const bodies_magic = "ananke-bodies 1";
+const max_bodies_bytes = 256 * 1024 * 1024;
+const stats_file = "stats.json";
+const max_stats_bytes = 64 * 1024;
+const runs_file = "runs.jsonl";
+/// Runs kept in runs.jsonl
+pub const max_recorded_runs = 100;
+const max_runs_bytes = 1024 * 1024;
+
+pub const Key = [std.crypto.hash.sha2.Sha256.digest_length * 2]u8;
+
+/// Digest identifying one extraction. Entries written by other tool versions,
+/// under another version of the language's rules or other enabled kinds
+/// (folded into `rules_digest` by the caller), or for other languages never
+/// match.
+pub fn computeKey(tool_version: []const u8, rules_digest: []const u8, language: []const u8, source: []const u8) Key {
+    var hasher = std.crypto.hash.sha2.Sha256.init(.{});
+    hasher.update(tool_version);
+    hasher.update(&.{0});
+    hasher.update(rules_digest);
+    hasher.update(&.{0});
+    hasher.update(language);
+    hasher.update(&.{0});
+    hasher.update(source);
+    return std.fmt.bytesToHex(hasher.finalResult(), .lower);
+}
+
+/// Counters for one run, or accumulated over every run in stats.json
+pub const Counters = struct {
+    runs: u64 = 0,
+    hits: u64 = 0,
+    misses: u64 = 0,
+    writes: u64 = 0,
+    /// Entries evicted to keep the cache within its size limit
+    evictions: u64 = 0,
+    /// Unix timestamp of the last recorded run
+    last_run: i64 = 0,
+    /// Size of the entries after the last run; null when unknown
+    bytes: ?u64 = null,
+
+    /// Add the lookups, writes, and evictions of `other`
+    pub fn add(self: *Counters, other: Counters) void {
+        self.hits += other.hits;
+        self.misses += other.misses;
+        self.writes += other.writes;
+        self.evictions += other.evictions;
+    }
+
+    /// Fraction of lookups served from the cache
+    pub fn hitRate(self: Counters) f64 {
+        const lookups = self.hits + self.misses;
+        if (lookups == 0) return 0;
+        return @as(f64, @floatFromInt(self.hits)) / @as(f64, @floatFromInt(lookups));
+    }
+};
+
+/// An open cache directory. Not thread-safe: look up and store entries from
+/// the thread that merges results, or hand stores to a `Writer` and do no
+/// lookups until it finishes.
+pub const Cache = struct {
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    /// Counters for the current run
+    run: Counters = .{},
+    /// Entries that could not be written (full disk, permissions)
+    write_errors: usize = 0,
+    /// Size of the entries written this run
+    written_bytes: u64 = 0,
+    /// Evict least recently used entries beyond this size; 0 for no limit
+    max_bytes: u64 = 0,
+    /// What the end-of-run size check evicted
+    evicted: GcStats = .{},
+    /// Shared second tier behind the local directory
+    remote: ?*remote_cache.Remote = null,
+    /// Function-body scans, loaded on the first extraction that misses
+    bodies: ?*patterns.BodyCache = null,
+    /// Tool version the body scans are saved under
+    bodies_version: []const u8 = "",
+
+    /// Open the cache, creating the directory if needed
+    pub fn open(allocator: std.mem.Allocator, path: []const u8) !Cache {
+        try std.fs.cwd().makePath(path);
+        return .{
+            .allocator = allocator,
+            .dir = try std.fs.cwd().openDir(path, .{}),
+        };
+    }
+
+    pub fn close(self: *Cache) void {
+        if (self.bodies) |bodies| {
+            // Unsaved scans only cost the next run a rescan
+            if (bodies.added > 0) self.saveBodies(bodies) catch {};
+            bodies.deinit();
+            self.allocator.destroy(bodies);
+        }
+        if (self.remote) |remote| remote.close();
+        self.dir.close();
+    }
+
+    /// Body scans for the engines of this run, loaded from bodies.scan on
+    /// first use. Scans saved by another tool version are dropped. Call from
+    /// the thread that owns the cache, before workers start.
+    pub fn bodyCache(self: *Cache, tool_version: []const u8) ?*patterns.BodyCache {
+        if (self.bodies) |bodies| return bodies;
+        const bodies = self.allocator.create(patterns.BodyCache) catch return null;
+        bodies.* = patterns.BodyCache.init(self.allocator);
+        self.bodies = bodies;
+        self.bodies_version = tool_version;
+
+        const text = self.dir.readFileAlloc(self.allocator, bodies_file, max_bodies_bytes) catch return bodies;
+        defer self.allocator.free(text);
+        const newline = std.mem.indexOfScalar(u8, text, '\n') orelse return bodies;
+        const header = text[0..newline];
+        if (!std.mem.startsWith(u8, header, bodies_magic ++ " ")) return bodies;
+        if (!std.mem.eql(u8, header[bodies_magic.len + 1 ..], tool_version)) return bodies;
+        bodies.load(text[newline + 1 ..]) catch {};
+        return bodies;
+    }
+
+    fn saveBodies(self: *Cache, bodies: *patterns.BodyCache) !void {
+        var text = std.ArrayList(u8){};
+        defer text.deinit(self.allocator);
+        const writer = text.writer(self.allocator);
+        try writer.print("{s} {s}\n", .{ bodies_magic, self.bodies_version });
+        try bodies.save(writer);
+        try writeAtomic(self.dir, bodies_file, text.items);
+    }
+
+    /// Back the cache with the remote cache at `url`
+    pub fn connectRemote(self: *Cache, url: []const u8, mode: remote_cache.Mode) !void {
+        std.debug.assert(self.remote == null);
+        self.remote = try remote_cache.Remote.open(self.allocator, url, mode);
+    }
+
+    /// Load the constraints stored under `key`, or null on a miss.
+    /// Unreadable or corrupt entries count as misses and are overwritten later.
+    pub fn load(self: *Cache, key: *const Key) ?results.ResultFile {
+        var buf: [entry_path_len]u8 = undefined;
+        const path = entryPath(&buf, key);
+
+        const data = self.dir.readFileAlloc(self.allocator, path, results.max_result_bytes) catch {
+            return self.loadRemote(key, path);
+        };
+        defer self.allocator.free(data);
+        const entry = parseEntry(self.allocator, data) catch {
+            self.run.misses += 1;
+            return null;
+        };
+
+        touch(self.dir, path);
+        self.run.hits += 1;
+        return entry;
+    }
+
+    /// Fetch a local miss from the remote cache, keeping a local copy so the
+    /// next run finds it without a request
+    fn loadRemote(self: *Cache, key: *const Key, path: []const u8) ?results.ResultFile {
+        const remote = self.remote orelse {
+            self.run.misses += 1;
+            return null;
+        };
+        const text = remote.fetch(key) orelse {
+            self.run.misses += 1;
+            return null;
+        };
+        defer self.allocator.free(text);
+        const entry = results.ResultFile.parse(self.allocator, text) catch {
+            self.run.misses += 1;
+            return null;
+        };
+
+        self.writeEntry(path, text) catch {};
+        self.run.hits += 1;
+        return entry;
+    }
+
+    /// Load the entry stored under `from`, an earlier key for an extraction
+    /// known to give the same result, and keep a copy under `key`. Called
+    /// after `load(key)` missed; success turns that miss into a hit.
+    pub fn carryOver(self: *Cache, from: *const Key, key: *const Key) ?results.ResultFile {
+        var from_buf: [entry_path_len]u8 = undefined;
+        const data = self.dir.readFileAlloc(self.allocator, entryPath(&from_buf, from), results.max_result_bytes) catch return null;
+        defer self.allocator.free(data);
+        const entry = parseEntry(self.allocator, data) catch return null;
+
+        var buf: [entry_path_len]u8 = undefined;
+        const path = entryPath(&buf, key);
+        self.dir.makePath(std.fs.path.dirname(path).?) catch {};
+        if (writeAtomic(self.dir, path, data)) {
+            self.written_bytes += data.len;
+        } else |_| {}
+        self.run.misses -|= 1;
+        self.run.hits += 1;
+        return entry;
+    }
+
+    /// Store the constraints extracted for `key`. Their files are dropped:
+    /// keys hold no path, so an entry serves the same content at any path in
+    /// any checkout, and the reader attaches its own path on merge.
+    pub fn store(self: *Cache, key: *const Key, set: constraint.ConstraintSet) !void {
+        const portable = try self.allocator.dupe(constraint.Constraint, set.constraints.items);
+        defer self.allocator.free(portable);
+        for (portable) |*c| c.origin_file = null;
+        const text = try output.formatJson(self.allocator, .{
+            .constraints = .{ .items = portable, .capacity = portable.len },
+            .name = "code_constraints",
+            .allocator = self.allocator,
+        });
+        defer self.allocator.free(text);
+
+        var buf: [entry_path_len]u8 = undefined;
+        try self.writeEntry(entryPath(&buf, key), text);
+        self.run.writes += 1;
+        if (self.remote) |remote| remote.upload(key, text);
+    }
+
+    /// Compress `text` into the entry at `path`
+    fn writeEntry(self: *Cache, path: []const u8, text: []const u8) !void {
+        var data = std.ArrayList(u8){};
+        defer data.deinit(self.allocator);
+        var length: [4]u8 = undefined;
+        std.mem.writeInt(u32, &length, std.math.cast(u32, text.len) orelse return error.EntryTooLarge, .little);
+        try data.appendSlice(self.allocator, &length);
+        try lz4.compress(self.allocator, &data, text);
+
+        try self.dir.makePath(std.fs.path.dirname(path).?);
+        try writeAtomic(self.dir, path, data.items);
+        self.written_bytes += data.items.len;
+    }
+
+    /// Add this run's counters to the totals in stats.json and to the
+    /// recent runs in runs.jsonl, first evicting the least recently used
+    /// entries if the cache outgrew `max_bytes`
+    pub fn recordRun(self: *Cache) !void {
+        var totals = try readCounters(self.allocator, self.dir);
+        // Overwritten and evicted entries make this an overestimate, which
+        // only costs an early walk
+        if (totals.bytes) |bytes| totals.bytes = bytes + self.written_bytes;
+        self.written_bytes = 0;
+        self.evicted = .{};
+        if (self.max_bytes > 0 and (totals.bytes orelse std.math.maxInt(u64)) > self.max_bytes) {
+            self.evicted = try evictToSize(self.allocator, self.dir, self.max_bytes, false);
+            totals.bytes = self.evicted.kept_bytes;
+        }
+
+        self.run.runs = 1;
+        self.run.evictions = self.evicted.removed;
+        self.run.last_run = std.time.timestamp();
+        totals.runs += 1;
+        totals.add(self.run);
+        totals.last_run = self.run.last_run;
+
+        var text = std.ArrayList(u8){};
+        defer text.deinit(self.allocator);
+        try writeCounters(text.writer(self.allocator), totals);
+        try text.append(self.allocator, '\n');
+        try writeAtomic(self.dir, stats_file, text.items);
+
+        // Only the newest runs are kept
+        const earlier = self.dir.readFileAlloc(self.allocator, runs_file, max_runs_bytes) catch "";
+        defer self.allocator.free(earlier);
+        var start: usize = 0;
+        var kept = std.mem.count(u8, earlier, "\n");
+        while (kept >= max_recorded_runs) : (kept -= 1) {
+            start = (std.mem.indexOfScalarPos(u8, earlier, start, '\n') orelse earlier.len) + 1;
+        }
+        text.clearRetainingCapacity();
+        try text.appendSlice(self.allocator, earlier[@min(start, earlier.len)..]);
+        try writeCounters(text.writer(self.allocator), self.run);
+        try text.append(self.allocator, '\n');
+        try writeAtomic(self.dir, runs_file, text.items);
+    }
+};
+
+/// Entries waiting for the writer thread before `submit` blocks
+pub const max_pending_entries = 256;
+
+/// Stores cache entries on a dedicated thread so serializing and writing
+/// them does not hold up extraction. `submit` queues an entry and only blocks
+/// while `max_pending_entries` are waiting; the thread takes everything queued
+/// at once and writes it as one batch. Constraint strings are borrowed and
+/// must stay valid until `finish` returns. If the thread cannot be spawned,
+/// entries are written as they are submitted.
+pub const Writer = struct {
+    cache: *Cache,
+    allocator: std.mem.Allocator,
+    mutex: std.Thread.Mutex = .{},
+    /// Signalled when entries are queued or the writer is closed
+    queued: std.Thread.Condition = .{},
+    /// Signalled when the thread takes a batch off the queue
+    drained: std.Thread.Condition = .{},
+    pending: std.ArrayList(Pending) = .{},
+    closed: bool = false,
+    thread: ?std.Thread = null,
+    /// Entries that could not be written; added to the cache on finish
+    failed: usize = 0,
+
+    const Pending = struct {
+        key: Key,
+        constraints: []constraint.Constraint,
+    };
+
+    pub fn init(allocator: std.mem.Allocator, cache: *Cache) Writer {
+        return .{ .cache = cache, .allocator = allocator };
+    }
+
+    /// Spawn the writer thread; `self` must not move until `finish`
+    pub fn start(self: *Writer) void {
+        self.thread = std.Thread.spawn(.{}, run, .{self}) catch null;
+    }
+
+    /// Queue `set` for storage under `key`. The constraint list is copied;
+    /// the strings it points to are not.
+    pub fn submit(self: *Writer, key: *const Key, set: constraint.ConstraintSet) !void {
+        if (self.thread == null) {
+            self.cache.store(key, set) catch {
+                self.failed += 1;
+            };
+            return;
+        }
+        const constraints = try self.allocator.dupe(constraint.Constraint, set.constraints.items);
+        errdefer self.allocator.free(constraints);
+
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        while (self.pending.items.len >= max_pending_entries) self.drained.wait(&self.mutex);
+        try self.pending.append(self.allocator, .{ .key = key.*, .constraints = constraints });
+        self.queued.signal();
+    }
+
+    /// Write everything still queued, stop the thread, and record failures
+    pub fn finish(self: *Writer) void {
+        if (self.thread) |thread| {
+            self.mutex.lock();
+            self.closed = true;
+            self.queued.signal();
+            self.mutex.unlock();
+            thread.join();
+            self.thread = null;
+        }
+        self.pending.deinit(self.allocator);
+        self.cache.write_errors += self.failed;
+        self.failed = 0;
+    }
+
+    fn run(self: *Writer) void {
+        var batch = std.ArrayList(Pending){};
+        defer batch.deinit(self.allocator);
+        while (true) {
+            self.mutex.lock();
+            while (self.pending.items.len == 0 and !self.closed) self.queued.wait(&self.mutex);
+            if (self.pending.items.len == 0) {
+                self.mutex.unlock();
+                return;
+            }
+            std.mem.swap(std.ArrayList(Pending), &batch, &self.pending);
+            self.mutex.unlock();
+            self.drained.broadcast();
+
+            for (batch.items) |entry| {
+                const set = constraint.ConstraintSet{
+                    .constraints = .{ .items = entry.constraints, .capacity = entry.constraints.len },
+                    .name = "code_constraints",
+                    .allocator = self.allocator,
+                };
+                self.cache.store(&entry.key, set) catch {
+                    self.failed += 1;
+                };
+                self.allocator.free(entry.constraints);
+            }
+            batch.clearRetainingCapacity();
+        }
+    }
+};
+
+/// "entries/" ++ 2 ++ "/" ++ 62 ++ ".lz4"
+const entry_path_len = entries_dir.len + 1 + @typeInfo(Key).array.len + 1 + ".lz4".len;
+
+fn entryPath(buf: *[entry_path_len]u8, key: *const Key) []const u8 {
+    return std.fmt.bufPrint(buf, "{s}/{s}/{s}.lz4", .{ entries_dir, key[0..2], key[2..] }) catch unreachable;
+}
+
+/// Decompress and parse a stored entry
+fn parseEntry(allocator: std.mem.Allocator, data: []const u8) !results.ResultFile {
+    if (data.len < 4) return error.CorruptEntry;
+    const length = std.mem.readInt(u32, data[0..4], .little);
+    if (length > results.max_result_bytes) return error.CorruptEntry;
+    const text = try lz4.decompress(allocator, data[4..], length);
+    defer allocator.free(text);
+    return results.ResultFile.parse(allocator, text);
+}
+
+/// Refresh an entry's modification time; failures only affect gc ordering
+fn touch(dir: std.fs.Dir, path: []const u8) void {
+    const file = dir.openFile(path, .{ .mode = .read_write }) catch return;
+    defer file.close();
+    const now = std.time.nanoTimestamp();
+    file.updateTimes(now, now) catch {};
+}
+
+/// Write to a temporary file and rename it into place, so concurrent runs
+/// never read a partially written file
+pub fn writeAtomic(dir: std.fs.Dir, path: []const u8, data: []const u8) !void {
+    var tmp_buf: [std.fs.max_path_bytes]u8 = undefined;
+    const tmp_path = try std.fmt.bufPrint(&tmp_buf, "{s}.tmp-{x}", .{ path, std.crypto.random.int(u64) });
+    try dir.writeFile(.{ .sub_path = tmp_path, .data = data });
+    dir.rename(tmp_path, path) catch |err| {
+        dir.deleteFile(tmp_path) catch {};
+        return err;
+    };
+}
+
+/// Write `counters` as one JSON object, the format of stats.json and of each
+/// line of runs.jsonl
+pub fn writeCounters(writer: anytype, counters: Counters) !void {
+    try writer.print(
+        "{{\"runs\": {d}, \"hits\": {d}, \"misses\": {d}, \"writes\": {d}, \"evictions\": {d}, \"last_run\": {d}",
+        .{ counters.runs, counters.hits, counters.misses, counters.writes, counters.evictions, counters.last_run },
+    );
+    if (counters.bytes) |bytes| try writer.print(", \"bytes\": {d}", .{bytes});
+    try writer.writeAll("}");
+}
+
+/// Totals from stats.json; a missing or unreadable file reads as zero
+pub fn readCounters(allocator: std.mem.Allocator, dir: std.fs.Dir) !Counters {
+    const text = dir.readFileAlloc(allocator, stats_file, max_stats_bytes) catch |err| switch (err) {
+        error.FileNotFound => return .{},
+        else => return err,
+    };
+    defer allocator.free(text);
+    const parsed = std.json.parseFromSlice(Counters, allocator, text, .{ .ignore_unknown_fields = true }) catch return .{};
+    defer parsed.deinit();
+    return parsed.value;
+}
+
+/// Counters of the most recent runs from runs.jsonl, oldest first.
+/// Unreadable lines are skipped.
+pub fn readRuns(allocator: std.mem.Allocator, dir: std.fs.Dir) ![]Counters {
+    const text = dir.readFileAlloc(allocator, runs_file, max_runs_bytes) catch |err| switch (err) {
+        error.FileNotFound => return allocator.alloc(Counters, 0),
+        else => return err,
+    };
+    defer allocator.free(text);
+
+    var runs = std.ArrayList(Counters){};
+    errdefer runs.deinit(allocator);
+    var lines = std.mem.tokenizeScalar(u8, text, '\n');
+    while (lines.next()) |line| {
+        const parsed = std.json.parseFromSlice(Counters, allocator, line, .{ .ignore_unknown_fields = true }) catch continue;
+        defer parsed.deinit();
+        try runs.append(allocator, parsed.value);
+    }
+    return runs.toOwnedSlice(allocator);
+}
+
+/// Whether `dir` looks like a cache directory. Guards destructive commands
+/// against a misconfigured path.
+pub fn isCacheDir(dir: std.fs.Dir) bool {
+    dir.access(entries_dir, .{}) catch {
+        dir.access(stats_file, .{}) catch return false;
+    };
+    return true;
+}
+
+/// Delete every entry and the accumulated statistics, keeping the directory
+pub fn clear(dir: std.fs.Dir) !void {
+    try dir.deleteTree(entries_dir);
+    try dir.deleteTree(warm_dir);
+    try dir.deleteTree(rules_dir);
+    try dir.deleteTree(incremental_dir);
+    try dir.deleteTree(summaries_dir);
+    dir.deleteFile(bodies_file) catch |err| switch (err) {
+        error.FileNotFound => {},
+        else => return err,
+    };
+    for ([_][]const u8{ stats_file, runs_file }) |name| {
+        dir.deleteFile(name) catch |err| switch (err) {
+            error.FileNotFound => {},
+            else => return err,
+        };
+    }
+}
+
+pub const Usage = struct {
+    entries: usize = 0,
+    bytes: u64 = 0,
+    /// Modification times (last use) of the least and most recently used entries
+    oldest_ns: ?i128 = null,
+    newest_ns: ?i128 = null,
+};
+
+pub const GcStats = struct {
+    removed: usize = 0,
+    freed_bytes: u64 = 0,
+    kept: usize = 0,
+    kept_bytes: u64 = 0,
+};
+
+/// Call `visit(context, shard_dir, name, stat)` for every file under entries/.
+/// Names are collected per shard first, so `visit` may delete the file.
+fn forEachEntry(
+    allocator: std.mem.Allocator,
+    dir: std.fs.Dir,
+    context: anytype,
+    comptime visit: fn (@TypeOf(context), std.fs.Dir, []const u8, std.fs.File.Stat) anyerror!void,
+) !void {
+    var entries = dir.openDir(entries_dir, .{ .iterate = true }) catch |err| switch (err) {
+        error.FileNotFound => return,
+        else => return err,
+    };
+    defer entries.close();
+
+    var names = std.ArrayList([]u8){};
+    defer {
+        for (names.items) |name| allocator.free(name);
+        names.deinit(allocator);
+    }
+
+    var shards = entries.iterate();
+    while (try shards.next()) |shard_entry| {
+        if (shard_entry.kind != .directory) continue;
+        var shard = try entries.openDir(shard_entry.name, .{ .iterate = true });
+        defer shard.close();
+
+        for (names.items) |name| allocator.free(name);
+        names.clearRetainingCapacity();
+        var it = shard.iterate();
+        while (try it.next()) |entry| {
+            if (entry.kind != .file) continue;
+            const name = try allocator.dupe(u8, entry.name);
+            names.append(allocator, name) catch |err| {
+                allocator.free(name);
+                return err;
+            };
+        }
+
+        for (names.items) |name| {
+            const stat = shard.statFile(name) catch continue;
+            try visit(context, shard, name, stat);
+        }
+    }
+}
+
+/// Count entries and their total size
+pub fn usage(allocator: std.mem.Allocator, dir: std.fs.Dir) !Usage {
+    var result = Usage{};
+    try forEachEntry(allocator, dir, &result, struct {
+        fn visit(u: *Usage, _: std.fs.Dir, _: []const u8, stat: std.fs.File.Stat) anyerror!void {
+            u.entries += 1;
+            u.bytes += stat.size;
+            u.oldest_ns = if (u.oldest_ns) |t| @min(t, stat.mtime) else stat.mtime;
+            u.newest_ns = if (u.newest_ns) |t| @max(t, stat.mtime) else stat.mtime;
+        }
+    }.visit);
+    return result;
+}
+
+/// Delete entries (and leftover temporary files) last used before `cutoff_ns`
+pub fn collectGarbage(allocator: std.mem.Allocator, dir: std.fs.Dir, cutoff_ns: i128, dry_run: bool) !GcStats {
+    const Context = struct {
+        stats: GcStats = .{},
+        cutoff_ns: i128,
+        dry_run: bool,
+
+        fn visit(ctx: *@This(), shard: std.fs.Dir, name: []const u8, stat: std.fs.File.Stat) anyerror!void {
+            if (stat.mtime >= ctx.cutoff_ns) {
+                ctx.stats.kept += 1;
+                ctx.stats.kept_bytes += stat.size;
+                return;
+            }
+            if (!ctx.dry_run) try shard.deleteFile(name);
+            ctx.stats.removed += 1;
+            ctx.stats.freed_bytes += stat.size;
+        }
+    };
+    var context = Context{ .cutoff_ns = cutoff_ns, .dry_run = dry_run };
+    try forEachEntry(allocator, dir, &context, Context.visit);
+    return context.stats;
+}
+
+/// Delete the least recently used entries until the rest fit in `max_bytes`.
+/// Entries last used at the same instant as the oldest one kept are kept too.
+pub fn evictToSize(allocator: std.mem.Allocator, dir: std.fs.Dir, max_bytes: u64, dry_run: bool) !GcStats {
+    const Use = struct {
+        mtime: i128,
+        size: u64,
+
+        fn older(_: void, a: @This(), b: @This()) bool {
+            return a.mtime < b.mtime;
+        }
+    };
+    const Context = struct {
+        allocator: std.mem.Allocator,
+        uses: std.ArrayList(Use) = .{},
+        bytes: u64 = 0,
+
+        fn visit(ctx: *@This(), _: std.fs.Dir, _: []const u8, stat: std.fs.File.Stat) anyerror!void {
+            try ctx.uses.append(ctx.allocator, .{ .mtime = stat.mtime, .size = stat.size });
+            ctx.bytes += stat.size;
+        }
+    };
+    var context = Context{ .allocator = allocator };
+    defer context.uses.deinit(allocator);
+    try forEachEntry(allocator, dir, &context, Context.visit);
+    if (context.bytes <= max_bytes) {
+        return .{ .kept = context.uses.items.len, .kept_bytes = context.bytes };
+    }
+
+    // Everything last used before the first entry that leaves the rest in
+    // the limit goes
+    std.mem.sort(Use, context.uses.items, {}, Use.older);
+    var bytes = context.bytes;
+    var cutoff_ns: i128 = std.math.maxInt(i128);
+    for (context.uses.items) |use| {
+        if (bytes <= max_bytes) {
+            cutoff_ns = use.mtime;
+            break;
+        }
+        bytes -= use.size;
+    }
+    return collectGarbage(allocator, dir, cutoff_ns, dry_run);
+}
+
+test "cache round trips entries and counts hits" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const root = try tmp.dir.realpathAlloc(allocator, ".");
+    defer allocator.free(root);
+    const path = try std.fs.path.join(allocator, &.{ root, "cache" });
+    defer allocator.free(path);
+
+    var cache = try Cache.open(allocator, path);
+    defer cache.close();
+
+    const key = computeKey("1.0.0", "0123456789abcdef", "go", "package main\n");
+    try testing.expect(cache.load(&key) == null);
+
+    var set = constraint.ConstraintSet.init(allocator, "code_constraints");
+    defer set.deinit();
+    try set.add(.{ .name = "Error return type", .description = "at line 1", .kind = .type_safety, .severity = .info });
+    try cache.store(&key, set);
+
+    var entry = cache.load(&key).?;
+    defer entry.deinit();
+    try testing.expectEqualStrings("Error return type", entry.constraint_set.constraints.items[0].name);
+    try testing.expect(!std.mem.eql(u8, &key, &computeKey("1.0.1", "0123456789abcdef", "go", "package main\n")));
+    try testing.expect(!std.mem.eql(u8, &key, &computeKey("1.0.0", "fedcba9876543210", "go", "package main\n")));
+
+    // Entries stored by the writer thread are visible once it finishes
+    var writer = Writer.init(allocator, &cache);
+    writer.start();
+    const queued_key = computeKey("1.0.0", "0123456789abcdef", "go", "package other\n");
+    try writer.submit(&queued_key, set);
+    writer.finish();
+    var queued = cache.load(&queued_key).?;
+    defer queued.deinit();
+    try testing.expectEqual(@as(usize, 1), queued.constraint_set.constraints.items.len);
+
+    try cache.recordRun();
+    const totals = try readCounters(allocator, cache.dir);
+    try testing.expectEqual(@as(u64, 2), totals.hits);
+    try testing.expectEqual(@as(u64, 1), totals.misses);
+    const runs = try readRuns(allocator, cache.dir);
+    defer allocator.free(runs);
+    try testing.expectEqual(@as(usize, 1), runs.len);
+    try testing.expectEqual(@as(u64, 2), runs[0].writes);
+    try testing.expect(isCacheDir(cache.dir));
+
+    const before = try usage(allocator, cache.dir);
+    try testing.expectEqual(@as(usize, 2), before.entries);
+    const gc = try collectGarbage(allocator, cache.dir, std.math.maxInt(i128), false);
+    try testing.expectEqual(@as(usize, 2), gc.removed);
+    try testing.expectEqual(@as(usize, 0), (try usage(allocator, cache.dir)).entries);
+}
+
+test "a rule set change misses entries stored under the old one" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const root = try tmp.dir.realpathAlloc(allocator, ".");
+    defer allocator.free(root);
+    const path = try std.fs.path.join(allocator, &.{ root, "cache" });
+    defer allocator.free(path);
+
+    var cache = try Cache.open(allocator, path);
+    defer cache.close();
+
+    const source = "package main\n";
+    var set = constraint.ConstraintSet.init(allocator, "code_constraints");
+    defer set.deinit();
+    try set.add(.{ .name = "Error return type", .description = "at line 1", .kind = .type_safety, .severity = .info });
+    const old_key = computeKey("1.0.0", "0123456789abcdef", "go", source);
+    try cache.store(&old_key, set);
+
+    // Same file and tool version, after a rule edit and with kinds restricted
+    for ([_][]const u8{ "fedcba9876543210", "0123456789abcdef+08" }) |rules| {
+        const key = computeKey("1.0.0", rules, "go", source);
+        try testing.expect(cache.load(&key) == null);
+    }
+    try testing.expectEqual(@as(u64, 2), cache.run.misses);
+    try testing.expectEqual(@as(u64, 0), cache.run.hits);
+
+    // The entry itself is intact for the rule set that wrote it
+    var entry = cache.load(&old_key).?;
+    defer entry.deinit();
+    try testing.expectEqual(@as(u64, 1), cache.run.hits);
+}
+
+test "entries are portable across checkouts" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const source = "package api\n\nfunc Handle() error { return nil }\n";
+    try tmp.dir.makePath("alice/repo/svc/api");
+    try tmp.dir.makePath("ci/build/checkout/moved");
+    try tmp.dir.writeFile(.{ .sub_path = "alice/repo/svc/api/handler.go", .data = source });
+    try tmp.dir.writeFile(.{ .sub_path = "ci/build/checkout/moved/handler.go", .data = source });
+    const first_path = try tmp.dir.realpathAlloc(allocator, "alice/repo/svc/api/handler.go");
+    defer allocator.free(first_path);
+    const second_path = try tmp.dir.realpathAlloc(allocator, "ci/build/checkout/moved/handler.go");
+    defer allocator.free(second_path);
+
+    // The same content under two roots gives the same key
+    const first_source = try std.fs.cwd().readFileAlloc(allocator, first_path, 1024);
+    defer allocator.free(first_source);
+    const second_source = try std.fs.cwd().readFileAlloc(allocator, second_path, 1024);
+    defer allocator.free(second_source);
+    const key = computeKey("1.0.0", "0123456789abcdef", "go", first_source);
+    try testing.expectEqualStrings(&key, &computeKey("1.0.0", "0123456789abcdef", "go", second_source));
+
+    const cache_path = try tmp.dir.realpathAlloc(allocator, ".");
+    defer allocator.free(cache_path);
+    const dir_path = try std.fs.path.join(allocator, &.{ cache_path, "cache" });
+    defer allocator.free(dir_path);
+    var cache = try Cache.open(allocator, dir_path);
+    defer cache.close();
+
+    var set = constraint.ConstraintSet.init(allocator, first_path);
+    defer set.deinit();
+    try set.add(.{ .name = "Error return type", .description = "at line 3", .kind = .type_safety, .severity = .info, .origin_file = first_path, .origin_line = 3 });
+    try cache.store(&key, set);
+
+    // The entry holds neither the absolute path nor the checkout-relative one
+    var buf: [entry_path_len]u8 = undefined;
+    const data = try cache.dir.readFileAlloc(allocator, entryPath(&buf, &key), results.max_result_bytes);
+    defer allocator.free(data);
+    const text = try lz4.decompress(allocator, data[4..], std.mem.readInt(u32, data[0..4], .little));
+    defer allocator.free(text);
+    try testing.expect(std.mem.indexOf(u8, text, "handler.go") == null);
+    try testing.expect(std.mem.indexOf(u8, text, cache_path) == null);
+
+    var entry = cache.load(&key).?;
+    defer entry.deinit();
+    const loaded = entry.constraint_set.constraints.items[0];
+    try testing.expectEqual(@as(?[]const u8, null), loaded.origin_file);
+    try testing.expectEqual(@as(?u32, 3), loaded.origin_line);
+}
+
+test "recent runs keep their own counters, newest last" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const root = try tmp.dir.realpathAlloc(allocator, ".");
+    defer allocator.free(root);
+    const path = try std.fs.path.join(allocator, &.{ root, "cache" });
+    defer allocator.free(path);
+
+    var cache = try Cache.open(allocator, path);
+    defer cache.close();
+
+    const n: u64 = max_recorded_runs + 5;
+    for (0..n) |i| {
+        cache.run = .{ .hits = i, .misses = 1 };
+        try cache.recordRun();
+    }
+
+    // Only the newest runs are kept, each with its own lookups
+    const runs = try readRuns(allocator, cache.dir);
+    defer allocator.free(runs);
+    try testing.expectEqual(@as(usize, max_recorded_runs), runs.len);
+    try testing.expectEqual(@as(u64, 5), runs[0].hits);
+    try testing.expectEqual(n - 1, runs[runs.len - 1].hits);
+    for (runs) |run| {
+        try testing.expectEqual(@as(u64, 1), run.runs);
+        try testing.expectEqual(@as(u64, 1), run.misses);
+    }
+
+    // The totals still cover every run
+    const totals = try readCounters(allocator, cache.dir);
+    try testing.expectEqual(n, totals.runs);
+    try testing.expectEqual(n, totals.misses);
+    try testing.expectEqual(n * (n - 1) / 2, totals.hits);
+}
+
+test "cache evicts least recently used entries beyond max_bytes" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const root = try tmp.dir.realpathAlloc(allocator, ".");
+    defer allocator.free(root);
+    const path = try std.fs.path.join(allocator, &.{ root, "cache" });
+    defer allocator.free(path);
+
+    var cache = try Cache.open(allocator, path);
+    defer cache.close();
+
+    var set = constraint.ConstraintSet.init(allocator, "code_constraints");
+    defer set.deinit();
+    for (0..50) |_| {
+        try set.add(.{ .name = "Error return type", .description = "at line 1", .kind = .type_safety, .severity = .info });
+    }
+
+    // Entries are compressed well below their JSON size
+    const json = try output.formatJson(allocator, set);
+    defer allocator.free(json);
+    var keys: [4]Key = undefined;
+    for (&keys, 0..) |*key, n| {
+        key.* = computeKey("1.0.0", "0123456789abcdef", "go", &[_]u8{@intCast(n)});
+        try cache.store(key, set);
+    }
+    try testing.expect(cache.written_bytes * 4 < json.len * keys.len);
+
+    // Age the entries in store order, then use the oldest again
+    var buf: [entry_path_len]u8 = undefined;
+    for (keys, 0..) |key, n| {
+        const file = try cache.dir.openFile(entryPath(&buf, &key), .{ .mode = .read_write });
+        defer file.close();
+        const mtime = @as(i128, @intCast(n + 1)) * std.time.ns_per_hour;
+        try file.updateTimes(mtime, mtime);
+    }
+    var used = cache.load(&keys[0]).?;
+    used.deinit();
+
+    const entry_bytes = (try usage(allocator, cache.dir)).bytes / keys.len;
+    cache.max_bytes = entry_bytes * 2;
+    try cache.recordRun();
+    try testing.expectEqual(@as(usize, 2), cache.evicted.removed);
+    try testing.expectEqual(@as(u64, 2), (try readCounters(allocator, cache.dir)).evictions);
+    var kept = cache.load(&keys[0]) orelse return error.TestUnexpectedResult;
+    kept.deinit();
+    try testing.expect(cache.load(&keys[1]) == null);
+    try testing.expect(cache.load(&keys[2]) == null);
+    try testing.expectEqual((try usage(allocator, cache.dir)).bytes, (try readCounters(allocator, cache.dir)).bytes.?);
+}
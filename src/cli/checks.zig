@@ -0,0 +1,219 @@
+// GitHub Checks annotations
+// Reports validation results as a GitHub check run (POST /repos/{owner}/{repo}/
+// check-runs), so violations render inline on pull request diffs without a
+// SARIF upload. GitHub accepts at most 50 annotations per request: the run is
+// created with the first 50, and each further batch is sent as an update of
+// the run's output, which GitHub appends to the annotations already there.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const issues = @import("cli_issues");
+const json_api = @import("cli_json_api");
+
+/// Annotations GitHub accepts in one create or update request
+pub const max_annotations_per_request = 50;
+pub const default_name = "ananke";
+
+pub const Level = enum {
+    notice,
+    warning,
+    failure,
+
+    pub fn fromSeverity(severity: constraint.Severity) Level {
+        return switch (severity) {
+            .err => .failure,
+            .warning => .warning,
+            .info, .hint => .notice,
+        };
+    }
+};
+
+pub const Conclusion = enum {
+    success,
+    neutral,
+    failure,
+};
+
+/// A completed check run and the text of its output
+pub const Run = struct {
+    name: []const u8 = default_name,
+    head_sha: []const u8,
+    conclusion: Conclusion,
+    title: []const u8,
+    summary: []const u8,
+};
+
+/// Line of the validated file to annotate: the constraint's origin line when
+/// it was extracted from that file, otherwise the first line
+fn annotationLine(violation: issues.Violation) u32 {
+    const origin = violation.constraint.origin_file orelse return 1;
+    const line = violation.constraint.origin_line orelse return 1;
+    if (!std.mem.eql(u8, trimDot(origin), trimDot(violation.file))) return 1;
+    return @max(line, 1);
+}
+
+fn trimDot(path: []const u8) []const u8 {
+    return if (std.mem.startsWith(u8, path, "./")) path[2..] else path;
+}
+
+fn writeAnnotation(writer: anytype, violation: issues.Violation) !void {
+    const c = violation.constraint;
+    const line = annotationLine(violation);
+    try writer.writeAll("{\"path\": \"");
+    try output.writeJsonEscaped(writer, trimDot(violation.file));
+    try writer.print("\", \"start_line\": {d}, \"end_line\": {d}, \"annotation_level\": \"{s}\", \"title\": \"", .{
+        line,
+        line,
+        @tagName(Level.fromSeverity(c.severity)),
+    });
+    try output.writeJsonEscaped(writer, c.name);
+    try writer.writeAll("\", \"message\": \"");
+    // GitHub rejects an empty message
+    try output.writeJsonEscaped(writer, if (c.description.len > 0) c.description else c.name);
+    try writer.print("\", \"raw_details\": \"Kind: {s}\\nSeverity: {s}\\nConfidence: {d:.2}\\nConstraint ID: {d}\"}}", .{
+        @tagName(c.kind),
+        @tagName(c.severity),
+        c.confidence,
+        violation.id(),
+    });
+}
+
+fn writeOutput(writer: anytype, run: Run, batch: []const issues.Violation) !void {
+    try writer.writeAll("\"output\": {\"title\": \"");
+    try output.writeJsonEscaped(writer, run.title);
+    try writer.writeAll("\", \"summary\": \"");
+    try output.writeJsonEscaped(writer, run.summary);
+    try writer.writeAll("\", \"annotations\": [");
+    for (batch, 0..) |violation, i| {
+        if (i > 0) try writer.writeAll(", ");
+        try writeAnnotation(writer, violation);
+    }
+    try writer.writeAll("]}");
+}
+
+/// Request bodies for a run: the create request with the first batch of
+/// annotations, then one update per further batch. Caller frees each body
+/// and the slice.
+pub fn formatRequests(allocator: std.mem.Allocator, run: Run, violations: []const issues.Violation) ![][]u8 {
+    const batches = @max(1, std.math.divCeil(usize, violations.len, max_annotations_per_request) catch unreachable);
+    const bodies = try allocator.alloc([]u8, batches);
+    var done: usize = 0;
+    errdefer {
+        for (bodies[0..done]) |body| allocator.free(body);
+        allocator.free(bodies);
+    }
+
+    while (done < batches) : (done += 1) {
+        const start = done * max_annotations_per_request;
+        const batch = violations[start..@min(start + max_annotations_per_request, violations.len)];
+        var list = std.ArrayList(u8){};
+        errdefer list.deinit(allocator);
+        const writer = list.writer(allocator);
+        try writer.writeAll("{");
+        if (done == 0) {
+            try writer.writeAll("\"name\": \"");
+            try output.writeJsonEscaped(writer, run.name);
+            try writer.print("\", \"head_sha\": \"{s}\", \"status\": \"completed\", \"conclusion\": \"{s}\", ", .{ run.head_sha, @tagName(run.conclusion) });
+        }
+        try writeOutput(writer, run, batch);
+        try writer.writeAll("}\n");
+        bodies[done] = try list.toOwnedSlice(allocator);
+    }
+    return bodies;
+}
+
+pub const Published = struct {
+    /// Check run ID
+    id: u64,
+    /// Requests sent, one per batch of annotations
+    requests: usize,
+};
+
+pub const PublishError = error{
+    CreateFailed,
+    UpdateFailed,
+    InvalidResponse,
+};
+
+/// Create the check run on `repository` ("owner/name") and append the
+/// remaining annotation batches
+pub fn publish(
+    allocator: std.mem.Allocator,
+    api_url: []const u8,
+    repository: []const u8,
+    token: []const u8,
+    run: Run,
+    violations: []const issues.Violation,
+) !Published {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const bodies = try formatRequests(arena, run, violations);
+    const api = std.mem.trimRight(u8, api_url, "/");
+    const authorization = try std.fmt.allocPrint(arena, "Bearer {s}", .{token});
+    const headers = json_api.githubHeaders(authorization);
+    var client = std.http.Client{ .allocator = allocator };
+    defer client.deinit();
+
+    var created = std.ArrayList(u8){};
+    const create_url = try std.fmt.allocPrint(arena, "{s}/repos/{s}/check-runs", .{ api, repository });
+    const status = try json_api.send(arena, &client, .POST, create_url, &headers, bodies[0], .{ .body = &created });
+    if (status < 200 or status >= 300) return PublishError.CreateFailed;
+    const id = try parseRunId(arena, created.items);
+
+    const update_url = try std.fmt.allocPrint(arena, "{s}/repos/{s}/check-runs/{d}", .{ api, repository, id });
+    for (bodies[1..]) |body| {
+        const updated = try json_api.send(arena, &client, .PATCH, update_url, &headers, body, null);
+        if (updated < 200 or updated >= 300) return PublishError.UpdateFailed;
+    }
+    return .{ .id = id, .requests = bodies.len };
+}
+
+/// The `id` of a created check run
+pub fn parseRunId(arena: std.mem.Allocator, body: []const u8) !u64 {
+    const root = std.json.parseFromSliceLeaky(std.json.Value, arena, body, .{}) catch return PublishError.InvalidResponse;
+    if (root != .object) return PublishError.InvalidResponse;
+    const id = root.object.get("id") orelse return PublishError.InvalidResponse;
+    if (id != .integer) return PublishError.InvalidResponse;
+    return std.math.cast(u64, id.integer) orelse PublishError.InvalidResponse;
+}
+
+test "check run requests batch annotations by fifty" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var violations: [120]issues.Violation = undefined;
+    for (&violations, 0..) |*violation, i| {
+        violation.* = .{
+            .constraint = .{
+                .id = i + 1,
+                .name = "bound_params",
+                .description = if (i == 0) "Queries use \"bound\" parameters" else "",
+                .kind = .security,
+                .severity = if (i == 0) .err else .warning,
+                .origin_file = "./src/db.go",
+                .origin_line = if (i == 0) 12 else null,
+            },
+            .file = "src/db.go",
+        };
+    }
+    const run = Run{ .head_sha = "9f2c1e4b", .conclusion = .failure, .title = "1 violation", .summary = "1 error, 119 warnings" };
+
+    const bodies = try formatRequests(arena, run, &violations);
+    try testing.expectEqual(@as(usize, 3), bodies.len);
+    try testing.expect(std.mem.startsWith(u8, bodies[0], "{\"name\": \"ananke\", \"head_sha\": \"9f2c1e4b\", \"status\": \"completed\", \"conclusion\": \"failure\", "));
+    try testing.expect(std.mem.indexOf(u8, bodies[0], "{\"path\": \"src/db.go\", \"start_line\": 12, \"end_line\": 12, \"annotation_level\": \"failure\", \"title\": \"bound_params\", \"message\": \"Queries use \\\"bound\\\" parameters\"") != null);
+    try testing.expect(std.mem.indexOf(u8, bodies[1], "\"head_sha\"") == null);
+    try testing.expectEqual(@as(usize, 50), std.mem.count(u8, bodies[1], "\"annotation_level\": \"warning\""));
+    try testing.expectEqual(@as(usize, 20), std.mem.count(u8, bodies[2], "\"start_line\": 1,"));
+
+    const empty = try formatRequests(arena, run, &.{});
+    try testing.expectEqual(@as(usize, 1), empty.len);
+    try testing.expect(std.mem.indexOf(u8, empty[0], "\"annotations\": []") != null);
+
+    try testing.expectEqual(@as(u64, 4), try parseRunId(arena, "{\"id\": 4, \"html_url\": \"https://github.com/o/r/runs/4\"}"));
+}
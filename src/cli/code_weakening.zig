@@ -0,0 +1,348 @@
+// Weakening detection on changed code
+// Compares the old and new version of a changed Go file for edits that
+// loosen a check the old code enforced: a validate tag or Validate-method
+// check dropped or its bound relaxed, a field made optional, a Validate
+// method removed, a Validate call dropped from a function (such as the one
+// before `repo.Create`), or a handler that stopped checking the request
+// method. Constraint extraction may see the same code before and after
+// these edits, so they are reported separately, as high-severity findings.
+const std = @import("std");
+const go_rules = @import("cli_go_rules");
+const go_http = @import("cli_go_http");
+const constraint_diff = @import("cli_constraint_diff");
+
+const CodeWeakening = constraint_diff.CodeWeakening;
+
+/// Calls that persist or send data, which a validation should precede
+const sink_methods = [_][]const u8{ "Create", "Save", "Insert", "Update", "Upsert", "Exec", "Store", "Put", "Publish", "Send" };
+
+/// Weakened checks from `before` to `after`, the two versions of the Go file
+/// `file`. Everything lives in `arena` or borrows the inputs.
+pub fn detect(arena: std.mem.Allocator, file: []const u8, before: []const u8, after: []const u8) ![]const CodeWeakening {
+    var found = std.ArrayList(CodeWeakening){};
+
+    const old_rules = try go_rules.parse(arena, before);
+    const new_rules = try go_rules.parse(arena, after);
+    for (old_rules.structs) |old| {
+        const new = findStruct(new_rules.structs, old.name) orelse continue;
+        if (old.has_validate and !new.has_validate) {
+            try found.append(arena, .{ .file = file, .subject = old.name, .message = "Validate method removed", .before_line = old.line, .after_line = new.line });
+        }
+        for (old.fields) |old_field| {
+            if (old_field.checks.len == 0) continue;
+            const new_field = findField(new.fields, old_field.name) orelse continue;
+            const subject = try std.fmt.allocPrint(arena, "{s}.{s}", .{ old.name, old_field.name });
+            if (!old_field.optional and new_field.optional) {
+                try found.append(arena, .{ .file = file, .subject = subject, .message = "made optional (omitempty)", .before_line = old_field.line, .after_line = new_field.line });
+            }
+            try compareChecks(arena, &found, file, subject, old_field.checks, new_field.checks, old_field.line, new_field.line);
+        }
+    }
+    for (old_rules.validators) |old| {
+        const new = for (new_rules.validators) |v| {
+            if (std.mem.eql(u8, v.name, old.name)) break v;
+        } else continue;
+        try compareChecks(arena, &found, file, old.name, old.checks, new.checks, old.line, new.line);
+    }
+
+    try compareValidateCalls(arena, &found, file, before, after);
+
+    const old_http = try go_http.parse(arena, before);
+    const new_http = try go_http.parse(arena, after);
+    for (old_http.handlers) |old| {
+        const old_method = old.method orelse continue;
+        const new = for (new_http.handlers) |h| {
+            if (std.mem.eql(u8, h.name, old.name) and std.mem.eql(u8, h.receiver_type orelse "", old.receiver_type orelse "")) break h;
+        } else continue;
+        if (new.method != null) continue;
+        // Moving the restriction to the route is not a weakening
+        if (go_http.routeOf(new_http.routes, new)) |route| {
+            if (route.method != null) continue;
+        }
+        try found.append(arena, .{
+            .file = file,
+            .subject = try qualifiedName(arena, new.receiver_type, new.name),
+            .message = try std.fmt.allocPrint(arena, "no longer rejects methods other than {s}", .{old_method}),
+            .before_line = old.line,
+            .after_line = new.line,
+        });
+    }
+
+    return found.items;
+}
+
+fn findStruct(structs: []const go_rules.Struct, name: []const u8) ?go_rules.Struct {
+    for (structs) |s| {
+        if (std.mem.eql(u8, s.name, name)) return s;
+    }
+    return null;
+}
+
+fn findField(fields: []const go_rules.Field, name: []const u8) ?go_rules.Field {
+    for (fields) |f| {
+        if (std.mem.eql(u8, f.name, name)) return f;
+    }
+    return null;
+}
+
+fn findCheck(checks: []const go_rules.Check, kind: go_rules.CheckKind) ?go_rules.Check {
+    for (checks) |c| {
+        if (c.kind == kind) return c;
+    }
+    return null;
+}
+
+/// Whether every space-separated word of `new` is one of `old`'s
+fn wordsWithin(new: []const u8, old: []const u8) bool {
+    var words = std.mem.tokenizeScalar(u8, new, ' ');
+    outer: while (words.next()) |word| {
+        var known = std.mem.tokenizeScalar(u8, old, ' ');
+        while (known.next()) |k| {
+            if (std.mem.eql(u8, k, word)) continue :outer;
+        }
+        return false;
+    }
+    return true;
+}
+
+/// Checks of `old` that `new` dropped or relaxed
+fn compareChecks(
+    arena: std.mem.Allocator,
+    found: *std.ArrayList(CodeWeakening),
+    file: []const u8,
+    subject: []const u8,
+    old: []const go_rules.Check,
+    new: []const go_rules.Check,
+    before_line: u32,
+    after_line: u32,
+) !void {
+    for (old) |check| {
+        const label = check.kind.label();
+        const message: ?[]const u8 = if (findCheck(new, check.kind)) |now| switch (check.kind) {
+            .min_len, .min => if (now.bound < check.bound)
+                try std.fmt.allocPrint(arena, "{s} lowered from {d} to {d}", .{ label, check.bound, now.bound })
+            else
+                null,
+            .max_len, .max => if (now.bound > check.bound)
+                try std.fmt.allocPrint(arena, "{s} raised from {d} to {d}", .{ label, check.bound, now.bound })
+            else
+                null,
+            .one_of => if (!wordsWithin(now.text, check.text))
+                try std.fmt.allocPrint(arena, "{s} widened from \"{s}\" to \"{s}\"", .{ label, check.text, now.text })
+            else
+                null,
+            .contains_any => if (!std.mem.eql(u8, now.text, check.text) and std.mem.indexOfNone(u8, now.text, check.text) != null)
+                try std.fmt.allocPrint(arena, "{s} widened from \"{s}\" to \"{s}\"", .{ label, check.text, now.text })
+            else
+                null,
+            // A changed pattern may be stricter or looser; it is not judged
+            .required, .email, .pattern => null,
+        } else switch (check.kind) {
+            .min_len, .max_len, .min, .max => try std.fmt.allocPrint(arena, "{s} {d} check dropped", .{ label, check.bound }),
+            .pattern, .one_of, .contains_any => try std.fmt.allocPrint(arena, "{s} \"{s}\" check dropped", .{ label, check.text }),
+            .required, .email => try std.fmt.allocPrint(arena, "{s} check dropped", .{label}),
+        };
+        if (message) |text| {
+            try found.append(arena, .{ .file = file, .subject = subject, .message = text, .before_line = before_line, .after_line = after_line });
+        }
+    }
+}
+
+fn qualifiedName(arena: std.mem.Allocator, receiver_type: ?[]const u8, name: []const u8) ![]const u8 {
+    const receiver = receiver_type orelse return name;
+    return std.fmt.allocPrint(arena, "{s}.{s}", .{ receiver, name });
+}
+
+/// A call on one line of a function body
+const Call = struct {
+    callee: []const u8,
+    line: u32,
+};
+
+const Func = struct {
+    name: []const u8,
+    line: u32,
+    validations: []const Call,
+    sinks: []const Call,
+};
+
+/// The callee of the call whose name ends at `line[end]`, e.g.
+/// `h.repo.Create` for `err := h.repo.Create(ctx, user)`
+fn calleeEndingAt(line: []const u8, end: usize) []const u8 {
+    var start = end;
+    while (start > 0 and (std.ascii.isAlphanumeric(line[start - 1]) or line[start - 1] == '_' or line[start - 1] == '.')) start -= 1;
+    return line[start..end];
+}
+
+/// Validation calls on `line`: `req.Validate()`, `ValidateUsername(name)`,
+/// and go-playground's `validate.Struct(req)`
+fn validationCall(line: []const u8) ?[]const u8 {
+    var start: usize = 0;
+    while (std.mem.indexOfPos(u8, line, start, "(")) |open| : (start = open + 1) {
+        const callee = calleeEndingAt(line, open);
+        const name = callee[if (std.mem.lastIndexOfScalar(u8, callee, '.')) |dot| dot + 1 else 0..];
+        if (std.mem.startsWith(u8, name, "Validate")) return callee;
+        if (std.mem.endsWith(u8, callee, "validate.Struct") or std.mem.endsWith(u8, callee, "validate.Var")) return callee;
+    }
+    return null;
+}
+
+/// A persisting call on `line`: a method named like `Create` or `Save`
+fn sinkCall(line: []const u8) ?[]const u8 {
+    var start: usize = 0;
+    while (std.mem.indexOfPos(u8, line, start, "(")) |open| : (start = open + 1) {
+        const callee = calleeEndingAt(line, open);
+        const dot = std.mem.lastIndexOfScalar(u8, callee, '.') orelse continue;
+        for (sink_methods) |method| {
+            if (std.mem.startsWith(u8, callee[dot + 1 ..], method)) return callee;
+        }
+    }
+    return null;
+}
+
+/// Functions of a gofmt-formatted `source` with their validation and
+/// persisting calls
+fn parseFuncs(arena: std.mem.Allocator, source: []const u8) ![]const Func {
+    var funcs = std.ArrayList(Func){};
+    var current: ?Func = null;
+    var validations = std.ArrayList(Call){};
+    var sinks = std.ArrayList(Call){};
+    var lines = std.mem.splitScalar(u8, source, '\n');
+    var number: u32 = 0;
+    while (lines.next()) |raw| {
+        number += 1;
+        const line = std.mem.trimRight(u8, raw, " \t\r");
+        if (go_rules.parseFuncHeader(line)) |header| {
+            current = .{
+                .name = try qualifiedName(arena, header.receiver_type, header.name),
+                .line = number,
+                .validations = &.{},
+                .sinks = &.{},
+            };
+            validations = .{};
+            sinks = .{};
+            continue;
+        }
+        var func = current orelse continue;
+        if (std.mem.eql(u8, line, "}")) {
+            func.validations = validations.items;
+            func.sinks = sinks.items;
+            try funcs.append(arena, func);
+            current = null;
+            continue;
+        }
+        const code = std.mem.trimLeft(u8, line, " \t");
+        if (std.mem.startsWith(u8, code, "//")) continue;
+        if (validationCall(code)) |callee| try validations.append(arena, .{ .callee = callee, .line = number });
+        if (sinkCall(code)) |callee| try sinks.append(arena, .{ .callee = callee, .line = number });
+    }
+    return funcs.items;
+}
+
+fn countCallee(calls: []const Call, callee: []const u8) usize {
+    var n: usize = 0;
+    for (calls) |call| {
+        if (std.mem.eql(u8, call.callee, callee)) n += 1;
+    }
+    return n;
+}
+
+/// Validation calls a function made before and no longer makes, named with
+/// the persisting call they guarded
+fn compareValidateCalls(
+    arena: std.mem.Allocator,
+    found: *std.ArrayList(CodeWeakening),
+    file: []const u8,
+    before: []const u8,
+    after: []const u8,
+) !void {
+    const old_funcs = try parseFuncs(arena, before);
+    const new_funcs = try parseFuncs(arena, after);
+    for (old_funcs) |old| {
+        const new = for (new_funcs) |f| {
+            if (std.mem.eql(u8, f.name, old.name)) break f;
+        } else continue;
+        for (old.validations, 0..) |call, i| {
+            // Report each callee once, for the calls beyond those kept
+            if (countCallee(old.validations[0..i], call.callee) > 0) continue;
+            if (countCallee(old.validations, call.callee) <= countCallee(new.validations, call.callee)) continue;
+            var guarded: ?[]const u8 = null;
+            for (old.sinks) |sink| {
+                if (sink.line > call.line and countCallee(new.sinks, sink.callee) > 0) {
+                    guarded = sink.callee;
+                    break;
+                }
+            }
+            const message = if (guarded) |sink|
+                try std.fmt.allocPrint(arena, "no longer calls {s}() before {s}()", .{ call.callee, sink })
+            else
+                try std.fmt.allocPrint(arena, "no longer calls {s}()", .{call.callee});
+            try found.append(arena, .{ .file = file, .subject = old.name, .message = message, .before_line = call.line, .after_line = new.line });
+        }
+    }
+}
+
+test "detect weakened checks between two versions of a file" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const before =
+        \\package api
+        \\
+        \\type CreateUserRequest struct {
+        \\	Username string `json:"username" validate:"required,min=3,max=50"`
+        \\	Role     string `json:"role" validate:"required,oneof=admin member"`
+        \\	Age      int    `json:"age" validate:"min=18"`
+        \\}
+        \\
+        \\func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+        \\	if r.Method != http.MethodPost {
+        \\		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        \\		return
+        \\	}
+        \\	var req CreateUserRequest
+        \\	if err := req.Validate(); err != nil {
+        \\		http.Error(w, err.Error(), http.StatusBadRequest)
+        \\		return
+        \\	}
+        \\	if err := h.repo.Create(r.Context(), req); err != nil {
+        \\		http.Error(w, err.Error(), http.StatusInternalServerError)
+        \\	}
+        \\}
+    ;
+    const after =
+        \\package api
+        \\
+        \\type CreateUserRequest struct {
+        \\	Username string `json:"username" validate:"required,min=3,max=500"`
+        \\	Role     string `json:"role" validate:"required,oneof=admin member guest"`
+        \\	Age      int    `json:"age" validate:"min=21"`
+        \\}
+        \\
+        \\func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+        \\	var req CreateUserRequest
+        \\	if err := h.repo.Create(r.Context(), req); err != nil {
+        \\		http.Error(w, err.Error(), http.StatusInternalServerError)
+        \\	}
+        \\}
+    ;
+    const found = try detect(arena, "api/users.go", before, after);
+    try testing.expectEqual(@as(usize, 4), found.len);
+
+    try testing.expectEqualStrings("CreateUserRequest.Username", found[0].subject);
+    try testing.expectEqualStrings("max length raised from 50 to 500", found[0].message);
+    try testing.expectEqual(@as(?u32, 4), found[0].after_line);
+    try testing.expectEqualStrings("CreateUserRequest.Role", found[1].subject);
+    try testing.expectEqualStrings("one of widened from \"admin member\" to \"admin member guest\"", found[1].message);
+
+    try testing.expectEqualStrings("Handler.CreateUser", found[2].subject);
+    try testing.expectEqualStrings("no longer calls req.Validate() before h.repo.Create()", found[2].message);
+    try testing.expectEqual(@as(?u32, 15), found[2].before_line);
+    try testing.expectEqualStrings("Handler.CreateUser", found[3].subject);
+    try testing.expectEqualStrings("no longer rejects methods other than POST", found[3].message);
+
+    // Tightening is not reported
+    try testing.expectEqual(@as(usize, 0), (try detect(arena, "api/users.go", after, after)).len);
+}
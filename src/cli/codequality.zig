@@ -0,0 +1,114 @@
+// GitLab Code Quality output format
+// Emits constraints as a GitLab Code Quality report (a subset of the Code
+// Climate issue format), so a CI job publishing it as `artifacts:reports:
+// codequality` shows constraints in the merge request widget and diff.
+// GitLab tells new findings from resolved ones by comparing fingerprints
+// between the source and target branch reports, so fingerprints use the
+// line-independent identity of a constraint (the one baselines use):
+// unrelated edits that shift code do not report a finding as new.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const constraint_diff = @import("cli_constraint_diff");
+
+/// GitLab's severity for a constraint
+pub fn severityName(severity: constraint.Severity) []const u8 {
+    return switch (severity) {
+        .err => "critical",
+        .warning => "major",
+        .info => "minor",
+        .hint => "info",
+    };
+}
+
+/// Code Climate category for a constraint kind
+fn category(kind: constraint.ConstraintKind) []const u8 {
+    return switch (kind) {
+        .syntactic => "Style",
+        .type_safety, .semantic, .operational => "Bug Risk",
+        .architectural => "Complexity",
+        .security => "Security",
+    };
+}
+
+/// Format constraints as a Code Quality JSON array. Constraints without a
+/// source file have no location GitLab can show and are left out.
+pub fn formatCodeQuality(allocator: std.mem.Allocator, constraint_set: constraint.ConstraintSet) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    // Fingerprints must be unique within a report; repeats of one identity
+    // are told apart by their order
+    var occurrences = std.AutoHashMap(u64, u32).init(allocator);
+    defer occurrences.deinit();
+
+    var written: usize = 0;
+    try writer.writeAll("[");
+    for (constraint_set.constraints.items) |c| {
+        const file = c.origin_file orelse continue;
+        const identity = constraint_diff.identityHash(c);
+        const gop = try occurrences.getOrPut(identity);
+        const occurrence: u32 = if (gop.found_existing) gop.value_ptr.* else 0;
+        gop.value_ptr.* = occurrence + 1;
+
+        try writer.writeAll(if (written == 0) "\n  {\"type\": \"issue\", \"check_name\": \"" else ",\n  {\"type\": \"issue\", \"check_name\": \"");
+        try output.writeJsonEscaped(writer, c.name);
+        try writer.writeAll("\", \"description\": \"");
+        try output.writeJsonEscaped(writer, if (c.description.len > 0) c.description else c.name);
+        try writer.print("\", \"categories\": [\"{s}\"], \"severity\": \"{s}\", \"fingerprint\": \"{x:0>16}", .{
+            category(c.kind),
+            severityName(c.severity),
+            identity,
+        });
+        if (occurrence > 0) try writer.print("-{d}", .{occurrence});
+        try writer.writeAll("\", \"location\": {\"path\": \"");
+        try output.writeJsonEscaped(writer, normalize(file));
+        try writer.print("\", \"lines\": {{\"begin\": {d}}}}}}}", .{c.origin_line orelse 1});
+        written += 1;
+    }
+    try writer.writeAll(if (written > 0) "\n]\n" else "]\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// GitLab resolves paths from the repository root, without a leading "./"
+fn normalize(path: []const u8) []const u8 {
+    var rest = path;
+    while (std.mem.startsWith(u8, rest, "./")) rest = rest[2..];
+    return rest;
+}
+
+test "code quality report keeps fingerprints stable and unique" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var set = constraint.ConstraintSet.init(allocator, "test");
+    defer set.deinit();
+    try set.add(.{ .name = "sql_params", .description = "Use bound parameters", .kind = .security, .severity = .err, .origin_file = "./db/query.go", .origin_line = 12 });
+    try set.add(.{ .name = "sql_params", .description = "Use bound parameters", .kind = .security, .severity = .err, .origin_file = "./db/query.go", .origin_line = 40 });
+    try set.add(.{ .name = "naming", .description = "", .kind = .syntactic, .severity = .hint });
+
+    const text = try formatCodeQuality(allocator, set);
+    defer allocator.free(text);
+    const parsed = try std.json.parseFromSlice(std.json.Value, allocator, text, .{});
+    defer parsed.deinit();
+
+    // The constraint without a file has no location and is left out
+    const issues = parsed.value.array.items;
+    try testing.expectEqual(@as(usize, 2), issues.len);
+    const first = issues[0].object;
+    try testing.expectEqualStrings("critical", first.get("severity").?.string);
+    try testing.expectEqualStrings("db/query.go", first.get("location").?.object.get("path").?.string);
+    try testing.expectEqual(@as(i64, 12), first.get("location").?.object.get("lines").?.object.get("begin").?.integer);
+
+    const fingerprint = first.get("fingerprint").?.string;
+    try testing.expectEqual(@as(usize, 16), fingerprint.len);
+    try testing.expect(!std.mem.eql(u8, fingerprint, issues[1].object.get("fingerprint").?.string));
+
+    // Moving the code does not change the fingerprint
+    set.constraints.items[0].origin_line = 99;
+    const moved = try formatCodeQuality(allocator, set);
+    defer allocator.free(moved);
+    try testing.expect(std.mem.indexOf(u8, moved, fingerprint) != null);
+}
@@ -0,0 +1,159 @@
+// Api-diff command - Report breaking changes to the exported Go API between two git refs
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const git = @import("cli_git");
+const discovery = @import("cli_discovery");
+const go_api = @import("cli_go_api");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke api-diff <refA> <refB> [path] [options]
+    \\
+    \\Compare the exported API of the Go packages at two git refs: functions,
+    \\methods, types, struct fields, interface methods, constants, and
+    \\variables. Removals, changed signatures, and methods added to interfaces
+    \\(their implementations no longer satisfy them) are breaking; other
+    \\additions are compatible. Parameter names, struct tags, and formatting
+    \\are not part of the API. Packages under internal/, main packages, and
+    \\tests are left out, since other modules cannot import them.
+    \\
+    \\The command fails when the changes need a larger release than --bump:
+    \\breaking changes need a major one, additions a minor one. Run it against
+    \\the last release tag to gate a library's releases on semantic versioning.
+    \\
+    \\Arguments:
+    \\  <refA>                  Base commit-ish, usually the last release tag
+    \\  <refB>                  Commit-ish to compare against the base (e.g. HEAD)
+    \\  [path]                  Limit the comparison to a file or directory
+    \\
+    \\Options:
+    \\  --bump <level>          Release being prepared: patch, minor, major (default: minor)
+    \\  --format <fmt>          Output format: text, json, markdown (default: text)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --exclude <globs>       Comma-separated .gitignore-style patterns to skip
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\Exit codes:
+    \\  0   the changes fit the release
+    \\  5   the changes need a larger release than --bump
+    \\  1   invalid arguments
+    \\
+    \\Examples:
+    \\  ananke api-diff v1.4.0 HEAD
+    \\  ananke api-diff $(git describe --tags --abbrev=0) HEAD --bump patch
+    \\  ananke api-diff origin/main HEAD ./client --format markdown -o api-changes.md
+;
+
+const ApiDiffFormat = enum {
+    text,
+    json,
+    markdown,
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const before_rev = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <refA>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const after_rev = parsed_args.getPositional(1) catch {
+        cli_error.printError("Missing required argument: <refB>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const pathspec: ?[]const u8 = parsed_args.getPositional(2) catch null;
+
+    const format_str = parsed_args.getFlagOr("format", "text");
+    const format = std.meta.stringToEnum(ApiDiffFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text, json, or markdown)", .{format_str});
+        return error.InvalidArgument;
+    };
+    const bump_str = parsed_args.getFlagOr("bump", "minor");
+    const allowed = std.meta.stringToEnum(go_api.Bump, bump_str) orelse {
+        cli_error.printError("Invalid bump: {s} (expected patch, minor, or major)", .{bump_str});
+        return error.InvalidArgument;
+    };
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+
+    var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+    const discovery_options = discovery.Options{
+        .excludes = excludes.items,
+        .language = "go",
+    };
+
+    var before_snapshot = try loadSnapshot(allocator, before_rev, pathspec, discovery_options);
+    defer before_snapshot.deinit();
+    var after_snapshot = try loadSnapshot(allocator, after_rev, pathspec, discovery_options);
+    defer after_snapshot.deinit();
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const before = try surface(arena, before_snapshot.files.items);
+    const after = try surface(arena, after_snapshot.files.items);
+    if (verbose) {
+        cli_error.printInfo("{s} ({s}) exports {d} symbols, {s} ({s}) {d}", .{
+            before_rev,
+            before_snapshot.commit,
+            before.len,
+            after_rev,
+            after_snapshot.commit,
+            after.len,
+        });
+    }
+
+    const report = try go_api.compare(arena, before, after);
+    const output_text = switch (format) {
+        .text => try go_api.formatText(allocator, report, before_rev, after_rev),
+        .json => try go_api.formatJson(allocator, report, before_rev, after_rev),
+        .markdown => try go_api.formatMarkdown(allocator, report, before_rev, after_rev),
+    };
+    defer allocator.free(output_text);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+
+    const needed = report.bump();
+    if (@intFromEnum(needed) > @intFromEnum(allowed)) {
+        cli_error.printError("The API changes need a {s} release, but --bump is {s}", .{ @tagName(needed), @tagName(allowed) });
+        return error.ValidationFailed;
+    }
+}
+
+/// Exported declarations of the importable Go files of a snapshot
+fn surface(arena: std.mem.Allocator, files: []const discovery.SourceFile) ![]const go_api.Symbol {
+    var symbols = std.ArrayList(go_api.Symbol){};
+    for (files) |file| {
+        if (!go_api.isPublicFile(file.path) or discovery.isGenerated(file.path, file.source)) continue;
+        try symbols.appendSlice(arena, try go_api.parse(arena, file.path, file.source));
+    }
+    return symbols.items;
+}
+
+fn loadSnapshot(
+    allocator: std.mem.Allocator,
+    rev: []const u8,
+    pathspec: ?[]const u8,
+    options: discovery.Options,
+) !git.Snapshot {
+    return git.loadSnapshot(allocator, rev, pathspec, options, extract.max_source_bytes) catch |err| {
+        switch (err) {
+            git.GitError.GitNotFound => cli_error.printError("git executable not found in PATH", .{}),
+            git.GitError.InvalidRevision => cli_error.printError("Invalid revision: {s}", .{rev}),
+            git.GitError.UnknownRevision => {
+                cli_error.printError("Unknown revision: {s}", .{rev});
+                cli_error.printInfo("Run this command inside a git repository with the ref fetched", .{});
+            },
+            else => cli_error.printError("Failed to read {s} from git: {s}", .{ rev, @errorName(err) }),
+        }
+        return err;
+    };
+}
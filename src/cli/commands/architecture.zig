@@ -0,0 +1,280 @@
+// Architecture command - Extract the layering of Go packages and promote it into enforced architecture rules
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const layering = @import("cli_layering");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke architecture [show|promote|check] [path] [options]
+    \\
+    \\Recover how the packages of a Go module depend on each other by layer and
+    \\turn what holds today into architecture rules that `ananke verify`
+    \\enforces. Packages belong to the layers under `layers` in the
+    \\[architecture] config section, written "handler=internal/api,internal/http",
+    \\or else to the layer their directory is conventionally named for:
+    \\
+    \\  handler     handler(s), api, http, transport, controller(s), rest
+    \\  service     service(s), usecase(s)
+    \\  repository  repository, repositories, repo, store, storage, persistence, dao
+    \\
+    \\Rules, under `rules` in [architecture], take two forms:
+    \\
+    \\  service !-> handler                  no service package depends on a
+    \\                                       handler package, even through others
+    \\  handler -> repository via interface  handler packages use only interface
+    \\                                       types of repository packages
+    \\
+    \\Subcommands:
+    \\  show        The layers, the dependencies between them, and the rules the
+    \\              code satisfies (default)
+    \\  promote     Add the rules the code satisfies to [architecture] in the
+    \\              config file, which must not have that section yet
+    \\  check       Check the configured rules, tracing each violation to the
+    \\              imports or type uses causing it
+    \\
+    \\Arguments:
+    \\  [path]                  Go module directory (default: .)
+    \\
+    \\Options:
+    \\  --rule <rules>          check: comma-separated rules, replacing the configured ones
+    \\  --config <file>         promote: config file to add the rules to (default: .ananke.toml)
+    \\  --dry-run               promote: print the section instead of writing it
+    \\  --format <fmt>          show, check: text, json (default: text)
+    \\  --output, -o <file>     show, check: write output to file instead of stdout
+    \\  --exclude <globs>       Comma-separated .gitignore-style patterns to skip
+    \\  --help, -h              Show this help message
+    \\
+    \\Exit codes:
+    \\  0   success, or no rule is violated
+    \\  5   check: architecture rules are violated
+    \\  1   invalid arguments, rules, or layers
+    \\
+    \\Examples:
+    \\  ananke architecture
+    \\  ananke architecture promote --dry-run
+    \\  ananke architecture check --rule "service !-> handler"
+;
+
+const Subcommand = enum {
+    show,
+    promote,
+    check,
+};
+
+const ArchitectureFormat = enum {
+    text,
+    json,
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    // The subcommand is optional; without one, the first argument is the path
+    const first: ?[]const u8 = parsed_args.getPositional(0) catch null;
+    const named = if (first) |arg| std.meta.stringToEnum(Subcommand, arg) else null;
+    const subcommand = named orelse .show;
+    const path: []const u8 = (if (named != null) parsed_args.getPositional(1) catch null else first) orelse ".";
+
+    const format_str = parsed_args.getFlagOr("format", "text");
+    const format = std.meta.stringToEnum(ArchitectureFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text or json)", .{format_str});
+        return error.InvalidArgument;
+    };
+
+    var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const layers = try configuredLayers(arena, config);
+    const graph = try loadGraph(arena, path, excludes.items) orelse {
+        cli_error.printError("No go.mod with a module path in {s}", .{path});
+        return error.InvalidArgument;
+    };
+
+    switch (subcommand) {
+        .show => {
+            const extracted = try layering.extract(arena, &graph, layers);
+            const output_text = switch (format) {
+                .text => try layering.formatLayering(allocator, extracted),
+                .json => try layering.formatLayeringJson(allocator, extracted),
+            };
+            defer allocator.free(output_text);
+            try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+            if (extracted.layers.len == 0) {
+                cli_error.printWarning("No package is in a layer; configure layers under [architecture]", .{});
+            }
+        },
+        .promote => try promote(arena, parsed_args, config, try layering.extract(arena, &graph, layers)),
+        .check => {
+            const texts = if (parsed_args.getFlag("rule")) |list| try splitList(arena, list) else config.architecture_rules;
+            if (texts.len == 0) {
+                cli_error.printError("No architecture rules: set rules under [architecture], run `ananke architecture promote`, or pass --rule", .{});
+                return error.InvalidArgument;
+            }
+            const rules = try parseRules(arena, texts);
+            const violations = try layering.check(arena, &graph, layers, rules);
+
+            var list = std.ArrayList(u8){};
+            defer list.deinit(allocator);
+            const writer = list.writer(allocator);
+            switch (format) {
+                .text => {
+                    try writer.print("Checked {d} architecture rules in {d} packages: {d} violations\n", .{ rules.len, graph.packages.items.len, violations.len });
+                    if (violations.len > 0) try writer.writeAll("\n");
+                    try layering.writeViolations(writer, violations, "");
+                },
+                .json => {
+                    try writer.print("{{\n  \"rules\": {d},\n  \"packages\": {d},\n  \"violations\": ", .{ rules.len, graph.packages.items.len });
+                    try layering.writeViolationsJson(writer, violations, "  ");
+                    try writer.writeAll("\n}\n");
+                },
+            }
+            try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), list.items);
+
+            if (violations.len > 0) {
+                cli_error.printError("{d} architecture rule violations", .{violations.len});
+                return error.ValidationFailed;
+            }
+            cli_error.printSuccess("Every architecture rule holds", .{});
+        },
+    }
+}
+
+/// Add the rules the code satisfies to the config file as a new
+/// [architecture] section, leaving the rest of the file as it is
+fn promote(arena: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config, extracted: layering.Layering) !void {
+    if (extracted.proposed.len == 0) {
+        cli_error.printWarning("The code satisfies no architecture rule to promote", .{});
+        return;
+    }
+    var section = std.ArrayList(u8){};
+    const writer = section.writer(arena);
+    try writer.writeAll("[architecture]\n");
+    if (config.architecture_layers.len > 0) try writeArray(writer, "layers", config.architecture_layers);
+    try writeArray(writer, "rules", extracted.proposed);
+
+    if (parsed_args.hasFlag("dry-run")) {
+        try extract.writeOutput(null, section.items);
+        return;
+    }
+
+    const config_path = parsed_args.getFlagOr("config", ".ananke.toml");
+    const existing = std.fs.cwd().readFileAlloc(arena, config_path, 1024 * 1024) catch |err| switch (err) {
+        error.FileNotFound => "",
+        else => {
+            cli_error.printFileError(err, config_path);
+            return err;
+        },
+    };
+    var lines = std.mem.splitScalar(u8, existing, '\n');
+    while (lines.next()) |line| {
+        if (!std.mem.eql(u8, std.mem.trim(u8, line, " \t\r"), "[architecture]")) continue;
+        cli_error.printError("{s} already has an [architecture] section; add the rules to it by hand:", .{config_path});
+        std.debug.print("\n{s}", .{section.items});
+        return error.InvalidArgument;
+    }
+
+    const separator: []const u8 = if (existing.len == 0) "" else if (std.mem.endsWith(u8, existing, "\n\n")) "" else if (std.mem.endsWith(u8, existing, "\n")) "\n" else "\n\n";
+    const text = try std.mem.concat(arena, u8, &.{ existing, separator, section.items });
+    std.fs.cwd().writeFile(.{ .sub_path = config_path, .data = text }) catch |err| {
+        cli_error.printFileError(err, config_path);
+        return err;
+    };
+    cli_error.printSuccess("Promoted {d} architecture rules to {s}; `ananke verify` now enforces them", .{ extracted.proposed.len, config_path });
+}
+
+fn writeArray(writer: anytype, key: []const u8, values: []const []const u8) !void {
+    try writer.print("{s} = [", .{key});
+    for (values, 0..) |value, i| {
+        if (i > 0) try writer.writeAll(", ");
+        try writer.print("\"{s}\"", .{value});
+    }
+    try writer.writeAll("]\n");
+}
+
+fn splitList(arena: std.mem.Allocator, list: []const u8) ![]const []const u8 {
+    var items = std.ArrayList([]const u8){};
+    var parts = std.mem.splitScalar(u8, list, ',');
+    while (parts.next()) |part| {
+        const item = std.mem.trim(u8, part, " ");
+        if (item.len > 0) try items.append(arena, item);
+    }
+    return items.items;
+}
+
+/// The layers of [architecture], or none to place packages by directory name
+pub fn configuredLayers(arena: std.mem.Allocator, config: config_mod.Config) ![]const layering.Layer {
+    var layers = std.ArrayList(layering.Layer){};
+    for (config.architecture_layers) |text| {
+        const layer = layering.parseLayer(arena, text) catch |err| {
+            if (err == layering.LayeringError.InvalidLayer) {
+                cli_error.printError("Invalid architecture layer: {s} (expected name=path,path)", .{text});
+                return error.InvalidArgument;
+            }
+            return err;
+        };
+        try layers.append(arena, layer);
+    }
+    return layers.items;
+}
+
+pub fn parseRules(arena: std.mem.Allocator, texts: []const []const u8) ![]const layering.Rule {
+    var rules = std.ArrayList(layering.Rule){};
+    for (texts) |text| {
+        const rule = layering.parseRule(text) catch {
+            cli_error.printError("Invalid architecture rule: {s} (expected \"a !-> b\" or \"a -> b via interface\")", .{text});
+            return error.InvalidArgument;
+        };
+        try rules.append(arena, rule);
+    }
+    return rules.items;
+}
+
+/// The package graph of the Go module at `path`, or null without a go.mod
+/// naming the module there or in the working directory
+pub fn loadGraph(arena: std.mem.Allocator, path: []const u8, excludes: []const []const u8) !?layering.Graph {
+    const trimmed = trimDot(std.mem.trimRight(u8, path, "/"));
+    const dir = if (trimmed.len == 0) "." else trimmed;
+    var root: ?[]const u8 = null;
+    var module: []const u8 = "";
+    for ([_][]const u8{ dir, "." }) |candidate| {
+        const go_mod_path = try std.fs.path.join(arena, &.{ candidate, "go.mod" });
+        const go_mod = std.fs.cwd().readFileAlloc(arena, go_mod_path, 1024 * 1024) catch continue;
+        module = layering.modulePath(go_mod) orelse continue;
+        root = candidate;
+        break;
+    }
+
+    var graph = layering.Graph.init(arena, root orelse return null, module);
+    var set = discovery.discover(arena, dir, .{ .language = "go", .excludes = excludes }) catch |err| {
+        cli_error.printFileError(err, path);
+        return err;
+    };
+    defer set.deinit();
+    for (set.files.items) |file| {
+        if (std.mem.endsWith(u8, file.path, "_test.go")) continue;
+        const file_path = try arena.dupe(u8, trimDot(file.path));
+        const source = std.fs.cwd().readFileAlloc(arena, file_path, extract.max_source_bytes) catch |err| {
+            cli_error.printFileError(err, file_path);
+            return err;
+        };
+        if (discovery.isGenerated(file_path, source)) continue;
+        try graph.addFile(file_path, source);
+    }
+    return graph;
+}
+
+fn trimDot(path: []const u8) []const u8 {
+    var rel = path;
+    while (std.mem.startsWith(u8, rel, "./")) rel = rel[2..];
+    return rel;
+}
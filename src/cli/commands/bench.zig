@@ -0,0 +1,1023 @@
+// Bench command - Measure extraction throughput on the size-tiered fixtures
+const std = @import("std");
+const builtin = @import("builtin");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const output = @import("cli_output");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const profiling = @import("cli_profiling");
+const discovery = @import("cli_discovery");
+const cyclonedx = @import("cli_cyclonedx");
+const version = @import("cli_version");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke bench [fixtures-dir] [options]
+    \\       ananke bench compare <baseline> <current> [options]
+    \\
+    \\Time extraction of every fixture under <fixtures-dir>/<language>/<size>/,
+    \\where size is small, medium, large, or xlarge, and report the mean, 95th
+    \\percentile, and median time and the throughput in lines per second. Each
+    \\fixture is extracted --warmup untimed times, then --iterations timed
+    \\times, each with a fresh engine. Throughput is computed from the median.
+    \\Each run is also timed by stage: init (engine setup), extract, and render
+    \\(JSON output). --format benchstat prints one Go benchmark line per timed
+    \\run, for comparing runs with benchstat.
+    \\
+    \\Memory is measured on one more, untimed run through a counting allocator:
+    \\allocations, bytes allocated, peak heap, and peak RSS (reset per fixture on
+    \\Linux; the process high-water mark so far elsewhere), reported per fixture
+    \\and per size tier.
+    \\
+    \\Baselines are versioned JSON files of the results. The default one is the
+    \\`fixture_baseline` section of the harness's benchmarks/baselines.json,
+    \\next to the targets of the benches/ suite. Record it with --update, then
+    \\use --check in CI, or compare two saved files with
+    \\`bench compare`: both report the change per fixture and per stage and
+    \\fail when a fixture's throughput drops, or its peak heap grows, by more
+    \\than --tolerance percent. Stage changes show where a regression comes from
+    \\but do not fail alone. --check without a baseline reports the run and
+    \\passes.
+    \\
+    \\Arguments:
+    \\  [fixtures-dir]          Fixture tree (default: test/fixtures)
+    \\
+    \\Options:
+    \\  --iterations <n>        Timed runs per fixture (default: 5)
+    \\  --warmup <n>            Untimed runs per fixture before timing (default: 1)
+    \\  --size <sizes>          Comma-separated tiers to run (default: all)
+    \\  --language, --lang <l>  Only run fixtures in this language
+    \\  --baseline <file>       Baseline file (default: [bench] baseline, or
+    \\                          benchmarks/baselines.json)
+    \\  --update                Write this run's timings to the baseline file
+    \\                          (also --save-baseline)
+    \\  --check                 Compare against the baseline; exit 5 on regression
+    \\  --tolerance <pct>       Allowed throughput drop or peak heap growth in
+    \\                          percent (default: [bench] tolerance, or 10)
+    \\  --format <fmt>          Output format: text, json, benchstat (default: text)
+    \\  --output, -o <file>     Write the report to file instead of stdout
+    \\  --help, -h              Show this help message
+    \\
+    \\Exit codes:
+    \\  0   success, or no fixture regressed
+    \\  5   --check or compare: a fixture regressed beyond the tolerance
+    \\  1   invalid arguments or baseline file
+    \\
+    \\Examples:
+    \\  ananke bench --update
+    \\  ananke bench --check --tolerance 15
+    \\  ananke bench --size xlarge --lang go --iterations 10
+    \\  ananke bench --iterations 10 --format benchstat -o new.txt && benchstat old.txt new.txt
+    \\  ananke bench --update --baseline benchmarks/v0.9.json
+    \\  ananke bench compare benchmarks/v0.8.json benchmarks/v0.9.json --tolerance 5
+;
+
+pub const default_fixtures_dir = "test/fixtures";
+pub const default_baseline_path = "benchmarks/baselines.json";
+/// Section holding the fixture baseline in a file shared with the benches/
+/// harness, whose own sections are left as they are
+const harness_section = "fixture_baseline";
+/// Version 2 added per-stage timings and version 3 memory; older files
+/// still load
+const baseline_version = 3;
+const min_baseline_version = 1;
+const max_baseline_bytes = 4 * 1024 * 1024;
+
+pub const Size = enum {
+    small,
+    medium,
+    large,
+    xlarge,
+};
+
+const BenchFormat = enum {
+    text,
+    json,
+    benchstat,
+};
+
+/// Timing and memory of one fixture; this is also the baseline file's record
+pub const Measurement = struct {
+    name: []const u8,
+    language: []const u8,
+    size: []const u8,
+    lines: usize,
+    bytes: usize,
+    median_ns: u64,
+    lines_per_sec: f64,
+    mean_ns: u64 = 0,
+    p95_ns: u64 = 0,
+    stddev_ns: u64 = 0,
+    /// Median time of each stage, in `stage_names` order
+    stages: []const StageTiming = &.{},
+    allocations: usize = 0,
+    allocated_bytes: usize = 0,
+    peak_heap_bytes: usize = 0,
+    peak_rss_bytes: usize = 0,
+    /// Timed runs in ascending order; not stored in baselines
+    samples: []const u64 = &.{},
+};
+
+/// The stages of a timed run: engine setup, extraction, and rendering the
+/// constraints as JSON. Extraction alone makes up a measurement's headline
+/// time and throughput.
+pub const stage_names = [_][]const u8{ "init", "extract", "render" };
+const extract_stage = 1;
+
+pub const StageTiming = struct {
+    name: []const u8,
+    median_ns: u64,
+};
+
+pub const Stats = struct {
+    mean_ns: u64,
+    median_ns: u64,
+    /// Nearest-rank 95th percentile
+    p95_ns: u64,
+    stddev_ns: u64,
+};
+
+/// Statistics of `samples`, which are sorted in place
+pub fn summarize(samples: []u64) Stats {
+    std.debug.assert(samples.len > 0);
+    std.mem.sort(u64, samples, {}, std.sort.asc(u64));
+
+    var sum: f64 = 0;
+    for (samples) |s| sum += @floatFromInt(s);
+    const n: f64 = @floatFromInt(samples.len);
+    const mean = sum / n;
+    var squares: f64 = 0;
+    for (samples) |s| {
+        const d = @as(f64, @floatFromInt(s)) - mean;
+        squares += d * d;
+    }
+    const variance = if (samples.len > 1) squares / (n - 1) else 0;
+
+    const rank: usize = @intFromFloat(@ceil(0.95 * n));
+    return .{
+        .mean_ns = @intFromFloat(@round(mean)),
+        .median_ns = samples[samples.len / 2],
+        .p95_ns = samples[@max(rank, 1) - 1],
+        .stddev_ns = @intFromFloat(@round(@sqrt(variance))),
+    };
+}
+
+const BaselineFile = struct {
+    version: u32,
+    tool_version: []const u8 = "",
+    created_at: []const u8 = "",
+    iterations: usize = 0,
+    fixtures: []Measurement,
+};
+
+pub const Status = enum {
+    ok,
+    improved,
+    regressed,
+    /// The baseline has no entry for this fixture
+    new,
+};
+
+pub const Comparison = struct {
+    current: Measurement,
+    baseline_lines_per_sec: ?f64,
+    /// Throughput change relative to the baseline, in percent
+    change_percent: f64,
+    status: Status,
+    stages: []const StageComparison = &.{},
+    baseline_peak_heap_bytes: ?usize = null,
+    /// Peak heap change relative to the baseline, in percent; positive is larger
+    peak_heap_change_percent: f64 = 0,
+    memory_status: Status = .new,
+};
+
+pub const StageComparison = struct {
+    name: []const u8,
+    median_ns: u64,
+    baseline_median_ns: ?u64,
+    /// Time change relative to the baseline, in percent; positive is slower
+    change_percent: f64,
+    status: Status,
+};
+
+/// Compare each measurement with the baseline entry of the same name. A drop
+/// beyond `tolerance_percent` regresses; a gain beyond it is reported as an
+/// improvement so the baseline can be refreshed. Stages are compared the
+/// same way by time, and peak heap by size when both sides measured it.
+/// Allocates into `allocator`, meant to be an arena.
+pub fn compare(
+    allocator: std.mem.Allocator,
+    current: []const Measurement,
+    baseline: []const Measurement,
+    tolerance_percent: f64,
+) ![]Comparison {
+    const comparisons = try allocator.alloc(Comparison, current.len);
+    for (current, comparisons) |m, *c| {
+        c.* = .{ .current = m, .baseline_lines_per_sec = null, .change_percent = 0, .status = .new };
+        for (baseline) |b| {
+            if (!std.mem.eql(u8, b.name, m.name) or b.lines_per_sec <= 0) continue;
+            c.stages = try compareStages(allocator, m.stages, b.stages, tolerance_percent);
+            if (m.peak_heap_bytes > 0 and b.peak_heap_bytes > 0) {
+                const base: f64 = @floatFromInt(b.peak_heap_bytes);
+                const growth = (@as(f64, @floatFromInt(m.peak_heap_bytes)) - base) / base * 100.0;
+                c.baseline_peak_heap_bytes = b.peak_heap_bytes;
+                c.peak_heap_change_percent = growth;
+                c.memory_status = if (growth > tolerance_percent)
+                    .regressed
+                else if (growth < -tolerance_percent)
+                    .improved
+                else
+                    .ok;
+            }
+            const change = (m.lines_per_sec - b.lines_per_sec) / b.lines_per_sec * 100.0;
+            c.baseline_lines_per_sec = b.lines_per_sec;
+            c.change_percent = change;
+            c.status = if (change < -tolerance_percent)
+                .regressed
+            else if (change > tolerance_percent)
+                .improved
+            else
+                .ok;
+            break;
+        }
+    }
+    return comparisons;
+}
+
+fn compareStages(allocator: std.mem.Allocator, current: []const StageTiming, baseline: []const StageTiming, tolerance_percent: f64) ![]StageComparison {
+    const comparisons = try allocator.alloc(StageComparison, current.len);
+    for (current, comparisons) |stage, *c| {
+        c.* = .{ .name = stage.name, .median_ns = stage.median_ns, .baseline_median_ns = null, .change_percent = 0, .status = .new };
+        for (baseline) |b| {
+            if (!std.mem.eql(u8, b.name, stage.name) or b.median_ns == 0) continue;
+            const base: f64 = @floatFromInt(b.median_ns);
+            const change = (@as(f64, @floatFromInt(stage.median_ns)) - base) / base * 100.0;
+            c.baseline_median_ns = b.median_ns;
+            c.change_percent = change;
+            c.status = if (change > tolerance_percent)
+                .regressed
+            else if (change < -tolerance_percent)
+                .improved
+            else
+                .ok;
+            break;
+        }
+    }
+    return comparisons;
+}
+
+/// Fixtures under `root`/<language>/<size>/, ordered by language, size, name.
+/// Names are relative to `root`.
+fn findFixtures(
+    arena: std.mem.Allocator,
+    root: []const u8,
+    sizes: std.EnumSet(Size),
+    language_filter: ?[]const u8,
+) !std.ArrayList([]const u8) {
+    var names = std.ArrayList([]const u8){};
+    var root_dir = try std.fs.cwd().openDir(root, .{ .iterate = true });
+    defer root_dir.close();
+
+    var languages = std.ArrayList([]const u8){};
+    var it = root_dir.iterate();
+    while (try it.next()) |entry| {
+        if (entry.kind == .directory) try languages.append(arena, try arena.dupe(u8, entry.name));
+    }
+    std.mem.sort([]const u8, languages.items, {}, lessThanString);
+
+    for (languages.items) |language_dir| {
+        for (std.enums.values(Size)) |size| {
+            if (!sizes.contains(size)) continue;
+            const sub_path = try std.fs.path.join(arena, &.{ language_dir, @tagName(size) });
+            var dir = root_dir.openDir(sub_path, .{ .iterate = true }) catch |err| switch (err) {
+                error.FileNotFound, error.NotDir => continue,
+                else => return err,
+            };
+            defer dir.close();
+
+            const first = names.items.len;
+            var files = dir.iterate();
+            while (try files.next()) |entry| {
+                if (entry.kind != .file) continue;
+                const name = try std.fs.path.join(arena, &.{ sub_path, entry.name });
+                const language = discovery.detectLanguage(name);
+                if (std.mem.eql(u8, language, "unknown")) continue;
+                if (language_filter) |wanted| {
+                    if (!std.mem.eql(u8, language, wanted)) continue;
+                }
+                try names.append(arena, name);
+            }
+            std.mem.sort([]const u8, names.items[first..], {}, lessThanString);
+        }
+    }
+    return names;
+}
+
+fn lessThanString(_: void, a: []const u8, b: []const u8) bool {
+    return std.mem.lessThan(u8, a, b);
+}
+
+/// Wall times of `iterations` runs per stage, in `stage_names` order, each
+/// run on a fresh engine so Clew's in-memory cache never serves a repeat.
+/// The `warmup` runs before them warm page cache and lazily loaded grammars
+/// and are not timed. Allocates into `arena`.
+fn timeExtraction(arena: std.mem.Allocator, allocator: std.mem.Allocator, source: []const u8, language: []const u8, warmup: usize, iterations: usize) ![stage_names.len][]u64 {
+    var samples: [stage_names.len][]u64 = undefined;
+    for (&samples) |*stage| stage.* = try arena.alloc(u64, iterations);
+
+    for (0..warmup + iterations) |i| {
+        var timer = try std.time.Timer.start();
+        var engine = try ananke.Ananke.init(allocator);
+        defer engine.deinit();
+        const init_ns = timer.lap();
+        var constraints = try engine.extract(source, language);
+        defer constraints.deinit();
+        const extract_ns = timer.lap();
+        allocator.free(try output.formatJson(allocator, constraints));
+        const render_ns = timer.read();
+        if (i < warmup) continue;
+        for (samples, [_]u64{ init_ns, extract_ns, render_ns }) |stage, elapsed| stage[i - warmup] = elapsed;
+    }
+    return samples;
+}
+
+pub const Memory = struct {
+    allocations: usize,
+    allocated_bytes: usize,
+    peak_heap_bytes: usize,
+    peak_rss_bytes: usize,
+};
+
+/// Heap use of one untimed run through a counting allocator, kept apart
+/// from the timed runs so counting does not slow them
+fn measureMemory(allocator: std.mem.Allocator, source: []const u8, language: []const u8) !Memory {
+    return countMemory(allocator, Extraction{ .source = source, .language = language });
+}
+
+/// The work a fixture's memory is measured on: extraction and JSON rendering
+const Extraction = struct {
+    source: []const u8,
+    language: []const u8,
+
+    fn run(self: Extraction, allocator: std.mem.Allocator) !void {
+        var engine = try ananke.Ananke.init(allocator);
+        defer engine.deinit();
+        var constraints = try engine.extract(self.source, self.language);
+        defer constraints.deinit();
+        allocator.free(try output.formatJson(allocator, constraints));
+    }
+};
+
+/// Allocations, heap, and peak RSS of `work.run`, which allocates through the
+/// allocator it is given
+fn countMemory(allocator: std.mem.Allocator, work: anytype) !Memory {
+    resetPeakRss();
+    var counting = profiling.CountingAllocator.init(allocator);
+    try work.run(counting.allocator());
+    return .{
+        .allocations = counting.allocations,
+        .allocated_bytes = counting.total_bytes,
+        .peak_heap_bytes = counting.peak,
+        .peak_rss_bytes = peakRss(),
+    };
+}
+
+/// Start a new peak RSS at the current RSS; only Linux can
+fn resetPeakRss() void {
+    if (builtin.os.tag != .linux) return;
+    const file = std.fs.openFileAbsolute("/proc/self/clear_refs", .{ .mode = .write_only }) catch return;
+    defer file.close();
+    file.writeAll("5") catch {};
+}
+
+/// Peak resident set size of the process in bytes, or 0 when unknown
+fn peakRss() usize {
+    switch (builtin.os.tag) {
+        .linux => {
+            var buf: [8192]u8 = undefined;
+            const status = std.fs.cwd().readFile("/proc/self/status", &buf) catch return 0;
+            return parseVmHwm(status) orelse 0;
+        },
+        .windows, .wasi => return 0,
+        else => {
+            const usage = std.posix.getrusage(std.posix.rusage.SELF);
+            const maxrss: usize = @intCast(@max(usage.maxrss, 0));
+            // Darwin reports bytes, the BSDs kilobytes
+            return if (builtin.os.tag.isDarwin()) maxrss else maxrss * 1024;
+        },
+    }
+}
+
+/// The `VmHWM:` line of /proc/self/status, in bytes
+fn parseVmHwm(status: []const u8) ?usize {
+    var lines = std.mem.splitScalar(u8, status, '\n');
+    while (lines.next()) |line| {
+        if (!std.mem.startsWith(u8, line, "VmHWM:")) continue;
+        const value = std.mem.trim(u8, line["VmHWM:".len..], " \t");
+        const digits = std.mem.trimRight(u8, value, " kB");
+        const kib = std.fmt.parseInt(usize, digits, 10) catch return null;
+        return kib * 1024;
+    }
+    return null;
+}
+
+/// `bytes` as formatted by `profiling.writeBytes`, for padding into columns
+fn bytesText(buf: []u8, bytes: usize) []const u8 {
+    var fbs = std.io.fixedBufferStream(buf);
+    profiling.writeBytes(fbs.writer(), bytes) catch {};
+    return fbs.getWritten();
+}
+
+pub fn formatText(allocator: std.mem.Allocator, comparisons: []const Comparison, tolerance_percent: f64, checked: bool) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{s:<48} {s:>7} {s:>10} {s:>10} {s:>10} {s:>12}", .{ "Fixture", "Lines", "Mean", "P95", "Median", "Lines/s" });
+    if (checked) try writer.print(" {s:>12} {s:>8}  Status", .{ "Baseline", "Change" });
+    try writer.writeAll("\n");
+
+    var regressions: usize = 0;
+    for (comparisons) |c| {
+        const m = c.current;
+        try writer.print("{s:<48} {d:>7} {d:>8.2}ms {d:>8.2}ms {d:>8.2}ms {d:>12.0}", .{
+            m.name,
+            m.lines,
+            @as(f64, @floatFromInt(m.mean_ns)) / std.time.ns_per_ms,
+            @as(f64, @floatFromInt(m.p95_ns)) / std.time.ns_per_ms,
+            @as(f64, @floatFromInt(m.median_ns)) / std.time.ns_per_ms,
+            m.lines_per_sec,
+        });
+        if (checked) {
+            if (c.baseline_lines_per_sec) |b| {
+                try writer.print(" {d:>12.0} {d:>+7.1}%  {s}", .{ b, c.change_percent, @tagName(c.status) });
+            } else {
+                try writer.print(" {s:>12} {s:>8}  new", .{ "-", "-" });
+            }
+            if (c.status == .regressed or c.memory_status == .regressed) regressions += 1;
+        }
+        try writer.writeAll("\n");
+    }
+
+    var allocated_buf: [16]u8 = undefined;
+    var heap_buf: [16]u8 = undefined;
+    var rss_buf: [16]u8 = undefined;
+    try writer.print("\n{s:<48} {s:>9} {s:>10} {s:>10} {s:>10}", .{ "Memory", "Allocs", "Allocated", "Peak heap", "Peak RSS" });
+    if (checked) try writer.print(" {s:>10} {s:>8}  Status", .{ "Baseline", "Change" });
+    try writer.writeAll("\n");
+    for (comparisons) |c| {
+        const m = c.current;
+        try writer.print("{s:<48} {d:>9} {s:>10} {s:>10} {s:>10}", .{
+            m.name,
+            m.allocations,
+            bytesText(&allocated_buf, m.allocated_bytes),
+            bytesText(&heap_buf, m.peak_heap_bytes),
+            bytesText(&rss_buf, m.peak_rss_bytes),
+        });
+        if (checked) {
+            if (c.baseline_peak_heap_bytes) |base| {
+                try writer.print(" {s:>10} {d:>+7.1}%  {s}", .{ bytesText(&allocated_buf, base), c.peak_heap_change_percent, @tagName(c.memory_status) });
+            } else {
+                try writer.print(" {s:>10} {s:>8}  new", .{ "-", "-" });
+            }
+        }
+        try writer.writeAll("\n");
+    }
+
+    try writer.print("\n{s:<8} {s:>8} {s:>12} {s:>14} {s:>14}\n", .{ "Tier", "Fixtures", "Lines/s", "Max peak heap", "Max peak RSS" });
+    for (std.enums.values(Size)) |size| {
+        const tier = summarizeTier(comparisons, @tagName(size)) orelse continue;
+        try writer.print("{s:<8} {d:>8} {d:>12.0} {s:>14} {s:>14}\n", .{
+            @tagName(size),
+            tier.fixtures,
+            tier.lines_per_sec,
+            bytesText(&heap_buf, tier.peak_heap_bytes),
+            bytesText(&rss_buf, tier.peak_rss_bytes),
+        });
+    }
+
+    if (checked) {
+        var header = false;
+        for (comparisons) |c| {
+            for (c.stages) |stage| {
+                const base = stage.baseline_median_ns orelse continue;
+                if (!header) {
+                    try writer.print("\n{s:<48} {s:<8} {s:>10} {s:>10} {s:>8}  Status\n", .{ "Stage", "", "Median", "Baseline", "Change" });
+                    header = true;
+                }
+                try writer.print("{s:<48} {s:<8} {d:>8.2}ms {d:>8.2}ms {d:>+7.1}%  {s}\n", .{
+                    c.current.name,
+                    stage.name,
+                    @as(f64, @floatFromInt(stage.median_ns)) / std.time.ns_per_ms,
+                    @as(f64, @floatFromInt(base)) / std.time.ns_per_ms,
+                    stage.change_percent,
+                    @tagName(stage.status),
+                });
+            }
+        }
+        try writer.print("\n{d} of {d} fixtures regressed beyond {d:.1}% tolerance\n", .{ regressions, comparisons.len, tolerance_percent });
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+const TierSummary = struct {
+    fixtures: usize,
+    /// Total lines over total median time of the tier's fixtures
+    lines_per_sec: f64,
+    peak_heap_bytes: usize,
+    peak_rss_bytes: usize,
+};
+
+/// Throughput and the largest memory figures of the fixtures of size `size`,
+/// or null when it has none
+fn summarizeTier(comparisons: []const Comparison, size: []const u8) ?TierSummary {
+    var tier = TierSummary{ .fixtures = 0, .lines_per_sec = 0, .peak_heap_bytes = 0, .peak_rss_bytes = 0 };
+    var lines: usize = 0;
+    var ns: u64 = 0;
+    for (comparisons) |c| {
+        const m = c.current;
+        if (!std.mem.eql(u8, m.size, size)) continue;
+        tier.fixtures += 1;
+        lines += m.lines;
+        ns += m.median_ns;
+        tier.peak_heap_bytes = @max(tier.peak_heap_bytes, m.peak_heap_bytes);
+        tier.peak_rss_bytes = @max(tier.peak_rss_bytes, m.peak_rss_bytes);
+    }
+    if (tier.fixtures == 0) return null;
+    tier.lines_per_sec = @as(f64, @floatFromInt(lines)) * std.time.ns_per_s / @as(f64, @floatFromInt(@max(ns, 1)));
+    return tier;
+}
+
+pub fn formatJson(allocator: std.mem.Allocator, comparisons: []const Comparison, tolerance_percent: f64, iterations: usize) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\n  \"tool_version\": \"{s}\",\n  \"iterations\": {d},\n  \"tolerance_percent\": {d},\n  \"fixtures\": [\n", .{
+        version.VERSION,
+        iterations,
+        tolerance_percent,
+    });
+    for (comparisons, 0..) |c, i| {
+        try writer.writeAll("    ");
+        try writeMeasurementFields(writer, c.current);
+        try writer.writeAll(", \"samples_ns\": [");
+        for (c.current.samples, 0..) |s, j| {
+            if (j > 0) try writer.writeAll(", ");
+            try writer.print("{d}", .{s});
+        }
+        try writer.writeAll("], \"stages\": [");
+        for (c.stages, 0..) |stage, j| {
+            if (j > 0) try writer.writeAll(", ");
+            try writer.print("{{\"name\": \"{s}\", \"median_ns\": {d}", .{ stage.name, stage.median_ns });
+            if (stage.baseline_median_ns) |b| {
+                try writer.print(", \"baseline_median_ns\": {d}, \"change_percent\": {d:.2}", .{ b, stage.change_percent });
+            }
+            try writer.print(", \"status\": \"{s}\"}}", .{@tagName(stage.status)});
+        }
+        try writer.writeAll("]");
+        if (c.baseline_lines_per_sec) |b| {
+            try writer.print(", \"baseline_lines_per_sec\": {d:.1}, \"change_percent\": {d:.2}", .{ b, c.change_percent });
+        }
+        if (c.baseline_peak_heap_bytes) |b| {
+            try writer.print(", \"baseline_peak_heap_bytes\": {d}, \"peak_heap_change_percent\": {d:.2}", .{ b, c.peak_heap_change_percent });
+        }
+        try writer.print(", \"status\": \"{s}\", \"memory_status\": \"{s}\"}}", .{ @tagName(c.status), @tagName(c.memory_status) });
+        if (i + 1 < comparisons.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("  ]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// Open a JSON object holding the measurement's fields; the caller closes it
+fn writeMeasurementFields(writer: anytype, m: Measurement) !void {
+    try writer.writeAll("{\"name\": \"");
+    try output.writeJsonEscaped(writer, m.name);
+    try writer.print("\", \"language\": \"{s}\", \"size\": \"{s}\", \"lines\": {d}, \"bytes\": {d}, \"median_ns\": {d}, \"mean_ns\": {d}, \"p95_ns\": {d}, \"stddev_ns\": {d}, \"lines_per_sec\": {d:.1}, \"allocations\": {d}, \"allocated_bytes\": {d}, \"peak_heap_bytes\": {d}, \"peak_rss_bytes\": {d}", .{
+        m.language,
+        m.size,
+        m.lines,
+        m.bytes,
+        m.median_ns,
+        m.mean_ns,
+        m.p95_ns,
+        m.stddev_ns,
+        m.lines_per_sec,
+        m.allocations,
+        m.allocated_bytes,
+        m.peak_heap_bytes,
+        m.peak_rss_bytes,
+    });
+}
+
+/// Go benchmark format, one line per timed run, which benchstat reads to
+/// compare two runs with confidence intervals. B/op and allocs/op come from
+/// the memory run, so they repeat on every line of a fixture.
+pub fn formatBenchstat(allocator: std.mem.Allocator, measurements: []const Measurement) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("goos: {s}\ngoarch: {s}\npkg: ananke\n", .{ @tagName(builtin.os.tag), @tagName(builtin.cpu.arch) });
+    for (measurements) |m| {
+        for (m.samples) |ns| {
+            const lines_per_sec = @as(f64, @floatFromInt(m.lines)) * std.time.ns_per_s / @as(f64, @floatFromInt(@max(ns, 1)));
+            try writer.writeAll("BenchmarkExtract/");
+            // Benchmark names end at whitespace
+            for (m.name) |c| try writer.writeByte(if (c == '\\' or std.ascii.isWhitespace(c)) '/' else c);
+            try writer.print(" 1 {d} ns/op {d:.0} lines/s {d} B/op {d} allocs/op\n", .{ ns, lines_per_sec, m.allocated_bytes, m.allocations });
+        }
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+fn formatBaseline(allocator: std.mem.Allocator, measurements: []const Measurement, iterations: usize) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\n  \"version\": {d},\n  \"tool_version\": \"{s}\",\n  \"created_at\": \"", .{ baseline_version, version.VERSION });
+    try cyclonedx.writeIso8601(writer, std.time.timestamp());
+    try writer.print("\",\n  \"iterations\": {d},\n  \"fixtures\": [\n", .{iterations});
+    for (measurements, 0..) |m, i| {
+        try writer.writeAll("    ");
+        try writeMeasurementFields(writer, m);
+        try writer.writeAll(", \"stages\": [");
+        for (m.stages, 0..) |stage, j| {
+            if (j > 0) try writer.writeAll(", ");
+            try writer.print("{{\"name\": \"{s}\", \"median_ns\": {d}}}", .{ stage.name, stage.median_ns });
+        }
+        try writer.writeAll("]}");
+        if (i + 1 < measurements.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("  ]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+fn parseSizes(list: []const u8) ?std.EnumSet(Size) {
+    var sizes = std.EnumSet(Size).initEmpty();
+    var it = std.mem.splitScalar(u8, list, ',');
+    while (it.next()) |raw| {
+        const name = std.mem.trim(u8, raw, " \t");
+        if (name.len == 0) continue;
+        sizes.insert(std.meta.stringToEnum(Size, name) orelse return null);
+    }
+    return sizes;
+}
+
+/// A baseline file of any supported version, on its own or as the
+/// `fixture_baseline` section of a harness file; null when there is none yet
+fn loadBaseline(arena: std.mem.Allocator, path: []const u8) !?BaselineFile {
+    const text = std.fs.cwd().readFileAlloc(arena, path, max_baseline_bytes) catch |err| switch (err) {
+        error.FileNotFound => return null,
+        else => {
+            cli_error.printFileError(err, path);
+            return err;
+        },
+    };
+    const root = std.json.parseFromSliceLeaky(std.json.Value, arena, text, .{}) catch .null;
+    const value = if (isHarnessFile(root)) root.object.get(harness_section) orelse return null else root;
+    const parsed = std.json.parseFromValueLeaky(BaselineFile, arena, value, .{ .ignore_unknown_fields = true }) catch {
+        cli_error.printError("Invalid baseline file: {s}", .{path});
+        return error.InvalidArgument;
+    };
+    if (parsed.version < min_baseline_version or parsed.version > baseline_version) {
+        cli_error.printError("Unsupported baseline version {d} in {s}", .{ parsed.version, path });
+        return error.InvalidArgument;
+    }
+    return parsed;
+}
+
+/// A baseline `bench compare` cannot do without
+fn requireBaseline(arena: std.mem.Allocator, path: []const u8) !BaselineFile {
+    return try loadBaseline(arena, path) orelse {
+        cli_error.printError("No fixture baseline in {s}", .{path});
+        cli_error.printInfo("Record one first with: ananke bench --update --baseline {s}", .{path});
+        return error.InvalidArgument;
+    };
+}
+
+/// Whether `root` is the benches/ harness's file, whose version is a string
+fn isHarnessFile(root: std.json.Value) bool {
+    if (root != .object) return false;
+    const file_version = root.object.get("version") orelse return false;
+    return file_version == .string;
+}
+
+/// Write `text` (from formatBaseline) to `path`, into the `fixture_baseline`
+/// section when `path` is a harness file
+fn saveBaseline(arena: std.mem.Allocator, path: []const u8, text: []const u8) !void {
+    const data = blk: {
+        const existing = std.fs.cwd().readFileAlloc(arena, path, max_baseline_bytes) catch break :blk text;
+        var root = std.json.parseFromSliceLeaky(std.json.Value, arena, existing, .{}) catch break :blk text;
+        if (!isHarnessFile(root)) break :blk text;
+        try root.object.put(harness_section, try std.json.parseFromSliceLeaky(std.json.Value, arena, text, .{}));
+        break :blk try std.mem.concat(arena, u8, &.{ try std.json.Stringify.valueAlloc(arena, root, .{ .whitespace = .indent_2 }), "\n" });
+    };
+    if (std.fs.path.dirname(path)) |dir| std.fs.cwd().makePath(dir) catch {};
+    try std.fs.cwd().writeFile(.{ .sub_path = path, .data = data });
+}
+
+fn failOnRegressions(comparisons: []const Comparison, tolerance: f64, baseline_path: []const u8) !void {
+    var regressions: usize = 0;
+    for (comparisons) |c| {
+        if (c.status == .regressed or c.memory_status == .regressed) regressions += 1;
+    }
+    if (regressions > 0) {
+        cli_error.printError("{d} fixtures regressed more than {d:.1}% in throughput or peak heap against {s}", .{ regressions, tolerance, baseline_path });
+        return error.ValidationFailed;
+    }
+    cli_error.printSuccess("No throughput or peak heap regressions beyond {d:.1}%", .{tolerance});
+}
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const root = if (parsed_args.positional.items.len > 0) parsed_args.positional.items[0] else default_fixtures_dir;
+    const format_str = parsed_args.getFlagOr("format", "text");
+    const format = std.meta.stringToEnum(BenchFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text, json, or benchstat)", .{format_str});
+        return error.InvalidArgument;
+    };
+    const iterations = try parsed_args.getFlagInt("iterations", usize) orelse 5;
+    if (iterations == 0) {
+        cli_error.printError("--iterations must be at least 1", .{});
+        return error.InvalidArgument;
+    }
+    const warmup = try parsed_args.getFlagInt("warmup", usize) orelse 1;
+    const tolerance = try parsed_args.getFlagFloat("tolerance", f64) orelse config.bench_tolerance;
+    if (tolerance < 0) {
+        cli_error.printError("--tolerance must not be negative", .{});
+        return error.InvalidArgument;
+    }
+    const sizes = if (parsed_args.getFlag("size")) |list| parseSizes(list) orelse {
+        cli_error.printError("Invalid size: {s} (expected small, medium, large, xlarge)", .{list});
+        return error.InvalidArgument;
+    } else std.EnumSet(Size).initFull();
+    const baseline_path = parsed_args.getFlag("baseline") orelse config.bench_baseline orelse default_baseline_path;
+    const check = parsed_args.hasFlag("check");
+    const save = parsed_args.hasFlag("update") or parsed_args.hasFlag("save-baseline");
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    if (std.mem.eql(u8, root, "compare")) {
+        const old_path = parsed_args.getPositional(1) catch null;
+        const new_path = parsed_args.getPositional(2) catch null;
+        if (old_path == null or new_path == null) {
+            cli_error.printError("bench compare takes two baseline files: <baseline> <current>", .{});
+            return error.MissingArgument;
+        }
+        const old = try requireBaseline(arena, old_path.?);
+        const new = try requireBaseline(arena, new_path.?);
+        const comparisons = try compare(arena, new.fixtures, old.fixtures, tolerance);
+        const report = switch (format) {
+            .text => try formatText(allocator, comparisons, tolerance, true),
+            .json => try formatJson(allocator, comparisons, tolerance, new.iterations),
+            .benchstat => {
+                cli_error.printError("bench compare has no benchstat output; baselines keep no per-run samples", .{});
+                return error.InvalidArgument;
+            },
+        };
+        defer allocator.free(report);
+        try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), report);
+        return failOnRegressions(comparisons, tolerance, old_path.?);
+    }
+
+    // Load the baseline before spending minutes on measurements
+    const baseline = if (check) try loadBaseline(arena, baseline_path) else null;
+    if (check and baseline == null) {
+        cli_error.printWarning("No baseline in {s}; run with --update to record one", .{baseline_path});
+    }
+
+    const names = findFixtures(arena, root, sizes, parsed_args.getFlag("language") orelse parsed_args.getFlag("lang")) catch |err| {
+        cli_error.printFileError(err, root);
+        return err;
+    };
+    if (names.items.len == 0) {
+        cli_error.printWarning("No fixtures found under {s}/<language>/<size>/", .{root});
+        return;
+    }
+
+    var measurements = std.ArrayList(Measurement){};
+    for (names.items) |name| {
+        const path = try std.fs.path.join(arena, &.{ root, name });
+        const source = std.fs.cwd().readFileAlloc(allocator, path, extract.max_source_bytes) catch |err| {
+            cli_error.printFileError(err, path);
+            return err;
+        };
+        defer allocator.free(source);
+
+        const language = discovery.detectLanguage(name);
+        const runs = try timeExtraction(arena, allocator, source, language, warmup, iterations);
+        var stages: [stage_names.len]StageTiming = undefined;
+        for (&stages, stage_names, runs) |*stage, stage_name, stage_samples| {
+            stage.* = .{ .name = stage_name, .median_ns = summarize(stage_samples).median_ns };
+        }
+        const samples = runs[extract_stage];
+        const stats = summarize(samples);
+        const memory = try measureMemory(allocator, source, language);
+        const lines = std.mem.count(u8, source, "\n") + 1;
+        var parts = std.mem.splitScalar(u8, name, std.fs.path.sep);
+        _ = parts.next();
+        try measurements.append(arena, .{
+            .name = name,
+            .language = language,
+            .size = parts.next() orelse "",
+            .lines = lines,
+            .bytes = source.len,
+            .median_ns = stats.median_ns,
+            .lines_per_sec = @as(f64, @floatFromInt(lines)) * std.time.ns_per_s / @as(f64, @floatFromInt(@max(stats.median_ns, 1))),
+            .mean_ns = stats.mean_ns,
+            .p95_ns = stats.p95_ns,
+            .stddev_ns = stats.stddev_ns,
+            .stages = try arena.dupe(StageTiming, &stages),
+            .allocations = memory.allocations,
+            .allocated_bytes = memory.allocated_bytes,
+            .peak_heap_bytes = memory.peak_heap_bytes,
+            .peak_rss_bytes = memory.peak_rss_bytes,
+            .samples = samples,
+        });
+    }
+
+    const comparisons = try compare(arena, measurements.items, if (baseline) |b| b.fixtures else &.{}, tolerance);
+    const report = switch (format) {
+        .text => try formatText(allocator, comparisons, tolerance, baseline != null),
+        .json => try formatJson(allocator, comparisons, tolerance, iterations),
+        .benchstat => try formatBenchstat(allocator, measurements.items),
+    };
+    defer allocator.free(report);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), report);
+
+    if (save) {
+        const text = try formatBaseline(allocator, measurements.items, iterations);
+        defer allocator.free(text);
+        saveBaseline(arena, baseline_path, text) catch |err| {
+            cli_error.printFileError(err, baseline_path);
+            return err;
+        };
+        cli_error.printSuccess("Saved baseline for {d} fixtures to {s}", .{ measurements.items.len, baseline_path });
+    }
+
+    if (baseline != null) try failOnRegressions(comparisons, tolerance, baseline_path);
+}
+
+test "compare flags throughput drops beyond tolerance" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const fixture = Measurement{ .name = "go/xlarge/a.go", .language = "go", .size = "xlarge", .lines = 5000, .bytes = 1, .median_ns = 1, .lines_per_sec = 1000 };
+    var slower = fixture;
+    slower.lines_per_sec = 850;
+    var faster = fixture;
+    faster.name = "go/small/b.go";
+    faster.lines_per_sec = 1050;
+    var unseen = fixture;
+    unseen.name = "zig/small/c.zig";
+
+    const baseline = [_]Measurement{ fixture, .{ .name = "go/small/b.go", .language = "go", .size = "small", .lines = 100, .bytes = 1, .median_ns = 1, .lines_per_sec = 1000 } };
+    const comparisons = try compare(allocator, &.{ slower, faster, unseen }, &baseline, 10);
+    defer allocator.free(comparisons);
+
+    try testing.expectEqual(Status.regressed, comparisons[0].status);
+    try testing.expectApproxEqAbs(@as(f64, -15), comparisons[0].change_percent, 0.001);
+    try testing.expectEqual(Status.ok, comparisons[1].status);
+    try testing.expectEqual(Status.new, comparisons[2].status);
+
+    // Stages compare by time, where slower regresses
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    var staged = fixture;
+    staged.stages = &.{ .{ .name = "init", .median_ns = 100 }, .{ .name = "extract", .median_ns = 1300 } };
+    var staged_baseline = fixture;
+    staged_baseline.stages = &.{ .{ .name = "init", .median_ns = 105 }, .{ .name = "extract", .median_ns = 1000 } };
+    const staged_comparisons = try compare(arena_state.allocator(), &.{staged}, &.{staged_baseline}, 10);
+    const stages = staged_comparisons[0].stages;
+    try testing.expectEqual(Status.ok, stages[0].status);
+    try testing.expectEqual(Status.regressed, stages[1].status);
+    try testing.expectApproxEqAbs(@as(f64, 30), stages[1].change_percent, 0.001);
+
+    // Peak heap growth regresses too, but only against a baseline that has it
+    var heavier = fixture;
+    heavier.peak_heap_bytes = 1200;
+    var measured_baseline = fixture;
+    measured_baseline.peak_heap_bytes = 1000;
+    const memory_comparisons = try compare(arena_state.allocator(), &.{ heavier, heavier }, &.{measured_baseline}, 10);
+    try testing.expectEqual(Status.ok, memory_comparisons[0].status);
+    try testing.expectEqual(Status.regressed, memory_comparisons[0].memory_status);
+    try testing.expectApproxEqAbs(@as(f64, 20), memory_comparisons[0].peak_heap_change_percent, 0.001);
+    const unmeasured = try compare(arena_state.allocator(), &.{heavier}, &.{fixture}, 10);
+    try testing.expectEqual(Status.new, unmeasured[0].memory_status);
+    try testing.expectEqual(@as(?usize, null), unmeasured[0].baseline_peak_heap_bytes);
+}
+
+test "summarize reports mean, median, and p95 of the timed runs" {
+    const testing = std.testing;
+
+    var samples = [_]u64{ 120, 100, 110, 400, 105, 115, 100, 108, 112, 104, 101, 103, 109, 111, 102, 106, 107, 113, 114, 200 };
+    const stats = summarize(&samples);
+    try testing.expectEqual(@as(u64, 100), samples[0]);
+    try testing.expectEqual(@as(u64, 127), stats.mean_ns);
+    try testing.expectEqual(@as(u64, 109), stats.median_ns);
+    try testing.expectEqual(@as(u64, 200), stats.p95_ns);
+
+    var single = [_]u64{42};
+    const one = summarize(&single);
+    try testing.expectEqual(@as(u64, 42), one.p95_ns);
+    try testing.expectEqual(@as(u64, 0), one.stddev_ns);
+
+    const measurement = Measurement{ .name = "go/small/a.go", .language = "go", .size = "small", .lines = 100, .bytes = 1, .median_ns = 1, .lines_per_sec = 1, .allocations = 12, .allocated_bytes = 4096, .samples = &.{ 1000, 2000 } };
+    const text = try formatBenchstat(testing.allocator, &.{measurement});
+    defer testing.allocator.free(text);
+    try testing.expect(std.mem.indexOf(u8, text, "BenchmarkExtract/go/small/a.go 1 1000 ns/op 100000000 lines/s 4096 B/op 12 allocs/op\n") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "BenchmarkExtract/go/small/a.go 1 2000 ns/op 50000000 lines/s 4096 B/op 12 allocs/op\n") != null);
+
+    try testing.expectEqual(@as(?usize, 10240 * 1024), parseVmHwm("Name:\tananke\nVmPeak:\t  20480 kB\nVmHWM:\t   10240 kB\nVmRSS:\t    9000 kB\n"));
+    try testing.expectEqual(@as(?usize, null), parseVmHwm("Name:\tananke\n"));
+}
+
+test "memory counts one run's allocations and tiers keep their peaks" {
+    const testing = std.testing;
+
+    // 100 and 50 bytes live together, then 30 more once the 100 are freed
+    const Workload = struct {
+        fn run(_: @This(), allocator: std.mem.Allocator) !void {
+            const first = try allocator.alloc(u8, 100);
+            const second = try allocator.alloc(u8, 50);
+            allocator.free(first);
+            const third = try allocator.alloc(u8, 30);
+            allocator.free(third);
+            allocator.free(second);
+        }
+    };
+    const memory = try countMemory(testing.allocator, Workload{});
+    try testing.expectEqual(@as(usize, 3), memory.allocations);
+    try testing.expectEqual(@as(usize, 180), memory.allocated_bytes);
+    try testing.expectEqual(@as(usize, 150), memory.peak_heap_bytes);
+    if (builtin.os.tag == .linux) try testing.expect(memory.peak_rss_bytes > 0);
+
+    // A real extraction frees everything it counts
+    const extraction = try measureMemory(testing.allocator, "def f(x: int) -> int:\n    return x\n", "python");
+    try testing.expect(extraction.allocations > 0);
+    try testing.expect(extraction.peak_heap_bytes > 0);
+    try testing.expect(extraction.peak_heap_bytes <= extraction.allocated_bytes);
+
+    const small = Measurement{ .name = "go/small/a.go", .language = "go", .size = "small", .lines = 100, .bytes = 1, .median_ns = std.time.ns_per_ms, .lines_per_sec = 1, .peak_heap_bytes = 4096, .peak_rss_bytes = 1 << 20 };
+    var other = small;
+    other.name = "go/small/b.go";
+    other.lines = 300;
+    other.median_ns = 3 * std.time.ns_per_ms;
+    other.peak_heap_bytes = 8192;
+    other.peak_rss_bytes = 1 << 19;
+    var large = small;
+    large.name = "go/large/c.go";
+    large.size = "large";
+    large.peak_heap_bytes = 1 << 20;
+    const comparisons = try compare(testing.allocator, &.{ small, other, large }, &.{}, 10);
+    defer testing.allocator.free(comparisons);
+
+    const tier = summarizeTier(comparisons, "small").?;
+    try testing.expectEqual(@as(usize, 2), tier.fixtures);
+    try testing.expectApproxEqAbs(@as(f64, 100_000), tier.lines_per_sec, 0.001);
+    try testing.expectEqual(@as(usize, 8192), tier.peak_heap_bytes);
+    try testing.expectEqual(@as(usize, 1 << 20), tier.peak_rss_bytes);
+    try testing.expectEqual(@as(usize, 1 << 20), summarizeTier(comparisons, "large").?.peak_heap_bytes);
+    try testing.expect(summarizeTier(comparisons, "xlarge") == null);
+}
+
+test "fixture baselines live in a section of the harness file" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const dir = try tmp.dir.realpathAlloc(arena, ".");
+    const path = try std.fs.path.join(arena, &.{ dir, "baselines.json" });
+
+    // No file, then a harness file without the section: nothing to check against
+    try testing.expect(try loadBaseline(arena, path) == null);
+    try tmp.dir.writeFile(.{ .sub_path = "baselines.json", .data = "{\"version\": \"1.0.0\", \"zig\": {\"ffi_roundtrip\": {\"mean_ns\": 100000}}}" });
+    try testing.expect(try loadBaseline(arena, path) == null);
+
+    const fixture = Measurement{ .name = "go/small/a.go", .language = "go", .size = "small", .lines = 100, .bytes = 1, .median_ns = 5000, .lines_per_sec = 2e7 };
+    try saveBaseline(arena, path, try formatBaseline(arena, &.{fixture}, 5));
+    const saved = (try loadBaseline(arena, path)).?;
+    try testing.expectEqual(@as(usize, 1), saved.fixtures.len);
+    try testing.expectEqualStrings("go/small/a.go", saved.fixtures[0].name);
+    const text = try tmp.dir.readFileAlloc(arena, "baselines.json", max_baseline_bytes);
+    try testing.expect(std.mem.indexOf(u8, text, "\"ffi_roundtrip\"") != null);
+
+    // A file of its own is written whole
+    const own = try std.fs.path.join(arena, &.{ dir, "v0.9.json" });
+    try saveBaseline(arena, own, try formatBaseline(arena, &.{fixture}, 5));
+    try testing.expectEqual(@as(u32, baseline_version), (try loadBaseline(arena, own)).?.version);
+}
@@ -0,0 +1,296 @@
+// Cache command - Inspect and maintain the extraction cache
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const output = @import("cli_output");
+const cli_error = @import("cli_error");
+const profiling = @import("cli_profiling");
+const jobs = @import("cli_jobs");
+const cache_store = @import("cli_cache_store");
+
+pub const usage =
+    \\Usage: ananke cache <stats|clear|gc> [options]
+    \\
+    \\Inspect and maintain the extraction cache. `extract`, `extract-ref`, and `diff`
+    \\store the constraints of every file they analyze, keyed by content, language,
+    \\tool version, and rule-set digest, and reuse them while none of these change.
+    \\After a rule change, entries are still reused for files that contain none of
+    \\the changed rules' patterns. Entries are stored compressed, and runs evict the
+    \\least recently used ones beyond [cache] max_size (default: 2G).
+    \\
+    \\Subcommands:
+    \\  stats                   Show entry count, size, last use, hit rate, and the
+    \\                          lookups of recent runs
+    \\  clear                   Delete every entry, body scan, discovery snapshot,
+    \\                          rule-set record, and the statistics
+    \\  gc                      Delete entries not used within --max-age days, or the
+    \\                          least recently used beyond --max-size
+    \\
+    \\Options:
+    \\  --cache-dir <dir>       Cache directory (default: [cache] dir, or .ananke-cache)
+    \\  --max-age <days>        gc: keep entries used within this many days (default: 30)
+    \\  --max-size <size>       gc: keep the most recently used entries that fit in size
+    \\                          (e.g. 500M) instead
+    \\  --dry-run               gc: report what would be deleted without deleting
+    \\  --format <fmt>          stats: text, json (default: text)
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke cache stats
+    \\  ananke cache gc --max-age 7
+    \\  ananke cache gc --max-size 500M
+    \\  ananke cache clear --cache-dir /tmp/ananke-cache
+;
+
+const Subcommand = enum {
+    stats,
+    clear,
+    gc,
+};
+
+const StatsFormat = enum {
+    text,
+    json,
+};
+
+pub const default_max_age_days = 30;
+/// Recent runs listed by `stats` in text form; json lists every recorded run
+const text_recent_runs = 5;
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const subcommand_str = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <stats|clear|gc>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const subcommand = std.meta.stringToEnum(Subcommand, subcommand_str) orelse {
+        cli_error.printError("Unknown cache subcommand: {s} (expected stats, clear, or gc)", .{subcommand_str});
+        return error.InvalidArgument;
+    };
+
+    const dir_path = parsed_args.getFlag("cache-dir") orelse config.cache_dir orelse cache_store.default_dir;
+    var dir = std.fs.cwd().openDir(dir_path, .{}) catch |err| switch (err) {
+        error.FileNotFound => {
+            cli_error.printInfo("No extraction cache at {s}", .{dir_path});
+            return;
+        },
+        else => {
+            cli_error.printFileError(err, dir_path);
+            return err;
+        },
+    };
+    defer dir.close();
+
+    // Refuse to delete a directory the cache did not create
+    if (subcommand != .stats and !cache_store.isCacheDir(dir)) {
+        cli_error.printError("{s} does not look like an extraction cache; not deleting anything", .{dir_path});
+        return error.InvalidArgument;
+    }
+
+    switch (subcommand) {
+        .stats => {
+            const format_str = parsed_args.getFlagOr("format", "text");
+            const format = std.meta.stringToEnum(StatsFormat, format_str) orelse {
+                cli_error.printError("Invalid format: {s} (expected text or json)", .{format_str});
+                return error.InvalidArgument;
+            };
+            const usage_stats = try cache_store.usage(allocator, dir);
+            const counters = try cache_store.readCounters(allocator, dir);
+            const runs = try cache_store.readRuns(allocator, dir);
+            defer allocator.free(runs);
+            const text = try formatStats(allocator, dir_path, usage_stats, counters, runs, std.time.timestamp(), format);
+            defer allocator.free(text);
+            try std.fs.File.stdout().writeAll(text);
+        },
+        .clear => {
+            const usage_stats = try cache_store.usage(allocator, dir);
+            cache_store.clear(dir) catch |err| {
+                cli_error.printFileError(err, dir_path);
+                return err;
+            };
+            var freed = std.ArrayList(u8){};
+            defer freed.deinit(allocator);
+            try profiling.writeBytes(freed.writer(allocator), usage_stats.bytes);
+            cli_error.printSuccess("Cleared {d} entries ({s}) from {s}", .{ usage_stats.entries, freed.items, dir_path });
+        },
+        .gc => {
+            const dry_run = parsed_args.hasFlag("dry-run");
+            if (parsed_args.getFlag("max-size")) |size_str| {
+                const max_bytes = jobs.parseByteSize(size_str) orelse {
+                    cli_error.printError("Invalid cache size: {s} (expected a byte count, e.g. 500M)", .{size_str});
+                    return error.InvalidArgument;
+                };
+                const gc = try cache_store.evictToSize(allocator, dir, max_bytes, dry_run);
+
+                var freed = std.ArrayList(u8){};
+                defer freed.deinit(allocator);
+                try profiling.writeBytes(freed.writer(allocator), gc.freed_bytes);
+                if (dry_run) {
+                    cli_error.printInfo("Would remove {d} least recently used entries ({s}) to fit in {s}; {d} kept", .{ gc.removed, freed.items, size_str, gc.kept });
+                } else {
+                    cli_error.printSuccess("Removed {d} least recently used entries ({s}) to fit in {s}; {d} kept", .{ gc.removed, freed.items, size_str, gc.kept });
+                }
+                return;
+            }
+
+            const max_age_days = try parsed_args.getFlagInt("max-age", u32) orelse default_max_age_days;
+            const cutoff_ns = std.time.nanoTimestamp() - @as(i128, max_age_days) * std.time.ns_per_day;
+            const gc = try cache_store.collectGarbage(allocator, dir, cutoff_ns, dry_run);
+
+            var freed = std.ArrayList(u8){};
+            defer freed.deinit(allocator);
+            try profiling.writeBytes(freed.writer(allocator), gc.freed_bytes);
+            if (dry_run) {
+                cli_error.printInfo("Would remove {d} entries ({s}) unused for {d} days; {d} kept", .{ gc.removed, freed.items, max_age_days, gc.kept });
+            } else {
+                cli_error.printSuccess("Removed {d} entries ({s}) unused for {d} days; {d} kept", .{ gc.removed, freed.items, max_age_days, gc.kept });
+            }
+        },
+    }
+}
+
+/// Render cache statistics and the counters of recent `runs` (oldest
+/// first). `now` (Unix seconds) dates the last-use ages.
+pub fn formatStats(
+    allocator: std.mem.Allocator,
+    dir_path: []const u8,
+    usage_stats: cache_store.Usage,
+    counters: cache_store.Counters,
+    runs: []const cache_store.Counters,
+    now: i64,
+    format: StatsFormat,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    const oldest_s: ?i64 = if (usage_stats.oldest_ns) |ns| @intCast(@divFloor(ns, std.time.ns_per_s)) else null;
+    const newest_s: ?i64 = if (usage_stats.newest_ns) |ns| @intCast(@divFloor(ns, std.time.ns_per_s)) else null;
+
+    switch (format) {
+        .json => {
+            try writer.writeAll("{\n  \"dir\": \"");
+            try output.writeJsonEscaped(writer, dir_path);
+            try writer.print("\",\n  \"entries\": {d},\n  \"bytes\": {d},\n", .{ usage_stats.entries, usage_stats.bytes });
+            try writeJsonTimestamp(writer, "oldest_used", oldest_s);
+            try writeJsonTimestamp(writer, "newest_used", newest_s);
+            try writer.print(
+                "  \"runs\": {d},\n  \"hits\": {d},\n  \"misses\": {d},\n  \"writes\": {d},\n  \"evictions\": {d},\n  \"hit_rate\": {d:.4},\n  \"last_run\": {d},\n",
+                .{ counters.runs, counters.hits, counters.misses, counters.writes, counters.evictions, counters.hitRate(), counters.last_run },
+            );
+            try writer.writeAll("  \"recent_runs\": [");
+            for (runs, 0..) |run_counters, i| {
+                try writer.writeAll(if (i == 0) "\n    " else ",\n    ");
+                try writer.print(
+                    "{{\"time\": {d}, \"hits\": {d}, \"misses\": {d}, \"writes\": {d}, \"evictions\": {d}, \"hit_rate\": {d:.4}}}",
+                    .{ run_counters.last_run, run_counters.hits, run_counters.misses, run_counters.writes, run_counters.evictions, run_counters.hitRate() },
+                );
+            }
+            try writer.writeAll(if (runs.len > 0) "\n  ]\n}\n" else "]\n}\n");
+        },
+        .text => {
+            try writer.print("Extraction cache: {s}\n", .{dir_path});
+            try writer.print("  Entries:    {d} (", .{usage_stats.entries});
+            try profiling.writeBytes(writer, usage_stats.bytes);
+            try writer.writeAll(")\n");
+            if (oldest_s != null and newest_s != null) {
+                try writer.writeAll("  Last used:  oldest ");
+                try writeAge(writer, now - oldest_s.?);
+                try writer.writeAll(", newest ");
+                try writeAge(writer, now - newest_s.?);
+                try writer.writeAll("\n");
+            }
+            try writer.print("  Runs:       {d}", .{counters.runs});
+            if (counters.runs > 0) {
+                try writer.writeAll(" (last ");
+                try writeAge(writer, now - counters.last_run);
+                try writer.writeAll(")");
+            }
+            try writer.print("\n  Lookups:    {d} hits, {d} misses ({d:.1}% hit rate)\n", .{
+                counters.hits,
+                counters.misses,
+                counters.hitRate() * 100,
+            });
+            try writer.print("  Writes:     {d}\n", .{counters.writes});
+            try writer.print("  Evictions:  {d}\n", .{counters.evictions});
+            if (runs.len > 0) {
+                try writer.writeAll("  Recent runs:\n");
+                var i = runs.len;
+                while (i > runs.len -| text_recent_runs) {
+                    i -= 1;
+                    const run_counters = runs[i];
+                    try writer.writeAll("    ");
+                    try writeAge(writer, now - run_counters.last_run);
+                    try writer.print(": {d} hits, {d} misses ({d:.1}%), {d} written, {d} evicted\n", .{
+                        run_counters.hits,
+                        run_counters.misses,
+                        run_counters.hitRate() * 100,
+                        run_counters.writes,
+                        run_counters.evictions,
+                    });
+                }
+            }
+        },
+    }
+
+    return list.toOwnedSlice(allocator);
+}
+
+fn writeJsonTimestamp(writer: anytype, name: []const u8, seconds: ?i64) !void {
+    if (seconds) |s| {
+        try writer.print("  \"{s}\": {d},\n", .{ name, s });
+    } else {
+        try writer.print("  \"{s}\": null,\n", .{name});
+    }
+}
+
+/// Write an elapsed time as "N minutes/hours/days ago"
+fn writeAge(writer: anytype, seconds: i64) !void {
+    const s: u64 = @intCast(@max(seconds, 0));
+    if (s < std.time.s_per_hour) {
+        try writer.print("{d} minutes ago", .{s / std.time.s_per_min});
+    } else if (s < std.time.s_per_day) {
+        try writer.print("{d} hours ago", .{s / std.time.s_per_hour});
+    } else {
+        try writer.print("{d} days ago", .{s / std.time.s_per_day});
+    }
+}
+
+test "cache stats report size, age, and hit rate" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const now: i64 = 1_700_000_000;
+    const usage_stats = cache_store.Usage{
+        .entries = 12,
+        .bytes = 3 * 1024,
+        .oldest_ns = @as(i128, now - 3 * std.time.s_per_day) * std.time.ns_per_s,
+        .newest_ns = @as(i128, now - 2 * std.time.s_per_hour) * std.time.ns_per_s,
+    };
+    const counters = cache_store.Counters{ .runs = 4, .hits = 30, .misses = 10, .writes = 10, .evictions = 3, .last_run = now - 120 };
+    const runs = [_]cache_store.Counters{
+        .{ .runs = 1, .hits = 0, .misses = 10, .writes = 10, .last_run = now - 3 * std.time.s_per_day },
+        .{ .runs = 1, .hits = 9, .misses = 1, .evictions = 3, .last_run = now - 120 },
+    };
+
+    const text = try formatStats(allocator, ".ananke-cache", usage_stats, counters, &runs, now, .text);
+    defer allocator.free(text);
+    try testing.expect(std.mem.indexOf(u8, text, "Entries:    12 (3.0 KiB)") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "oldest 3 days ago, newest 2 hours ago") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "30 hits, 10 misses (75.0% hit rate)") != null);
+    // Newest run first
+    try testing.expect(std.mem.indexOf(u8, text, "Recent runs:\n    2 minutes ago: 9 hits, 1 misses (90.0%), 0 written, 3 evicted\n    3 days ago") != null);
+
+    const json = try formatStats(allocator, ".ananke-cache", usage_stats, counters, &runs, now, .json);
+    defer allocator.free(json);
+    try testing.expect(std.mem.indexOf(u8, json, "\"hit_rate\": 0.7500") != null);
+    try testing.expect(std.mem.indexOf(u8, json, "\"evictions\": 3,") != null);
+    const parsed = try std.json.parseFromSlice(std.json.Value, allocator, json, .{});
+    defer parsed.deinit();
+    try testing.expectEqual(@as(usize, 2), parsed.value.object.get("recent_runs").?.array.items.len);
+}
@@ -0,0 +1,164 @@
+// Constraints-doc command - Write or check a CONSTRAINTS.md per package from a stored constraint set
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const messages = @import("cli_messages");
+const results = @import("cli_results");
+const package_docs = @import("cli_package_docs");
+
+pub const usage =
+    \\Usage: ananke constraints-doc <constraints> [options]
+    \\
+    \\Write a CONSTRAINTS.md into each package directory (a file's directory)
+    \\documenting its constraints, from a JSON result file (`ananke extract
+    \\--format json`, usually committed). The docs list constraints by file,
+    \\severity, kind, and name without line numbers, so they only change when
+    \\the constraints do. Docs of packages that no longer have constraints are
+    \\removed; hand-written CONSTRAINTS.md files are left alone.
+    \\
+    \\With --check nothing is written: the command fails when a doc is missing,
+    \\differs from what would be generated, or belongs to a package without
+    \\constraints, so CI can keep the docs current.
+    \\
+    \\Arguments:
+    \\  <constraints>           Stored JSON result file
+    \\
+    \\Options:
+    \\  --root <dir>            Directory the result's file paths are relative to
+    \\                          (default: .)
+    \\  --check                 Fail when a doc is stale instead of writing it
+    \\  --messages <file>       Message catalog for the docs' strings
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\Exit codes:
+    \\  0   docs written, or all current with --check
+    \\  5   --check found stale docs
+    \\  1   invalid arguments; 3 when the constraints file is missing
+    \\
+    \\Examples:
+    \\  ananke constraints-doc constraints.json
+    \\  ananke extract . --format json -o constraints.json && ananke constraints-doc constraints.json --check
+;
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const constraints_path = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <constraints>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const root = parsed_args.getFlagOr("root", ".");
+    const check = parsed_args.hasFlag("check");
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+
+    var catalog = try loadCatalog(allocator, parsed_args, config);
+    defer catalog.deinit();
+
+    var stored = results.ResultFile.loadFile(allocator, constraints_path) catch |err| {
+        if (err == results.ResultError.InvalidResultFile) {
+            cli_error.printError("Not an ananke JSON result file: {s}", .{constraints_path});
+            return error.InvalidArgument;
+        }
+        cli_error.printFileError(err, constraints_path);
+        return err;
+    };
+    defer stored.deinit();
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const packages = try package_docs.group(arena, stored.constraint_set.constraints.items);
+    var documented = std.StringHashMap(void).init(arena);
+    var stale: usize = 0;
+    var written: usize = 0;
+    var current_docs: usize = 0;
+    for (packages) |pkg| {
+        try documented.put(pkg.name, {});
+        const path = try package_docs.docPath(arena, root, pkg.name);
+        const text = try package_docs.format(arena, pkg, &catalog);
+        const existing = try readDoc(arena, path);
+        if (existing) |current| {
+            if (!std.mem.startsWith(u8, current, package_docs.generated_marker)) {
+                cli_error.printWarning("Skipping {s}: not generated by ananke", .{path});
+                continue;
+            }
+            if (std.mem.eql(u8, current, text)) {
+                current_docs += 1;
+                continue;
+            }
+        }
+
+        if (check) {
+            stale += 1;
+            cli_error.printError("{s} is {s}", .{ path, if (existing == null) "missing" else "stale" });
+            continue;
+        }
+        if (verbose) cli_error.printInfo("Writing {s}", .{path});
+        std.fs.cwd().writeFile(.{ .sub_path = path, .data = text }) catch |err| {
+            cli_error.printFileError(err, path);
+            return err;
+        };
+        written += 1;
+    }
+
+    // Generated docs of directories whose packages lost all their constraints
+    var removed: usize = 0;
+    var set = discovery.discover(arena, root, .{}) catch |err| {
+        cli_error.printFileError(err, root);
+        return err;
+    };
+    defer set.deinit();
+    for (set.walked.items) |dir| {
+        const package = if (dir.path.len == 0) "." else dir.path;
+        if (documented.contains(package)) continue;
+        const path = try package_docs.docPath(arena, root, package);
+        const current = try readDoc(arena, path) orelse continue;
+        if (!std.mem.startsWith(u8, current, package_docs.generated_marker)) continue;
+        if (check) {
+            stale += 1;
+            cli_error.printError("{s} documents a package without constraints", .{path});
+            continue;
+        }
+        if (verbose) cli_error.printInfo("Removing {s}", .{path});
+        try std.fs.cwd().deleteFile(path);
+        removed += 1;
+    }
+
+    if (check) {
+        if (stale > 0) {
+            cli_error.printInfo("Regenerate with: ananke constraints-doc {s}", .{constraints_path});
+            return error.ValidationFailed;
+        }
+        cli_error.printSuccess("Constraint docs of {d} packages are current", .{current_docs});
+        return;
+    }
+    cli_error.printSuccess("Constraint docs: {d} written, {d} current, {d} removed", .{ written, current_docs, removed });
+}
+
+/// Contents of a doc, or null when it does not exist
+fn readDoc(arena: std.mem.Allocator, path: []const u8) !?[]const u8 {
+    return std.fs.cwd().readFileAlloc(arena, path, 16 * 1024 * 1024) catch |err| switch (err) {
+        error.FileNotFound, error.NotDir => return null,
+        else => {
+            cli_error.printFileError(err, path);
+            return err;
+        },
+    };
+}
+
+/// Report strings from --messages or `[report] messages`; English when neither is set
+fn loadCatalog(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !messages.Catalog {
+    const path = parsed_args.getFlag("messages") orelse config.report_messages orelse return messages.Catalog.default();
+    return messages.Catalog.loadFile(allocator, path) catch |err| {
+        cli_error.printFileError(err, path);
+        return err;
+    };
+}
@@ -0,0 +1,186 @@
+// Coverage command - Report the share of constraints linked to tests, per package
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const results = @import("cli_results");
+const policy = @import("cli_policy");
+const test_coverage = @import("cli_test_coverage");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke coverage <constraints> [options]
+    \\
+    \\Report how many constraints of a JSON result file (`ananke extract
+    \\--format json`) are linked to at least one test, per package (a file's
+    \\directory). A constraint is linked when a test of its file mentions it
+    \\in an `ananke:covers <name>, ...` comment, or uses the function or type
+    \\declared around it. The tests of a file are the test files in its
+    \\directory and those elsewhere named after it (users_test.go,
+    \\test_users.py, users.spec.ts).
+    \\
+    \\Thresholds make the command a gate: each is `[<package>=]<percent>`, and
+    \\one without a package (or with `*`) applies to the whole run. They come
+    \\from --min, or from `coverage` under [enforce] in the config file.
+    \\
+    \\Arguments:
+    \\  <constraints>           Stored JSON result file
+    \\
+    \\Options:
+    \\  --root <dir>            Directory the result's file paths are relative to
+    \\                          (default: .)
+    \\  --min <thresholds>      Comma-separated thresholds, e.g. 60,internal/api=80
+    \\  --show-unlinked         List the constraints no test is linked to
+    \\  --format <fmt>          Output format: text, json (default: text)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\Exit codes:
+    \\  0   coverage meets every threshold
+    \\  5   a threshold is not met
+    \\  1   invalid arguments; 3 when the constraints file is missing
+    \\
+    \\Examples:
+    \\  ananke coverage constraints.json --show-unlinked
+    \\  ananke coverage constraints.json --min 60,internal/billing=90
+;
+
+const CoverageFormat = enum {
+    text,
+    json,
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const constraints_path = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <constraints>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const root = parsed_args.getFlagOr("root", ".");
+    const format_str = parsed_args.getFlagOr("format", "text");
+    const format = std.meta.stringToEnum(CoverageFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text or json)", .{format_str});
+        return error.InvalidArgument;
+    };
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var thresholds = std.ArrayList(policy.CoverageThreshold){};
+    if (parsed_args.getFlag("min")) |list| {
+        var parts = std.mem.splitScalar(u8, list, ',');
+        while (parts.next()) |part| {
+            if (std.mem.trim(u8, part, " ").len == 0) continue;
+            try thresholds.append(arena, try parseThreshold(part));
+        }
+    } else {
+        for (config.enforce_coverage) |text| try thresholds.append(arena, try parseThreshold(text));
+    }
+
+    var stored = try loadRun(allocator, constraints_path);
+    defer stored.deinit();
+    const constraints = stored.constraint_set.constraints.items;
+
+    // Test files, with paths relative to root like the result's
+    var set = discovery.discover(arena, root, .{}) catch |err| {
+        cli_error.printFileError(err, root);
+        return err;
+    };
+    defer set.deinit();
+    var tests = std.ArrayList(test_coverage.TestFile){};
+    for (set.files.items) |file| {
+        const rel = relativeTo(root, file.path);
+        if (!test_coverage.isTestFile(rel)) continue;
+        const source = std.fs.cwd().readFileAlloc(arena, file.path, extract.max_source_bytes) catch |err| {
+            if (verbose) cli_error.printWarning("Skipping {s}: {s}", .{ file.path, @errorName(err) });
+            continue;
+        };
+        try tests.append(arena, .{ .path = rel, .source = source });
+    }
+    if (verbose) cli_error.printInfo("Found {d} test files under {s}", .{ tests.items.len, root });
+
+    // Sources of the constrained files, to find what each constraint is in
+    var sources = std.StringHashMap([]const u8).init(arena);
+    for (constraints) |c| {
+        const path = trimDot(c.origin_file orelse continue);
+        if (sources.contains(path)) continue;
+        const full = try std.fs.path.join(arena, &.{ root, path });
+        const source = std.fs.cwd().readFileAlloc(arena, full, extract.max_source_bytes) catch |err| {
+            if (verbose) cli_error.printWarning("Cannot read {s}: {s}; linking its constraints by name only", .{ full, @errorName(err) });
+            continue;
+        };
+        try sources.put(path, source);
+    }
+
+    const coverage = try test_coverage.compute(arena, constraints, tests.items, &sources);
+    const output_text = switch (format) {
+        .text => try test_coverage.formatText(allocator, coverage, parsed_args.hasFlag("show-unlinked")),
+        .json => try test_coverage.formatJson(allocator, coverage),
+    };
+    defer allocator.free(output_text);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+
+    var failed: usize = 0;
+    for (thresholds.items) |threshold| {
+        const label = threshold.package orelse "all packages";
+        const percent = if (threshold.package) |name|
+            (coverage.find(name) orelse {
+                cli_error.printWarning("Threshold {s}: no constraints in {s}", .{ threshold.text, name });
+                continue;
+            }).percent()
+        else
+            coverage.percent();
+        if (percent < threshold.min) {
+            failed += 1;
+            cli_error.printError("Constraint coverage of {s} is {d:.1}%, below {d:.1}%", .{ label, percent, threshold.min });
+        }
+    }
+    if (failed > 0) {
+        cli_error.printInfo("Link tests with an `{s} <name>` comment, or use --show-unlinked to see what is missing", .{test_coverage.covers_marker});
+        return error.ValidationFailed;
+    }
+    if (thresholds.items.len > 0) {
+        cli_error.printSuccess("All {d} coverage thresholds hold ({d:.1}% overall)", .{ thresholds.items.len, coverage.percent() });
+    }
+}
+
+fn parseThreshold(text: []const u8) !policy.CoverageThreshold {
+    return policy.parseCoverageThreshold(text) catch {
+        cli_error.printError("Invalid coverage threshold: {s} (expected [<package>=]<percent>)", .{text});
+        return error.InvalidArgument;
+    };
+}
+
+fn loadRun(allocator: std.mem.Allocator, path: []const u8) !results.ResultFile {
+    return results.ResultFile.loadFile(allocator, path) catch |err| {
+        if (err == results.ResultError.InvalidResultFile) {
+            cli_error.printError("Not an ananke JSON result file: {s}", .{path});
+            return error.InvalidArgument;
+        }
+        cli_error.printFileError(err, path);
+        return err;
+    };
+}
+
+/// `path` from a walk of `root`, made relative to it
+fn relativeTo(root: []const u8, path: []const u8) []const u8 {
+    const base = std.mem.trimRight(u8, root, "/");
+    if (base.len == 0 or std.mem.eql(u8, base, ".")) return path;
+    if (std.mem.startsWith(u8, path, base) and path.len > base.len and path[base.len] == '/') return path[base.len + 1 ..];
+    return path;
+}
+
+fn trimDot(path: []const u8) []const u8 {
+    var rel = path;
+    while (std.mem.startsWith(u8, rel, "./")) rel = rel[2..];
+    return rel;
+}
@@ -0,0 +1,898 @@
+// Daemon command - Keep extraction state warm and serve requests over a local socket
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const output = @import("cli_output");
+const discovery = @import("cli_discovery");
+const cache_store = @import("cli_cache_store");
+const constraint_diff = @import("cli_constraint_diff");
+const incremental = @import("cli_incremental");
+const warm_state = @import("cli_warm_state");
+const results = @import("cli_results");
+const metrics = @import("cli_metrics");
+const version = @import("cli_version");
+const extract = @import("cli/commands/extract");
+const query = @import("cli/commands/query");
+
+const constraint = ananke.types.constraint;
+
+pub const usage =
+    \\Usage: ananke daemon [path] [options]
+    \\
+    \\Keep the extraction of a directory in memory, watch it for changes, and
+    \\answer requests from editors and CI jobs over a local socket. The engine,
+    \\cache, and discovery snapshot stay warm between requests; an edited file is
+    \\re-extracted once, and every other file is merged from the cache.
+    \\
+    \\Arguments:
+    \\  [path]                  Directory to watch (default: .)
+    \\
+    \\Options:
+    \\  --socket <path>         Unix socket to listen on (default: <cache-dir>/daemon.sock)
+    \\  --poll <ms>             How often to check the tree for changes (default: 1000)
+    \\  --metrics-port <n>      Serve Prometheus metrics at http://<host>:<n>/metrics
+    \\  --metrics-host <addr>   Address for --metrics-port (default: 127.0.0.1)
+    \\  --send <json>           Send one request to a running daemon and print the response
+    \\  --language, --lang <l>  Only extract files in this language
+    \\  --exclude <globs>       Comma-separated .gitignore-style patterns to skip
+    \\  --no-gitignore          Do not honor .gitignore files
+    \\  --no-default-excludes   Do not skip .git/, node_modules/, vendor/, zig-out/, .zig-cache/
+    \\  --confidence <min>      Minimum confidence threshold (0.0-1.0, default: 0.5)
+    \\  --jobs, -j <n>          Extraction workers (default: number of CPUs)
+    \\  --cache-dir <dir>       Extraction cache directory (default: .ananke-cache)
+    \\  --remote-cache <url>    Shared cache behind the local one (see `ananke extract`)
+    \\  --remote-cache-mode <m> read (default) or read-write
+    \\  --cache-max-size <size> Cache size limit (see `ananke extract`)
+    \\  --verbose, -v           Report every rebuild
+    \\  --help, -h              Show this help message
+    \\
+    \\Protocol:
+    \\  Each connection carries one request: a JSON object on one line. The daemon
+    \\  answers with one JSON object, {"ok": true, ...} or {"ok": false, "error": ...},
+    \\  and closes the connection. Requests see the tree as of the moment they
+    \\  arrive; changes are picked up before answering.
+    \\
+    \\  {"method": "status"}                        Files, constraints, and generation
+    \\  {"method": "extract", "path": "src/api"}     Constraints of a file or directory
+    \\  {"method": "extract", "path": "src/a.go", "source": "..."}
+    \\                                              Constraints of an unsaved buffer
+    \\  {"method": "query", "filter": {"kind": "security", "text": "token"}}
+    \\                                              Filter constraints; keys are the
+    \\                                              flags of `ananke query`
+    \\  {"method": "diff", "base": "constraints.json"}
+    \\                                              Changes since a saved result file
+    \\  {"method": "diff", "path": "src/a.go", "source": "..."}
+    \\                                              Changes an unsaved buffer would make
+    \\  {"method": "metrics"}                       Cache hits, misses, writes, and
+    \\                                              evictions: since start, of the
+    \\                                              last rebuild, and over all runs
+    \\  {"method": "refresh"}                       Rescan now
+    \\  {"method": "shutdown"}                      Stop the daemon
+    \\
+    \\Examples:
+    \\  ananke daemon
+    \\  ananke daemon src --poll 250
+    \\  ananke daemon --metrics-port 9464
+    \\  ananke daemon --send '{"method": "query", "filter": {"severity": "error"}}'
+    \\  echo '{"method": "status"}' | nc -U .ananke-cache/daemon.sock
+;
+
+pub const default_poll_ms: u32 = 1000;
+pub const socket_name = "daemon.sock";
+/// Largest request accepted; an unsaved buffer is at most an extractable file
+const max_request_bytes = extract.max_source_bytes * 2;
+
+pub const Method = enum {
+    status,
+    extract,
+    query,
+    diff,
+    metrics,
+    refresh,
+    shutdown,
+};
+
+pub const Request = struct {
+    method: []const u8,
+    /// File or directory the request is limited to, relative to the working directory
+    path: ?[]const u8 = null,
+    /// Unsaved contents of `path`
+    source: ?[]const u8 = null,
+    /// Language of `source` when its path does not tell
+    language: ?[]const u8 = null,
+    /// Result file to compare against (diff)
+    base: ?[]const u8 = null,
+    /// Flags of `ananke query` without the leading dashes (query)
+    filter: ?std.json.ArrayHashMap([]const u8) = null,
+};
+
+/// Request and rebuild counters exported on /metrics
+pub const Stats = struct {
+    requests: std.EnumArray(Method, u64) = .initFill(0),
+    failures: std.EnumArray(Method, u64) = .initFill(0),
+    /// Requests that were not valid JSON or named no known method
+    malformed: u64 = 0,
+    /// Connections accepted and not yet answered
+    queue_depth: usize = 0,
+    rebuilds: u64 = 0,
+    rebuild_failures: u64 = 0,
+    /// Files read and extracted by rebuilds; files merged from the cache are not counted
+    files_extracted: u64 = 0,
+    bytes_extracted: u64 = 0,
+    rebuild_ns: u64 = 0,
+
+    pub fn record(self: *Stats, method: Method, ok: bool) void {
+        self.requests.getPtr(method).* += 1;
+        if (!ok) self.failures.getPtr(method).* += 1;
+    }
+};
+
+/// Parse one request line. Strings live in `arena`.
+pub fn parseRequest(arena: std.mem.Allocator, line: []const u8) !struct { Method, Request } {
+    const request = std.json.parseFromSliceLeaky(Request, arena, line, .{
+        .ignore_unknown_fields = true,
+        .allocate = .alloc_always,
+    }) catch return error.InvalidRequest;
+    const method = std.meta.stringToEnum(Method, request.method) orelse return error.UnknownMethod;
+    return .{ method, request };
+}
+
+/// Whether a constraint from `file` belongs to a request limited to `scope`:
+/// the file itself or anything below the directory
+pub fn inScope(file: []const u8, scope: ?[]const u8) bool {
+    const wanted = std.mem.trimRight(u8, incremental.normalize(scope orelse return true), "/");
+    if (wanted.len == 0 or std.mem.eql(u8, wanted, ".")) return true;
+    const path = incremental.normalize(file);
+    if (!std.mem.startsWith(u8, path, wanted)) return false;
+    return path.len == wanted.len or path[wanted.len] == '/';
+}
+
+/// What the daemon knows about one discovered file
+const Tracked = struct {
+    mtime: i128,
+    size: u64,
+    /// Cache entry holding the file's constraints as of `mtime`
+    entry: incremental.Entry,
+};
+
+/// Warm extraction state shared by the socket server and `ananke mcp`
+pub const Daemon = struct {
+    allocator: std.mem.Allocator,
+    root: []const u8,
+    options: extract.Options,
+    discovery_options: discovery.Options,
+    engine: *ananke.Ananke,
+    cache: *cache_store.Cache,
+    /// Constraints of the whole tree as of `generation`
+    result: *extract.Result,
+    /// Sources read for `result`, which borrows them
+    sources: std.ArrayList([]u8) = .{},
+    /// Files of `result` by path; strings live in `tracked_arena`
+    tracked: std.StringHashMapUnmanaged(Tracked) = .{},
+    tracked_arena: std.heap.ArenaAllocator,
+    /// Bumped whenever `result` is rebuilt, so clients can tell stale answers
+    generation: u64 = 0,
+    /// Cache counters since the daemon started, and of the last rebuild
+    served: cache_store.Counters = .{},
+    last_rebuild: cache_store.Counters = .{},
+    stats: Stats = .{},
+    stopping: bool = false,
+
+    /// Start with an empty snapshot; the first `refresh` extracts the tree
+    pub fn init(
+        allocator: std.mem.Allocator,
+        root: []const u8,
+        options: extract.Options,
+        discovery_options: discovery.Options,
+        engine: *ananke.Ananke,
+        cache: *cache_store.Cache,
+    ) !Daemon {
+        const result = try allocator.create(extract.Result);
+        result.* = extract.Result.init(allocator);
+        return .{
+            .allocator = allocator,
+            .root = root,
+            .options = options,
+            .discovery_options = discovery_options,
+            .engine = engine,
+            .cache = cache,
+            .result = result,
+            .tracked_arena = std.heap.ArenaAllocator.init(allocator),
+        };
+    }
+
+    pub fn deinit(self: *Daemon) void {
+        self.result.deinit();
+        self.allocator.destroy(self.result);
+        for (self.sources.items) |source| self.allocator.free(source);
+        self.sources.deinit(self.allocator);
+        self.tracked_arena.deinit();
+    }
+
+    /// Discover the tree, reusing the warm-start snapshot while no
+    /// directory or .gitignore changed
+    fn discover(self: *Daemon) !discovery.FileSet {
+        const key = warm_state.discoveryKey(version.VERSION, self.root, self.discovery_options);
+        if (try warm_state.loadDiscovery(self.allocator, self.cache.dir, &key, self.root, self.discovery_options.use_gitignore)) |set| {
+            return set;
+        }
+        var set = try discovery.discover(self.allocator, self.root, self.discovery_options);
+        errdefer set.deinit();
+        warm_state.saveDiscovery(self.allocator, self.cache.dir, &key, &set) catch {};
+        return set;
+    }
+
+    /// Bring `result` up to date with the tree. Files whose size and mtime
+    /// are unchanged are merged from their cache entries without being read.
+    /// Returns whether anything changed.
+    pub fn refresh(self: *Daemon) !bool {
+        errdefer self.stats.rebuild_failures += 1;
+        var timer = try std.time.Timer.start();
+        var set = try self.discover();
+        defer set.deinit();
+
+        var arena_state = std.heap.ArenaAllocator.init(self.allocator);
+        var owned = true;
+        defer if (owned) arena_state.deinit();
+        const arena = arena_state.allocator();
+
+        var carried = std.ArrayList(Tracked){};
+        var inputs = std.ArrayList(discovery.DiscoveredFile){};
+        var changed = self.generation == 0 or set.files.items.len != self.tracked.count();
+        for (set.files.items) |file| {
+            const path = try arena.dupe(u8, file.path);
+            const language = try arena.dupe(u8, file.language);
+            if (self.tracked.get(file.path)) |known| {
+                const stat = std.fs.cwd().statFile(file.path) catch null;
+                if (stat != null and stat.?.mtime == known.mtime and stat.?.size == known.size and
+                    std.mem.eql(u8, known.entry.language, file.language))
+                {
+                    var kept = known;
+                    kept.entry.path = path;
+                    kept.entry.language = language;
+                    if (known.entry.content_hash) |hash| kept.entry.content_hash = try arena.dupe(u8, hash);
+                    try carried.append(arena, kept);
+                    continue;
+                }
+            }
+            changed = true;
+            try inputs.append(arena, .{ .path = path, .language = language });
+        }
+        if (!changed) return false;
+
+        const result = try self.allocator.create(extract.Result);
+        result.* = extract.Result.init(self.allocator);
+        var result_owned = true;
+        defer if (result_owned) {
+            result.deinit();
+            self.allocator.destroy(result);
+        };
+        result.cache = self.cache;
+        result.generated = self.options.generated;
+        result.limits = self.options.limits;
+        result.pipeline_memory = self.options.pipeline_memory;
+
+        var tracked = std.StringHashMapUnmanaged(Tracked){};
+        for (carried.items) |file| {
+            const entry = self.cache.load(&file.entry.key) orelse {
+                // Evicted or cleared since; read the file again
+                try inputs.append(arena, .{ .path = file.entry.path, .language = file.entry.language });
+                continue;
+            };
+            try result.addCached(file.entry, entry);
+            try tracked.put(arena, file.entry.path, file);
+        }
+
+        var sources = std.ArrayList([]u8){};
+        defer if (result_owned) {
+            for (sources.items) |source| self.allocator.free(source);
+            sources.deinit(self.allocator);
+        };
+        var files = std.ArrayList(discovery.SourceFile){};
+        defer files.deinit(self.allocator);
+        var stats = std.ArrayList(std.fs.File.Stat){};
+        defer stats.deinit(self.allocator);
+        var large = std.ArrayList(discovery.DiscoveredFile){};
+        defer large.deinit(self.allocator);
+        var bytes_read: u64 = 0;
+        for (inputs.items) |input| {
+            // Stat before reading: a write in between shows up as a change next time
+            const stat = std.fs.cwd().statFile(input.path) catch |err| {
+                cli_error.printWarning("Skipping {s}: {s}", .{ input.path, @errorName(err) });
+                continue;
+            };
+            const source = std.fs.cwd().readFileAlloc(self.allocator, input.path, extract.max_source_bytes) catch |err| {
+                if (err == error.FileTooBig) {
+                    bytes_read += stat.size;
+                    try large.append(self.allocator, input);
+                    try tracked.put(arena, input.path, .{ .mtime = stat.mtime, .size = stat.size, .entry = .{
+                        .key = @splat('0'),
+                        .path = input.path,
+                        .language = input.language,
+                        .line_count = 0,
+                    } });
+                } else {
+                    cli_error.printWarning("Skipping {s}: {s}", .{ input.path, @errorName(err) });
+                }
+                continue;
+            };
+            sources.append(self.allocator, source) catch |err| {
+                self.allocator.free(source);
+                return err;
+            };
+            try files.append(self.allocator, .{ .path = input.path, .language = input.language, .source = source });
+            try stats.append(self.allocator, stat);
+            bytes_read += source.len;
+        }
+
+        try result.addAll(self.engine, files.items, self.options.concurrency.analyze);
+        for (large.items) |input| {
+            result.addStreamed(self.engine, input) catch |err| {
+                cli_error.printWarning("Skipping {s}: {s}", .{ input.path, @errorName(err) });
+            };
+        }
+
+        var infos = std.StringHashMap(usize).init(arena);
+        for (result.files.items, 0..) |info, i| try infos.put(info.path, i);
+        for (files.items, stats.items) |file, stat| {
+            // Files without an entry (skipped, unwritable) are read again on the next rebuild
+            var entry = incremental.Entry{
+                .key = result.cacheKey(file),
+                .path = file.path,
+                .language = file.language,
+                .line_count = 0,
+            };
+            if (infos.get(file.path)) |i| {
+                entry.line_count = result.files.items[i].line_count;
+                if (result.files.items[i].content_hash) |hash| entry.content_hash = try arena.dupe(u8, hash);
+            }
+            try tracked.put(arena, file.path, .{ .mtime = stat.mtime, .size = stat.size, .entry = entry });
+        }
+        _ = result.filterConfidence(self.options.confidence_threshold);
+
+        self.cache.recordRun() catch {};
+        self.last_rebuild = self.cache.run;
+        self.served.add(self.cache.run);
+        if (self.options.verbose) {
+            cli_error.printInfo("Generation {d}: {d} files, {d} re-extracted, {d} cache hits", .{
+                self.generation + 1,
+                tracked.count(),
+                files.items.len + large.items.len,
+                self.cache.run.hits,
+            });
+        }
+        self.cache.run = .{};
+
+        self.deinit();
+        self.result = result;
+        self.sources = sources;
+        self.tracked = tracked;
+        self.tracked_arena = arena_state;
+        self.generation += 1;
+        self.stats.rebuilds += 1;
+        self.stats.files_extracted += files.items.len + large.items.len;
+        self.stats.bytes_extracted += bytes_read;
+        self.stats.rebuild_ns += timer.read();
+        result_owned = false;
+        owned = false;
+        return true;
+    }
+
+    /// Extract an unsaved buffer with the warm engine. The result borrows
+    /// `source` and `path`.
+    fn extractBuffer(self: *Daemon, path: []const u8, language: ?[]const u8, source: []const u8) !*extract.Result {
+        const result = try self.allocator.create(extract.Result);
+        errdefer self.allocator.destroy(result);
+        result.* = extract.Result.init(self.allocator);
+        errdefer result.deinit();
+        result.cache = self.cache;
+        result.generated = self.options.generated;
+        result.limits = self.options.limits;
+        try result.add(self.engine, .{
+            .path = path,
+            .language = language orelse discovery.detectLanguage(path),
+            .source = source,
+        });
+        _ = result.filterConfidence(self.options.confidence_threshold);
+        self.served.add(self.cache.run);
+        self.cache.run = .{};
+        return result;
+    }
+
+    /// Constraints of the current snapshot within `scope`
+    fn scoped(self: *Daemon, arena: std.mem.Allocator, scope: ?[]const u8) !ananke.ConstraintSet {
+        var set = ananke.ConstraintSet.init(arena, "code_constraints");
+        for (self.result.constraint_set.constraints.items) |c| {
+            if (inScope(c.origin_file orelse "", scope)) try set.constraints.append(arena, c);
+        }
+        return set;
+    }
+
+    /// Constraints of an unsaved buffer, copied into `arena`
+    fn bufferConstraints(self: *Daemon, arena: std.mem.Allocator, request: Request, source: []const u8) !ananke.ConstraintSet {
+        const result = try self.extractBuffer(request.path orelse return error.MissingPath, request.language, source);
+        defer {
+            result.deinit();
+            self.allocator.destroy(result);
+        }
+        var set = ananke.ConstraintSet.init(arena, "code_constraints");
+        for (result.constraint_set.constraints.items) |c| {
+            var copy = c;
+            copy.name = try arena.dupe(u8, c.name);
+            copy.description = try arena.dupe(u8, c.description);
+            if (c.origin_file) |file| copy.origin_file = try arena.dupe(u8, file);
+            try set.constraints.append(arena, copy);
+        }
+        return set;
+    }
+
+    /// Constraints an extract request asks for: of its unsaved buffer, or of
+    /// the snapshot within its path. They live in `arena` or the snapshot.
+    pub fn extractConstraints(self: *Daemon, arena: std.mem.Allocator, request: Request) !ananke.ConstraintSet {
+        if (request.source) |source| return self.bufferConstraints(arena, request, source);
+        return self.scoped(arena, request.path);
+    }
+
+    /// Constraints of the snapshot a query request matches
+    pub fn queryConstraints(self: *Daemon, arena: std.mem.Allocator, request: Request) !ananke.ConstraintSet {
+        var flags = args_mod.Args.init(arena);
+        if (request.filter) |fields| {
+            var it = fields.map.iterator();
+            while (it.next()) |entry| try flags.flags.put(entry.key_ptr.*, entry.value_ptr.*);
+        }
+        const filter = try query.parseFilter(flags);
+        var set = ananke.ConstraintSet.init(arena, "code_constraints");
+        for (self.result.constraint_set.constraints.items) |c| {
+            if (inScope(c.origin_file orelse "", request.path) and filter.matches(c)) try set.constraints.append(arena, c);
+        }
+        return set;
+    }
+
+    pub const DiffAnswer = struct {
+        diff: constraint_diff.Diff,
+        before_label: []const u8,
+        after_label: []const u8,
+    };
+
+    /// Changes a diff request asks for: from the file on disk to its unsaved
+    /// buffer, or from a saved result file to the snapshot. Everything lives
+    /// in `arena` or the snapshot.
+    pub fn diffConstraints(self: *Daemon, arena: std.mem.Allocator, request: Request) !DiffAnswer {
+        const after = try self.scoped(arena, request.path);
+        if (request.source) |source| {
+            const buffer = try self.bufferConstraints(arena, request, source);
+            return .{
+                .diff = try constraint_diff.compute(arena, after.constraints.items, buffer.constraints.items),
+                .before_label = "saved",
+                .after_label = "buffer",
+            };
+        }
+        const base_path = request.base orelse return error.MissingBase;
+        const base = try results.ResultFile.loadFile(arena, base_path);
+        var before = std.ArrayList(constraint.Constraint){};
+        for (base.constraint_set.constraints.items) |c| {
+            if (inScope(c.origin_file orelse "", request.path)) try before.append(arena, c);
+        }
+        return .{
+            .diff = try constraint_diff.compute(arena, before.items, after.constraints.items),
+            .before_label = base_path,
+            .after_label = "working tree",
+        };
+    }
+
+    /// Answer one request line; the response lives in `arena`
+    fn respond(self: *Daemon, arena: std.mem.Allocator, line: []const u8) ![]const u8 {
+        const method, const request = parseRequest(arena, line) catch |err| {
+            self.stats.malformed += 1;
+            return err;
+        };
+        const text = self.answer(arena, method, request) catch |err| {
+            self.stats.record(method, false);
+            return err;
+        };
+        self.stats.record(method, true);
+        return text;
+    }
+
+    /// Answer one parsed request; the response lives in `arena`
+    pub fn answer(self: *Daemon, arena: std.mem.Allocator, method: Method, request: Request) ![]const u8 {
+        if (method == .shutdown) {
+            self.stopping = true;
+            return "{\"ok\": true}";
+        }
+        _ = try self.refresh();
+
+        var text = std.ArrayList(u8){};
+        const writer = text.writer(arena);
+        try writer.print("{{\"ok\": true, \"generation\": {d}", .{self.generation});
+        switch (method) {
+            .status, .refresh => {
+                try writer.writeAll(", \"root\": \"");
+                try output.writeJsonEscaped(writer, self.root);
+                try writer.print("\", \"files\": {d}, \"constraints\": {d}", .{
+                    self.tracked.count(),
+                    self.result.constraint_set.constraints.items.len,
+                });
+            },
+            .extract => {
+                const set = try self.extractConstraints(arena, request);
+                try writer.print(", \"result\": {s}", .{try output.formatJson(arena, set)});
+            },
+            .query => {
+                const set = try self.queryConstraints(arena, request);
+                try writer.print(", \"result\": {s}", .{try output.formatJson(arena, set)});
+            },
+            .diff => {
+                const changes = try self.diffConstraints(arena, request);
+                try writer.print(", \"diff\": {s}", .{try constraint_diff.formatJson(arena, changes.diff, changes.before_label, changes.after_label)});
+            },
+            .metrics => {
+                try writer.writeAll(", \"cache\": {\"since_start\": ");
+                try writeMetrics(writer, self.served);
+                try writer.writeAll(", \"last_rebuild\": ");
+                try writeMetrics(writer, self.last_rebuild);
+                try writer.writeAll(", \"totals\": ");
+                try writeMetrics(writer, try cache_store.readCounters(arena, self.cache.dir));
+                try writer.writeAll("}");
+            },
+            .shutdown => unreachable,
+        }
+        try writer.writeAll("}\n");
+        return text.items;
+    }
+
+    fn handle(self: *Daemon, connection: std.net.Server.Connection) !void {
+        var arena_state = std.heap.ArenaAllocator.init(self.allocator);
+        defer arena_state.deinit();
+        const arena = arena_state.allocator();
+
+        var recv_buffer: [8192]u8 = undefined;
+        var send_buffer: [8192]u8 = undefined;
+        var connection_reader = connection.stream.reader(&recv_buffer);
+        var connection_writer = connection.stream.writer(&send_buffer);
+
+        var line = std.Io.Writer.Allocating.init(arena);
+        _ = try connection_reader.interface().streamDelimiterLimit(&line.writer, '\n', .limited(max_request_bytes));
+
+        const response = self.respond(arena, line.written()) catch |err| blk: {
+            if (self.options.verbose) cli_error.printWarning("Request failed: {s}", .{@errorName(err)});
+            break :blk try std.fmt.allocPrint(arena, "{{\"ok\": false, \"error\": \"{s}\"}}\n", .{@errorName(err)});
+        };
+        try connection_writer.interface.writeAll(response);
+        try connection_writer.interface.flush();
+    }
+
+    /// Counters and gauges in the Prometheus text format; the text lives in `arena`
+    pub fn formatPrometheus(self: *Daemon, arena: std.mem.Allocator) ![]const u8 {
+        var text = std.ArrayList(u8){};
+        const writer = text.writer(arena);
+
+        try metrics.writeHeader(writer, "ananke_requests_total", .counter, "Requests answered, by method");
+        for (std.enums.values(Method)) |method| {
+            try metrics.writeSample(writer, "ananke_requests_total", &.{.{ .name = "method", .value = @tagName(method) }}, self.stats.requests.get(method));
+        }
+        try metrics.writeHeader(writer, "ananke_request_failures_total", .counter, "Requests answered with an error, by method");
+        for (std.enums.values(Method)) |method| {
+            try metrics.writeSample(writer, "ananke_request_failures_total", &.{.{ .name = "method", .value = @tagName(method) }}, self.stats.failures.get(method));
+        }
+        try metrics.writeMetric(writer, "ananke_malformed_requests_total", .counter, "Requests that were not valid JSON or named no known method", self.stats.malformed);
+        try metrics.writeMetric(writer, "ananke_queue_depth", .gauge, "Connections accepted and waiting to be answered", self.stats.queue_depth);
+
+        try metrics.writeMetric(writer, "ananke_rebuilds_total", .counter, "Snapshot rebuilds after the tree changed", self.stats.rebuilds);
+        try metrics.writeMetric(writer, "ananke_rebuild_failures_total", .counter, "Rebuilds that failed", self.stats.rebuild_failures);
+        try metrics.writeMetric(writer, "ananke_extracted_files_total", .counter, "Files read and extracted by rebuilds", self.stats.files_extracted);
+        try metrics.writeMetric(writer, "ananke_extracted_bytes_total", .counter, "Source bytes read and extracted by rebuilds", self.stats.bytes_extracted);
+        try metrics.writeMetric(writer, "ananke_rebuild_seconds_total", .counter, "Time spent rebuilding", @as(f64, @floatFromInt(self.stats.rebuild_ns)) / std.time.ns_per_s);
+
+        try metrics.writeMetric(writer, "ananke_generation", .gauge, "Generation of the current snapshot", self.generation);
+        try metrics.writeMetric(writer, "ananke_files", .gauge, "Files in the current snapshot", self.tracked.count());
+        try metrics.writeMetric(writer, "ananke_constraints", .gauge, "Constraints in the current snapshot", self.result.constraint_set.constraints.items.len);
+
+        // Lookups since the last rebuild are added at the next one
+        try metrics.writeMetric(writer, "ananke_cache_hits_total", .counter, "Cache lookups that found an entry", self.served.hits);
+        try metrics.writeMetric(writer, "ananke_cache_misses_total", .counter, "Cache lookups that found no entry", self.served.misses);
+        try metrics.writeMetric(writer, "ananke_cache_writes_total", .counter, "Entries written to the cache", self.served.writes);
+        try metrics.writeMetric(writer, "ananke_cache_evictions_total", .counter, "Entries evicted to keep the cache within its size limit", self.served.evictions);
+        try metrics.writeMetric(writer, "ananke_cache_hit_ratio", .gauge, "Fraction of cache lookups that found an entry", self.served.hitRate());
+        return text.items;
+    }
+
+    /// Answer every scrape waiting on the non-blocking metrics listener
+    fn serveScrapes(self: *Daemon, server: *std.net.Server) void {
+        var scrapes: [8]std.net.Server.Connection = undefined;
+        for (scrapes[0..acceptPending(server, &scrapes)]) |connection| {
+            defer connection.stream.close();
+            self.serveMetrics(connection) catch {};
+        }
+    }
+
+    fn serveMetrics(self: *Daemon, connection: std.net.Server.Connection) !void {
+        var recv_buffer: [8192]u8 = undefined;
+        var send_buffer: [8192]u8 = undefined;
+        var connection_reader = connection.stream.reader(&recv_buffer);
+        var connection_writer = connection.stream.writer(&send_buffer);
+        var http_server = std.http.Server.init(connection_reader.interface(), &connection_writer.interface);
+        var request = try http_server.receiveHead();
+        try respondMetrics(self, &request);
+    }
+};
+
+/// Answer `request` with the daemon's metrics if it asks for /metrics, and
+/// with 404 otherwise
+pub fn respondMetrics(state: *Daemon, request: *std.http.Server.Request) !void {
+    var arena_state = std.heap.ArenaAllocator.init(state.allocator);
+    defer arena_state.deinit();
+
+    if (!isMetricsTarget(request.head.target)) {
+        return request.respond("Not found\n", .{ .status = .not_found, .keep_alive = false });
+    }
+    if (request.head.method != .GET and request.head.method != .HEAD) {
+        return request.respond("Use GET\n", .{ .status = .method_not_allowed, .keep_alive = false });
+    }
+    try request.respond(try state.formatPrometheus(arena_state.allocator()), .{
+        .keep_alive = false,
+        .extra_headers = &.{.{ .name = "content-type", .value = metrics.content_type }},
+    });
+}
+
+pub fn isMetricsTarget(target: []const u8) bool {
+    const path = target[0 .. std.mem.indexOfScalar(u8, target, '?') orelse target.len];
+    return std.mem.eql(u8, path, "/metrics");
+}
+
+/// Accept every connection already waiting on the non-blocking `listener`,
+/// up to the capacity of `queue`. Returns how many were accepted.
+pub fn acceptPending(listener: *std.net.Server, queue: []std.net.Server.Connection) usize {
+    var count: usize = 0;
+    while (count < queue.len) {
+        queue[count] = listener.accept() catch |err| {
+            if (err != error.WouldBlock) cli_error.printWarning("Accept failed: {s}", .{@errorName(err)});
+            break;
+        };
+        count += 1;
+    }
+    return count;
+}
+
+/// Connections answered per wakeup at most; the rest wait for the next poll
+pub const max_pending = 64;
+
+fn writeMetrics(writer: anytype, counters: cache_store.Counters) !void {
+    try writer.print("{{\"hits\": {d}, \"misses\": {d}, \"writes\": {d}, \"evictions\": {d}, \"hit_rate\": {d:.4}}}", .{
+        counters.hits,
+        counters.misses,
+        counters.writes,
+        counters.evictions,
+        counters.hitRate(),
+    });
+}
+
+/// Send one request to the daemon listening on `socket_path` and print its answer
+fn send(allocator: std.mem.Allocator, socket_path: []const u8, request: []const u8) !void {
+    const stream = std.net.connectUnixSocket(socket_path) catch |err| {
+        cli_error.printError("No daemon listening on {s}: {s}", .{ socket_path, @errorName(err) });
+        cli_error.printInfo("Start one with `ananke daemon`", .{});
+        return err;
+    };
+    defer stream.close();
+
+    var send_buffer: [8192]u8 = undefined;
+    var stream_writer = stream.writer(&send_buffer);
+    try stream_writer.interface.writeAll(std.mem.trimRight(u8, request, "\n"));
+    try stream_writer.interface.writeAll("\n");
+    try stream_writer.interface.flush();
+
+    var recv_buffer: [8192]u8 = undefined;
+    var stream_reader = stream.reader(&recv_buffer);
+    const response = try stream_reader.interface().allocRemaining(allocator, .unlimited);
+    defer allocator.free(response);
+    try extract.writeOutput(null, response);
+}
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const options = try extract.Options.parse(parsed_args, config);
+    const cache_dir = options.cache_dir orelse {
+        cli_error.printError("The daemon merges unchanged files from the cache and cannot be combined with --no-cache or --use-claude", .{});
+        return error.InvalidArgument;
+    };
+    var socket_buf: [std.fs.max_path_bytes]u8 = undefined;
+    const socket_path = parsed_args.getFlag("socket") orelse
+        try std.fmt.bufPrint(&socket_buf, "{s}/{s}", .{ cache_dir, socket_name });
+
+    if (parsed_args.getFlag("send")) |request| return send(allocator, socket_path, request);
+
+    const root = parsed_args.getPositional(0) catch ".";
+    const root_stat = std.fs.cwd().statFile(root) catch |err| {
+        cli_error.printFileError(err, root);
+        return err;
+    };
+    if (root_stat.kind != .directory) {
+        cli_error.printError("Not a directory: {s}", .{root});
+        return error.InvalidArgument;
+    }
+    const poll_ms = try parsed_args.getFlagInt("poll", u32) orelse default_poll_ms;
+    const metrics_port = try parsed_args.getFlagInt("metrics-port", u16);
+    const metrics_host = parsed_args.getFlagOr("metrics-host", "127.0.0.1");
+    const metrics_address = if (metrics_port) |port| std.net.Address.parseIp(metrics_host, port) catch {
+        cli_error.printError("Invalid address: {s}", .{metrics_host});
+        return error.InvalidArgument;
+    } else null;
+
+    var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+
+    var claude_client: ?ananke.api.claude.ClaudeClient = null;
+    defer if (claude_client) |*client| client.deinit();
+    var engine = try extract.initEngine(allocator, config, options, &claude_client);
+    defer engine.deinit();
+
+    var cache = extract.openCache(allocator, options) orelse return error.InvalidArgument;
+    defer cache.close();
+
+    const address = std.net.Address.initUnix(socket_path) catch |err| {
+        cli_error.printError("Invalid socket path {s}: {s}", .{ socket_path, @errorName(err) });
+        return error.InvalidArgument;
+    };
+    // A socket file nobody answers on is left over from a daemon that died
+    if (std.net.connectUnixSocket(socket_path)) |stream| {
+        stream.close();
+        cli_error.printError("A daemon is already listening on {s}", .{socket_path});
+        return error.InvalidArgument;
+    } else |_| {
+        std.fs.cwd().deleteFile(socket_path) catch {};
+    }
+    // Non-blocking, so every waiting connection can be counted in the queue depth
+    var listener = address.listen(.{ .force_nonblocking = true }) catch |err| {
+        cli_error.printError("Cannot listen on {s}: {s}", .{ socket_path, @errorName(err) });
+        return err;
+    };
+    defer {
+        listener.deinit();
+        std.fs.cwd().deleteFile(socket_path) catch {};
+    }
+    var metrics_listener = if (metrics_address) |metrics_addr| metrics_addr.listen(.{ .reuse_address = true, .force_nonblocking = true }) catch |err| {
+        cli_error.printError("Cannot listen on {s}:{d}: {s}", .{ metrics_host, metrics_port.?, @errorName(err) });
+        return err;
+    } else null;
+    defer if (metrics_listener) |*server| server.deinit();
+
+    var daemon = try Daemon.init(allocator, root, options, .{
+        .excludes = excludes.items,
+        .use_gitignore = config.extract_gitignore and !parsed_args.hasFlag("no-gitignore"),
+        .use_default_excludes = !parsed_args.hasFlag("no-default-excludes"),
+        .language = options.language,
+    }, &engine, &cache);
+    defer daemon.deinit();
+
+    var spinner = output.Spinner.init("Extracting constraints...");
+    _ = try daemon.refresh();
+    spinner.finish("Extraction complete");
+    cli_error.printSuccess("Watching {s} ({d} files, {d} constraints) on {s}", .{
+        root,
+        daemon.tracked.count(),
+        daemon.result.constraint_set.constraints.items.len,
+        socket_path,
+    });
+    if (metrics_port) |port| cli_error.printInfo("Metrics at http://{s}:{d}/metrics", .{ metrics_host, port });
+    cli_error.printInfo("Press Ctrl-C to stop", .{});
+
+    var pending: [max_pending]std.net.Server.Connection = undefined;
+    while (!daemon.stopping) {
+        var fds = [_]std.posix.pollfd{
+            .{ .fd = listener.stream.handle, .events = std.posix.POLL.IN, .revents = 0 },
+            .{ .fd = if (metrics_listener) |server| server.stream.handle else -1, .events = std.posix.POLL.IN, .revents = 0 },
+        };
+        const ready = try std.posix.poll(&fds, @intCast(poll_ms));
+        if (ready == 0) {
+            // Idle: pick up edits now so the next request finds them extracted
+            _ = daemon.refresh() catch |err| {
+                cli_error.printWarning("Rebuild failed: {s}", .{@errorName(err)});
+            };
+            continue;
+        }
+        const count = if (fds[0].revents != 0) acceptPending(&listener, &pending) else 0;
+        daemon.stats.queue_depth = count;
+        // Scrapes are answered between requests, so they see the queue drain
+        if (metrics_listener) |*server| daemon.serveScrapes(server);
+        for (pending[0..count]) |connection| {
+            defer connection.stream.close();
+            daemon.stats.queue_depth -= 1;
+            // A client that disconnects mid-request only loses its own answer
+            if (!daemon.stopping) daemon.handle(connection) catch {};
+            if (metrics_listener) |*server| daemon.serveScrapes(server);
+        }
+    }
+    cli_error.printInfo("Daemon stopped", .{});
+}
+
+test "daemon requests and scopes" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const method, const request = try parseRequest(arena, "{\"method\": \"query\", \"path\": \"src\", \"filter\": {\"kind\": \"security\"}, \"id\": 7}");
+    try testing.expectEqual(Method.query, method);
+    try testing.expectEqualStrings("src", request.path.?);
+    try testing.expectEqualStrings("security", request.filter.?.map.get("kind").?);
+    try testing.expectError(error.UnknownMethod, parseRequest(arena, "{\"method\": \"compile\"}"));
+    try testing.expectError(error.InvalidRequest, parseRequest(arena, "not json"));
+
+    try testing.expect(inScope("./src/api/a.go", "src/api/"));
+    try testing.expect(inScope("src/api/a.go", "src/api/a.go"));
+    try testing.expect(!inScope("src/apiv2/a.go", "src/api"));
+    try testing.expect(inScope("lib/b.go", null));
+    try testing.expect(inScope("lib/b.go", "."));
+}
+
+test "daemon metrics count requests by method" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+
+    // Metrics read only the snapshot and counters, never the engine or cache
+    var state = try Daemon.init(testing.allocator, ".", .{}, .{}, undefined, undefined);
+    defer state.deinit();
+    state.stats.record(.query, true);
+    state.stats.record(.query, false);
+    state.stats.queue_depth = 3;
+
+    const text = try state.formatPrometheus(arena_state.allocator());
+    try testing.expect(std.mem.indexOf(u8, text, "\nananke_requests_total{method=\"query\"} 2\n") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "\nananke_request_failures_total{method=\"query\"} 1\n") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "\nananke_requests_total{method=\"extract\"} 0\n") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "\nananke_queue_depth 3\n") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "# TYPE ananke_cache_hit_ratio gauge\n") != null);
+}
+
+test "daemon counts cache lookups per rebuild and since start" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.makePath("repo");
+    try tmp.dir.writeFile(.{ .sub_path = "repo/a.py", .data = "def a(x: int) -> int:\n    return x\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "repo/b.py", .data = "def b(y: str) -> str:\n    return y\n" });
+    const root = try tmp.dir.realpathAlloc(arena, "repo");
+    const cache_path = try std.fs.path.join(arena, &.{ try tmp.dir.realpathAlloc(arena, "."), "cache" });
+
+    var engine = try ananke.Ananke.init(allocator);
+    defer engine.deinit();
+    var cache = try cache_store.Cache.open(allocator, cache_path);
+    defer cache.close();
+    var state = try Daemon.init(allocator, root, .{}, .{ .use_gitignore = false }, &engine, &cache);
+    defer state.deinit();
+
+    // Both files are new
+    try testing.expect(try state.refresh());
+    try testing.expectEqual(@as(u64, 0), state.last_rebuild.hits);
+    try testing.expectEqual(@as(u64, 2), state.last_rebuild.misses);
+
+    // An edit re-extracts b.py and merges a.py from its entry
+    try tmp.dir.writeFile(.{ .sub_path = "repo/b.py", .data = "def b(y: str) -> str:\n    return y.strip()\n" });
+    try testing.expect(try state.refresh());
+    try testing.expectEqual(@as(u64, 1), state.last_rebuild.hits);
+    try testing.expectEqual(@as(u64, 1), state.last_rebuild.misses);
+    try testing.expectEqual(@as(u64, 1), state.served.hits);
+    try testing.expectEqual(@as(u64, 3), state.served.misses);
+    try testing.expect(!try state.refresh());
+
+    const text = try state.formatPrometheus(arena);
+    try testing.expect(std.mem.indexOf(u8, text, "\nananke_cache_hits_total 1\n") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "\nananke_cache_misses_total 3\n") != null);
+    const runs = try cache_store.readRuns(allocator, cache.dir);
+    defer allocator.free(runs);
+    try testing.expectEqual(@as(usize, 2), runs.len);
+    try testing.expectEqual(@as(u64, 1), runs[1].hits);
+}
@@ -0,0 +1,293 @@
+// Diff command - Compare constraints extracted at two git refs
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const output = @import("cli_output");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const git = @import("cli_git");
+const discovery = @import("cli_discovery");
+const constraint_diff = @import("cli_constraint_diff");
+const code_weakening = @import("cli_code_weakening");
+const github = @import("cli_github");
+const extract = @import("cli/commands/extract");
+const extract_cache = @import("cli_extract_cache");
+const extract_pipeline = @import("cli_extract_pipeline");
+
+pub const usage =
+    \\Usage: ananke diff <refA> <refB> [path] [options]
+    \\
+    \\Extract constraints at two git refs and report what changed between them:
+    \\constraints that were added, removed, strengthened, or weakened.
+    \\Constraints are matched by file, kind, and name, so code that only moved
+    \\lines is reported as unchanged.
+    \\
+    \\Changed Go files are also compared directly for edits that weaken a check
+    \\the old code enforced: a validate tag or Validate-method check dropped or
+    \\its bound relaxed, a field made optional, a Validate call removed (e.g.
+    \\the one before repo.Create), or a handler that stopped checking the
+    \\request method. These are reported apart, as high-severity findings.
+    \\
+    \\Arguments:
+    \\  <refA>                  Base commit-ish (e.g. origin/main, v1.2.0)
+    \\  <refB>                  Commit-ish to compare against the base (e.g. HEAD)
+    \\  [path]                  Limit the comparison to a file or directory
+    \\
+    \\Options:
+    \\  --format <fmt>          Output format: text, json, markdown, github (default: text)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --confidence <min>      Minimum confidence threshold (0.0-1.0, default: 0.5)
+    \\  --language, --lang <l>  Only compare files in this language
+    \\  --exclude <globs>       Comma-separated .gitignore-style patterns to skip
+    \\  --no-default-excludes   Do not skip .git/, node_modules/, vendor/, zig-out/, .zig-cache/
+    \\  --use-claude            Enable Claude API for semantic analysis
+    \\  --jobs, -j <n>          Extraction workers (default: number of CPUs)
+    \\  --analyze-jobs <n>      Override --jobs for extraction only
+    \\  --no-cache              Re-extract every file instead of reusing cached results
+    \\  --remote-cache <url>    Shared cache behind the local one (see `ananke extract`)
+    \\  --remote-cache-mode <m> read (default) or read-write
+    \\  --cache-max-size <size> Cache size limit (see `ananke extract`)
+    \\  --github-pr <n>         Post the github review to pull request n (needs GITHUB_TOKEN)
+    \\  --github-repo <o/r>     Repository of the pull request (default: $GITHUB_REPOSITORY)
+    \\  --fail-on-weakening     Exit with status 5 when changed code weakens a check
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\A constraint is stronger when its severity, priority, or confidence rises.
+    \\
+    \\The github format is the payload of GitHub's create-review endpoint: a
+    \\comment on each change that sits on a line changed between the refs,
+    \\anchored to that line, and a summary listing the rest. Post it with
+    \\--github-pr, or with `gh api repos/{owner}/{repo}/pulls/<n>/reviews --input <file>`.
+    \\$GITHUB_API_URL selects a GitHub Enterprise server.
+    \\
+    \\Examples:
+    \\  ananke diff origin/main HEAD
+    \\  ananke diff v1.2.0 v1.3.0 src/api --format markdown -o constraint-changes.md
+    \\  ananke diff main feature/auth --format json
+    \\  ananke diff origin/main HEAD --github-pr 42
+;
+
+const DiffFormat = enum {
+    text,
+    json,
+    markdown,
+    github,
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const before_rev = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <refA>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const after_rev = parsed_args.getPositional(1) catch {
+        cli_error.printError("Missing required argument: <refB>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const pathspec: ?[]const u8 = parsed_args.getPositional(2) catch null;
+
+    const github_pr = try parsed_args.getFlagInt("github-pr", u64);
+    const format_str = parsed_args.getFlagOr("format", if (github_pr != null) "github" else "text");
+    const format = std.meta.stringToEnum(DiffFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text, json, markdown, or github)", .{format_str});
+        return error.InvalidArgument;
+    };
+    if (github_pr != null and format != .github) {
+        cli_error.printError("--github-pr posts the github format and cannot be combined with --format {s}", .{format_str});
+        return error.InvalidArgument;
+    }
+
+    const confidence_threshold = try parsed_args.getFlagFloat("confidence", f32) orelse config.confidence_threshold;
+    if (confidence_threshold < 0.0 or confidence_threshold > 1.0) {
+        cli_error.printError("Confidence threshold must be between 0.0 and 1.0", .{});
+        return error.InvalidArgument;
+    }
+
+    const use_claude = parsed_args.hasFlag("use-claude") or config.use_claude;
+    const options = extract.Options{
+        .confidence_threshold = confidence_threshold,
+        .use_claude = use_claude,
+        .verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v"),
+        .language = parsed_args.getFlag("language") orelse parsed_args.getFlag("lang"),
+        .concurrency = try extract_pipeline.parseConcurrency(parsed_args, config, use_claude),
+        .cache_dir = extract_cache.parseCacheDir(parsed_args, config, use_claude),
+        .remote_cache = try extract_cache.parseRemoteCache(parsed_args, config, use_claude),
+        .remote_cache_mode = try extract_cache.parseRemoteCacheMode(parsed_args, config),
+        .cache_max_bytes = try extract_cache.parseCacheMaxSize(parsed_args, config),
+    };
+
+    var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+    const discovery_options = discovery.Options{
+        .excludes = excludes.items,
+        .use_default_excludes = !parsed_args.hasFlag("no-default-excludes"),
+        .language = options.language,
+    };
+
+    var before_snapshot = try loadSnapshot(allocator, before_rev, pathspec, discovery_options);
+    defer before_snapshot.deinit();
+    var after_snapshot = try loadSnapshot(allocator, after_rev, pathspec, discovery_options);
+    defer after_snapshot.deinit();
+
+    if (options.verbose) {
+        cli_error.printInfo("Comparing {s} ({s}) with {s} ({s})", .{
+            before_rev,
+            before_snapshot.commit,
+            after_rev,
+            after_snapshot.commit,
+        });
+    }
+
+    var claude_client: ?ananke.api.claude.ClaudeClient = null;
+    defer if (claude_client) |*client| client.deinit();
+    var engine = try extract.initEngine(allocator, config, options, &claude_client);
+    defer engine.deinit();
+
+    var before_result = extract.Result.init(allocator);
+    defer before_result.deinit();
+    var after_result = extract.Result.init(allocator);
+    defer after_result.deinit();
+
+    // Files unchanged between the refs are extracted once and then hit the cache
+    var cache = extract.openCache(allocator, options);
+    defer if (cache) |*c| c.close();
+    if (cache) |*c| {
+        before_result.cache = c;
+        after_result.cache = c;
+    }
+
+    var spinner = output.Spinner.init("Extracting constraints...");
+    try before_result.addAll(&engine, before_snapshot.files.items, options.concurrency.analyze);
+    try after_result.addAll(&engine, after_snapshot.files.items, options.concurrency.analyze);
+    spinner.finish("Extraction complete");
+    if (cache) |*c| extract_cache.finish(c, options.verbose);
+
+    _ = before_result.filterConfidence(options.confidence_threshold);
+    _ = after_result.filterConfidence(options.confidence_threshold);
+
+    var diff = try constraint_diff.compute(
+        allocator,
+        before_result.constraint_set.constraints.items,
+        after_result.constraint_set.constraints.items,
+    );
+    defer diff.deinit();
+
+    var weakening_arena = std.heap.ArenaAllocator.init(allocator);
+    defer weakening_arena.deinit();
+    diff.weakened_code = try detectWeakenings(weakening_arena.allocator(), before_snapshot.files.items, after_snapshot.files.items);
+
+    const output_text = switch (format) {
+        .text => try constraint_diff.formatText(allocator, diff, before_rev, after_rev),
+        .json => try constraint_diff.formatJson(allocator, diff, before_rev, after_rev),
+        .markdown => try constraint_diff.formatMarkdown(allocator, diff, before_rev, after_rev),
+        .github => blk: {
+            var arena_state = std.heap.ArenaAllocator.init(allocator);
+            defer arena_state.deinit();
+            const changed = github.changedLines(arena_state.allocator(), before_snapshot.commit, after_snapshot.commit, pathspec) catch |err| {
+                cli_error.printError("Failed to list changed lines: {s}", .{@errorName(err)});
+                return err;
+            };
+            break :blk try github.formatReview(allocator, diff, &changed, after_snapshot.commit, before_rev, after_rev);
+        },
+    };
+    defer allocator.free(output_text);
+
+    const output_file = parsed_args.getFlag("output") orelse parsed_args.getFlag("o");
+    if (github_pr) |number| {
+        if (output_file != null) try extract.writeOutput(output_file, output_text);
+        if (diff.changes.items.len == 0 and diff.weakened_code.len == 0) {
+            cli_error.printInfo("No constraint changes; no review posted", .{});
+            return;
+        }
+        try postReview(allocator, parsed_args, number, output_text);
+    } else {
+        try extract.writeOutput(output_file, output_text);
+    }
+
+    if (diff.weakened_code.len > 0) {
+        cli_error.printWarning("{d} checks weakened in changed code", .{diff.weakened_code.len});
+        if (parsed_args.hasFlag("fail-on-weakening")) return error.ValidationFailed;
+    }
+}
+
+/// Weakened checks in the Go files that changed between the snapshots
+fn detectWeakenings(
+    arena: std.mem.Allocator,
+    before: []const discovery.SourceFile,
+    after: []const discovery.SourceFile,
+) ![]const constraint_diff.CodeWeakening {
+    var old_sources = std.StringHashMap([]const u8).init(arena);
+    for (before) |file| try old_sources.put(file.path, file.source);
+
+    var found = std.ArrayList(constraint_diff.CodeWeakening){};
+    for (after) |file| {
+        if (!std.mem.eql(u8, file.language, "go") or std.mem.endsWith(u8, file.path, "_test.go")) continue;
+        const old = old_sources.get(file.path) orelse continue;
+        if (std.mem.eql(u8, old, file.source)) continue;
+        try found.appendSlice(arena, try code_weakening.detect(arena, file.path, old, file.source));
+    }
+    return found.items;
+}
+
+/// Post a github-format review, with the repository and token from the
+/// environment GitHub Actions sets up
+fn postReview(allocator: std.mem.Allocator, parsed_args: args_mod.Args, number: u64, payload: []const u8) !void {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const repository = parsed_args.getFlag("github-repo") orelse try getEnv(arena, "GITHUB_REPOSITORY") orelse {
+        cli_error.printError("--github-pr needs the repository: pass --github-repo owner/name or set GITHUB_REPOSITORY", .{});
+        return error.MissingArgument;
+    };
+    const token = try getEnv(arena, "GITHUB_TOKEN") orelse {
+        cli_error.printError("--github-pr needs a token with pull request write access in GITHUB_TOKEN", .{});
+        return error.MissingArgument;
+    };
+    const api_url = try getEnv(arena, "GITHUB_API_URL") orelse github.default_api_url;
+
+    const status = github.postReview(allocator, api_url, repository, number, token, payload) catch |err| {
+        cli_error.printError("Failed to post the review to {s}: {s}", .{ api_url, @errorName(err) });
+        return err;
+    };
+    if (status != 200) {
+        cli_error.printError("GitHub rejected the review for {s}#{d} (HTTP {d})", .{ repository, number, status });
+        if (status == 422) cli_error.printInfo("Check that both refs are commits of the pull request", .{});
+        return error.ReviewRejected;
+    }
+    cli_error.printSuccess("Posted review to {s}#{d}", .{ repository, number });
+}
+
+fn getEnv(arena: std.mem.Allocator, name: []const u8) !?[]const u8 {
+    return std.process.getEnvVarOwned(arena, name) catch |err| switch (err) {
+        error.EnvironmentVariableNotFound => null,
+        else => return err,
+    };
+}
+
+fn loadSnapshot(
+    allocator: std.mem.Allocator,
+    rev: []const u8,
+    pathspec: ?[]const u8,
+    options: discovery.Options,
+) !git.Snapshot {
+    return git.loadSnapshot(allocator, rev, pathspec, options, extract.max_source_bytes) catch |err| {
+        switch (err) {
+            git.GitError.GitNotFound => cli_error.printError("git executable not found in PATH", .{}),
+            git.GitError.InvalidRevision => cli_error.printError("Invalid revision: {s}", .{rev}),
+            git.GitError.UnknownRevision => {
+                cli_error.printError("Unknown revision: {s}", .{rev});
+                cli_error.printInfo("Run this command inside a git repository with the ref fetched", .{});
+            },
+            else => cli_error.printError("Failed to read {s} from git: {s}", .{ rev, @errorName(err) }),
+        }
+        return err;
+    };
+}
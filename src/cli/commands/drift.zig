@@ -0,0 +1,114 @@
+// Drift command - Per-package constraint drift between two extraction runs
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const results = @import("cli_results");
+const drift_report = @import("cli_drift_report");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke drift <before> <after> [options]
+    \\
+    \\Compare two JSON result files (`ananke extract --format json`), such as the
+    \\runs of two releases, and report per package (file directory) which
+    \\constraints were added, removed, strengthened, or weakened. Constraints are
+    \\matched by file, kind, and name, like `ananke diff`; a constraint is
+    \\stronger when its severity, priority, or confidence rises.
+    \\
+    \\Arguments:
+    \\  <before>                Result file of the earlier run
+    \\  <after>                 Result file of the later run
+    \\
+    \\Options:
+    \\  --format <fmt>          Output format: json, html, text (default: json)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --help, -h              Show this help message
+    \\
+    \\JSON output follows docs/schemas/drift-report.schema.json; its "schema"
+    \\field names the version.
+    \\
+    \\Examples:
+    \\  ananke drift results/v1.4.json results/v1.5.json -o drift.json
+    \\  ananke drift results/v1.4.json results/v1.5.json --format html -o drift.html
+;
+
+const DriftFormat = enum {
+    json,
+    html,
+    text,
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const before_path = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <before>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const after_path = parsed_args.getPositional(1) catch {
+        cli_error.printError("Missing required argument: <after>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const format_str = parsed_args.getFlagOr("format", "json");
+    const format = std.meta.stringToEnum(DriftFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected json, html, or text)", .{format_str});
+        return error.InvalidArgument;
+    };
+
+    var before = try loadRun(allocator, before_path);
+    defer before.deinit();
+    var after = try loadRun(allocator, after_path);
+    defer after.deinit();
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const report = try drift_report.compute(
+        arena,
+        try normalized(arena, before.constraint_set.constraints.items),
+        try normalized(arena, after.constraint_set.constraints.items),
+        before_path,
+        after_path,
+    );
+    const output_text = switch (format) {
+        .json => try drift_report.formatJson(allocator, report),
+        .html => try drift_report.formatHtml(allocator, report),
+        .text => try drift_report.formatText(allocator, report),
+    };
+    defer allocator.free(output_text);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+}
+
+fn loadRun(allocator: std.mem.Allocator, path: []const u8) !results.ResultFile {
+    return results.ResultFile.loadFile(allocator, path) catch |err| {
+        if (err == results.ResultError.InvalidResultFile) {
+            cli_error.printError("Not an ananke JSON result file: {s}", .{path});
+            return error.InvalidArgument;
+        }
+        cli_error.printFileError(err, path);
+        return err;
+    };
+}
+
+/// Copies without a leading "./" on file paths, which depends on how each
+/// run was invoked rather than on the code
+fn normalized(arena: std.mem.Allocator, constraints: []const ananke.Constraint) ![]const ananke.Constraint {
+    const copies = try arena.dupe(ananke.Constraint, constraints);
+    for (copies) |*c| {
+        if (c.origin_file) |file| {
+            var rel = file;
+            while (std.mem.startsWith(u8, rel, "./")) rel = rel[2..];
+            c.origin_file = rel;
+        }
+    }
+    return copies;
+}
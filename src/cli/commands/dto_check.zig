@@ -0,0 +1,146 @@
+// Dto-check command - Compare the field rules of the same DTO across services in Go and TypeScript
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const go_rules = @import("cli_go_rules");
+const ts_rules = @import("cli_ts_rules");
+const dto_consistency = @import("cli_dto_consistency");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke dto-check <service> <service>... [options]
+    \\
+    \\Find DTOs that several services declare separately and report the field
+    \\rules they disagree on. Each argument is the directory (or file) of one
+    \\service. DTOs are Go structs with validate or binding tags or Validate
+    \\methods, TypeScript classes with class-validator decorators, and zod
+    \\object schemas.
+    \\
+    \\DTOs of different services are paired by name, ignoring suffixes such as
+    \\Request, Dto, and Schema (CreateUserRequest ~ CreateUserDto), or else by
+    \\sharing most of their fields. Fields are aligned by name, ignoring case,
+    \\underscores, and json tags. Mismatched length and value bounds, required
+    \\fields, email, patterns, and option lists are reported, as are validated
+    \\fields only one side has.
+    \\
+    \\Arguments:
+    \\  <service>               Directory or file of a service (at least two)
+    \\
+    \\Options:
+    \\  --format <fmt>          Output format: text, json (default: text)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --show-consistent       Also list the pairs whose rules agree
+    \\  --exclude <globs>       Comma-separated .gitignore-style patterns to skip
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\Exit codes:
+    \\  0   the paired DTOs agree
+    \\  5   mismatched rules were found
+    \\  1   invalid arguments
+    \\
+    \\Examples:
+    \\  ananke dto-check services/users-api web/src
+    \\  ananke dto-check api/ web/ mobile/src --format json -o dto-report.json
+;
+
+const DtoCheckFormat = enum {
+    text,
+    json,
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const services = parsed_args.positional.items;
+    if (services.len < 2) {
+        cli_error.printError("Need at least two services to compare", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    }
+    const format_str = parsed_args.getFlagOr("format", "text");
+    const format = std.meta.stringToEnum(DtoCheckFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text or json)", .{format_str});
+        return error.InvalidArgument;
+    };
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+
+    var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var dtos = std.ArrayList(dto_consistency.Dto){};
+    for (services) |path| {
+        const service = std.mem.trimRight(u8, path, "/");
+        const before = dtos.items.len;
+        try collectDtos(arena, service, excludes.items, &dtos);
+        if (verbose) cli_error.printInfo("{s}: {d} DTOs with rules", .{ service, dtos.items.len - before });
+    }
+
+    const report = try dto_consistency.compare(arena, dtos.items);
+    const output_text = switch (format) {
+        .text => try dto_consistency.formatText(allocator, report, parsed_args.hasFlag("show-consistent")),
+        .json => try dto_consistency.formatJson(allocator, report),
+    };
+    defer allocator.free(output_text);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+
+    const mismatches = report.mismatchCount();
+    if (mismatches > 0) {
+        cli_error.printError("{d} rule mismatches between paired DTOs", .{mismatches});
+        return error.ValidationFailed;
+    }
+}
+
+fn isTestFile(path: []const u8) bool {
+    const suffixes = [_][]const u8{ "_test.go", ".d.ts", ".spec.ts", ".test.ts", ".spec.tsx", ".test.tsx" };
+    for (suffixes) |suffix| {
+        if (std.mem.endsWith(u8, path, suffix)) return true;
+    }
+    return false;
+}
+
+/// The DTOs with rules in the Go and TypeScript files of a service
+fn collectDtos(arena: std.mem.Allocator, service: []const u8, excludes: []const []const u8, dtos: *std.ArrayList(dto_consistency.Dto)) !void {
+    const stat = std.fs.cwd().statFile(service) catch |err| {
+        cli_error.printFileError(err, service);
+        return err;
+    };
+    var set = if (stat.kind == .directory)
+        try discoverService(arena, service, excludes)
+    else
+        discovery.FileSet.init(arena);
+    defer set.deinit();
+    if (stat.kind != .directory) try set.addFile(service, discovery.detectLanguage(service));
+
+    for (set.files.items) |file| {
+        const is_go = std.mem.eql(u8, file.language, "go");
+        if (!is_go and !std.mem.eql(u8, file.language, "typescript")) continue;
+        if (isTestFile(file.path)) continue;
+        const source = std.fs.cwd().readFileAlloc(arena, file.path, extract.max_source_bytes) catch |err| {
+            cli_error.printFileError(err, file.path);
+            return err;
+        };
+        if (discovery.isGenerated(file.path, source)) continue;
+        const structs = if (is_go) (try go_rules.parse(arena, source)).structs else try ts_rules.parse(arena, source);
+        for (structs) |s| {
+            if (!s.hasChecks()) continue;
+            try dtos.append(arena, .{ .service = service, .file = file.path, .value = s });
+        }
+    }
+}
+
+fn discoverService(arena: std.mem.Allocator, root: []const u8, excludes: []const []const u8) !discovery.FileSet {
+    return discovery.discover(arena, root, .{ .excludes = excludes }) catch |err| {
+        cli_error.printFileError(err, root);
+        return err;
+    };
+}
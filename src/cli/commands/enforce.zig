@@ -0,0 +1,152 @@
+// Enforce command - Evaluate a change set against the team's constraint budgets
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const results = @import("cli_results");
+const constraint_diff = @import("cli_constraint_diff");
+const policy = @import("cli_policy");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke enforce <before> <after> [options]
+    \\
+    \\Compare two JSON result files (`ananke extract --format json`), typically
+    \\of a pull request's base and head, and check the constraint changes against
+    \\budgets. Budgets come from `budgets` under [enforce] in the config file, or
+    \\from --budget, and are written
+    \\
+    \\  <change>[:<kind>[:<severity>]]=<max>
+    \\
+    \\where <change> is added, removed, strengthened, or weakened, <kind> a
+    \\constraint kind (security, semantic, ...) or *, and <severity> error,
+    \\warning, info, or hint. Removed and weakened constraints are matched by
+    \\what they were before the change.
+    \\
+    \\Arguments:
+    \\  <before>                Result file of the base
+    \\  <after>                 Result file of the change
+    \\
+    \\Options:
+    \\  --budget <list>         Comma-separated budgets, replacing the configured ones
+    \\  --format <fmt>          Output format: text, json (default: text)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --help, -h              Show this help message
+    \\
+    \\Exit codes:
+    \\  0   every budget holds
+    \\  5   a budget is exceeded
+    \\  1   invalid arguments or budgets; 3 when a result file is missing
+    \\
+    \\Examples:
+    \\  ananke enforce base.json head.json
+    \\  ananke enforce base.json head.json --budget "removed:security:error=0,weakened=5"
+;
+
+const EnforceFormat = enum {
+    text,
+    json,
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const before_path = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <before>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const after_path = parsed_args.getPositional(1) catch {
+        cli_error.printError("Missing required argument: <after>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const format_str = parsed_args.getFlagOr("format", "text");
+    const format = std.meta.stringToEnum(EnforceFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text or json)", .{format_str});
+        return error.InvalidArgument;
+    };
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var budgets = std.ArrayList(policy.Budget){};
+    if (parsed_args.getFlag("budget")) |list| {
+        var parts = std.mem.splitScalar(u8, list, ',');
+        while (parts.next()) |part| {
+            if (std.mem.trim(u8, part, " ").len == 0) continue;
+            try budgets.append(arena, try parseBudget(part));
+        }
+    } else {
+        for (config.enforce_budgets) |text| try budgets.append(arena, try parseBudget(text));
+    }
+    if (budgets.items.len == 0) {
+        cli_error.printError("No budgets to enforce", .{});
+        cli_error.printInfo("Set `budgets` under [enforce] in the config file, or pass --budget", .{});
+        return error.MissingArgument;
+    }
+
+    var before = try loadRun(allocator, before_path);
+    defer before.deinit();
+    var after = try loadRun(allocator, after_path);
+    defer after.deinit();
+
+    var diff = try constraint_diff.compute(
+        allocator,
+        try normalized(arena, before.constraint_set.constraints.items),
+        try normalized(arena, after.constraint_set.constraints.items),
+    );
+    defer diff.deinit();
+
+    const outcomes = try policy.evaluate(arena, budgets.items, diff.changes.items);
+    const output_text = switch (format) {
+        .text => try policy.formatText(allocator, outcomes),
+        .json => try policy.formatJson(allocator, outcomes, before_path, after_path),
+    };
+    defer allocator.free(output_text);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+
+    const exceeded = policy.countExceeded(outcomes);
+    if (exceeded > 0) {
+        cli_error.printError("{d} of {d} budgets exceeded", .{ exceeded, outcomes.len });
+        return error.ValidationFailed;
+    }
+    cli_error.printSuccess("All {d} budgets hold ({d} constraint changes)", .{ outcomes.len, diff.changes.items.len });
+}
+
+fn parseBudget(text: []const u8) !policy.Budget {
+    return policy.parseBudget(text) catch {
+        cli_error.printError("Invalid budget: {s} (expected <change>[:<kind>[:<severity>]]=<max>)", .{text});
+        return error.InvalidArgument;
+    };
+}
+
+fn loadRun(allocator: std.mem.Allocator, path: []const u8) !results.ResultFile {
+    return results.ResultFile.loadFile(allocator, path) catch |err| {
+        if (err == results.ResultError.InvalidResultFile) {
+            cli_error.printError("Not an ananke JSON result file: {s}", .{path});
+            return error.InvalidArgument;
+        }
+        cli_error.printFileError(err, path);
+        return err;
+    };
+}
+
+/// Copies without a leading "./" on file paths, which depends on how each
+/// run was invoked rather than on the code
+fn normalized(arena: std.mem.Allocator, constraints: []const ananke.Constraint) ![]const ananke.Constraint {
+    const copies = try arena.dupe(ananke.Constraint, constraints);
+    for (copies) |*c| {
+        if (c.origin_file) |file| {
+            var rel = file;
+            while (std.mem.startsWith(u8, rel, "./")) rel = rel[2..];
+            c.origin_file = rel;
+        }
+    }
+    return copies;
+}
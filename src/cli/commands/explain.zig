@@ -0,0 +1,276 @@
+// Explain command - Show everything known about a single constraint
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const results = @import("cli_results");
+const result_index = @import("cli_result_index");
+
+const constraint = ananke.types.constraint;
+const patterns = ananke.clew.patterns;
+
+pub const usage =
+    \\Usage: ananke explain <constraint-id> <results> [options]
+    \\
+    \\Print the full record for one constraint from a stored result file: its
+    \\metadata, the source lines it was extracted from, the rule that produced it,
+    \\and guidance on how to remediate and verify it.
+    \\With a current index from `ananke index`, only that constraint is read.
+    \\
+    \\Arguments:
+    \\  <constraint-id>         Constraint id as shown in reports (e.g. 8127364512 or c-8127364512)
+    \\  <results>               Result file from `ananke extract --format json`, or "-" for stdin
+    \\
+    \\Options:
+    \\  --context <n>           Source lines shown around the evidence (default: 2)
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke extract src --format json -o constraints.json
+    \\  ananke explain 8127364512 constraints.json
+    \\  ananke extract api.go --format json | ananke explain c-8127364512 -
+;
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const id_arg = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <constraint-id>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const results_path = parsed_args.getPositional(1) catch {
+        cli_error.printError("Missing required argument: <results>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const id = results.parseId(id_arg) orelse {
+        cli_error.printError("Invalid constraint id: {s}", .{id_arg});
+        return error.InvalidArgument;
+    };
+    const context_lines = try parsed_args.getFlagInt("context", u32) orelse 2;
+
+    var arena = std.heap.ArenaAllocator.init(allocator);
+    defer arena.deinit();
+    var loaded: ?results.ResultFile = null;
+    defer if (loaded) |*result| result.deinit();
+
+    const found = if (try result_index.openCurrent(allocator, results_path)) |index| blk: {
+        defer index.close();
+        break :blk try index.find(arena.allocator(), id);
+    } else blk: {
+        loaded = results.ResultFile.loadFile(allocator, results_path) catch |err| {
+            if (err == results.ResultError.InvalidResultFile) {
+                cli_error.printError("Not an ananke JSON result file: {s}", .{results_path});
+                return error.InvalidArgument;
+            }
+            cli_error.printFileError(err, results_path);
+            return err;
+        };
+        break :blk loaded.?.findById(id);
+    };
+    const c = found orelse {
+        cli_error.printError("Constraint {d} not found in {s}", .{ id, results_path });
+        cli_error.printInfo("Ids change when a constraint's name, description, or kind changes; re-extract and retry", .{});
+        return error.InvalidArgument;
+    };
+
+    const text = try formatExplanation(allocator, c, context_lines);
+    defer allocator.free(text);
+    try std.fs.File.stdout().writeAll(text);
+}
+
+/// Render the record, evidence, producing rule, and guidance for a constraint
+pub fn formatExplanation(allocator: std.mem.Allocator, c: constraint.Constraint, context_lines: u32) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("Constraint {d}: {s}\n\n", .{ c.id, c.name });
+    try writer.print("  Kind:         {s}\n", .{@tagName(c.kind)});
+    try writer.print("  Severity:     {s}\n", .{severityLabel(c.severity)});
+    try writer.print("  Priority:     {s}\n", .{@tagName(c.priority)});
+    try writer.print("  Confidence:   {d:.2}\n", .{c.confidence});
+    try writer.print("  Source:       {s}\n", .{@tagName(c.source)});
+    try writer.print("  Enforcement:  {s}\n", .{@tagName(c.enforcement)});
+    try writer.print("  Frequency:    {d}\n", .{c.frequency});
+    if (c.origin_file) |file| {
+        if (c.origin_line) |line| {
+            try writer.print("  Location:     {s}:{d}\n", .{ file, line });
+        } else {
+            try writer.print("  Location:     {s}\n", .{file});
+        }
+    }
+    try writer.print("\nDescription\n  {s}\n", .{c.description});
+
+    try writer.writeAll("\nEvidence\n");
+    try writeEvidence(allocator, writer, c, context_lines);
+
+    try writer.writeAll("\nProduced by\n");
+    try writeRule(writer, c);
+
+    const guidance = guidanceFor(c.kind);
+    try writer.print("\nRemediation\n  {s}\n", .{guidance.remediation});
+    try writer.print("\nVerification\n  {s}\n", .{guidance.verification});
+    if (c.severity == .err) {
+        try writer.writeAll("  Errors block generation: constrained output that violates this is rejected.\n");
+    }
+
+    return list.toOwnedSlice(allocator);
+}
+
+fn severityLabel(severity: constraint.Severity) []const u8 {
+    return switch (severity) {
+        .err => "error",
+        .warning => "warning",
+        .info => "info",
+        .hint => "hint",
+    };
+}
+
+/// Print the source lines around the constraint's origin, if still on disk
+fn writeEvidence(allocator: std.mem.Allocator, writer: anytype, c: constraint.Constraint, context_lines: u32) !void {
+    const file = c.origin_file orelse {
+        try writer.writeAll("  No source location recorded.\n");
+        return;
+    };
+    const line = c.origin_line orelse {
+        try writer.print("  Extracted from {s} (no line recorded).\n", .{file});
+        return;
+    };
+
+    const source = std.fs.cwd().readFileAlloc(allocator, file, 10 * 1024 * 1024) catch {
+        try writer.print("  {s} is not readable from the current directory.\n", .{file});
+        return;
+    };
+    defer allocator.free(source);
+
+    const first = if (line > context_lines) line - context_lines else 1;
+    const last = line + context_lines;
+    var number: u32 = 1;
+    var lines = std.mem.splitScalar(u8, source, '\n');
+    var shown = false;
+    while (lines.next()) |text| : (number += 1) {
+        if (number < first) continue;
+        if (number > last) break;
+        const marker: []const u8 = if (number == line) ">" else " ";
+        try writer.print("  {s} {d:>5} | {s}\n", .{ marker, number, std.mem.trimRight(u8, text, "\r") });
+        shown = true;
+    }
+    if (!shown) {
+        try writer.print("  Line {d} is past the end of {s}; the file changed since extraction.\n", .{ line, file });
+    }
+}
+
+/// Describe the extractor that produced the constraint. Pattern constraints
+/// are named after the rule description, which identifies the rule.
+fn writeRule(writer: anytype, c: constraint.Constraint) !void {
+    if (c.source == .AST_Pattern) {
+        if (c.origin_file) |file| {
+            const language = discovery.detectLanguage(file);
+            if (patterns.getPatternsForLanguage(language)) |lang_patterns| {
+                inline for (std.meta.fields(patterns.LanguagePatterns)) |field| {
+                    for (@field(lang_patterns, field.name)) |rule| {
+                        if (rule.constraint_kind == c.kind and std.mem.eql(u8, rule.description, c.name)) {
+                            try writer.print("  Clew {s} pattern rule \"{s}\" (category {s})\n", .{ language, rule.description, field.name });
+                            try writer.writeAll("  Matches source text: ");
+                            try writeQuoted(writer, rule.pattern);
+                            try writer.writeAll("\n");
+                            return;
+                        }
+                    }
+                }
+            }
+        }
+    }
+
+    const description: []const u8 = switch (c.source) {
+        .AST_Pattern => "Clew tree-sitter analysis of the syntax tree",
+        .Type_System => "Clew type analysis of annotations and inferred types",
+        .Control_Flow => "Clew control flow analysis",
+        .Data_Flow => "Clew data flow analysis",
+        .Test_Mining => "Assertions mined from test code",
+        .Documentation => "Docstrings and comments",
+        .Telemetry => "Runtime telemetry",
+        .User_Defined => "A user-supplied constraint file",
+        .LLM_Analysis => "Semantic analysis by the configured LLM (--use-claude)",
+    };
+    try writer.print("  {s}\n", .{description});
+}
+
+fn writeQuoted(writer: anytype, s: []const u8) !void {
+    try writer.writeByte('"');
+    for (s) |ch| {
+        switch (ch) {
+            '\n' => try writer.writeAll("\\n"),
+            '\t' => try writer.writeAll("\\t"),
+            '"' => try writer.writeAll("\\\""),
+            else => try writer.writeByte(ch),
+        }
+    }
+    try writer.writeByte('"');
+}
+
+const Guidance = struct {
+    remediation: []const u8,
+    verification: []const u8,
+};
+
+fn guidanceFor(kind: constraint.ConstraintKind) Guidance {
+    return switch (kind) {
+        .syntactic => .{
+            .remediation = "Follow the structure and naming shown in the evidence; new code in this file should use the same form.",
+            .verification = "Re-run `ananke extract` on the file and confirm the constraint is still reported; lint and format checks should pass.",
+        },
+        .type_safety => .{
+            .remediation = "Keep explicit types at this boundary and handle absent values before use instead of widening or casting them away.",
+            .verification = "Type-check the project and add a test passing the empty/null case through this code path.",
+        },
+        .semantic => .{
+            .remediation = "Preserve the behaviour shown in the evidence (ordering, side effects, async boundaries) when changing this code.",
+            .verification = "Cover the behaviour with a test that fails if it changes, then compare `ananke diff` before and after the change.",
+        },
+        .architectural => .{
+            .remediation = "Keep dependencies flowing through the module boundary shown; do not import across layers to reach internals.",
+            .verification = "Check imports of the changed files and confirm `ananke diff` reports no removed architectural constraints.",
+        },
+        .operational => .{
+            .remediation = "Keep resource handling (allocation, cleanup, concurrency, timeouts) paired as in the evidence on every path, including errors.",
+            .verification = "Exercise the error paths under a leak or race detector and re-run benchmarks touching this code.",
+        },
+        .security => .{
+            .remediation = "Validate and sanitize untrusted input before it reaches this code, and keep authentication and authorization checks in place.",
+            .verification = "Add a negative test with malicious or unauthenticated input and confirm it is rejected; review `ananke diff` for weakened security constraints.",
+        },
+    };
+}
+
+test "explanation includes record, rule, and guidance" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const c = constraint.Constraint{
+        .id = 7,
+        .name = "Error return type",
+        .description = "Error return type detected at line 3 in go code",
+        .kind = .type_safety,
+        .severity = .info,
+        .origin_file = "does/not/exist.go",
+        .origin_line = 3,
+    };
+
+    const text = try formatExplanation(allocator, c, 2);
+    defer allocator.free(text);
+
+    try testing.expect(std.mem.startsWith(u8, text, "Constraint 7: Error return type"));
+    try testing.expect(std.mem.indexOf(u8, text, "Location:     does/not/exist.go:3") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "not readable from the current directory") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "Remediation\n") != null);
+}
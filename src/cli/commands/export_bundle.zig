@@ -0,0 +1,223 @@
+// Export-bundle command - Package a run for audit or reproduction
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const output = @import("cli_output");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const results = @import("cli_results");
+const archive = @import("cli_archive");
+const plan = @import("cli_plan");
+const cyclonedx = @import("cli_cyclonedx");
+const version = @import("cli_version");
+const lint_rules = @import("cli/commands/lint_rules");
+
+const tree_sitter = ananke.clew.tree_sitter;
+
+pub const usage =
+    \\Usage: ananke export-bundle <results> [options]
+    \\
+    \\Write a self-contained tar archive describing one extraction run, for audits
+    \\and for reproducing the run elsewhere. The archive holds:
+    \\
+    \\  manifest.json           Tool version, creation time, SHA-256 of every file,
+    \\                          and the version digest of each language's pattern rules
+    \\  results.json            The result file, unchanged
+    \\  ananke.toml             The effective configuration (API keys are never included)
+    \\  rules/...               Ariadne rule files passed with --rules
+    \\
+    \\Arguments:
+    \\  <results>               Result file from `ananke extract --format json`
+    \\
+    \\Options:
+    \\  --rules <paths>         Comma-separated rule files or directories (*.ariadne)
+    \\  --name <dir>            Top-level directory inside the archive (default: ananke-bundle)
+    \\  --output, -o <file>     Archive path (default: <name>.tar)
+    \\  --help, -h              Show this help message
+    \\
+    \\To reproduce a run, install the recorded tool version, copy ananke.toml to
+    \\.ananke.toml in the checkout, and rerun the extraction.
+    \\
+    \\Examples:
+    \\  ananke export-bundle constraints.json
+    \\  ananke export-bundle constraints.json --rules rules/ -o audit-2026-q3.tar
+;
+
+pub const bundle_version = 1;
+
+/// One file stored in the bundle, as recorded in the manifest
+pub const Member = struct {
+    /// Path inside the bundle directory
+    path: []const u8,
+    /// Where the file came from, if it was copied
+    source: ?[]const u8 = null,
+    data: []const u8,
+};
+
+/// Build manifest.json for `members` (which must start with the results file)
+pub fn formatManifest(
+    allocator: std.mem.Allocator,
+    members: []const Member,
+    constraint_count: usize,
+    created_at: i64,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\n  \"bundle_version\": {d},\n  \"tool_version\": \"{s}\",\n  \"created_at\": \"", .{
+        bundle_version,
+        version.VERSION,
+    });
+    try cyclonedx.writeIso8601(writer, created_at);
+    try writer.print("\",\n  \"constraints\": {d},\n  \"files\": [\n", .{constraint_count});
+    for (members, 0..) |member, i| {
+        var digest: [std.crypto.hash.sha2.Sha256.digest_length]u8 = undefined;
+        std.crypto.hash.sha2.Sha256.hash(member.data, &digest, .{});
+        try writer.writeAll("    {\"path\": \"");
+        try output.writeJsonEscaped(writer, member.path);
+        try writer.writeAll("\"");
+        if (member.source) |source| {
+            try writer.writeAll(", \"source\": \"");
+            try output.writeJsonEscaped(writer, source);
+            try writer.writeAll("\"");
+        }
+        try writer.print(", \"bytes\": {d}, \"sha256\": \"{s}\"}}", .{ member.data.len, std.fmt.bytesToHex(digest, .lower) });
+        if (i + 1 < members.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+
+    // Built-in extractor rules change between releases; the digest pins them
+    try writer.writeAll("  ],\n  \"extractors\": [\n");
+    const languages = std.enums.values(tree_sitter.Language);
+    for (languages, 0..) |language, i| {
+        const name = @tagName(language);
+        try writer.print("    {{\"language\": \"{s}\", \"pattern_rules\": {d}, \"rules_digest\": \"{s}\"}}", .{
+            name,
+            plan.ruleCount(name),
+            plan.rulesDigest(name),
+        });
+        if (i + 1 < languages.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("  ]\n}\n");
+
+    return list.toOwnedSlice(allocator);
+}
+
+/// Path for a copied rule file under rules/. Paths that would escape the
+/// bundle directory keep only their file name.
+fn rulePath(allocator: std.mem.Allocator, path: []const u8) ![]u8 {
+    var relative = path;
+    while (std.mem.startsWith(u8, relative, "./")) relative = relative[2..];
+    if (std.fs.path.isAbsolute(relative) or std.mem.indexOf(u8, relative, "..") != null) {
+        relative = std.fs.path.basename(relative);
+    }
+    return std.fmt.allocPrint(allocator, "rules/{s}", .{relative});
+}
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const results_path = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <results>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const name = parsed_args.getFlagOr("name", "ananke-bundle");
+    const output_path = parsed_args.getFlag("output") orelse parsed_args.getFlag("o") orelse
+        try std.fmt.allocPrint(arena, "{s}.tar", .{name});
+
+    const results_text = std.fs.cwd().readFileAlloc(arena, results_path, results.max_result_bytes) catch |err| {
+        cli_error.printFileError(err, results_path);
+        return err;
+    };
+    var result = results.ResultFile.parse(allocator, results_text) catch |err| {
+        if (err == results.ResultError.InvalidResultFile) {
+            cli_error.printError("Not an ananke JSON result file: {s}", .{results_path});
+            return error.InvalidArgument;
+        }
+        return err;
+    };
+    defer result.deinit();
+
+    var members = std.ArrayList(Member){};
+    try members.append(arena, .{ .path = "results.json", .source = results_path, .data = results_text });
+    try members.append(arena, .{ .path = "ananke.toml", .data = try config.formatToml(arena) });
+
+    if (parsed_args.getFlag("rules")) |list| {
+        var paths = std.ArrayList([]const u8){};
+        var it = std.mem.splitScalar(u8, list, ',');
+        while (it.next()) |path| {
+            const trimmed = std.mem.trim(u8, path, " \t");
+            if (trimmed.len > 0) try paths.append(arena, trimmed);
+        }
+        const rule_files = try lint_rules.collectRuleFiles(arena, paths.items);
+        for (rule_files.items) |path| {
+            const data = std.fs.cwd().readFileAlloc(arena, path, 16 * 1024 * 1024) catch |err| {
+                cli_error.printFileError(err, path);
+                return err;
+            };
+            try members.append(arena, .{ .path = try rulePath(arena, path), .source = path, .data = data });
+        }
+    }
+
+    const created_at = std.time.timestamp();
+    const manifest = try formatManifest(arena, members.items, result.constraint_set.constraints.items.len, created_at);
+
+    var tar = archive.TarWriter.init(allocator, created_at);
+    defer tar.deinit();
+    try tar.addFile(try std.fmt.allocPrint(arena, "{s}/manifest.json", .{name}), manifest);
+    for (members.items) |member| {
+        const path = try std.fmt.allocPrint(arena, "{s}/{s}", .{ name, member.path });
+        tar.addFile(path, member.data) catch |err| {
+            if (err == archive.ArchiveError.NameTooLong) {
+                cli_error.printError("Path too long for the archive: {s}", .{path});
+                return error.InvalidArgument;
+            }
+            return err;
+        };
+    }
+    const bytes = try tar.finish();
+    defer allocator.free(bytes);
+
+    std.fs.cwd().writeFile(.{ .sub_path = output_path, .data = bytes }) catch |err| {
+        cli_error.printFileError(err, output_path);
+        return err;
+    };
+    cli_error.printSuccess("Wrote {s} ({d} files, {d} constraints)", .{
+        output_path,
+        members.items.len + 1,
+        result.constraint_set.constraints.items.len,
+    });
+}
+
+test "manifest records hashes, tool version, and rule digests" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const members = [_]Member{
+        .{ .path = "results.json", .source = "out/constraints.json", .data = "{}" },
+        .{ .path = "rules/api.ariadne", .source = "./api.ariadne", .data = "" },
+    };
+    const manifest = try formatManifest(allocator, &members, 3, 1_700_000_000);
+    defer allocator.free(manifest);
+
+    try testing.expect(std.mem.indexOf(u8, manifest, "\"tool_version\": \"" ++ version.VERSION ++ "\"") != null);
+    try testing.expect(std.mem.indexOf(u8, manifest, "\"created_at\": \"2023-11-14T22:13:20Z\"") != null);
+    // SHA-256 of the empty string
+    try testing.expect(std.mem.indexOf(u8, manifest, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855") != null);
+    try testing.expect(std.mem.indexOf(u8, manifest, "{\"language\": \"go\", \"pattern_rules\": ") != null);
+
+    const path = try rulePath(allocator, "../shared/api.ariadne");
+    defer allocator.free(path);
+    try testing.expectEqualStrings("rules/api.ariadne", path);
+}
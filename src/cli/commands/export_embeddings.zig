@@ -0,0 +1,197 @@
+// Export-embeddings command - Write constraint embeddings to a vector store
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const results = @import("cli_results");
+const embeddings = @import("cli_embeddings");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke export-embeddings <results> --store <store> [options]
+    \\
+    \\Embed the constraints of a JSON result file (from `ananke extract --format json`)
+    \\and write them to a vector store, so retrieval tooling can search constraints
+    \\by meaning. Each constraint is split into chunks that carry its name, kind,
+    \\severity, and location; records are keyed by constraint id, so exporting
+    \\again updates them in place.
+    \\
+    \\Stores:
+    \\  pgvector    SQL for `psql -f` that creates the table and upserts the rows
+    \\  chroma      Chroma server (default: http://localhost:8000)
+    \\  qdrant      Qdrant server (default: http://localhost:6333)
+    \\
+    \\Providers:
+    \\  openai      OpenAI-compatible /v1/embeddings API (key: OPENAI_API_KEY)
+    \\  ollama      Local Ollama server (default: http://localhost:11434)
+    \\  command     Command that reads {"model", "input"} JSON on stdin and writes
+    \\              {"embeddings": [[...], ...]} to stdout
+    \\
+    \\Options:
+    \\  --store <store>         pgvector, chroma, or qdrant
+    \\  --store-url <url>       Chroma or Qdrant server URL
+    \\  --collection <name>     Collection or table name (default: ananke_constraints)
+    \\  --provider <name>       openai, ollama, or command (default: openai)
+    \\  --model <name>          Embedding model (default depends on the provider)
+    \\  --endpoint <url|cmd>    Provider base URL, or the command to run
+    \\  --batch-size <n>        Chunks per embedding request (default: 64)
+    \\  --chunk-chars <n>       Maximum characters per chunk (default: 1500)
+    \\  --output, -o <file>     Write pgvector SQL to file instead of stdout
+    \\  --verbose, -v           Report each batch
+    \\  --help, -h              Show this help message
+    \\
+    \\Store keys are read from QDRANT_API_KEY or CHROMA_API_KEY when set.
+    \\Defaults for these options can be set in the [embeddings] config section.
+    \\
+    \\Examples:
+    \\  ananke export-embeddings constraints.json --store pgvector -o embeddings.sql && psql -f embeddings.sql
+    \\  ananke export-embeddings constraints.json --store qdrant --provider ollama
+    \\  ananke export-embeddings constraints.json --store chroma --provider command --endpoint "./embed.py"
+;
+
+const default_batch_size = 64;
+const default_chunk_chars = 1500;
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    if (parsed_args.positional.items.len == 0) {
+        cli_error.printError("Missing required argument: <results>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    }
+    const results_path = parsed_args.positional.items[0];
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+
+    const store_name = parsed_args.getFlag("store") orelse config.embeddings_store orelse {
+        cli_error.printError("Missing required option: --store (pgvector, chroma, or qdrant)", .{});
+        return error.MissingArgument;
+    };
+    const store = std.meta.stringToEnum(embeddings.Store, store_name) orelse {
+        cli_error.printError("Unknown store '{s}' (expected pgvector, chroma, or qdrant)", .{store_name});
+        return error.InvalidArgument;
+    };
+    const provider_name = parsed_args.getFlag("provider") orelse config.embeddings_provider orelse "openai";
+    const provider_kind = std.meta.stringToEnum(embeddings.ProviderKind, provider_name) orelse {
+        cli_error.printError("Unknown provider '{s}' (expected openai, ollama, or command)", .{provider_name});
+        return error.InvalidArgument;
+    };
+    const collection = parsed_args.getFlag("collection") orelse config.embeddings_collection orelse embeddings.default_collection;
+    if (!embeddings.validCollection(collection)) {
+        cli_error.printError("Invalid collection name '{s}': use letters, digits, and underscores", .{collection});
+        return error.InvalidArgument;
+    }
+    const batch_size = try parsed_args.getFlagInt("batch-size", usize) orelse default_batch_size;
+    const chunk_chars = try parsed_args.getFlagInt("chunk-chars", usize) orelse default_chunk_chars;
+    if (batch_size == 0) {
+        cli_error.printError("--batch-size must be at least 1", .{});
+        return error.InvalidArgument;
+    }
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const endpoint = parsed_args.getFlag("endpoint") orelse config.embeddings_endpoint orelse provider_kind.defaultEndpoint() orelse {
+        cli_error.printError("The command provider needs --endpoint <command>", .{});
+        return error.MissingArgument;
+    };
+    const provider = embeddings.Provider{
+        .kind = provider_kind,
+        .model = parsed_args.getFlag("model") orelse config.embeddings_model orelse provider_kind.defaultModel(),
+        .endpoint = endpoint,
+        .api_key = if (provider_kind == .openai) try getEnv(arena, "OPENAI_API_KEY") else null,
+    };
+    if (provider_kind == .openai and provider.api_key == null and parsed_args.getFlag("endpoint") == null and config.embeddings_endpoint == null) {
+        cli_error.printError("OPENAI_API_KEY is not set", .{});
+        cli_error.printInfo("Set it, or use --provider ollama or --provider command", .{});
+        return error.MissingArgument;
+    }
+
+    var result = results.ResultFile.loadFile(allocator, results_path) catch |err| {
+        if (err == results.ResultError.InvalidResultFile) {
+            cli_error.printError("Not an ananke JSON result file: {s}", .{results_path});
+            return error.InvalidArgument;
+        }
+        cli_error.printFileError(err, results_path);
+        return err;
+    };
+    defer result.deinit();
+
+    const chunks = try embeddings.chunkConstraints(arena, result.constraint_set.constraints.items, chunk_chars);
+    if (chunks.len == 0) {
+        cli_error.printWarning("No constraints in {s}; nothing to export", .{results_path});
+        return;
+    }
+
+    var client = std.http.Client{ .allocator = allocator };
+    defer client.deinit();
+
+    const texts = try arena.alloc([]const u8, chunks.len);
+    for (chunks, texts) |chunk, *text| text.* = chunk.text;
+    const vectors = try arena.alloc([]const f32, chunks.len);
+
+    var start: usize = 0;
+    while (start < chunks.len) : (start += batch_size) {
+        const end = @min(start + batch_size, chunks.len);
+        const batch = embeddings.embed(arena, &client, provider, texts[start..end]) catch |err| {
+            printExportError(err, provider_name, endpoint);
+            return err;
+        };
+        @memcpy(vectors[start..end], batch);
+        if (verbose) cli_error.printInfo("Embedded chunks {d}-{d} of {d}", .{ start + 1, end, chunks.len });
+    }
+
+    if (store == .pgvector) {
+        const sql = embeddings.formatPgvectorSql(arena, collection, chunks, vectors) catch |err| {
+            printExportError(err, provider_name, endpoint);
+            return err;
+        };
+        try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), sql);
+        cli_error.printSuccess("Wrote {d} embeddings ({d} constraints) for table {s}", .{
+            chunks.len,
+            result.constraint_set.constraints.items.len,
+            collection,
+        });
+        return;
+    }
+
+    const url = parsed_args.getFlag("store-url") orelse config.embeddings_store_url orelse store.defaultUrl().?;
+    const remote = embeddings.Remote{
+        .url = url,
+        .collection = collection,
+        .api_key = try getEnv(arena, if (store == .qdrant) "QDRANT_API_KEY" else "CHROMA_API_KEY"),
+    };
+    const written = embeddings.upsertRemote(arena, &client, store, remote, chunks, vectors) catch |err| {
+        printExportError(err, store_name, url);
+        return err;
+    };
+    cli_error.printSuccess("Upserted {d} embeddings ({d} constraints) into {s} collection {s}", .{
+        written,
+        result.constraint_set.constraints.items.len,
+        store_name,
+        collection,
+    });
+}
+
+fn printExportError(err: anyerror, service: []const u8, location: []const u8) void {
+    switch (err) {
+        embeddings.ExportError.ProviderFailed => cli_error.printError("Embedding provider {s} failed ({s})", .{ service, location }),
+        embeddings.ExportError.StoreFailed => cli_error.printError("{s} at {s} rejected the request", .{ service, location }),
+        embeddings.ExportError.InvalidResponse => cli_error.printError("Unexpected response from {s} ({s})", .{ service, location }),
+        embeddings.ExportError.DimensionMismatch => cli_error.printError("Provider {s} returned vectors of different sizes", .{service}),
+        error.ConnectionRefused => cli_error.printError("Cannot connect to {s} at {s}", .{ service, location }),
+        error.FileNotFound => cli_error.printError("Embedding command not found: {s}", .{location}),
+        else => cli_error.printError("Export failed: {s}", .{@errorName(err)}),
+    }
+}
+
+fn getEnv(arena: std.mem.Allocator, name: []const u8) !?[]const u8 {
+    return std.process.getEnvVarOwned(arena, name) catch |err| switch (err) {
+        error.EnvironmentVariableNotFound => null,
+        else => return err,
+    };
+}
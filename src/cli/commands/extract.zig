@@ -7,171 +7,1247 @@ const config_mod = @import("cli_config");
 const cli_error = @import("cli_error");
 const error_help = @import("cli_error_help");
 const path_validator = @import("path_validator");
+const summary_mod = @import("cli_summary");
+const prompt_pack = @import("cli_prompt_pack");
+const cyclonedx = @import("cli_cyclonedx");
+const codequality = @import("cli_codequality");
+const sonarqube = @import("cli_sonarqube");
+const techdocs = @import("cli_techdocs");
+const requirements = @import("cli_requirements");
+const sidecar = @import("cli_sidecar");
+const blame = @import("cli_blame");
+const summarize = @import("cli_summarize");
+const build_graph = @import("cli_build_graph");
+const annotate = @import("cli_annotate");
+const discovery = @import("cli_discovery");
+const git = @import("cli_git");
+const messages = @import("cli_messages");
+const report = @import("cli_report");
+const version = @import("cli_version");
+const baseline_mod = @import("cli_baseline");
+const jobs = @import("cli_jobs");
+const plan = @import("cli_plan");
+const cache_store = @import("cli_cache_store");
+const remote_cache = @import("cli_remote_cache");
+const incremental = @import("cli_incremental");
+const action = @import("cli_action");
+const similarity = @import("cli_similarity");
+const warm_state = @import("cli_warm_state");
+const results = @import("cli_results");
+const profiling = @import("cli_profiling");
+const extract_cache = @import("cli_extract_cache");
+const extract_pipeline = @import("cli_extract_pipeline");
+const extract_trace = @import("cli_extract_trace");
+const webhook = @import("cli_webhook");
+const tracing = @import("cli_tracing");
+const notify = @import("cli_notify");
 
 pub const usage =
-    \\Usage: ananke extract <file> [options]
+    \\Usage: ananke extract <path>... [options]
     \\
     \\Extract constraints from source code using pattern matching and optional LLM analysis.
     \\
     \\Arguments:
-    \\  <file>                  Source file to extract constraints from
+    \\  <path>                  Source file or directory to extract constraints from.
+    \\                          Directories are walked recursively, honoring .gitignore.
+    \\                          Use "-" to read a single file's content from stdin.
+    \\                          Several paths are extracted together with one worker
+    \\                          budget (e.g. a polyrepo checkout).
     \\
     \\Options:
-    \\  --language <lang>       Source language (auto-detected if not specified)
-    \\  --format <fmt>          Output format: json, yaml, pretty, ariadne (default: pretty)
-    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --workspace <file>      Also extract every path listed in <file>, one per line,
+    \\                          relative to the file's directory (# starts a comment)
+    \\  --split                 With several paths, write one output per path instead
+    \\                          of a merged result
+    \\  --output-dir <dir>      Directory for --split outputs, named after each path
+    \\                          (default: current directory)
+    \\  --parallel-targets <n>  With --split, extract up to n paths at once, sharing
+    \\                          the --jobs budget between them (default: 1)
+    \\  --language, --lang <l>  Source language (auto-detected if not specified; for a
+    \\                          directory, only files in this language are extracted)
+    \\  --stdin-filename <name> File name reported for stdin input (default: <stdin>)
+    \\  --exclude <globs>       Comma-separated .gitignore-style patterns to skip
+    \\                          (e.g. "test/fixtures,**/*_generated.go")
+    \\  --no-gitignore          Do not honor .gitignore files
+    \\  --no-default-excludes   Do not skip .git/, node_modules/, vendor/, zig-out/, .zig-cache/
+    \\  --changed-since <ref>   Only extract files changed since a git ref (plus untracked
+    \\                          files), listed by git instead of walking directories
+    \\  --scope-target <t,...>  Only extract the sources the given Bazel targets or Go
+    \\                          packages are built from, transitively (bazel query or
+    \\                          go list -deps; run from the workspace root)
+    \\  --build-system <name>   bazel or go for --scope-target (default: detected from
+    \\                          MODULE.bazel/WORKSPACE or go.mod/go.work)
+    \\  --incremental           Full result, but only files git reports as modified,
+    \\                          added, or renamed since the last --incremental run are
+    \\                          read; the rest are merged from the cache
+    \\  --dry-run               List the files that would be analyzed and by which extractor,
+    \\                          and every skipped path with the reason, without extracting
+    \\  --action-key            Print a digest of everything the output depends on (inputs,
+    \\                          rules, output settings) without extracting, for Bazel/Buck
+    \\  --action-record <file>  Also write the action key and the digest of each output file
+    \\  --format <fmt>          Output format: json, yaml, pretty, ariadne, stats, stats-json,
+    \\                          prompt-pack, cyclonedx, patch, markdown, html, codequality,
+    \\                          sonarqube, techdocs, sidecar, requirements
+    \\                          (default: pretty)
+    \\  --output, -o <file>     Write output to file instead of stdout (techdocs: the site
+    \\                          directory; sidecar: a directory mirroring the sources,
+    \\                          instead of next to them)
     \\  --confidence <min>      Minimum confidence threshold (0.0-1.0, default: 0.5)
+    \\  --collapse-similar <x>  Merge near-identical constraints of one kind (templated
+    \\                          code) into the first, at this similarity (e.g. 0.8)
+    \\  --kinds <kinds>         Comma-separated constraint kinds to extract (default: all);
+    \\                          without type_safety, type information is never analyzed
     \\  --use-claude            Enable Claude API for semantic analysis
-    \\  --verbose, -v           Verbose output
+    \\  --top <n>               Files listed by density in stats output (default: 10)
+    \\  --token-budget <n>      Approximate tokens per prompt-pack chunk (default: 2000)
+    \\  --max-chunks <n>        Emit at most n prompt-pack chunks (default: unlimited)
+    \\  --bom-component <name>  Component name recorded in cyclonedx output
+    \\  --bom-version <ver>     Component version recorded in cyclonedx output
+    \\  --messages <file>       Message catalog for markdown/html/techdocs report strings
+    \\  --techdocs-component <name>
+    \\                          Component the techdocs site documents (default: the nearest
+    \\                          catalog-info.yaml above the extracted files)
+    \\  --techdocs-owner <ref>  Owner recorded for the techdocs site (default: the
+    \\                          component's spec.owner)
+    \\  --redact                Mask string literals and strip code snippets from output
+    \\  --blame                 Record the commit, author, and date that last changed each
+    \\                          constraint's line (git blame) in json output
+    \\  --summarize             Ask the LLM configured under [summarize] for a summary and
+    \\                          remediation hints per package, recorded in json output
+    \\  --summarize-model <name>
+    \\                          Model for --summarize (default: [summarize] model)
+    \\  --webhook <url>         POST a summary to <url> when the run completes, in
+    \\                          addition to the [webhooks] urls of .ananke.toml
+    \\  --trace-endpoint <url>  Export OpenTelemetry spans of the run to this OTLP/HTTP
+    \\                          endpoint (default: $OTEL_EXPORTER_OTLP_ENDPOINT)
+    \\  --trace-file <file>     Write the spans as OTLP JSON to <file>
+    \\  --baseline <file>       Report only constraints not accepted in this baseline
+    \\  --write-baseline <file> Record the current constraints as the accepted baseline
+    \\  --no-baseline           Ignore the baseline configured in .ananke.toml
+    \\  --drift-threshold <n>   With --baseline, send the [notify] drift digest when new
+    \\                          plus stale constraints exceed n (default: [notify] config)
+    \\  --jobs, -j <n>          Worker threads for every stage (default: number of CPUs)
+    \\  --parse-jobs <n>        Concurrent file reads (default: --jobs)
+    \\  --analyze-jobs <n>      Concurrent extraction workers (default: --jobs; 1 with --use-claude)
+    \\  --render-jobs <n>       Concurrent per-file rendering (default: --jobs, at most 4)
+    \\  --io-rate <rate>        Cap combined source reads per second (e.g. 50M) on
+    \\                          network filesystems; lower --parse-jobs caps open reads
+    \\  --by-language           Extract each language on its own worker pool, so a slow
+    \\                          extractor only holds up its own files
+    \\  --pipeline-memory <n>   With --by-language, unmerged source bytes each language
+    \\                          may hold (default: 256M)
+    \\  --no-cache              Re-extract every file and re-walk directories instead of
+    \\                          reusing cached results and discovery snapshots
+    \\  --cache-dir <dir>       Extraction cache directory (default: .ananke-cache)
+    \\  --remote-cache <url>    Shared cache behind the local one: http(s)://host/path,
+    \\                          s3://bucket/prefix, or gs://bucket/prefix
+    \\  --remote-cache-mode <m> read (default) fetches local misses; read-write also
+    \\                          uploads every newly extracted file
+    \\  --cache-max-size <size> Evict least recently used cache entries beyond this
+    \\                          size (default: 2G; 0 = no limit)
+    \\  --generated <mode>      Files with a generated-code header (DO NOT EDIT,
+    \\                          @generated): full, reduced (default), or skip
+    \\  --max-file-lines <n>    Longer files get only their top-level declarations
+    \\                          extracted (default: 100000; 0 = no limit)
+    \\  --max-file-bytes <n>    Same for larger files (default: no limit)
+    \\  --oversized <action>    Files over a limit: outline (default) or skip
+    \\  --timings               Record per-stage wall time and allocations in json output
+    \\  --rule-timings          Report the pattern rules that took the most scan time
+    \\                          (cached files are not scanned; add --no-cache)
+    \\  --verbose, -v           Verbose output, including the per-stage breakdown
     \\  --help, -h              Show this help message
     \\
     \\Examples:
     \\  ananke extract src/main.ts
+    \\  cat handler.go | ananke extract - --lang go --format json
+    \\  ananke extract . --exclude "test/fixtures,benches" --format stats
+    \\  ananke extract . --exclude "**/*_gen.go" --dry-run
     \\  ananke extract src/auth.py --use-claude --format json -o constraints.json
     \\  ananke extract lib.rs --confidence 0.7 --format ariadne
+    \\  ananke extract src/payments.go --redact --format json
+    \\  ananke extract . --blame --format json -o constraints.json
+    \\  ananke extract src/auth.py --format stats
+    \\  ananke extract src/api.ts --format prompt-pack --token-budget 1500
+    \\  ananke extract main.go --format cyclonedx --bom-component api --bom-version 1.4.0
+    \\  ananke extract src/user.go --format patch | git apply
+    \\  ananke extract app.py --format html --messages messages.es.toml -o report.html
+    \\  ananke extract . --baseline .ananke-baseline.json --format codequality -o gl-code-quality-report.json
+    \\  ananke extract . --format sonarqube -o ananke-sonar.json
+    \\  ananke extract services/billing --format techdocs -o site/billing
+    \\  ananke extract services/users --format requirements -o REQUIREMENTS.md
+    \\  ananke extract . --write-baseline .ananke-baseline.json
+    \\  ananke extract . --baseline .ananke-baseline.json --format json
+    \\  ananke extract . -j 2 --analyze-jobs 4 --format stats
+    \\  ananke extract . --no-cache --format json
+    \\  ananke extract . --changed-since origin/main --format json
+    \\  ananke extract . --scope-target //services/api:server --format json
+    \\  ananke extract . --incremental --format json -o constraints.json
+    \\  ananke extract services/billing services/auth --format json -o services.json
+    \\  ananke extract --workspace repos.txt --split --output-dir reports --format html
+    \\  ananke extract --workspace services.txt --split --parallel-targets 8 --format json
 ;
 
-pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
-    // Check for help flag
-    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
-        std.debug.print("{s}\n", .{usage});
-        return;
+/// Largest source file read into memory for extraction; larger files are
+/// streamed in chunks (see Result.addStreamed)
+pub const max_source_bytes = 10 * 1024 * 1024;
+
+/// Bytes of a streamed file held in memory at once
+pub const stream_chunk_bytes = 4 * 1024 * 1024;
+
+/// Output and filtering options shared by every extraction entry point
+/// (file, directory, stdin, git ref)
+pub const Options = struct {
+    format: output.OutputFormat = .pretty,
+    output_file: ?[]const u8 = null,
+    confidence_threshold: f32 = 0.5,
+    use_claude: bool = false,
+    redact: bool = false,
+    verbose: bool = false,
+    language: ?[]const u8 = null,
+    top_n: usize = 10,
+    pack_options: prompt_pack.PackOptions = .{},
+    /// Suppress constraints accepted in this baseline file
+    baseline: ?[]const u8 = null,
+    /// Write the extracted constraints to this baseline file instead of rendering
+    write_baseline: ?[]const u8 = null,
+    /// Worker threads per extraction stage
+    concurrency: jobs.Concurrency = .{},
+    /// Extraction cache directory; null disables the cache
+    cache_dir: ?[]const u8 = null,
+    /// Shared cache behind the local one (http(s)://, s3://, gs://)
+    remote_cache: ?[]const u8 = null,
+    remote_cache_mode: remote_cache.Mode = .read,
+    /// Cache size at which least recently used entries are evicted; 0 is unlimited
+    cache_max_bytes: u64 = cache_store.default_max_bytes,
+    /// Constraint kinds to extract; analysis passes for other kinds are skipped
+    kinds: std.EnumSet(ananke.ConstraintKind) = std.EnumSet(ananke.ConstraintKind).initFull(),
+    /// Extraction of files recognized as generated code
+    generated: discovery.GeneratedMode = .reduced,
+    /// Per-file size limits for partial extraction
+    limits: FileLimits = .{},
+    /// Add stage timings to json output (--timings)
+    timings: bool = false,
+    /// Records discovery, parse, analyze, dedup, and render stages when set
+    profiler: ?*profiling.Profiler = null,
+    /// Combined source read rate in bytes per second; 0 is unlimited
+    io_rate: u64 = 0,
+    /// Shared by every reader in the run when io_rate is set
+    throttle: ?*jobs.Throttle = null,
+    /// Per-language pipeline memory budget in bytes; 0 runs one shared pool
+    pipeline_memory: usize = 0,
+    /// Only extract files changed since this git ref (--changed-since)
+    changed_since: ?[]const u8 = null,
+    /// Only extract the transitive sources of these build targets (--scope-target)
+    scope_targets: ?[]const u8 = null,
+    /// Build system answering --scope-target; null detects it
+    build_system: ?build_graph.System = null,
+    /// Re-extract only what git reports as changed since the last
+    /// --incremental run and merge the rest from the cache
+    incremental: bool = false,
+    /// Time every pattern rule and report the slowest (--rule-timings)
+    rule_timings: bool = false,
+    /// Collapse near-duplicate constraints at this similarity; 0 disables
+    collapse_similar: f32 = 0.0,
+    /// Posted to when the run completes (--webhook and [webhooks] config)
+    hooks: webhook.Hooks = .{},
+    /// Drift digests sent after a baseline check ([notify] config)
+    notifiers: notify.Notifiers = .{},
+    /// Records stage and per-file spans for --trace-endpoint and --trace-file
+    tracer: ?*tracing.Tracer = null,
+    /// Look up the last change to each constraint's line with git blame
+    blame: bool = false,
+    /// Summarize each package with an LLM (--summarize or [summarize] config)
+    summarize: ?summarize.Options = null,
+
+    /// Where stage timings and spans are recorded
+    pub fn stages(self: Options) extract_trace.Stages {
+        return .{ .profiler = self.profiler, .tracer = self.tracer };
     }
 
-    // Get required file argument
-    const file_path = parsed_args.getPositional(0) catch {
-        cli_error.printError("Missing required argument: <file>", .{});
-        std.debug.print("\n{s}\n", .{usage});
+    /// Parse and validate flags, falling back to config values
+    pub fn parse(parsed_args: args_mod.Args, config: config_mod.Config) !Options {
+        const format_str = parsed_args.getFlagOr("format", config.output_format);
+        const format = output.OutputFormat.fromString(format_str) orelse {
+            const valid_formats = &[_][]const u8{ "json", "yaml", "pretty", "ariadne", "stats", "stats-json", "prompt-pack", "cyclonedx", "patch", "markdown", "html", "codequality", "sonarqube", "techdocs", "sidecar", "requirements" };
+            error_help.printInvalidFormatError(format_str, valid_formats);
+            return error.InvalidArgument;
+        };
+
+        const confidence_threshold = try parsed_args.getFlagFloat("confidence", f32) orelse config.confidence_threshold;
+        if (confidence_threshold < 0.0 or confidence_threshold > 1.0) {
+            cli_error.printError("Confidence threshold must be between 0.0 and 1.0", .{});
+            return error.InvalidArgument;
+        }
+
+        const use_claude = parsed_args.hasFlag("use-claude") or config.use_claude;
+
+        const output_file = parsed_args.getFlag("output") orelse parsed_args.getFlag("o");
+        if (format == .techdocs and output_file == null and !parsed_args.hasFlag("split")) {
+            cli_error.printError("--format techdocs writes a site directory; pass it with --output", .{});
+            return error.MissingArgument;
+        }
+
+        const with_blame = parsed_args.hasFlag("blame");
+        if (with_blame and format != .json) {
+            cli_error.printError("--blame is recorded in json output; add --format json", .{});
+            return error.InvalidArgument;
+        }
+
+        return .{
+            .format = format,
+            .output_file = output_file,
+            .confidence_threshold = confidence_threshold,
+            .use_claude = use_claude,
+            .redact = parsed_args.hasFlag("redact") or config.redact,
+            .verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v"),
+            .language = parsed_args.getFlag("language") orelse parsed_args.getFlag("lang"),
+            .top_n = try parsed_args.getFlagInt("top", usize) orelse 10,
+            .pack_options = .{
+                .token_budget = try parsed_args.getFlagInt("token-budget", usize) orelse 2000,
+                .max_chunks = try parsed_args.getFlagInt("max-chunks", usize) orelse 0,
+            },
+            .baseline = if (parsed_args.hasFlag("no-baseline"))
+                null
+            else
+                parsed_args.getFlag("baseline") orelse config.extract_baseline,
+            .write_baseline = parsed_args.getFlag("write-baseline"),
+            .concurrency = try extract_pipeline.parseConcurrency(parsed_args, config, use_claude),
+            .cache_dir = extract_cache.parseCacheDir(parsed_args, config, use_claude),
+            .remote_cache = try extract_cache.parseRemoteCache(parsed_args, config, use_claude),
+            .remote_cache_mode = try extract_cache.parseRemoteCacheMode(parsed_args, config),
+            .cache_max_bytes = try extract_cache.parseCacheMaxSize(parsed_args, config),
+            .kinds = try parseKinds(parsed_args, config),
+            .generated = try parseGenerated(parsed_args, config),
+            .limits = try parseLimits(parsed_args, config),
+            .timings = parsed_args.hasFlag("timings"),
+            .io_rate = try parseIoRate(parsed_args, config),
+            .pipeline_memory = try extract_pipeline.parsePipelineMemory(parsed_args, config),
+            .changed_since = parsed_args.getFlag("changed-since"),
+            .scope_targets = parsed_args.getFlag("scope-target"),
+            .build_system = try parseBuildSystem(parsed_args),
+            .incremental = parsed_args.hasFlag("incremental"),
+            .rule_timings = parsed_args.hasFlag("rule-timings"),
+            .collapse_similar = try parseCollapseSimilar(parsed_args, config),
+            .hooks = webhook.Hooks.fromConfig(config, parsed_args.getFlag("webhook")),
+            .notifiers = try parseNotifiers(parsed_args, config),
+            .blame = with_blame,
+            .summarize = try parseSummarize(parsed_args, config, format),
+        };
+    }
+};
+
+/// LLM summary settings from --summarize or `[summarize] enabled`; null when
+/// off. Enabled from config, summaries are only produced for json output.
+fn parseSummarize(parsed_args: args_mod.Args, config: config_mod.Config, format: output.OutputFormat) !?summarize.Options {
+    const requested = parsed_args.hasFlag("summarize");
+    if (!requested and !config.summarize_enabled) return null;
+    if (format != .json) {
+        if (!requested) return null;
+        cli_error.printError("--summarize is recorded in json output; add --format json", .{});
+        return error.InvalidArgument;
+    }
+
+    const provider_name = config.summarize_provider orelse "openai";
+    const provider = std.meta.stringToEnum(summarize.Provider, provider_name) orelse {
+        cli_error.printError("Unknown [summarize] provider '{s}' (expected openai or anthropic)", .{provider_name});
+        return error.InvalidArgument;
+    };
+    const model = parsed_args.getFlag("summarize-model") orelse config.summarize_model orelse {
+        cli_error.printError("Summaries need a model: set model under [summarize] or pass --summarize-model", .{});
         return error.MissingArgument;
     };
+    return .{
+        .provider = provider,
+        .endpoint = config.summarize_endpoint orelse provider.defaultEndpoint(),
+        .model = model,
+        .requests_per_minute = config.summarize_requests_per_minute,
+        .max_requests = config.summarize_max_requests,
+        .cache_dir = extract_cache.parseCacheDir(parsed_args, config, false),
+    };
+}
+
+/// Build system named by --build-system
+fn parseBuildSystem(parsed_args: args_mod.Args) !?build_graph.System {
+    const name = parsed_args.getFlag("build-system") orelse return null;
+    return build_graph.System.fromString(name) orelse {
+        cli_error.printError("Unknown build system: {s} (expected bazel or go)", .{name});
+        return error.InvalidArgument;
+    };
+}
+
+/// Drift channels from `[notify]`, with --drift-threshold over the configured threshold
+fn parseNotifiers(parsed_args: args_mod.Args, config: config_mod.Config) !notify.Notifiers {
+    var notifiers = notify.Notifiers.fromConfig(config);
+    if (try parsed_args.getFlagInt("drift-threshold", usize)) |threshold| notifiers.threshold = threshold;
+    return notifiers;
+}
+
+/// Constraint kinds from --kinds or `[extract] kinds`; all kinds when neither is set
+pub fn parseKinds(parsed_args: args_mod.Args, config: config_mod.Config) !std.EnumSet(ananke.ConstraintKind) {
+    var kinds = std.EnumSet(ananke.ConstraintKind).initEmpty();
+    if (parsed_args.getFlag("kinds")) |list| {
+        var it = std.mem.splitScalar(u8, list, ',');
+        while (it.next()) |raw| {
+            const name = std.mem.trim(u8, raw, " \t");
+            if (name.len > 0) try insertKind(&kinds, name);
+        }
+    } else {
+        for (config.extract_kinds) |name| try insertKind(&kinds, name);
+    }
+    return if (kinds.count() == 0) std.EnumSet(ananke.ConstraintKind).initFull() else kinds;
+}
+
+fn insertKind(kinds: *std.EnumSet(ananke.ConstraintKind), name: []const u8) !void {
+    const kind = std.meta.stringToEnum(ananke.ConstraintKind, name) orelse {
+        cli_error.printError("Invalid constraint kind: {s}", .{name});
+        cli_error.printInfo("Valid kinds: syntactic, type_safety, semantic, architectural, operational, security", .{});
+        return error.InvalidArgument;
+    };
+    kinds.insert(kind);
+}
+
+/// Generated-file handling from --generated or `[extract] generated`
+pub fn parseGenerated(parsed_args: args_mod.Args, config: config_mod.Config) !discovery.GeneratedMode {
+    const mode_str = parsed_args.getFlag("generated") orelse config.extract_generated orelse return .reduced;
+    return std.meta.stringToEnum(discovery.GeneratedMode, mode_str) orelse {
+        cli_error.printError("Invalid generated-file mode: {s} (expected full, reduced, or skip)", .{mode_str});
+        return error.InvalidArgument;
+    };
+}
 
-    // Parse options
-    const language_override = parsed_args.getFlag("language");
-    const format_str = parsed_args.getFlagOr("format", config.output_format);
-    const output_file = parsed_args.getFlag("output") orelse parsed_args.getFlag("o");
-    const confidence_threshold = try parsed_args.getFlagFloat("confidence", f32) orelse config.confidence_threshold;
-    const use_claude = parsed_args.hasFlag("use-claude") or config.use_claude;
-    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+/// Per-file limits from --max-file-lines, --max-file-bytes, and --oversized,
+/// falling back to the `[extract]` settings
+pub fn parseLimits(parsed_args: args_mod.Args, config: config_mod.Config) !FileLimits {
+    const action_str = parsed_args.getFlag("oversized") orelse config.extract_oversized orelse "outline";
+    return .{
+        .max_bytes = try parsed_args.getFlagInt("max-file-bytes", usize) orelse config.extract_max_file_bytes,
+        .max_lines = try parsed_args.getFlagInt("max-file-lines", usize) orelse config.extract_max_file_lines,
+        .action = std.meta.stringToEnum(FileLimits.Action, action_str) orelse {
+            cli_error.printError("Invalid oversized-file action: {s} (expected outline or skip)", .{action_str});
+            return error.InvalidArgument;
+        },
+    };
+}
 
-    // Validate format
-    const format = output.OutputFormat.fromString(format_str) orelse {
-        const valid_formats = &[_][]const u8{ "json", "yaml", "pretty", "ariadne" };
-        error_help.printInvalidFormatError(format_str, valid_formats);
+/// Read rate limit from --io-rate or `[performance] io_rate`; 0 when unset
+pub fn parseIoRate(parsed_args: args_mod.Args, config: config_mod.Config) !u64 {
+    const rate_str = parsed_args.getFlag("io-rate") orelse config.io_rate orelse return 0;
+    return jobs.parseByteSize(rate_str) orelse {
+        cli_error.printError("Invalid IO rate: {s} (expected bytes per second, e.g. 512K or 50M)", .{rate_str});
         return error.InvalidArgument;
     };
+}
 
-    // Validate confidence threshold
-    if (confidence_threshold < 0.0 or confidence_threshold > 1.0) {
-        cli_error.printError("Confidence threshold must be between 0.0 and 1.0", .{});
+/// Similarity threshold from --collapse-similar or `[extract]
+/// collapse_similar`; 0 when unset
+pub fn parseCollapseSimilar(parsed_args: args_mod.Args, config: config_mod.Config) !f32 {
+    const threshold = try parsed_args.getFlagFloat("collapse-similar", f32) orelse config.extract_collapse_similar;
+    if (threshold < 0.0 or threshold > 1.0) {
+        cli_error.printError("Similarity threshold must be between 0.0 and 1.0", .{});
         return error.InvalidArgument;
     }
+    return threshold;
+}
 
-    if (verbose) {
-        cli_error.printInfo("Extracting constraints from: {s}", .{file_path});
-        if (use_claude) {
-            cli_error.printInfo("Claude semantic analysis: enabled", .{});
+/// Open the extraction cache for `options`; see extract_cache.open
+pub fn openCache(allocator: std.mem.Allocator, options: Options) ?cache_store.Cache {
+    return extract_cache.open(allocator, .{
+        .dir = options.cache_dir,
+        .max_bytes = options.cache_max_bytes,
+        .remote = options.remote_cache,
+        .remote_mode = options.remote_cache_mode,
+    });
+}
+
+/// Exclude patterns from `[extract] exclude` plus the comma-separated --exclude flag.
+/// Returned slices borrow from config and args.
+pub fn collectExcludes(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    config: config_mod.Config,
+) !std.ArrayList([]const u8) {
+    var excludes = std.ArrayList([]const u8){};
+    errdefer excludes.deinit(allocator);
+    try excludes.appendSlice(allocator, config.extract_excludes);
+    if (parsed_args.getFlag("exclude")) |patterns| {
+        var it = std.mem.splitScalar(u8, patterns, ',');
+        while (it.next()) |pattern| {
+            const trimmed = std.mem.trim(u8, pattern, " \t");
+            if (trimmed.len > 0) try excludes.append(allocator, trimmed);
         }
-        cli_error.printInfo("Confidence threshold: {d:.1}%", .{confidence_threshold * 100});
     }
+    return excludes;
+}
 
-    // Validate and resolve file path (security: prevent path traversal)
-    const validated_path = path_validator.validatePath(
-        allocator,
-        file_path,
-        false, // Don't allow absolute paths by default
-    ) catch |err| {
-        if (err == path_validator.PathValidationError.PathTraversalAttempt) {
-            cli_error.printError("Path traversal attempt detected: {s}", .{file_path});
-            cli_error.printInfo("Only relative paths within the current directory are allowed.", .{});
-            return error.InvalidPath;
+/// Create the extraction engine, wiring in Claude when requested and configured.
+/// `client_slot` must outlive the returned instance.
+pub fn initEngine(
+    allocator: std.mem.Allocator,
+    config: config_mod.Config,
+    options: Options,
+    client_slot: *?ananke.api.claude.ClaudeClient,
+) !ananke.Ananke {
+    var engine = try ananke.Ananke.init(allocator);
+    errdefer engine.deinit();
+    engine.clew_engine.config.kinds = options.kinds;
+
+    if (!options.use_claude) return engine;
+
+    if (config.claude_api_key.slice()) |api_key| {
+        const claude_config = ananke.api.claude.ClaudeConfig{
+            .api_key = api_key,
+            .endpoint = config.claude_endpoint orelse "https://api.anthropic.com/v1/messages",
+            .model = config.claude_model,
+            .max_tokens = config.max_tokens,
+            .temperature = config.temperature,
+            .timeout_ms = 30000,
+        };
+
+        client_slot.* = try ananke.api.claude.ClaudeClient.init(allocator, claude_config);
+        if (client_slot.*) |*client| {
+            engine.clew_engine.setClaudeClient(client);
         }
-        if (err == error.FileNotFound) {
-            error_help.printFileNotFoundError(file_path, allocator);
-        } else {
-            cli_error.printFileError(err, file_path);
+
+        if (options.verbose) {
+            cli_error.printInfo("Claude client initialized with model: {s}", .{config.claude_model});
         }
-        return err;
+    } else {
+        // API key missing - provide detailed setup instructions
+        error_help.printApiKeyMissingError("Claude");
+        if (!options.verbose) {
+            std.debug.print("\n", .{});
+            cli_error.printWarning("Proceeding without semantic analysis", .{});
+        }
+    }
+
+    return engine;
+}
+
+/// Per-file size limits (0 disables one) past which megafiles are outlined or skipped
+pub const FileLimits = struct {
+    max_bytes: usize = 0,
+    max_lines: usize = 0,
+    action: Action = .outline,
+
+    pub const Action = enum {
+        /// Extract only top-level declarations (see `outline`)
+        outline,
+        skip,
     };
-    defer allocator.free(validated_path);
 
-    // Read source file
-    const source = std.fs.cwd().readFileAlloc(allocator, validated_path, 10 * 1024 * 1024) catch |err| {
-        if (err == error.FileNotFound) {
-            error_help.printFileNotFoundError(validated_path, allocator);
-        } else {
-            cli_error.printFileError(err, validated_path);
+    pub fn exceeded(self: FileLimits, bytes: usize, lines: usize) bool {
+        return (self.max_bytes > 0 and bytes > self.max_bytes) or
+            (self.max_lines > 0 and lines > self.max_lines);
+    }
+};
+
+/// A file that was not extracted in full, reported after extraction
+pub const Notice = struct {
+    path: []const u8,
+    message: []const u8,
+};
+
+/// Kinds extracted from generated files in reduced mode: nobody edits them by
+/// hand, so only cheap syntactic checks and security findings are worth it
+pub const reduced_kinds = std.EnumSet(ananke.ConstraintKind).init(.{ .syntactic = true, .security = true });
+
+/// Constraints extracted from a set of sources, with per-file provenance so
+/// reports can group by file, language, and package.
+/// Sources and paths are borrowed and must outlive the result.
+pub const Result = struct {
+    allocator: std.mem.Allocator,
+    arena: std.heap.ArenaAllocator,
+    constraint_set: ananke.ConstraintSet,
+    files: std.ArrayList(summary_mod.FileInfo),
+    sources: std.ArrayList([]const u8),
+    /// Extra engines used by concurrent extraction; constraint strings point
+    /// into them, so they live as long as the result
+    engines: std.ArrayList(*ananke.Ananke),
+    /// Consulted before extracting each file and filled with new extractions
+    cache: ?*cache_store.Cache = null,
+    /// Background cache writer while addAll runs, so entry IO overlaps
+    /// extraction instead of stalling the merge
+    writer: ?*cache_store.Writer = null,
+    /// Cache entries whose constraints were merged; they own those strings
+    cached: std.ArrayList(results.ResultFile),
+    /// Keys of cache lookups and stores
+    keys: extract_cache.Keys,
+    /// Kinds the engine extracts, taken from it on each add; a restricted set
+    /// yields fewer constraints, so it is part of the cache key too
+    kinds: std.EnumSet(ananke.ConstraintKind) = std.EnumSet(ananke.ConstraintKind).initFull(),
+    /// Extraction of generated files; extract sets it from Options
+    generated: discovery.GeneratedMode = .full,
+    /// Files recognized as generated code (skipped or reduced)
+    generated_files: usize = 0,
+    /// Per-file limits; extract sets them from Options
+    limits: FileLimits = .{},
+    /// Read rate limit for streamed files; extract sets it from Options
+    throttle: ?*jobs.Throttle = null,
+    /// Receives a span per extracted file; extract sets it from Options
+    tracer: ?*tracing.Tracer = null,
+    /// Per-language pipeline memory budget in bytes; 0 runs one shared pool
+    pipeline_memory: usize = 0,
+    /// Top-level outline extracted in place of each oversized file, by path.
+    /// Filled before workers start, so they only read it.
+    outlines: std.StringHashMap([]const u8),
+    /// Oversized files that were outlined or skipped
+    notices: std.ArrayList(Notice),
+
+    pub fn init(allocator: std.mem.Allocator) Result {
+        return .{
+            .allocator = allocator,
+            .arena = std.heap.ArenaAllocator.init(allocator),
+            .constraint_set = ananke.ConstraintSet.init(allocator, "code_constraints"),
+            .files = std.ArrayList(summary_mod.FileInfo){},
+            .sources = std.ArrayList([]const u8){},
+            .engines = std.ArrayList(*ananke.Ananke){},
+            .cached = std.ArrayList(results.ResultFile){},
+            .keys = extract_cache.Keys.init(allocator),
+            .outlines = std.StringHashMap([]const u8).init(allocator),
+            .notices = std.ArrayList(Notice){},
+        };
+    }
+
+    pub fn deinit(self: *Result) void {
+        self.constraint_set.deinit();
+        self.files.deinit(self.allocator);
+        self.sources.deinit(self.allocator);
+        for (self.engines.items) |engine| {
+            engine.deinit();
+            self.allocator.destroy(engine);
         }
-        return err;
-    };
-    defer allocator.free(source);
+        self.engines.deinit(self.allocator);
+        for (self.cached.items) |*entry| entry.deinit();
+        self.cached.deinit(self.allocator);
+        self.keys.deinit();
+        self.outlines.deinit();
+        self.notices.deinit(self.allocator);
+        self.arena.deinit();
+    }
 
-    // Detect or use specified language
-    const language = language_override orelse detectLanguage(file_path);
+    /// Extract one source and merge its constraints into the result
+    pub fn add(self: *Result, engine: *ananke.Ananke, input: discovery.SourceFile) !void {
+        self.kinds = engine.clew_engine.config.kinds;
+        const prepared = try self.prepare(&.{input});
+        defer self.allocator.free(prepared);
+        if (prepared.len == 0) return;
+        const file = prepared[0];
+        if (try self.lookup(file)) |cached| return self.merge(file, cached);
+        self.attachBodies(engine);
+        var file_constraints = try self.extractFile(engine, file);
+        defer file_constraints.deinit();
+        self.remember(file, file_constraints);
+        try self.merge(file, file_constraints);
+    }
 
-    if (verbose) {
-        cli_error.printInfo("Detected language: {s}", .{language});
-    }
-
-    // Initialize Ananke
-    var ananke_instance = try ananke.Ananke.init(allocator);
-    defer ananke_instance.deinit();
-
-    // Initialize Claude client if requested and API key is available
-    var claude_client_opt: ?ananke.api.claude.ClaudeClient = null;
-    defer if (claude_client_opt) |*client| client.deinit();
-
-    if (use_claude) {
-        if (config.claude_api_key.slice()) |api_key| {
-            const claude_config = ananke.api.claude.ClaudeConfig{
-                .api_key = api_key,
-                .endpoint = config.claude_endpoint orelse "https://api.anthropic.com/v1/messages",
-                .model = config.claude_model,
-                .max_tokens = config.max_tokens,
-                .temperature = config.temperature,
-                .timeout_ms = 30000,
+    /// Extract every source on a pool of up to `workers` threads, each with
+    /// its own engine (engines are not thread-safe), while the calling thread
+    /// merges in input order so output does not depend on scheduling.
+    /// Workers take the largest files first.
+    /// `engine` serves the first worker. Files found in the cache are not
+    /// extracted again.
+    pub fn addAll(self: *Result, engine: *ananke.Ananke, all_files: []const discovery.SourceFile, workers: usize) !void {
+        self.kinds = engine.clew_engine.config.kinds;
+        const files = try self.prepare(all_files);
+        defer self.allocator.free(files);
+        const hits = try self.allocator.alloc(?ananke.ConstraintSet, files.len);
+        defer self.allocator.free(hits);
+        var misses: usize = 0;
+        for (files, hits) |file, *hit| {
+            hit.* = try self.lookup(file);
+            if (hit.* == null) misses += 1;
+        }
+        if (misses > 0) self.attachBodies(engine);
+
+        // Joined before returning: diff looks up the entries this run stored
+        var writer: cache_store.Writer = undefined;
+        if (self.cache != null and misses > 0) {
+            writer = cache_store.Writer.init(self.allocator, self.cache.?);
+            writer.start();
+            self.writer = &writer;
+        }
+        defer if (self.writer) |w| {
+            w.finish();
+            self.writer = null;
+        };
+
+        const n = jobs.workerCount(misses, workers);
+        if (n == 1) {
+            for (files, hits) |file, hit| {
+                if (hit) |cached| {
+                    try self.merge(file, cached);
+                    continue;
+                }
+                var file_constraints = try self.extractFile(engine, file);
+                defer file_constraints.deinit();
+                self.remember(file, file_constraints);
+                try self.merge(file, file_constraints);
+            }
+            return;
+        }
+        if (self.pipeline_memory > 0 and extract_pipeline.spansLanguages(files, hits)) {
+            return self.addPipelined(engine, files, hits, workers);
+        }
+
+        const engines = try self.workerEngines(engine, n);
+        defer self.allocator.free(engines);
+        const extractions = try self.allocator.alloc(Extraction, files.len);
+        defer {
+            for (extractions) |*slot| {
+                if (slot.value) |*set| set.deinit();
+            }
+            self.allocator.free(extractions);
+        }
+        @memset(extractions, .{});
+
+        // Largest misses are claimed first, so the run does not end on one
+        // worker grinding a huge file picked up last; hits cost nothing
+        const costs = try self.allocator.alloc(u64, files.len);
+        defer self.allocator.free(costs);
+        for (files, hits, costs) |file, hit, *cost| {
+            cost.* = if (hit == null) self.extractedSource(file).len else 0;
+        }
+        const order = try jobs.costOrder(self.allocator, costs);
+        defer self.allocator.free(order);
+
+        // Workers extract misses in any order; this thread merges in input
+        // order as each file completes and frees its extraction right away
+        const context = Concurrent{ .result = self, .engines = engines, .files = files, .hits = hits, .extractions = extractions };
+        try jobs.forEachOrderedBy(self.allocator, files.len, n, order, &context, Concurrent.extractOne, Concurrent.collect);
+    }
+
+    /// Extract misses with one pipeline per language (see
+    /// extract_pipeline.zig), each on its own pool of workers sized by the
+    /// language's share of the bytes, so a slow extractor only holds up its
+    /// own files. This thread still merges in input order.
+    fn addPipelined(
+        self: *Result,
+        engine: *ananke.Ananke,
+        files: []const discovery.SourceFile,
+        hits: []const ?ananke.ConstraintSet,
+        workers: usize,
+    ) !void {
+        var schedule = try extract_pipeline.Schedule.init(self.allocator, files, hits, workers);
+        defer schedule.deinit();
+        const engines = try self.workerEngines(engine, schedule.workers);
+        defer self.allocator.free(engines);
+        const extractions = try self.allocator.alloc(Extraction, files.len);
+        defer {
+            for (extractions) |*slot| {
+                if (slot.value) |*set| set.deinit();
+            }
+            self.allocator.free(extractions);
+        }
+        @memset(extractions, .{});
+
+        const context = Concurrent{ .result = self, .engines = engines, .files = files, .hits = hits, .extractions = extractions };
+        try schedule.run(self.pipeline_memory, &context, Concurrent.extractOne, Concurrent.collect);
+    }
+
+    const Extraction = jobs.Slot(ananke.ConstraintSet);
+
+    /// Extraction of misses on worker threads, merged by the calling thread
+    const Concurrent = struct {
+        result: *Result,
+        /// One per worker
+        engines: []const *ananke.Ananke,
+        files: []const discovery.SourceFile,
+        hits: []const ?ananke.ConstraintSet,
+        extractions: []Extraction,
+
+        fn extractOne(ctx: *const @This(), worker: usize, i: usize) void {
+            if (ctx.hits[i] != null) return;
+            const file = ctx.files[i];
+            ctx.extractions[i].value = ctx.result.extractFile(ctx.engines[worker], file) catch |err| {
+                ctx.extractions[i].err = err;
+                return;
+            };
+        }
+
+        fn collect(ctx: *const @This(), i: usize) anyerror!void {
+            const file = ctx.files[i];
+            if (ctx.hits[i]) |cached| return ctx.result.merge(file, cached);
+            const slot = &ctx.extractions[i];
+            if (slot.err) |err| return err;
+            var file_constraints = slot.value orelse return;
+            slot.value = null;
+            defer file_constraints.deinit();
+            ctx.result.remember(file, file_constraints);
+            try ctx.result.merge(file, file_constraints);
+        }
+    };
+
+    /// `n` engines for concurrent workers: `engine` first, then extras
+    /// created on demand with its configuration. Caller frees the slice.
+    fn workerEngines(self: *Result, engine: *ananke.Ananke, n: usize) ![]*ananke.Ananke {
+        while (self.engines.items.len < n - 1) {
+            const extra = try self.allocator.create(ananke.Ananke);
+            extra.* = ananke.Ananke.init(self.allocator) catch |err| {
+                self.allocator.destroy(extra);
+                return err;
             };
+            extra.clew_engine.config = engine.clew_engine.config;
+            self.engines.append(self.allocator, extra) catch |err| {
+                extra.deinit();
+                self.allocator.destroy(extra);
+                return err;
+            };
+        }
+        // Body scans are attached per run; extras may predate them
+        for (self.engines.items) |extra| extra.clew_engine.config.bodies = engine.clew_engine.config.bodies;
+
+        const engines = try self.allocator.alloc(*ananke.Ananke, n);
+        engines[0] = engine;
+        @memcpy(engines[1..], self.engines.items[0 .. n - 1]);
+        return engines;
+    }
 
-            claude_client_opt = try ananke.api.claude.ClaudeClient.init(allocator, claude_config);
-            if (claude_client_opt) |*client| {
-                ananke_instance.clew_engine.setClaudeClient(client);
+    /// The files to extract: generated files are counted (and dropped in skip
+    /// mode), and oversized files are dropped or outlined, with a notice.
+    /// Caller owns the slice.
+    fn prepare(self: *Result, files: []const discovery.SourceFile) ![]const discovery.SourceFile {
+        var kept = std.ArrayList(discovery.SourceFile){};
+        errdefer kept.deinit(self.allocator);
+        try kept.ensureTotalCapacity(self.allocator, files.len);
+        for (files) |file| {
+            if (self.generated != .full and discovery.isGenerated(file.path, file.source)) {
+                self.generated_files += 1;
+                if (self.generated == .skip) continue;
             }
+            const lines = std.mem.count(u8, file.source, "\n") + 1;
+            if (self.limits.exceeded(file.source.len, lines)) {
+                const skip = self.limits.action == .skip;
+                try self.addNotice(file.path, lines, file.source.len, skip);
+                if (skip) continue;
+                try self.outlines.put(file.path, try outline(self.arena.allocator(), file.source));
+            }
+            kept.appendAssumeCapacity(file);
+        }
+        return kept.toOwnedSlice(self.allocator);
+    }
+
+    fn addNotice(self: *Result, path: []const u8, lines: ?usize, bytes: u64, skipped: bool) !void {
+        const action = if (skipped) "skipped" else "extracted top-level declarations only";
+        const arena = self.arena.allocator();
+        const message = if (lines) |n|
+            try std.fmt.allocPrint(arena, "{d} lines, {d} bytes is over the per-file limit; {s}", .{ n, bytes, action })
+        else
+            try std.fmt.allocPrint(arena, "{d} bytes is over the per-file limit; {s}", .{ bytes, action });
+        try self.notices.append(self.allocator, .{ .path = path, .message = message });
+    }
+
+    /// Text the engine sees for `file`: its outline when it is oversized
+    fn extractedSource(self: *const Result, file: discovery.SourceFile) []const u8 {
+        return self.outlines.get(file.path) orelse file.source;
+    }
+
+    /// Kinds extracted from `file`: the engine's, narrowed to `reduced_kinds`
+    /// for generated code in reduced mode
+    fn kindsFor(self: *const Result, file: discovery.SourceFile) std.EnumSet(ananke.ConstraintKind) {
+        if (self.generated != .reduced or !discovery.isGenerated(file.path, file.source)) return self.kinds;
+        return self.kinds.intersectWith(reduced_kinds);
+    }
+
+    /// Extract `file` with `engine`, limited to kindsFor(file)
+    fn extractFile(self: *const Result, engine: *ananke.Ananke, file: discovery.SourceFile) !ananke.ConstraintSet {
+        const config = &engine.clew_engine.config;
+        const saved = config.kinds;
+        config.kinds = self.kindsFor(file);
+        defer config.kinds = saved;
+        const source = self.extractedSource(file);
+        const tracer = self.tracer orelse return engine.extract(source, file.language);
+        return extract_trace.extractFile(tracer, engine, file.path, file.language, source);
+    }
+
+    /// Extract a file too large to hold in memory, `stream_chunk_bytes` at a
+    /// time. Chunks end at a blank line where possible (usually between
+    /// top-level declarations), else at a line end; constraint lines are
+    /// offset to the whole file and strings are copied out before the buffer
+    /// is reused. A construct split across chunks is only seen in part, and
+    /// streamed files are neither cached nor annotated by patch output.
+    pub fn addStreamed(self: *Result, engine: *ananke.Ananke, input: discovery.DiscoveredFile) !void {
+        const file = try std.fs.cwd().openFile(input.path, .{});
+        defer file.close();
+        // Line counts are unknown until the end, so only the byte limit applies
+        const size = (try file.stat()).size;
+        const outlined = self.limits.max_bytes > 0 and size > self.limits.max_bytes;
+        if (outlined) {
+            const skip = self.limits.action == .skip;
+            try self.addNotice(input.path, null, size, skip);
+            if (skip) return;
+        }
+        const span: ?tracing.Handle = if (self.tracer) |tracer| try extract_trace.beginFile(tracer) else null;
+        const first_constraint = self.constraint_set.constraints.items.len;
+        defer if (span) |handle| {
+            const constraints = self.constraint_set.constraints.items.len - first_constraint;
+            extract_trace.endFile(self.tracer.?, handle, input.path, input.language, size, constraints, "streamed");
+        };
+        const buffer = try self.allocator.alloc(u8, stream_chunk_bytes);
+        defer self.allocator.free(buffer);
+        const strings = self.arena.allocator();
 
-            if (verbose) {
-                cli_error.printInfo("Claude client initialized with model: {s}", .{config.claude_model});
+        var hasher = std.crypto.hash.sha2.Sha256.init(.{});
+        var len: usize = 0;
+        var eof = false;
+        var line_offset: u32 = 0;
+        var kinds_set = false;
+        const saved_kinds = engine.clew_engine.config.kinds;
+        defer engine.clew_engine.config.kinds = saved_kinds;
+        self.kinds = saved_kinds;
+        while (true) {
+            while (!eof and len < buffer.len) {
+                const n = try file.read(buffer[len..]);
+                if (self.throttle) |throttle| throttle.charge(n);
+                if (n == 0) {
+                    eof = true;
+                } else {
+                    hasher.update(buffer[len..][0..n]);
+                    len += n;
+                }
             }
-        } else {
-            // API key missing - provide detailed setup instructions
-            error_help.printApiKeyMissingError("Claude");
-            if (!verbose) {
-                std.debug.print("\n", .{});
-                cli_error.printWarning("Proceeding without semantic analysis", .{});
+            if (len == 0) break;
+
+            // The header is in the first chunk; its kinds apply to every chunk
+            if (!kinds_set) {
+                kinds_set = true;
+                const first = discovery.SourceFile{ .path = input.path, .language = input.language, .source = buffer[0..len] };
+                if (self.generated != .full and discovery.isGenerated(input.path, first.source)) {
+                    self.generated_files += 1;
+                    if (self.generated == .skip) return;
+                }
+                engine.clew_engine.config.kinds = self.kindsFor(first);
+            }
+
+            const cut = if (eof) len else chunkEnd(buffer[0..len]);
+            const chunk = buffer[0..cut];
+            const chunk_outline = if (outlined) try outline(self.allocator, chunk) else null;
+            defer if (chunk_outline) |text| self.allocator.free(text);
+            var chunk_constraints = try engine.extract(chunk_outline orelse chunk, input.language);
+            defer chunk_constraints.deinit();
+            for (chunk_constraints.constraints.items) |c| {
+                var owned = c;
+                owned.name = try strings.dupe(u8, c.name);
+                owned.description = try strings.dupe(u8, c.description);
+                owned.origin_file = input.path;
+                if (c.origin_line) |line| owned.origin_line = line + line_offset;
+                try self.constraint_set.constraints.append(self.allocator, owned);
             }
+
+            line_offset += @intCast(std.mem.count(u8, chunk, "\n"));
+            std.mem.copyForwards(u8, buffer, buffer[cut..len]);
+            len -= cut;
         }
+
+        var digest: [std.crypto.hash.sha2.Sha256.digest_length]u8 = undefined;
+        hasher.final(&digest);
+        try self.files.append(self.allocator, .{
+            .path = input.path,
+            .language = input.language,
+            .line_count = line_offset + 1,
+            .content_hash = try strings.dupe(u8, &std.fmt.bytesToHex(digest, .lower)),
+        });
+        // Keep `sources` parallel to `files`; an empty source yields no patch
+        try self.sources.append(self.allocator, "");
     }
 
-    // Extract constraints
-    var spinner = output.Spinner.init("Extracting constraints...");
-    var constraint_set = try ananke_instance.extract(source, language);
-    defer constraint_set.deinit();
-    spinner.finish("Extraction complete");
+    /// Cache key for `file`, over the text actually extracted, so an
+    /// outlined file never reuses a full extraction or the other way round
+    pub fn cacheKey(self: *Result, file: discovery.SourceFile) cache_store.Key {
+        return self.keys.key(file.language, self.extractedSource(file), self.kindsFor(file));
+    }
 
-    // Filter by confidence threshold
-    const original_count = constraint_set.constraints.items.len;
-    var i: usize = 0;
-    while (i < constraint_set.constraints.items.len) {
-        if (constraint_set.constraints.items[i].confidence < confidence_threshold) {
-            _ = constraint_set.constraints.orderedRemove(i);
-        } else {
-            i += 1;
+    /// Cached constraints for `file`, kept alive in `cached`
+    fn lookup(self: *Result, file: discovery.SourceFile) !?ananke.ConstraintSet {
+        const cache = self.cache orelse return null;
+        const key = self.cacheKey(file);
+        var entry = cache.load(&key) orelse
+            self.keys.carryOver(cache, file.language, self.extractedSource(file), self.kindsFor(file), &key) orelse
+            return null;
+        self.cached.append(self.allocator, entry) catch |err| {
+            entry.deinit();
+            return err;
+        };
+        return entry.constraint_set;
+    }
+
+    /// Share the cache's function-body scans with `engine`, and the worker
+    /// engines configured from it, so a file that missed because one function
+    /// changed rescans only that function
+    fn attachBodies(self: *Result, engine: *ananke.Ananke) void {
+        const cache = self.cache orelse return;
+        engine.clew_engine.config.bodies = cache.bodyCache(version.VERSION);
+    }
+
+    /// Store a fresh extraction; a failed write only costs a future cache miss
+    fn remember(self: *Result, file: discovery.SourceFile, file_constraints: ananke.ConstraintSet) void {
+        const cache = self.cache orelse return;
+        const key = self.cacheKey(file);
+        extract_cache.store(cache, self.writer, &key, file_constraints);
+    }
+
+    /// Merge the cached constraints of a file that was not read
+    /// (--incremental). Takes ownership of `entry`.
+    pub fn addCached(self: *Result, file: incremental.Entry, entry: results.ResultFile) !void {
+        var owned_entry = entry;
+        self.cached.append(self.allocator, owned_entry) catch |err| {
+            owned_entry.deinit();
+            return err;
+        };
+        for (entry.constraint_set.constraints.items) |c| {
+            var owned = c;
+            if (owned.origin_file == null) owned.origin_file = file.path;
+            try self.constraint_set.constraints.append(self.allocator, owned);
+        }
+        try self.files.append(self.allocator, .{
+            .path = file.path,
+            .language = file.language,
+            .line_count = file.line_count,
+            .content_hash = file.content_hash,
+        });
+        // Keep `sources` parallel to `files`; an unread source yields no patch
+        try self.sources.append(self.allocator, "");
+    }
+
+    fn merge(self: *Result, file: discovery.SourceFile, file_constraints: ananke.ConstraintSet) !void {
+        for (file_constraints.constraints.items) |c| {
+            var owned = c;
+            if (owned.origin_file == null) owned.origin_file = file.path;
+            try self.constraint_set.constraints.append(self.allocator, owned);
+        }
+
+        var digest: [std.crypto.hash.sha2.Sha256.digest_length]u8 = undefined;
+        std.crypto.hash.sha2.Sha256.hash(file.source, &digest, .{});
+        try self.files.append(self.allocator, .{
+            .path = file.path,
+            .language = file.language,
+            .line_count = std.mem.count(u8, file.source, "\n") + 1,
+            .content_hash = try self.arena.allocator().dupe(u8, &std.fmt.bytesToHex(digest, .lower)),
+        });
+        try self.sources.append(self.allocator, file.source);
+    }
+
+    /// Collapse near-identical constraints of the same kind into their first
+    /// occurrence, which keeps its location and takes the group's combined
+    /// frequency and highest confidence. Returns how many were removed.
+    pub fn collapseSimilar(self: *Result, threshold: f32) !usize {
+        const items = self.constraint_set.constraints.items;
+        if (items.len < 2) return 0;
+
+        var arena = std.heap.ArenaAllocator.init(self.allocator);
+        defer arena.deinit();
+        const texts = try arena.allocator().alloc([]const u8, items.len);
+        const classes = try arena.allocator().alloc(u32, items.len);
+        for (items, texts, classes) |c, *text, *class| {
+            text.* = try std.mem.concat(arena.allocator(), u8, &.{ c.name, "\n", c.description });
+            class.* = @intFromEnum(c.kind);
+        }
+
+        const leaders = try similarity.groupSimilar(self.allocator, texts, classes, threshold);
+        defer self.allocator.free(leaders);
+
+        var kept: usize = 0;
+        for (items, leaders, 0..) |c, leader, i| {
+            if (leader == i) {
+                items[kept] = c;
+                // Leaders precede their members, so remember where each one went
+                leaders[i] = kept;
+                kept += 1;
+                continue;
+            }
+            const into = &items[leaders[leader]];
+            into.frequency +|= c.frequency;
+            into.confidence = @max(into.confidence, c.confidence);
+        }
+        const removed = items.len - kept;
+        self.constraint_set.constraints.shrinkRetainingCapacity(kept);
+        return removed;
+    }
+
+    /// Drop constraints below the confidence threshold; returns how many were removed
+    pub fn filterConfidence(self: *Result, threshold: f32) usize {
+        const items = &self.constraint_set.constraints;
+        const original_count = items.items.len;
+        var i: usize = 0;
+        while (i < items.items.len) {
+            if (items.items[i].confidence < threshold) {
+                _ = items.orderedRemove(i);
+            } else {
+                i += 1;
+            }
         }
+        return original_count - items.items.len;
+    }
+};
+
+/// Top-level declarations of `source`: every indented line is blanked, so
+/// bodies drop out while line numbers stay those of the original
+pub fn outline(allocator: std.mem.Allocator, source: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    try list.ensureTotalCapacity(allocator, source.len / 4);
+    var lines = std.mem.splitScalar(u8, source, '\n');
+    var first = true;
+    while (lines.next()) |line| {
+        if (!first) try list.append(allocator, '\n');
+        first = false;
+        if (line.len > 0 and line[0] != ' ' and line[0] != '\t') try list.appendSlice(allocator, line);
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+/// End of the next streamed chunk in a full buffer: just past the last blank
+/// line, else past the last newline, else the whole buffer (one huge line)
+fn chunkEnd(data: []const u8) usize {
+    if (std.mem.lastIndexOf(u8, data, "\n\n")) |i| return i + 2;
+    if (std.mem.lastIndexOfScalar(u8, data, '\n')) |i| return i + 1;
+    return data.len;
+}
+
+/// Report the extraction, then render and write the requested output format.
+/// `component_name` labels the run in cyclonedx output and webhook payloads.
+pub fn render(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    config: config_mod.Config,
+    options: Options,
+    result: *Result,
+    component_name: []const u8,
+) !void {
+    try renderOutput(allocator, parsed_args, config, options, result, component_name);
+    if (options.hooks.wants(.extract)) try notifyCompletion(allocator, options, result, component_name);
+}
+
+/// Post the summary of the rendered constraints to the completion webhooks
+fn notifyCompletion(allocator: std.mem.Allocator, options: Options, result: *Result, target: []const u8) !void {
+    var summary = try summary_mod.Summary.compute(allocator, result.constraint_set.constraints.items, result.files.items, options.top_n);
+    defer summary.deinit();
+    const summary_json = try summary_mod.formatJson(allocator, summary);
+    defer allocator.free(summary_json);
+    const payload = try webhook.formatPayload(allocator, .extract, version.VERSION, std.time.timestamp(), target, "completed", summary_json);
+    defer allocator.free(payload);
+    webhook.notify(allocator, options.hooks, .extract, payload, options.verbose);
+}
+
+/// Send the drift digest of a baseline check to the [notify] channels
+fn notifyDrift(
+    allocator: std.mem.Allocator,
+    options: Options,
+    added: []const ananke.Constraint,
+    stats: baseline_mod.FilterStats,
+    baseline_path: []const u8,
+    target: []const u8,
+) void {
+    if (added.len + stats.stale <= options.notifiers.threshold) return;
+    var arena = std.heap.ArenaAllocator.init(allocator);
+    defer arena.deinit();
+    const digest = driftDigest(arena.allocator(), options, added, stats, baseline_path, target) catch return;
+    if (notify.notifyDrift(allocator, options.notifiers, digest, version.VERSION, options.verbose)) {
+        cli_error.printInfo("Drift of {d} is over the threshold of {d}; sent the digest", .{ digest.drift(), digest.threshold });
     }
+}
+
+/// The digest leaves the process before the output is redacted, so --redact
+/// masks its constraints here
+fn driftDigest(
+    arena: std.mem.Allocator,
+    options: Options,
+    added: []const ananke.Constraint,
+    stats: baseline_mod.FilterStats,
+    baseline_path: []const u8,
+    target: []const u8,
+) !notify.Digest {
+    const digest = notify.Digest{
+        .target = target,
+        .baseline = baseline_path,
+        .branch = notify.currentBranch(arena),
+        .commit = notify.currentCommit(arena),
+        .threshold = options.notifiers.threshold,
+        .added = added,
+        .removed = stats.stale,
+    };
+    return if (options.redact) digest.redact(arena) else digest;
+}
 
-    const filtered_count = original_count - constraint_set.constraints.items.len;
-    if (filtered_count > 0 and verbose) {
+fn renderOutput(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    config: config_mod.Config,
+    options: Options,
+    result: *Result,
+    component_name: []const u8,
+) !void {
+    if (result.generated_files > 0 and options.verbose) {
+        cli_error.printInfo("{d} generated files {s}", .{
+            result.generated_files,
+            if (result.generated == .skip) "skipped" else "extracted with the reduced rule set",
+        });
+    }
+    for (result.notices.items) |notice| {
+        cli_error.printWarning("{s}: {s}", .{ notice.path, notice.message });
+    }
+    // Dropping low-confidence constraints and ones already accepted in the
+    // baseline is the dedup stage; formatting and writing is render
+    options.stages().begin("dedup");
+    const filtered_count = result.filterConfidence(options.confidence_threshold);
+    if (filtered_count > 0 and options.verbose) {
         cli_error.printInfo("Filtered {d} constraints below confidence threshold", .{filtered_count});
     }
+    if (options.collapse_similar > 0) {
+        const collapsed = try result.collapseSimilar(options.collapse_similar);
+        if (collapsed > 0 and options.verbose) {
+            cli_error.printInfo("Collapsed {d} near-duplicate constraints", .{collapsed});
+        }
+    }
+
+    const constraint_set = &result.constraint_set;
+
+    if (options.write_baseline) |path| {
+        const text = try baseline_mod.formatBaseline(allocator, constraint_set.constraints.items);
+        defer allocator.free(text);
+        std.fs.cwd().writeFile(.{ .sub_path = path, .data = text }) catch |err| {
+            cli_error.printFileError(err, path);
+            return err;
+        };
+        cli_error.printSuccess("Wrote baseline of {d} constraints to {s}", .{ constraint_set.constraints.items.len, path });
+        return;
+    }
+
+    if (options.baseline) |path| {
+        var baseline = baseline_mod.Baseline.loadFile(allocator, path) catch |err| {
+            switch (err) {
+                baseline_mod.BaselineError.UnsupportedVersion => cli_error.printError("Unsupported baseline version in {s}", .{path}),
+                baseline_mod.BaselineError.InvalidFingerprint => cli_error.printError("Malformed fingerprint in baseline {s}", .{path}),
+                else => cli_error.printFileError(err, path),
+            }
+            return err;
+        };
+        defer baseline.deinit();
+
+        const stats = try baseline.filter(&constraint_set.constraints);
+        cli_error.printInfo("Suppressed {d} constraints accepted in baseline {s}", .{ stats.suppressed, path });
+        if (stats.stale > 0 and options.verbose) {
+            cli_error.printInfo("{d} baseline entries no longer match; rerun with --write-baseline to prune them", .{stats.stale});
+        }
+        if (options.notifiers.enabled()) notifyDrift(allocator, options, constraint_set.constraints.items, stats, path, component_name);
+        if (constraint_set.constraints.items.len == 0) {
+            cli_error.printSuccess("No new constraints beyond the baseline", .{});
+            // An empty report tells GitLab that earlier findings are resolved
+            if (options.format == .codequality) try writeOutput(options.output_file, "[]\n");
+            if (options.format == .sonarqube) try writeOutput(options.output_file, sonarqube.empty_report);
+            if (options.format == .techdocs) try writeTechDocs(allocator, parsed_args, config, options, result, component_name);
+            if (options.format == .sidecar) try writeSidecarFiles(allocator, options, result);
+            return options.stages().end();
+        }
+    }
+    try options.stages().end();
+    options.stages().begin("render");
+    defer options.stages().end() catch {};
 
-    std.debug.print("Extracted {d} constraints\n", .{constraint_set.constraints.items.len});
+    if (result.files.items.len > 1) {
+        std.debug.print("Extracted {d} constraints from {d} files\n", .{ constraint_set.constraints.items.len, result.files.items.len });
+    } else {
+        std.debug.print("Extracted {d} constraints\n", .{constraint_set.constraints.items.len});
+    }
 
     // Check for empty constraint set
     if (constraint_set.constraints.items.len == 0) {
@@ -180,67 +1256,1471 @@ pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: con
         cli_error.printInfo("  - The source file is empty or minimal", .{});
         cli_error.printInfo("  - The language is not fully supported", .{});
         cli_error.printInfo("  - The confidence threshold is too high", .{});
-        if (!use_claude) {
+        if (!options.use_claude) {
             cli_error.printInfo("  - Try using --use-claude for semantic analysis", .{});
         }
+        if (options.format == .codequality) try writeOutput(options.output_file, "[]\n");
+        if (options.format == .sonarqube) try writeOutput(options.output_file, sonarqube.empty_report);
+        if (options.format == .techdocs) try writeTechDocs(allocator, parsed_args, config, options, result, component_name);
+        if (options.format == .sidecar) try writeSidecarFiles(allocator, options, result);
         return;
     }
 
+    // Redact string literals and snippets before anything leaves the process
+    if (options.redact) {
+        try output.redactConstraintSet(result.arena.allocator(), constraint_set);
+        if (options.verbose) {
+            cli_error.printInfo("Redacted string literals and source snippets from output", .{});
+        }
+    }
+
+    // A site is a directory of pages rather than one rendered document
+    if (options.format == .techdocs) return writeTechDocs(allocator, parsed_args, config, options, result, component_name);
+    if (options.format == .sidecar) return writeSidecarFiles(allocator, options, result);
+
     // Format output
-    const output_text = switch (format) {
-        .json => try output.formatJson(allocator, constraint_set),
-        .yaml => try output.formatYaml(allocator, constraint_set),
-        .pretty => try output.formatPretty(allocator, constraint_set),
-        .ariadne => try output.formatAriadne(allocator, constraint_set),
+    var catalog = try loadCatalog(allocator, parsed_args, config);
+    defer catalog.deinit();
+
+    const files = result.files.items;
+    const output_text = switch (options.format) {
+        .json => try output.formatJsonWith(allocator, constraint_set.*, .{
+            // Stages finished so far; render itself is still running
+            .timings = if (options.timings and options.profiler != null) options.profiler.?.stages.items else &.{},
+            .authorship = if (options.blame) try blameConstraints(result, options.verbose) else &.{},
+            .summaries = if (options.summarize) |settings| try summarizeConstraints(allocator, result, settings, options.verbose) else &.{},
+        }),
+        .yaml => try output.formatYaml(allocator, constraint_set.*),
+        .pretty => try output.formatPretty(allocator, constraint_set.*),
+        .ariadne => try output.formatAriadne(allocator, constraint_set.*),
+        .stats, .stats_json => blk: {
+            var summary = try summary_mod.Summary.compute(allocator, constraint_set.constraints.items, files, options.top_n);
+            defer summary.deinit();
+            break :blk if (options.format == .stats)
+                try summary_mod.formatTable(allocator, summary)
+            else
+                try summary_mod.formatJson(allocator, summary);
+        },
+        .prompt_pack => try prompt_pack.formatPromptPack(allocator, constraint_set.*, files, options.pack_options),
+        .cyclonedx => try cyclonedx.formatCycloneDx(allocator, constraint_set.*, files, .{
+            .tool_version = version.VERSION,
+            .component_name = parsed_args.getFlagOr("bom-component", component_name),
+            .component_version = parsed_args.getFlag("bom-version"),
+        }),
+        .patch => try formatPatch(allocator, constraint_set.*, files, result.sources.items, options.concurrency.render),
+        .markdown => try report.formatMarkdown(allocator, constraint_set.*, files, &catalog),
+        .html => try report.formatHtml(allocator, constraint_set.*, &catalog, null),
+        .codequality => try codequality.formatCodeQuality(allocator, constraint_set.*),
+        .sonarqube => try sonarqube.formatSonarQube(allocator, constraint_set.*),
+        .requirements => try requirements.formatRequirements(allocator, constraint_set.*, files, result.sources.items),
+        .techdocs, .sidecar => unreachable,
     };
     defer allocator.free(output_text);
 
-    // Write output
-    if (output_file) |path| {
-        const file = std.fs.cwd().createFile(path, .{}) catch |err| {
-            cli_error.printFileError(err, path);
-            return err;
-        };
-        defer file.close();
+    try writeOutput(options.output_file, output_text);
+}
 
-        try file.writeAll(output_text);
-        cli_error.printSuccess("Output written to {s}", .{path});
-    } else {
-        // Write formatted output to stdout (not stderr) so it can be piped
-        const stdout_file = std.fs.File.stdout();
-        try stdout_file.writeAll(output_text);
+/// Last change to each constraint's line for --blame. Without git, the
+/// output goes out unannotated.
+fn blameConstraints(result: *Result, verbose: bool) ![]const ?blame.Authorship {
+    const constraints = result.constraint_set.constraints.items;
+    const authorship = blame.annotate(result.arena.allocator(), constraints) catch |err| switch (err) {
+        git.GitError.GitNotFound => {
+            cli_error.printWarning("git not found; constraints are written without blame", .{});
+            return &.{};
+        },
+        else => return err,
+    };
+    if (verbose) {
+        var found: usize = 0;
+        for (authorship) |a| {
+            if (a != null) found += 1;
+        }
+        cli_error.printInfo("Blamed {d} of {d} constraints; the rest are untracked or uncommitted", .{ found, constraints.len });
+    }
+    return authorship;
+}
+
+/// Package summaries for --summarize. Endpoint failures leave packages
+/// unsummarized and never fail the run.
+fn summarizeConstraints(allocator: std.mem.Allocator, result: *Result, settings: summarize.Options, verbose: bool) ![]const summarize.Summary {
+    const arena = result.arena.allocator();
+    var options = settings;
+    options.api_key = try getEnv(arena, "ANANKE_SUMMARIZE_API_KEY") orelse
+        try getEnv(arena, if (options.provider == .anthropic) "ANTHROPIC_API_KEY" else "OPENAI_API_KEY");
+
+    var client = std.http.Client{ .allocator = allocator };
+    defer client.deinit();
+    const outcome = try summarize.summarize(arena, &client, result.constraint_set.constraints.items, options);
+
+    const stats = outcome.stats;
+    if (outcome.stopped) |err| {
+        cli_error.printWarning("Stopped summary requests to {s}: {s}", .{ options.endpoint, @errorName(err) });
+    } else if (stats.skipped > 0) {
+        cli_error.printWarning("{d} packages left unsummarized; raise max_requests under [summarize] to allow more than {d} requests", .{ stats.skipped, options.max_requests });
+    }
+    if (stats.failed > 0) {
+        cli_error.printWarning("{d} package summaries failed", .{stats.failed});
+    }
+    if (verbose) {
+        cli_error.printInfo("Summarized {d} packages ({d} from cache, {d} requests)", .{ outcome.summaries.len, stats.cached, stats.requested });
     }
+    return outcome.summaries;
+}
+
+fn getEnv(arena: std.mem.Allocator, name: []const u8) !?[]const u8 {
+    return std.process.getEnvVarOwned(arena, name) catch |err| switch (err) {
+        error.EnvironmentVariableNotFound => null,
+        else => return err,
+    };
+}
+
+/// Report strings from --messages or `[report] messages`; English when neither is set
+fn loadCatalog(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !messages.Catalog {
+    const path = parsed_args.getFlag("messages") orelse config.report_messages orelse return messages.Catalog.default();
+    return messages.Catalog.loadFile(allocator, path) catch |err| {
+        cli_error.printFileError(err, path);
+        return err;
+    };
 }
 
-fn detectLanguage(file_path: []const u8) []const u8 {
-    if (std.mem.endsWith(u8, file_path, ".ts") or std.mem.endsWith(u8, file_path, ".tsx")) {
-        return "typescript";
-    } else if (std.mem.endsWith(u8, file_path, ".js") or std.mem.endsWith(u8, file_path, ".jsx")) {
-        return "javascript";
-    } else if (std.mem.endsWith(u8, file_path, ".py")) {
-        return "python";
-    } else if (std.mem.endsWith(u8, file_path, ".rs")) {
-        return "rust";
-    } else if (std.mem.endsWith(u8, file_path, ".go")) {
-        return "go";
-    } else if (std.mem.endsWith(u8, file_path, ".java")) {
-        return "java";
-    } else if (std.mem.endsWith(u8, file_path, ".zig")) {
-        return "zig";
-    } else if (std.mem.endsWith(u8, file_path, ".c")) {
-        return "c";
-    } else if (std.mem.endsWith(u8, file_path, ".cpp") or std.mem.endsWith(u8, file_path, ".cc")) {
-        return "cpp";
-    } else if (std.mem.endsWith(u8, file_path, ".kt") or std.mem.endsWith(u8, file_path, ".kts")) {
-        return "kotlin";
-    } else if (std.mem.endsWith(u8, file_path, ".cs")) {
-        return "csharp";
-    } else if (std.mem.endsWith(u8, file_path, ".rb") or std.mem.endsWith(u8, file_path, ".rake") or std.mem.endsWith(u8, file_path, ".gemspec")) {
-        return "ruby";
-    } else if (std.mem.endsWith(u8, file_path, ".php")) {
-        return "php";
-    } else if (std.mem.endsWith(u8, file_path, ".swift")) {
-        return "swift";
-    }
-    return "unknown";
+/// Write the TechDocs site of the run to the --output directory. The owning
+/// component comes from --techdocs-component, else the nearest catalog-info.yaml,
+/// else the target name.
+fn writeTechDocs(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    config: config_mod.Config,
+    options: Options,
+    result: *Result,
+    component_name: []const u8,
+) !void {
+    const dir_path = options.output_file.?;
+    var catalog = try loadCatalog(allocator, parsed_args, config);
+    defer catalog.deinit();
+
+    var component = if (parsed_args.getFlag("techdocs-component")) |name|
+        techdocs.Component{ .name = name }
+    else
+        try techdocs.findComponent(result.arena.allocator(), result.files.items) orelse techdocs.Component{ .name = component_name };
+    if (parsed_args.getFlag("techdocs-owner")) |owner| component.owner = owner;
+    if (component.owner == null) {
+        cli_error.printWarning("No owner found for component {s}; pass --techdocs-owner or set spec.owner in its {s}", .{ component.name, techdocs.catalog_file });
+    }
+
+    techdocs.writeSite(allocator, dir_path, result.constraint_set, component, &catalog) catch |err| {
+        cli_error.printFileError(err, dir_path);
+        return err;
+    };
+    cli_error.printSuccess("TechDocs site for component:{s}/{s} written to {s}", .{ component.namespace, component.name, dir_path });
+}
+
+/// Write a .ananke.json sidecar for each extracted file, next to it or under
+/// the --output directory. Redacted runs leave the source text out of anchors.
+fn writeSidecarFiles(allocator: std.mem.Allocator, options: Options, result: *Result) !void {
+    const written = sidecar.writeSidecars(
+        allocator,
+        version.VERSION,
+        result.constraint_set,
+        result.files.items,
+        result.sources.items,
+        options.output_file,
+        !options.redact,
+    ) catch |err| {
+        cli_error.printFileError(err, options.output_file orelse ".");
+        return err;
+    };
+    cli_error.printSuccess("Wrote {d} sidecar files", .{written.written});
+    if (written.removed > 0 and options.verbose) {
+        cli_error.printInfo("Removed {d} stale sidecar files of files without constraints", .{written.removed});
+    }
+}
+
+/// Write rendered output to `output_file`, or to stdout when unset
+pub fn writeOutput(output_file: ?[]const u8, output_text: []const u8) !void {
+    if (output_file) |path| {
+        const file = std.fs.cwd().createFile(path, .{}) catch |err| {
+            cli_error.printFileError(err, path);
+            return err;
+        };
+        defer file.close();
+
+        try file.writeAll(output_text);
+        cli_error.printSuccess("Output written to {s}", .{path});
+    } else {
+        // Write formatted output to stdout (not stderr) so it can be piped
+        const stdout_file = std.fs.File.stdout();
+        try stdout_file.writeAll(output_text);
+    }
+}
+
+/// Split a workspace file into target paths: one per line, `#` starts a
+/// comment. Paths are relative to the workspace file's directory.
+pub fn parseWorkspace(allocator: std.mem.Allocator, workspace_path: []const u8, content: []const u8) !std.ArrayList([]const u8) {
+    var targets = std.ArrayList([]const u8){};
+    errdefer targets.deinit(allocator);
+    const base = std.fs.path.dirname(workspace_path);
+
+    var lines = std.mem.splitScalar(u8, content, '\n');
+    while (lines.next()) |raw| {
+        const line = std.mem.trim(u8, raw[0 .. std.mem.indexOfScalar(u8, raw, '#') orelse raw.len], " \t\r");
+        if (line.len == 0) continue;
+        try targets.append(allocator, if (base) |dir| try std.fs.path.join(allocator, &.{ dir, line }) else line);
+    }
+    return targets;
+}
+
+/// Output file name for one target in --split mode: the target path with
+/// separators flattened, so sibling checkouts never collide
+pub fn splitOutputName(allocator: std.mem.Allocator, target: []const u8, format: output.OutputFormat) ![]u8 {
+    var name = std.mem.trimRight(u8, target, "/");
+    while (std.mem.startsWith(u8, name, "./")) name = name[2..];
+    if (name.len == 0 or std.mem.eql(u8, name, ".")) name = "root";
+
+    // Formats without an extension write a directory per target
+    const file_name = if (format.extension().len == 0)
+        try allocator.dupe(u8, name)
+    else
+        try std.fmt.allocPrint(allocator, "{s}.{s}", .{ name, format.extension() });
+    std.mem.replaceScalar(u8, file_name[0..name.len], '/', '_');
+    return file_name;
+}
+
+/// One extraction root after validation and discovery
+const Target = struct {
+    /// Path as given on the command line
+    path: []const u8,
+    validated_path: []u8,
+    is_dir: bool,
+    inputs: discovery.FileSet,
+    /// Set for --incremental runs of a directory inside a git repository
+    incremental: ?IncrementalRun = null,
+
+    fn deinit(self: *Target, allocator: std.mem.Allocator) void {
+        if (self.incremental) |*state| state.arena.deinit();
+        self.inputs.deinit();
+        allocator.free(self.validated_path);
+    }
+};
+
+/// State of an --incremental run, from discovery until its manifest is saved
+const IncrementalRun = struct {
+    arena: std.heap.ArenaAllocator,
+    key: incremental.Key,
+    /// HEAD when the run started, recorded in the new manifest
+    commit: []const u8,
+    /// Files to merge from the cache instead of reading them
+    carried: []const incremental.Entry,
+    /// Paths that differ from `commit`; the manifest lists them as volatile
+    dirty: std.StringHashMapUnmanaged(void) = .{},
+};
+
+/// Validate `path` and collect its inputs: the file itself, or every
+/// supported file under the directory
+fn openTarget(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    config: config_mod.Config,
+    options: Options,
+    path: []const u8,
+    excludes: []const []const u8,
+) !Target {
+    // Validate and resolve input path (security: prevent path traversal)
+    const validated_path = path_validator.validatePath(
+        allocator,
+        path,
+        false, // Don't allow absolute paths by default
+    ) catch |err| {
+        if (err == path_validator.PathValidationError.PathTraversalAttempt) {
+            cli_error.printError("Path traversal attempt detected: {s}", .{path});
+            cli_error.printInfo("Only relative paths within the current directory are allowed.", .{});
+            return error.InvalidPath;
+        }
+        if (err == error.FileNotFound) {
+            error_help.printFileNotFoundError(path, allocator);
+        } else {
+            cli_error.printFileError(err, path);
+        }
+        return err;
+    };
+    errdefer allocator.free(validated_path);
+
+    const stat = std.fs.cwd().statFile(validated_path) catch |err| {
+        cli_error.printFileError(err, path);
+        return err;
+    };
+    const is_dir = stat.kind == .directory;
+
+    const discovery_options = discovery.Options{
+        .excludes = excludes,
+        .use_gitignore = config.extract_gitignore and !parsed_args.hasFlag("no-gitignore"),
+        .use_default_excludes = !parsed_args.hasFlag("no-default-excludes"),
+        .language = options.language,
+    };
+    var incremental_run: ?IncrementalRun = null;
+    errdefer if (incremental_run) |*state| state.arena.deinit();
+    const inputs = if (is_dir and options.scope_targets != null)
+        try discoverScoped(allocator, path, options, discovery_options)
+    else if (is_dir and options.changed_since != null)
+        try discoverChanged(allocator, path, options.changed_since.?, discovery_options)
+    else if (is_dir and options.incremental and !parsed_args.hasFlag("dry-run"))
+        try discoverIncremental(allocator, parsed_args, options, path, discovery_options, &incremental_run)
+    else if (is_dir)
+        try discoverWarm(allocator, parsed_args, options, path, discovery_options)
+    else blk: {
+        var single = discovery.FileSet.init(allocator);
+        errdefer single.deinit();
+        try single.addFile(path, options.language orelse discovery.detectLanguage(path));
+        break :blk single;
+    };
+
+    return .{
+        .path = path,
+        .validated_path = validated_path,
+        .is_dir = is_dir,
+        .inputs = inputs,
+        .incremental = incremental_run,
+    };
+}
+
+/// Discover `path`, reusing the warm-start snapshot in the cache directory
+/// when none of the directories or .gitignore files it was built from
+/// changed. --dry-run always walks: its plan names the pattern behind each
+/// skip, which snapshots do not keep.
+fn discoverWarm(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    options: Options,
+    path: []const u8,
+    discovery_options: discovery.Options,
+) !discovery.FileSet {
+    const cache_path = if (parsed_args.hasFlag("dry-run")) null else options.cache_dir;
+    var cache_dir: ?std.fs.Dir = if (cache_path) |dir| std.fs.cwd().makeOpenPath(dir, .{}) catch null else null;
+    defer if (cache_dir) |*dir| dir.close();
+
+    const key = warm_state.discoveryKey(version.VERSION, path, discovery_options);
+    if (cache_dir) |dir| {
+        if (try warm_state.loadDiscovery(allocator, dir, &key, path, discovery_options.use_gitignore)) |set| {
+            if (options.verbose) cli_error.printInfo("Reusing discovery snapshot for {s}", .{path});
+            return set;
+        }
+    }
+
+    var set = discovery.discover(allocator, path, discovery_options) catch |err| {
+        cli_error.printFileError(err, path);
+        return err;
+    };
+    errdefer set.deinit();
+    // A snapshot that cannot be written only costs the next run a walk
+    if (cache_dir) |dir| warm_state.saveDiscovery(allocator, dir, &key, &set) catch {};
+    return set;
+}
+
+/// Inputs under `path` that changed since `base` according to git, without
+/// walking the directory
+fn discoverChanged(
+    allocator: std.mem.Allocator,
+    path: []const u8,
+    base: []const u8,
+    options: discovery.Options,
+) !discovery.FileSet {
+    var arena = std.heap.ArenaAllocator.init(allocator);
+    defer arena.deinit();
+    var changed = git.changedPaths(allocator, arena.allocator(), base, path) catch |err| {
+        switch (err) {
+            git.GitError.GitNotFound => cli_error.printError("git executable not found in PATH", .{}),
+            git.GitError.InvalidRevision => cli_error.printError("Invalid revision: {s}", .{base}),
+            git.GitError.UnknownRevision => {
+                cli_error.printError("Unknown revision: {s}", .{base});
+                cli_error.printInfo("--changed-since needs a git repository with the ref fetched", .{});
+            },
+            else => cli_error.printError("Failed to list files changed since {s}: {s}", .{ base, @errorName(err) }),
+        }
+        return err;
+    };
+    defer changed.deinit(allocator);
+    return discovery.discoverListed(allocator, path, changed.items, options);
+}
+
+/// Inputs under `path` that the --scope-target targets are built from,
+/// listed by the build system instead of walking the directory
+fn discoverScoped(
+    allocator: std.mem.Allocator,
+    path: []const u8,
+    options: Options,
+    discovery_options: discovery.Options,
+) !discovery.FileSet {
+    const targets = options.scope_targets.?;
+    const system = options.build_system orelse build_graph.System.detect(std.fs.cwd()) orelse {
+        cli_error.printError("--scope-target needs a Bazel or Go workspace: run from its root or pass --build-system", .{});
+        return error.InvalidArgument;
+    };
+
+    var arena = std.heap.ArenaAllocator.init(allocator);
+    defer arena.deinit();
+    const sources = build_graph.transitiveSources(arena.allocator(), system, targets) catch |err| {
+        switch (err) {
+            build_graph.BuildGraphError.ToolNotFound => cli_error.printError("{s} executable not found in PATH", .{@tagName(system)}),
+            build_graph.BuildGraphError.QueryFailed => cli_error.printError("{s} could not list the sources of {s}", .{ @tagName(system), targets }),
+            else => cli_error.printError("Failed to list the sources of {s}: {s}", .{ targets, @errorName(err) }),
+        }
+        return err;
+    };
+    if (options.verbose) {
+        cli_error.printInfo("{s} lists {d} source files for {s}", .{ @tagName(system), sources.len, targets });
+    }
+    return discovery.discoverListed(allocator, path, sources, discovery_options);
+}
+
+/// Inputs under `path` for an --incremental run: the files git reports as
+/// changed since the commit of the last run's manifest, or every file when
+/// there is no usable manifest yet. Sets `state` for merging and recording;
+/// outside a git repository it stays null and the run extracts everything.
+fn discoverIncremental(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    options: Options,
+    path: []const u8,
+    discovery_options: discovery.Options,
+    state: *?IncrementalRun,
+) !discovery.FileSet {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    var owned = true;
+    defer if (owned) arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const head = git.resolveCommit(arena, "HEAD") catch |err| {
+        cli_error.printWarning("--incremental needs a git repository with a commit ({s}); extracting every file", .{@errorName(err)});
+        return discoverWarm(allocator, parsed_args, options, path, discovery_options);
+    };
+    var cache_dir = std.fs.cwd().makeOpenPath(options.cache_dir.?, .{}) catch |err| {
+        cli_error.printWarning("--incremental disabled: cannot open {s}: {s}", .{ options.cache_dir.?, @errorName(err) });
+        return discoverWarm(allocator, parsed_args, options, path, discovery_options);
+    };
+    defer cache_dir.close();
+
+    // Settings that change what a file's cache entry holds
+    var settings_buf: [128]u8 = undefined;
+    const settings = std.fmt.bufPrint(&settings_buf, "{x} {s} {d} {d} {s}", .{
+        options.kinds.bits.mask,
+        @tagName(options.generated),
+        options.limits.max_bytes,
+        options.limits.max_lines,
+        @tagName(options.limits.action),
+    }) catch unreachable;
+    const discovery_key = warm_state.discoveryKey(version.VERSION, path, discovery_options);
+    const key = incremental.manifestKey(&discovery_key, settings);
+
+    var manifest = incremental.load(arena, cache_dir, &key);
+    var changes = std.ArrayList(git.Change){};
+    defer changes.deinit(allocator);
+    if (manifest) |m| {
+        changes = git.changesSince(allocator, arena, m.commit, path) catch blk: {
+            // A rebase or shallow fetch can drop the manifest's commit
+            cli_error.printInfo("Commit {s} of the last incremental run is gone; extracting every file", .{m.commit});
+            manifest = null;
+            break :blk .{};
+        };
+    }
+    // What differs from HEAD is re-read next time; with HEAD unchanged since
+    // the manifest, that is exactly what git just reported
+    var dirty = std.ArrayList(git.Change){};
+    defer dirty.deinit(allocator);
+    const same_commit = if (manifest) |m| std.mem.eql(u8, m.commit, head) else false;
+    if (!same_commit) {
+        dirty = git.changesSince(allocator, arena, head, path) catch |err| {
+            cli_error.printWarning("--incremental disabled: cannot list changes in {s}: {s}", .{ path, @errorName(err) });
+            return discoverWarm(allocator, parsed_args, options, path, discovery_options);
+        };
+    }
+
+    var run_state = IncrementalRun{ .arena = undefined, .key = key, .commit = head, .carried = &.{} };
+    for (if (same_commit) changes.items else dirty.items) |change| {
+        try run_state.dirty.put(arena, incremental.normalize(change.path), {});
+        if (change.from) |from| try run_state.dirty.put(arena, incremental.normalize(from), {});
+    }
+
+    const set = if (manifest) |m| blk: {
+        const planned = try incremental.plan(arena, m, changes.items);
+        run_state.carried = planned.carried.items;
+        break :blk try discovery.discoverListed(allocator, path, planned.extract.items, discovery_options);
+    } else try discoverWarm(allocator, parsed_args, options, path, discovery_options);
+
+    if (options.verbose) {
+        if (manifest) |m| {
+            cli_error.printInfo("Incremental: {d} files unchanged since {s}, {d} to extract", .{
+                run_state.carried.len,
+                m.commit[0..@min(m.commit.len, 12)],
+                set.files.items.len,
+            });
+        } else {
+            cli_error.printInfo("Incremental: no earlier run recorded for {s}; extracting every file", .{path});
+        }
+    }
+
+    run_state.arena = arena_state;
+    owned = false;
+    state.* = run_state;
+    return set;
+}
+
+/// Merge the files an --incremental run carries over from the cache. Files
+/// whose entries are gone (gc, clear) are queued for extraction instead.
+fn mergeCarried(result: *Result, cache: ?*cache_store.Cache, target: *Target) !void {
+    const state = if (target.incremental) |*state| state else return;
+    var kept = std.ArrayList(incremental.Entry){};
+    for (state.carried) |file| {
+        const loaded = if (cache) |c| c.load(&file.key) else null;
+        const entry = loaded orelse {
+            try target.inputs.addFile(file.path, file.language);
+            continue;
+        };
+        try result.addCached(file, entry);
+        try kept.append(state.arena.allocator(), file);
+    }
+    state.carried = kept.items;
+}
+
+/// Record which cache entry holds each file of an --incremental run, for the
+/// next one. Files that differ from HEAD, were not cached, or could not be
+/// read are listed as volatile. A manifest that cannot be written only
+/// costs the next run a full extraction.
+fn saveIncremental(
+    allocator: std.mem.Allocator,
+    cache: *cache_store.Cache,
+    target: *Target,
+    result: *Result,
+    files: []const discovery.SourceFile,
+) !void {
+    const state = if (target.incremental) |*state| state else return;
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var merged = std.StringHashMap(summary_mod.FileInfo).init(arena);
+    for (result.files.items) |info| try merged.put(info.path, info);
+
+    var entries = std.ArrayList(incremental.Entry){};
+    var recorded = std.StringHashMap(void).init(arena);
+    for (state.carried) |file| {
+        if (state.dirty.contains(incremental.normalize(file.path))) continue;
+        try entries.append(arena, file);
+        try recorded.put(incremental.normalize(file.path), {});
+    }
+    for (files) |file| {
+        if (state.dirty.contains(incremental.normalize(file.path))) continue;
+        const info = merged.get(file.path) orelse continue;
+        try entries.append(arena, .{
+            .key = result.cacheKey(file),
+            .path = file.path,
+            .language = file.language,
+            .line_count = info.line_count,
+            .content_hash = info.content_hash,
+        });
+        try recorded.put(incremental.normalize(file.path), {});
+    }
+
+    var volatile_paths = std.ArrayList([]const u8){};
+    for (state.carried) |file| {
+        if (!recorded.contains(incremental.normalize(file.path))) try volatile_paths.append(arena, file.path);
+    }
+    for (target.inputs.files.items) |input| {
+        if (!recorded.contains(incremental.normalize(input.path))) try volatile_paths.append(arena, input.path);
+    }
+
+    var rules = std.ArrayList(incremental.RuleSet){};
+    for (entries.items) |entry| {
+        for (rules.items) |existing| {
+            if (std.mem.eql(u8, existing.language, entry.language)) break;
+        } else try rules.append(arena, .{ .language = entry.language, .digest = plan.rulesDigest(entry.language) });
+    }
+
+    try incremental.save(allocator, cache.dir, &state.key, .{
+        .commit = state.commit,
+        .rules = rules.items,
+        .entries = entries.items,
+        .volatile_paths = volatile_paths.items,
+    });
+}
+
+fn reportDiscovery(target: *const Target) void {
+    if (target.is_dir) {
+        cli_error.printInfo("Discovered {d} source files under {s} ({d} excluded, {d} gitignored, {d} unsupported)", .{
+            target.inputs.files.items.len,
+            target.path,
+            target.inputs.skippedCount(.excluded),
+            target.inputs.skippedCount(.gitignored),
+            target.inputs.skippedCount(.unsupported_language),
+        });
+    } else {
+        cli_error.printInfo("Detected language: {s}", .{target.inputs.files.items[0].language});
+    }
+}
+
+/// Read a target's inputs into `sources` (owned) and `files`. Files over
+/// `max_source_bytes` go to `large` for streaming. Unreadable files are
+/// skipped with a warning inside directories and fatal otherwise.
+fn loadTarget(
+    allocator: std.mem.Allocator,
+    target: *const Target,
+    options: Options,
+    sources: *std.ArrayList([]u8),
+    files: *std.ArrayList(discovery.SourceFile),
+    large: *std.ArrayList(discovery.DiscoveredFile),
+) !void {
+    const loaded = try readSources(allocator, target.inputs.files.items, options.concurrency.parse, options.throttle);
+    defer allocator.free(loaded);
+    // Take ownership of every source before reporting failures so none leak
+    try sources.ensureUnusedCapacity(allocator, loaded.len);
+    for (loaded) |slot| {
+        if (slot.value) |source| sources.appendAssumeCapacity(source);
+    }
+
+    for (target.inputs.files.items, loaded) |input, slot| {
+        const source = slot.value orelse {
+            const err = slot.err orelse error.Unexpected;
+            if (err == error.FileTooBig) {
+                try large.append(allocator, input);
+                continue;
+            }
+            if (target.is_dir) {
+                cli_error.printWarning("Skipping {s}: {s}", .{ input.path, @errorName(err) });
+                continue;
+            }
+            if (err == error.FileNotFound) {
+                error_help.printFileNotFoundError(input.path, allocator);
+            } else {
+                cli_error.printFileError(err, input.path);
+            }
+            return err;
+        };
+        try files.append(allocator, .{ .path = input.path, .language = input.language, .source = source });
+    }
+}
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    // Check for help flag
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    var targets_arena = std.heap.ArenaAllocator.init(allocator);
+    defer targets_arena.deinit();
+    var targets = std.ArrayList([]const u8){};
+    try targets.appendSlice(targets_arena.allocator(), parsed_args.positional.items);
+    if (parsed_args.getFlag("workspace")) |workspace_path| {
+        const content = std.fs.cwd().readFileAlloc(targets_arena.allocator(), workspace_path, 1024 * 1024) catch |err| {
+            cli_error.printFileError(err, workspace_path);
+            return err;
+        };
+        const listed = try parseWorkspace(targets_arena.allocator(), workspace_path, content);
+        try targets.appendSlice(targets_arena.allocator(), listed.items);
+        if (listed.items.len == 0) {
+            cli_error.printError("Workspace file lists no targets: {s}", .{workspace_path});
+            return error.InvalidArgument;
+        }
+    }
+
+    // Get required file argument
+    if (targets.items.len == 0) {
+        cli_error.printError("Missing required argument: <path>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    }
+
+    var options = try Options.parse(parsed_args, config);
+    if (parsed_args.hasFlag("action-key") or parsed_args.getFlag("action-record") != null) {
+        if (targets.items.len > 1 or options.incremental or options.use_claude) {
+            cli_error.printError("--action-key and --action-record take a single target and cannot be combined with --incremental or --use-claude", .{});
+            return error.InvalidArgument;
+        }
+        if (options.format == .cyclonedx) {
+            cli_error.printError("cyclonedx output carries a random serial number and a timestamp, so it has no action key", .{});
+            return error.InvalidArgument;
+        }
+        if (parsed_args.getFlag("action-record") != null and options.output_file == null and options.write_baseline == null) {
+            cli_error.printError("--action-record needs --output: it records the digest of the file written", .{});
+            return error.MissingArgument;
+        }
+    }
+    if (options.scope_targets != null and options.changed_since != null) {
+        cli_error.printError("--scope-target cannot be combined with --changed-since", .{});
+        return error.InvalidArgument;
+    }
+    if (options.incremental) {
+        if (options.changed_since != null or options.scope_targets != null) {
+            cli_error.printError("--incremental cannot be combined with --changed-since or --scope-target", .{});
+            return error.InvalidArgument;
+        }
+        if (options.cache_dir == null) {
+            cli_error.printError("--incremental merges unchanged files from the cache and cannot be combined with --no-cache or --use-claude", .{});
+            return error.InvalidArgument;
+        }
+        if (targets.items.len > 1) {
+            cli_error.printError("--incremental takes a single directory", .{});
+            return error.InvalidArgument;
+        }
+    }
+
+    // Stage heap figures need every allocation to go through `counting`
+    var counting = profiling.CountingAllocator.init(allocator);
+    var profiler = profiling.Profiler.init(allocator, &counting);
+    defer profiler.deinit();
+    if (options.verbose or options.timings) options.profiler = &profiler;
+    var throttle = jobs.Throttle.init(options.io_rate);
+    if (options.io_rate > 0) options.throttle = &throttle;
+    const tracked = if (options.profiler != null) counting.allocator() else allocator;
+    if (options.rule_timings) ananke.clew.patterns.setRuleTiming(true);
+    const destination = try tracing.Destination.resolve(targets_arena.allocator(), parsed_args.getFlag("trace-endpoint"), parsed_args.getFlag("trace-file"));
+    var tracer: tracing.Tracer = undefined;
+    if (destination.enabled()) {
+        tracer = try tracing.Tracer.init(allocator, "ananke.extract");
+        options.tracer = &tracer;
+    }
+    defer if (options.tracer) |t| t.deinit();
+
+    const outcome = if (targets.items.len > 1)
+        runTargets(tracked, parsed_args, config, options, targets.items)
+    else
+        runTarget(tracked, parsed_args, config, options, targets.items[0]);
+    // A failed run is exported too: its trace shows where it stopped
+    if (options.tracer) |t| {
+        const failed = if (outcome) |_| false else |_| true;
+        t.finish(&.{tracing.Attribute.int("ananke.targets", targets.items.len)}, failed);
+        tracing.flush(allocator, t, destination, version.VERSION, options.verbose);
+    }
+    try outcome;
+    if (options.verbose) extract_trace.reportTimings(&profiler);
+    if (options.rule_timings) try extract_trace.reportRuleTimings(allocator);
+}
+
+/// Extract one file, directory, or stdin ("-")
+fn runTarget(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    config: config_mod.Config,
+    options: Options,
+    file_path: []const u8,
+) !void {
+    if (options.verbose) {
+        cli_error.printInfo("Extracting constraints from: {s}", .{file_path});
+        if (options.use_claude) {
+            cli_error.printInfo("Claude semantic analysis: enabled", .{});
+        }
+        cli_error.printInfo("Confidence threshold: {d:.1}%", .{options.confidence_threshold * 100});
+    }
+
+    // "-" reads an unsaved buffer from stdin, named by --stdin-filename
+    const is_stdin = std.mem.eql(u8, file_path, "-");
+    const stdin_name = parsed_args.getFlagOr("stdin-filename", "<stdin>");
+    if (is_stdin and options.language == null and
+        std.mem.eql(u8, discovery.detectLanguage(stdin_name), "unknown"))
+    {
+        cli_error.printError("--lang is required when reading from stdin", .{});
+        cli_error.printInfo("Example: cat main.go | ananke extract - --lang go", .{});
+        return error.MissingArgument;
+    }
+
+    var excludes = try collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+
+    options.stages().begin("discovery");
+    var target = if (is_stdin) blk: {
+        var single = discovery.FileSet.init(allocator);
+        errdefer single.deinit();
+        try single.addFile(stdin_name, options.language orelse discovery.detectLanguage(stdin_name));
+        break :blk Target{
+            .path = file_path,
+            .validated_path = try allocator.dupe(u8, stdin_name),
+            .is_dir = false,
+            .inputs = single,
+        };
+    } else try openTarget(allocator, parsed_args, config, options, file_path, excludes.items);
+    defer target.deinit(allocator);
+    try options.stages().end();
+
+    if (options.verbose) reportDiscovery(&target);
+
+    if (parsed_args.hasFlag("dry-run")) {
+        const plan_text = try plan.formatPlan(allocator, &target.inputs, file_path, options.language);
+        defer allocator.free(plan_text);
+        try writeOutput(options.output_file, plan_text);
+        return;
+    }
+
+    const carried = if (target.incremental) |state| state.carried.len else 0;
+    if (target.inputs.files.items.len == 0 and carried == 0) {
+        cli_error.printWarning("No supported source files found under {s}", .{file_path});
+        cli_error.printInfo("Check --exclude patterns and .gitignore rules, or use --verbose", .{});
+        return;
+    }
+
+    // Initialize Ananke (and Claude, if requested)
+    var claude_client: ?ananke.api.claude.ClaudeClient = null;
+    defer if (claude_client) |*client| client.deinit();
+    var engine = try initEngine(allocator, config, options, &claude_client);
+    defer engine.deinit();
+
+    if (options.verbose and target.is_dir) {
+        cli_error.printInfo("Workers: {d} parse, {d} analyze, {d} render", .{
+            options.concurrency.parse,
+            options.concurrency.analyze,
+            options.concurrency.render,
+        });
+    }
+
+    var sources = std.ArrayList([]u8){};
+    defer {
+        for (sources.items) |source| allocator.free(source);
+        sources.deinit(allocator);
+    }
+    var files = std.ArrayList(discovery.SourceFile){};
+    defer files.deinit(allocator);
+    var large = std.ArrayList(discovery.DiscoveredFile){};
+    defer large.deinit(allocator);
+    var result = Result.init(allocator);
+    defer result.deinit();
+    result.generated = options.generated;
+    result.limits = options.limits;
+    result.throttle = options.throttle;
+    result.tracer = options.tracer;
+    result.pipeline_memory = options.pipeline_memory;
+
+    var cache = openCache(allocator, options);
+    defer if (cache) |*c| c.close();
+    if (cache) |*c| result.cache = c;
+    try mergeCarried(&result, if (cache) |*c| c else null, &target);
+
+    var spinner = output.Spinner.init("Extracting constraints...");
+    options.stages().begin("parse");
+    if (is_stdin) {
+        const input = target.inputs.files.items[0];
+        const source = std.fs.File.stdin().readToEndAlloc(allocator, max_source_bytes) catch |err| {
+            cli_error.printFileError(err, input.path);
+            return err;
+        };
+        sources.append(allocator, source) catch |err| {
+            allocator.free(source);
+            return err;
+        };
+        try files.append(allocator, .{ .path = input.path, .language = input.language, .source = source });
+    } else {
+        try loadTarget(allocator, &target, options, &sources, &files, &large);
+    }
+    try options.stages().end();
+
+    const action_record = parsed_args.getFlag("action-record");
+    const action_key = if (parsed_args.hasFlag("action-key") or action_record != null)
+        try actionKey(allocator, parsed_args, config, options, &target, &result, files.items, large.items)
+    else
+        null;
+    if (parsed_args.hasFlag("action-key")) {
+        try writeOutput(null, &action_key.?);
+        try writeOutput(null, "\n");
+        return;
+    }
+
+    options.stages().begin("analyze");
+    try result.addAll(&engine, files.items, options.concurrency.analyze);
+    try addLarge(&result, &engine, large.items, options.verbose);
+    try options.stages().end();
+    spinner.finish("Extraction complete");
+    if (cache) |*c| {
+        extract_cache.finish(c, options.verbose);
+        saveIncremental(allocator, c, &target, &result, files.items) catch |err| {
+            cli_error.printWarning("Could not record this run for --incremental: {s}", .{@errorName(err)});
+        };
+    }
+
+    try render(allocator, parsed_args, config, options, &result, std.fs.path.stem(target.validated_path));
+    if (action_record) |record_path| try writeActionRecord(allocator, &action_key.?, options, record_path);
+}
+
+/// Action key of an extraction (see action.zig): its output settings and
+/// inputs, sorted by path so discovery order never matters
+fn actionKey(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    config: config_mod.Config,
+    options: Options,
+    target: *const Target,
+    result: *Result,
+    files: []const discovery.SourceFile,
+    large: []const discovery.DiscoveredFile,
+) !action.Key {
+    var builder = action.Builder.init(version.VERSION);
+    var buf: [64]u8 = undefined;
+    builder.setting("component", std.fs.path.stem(target.validated_path));
+    builder.setting("format", @tagName(options.format));
+    builder.setting("confidence", std.fmt.bufPrint(&buf, "{d}", .{options.confidence_threshold}) catch unreachable);
+    builder.setting("redact", if (options.redact) "true" else "false");
+    builder.setting("kinds", std.fmt.bufPrint(&buf, "{x}", .{options.kinds.bits.mask}) catch unreachable);
+    builder.setting("generated", @tagName(options.generated));
+    builder.setting("limits", std.fmt.bufPrint(&buf, "{d} {d} {s}", .{
+        options.limits.max_bytes,
+        options.limits.max_lines,
+        @tagName(options.limits.action),
+    }) catch unreachable);
+    builder.setting("collapse-similar", std.fmt.bufPrint(&buf, "{d}", .{options.collapse_similar}) catch unreachable);
+    builder.setting("top", std.fmt.bufPrint(&buf, "{d}", .{options.top_n}) catch unreachable);
+    builder.setting("pack", std.fmt.bufPrint(&buf, "{d} {d}", .{
+        options.pack_options.token_budget,
+        options.pack_options.max_chunks,
+    }) catch unreachable);
+    builder.setting("write-baseline", if (options.write_baseline != null) "true" else "false");
+    builder.setting("bom-component", parsed_args.getFlag("bom-component") orelse "");
+    builder.setting("bom-version", parsed_args.getFlag("bom-version") orelse "");
+    // Files the output depends on besides the inputs
+    for ([_][]const u8{ "baseline", "messages" }, [_]?[]const u8{
+        options.baseline,
+        parsed_args.getFlag("messages") orelse config.report_messages,
+    }) |name, maybe_path| {
+        const path = maybe_path orelse {
+            builder.setting(name, "");
+            continue;
+        };
+        const digest = action.digestFile(path) catch |err| {
+            cli_error.printFileError(err, path);
+            return err;
+        };
+        builder.setting(name, &digest.sha256);
+    }
+
+    const Input = struct {
+        path: []const u8,
+        key: cache_store.Key,
+
+        fn lessThan(_: void, a: @This(), b: @This()) bool {
+            return std.mem.lessThan(u8, a.path, b.path);
+        }
+    };
+    var inputs = std.ArrayList(Input){};
+    defer inputs.deinit(allocator);
+    for (files) |file| try inputs.append(allocator, .{ .path = file.path, .key = result.cacheKey(file) });
+    for (large) |file| {
+        // Streamed files are never held whole; key their digest instead
+        const digest = try action.digestFile(file.path);
+        const rules = plan.rulesDigest(file.language);
+        try inputs.append(allocator, .{
+            .path = file.path,
+            .key = cache_store.computeKey(version.VERSION, &rules, file.language, &digest.sha256),
+        });
+    }
+    std.mem.sort(Input, inputs.items, {}, Input.lessThan);
+    for (inputs.items) |input| builder.input(input.path, &input.key);
+    return builder.final();
+}
+
+/// Write the --action-record for the files this run wrote
+fn writeActionRecord(allocator: std.mem.Allocator, key: *const action.Key, options: Options, record_path: []const u8) !void {
+    var outputs = std.ArrayList(action.Output){};
+    defer outputs.deinit(allocator);
+    for ([_]?[]const u8{ options.output_file, options.write_baseline }) |maybe_path| {
+        const path = maybe_path orelse continue;
+        // Runs that find nothing to report write no output
+        const digest = action.digestFile(path) catch |err| switch (err) {
+            error.FileNotFound => continue,
+            else => return err,
+        };
+        try outputs.append(allocator, digest);
+    }
+    const text = try action.formatRecord(allocator, key, outputs.items);
+    defer allocator.free(text);
+    std.fs.cwd().writeFile(.{ .sub_path = record_path, .data = text }) catch |err| {
+        cli_error.printFileError(err, record_path);
+        return err;
+    };
+}
+
+/// Stream each oversized input into `result`
+fn addLarge(result: *Result, engine: *ananke.Ananke, large: []const discovery.DiscoveredFile, verbose: bool) !void {
+    for (large) |input| {
+        if (verbose) {
+            cli_error.printInfo("Streaming {s} in {d} MiB chunks (larger than {d} MiB)", .{
+                input.path,
+                stream_chunk_bytes / (1024 * 1024),
+                max_source_bytes / (1024 * 1024),
+            });
+        }
+        result.addStreamed(engine, input) catch |err| {
+            cli_error.printWarning("Skipping {s}: {s}", .{ input.path, @errorName(err) });
+        };
+    }
+}
+
+/// Extract several roots in one invocation (polyrepo checkouts). Files from
+/// every target share one worker budget; output is one merged result, or
+/// one file per target in --output-dir with --split.
+fn runTargets(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    config: config_mod.Config,
+    options: Options,
+    paths: []const []const u8,
+) !void {
+    for (paths) |path| {
+        if (std.mem.eql(u8, path, "-")) {
+            cli_error.printError("stdin (\"-\") cannot be combined with other targets", .{});
+            return error.InvalidArgument;
+        }
+    }
+
+    const split = parsed_args.hasFlag("split");
+    const output_dir = parsed_args.getFlagOr("output-dir", ".");
+    if (split and options.output_file != null) {
+        cli_error.printError("--output cannot be combined with --split; use --output-dir", .{});
+        return error.InvalidArgument;
+    }
+    if (split and options.write_baseline != null) {
+        cli_error.printError("--write-baseline records one baseline and cannot be combined with --split", .{});
+        return error.InvalidArgument;
+    }
+    const parallel = try parsed_args.getFlagInt("parallel-targets", usize) orelse 1;
+    if (parallel == 0) {
+        cli_error.printError("--parallel-targets must be at least 1", .{});
+        return error.InvalidArgument;
+    }
+    if (parallel > 1 and !split) {
+        cli_error.printError("--parallel-targets writes one output per path and requires --split", .{});
+        return error.InvalidArgument;
+    }
+
+    var excludes = try collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+
+    var targets = std.ArrayList(Target){};
+    defer {
+        for (targets.items) |*target| target.deinit(allocator);
+        targets.deinit(allocator);
+    }
+    options.stages().begin("discovery");
+    for (paths) |path| {
+        var target = try openTarget(allocator, parsed_args, config, options, path, excludes.items);
+        targets.append(allocator, target) catch |err| {
+            target.deinit(allocator);
+            return err;
+        };
+        if (options.verbose) reportDiscovery(&targets.items[targets.items.len - 1]);
+    }
+    try options.stages().end();
+
+    if (parsed_args.hasFlag("dry-run")) {
+        var list = std.ArrayList(u8){};
+        defer list.deinit(allocator);
+        for (targets.items, 0..) |*target, i| {
+            const plan_text = try plan.formatPlan(allocator, &target.inputs, target.path, options.language);
+            defer allocator.free(plan_text);
+            if (i > 0) try list.append(allocator, '\n');
+            try list.appendSlice(allocator, plan_text);
+        }
+        try writeOutput(options.output_file, list.items);
+        return;
+    }
+
+    if (parallel > 1 and targets.items.len > 1) {
+        std.fs.cwd().makePath(output_dir) catch |err| {
+            cli_error.printFileError(err, output_dir);
+            return err;
+        };
+        return runTargetsConcurrently(allocator, parsed_args, config, options, targets.items, output_dir, parallel);
+    }
+
+    var claude_client: ?ananke.api.claude.ClaudeClient = null;
+    defer if (claude_client) |*client| client.deinit();
+    var engine = try initEngine(allocator, config, options, &claude_client);
+    defer engine.deinit();
+
+    if (options.verbose) {
+        cli_error.printInfo("Extracting {d} targets; workers: {d} parse, {d} analyze, {d} render", .{
+            targets.items.len,
+            options.concurrency.parse,
+            options.concurrency.analyze,
+            options.concurrency.render,
+        });
+    }
+
+    var sources = std.ArrayList([]u8){};
+    defer {
+        for (sources.items) |source| allocator.free(source);
+        sources.deinit(allocator);
+    }
+    var files = std.ArrayList(discovery.SourceFile){};
+    defer files.deinit(allocator);
+    var large = std.ArrayList(discovery.DiscoveredFile){};
+    defer large.deinit(allocator);
+    // files[bounds[i]..bounds[i + 1]] and large[large_bounds[i]..large_bounds[i + 1]]
+    // belong to targets[i]
+    var bounds = std.ArrayList(usize){};
+    defer bounds.deinit(allocator);
+    var large_bounds = std.ArrayList(usize){};
+    defer large_bounds.deinit(allocator);
+    try bounds.append(allocator, 0);
+    try large_bounds.append(allocator, 0);
+    options.stages().begin("parse");
+    for (targets.items) |*target| {
+        try loadTarget(allocator, target, options, &sources, &files, &large);
+        try bounds.append(allocator, files.items.len);
+        try large_bounds.append(allocator, large.items.len);
+    }
+    try options.stages().end();
+    if (files.items.len == 0 and large.items.len == 0) {
+        cli_error.printWarning("No supported source files found in {d} targets", .{targets.items.len});
+        cli_error.printInfo("Check --exclude patterns and .gitignore rules, or use --verbose", .{});
+        return;
+    }
+
+    var cache = openCache(allocator, options);
+    defer if (cache) |*c| c.close();
+
+    if (!split) {
+        var result = Result.init(allocator);
+        defer result.deinit();
+        result.generated = options.generated;
+        result.limits = options.limits;
+        result.throttle = options.throttle;
+        result.tracer = options.tracer;
+        result.pipeline_memory = options.pipeline_memory;
+        if (cache) |*c| result.cache = c;
+
+        var spinner = output.Spinner.init("Extracting constraints...");
+        options.stages().begin("analyze");
+        try result.addAll(&engine, files.items, options.concurrency.analyze);
+        try addLarge(&result, &engine, large.items, options.verbose);
+        try options.stages().end();
+        spinner.finish("Extraction complete");
+        if (cache) |*c| extract_cache.finish(c, options.verbose);
+
+        const component_name = if (parsed_args.getFlag("workspace")) |path| std.fs.path.stem(path) else "workspace";
+        return render(allocator, parsed_args, config, options, &result, component_name);
+    }
+
+    std.fs.cwd().makePath(output_dir) catch |err| {
+        cli_error.printFileError(err, output_dir);
+        return err;
+    };
+    for (targets.items, 0..) |*target, i| {
+        var result = Result.init(allocator);
+        defer result.deinit();
+        result.generated = options.generated;
+        result.limits = options.limits;
+        result.throttle = options.throttle;
+        result.tracer = options.tracer;
+        result.pipeline_memory = options.pipeline_memory;
+        if (cache) |*c| result.cache = c;
+
+        cli_error.printInfo("Target {s}", .{target.path});
+        options.stages().begin("analyze");
+        try result.addAll(&engine, files.items[bounds.items[i]..bounds.items[i + 1]], options.concurrency.analyze);
+        try addLarge(&result, &engine, large.items[large_bounds.items[i]..large_bounds.items[i + 1]], options.verbose);
+        try options.stages().end();
+
+        const file_name = try splitOutputName(allocator, target.path, options.format);
+        defer allocator.free(file_name);
+        const output_path = try std.fs.path.join(allocator, &.{ output_dir, file_name });
+        defer allocator.free(output_path);
+
+        var target_options = options;
+        target_options.output_file = output_path;
+        try render(allocator, parsed_args, config, target_options, &result, std.fs.path.stem(target.validated_path));
+    }
+    if (cache) |*c| extract_cache.finish(c, options.verbose);
+}
+
+/// Extract up to `parallel` targets at once for --split, each with its own
+/// engine, cache handle, and output file. The run's worker budget is shared
+/// between the targets in flight. Targets finish in any order but are
+/// reported in input order; a failed target is reported and the rest still
+/// run.
+fn runTargetsConcurrently(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    config: config_mod.Config,
+    options: Options,
+    targets: []Target,
+    output_dir: []const u8,
+    parallel: usize,
+) !void {
+    const n = jobs.workerCount(targets.len, parallel);
+    var target_options = options;
+    target_options.concurrency = jobs.share(options.concurrency, n);
+    // The profiler is not thread-safe, and targets would interleave their
+    // stage spans; the whole run is one stage
+    target_options.profiler = null;
+    target_options.tracer = null;
+
+    if (options.verbose) {
+        cli_error.printInfo("Extracting {d} targets, {d} at a time; workers per target: {d} parse, {d} analyze, {d} render", .{
+            targets.len,
+            n,
+            target_options.concurrency.parse,
+            target_options.concurrency.analyze,
+            target_options.concurrency.render,
+        });
+    }
+
+    var cache = openCache(allocator, options);
+    defer if (cache) |*c| c.close();
+
+    const Outcome = struct {
+        err: ?anyerror = null,
+        constraints: usize = 0,
+        files: usize = 0,
+        cache: extract_cache.Usage = .{},
+    };
+    const outcomes = try allocator.alloc(Outcome, targets.len);
+    defer allocator.free(outcomes);
+    @memset(outcomes, .{});
+
+    const Context = struct {
+        allocator: std.mem.Allocator,
+        parsed_args: args_mod.Args,
+        config: config_mod.Config,
+        options: Options,
+        targets: []Target,
+        output_dir: []const u8,
+        outcomes: []Outcome,
+        failed: usize = 0,
+
+        fn extractOne(ctx: *@This(), _: usize, i: usize) void {
+            ctx.extractTarget(&ctx.targets[i], &ctx.outcomes[i]) catch |err| {
+                ctx.outcomes[i].err = err;
+            };
+        }
+
+        fn extractTarget(ctx: *const @This(), target: *Target, outcome: *Outcome) !void {
+            const alloc = ctx.allocator;
+            var claude_client: ?ananke.api.claude.ClaudeClient = null;
+            defer if (claude_client) |*client| client.deinit();
+            var engine = try initEngine(alloc, ctx.config, ctx.options, &claude_client);
+            defer engine.deinit();
+
+            var sources = std.ArrayList([]u8){};
+            defer {
+                for (sources.items) |source| alloc.free(source);
+                sources.deinit(alloc);
+            }
+            var files = std.ArrayList(discovery.SourceFile){};
+            defer files.deinit(alloc);
+            var large = std.ArrayList(discovery.DiscoveredFile){};
+            defer large.deinit(alloc);
+            try loadTarget(alloc, target, ctx.options, &sources, &files, &large);
+
+            // Cache handles are not thread-safe, so each target opens its
+            // own; entry writes are atomic, so handles can share a directory
+            var cache = openCache(alloc, ctx.options);
+            defer if (cache) |*c| {
+                outcome.cache = extract_cache.Usage.of(c);
+                c.close();
+            };
+
+            var result = Result.init(alloc);
+            defer result.deinit();
+            result.generated = ctx.options.generated;
+            result.limits = ctx.options.limits;
+            result.throttle = ctx.options.throttle;
+            result.pipeline_memory = ctx.options.pipeline_memory;
+            if (cache) |*c| result.cache = c;
+            try result.addAll(&engine, files.items, ctx.options.concurrency.analyze);
+            try addLarge(&result, &engine, large.items, ctx.options.verbose);
+            outcome.files = result.files.items.len;
+            outcome.constraints = result.constraint_set.constraints.items.len;
+
+            const file_name = try splitOutputName(alloc, target.path, ctx.options.format);
+            defer alloc.free(file_name);
+            const output_path = try std.fs.path.join(alloc, &.{ ctx.output_dir, file_name });
+            defer alloc.free(output_path);
+            var target_options = ctx.options;
+            target_options.output_file = output_path;
+            try render(alloc, ctx.parsed_args, ctx.config, target_options, &result, std.fs.path.stem(target.validated_path));
+        }
+
+        fn report(ctx: *@This(), i: usize) anyerror!void {
+            const outcome = ctx.outcomes[i];
+            if (outcome.err) |err| {
+                ctx.failed += 1;
+                cli_error.printWarning("Target {s} failed: {s}", .{ ctx.targets[i].path, @errorName(err) });
+                return;
+            }
+            cli_error.printInfo("Target {s}: {d} constraints from {d} files", .{ ctx.targets[i].path, outcome.constraints, outcome.files });
+        }
+    };
+    var context = Context{
+        .allocator = allocator,
+        .parsed_args = parsed_args,
+        .config = config,
+        .options = target_options,
+        .targets = targets,
+        .output_dir = output_dir,
+        .outcomes = outcomes,
+    };
+
+    options.stages().begin("analyze");
+    try jobs.forEachOrdered(allocator, targets.len, n, &context, Context.extractOne, Context.report);
+    try options.stages().end();
+
+    if (cache) |*c| {
+        for (outcomes) |outcome| outcome.cache.addTo(c);
+        extract_cache.finish(c, options.verbose);
+    }
+
+    if (context.failed > 0) {
+        cli_error.printError("{d} of {d} targets failed", .{ context.failed, targets.len });
+        for (outcomes) |outcome| {
+            if (outcome.err) |err| return err;
+        }
+    }
+}
+
+/// Read every input with up to `workers` concurrent readers, charging each
+/// read to `throttle` when set. Slots keep input order; the caller owns each
+/// loaded source.
+fn readSources(
+    allocator: std.mem.Allocator,
+    inputs: []const discovery.DiscoveredFile,
+    workers: usize,
+    throttle: ?*jobs.Throttle,
+) ![]jobs.Slot([]u8) {
+    const Loaded = jobs.Slot([]u8);
+    const loaded = try allocator.alloc(Loaded, inputs.len);
+    @memset(loaded, .{});
+
+    const Context = struct {
+        allocator: std.mem.Allocator,
+        inputs: []const discovery.DiscoveredFile,
+        loaded: []Loaded,
+        throttle: ?*jobs.Throttle,
+
+        fn readChunk(ctx: *const @This(), _: usize, start: usize, end: usize) void {
+            for (ctx.inputs[start..end], ctx.loaded[start..end]) |input, *slot| {
+                const source = std.fs.cwd().readFileAlloc(ctx.allocator, input.path, max_source_bytes) catch |err| {
+                    slot.err = err;
+                    continue;
+                };
+                slot.value = source;
+                if (ctx.throttle) |throttle| throttle.charge(source.len);
+            }
+        }
+    };
+    const context = Context{ .allocator = allocator, .inputs = inputs, .loaded = loaded, .throttle = throttle };
+    jobs.forEachChunk(allocator, inputs.len, workers, &context, Context.readChunk);
+    return loaded;
+}
+
+/// Concatenate per-file annotation patches into a single multi-file diff.
+/// Files are rendered concurrently and joined in input order.
+fn formatPatch(
+    allocator: std.mem.Allocator,
+    constraint_set: ananke.ConstraintSet,
+    files: []const summary_mod.FileInfo,
+    sources: []const []const u8,
+    workers: usize,
+) ![]u8 {
+    const Patch = jobs.Slot([]u8);
+    const patches = try allocator.alloc(Patch, files.len);
+    defer {
+        for (patches) |slot| {
+            if (slot.value) |patch| allocator.free(patch);
+        }
+        allocator.free(patches);
+    }
+    @memset(patches, .{});
+
+    const Context = struct {
+        allocator: std.mem.Allocator,
+        constraint_set: *const ananke.ConstraintSet,
+        files: []const summary_mod.FileInfo,
+        sources: []const []const u8,
+        patches: []Patch,
+
+        fn renderChunk(ctx: *const @This(), _: usize, start: usize, end: usize) void {
+            for (start..end) |i| {
+                const file = ctx.files[i];
+                ctx.patches[i].value = annotate.formatAnnotationPatch(
+                    ctx.allocator,
+                    ctx.constraint_set.*,
+                    file.path,
+                    ctx.sources[i],
+                    file.language,
+                ) catch |err| {
+                    ctx.patches[i].err = err;
+                    continue;
+                };
+            }
+        }
+    };
+    const context = Context{
+        .allocator = allocator,
+        .constraint_set = &constraint_set,
+        .files = files,
+        .sources = sources,
+        .patches = patches,
+    };
+    jobs.forEachChunk(allocator, files.len, workers, &context, Context.renderChunk);
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    for (patches) |slot| {
+        if (slot.err) |err| return err;
+        try list.appendSlice(allocator, slot.value orelse continue);
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+test "workspace targets and split output names" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const targets = try parseWorkspace(arena, "checkouts/repos.txt", "# services\nbilling\n\n  auth/api  # owned by platform\n");
+    try testing.expectEqual(@as(usize, 2), targets.items.len);
+    try testing.expectEqualStrings("checkouts/billing", targets.items[0]);
+    try testing.expectEqualStrings("checkouts/auth/api", targets.items[1]);
+
+    try testing.expectEqualStrings("auth_api.cdx.json", try splitOutputName(arena, "./auth/api/", .cyclonedx));
+    try testing.expectEqualStrings("root.json", try splitOutputName(arena, ".", .json));
+}
+
+test "outline keeps top-level lines in place" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const source = "package big\n\nfunc A() {\n\treturn\n}\n\ntype T struct {\n    x int\n}\n";
+    const text = try outline(allocator, source);
+    defer allocator.free(text);
+    try testing.expectEqualStrings("package big\n\nfunc A() {\n\n}\n\ntype T struct {\n\n}\n", text);
+    try testing.expectEqual(std.mem.count(u8, source, "\n"), std.mem.count(u8, text, "\n"));
+
+    const limits = FileLimits{ .max_lines = 5 };
+    try testing.expect(limits.exceeded(source.len, 10));
+    try testing.expect(!limits.exceeded(1 << 30, 5));
+}
+
+test "drift digests are redacted with --redact" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const added = [_]ananke.Constraint{
+        .{ .name = "Compare token to \"sk_live_51H8\"", .description = "", .kind = .security, .severity = .err, .origin_file = "src/auth.go", .origin_line = 7 },
+    };
+    const digest = try driftDigest(arena, .{ .redact = true }, &added, .{ .stale = 1 }, ".ananke-baseline.json", "src");
+    const slack = try notify.formatSlack(arena, digest);
+    try testing.expect(std.mem.indexOf(u8, slack, "sk_live_51H8") == null);
+    const payload = try notify.formatWebhook(arena, digest, version.VERSION, 1700000000);
+    try testing.expect(std.mem.indexOf(u8, payload, "sk_live_51H8") == null);
+    try testing.expect(std.mem.indexOf(u8, payload, "src/auth.go") != null);
+
+    const plain = try driftDigest(arena, .{}, &added, .{ .stale = 1 }, ".ananke-baseline.json", "src");
+    try testing.expectEqualStrings(added[0].name, plain.added[0].name);
+}
+
+test "streamed chunks end at blank lines" {
+    const testing = std.testing;
+
+    try testing.expectEqual(@as(usize, 11), chunkEnd("fn a() {}\n\nfn b() {\n  x"));
+    try testing.expectEqual(@as(usize, 10), chunkEnd("a = 1\n  b\n  c"));
+    try testing.expectEqual(@as(usize, 5), chunkEnd("xxxxx"));
 }
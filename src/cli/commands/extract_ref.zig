@@ -0,0 +1,113 @@
+// Extract-ref command - Extract constraints at a git commit, tag, or branch
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const output = @import("cli_output");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const git = @import("cli_git");
+const extract = @import("cli/commands/extract");
+const extract_cache = @import("cli_extract_cache");
+
+pub const usage =
+    \\Usage: ananke extract-ref <ref> [path] [options]
+    \\
+    \\Extract constraints from a git commit, tag, or branch without checking it out.
+    \\Files are read directly from the object database, so the working tree is untouched.
+    \\
+    \\Arguments:
+    \\  <ref>                   Commit-ish to read (e.g. main, v1.2.0, HEAD~3, a1b2c3d)
+    \\  [path]                  Limit extraction to a file or directory in the tree
+    \\
+    \\Options:
+    \\  --language, --lang <l>  Only extract files in this language
+    \\  --exclude <globs>       Comma-separated .gitignore-style patterns to skip
+    \\  --no-default-excludes   Do not skip .git/, node_modules/, vendor/, zig-out/, .zig-cache/
+    \\  --format <fmt>          Output format (same formats as `ananke extract`)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --confidence <min>      Minimum confidence threshold (0.0-1.0, default: 0.5)
+    \\  --use-claude            Enable Claude API for semantic analysis
+    \\  --jobs, -j <n>          Extraction workers (default: number of CPUs)
+    \\  --no-cache              Re-extract every file instead of reusing cached results
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\All other `ananke extract` output options are accepted.
+    \\
+    \\Examples:
+    \\  ananke extract-ref v1.2.0 --format json -o constraints-v1.2.0.json
+    \\  ananke extract-ref origin/main src/api --format stats
+    \\  ananke extract-ref HEAD~5 src/auth.py
+;
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const rev = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <ref>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const pathspec: ?[]const u8 = parsed_args.getPositional(1) catch null;
+
+    const options = try extract.Options.parse(parsed_args, config);
+
+    var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+
+    var snapshot = git.loadSnapshot(allocator, rev, pathspec, .{
+        .excludes = excludes.items,
+        .use_default_excludes = !parsed_args.hasFlag("no-default-excludes"),
+        .language = options.language,
+    }, extract.max_source_bytes) catch |err| {
+        switch (err) {
+            git.GitError.GitNotFound => cli_error.printError("git executable not found in PATH", .{}),
+            git.GitError.InvalidRevision => cli_error.printError("Invalid revision: {s}", .{rev}),
+            git.GitError.UnknownRevision => {
+                cli_error.printError("Unknown revision: {s}", .{rev});
+                cli_error.printInfo("Run this command inside a git repository with the ref fetched", .{});
+            },
+            else => cli_error.printError("Failed to read {s} from git: {s}", .{ rev, @errorName(err) }),
+        }
+        return err;
+    };
+    defer snapshot.deinit();
+
+    if (options.verbose) {
+        cli_error.printInfo("Resolved {s} to {s}", .{ rev, snapshot.commit });
+        cli_error.printInfo("Read {d} source files from the object database", .{snapshot.files.items.len});
+        if (snapshot.oversized > 0) {
+            cli_error.printInfo("Skipped {d} files larger than {d} bytes", .{ snapshot.oversized, extract.max_source_bytes });
+        }
+    }
+
+    if (snapshot.files.items.len == 0) {
+        cli_error.printWarning("No supported source files found at {s}", .{rev});
+        return;
+    }
+
+    var claude_client: ?ananke.api.claude.ClaudeClient = null;
+    defer if (claude_client) |*client| client.deinit();
+    var engine = try extract.initEngine(allocator, config, options, &claude_client);
+    defer engine.deinit();
+
+    var result = extract.Result.init(allocator);
+    defer result.deinit();
+    result.generated = options.generated;
+    result.limits = options.limits;
+    result.pipeline_memory = options.pipeline_memory;
+
+    var cache = extract.openCache(allocator, options);
+    defer if (cache) |*c| c.close();
+    if (cache) |*c| result.cache = c;
+
+    var spinner = output.Spinner.init("Extracting constraints...");
+    try result.addAll(&engine, snapshot.files.items, options.concurrency.analyze);
+    spinner.finish("Extraction complete");
+    if (cache) |*c| extract_cache.finish(c, options.verbose);
+
+    try extract.render(allocator, parsed_args, config, options, &result, rev);
+}
@@ -0,0 +1,187 @@
+// Gen-tests command - Generate Go tests from the validation rules and handler contracts in the code
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const go_rules = @import("cli_go_rules");
+const go_http = @import("cli_go_http");
+const gotests = @import("cli_gotests");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke gen-tests [path] [options]
+    \\
+    \\Generate table-driven Go tests from the typed rules in Go files: struct
+    \\tags (`validate:"required,min=3,max=50"`, gin `binding` tags), the checks of
+    \\a struct's Validate method, and functions validating a single argument
+    \\(`func ValidateUsername(name string) error`) with length, range, empty, and
+    \\regexp checks. Each bound gets a case just outside it that must be rejected
+    \\and, where a passing value can be built, one on it that must be accepted.
+    \\
+    \\net/http handlers also get httptest contract tests of the rejections their
+    \\code documents: a request with another method when the handler checks
+    \\r.Method, a malformed JSON body when it decodes one, and a body breaking
+    \\each rule of its request DTO when it validates the DTO, each expecting the
+    \\status the handler answers with. Requests go to a zero-value receiver and
+    \\take the path of the handler's route registration. Pass a directory, so
+    \\DTOs and routes declared in other files are found.
+    \\
+    \\Tests go to `<file>_ananke_test.go` next to each file, behind a build tag,
+    \\so they run with `go test -tags ananke ./...`. Regenerating overwrites
+    \\them, and removes those whose file no longer has rules.
+    \\
+    \\Arguments:
+    \\  [path]                  Go file or directory (default: .)
+    \\
+    \\Options:
+    \\  --tag <name>            Build tag of the generated files (default: ananke)
+    \\  --no-contracts          Skip handler contract tests
+    \\  --dry-run               List the files that would be written
+    \\  --exclude <pattern>     Skip paths matching a glob (comma-separated)
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke gen-tests ./internal/users
+    \\  ananke gen-tests --tag constraints && go test -tags constraints ./...
+;
+
+/// First line of every generated file after the build tag, used to tell
+/// our files from hand-written ones before removing them
+const generated_marker = "// Code generated by ananke gen-tests";
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const path = parsed_args.getPositional(0) catch ".";
+    const tag = parsed_args.getFlagOr("tag", gotests.default_tag);
+    if (tag.len == 0 or std.mem.indexOfAny(u8, tag, " \t\n") != null) {
+        cli_error.printError("Invalid build tag: \"{s}\"", .{tag});
+        return error.InvalidArgument;
+    }
+    const dry_run = parsed_args.hasFlag("dry-run");
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+    const contracts = !parsed_args.hasFlag("no-contracts");
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var excludes = std.ArrayList([]const u8){};
+    if (parsed_args.getFlag("exclude")) |list| {
+        var parts = std.mem.splitScalar(u8, list, ',');
+        while (parts.next()) |part| {
+            if (part.len > 0) try excludes.append(arena, part);
+        }
+    }
+    const files = try goFiles(arena, path, excludes.items);
+
+    // Parse everything first: a handler's DTO is often declared in another
+    // file of its package, and its route registered in another package
+    var parsed = std.ArrayList(Parsed){};
+    var routes = std.ArrayList(go_http.Route){};
+    var package_structs = std.StringHashMap(std.ArrayList(go_rules.Struct)).init(arena);
+    for (files) |file| {
+        const source = std.fs.cwd().readFileAlloc(arena, file, extract.max_source_bytes) catch |err| {
+            cli_error.printFileError(err, file);
+            return err;
+        };
+        if (discovery.isGenerated(file, source)) continue;
+
+        const rules = try go_rules.parse(arena, source);
+        const endpoints = if (contracts) try go_http.parse(arena, source) else go_http.File{};
+        try parsed.append(arena, .{ .path = file, .rules = rules, .handlers = endpoints.handlers });
+        try routes.appendSlice(arena, endpoints.routes);
+        const gop = try package_structs.getOrPut(std.fs.path.dirname(file) orelse ".");
+        if (!gop.found_existing) gop.value_ptr.* = std.ArrayList(go_rules.Struct){};
+        try gop.value_ptr.appendSlice(arena, rules.structs);
+    }
+
+    var written: usize = 0;
+    var removed: usize = 0;
+    for (parsed.items) |entry| {
+        const file = entry.path;
+        const test_path = try gotests.testPath(arena, file);
+        const text = try gotests.generate(arena, .{
+            .file_name = file,
+            .rules = entry.rules,
+            .handlers = entry.handlers,
+            .structs = package_structs.get(std.fs.path.dirname(file) orelse ".").?.items,
+            .routes = routes.items,
+        }, tag) orelse {
+            if (try removeStale(arena, test_path, dry_run)) {
+                removed += 1;
+                if (verbose or dry_run) cli_error.printInfo("{s} {s}", .{ if (dry_run) "Would remove" else "Removed", test_path });
+            }
+            continue;
+        };
+        if (verbose or dry_run) cli_error.printInfo("{s} {s}", .{ if (dry_run) "Would write" else "Writing", test_path });
+        if (!dry_run) {
+            std.fs.cwd().writeFile(.{ .sub_path = test_path, .data = text }) catch |err| {
+                cli_error.printFileError(err, test_path);
+                return err;
+            };
+        }
+        written += 1;
+    }
+
+    if (written == 0 and removed == 0) {
+        cli_error.printWarning("No validation rules or handler contracts found in {d} Go files under {s}", .{ files.len, path });
+        return;
+    }
+    if (dry_run) return;
+    cli_error.printSuccess("Generated {d} test files ({d} stale removed); run them with go test -tags {s}", .{ written, removed, tag });
+}
+
+const Parsed = struct {
+    path: []const u8,
+    rules: go_rules.File,
+    handlers: []const go_http.Handler,
+};
+
+/// Go sources under `path` (or `path` itself), without tests
+pub fn goFiles(arena: std.mem.Allocator, path: []const u8, excludes: []const []const u8) ![]const []const u8 {
+    const stat = std.fs.cwd().statFile(path) catch |err| {
+        cli_error.printFileError(err, path);
+        return err;
+    };
+    var files = std.ArrayList([]const u8){};
+    if (stat.kind != .directory) {
+        try files.append(arena, path);
+        return files.items;
+    }
+
+    var set = discovery.discover(arena, path, .{ .language = "go", .excludes = excludes }) catch |err| {
+        cli_error.printFileError(err, path);
+        return err;
+    };
+    for (set.files.items) |file| {
+        if (std.mem.endsWith(u8, file.path, "_test.go")) continue;
+        try files.append(arena, try arena.dupe(u8, file.path));
+    }
+    set.deinit();
+    return files.items;
+}
+
+/// Remove a previously generated test file; hand-written files are kept
+fn removeStale(arena: std.mem.Allocator, test_path: []const u8, dry_run: bool) !bool {
+    const existing = std.fs.cwd().readFileAlloc(arena, test_path, 4096) catch |err| switch (err) {
+        error.FileNotFound => return false,
+        // Longer than a header: read just enough to find the marker
+        error.FileTooBig => blk: {
+            var file = try std.fs.cwd().openFile(test_path, .{});
+            defer file.close();
+            const head = try arena.alloc(u8, 4096);
+            break :blk head[0..try file.readAll(head)];
+        },
+        else => return err,
+    };
+    if (std.mem.indexOf(u8, existing, generated_marker) == null) return false;
+    if (!dry_run) try std.fs.cwd().deleteFile(test_path);
+    return true;
+}
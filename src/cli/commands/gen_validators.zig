@@ -0,0 +1,162 @@
+// Gen-validators command - Generate Go code enforcing the validation rules of request structs at runtime
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const go_rules = @import("cli_go_rules");
+const go_http = @import("cli_go_http");
+const govalidate = @import("cli_govalidate");
+const gen_tests = @import("cli/commands/gen_tests");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke gen-validators [path] [options]
+    \\
+    \\Generate Go code that enforces the typed rules of each package's structs
+    \\at runtime: length bounds, value ranges, required fields, email format,
+    \\option lists, regexps, and character classes (`containsany=0123456789`,
+    \\`!strings.ContainsAny(p, "0123456789")`) from struct tags and Validate
+    \\methods. Every struct with rules gets a CheckConstraints method returning
+    \\all the fields that break one, and the package a ValidateJSON middleware
+    \\answering 422 with them before a handler runs:
+    \\
+    \\  mux.Handle("POST /users", ValidateJSON[CreateUserRequest](http.HandlerFunc(h.CreateUser)))
+    \\
+    \\Every net/http handler that checks its method or decodes a struct with
+    \\rules also gets a Validate<Handler> middleware enforcing the request
+    \\contract found in its code: the method it accepts, the body it decodes,
+    \\and the status each rejection answers with. The Endpoints map
+    \\keys those middlewares by the route patterns registered for them:
+    \\
+    \\  mux.Handle("POST /users", Endpoints["POST /users"](http.HandlerFunc(h.CreateUser)))
+    \\
+    \\Code goes to `ananke_validators.go` in each package directory.
+    \\Regenerating overwrites it, and removes it when the package no longer has
+    \\rules; a hand-written file of that name is left alone.
+    \\
+    \\Arguments:
+    \\  [path]                  Go file or directory (default: .)
+    \\
+    \\Options:
+    \\  --no-middleware         Generate only the CheckConstraints methods
+    \\  --no-endpoints          Skip the per-handler middlewares
+    \\  --dry-run               List the files that would be written
+    \\  --exclude <pattern>     Skip paths matching a glob (comma-separated)
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke gen-validators ./internal/api
+    \\  ananke gen-validators --no-middleware --exclude "vendor/**"
+;
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const path = parsed_args.getPositional(0) catch ".";
+    const dry_run = parsed_args.hasFlag("dry-run");
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+    var options = govalidate.Options{
+        .middleware = !parsed_args.hasFlag("no-middleware"),
+        .endpoints = !parsed_args.hasFlag("no-endpoints"),
+    };
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var excludes = std.ArrayList([]const u8){};
+    if (parsed_args.getFlag("exclude")) |list| {
+        var parts = std.mem.splitScalar(u8, list, ',');
+        while (parts.next()) |part| {
+            if (part.len > 0) try excludes.append(arena, part);
+        }
+    }
+    const files = try gen_tests.goFiles(arena, path, excludes.items);
+
+    // A package's structs spread over its files, which share a directory
+    var packages = std.ArrayList(Package){};
+    var by_dir = std.StringHashMap(usize).init(arena);
+    var routes = std.ArrayList(go_http.Route){};
+    for (files) |file| {
+        const source = std.fs.cwd().readFileAlloc(arena, file, extract.max_source_bytes) catch |err| {
+            cli_error.printFileError(err, file);
+            return err;
+        };
+        if (discovery.isGenerated(file, source)) continue;
+
+        const rules = try go_rules.parse(arena, source);
+        if (rules.package.len == 0) continue;
+        // Routes are often registered in another package than the handlers
+        const http = try go_http.parse(arena, source);
+        try routes.appendSlice(arena, http.routes);
+        const dir = std.fs.path.dirname(file) orelse ".";
+        const gop = try by_dir.getOrPut(dir);
+        if (!gop.found_existing) {
+            gop.value_ptr.* = packages.items.len;
+            try packages.append(arena, .{ .dir = dir, .name = rules.package });
+        }
+        try packages.items[gop.value_ptr.*].sources.append(arena, .{ .path = file, .structs = rules.structs, .handlers = http.handlers });
+    }
+    options.routes = routes.items;
+
+    var written: usize = 0;
+    var removed: usize = 0;
+    for (packages.items) |pkg| {
+        const out_path = try govalidate.outputPath(arena, pkg.dir);
+        const owned = try isOurs(arena, out_path);
+        const text = try govalidate.generate(arena, pkg.name, pkg.sources.items, options) orelse {
+            if (owned == true) {
+                if (!dry_run) try std.fs.cwd().deleteFile(out_path);
+                removed += 1;
+                if (verbose or dry_run) cli_error.printInfo("{s} {s}", .{ if (dry_run) "Would remove" else "Removed", out_path });
+            }
+            continue;
+        };
+        if (owned == false) {
+            cli_error.printWarning("Skipping {s}: not generated by ananke", .{out_path});
+            continue;
+        }
+        if (verbose or dry_run) cli_error.printInfo("{s} {s}", .{ if (dry_run) "Would write" else "Writing", out_path });
+        if (!dry_run) {
+            std.fs.cwd().writeFile(.{ .sub_path = out_path, .data = text }) catch |err| {
+                cli_error.printFileError(err, out_path);
+                return err;
+            };
+        }
+        written += 1;
+    }
+
+    if (written == 0 and removed == 0) {
+        cli_error.printWarning("No struct validation rules or handler contracts found in {d} Go files under {s}", .{ files.len, path });
+        return;
+    }
+    if (dry_run) return;
+    cli_error.printSuccess("Generated validators for {d} packages ({d} stale removed)", .{ written, removed });
+}
+
+const Package = struct {
+    dir: []const u8,
+    name: []const u8,
+    sources: std.ArrayList(govalidate.Source) = .{},
+};
+
+/// Whether the file at `path` was generated by us; null when it does not exist
+fn isOurs(arena: std.mem.Allocator, path: []const u8) !?bool {
+    var file = std.fs.cwd().openFile(path, .{}) catch |err| switch (err) {
+        error.FileNotFound => return null,
+        else => {
+            cli_error.printFileError(err, path);
+            return err;
+        },
+    };
+    defer file.close();
+    const head = try arena.alloc(u8, govalidate.generated_marker.len);
+    const n = try file.readAll(head);
+    return std.mem.eql(u8, head[0..n], govalidate.generated_marker);
+}
@@ -0,0 +1,153 @@
+// Genfixture command - Generate benchmark fixtures of a given language, size, and shape
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const fixture_gen = @import("cli_fixture_gen");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke genfixture [options]
+    \\
+    \\Generate a benchmark fixture of a language, target line count, and shape,
+    \\so fixtures of any size are generated when needed instead of committed.
+    \\With --tiers, write the fixtures `ananke bench` runs instead: one per size
+    \\tier (small 100, medium 500, large 1000, xlarge 5000 lines) under
+    \\<dir>/<language>/<size>/.
+    \\
+    \\Shapes:
+    \\  service     An entity type, its DTOs, and a service whose methods each
+    \\              query a database, log, and handle the error (default)
+    \\
+    \\Modes:
+    \\  clone       Every method has the same body (default)
+    \\  realistic   Struct types and functions whose control flow, nesting,
+    \\              and comments vary by the distribution options below, drawn
+    \\              from a seeded generator so a seed always gives the same file
+    \\
+    \\Options:
+    \\  --language, --lang <l>  go, typescript, python, rust, zig (default: go;
+    \\                          with --tiers, every language)
+    \\  --lines <n>             Target line count (default: 1000)
+    \\  --shape <shape>         Fixture shape (default: service)
+    \\  --mode <mode>           clone, realistic (default: clone)
+    \\  --output, -o <file>     Write the fixture to file instead of stdout
+    \\  --tiers <dir>           Write every size tier under <dir>
+    \\  --help, -h              Show this help message
+    \\
+    \\Realistic mode:
+    \\  --seed <n>              Generator seed (default: 0)
+    \\  --types <n>             Struct types to declare (default: 8)
+    \\  --branch-rate <p>       Chance a statement opens an if, loop, or switch (default: 0.3)
+    \\  --max-depth <n>         Deepest block nesting in a function (default: 4)
+    \\  --comment-rate <p>      Chance a statement has a comment above it (default: 0.15)
+    \\  --min-statements <n>    Fewest statements per block (default: 1)
+    \\  --max-statements <n>    Most statements per block (default: 6)
+    \\
+    \\Examples:
+    \\  ananke genfixture --lang go --lines 5000 --shape service -o service.go
+    \\  ananke genfixture --tiers test/fixtures
+    \\  ananke genfixture --mode realistic --seed 42 --lines 5000 --max-depth 6 -o realistic.go
+    \\  ananke genfixture --tiers /tmp/fixtures --lang go && ananke bench /tmp/fixtures
+;
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const language_str = parsed_args.getFlag("language") orelse parsed_args.getFlag("lang");
+    const language: ?fixture_gen.Language = if (language_str) |name| std.meta.stringToEnum(fixture_gen.Language, name) orelse {
+        cli_error.printError("Invalid language: {s} (expected go, typescript, python, rust, zig)", .{name});
+        return error.InvalidArgument;
+    } else null;
+    const shape_str = parsed_args.getFlagOr("shape", "service");
+    const shape = std.meta.stringToEnum(fixture_gen.Shape, shape_str) orelse {
+        cli_error.printError("Invalid shape: {s} (expected service)", .{shape_str});
+        return error.InvalidArgument;
+    };
+
+    const mode_str = parsed_args.getFlagOr("mode", "clone");
+    const mode = std.meta.stringToEnum(fixture_gen.Mode, mode_str) orelse {
+        cli_error.printError("Invalid mode: {s} (expected clone or realistic)", .{mode_str});
+        return error.InvalidArgument;
+    };
+    const distribution = try parseDistribution(parsed_args);
+
+    if (parsed_args.getFlag("tiers")) |dir| {
+        return writeTiers(allocator, dir, language, .{ .shape = shape, .mode = mode, .distribution = distribution });
+    }
+
+    const lines = try parsed_args.getFlagInt("lines", usize) orelse 1000;
+    if (lines == 0) {
+        cli_error.printError("--lines must be at least 1", .{});
+        return error.InvalidArgument;
+    }
+    const text = try fixture_gen.generate(allocator, .{
+        .language = language orelse .go,
+        .shape = shape,
+        .lines = lines,
+        .mode = mode,
+        .distribution = distribution,
+    });
+    defer allocator.free(text);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), text);
+}
+
+fn parseDistribution(parsed_args: args_mod.Args) !fixture_gen.Distribution {
+    const defaults = fixture_gen.Distribution{};
+    const distribution = fixture_gen.Distribution{
+        .seed = try parsed_args.getFlagInt("seed", u64) orelse defaults.seed,
+        .types = try parsed_args.getFlagInt("types", usize) orelse defaults.types,
+        .branch_rate = try parsed_args.getFlagFloat("branch-rate", f64) orelse defaults.branch_rate,
+        .max_depth = try parsed_args.getFlagInt("max-depth", usize) orelse defaults.max_depth,
+        .comment_rate = try parsed_args.getFlagFloat("comment-rate", f64) orelse defaults.comment_rate,
+        .min_statements = try parsed_args.getFlagInt("min-statements", usize) orelse defaults.min_statements,
+        .max_statements = try parsed_args.getFlagInt("max-statements", usize) orelse defaults.max_statements,
+    };
+    if (distribution.branch_rate < 0 or distribution.branch_rate > 1 or distribution.comment_rate < 0 or distribution.comment_rate > 1) {
+        cli_error.printError("--branch-rate and --comment-rate must be between 0.0 and 1.0", .{});
+        return error.InvalidArgument;
+    }
+    if (distribution.min_statements == 0 or distribution.min_statements > distribution.max_statements) {
+        cli_error.printError("--min-statements must be at least 1 and at most --max-statements", .{});
+        return error.InvalidArgument;
+    }
+    return distribution;
+}
+
+/// Write the fixture of every size tier to `root`/<language>/<size>/, for
+/// `language` or every language; `base` gives the other options
+fn writeTiers(allocator: std.mem.Allocator, root: []const u8, language: ?fixture_gen.Language, base: fixture_gen.Options) !void {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var written: usize = 0;
+    for (std.enums.values(fixture_gen.Language)) |candidate| {
+        if (language) |wanted| {
+            if (candidate != wanted) continue;
+        }
+        for (fixture_gen.tiers) |tier| {
+            var options = base;
+            options.language = candidate;
+            options.lines = tier.lines;
+            const dir = try std.fs.path.join(arena, &.{ root, @tagName(candidate), tier.name });
+            const path = try std.fs.path.join(arena, &.{ dir, try fixture_gen.fileName(arena, options) });
+            const text = try fixture_gen.generate(arena, options);
+            std.fs.cwd().makePath(dir) catch |err| {
+                cli_error.printFileError(err, dir);
+                return err;
+            };
+            std.fs.cwd().writeFile(.{ .sub_path = path, .data = text }) catch |err| {
+                cli_error.printFileError(err, path);
+                return err;
+            };
+            written += 1;
+        }
+    }
+    cli_error.printSuccess("Generated {d} fixtures under {s}", .{ written, root });
+}
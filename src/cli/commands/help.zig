@@ -5,6 +5,39 @@ const config_mod = @import("cli_config");
 const output = @import("cli_output");
 
 const extract = @import("cli/commands/extract");
+const extract_ref = @import("cli/commands/extract_ref");
+const diff = @import("cli/commands/diff");
+const explain = @import("cli/commands/explain");
+const query = @import("cli/commands/query");
+const profile = @import("cli/commands/profile");
+const cache = @import("cli/commands/cache");
+const merge = @import("cli/commands/merge");
+const stats = @import("cli/commands/stats");
+const lint_rules = @import("cli/commands/lint_rules");
+const export_bundle = @import("cli/commands/export_bundle");
+const serve = @import("cli/commands/serve");
+const bench = @import("cli/commands/bench");
+const index = @import("cli/commands/index");
+const daemon = @import("cli/commands/daemon");
+const mcp = @import("cli/commands/mcp");
+const rpc = @import("cli/commands/rpc");
+const import_rules = @import("cli/commands/import_rules");
+const export_embeddings = @import("cli/commands/export_embeddings");
+const verify = @import("cli/commands/verify");
+const drift = @import("cli/commands/drift");
+const enforce = @import("cli/commands/enforce");
+const gen_tests = @import("cli/commands/gen_tests");
+const gen_validators = @import("cli/commands/gen_validators");
+const inject_asserts = @import("cli/commands/inject_asserts");
+const constraints_doc = @import("cli/commands/constraints_doc");
+const api_diff = @import("cli/commands/api_diff");
+const coverage = @import("cli/commands/coverage");
+const scaffold = @import("cli/commands/scaffold");
+const dto_check = @import("cli/commands/dto_check");
+const policy_check = @import("cli/commands/policy_check");
+const review = @import("cli/commands/review");
+const architecture = @import("cli/commands/architecture");
+const genfixture = @import("cli/commands/genfixture");
 const compile = @import("cli/commands/compile");
 const generate = @import("cli/commands/generate");
 const validate = @import("cli/commands/validate");
@@ -17,13 +50,46 @@ pub const usage =
     \\Show help information for a specific command.
     \\
     \\Available commands:
-    \\  extract   - Extract constraints from source code
-    \\  compile   - Compile constraints to IR
-    \\  generate  - Generate code with constraints
-    \\  validate  - Validate code against constraints
-    \\  init      - Initialize configuration file
-    \\  version   - Show version information
-    \\  help      - Show this help message
+    \\  extract     - Extract constraints from source code
+    \\  extract-ref - Extract constraints at a git commit, tag, or branch
+    \\  diff        - Compare constraints between two git refs
+    \\  explain     - Explain a constraint from a stored result file
+    \\  query       - Filter a stored result file
+    \\  profile     - Profile an extraction stage by stage
+    \\  cache       - Inspect or clean the extraction cache
+    \\  merge       - Merge sharded result files
+    \\  stats       - Show constraint trends across past runs
+    \\  lint-rules  - Check Ariadne rule files for errors
+    \\  export-bundle- Package results for audit or reproduction
+    \\  serve       - Browse results in a local web report
+    \\  bench       - Benchmark extraction against baselines
+    \\  index       - Index a result file for query and explain
+    \\  daemon      - Serve extraction, diff, and query requests from a warm process
+    \\  mcp         - Serve constraints to coding agents over MCP
+    \\  rpc         - Serve extraction and queries to protobuf clients
+    \\  import-rules- Convert Semgrep rules into Ariadne constraints
+    \\  export-embeddings- Export constraint embeddings to a vector store
+    \\  verify      - Check code against stored constraints
+    \\  drift       - Per-package drift between two runs
+    \\  enforce     - Check constraint changes against budgets
+    \\  gen-tests   - Generate Go tests from validation rules
+    \\  gen-validators - Generate Go validators from struct rules
+    \\  inject-asserts - Inject build-tagged checks of struct rules
+    \\  constraints-doc - Write or check per-package CONSTRAINTS.md
+    \\  api-diff    - Report breaking Go API changes between refs
+    \\  coverage    - Report the share of constraints linked to tests
+    \\  scaffold    - Scaffold Go structs and Validate methods from a type spec
+    \\  dto-check   - Compare the field rules of the same DTO across services
+    \\  policy-check - Check sources against security and resilience policy packs
+    \\  review      - Record review decisions on stored constraints
+    \\  architecture - Extract package layering and check architecture rules
+    \\  genfixture  - Generate benchmark fixtures of a language, size, and shape
+    \\  compile     - Compile constraints to IR
+    \\  generate    - Generate code with constraints
+    \\  validate    - Validate code against constraints
+    \\  init        - Initialize configuration file
+    \\  version     - Show version information
+    \\  help        - Show this help message
     \\
     \\Examples:
     \\  ananke help
@@ -42,6 +108,72 @@ pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: con
 
     if (std.mem.eql(u8, command, "extract")) {
         std.debug.print("{s}\n", .{extract.usage});
+    } else if (std.mem.eql(u8, command, "extract-ref")) {
+        std.debug.print("{s}\n", .{extract_ref.usage});
+    } else if (std.mem.eql(u8, command, "diff")) {
+        std.debug.print("{s}\n", .{diff.usage});
+    } else if (std.mem.eql(u8, command, "explain")) {
+        std.debug.print("{s}\n", .{explain.usage});
+    } else if (std.mem.eql(u8, command, "query")) {
+        std.debug.print("{s}\n", .{query.usage});
+    } else if (std.mem.eql(u8, command, "profile")) {
+        std.debug.print("{s}\n", .{profile.usage});
+    } else if (std.mem.eql(u8, command, "cache")) {
+        std.debug.print("{s}\n", .{cache.usage});
+    } else if (std.mem.eql(u8, command, "merge")) {
+        std.debug.print("{s}\n", .{merge.usage});
+    } else if (std.mem.eql(u8, command, "stats")) {
+        std.debug.print("{s}\n", .{stats.usage});
+    } else if (std.mem.eql(u8, command, "lint-rules")) {
+        std.debug.print("{s}\n", .{lint_rules.usage});
+    } else if (std.mem.eql(u8, command, "export-bundle")) {
+        std.debug.print("{s}\n", .{export_bundle.usage});
+    } else if (std.mem.eql(u8, command, "serve")) {
+        std.debug.print("{s}\n", .{serve.usage});
+    } else if (std.mem.eql(u8, command, "bench")) {
+        std.debug.print("{s}\n", .{bench.usage});
+    } else if (std.mem.eql(u8, command, "index")) {
+        std.debug.print("{s}\n", .{index.usage});
+    } else if (std.mem.eql(u8, command, "daemon")) {
+        std.debug.print("{s}\n", .{daemon.usage});
+    } else if (std.mem.eql(u8, command, "mcp")) {
+        std.debug.print("{s}\n", .{mcp.usage});
+    } else if (std.mem.eql(u8, command, "rpc")) {
+        std.debug.print("{s}\n", .{rpc.usage});
+    } else if (std.mem.eql(u8, command, "import-rules")) {
+        std.debug.print("{s}\n", .{import_rules.usage});
+    } else if (std.mem.eql(u8, command, "export-embeddings")) {
+        std.debug.print("{s}\n", .{export_embeddings.usage});
+    } else if (std.mem.eql(u8, command, "verify")) {
+        std.debug.print("{s}\n", .{verify.usage});
+    } else if (std.mem.eql(u8, command, "drift")) {
+        std.debug.print("{s}\n", .{drift.usage});
+    } else if (std.mem.eql(u8, command, "enforce")) {
+        std.debug.print("{s}\n", .{enforce.usage});
+    } else if (std.mem.eql(u8, command, "gen-tests")) {
+        std.debug.print("{s}\n", .{gen_tests.usage});
+    } else if (std.mem.eql(u8, command, "gen-validators")) {
+        std.debug.print("{s}\n", .{gen_validators.usage});
+    } else if (std.mem.eql(u8, command, "inject-asserts")) {
+        std.debug.print("{s}\n", .{inject_asserts.usage});
+    } else if (std.mem.eql(u8, command, "constraints-doc")) {
+        std.debug.print("{s}\n", .{constraints_doc.usage});
+    } else if (std.mem.eql(u8, command, "api-diff")) {
+        std.debug.print("{s}\n", .{api_diff.usage});
+    } else if (std.mem.eql(u8, command, "coverage")) {
+        std.debug.print("{s}\n", .{coverage.usage});
+    } else if (std.mem.eql(u8, command, "scaffold")) {
+        std.debug.print("{s}\n", .{scaffold.usage});
+    } else if (std.mem.eql(u8, command, "dto-check")) {
+        std.debug.print("{s}\n", .{dto_check.usage});
+    } else if (std.mem.eql(u8, command, "policy-check")) {
+        std.debug.print("{s}\n", .{policy_check.usage});
+    } else if (std.mem.eql(u8, command, "review")) {
+        std.debug.print("{s}\n", .{review.usage});
+    } else if (std.mem.eql(u8, command, "architecture")) {
+        std.debug.print("{s}\n", .{architecture.usage});
+    } else if (std.mem.eql(u8, command, "genfixture")) {
+        std.debug.print("{s}\n", .{genfixture.usage});
     } else if (std.mem.eql(u8, command, "compile")) {
         std.debug.print("{s}\n", .{compile.usage});
     } else if (std.mem.eql(u8, command, "generate")) {
@@ -82,19 +214,52 @@ fn printGeneralHelp() void {
 
     std.debug.print("Usage: ananke <command> [options]\n\n", .{});
     std.debug.print("Commands:\n", .{});
-    std.debug.print("  extract   Extract constraints from source code\n", .{});
-    std.debug.print("  compile   Compile constraints to intermediate representation\n", .{});
-    std.debug.print("  generate  Generate code with constraints (requires Modal)\n", .{});
-    std.debug.print("  validate  Validate code against constraints\n", .{});
-    std.debug.print("  init      Initialize .ananke.toml configuration file\n", .{});
-    std.debug.print("  version   Show version information\n", .{});
-    std.debug.print("  help      Show help for a specific command\n", .{});
+    std.debug.print("  extract      Extract constraints from source code\n", .{});
+    std.debug.print("  extract-ref  Extract constraints at a git commit, tag, or branch\n", .{});
+    std.debug.print("  diff         Compare constraints between two git refs\n", .{});
+    std.debug.print("  explain      Explain a constraint from a stored result file\n", .{});
+    std.debug.print("  query        Filter a stored result file\n", .{});
+    std.debug.print("  profile      Profile extraction time and memory by stage\n", .{});
+    std.debug.print("  cache        Show extraction cache statistics, clear it, or evict stale entries\n", .{});
+    std.debug.print("  merge        Combine result files from parallel extraction jobs into one\n", .{});
+    std.debug.print("  stats        Report constraint counts, drift velocity, and category mix over a directory of results\n", .{});
+    std.debug.print("  lint-rules   Validate rule files for schema errors, unreachable patterns, and duplicate ids\n", .{});
+    std.debug.print("  export-bundleArchive results, config, rule versions, and tool version\n", .{});
+    std.debug.print("  serve        Serve the HTML report with search and deep links\n", .{});
+    std.debug.print("  bench        Measure fixture throughput and gate regressions\n", .{});
+    std.debug.print("  index        Build a disk-backed index over a stored result file\n", .{});
+    std.debug.print("  daemon       Keep extraction warm and answer requests over a local socket\n", .{});
+    std.debug.print("  mcp          Model Context Protocol server for coding agents\n", .{});
+    std.debug.print("  rpc          Connect/protobuf service for generated clients\n", .{});
+    std.debug.print("  import-rules Convert Semgrep rule files into Ariadne constraints\n", .{});
+    std.debug.print("  export-embeddingsEmbed constraints into pgvector, Chroma, or Qdrant\n", .{});
+    std.debug.print("  verify       Check code against a stored constraint set\n", .{});
+    std.debug.print("  drift        Report per-package constraint drift between two runs (JSON/HTML)\n", .{});
+    std.debug.print("  enforce      Evaluate constraint changes between two runs against budgets\n", .{});
+    std.debug.print("  gen-tests    Generate table-driven Go tests from validation rules\n", .{});
+    std.debug.print("  gen-validators Generate CheckConstraints methods and ValidateJSON middleware from struct rules\n", .{});
+    std.debug.print("  inject-asserts Inject runtime assertions of struct rules behind a build tag\n", .{});
+    std.debug.print("  constraints-doc Write a CONSTRAINTS.md per package, or --check that they are current\n", .{});
+    std.debug.print("  api-diff     Compare the exported Go API at two refs and gate releases on semver\n", .{});
+    std.debug.print("  coverage     Constraint test coverage per package, with thresholds\n", .{});
+    std.debug.print("  scaffold     Spec-first Go types with validate tags, and back\n", .{});
+    std.debug.print("  dto-check    Cross-service DTO rule mismatches (Go, TypeScript)\n", .{});
+    std.debug.print("  policy-check Policy packs (passwords, methods, SQL, secrets, TLS, timeouts)\n", .{});
+    std.debug.print("  review       Accept, reject, or mark constraints reviewed for verify\n", .{});
+    std.debug.print("  architecture Package layering and architecture rules (show, promote, check)\n", .{});
+    std.debug.print("  genfixture   Generate benchmark fixtures of a language, size, and shape\n", .{});
+    std.debug.print("  compile      Compile constraints to intermediate representation\n", .{});
+    std.debug.print("  generate     Generate code with constraints (requires Modal)\n", .{});
+    std.debug.print("  validate     Validate code against constraints\n", .{});
+    std.debug.print("  init         Initialize .ananke.toml configuration file\n", .{});
+    std.debug.print("  version      Show version information\n", .{});
+    std.debug.print("  help         Show help for a specific command\n", .{});
     std.debug.print("\n", .{});
     std.debug.print("Global Options:\n", .{});
     std.debug.print("  --config <file>  Use specified configuration file\n", .{});
-    std.debug.print("  --no-color       Disable colored output\n", .{});
-    std.debug.print("  --version        Show version and exit\n", .{});
-    std.debug.print("  --help           Show this help message\n", .{});
+    std.debug.print("  --no-color   Disable colored output\n", .{});
+    std.debug.print("  --version    Show version and exit\n", .{});
+    std.debug.print("  --help       Show this help message\n", .{});
     std.debug.print("\n", .{});
     std.debug.print("Examples:\n", .{});
     std.debug.print("  ananke extract src/main.ts --format json\n", .{});
@@ -0,0 +1,137 @@
+// Import-rules command - Convert Semgrep rules into Ariadne constraints
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const semgrep = @import("cli_semgrep");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke import-rules <path>... [options]
+    \\
+    \\Convert Semgrep rule files into Ariadne constraints (*.ariadne), so existing
+    \\rulepacks can be used with compile, lint-rules, and export-bundle.
+    \\Directories are searched recursively for *.yaml and *.yml files.
+    \\
+    \\Rules whose pattern (or each pattern-either alternative) is a call of a named
+    \\function or method, such as eval(...) or $DB.raw(...), are converted for
+    \\JavaScript, TypeScript, Python, Go, and Java: one constraint per pattern and
+    \\language, with the message, severity, confidence, and CWE/OWASP metadata.
+    \\Other rules (patterns, pattern-regex, taint mode, ...) are listed as skipped.
+    \\
+    \\Options:
+    \\  --module <name>         Module declared in the output (default: semgrep)
+    \\  --output, -o <file>     Write constraints to file instead of stdout
+    \\  --strict                Fail when any rule is skipped
+    \\  --verbose, -v           List every skipped rule with the reason
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke import-rules semgrep/security.yaml -o rules/security.ariadne
+    \\  ananke import-rules .semgrep/ --strict -o rules/semgrep.ariadne && ananke lint-rules rules/
+;
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const paths = parsed_args.positional.items;
+    if (paths.len == 0) {
+        cli_error.printError("Missing required argument: <path>...", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    }
+    const module_name = parsed_args.getFlagOr("module", "semgrep");
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const files = try collectRuleFiles(arena, paths);
+    if (files.items.len == 0) {
+        cli_error.printWarning("No Semgrep rule files (*.yaml, *.yml) found", .{});
+        return;
+    }
+
+    var list = std.ArrayList(u8){};
+    defer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    try writer.print("-- Imported from Semgrep rules by `ananke import-rules`\n\nmodule {s}\n", .{module_name});
+
+    var rules: usize = 0;
+    var converted: usize = 0;
+    var constraints: usize = 0;
+    for (files.items) |file| {
+        const text = std.fs.cwd().readFileAlloc(arena, file, extract.max_source_bytes) catch |err| {
+            cli_error.printFileError(err, file);
+            return err;
+        };
+        const doc = semgrep.parseYaml(arena, text) catch |err| {
+            cli_error.printError("{s}: not a YAML file this importer can read", .{file});
+            return err;
+        };
+        const rule_list = doc.get("rules") orelse {
+            cli_error.printWarning("{s}: no rules list; skipped", .{file});
+            continue;
+        };
+        if (rule_list != .list) continue;
+
+        try writer.print("\n-- {s}\n", .{file});
+        for (rule_list.list) |rule| {
+            rules += 1;
+            switch (try semgrep.convertRule(arena, writer, rule, file)) {
+                .converted => |count| {
+                    converted += 1;
+                    constraints += count;
+                },
+                .skipped => |reason| if (verbose) {
+                    cli_error.printWarning("{s}: skipped {s}: {s}", .{ file, rule.getString("id") orelse "(no id)", reason });
+                },
+            }
+        }
+    }
+
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), list.items);
+    cli_error.printInfo("Converted {d} of {d} rules into {d} constraints", .{ converted, rules, constraints });
+    if (converted < rules) {
+        if (!verbose) cli_error.printInfo("{d} rules skipped; add --verbose for the reasons", .{rules - converted});
+        if (parsed_args.hasFlag("strict")) return error.ValidationFailed;
+    }
+}
+
+/// Rule files named on the command line, plus YAML files under directories
+fn collectRuleFiles(arena: std.mem.Allocator, paths: []const []const u8) !std.ArrayList([]const u8) {
+    var files = std.ArrayList([]const u8){};
+    for (paths) |path| {
+        const stat = std.fs.cwd().statFile(path) catch |err| {
+            cli_error.printFileError(err, path);
+            return err;
+        };
+        if (stat.kind != .directory) {
+            try files.append(arena, path);
+            continue;
+        }
+
+        var dir = try std.fs.cwd().openDir(path, .{ .iterate = true });
+        defer dir.close();
+        var walker = try dir.walk(arena);
+        defer walker.deinit();
+        const first = files.items.len;
+        while (try walker.next()) |entry| {
+            if (entry.kind != .file) continue;
+            if (!std.mem.endsWith(u8, entry.basename, ".yaml") and !std.mem.endsWith(u8, entry.basename, ".yml")) continue;
+            try files.append(arena, try std.fs.path.join(arena, &.{ path, entry.path }));
+        }
+        std.mem.sort([]const u8, files.items[first..], {}, lessThanString);
+    }
+    return files;
+}
+
+fn lessThanString(_: void, a: []const u8, b: []const u8) bool {
+    return std.mem.lessThan(u8, a, b);
+}
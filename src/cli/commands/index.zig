@@ -0,0 +1,60 @@
+// Index command - Build a disk-backed index over a stored result file
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const result_index = @import("cli_result_index");
+
+pub const usage =
+    \\Usage: ananke index <results> [options]
+    \\
+    \\Write a sidecar index (<results>.idx) recording where each constraint sits in
+    \\a stored result file, with its id, category, severity, confidence, and file.
+    \\When a current index exists, `ananke query` filters on it and `ananke
+    \\explain` looks up ids in it, parsing only the constraints they return
+    \\instead of loading the whole result file. An index older than its result
+    \\file is ignored; re-run this command after re-extracting.
+    \\
+    \\Arguments:
+    \\  <results>               Result file from `ananke extract --format json`
+    \\
+    \\Options:
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke extract . --format json -o constraints.json
+    \\  ananke index constraints.json
+    \\  ananke query constraints.json --category security --format count
+;
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const results_path = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <results>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    if (std.mem.eql(u8, results_path, "-")) {
+        cli_error.printError("Cannot index stdin; write the result to a file first", .{});
+        return error.InvalidArgument;
+    }
+
+    const index_path = try result_index.indexPath(allocator, results_path);
+    defer allocator.free(index_path);
+
+    const count = result_index.build(allocator, results_path, index_path) catch |err| {
+        if (err == error.InvalidResultFile) {
+            cli_error.printError("Not an ananke JSON result file: {s}", .{results_path});
+            return error.InvalidArgument;
+        }
+        cli_error.printFileError(err, results_path);
+        return err;
+    };
+    cli_error.printInfo("Indexed {d} constraints in {s}", .{ count, index_path });
+}
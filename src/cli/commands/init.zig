@@ -1,22 +1,34 @@
 // Init command - Initialize .ananke.toml configuration
 const std = @import("std");
+const ananke = @import("ananke");
 const args_mod = @import("cli_args");
 const config_mod = @import("cli_config");
 const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+
+const tree_sitter = ananke.clew.tree_sitter;
+const patterns = ananke.clew.patterns;
 
 pub const usage =
     \\Usage: ananke init [options]
     \\
     \\Initialize a new .ananke.toml configuration file in the current directory.
     \\
+    \\The repository is scanned for vendored, generated, fixture, and build output
+    \\directories, which are written to the config as suggested [extract] excludes,
+    \\and every installed language extractor is checked.
+    \\
     \\Options:
     \\  --config <file>         Configuration file path (default: .ananke.toml)
     \\  --modal-endpoint <url>  Set Modal inference endpoint
     \\  --force                 Overwrite existing configuration file
+    \\  --no-suggest            Do not scan the repository for exclude suggestions
+    \\  --no-verify             Skip the extractor check
     \\  --help, -h              Show this help message
     \\
     \\Examples:
     \\  ananke init
+    \\  ananke init --no-suggest
     \\  ananke init --config my-config.toml
     \\  ananke init --modal-endpoint https://my-app.modal.run
 ;
@@ -60,14 +72,121 @@ pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: con
         new_config.modal_endpoint = try allocator.dupe(u8, endpoint);
     }
 
+    const suggest = !parsed_args.hasFlag("no-suggest");
+    const verify = !parsed_args.hasFlag("no-verify");
+
+    // Survey the repository once; both the suggestions and the extractor check use it
+    var file_set: ?discovery.FileSet = null;
+    defer if (file_set) |*set| set.deinit();
+    if (suggest or verify) {
+        file_set = discovery.discover(allocator, ".", .{}) catch |err| blk: {
+            cli_error.printWarning("Could not scan the repository layout: {s}", .{@errorName(err)});
+            break :blk null;
+        };
+    }
+
+    if (suggest) {
+        if (file_set) |*set| try applySuggestions(allocator, &new_config, set);
+    }
+
     // Save to file
     try new_config.saveToFile(config_file);
 
     cli_error.printSuccess("Created configuration file: {s}", .{config_file});
+
+    var failed_extractors: usize = 0;
+    if (verify) {
+        failed_extractors = try verifyExtractors(allocator, if (file_set) |*set| set else null);
+    }
+
     std.debug.print("\n", .{});
     std.debug.print("Next steps:\n", .{});
     std.debug.print("  1. Edit {s} to configure Modal endpoint and preferences\n", .{config_file});
     std.debug.print("  2. Set ANANKE_MODAL_API_KEY environment variable for API access\n", .{});
-    std.debug.print("  3. Run 'ananke extract <file>' to start extracting constraints\n", .{});
+    std.debug.print("  3. Run 'ananke extract .' to start extracting constraints\n", .{});
+    std.debug.print("\n", .{});
+
+    if (failed_extractors > 0) {
+        cli_error.printWarning("{d} extractors used by this repository failed to load", .{failed_extractors});
+        cli_error.printInfo("Reinstall ananke or rebuild the tree-sitter grammars, then rerun 'ananke init --force'", .{});
+    }
+}
+
+/// Detect vendored, generated, and fixture paths and store them as config excludes
+fn applySuggestions(allocator: std.mem.Allocator, new_config: *config_mod.Config, set: *const discovery.FileSet) !void {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+
+    const suggestions = try discovery.suggestExcludes(arena_state.allocator(), set, ".");
+    if (suggestions.items.len == 0) {
+        cli_error.printInfo("No vendored, generated, or fixture directories detected", .{});
+        return;
+    }
+
+    const excludes = try allocator.alloc([]const u8, suggestions.items.len);
+    var filled: usize = 0;
+    errdefer {
+        for (excludes[0..filled]) |pattern| allocator.free(pattern);
+        allocator.free(excludes);
+    }
+
+    std.debug.print("Suggested excludes:\n", .{});
+    for (suggestions.items, 0..) |suggestion, i| {
+        excludes[i] = try allocator.dupe(u8, suggestion.pattern);
+        filled += 1;
+        std.debug.print("  {s:<32} {s} ({d} files)\n", .{ suggestion.pattern, suggestion.category.label(), suggestion.files });
+    }
     std.debug.print("\n", .{});
+
+    new_config.extract_excludes = excludes;
+    new_config.extract_excludes_owned = true;
+}
+
+/// Load every tree-sitter grammar and its pattern rules. Returns the number of
+/// failing languages that the repository actually contains.
+fn verifyExtractors(allocator: std.mem.Allocator, set: ?*const discovery.FileSet) !usize {
+    std.debug.print("\nExtractors:\n", .{});
+
+    var failed_in_use: usize = 0;
+    for (std.enums.values(tree_sitter.Language)) |language| {
+        const name = @tagName(language);
+
+        var files: usize = 0;
+        if (set) |s| {
+            for (s.files.items) |file| {
+                if (std.mem.eql(u8, file.language, name)) files += 1;
+            }
+        }
+
+        var rules: usize = 0;
+        if (patterns.getPatternsForLanguage(name)) |lang_patterns| {
+            inline for (std.meta.fields(patterns.LanguagePatterns)) |field| {
+                rules += @field(lang_patterns, field.name).len;
+            }
+        }
+
+        const status = checkGrammar(allocator, language);
+        const usage_note = if (files > 0)
+            try std.fmt.allocPrint(allocator, " ({d} files)", .{files})
+        else
+            try allocator.dupe(u8, "");
+        defer allocator.free(usage_note);
+
+        if (status) |_| {
+            std.debug.print("  ok    {s:<11} tree-sitter grammar, {d} pattern rules{s}\n", .{ name, rules, usage_note });
+        } else |err| {
+            std.debug.print("  FAIL  {s:<11} {s}{s}\n", .{ name, @errorName(err), usage_note });
+            if (files > 0) failed_in_use += 1;
+        }
+    }
+
+    return failed_in_use;
+}
+
+/// Load a grammar into a parser and parse an empty buffer
+fn checkGrammar(allocator: std.mem.Allocator, language: tree_sitter.Language) !void {
+    var parser = try tree_sitter.TreeSitterParser.init(allocator, language);
+    defer parser.deinit();
+    const tree = try parser.parse("");
+    tree.deinit();
 }
@@ -0,0 +1,238 @@
+// Inject-asserts command - Inject build-tagged runtime checks of struct rules where the values are handled
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const go_rules = @import("cli_go_rules");
+const go_http = @import("cli_go_http");
+const govalidate = @import("cli_govalidate");
+const goassert = @import("cli_goassert");
+const gen_tests = @import("cli/commands/gen_tests");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke inject-asserts [path] [options]
+    \\
+    \\Inject runtime assertions of the typed struct rules (the ones gen-tests and
+    \\gen-validators use) at the sites handling the constrained values: right
+    \\after a net/http handler decodes its request DTO, and on entry to every
+    \\function taking such a struct. Each site gets one line,
+    \\
+    \\  anankeCheckCreateUserRequest(&req, "api/users.go:42") // ananke:assert
+    \\
+    \\calling checks generated into `ananke_asserts.go`, built only with the
+    \\build tag, and into empty stubs in `ananke_asserts_off.go` otherwise. Run
+    \\integration environments with `go build -tags ananke_asserts` to catch
+    \\violations; regular builds are unaffected.
+    \\
+    \\Injecting again replaces earlier assertions; --remove takes them and the
+    \\generated files out.
+    \\
+    \\Arguments:
+    \\  [path]                  Go file or directory (default: .)
+    \\
+    \\Options:
+    \\  --select <globs>        Only assert the rules of matching fields, as
+    \\                          Type.Field (comma-separated, e.g. "CreateUser*.*,*.Email")
+    \\  --tag <name>            Build tag enabling the checks (default: ananke_asserts)
+    \\  --mode <mode>           What a violation does: panic, log (default: panic)
+    \\  --remove                Remove injected assertions and generated files
+    \\  --dry-run               List the files that would change
+    \\  --exclude <pattern>     Skip paths matching a glob (comma-separated)
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke inject-asserts ./internal --select "*Request.*" --mode log
+    \\  ananke inject-asserts ./internal --remove
+;
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const path = parsed_args.getPositional(0) catch ".";
+    const tag = parsed_args.getFlagOr("tag", goassert.default_tag);
+    if (tag.len == 0 or std.mem.indexOfAny(u8, tag, " \t\n!") != null) {
+        cli_error.printError("Invalid build tag: \"{s}\"", .{tag});
+        return error.InvalidArgument;
+    }
+    const mode_str = parsed_args.getFlagOr("mode", "panic");
+    const mode = std.meta.stringToEnum(goassert.Mode, mode_str) orelse {
+        cli_error.printError("Invalid mode: {s} (expected panic or log)", .{mode_str});
+        return error.InvalidArgument;
+    };
+    const remove = parsed_args.hasFlag("remove");
+    const dry_run = parsed_args.hasFlag("dry-run");
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var selectors = std.ArrayList([]const u8){};
+    if (parsed_args.getFlag("select")) |list| {
+        var parts = std.mem.splitScalar(u8, list, ',');
+        while (parts.next()) |part| {
+            const glob = std.mem.trim(u8, part, " ");
+            if (glob.len > 0) try selectors.append(arena, glob);
+        }
+    }
+    var excludes = std.ArrayList([]const u8){};
+    if (parsed_args.getFlag("exclude")) |list| {
+        var parts = std.mem.splitScalar(u8, list, ',');
+        while (parts.next()) |part| {
+            if (part.len > 0) try excludes.append(arena, part);
+        }
+    }
+    const files = try gen_tests.goFiles(arena, path, excludes.items);
+
+    // Sites and checks are worked out on the sources without earlier
+    // assertions, so their lines match what the parsers see
+    var packages = std.ArrayList(Package){};
+    var by_dir = std.StringHashMap(usize).init(arena);
+    for (files) |file| {
+        const source = std.fs.cwd().readFileAlloc(arena, file, extract.max_source_bytes) catch |err| {
+            cli_error.printFileError(err, file);
+            return err;
+        };
+        if (discovery.isGenerated(file, source)) continue;
+
+        const stripped = try goassert.strip(arena, source);
+        const rules = try go_rules.parse(arena, stripped);
+        if (rules.package.len == 0) continue;
+        const dir = std.fs.path.dirname(file) orelse ".";
+        const gop = try by_dir.getOrPut(dir);
+        if (!gop.found_existing) {
+            gop.value_ptr.* = packages.items.len;
+            try packages.append(arena, .{ .dir = dir, .name = rules.package });
+        }
+        const pkg = &packages.items[gop.value_ptr.*];
+        try pkg.files.append(arena, .{
+            .path = file,
+            .source = source,
+            .stripped = stripped,
+            .handlers = (try go_http.parse(arena, stripped)).handlers,
+        });
+        try pkg.sources.append(arena, .{ .path = trimDot(file), .structs = try selected(arena, rules.structs, selectors.items) });
+    }
+
+    var asserted: usize = 0;
+    var changed: usize = 0;
+    for (packages.items) |*pkg| {
+        const checks_path = try std.fs.path.join(arena, &.{ pkg.dir, goassert.helper_file });
+        const stubs_path = try std.fs.path.join(arena, &.{ pkg.dir, goassert.stub_file });
+        const helpers = if (remove) null else try goassert.generateHelpers(arena, pkg.name, pkg.sources.items, tag, mode);
+
+        if (helpers) |h| {
+            if (try isOurs(arena, checks_path) == false or try isOurs(arena, stubs_path) == false) {
+                cli_error.printWarning("Skipping {s}: {s} or {s} exists and was not generated by ananke", .{ pkg.dir, goassert.helper_file, goassert.stub_file });
+                continue;
+            }
+            try writeFile(checks_path, h.checks, dry_run, verbose);
+            try writeFile(stubs_path, h.stubs, dry_run, verbose);
+        } else {
+            for ([_][]const u8{ checks_path, stubs_path }) |helper| {
+                if (try isOurs(arena, helper) != true) continue;
+                if (verbose or dry_run) cli_error.printInfo("{s} {s}", .{ if (dry_run) "Would remove" else "Removed", helper });
+                if (!dry_run) try std.fs.cwd().deleteFile(helper);
+            }
+        }
+
+        const types: []const []const u8 = if (helpers) |h| h.types else &.{};
+        for (pkg.files.items) |file| {
+            const sites = try goassert.findSites(arena, file.stripped, file.handlers, types);
+            const text = try goassert.inject(arena, file.stripped, trimDot(file.path), sites);
+            asserted += sites.len;
+            if (std.mem.eql(u8, text, file.source)) continue;
+            changed += 1;
+            try writeFile(file.path, text, dry_run, verbose);
+        }
+    }
+
+    if (remove) {
+        if (!dry_run) cli_error.printSuccess("Removed assertions from {d} files", .{changed});
+        return;
+    }
+    if (asserted == 0) {
+        cli_error.printWarning("No sites handling structs with rules found in {d} Go files under {s}", .{ files.len, path });
+        return;
+    }
+    if (dry_run) return;
+    cli_error.printSuccess("Injected {d} assertions ({d} files changed); enable them with go build -tags {s}", .{ asserted, changed, tag });
+}
+
+const File = struct {
+    path: []const u8,
+    source: []const u8,
+    /// The source without earlier assertions
+    stripped: []const u8,
+    handlers: []const go_http.Handler,
+};
+
+const Package = struct {
+    dir: []const u8,
+    name: []const u8,
+    files: std.ArrayList(File) = .{},
+    sources: std.ArrayList(govalidate.Source) = .{},
+};
+
+fn trimDot(path: []const u8) []const u8 {
+    var rel = path;
+    while (std.mem.startsWith(u8, rel, "./")) rel = rel[2..];
+    return rel;
+}
+
+/// Copies of `structs` keeping only the fields matching a selector
+fn selected(arena: std.mem.Allocator, structs: []const go_rules.Struct, selectors: []const []const u8) ![]const go_rules.Struct {
+    if (selectors.len == 0) return structs;
+    var kept = std.ArrayList(go_rules.Struct){};
+    for (structs) |s| {
+        var fields = std.ArrayList(go_rules.Field){};
+        for (s.fields) |field| {
+            const name = try std.fmt.allocPrint(arena, "{s}.{s}", .{ s.name, field.name });
+            for (selectors) |glob| {
+                if (!discovery.globMatch(glob, name)) continue;
+                try fields.append(arena, field);
+                break;
+            }
+        }
+        if (fields.items.len == 0) continue;
+        var copy = s;
+        copy.fields = fields.items;
+        try kept.append(arena, copy);
+    }
+    return kept.items;
+}
+
+fn writeFile(path: []const u8, text: []const u8, dry_run: bool, verbose: bool) !void {
+    if (verbose or dry_run) cli_error.printInfo("{s} {s}", .{ if (dry_run) "Would write" else "Writing", path });
+    if (dry_run) return;
+    std.fs.cwd().writeFile(.{ .sub_path = path, .data = text }) catch |err| {
+        cli_error.printFileError(err, path);
+        return err;
+    };
+}
+
+/// Whether the file at `path` was generated by us; null when it does not exist
+fn isOurs(arena: std.mem.Allocator, path: []const u8) !?bool {
+    const head = std.fs.cwd().readFileAlloc(arena, path, 4096) catch |err| switch (err) {
+        error.FileNotFound => return null,
+        // Longer than a header: read just enough to find the marker
+        error.FileTooBig => blk: {
+            var file = try std.fs.cwd().openFile(path, .{});
+            defer file.close();
+            const buf = try arena.alloc(u8, 4096);
+            break :blk buf[0..try file.readAll(buf)];
+        },
+        else => {
+            cli_error.printFileError(err, path);
+            return err;
+        },
+    };
+    return std.mem.indexOf(u8, head, goassert.generated_marker) != null;
+}
@@ -0,0 +1,492 @@
+// Lint-rules command - Check Ariadne rule files before they are used
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const output = @import("cli_output");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const extract = @import("cli/commands/extract");
+
+const ariadne = ananke.ariadne;
+const tree_sitter = ananke.clew.tree_sitter;
+
+pub const usage =
+    \\Usage: ananke lint-rules <path>... [options]
+    \\
+    \\Check Ariadne rule files (*.ariadne) for problems that would otherwise
+    \\surface as silently ignored rules during extraction or compilation.
+    \\Directories are searched recursively.
+    \\
+    \\Errors:
+    \\  parse-error             The file does not parse
+    \\  missing-id, invalid-id  A constraint has no string id
+    \\  duplicate-id            Two constraints share an id (across all files checked)
+    \\  duplicate-name          Two constraints share a name
+    \\  invalid-confidence      provenance.confidence_score outside 0.0-1.0
+    \\
+    \\Warnings:
+    \\  unknown-key             Property the compiler does not read
+    \\  unknown-variant         enforcement, failure_mode, or provenance.source value
+    \\                          the compiler does not recognize (a default is used)
+    \\  unreachable-pattern     query() for a language without a tree-sitter grammar
+    \\  empty-pattern           query() with an empty body
+    \\  duplicate-pattern       query() identical to one in an earlier constraint
+    \\  unknown-parent          inherits a constraint that is not defined
+    \\
+    \\Options:
+    \\  --strict                Fail on warnings as well as errors
+    \\  --format <fmt>          Output format: text, json (default: text)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --help, -h              Show this help message
+    \\
+    \\Exits with status 5 when errors (or, with --strict, warnings) are found.
+    \\
+    \\Examples:
+    \\  ananke lint-rules rules/
+    \\  ananke lint-rules api.ariadne db.ariadne --strict
+    \\  ananke lint-rules rules/ --format json -o lint.json
+;
+
+const LintFormat = enum {
+    text,
+    json,
+};
+
+pub const Level = enum {
+    err,
+    warning,
+
+    fn label(self: Level) []const u8 {
+        return switch (self) {
+            .err => "error",
+            .warning => "warning",
+        };
+    }
+};
+
+pub const Finding = struct {
+    file: []const u8,
+    line: usize,
+    level: Level,
+    rule: []const u8,
+    message: []const u8,
+};
+
+const Location = struct {
+    file: []const u8,
+    line: usize,
+    constraint: []const u8,
+};
+
+/// Properties IRGenerator reads, plus documented ones it carries through
+const known_keys = [_][]const u8{ "id", "name", "description", "enforcement", "provenance", "failure_mode", "repair", "metadata", "depends_on" };
+const enforcement_variants = [_][]const u8{ ".Syntactic", ".Structural", ".Semantic", ".Performance", ".Security" };
+const failure_mode_variants = [_][]const u8{ ".HardBlock", ".SoftWarn", ".Warn", ".AutoFix", ".Suggest" };
+const source_variants = [_][]const u8{ ".ManualPolicy", ".ClewMined", ".BestPractice", ".PerformancePolicy" };
+
+fn contains(list: []const []const u8, value: []const u8) bool {
+    for (list) |item| {
+        if (std.mem.eql(u8, item, value)) return true;
+    }
+    return false;
+}
+
+/// Checks rule files one at a time; ids, names, and patterns are compared
+/// across every file linted. All memory comes from `arena`.
+pub const Linter = struct {
+    arena: std.mem.Allocator,
+    findings: std.ArrayList(Finding),
+    files: usize = 0,
+    ids: std.StringHashMap(Location),
+    names: std.StringHashMap(Location),
+    patterns: std.StringHashMap(Location),
+    /// inherits clauses, resolved once every file has been read
+    parents: std.ArrayList(struct { parent: []const u8, at: Location }),
+
+    pub fn init(arena: std.mem.Allocator) Linter {
+        return .{
+            .arena = arena,
+            .findings = .{},
+            .ids = std.StringHashMap(Location).init(arena),
+            .names = std.StringHashMap(Location).init(arena),
+            .patterns = std.StringHashMap(Location).init(arena),
+            .parents = .{},
+        };
+    }
+
+    fn report(self: *Linter, at: Location, level: Level, rule: []const u8, comptime fmt: []const u8, args: anytype) !void {
+        try self.findings.append(self.arena, .{
+            .file = at.file,
+            .line = at.line,
+            .level = level,
+            .rule = rule,
+            .message = try std.fmt.allocPrint(self.arena, fmt, args),
+        });
+    }
+
+    pub fn lintSource(self: *Linter, file: []const u8, source: []const u8) !void {
+        self.files += 1;
+
+        var parser = ariadne.Parser.init(self.arena, source);
+        const ast = parser.parse() catch |err| {
+            const at = Location{ .file = file, .line = parser.current_token.line, .constraint = "" };
+            return self.report(at, .err, "parse-error", "{s} near \"{s}\"", .{ @errorName(err), parser.current_token.lexeme });
+        };
+
+        for (ast.nodes) |node| {
+            const def = switch (node) {
+                .constraint_def => |d| d,
+                else => continue,
+            };
+            try self.lintConstraint(file, source, def);
+        }
+    }
+
+    fn lintConstraint(self: *Linter, file: []const u8, source: []const u8, def: ariadne.ConstraintDef) !void {
+        const at = Location{ .file = file, .line = definitionLine(source, def.name), .constraint = def.name };
+
+        const name = try self.names.getOrPut(def.name);
+        if (name.found_existing) {
+            try self.report(at, .err, "duplicate-name", "constraint {s} is already defined at {s}:{d}", .{ def.name, name.value_ptr.file, name.value_ptr.line });
+        } else {
+            name.value_ptr.* = at;
+        }
+        if (def.inherits) |parent| try self.parents.append(self.arena, .{ .parent = parent, .at = at });
+
+        var has_id = false;
+        var has_enforcement = false;
+        for (def.properties) |prop| {
+            if (!contains(&known_keys, prop.key)) {
+                try self.report(at, .warning, "unknown-key", "{s}: property \"{s}\" is not used by the compiler", .{ def.name, prop.key });
+            }
+
+            if (std.mem.eql(u8, prop.key, "id")) {
+                has_id = true;
+                if (prop.value != .string) {
+                    try self.report(at, .err, "invalid-id", "{s}: id must be a string", .{def.name});
+                    continue;
+                }
+                const id = try self.ids.getOrPut(prop.value.string);
+                if (id.found_existing) {
+                    try self.report(at, .err, "duplicate-id", "{s}: id \"{s}\" is already used by {s} at {s}:{d}", .{
+                        def.name,
+                        prop.value.string,
+                        id.value_ptr.constraint,
+                        id.value_ptr.file,
+                        id.value_ptr.line,
+                    });
+                } else {
+                    id.value_ptr.* = at;
+                }
+            } else if (std.mem.eql(u8, prop.key, "enforcement")) {
+                has_enforcement = true;
+                try self.checkVariant(at, prop, &enforcement_variants, ".Syntactic");
+            } else if (std.mem.eql(u8, prop.key, "failure_mode")) {
+                try self.checkVariant(at, prop, &failure_mode_variants, ".HardBlock");
+            } else if (std.mem.eql(u8, prop.key, "provenance") and prop.value == .object) {
+                for (prop.value.object) |field| {
+                    if (std.mem.eql(u8, field.key, "source")) {
+                        try self.checkVariant(at, field, &source_variants, ".ManualPolicy");
+                    } else if (std.mem.eql(u8, field.key, "confidence_score") and field.value == .number) {
+                        const score = field.value.number;
+                        if (score < 0 or score > 1) {
+                            try self.report(at, .err, "invalid-confidence", "{s}: confidence_score {d} is outside 0.0-1.0", .{ def.name, score });
+                        }
+                    }
+                }
+            }
+
+            try self.lintPatterns(at, prop.value);
+        }
+
+        if (!has_id) try self.report(at, .err, "missing-id", "{s}: constraint has no id", .{def.name});
+        if (!has_enforcement) {
+            try self.report(at, .warning, "unknown-variant", "{s}: no enforcement; the compiler assumes .Syntactic", .{def.name});
+        }
+    }
+
+    fn checkVariant(self: *Linter, at: Location, prop: ariadne.Property, allowed: []const []const u8, fallback: []const u8) !void {
+        const variant = switch (prop.value) {
+            .variant => |v| v,
+            .variant_with_value => |v| v.name,
+            else => return,
+        };
+        if (!contains(allowed, variant)) {
+            try self.report(at, .warning, "unknown-variant", "{s}: {s} {s} is not recognized by the compiler and is treated as {s}", .{
+                at.constraint,
+                prop.key,
+                variant,
+                fallback,
+            });
+        }
+    }
+
+    /// Check every query() nested in `value`
+    fn lintPatterns(self: *Linter, at: Location, value: ariadne.Value) std.mem.Allocator.Error!void {
+        switch (value) {
+            .query => |query| {
+                const pattern = std.mem.trim(u8, query.pattern, " \t\r\n");
+                if (std.meta.stringToEnum(tree_sitter.Language, query.language) == null) {
+                    try self.report(at, .warning, "unreachable-pattern", "{s}: query({s}) can never match; there is no tree-sitter grammar for {s}", .{
+                        at.constraint,
+                        query.language,
+                        query.language,
+                    });
+                }
+                if (pattern.len == 0) {
+                    return self.report(at, .warning, "empty-pattern", "{s}: query({s}) has an empty pattern", .{ at.constraint, query.language });
+                }
+
+                const key = try std.fmt.allocPrint(self.arena, "{s}\x00{s}", .{ query.language, try collapseWhitespace(self.arena, pattern) });
+                const seen = try self.patterns.getOrPut(key);
+                if (seen.found_existing) {
+                    try self.report(at, .warning, "duplicate-pattern", "{s}: query({s}) duplicates the pattern of {s} at {s}:{d}", .{
+                        at.constraint,
+                        query.language,
+                        seen.value_ptr.constraint,
+                        seen.value_ptr.file,
+                        seen.value_ptr.line,
+                    });
+                } else {
+                    seen.value_ptr.* = at;
+                }
+            },
+            .array => |items| for (items) |item| try self.lintPatterns(at, item),
+            .object => |fields| for (fields) |field| try self.lintPatterns(at, field.value),
+            .variant_with_value => |v| try self.lintPatterns(at, v.value.*),
+            else => {},
+        }
+    }
+
+    /// Resolve cross-file references; call after every file has been linted
+    pub fn finish(self: *Linter) !void {
+        for (self.parents.items) |entry| {
+            if (!self.names.contains(entry.parent)) {
+                try self.report(entry.at, .warning, "unknown-parent", "{s}: inherits unknown constraint {s}", .{ entry.at.constraint, entry.parent });
+            }
+        }
+        std.mem.sort(Finding, self.findings.items, {}, lessThanFinding);
+    }
+
+    pub fn count(self: *const Linter, level: Level) usize {
+        var n: usize = 0;
+        for (self.findings.items) |finding| {
+            if (finding.level == level) n += 1;
+        }
+        return n;
+    }
+};
+
+fn lessThanFinding(_: void, a: Finding, b: Finding) bool {
+    const order = std.mem.order(u8, a.file, b.file);
+    if (order != .eq) return order == .lt;
+    return a.line < b.line;
+}
+
+/// 1-based line of `constraint <name>`, or 0 when it cannot be found
+fn definitionLine(source: []const u8, name: []const u8) usize {
+    var offset: usize = 0;
+    while (std.mem.indexOfPos(u8, source, offset, name)) |pos| : (offset = pos + name.len) {
+        const before = std.mem.trimRight(u8, source[0..pos], " \t");
+        const end = pos + name.len;
+        const boundary = end == source.len or !(std.ascii.isAlphanumeric(source[end]) or source[end] == '_');
+        if (boundary and std.mem.endsWith(u8, before, "constraint")) {
+            return std.mem.count(u8, source[0..pos], "\n") + 1;
+        }
+    }
+    return 0;
+}
+
+fn collapseWhitespace(allocator: std.mem.Allocator, text: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    var it = std.mem.tokenizeAny(u8, text, " \t\r\n");
+    while (it.next()) |word| {
+        if (list.items.len > 0) try list.append(allocator, ' ');
+        try list.appendSlice(allocator, word);
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+/// Rule files named on the command line, expanding directories to their
+/// *.ariadne files in path order
+pub fn collectRuleFiles(arena: std.mem.Allocator, paths: []const []const u8) !std.ArrayList([]const u8) {
+    var files = std.ArrayList([]const u8){};
+    for (paths) |path| {
+        const stat = std.fs.cwd().statFile(path) catch |err| {
+            cli_error.printFileError(err, path);
+            return err;
+        };
+        if (stat.kind != .directory) {
+            try files.append(arena, path);
+            continue;
+        }
+
+        var dir = try std.fs.cwd().openDir(path, .{ .iterate = true });
+        defer dir.close();
+        var walker = try dir.walk(arena);
+        defer walker.deinit();
+        const first = files.items.len;
+        while (try walker.next()) |entry| {
+            if (entry.kind != .file or !std.mem.endsWith(u8, entry.basename, ".ariadne")) continue;
+            try files.append(arena, try std.fs.path.join(arena, &.{ path, entry.path }));
+        }
+        std.mem.sort([]const u8, files.items[first..], {}, lessThanString);
+    }
+    return files;
+}
+
+fn lessThanString(_: void, a: []const u8, b: []const u8) bool {
+    return std.mem.lessThan(u8, a, b);
+}
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const paths = parsed_args.positional.items;
+    if (paths.len == 0) {
+        cli_error.printError("Missing required argument: <path>...", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    }
+
+    const format_str = parsed_args.getFlagOr("format", "text");
+    const format = std.meta.stringToEnum(LintFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text or json)", .{format_str});
+        return error.InvalidArgument;
+    };
+    const strict = parsed_args.hasFlag("strict");
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const files = try collectRuleFiles(arena, paths);
+    if (files.items.len == 0) {
+        cli_error.printWarning("No rule files (*.ariadne) found", .{});
+        return;
+    }
+
+    var linter = Linter.init(arena);
+    for (files.items) |file| {
+        const source = std.fs.cwd().readFileAlloc(arena, file, extract.max_source_bytes) catch |err| {
+            cli_error.printFileError(err, file);
+            return err;
+        };
+        try linter.lintSource(file, source);
+    }
+    try linter.finish();
+
+    const output_text = switch (format) {
+        .text => try formatText(allocator, &linter),
+        .json => try formatJson(allocator, &linter),
+    };
+    defer allocator.free(output_text);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+
+    const errors = linter.count(.err);
+    const warnings = linter.count(.warning);
+    if (errors > 0 or (strict and warnings > 0)) return error.ValidationFailed;
+}
+
+pub fn formatText(allocator: std.mem.Allocator, linter: *const Linter) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    for (linter.findings.items) |finding| {
+        try writer.print("{s}:{d}: {s}: {s} [{s}]\n", .{
+            finding.file,
+            finding.line,
+            finding.level.label(),
+            finding.message,
+            finding.rule,
+        });
+    }
+    try writer.print("{d} errors, {d} warnings in {d} files\n", .{
+        linter.count(.err),
+        linter.count(.warning),
+        linter.files,
+    });
+
+    return list.toOwnedSlice(allocator);
+}
+
+pub fn formatJson(allocator: std.mem.Allocator, linter: *const Linter) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\n  \"files\": {d},\n  \"errors\": {d},\n  \"warnings\": {d},\n  \"findings\": [\n", .{
+        linter.files,
+        linter.count(.err),
+        linter.count(.warning),
+    });
+    for (linter.findings.items, 0..) |finding, i| {
+        try writer.writeAll("    {\"file\": \"");
+        try output.writeJsonEscaped(writer, finding.file);
+        try writer.print("\", \"line\": {d}, \"level\": \"{s}\", \"rule\": \"{s}\", \"message\": \"", .{
+            finding.line,
+            finding.level.label(),
+            finding.rule,
+        });
+        try output.writeJsonEscaped(writer, finding.message);
+        try writer.writeAll("\"}");
+        if (i + 1 < linter.findings.items.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("  ]\n}\n");
+
+    return list.toOwnedSlice(allocator);
+}
+
+test "lint finds duplicate ids, unknown variants, and unreachable patterns" {
+    const testing = std.testing;
+
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+
+    const api =
+        \\constraint no_eval {
+        \\    id: "security-001",
+        \\    enforcement: .Structural({
+        \\        pattern: query(javascript) { (call_expression) }
+        \\    }),
+        \\    failure_mode: .HardBlock
+        \\}
+        \\
+        \\constraint typed_handlers {
+        \\    id: "security-002",
+        \\    enforcement: .Type({ strictness: .Strict }),
+        \\    owner: "api-team"
+        \\}
+    ;
+    const db =
+        \\constraint no_select_star {
+        \\    id: "security-001",
+        \\    enforcement: .Structural({
+        \\        pattern: query(sql) { (select_statement) }
+        \\    }),
+        \\    provenance: { confidence_score: 1.5 }
+        \\}
+    ;
+
+    var linter = Linter.init(arena_state.allocator());
+    try linter.lintSource("api.ariadne", api);
+    try linter.lintSource("db.ariadne", db);
+    try linter.finish();
+
+    try testing.expectEqual(@as(usize, 2), linter.count(.err));
+    try testing.expectEqual(@as(usize, 3), linter.count(.warning));
+
+    const text = try formatText(testing.allocator, &linter);
+    defer testing.allocator.free(text);
+    try testing.expect(std.mem.indexOf(u8, text, "db.ariadne:1: error: no_select_star: id \"security-001\" is already used by no_eval at api.ariadne:1 [duplicate-id]") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "api.ariadne:9: warning: typed_handlers: enforcement .Type is not recognized") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "query(sql) can never match") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "property \"owner\" is not used") != null);
+}
@@ -0,0 +1,361 @@
+// MCP command - Serve warm extraction to coding agents over the Model Context Protocol
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const output = @import("cli_output");
+const version = @import("cli_version");
+const extract = @import("cli/commands/extract");
+const daemon = @import("cli/commands/daemon");
+
+pub const usage =
+    \\Usage: ananke mcp [path] [options]
+    \\
+    \\Run a Model Context Protocol server on stdin/stdout, so coding agents can
+    \\ask for constraints as tools instead of running `ananke extract` and parsing
+    \\its output. The server keeps the same warm state as `ananke daemon`: the
+    \\tree is extracted on the first tool call, and later calls re-extract only
+    \\the files edited since.
+    \\
+    \\Arguments:
+    \\  [path]                  Repository to serve; tool paths are relative to it (default: .)
+    \\
+    \\Options:
+    \\  --language, --lang <l>  Only extract files in this language
+    \\  --exclude <globs>       Comma-separated .gitignore-style patterns to skip
+    \\  --no-gitignore          Do not honor .gitignore files
+    \\  --no-default-excludes   Do not skip .git/, node_modules/, vendor/, zig-out/, .zig-cache/
+    \\  --confidence <min>      Minimum confidence threshold (0.0-1.0, default: 0.5)
+    \\  --jobs, -j <n>          Extraction workers (default: number of CPUs)
+    \\  --cache-dir <dir>       Extraction cache directory (default: .ananke-cache)
+    \\  --remote-cache <url>    Shared cache behind the local one (see `ananke extract`)
+    \\  --remote-cache-mode <m> read (default) or read-write
+    \\  --cache-max-size <size> Cache size limit (see `ananke extract`)
+    \\  --verbose, -v           Report every rebuild on stderr
+    \\  --help, -h              Show this help message
+    \\
+    \\Tools:
+    \\  extract_file            Constraints of one file, or of unsaved contents for it
+    \\  extract_package         Constraints of a directory (default: the whole tree)
+    \\  query_constraints       Filter constraints by kind, severity, package, file, or text
+    \\  diff_constraints        Changes since a saved result file, or that unsaved
+    \\                          contents would make to a file
+    \\
+    \\Tool results are the JSON answers of the matching `ananke daemon` request.
+    \\
+    \\Examples:
+    \\  ananke mcp
+    \\  ananke mcp ~/src/service --lang go
+    \\
+    \\  Client configuration:
+    \\  {"mcpServers": {"ananke": {"command": "ananke", "args": ["mcp", "/path/to/repo"]}}}
+;
+
+/// Protocol revision answered when the client asks for one this server does not know
+pub const protocol_version = "2025-06-18";
+const known_protocol_versions = [_][]const u8{ "2024-11-05", "2025-03-26", protocol_version };
+
+/// Largest message accepted; tool calls may carry an unsaved file
+const max_message_bytes = extract.max_source_bytes * 2;
+
+// JSON-RPC error codes
+const parse_error = -32700;
+const invalid_request = -32600;
+const method_not_found = -32601;
+const invalid_params = -32602;
+
+pub const Tool = enum {
+    extract_file,
+    extract_package,
+    query_constraints,
+    diff_constraints,
+};
+
+/// Tool descriptions and input schemas, in `Tool` order
+pub const tools_json =
+    \\[{"name": "extract_file", "description": "Constraints (syntactic, type safety, semantic, architectural, operational, security) extracted from one source file. Pass `source` to extract unsaved contents instead of the file on disk.",
+    \\"inputSchema": {"type": "object", "properties": {
+    \\"path": {"type": "string", "description": "File path relative to the repository root"},
+    \\"source": {"type": "string", "description": "Unsaved contents of the file"},
+    \\"language": {"type": "string", "description": "Language of `source` when the path does not tell"}},
+    \\"required": ["path"]}},
+    \\{"name": "extract_package", "description": "Constraints of every file in a directory and below it.",
+    \\"inputSchema": {"type": "object", "properties": {
+    \\"path": {"type": "string", "description": "Directory relative to the repository root (default: the whole repository)"}}}},
+    \\{"name": "query_constraints", "description": "Constraints matching all given filters. Comma-separated values within one filter match any of them.",
+    \\"inputSchema": {"type": "object", "properties": {
+    \\"path": {"type": "string", "description": "Limit to a file or directory"},
+    \\"kind": {"type": "string", "description": "syntactic, type_safety, semantic, architectural, operational, security"},
+    \\"severity": {"type": "string", "description": "error, warning, info, hint"},
+    \\"package": {"type": "string", "description": "Package (directory) of the file; pkg/... includes subpackages"},
+    \\"file": {"type": "string", "description": ".gitignore-style glob on the file path"},
+    \\"text": {"type": "string", "description": "Case-insensitive search in name, description, and file"},
+    \\"min_confidence": {"type": "number", "description": "Minimum confidence (0.0-1.0)"}}}},
+    \\{"name": "diff_constraints", "description": "Constraints added, removed, strengthened, or weakened: between a saved result file and the working tree, or between a file on disk and unsaved contents for it.",
+    \\"inputSchema": {"type": "object", "properties": {
+    \\"base": {"type": "string", "description": "Result file from `ananke extract --format json` to compare the working tree against"},
+    \\"path": {"type": "string", "description": "Limit to a file or directory; the file `source` replaces"},
+    \\"source": {"type": "string", "description": "Unsaved contents of `path`"},
+    \\"language": {"type": "string", "description": "Language of `source` when the path does not tell"}}}}]
+;
+
+/// Arguments of every tool; each tool reads the ones its schema lists
+pub const Arguments = struct {
+    path: ?[]const u8 = null,
+    source: ?[]const u8 = null,
+    language: ?[]const u8 = null,
+    base: ?[]const u8 = null,
+    kind: ?[]const u8 = null,
+    severity: ?[]const u8 = null,
+    package: ?[]const u8 = null,
+    file: ?[]const u8 = null,
+    text: ?[]const u8 = null,
+    min_confidence: ?f64 = null,
+};
+
+/// The daemon request a tool call stands for. Strings live in `arena`.
+pub fn toRequest(arena: std.mem.Allocator, tool: Tool, arguments: Arguments) !struct { daemon.Method, daemon.Request } {
+    switch (tool) {
+        .extract_file => return .{ .extract, .{
+            .method = "extract",
+            .path = arguments.path orelse return error.MissingPath,
+            .source = arguments.source,
+            .language = arguments.language,
+        } },
+        .extract_package => return .{ .extract, .{ .method = "extract", .path = arguments.path } },
+        .query_constraints => {
+            var filter = std.json.ArrayHashMap([]const u8){};
+            inline for (.{ "kind", "severity", "package", "file", "text" }) |name| {
+                if (@field(arguments, name)) |value| try filter.map.put(arena, name, value);
+            }
+            if (arguments.min_confidence) |min| {
+                try filter.map.put(arena, "min-confidence", try std.fmt.allocPrint(arena, "{d}", .{min}));
+            }
+            return .{ .query, .{ .method = "query", .path = arguments.path, .filter = filter } };
+        },
+        .diff_constraints => return .{ .diff, .{
+            .method = "diff",
+            .path = arguments.path,
+            .source = arguments.source,
+            .language = arguments.language,
+            .base = arguments.base,
+        } },
+    }
+}
+
+const Message = struct {
+    id: ?std.json.Value = null,
+    method: ?[]const u8 = null,
+    params: ?std.json.Value = null,
+};
+
+const Initialize = struct {
+    protocolVersion: []const u8 = "",
+};
+
+const Call = struct {
+    name: []const u8,
+    arguments: ?std.json.Value = null,
+};
+
+/// Answer one JSON-RPC message; null for notifications and for replies
+/// from the client. The response lives in `arena`.
+fn handle(state: *daemon.Daemon, arena: std.mem.Allocator, line: []const u8) !?[]const u8 {
+    const message = std.json.parseFromSliceLeaky(Message, arena, line, .{
+        .ignore_unknown_fields = true,
+        .allocate = .alloc_always,
+    }) catch return try errorResponse(arena, null, parse_error, "Parse error");
+    const method = message.method orelse return null;
+    const id = message.id orelse return null;
+
+    var result = std.ArrayList(u8){};
+    const writer = result.writer(arena);
+    if (std.mem.eql(u8, method, "initialize")) {
+        const params = if (message.params) |params|
+            std.json.parseFromValueLeaky(Initialize, arena, params, .{ .ignore_unknown_fields = true }) catch Initialize{}
+        else
+            Initialize{};
+        // Answer in the client's revision when we know it; otherwise the client decides
+        var answered: []const u8 = protocol_version;
+        for (known_protocol_versions) |known| {
+            if (std.mem.eql(u8, known, params.protocolVersion)) answered = known;
+        }
+        try writer.print("{{\"protocolVersion\": \"{s}\", \"capabilities\": {{\"tools\": {{}}}}, \"serverInfo\": {{\"name\": \"ananke\", \"version\": \"{s}\"}}}}", .{
+            answered,
+            version.VERSION,
+        });
+    } else if (std.mem.eql(u8, method, "ping")) {
+        try writer.writeAll("{}");
+    } else if (std.mem.eql(u8, method, "tools/list")) {
+        try writer.print("{{\"tools\": {s}}}", .{tools_json});
+    } else if (std.mem.eql(u8, method, "tools/call")) {
+        const call = std.json.parseFromValueLeaky(Call, arena, message.params orelse .null, .{
+            .ignore_unknown_fields = true,
+        }) catch return try errorResponse(arena, id, invalid_params, "Invalid tool call");
+        const tool = std.meta.stringToEnum(Tool, call.name) orelse
+            return try errorResponse(arena, id, invalid_params, "Unknown tool");
+        const arguments = if (call.arguments) |arguments|
+            std.json.parseFromValueLeaky(Arguments, arena, arguments, .{ .ignore_unknown_fields = true }) catch
+                return try errorResponse(arena, id, invalid_params, "Invalid tool arguments")
+        else
+            Arguments{};
+        try callTool(state, arena, writer, tool, arguments);
+    } else {
+        return try errorResponse(arena, id, method_not_found, "Method not found");
+    }
+
+    var response = std.ArrayList(u8){};
+    try response.writer(arena).print("{{\"jsonrpc\": \"2.0\", \"id\": {s}, \"result\": {s}}}\n", .{
+        try std.json.Stringify.valueAlloc(arena, id, .{}),
+        result.items,
+    });
+    return response.items;
+}
+
+/// Run a tool as the daemon request it stands for. Failures are tool
+/// results the agent can read, not protocol errors.
+fn callTool(state: *daemon.Daemon, arena: std.mem.Allocator, writer: anytype, tool: Tool, arguments: Arguments) !void {
+    const method, const request = toRequest(arena, tool, arguments) catch |err| {
+        return writeContent(writer, @errorName(err), true);
+    };
+    const answer = state.answer(arena, method, request) catch |err| {
+        if (state.options.verbose) cli_error.printWarning("{s} failed: {s}", .{ @tagName(tool), @errorName(err) });
+        return writeContent(writer, @errorName(err), true);
+    };
+    try writeContent(writer, std.mem.trimRight(u8, answer, "\n"), false);
+}
+
+fn writeContent(writer: anytype, text: []const u8, is_error: bool) !void {
+    try writer.writeAll("{\"content\": [{\"type\": \"text\", \"text\": \"");
+    try output.writeJsonEscaped(writer, text);
+    try writer.print("\"}}], \"isError\": {s}}}", .{if (is_error) "true" else "false"});
+}
+
+fn errorResponse(arena: std.mem.Allocator, id: ?std.json.Value, code: i32, text: []const u8) ![]const u8 {
+    return std.fmt.allocPrint(arena, "{{\"jsonrpc\": \"2.0\", \"id\": {s}, \"error\": {{\"code\": {d}, \"message\": \"{s}\"}}}}\n", .{
+        if (id) |value| try std.json.Stringify.valueAlloc(arena, value, .{}) else "null",
+        code,
+        text,
+    });
+}
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const root = parsed_args.getPositional(0) catch ".";
+    const root_stat = std.fs.cwd().statFile(root) catch |err| {
+        cli_error.printFileError(err, root);
+        return err;
+    };
+    if (root_stat.kind != .directory) {
+        cli_error.printError("Not a directory: {s}", .{root});
+        return error.InvalidArgument;
+    }
+    // Agents name files relative to the repository, wherever the client started us
+    std.process.changeCurDir(root) catch |err| {
+        cli_error.printFileError(err, root);
+        return err;
+    };
+
+    const options = try extract.Options.parse(parsed_args, config);
+    if (options.cache_dir == null) {
+        cli_error.printError("The MCP server merges unchanged files from the cache and cannot be combined with --no-cache or --use-claude", .{});
+        return error.InvalidArgument;
+    }
+
+    var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+
+    var claude_client: ?ananke.api.claude.ClaudeClient = null;
+    defer if (claude_client) |*client| client.deinit();
+    var engine = try extract.initEngine(allocator, config, options, &claude_client);
+    defer engine.deinit();
+
+    var cache = extract.openCache(allocator, options) orelse return error.InvalidArgument;
+    defer cache.close();
+
+    // The tree is extracted on the first tool call, so the client's
+    // initialize handshake is answered right away
+    var state = try daemon.Daemon.init(allocator, ".", options, .{
+        .excludes = excludes.items,
+        .use_gitignore = config.extract_gitignore and !parsed_args.hasFlag("no-gitignore"),
+        .use_default_excludes = !parsed_args.hasFlag("no-default-excludes"),
+        .language = options.language,
+    }, &engine, &cache);
+    defer state.deinit();
+    if (options.verbose) cli_error.printInfo("Serving {s} over MCP on stdio", .{root});
+
+    var recv_buffer: [8192]u8 = undefined;
+    var stdin_reader = std.fs.File.stdin().reader(&recv_buffer);
+    const reader = &stdin_reader.interface;
+    const stdout = std.fs.File.stdout();
+    while (true) {
+        var arena_state = std.heap.ArenaAllocator.init(allocator);
+        defer arena_state.deinit();
+        const arena = arena_state.allocator();
+
+        var line = std.Io.Writer.Allocating.init(arena);
+        var too_long = false;
+        _ = reader.streamDelimiterLimit(&line.writer, '\n', .limited(max_message_bytes)) catch |err| switch (err) {
+            error.StreamTooLong => too_long = true,
+            else => return err,
+        };
+        if (too_long) {
+            // Drop the rest of the message; the client gets an error in its place
+            _ = try reader.discardDelimiterInclusive('\n');
+            try stdout.writeAll(try errorResponse(arena, null, invalid_request, "Message too large"));
+            continue;
+        }
+        const at_end = if (reader.takeByte()) |_| false else |err| switch (err) {
+            error.EndOfStream => true,
+            else => return err,
+        };
+
+        const text = std.mem.trim(u8, line.written(), " \t\r");
+        if (text.len > 0) {
+            if (try handle(&state, arena, text)) |response| try stdout.writeAll(response);
+        }
+        if (at_end) break;
+    }
+}
+
+test "mcp tool calls map onto daemon requests" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    // The advertised tools are exactly the ones handled
+    const parsed = try std.json.parseFromSliceLeaky(std.json.Value, arena, tools_json, .{});
+    const listed = parsed.array.items;
+    try testing.expectEqual(@as(usize, @typeInfo(Tool).@"enum".fields.len), listed.len);
+    inline for (@typeInfo(Tool).@"enum".fields, 0..) |field, i| {
+        try testing.expectEqualStrings(field.name, listed[i].object.get("name").?.string);
+    }
+
+    const method, const request = try toRequest(arena, .query_constraints, .{
+        .path = "src/api",
+        .kind = "security",
+        .min_confidence = 0.8,
+    });
+    try testing.expectEqual(daemon.Method.query, method);
+    try testing.expectEqualStrings("src/api", request.path.?);
+    try testing.expectEqualStrings("security", request.filter.?.map.get("kind").?);
+    try testing.expectEqualStrings("0.8", request.filter.?.map.get("min-confidence").?);
+    try testing.expect(request.filter.?.map.get("severity") == null);
+
+    try testing.expectError(error.MissingPath, toRequest(arena, .extract_file, .{}));
+    const package_method, const package_request = try toRequest(arena, .extract_package, .{ .path = "pkg" });
+    try testing.expectEqual(daemon.Method.extract, package_method);
+    try testing.expect(package_request.source == null);
+
+    // Protocol errors keep the request id
+    const response = try errorResponse(arena, .{ .integer = 7 }, method_not_found, "Method not found");
+    const answer = try std.json.parseFromSliceLeaky(std.json.Value, arena, response, .{});
+    try testing.expectEqual(@as(i64, 7), answer.object.get("id").?.integer);
+    try testing.expectEqual(@as(i64, method_not_found), answer.object.get("error").?.object.get("code").?.integer);
+}
@@ -0,0 +1,223 @@
+// Merge command - Combine sharded extraction results into one
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const output = @import("cli_output");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const results = @import("cli_results");
+const extract = @import("cli/commands/extract");
+
+const constraint = ananke.types.constraint;
+
+pub const usage =
+    \\Usage: ananke merge <results>... [options]
+    \\
+    \\Combine result files from sharded or per-language extraction jobs into one
+    \\result, as if the whole tree had been extracted in a single run. Constraints
+    \\found by more than one shard (same id, file, and line) are kept once, ids are
+    \\recomputed from constraint content, and constraints are ordered by file so
+    \\the output does not depend on shard order.
+    \\
+    \\Arguments:
+    \\  <results>...            Result files from `ananke extract --format json`
+    \\
+    \\Options:
+    \\  --name <name>           Name of the merged result (default: name of the first input)
+    \\  --format <fmt>          Output format: json, yaml, pretty (default: json)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --verbose, -v           Report per-input counts
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke merge shard-*.json -o constraints.json
+    \\  ananke merge go.json ts.json py.json --name monorepo --format yaml
+;
+
+const MergeFormat = enum {
+    json,
+    yaml,
+    pretty,
+};
+
+pub const MergeStats = struct {
+    /// Constraints read across all inputs
+    total: usize = 0,
+    /// Constraints dropped because an earlier input already had them
+    duplicates: usize = 0,
+    /// Constraints whose stored id differed from their content id
+    renumbered: usize = 0,
+    /// Files whose constraints appear in more than one input
+    overlapping_files: usize = 0,
+};
+
+/// Merge `inputs` into `merged`. The first occurrence of each (id, file, line)
+/// wins; constraints are then ordered by file, keeping each file's original
+/// order. Strings are borrowed from the inputs.
+pub fn mergeSets(
+    allocator: std.mem.Allocator,
+    merged: *constraint.ConstraintSet,
+    inputs: []const constraint.ConstraintSet,
+) !MergeStats {
+    var stats = MergeStats{};
+
+    var keys_arena = std.heap.ArenaAllocator.init(allocator);
+    defer keys_arena.deinit();
+    const keys = keys_arena.allocator();
+    var seen = std.StringHashMap(void).init(allocator);
+    defer seen.deinit();
+    // File -> index of the first input that reported it
+    var file_owner = std.StringHashMap(usize).init(allocator);
+    defer file_owner.deinit();
+    var overlapping = std.StringHashMap(void).init(allocator);
+    defer overlapping.deinit();
+
+    for (inputs, 0..) |input, input_index| {
+        for (input.constraints.items) |c| {
+            stats.total += 1;
+
+            var normalized = c;
+            normalized.id = c.computeId();
+            if (c.id != normalized.id) stats.renumbered += 1;
+
+            if (c.origin_file) |file| {
+                const owner = try file_owner.getOrPut(file);
+                if (!owner.found_existing) {
+                    owner.value_ptr.* = input_index;
+                } else if (owner.value_ptr.* != input_index) {
+                    try overlapping.put(file, {});
+                }
+            }
+
+            const key = try std.fmt.allocPrint(keys, "{d}\x00{s}\x00{d}", .{
+                normalized.id,
+                c.origin_file orelse "",
+                c.origin_line orelse 0,
+            });
+            const entry = try seen.getOrPut(key);
+            if (entry.found_existing) {
+                stats.duplicates += 1;
+                continue;
+            }
+            try merged.constraints.append(allocator, normalized);
+        }
+    }
+
+    std.mem.sort(constraint.Constraint, merged.constraints.items, {}, lessThanFile);
+    stats.overlapping_files = overlapping.count();
+    return stats;
+}
+
+/// Order by file path; constraints without a file sort last. std.mem.sort is
+/// stable, so each file keeps its extraction order.
+fn lessThanFile(_: void, a: constraint.Constraint, b: constraint.Constraint) bool {
+    const a_file = a.origin_file orelse return false;
+    const b_file = b.origin_file orelse return true;
+    return std.mem.lessThan(u8, a_file, b_file);
+}
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const paths = parsed_args.positional.items;
+    if (paths.len == 0) {
+        cli_error.printError("Missing required argument: <results>...", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    }
+
+    const format_str = parsed_args.getFlagOr("format", "json");
+    const format = std.meta.stringToEnum(MergeFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected json, yaml, or pretty)", .{format_str});
+        return error.InvalidArgument;
+    };
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+
+    var loaded = std.ArrayList(results.ResultFile){};
+    defer {
+        for (loaded.items) |*result| result.deinit();
+        loaded.deinit(allocator);
+    }
+    var sets = std.ArrayList(constraint.ConstraintSet){};
+    defer sets.deinit(allocator);
+
+    for (paths) |path| {
+        var result = results.ResultFile.loadFile(allocator, path) catch |err| {
+            if (err == results.ResultError.InvalidResultFile) {
+                cli_error.printError("Not an ananke JSON result file: {s}", .{path});
+                return error.InvalidArgument;
+            }
+            cli_error.printFileError(err, path);
+            return err;
+        };
+        loaded.append(allocator, result) catch |err| {
+            result.deinit();
+            return err;
+        };
+        try sets.append(allocator, result.constraint_set);
+        if (verbose) {
+            cli_error.printInfo("{s}: {d} constraints", .{ path, result.constraint_set.constraints.items.len });
+        }
+    }
+
+    const name = parsed_args.getFlag("name") orelse loaded.items[0].constraint_set.name;
+    var merged = constraint.ConstraintSet.init(allocator, name);
+    defer merged.deinit();
+    const stats = try mergeSets(allocator, &merged, sets.items);
+
+    cli_error.printInfo("Merged {d} inputs: {d} constraints ({d} duplicates removed)", .{
+        paths.len,
+        merged.constraints.items.len,
+        stats.duplicates,
+    });
+    if (stats.overlapping_files > 0) {
+        cli_error.printWarning("{d} files appear in more than one input; check that shards do not overlap", .{stats.overlapping_files});
+    }
+    if (verbose and stats.renumbered > 0) {
+        cli_error.printInfo("Recomputed {d} ids that did not match constraint content", .{stats.renumbered});
+    }
+
+    const output_text = switch (format) {
+        .json => try output.formatJson(allocator, merged),
+        .yaml => try output.formatYaml(allocator, merged),
+        .pretty => try output.formatPretty(allocator, merged),
+    };
+    defer allocator.free(output_text);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+}
+
+test "merge drops cross-shard duplicates and orders by file" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var go_shard = constraint.ConstraintSet.init(allocator, "svc");
+    defer go_shard.deinit();
+    try go_shard.add(.{ .name = "Error check", .description = "at line 3", .kind = .semantic, .severity = .info, .origin_file = "svc/main.go", .origin_line = 3 });
+    try go_shard.add(.{ .name = "Retry loop", .description = "at line 9", .kind = .operational, .severity = .info, .origin_file = "svc/main.go", .origin_line = 9 });
+
+    var mixed_shard = constraint.ConstraintSet.init(allocator, "svc");
+    defer mixed_shard.deinit();
+    try mixed_shard.add(.{ .id = 7, .name = "Null guard", .description = "at line 1", .kind = .type_safety, .severity = .info, .origin_file = "app/index.ts", .origin_line = 1 });
+    try mixed_shard.add(.{ .name = "Error check", .description = "at line 3", .kind = .semantic, .severity = .info, .origin_file = "svc/main.go", .origin_line = 3 });
+
+    var merged = constraint.ConstraintSet.init(allocator, "svc");
+    defer merged.deinit();
+    const stats = try mergeSets(allocator, &merged, &.{ go_shard, mixed_shard });
+
+    try testing.expectEqual(@as(usize, 4), stats.total);
+    try testing.expectEqual(@as(usize, 1), stats.duplicates);
+    try testing.expectEqual(@as(usize, 1), stats.renumbered);
+    try testing.expectEqual(@as(usize, 1), stats.overlapping_files);
+
+    const items = merged.constraints.items;
+    try testing.expectEqual(@as(usize, 3), items.len);
+    try testing.expectEqualStrings("app/index.ts", items[0].origin_file.?);
+    try testing.expectEqual(items[0].computeId(), items[0].id);
+    try testing.expectEqualStrings("Error check", items[1].name);
+    try testing.expectEqualStrings("Retry loop", items[2].name);
+}
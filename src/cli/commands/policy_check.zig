@@ -0,0 +1,160 @@
+// Policy-check command - Check a codebase against the policy packs enabled in config
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const policy_packs = @import("cli_policy_packs");
+const test_coverage = @import("cli_test_coverage");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke policy-check [path] [options]
+    \\
+    \\Check the Go, TypeScript, JavaScript, and Python sources under <path>
+    \\against policy packs: curated security and operational rule bundles
+    \\enabled by name with `packs` under [policy] in the config file, or with
+    \\--pack. Test files and generated code are skipped.
+    \\
+    \\Packs:
+    \\  web-service-baseline    no-plaintext-passwords, handlers-check-method,
+    \\                          parameterized-queries
+    \\  secrets-and-tls         no-hardcoded-secrets, tls-verification
+    \\  operational-resilience  outbound-timeouts, retry-backoff,
+    \\                          server-timeouts (Go)
+    \\
+    \\Resilience findings inside an HTTP handler name the endpoint it serves.
+    \\The rules are line-based heuristics. A finding that is intended is waived
+    \\by an `ananke:allow <rule>` comment on its line or the line above.
+    \\
+    \\Arguments:
+    \\  [path]                  Directory or file to check (default: .)
+    \\
+    \\Options:
+    \\  --pack <names>          Comma-separated packs, replacing [policy] packs
+    \\  --list                  List the packs and their rules, then exit
+    \\  --format <fmt>          Output format: text, json (default: text)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --exclude <globs>       Comma-separated .gitignore-style patterns to skip
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\Exit codes:
+    \\  0   no violations
+    \\  5   violations were found
+    \\  1   invalid arguments or no pack enabled
+    \\
+    \\Examples:
+    \\  ananke policy-check --list
+    \\  ananke policy-check ./services/api --pack web-service-baseline
+    \\  ananke policy-check ./services/api --pack operational-resilience
+    \\  ananke policy-check --format json -o policy-report.json
+;
+
+const PolicyCheckFormat = enum {
+    text,
+    json,
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+    if (parsed_args.hasFlag("list")) {
+        for (policy_packs.packs) |pack| {
+            std.debug.print("{s}\n  {s}\n", .{ pack.name, pack.summary });
+            for (pack.rules) |id| {
+                const rule = policy_packs.rule(id);
+                std.debug.print("    {s:<24}{s}\n", .{ rule.name, rule.summary });
+            }
+        }
+        return;
+    }
+
+    const root = parsed_args.getPositional(0) catch ".";
+    const format_str = parsed_args.getFlagOr("format", "text");
+    const format = std.meta.stringToEnum(PolicyCheckFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text or json)", .{format_str});
+        return error.InvalidArgument;
+    };
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var names = std.ArrayList([]const u8){};
+    if (parsed_args.getFlag("pack")) |list| {
+        var parts = std.mem.splitScalar(u8, list, ',');
+        while (parts.next()) |part| {
+            const name = std.mem.trim(u8, part, " ");
+            if (name.len > 0) try names.append(arena, name);
+        }
+    } else {
+        try names.appendSlice(arena, config.policy_packs);
+    }
+    if (names.items.len == 0) {
+        cli_error.printError("No policy packs enabled", .{});
+        cli_error.printInfo("Set packs under [policy] in .ananke.toml or pass --pack; see ananke policy-check --list", .{});
+        return error.InvalidArgument;
+    }
+    var enabled = std.ArrayList(policy_packs.Pack){};
+    for (names.items) |name| {
+        const pack = policy_packs.findPack(name) orelse {
+            cli_error.printError("Unknown policy pack: {s}", .{name});
+            cli_error.printInfo("See ananke policy-check --list for the available packs", .{});
+            return error.InvalidArgument;
+        };
+        try enabled.append(arena, pack);
+    }
+
+    var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+
+    const stat = std.fs.cwd().statFile(root) catch |err| {
+        cli_error.printFileError(err, root);
+        return err;
+    };
+    var set = if (stat.kind == .directory)
+        discovery.discover(arena, root, .{ .excludes = excludes.items }) catch |err| {
+            cli_error.printFileError(err, root);
+            return err;
+        }
+    else
+        discovery.FileSet.init(arena);
+    defer set.deinit();
+    if (stat.kind != .directory) try set.addFile(root, discovery.detectLanguage(root));
+
+    var sources = std.ArrayList(policy_packs.Source){};
+    for (set.files.items) |file| {
+        if (!isChecked(file.language) or test_coverage.isTestFile(file.path)) continue;
+        const text = std.fs.cwd().readFileAlloc(arena, file.path, extract.max_source_bytes) catch |err| {
+            cli_error.printFileError(err, file.path);
+            return err;
+        };
+        if (discovery.isGenerated(file.path, text)) continue;
+        try sources.append(arena, .{ .path = file.path, .language = file.language, .text = text });
+    }
+    if (verbose) cli_error.printInfo("Checking {d} files against {d} policy packs", .{ sources.items.len, enabled.items.len });
+
+    const report = try policy_packs.check(arena, enabled.items, sources.items);
+    const output_text = switch (format) {
+        .text => try policy_packs.formatText(allocator, report),
+        .json => try policy_packs.formatJson(allocator, report),
+    };
+    defer allocator.free(output_text);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+
+    if (report.violations.len > 0) {
+        cli_error.printError("{d} policy violations", .{report.violations.len});
+        return error.ValidationFailed;
+    }
+}
+
+fn isChecked(language: []const u8) bool {
+    for ([_][]const u8{ "go", "typescript", "javascript", "python" }) |name| {
+        if (std.mem.eql(u8, language, name)) return true;
+    }
+    return false;
+}
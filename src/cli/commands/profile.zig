@@ -0,0 +1,458 @@
+// Profile command - Time and measure an extraction run stage by stage
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const output = @import("cli_output");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const profiling = @import("cli_profiling");
+const version = @import("cli_version");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke profile <path> [options]
+    \\
+    \\Run an extraction while recording wall time and heap usage for each stage
+    \\(discover, read, init, analyze, render), plus per-language and per-file
+    \\analysis cost. Stages run on a single thread so every file's cost is
+    \\attributable. Attach the JSON profile to performance reports.
+    \\
+    \\Arguments:
+    \\  <path>                  Source file or directory to profile
+    \\
+    \\Options:
+    \\  --language, --lang <l>  Only profile files in this language
+    \\  --exclude <globs>       Comma-separated .gitignore-style patterns to skip
+    \\  --no-gitignore          Do not honor .gitignore files
+    \\  --no-default-excludes   Do not skip .git/, node_modules/, vendor/, zig-out/, .zig-cache/
+    \\  --render <fmt>          Output format produced in the render stage: json, yaml, pretty
+    \\                          (default: json; the rendered output is discarded)
+    \\  --top <n>               Slowest files listed (default: 10)
+    \\  --format <fmt>          Profile format: text, json (default: text)
+    \\  --output, -o <file>     Write the profile to file instead of stdout
+    \\  --help, -h              Show this help message
+    \\
+    \\For sampled CPU call stacks, run the extraction under a system profiler,
+    \\e.g. `perf record -g -- ananke extract <path> -j 1`.
+    \\
+    \\Examples:
+    \\  ananke profile src
+    \\  ananke profile . --exclude benches --format json -o ananke-profile.json
+;
+
+const ProfileFormat = enum {
+    text,
+    json,
+};
+
+const RenderFormat = enum {
+    json,
+    yaml,
+    pretty,
+};
+
+pub const FileProfile = struct {
+    path: []const u8,
+    language: []const u8,
+    bytes: usize,
+    read_ns: u64,
+    analyze_ns: u64,
+    constraints: usize,
+};
+
+pub const LanguageProfile = struct {
+    language: []const u8,
+    files: usize = 0,
+    bytes: usize = 0,
+    analyze_ns: u64 = 0,
+    constraints: usize = 0,
+};
+
+pub const Profile = struct {
+    path: []const u8,
+    stages: []const profiling.Stage,
+    files: []const FileProfile,
+    /// Heap high-water mark over the whole run
+    peak_bytes: usize,
+    allocations: usize,
+    allocated_bytes: usize,
+    top_n: usize,
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const path = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <path>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+
+    const format_str = parsed_args.getFlagOr("format", "text");
+    const format = std.meta.stringToEnum(ProfileFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text or json)", .{format_str});
+        return error.InvalidArgument;
+    };
+    const render_str = parsed_args.getFlagOr("render", "json");
+    const render_format = std.meta.stringToEnum(RenderFormat, render_str) orelse {
+        cli_error.printError("Invalid render format: {s} (expected json, yaml, or pretty)", .{render_str});
+        return error.InvalidArgument;
+    };
+    const top_n = try parsed_args.getFlagInt("top", usize) orelse 10;
+    const language = parsed_args.getFlag("language") orelse parsed_args.getFlag("lang");
+
+    const stat = std.fs.cwd().statFile(path) catch |err| {
+        cli_error.printFileError(err, path);
+        return err;
+    };
+
+    var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+
+    // Everything measured allocates through `tracked`
+    var counting = profiling.CountingAllocator.init(allocator);
+    const tracked = counting.allocator();
+    var profiler = profiling.Profiler.init(allocator, &counting);
+    defer profiler.deinit();
+
+    profiler.begin("discover");
+    var inputs = if (stat.kind == .directory)
+        discovery.discover(tracked, path, .{
+            .excludes = excludes.items,
+            .use_gitignore = config.extract_gitignore and !parsed_args.hasFlag("no-gitignore"),
+            .use_default_excludes = !parsed_args.hasFlag("no-default-excludes"),
+            .language = language,
+        }) catch |err| {
+            cli_error.printFileError(err, path);
+            return err;
+        }
+    else blk: {
+        var single = discovery.FileSet.init(tracked);
+        errdefer single.deinit();
+        try single.addFile(path, language orelse discovery.detectLanguage(path));
+        break :blk single;
+    };
+    defer inputs.deinit();
+    try profiler.end();
+
+    if (inputs.files.items.len == 0) {
+        cli_error.printWarning("No supported source files found under {s}", .{path});
+        return;
+    }
+
+    var files = std.ArrayList(FileProfile){};
+    defer files.deinit(allocator);
+    var sources = std.ArrayList(discovery.SourceFile){};
+    defer {
+        for (sources.items) |file| tracked.free(file.source);
+        sources.deinit(allocator);
+    }
+
+    var spinner = output.Spinner.init("Profiling extraction...");
+
+    profiler.begin("read");
+    for (inputs.files.items) |input| {
+        var timer = try std.time.Timer.start();
+        const source = std.fs.cwd().readFileAlloc(tracked, input.path, extract.max_source_bytes) catch |err| {
+            cli_error.printWarning("Skipping {s}: {s}", .{ input.path, @errorName(err) });
+            continue;
+        };
+        sources.append(allocator, .{ .path = input.path, .language = input.language, .source = source }) catch |err| {
+            tracked.free(source);
+            return err;
+        };
+        try files.append(allocator, .{
+            .path = input.path,
+            .language = input.language,
+            .bytes = source.len,
+            .read_ns = timer.read(),
+            .analyze_ns = 0,
+            .constraints = 0,
+        });
+    }
+    try profiler.end();
+
+    profiler.begin("init");
+    var claude_client: ?ananke.api.claude.ClaudeClient = null;
+    var engine = try extract.initEngine(tracked, config, .{}, &claude_client);
+    defer engine.deinit();
+    try profiler.end();
+
+    var result = extract.Result.init(tracked);
+    defer result.deinit();
+
+    profiler.begin("analyze");
+    for (sources.items, files.items) |source, *file| {
+        const before = result.constraint_set.constraints.items.len;
+        var timer = try std.time.Timer.start();
+        try result.add(&engine, source);
+        file.analyze_ns = timer.read();
+        file.constraints = result.constraint_set.constraints.items.len - before;
+    }
+    try profiler.end();
+
+    profiler.begin("render");
+    const rendered = switch (render_format) {
+        .json => try output.formatJson(tracked, result.constraint_set),
+        .yaml => try output.formatYaml(tracked, result.constraint_set),
+        .pretty => try output.formatPretty(tracked, result.constraint_set),
+    };
+    tracked.free(rendered);
+    try profiler.end();
+
+    spinner.finish("Profiling complete");
+
+    var peak: usize = 0;
+    for (profiler.stages.items) |stage| peak = @max(peak, stage.peak_bytes);
+    const profile = Profile{
+        .path = path,
+        .stages = profiler.stages.items,
+        .files = files.items,
+        .peak_bytes = peak,
+        .allocations = counting.allocations,
+        .allocated_bytes = counting.total_bytes,
+        .top_n = top_n,
+    };
+
+    const output_text = switch (format) {
+        .text => try formatText(allocator, profile),
+        .json => try formatJson(allocator, profile),
+    };
+    defer allocator.free(output_text);
+
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+}
+
+/// Aggregate per-file figures by language, largest analysis time first
+pub fn byLanguage(allocator: std.mem.Allocator, files: []const FileProfile) !std.ArrayList(LanguageProfile) {
+    var languages = std.ArrayList(LanguageProfile){};
+    errdefer languages.deinit(allocator);
+    for (files) |file| {
+        const entry = for (languages.items) |*lang| {
+            if (std.mem.eql(u8, lang.language, file.language)) break lang;
+        } else blk: {
+            const added = try languages.addOne(allocator);
+            added.* = .{ .language = file.language };
+            break :blk added;
+        };
+        entry.files += 1;
+        entry.bytes += file.bytes;
+        entry.analyze_ns += file.analyze_ns;
+        entry.constraints += file.constraints;
+    }
+    std.mem.sort(LanguageProfile, languages.items, {}, struct {
+        fn lessThan(_: void, a: LanguageProfile, b: LanguageProfile) bool {
+            return a.analyze_ns > b.analyze_ns;
+        }
+    }.lessThan);
+    return languages;
+}
+
+/// Indices of the `n` files with the longest analysis time
+fn slowestFiles(allocator: std.mem.Allocator, files: []const FileProfile, n: usize) ![]usize {
+    const order = try allocator.alloc(usize, files.len);
+    errdefer allocator.free(order);
+    for (order, 0..) |*index, i| index.* = i;
+    std.mem.sort(usize, order, files, struct {
+        fn lessThan(items: []const FileProfile, a: usize, b: usize) bool {
+            return items[a].analyze_ns > items[b].analyze_ns;
+        }
+    }.lessThan);
+    return allocator.realloc(order, @min(n, files.len));
+}
+
+fn totalBytes(files: []const FileProfile) usize {
+    var total: usize = 0;
+    for (files) |file| total += file.bytes;
+    return total;
+}
+
+fn totalConstraints(files: []const FileProfile) usize {
+    var total: usize = 0;
+    for (files) |file| total += file.constraints;
+    return total;
+}
+
+pub fn formatText(allocator: std.mem.Allocator, profile: Profile) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    var total_ns: u64 = 0;
+    for (profile.stages) |stage| total_ns += stage.elapsed_ns;
+
+    try writer.print("Profile of {s}: {d} files, ", .{ profile.path, profile.files.len });
+    try profiling.writeBytes(writer, totalBytes(profile.files));
+    try writer.print(", {d} constraints\n\n", .{totalConstraints(profile.files)});
+
+    try writer.writeAll("Stage       Wall time     Share   Peak heap     Allocations\n");
+    for (profile.stages) |stage| {
+        try writer.print("{s:<10}  ", .{stage.name});
+        try writeColumn(writer, 12, durationText, stage.elapsed_ns);
+        const share: f64 = if (total_ns == 0) 0 else @as(f64, @floatFromInt(stage.elapsed_ns)) * 100 / @as(f64, @floatFromInt(total_ns));
+        try writer.print("  {d:>5.1}%  ", .{share});
+        try writeColumn(writer, 10, bytesText, stage.peak_bytes);
+        try writer.print("  {d:>14}\n", .{stage.allocations});
+    }
+    try writer.writeAll("total       ");
+    try writeColumn(writer, 12, durationText, total_ns);
+    try writer.writeAll("          ");
+    try writeColumn(writer, 10, bytesText, profile.peak_bytes);
+    try writer.print("  {d:>14}\n", .{profile.allocations});
+
+    var languages = try byLanguage(allocator, profile.files);
+    defer languages.deinit(allocator);
+    try writer.writeAll("\nLanguage       Files       Bytes       Analyze   Per file\n");
+    for (languages.items) |lang| {
+        try writer.print("{s:<12}  {d:>6}  ", .{ lang.language, lang.files });
+        try writeColumn(writer, 10, bytesText, lang.bytes);
+        try writer.writeAll("  ");
+        try writeColumn(writer, 12, durationText, lang.analyze_ns);
+        try writer.writeAll("  ");
+        try writeColumn(writer, 9, durationText, lang.analyze_ns / lang.files);
+        try writer.writeAll("\n");
+    }
+
+    const slowest = try slowestFiles(allocator, profile.files, profile.top_n);
+    defer allocator.free(slowest);
+    if (slowest.len > 0) {
+        try writer.writeAll("\nSlowest files (analyze)\n");
+        for (slowest) |index| {
+            const file = profile.files[index];
+            try writer.writeAll("  ");
+            try writeColumn(writer, 10, durationText, file.analyze_ns);
+            try writer.print("  {s} ({s}, ", .{ file.path, file.language });
+            try profiling.writeBytes(writer, file.bytes);
+            try writer.print(", {d} constraints)\n", .{file.constraints});
+        }
+    }
+
+    return list.toOwnedSlice(allocator);
+}
+
+fn durationText(writer: anytype, ns: u64) !void {
+    try profiling.writeDuration(writer, ns);
+}
+
+fn bytesText(writer: anytype, bytes: u64) !void {
+    try profiling.writeBytes(writer, @intCast(bytes));
+}
+
+/// Right-align a formatted value in a column
+fn writeColumn(writer: anytype, width: usize, comptime formatFn: anytype, value: u64) !void {
+    var buf: [32]u8 = undefined;
+    var fbs = std.io.fixedBufferStream(&buf);
+    try formatFn(fbs.writer(), value);
+    const text = fbs.getWritten();
+    if (text.len < width) try writer.writeByteNTimes(' ', width - text.len);
+    try writer.writeAll(text);
+}
+
+pub fn formatJson(allocator: std.mem.Allocator, profile: Profile) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    var total_ns: u64 = 0;
+    for (profile.stages) |stage| total_ns += stage.elapsed_ns;
+
+    try writer.writeAll("{\n  \"tool_version\": \"");
+    try output.writeJsonEscaped(writer, version.VERSION);
+    try writer.writeAll("\",\n  \"path\": \"");
+    try output.writeJsonEscaped(writer, profile.path);
+    try writer.writeAll("\",\n  \"jobs\": 1,\n");
+    try writer.print(
+        "  \"totals\": {{\"wall_ns\": {d}, \"peak_bytes\": {d}, \"allocations\": {d}, \"allocated_bytes\": {d}, \"files\": {d}, \"source_bytes\": {d}, \"constraints\": {d}}},\n",
+        .{ total_ns, profile.peak_bytes, profile.allocations, profile.allocated_bytes, profile.files.len, totalBytes(profile.files), totalConstraints(profile.files) },
+    );
+
+    try writer.writeAll("  \"stages\": [\n");
+    for (profile.stages, 0..) |stage, i| {
+        try writer.print("    {{\"name\": \"{s}\", \"wall_ns\": {d}, \"peak_bytes\": {d}, \"allocations\": {d}}}", .{
+            stage.name,
+            stage.elapsed_ns,
+            stage.peak_bytes,
+            stage.allocations,
+        });
+        try writer.writeAll(if (i + 1 < profile.stages.len) ",\n" else "\n");
+    }
+    try writer.writeAll("  ],\n");
+
+    var languages = try byLanguage(allocator, profile.files);
+    defer languages.deinit(allocator);
+    try writer.writeAll("  \"languages\": [\n");
+    for (languages.items, 0..) |lang, i| {
+        try writer.print("    {{\"language\": \"{s}\", \"files\": {d}, \"bytes\": {d}, \"analyze_ns\": {d}, \"constraints\": {d}}}", .{
+            lang.language,
+            lang.files,
+            lang.bytes,
+            lang.analyze_ns,
+            lang.constraints,
+        });
+        try writer.writeAll(if (i + 1 < languages.items.len) ",\n" else "\n");
+    }
+    try writer.writeAll("  ],\n");
+
+    try writer.writeAll("  \"files\": [\n");
+    for (profile.files, 0..) |file, i| {
+        try writer.writeAll("    {\"path\": \"");
+        try output.writeJsonEscaped(writer, file.path);
+        try writer.print("\", \"language\": \"{s}\", \"bytes\": {d}, \"read_ns\": {d}, \"analyze_ns\": {d}, \"constraints\": {d}}}", .{
+            file.language,
+            file.bytes,
+            file.read_ns,
+            file.analyze_ns,
+            file.constraints,
+        });
+        try writer.writeAll(if (i + 1 < profile.files.len) ",\n" else "\n");
+    }
+    try writer.writeAll("  ]\n}\n");
+
+    return list.toOwnedSlice(allocator);
+}
+
+test "profile reports stages, languages, and slowest files" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const stages = [_]profiling.Stage{
+        .{ .name = "read", .elapsed_ns = 2 * std.time.ns_per_ms, .peak_bytes = 4096, .allocations = 3 },
+        .{ .name = "analyze", .elapsed_ns = 8 * std.time.ns_per_ms, .peak_bytes = 2 * 1024 * 1024, .allocations = 120 },
+    };
+    const files = [_]FileProfile{
+        .{ .path = "a.go", .language = "go", .bytes = 100, .read_ns = 1000, .analyze_ns = 1 * std.time.ns_per_ms, .constraints = 2 },
+        .{ .path = "big.py", .language = "python", .bytes = 5000, .read_ns = 1000, .analyze_ns = 6 * std.time.ns_per_ms, .constraints = 9 },
+        .{ .path = "b.go", .language = "go", .bytes = 200, .read_ns = 1000, .analyze_ns = 1 * std.time.ns_per_ms, .constraints = 1 },
+    };
+    const profile = Profile{
+        .path = "src",
+        .stages = &stages,
+        .files = &files,
+        .peak_bytes = 2 * 1024 * 1024,
+        .allocations = 123,
+        .allocated_bytes = 4 * 1024 * 1024,
+        .top_n = 1,
+    };
+
+    const text = try formatText(allocator, profile);
+    defer allocator.free(text);
+    try testing.expect(std.mem.indexOf(u8, text, "Profile of src: 3 files") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "8.0 ms   80.0%") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "6.0 ms  big.py (python") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "a.go") == null);
+
+    var languages = try byLanguage(allocator, &files);
+    defer languages.deinit(allocator);
+    try testing.expectEqualStrings("python", languages.items[0].language);
+    try testing.expectEqual(@as(usize, 2), languages.items[1].files);
+
+    const json = try formatJson(allocator, profile);
+    defer allocator.free(json);
+    const parsed = try std.json.parseFromSlice(std.json.Value, allocator, json, .{});
+    defer parsed.deinit();
+    try testing.expectEqual(@as(usize, 3), parsed.value.object.get("files").?.array.items.len);
+}
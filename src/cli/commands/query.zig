@@ -0,0 +1,283 @@
+// Query command - Filter a stored extraction result
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const output = @import("cli_output");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const summary_mod = @import("cli_summary");
+const results = @import("cli_results");
+const result_index = @import("cli_result_index");
+const extract = @import("cli/commands/extract");
+
+const constraint = ananke.types.constraint;
+
+pub const usage =
+    \\Usage: ananke query <results> [filters] [options]
+    \\
+    \\Filter a stored result file without external tooling. Filters combine with AND;
+    \\comma-separated values within one filter combine with OR.
+    \\When <results> has a current index from `ananke index`, only matching
+    \\constraints are read from the file.
+    \\
+    \\Arguments:
+    \\  <results>               Result file from `ananke extract --format json`, or "-" for stdin
+    \\
+    \\Filters:
+    \\  --category, --kind <k>  syntactic, type_safety, semantic, architectural, operational, security
+    \\  --severity <s>          error, warning, info, hint
+    \\  --package <p>           Package (directory) of the file; "pkg/..." includes subpackages
+    \\  --file <glob>           .gitignore-style glob on the file path (e.g. "*.go", "src/**/auth*")
+    \\  --text, -q <text>       Case-insensitive search in name, description, and file
+    \\  --min-confidence <x>    Only constraints with at least this confidence (0.0-1.0)
+    \\
+    \\Options:
+    \\  --format <fmt>          Output format: pretty, json, yaml, count (default: pretty)
+    \\  --limit <n>             Stop after n matches
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke query constraints.json --category security --severity error,warning
+    \\  ananke query constraints.json --package internal/api/... --format json
+    \\  ananke query constraints.json --file "*_handler.go" -q token
+    \\  ananke extract . --format json | ananke query - --kind operational --format count
+;
+
+const QueryFormat = enum {
+    pretty,
+    json,
+    yaml,
+    count,
+};
+
+pub const Filter = struct {
+    kinds: ?std.EnumSet(constraint.ConstraintKind) = null,
+    severities: ?std.EnumSet(constraint.Severity) = null,
+    /// Comma-separated package list; "pkg/..." matches pkg and everything below it
+    packages: ?[]const u8 = null,
+    file_glob: ?discovery.Pattern = null,
+    text: ?[]const u8 = null,
+    min_confidence: f32 = 0.0,
+
+    pub fn matches(self: Filter, c: constraint.Constraint) bool {
+        if (self.kinds) |kinds| {
+            if (!kinds.contains(c.kind)) return false;
+        }
+        if (self.severities) |severities| {
+            if (!severities.contains(c.severity)) return false;
+        }
+        if (c.confidence < self.min_confidence) return false;
+
+        const file = c.origin_file orelse "";
+        if (self.packages) |packages| {
+            if (c.origin_file == null or !matchesPackage(packages, summary_mod.packageOf(file))) return false;
+        }
+        if (self.file_glob) |glob| {
+            if (c.origin_file == null or !glob.matches(file, false)) return false;
+        }
+        if (self.text) |text| {
+            if (!containsIgnoreCase(c.name, text) and
+                !containsIgnoreCase(c.description, text) and
+                !containsIgnoreCase(file, text)) return false;
+        }
+        return true;
+    }
+};
+
+fn matchesPackage(packages: []const u8, package: []const u8) bool {
+    var it = std.mem.splitScalar(u8, packages, ',');
+    while (it.next()) |raw| {
+        const wanted = std.mem.trimRight(u8, std.mem.trim(u8, raw, " \t"), "/");
+        if (std.mem.endsWith(u8, wanted, "/...")) {
+            const root = wanted[0 .. wanted.len - "/...".len];
+            if (std.mem.eql(u8, package, root)) return true;
+            if (std.mem.startsWith(u8, package, root) and package.len > root.len and package[root.len] == '/') return true;
+        } else if (std.mem.eql(u8, package, wanted)) {
+            return true;
+        }
+    }
+    return false;
+}
+
+fn containsIgnoreCase(haystack: []const u8, needle: []const u8) bool {
+    return std.ascii.indexOfIgnoreCase(haystack, needle) != null;
+}
+
+fn Alias(comptime E: type) type {
+    return struct { name: []const u8, value: E };
+}
+
+const severity_aliases = [_]Alias(constraint.Severity){.{ .name = "error", .value = .err }};
+
+/// Parse a comma-separated list of enum tags into a set
+fn parseEnumSet(comptime E: type, list: []const u8, aliases: []const Alias(E)) ?std.EnumSet(E) {
+    var set = std.EnumSet(E).initEmpty();
+    var it = std.mem.splitScalar(u8, list, ',');
+    while (it.next()) |raw| {
+        const name = std.mem.trim(u8, raw, " \t");
+        if (name.len == 0) continue;
+        const value = std.meta.stringToEnum(E, name) orelse blk: {
+            for (aliases) |alias| {
+                if (std.mem.eql(u8, alias.name, name)) break :blk alias.value;
+            }
+            return null;
+        };
+        set.insert(value);
+    }
+    return set;
+}
+
+/// Build a filter from command-line flags
+pub fn parseFilter(parsed_args: args_mod.Args) !Filter {
+    var filter = Filter{};
+
+    if (parsed_args.getFlag("category") orelse parsed_args.getFlag("kind")) |list| {
+        filter.kinds = parseEnumSet(constraint.ConstraintKind, list, &.{}) orelse {
+            cli_error.printError("Invalid category: {s}", .{list});
+            cli_error.printInfo("Valid categories: syntactic, type_safety, semantic, architectural, operational, security", .{});
+            return error.InvalidArgument;
+        };
+    }
+    if (parsed_args.getFlag("severity")) |list| {
+        filter.severities = parseEnumSet(constraint.Severity, list, &severity_aliases) orelse {
+            cli_error.printError("Invalid severity: {s}", .{list});
+            cli_error.printInfo("Valid severities: error, warning, info, hint", .{});
+            return error.InvalidArgument;
+        };
+    }
+    filter.packages = parsed_args.getFlag("package");
+    if (parsed_args.getFlag("file")) |glob| {
+        filter.file_glob = discovery.Pattern.parse("", glob) orelse {
+            cli_error.printError("Invalid file glob: {s}", .{glob});
+            return error.InvalidArgument;
+        };
+    }
+    filter.text = parsed_args.getFlag("text") orelse parsed_args.getFlag("q");
+    filter.min_confidence = try parsed_args.getFlagFloat("min-confidence", f32) orelse 0.0;
+    if (filter.min_confidence < 0.0 or filter.min_confidence > 1.0) {
+        cli_error.printError("Confidence threshold must be between 0.0 and 1.0", .{});
+        return error.InvalidArgument;
+    }
+
+    return filter;
+}
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const results_path = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <results>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+
+    const format_str = parsed_args.getFlagOr("format", "pretty");
+    const format = std.meta.stringToEnum(QueryFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected pretty, json, yaml, or count)", .{format_str});
+        return error.InvalidArgument;
+    };
+    const filter = try parseFilter(parsed_args);
+    const limit = try parsed_args.getFlagInt("limit", usize);
+
+    var matched = constraint.ConstraintSet.init(allocator, "");
+    defer matched.deinit();
+    // Holds constraints decoded through the index
+    var arena = std.heap.ArenaAllocator.init(allocator);
+    defer arena.deinit();
+    var loaded: ?results.ResultFile = null;
+    defer if (loaded) |*result| result.deinit();
+    var total: usize = 0;
+
+    if (try result_index.openCurrent(allocator, results_path)) |index| {
+        defer index.close();
+        matched.name = try arena.allocator().dupe(u8, index.name);
+        total = index.count;
+        try collectIndexed(arena.allocator(), index, filter, limit, &matched);
+    } else {
+        loaded = results.ResultFile.loadFile(allocator, results_path) catch |err| {
+            if (err == results.ResultError.InvalidResultFile) {
+                cli_error.printError("Not an ananke JSON result file: {s}", .{results_path});
+                return error.InvalidArgument;
+            }
+            cli_error.printFileError(err, results_path);
+            return err;
+        };
+        const result = &loaded.?;
+        matched.name = result.constraint_set.name;
+        total = result.constraint_set.constraints.items.len;
+        for (result.constraint_set.constraints.items) |c| {
+            if (limit) |n| {
+                if (matched.constraints.items.len >= n) break;
+            }
+            if (filter.matches(c)) try matched.add(c);
+        }
+    }
+
+    const output_text = switch (format) {
+        .pretty => try output.formatPretty(allocator, matched),
+        .json => try output.formatJson(allocator, matched),
+        .yaml => try output.formatYaml(allocator, matched),
+        .count => try std.fmt.allocPrint(allocator, "{d}\n", .{matched.constraints.items.len}),
+    };
+    defer allocator.free(output_text);
+
+    if (format != .count) {
+        cli_error.printInfo("{d} of {d} constraints match", .{ matched.constraints.items.len, total });
+    }
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+}
+
+/// Filter on the indexed fields first and decode only the constraints that
+/// pass them; the text filter needs the name and description, so it runs on
+/// the decoded constraint
+fn collectIndexed(
+    arena: std.mem.Allocator,
+    index: *result_index.Index,
+    filter: Filter,
+    limit: ?usize,
+    matched: *constraint.ConstraintSet,
+) !void {
+    var indexed_filter = filter;
+    indexed_filter.text = null;
+    while (try index.next()) |entry| {
+        if (limit) |n| {
+            if (matched.constraints.items.len >= n) break;
+        }
+        if (!indexed_filter.matches(entry.summary())) continue;
+        const c = try index.load(arena, entry);
+        if (filter.matches(c)) try matched.add(c);
+    }
+}
+
+test "query filter combines category, severity, package, glob, and text" {
+    const testing = std.testing;
+
+    const items = [_]constraint.Constraint{
+        .{ .name = "Token check", .description = "auth", .kind = .security, .severity = .err, .origin_file = "internal/api/auth/handler.go" },
+        .{ .name = "Token check", .description = "auth", .kind = .security, .severity = .err, .origin_file = "cmd/main.go" },
+        .{ .name = "Retry loop", .description = "backoff", .kind = .operational, .severity = .warning, .origin_file = "internal/api/client.go" },
+    };
+
+    const filter = Filter{
+        .kinds = std.EnumSet(constraint.ConstraintKind).initOne(.security),
+        .severities = parseEnumSet(constraint.Severity, "error,warning", &severity_aliases),
+        .packages = "internal/api/...",
+        .file_glob = discovery.Pattern.parse("", "*.go"),
+        .text = "TOKEN",
+    };
+
+    try testing.expect(filter.matches(items[0]));
+    try testing.expect(!filter.matches(items[1]));
+    try testing.expect(!filter.matches(items[2]));
+
+    try testing.expect(matchesPackage("svc, lib/...", "lib/util"));
+    try testing.expect(!matchesPackage("lib/...", "library"));
+    try testing.expect(parseEnumSet(constraint.Severity, "fatal", &.{}) == null);
+}
@@ -0,0 +1,221 @@
+// Review command - Record review decisions on stored constraints
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const git = @import("cli_git");
+const results = @import("cli_results");
+const approvals_mod = @import("cli_approvals");
+const constraint_diff = @import("cli_constraint_diff");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke review list <constraints> [options]
+    \\       ananke review <accept|reject|reviewed> <constraints> <constraint>... [options]
+    \\
+    \\Record who reviewed the constraints of a stored set (a JSON result of
+    \\`ananke extract --format json`) and what they decided, in an approvals
+    \\file committed next to it:
+    \\
+    \\  accepted    the constraint is an intended contract
+    \\  rejected    the constraint is not real; `verify` no longer checks it
+    \\  reviewed    seen, without a verdict yet
+    \\
+    \\`ananke verify` reads the approvals and fails on new error-severity
+    \\(critical) constraints that have no decision, so new contracts get a
+    \\reviewer before they are relied on. Decisions follow a constraint by
+    \\file, kind, and name, so they survive code moving; a later decision
+    \\replaces an earlier one.
+    \\
+    \\Subcommands:
+    \\  list                    Show the review state of every constraint
+    \\  accept                  Mark constraints accepted
+    \\  reject                  Mark constraints rejected
+    \\  reviewed                Mark constraints reviewed
+    \\
+    \\Arguments:
+    \\  <constraints>           Stored JSON result file
+    \\  <constraint>            Constraint name, or fingerprint as shown by list
+    \\
+    \\Options:
+    \\  --approvals <file>      Approvals file (default: .ananke-approvals.json)
+    \\  --file <path>           Only constraints of this file; without
+    \\                          <constraint>, every constraint of the file
+    \\  --reviewer <name>       Reviewer to record (default: git config user.name)
+    \\  --note <text>           Note to record with the decision
+    \\  --pending               list: only critical constraints without a decision
+    \\  --format <fmt>          list: text, json (default: text)
+    \\  --output, -o <file>     list: write output to file instead of stdout
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke review list constraints.json --pending
+    \\  ananke review accept constraints.json sql_params auth_required --reviewer alice
+    \\  ananke review reject constraints.json 3f2a9c0d1e4b5a67 --note "test helper, not an API"
+    \\  ananke review reviewed constraints.json --file internal/api/users.go
+;
+
+const Subcommand = enum {
+    list,
+    accept,
+    reject,
+    reviewed,
+
+    fn decision(self: Subcommand) ?approvals_mod.Decision {
+        return switch (self) {
+            .list => null,
+            .accept => .accepted,
+            .reject => .rejected,
+            .reviewed => .reviewed,
+        };
+    }
+};
+
+const ListFormat = enum {
+    text,
+    json,
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const subcommand_str = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <list|accept|reject|reviewed>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const subcommand = std.meta.stringToEnum(Subcommand, subcommand_str) orelse {
+        cli_error.printError("Unknown review subcommand: {s} (expected list, accept, reject, or reviewed)", .{subcommand_str});
+        return error.InvalidArgument;
+    };
+    const constraints_path = parsed_args.getPositional(1) catch {
+        cli_error.printError("Missing required argument: <constraints>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const approvals_path = parsed_args.getFlagOr("approvals", approvals_mod.default_path);
+
+    var stored = results.ResultFile.loadFile(allocator, constraints_path) catch |err| {
+        if (err == results.ResultError.InvalidResultFile) {
+            cli_error.printError("Not an ananke JSON result file: {s}", .{constraints_path});
+            return error.InvalidArgument;
+        }
+        cli_error.printFileError(err, constraints_path);
+        return err;
+    };
+    defer stored.deinit();
+
+    var approvals = approvals_mod.Approvals.loadFile(allocator, approvals_path) catch |err| switch (err) {
+        error.FileNotFound => approvals_mod.Approvals.init(allocator),
+        approvals_mod.ApprovalsError.UnsupportedVersion => {
+            cli_error.printError("{s}: unsupported approvals version (expected {d})", .{ approvals_path, approvals_mod.format_version });
+            return error.InvalidArgument;
+        },
+        else => {
+            cli_error.printFileError(err, approvals_path);
+            return err;
+        },
+    };
+    defer approvals.deinit();
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    // Constraints of --file, with paths compared without a leading "./"
+    const file_filter: ?[]const u8 = if (parsed_args.getFlag("file")) |path| trimDot(path) else null;
+    var candidates = std.ArrayList(ananke.Constraint){};
+    for (stored.constraint_set.constraints.items) |c| {
+        var copy = c;
+        copy.origin_file = if (c.origin_file) |file| trimDot(file) else null;
+        if (file_filter) |path| {
+            if (!std.mem.eql(u8, copy.origin_file orelse "", path)) continue;
+        }
+        try candidates.append(arena, copy);
+    }
+
+    const decision = subcommand.decision() orelse {
+        const pending_only = parsed_args.hasFlag("pending");
+        const format_str = parsed_args.getFlagOr("format", "text");
+        const format = std.meta.stringToEnum(ListFormat, format_str) orelse {
+            cli_error.printError("Invalid format: {s} (expected text or json)", .{format_str});
+            return error.InvalidArgument;
+        };
+        const output_text = switch (format) {
+            .text => try approvals_mod.formatText(allocator, candidates.items, &approvals, pending_only),
+            .json => try approvals_mod.formatJson(allocator, candidates.items, &approvals, pending_only),
+        };
+        defer allocator.free(output_text);
+        try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+        return;
+    };
+
+    const selectors = parsed_args.positional.items[2..];
+    if (selectors.len == 0 and file_filter == null) {
+        cli_error.printError("Name the constraints to mark, or pass --file", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    }
+    const reviewer = parsed_args.getFlag("reviewer") orelse gitUserName(arena) orelse {
+        cli_error.printError("No reviewer: pass --reviewer or set git config user.name", .{});
+        return error.MissingArgument;
+    };
+    var date_buf: [10]u8 = undefined;
+    const date = approvals_mod.formatDate(&date_buf, std.time.timestamp());
+    const note = parsed_args.getFlag("note");
+
+    var marked: usize = 0;
+    for (candidates.items) |c| {
+        if (selectors.len > 0 and !selected(selectors, c)) continue;
+        try approvals.record(c, decision, reviewer, date, note);
+        marked += 1;
+    }
+    for (selectors) |selector| {
+        const found = for (candidates.items) |c| {
+            if (selected(&.{selector}, c)) break true;
+        } else false;
+        if (!found) {
+            cli_error.printError("No constraint {s} in {s}{s}{s}", .{ selector, constraints_path, if (file_filter != null) " under " else "", file_filter orelse "" });
+            return error.InvalidArgument;
+        }
+    }
+    if (marked == 0) {
+        cli_error.printWarning("No constraints to mark", .{});
+        return;
+    }
+
+    const text = try approvals_mod.formatApprovals(allocator, &approvals);
+    defer allocator.free(text);
+    std.fs.cwd().writeFile(.{ .sub_path = approvals_path, .data = text }) catch |err| {
+        cli_error.printFileError(err, approvals_path);
+        return err;
+    };
+    cli_error.printSuccess("Marked {d} constraints {s} by {s} in {s}", .{ marked, @tagName(decision), reviewer, approvals_path });
+}
+
+/// Whether one of `selectors` names `c` or its fingerprint
+fn selected(selectors: []const []const u8, c: ananke.Constraint) bool {
+    var buf: [16]u8 = undefined;
+    const fingerprint = std.fmt.bufPrint(&buf, "{x:0>16}", .{constraint_diff.identityHash(c)}) catch unreachable;
+    for (selectors) |selector| {
+        if (std.mem.eql(u8, selector, c.name) or std.ascii.eqlIgnoreCase(selector, fingerprint)) return true;
+    }
+    return false;
+}
+
+fn gitUserName(arena: std.mem.Allocator) ?[]const u8 {
+    const out = git.runGit(arena, &.{ "git", "config", "user.name" }) catch return null;
+    const name = std.mem.trim(u8, out, " \t\r\n");
+    return if (name.len > 0) name else null;
+}
+
+fn trimDot(path: []const u8) []const u8 {
+    var rel = path;
+    while (std.mem.startsWith(u8, rel, "./")) rel = rel[2..];
+    return rel;
+}
@@ -0,0 +1,654 @@
+// RPC command - Serve warm extraction to protobuf clients over Connect and gRPC-Web
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const cache_store = @import("cli_cache_store");
+const constraint_diff = @import("cli_constraint_diff");
+const protobuf = @import("cli_protobuf");
+const extract = @import("cli/commands/extract");
+const daemon = @import("cli/commands/daemon");
+const serve = @import("cli/commands/serve");
+
+const constraint = ananke.types.constraint;
+
+pub const usage =
+    \\Usage: ananke rpc [path] [options]
+    \\
+    \\Serve the AnankeService of proto/ananke/v1/ananke.proto over HTTP/1.1, for
+    \\clients generated from the protos in any language. Calls are unary POST
+    \\requests to /ananke.v1.AnankeService/<Method>, in either protocol:
+    \\
+    \\  Connect   application/proto body holding the request message
+    \\  gRPC-Web  application/grpc-web+proto body holding one length-prefixed
+    \\            frame, answered with the message frame and a grpc-status trailer
+    \\
+    \\The server keeps the same warm state as `ananke daemon`: the tree is
+    \\extracted once, and each call re-extracts only the files edited since the
+    \\last one.
+    \\
+    \\Arguments:
+    \\  [path]                  Repository to serve; request paths are relative to it (default: .)
+    \\
+    \\Options:
+    \\  --port <n>              Port to listen on (default: 8378)
+    \\  --host <addr>           Address to bind (default: 127.0.0.1)
+    \\  --language, --lang <l>  Only extract files in this language
+    \\  --exclude <globs>       Comma-separated .gitignore-style patterns to skip
+    \\  --no-gitignore          Do not honor .gitignore files
+    \\  --no-default-excludes   Do not skip .git/, node_modules/, vendor/, zig-out/, .zig-cache/
+    \\  --confidence <min>      Minimum confidence threshold (0.0-1.0, default: 0.5)
+    \\  --jobs, -j <n>          Extraction workers (default: number of CPUs)
+    \\  --cache-dir <dir>       Extraction cache directory (default: .ananke-cache)
+    \\  --remote-cache <url>    Shared cache behind the local one (see `ananke extract`)
+    \\  --remote-cache-mode <m> read (default) or read-write
+    \\  --cache-max-size <size> Cache size limit (see `ananke extract`)
+    \\  --verbose, -v           Report every rebuild and failed call
+    \\  --help, -h              Show this help message
+    \\
+    \\Methods:
+    \\  Status, Extract, Query, Diff, Metrics (see the proto for their messages)
+    \\
+    \\GET /metrics answers with Prometheus metrics: calls and failures by method,
+    \\queue depth, extraction throughput, and cache hit rate.
+    \\
+    \\Each connection is served on its own thread and kept alive between calls,
+    \\until the client closes it or sends nothing for 10 seconds. Calls take
+    \\turns at the warm state, so a slow client only holds up its own connection.
+    \\Failed Connect calls answer with an HTTP status and a JSON body,
+    \\{"code": "invalid_argument", "message": "MissingPath"}; failed gRPC-Web
+    \\calls answer with the matching grpc-status and grpc-message trailers.
+    \\
+    \\Examples:
+    \\  ananke rpc --port 9000
+    \\  buf curl --protocol connect --schema proto --http1.1 \
+    \\    --data '{"path": "src/api"}' http://127.0.0.1:8378/ananke.v1.AnankeService/Extract
+    \\  buf curl --protocol grpcweb --schema proto --http1.1 \
+    \\    http://127.0.0.1:8378/ananke.v1.AnankeService/Status
+;
+
+pub const default_port: u16 = 8378;
+pub const service_path = "/ananke.v1.AnankeService/";
+
+/// Largest request body accepted; an unsaved buffer is at most an extractable file
+const max_request_bytes = extract.max_source_bytes * 2;
+
+/// Flag byte and big-endian length in front of every gRPC-Web message
+const frame_header_len = 5;
+/// Flag of the frame that carries the trailers
+const trailer_flag = 0x80;
+
+pub const Protocol = enum {
+    connect,
+    grpc_web,
+
+    /// The protocol a request's content type selects, or null for one not served
+    pub fn of(content_type: []const u8) ?Protocol {
+        if (std.mem.eql(u8, content_type, "application/proto")) return .connect;
+        if (std.mem.eql(u8, content_type, "application/grpc-web") or
+            std.mem.eql(u8, content_type, "application/grpc-web+proto")) return .grpc_web;
+        return null;
+    }
+
+    fn contentType(self: Protocol) []const u8 {
+        return switch (self) {
+            .connect => "application/proto",
+            .grpc_web => "application/grpc-web+proto",
+        };
+    }
+};
+
+/// Status codes of failed calls: Connect names them, gRPC numbers them
+pub const Code = enum(u8) {
+    ok = 0,
+    invalid_argument = 3,
+    not_found = 5,
+    unimplemented = 12,
+    internal = 13,
+};
+
+pub const Procedure = enum {
+    Status,
+    Extract,
+    Query,
+    Diff,
+    Metrics,
+
+    /// The daemon method a procedure answers, which counts it on /metrics
+    pub fn method(self: Procedure) daemon.Method {
+        return switch (self) {
+            .Status => .status,
+            .Extract => .extract,
+            .Query => .query,
+            .Diff => .diff,
+            .Metrics => .metrics,
+        };
+    }
+};
+
+/// The procedure a request target names, or null for anything else
+pub fn route(target: []const u8) ?Procedure {
+    if (!std.mem.startsWith(u8, target, service_path)) return null;
+    return std.meta.stringToEnum(Procedure, target[service_path.len..]);
+}
+
+// Field numbers and enum values of proto/ananke/v1/ananke.proto
+
+fn kindValue(kind: constraint.ConstraintKind) u64 {
+    return switch (kind) {
+        .syntactic => 1,
+        .type_safety => 2,
+        .semantic => 3,
+        .architectural => 4,
+        .operational => 5,
+        .security => 6,
+    };
+}
+
+fn severityValue(severity: constraint.Severity) u64 {
+    return switch (severity) {
+        .err => 1,
+        .warning => 2,
+        .info => 3,
+        .hint => 4,
+    };
+}
+
+fn changeValue(kind: constraint_diff.ChangeKind) u64 {
+    return switch (kind) {
+        .added => 1,
+        .removed => 2,
+        .strengthened => 3,
+        .weakened => 4,
+    };
+}
+
+/// Encode a Constraint message
+pub fn encodeConstraint(allocator: std.mem.Allocator, c: constraint.Constraint) ![]u8 {
+    var encoder = protobuf.Encoder.init(allocator);
+    errdefer encoder.deinit();
+    try encoder.uint(1, c.id);
+    try encoder.string(2, c.name);
+    try encoder.string(3, c.description);
+    try encoder.uint(4, kindValue(c.kind));
+    try encoder.uint(5, severityValue(c.severity));
+    try encoder.double(6, c.confidence);
+    try encoder.string(7, c.origin_file orelse "");
+    try encoder.uint(8, c.origin_line orelse 0);
+    try encoder.uint(9, c.frequency);
+    return encoder.buffer.toOwnedSlice(allocator);
+}
+
+fn encodeConstraints(arena: std.mem.Allocator, encoder: *protobuf.Encoder, field: u32, set: ananke.ConstraintSet) !void {
+    for (set.constraints.items) |c| try encoder.message(field, try encodeConstraint(arena, c));
+}
+
+fn encodeCounters(arena: std.mem.Allocator, counters: cache_store.Counters) ![]u8 {
+    var encoder = protobuf.Encoder.init(arena);
+    try encoder.uint(1, counters.hits);
+    try encoder.uint(2, counters.misses);
+    try encoder.uint(3, counters.writes);
+    try encoder.uint(4, counters.evictions);
+    try encoder.double(5, counters.hitRate());
+    return encoder.buffer.items;
+}
+
+/// Decode an ExtractRequest or DiffRequest (which shares its first three
+/// fields) into a daemon request. Strings borrow `data`.
+pub fn decodeRequest(arena: std.mem.Allocator, procedure: Procedure, data: []const u8) !daemon.Request {
+    var request = daemon.Request{ .method = "" };
+    var filter = std.json.ArrayHashMap([]const u8){};
+    var decoder = protobuf.Decoder.init(data);
+    while (try decoder.next()) |field| {
+        switch (procedure) {
+            .Extract, .Diff => switch (field.number) {
+                1 => request.path = nonEmpty(try field.asString()),
+                2 => request.source = try field.asString(),
+                3 => request.language = nonEmpty(try field.asString()),
+                4 => if (procedure == .Diff) {
+                    request.base = nonEmpty(try field.asString());
+                },
+                else => {},
+            },
+            .Query => switch (field.number) {
+                1 => request.path = nonEmpty(try field.asString()),
+                2...6 => {
+                    const names = [_][]const u8{ "kind", "severity", "package", "file", "text" };
+                    if (nonEmpty(try field.asString())) |value| try filter.map.put(arena, names[field.number - 2], value);
+                },
+                7 => {
+                    const min = try field.asDouble();
+                    if (min != 0) try filter.map.put(arena, "min-confidence", try std.fmt.allocPrint(arena, "{d}", .{min}));
+                },
+                else => {},
+            },
+            // Unknown fields are skipped, as protobuf requires
+            .Status, .Metrics => {},
+        }
+    }
+    if (procedure == .Query) request.filter = filter;
+    return request;
+}
+
+fn nonEmpty(value: []const u8) ?[]const u8 {
+    return if (value.len > 0) value else null;
+}
+
+/// Answer one call with an encoded response message; the message lives in `arena`
+fn call(state: *daemon.Daemon, arena: std.mem.Allocator, procedure: Procedure, body: []const u8) ![]const u8 {
+    const request = try decodeRequest(arena, procedure, body);
+    _ = try state.refresh();
+
+    var encoder = protobuf.Encoder.init(arena);
+    try encoder.uint(1, state.generation);
+    switch (procedure) {
+        .Status => {
+            try encoder.string(2, state.root);
+            try encoder.uint(3, state.tracked.count());
+            try encoder.uint(4, state.result.constraint_set.constraints.items.len);
+        },
+        .Extract => try encodeConstraints(arena, &encoder, 2, try state.extractConstraints(arena, request)),
+        .Query => try encodeConstraints(arena, &encoder, 2, try state.queryConstraints(arena, request)),
+        .Diff => {
+            const changes = try state.diffConstraints(arena, request);
+            try encoder.string(2, changes.before_label);
+            try encoder.string(3, changes.after_label);
+            for (changes.diff.changes.items) |change| {
+                var item = protobuf.Encoder.init(arena);
+                try item.uint(1, changeValue(change.kind));
+                if (change.before) |c| try item.message(2, try encodeConstraint(arena, c));
+                if (change.after) |c| try item.message(3, try encodeConstraint(arena, c));
+                try encoder.message(4, item.buffer.items);
+            }
+            try encoder.uint(5, changes.diff.unchanged);
+        },
+        .Metrics => {
+            try encoder.message(2, try encodeCounters(arena, state.served));
+            try encoder.message(3, try encodeCounters(arena, state.last_rebuild));
+            try encoder.message(4, try encodeCounters(arena, try cache_store.readCounters(arena, state.cache.dir)));
+        },
+    }
+    return encoder.buffer.items;
+}
+
+/// Status code and Connect HTTP status for a failed call
+fn errorCode(err: anyerror) struct { Code, std.http.Status } {
+    return switch (err) {
+        error.MissingPath, error.MissingBase, error.InvalidArgument, error.MalformedMessage => .{ .invalid_argument, .bad_request },
+        error.FileNotFound => .{ .not_found, .not_found },
+        error.CompressedMessage => .{ .unimplemented, .not_implemented },
+        else => .{ .internal, .internal_server_error },
+    };
+}
+
+/// The message in a unary gRPC-Web request body: exactly one frame, uncompressed
+pub fn unframe(body: []const u8) ![]const u8 {
+    if (body.len < frame_header_len) return error.MalformedMessage;
+    if (body[0] != 0) return error.CompressedMessage;
+    const len = std.mem.readInt(u32, body[1..frame_header_len], .big);
+    if (len != body.len - frame_header_len) return error.MalformedMessage;
+    return body[frame_header_len..];
+}
+
+fn appendFrame(body: *std.ArrayList(u8), arena: std.mem.Allocator, flags: u8, payload: []const u8) !void {
+    var header: [frame_header_len]u8 = undefined;
+    header[0] = flags;
+    std.mem.writeInt(u32, header[1..frame_header_len], @intCast(payload.len), .big);
+    try body.appendSlice(arena, &header);
+    try body.appendSlice(arena, payload);
+}
+
+/// A gRPC-Web response body: the message frame, if the call succeeded, then
+/// the trailer frame with its status
+pub fn grpcWebBody(arena: std.mem.Allocator, message: ?[]const u8, code: Code, text: []const u8) ![]u8 {
+    var body = std.ArrayList(u8){};
+    if (message) |m| try appendFrame(&body, arena, 0, m);
+    const trailers = if (code == .ok)
+        try std.fmt.allocPrint(arena, "grpc-status:0\r\n", .{})
+    else
+        try std.fmt.allocPrint(arena, "grpc-status:{d}\r\ngrpc-message:{s}\r\n", .{ @intFromEnum(code), text });
+    try appendFrame(&body, arena, trailer_flag, trailers);
+    return body.items;
+}
+
+/// Shared by the connection threads
+const Server = struct {
+    state: *daemon.Daemon,
+    /// Held while a call uses the warm state
+    mutex: std.Thread.Mutex = .{},
+    /// Calls received and waiting for the warm state
+    waiting: std.atomic.Value(usize) = .init(0),
+
+    /// Take the warm state; calls still waiting for it are the queue depth on /metrics
+    fn acquire(self: *Server) void {
+        _ = self.waiting.fetchAdd(1, .monotonic);
+        self.mutex.lock();
+        _ = self.waiting.fetchSub(1, .monotonic);
+        self.state.stats.queue_depth = self.waiting.load(.monotonic);
+    }
+
+    /// Answer calls on one connection until the client closes it
+    fn handle(self: *Server, connection: std.net.Server.Connection) !void {
+        var recv_buffer: [8192]u8 = undefined;
+        var send_buffer: [8192]u8 = undefined;
+        var connection_reader = connection.stream.reader(&recv_buffer);
+        var connection_writer = connection.stream.writer(&send_buffer);
+        var http_server = std.http.Server.init(connection_reader.interface(), &connection_writer.interface);
+
+        while (true) {
+            var request = http_server.receiveHead() catch |err| switch (err) {
+                error.HttpConnectionClosing => return,
+                else => return err,
+            };
+            try self.answer(&request);
+        }
+    }
+
+    fn answer(self: *Server, request: *std.http.Server.Request) !void {
+        var arena_state = std.heap.ArenaAllocator.init(self.state.allocator);
+        defer arena_state.deinit();
+        const arena = arena_state.allocator();
+
+        if (daemon.isMetricsTarget(request.head.target)) {
+            self.acquire();
+            defer self.mutex.unlock();
+            return daemon.respondMetrics(self.state, request);
+        }
+        const protocol = Protocol.of(request.head.content_type orelse "");
+        // Requests in no known protocol are told so in Connect's JSON errors
+        const reply = protocol orelse .connect;
+        const procedure = route(request.head.target) orelse {
+            self.acquire();
+            self.state.stats.malformed += 1;
+            self.mutex.unlock();
+            return respondError(request, arena, reply, .unimplemented, .not_found, "Unknown procedure");
+        };
+        if (request.head.method != .POST) {
+            return respondError(request, arena, reply, .unimplemented, .method_not_allowed, "Unary calls are POST requests");
+        }
+        if (protocol == null) {
+            return respondError(request, arena, .connect, .invalid_argument, .unsupported_media_type, "Only application/proto and application/grpc-web+proto bodies are supported");
+        }
+
+        // Bodies are read, and answers written, without holding the warm state
+        var body_buffer: [8192]u8 = undefined;
+        const reader = try request.readerExpectContinue(&body_buffer);
+        const body = try reader.allocRemaining(arena, .limited(max_request_bytes + frame_header_len));
+
+        const message = self.dispatch(arena, reply, procedure, body) catch |err| {
+            if (self.state.options.verbose) cli_error.printWarning("{s} failed: {s}", .{ @tagName(procedure), @errorName(err) });
+            const code, const status = errorCode(err);
+            return respondError(request, arena, reply, code, status, @errorName(err));
+        };
+        const answer_body = switch (reply) {
+            .connect => message,
+            .grpc_web => try grpcWebBody(arena, message, .ok, ""),
+        };
+        try request.respond(answer_body, .{
+            .extra_headers = &.{.{ .name = "content-type", .value = reply.contentType() }},
+        });
+    }
+
+    /// Answer one call and count it on /metrics
+    fn dispatch(self: *Server, arena: std.mem.Allocator, protocol: Protocol, procedure: Procedure, body: []const u8) ![]const u8 {
+        self.acquire();
+        defer self.mutex.unlock();
+        errdefer self.state.stats.record(procedure.method(), false);
+        const payload = if (protocol == .grpc_web) try unframe(body) else body;
+        const message = try call(self.state, arena, procedure, payload);
+        self.state.stats.record(procedure.method(), true);
+        return message;
+    }
+};
+
+fn serveConnection(server: *Server, connection: std.net.Server.Connection) void {
+    defer connection.stream.close();
+    // A client that disconnects mid-call only loses its own answer
+    server.handle(connection) catch {};
+}
+
+fn respondError(
+    request: *std.http.Server.Request,
+    arena: std.mem.Allocator,
+    protocol: Protocol,
+    code: Code,
+    status: std.http.Status,
+    message: []const u8,
+) !void {
+    switch (protocol) {
+        .connect => {
+            const body = try std.fmt.allocPrint(arena, "{{\"code\": \"{s}\", \"message\": \"{s}\"}}", .{ @tagName(code), message });
+            try request.respond(body, .{
+                .status = status,
+                .extra_headers = &.{.{ .name = "content-type", .value = "application/json" }},
+            });
+        },
+        // gRPC-Web failures are HTTP 200 responses with only the trailers
+        .grpc_web => try request.respond(try grpcWebBody(arena, null, code, message), .{
+            .extra_headers = &.{.{ .name = "content-type", .value = protocol.contentType() }},
+        }),
+    }
+}
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const root = parsed_args.getPositional(0) catch ".";
+    const root_stat = std.fs.cwd().statFile(root) catch |err| {
+        cli_error.printFileError(err, root);
+        return err;
+    };
+    if (root_stat.kind != .directory) {
+        cli_error.printError("Not a directory: {s}", .{root});
+        return error.InvalidArgument;
+    }
+    const host = parsed_args.getFlagOr("host", "127.0.0.1");
+    const port = try parsed_args.getFlagInt("port", u16) orelse default_port;
+    const address = std.net.Address.parseIp(host, port) catch {
+        cli_error.printError("Invalid address: {s}", .{host});
+        return error.InvalidArgument;
+    };
+    // Request paths are relative to the repository, like `ananke mcp`
+    std.process.changeCurDir(root) catch |err| {
+        cli_error.printFileError(err, root);
+        return err;
+    };
+
+    const options = try extract.Options.parse(parsed_args, config);
+    if (options.cache_dir == null) {
+        cli_error.printError("The RPC server merges unchanged files from the cache and cannot be combined with --no-cache or --use-claude", .{});
+        return error.InvalidArgument;
+    }
+
+    var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+
+    var claude_client: ?ananke.api.claude.ClaudeClient = null;
+    defer if (claude_client) |*client| client.deinit();
+    var engine = try extract.initEngine(allocator, config, options, &claude_client);
+    defer engine.deinit();
+
+    var cache = extract.openCache(allocator, options) orelse return error.InvalidArgument;
+    defer cache.close();
+
+    var state = try daemon.Daemon.init(allocator, ".", options, .{
+        .excludes = excludes.items,
+        .use_gitignore = config.extract_gitignore and !parsed_args.hasFlag("no-gitignore"),
+        .use_default_excludes = !parsed_args.hasFlag("no-default-excludes"),
+        .language = options.language,
+    }, &engine, &cache);
+    defer state.deinit();
+
+    var listener = address.listen(.{ .reuse_address = true }) catch |err| {
+        cli_error.printError("Cannot listen on {s}:{d}: {s}", .{ host, port, @errorName(err) });
+        return err;
+    };
+    defer listener.deinit();
+
+    _ = try state.refresh();
+    cli_error.printSuccess("Serving {s} ({d} files, {d} constraints) at http://{s}:{d}{s}", .{
+        root,
+        state.tracked.count(),
+        state.result.constraint_set.constraints.items.len,
+        host,
+        port,
+        service_path,
+    });
+    cli_error.printInfo("Press Ctrl-C to stop", .{});
+
+    var server = Server{ .state = &state };
+    while (true) {
+        const connection = listener.accept() catch |err| {
+            cli_error.printWarning("Accept failed: {s}", .{@errorName(err)});
+            continue;
+        };
+        serve.setReadTimeout(connection.stream.handle) catch {};
+        const thread = std.Thread.spawn(.{}, serveConnection, .{ &server, connection }) catch {
+            serveConnection(&server, connection);
+            continue;
+        };
+        thread.detach();
+    }
+}
+
+test "rpc routes and messages follow the proto" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    try testing.expectEqual(Procedure.Extract, route("/ananke.v1.AnankeService/Extract").?);
+    try testing.expect(route("/ananke.v1.AnankeService/Compile") == null);
+    try testing.expect(route("/other.Service/Extract") == null);
+    try testing.expect(daemon.isMetricsTarget("/metrics?name[]=ananke_files"));
+    try testing.expect(!daemon.isMetricsTarget("/metrics/extra"));
+
+    // QueryRequest{path: "src", kind: "security", min_confidence: 0.8}
+    var encoder = protobuf.Encoder.init(arena);
+    try encoder.string(1, "src");
+    try encoder.string(2, "security");
+    try encoder.double(7, 0.8);
+    const query = try decodeRequest(arena, .Query, encoder.buffer.items);
+    try testing.expectEqualStrings("src", query.path.?);
+    try testing.expectEqualStrings("security", query.filter.?.map.get("kind").?);
+    try testing.expectEqualStrings("0.8", query.filter.?.map.get("min-confidence").?);
+
+    // An empty but present source is still an unsaved buffer
+    var extract_encoder = protobuf.Encoder.init(arena);
+    try extract_encoder.string(1, "a.go");
+    try extract_encoder.message(2, "");
+    const extract_request = try decodeRequest(arena, .Extract, extract_encoder.buffer.items);
+    try testing.expectEqualStrings("", extract_request.source.?);
+
+    const encoded = try encodeConstraint(arena, .{
+        .id = 7,
+        .name = "csrf_token",
+        .description = "x",
+        .kind = .security,
+        .severity = .warning,
+        .origin_file = "a.go",
+        .origin_line = 3,
+    });
+    var decoder = protobuf.Decoder.init(encoded);
+    try testing.expectEqual(@as(u64, 7), (try decoder.next()).?.value.varint);
+    try testing.expectEqualStrings("csrf_token", try (try decoder.next()).?.asString());
+    _ = try decoder.next();
+    const kind = (try decoder.next()).?;
+    try testing.expectEqual(@as(u32, 4), kind.number);
+    try testing.expectEqual(@as(u64, 6), kind.value.varint);
+}
+
+test "rpc answers framed gRPC-Web calls from the warm state" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.makePath("repo");
+    try tmp.dir.writeFile(.{ .sub_path = "repo/main.py", .data =
+        \\def authenticate(user: str, password: str) -> bool:
+        \\    if not user:
+        \\        raise ValueError("user is required")
+        \\    return check_password(user, password)
+        \\
+    });
+    const root = try tmp.dir.realpathAlloc(arena, "repo");
+    const cache_path = try std.fs.path.join(arena, &.{ try tmp.dir.realpathAlloc(arena, "."), "cache" });
+    const file_path = try std.fs.path.join(arena, &.{ root, "main.py" });
+
+    var engine = try ananke.Ananke.init(allocator);
+    defer engine.deinit();
+    var cache = try cache_store.Cache.open(allocator, cache_path);
+    defer cache.close();
+    var state = try daemon.Daemon.init(allocator, root, .{}, .{ .use_gitignore = false }, &engine, &cache);
+    defer state.deinit();
+    var server = Server{ .state = &state };
+
+    // StatusRequest is empty, so its frame is the header alone
+    var status_frame = std.ArrayList(u8){};
+    try appendFrame(&status_frame, arena, 0, "");
+    try testing.expectEqual(@as(usize, frame_header_len), status_frame.items.len);
+    var status = protobuf.Decoder.init(try server.dispatch(arena, .grpc_web, .Status, status_frame.items));
+    var files: u64 = 0;
+    var total: u64 = 0;
+    while (try status.next()) |field| {
+        switch (field.number) {
+            3 => files = field.value.varint,
+            4 => total = field.value.varint,
+            else => {},
+        }
+    }
+    try testing.expectEqual(@as(u64, 1), files);
+    try testing.expect(total > 0);
+
+    // ExtractRequest{path: <root>/main.py}
+    var request = protobuf.Encoder.init(arena);
+    try request.string(1, file_path);
+    var extract_frame = std.ArrayList(u8){};
+    try appendFrame(&extract_frame, arena, 0, request.buffer.items);
+    const message = try server.dispatch(arena, .grpc_web, .Extract, extract_frame.items);
+    var response = protobuf.Decoder.init(message);
+    var constraints: usize = 0;
+    while (try response.next()) |field| {
+        if (field.number != 2) continue;
+        var item = protobuf.Decoder.init(try field.asString());
+        while (try item.next()) |c| {
+            if (c.number == 7) try testing.expectEqualStrings(file_path, try c.asString());
+        }
+        constraints += 1;
+    }
+    try testing.expectEqual(total, constraints);
+
+    // The answer is the message frame, then the trailers
+    const body = try grpcWebBody(arena, message, .ok, "");
+    try testing.expectEqualSlices(u8, message, try unframe(body[0 .. frame_header_len + message.len]));
+    const trailers = body[frame_header_len + message.len ..];
+    try testing.expectEqual(@as(u8, trailer_flag), trailers[0]);
+    try testing.expectEqualStrings("grpc-status:0\r\n", trailers[frame_header_len..]);
+
+    // A source without a path fails, and is counted as a failed extract
+    var buffer_request = protobuf.Encoder.init(arena);
+    try buffer_request.string(2, "def f(): pass\n");
+    var buffer_frame = std.ArrayList(u8){};
+    try appendFrame(&buffer_frame, arena, 0, buffer_request.buffer.items);
+    try testing.expectError(error.MissingPath, server.dispatch(arena, .grpc_web, .Extract, buffer_frame.items));
+    try testing.expectEqual(@as(u64, 2), state.stats.requests.get(.extract));
+    try testing.expectEqual(@as(u64, 1), state.stats.failures.get(.extract));
+    const code, _ = errorCode(error.MissingPath);
+    const failed = try grpcWebBody(arena, null, code, "MissingPath");
+    try testing.expectEqualStrings("grpc-status:3\r\ngrpc-message:MissingPath\r\n", failed[frame_header_len..]);
+
+    // Compressed or truncated frames are refused before any work
+    try testing.expectError(error.CompressedMessage, unframe(&.{ 1, 0, 0, 0, 0 }));
+    try testing.expectError(error.MalformedMessage, unframe(&.{ 0, 0, 0, 0, 9, 1 }));
+    try testing.expectEqual(Protocol.grpc_web, Protocol.of("application/grpc-web+proto").?);
+    try testing.expectEqual(Protocol.connect, Protocol.of("application/proto").?);
+    try testing.expect(Protocol.of("application/grpc") == null);
+}
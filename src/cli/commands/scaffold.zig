@@ -0,0 +1,183 @@
+// Scaffold command - Scaffold Go structs with validate tags and Validate methods from a type spec, or recover the spec from code
+const std = @import("std");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const go_rules = @import("cli_go_rules");
+const goscaffold = @import("cli_goscaffold");
+const extract = @import("cli/commands/extract");
+
+pub const usage =
+    \\Usage: ananke scaffold <spec> [options]
+    \\       ananke scaffold --from <path> [options]
+    \\
+    \\Scaffold Go code from a type spec for spec-first development: each struct
+    \\of the spec becomes a struct definition with json and validate tags and a
+    \\Validate method returning the first rule a value breaks. The spec is a
+    \\JSON file, written by hand or recovered from existing code with --from:
+    \\
+    \\  {"version": 1, "package": "api", "structs": [{"name": "CreateUserRequest",
+    \\    "fields": [{"name": "Username", "type": "string", "json": "username",
+    \\      "rules": ["required", "min=3", "max=50", "pattern=^[a-z0-9_]+$"]}]}]}
+    \\
+    \\Rules use go-playground/validator syntax (required, omitempty, min, max,
+    \\gte, lte, gt, lt, len, email, oneof, containsany) plus pattern=<regexp>.
+    \\The scaffolded code is yours to edit; ananke extracts it like hand-written
+    \\code, and --from reads the same rules back, so a spec round-trips.
+    \\
+    \\Arguments:
+    \\  <spec>                  Type spec to scaffold code from
+    \\
+    \\Options:
+    \\  --from <path>           Write the spec of the structs with rules in a Go
+    \\                          file or package directory instead
+    \\  --exclude <globs>       Comma-separated patterns to skip with --from
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --force                 Overwrite an existing output file
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke scaffold api.spec.json -o internal/api/types.go
+    \\  ananke scaffold --from ./internal/api -o api.spec.json
+;
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const output_file = parsed_args.getFlag("output") orelse parsed_args.getFlag("o");
+    const verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v");
+    if (output_file) |path| {
+        if (!parsed_args.hasFlag("force")) {
+            if (std.fs.cwd().access(path, .{})) |_| {
+                cli_error.printError("{s} already exists", .{path});
+                cli_error.printInfo("Scaffolded code is meant to be edited; pass --force to overwrite it", .{});
+                return error.InvalidArgument;
+            } else |_| {}
+        }
+    }
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const output_text = if (parsed_args.getFlag("from")) |from|
+        try recoverSpec(allocator, arena, from, parsed_args, config, verbose)
+    else blk: {
+        const spec_path = parsed_args.getPositional(0) catch {
+            cli_error.printError("Missing required argument: <spec>", .{});
+            std.debug.print("\n{s}\n", .{usage});
+            return error.MissingArgument;
+        };
+        break :blk try scaffoldSpec(allocator, arena, spec_path, verbose);
+    };
+    defer allocator.free(output_text);
+    try extract.writeOutput(output_file, output_text);
+}
+
+fn scaffoldSpec(allocator: std.mem.Allocator, arena: std.mem.Allocator, spec_path: []const u8, verbose: bool) ![]u8 {
+    const text = std.fs.cwd().readFileAlloc(arena, spec_path, extract.max_source_bytes) catch |err| {
+        cli_error.printFileError(err, spec_path);
+        return err;
+    };
+    const spec = goscaffold.parseSpec(arena, text) catch |err| {
+        if (err == goscaffold.ScaffoldError.UnsupportedVersion) {
+            cli_error.printError("{s}: unsupported spec version (expected {d})", .{ spec_path, goscaffold.format_version });
+        } else {
+            cli_error.printError("{s} is not a type spec: {s}", .{ spec_path, @errorName(err) });
+        }
+        return error.InvalidArgument;
+    };
+    var problem: []const u8 = "";
+    const structs = goscaffold.resolve(arena, spec, &problem) catch |err| {
+        if (err == goscaffold.ScaffoldError.InvalidSpec) {
+            cli_error.printError("{s}: {s}", .{ spec_path, problem });
+            return error.InvalidArgument;
+        }
+        return err;
+    };
+    if (verbose) cli_error.printInfo("Scaffolding {d} structs of package {s}", .{ structs.len, spec.package });
+    return goscaffold.scaffold(allocator, spec, structs, std.fs.path.basename(spec_path));
+}
+
+/// The spec of the structs with rules in the Go files under `from`, which
+/// must all belong to one package
+fn recoverSpec(
+    allocator: std.mem.Allocator,
+    arena: std.mem.Allocator,
+    from: []const u8,
+    parsed_args: args_mod.Args,
+    config: config_mod.Config,
+    verbose: bool,
+) ![]u8 {
+    var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+    defer excludes.deinit(allocator);
+
+    const stat = std.fs.cwd().statFile(from) catch |err| {
+        cli_error.printFileError(err, from);
+        return err;
+    };
+    var set = try loadFiles(arena, from, stat.kind == .directory, excludes.items);
+    defer set.deinit();
+
+    var package: ?[]const u8 = null;
+    var structs = std.ArrayList(go_rules.Struct){};
+    for (set.files.items) |file| {
+        if (std.mem.endsWith(u8, file.path, "_test.go")) continue;
+        // Only the package itself, not the ones nested under it
+        if (stat.kind == .directory and std.mem.indexOfScalar(u8, trimRoot(from, file.path), '/') != null) continue;
+        const source = std.fs.cwd().readFileAlloc(arena, file.path, extract.max_source_bytes) catch |err| {
+            cli_error.printFileError(err, file.path);
+            return err;
+        };
+        if (discovery.isGenerated(file.path, source)) continue;
+        const rules = try go_rules.parse(arena, source);
+        if (package) |name| {
+            if (!std.mem.eql(u8, name, rules.package)) {
+                cli_error.printError("{s} declares package {s}, not {s}", .{ file.path, rules.package, name });
+                cli_error.printInfo("Pass a single package directory", .{});
+                return error.InvalidArgument;
+            }
+        } else {
+            package = rules.package;
+        }
+        var found: usize = 0;
+        for (rules.structs) |s| {
+            if (!s.hasChecks()) continue;
+            try structs.append(arena, s);
+            found += 1;
+        }
+        if (verbose and found > 0) cli_error.printInfo("{s}: {d} structs with rules", .{ file.path, found });
+    }
+
+    if (structs.items.len == 0) {
+        cli_error.printError("No structs with validation rules in {s}", .{from});
+        return error.InvalidArgument;
+    }
+    return goscaffold.formatSpec(allocator, package.?, structs.items);
+}
+
+fn loadFiles(arena: std.mem.Allocator, from: []const u8, is_dir: bool, excludes: []const []const u8) !discovery.FileSet {
+    if (!is_dir) {
+        var set = discovery.FileSet.init(arena);
+        errdefer set.deinit();
+        try set.addFile(from, "go");
+        return set;
+    }
+    return discovery.discover(arena, from, .{ .excludes = excludes, .language = "go" }) catch |err| {
+        cli_error.printFileError(err, from);
+        return err;
+    };
+}
+
+/// `path` from a walk of `root`, made relative to it
+fn trimRoot(root: []const u8, path: []const u8) []const u8 {
+    const base = std.mem.trimRight(u8, root, "/");
+    if (base.len == 0 or std.mem.eql(u8, base, ".")) return path;
+    if (std.mem.startsWith(u8, path, base) and path.len > base.len and path[base.len] == '/') return path[base.len + 1 ..];
+    return path;
+}
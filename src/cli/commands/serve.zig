@@ -0,0 +1,260 @@
+// Serve command - Browse a result file in a local web server
+const std = @import("std");
+const builtin = @import("builtin");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const messages = @import("cli_messages");
+const report = @import("cli_report");
+const results = @import("cli_results");
+const query = @import("cli/commands/query");
+
+const constraint = ananke.types.constraint;
+
+pub const usage =
+    \\Usage: ananke serve <results> [options]
+    \\
+    \\Serve the HTML report for a result file on a local web server. The file is
+    \\re-read on every request, so rerunning `ananke extract` and refreshing the
+    \\page shows the new results. Each connection is served on its own thread
+    \\and dropped when the client sends nothing for 10 seconds.
+    \\
+    \\Arguments:
+    \\  <results>               Result file from `ananke extract --format json`
+    \\
+    \\Options:
+    \\  --port <n>              Port to listen on (default: 8377)
+    \\  --host <addr>           Address to bind (default: 127.0.0.1)
+    \\  --messages <file>       Message catalog for report strings
+    \\  --help, -h              Show this help message
+    \\
+    \\Pages:
+    \\  /                       Full report; type in the search box to filter rows
+    \\  /?q=<text>              Only constraints whose name, description, or file match
+    \\  /c/<id>                 Link to one constraint (redirects to its row)
+    \\  /results.json           The raw result file
+    \\
+    \\Examples:
+    \\  ananke serve constraints.json
+    \\  ananke serve constraints.json --port 9000
+;
+
+pub const default_port: u16 = 8377;
+/// Seconds a connection may wait on a stalled client before it is dropped
+const read_timeout_seconds = 10;
+
+pub const Route = union(enum) {
+    /// Report page, optionally filtered by a search term
+    report: ?[]const u8,
+    /// Deep link to a single constraint
+    constraint: constraint.ConstraintID,
+    raw,
+    not_found,
+};
+
+/// Map a request target to a page. The search term is percent-decoded in
+/// place, so `target` must be writable.
+pub fn route(target: []u8) Route {
+    const question = std.mem.indexOfScalar(u8, target, '?');
+    const path = target[0 .. question orelse target.len];
+
+    if (std.mem.eql(u8, path, "/") or std.mem.eql(u8, path, "/index.html")) {
+        const query_string = if (question) |q| target[q + 1 ..] else return .{ .report = null };
+        var params = std.mem.splitScalar(u8, query_string, '&');
+        while (params.next()) |param| {
+            if (!std.mem.startsWith(u8, param, "q=")) continue;
+            // `param` is read-only; decode through the matching slice of `target`
+            const start = @intFromPtr(param.ptr) - @intFromPtr(target.ptr) + "q=".len;
+            const value = target[start .. start + param.len - "q=".len];
+            for (value) |*c| {
+                if (c.* == '+') c.* = ' ';
+            }
+            const text = std.Uri.percentDecodeInPlace(value);
+            return .{ .report = if (text.len > 0) text else null };
+        }
+        return .{ .report = null };
+    }
+    if (std.mem.eql(u8, path, "/results.json")) return .raw;
+    if (std.mem.startsWith(u8, path, "/c/")) {
+        if (results.parseId(path["/c/".len..])) |id| return .{ .constraint = id };
+    }
+    return .not_found;
+}
+
+const Response = struct {
+    status: std.http.Status = .ok,
+    content_type: []const u8 = "text/html; charset=utf-8",
+    location: ?[]const u8 = null,
+    body: []const u8 = "",
+};
+
+const Server = struct {
+    allocator: std.mem.Allocator,
+    results_path: []const u8,
+    catalog: *const messages.Catalog,
+
+    /// Build the response for one request; strings live in `arena`
+    fn respond(self: *Server, arena: std.mem.Allocator, target: []u8) !Response {
+        switch (route(target)) {
+            .not_found => return .{ .status = .not_found, .content_type = "text/plain; charset=utf-8", .body = "Not found\n" },
+            .constraint => |id| return .{
+                .status = .found,
+                .location = try std.fmt.allocPrint(arena, "/#c-{d}", .{id}),
+            },
+            .raw => {
+                const text = try std.fs.cwd().readFileAlloc(arena, self.results_path, results.max_result_bytes);
+                return .{ .content_type = "application/json", .body = text };
+            },
+            .report => |text| {
+                var result = try results.ResultFile.loadFile(self.allocator, self.results_path);
+                defer result.deinit();
+
+                var shown = constraint.ConstraintSet.init(arena, result.constraint_set.name);
+                const filter = query.Filter{ .text = text };
+                for (result.constraint_set.constraints.items) |c| {
+                    if (filter.matches(c)) try shown.constraints.append(arena, c);
+                }
+                return .{ .body = try report.formatHtml(arena, shown, self.catalog, text) };
+            },
+        }
+    }
+
+    fn handle(self: *Server, connection: std.net.Server.Connection) !void {
+        var recv_buffer: [8192]u8 = undefined;
+        var send_buffer: [8192]u8 = undefined;
+        var connection_reader = connection.stream.reader(&recv_buffer);
+        var connection_writer = connection.stream.writer(&send_buffer);
+        var http_server = std.http.Server.init(connection_reader.interface(), &connection_writer.interface);
+
+        var request = try http_server.receiveHead();
+
+        var arena_state = std.heap.ArenaAllocator.init(self.allocator);
+        defer arena_state.deinit();
+        const arena = arena_state.allocator();
+
+        const target = try arena.dupe(u8, request.head.target);
+        const response = self.respond(arena, target) catch |err| blk: {
+            cli_error.printWarning("{s} {s}: {s}", .{ @tagName(request.head.method), request.head.target, @errorName(err) });
+            const message = if (err == results.ResultError.InvalidResultFile)
+                "The result file is not an ananke JSON result.\n"
+            else
+                try std.fmt.allocPrint(arena, "Could not read {s}: {s}\n", .{ self.results_path, @errorName(err) });
+            break :blk Response{
+                .status = .internal_server_error,
+                .content_type = "text/plain; charset=utf-8",
+                .body = message,
+            };
+        };
+
+        var headers = std.ArrayList(std.http.Header){};
+        try headers.append(arena, .{ .name = "content-type", .value = response.content_type });
+        if (response.location) |location| try headers.append(arena, .{ .name = "location", .value = location });
+        try request.respond(response.body, .{
+            .status = response.status,
+            .keep_alive = false,
+            .extra_headers = headers.items,
+        });
+    }
+};
+
+/// Serve one connection on its own thread; a client that disconnects or
+/// stalls mid-request only loses its own page
+fn serveConnection(server: *Server, connection: std.net.Server.Connection) void {
+    defer connection.stream.close();
+    server.handle(connection) catch {};
+}
+
+/// Fail reads that wait longer than `read_timeout_seconds`
+pub fn setReadTimeout(handle: std.posix.socket_t) !void {
+    if (builtin.os.tag == .windows) return;
+    const timeout = std.posix.timeval{ .sec = read_timeout_seconds, .usec = 0 };
+    try std.posix.setsockopt(handle, std.posix.SOL.SOCKET, std.posix.SO.RCVTIMEO, std.mem.asBytes(&timeout));
+}
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const results_path = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <results>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+
+    // Fail early on a bad file rather than on the first page load
+    var initial = results.ResultFile.loadFile(allocator, results_path) catch |err| {
+        if (err == results.ResultError.InvalidResultFile) {
+            cli_error.printError("Not an ananke JSON result file: {s}", .{results_path});
+            return error.InvalidArgument;
+        }
+        cli_error.printFileError(err, results_path);
+        return err;
+    };
+    const count = initial.constraint_set.constraints.items.len;
+    initial.deinit();
+
+    const host = parsed_args.getFlagOr("host", "127.0.0.1");
+    const port = try parsed_args.getFlagInt("port", u16) orelse default_port;
+    const address = std.net.Address.parseIp(host, port) catch {
+        cli_error.printError("Invalid address: {s}", .{host});
+        return error.InvalidArgument;
+    };
+
+    var catalog = if (parsed_args.getFlag("messages") orelse config.report_messages) |path|
+        messages.Catalog.loadFile(allocator, path) catch |err| {
+            cli_error.printFileError(err, path);
+            return err;
+        }
+    else
+        messages.Catalog.default();
+    defer catalog.deinit();
+
+    var listener = address.listen(.{ .reuse_address = true }) catch |err| {
+        cli_error.printError("Cannot listen on {s}:{d}: {s}", .{ host, port, @errorName(err) });
+        return err;
+    };
+    defer listener.deinit();
+
+    cli_error.printSuccess("Serving {s} ({d} constraints) at http://{s}:{d}/", .{ results_path, count, host, port });
+    cli_error.printInfo("Press Ctrl-C to stop", .{});
+
+    var server = Server{
+        .allocator = allocator,
+        .results_path = results_path,
+        .catalog = &catalog,
+    };
+    while (true) {
+        const connection = listener.accept() catch |err| {
+            cli_error.printWarning("Accept failed: {s}", .{@errorName(err)});
+            continue;
+        };
+        setReadTimeout(connection.stream.handle) catch {};
+        const thread = std.Thread.spawn(.{}, serveConnection, .{ &server, connection }) catch {
+            serveConnection(&server, connection);
+            continue;
+        };
+        thread.detach();
+    }
+}
+
+test "route parses search terms and deep links" {
+    const testing = std.testing;
+
+    var root = "/".*;
+    try testing.expectEqual(@as(?[]const u8, null), route(&root).report);
+
+    var search = "/?sort=file&q=auth+token%2Fjwt".*;
+    try testing.expectEqualStrings("auth token/jwt", route(&search).report.?);
+
+    var link = "/c/12345".*;
+    try testing.expectEqual(@as(constraint.ConstraintID, 12345), route(&link).constraint);
+
+    var raw = "/results.json".*;
+    try testing.expect(route(&raw) == .raw);
+
+    var missing = "/c/not-an-id".*;
+    try testing.expect(route(&missing) == .not_found);
+}
@@ -0,0 +1,448 @@
+// Stats command - Trends across historical extraction results
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const output = @import("cli_output");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const results = @import("cli_results");
+const cyclonedx = @import("cli_cyclonedx");
+const profiling = @import("cli_profiling");
+const extract = @import("cli/commands/extract");
+
+const constraint = ananke.types.constraint;
+
+pub const usage =
+    \\Usage: ananke stats <dir> [options]
+    \\
+    \\Report how constraints changed across a directory of past result files
+    \\(`ananke extract --format json`), ordered by modification time: total count
+    \\per run, constraints added and removed between runs, drift velocity (changes
+    \\per day), and how the category mix shifted from the first run to the last.
+    \\Results written with `extract --timings` also get a per-stage timing
+    \\breakdown, so a slowdown can be traced to discovery, parsing, analysis,
+    \\or dedup.
+    \\
+    \\Arguments:
+    \\  <dir>                   Directory of result files (*.json)
+    \\
+    \\Options:
+    \\  --format <fmt>          Output format: text, json (default: text)
+    \\  --last <n>              Only the n most recent runs (default: all)
+    \\  --width <n>             Width of the chart bars in text output (default: 40)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --help, -h              Show this help message
+    \\
+    \\Examples:
+    \\  ananke stats ci-results/
+    \\  ananke stats ci-results/ --last 30 --format json -o trends.json
+;
+
+const StatsFormat = enum {
+    text,
+    json,
+};
+
+const KindCounts = std.EnumArray(constraint.ConstraintKind, usize);
+
+/// One historical result file
+pub const Run = struct {
+    name: []const u8,
+    /// Unix seconds, from the file's modification time
+    timestamp: i64,
+    total: usize,
+    by_kind: KindCounts,
+    /// Constraints not present in the previous run (0 for the first run)
+    added: usize = 0,
+    /// Constraints of the previous run no longer present
+    removed: usize = 0,
+    /// Stage timings stored in the result file, if any
+    timings: []const profiling.Stage = &.{},
+};
+
+pub const Trends = struct {
+    runs: []const Run,
+
+    /// Added plus removed constraints over the whole history
+    pub fn churn(self: Trends) usize {
+        var total: usize = 0;
+        for (self.runs) |run_info| total += run_info.added + run_info.removed;
+        return total;
+    }
+
+    pub fn spanDays(self: Trends) f64 {
+        if (self.runs.len < 2) return 0;
+        const seconds = self.runs[self.runs.len - 1].timestamp - self.runs[0].timestamp;
+        return @as(f64, @floatFromInt(@max(seconds, 0))) / std.time.s_per_day;
+    }
+
+    /// Constraint changes per day, or null when the history spans less than a day
+    pub fn velocity(self: Trends) ?f64 {
+        const days = self.spanDays();
+        if (days < 1) return null;
+        return @as(f64, @floatFromInt(self.churn())) / days;
+    }
+
+    /// First and latest runs that recorded stage timings
+    pub fn timedRuns(self: Trends) ?struct { first: Run, latest: Run } {
+        var first: ?Run = null;
+        var latest: ?Run = null;
+        for (self.runs) |run_info| {
+            if (run_info.timings.len == 0) continue;
+            if (first == null) first = run_info;
+            latest = run_info;
+        }
+        return .{ .first = first orelse return null, .latest = latest.? };
+    }
+};
+
+fn stageNs(stages: []const profiling.Stage, name: []const u8) ?u64 {
+    for (stages) |stage| {
+        if (std.mem.eql(u8, stage.name, name)) return stage.elapsed_ns;
+    }
+    return null;
+}
+
+fn percent(count: usize, total: usize) f64 {
+    if (total == 0) return 0;
+    return @as(f64, @floatFromInt(count)) * 100.0 / @as(f64, @floatFromInt(total));
+}
+
+/// Identity of a constraint across runs: content id plus file, so the same
+/// finding in two files counts twice
+fn fingerprint(allocator: std.mem.Allocator, c: constraint.Constraint) ![]u8 {
+    return std.fmt.allocPrint(allocator, "{d}\x00{s}", .{ c.id, c.origin_file orelse "" });
+}
+
+/// Fill in totals, category counts, and the change from the previous run.
+/// `previous` holds the previous run's fingerprints and is replaced with this
+/// run's; keys are allocated from `arena`.
+pub fn measureRun(
+    arena: std.mem.Allocator,
+    run_info: *Run,
+    constraints: []const constraint.Constraint,
+    previous: *std.StringHashMap(void),
+    is_first: bool,
+) !void {
+    var current = std.StringHashMap(void).init(previous.allocator);
+    errdefer current.deinit();
+
+    run_info.total = constraints.len;
+    run_info.by_kind = KindCounts.initFill(0);
+    for (constraints) |c| {
+        run_info.by_kind.getPtr(c.kind).* += 1;
+        const key = try fingerprint(arena, c);
+        const entry = try current.getOrPut(key);
+        if (entry.found_existing) continue;
+        if (!is_first and !previous.contains(key)) run_info.added += 1;
+    }
+    if (!is_first) {
+        var it = previous.keyIterator();
+        while (it.next()) |key| {
+            if (!current.contains(key.*)) run_info.removed += 1;
+        }
+    }
+
+    previous.deinit();
+    previous.* = current;
+}
+
+const Entry = struct {
+    name: []const u8,
+    mtime: i128,
+
+    fn lessThan(_: void, a: Entry, b: Entry) bool {
+        if (a.mtime != b.mtime) return a.mtime < b.mtime;
+        return std.mem.lessThan(u8, a.name, b.name);
+    }
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    _ = config;
+
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const dir_path = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <dir>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+
+    const format_str = parsed_args.getFlagOr("format", "text");
+    const format = std.meta.stringToEnum(StatsFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text or json)", .{format_str});
+        return error.InvalidArgument;
+    };
+    const last = try parsed_args.getFlagInt("last", usize);
+    const width = try parsed_args.getFlagInt("width", usize) orelse 40;
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var dir = std.fs.cwd().openDir(dir_path, .{ .iterate = true }) catch |err| {
+        cli_error.printFileError(err, dir_path);
+        return err;
+    };
+    defer dir.close();
+
+    var entries = std.ArrayList(Entry){};
+    defer entries.deinit(allocator);
+    var it = dir.iterate();
+    while (try it.next()) |entry| {
+        if (entry.kind != .file or !std.mem.endsWith(u8, entry.name, ".json")) continue;
+        const stat = try dir.statFile(entry.name);
+        try entries.append(allocator, .{ .name = try arena.dupe(u8, entry.name), .mtime = stat.mtime });
+    }
+    std.mem.sort(Entry, entries.items, {}, Entry.lessThan);
+
+    var selected = entries.items;
+    if (last) |n| {
+        if (n < selected.len) selected = selected[selected.len - n ..];
+    }
+    if (selected.len == 0) {
+        cli_error.printWarning("No result files (*.json) found in {s}", .{dir_path});
+        return;
+    }
+
+    var runs = std.ArrayList(Run){};
+    defer runs.deinit(allocator);
+    var previous = std.StringHashMap(void).init(allocator);
+    defer previous.deinit();
+
+    for (selected) |entry| {
+        const path = try std.fs.path.join(arena, &.{ dir_path, entry.name });
+        var result = results.ResultFile.loadFile(allocator, path) catch |err| {
+            if (err == results.ResultError.InvalidResultFile) {
+                cli_error.printWarning("Skipping {s}: not an ananke JSON result file", .{path});
+                continue;
+            }
+            cli_error.printFileError(err, path);
+            return err;
+        };
+        defer result.deinit();
+
+        var run_info = Run{
+            .name = entry.name,
+            .timestamp = @intCast(@divFloor(entry.mtime, std.time.ns_per_s)),
+            .total = 0,
+            .by_kind = KindCounts.initFill(0),
+            .timings = try copyStages(arena, result.timings),
+        };
+        try measureRun(arena, &run_info, result.constraint_set.constraints.items, &previous, runs.items.len == 0);
+        try runs.append(allocator, run_info);
+    }
+
+    const trends = Trends{ .runs = runs.items };
+    const output_text = switch (format) {
+        .text => try formatText(allocator, trends, width),
+        .json => try formatJson(allocator, trends),
+    };
+    defer allocator.free(output_text);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+}
+
+/// Copy stages out of a result file that is freed before rendering
+fn copyStages(arena: std.mem.Allocator, stages: []const profiling.Stage) ![]const profiling.Stage {
+    const copy = try arena.dupe(profiling.Stage, stages);
+    for (copy) |*stage| stage.name = try arena.dupe(u8, stage.name);
+    return copy;
+}
+
+fn writeDate(writer: anytype, timestamp: i64) !void {
+    var buf: [32]u8 = undefined;
+    var fbs = std.io.fixedBufferStream(&buf);
+    try cyclonedx.writeIso8601(fbs.writer(), timestamp);
+    try writer.writeAll(fbs.getWritten()[0.."YYYY-MM-DD".len]);
+}
+
+/// Render the history as a table with a bar chart of totals, then the
+/// drift velocity and category mix
+pub fn formatText(allocator: std.mem.Allocator, trends: Trends, width: usize) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    const runs = trends.runs;
+    if (runs.len == 0) {
+        try writer.writeAll("No runs\n");
+        return list.toOwnedSlice(allocator);
+    }
+
+    var max_total: usize = 0;
+    var name_width: usize = "Run".len;
+    for (runs) |run_info| {
+        max_total = @max(max_total, run_info.total);
+        name_width = @max(name_width, run_info.name.len);
+    }
+
+    try writer.print("Constraint trends over {d} runs\n\n", .{runs.len});
+    try writer.writeAll("Date        Run");
+    try writer.writeByteNTimes(' ', name_width - "Run".len);
+    try writer.writeAll("    Total   Added Removed\n");
+    for (runs, 0..) |run_info, i| {
+        try writeDate(writer, run_info.timestamp);
+        try writer.print("  {s}", .{run_info.name});
+        try writer.writeByteNTimes(' ', name_width - run_info.name.len);
+        try writer.print("  {d: >7}", .{run_info.total});
+        if (i == 0) {
+            try writer.writeAll("       -       -");
+        } else {
+            try writer.print(" {d: >7} {d: >7}", .{ run_info.added, run_info.removed });
+        }
+        const bar = if (max_total == 0) 0 else (run_info.total * width + max_total - 1) / max_total;
+        try writer.writeAll("  ");
+        for (0..bar) |_| try writer.writeAll("█");
+        try writer.writeAll("\n");
+    }
+
+    try writer.writeAll("\nDrift velocity: ");
+    if (trends.velocity()) |v| {
+        try writer.print("{d:.1} changes/day ({d} changes over {d:.1} days)\n", .{ v, trends.churn(), trends.spanDays() });
+    } else {
+        try writer.print("n/a ({d} changes; history spans less than a day)\n", .{trends.churn()});
+    }
+
+    const first = runs[0];
+    const latest = runs[runs.len - 1];
+    try writer.writeAll("\nCategory mix      First    Latest    Change\n");
+    for (std.enums.values(constraint.ConstraintKind)) |kind| {
+        const before = percent(first.by_kind.get(kind), first.total);
+        const after = percent(latest.by_kind.get(kind), latest.total);
+        try writer.print("  {s: <14} {d: >6.1}%  {d: >7.1}%  {s}{d:.1} pts\n", .{
+            @tagName(kind),
+            before,
+            after,
+            if (after >= before) "+" else "-",
+            @abs(after - before),
+        });
+    }
+
+    if (trends.timedRuns()) |timed| {
+        try writer.writeAll("\nStage timings      First    Latest    Change\n");
+        for (timed.latest.timings) |stage| {
+            try writer.print("  {s:<12}", .{stage.name});
+            try writeColumn(writer, stageNs(timed.first.timings, stage.name));
+            try writeColumn(writer, stage.elapsed_ns);
+            if (stageNs(timed.first.timings, stage.name)) |first_ns| {
+                if (first_ns > 0) {
+                    const change = (@as(f64, @floatFromInt(stage.elapsed_ns)) - @as(f64, @floatFromInt(first_ns))) * 100 / @as(f64, @floatFromInt(first_ns));
+                    try writer.print("  {s}{d:.1}%", .{ if (change >= 0) "+" else "-", @abs(change) });
+                }
+            }
+            try writer.writeAll("\n");
+        }
+    }
+
+    return list.toOwnedSlice(allocator);
+}
+
+/// Right-align a duration (or "-") in a 10-column field
+fn writeColumn(writer: anytype, ns: ?u64) !void {
+    var buf: [32]u8 = undefined;
+    var fbs = std.io.fixedBufferStream(&buf);
+    if (ns) |value| try profiling.writeDuration(fbs.writer(), value) else try fbs.writer().writeAll("-");
+    const text = fbs.getWritten();
+    try writer.writeByteNTimes(' ', 10 -| text.len);
+    try writer.writeAll(text);
+}
+
+pub fn formatJson(allocator: std.mem.Allocator, trends: Trends) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("{\n  \"runs\": [\n");
+    for (trends.runs, 0..) |run_info, i| {
+        try writer.writeAll("    {\"name\": \"");
+        try output.writeJsonEscaped(writer, run_info.name);
+        try writer.writeAll("\", \"timestamp\": \"");
+        try cyclonedx.writeIso8601(writer, run_info.timestamp);
+        try writer.print("\", \"total\": {d}, \"added\": {d}, \"removed\": {d}, \"by_category\": {{", .{
+            run_info.total,
+            run_info.added,
+            run_info.removed,
+        });
+        for (std.enums.values(constraint.ConstraintKind), 0..) |kind, k| {
+            if (k > 0) try writer.writeAll(", ");
+            try writer.print("\"{s}\": {d}", .{ @tagName(kind), run_info.by_kind.get(kind) });
+        }
+        try writer.writeAll("}");
+        if (run_info.timings.len > 0) {
+            try writer.writeAll(", \"timings\": ");
+            try profiling.writeStagesJson(writer, run_info.timings);
+        }
+        try writer.writeAll("}");
+        if (i + 1 < trends.runs.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("  ],\n");
+    try writer.print("  \"churn\": {d},\n", .{trends.churn()});
+    try writer.print("  \"span_days\": {d:.2},\n", .{trends.spanDays()});
+    if (trends.velocity()) |v| {
+        try writer.print("  \"velocity_per_day\": {d:.2}\n", .{v});
+    } else {
+        try writer.writeAll("  \"velocity_per_day\": null\n");
+    }
+    try writer.writeAll("}\n");
+
+    return list.toOwnedSlice(allocator);
+}
+
+test "stats track added and removed constraints between runs" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const monday = [_]constraint.Constraint{
+        .{ .id = 1, .name = "a", .description = "", .kind = .security, .severity = .err, .origin_file = "auth.go" },
+        .{ .id = 2, .name = "b", .description = "", .kind = .semantic, .severity = .info, .origin_file = "auth.go" },
+    };
+    const thursday = [_]constraint.Constraint{
+        .{ .id = 1, .name = "a", .description = "", .kind = .security, .severity = .err, .origin_file = "auth.go" },
+        .{ .id = 3, .name = "c", .description = "", .kind = .security, .severity = .err, .origin_file = "api.go" },
+        .{ .id = 1, .name = "a", .description = "", .kind = .security, .severity = .err, .origin_file = "api.go" },
+    };
+
+    var previous = std.StringHashMap(void).init(allocator);
+    defer previous.deinit();
+    var runs = [_]Run{
+        .{ .name = "mon.json", .timestamp = 0, .total = 0, .by_kind = KindCounts.initFill(0) },
+        .{ .name = "thu.json", .timestamp = 3 * std.time.s_per_day, .total = 0, .by_kind = KindCounts.initFill(0) },
+    };
+    try measureRun(arena, &runs[0], &monday, &previous, true);
+    try measureRun(arena, &runs[1], &thursday, &previous, false);
+
+    try testing.expectEqual(@as(usize, 2), runs[1].added);
+    try testing.expectEqual(@as(usize, 1), runs[1].removed);
+    try testing.expectEqual(@as(usize, 3), runs[1].by_kind.get(.security));
+
+    const trends = Trends{ .runs = &runs };
+    try testing.expectEqual(@as(f64, 1.0), trends.velocity().?);
+
+    const text = try formatText(allocator, trends, 10);
+    defer allocator.free(text);
+    try testing.expect(std.mem.indexOf(u8, text, "1.0 changes/day (3 changes over 3.0 days)") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "security         50.0%    100.0%  +50.0 pts") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "Stage timings") == null);
+
+    const first_stages = [_]profiling.Stage{
+        .{ .name = "analyze", .elapsed_ns = 10 * std.time.ns_per_ms, .peak_bytes = 0, .allocations = 0 },
+    };
+    const latest_stages = [_]profiling.Stage{
+        .{ .name = "discovery", .elapsed_ns = 2 * std.time.ns_per_ms, .peak_bytes = 0, .allocations = 0 },
+        .{ .name = "analyze", .elapsed_ns = 15 * std.time.ns_per_ms, .peak_bytes = 0, .allocations = 0 },
+    };
+    runs[0].timings = &first_stages;
+    runs[1].timings = &latest_stages;
+    const timed = try formatText(allocator, trends, 10);
+    defer allocator.free(timed);
+    try testing.expect(std.mem.indexOf(u8, timed, "  discovery            -    2.0 ms\n") != null);
+    try testing.expect(std.mem.indexOf(u8, timed, "  analyze        10.0 ms   15.0 ms  +50.0%") != null);
+}
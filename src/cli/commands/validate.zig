@@ -7,6 +7,13 @@ const config_mod = @import("cli_config");
 const cli_error = @import("cli_error");
 const error_help = @import("cli_error_help");
 const path_validator = @import("path_validator");
+const webhook = @import("cli_webhook");
+const version = @import("cli_version");
+const issues = @import("cli_issues");
+const github = @import("cli_github");
+const checks = @import("cli_checks");
+const git = @import("cli_git");
+const quickfix = @import("cli_quickfix");
 
 pub const usage =
     \\Usage: ananke validate <code-file> [options]
@@ -20,17 +27,32 @@ pub const usage =
     \\  --constraints, -c <file> Validate against constraints from file
     \\  --strict                Treat warnings as errors
     \\  --report <file>         Write validation report to file
+    \\  --fixes <file>          Write quick fixes for mechanical Go violations (unclosed
+    \\                          resources, functions making their own context) as JSON
+    \\                          text edits for editors and bots to apply
+    \\  --webhook <url>         POST the outcome to <url> when validation completes, in
+    \\                          addition to the [webhooks] urls of .ananke.toml
+    \\  --issues <tracker>      File or update a github or jira issue per error-severity
+    \\                          violation, deduplicated by constraint ID (default:
+    \\                          [issues] tracker of .ananke.toml)
+    \\  --github-checks         Publish violations as a GitHub check run, annotated inline
+    \\                          on pull request diffs (needs GITHUB_TOKEN with checks
+    \\                          write access and GITHUB_REPOSITORY)
+    \\  --checks-payload <file> Write the check run requests as JSON Lines instead of
+    \\                          posting them (first creates the run, the rest append)
+    \\  --checks-sha <sha>      Commit the check run belongs to (default: GITHUB_SHA or HEAD)
     \\  --verbose, -v           Verbose output
     \\  --help, -h              Show this help message
     \\
     \\Examples:
     \\  ananke validate src/auth.ts -c constraints.json
     \\  ananke validate lib.rs --strict --report validation.txt
+    \\  ananke validate src/store.go --fixes fixes.json
+    \\  GITHUB_TOKEN=... ananke validate src/db.go -c constraints.json --issues github
+    \\  ananke validate src/db.go -c constraints.json --github-checks --checks-sha "$PR_HEAD_SHA"
 ;
 
 pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
-    _ = config;
-
     if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
         std.debug.print("{s}\n", .{usage});
         return;
@@ -168,13 +190,19 @@ pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: con
 
     var violations_found: usize = 0;
     var warnings_found: usize = 0;
+    var critical = std.ArrayList(issues.Violation){};
+    defer critical.deinit(allocator);
+    var found = std.ArrayList(issues.Violation){};
+    defer found.deinit(allocator);
 
     for (cs.constraints.items) |constraint| {
         const validated = validateConstraint(source, constraint);
 
         if (!validated) {
+            try found.append(allocator, .{ .constraint = constraint, .file = file_path });
             if (constraint.severity == .err) {
                 violations_found += 1;
+                try critical.append(allocator, .{ .constraint = constraint, .file = file_path });
                 std.debug.print("  ✗ ERROR: {s}\n", .{constraint.name});
             } else if (constraint.severity == .warning) {
                 warnings_found += 1;
@@ -187,6 +215,39 @@ pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: con
         }
     }
 
+    // Violations with a mechanical fix, checked whatever the constraints say
+    const fixes = if (std.mem.eql(u8, detectLanguage(file_path), "go"))
+        try quickfix.detect(arena_allocator, source)
+    else
+        &[_]quickfix.Fix{};
+    for (fixes) |fix| {
+        warnings_found += 1;
+        try found.append(allocator, .{
+            .constraint = .{
+                .name = @tagName(fix.rule),
+                .description = fix.message,
+                .kind = .operational,
+                .severity = .warning,
+                .origin_file = file_path,
+                .origin_line = fix.line,
+            },
+            .file = file_path,
+        });
+        std.debug.print("  ⚠ WARNING: {s} (line {d})\n", .{ @tagName(fix.rule), fix.line });
+        std.debug.print("    Description: {s}\n", .{fix.message});
+        std.debug.print("    Quick fix: {s}{s}\n\n", .{ fix.title, if (fix.safe) "" else " (update callers too)" });
+    }
+
+    if (parsed_args.getFlag("fixes")) |path| {
+        const text = try quickfix.formatFixes(allocator, file_path, fixes);
+        defer allocator.free(text);
+        std.fs.cwd().writeFile(.{ .sub_path = path, .data = text }) catch |err| {
+            cli_error.printFileError(err, path);
+            return err;
+        };
+        cli_error.printSuccess("{d} quick fixes written to {s}", .{ fixes.len, path });
+    }
+
     // Write report if requested
     if (report_file) |path| {
         const report = try generateReport(allocator, violations_found, warnings_found, cs);
@@ -204,12 +265,177 @@ pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: con
     // Summary
     cli_error.printValidationSummary(violations_found, warnings_found);
 
+    const failed = violations_found > 0 or (strict and warnings_found > 0);
+    const hooks = webhook.Hooks.fromConfig(config, parsed_args.getFlag("webhook"));
+    if (hooks.wants(.validate)) {
+        const summary_json = try std.fmt.allocPrint(allocator, "{{\"constraints\": {d}, \"violations\": {d}, \"warnings\": {d}, \"strict\": {s}}}", .{
+            cs.constraints.items.len,
+            violations_found,
+            warnings_found,
+            if (strict) "true" else "false",
+        });
+        defer allocator.free(summary_json);
+        const payload = try webhook.formatPayload(allocator, .validate, version.VERSION, std.time.timestamp(), file_path, if (failed) "failed" else "passed", summary_json);
+        defer allocator.free(payload);
+        webhook.notify(allocator, hooks, .validate, payload, verbose);
+    }
+
+    if (parsed_args.getFlag("issues") orelse config.issues_tracker) |tracker| {
+        try fileIssues(allocator, config, tracker, critical.items, verbose);
+    }
+
+    const checks_payload = parsed_args.getFlag("checks-payload");
+    if (parsed_args.hasFlag("github-checks") or checks_payload != null) {
+        const run_output = checks.Run{
+            // Resolved by reportChecks
+            .head_sha = "",
+            .conclusion = if (failed) .failure else if (warnings_found > 0) .neutral else .success,
+            .title = try std.fmt.allocPrint(arena_allocator, "{d} violations, {d} warnings", .{ violations_found, warnings_found }),
+            .summary = try std.fmt.allocPrint(arena_allocator, "Validated `{s}` against {d} constraints: {d} did not hold.", .{
+                file_path,
+                cs.constraints.items.len,
+                found.items.len,
+            }),
+        };
+        try reportChecks(allocator, parsed_args, config, run_output, found.items, checks_payload, verbose);
+    }
+
     // Exit with error if validation failed
-    if (violations_found > 0 or (strict and warnings_found > 0)) {
+    if (failed) {
         return error.ValidationFailed;
     }
 }
 
+/// Export critical violations to the issue tracker named by --issues or
+/// `[issues] tracker`, with credentials from the environment
+fn fileIssues(allocator: std.mem.Allocator, config: config_mod.Config, tracker_name: []const u8, violations: []const issues.Violation, verbose: bool) !void {
+    const tracker = issues.Tracker.fromString(tracker_name) orelse {
+        cli_error.printError("Unknown issue tracker: {s} (expected github or jira)", .{tracker_name});
+        return error.InvalidArgument;
+    };
+    if (violations.len == 0) {
+        if (verbose) cli_error.printInfo("No critical violations to file", .{});
+        return;
+    }
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var target = issues.Target{ .tracker = tracker, .api_url = undefined, .authorization = undefined, .labels = config.issues_labels };
+    switch (tracker) {
+        .github => {
+            target.repository = config.issues_repository orelse try getEnv(arena, "GITHUB_REPOSITORY") orelse {
+                cli_error.printError("--issues github needs the repository: set [issues] repository or GITHUB_REPOSITORY", .{});
+                return error.MissingArgument;
+            };
+            const token = try getEnv(arena, "GITHUB_TOKEN") orelse {
+                cli_error.printError("--issues github needs a token with issues write access in GITHUB_TOKEN", .{});
+                return error.MissingArgument;
+            };
+            target.api_url = try getEnv(arena, "GITHUB_API_URL") orelse github.default_api_url;
+            target.authorization = try std.fmt.allocPrint(arena, "Bearer {s}", .{token});
+        },
+        .jira => {
+            target.api_url = config.issues_jira_url orelse try getEnv(arena, "JIRA_URL") orelse {
+                cli_error.printError("--issues jira needs the site: set [issues] jira_url or JIRA_URL", .{});
+                return error.MissingArgument;
+            };
+            target.project = config.issues_jira_project orelse try getEnv(arena, "JIRA_PROJECT") orelse {
+                cli_error.printError("--issues jira needs a project key: set [issues] jira_project or JIRA_PROJECT", .{});
+                return error.MissingArgument;
+            };
+            if (config.issues_jira_issue_type) |issue_type| target.issue_type = issue_type;
+            const email = try getEnv(arena, "JIRA_EMAIL");
+            const token = try getEnv(arena, "JIRA_API_TOKEN");
+            if (email == null or token == null) {
+                cli_error.printError("--issues jira needs JIRA_EMAIL and JIRA_API_TOKEN", .{});
+                return error.MissingArgument;
+            }
+            target.authorization = try issues.jiraBasicAuth(arena, email.?, token.?);
+        },
+    }
+
+    const outcome = issues.exportViolations(allocator, target, violations, {}, reportIssue) catch |err| {
+        cli_error.printError("Failed to look up existing {s} issues at {s}: {s}", .{ tracker_name, target.api_url, @errorName(err) });
+        return err;
+    };
+    cli_error.printInfo("Issues: {d} created, {d} updated, {d} failed", .{ outcome.created, outcome.updated, outcome.failed });
+}
+
+/// Publish violations as a GitHub check run, or with `payload_path` write the
+/// requests that would publish it
+fn reportChecks(
+    allocator: std.mem.Allocator,
+    parsed_args: args_mod.Args,
+    config: config_mod.Config,
+    run_output: checks.Run,
+    violations: []const issues.Violation,
+    payload_path: ?[]const u8,
+    verbose: bool,
+) !void {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var run = run_output;
+    run.head_sha = parsed_args.getFlag("checks-sha") orelse try getEnv(arena, "GITHUB_SHA") orelse blk: {
+        break :blk git.resolveCommit(arena, "HEAD") catch {
+            cli_error.printError("Cannot tell which commit to annotate: pass --checks-sha or set GITHUB_SHA", .{});
+            return error.MissingArgument;
+        };
+    };
+
+    if (payload_path) |path| {
+        const bodies = try checks.formatRequests(arena, run, violations);
+        var text = std.ArrayList(u8){};
+        for (bodies) |body| try text.appendSlice(arena, body);
+        std.fs.cwd().writeFile(.{ .sub_path = path, .data = text.items }) catch |err| {
+            cli_error.printFileError(err, path);
+            return err;
+        };
+        cli_error.printSuccess("Check run requests written to {s} ({d} annotations in {d} requests)", .{ path, violations.len, bodies.len });
+        return;
+    }
+
+    const repository = config.issues_repository orelse try getEnv(arena, "GITHUB_REPOSITORY") orelse {
+        cli_error.printError("--github-checks needs the repository: set [issues] repository or GITHUB_REPOSITORY", .{});
+        return error.MissingArgument;
+    };
+    const token = try getEnv(arena, "GITHUB_TOKEN") orelse {
+        cli_error.printError("--github-checks needs a token with checks write access in GITHUB_TOKEN", .{});
+        return error.MissingArgument;
+    };
+    const api_url = try getEnv(arena, "GITHUB_API_URL") orelse github.default_api_url;
+
+    const published = checks.publish(allocator, api_url, repository, token, run, violations) catch |err| {
+        cli_error.printError("Failed to publish the check run to {s}: {s}", .{ repository, @errorName(err) });
+        if (err == checks.PublishError.CreateFailed) {
+            cli_error.printInfo("GITHUB_TOKEN needs checks: write; the default Actions token has it when the workflow grants it", .{});
+        }
+        return err;
+    };
+    if (verbose) {
+        cli_error.printInfo("Sent {d} annotations in {d} requests", .{ violations.len, published.requests });
+    }
+    cli_error.printSuccess("Published check run {d} on {s}", .{ published.id, run.head_sha });
+}
+
+fn reportIssue(_: void, violation: issues.Violation, reference: ?[]const u8, created: bool) void {
+    const issue = reference orelse {
+        cli_error.printWarning("Could not file an issue for {s}", .{violation.constraint.name});
+        return;
+    };
+    cli_error.printInfo("{s} {s} for {s}", .{ if (created) "Filed" else "Updated", issue, violation.constraint.name });
+}
+
+fn getEnv(arena: std.mem.Allocator, name: []const u8) !?[]const u8 {
+    return std.process.getEnvVarOwned(arena, name) catch |err| switch (err) {
+        error.EnvironmentVariableNotFound => null,
+        else => return err,
+    };
+}
+
 fn detectLanguage(file_path: []const u8) []const u8 {
     if (std.mem.endsWith(u8, file_path, ".ts") or std.mem.endsWith(u8, file_path, ".tsx")) return "typescript";
     if (std.mem.endsWith(u8, file_path, ".js") or std.mem.endsWith(u8, file_path, ".jsx")) return "javascript";
@@ -0,0 +1,274 @@
+// Verify command - Check the code against a stored constraint set
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const output = @import("cli_output");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const results = @import("cli_results");
+const constraint_verify = @import("cli_constraint_verify");
+const approvals_mod = @import("cli_approvals");
+const layering = @import("cli_layering");
+const extract = @import("cli/commands/extract");
+const extract_cache = @import("cli_extract_cache");
+const extract_pipeline = @import("cli_extract_pipeline");
+const architecture = @import("cli/commands/architecture");
+
+pub const usage =
+    \\Usage: ananke verify <constraints> [path] [options]
+    \\
+    \\Re-extract the files a stored constraint set was extracted from (a JSON
+    \\result of `ananke extract --format json`, usually committed) and check that
+    \\its constraints still hold. Constraints are matched by file, kind, and name,
+    \\so code that only moved lines still verifies. A stored constraint that does
+    \\not hold is reported as:
+    \\
+    \\  violated              its file is still there, but the error-severity
+    \\                        constraint is no longer found
+    \\  weakened              found with lower severity, priority, or confidence
+    \\  no longer evidenced   its file was removed, or the warning, info, or hint
+    \\                        constraint is no longer found
+    \\
+    \\With review approvals (see `ananke review`), stored constraints a reviewer
+    \\rejected are not verified, and a new error-severity constraint without a
+    \\review decision is reported as unreviewed and fails verification; new
+    \\constraints that were accepted or reviewed only count as new.
+    \\
+    \\When the config has architecture rules (see `ananke architecture`), the Go
+    \\module in the working directory is checked against them too, and every
+    \\violation fails verification with the imports or type uses causing it.
+    \\
+    \\Arguments:
+    \\  <constraints>           Stored JSON result file
+    \\  [path]                  Only verify constraints of files under this path
+    \\
+    \\Options:
+    \\  --strict                Also fail on weakened and no longer evidenced constraints
+    \\  --approvals <file>      Review approvals (default: .ananke-approvals.json, if present)
+    \\  --no-approvals          Ignore review approvals
+    \\  --no-architecture       Skip the configured architecture rules
+    \\  --format <fmt>          Output format: text, json (default: text)
+    \\  --output, -o <file>     Write output to file instead of stdout
+    \\  --confidence <min>      Threshold for counting new constraints (default: 0.5)
+    \\  --use-claude            Enable Claude API for semantic analysis
+    \\  --jobs, -j <n>          Extraction workers (default: number of CPUs)
+    \\  --no-cache              Re-extract every file instead of reusing cached results
+    \\  --verbose, -v           Verbose output
+    \\  --help, -h              Show this help message
+    \\
+    \\Exit codes:
+    \\  0   every stored constraint holds, no new critical constraint is
+    \\      unreviewed, and no architecture rule is violated (other findings
+    \\      are reported but pass unless --strict)
+    \\  5   verification failed
+    \\  1   invalid arguments; 3 when the constraints file is missing
+    \\
+    \\Examples:
+    \\  ananke verify constraints.json
+    \\  ananke verify constraints.json src/api --strict --format json -o verify.json
+;
+
+const VerifyFormat = enum {
+    text,
+    json,
+};
+
+pub fn run(allocator: std.mem.Allocator, parsed_args: args_mod.Args, config: config_mod.Config) !void {
+    if (parsed_args.hasFlag("help") or parsed_args.hasFlag("h")) {
+        std.debug.print("{s}\n", .{usage});
+        return;
+    }
+
+    const constraints_path = parsed_args.getPositional(0) catch {
+        cli_error.printError("Missing required argument: <constraints>", .{});
+        std.debug.print("\n{s}\n", .{usage});
+        return error.MissingArgument;
+    };
+    const scope: ?[]const u8 = parsed_args.getPositional(1) catch null;
+    const strict = parsed_args.hasFlag("strict");
+
+    const format_str = parsed_args.getFlagOr("format", "text");
+    const format = std.meta.stringToEnum(VerifyFormat, format_str) orelse {
+        cli_error.printError("Invalid format: {s} (expected text or json)", .{format_str});
+        return error.InvalidArgument;
+    };
+    const confidence_threshold = try parsed_args.getFlagFloat("confidence", f32) orelse config.confidence_threshold;
+    if (confidence_threshold < 0.0 or confidence_threshold > 1.0) {
+        cli_error.printError("Confidence threshold must be between 0.0 and 1.0", .{});
+        return error.InvalidArgument;
+    }
+
+    const use_claude = parsed_args.hasFlag("use-claude") or config.use_claude;
+    const options = extract.Options{
+        .confidence_threshold = confidence_threshold,
+        .use_claude = use_claude,
+        .verbose = parsed_args.hasFlag("verbose") or parsed_args.hasFlag("v"),
+        .concurrency = try extract_pipeline.parseConcurrency(parsed_args, config, use_claude),
+        .cache_dir = extract_cache.parseCacheDir(parsed_args, config, use_claude),
+        .remote_cache = try extract_cache.parseRemoteCache(parsed_args, config, use_claude),
+        .remote_cache_mode = try extract_cache.parseRemoteCacheMode(parsed_args, config),
+        .cache_max_bytes = try extract_cache.parseCacheMaxSize(parsed_args, config),
+    };
+
+    var stored_file = results.ResultFile.loadFile(allocator, constraints_path) catch |err| {
+        if (err == results.ResultError.InvalidResultFile) {
+            cli_error.printError("Not an ananke JSON result file: {s}", .{constraints_path});
+            return error.InvalidArgument;
+        }
+        cli_error.printFileError(err, constraints_path);
+        return err;
+    };
+    defer stored_file.deinit();
+
+    var approvals = try loadApprovals(allocator, parsed_args);
+    defer if (approvals) |*a| a.deinit();
+
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    // Paths are compared without a leading "./", which depends on how the
+    // stored set was extracted
+    var stored = std.ArrayList(ananke.Constraint){};
+    var unanchored: usize = 0;
+    for (stored_file.constraint_set.constraints.items) |c| {
+        const file = trimDot(c.origin_file orelse {
+            unanchored += 1;
+            continue;
+        });
+        if (scope) |path| {
+            if (!inScope(file, trimDot(path))) continue;
+        }
+        var copy = c;
+        copy.origin_file = file;
+        try stored.append(arena, copy);
+    }
+    if (unanchored > 0) {
+        cli_error.printWarning("Skipping {d} stored constraints that record no file", .{unanchored});
+    }
+    if (stored.items.len == 0) {
+        cli_error.printWarning("No stored constraints to verify{s}{s}", .{ if (scope != null) " under " else "", scope orelse "" });
+        return;
+    }
+
+    // Every file the stored constraints were extracted from, once
+    var seen = std.StringHashMap(void).init(arena);
+    var sources = std.ArrayList(discovery.SourceFile){};
+    var removed = std.ArrayList([]const u8){};
+    for (stored.items) |c| {
+        const file = c.origin_file.?;
+        if ((try seen.getOrPut(file)).found_existing) continue;
+        const source = std.fs.cwd().readFileAlloc(arena, file, extract.max_source_bytes) catch |err| switch (err) {
+            error.FileNotFound => {
+                try removed.append(arena, file);
+                continue;
+            },
+            else => {
+                cli_error.printFileError(err, file);
+                return err;
+            },
+        };
+        try sources.append(arena, .{ .path = file, .language = discovery.detectLanguage(file), .source = source });
+    }
+    if (options.verbose) {
+        cli_error.printInfo("Re-extracting {d} files ({d} removed since the constraints were stored)", .{ sources.items.len, removed.items.len });
+    }
+
+    var claude_client: ?ananke.api.claude.ClaudeClient = null;
+    defer if (claude_client) |*client| client.deinit();
+    var engine = try extract.initEngine(allocator, config, options, &claude_client);
+    defer engine.deinit();
+
+    var result = extract.Result.init(allocator);
+    defer result.deinit();
+    var cache = extract.openCache(allocator, options);
+    defer if (cache) |*c| c.close();
+    if (cache) |*c| result.cache = c;
+
+    var spinner = output.Spinner.init("Extracting constraints...");
+    try result.addAll(&engine, sources.items, options.concurrency.analyze);
+    spinner.finish("Extraction complete");
+    if (cache) |*c| extract_cache.finish(c, options.verbose);
+
+    // Not filtered by confidence: a constraint that dropped below the
+    // threshold is weakened, not gone
+    const current = try arena.alloc(ananke.Constraint, result.constraint_set.constraints.items.len);
+    for (result.constraint_set.constraints.items, current) |c, *copy| {
+        copy.* = c;
+        if (c.origin_file) |file| copy.origin_file = trimDot(file);
+    }
+
+    var report = try constraint_verify.verify(arena, stored.items, current, removed.items, confidence_threshold, if (approvals) |*a| a else null);
+    if (config.architecture_rules.len > 0 and !parsed_args.hasFlag("no-architecture")) {
+        var excludes = try extract.collectExcludes(allocator, parsed_args, config);
+        defer excludes.deinit(allocator);
+        const rules = try architecture.parseRules(arena, config.architecture_rules);
+        const layers = try architecture.configuredLayers(arena, config);
+        if (try architecture.loadGraph(arena, ".", excludes.items)) |graph| {
+            report.architecture = try layering.check(arena, &graph, layers, rules);
+            report.architecture_rules = rules.len;
+        } else {
+            cli_error.printWarning("Skipping architecture rules: no go.mod with a module path in the working directory", .{});
+        }
+    }
+    const output_text = switch (format) {
+        .text => try constraint_verify.formatText(allocator, report, constraints_path),
+        .json => try constraint_verify.formatJson(allocator, report, constraints_path, strict),
+    };
+    defer allocator.free(output_text);
+    try extract.writeOutput(parsed_args.getFlag("output") orelse parsed_args.getFlag("o"), output_text);
+
+    if (report.failed(strict)) {
+        if (report.architecture.len > 0) {
+            cli_error.printError("{d} architecture rule violations", .{report.architecture.len});
+        }
+        const unreviewed = report.count(.unreviewed);
+        if (report.findings.len > unreviewed) {
+            cli_error.printError("{d} of {d} stored constraints no longer hold", .{ report.findings.len - unreviewed, report.stored });
+        }
+        if (unreviewed > 0) {
+            cli_error.printError("{d} new critical constraints have no review decision", .{unreviewed});
+            cli_error.printInfo("Record one with ananke review accept|reject|reviewed", .{});
+        }
+        const failing = report.count(.violated) + unreviewed;
+        if (!strict and report.findings.len > failing) {
+            cli_error.printInfo("{d} fail verification; the rest are reported without failing unless --strict", .{failing});
+        }
+        return error.ValidationFailed;
+    }
+    if (report.findings.len > 0) {
+        cli_error.printWarning("{d} stored constraints are weakened or no longer evidenced; --strict fails on them", .{report.findings.len});
+    }
+    cli_error.printSuccess("No stored constraint is violated ({d} verified)", .{report.stored});
+}
+
+/// Approvals from --approvals, or the default file when it exists
+fn loadApprovals(allocator: std.mem.Allocator, parsed_args: args_mod.Args) !?approvals_mod.Approvals {
+    if (parsed_args.hasFlag("no-approvals")) return null;
+    const explicit = parsed_args.getFlag("approvals");
+    const path = explicit orelse approvals_mod.default_path;
+    return approvals_mod.Approvals.loadFile(allocator, path) catch |err| {
+        if (err == error.FileNotFound and explicit == null) return null;
+        if (err == approvals_mod.ApprovalsError.UnsupportedVersion) {
+            cli_error.printError("{s}: unsupported approvals version (expected {d})", .{ path, approvals_mod.format_version });
+            return error.InvalidArgument;
+        }
+        cli_error.printFileError(err, path);
+        return err;
+    };
+}
+
+fn trimDot(path: []const u8) []const u8 {
+    var rel = path;
+    while (std.mem.startsWith(u8, rel, "./")) rel = rel[2..];
+    return rel;
+}
+
+/// Whether `file` is `scope` or lies under it
+fn inScope(file: []const u8, scope: []const u8) bool {
+    const dir = std.mem.trimRight(u8, scope, "/");
+    if (dir.len == 0 or std.mem.eql(u8, dir, ".")) return true;
+    if (!std.mem.startsWith(u8, file, dir)) return false;
+    return file.len == dir.len or file[dir.len] == '/';
+}
@@ -27,10 +27,104 @@ pub const Config = struct {
     confidence_threshold: f32 = 0.5,
     output_format: []const u8 = "pretty",
     output_format_owned: bool = false, // Track if output_format was allocated
+    redact: bool = false, // Mask string literals and snippets in output
+
+    // Report settings
+    report_messages: ?[]const u8 = null, // Message catalog for Markdown/HTML reports
 
     // Extract settings
     extract_patterns: []const []const u8 = &.{"all"},
     use_claude: bool = false,
+    extract_excludes: []const []const u8 = &.{}, // Extra exclude globs for directory extraction
+    extract_excludes_owned: bool = false,
+    extract_gitignore: bool = true, // Honor .gitignore files when walking directories
+    extract_baseline: ?[]const u8 = null, // Baseline of accepted constraints to suppress
+    extract_kinds: []const []const u8 = &.{}, // Constraint kinds to extract (empty = all)
+    extract_kinds_owned: bool = false,
+    extract_generated: ?[]const u8 = null, // Generated files: full, reduced (default), or skip
+    extract_max_file_bytes: usize = 0, // Larger files get partial extraction (0 = no limit)
+    extract_max_file_lines: usize = 100_000, // Longer files get partial extraction (0 = no limit)
+    extract_oversized: ?[]const u8 = null, // Files over a limit: outline (default) or skip
+    extract_collapse_similar: f32 = 0.0, // Merge near-duplicate constraints at this similarity (0 = off)
+
+    // Performance settings (0 = derive from the number of CPUs)
+    jobs: usize = 0, // Default worker count for every stage
+    parse_jobs: usize = 0, // Concurrent file reads
+    analyze_jobs: usize = 0, // Concurrent extraction workers
+    render_jobs: usize = 0, // Concurrent per-file output rendering
+    io_rate: ?[]const u8 = null, // Combined source read rate, e.g. "50M" per second (default: unlimited)
+    language_pipelines: bool = false, // Extract each language on its own worker pool
+    pipeline_memory: ?[]const u8 = null, // Unmerged source bytes per language pipeline (default: "256M")
+
+    // Cache settings
+    cache_enabled: bool = true, // Reuse constraints of unchanged files across runs
+    cache_dir: ?[]const u8 = null, // Cache directory (default: .ananke-cache)
+    cache_remote: ?[]const u8 = null, // Shared remote cache URL (http(s)://, s3://, gs://)
+    cache_remote_mode: ?[]const u8 = null, // read (default) or read-write
+    cache_max_size: ?[]const u8 = null, // Evict least recently used entries beyond this size (e.g. "2G"; "0" = no limit)
+
+    // Webhook settings
+    webhook_urls: []const []const u8 = &.{}, // Posted to when a run completes
+    webhook_urls_owned: bool = false,
+    webhook_events: []const []const u8 = &.{}, // Events to post: extract, validate (empty = all)
+    webhook_events_owned: bool = false,
+    webhook_secret: OptionalSecureString = .{ .inner = null }, // Signs payloads with HMAC-SHA256
+
+    // Drift notification settings
+    notify_slack: []const []const u8 = &.{}, // Slack incoming webhook URLs
+    notify_slack_owned: bool = false,
+    notify_teams: []const []const u8 = &.{}, // Microsoft Teams incoming webhook URLs
+    notify_teams_owned: bool = false,
+    notify_webhooks: []const []const u8 = &.{}, // Plain webhook URLs (drift.detected payloads)
+    notify_webhooks_owned: bool = false,
+    notify_drift_threshold: usize = 0, // Notify when new plus stale constraints exceed this
+    notify_branches: []const []const u8 = &.{}, // Watched branches, "release/*" style (empty = all)
+    notify_branches_owned: bool = false,
+
+    // Enforcement settings
+    enforce_budgets: []const []const u8 = &.{}, // Change budgets for `ananke enforce`, "removed:security:error=0" style
+    enforce_budgets_owned: bool = false,
+    enforce_coverage: []const []const u8 = &.{}, // Constraint test-coverage thresholds for `ananke coverage`, "api=80" style
+    enforce_coverage_owned: bool = false,
+
+    // Security policy settings
+    policy_packs: []const []const u8 = &.{}, // Policy packs checked by `ananke policy-check`, e.g. "web-service-baseline"
+    policy_packs_owned: bool = false,
+
+    // Architecture rule settings
+    architecture_layers: []const []const u8 = &.{}, // Layers by path, "handler=internal/api,internal/http" style (empty = by directory name)
+    architecture_layers_owned: bool = false,
+    architecture_rules: []const []const u8 = &.{}, // Rules `ananke verify` enforces, "service !-> handler" style
+    architecture_rules_owned: bool = false,
+
+    // Issue tracker export settings
+    issues_tracker: ?[]const u8 = null, // github or jira (default: only with --issues)
+    issues_repository: ?[]const u8 = null, // GitHub owner/name (default: $GITHUB_REPOSITORY)
+    issues_jira_url: ?[]const u8 = null, // Jira site, e.g. https://example.atlassian.net
+    issues_jira_project: ?[]const u8 = null, // Jira project key
+    issues_jira_issue_type: ?[]const u8 = null, // Jira issue type (default: Bug)
+    issues_labels: []const []const u8 = &.{}, // Extra labels for created issues
+    issues_labels_owned: bool = false,
+
+    // Vector-store export settings
+    embeddings_provider: ?[]const u8 = null, // openai, ollama, or command (default: openai)
+    embeddings_model: ?[]const u8 = null, // Embedding model (default depends on the provider)
+    embeddings_endpoint: ?[]const u8 = null, // Provider base URL, or the command to run
+    embeddings_store: ?[]const u8 = null, // pgvector, chroma, or qdrant
+    embeddings_store_url: ?[]const u8 = null, // Chroma or Qdrant server URL
+    embeddings_collection: ?[]const u8 = null, // Collection or table name (default: ananke_constraints)
+
+    // LLM summarization settings
+    summarize_enabled: bool = false, // Summarize each package's constraints after extraction
+    summarize_provider: ?[]const u8 = null, // openai (chat completions) or anthropic (default: openai)
+    summarize_endpoint: ?[]const u8 = null, // LLM base URL (default depends on the provider)
+    summarize_model: ?[]const u8 = null, // Model name (required)
+    summarize_requests_per_minute: u32 = 10, // Requests are spaced to stay under this rate
+    summarize_max_requests: usize = 20, // Uncached requests per run; later packages go unsummarized
+
+    // Benchmark settings
+    bench_baseline: ?[]const u8 = null, // Baseline `ananke bench --check` compares against (default: benchmarks/baselines.json)
+    bench_tolerance: f64 = 10.0, // Throughput drop in percent that fails `ananke bench --check` and `bench compare`
 
     // Compile settings
     compile_priority: []const u8 = "medium",
@@ -68,6 +162,102 @@ pub const Config = struct {
         if (self.compile_priority_owned) {
             self.allocator.free(self.compile_priority);
         }
+        if (self.report_messages) |path| {
+            self.allocator.free(path);
+        }
+        if (self.extract_excludes_owned) {
+            freeStringArray(self.allocator, self.extract_excludes);
+        }
+        if (self.extract_baseline) |path| {
+            self.allocator.free(path);
+        }
+        if (self.extract_kinds_owned) {
+            freeStringArray(self.allocator, self.extract_kinds);
+        }
+        if (self.extract_generated) |mode| {
+            self.allocator.free(mode);
+        }
+        if (self.extract_oversized) |action| {
+            self.allocator.free(action);
+        }
+        if (self.io_rate) |rate| {
+            self.allocator.free(rate);
+        }
+        if (self.pipeline_memory) |size| {
+            self.allocator.free(size);
+        }
+        if (self.cache_dir) |path| {
+            self.allocator.free(path);
+        }
+        if (self.cache_remote) |url| {
+            self.allocator.free(url);
+        }
+        if (self.cache_remote_mode) |mode| {
+            self.allocator.free(mode);
+        }
+        if (self.cache_max_size) |size| {
+            self.allocator.free(size);
+        }
+        if (self.webhook_urls_owned) {
+            freeStringArray(self.allocator, self.webhook_urls);
+        }
+        if (self.webhook_events_owned) {
+            freeStringArray(self.allocator, self.webhook_events);
+        }
+        self.webhook_secret.deinit();
+        if (self.notify_slack_owned) {
+            freeStringArray(self.allocator, self.notify_slack);
+        }
+        if (self.notify_teams_owned) {
+            freeStringArray(self.allocator, self.notify_teams);
+        }
+        if (self.notify_webhooks_owned) {
+            freeStringArray(self.allocator, self.notify_webhooks);
+        }
+        if (self.notify_branches_owned) {
+            freeStringArray(self.allocator, self.notify_branches);
+        }
+        if (self.enforce_budgets_owned) {
+            freeStringArray(self.allocator, self.enforce_budgets);
+        }
+        if (self.enforce_coverage_owned) {
+            freeStringArray(self.allocator, self.enforce_coverage);
+        }
+        if (self.policy_packs_owned) {
+            freeStringArray(self.allocator, self.policy_packs);
+        }
+        if (self.architecture_layers_owned) {
+            freeStringArray(self.allocator, self.architecture_layers);
+        }
+        if (self.architecture_rules_owned) {
+            freeStringArray(self.allocator, self.architecture_rules);
+        }
+        for ([_]?[]const u8{
+            self.issues_tracker,
+            self.issues_repository,
+            self.issues_jira_url,
+            self.issues_jira_project,
+            self.issues_jira_issue_type,
+        }) |value| {
+            if (value) |text| self.allocator.free(text);
+        }
+        if (self.issues_labels_owned) {
+            freeStringArray(self.allocator, self.issues_labels);
+        }
+        for ([_]?[]const u8{
+            self.embeddings_provider,
+            self.embeddings_model,
+            self.embeddings_endpoint,
+            self.embeddings_store,
+            self.embeddings_store_url,
+            self.embeddings_collection,
+            self.summarize_provider,
+            self.summarize_endpoint,
+            self.summarize_model,
+            self.bench_baseline,
+        }) |value| {
+            if (value) |text| self.allocator.free(text);
+        }
     }
 
     /// Load configuration from file
@@ -157,6 +347,15 @@ pub const Config = struct {
             self.sglang_endpoint_owned = true;
         } else |_| {}
 
+        if (std.process.getEnvVarOwned(self.allocator, "ANANKE_WEBHOOK_SECRET")) |secret| {
+            self.webhook_secret.replace(self.allocator, secret);
+        } else |_| {}
+
+        if (std.process.getEnvVarOwned(self.allocator, "ANANKE_JOBS")) |jobs| {
+            defer self.allocator.free(jobs);
+            self.jobs = std.fmt.parseInt(usize, std.mem.trim(u8, jobs, " \t"), 10) catch self.jobs;
+        } else |_| {}
+
         if (std.process.getEnvVarOwned(self.allocator, "ANANKE_LANGUAGE")) |lang| {
             if (self.default_language_owned) {
                 self.allocator.free(self.default_language);
@@ -249,10 +448,49 @@ pub const Config = struct {
                     }
                     self.output_format = try self.allocator.dupe(u8, value);
                     self.output_format_owned = true;
+                } else if (std.mem.eql(u8, key, "redact")) {
+                    self.redact = std.mem.eql(u8, value, "true");
                 }
             } else if (std.mem.eql(u8, sec, "extract")) {
                 if (std.mem.eql(u8, key, "use_claude")) {
                     self.use_claude = std.mem.eql(u8, value, "true");
+                } else if (std.mem.eql(u8, key, "exclude")) {
+                    const patterns = try parseStringArray(self.allocator, value);
+                    if (self.extract_excludes_owned) {
+                        freeStringArray(self.allocator, self.extract_excludes);
+                    }
+                    self.extract_excludes = patterns;
+                    self.extract_excludes_owned = true;
+                } else if (std.mem.eql(u8, key, "gitignore")) {
+                    self.extract_gitignore = std.mem.eql(u8, value, "true");
+                } else if (std.mem.eql(u8, key, "baseline")) {
+                    if (self.extract_baseline) |old| {
+                        self.allocator.free(old);
+                    }
+                    self.extract_baseline = try self.allocator.dupe(u8, value);
+                } else if (std.mem.eql(u8, key, "kinds")) {
+                    const kinds = try parseStringArray(self.allocator, value);
+                    if (self.extract_kinds_owned) {
+                        freeStringArray(self.allocator, self.extract_kinds);
+                    }
+                    self.extract_kinds = kinds;
+                    self.extract_kinds_owned = true;
+                } else if (std.mem.eql(u8, key, "generated")) {
+                    if (self.extract_generated) |old| {
+                        self.allocator.free(old);
+                    }
+                    self.extract_generated = try self.allocator.dupe(u8, value);
+                } else if (std.mem.eql(u8, key, "max_file_bytes")) {
+                    self.extract_max_file_bytes = try std.fmt.parseInt(usize, value, 10);
+                } else if (std.mem.eql(u8, key, "max_file_lines")) {
+                    self.extract_max_file_lines = try std.fmt.parseInt(usize, value, 10);
+                } else if (std.mem.eql(u8, key, "oversized")) {
+                    if (self.extract_oversized) |old| {
+                        self.allocator.free(old);
+                    }
+                    self.extract_oversized = try self.allocator.dupe(u8, value);
+                } else if (std.mem.eql(u8, key, "collapse_similar")) {
+                    self.extract_collapse_similar = try std.fmt.parseFloat(f32, value);
                 }
             } else if (std.mem.eql(u8, sec, "compile")) {
                 if (std.mem.eql(u8, key, "priority")) {
@@ -262,6 +500,228 @@ pub const Config = struct {
                     self.compile_priority = try self.allocator.dupe(u8, value);
                     self.compile_priority_owned = true;
                 }
+            } else if (std.mem.eql(u8, sec, "performance")) {
+                if (std.mem.eql(u8, key, "jobs")) {
+                    self.jobs = try std.fmt.parseInt(usize, value, 10);
+                } else if (std.mem.eql(u8, key, "parse_jobs")) {
+                    self.parse_jobs = try std.fmt.parseInt(usize, value, 10);
+                } else if (std.mem.eql(u8, key, "analyze_jobs")) {
+                    self.analyze_jobs = try std.fmt.parseInt(usize, value, 10);
+                } else if (std.mem.eql(u8, key, "render_jobs")) {
+                    self.render_jobs = try std.fmt.parseInt(usize, value, 10);
+                } else if (std.mem.eql(u8, key, "io_rate")) {
+                    if (self.io_rate) |old| {
+                        self.allocator.free(old);
+                    }
+                    self.io_rate = try self.allocator.dupe(u8, value);
+                } else if (std.mem.eql(u8, key, "language_pipelines")) {
+                    self.language_pipelines = std.mem.eql(u8, value, "true");
+                } else if (std.mem.eql(u8, key, "pipeline_memory")) {
+                    if (self.pipeline_memory) |old| {
+                        self.allocator.free(old);
+                    }
+                    self.pipeline_memory = try self.allocator.dupe(u8, value);
+                }
+            } else if (std.mem.eql(u8, sec, "cache")) {
+                if (std.mem.eql(u8, key, "enabled")) {
+                    self.cache_enabled = std.mem.eql(u8, value, "true");
+                } else if (std.mem.eql(u8, key, "dir")) {
+                    if (self.cache_dir) |old| {
+                        self.allocator.free(old);
+                    }
+                    self.cache_dir = try self.allocator.dupe(u8, value);
+                } else if (std.mem.eql(u8, key, "remote")) {
+                    if (self.cache_remote) |old| {
+                        self.allocator.free(old);
+                    }
+                    self.cache_remote = try self.allocator.dupe(u8, value);
+                } else if (std.mem.eql(u8, key, "remote_mode")) {
+                    if (self.cache_remote_mode) |old| {
+                        self.allocator.free(old);
+                    }
+                    self.cache_remote_mode = try self.allocator.dupe(u8, value);
+                } else if (std.mem.eql(u8, key, "max_size")) {
+                    if (self.cache_max_size) |old| {
+                        self.allocator.free(old);
+                    }
+                    self.cache_max_size = try self.allocator.dupe(u8, value);
+                }
+            } else if (std.mem.eql(u8, sec, "webhooks")) {
+                if (std.mem.eql(u8, key, "urls")) {
+                    const urls = try parseStringArray(self.allocator, value);
+                    if (self.webhook_urls_owned) {
+                        freeStringArray(self.allocator, self.webhook_urls);
+                    }
+                    self.webhook_urls = urls;
+                    self.webhook_urls_owned = true;
+                } else if (std.mem.eql(u8, key, "events")) {
+                    const events = try parseStringArray(self.allocator, value);
+                    if (self.webhook_events_owned) {
+                        freeStringArray(self.allocator, self.webhook_events);
+                    }
+                    self.webhook_events = events;
+                    self.webhook_events_owned = true;
+                } else if (std.mem.eql(u8, key, "secret")) {
+                    const secret_copy = try self.allocator.dupe(u8, value);
+                    self.webhook_secret.replace(self.allocator, secret_copy);
+                }
+            } else if (std.mem.eql(u8, sec, "notify")) {
+                if (std.mem.eql(u8, key, "slack")) {
+                    const urls = try parseStringArray(self.allocator, value);
+                    if (self.notify_slack_owned) {
+                        freeStringArray(self.allocator, self.notify_slack);
+                    }
+                    self.notify_slack = urls;
+                    self.notify_slack_owned = true;
+                } else if (std.mem.eql(u8, key, "teams")) {
+                    const urls = try parseStringArray(self.allocator, value);
+                    if (self.notify_teams_owned) {
+                        freeStringArray(self.allocator, self.notify_teams);
+                    }
+                    self.notify_teams = urls;
+                    self.notify_teams_owned = true;
+                } else if (std.mem.eql(u8, key, "webhooks")) {
+                    const urls = try parseStringArray(self.allocator, value);
+                    if (self.notify_webhooks_owned) {
+                        freeStringArray(self.allocator, self.notify_webhooks);
+                    }
+                    self.notify_webhooks = urls;
+                    self.notify_webhooks_owned = true;
+                } else if (std.mem.eql(u8, key, "drift_threshold")) {
+                    self.notify_drift_threshold = try std.fmt.parseInt(usize, value, 10);
+                } else if (std.mem.eql(u8, key, "branches")) {
+                    const branches = try parseStringArray(self.allocator, value);
+                    if (self.notify_branches_owned) {
+                        freeStringArray(self.allocator, self.notify_branches);
+                    }
+                    self.notify_branches = branches;
+                    self.notify_branches_owned = true;
+                }
+            } else if (std.mem.eql(u8, sec, "enforce")) {
+                if (std.mem.eql(u8, key, "budgets")) {
+                    const budgets = try parseStringArray(self.allocator, value);
+                    if (self.enforce_budgets_owned) {
+                        freeStringArray(self.allocator, self.enforce_budgets);
+                    }
+                    self.enforce_budgets = budgets;
+                    self.enforce_budgets_owned = true;
+                } else if (std.mem.eql(u8, key, "coverage")) {
+                    const thresholds = try parseStringArray(self.allocator, value);
+                    if (self.enforce_coverage_owned) {
+                        freeStringArray(self.allocator, self.enforce_coverage);
+                    }
+                    self.enforce_coverage = thresholds;
+                    self.enforce_coverage_owned = true;
+                }
+            } else if (std.mem.eql(u8, sec, "policy")) {
+                if (std.mem.eql(u8, key, "packs")) {
+                    const names = try parseStringArray(self.allocator, value);
+                    if (self.policy_packs_owned) {
+                        freeStringArray(self.allocator, self.policy_packs);
+                    }
+                    self.policy_packs = names;
+                    self.policy_packs_owned = true;
+                }
+            } else if (std.mem.eql(u8, sec, "architecture")) {
+                if (std.mem.eql(u8, key, "layers")) {
+                    const layers = try parseStringArray(self.allocator, value);
+                    if (self.architecture_layers_owned) {
+                        freeStringArray(self.allocator, self.architecture_layers);
+                    }
+                    self.architecture_layers = layers;
+                    self.architecture_layers_owned = true;
+                } else if (std.mem.eql(u8, key, "rules")) {
+                    const rules = try parseStringArray(self.allocator, value);
+                    if (self.architecture_rules_owned) {
+                        freeStringArray(self.allocator, self.architecture_rules);
+                    }
+                    self.architecture_rules = rules;
+                    self.architecture_rules_owned = true;
+                }
+            } else if (std.mem.eql(u8, sec, "issues")) {
+                const field: ?*?[]const u8 = if (std.mem.eql(u8, key, "tracker"))
+                    &self.issues_tracker
+                else if (std.mem.eql(u8, key, "repository"))
+                    &self.issues_repository
+                else if (std.mem.eql(u8, key, "jira_url"))
+                    &self.issues_jira_url
+                else if (std.mem.eql(u8, key, "jira_project"))
+                    &self.issues_jira_project
+                else if (std.mem.eql(u8, key, "jira_issue_type"))
+                    &self.issues_jira_issue_type
+                else
+                    null;
+                if (field) |ptr| {
+                    if (ptr.*) |old| {
+                        self.allocator.free(old);
+                    }
+                    ptr.* = try self.allocator.dupe(u8, value);
+                } else if (std.mem.eql(u8, key, "labels")) {
+                    const labels = try parseStringArray(self.allocator, value);
+                    if (self.issues_labels_owned) {
+                        freeStringArray(self.allocator, self.issues_labels);
+                    }
+                    self.issues_labels = labels;
+                    self.issues_labels_owned = true;
+                }
+            } else if (std.mem.eql(u8, sec, "embeddings")) {
+                const field: ?*?[]const u8 = if (std.mem.eql(u8, key, "provider"))
+                    &self.embeddings_provider
+                else if (std.mem.eql(u8, key, "model"))
+                    &self.embeddings_model
+                else if (std.mem.eql(u8, key, "endpoint"))
+                    &self.embeddings_endpoint
+                else if (std.mem.eql(u8, key, "store"))
+                    &self.embeddings_store
+                else if (std.mem.eql(u8, key, "store_url"))
+                    &self.embeddings_store_url
+                else if (std.mem.eql(u8, key, "collection"))
+                    &self.embeddings_collection
+                else
+                    null;
+                if (field) |ptr| {
+                    if (ptr.*) |old| {
+                        self.allocator.free(old);
+                    }
+                    ptr.* = try self.allocator.dupe(u8, value);
+                }
+            } else if (std.mem.eql(u8, sec, "summarize")) {
+                const field: ?*?[]const u8 = if (std.mem.eql(u8, key, "provider"))
+                    &self.summarize_provider
+                else if (std.mem.eql(u8, key, "endpoint"))
+                    &self.summarize_endpoint
+                else if (std.mem.eql(u8, key, "model"))
+                    &self.summarize_model
+                else
+                    null;
+                if (field) |ptr| {
+                    if (ptr.*) |old| {
+                        self.allocator.free(old);
+                    }
+                    ptr.* = try self.allocator.dupe(u8, value);
+                } else if (std.mem.eql(u8, key, "enabled")) {
+                    self.summarize_enabled = std.mem.eql(u8, value, "true");
+                } else if (std.mem.eql(u8, key, "requests_per_minute")) {
+                    self.summarize_requests_per_minute = try std.fmt.parseInt(u32, value, 10);
+                } else if (std.mem.eql(u8, key, "max_requests")) {
+                    self.summarize_max_requests = try std.fmt.parseInt(usize, value, 10);
+                }
+            } else if (std.mem.eql(u8, sec, "bench")) {
+                if (std.mem.eql(u8, key, "baseline")) {
+                    if (self.bench_baseline) |old| {
+                        self.allocator.free(old);
+                    }
+                    self.bench_baseline = try self.allocator.dupe(u8, value);
+                } else if (std.mem.eql(u8, key, "tolerance")) {
+                    self.bench_tolerance = try std.fmt.parseFloat(f64, value);
+                }
+            } else if (std.mem.eql(u8, sec, "report")) {
+                if (std.mem.eql(u8, key, "messages")) {
+                    if (self.report_messages) |old| {
+                        self.allocator.free(old);
+                    }
+                    self.report_messages = try self.allocator.dupe(u8, value);
+                }
             }
         }
     }
@@ -273,61 +733,286 @@ pub const Config = struct {
 
         var buf: [4096]u8 = undefined;
         var writer = file.writer(&buf);
+        try self.writeToml(&writer.interface);
+        try writer.interface.flush();
+    }
 
-        try writer.interface.writeAll("# Ananke Configuration File\n\n");
+    /// Configuration as TOML. API keys are never written.
+    pub fn formatToml(self: *const Config, allocator: std.mem.Allocator) ![]u8 {
+        var list = std.ArrayList(u8){};
+        errdefer list.deinit(allocator);
+        try self.writeToml(list.writer(allocator));
+        return list.toOwnedSlice(allocator);
+    }
+
+    fn writeToml(self: *const Config, writer: anytype) !void {
+        try writer.writeAll("# Ananke Configuration File\n\n");
 
         // Modal section
-        try writer.interface.writeAll("[modal]\n");
+        try writer.writeAll("[modal]\n");
         if (self.modal_endpoint) |endpoint| {
-            try writer.interface.print("endpoint = \"{s}\"\n", .{endpoint});
+            try writer.print("endpoint = \"{s}\"\n", .{endpoint});
         } else {
-            try writer.interface.writeAll("# endpoint = \"https://your-app.modal.run\"\n");
+            try writer.writeAll("# endpoint = \"https://your-app.modal.run\"\n");
         }
-        try writer.interface.writeAll("# API key should be stored in environment variable ANANKE_MODAL_API_KEY\n");
-        try writer.interface.writeAll("# or set here (not recommended for security)\n");
+        try writer.writeAll("# API key should be stored in environment variable ANANKE_MODAL_API_KEY\n");
+        try writer.writeAll("# or set here (not recommended for security)\n");
         if (self.modal_api_key.isSet()) {
-            try writer.interface.writeAll("# api_key = \"your-key-here\"\n");
+            try writer.writeAll("# api_key = \"your-key-here\"\n");
         }
-        try writer.interface.writeAll("\n");
+        try writer.writeAll("\n");
 
         // Claude section
-        try writer.interface.writeAll("[claude]\n");
-        try writer.interface.writeAll("# Claude API configuration for semantic analysis\n");
-        try writer.interface.writeAll("# API key should be stored in environment variable ANTHROPIC_API_KEY\n");
-        try writer.interface.writeAll("# or set here (not recommended for security)\n");
+        try writer.writeAll("[claude]\n");
+        try writer.writeAll("# Claude API configuration for semantic analysis\n");
+        try writer.writeAll("# API key should be stored in environment variable ANTHROPIC_API_KEY\n");
+        try writer.writeAll("# or set here (not recommended for security)\n");
         if (self.claude_api_key.isSet()) {
-            try writer.interface.writeAll("# api_key = \"sk-ant-...\"\n");
+            try writer.writeAll("# api_key = \"sk-ant-...\"\n");
         }
         if (self.claude_endpoint) |endpoint| {
-            try writer.interface.print("endpoint = \"{s}\"\n", .{endpoint});
+            try writer.print("endpoint = \"{s}\"\n", .{endpoint});
         } else {
-            try writer.interface.writeAll("# endpoint = \"https://api.anthropic.com/v1/messages\"\n");
+            try writer.writeAll("# endpoint = \"https://api.anthropic.com/v1/messages\"\n");
         }
-        try writer.interface.print("model = \"{s}\"\n", .{self.claude_model});
-        try writer.interface.print("enabled = {s}\n", .{if (self.use_claude) "true" else "false"});
-        try writer.interface.writeAll("\n");
+        try writer.print("model = \"{s}\"\n", .{self.claude_model});
+        try writer.print("enabled = {s}\n", .{if (self.use_claude) "true" else "false"});
+        try writer.writeAll("\n");
 
         // Defaults section
-        try writer.interface.writeAll("[defaults]\n");
-        try writer.interface.print("language = \"{s}\"\n", .{self.default_language});
-        try writer.interface.print("max_tokens = {d}\n", .{self.max_tokens});
-        try writer.interface.print("temperature = {d:.1}\n", .{self.temperature});
-        try writer.interface.print("confidence_threshold = {d:.1}\n", .{self.confidence_threshold});
-        try writer.interface.print("output_format = \"{s}\"\n", .{self.output_format});
-        try writer.interface.writeAll("\n");
+        try writer.writeAll("[defaults]\n");
+        try writer.print("language = \"{s}\"\n", .{self.default_language});
+        try writer.print("max_tokens = {d}\n", .{self.max_tokens});
+        try writer.print("temperature = {d:.1}\n", .{self.temperature});
+        try writer.print("confidence_threshold = {d:.1}\n", .{self.confidence_threshold});
+        try writer.print("output_format = \"{s}\"\n", .{self.output_format});
+        try writer.print("redact = {s}\n", .{if (self.redact) "true" else "false"});
+        try writer.writeAll("\n");
 
         // Extract section
-        try writer.interface.writeAll("[extract]\n");
-        try writer.interface.print("use_claude = {s}\n", .{if (self.use_claude) "true" else "false"});
-        try writer.interface.writeAll("patterns = [\"all\"]\n");
-        try writer.interface.writeAll("\n");
+        try writer.writeAll("[extract]\n");
+        try writer.print("use_claude = {s}\n", .{if (self.use_claude) "true" else "false"});
+        try writer.writeAll("patterns = [\"all\"]\n");
+        try writer.writeAll("exclude = [");
+        for (self.extract_excludes, 0..) |pattern, i| {
+            if (i > 0) try writer.writeAll(", ");
+            try writer.print("\"{s}\"", .{pattern});
+        }
+        try writer.writeAll("]\n");
+        try writer.print("gitignore = {s}\n", .{if (self.extract_gitignore) "true" else "false"});
+        if (self.extract_baseline) |path| {
+            try writer.print("baseline = \"{s}\"\n", .{path});
+        } else {
+            try writer.writeAll("# baseline = \".ananke-baseline.json\"\n");
+        }
+        if (self.extract_kinds.len > 0) {
+            try writer.writeAll("kinds = [");
+            for (self.extract_kinds, 0..) |kind, i| {
+                if (i > 0) try writer.writeAll(", ");
+                try writer.print("\"{s}\"", .{kind});
+            }
+            try writer.writeAll("]\n");
+        }
+        if (self.extract_generated) |mode| {
+            try writer.print("generated = \"{s}\"\n", .{mode});
+        }
+        try writer.print("max_file_bytes = {d}\n", .{self.extract_max_file_bytes});
+        try writer.print("max_file_lines = {d}\n", .{self.extract_max_file_lines});
+        if (self.extract_oversized) |action| {
+            try writer.print("oversized = \"{s}\"\n", .{action});
+        }
+        if (self.extract_collapse_similar > 0) {
+            try writer.print("collapse_similar = {d}\n", .{self.extract_collapse_similar});
+        }
+        try writer.writeAll("\n");
+
+        // Performance section
+        try writer.writeAll("[performance]\n");
+        try writer.writeAll("# 0 = number of CPUs; ANANKE_JOBS overrides jobs\n");
+        try writer.print("jobs = {d}\n", .{self.jobs});
+        try writer.print("parse_jobs = {d}\n", .{self.parse_jobs});
+        try writer.print("analyze_jobs = {d}\n", .{self.analyze_jobs});
+        try writer.print("render_jobs = {d}\n", .{self.render_jobs});
+        if (self.io_rate) |rate| {
+            try writer.print("io_rate = \"{s}\"\n", .{rate});
+        }
+        try writer.print("language_pipelines = {s}\n", .{if (self.language_pipelines) "true" else "false"});
+        if (self.pipeline_memory) |size| {
+            try writer.print("pipeline_memory = \"{s}\"\n", .{size});
+        }
+        try writer.writeAll("\n");
 
-        // Compile section
-        try writer.interface.writeAll("[compile]\n");
-        try writer.interface.print("priority = \"{s}\"\n", .{self.compile_priority});
-        try writer.interface.writeAll("formats = [\"json-schema\"]\n");
+        // Cache section
+        try writer.writeAll("[cache]\n");
+        try writer.print("enabled = {s}\n", .{if (self.cache_enabled) "true" else "false"});
+        if (self.cache_dir) |path| {
+            try writer.print("dir = \"{s}\"\n", .{path});
+        } else {
+            try writer.writeAll("# dir = \".ananke-cache\"\n");
+        }
+        if (self.cache_remote) |url| {
+            try writer.print("remote = \"{s}\"\n", .{url});
+        }
+        if (self.cache_remote_mode) |mode| {
+            try writer.print("remote_mode = \"{s}\"\n", .{mode});
+        }
+        if (self.cache_max_size) |size| {
+            try writer.print("max_size = \"{s}\"\n", .{size});
+        }
+        try writer.writeAll("\n");
+
+        // Webhooks section
+        if (self.webhook_urls.len > 0) {
+            try writer.writeAll("[webhooks]\n");
+            try writer.writeAll("urls = [");
+            for (self.webhook_urls, 0..) |url, i| {
+                if (i > 0) try writer.writeAll(", ");
+                try writer.print("\"{s}\"", .{url});
+            }
+            try writer.writeAll("]\n");
+            if (self.webhook_events.len > 0) {
+                try writer.writeAll("events = [");
+                for (self.webhook_events, 0..) |event, i| {
+                    if (i > 0) try writer.writeAll(", ");
+                    try writer.print("\"{s}\"", .{event});
+                }
+                try writer.writeAll("]\n");
+            }
+            try writer.writeAll("# Secret should be stored in environment variable ANANKE_WEBHOOK_SECRET\n");
+            try writer.writeAll("\n");
+        }
 
-        try writer.interface.flush();
+        // Notify section
+        if (self.notify_slack.len + self.notify_teams.len + self.notify_webhooks.len > 0) {
+            try writer.writeAll("[notify]\n");
+            for ([_][]const u8{ "slack", "teams", "webhooks", "branches" }, [_][]const []const u8{
+                self.notify_slack,
+                self.notify_teams,
+                self.notify_webhooks,
+                self.notify_branches,
+            }) |key, values| {
+                if (values.len == 0) continue;
+                try writer.print("{s} = [", .{key});
+                for (values, 0..) |value, i| {
+                    if (i > 0) try writer.writeAll(", ");
+                    try writer.print("\"{s}\"", .{value});
+                }
+                try writer.writeAll("]\n");
+            }
+            try writer.print("drift_threshold = {d}\n", .{self.notify_drift_threshold});
+            try writer.writeAll("\n");
+        }
+
+        // Enforce section
+        if (self.enforce_budgets.len > 0 or self.enforce_coverage.len > 0) {
+            try writer.writeAll("[enforce]\n");
+            for ([_][]const u8{ "budgets", "coverage" }, [_][]const []const u8{ self.enforce_budgets, self.enforce_coverage }) |key, values| {
+                if (values.len == 0) continue;
+                try writer.print("{s} = [", .{key});
+                for (values, 0..) |value, i| {
+                    if (i > 0) try writer.writeAll(", ");
+                    try writer.print("\"{s}\"", .{value});
+                }
+                try writer.writeAll("]\n");
+            }
+            try writer.writeAll("\n");
+        }
+
+        // Policy section
+        if (self.policy_packs.len > 0) {
+            try writer.writeAll("[policy]\npacks = [");
+            for (self.policy_packs, 0..) |name, i| {
+                if (i > 0) try writer.writeAll(", ");
+                try writer.print("\"{s}\"", .{name});
+            }
+            try writer.writeAll("]\n\n");
+        }
+
+        // Architecture section
+        if (self.architecture_layers.len > 0 or self.architecture_rules.len > 0) {
+            try writer.writeAll("[architecture]\n");
+            for ([_][]const u8{ "layers", "rules" }, [_][]const []const u8{ self.architecture_layers, self.architecture_rules }) |key, values| {
+                if (values.len == 0) continue;
+                try writer.print("{s} = [", .{key});
+                for (values, 0..) |value, i| {
+                    if (i > 0) try writer.writeAll(", ");
+                    try writer.print("\"{s}\"", .{value});
+                }
+                try writer.writeAll("]\n");
+            }
+            try writer.writeAll("\n");
+        }
+
+        // Issues section
+        if (self.issues_tracker != null or self.issues_repository != null or self.issues_jira_url != null) {
+            try writer.writeAll("[issues]\n");
+            for ([_][]const u8{ "tracker", "repository", "jira_url", "jira_project", "jira_issue_type" }, [_]?[]const u8{
+                self.issues_tracker,
+                self.issues_repository,
+                self.issues_jira_url,
+                self.issues_jira_project,
+                self.issues_jira_issue_type,
+            }) |key, value| {
+                if (value) |text| try writer.print("{s} = \"{s}\"\n", .{ key, text });
+            }
+            if (self.issues_labels.len > 0) {
+                try writer.writeAll("labels = [");
+                for (self.issues_labels, 0..) |issue_label, i| {
+                    if (i > 0) try writer.writeAll(", ");
+                    try writer.print("\"{s}\"", .{issue_label});
+                }
+                try writer.writeAll("]\n");
+            }
+            try writer.writeAll("# Tokens come from GITHUB_TOKEN, or JIRA_EMAIL and JIRA_API_TOKEN\n");
+            try writer.writeAll("\n");
+        }
+
+        // Embeddings section
+        if (self.embeddings_provider != null or self.embeddings_store != null) {
+            try writer.writeAll("[embeddings]\n");
+            for ([_][]const u8{ "provider", "model", "endpoint", "store", "store_url", "collection" }, [_]?[]const u8{
+                self.embeddings_provider,
+                self.embeddings_model,
+                self.embeddings_endpoint,
+                self.embeddings_store,
+                self.embeddings_store_url,
+                self.embeddings_collection,
+            }) |key, value| {
+                if (value) |text| try writer.print("{s} = \"{s}\"\n", .{ key, text });
+            }
+            try writer.writeAll("# API keys come from OPENAI_API_KEY, QDRANT_API_KEY, or CHROMA_API_KEY\n");
+            try writer.writeAll("\n");
+        }
+
+        // Summarize section
+        if (self.summarize_enabled or self.summarize_model != null) {
+            try writer.writeAll("[summarize]\n");
+            try writer.print("enabled = {s}\n", .{if (self.summarize_enabled) "true" else "false"});
+            for ([_][]const u8{ "provider", "endpoint", "model" }, [_]?[]const u8{
+                self.summarize_provider,
+                self.summarize_endpoint,
+                self.summarize_model,
+            }) |key, value| {
+                if (value) |text| try writer.print("{s} = \"{s}\"\n", .{ key, text });
+            }
+            try writer.print("requests_per_minute = {d}\n", .{self.summarize_requests_per_minute});
+            try writer.print("max_requests = {d}\n", .{self.summarize_max_requests});
+            try writer.writeAll("# API keys come from ANANKE_SUMMARIZE_API_KEY, OPENAI_API_KEY, or ANTHROPIC_API_KEY\n");
+            try writer.writeAll("\n");
+        }
+
+        // Bench section
+        if (self.bench_baseline != null or self.bench_tolerance != 10.0) {
+            try writer.writeAll("[bench]\n");
+            if (self.bench_baseline) |path| try writer.print("baseline = \"{s}\"\n", .{path});
+            try writer.print("tolerance = {d}\n", .{self.bench_tolerance});
+            try writer.writeAll("\n");
+        }
+
+        // Compile section
+        try writer.writeAll("[compile]\n");
+        try writer.print("priority = \"{s}\"\n", .{self.compile_priority});
+        try writer.writeAll("formats = [\"json-schema\"]\n");
     }
 
     /// Create a default configuration file
@@ -337,6 +1022,57 @@ pub const Config = struct {
     }
 };
 
+/// Parse a single-line TOML string array: ["a", "b"].
+/// A bare string value is accepted as a one-element array.
+fn parseStringArray(allocator: std.mem.Allocator, value: []const u8) ![]const []const u8 {
+    const trimmed = std.mem.trim(u8, value, " \t");
+    if (trimmed.len < 2 or trimmed[0] != '[' or trimmed[trimmed.len - 1] != ']') {
+        const items = try allocator.alloc([]const u8, 1);
+        errdefer allocator.free(items);
+        items[0] = try allocator.dupe(u8, trimmed);
+        return items;
+    }
+
+    var items = std.ArrayList([]const u8){};
+    errdefer {
+        for (items.items) |item| allocator.free(item);
+        items.deinit(allocator);
+    }
+
+    // Items are separated by commas outside quotes
+    const inner = trimmed[1 .. trimmed.len - 1];
+    var quote: ?u8 = null;
+    var start: usize = 0;
+    for (inner, 0..) |c, i| {
+        if (quote) |q| {
+            if (c == q) quote = null;
+        } else if (c == '"' or c == '\'') {
+            quote = c;
+        }
+        if (quote != null or (c != ',' and i + 1 < inner.len)) continue;
+        const end = if (c == ',') i else i + 1;
+        var item = std.mem.trim(u8, inner[start..end], " \t");
+        start = i + 1;
+        if (item.len == 0) continue;
+        if (item.len >= 2 and (item[0] == '"' or item[0] == '\'') and item[item.len - 1] == item[0]) {
+            item = item[1 .. item.len - 1];
+        }
+        try items.append(allocator, try allocator.dupe(u8, item));
+    }
+    // An unterminated quote runs to the end
+    if (quote != null) {
+        const item = std.mem.trim(u8, inner[start..], " \t");
+        if (item.len > 0) try items.append(allocator, try allocator.dupe(u8, item));
+    }
+
+    return items.toOwnedSlice(allocator);
+}
+
+fn freeStringArray(allocator: std.mem.Allocator, items: []const []const u8) void {
+    for (items) |item| allocator.free(item);
+    allocator.free(items);
+}
+
 test "config initialization" {
     const testing = std.testing;
     const allocator = testing.allocator;
@@ -398,6 +1134,60 @@ test "config parse Claude section" {
     try testing.expectEqual(true, config.use_claude);
 }
 
+test "config parse redact default" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var config = Config.init(allocator);
+    defer config.deinit();
+
+    try testing.expectEqual(false, config.redact);
+
+    const toml =
+        \\[defaults]
+        \\redact = true
+    ;
+
+    try config.parseToml(toml);
+
+    try testing.expectEqual(true, config.redact);
+}
+
+test "config parse extract excludes" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var config = Config.init(allocator);
+    defer config.deinit();
+
+    const toml =
+        \\[extract]
+        \\exclude = ["test/fixtures", "**/*_generated.go"]
+        \\gitignore = false
+        \\baseline = ".ananke-baseline.json"
+        \\kinds = ["syntactic", "security"]
+        \\generated = "skip"
+        \\max_file_lines = 20000
+        \\oversized = "skip"
+        \\collapse_similar = 0.85
+    ;
+
+    try config.parseToml(toml);
+
+    try testing.expectEqual(@as(usize, 2), config.extract_excludes.len);
+    try testing.expectEqualStrings("test/fixtures", config.extract_excludes[0]);
+    try testing.expectEqualStrings("**/*_generated.go", config.extract_excludes[1]);
+    try testing.expectEqual(false, config.extract_gitignore);
+    try testing.expectEqualStrings(".ananke-baseline.json", config.extract_baseline.?);
+    try testing.expectEqual(@as(usize, 2), config.extract_kinds.len);
+    try testing.expectEqualStrings("security", config.extract_kinds[1]);
+    try testing.expectEqualStrings("skip", config.extract_generated.?);
+    try testing.expectEqual(@as(usize, 20000), config.extract_max_file_lines);
+    try testing.expectEqual(@as(usize, 0), config.extract_max_file_bytes);
+    try testing.expectEqualStrings("skip", config.extract_oversized.?);
+    try testing.expectApproxEqAbs(@as(f32, 0.85), config.extract_collapse_similar, 0.001);
+}
+
 test "config parse sglang section" {
     const testing = std.testing;
     const allocator = testing.allocator;
@@ -433,3 +1223,216 @@ test "config Claude API key auto-enables use_claude" {
     try testing.expectEqual(true, config.use_claude);
     try testing.expectEqualStrings("sk-ant-test", config.claude_api_key.slice().?);
 }
+
+test "config parse performance section" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var config = Config.init(allocator);
+    defer config.deinit();
+
+    const toml =
+        \\[performance]
+        \\jobs = 4
+        \\analyze_jobs = 2
+        \\io_rate = "50M"
+        \\language_pipelines = true
+        \\pipeline_memory = "64M"
+    ;
+
+    try config.parseToml(toml);
+
+    try testing.expectEqual(@as(usize, 4), config.jobs);
+    try testing.expectEqual(@as(usize, 2), config.analyze_jobs);
+    try testing.expectEqual(@as(usize, 0), config.parse_jobs);
+    try testing.expectEqualStrings("50M", config.io_rate.?);
+    try testing.expect(config.language_pipelines);
+    try testing.expectEqualStrings("64M", config.pipeline_memory.?);
+}
+
+test "config parse cache section" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var config = Config.init(allocator);
+    defer config.deinit();
+    try testing.expectEqual(true, config.cache_enabled);
+
+    const toml =
+        \\[cache]
+        \\enabled = false
+        \\dir = "/tmp/ananke"
+        \\remote = "s3://ci-cache/ananke"
+        \\remote_mode = "read-write"
+        \\max_size = "512M"
+    ;
+
+    try config.parseToml(toml);
+
+    try testing.expectEqual(false, config.cache_enabled);
+    try testing.expectEqualStrings("/tmp/ananke", config.cache_dir.?);
+    try testing.expectEqualStrings("s3://ci-cache/ananke", config.cache_remote.?);
+    try testing.expectEqualStrings("read-write", config.cache_remote_mode.?);
+    try testing.expectEqualStrings("512M", config.cache_max_size.?);
+}
+
+test "config parse webhooks section" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var config = Config.init(allocator);
+    defer config.deinit();
+
+    const toml =
+        \\[webhooks]
+        \\urls = ["https://ci.example.com/hooks/ananke", "http://localhost:9000/done"]
+        \\events = ["extract"]
+        \\secret = "s3cret"
+    ;
+
+    try config.parseToml(toml);
+
+    try testing.expectEqual(@as(usize, 2), config.webhook_urls.len);
+    try testing.expectEqualStrings("http://localhost:9000/done", config.webhook_urls[1]);
+    try testing.expectEqualStrings("extract", config.webhook_events[0]);
+    try testing.expectEqualStrings("s3cret", config.webhook_secret.slice().?);
+}
+
+test "config parse notify section" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var config = Config.init(allocator);
+    defer config.deinit();
+
+    const toml =
+        \\[notify]
+        \\slack = ["https://hooks.slack.com/services/T0/B0/XYZ"]
+        \\teams = "https://example.webhook.office.com/webhookb2/abc"
+        \\drift_threshold = 5
+        \\branches = ["main", "release/*"]
+    ;
+
+    try config.parseToml(toml);
+
+    try testing.expectEqual(@as(usize, 1), config.notify_slack.len);
+    try testing.expectEqualStrings("https://example.webhook.office.com/webhookb2/abc", config.notify_teams[0]);
+    try testing.expectEqual(@as(usize, 0), config.notify_webhooks.len);
+    try testing.expectEqual(@as(usize, 5), config.notify_drift_threshold);
+    try testing.expectEqualStrings("release/*", config.notify_branches[1]);
+}
+
+test "config parse issues section" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var config = Config.init(allocator);
+    defer config.deinit();
+
+    const toml =
+        \\[issues]
+        \\tracker = "jira"
+        \\jira_url = "https://example.atlassian.net"
+        \\jira_project = "SEC"
+        \\labels = ["constraints"]
+    ;
+
+    try config.parseToml(toml);
+
+    try testing.expectEqualStrings("jira", config.issues_tracker.?);
+    try testing.expectEqualStrings("https://example.atlassian.net", config.issues_jira_url.?);
+    try testing.expectEqualStrings("SEC", config.issues_jira_project.?);
+    try testing.expect(config.issues_jira_issue_type == null);
+    try testing.expectEqualStrings("constraints", config.issues_labels[0]);
+}
+
+test "config parse embeddings section" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var config = Config.init(allocator);
+    defer config.deinit();
+
+    const toml =
+        \\[embeddings]
+        \\provider = "ollama"
+        \\model = "nomic-embed-text"
+        \\store = "qdrant"
+        \\store_url = "http://localhost:6333"
+    ;
+
+    try config.parseToml(toml);
+
+    try testing.expectEqualStrings("ollama", config.embeddings_provider.?);
+    try testing.expectEqualStrings("nomic-embed-text", config.embeddings_model.?);
+    try testing.expectEqualStrings("qdrant", config.embeddings_store.?);
+    try testing.expectEqualStrings("http://localhost:6333", config.embeddings_store_url.?);
+    try testing.expect(config.embeddings_collection == null);
+}
+
+test "config parse summarize section" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var config = Config.init(allocator);
+    defer config.deinit();
+    try testing.expect(!config.summarize_enabled);
+
+    const toml =
+        \\[summarize]
+        \\enabled = true
+        \\endpoint = "http://localhost:30000"
+        \\model = "Qwen/Qwen2.5-Coder-32B-Instruct"
+        \\requests_per_minute = 4
+    ;
+
+    try config.parseToml(toml);
+
+    try testing.expect(config.summarize_enabled);
+    try testing.expect(config.summarize_provider == null);
+    try testing.expectEqualStrings("http://localhost:30000", config.summarize_endpoint.?);
+    try testing.expectEqualStrings("Qwen/Qwen2.5-Coder-32B-Instruct", config.summarize_model.?);
+    try testing.expectEqual(@as(u32, 4), config.summarize_requests_per_minute);
+    try testing.expectEqual(@as(usize, 20), config.summarize_max_requests);
+}
+
+test "config parse architecture section" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var config = Config.init(allocator);
+    defer config.deinit();
+
+    const toml =
+        \\[architecture]
+        \\layers = ["handler=internal/api,internal/http", "service=internal/service"]
+        \\rules = ["service !-> handler", "handler -> repository via interface"]
+    ;
+
+    try config.parseToml(toml);
+
+    try testing.expectEqual(@as(usize, 2), config.architecture_layers.len);
+    try testing.expectEqualStrings("handler=internal/api,internal/http", config.architecture_layers[0]);
+    try testing.expectEqual(@as(usize, 2), config.architecture_rules.len);
+    try testing.expectEqualStrings("handler -> repository via interface", config.architecture_rules[1]);
+}
+
+test "config parse bench section" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var config = Config.init(allocator);
+    defer config.deinit();
+    try testing.expectEqual(@as(f64, 10.0), config.bench_tolerance);
+
+    const toml =
+        \\[bench]
+        \\baseline = "benchmarks/v0.9.json"
+        \\tolerance = 7.5
+    ;
+
+    try config.parseToml(toml);
+
+    try testing.expectEqualStrings("benchmarks/v0.9.json", config.bench_baseline.?);
+    try testing.expectEqual(@as(f64, 7.5), config.bench_tolerance);
+}
@@ -0,0 +1,421 @@
+// Constraint set comparison
+// Matches constraints across two extraction runs by a line-independent identity
+// (file, kind, name) and classifies each difference as added, removed,
+// strengthened, or weakened, so reviewers see which contracts a change touches.
+// Edits that loosen a check the changed code enforced before (a dropped
+// bounds check, a removed Validate call) are a separate, high-severity class.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+
+pub const ChangeKind = enum {
+    removed,
+    weakened,
+    strengthened,
+    added,
+
+    pub fn label(self: ChangeKind) []const u8 {
+        return @tagName(self);
+    }
+};
+
+pub const Change = struct {
+    kind: ChangeKind,
+    before: ?constraint.Constraint = null,
+    after: ?constraint.Constraint = null,
+
+    /// The constraint to describe (the surviving side, or the removed one)
+    pub fn subject(self: Change) constraint.Constraint {
+        return self.after orelse self.before.?;
+    }
+
+    fn lessThan(_: void, a: Change, b: Change) bool {
+        const a_c = a.subject();
+        const b_c = b.subject();
+        const order = std.mem.order(u8, a_c.origin_file orelse "", b_c.origin_file orelse "");
+        if (order != .eq) return order == .lt;
+        if (a.kind != b.kind) return @intFromEnum(a.kind) < @intFromEnum(b.kind);
+        return std.mem.lessThan(u8, a_c.name, b_c.name);
+    }
+};
+
+/// An edit that loosens a check the old code enforced, such as a dropped
+/// `max=50` tag or a Validate call removed before a repository write
+pub const CodeWeakening = struct {
+    file: []const u8,
+    /// What was weakened: `CreateUserRequest.Username`, `Handler.CreateUser`
+    subject: []const u8,
+    message: []const u8,
+    /// Line of the check in the old version, and of the subject in the new one
+    before_line: ?u32 = null,
+    after_line: ?u32 = null,
+};
+
+pub const Diff = struct {
+    allocator: std.mem.Allocator,
+    changes: std.ArrayList(Change),
+    unchanged: usize = 0,
+    /// Set by callers that compare the code itself; borrowed
+    weakened_code: []const CodeWeakening = &.{},
+
+    pub fn deinit(self: *Diff) void {
+        self.changes.deinit(self.allocator);
+    }
+
+    pub fn count(self: *const Diff, kind: ChangeKind) usize {
+        var n: usize = 0;
+        for (self.changes.items) |change| {
+            if (change.kind == kind) n += 1;
+        }
+        return n;
+    }
+};
+
+/// Write the line-independent identity of a constraint. Pattern constraints are
+/// deduplicated per file by kind and name, so this survives code moving around.
+pub fn writeIdentityKey(writer: anytype, c: constraint.Constraint) !void {
+    try writer.print("{s}\x00{s}\x00{s}", .{ c.origin_file orelse "", @tagName(c.kind), c.name });
+}
+
+/// 64-bit hash of the identity key, for fingerprints persisted across runs
+pub fn identityHash(c: constraint.Constraint) u64 {
+    var hasher = std.hash.Wyhash.init(0);
+    hasher.update(c.origin_file orelse "");
+    hasher.update("\x00");
+    hasher.update(@tagName(c.kind));
+    hasher.update("\x00");
+    hasher.update(c.name);
+    return hasher.final();
+}
+
+fn severityRank(severity: constraint.Severity) i32 {
+    return switch (severity) {
+        .hint => 0,
+        .info => 1,
+        .warning => 2,
+        .err => 3,
+    };
+}
+
+/// Positive when `after` is stricter than `before`, negative when looser.
+/// Severity dominates, then priority, then a meaningful confidence shift.
+pub fn compareStrength(before: constraint.Constraint, after: constraint.Constraint) i32 {
+    const severity_delta = severityRank(after.severity) - severityRank(before.severity);
+    if (severity_delta != 0) return severity_delta;
+
+    const priority_delta = @as(i32, @intCast(after.priority.toNumeric())) - @as(i32, @intCast(before.priority.toNumeric()));
+    if (priority_delta != 0) return priority_delta;
+
+    const confidence_delta = after.confidence - before.confidence;
+    if (confidence_delta >= 0.05) return 1;
+    if (confidence_delta <= -0.05) return -1;
+    return 0;
+}
+
+/// Compare two constraint lists. Changes borrow the constraints (and their strings).
+pub fn compute(
+    allocator: std.mem.Allocator,
+    before: []const constraint.Constraint,
+    after: []const constraint.Constraint,
+) !Diff {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var diff = Diff{ .allocator = allocator, .changes = std.ArrayList(Change){} };
+    errdefer diff.deinit();
+
+    // Index the "before" side by identity; repeated identities get an occurrence suffix
+    var before_index = std.StringHashMap(usize).init(arena);
+    var occurrences = std.StringHashMap(usize).init(arena);
+    for (before, 0..) |c, i| {
+        const key = try occurrenceKey(arena, &occurrences, c);
+        try before_index.put(key, i);
+    }
+
+    const matched = try arena.alloc(bool, before.len);
+    @memset(matched, false);
+
+    occurrences.clearRetainingCapacity();
+    for (after) |c| {
+        const key = try occurrenceKey(arena, &occurrences, c);
+        if (before_index.get(key)) |i| {
+            matched[i] = true;
+            const delta = compareStrength(before[i], c);
+            if (delta > 0) {
+                try diff.changes.append(allocator, .{ .kind = .strengthened, .before = before[i], .after = c });
+            } else if (delta < 0) {
+                try diff.changes.append(allocator, .{ .kind = .weakened, .before = before[i], .after = c });
+            } else {
+                diff.unchanged += 1;
+            }
+        } else {
+            try diff.changes.append(allocator, .{ .kind = .added, .after = c });
+        }
+    }
+
+    for (before, matched) |c, was_matched| {
+        if (!was_matched) try diff.changes.append(allocator, .{ .kind = .removed, .before = c });
+    }
+
+    std.mem.sort(Change, diff.changes.items, {}, Change.lessThan);
+    return diff;
+}
+
+fn occurrenceKey(
+    arena: std.mem.Allocator,
+    occurrences: *std.StringHashMap(usize),
+    c: constraint.Constraint,
+) ![]const u8 {
+    var buf = std.ArrayList(u8){};
+    try writeIdentityKey(buf.writer(arena), c);
+    const base = try buf.toOwnedSlice(arena);
+
+    const gop = try occurrences.getOrPut(base);
+    if (!gop.found_existing) gop.value_ptr.* = 0;
+    const n = gop.value_ptr.*;
+    gop.value_ptr.* += 1;
+    return std.fmt.allocPrint(arena, "{s}#{d}", .{ base, n });
+}
+
+pub fn severityLabel(severity: constraint.Severity) []const u8 {
+    return switch (severity) {
+        .err => "error",
+        .warning => "warning",
+        .info => "info",
+        .hint => "hint",
+    };
+}
+
+/// Human-readable diff, grouped by file
+pub fn formatText(allocator: std.mem.Allocator, diff: Diff, before_label: []const u8, after_label: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("Constraint changes {s}..{s}\n", .{ before_label, after_label });
+    try writer.print("  {d} added, {d} removed, {d} strengthened, {d} weakened, {d} unchanged\n", .{
+        diff.count(.added),
+        diff.count(.removed),
+        diff.count(.strengthened),
+        diff.count(.weakened),
+        diff.unchanged,
+    });
+    if (diff.weakened_code.len > 0) {
+        try writer.print("\nWeakened checks in changed code ({d}, high severity)\n", .{diff.weakened_code.len});
+        for (diff.weakened_code) |w| {
+            try writer.print("  ! {s}", .{w.file});
+            if (w.after_line orelse w.before_line) |line| try writer.print(":{d}", .{line});
+            try writer.print(" {s}: {s}\n", .{ w.subject, w.message });
+        }
+    }
+
+    var current_file: ?[]const u8 = null;
+    for (diff.changes.items) |change| {
+        const c = change.subject();
+        const file = c.origin_file orelse "(unknown file)";
+        if (current_file == null or !std.mem.eql(u8, current_file.?, file)) {
+            try writer.print("\n{s}\n", .{file});
+            current_file = file;
+        }
+
+        const marker: []const u8 = switch (change.kind) {
+            .added => "+",
+            .removed => "-",
+            .strengthened => "^",
+            .weakened => "v",
+        };
+        try writer.print("  {s} {s} [{s}] {s}", .{ marker, change.kind.label(), @tagName(c.kind), c.name });
+        if (change.before != null and change.after != null) {
+            const b = change.before.?;
+            const a = change.after.?;
+            try writer.print(" ({s} -> {s}, confidence {d:.2} -> {d:.2})", .{
+                severityLabel(b.severity),
+                severityLabel(a.severity),
+                b.confidence,
+                a.confidence,
+            });
+        }
+        if (c.origin_line) |line| try writer.print(" line {d}", .{line});
+        try writer.writeAll("\n");
+    }
+
+    return list.toOwnedSlice(allocator);
+}
+
+/// Markdown diff suitable for a pull request comment
+pub fn formatMarkdown(allocator: std.mem.Allocator, diff: Diff, before_label: []const u8, after_label: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writeMarkdownSummary(writer, diff, before_label, after_label);
+    if (diff.weakened_code.len > 0) {
+        try writer.writeAll("\n");
+        try writeMarkdownWeakenings(writer, diff.weakened_code);
+    }
+    if (diff.changes.items.len > 0) {
+        try writer.writeAll("\n");
+        try writeMarkdownTable(writer, diff.changes.items);
+    }
+
+    return list.toOwnedSlice(allocator);
+}
+
+/// Markdown heading and table of change counts
+pub fn writeMarkdownSummary(writer: anytype, diff: Diff, before_label: []const u8, after_label: []const u8) !void {
+    try writer.print("### Constraint changes `{s}..{s}`\n\n", .{ before_label, after_label });
+    try writer.print("| Added | Removed | Strengthened | Weakened | Unchanged |\n|---:|---:|---:|---:|---:|\n| {d} | {d} | {d} | {d} | {d} |\n", .{
+        diff.count(.added),
+        diff.count(.removed),
+        diff.count(.strengthened),
+        diff.count(.weakened),
+        diff.unchanged,
+    });
+}
+
+/// Markdown section listing weakened checks, for reviewers to look at first
+pub fn writeMarkdownWeakenings(writer: anytype, weakenings: []const CodeWeakening) !void {
+    try writer.print("#### Weakened checks in changed code ({d}, high severity)\n\n", .{weakenings.len});
+    try writer.writeAll("| File | Subject | Change |\n|---|---|---|\n");
+    for (weakenings) |w| {
+        try writer.print("| `{s}`", .{w.file});
+        if (w.after_line orelse w.before_line) |line| try writer.print(" line {d}", .{line});
+        try writer.writeAll(" | ");
+        try writeCell(writer, w.subject);
+        try writer.writeAll(" | ");
+        try writeCell(writer, w.message);
+        try writer.writeAll(" |\n");
+    }
+}
+
+/// Markdown table with one row per change
+pub fn writeMarkdownTable(writer: anytype, changes: []const Change) !void {
+    try writer.writeAll("| Change | File | Kind | Constraint | Severity |\n|---|---|---|---|---|\n");
+    for (changes) |change| {
+        const c = change.subject();
+        try writer.print("| {s} | `{s}` | {s} | ", .{ change.kind.label(), c.origin_file orelse "", @tagName(c.kind) });
+        try writeCell(writer, c.name);
+        if (change.before != null and change.after != null) {
+            try writer.print(" | {s} → {s} |\n", .{ severityLabel(change.before.?.severity), severityLabel(change.after.?.severity) });
+        } else {
+            try writer.print(" | {s} |\n", .{severityLabel(c.severity)});
+        }
+    }
+}
+
+fn writeCell(writer: anytype, s: []const u8) !void {
+    for (s) |ch| {
+        switch (ch) {
+            '|' => try writer.writeAll("\\|"),
+            '\n', '\r' => try writer.writeByte(' '),
+            else => try writer.writeByte(ch),
+        }
+    }
+}
+
+/// Machine-readable diff
+pub fn formatJson(allocator: std.mem.Allocator, diff: Diff, before_label: []const u8, after_label: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("{\n  \"before\": \"");
+    try output.writeJsonEscaped(writer, before_label);
+    try writer.writeAll("\",\n  \"after\": \"");
+    try output.writeJsonEscaped(writer, after_label);
+    try writer.print("\",\n  \"summary\": {{\"added\": {d}, \"removed\": {d}, \"strengthened\": {d}, \"weakened\": {d}, \"unchanged\": {d}, \"weakened_code\": {d}}},\n", .{
+        diff.count(.added),
+        diff.count(.removed),
+        diff.count(.strengthened),
+        diff.count(.weakened),
+        diff.unchanged,
+        diff.weakened_code.len,
+    });
+    try writer.writeAll("  \"weakened_code\": [");
+    for (diff.weakened_code, 0..) |w, i| {
+        try writer.writeAll(if (i == 0) "\n    {\"severity\": \"error\", \"file\": \"" else ",\n    {\"severity\": \"error\", \"file\": \"");
+        try output.writeJsonEscaped(writer, w.file);
+        try writer.writeAll("\", \"subject\": \"");
+        try output.writeJsonEscaped(writer, w.subject);
+        try writer.writeAll("\", \"message\": \"");
+        try output.writeJsonEscaped(writer, w.message);
+        try writer.writeAll("\"");
+        if (w.before_line) |line| try writer.print(", \"before_line\": {d}", .{line});
+        if (w.after_line) |line| try writer.print(", \"after_line\": {d}", .{line});
+        try writer.writeAll("}");
+    }
+    try writer.writeAll(if (diff.weakened_code.len == 0) "],\n" else "\n  ],\n");
+    try writer.writeAll("  \"changes\": [\n");
+    for (diff.changes.items, 0..) |change, i| {
+        try writer.print("    {{\"change\": \"{s}\"", .{change.kind.label()});
+        if (change.before) |b| {
+            try writer.writeAll(", \"before\": ");
+            try writeConstraintJson(writer, b);
+        }
+        if (change.after) |a| {
+            try writer.writeAll(", \"after\": ");
+            try writeConstraintJson(writer, a);
+        }
+        try writer.writeAll("}");
+        if (i + 1 < diff.changes.items.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("  ]\n}\n");
+
+    return list.toOwnedSlice(allocator);
+}
+
+pub fn writeConstraintJson(writer: anytype, c: constraint.Constraint) !void {
+    try writer.print("{{\"id\": {d}, \"name\": \"", .{c.id});
+    try output.writeJsonEscaped(writer, c.name);
+    try writer.writeAll("\", \"description\": \"");
+    try output.writeJsonEscaped(writer, c.description);
+    try writer.print("\", \"kind\": \"{s}\", \"severity\": \"{s}\", \"confidence\": {d:.2}", .{
+        @tagName(c.kind),
+        @tagName(c.severity),
+        c.confidence,
+    });
+    if (c.origin_file) |file| {
+        try writer.writeAll(", \"file\": \"");
+        try output.writeJsonEscaped(writer, file);
+        try writer.writeAll("\"");
+    }
+    if (c.origin_line) |line| try writer.print(", \"line\": {d}", .{line});
+    try writer.writeAll("}");
+}
+
+test "diff matches constraints across line moves" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const before = [_]constraint.Constraint{
+        .{ .name = "null_safety", .description = "at line 3", .kind = .type_safety, .severity = .info, .origin_file = "a.ts", .origin_line = 3 },
+        .{ .name = "sql_params", .description = "x", .kind = .security, .severity = .err, .origin_file = "a.ts" },
+        .{ .name = "retry_budget", .description = "x", .kind = .operational, .severity = .warning, .origin_file = "b.go" },
+    };
+    const after = [_]constraint.Constraint{
+        .{ .name = "null_safety", .description = "at line 9", .kind = .type_safety, .severity = .info, .origin_file = "a.ts", .origin_line = 9 },
+        .{ .name = "sql_params", .description = "x", .kind = .security, .severity = .warning, .origin_file = "a.ts" },
+        .{ .name = "auth_required", .description = "x", .kind = .security, .severity = .err, .origin_file = "b.go" },
+    };
+
+    var diff = try compute(allocator, &before, &after);
+    defer diff.deinit();
+
+    try testing.expectEqual(@as(usize, 1), diff.unchanged);
+    try testing.expectEqual(@as(usize, 1), diff.count(.weakened));
+    try testing.expectEqual(@as(usize, 1), diff.count(.added));
+    try testing.expectEqual(@as(usize, 1), diff.count(.removed));
+
+    diff.weakened_code = &.{.{ .file = "b.go", .subject = "CreateUserRequest.Name", .message = "max length 50 check dropped", .before_line = 12, .after_line = 12 }};
+    const text = try formatJson(allocator, diff, "v1", "v2");
+    defer allocator.free(text);
+    const parsed = try std.json.parseFromSlice(std.json.Value, allocator, text, .{});
+    defer parsed.deinit();
+    try testing.expectEqual(@as(usize, 3), parsed.value.object.get("changes").?.array.items.len);
+    const weakened = parsed.value.object.get("weakened_code").?.array.items;
+    try testing.expectEqual(@as(usize, 1), weakened.len);
+    try testing.expectEqualStrings("error", weakened[0].object.get("severity").?.string);
+}
@@ -0,0 +1,297 @@
+// Constraint verification
+// Checks a fresh extraction against a stored (usually committed) constraint
+// set. A stored constraint that is not found again with at least its strength
+// is reported as weakened (found with lower severity, priority, or
+// confidence), violated (an error-severity constraint whose file is still
+// there is gone), or no longer evidenced (its file was removed, or a
+// lower-severity constraint is gone). Constraints are matched by the
+// line-independent identity of constraint_diff, so moved code still verifies.
+// With review approvals, rejected stored constraints are not verified and new
+// critical constraints nobody has reviewed are reported as unreviewed. The
+// configured architecture rules are reported alongside, with their traces.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const constraint_diff = @import("cli_constraint_diff");
+const approvals_mod = @import("cli_approvals");
+const layering = @import("cli_layering");
+
+pub const Status = enum {
+    violated,
+    weakened,
+    unevidenced,
+    unreviewed,
+
+    pub fn label(self: Status) []const u8 {
+        return switch (self) {
+            .violated => "violated",
+            .weakened => "weakened",
+            .unevidenced => "no longer evidenced",
+            .unreviewed => "unreviewed",
+        };
+    }
+};
+
+/// A stored constraint that no longer holds as stored
+pub const Finding = struct {
+    status: Status,
+    /// The stored constraint; for unreviewed findings, the new one
+    stored: constraint.Constraint,
+    /// The weaker constraint found now
+    current: ?constraint.Constraint = null,
+    /// The stored constraint's file no longer exists
+    file_removed: bool = false,
+};
+
+pub const Report = struct {
+    findings: []const Finding,
+    /// Stored constraints checked
+    stored: usize,
+    /// Stored constraints found again at least as strong
+    held: usize,
+    /// Constraints found now that the stored set lacks, at or above the
+    /// confidence threshold
+    new: usize,
+    /// Stored constraints skipped because review rejected them
+    rejected: usize = 0,
+    /// Architecture rules checked, and their violations
+    architecture_rules: usize = 0,
+    architecture: []const layering.Violation = &.{},
+
+    pub fn count(self: Report, status: Status) usize {
+        var n: usize = 0;
+        for (self.findings) |finding| {
+            if (finding.status == status) n += 1;
+        }
+        return n;
+    }
+
+    /// Violations, unreviewed critical constraints, and architecture rule
+    /// violations fail verification; with `strict`, so does every finding
+    pub fn failed(self: Report, strict: bool) bool {
+        if (self.architecture.len > 0) return true;
+        return if (strict) self.findings.len > 0 else self.count(.violated) + self.count(.unreviewed) > 0;
+    }
+};
+
+/// Check `current` against `stored`. `removed_files` lists the stored
+/// constraints' files that no longer exist. With `approvals`, rejected
+/// constraints are left out on both sides and new critical constraints
+/// without a decision are unreviewed findings. The report lives in `arena`.
+pub fn verify(
+    arena: std.mem.Allocator,
+    stored: []const constraint.Constraint,
+    current: []const constraint.Constraint,
+    removed_files: []const []const u8,
+    confidence_threshold: f32,
+    approvals: ?*const approvals_mod.Approvals,
+) !Report {
+    var checked = std.ArrayList(constraint.Constraint){};
+    var rejected: usize = 0;
+    for (stored) |c| {
+        if (approvals) |a| {
+            if (a.get(c)) |entry| {
+                if (entry.decision == .rejected) {
+                    rejected += 1;
+                    continue;
+                }
+            }
+        }
+        try checked.append(arena, c);
+    }
+
+    var diff = try constraint_diff.compute(arena, checked.items, current);
+    defer diff.deinit();
+
+    var removed = std.StringHashMap(void).init(arena);
+    for (removed_files) |file| try removed.put(file, {});
+
+    var findings = std.ArrayList(Finding){};
+    var report = Report{ .findings = &.{}, .stored = checked.items.len, .held = diff.unchanged, .new = 0, .rejected = rejected };
+    for (diff.changes.items) |change| {
+        switch (change.kind) {
+            .removed => {
+                const c = change.before.?;
+                const file_removed = removed.contains(c.origin_file orelse "");
+                try findings.append(arena, .{
+                    .status = if (!file_removed and c.severity == .err) .violated else .unevidenced,
+                    .stored = c,
+                    .file_removed = file_removed,
+                });
+            },
+            .weakened => try findings.append(arena, .{ .status = .weakened, .stored = change.before.?, .current = change.after }),
+            .strengthened => report.held += 1,
+            .added => {
+                const c = change.after.?;
+                if (c.confidence < confidence_threshold) continue;
+                if (approvals) |a| {
+                    if (a.get(c)) |entry| {
+                        if (entry.decision == .rejected) continue;
+                    } else if (approvals_mod.isCritical(c)) {
+                        try findings.append(arena, .{ .status = .unreviewed, .stored = c });
+                        continue;
+                    }
+                }
+                report.new += 1;
+            },
+        }
+    }
+    report.findings = findings.items;
+    return report;
+}
+
+/// Findings grouped by file, after a summary line
+pub fn formatText(allocator: std.mem.Allocator, report: Report, label: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("Verified {d} stored constraints from {s}\n", .{ report.stored, label });
+    try writer.print("  {d} hold, {d} violated, {d} weakened, {d} no longer evidenced, {d} new\n", .{
+        report.held,
+        report.count(.violated),
+        report.count(.weakened),
+        report.count(.unevidenced),
+        report.new,
+    });
+    if (report.count(.unreviewed) > 0 or report.rejected > 0) {
+        try writer.print("  {d} new critical constraints unreviewed, {d} rejected constraints skipped\n", .{ report.count(.unreviewed), report.rejected });
+    }
+    if (report.architecture_rules > 0) {
+        try writer.print("  {d} architecture rules checked, {d} violations\n", .{ report.architecture_rules, report.architecture.len });
+    }
+
+    var current_file: ?[]const u8 = null;
+    for (report.findings) |finding| {
+        const c = finding.stored;
+        const file = c.origin_file orelse "(unknown file)";
+        if (current_file == null or !std.mem.eql(u8, current_file.?, file)) {
+            try writer.print("\n{s}{s}\n", .{ file, if (finding.file_removed) " (removed)" else "" });
+            current_file = file;
+        }
+
+        const marker: []const u8 = switch (finding.status) {
+            .violated => "x",
+            .weakened => "v",
+            .unevidenced => "-",
+            .unreviewed => "?",
+        };
+        try writer.print("  {s} {s} [{s}] {s} ({s}", .{ marker, finding.status.label(), @tagName(c.kind), c.name, constraint_diff.severityLabel(c.severity) });
+        if (finding.current) |now| {
+            try writer.print(" -> {s}, confidence {d:.2} -> {d:.2}", .{ constraint_diff.severityLabel(now.severity), c.confidence, now.confidence });
+        }
+        try writer.writeAll(")");
+        if (c.origin_line) |line| try writer.print(" line {d}", .{line});
+        try writer.writeAll("\n");
+    }
+
+    if (report.architecture.len > 0) {
+        try writer.writeAll("\nArchitecture rules\n");
+        try layering.writeViolations(writer, report.architecture, "  ");
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+/// Machine-readable report; `passed` applies the same rule as the exit code
+pub fn formatJson(allocator: std.mem.Allocator, report: Report, label: []const u8, strict: bool) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("{\n  \"constraints\": \"");
+    try output.writeJsonEscaped(writer, label);
+    try writer.print("\",\n  \"passed\": {s},\n  \"strict\": {s},\n", .{
+        if (report.failed(strict)) "false" else "true",
+        if (strict) "true" else "false",
+    });
+    try writer.print("  \"summary\": {{\"stored\": {d}, \"held\": {d}, \"violated\": {d}, \"weakened\": {d}, \"unevidenced\": {d}, \"new\": {d}, \"unreviewed\": {d}, \"rejected\": {d}, \"architecture_rules\": {d}, \"architecture_violations\": {d}}},\n", .{
+        report.stored,
+        report.held,
+        report.count(.violated),
+        report.count(.weakened),
+        report.count(.unevidenced),
+        report.new,
+        report.count(.unreviewed),
+        report.rejected,
+        report.architecture_rules,
+        report.architecture.len,
+    });
+    try writer.writeAll("  \"architecture\": ");
+    try layering.writeViolationsJson(writer, report.architecture, "  ");
+    try writer.writeAll(",\n  \"findings\": [\n");
+    for (report.findings, 0..) |finding, i| {
+        try writer.print("    {{\"status\": \"{s}\", \"file_removed\": {s}, \"stored\": ", .{
+            @tagName(finding.status),
+            if (finding.file_removed) "true" else "false",
+        });
+        try constraint_diff.writeConstraintJson(writer, finding.stored);
+        if (finding.current) |now| {
+            try writer.writeAll(", \"current\": ");
+            try constraint_diff.writeConstraintJson(writer, now);
+        }
+        try writer.writeAll("}");
+        if (i + 1 < report.findings.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("  ]\n}\n");
+
+    return list.toOwnedSlice(allocator);
+}
+
+test "verify classifies stored constraints that no longer hold" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const stored = [_]constraint.Constraint{
+        .{ .name = "null_safety", .description = "x", .kind = .type_safety, .severity = .warning, .origin_file = "a.ts", .origin_line = 3 },
+        .{ .name = "sql_params", .description = "x", .kind = .security, .severity = .err, .origin_file = "a.ts" },
+        .{ .name = "retry_budget", .description = "x", .kind = .operational, .severity = .err, .origin_file = "a.ts" },
+        .{ .name = "auth_required", .description = "x", .kind = .security, .severity = .err, .origin_file = "old.go" },
+        .{ .name = "naming", .description = "x", .kind = .syntactic, .severity = .info, .origin_file = "a.ts" },
+    };
+    const current = [_]constraint.Constraint{
+        .{ .name = "null_safety", .description = "x", .kind = .type_safety, .severity = .warning, .origin_file = "a.ts", .origin_line = 8 },
+        .{ .name = "retry_budget", .description = "x", .kind = .operational, .severity = .warning, .origin_file = "a.ts" },
+        .{ .name = "timeouts", .description = "x", .kind = .operational, .severity = .info, .origin_file = "a.ts" },
+        .{ .name = "pooling", .description = "x", .kind = .operational, .severity = .info, .confidence = 0.2, .origin_file = "a.ts" },
+    };
+
+    const report = try verify(arena, &stored, &current, &.{"old.go"}, 0.5, null);
+    try testing.expectEqual(@as(usize, 1), report.held);
+    try testing.expectEqual(@as(usize, 1), report.new);
+    try testing.expectEqual(@as(usize, 1), report.count(.violated));
+    try testing.expectEqual(@as(usize, 1), report.count(.weakened));
+    try testing.expectEqual(@as(usize, 2), report.count(.unevidenced));
+    try testing.expect(report.failed(false));
+
+    var clean = try verify(arena, stored[0..1], &current, &.{}, 0.5, null);
+    try testing.expect(!clean.failed(true));
+    clean.architecture = &.{.{ .rule = "service !-> handler", .package = "internal/service", .message = "imports a handler package", .trace = &.{} }};
+    try testing.expect(clean.failed(false));
+
+    const text = try formatJson(arena, report, "constraints.json", false);
+    const parsed = try std.json.parseFromSliceLeaky(std.json.Value, arena, text, .{});
+    try testing.expectEqual(false, parsed.object.get("passed").?.bool);
+    try testing.expectEqual(@as(usize, 4), parsed.object.get("findings").?.array.items.len);
+
+    // Review decisions: the rejected stored constraint is not verified, the
+    // accepted new one is simply new, the unreviewed critical one fails
+    const rate_limit = constraint.Constraint{ .name = "rate_limit", .description = "x", .kind = .security, .severity = .err, .origin_file = "a.ts" };
+    const csrf_token = constraint.Constraint{ .name = "csrf_token", .description = "x", .kind = .security, .severity = .err, .origin_file = "a.ts" };
+    var approvals = approvals_mod.Approvals.init(testing.allocator);
+    defer approvals.deinit();
+    try approvals.record(stored[1], .rejected, "alice", "2026-10-01", "false positive");
+    try approvals.record(rate_limit, .accepted, "bob", "2026-10-02", null);
+    const reviewed = try verify(arena, &stored, &(current ++ [_]constraint.Constraint{ rate_limit, csrf_token }), &.{"old.go"}, 0.5, &approvals);
+    try testing.expectEqual(@as(usize, 1), reviewed.rejected);
+    try testing.expectEqual(@as(usize, 0), reviewed.count(.violated));
+    try testing.expectEqual(@as(usize, 2), reviewed.new);
+    try testing.expectEqual(@as(usize, 1), reviewed.count(.unreviewed));
+    for (reviewed.findings) |finding| {
+        if (finding.status == .unreviewed) try testing.expectEqualStrings("csrf_token", finding.stored.name);
+    }
+    try testing.expect(reviewed.failed(false));
+}
@@ -0,0 +1,268 @@
+// CycloneDX output format
+// Emits constraints as CycloneDX 1.6 attestation claims with supporting evidence,
+// so constraint evidence can travel alongside an existing SBOM for a release.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const summary_mod = @import("cli_summary");
+
+pub const spec_version = "1.6";
+
+/// Identity of the component the constraints describe
+pub const BomOptions = struct {
+    tool_version: []const u8,
+    component_name: []const u8 = "unknown",
+    component_version: ?[]const u8 = null,
+    /// Unix timestamp recorded in metadata (0 = now)
+    timestamp: i64 = 0,
+};
+
+/// Format constraints as a CycloneDX JSON BOM.
+/// Analyzed files become `file` components; each constraint becomes a claim
+/// targeting its file, backed by an evidence record carrying the constraint fields.
+/// Claims and evidence are referenced by constraint id. Ids are content hashes,
+/// so identical constraints share one; repeats get a `-<n>` suffix to keep
+/// every bom-ref unique, as the schema requires.
+pub fn formatCycloneDx(
+    allocator: std.mem.Allocator,
+    constraint_set: constraint.ConstraintSet,
+    files: []const summary_mod.FileInfo,
+    options: BomOptions,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    var uuid_bytes: [16]u8 = undefined;
+    std.crypto.random.bytes(&uuid_bytes);
+    uuid_bytes[6] = (uuid_bytes[6] & 0x0f) | 0x40; // version 4
+    uuid_bytes[8] = (uuid_bytes[8] & 0x3f) | 0x80; // RFC 4122 variant
+    const hex = std.fmt.bytesToHex(uuid_bytes, .lower);
+
+    try writer.writeAll("{\n");
+    try writer.writeAll("  \"bomFormat\": \"CycloneDX\",\n");
+    try writer.print("  \"specVersion\": \"{s}\",\n", .{spec_version});
+    try writer.print("  \"serialNumber\": \"urn:uuid:{s}-{s}-{s}-{s}-{s}\",\n", .{
+        hex[0..8], hex[8..12], hex[12..16], hex[16..20], hex[20..32],
+    });
+    try writer.writeAll("  \"version\": 1,\n");
+
+    // Metadata: timestamp, tool, and the described component
+    try writer.writeAll("  \"metadata\": {\n");
+    try writer.writeAll("    \"timestamp\": \"");
+    try writeIso8601(writer, if (options.timestamp != 0) options.timestamp else std.time.timestamp());
+    try writer.writeAll("\",\n");
+    try writer.writeAll("    \"tools\": {\"components\": [{\"type\": \"application\", \"name\": \"ananke\", ");
+    try writer.print("\"version\": \"{s}\"}}]}},\n", .{options.tool_version});
+    try writer.writeAll("    \"component\": {\"type\": \"application\", \"bom-ref\": \"component\", \"name\": \"");
+    try output.writeJsonEscaped(writer, options.component_name);
+    try writer.writeAll("\"");
+    if (options.component_version) |v| {
+        try writer.writeAll(", \"version\": \"");
+        try output.writeJsonEscaped(writer, v);
+        try writer.writeAll("\"");
+    }
+    try writer.writeAll("},\n");
+    try writer.print("    \"properties\": [{{\"name\": \"ananke:constraint-count\", \"value\": \"{d}\"}}]\n", .{
+        constraint_set.constraints.items.len,
+    });
+    try writer.writeAll("  },\n");
+
+    // Components: one per analyzed file
+    var seen_files = std.StringHashMap(void).init(allocator);
+    defer seen_files.deinit();
+    try writer.writeAll("  \"components\": [\n");
+    var first_file = true;
+    for (files) |f| {
+        if ((try seen_files.getOrPut(f.path)).found_existing) continue;
+        if (!first_file) try writer.writeAll(",\n");
+        first_file = false;
+        try writer.writeAll("    {\"type\": \"file\", \"bom-ref\": \"file:");
+        try output.writeJsonEscaped(writer, f.path);
+        try writer.writeAll("\", \"name\": \"");
+        try output.writeJsonEscaped(writer, f.path);
+        try writer.writeAll("\"");
+        if (f.content_hash) |digest| {
+            try writer.print(", \"hashes\": [{{\"alg\": \"SHA-256\", \"content\": \"{s}\"}}]", .{digest});
+        }
+        try writer.print(", \"properties\": [{{\"name\": \"ananke:language\", \"value\": \"{s}\"}}]}}", .{f.language});
+    }
+    if (!first_file) try writer.writeAll("\n");
+    try writer.writeAll("  ],\n");
+
+    // Declarations: claims (one per constraint) and their evidence
+    const items = constraint_set.constraints.items;
+    const refs = try refSuffixes(allocator, items);
+    defer allocator.free(refs);
+    try writer.writeAll("  \"declarations\": {\n");
+    try writer.writeAll("    \"claims\": [\n");
+    for (items, 0..) |c, i| {
+        try writer.writeAll("      {\"bom-ref\": \"");
+        try writeRef(writer, "claim", c.id, refs[i]);
+        try writer.writeAll("\", \"target\": \"");
+        if (c.origin_file) |path| {
+            try writer.writeAll("file:");
+            try output.writeJsonEscaped(writer, path);
+        } else {
+            try writer.writeAll("component");
+        }
+        try writer.writeAll("\", \"predicate\": \"");
+        try output.writeJsonEscaped(writer, c.name);
+        try writer.writeAll(": ");
+        try output.writeJsonEscaped(writer, c.description);
+        try writer.writeAll("\", \"evidence\": [\"");
+        try writeRef(writer, "evidence", c.id, refs[i]);
+        try writer.writeAll("\"]}");
+        if (i + 1 < items.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("    ],\n");
+
+    try writer.writeAll("    \"evidence\": [\n");
+    for (items, 0..) |c, i| {
+        try writer.writeAll("      {\"bom-ref\": \"");
+        try writeRef(writer, "evidence", c.id, refs[i]);
+        try writer.print("\", \"propertyName\": \"ananke:{s}\", \"description\": \"", .{@tagName(c.kind)});
+        try output.writeJsonEscaped(writer, c.description);
+        try writer.writeAll("\", \"data\": [{\"name\": \"constraint\", \"contents\": {\"attachment\": {");
+        try writer.writeAll("\"contentType\": \"application/json\", \"content\": \"");
+        try writeConstraintAttachment(writer, c);
+        try writer.writeAll("\"}}}]}");
+        if (i + 1 < items.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("    ]\n");
+    try writer.writeAll("  }\n");
+    try writer.writeAll("}\n");
+
+    return list.toOwnedSlice(allocator);
+}
+
+/// Write a claim or evidence bom-ref; `repeat` counts earlier constraints
+/// with the same id
+fn writeRef(writer: anytype, prefix: []const u8, id: constraint.ConstraintID, repeat: usize) !void {
+    try writer.print("{s}-{d}", .{ prefix, id });
+    if (repeat > 0) try writer.print("-{d}", .{repeat});
+}
+
+/// For each constraint, how many before it share its id
+fn refSuffixes(allocator: std.mem.Allocator, items: []const constraint.Constraint) ![]usize {
+    var counts = std.AutoHashMap(constraint.ConstraintID, usize).init(allocator);
+    defer counts.deinit();
+    const repeats = try allocator.alloc(usize, items.len);
+    errdefer allocator.free(repeats);
+    for (items, repeats) |c, *repeat| {
+        const entry = try counts.getOrPut(c.id);
+        repeat.* = if (entry.found_existing) entry.value_ptr.* else 0;
+        entry.value_ptr.* = repeat.* + 1;
+    }
+    return repeats;
+}
+
+/// Write a constraint's JSON record, itself escaped for embedding in a JSON string
+fn writeConstraintAttachment(writer: anytype, c: constraint.Constraint) !void {
+    var buf: [256]u8 = undefined;
+    const head = try std.fmt.bufPrint(&buf, "{{\"id\": {d}, \"kind\": \"{s}\", \"severity\": \"{s}\", \"source\": \"{s}\", \"confidence\": {d:.2}", .{
+        c.id,
+        @tagName(c.kind),
+        @tagName(c.severity),
+        @tagName(c.source),
+        c.confidence,
+    });
+    try output.writeJsonEscaped(writer, head);
+    if (c.origin_line) |line| {
+        const tail = try std.fmt.bufPrint(&buf, ", \"line\": {d}", .{line});
+        try output.writeJsonEscaped(writer, tail);
+    }
+    try output.writeJsonEscaped(writer, "}");
+}
+
+/// Write a Unix timestamp as an ISO-8601 UTC string
+pub fn writeIso8601(writer: anytype, timestamp: i64) !void {
+    const epoch_seconds = std.time.epoch.EpochSeconds{ .secs = @intCast(@max(timestamp, 0)) };
+    const day = epoch_seconds.getEpochDay();
+    const year_day = day.calculateYearDay();
+    const month_day = year_day.calculateMonthDay();
+    const day_seconds = epoch_seconds.getDaySeconds();
+
+    try writer.print("{d:0>4}-{d:0>2}-{d:0>2}T{d:0>2}:{d:0>2}:{d:0>2}Z", .{
+        year_day.year,
+        month_day.month.numeric(),
+        month_day.day_index + 1,
+        day_seconds.getHoursIntoDay(),
+        day_seconds.getMinutesIntoHour(),
+        day_seconds.getSecondsIntoMinute(),
+    });
+}
+
+test "iso8601 formatting" {
+    const testing = std.testing;
+    var buf: [32]u8 = undefined;
+    var fbs = std.io.fixedBufferStream(&buf);
+    try writeIso8601(fbs.writer(), 1_700_000_000);
+    try testing.expectEqualStrings("2023-11-14T22:13:20Z", fbs.getWritten());
+}
+
+test "cyclonedx document shape" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var set = constraint.ConstraintSet.init(allocator, "test");
+    defer set.deinit();
+    try set.add(.{ .name = "no_plaintext", .description = "Passwords are hashed", .kind = .security, .severity = .err, .origin_file = "auth.go" });
+
+    const files = [_]summary_mod.FileInfo{.{ .path = "auth.go", .language = "go", .line_count = 1 }};
+    const text = try formatCycloneDx(allocator, set, &files, .{ .tool_version = "0.0.0", .component_name = "svc" });
+    defer allocator.free(text);
+
+    const parsed = try std.json.parseFromSlice(std.json.Value, allocator, text, .{});
+    defer parsed.deinit();
+    const root = parsed.value.object;
+    try testing.expectEqualStrings("CycloneDX", root.get("bomFormat").?.string);
+    const claims = root.get("declarations").?.object.get("claims").?.array;
+    try testing.expectEqual(@as(usize, 1), claims.items.len);
+    try testing.expectEqualStrings("file:auth.go", claims.items[0].object.get("target").?.string);
+}
+
+test "cyclonedx bom-refs stay unique for identical constraints" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var set = constraint.ConstraintSet.init(allocator, "test");
+    defer set.deinit();
+    const twin = constraint.Constraint{ .name = "no_plaintext", .description = "Passwords are hashed", .kind = .security, .origin_file = "auth.go" };
+    try set.add(twin);
+    try set.add(twin);
+    try testing.expectEqual(set.constraints.items[0].id, set.constraints.items[1].id);
+
+    const files = [_]summary_mod.FileInfo{
+        .{ .path = "auth.go", .language = "go", .line_count = 1 },
+        .{ .path = "auth.go", .language = "go", .line_count = 1 },
+    };
+    const text = try formatCycloneDx(allocator, set, &files, .{ .tool_version = "0.0.0" });
+    defer allocator.free(text);
+
+    const parsed = try std.json.parseFromSlice(std.json.Value, allocator, text, .{});
+    defer parsed.deinit();
+    var refs = std.StringHashMap(void).init(allocator);
+    defer refs.deinit();
+    const root = parsed.value.object;
+    const declarations = root.get("declarations").?.object;
+    const components = root.get("components").?.array.items;
+    try testing.expectEqual(@as(usize, 1), components.len);
+    for ([_][]const std.json.Value{ components, declarations.get("claims").?.array.items, declarations.get("evidence").?.array.items }) |entries| {
+        for (entries) |entry| {
+            const ref = entry.object.get("bom-ref").?.string;
+            try testing.expect(!(try refs.getOrPut(ref)).found_existing);
+        }
+    }
+    try testing.expectEqual(@as(usize, 5), refs.count());
+
+    // Each claim still points at its own evidence
+    const claims = declarations.get("claims").?.array.items;
+    try testing.expectEqualStrings(
+        claims[1].object.get("evidence").?.array.items[0].string,
+        declarations.get("evidence").?.array.items[1].object.get("bom-ref").?.string,
+    );
+}
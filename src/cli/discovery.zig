@@ -0,0 +1,1052 @@
+// Source file discovery for directory extraction
+// Walks a directory tree honoring .gitignore files and explicit exclude
+// patterns, so vendored dependencies, generated code, and test fixtures do not
+// pollute extraction results. As with git, the .gitignore files of the
+// directories above the root, up to the top of the checkout, apply as well.
+//
+// Patterns use .gitignore syntax:
+//   vendor/              directory named vendor at any depth
+//   *.pb.go              file name glob at any depth
+//   test/fixtures        path relative to the walk root (contains a slash)
+//   /build               anchored to the walk root
+//   **/generated/**      `**` crosses directory boundaries
+//   !keep.go             negation; the last matching pattern wins
+const std = @import("std");
+
+/// Excludes applied unless disabled with `use_default_excludes = false`
+pub const default_excludes = [_][]const u8{
+    ".git/",
+    "node_modules/",
+    "vendor/",
+    "zig-out/",
+    ".zig-cache/",
+};
+
+pub const Options = struct {
+    /// Extra exclude patterns, relative to the walk root
+    excludes: []const []const u8 = &.{},
+    /// Honor .gitignore files found while walking
+    use_gitignore: bool = true,
+    /// Apply `default_excludes`
+    use_default_excludes: bool = true,
+    /// Only keep files in this language (null = every supported language)
+    language: ?[]const u8 = null,
+};
+
+pub const SkipReason = enum {
+    excluded,
+    gitignored,
+    unsupported_language,
+    symlink,
+};
+
+pub const DiscoveredFile = struct {
+    path: []const u8,
+    language: []const u8,
+};
+
+/// A source file loaded into memory, ready for extraction
+pub const SourceFile = struct {
+    path: []const u8,
+    language: []const u8,
+    source: []const u8,
+};
+
+pub const SkippedPath = struct {
+    path: []const u8,
+    is_dir: bool,
+    reason: SkipReason,
+    /// Exclude or .gitignore pattern that matched, for `excluded` and `gitignored`.
+    /// Exclude patterns borrow from `Options.excludes`.
+    pattern: ?Pattern = null,
+};
+
+/// A directory read by the walk, with the modification times that show
+/// whether its entries or its .gitignore rules may have changed since
+pub const WalkedDir = struct {
+    /// Relative to the walk root; "" for the root itself
+    path: []const u8,
+    mtime: i128,
+    /// Null when the directory had no .gitignore or gitignores were not read
+    gitignore_mtime: ?i128 = null,
+};
+
+/// A .gitignore location between the walk root and the top of the git
+/// checkout containing it, whose rules apply to the walk
+pub const AncestorIgnore = struct {
+    /// Relative to the walk root, such as "../.gitignore"
+    path: []const u8,
+    /// Null when there is no .gitignore there
+    mtime: ?i128,
+};
+
+/// Files selected for extraction plus everything that was skipped and why.
+/// All paths are owned by the set's arena.
+pub const FileSet = struct {
+    allocator: std.mem.Allocator,
+    arena: std.heap.ArenaAllocator,
+    files: std.ArrayList(DiscoveredFile),
+    skipped: std.ArrayList(SkippedPath),
+    /// Directories `discover` read, in walk order; empty for other sources
+    walked: std.ArrayList(WalkedDir),
+    /// .gitignore locations above the root `discover` read, nearest first
+    ancestor_ignores: std.ArrayList(AncestorIgnore),
+
+    pub fn init(allocator: std.mem.Allocator) FileSet {
+        return .{
+            .allocator = allocator,
+            .arena = std.heap.ArenaAllocator.init(allocator),
+            .files = std.ArrayList(DiscoveredFile){},
+            .skipped = std.ArrayList(SkippedPath){},
+            .walked = std.ArrayList(WalkedDir){},
+            .ancestor_ignores = std.ArrayList(AncestorIgnore){},
+        };
+    }
+
+    pub fn deinit(self: *FileSet) void {
+        self.files.deinit(self.allocator);
+        self.skipped.deinit(self.allocator);
+        self.walked.deinit(self.allocator);
+        self.ancestor_ignores.deinit(self.allocator);
+        self.arena.deinit();
+    }
+
+    /// Add a single file explicitly (no pattern checks)
+    pub fn addFile(self: *FileSet, path: []const u8, language: []const u8) !void {
+        try self.files.append(self.allocator, .{
+            .path = try self.arena.allocator().dupe(u8, path),
+            .language = language,
+        });
+    }
+
+    /// Number of skipped paths with the given reason
+    pub fn skippedCount(self: *const FileSet, reason: SkipReason) usize {
+        var count: usize = 0;
+        for (self.skipped.items) |s| {
+            if (s.reason == reason) count += 1;
+        }
+        return count;
+    }
+};
+
+/// A single .gitignore-style pattern
+pub const Pattern = struct {
+    /// Directory (relative to the walk root) the pattern was declared in; "" for the root
+    base: []const u8,
+    glob: []const u8,
+    negated: bool = false,
+    dir_only: bool = false,
+    /// Match against the path relative to `base` rather than just the file name
+    anchored: bool = false,
+    /// The pattern as written, for diagnostics
+    text: []const u8 = "",
+    /// The .gitignore declaring the pattern when it lies above the walk root,
+    /// as a path from the working directory; `base` is then relative to the
+    /// top of the checkout instead
+    origin: ?[]const u8 = null,
+
+    /// Parse one pattern line. Returns null for blank lines and comments.
+    pub fn parse(base: []const u8, line: []const u8) ?Pattern {
+        var text = std.mem.trim(u8, line, " \t\r");
+        if (text.len == 0 or text[0] == '#') return null;
+
+        var pattern = Pattern{ .base = base, .glob = text, .text = text };
+        if (text[0] == '!') {
+            pattern.negated = true;
+            text = text[1..];
+        }
+        if (text.len > 0 and text[text.len - 1] == '/') {
+            pattern.dir_only = true;
+            text = text[0 .. text.len - 1];
+        }
+        if (text.len > 0 and text[0] == '/') {
+            pattern.anchored = true;
+            text = text[1..];
+        } else if (std.mem.indexOfScalar(u8, text, '/') != null) {
+            pattern.anchored = true;
+        }
+        if (text.len == 0) return null;
+
+        pattern.glob = text;
+        return pattern;
+    }
+
+    /// Check a path relative to the walk root
+    pub fn matches(self: Pattern, path: []const u8, is_dir: bool) bool {
+        if (self.dir_only and !is_dir) return false;
+
+        var rel = path;
+        if (self.base.len > 0) {
+            if (!std.mem.startsWith(u8, path, self.base) or
+                path.len <= self.base.len or
+                path[self.base.len] != '/') return false;
+            rel = path[self.base.len + 1 ..];
+        }
+
+        if (self.anchored) return globMatch(self.glob, rel);
+        return globMatch(self.glob, std.fs.path.basename(rel));
+    }
+};
+
+/// Evaluate patterns in order; the last match decides (negations re-include)
+pub fn isIgnored(patterns: []const Pattern, path: []const u8, is_dir: bool) bool {
+    return ignoringPattern(patterns, path, is_dir) != null;
+}
+
+/// The pattern that causes `path` to be ignored, or null if it is kept
+pub fn ignoringPattern(patterns: []const Pattern, path: []const u8, is_dir: bool) ?Pattern {
+    var last: ?Pattern = null;
+    for (patterns) |p| {
+        if (p.matches(path, is_dir)) last = p;
+    }
+    const decisive = last orelse return null;
+    return if (decisive.negated) null else decisive;
+}
+
+/// Check a file path and each of its parent directories, so directory
+/// patterns apply to paths that were never walked (e.g. a git tree listing)
+pub fn isExcludedPath(patterns: []const Pattern, path: []const u8) bool {
+    var i: usize = 0;
+    while (std.mem.indexOfScalarPos(u8, path, i, '/')) |slash| : (i = slash + 1) {
+        if (isIgnored(patterns, path[0..slash], true)) return true;
+    }
+    return isIgnored(patterns, path, false);
+}
+
+/// Default (unless disabled) and explicit excludes as parsed patterns.
+/// Patterns borrow from `options.excludes`.
+pub fn excludePatterns(allocator: std.mem.Allocator, options: Options) !std.ArrayList(Pattern) {
+    var patterns = std.ArrayList(Pattern){};
+    errdefer patterns.deinit(allocator);
+    if (options.use_default_excludes) {
+        for (default_excludes) |line| {
+            if (Pattern.parse("", line)) |p| try patterns.append(allocator, p);
+        }
+    }
+    for (options.excludes) |line| {
+        if (Pattern.parse("", line)) |p| try patterns.append(allocator, p);
+    }
+    return patterns;
+}
+
+/// Whether a language passes the `options.language` filter
+pub fn wantsLanguage(options: Options, language: []const u8) bool {
+    if (options.language) |only| return std.mem.eql(u8, language, only);
+    return !std.mem.eql(u8, language, "unknown");
+}
+
+/// Glob match supporting `*`, `?`, `[...]` classes, `\` escapes, and `**`.
+/// `*` and `?` never match `/`; `**` matches across directories.
+pub fn globMatch(pattern: []const u8, text: []const u8) bool {
+    var pi: usize = 0;
+    var ti: usize = 0;
+
+    while (pi < pattern.len) {
+        const c = pattern[pi];
+        switch (c) {
+            '*' => {
+                if (pi + 1 < pattern.len and pattern[pi + 1] == '*') {
+                    var rest = pattern[pi + 2 ..];
+                    if (rest.len > 0 and rest[0] == '/') rest = rest[1..];
+                    if (rest.len == 0) return true;
+                    var k = ti;
+                    while (k <= text.len) : (k += 1) {
+                        if ((k == ti or text[k - 1] == '/') and globMatch(rest, text[k..])) return true;
+                    }
+                    return false;
+                }
+
+                const rest = pattern[pi + 1 ..];
+                var k = ti;
+                while (true) : (k += 1) {
+                    if (globMatch(rest, text[k..])) return true;
+                    if (k >= text.len or text[k] == '/') return false;
+                }
+            },
+            '?' => {
+                if (ti >= text.len or text[ti] == '/') return false;
+                pi += 1;
+                ti += 1;
+            },
+            '[' => {
+                if (ti >= text.len) return false;
+                const close = std.mem.indexOfScalarPos(u8, pattern, pi + 1, ']') orelse {
+                    // Unterminated class: treat '[' literally
+                    if (text[ti] != '[') return false;
+                    pi += 1;
+                    ti += 1;
+                    continue;
+                };
+                if (!classMatch(pattern[pi + 1 .. close], text[ti])) return false;
+                pi = close + 1;
+                ti += 1;
+            },
+            '\\' => {
+                const literal = if (pi + 1 < pattern.len) pattern[pi + 1] else '\\';
+                if (ti >= text.len or text[ti] != literal) return false;
+                pi += if (pi + 1 < pattern.len) 2 else 1;
+                ti += 1;
+            },
+            else => {
+                if (ti >= text.len or text[ti] != c) return false;
+                pi += 1;
+                ti += 1;
+            },
+        }
+    }
+
+    return ti == text.len;
+}
+
+fn classMatch(class: []const u8, ch: u8) bool {
+    if (ch == '/') return false;
+    var body = class;
+    var negate = false;
+    if (body.len > 0 and (body[0] == '!' or body[0] == '^')) {
+        negate = true;
+        body = body[1..];
+    }
+
+    var matched = false;
+    var i: usize = 0;
+    while (i < body.len) {
+        if (i + 2 < body.len and body[i + 1] == '-') {
+            if (ch >= body[i] and ch <= body[i + 2]) matched = true;
+            i += 3;
+        } else {
+            if (ch == body[i]) matched = true;
+            i += 1;
+        }
+    }
+    return matched != negate;
+}
+
+/// Detect a source language from a file extension ("unknown" if unsupported)
+pub fn detectLanguage(file_path: []const u8) []const u8 {
+    if (std.mem.endsWith(u8, file_path, ".ts") or std.mem.endsWith(u8, file_path, ".tsx")) {
+        return "typescript";
+    } else if (std.mem.endsWith(u8, file_path, ".js") or std.mem.endsWith(u8, file_path, ".jsx")) {
+        return "javascript";
+    } else if (std.mem.endsWith(u8, file_path, ".py")) {
+        return "python";
+    } else if (std.mem.endsWith(u8, file_path, ".rs")) {
+        return "rust";
+    } else if (std.mem.endsWith(u8, file_path, ".go")) {
+        return "go";
+    } else if (std.mem.endsWith(u8, file_path, ".java")) {
+        return "java";
+    } else if (std.mem.endsWith(u8, file_path, ".zig")) {
+        return "zig";
+    } else if (std.mem.endsWith(u8, file_path, ".c")) {
+        return "c";
+    } else if (std.mem.endsWith(u8, file_path, ".cpp") or std.mem.endsWith(u8, file_path, ".cc")) {
+        return "cpp";
+    } else if (std.mem.endsWith(u8, file_path, ".kt") or std.mem.endsWith(u8, file_path, ".kts")) {
+        return "kotlin";
+    } else if (std.mem.endsWith(u8, file_path, ".cs")) {
+        return "csharp";
+    } else if (std.mem.endsWith(u8, file_path, ".rb") or std.mem.endsWith(u8, file_path, ".rake") or std.mem.endsWith(u8, file_path, ".gemspec")) {
+        return "ruby";
+    } else if (std.mem.endsWith(u8, file_path, ".php")) {
+        return "php";
+    } else if (std.mem.endsWith(u8, file_path, ".swift")) {
+        return "swift";
+    }
+    return "unknown";
+}
+
+/// How files recognized as generated code are extracted
+pub const GeneratedMode = enum {
+    /// Like any other file
+    full,
+    /// With a reduced rule set (see extract's `reduced_kinds`)
+    reduced,
+    /// Not at all; they are left out of the results
+    skip,
+};
+
+/// Leading bytes searched for a generated-code marker
+pub const generated_header_bytes = 2048;
+
+/// File name suffixes of common code generators that do not always write a
+/// marker (protobuf, Dart build_runner)
+const generated_suffixes = [_][]const u8{
+    ".pb.go",
+    ".pb.cc",
+    ".pb.h",
+    "_pb2.py",
+    "_pb2_grpc.py",
+    ".g.dart",
+    ".freezed.dart",
+};
+
+/// Whether `source` looks generated: a header line carrying "@generated",
+/// "<auto-generated", or "generated" alongside "do not edit" (Go's
+/// "Code generated ... DO NOT EDIT." convention and most generators'
+/// variations of it), or a generator's file name suffix
+pub fn isGenerated(path: []const u8, source: []const u8) bool {
+    for (generated_suffixes) |suffix| {
+        if (std.mem.endsWith(u8, path, suffix)) return true;
+    }
+    const header = source[0..@min(source.len, generated_header_bytes)];
+    var lines = std.mem.splitScalar(u8, header, '\n');
+    while (lines.next()) |line| {
+        if (std.mem.indexOf(u8, line, "@generated") != null) return true;
+        if (std.ascii.indexOfIgnoreCase(line, "<auto-generated") != null) return true;
+        if (std.ascii.indexOfIgnoreCase(line, "generated") != null and
+            (std.ascii.indexOfIgnoreCase(line, "do not edit") != null or
+                std.ascii.indexOfIgnoreCase(line, "do not modify") != null))
+        {
+            return true;
+        }
+    }
+    return false;
+}
+
+fn hasGitDir(dir: []const u8) bool {
+    var buf: [std.fs.max_path_bytes]u8 = undefined;
+    const git_path = std.fmt.bufPrint(&buf, "{s}/.git", .{dir}) catch return false;
+    std.fs.accessAbsolute(git_path, .{}) catch return false;
+    return true;
+}
+
+/// `path` with `/` separators, as patterns expect
+fn slashed(path: []u8) ![]const u8 {
+    std.mem.replaceScalar(u8, path, '\\', '/');
+    return path;
+}
+
+/// Discover supported source files under `root`, sorted by path
+pub fn discover(allocator: std.mem.Allocator, root: []const u8, options: Options) !FileSet {
+    var set = FileSet.init(allocator);
+    errdefer set.deinit();
+
+    var walker = Walker{
+        .allocator = allocator,
+        .arena = set.arena.allocator(),
+        .options = options,
+        .root = std.mem.trimRight(u8, root, "/"),
+        .set = &set,
+        .excludes = try excludePatterns(allocator, options),
+    };
+    defer walker.excludes.deinit(allocator);
+    defer walker.ignore_rules.deinit(allocator);
+    defer walker.ancestor_rules.deinit(allocator);
+    if (options.use_gitignore) try walker.loadAncestorGitignores();
+
+    var dir = try std.fs.cwd().openDir(root, .{ .iterate = true });
+    defer dir.close();
+    try walker.walk(dir, "");
+
+    std.mem.sort(DiscoveredFile, set.files.items, {}, struct {
+        fn lessThan(_: void, a: DiscoveredFile, b: DiscoveredFile) bool {
+            return std.mem.lessThan(u8, a.path, b.path);
+        }
+    }.lessThan);
+
+    return set;
+}
+
+/// Build a file set from an explicit list of paths (e.g. the files changed
+/// since a git ref) instead of walking `root`. Paths are relative to the
+/// current directory; those outside `root`, excluded, or in an unsupported
+/// language are skipped as a walk would skip them, and paths that are no
+/// longer regular files are dropped. .gitignore files are not read: the list
+/// comes from git, which has already applied them.
+pub fn discoverListed(
+    allocator: std.mem.Allocator,
+    root: []const u8,
+    paths: []const []const u8,
+    options: Options,
+) !FileSet {
+    var set = FileSet.init(allocator);
+    errdefer set.deinit();
+    const arena = set.arena.allocator();
+
+    var excludes = try excludePatterns(allocator, options);
+    defer excludes.deinit(allocator);
+
+    const base = std.mem.trimRight(u8, root, "/");
+    var prefix = base;
+    while (std.mem.startsWith(u8, prefix, "./")) prefix = prefix[2..];
+    if (std.mem.eql(u8, prefix, ".")) prefix = "";
+
+    for (paths) |path| {
+        const rel = if (prefix.len == 0)
+            path
+        else if (path.len > prefix.len and std.mem.startsWith(u8, path, prefix) and path[prefix.len] == '/')
+            path[prefix.len + 1 ..]
+        else
+            continue;
+        const display = if (prefix.len == 0)
+            try arena.dupe(u8, rel)
+        else
+            try std.fmt.allocPrint(arena, "{s}/{s}", .{ base, rel });
+
+        if (isExcludedPath(excludes.items, rel)) {
+            try set.skipped.append(allocator, .{ .path = display, .is_dir = false, .reason = .excluded });
+            continue;
+        }
+        const stat = std.fs.cwd().statFile(display) catch continue;
+        if (stat.kind != .file) continue;
+        const language = detectLanguage(rel);
+        if (!wantsLanguage(options, language)) {
+            try set.skipped.append(allocator, .{ .path = display, .is_dir = false, .reason = .unsupported_language });
+            continue;
+        }
+        try set.files.append(allocator, .{ .path = display, .language = language });
+    }
+
+    std.mem.sort(DiscoveredFile, set.files.items, {}, struct {
+        fn lessThan(_: void, a: DiscoveredFile, b: DiscoveredFile) bool {
+            return std.mem.lessThan(u8, a.path, b.path);
+        }
+    }.lessThan);
+
+    return set;
+}
+
+const Walker = struct {
+    allocator: std.mem.Allocator,
+    /// Backing storage for paths and .gitignore contents
+    arena: std.mem.Allocator,
+    options: Options,
+    root: []const u8,
+    set: *FileSet,
+    /// Explicit and default excludes; always win over .gitignore negations
+    excludes: std.ArrayList(Pattern) = .{},
+    /// .gitignore rules in scope for the directory being walked
+    ignore_rules: std.ArrayList(Pattern) = .{},
+    /// Rules of the .gitignore files above the root, top of the checkout first
+    ancestor_rules: std.ArrayList(Pattern) = .{},
+    /// The root relative to the top of the checkout, when rules above it apply
+    repo_prefix: []const u8 = "",
+
+    const Entry = struct {
+        name: []const u8,
+        kind: std.fs.File.Kind,
+
+        fn lessThan(_: void, a: Entry, b: Entry) bool {
+            return std.mem.lessThan(u8, a.name, b.name);
+        }
+    };
+
+    fn walk(self: *Walker, dir: std.fs.Dir, rel_dir: []const u8) !void {
+        // Rules from this directory's .gitignore only apply beneath it
+        const saved_rules = self.ignore_rules.items.len;
+        defer self.ignore_rules.shrinkRetainingCapacity(saved_rules);
+        // Stat before reading so a change made during the walk shows up as a
+        // newer mtime than the one recorded
+        const stat = try dir.stat();
+        const gitignore_mtime = if (self.options.use_gitignore) try self.loadGitignore(dir, rel_dir) else null;
+        try self.set.walked.append(self.allocator, .{
+            .path = rel_dir,
+            .mtime = stat.mtime,
+            .gitignore_mtime = gitignore_mtime,
+        });
+
+        // Sort entries so discovery order is deterministic across filesystems
+        var entries = std.ArrayList(Entry){};
+        defer entries.deinit(self.allocator);
+        var it = dir.iterate();
+        while (try it.next()) |entry| {
+            try entries.append(self.allocator, .{
+                .name = try self.arena.dupe(u8, entry.name),
+                .kind = entry.kind,
+            });
+        }
+        std.mem.sort(Entry, entries.items, {}, Entry.lessThan);
+
+        for (entries.items) |entry| {
+            const rel = if (rel_dir.len == 0)
+                entry.name
+            else
+                try std.fmt.allocPrint(self.arena, "{s}/{s}", .{ rel_dir, entry.name });
+            const is_dir = entry.kind == .directory;
+
+            if (entry.kind == .sym_link) {
+                try self.skip(rel, false, .symlink, null);
+                continue;
+            }
+            if (!is_dir and entry.kind != .file) continue;
+
+            if (ignoringPattern(self.excludes.items, rel, is_dir)) |p| {
+                try self.skip(rel, is_dir, .excluded, p);
+                continue;
+            }
+            if (try self.gitignored(rel, is_dir)) |p| {
+                try self.skip(rel, is_dir, .gitignored, p);
+                continue;
+            }
+
+            if (is_dir) {
+                var sub = dir.openDir(entry.name, .{ .iterate = true }) catch |err| switch (err) {
+                    error.AccessDenied => continue,
+                    else => return err,
+                };
+                defer sub.close();
+                try self.walk(sub, rel);
+                continue;
+            }
+
+            const language = detectLanguage(entry.name);
+            if (!wantsLanguage(self.options, language)) {
+                try self.skip(rel, false, .unsupported_language, null);
+                continue;
+            }
+
+            try self.set.files.append(self.allocator, .{
+                .path = try self.displayPath(rel),
+                .language = language,
+            });
+        }
+    }
+
+    /// Add the rules of `dir`'s .gitignore and return its mtime, or null
+    /// when there is none
+    fn loadGitignore(self: *Walker, dir: std.fs.Dir, rel_dir: []const u8) !?i128 {
+        const file = dir.openFile(".gitignore", .{}) catch |err| switch (err) {
+            error.FileNotFound => return null,
+            else => return err,
+        };
+        defer file.close();
+        const stat = try file.stat();
+        const content = try file.readToEndAlloc(self.arena, 1024 * 1024);
+        var lines = std.mem.splitScalar(u8, content, '\n');
+        while (lines.next()) |line| {
+            if (Pattern.parse(rel_dir, line)) |p| try self.ignore_rules.append(self.allocator, p);
+        }
+        return stat.mtime;
+    }
+
+    /// Read the .gitignore files of the directories above the root, from the
+    /// top of the git checkout down. Outside a checkout there are none.
+    fn loadAncestorGitignores(self: *Walker) !void {
+        const absolute = std.fs.cwd().realpathAlloc(self.arena, if (self.root.len == 0) "." else self.root) catch return;
+        // Directories above the root, nearest first, up to the one holding .git
+        var ancestors = std.ArrayList([]const u8){};
+        defer ancestors.deinit(self.allocator);
+        var dir: []const u8 = absolute;
+        while (!hasGitDir(dir)) {
+            dir = std.fs.path.dirname(dir) orelse return;
+            try ancestors.append(self.allocator, dir);
+        }
+        if (ancestors.items.len == 0) return;
+
+        const top = ancestors.items[ancestors.items.len - 1];
+        self.repo_prefix = try slashed(try std.fs.path.relative(self.arena, top, absolute));
+        var rel_path = std.ArrayList(u8){};
+        for (0..ancestors.items.len) |_| try rel_path.appendSlice(self.arena, "../");
+        try rel_path.appendSlice(self.arena, ".gitignore");
+
+        var up = ancestors.items.len;
+        while (up > 0) {
+            up -= 1;
+            // "../" once per level between this ancestor and the root
+            const path = rel_path.items[3 * (ancestors.items.len - 1 - up) ..];
+            const ancestor = ancestors.items[up];
+            const file_path = try std.fs.path.join(self.arena, &.{ ancestor, ".gitignore" });
+            const mtime = try self.readAncestorGitignore(file_path, try slashed(try std.fs.path.relative(self.arena, top, ancestor)), try std.fs.path.resolve(self.arena, &.{ if (self.root.len == 0) "." else self.root, path }));
+            try self.set.ancestor_ignores.append(self.allocator, .{ .path = path, .mtime = mtime });
+        }
+        std.mem.reverse(AncestorIgnore, self.set.ancestor_ignores.items);
+    }
+
+    /// Add the rules of the .gitignore at `file_path`, declared in `base`
+    /// relative to the top of the checkout; its mtime, or null without one
+    fn readAncestorGitignore(self: *Walker, file_path: []const u8, base: []const u8, origin: []const u8) !?i128 {
+        const file = std.fs.openFileAbsolute(file_path, .{}) catch |err| switch (err) {
+            error.FileNotFound => return null,
+            else => return err,
+        };
+        defer file.close();
+        const stat = try file.stat();
+        const content = try file.readToEndAlloc(self.arena, 1024 * 1024);
+        var lines = std.mem.splitScalar(u8, content, '\n');
+        while (lines.next()) |line| {
+            var pattern = Pattern.parse(base, line) orelse continue;
+            pattern.origin = origin;
+            try self.ancestor_rules.append(self.allocator, pattern);
+        }
+        return stat.mtime;
+    }
+
+    /// The .gitignore rule ignoring `rel`. Rules from above the root come
+    /// first, so the walk's own, deeper rules override them as in git.
+    fn gitignored(self: *Walker, rel: []const u8, is_dir: bool) !?Pattern {
+        var last: ?Pattern = null;
+        if (self.ancestor_rules.items.len > 0) {
+            const repo_rel = try std.fmt.allocPrint(self.allocator, "{s}/{s}", .{ self.repo_prefix, rel });
+            defer self.allocator.free(repo_rel);
+            for (self.ancestor_rules.items) |p| {
+                if (p.matches(repo_rel, is_dir)) last = p;
+            }
+        }
+        for (self.ignore_rules.items) |p| {
+            if (p.matches(rel, is_dir)) last = p;
+        }
+        const decisive = last orelse return null;
+        return if (decisive.negated) null else decisive;
+    }
+
+    fn skip(self: *Walker, rel: []const u8, is_dir: bool, reason: SkipReason, pattern: ?Pattern) !void {
+        try self.set.skipped.append(self.allocator, .{
+            .path = try self.displayPath(rel),
+            .is_dir = is_dir,
+            .reason = reason,
+            .pattern = pattern,
+        });
+    }
+
+    /// Path as the user would type it: relative to cwd, prefixed by the root
+    fn displayPath(self: *Walker, rel: []const u8) ![]const u8 {
+        if (self.root.len == 0 or std.mem.eql(u8, self.root, ".")) return rel;
+        return std.fmt.allocPrint(self.arena, "{s}/{s}", .{ self.root, rel });
+    }
+};
+
+/// Why a path looks like it should be excluded from extraction
+pub const SuggestionCategory = enum {
+    vendored,
+    generated,
+    fixtures,
+    build_output,
+
+    pub fn label(self: SuggestionCategory) []const u8 {
+        return switch (self) {
+            .vendored => "vendored dependencies",
+            .generated => "generated code",
+            .fixtures => "test fixtures",
+            .build_output => "build output",
+        };
+    }
+};
+
+/// An exclude pattern suggested from the repository layout
+pub const Suggestion = struct {
+    pattern: []const u8,
+    category: SuggestionCategory,
+    /// Discovered files the pattern would exclude
+    files: usize,
+};
+
+const DirectoryHint = struct {
+    name: []const u8,
+    category: SuggestionCategory,
+};
+
+const directory_hints = [_]DirectoryHint{
+    .{ .name = "third_party", .category = .vendored },
+    .{ .name = "third-party", .category = .vendored },
+    .{ .name = "thirdparty", .category = .vendored },
+    .{ .name = "external", .category = .vendored },
+    .{ .name = "bower_components", .category = .vendored },
+    .{ .name = "Pods", .category = .vendored },
+    .{ .name = "generated", .category = .generated },
+    .{ .name = "__generated__", .category = .generated },
+    .{ .name = "autogen", .category = .generated },
+    .{ .name = "codegen", .category = .generated },
+    .{ .name = "fixtures", .category = .fixtures },
+    .{ .name = "__fixtures__", .category = .fixtures },
+    .{ .name = "testdata", .category = .fixtures },
+    .{ .name = "test_data", .category = .fixtures },
+    .{ .name = "__snapshots__", .category = .fixtures },
+    .{ .name = "dist", .category = .build_output },
+    .{ .name = "build", .category = .build_output },
+    .{ .name = "target", .category = .build_output },
+    .{ .name = ".next", .category = .build_output },
+    .{ .name = "coverage", .category = .build_output },
+};
+
+/// File name globs produced by common code generators
+const generated_file_globs = [_][]const u8{
+    "*.pb.go",
+    "*_pb2.py",
+    "*_pb2_grpc.py",
+    "*_generated.go",
+    "zz_generated.*.go",
+    "*.gen.go",
+    "*.generated.ts",
+    "*.g.cs",
+    "*.designer.cs",
+    "*.min.js",
+};
+
+/// Directory names matched in more places than this collapse to a single
+/// unanchored pattern (e.g. `testdata/`) instead of one pattern per location
+const collapse_threshold = 3;
+
+/// Suggest exclude patterns for vendored, generated, fixture, and build output
+/// paths among already discovered files. Patterns are relative to the walk root
+/// and owned by `arena`.
+pub fn suggestExcludes(arena: std.mem.Allocator, set: *const FileSet, root: []const u8) !std.ArrayList(Suggestion) {
+    var suggestions = std.ArrayList(Suggestion){};
+
+    // Directory hint name -> (directory prefix -> file count)
+    var by_name = std.StringArrayHashMap(std.StringArrayHashMap(usize)).init(arena);
+    var glob_counts = [_]usize{0} ** generated_file_globs.len;
+
+    const prefix = std.mem.trimRight(u8, root, "/");
+    for (set.files.items) |file| {
+        var rel = file.path;
+        if (prefix.len > 0 and !std.mem.eql(u8, prefix, ".") and std.mem.startsWith(u8, rel, prefix) and
+            rel.len > prefix.len and rel[prefix.len] == '/')
+        {
+            rel = rel[prefix.len + 1 ..];
+        }
+
+        // Outermost hinted directory wins, so fixtures inside generated/ count once
+        var offset: usize = 0;
+        var components = std.mem.splitScalar(u8, rel, '/');
+        while (components.next()) |component| {
+            if (components.peek() == null) break; // file name
+            defer offset += component.len + 1;
+            const hint = directoryHint(component) orelse continue;
+            const dirs = try by_name.getOrPut(hint.name);
+            if (!dirs.found_existing) dirs.value_ptr.* = std.StringArrayHashMap(usize).init(arena);
+            const count = try dirs.value_ptr.getOrPut(rel[0 .. offset + component.len]);
+            count.value_ptr.* = if (count.found_existing) count.value_ptr.* + 1 else 1;
+            break;
+        }
+
+        const name = std.fs.path.basename(rel);
+        for (generated_file_globs, 0..) |glob, i| {
+            if (globMatch(glob, name)) {
+                glob_counts[i] += 1;
+                break;
+            }
+        }
+    }
+
+    var it = by_name.iterator();
+    while (it.next()) |entry| {
+        const category = directoryHint(entry.key_ptr.*).?.category;
+        const dirs = entry.value_ptr;
+        if (dirs.count() > collapse_threshold) {
+            var total: usize = 0;
+            for (dirs.values()) |n| total += n;
+            try suggestions.append(arena, .{
+                .pattern = try std.fmt.allocPrint(arena, "{s}/", .{entry.key_ptr.*}),
+                .category = category,
+                .files = total,
+            });
+            continue;
+        }
+        var dir_it = dirs.iterator();
+        while (dir_it.next()) |dir| {
+            try suggestions.append(arena, .{
+                .pattern = try std.fmt.allocPrint(arena, "{s}/", .{dir.key_ptr.*}),
+                .category = category,
+                .files = dir.value_ptr.*,
+            });
+        }
+    }
+
+    for (generated_file_globs, glob_counts) |glob, count| {
+        if (count == 0) continue;
+        try suggestions.append(arena, .{ .pattern = glob, .category = .generated, .files = count });
+    }
+
+    std.mem.sort(Suggestion, suggestions.items, {}, struct {
+        fn lessThan(_: void, a: Suggestion, b: Suggestion) bool {
+            if (a.category != b.category) return @intFromEnum(a.category) < @intFromEnum(b.category);
+            return std.mem.lessThan(u8, a.pattern, b.pattern);
+        }
+    }.lessThan);
+
+    return suggestions;
+}
+
+fn directoryHint(name: []const u8) ?DirectoryHint {
+    for (directory_hints) |hint| {
+        if (std.mem.eql(u8, hint.name, name)) return hint;
+    }
+    return null;
+}
+
+test "glob matching" {
+    const testing = std.testing;
+    try testing.expect(globMatch("*.go", "main.go"));
+    try testing.expect(!globMatch("*.go", "cmd/main.go"));
+    try testing.expect(globMatch("**/*.go", "cmd/main.go"));
+    try testing.expect(globMatch("**/*.go", "main.go"));
+    try testing.expect(globMatch("test/**", "test/fixtures/a.py"));
+    try testing.expect(globMatch("a/**/b", "a/b"));
+    try testing.expect(globMatch("a/**/b", "a/x/y/b"));
+    try testing.expect(globMatch("file?.[ch]", "file1.c"));
+    try testing.expect(!globMatch("file?.[!ch]", "file1.c"));
+    try testing.expect(globMatch("zz_generated_*", "zz_generated_deepcopy.go"));
+}
+
+test "gitignore pattern semantics" {
+    const testing = std.testing;
+
+    const vendor = Pattern.parse("", "vendor/").?;
+    try testing.expect(vendor.matches("vendor", true));
+    try testing.expect(vendor.matches("pkg/vendor", true));
+    try testing.expect(!vendor.matches("vendor", false));
+
+    const fixtures = Pattern.parse("", "test/fixtures").?;
+    try testing.expect(fixtures.matches("test/fixtures", true));
+    try testing.expect(!fixtures.matches("other/test/fixtures", true));
+
+    // Rules from a nested .gitignore are scoped to that directory
+    const nested = Pattern.parse("web", "/dist").?;
+    try testing.expect(nested.matches("web/dist", true));
+    try testing.expect(!nested.matches("dist", true));
+
+    const patterns = [_]Pattern{
+        Pattern.parse("", "*.pb.go").?,
+        Pattern.parse("", "!keep.pb.go").?,
+    };
+    try testing.expect(isIgnored(&patterns, "api/user.pb.go", false));
+    try testing.expect(!isIgnored(&patterns, "api/keep.pb.go", false));
+    try testing.expectEqualStrings("*.pb.go", ignoringPattern(&patterns, "api/user.pb.go", false).?.text);
+    try testing.expect(Pattern.parse("", "# comment") == null);
+
+    const excludes = [_]Pattern{Pattern.parse("", "vendor/").?};
+    try testing.expect(isExcludedPath(&excludes, "pkg/vendor/lib/dep.go"));
+    try testing.expect(!isExcludedPath(&excludes, "pkg/vendored.go"));
+}
+
+test "discover honors gitignore and excludes" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    try tmp.dir.makePath("src");
+    try tmp.dir.makePath("vendor/lib");
+    try tmp.dir.makePath("test/fixtures");
+    try tmp.dir.makePath("gen");
+    try tmp.dir.writeFile(.{ .sub_path = "src/main.go", .data = "package main\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "src/notes.txt", .data = "notes\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "vendor/lib/dep.go", .data = "package lib\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "test/fixtures/sample.py", .data = "x = 1\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "gen/api.go", .data = "package gen\n" });
+    try tmp.dir.writeFile(.{ .sub_path = ".gitignore", .data = "gen/\n" });
+
+    const root = try tmp.dir.realpathAlloc(allocator, ".");
+    defer allocator.free(root);
+
+    var set = try discover(allocator, root, .{ .excludes = &.{"test/fixtures"} });
+    defer set.deinit();
+
+    try testing.expectEqual(@as(usize, 1), set.files.items.len);
+    try testing.expect(std.mem.endsWith(u8, set.files.items[0].path, "src/main.go"));
+    try testing.expectEqualStrings("go", set.files.items[0].language);
+    try testing.expectEqual(@as(usize, 1), set.skippedCount(.gitignored));
+    try testing.expectEqual(@as(usize, 2), set.skippedCount(.excluded));
+}
+
+test "discover applies .gitignore files above the root" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    try tmp.dir.makePath(".git");
+    try tmp.dir.makePath("sub/dir/dist");
+    try tmp.dir.writeFile(.{ .sub_path = ".gitignore", .data = "*.gen.go\ndist/\n/sub/dir/skip.go\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "sub/.gitignore", .data = "!keep.gen.go\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "sub/dir/main.go", .data = "package dir\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "sub/dir/api.gen.go", .data = "package dir\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "sub/dir/keep.gen.go", .data = "package dir\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "sub/dir/skip.go", .data = "package dir\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "sub/dir/dist/out.go", .data = "package dist\n" });
+
+    const root = try tmp.dir.realpathAlloc(allocator, "sub/dir");
+    defer allocator.free(root);
+
+    var set = try discover(allocator, root, .{});
+    defer set.deinit();
+
+    // What `git ls-files` lists from sub/dir
+    try testing.expectEqual(@as(usize, 2), set.files.items.len);
+    try testing.expect(std.mem.endsWith(u8, set.files.items[0].path, "keep.gen.go"));
+    try testing.expect(std.mem.endsWith(u8, set.files.items[1].path, "main.go"));
+    try testing.expectEqual(@as(usize, 3), set.skippedCount(.gitignored));
+    for (set.skipped.items) |skipped| {
+        if (skipped.reason == .gitignored) try testing.expect(skipped.pattern.?.origin != null);
+    }
+    try testing.expectEqual(@as(usize, 2), set.ancestor_ignores.items.len);
+    try testing.expectEqualStrings("../.gitignore", set.ancestor_ignores.items[0].path);
+    try testing.expectEqualStrings("../../.gitignore", set.ancestor_ignores.items[1].path);
+
+    var plain = try discover(allocator, root, .{ .use_gitignore = false });
+    defer plain.deinit();
+    try testing.expectEqual(@as(usize, 5), plain.files.items.len);
+}
+
+test "discoverListed keeps listed files under the root" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    try tmp.dir.makePath("src");
+    try tmp.dir.makePath("vendor/lib");
+    try tmp.dir.writeFile(.{ .sub_path = "src/main.go", .data = "package main\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "src/notes.txt", .data = "notes\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "vendor/lib/dep.go", .data = "package lib\n" });
+
+    const root = try tmp.dir.realpathAlloc(allocator, ".");
+    defer allocator.free(root);
+    const main = try std.fs.path.join(allocator, &.{ root, "src/main.go" });
+    defer allocator.free(main);
+    const notes = try std.fs.path.join(allocator, &.{ root, "src/notes.txt" });
+    defer allocator.free(notes);
+    const dep = try std.fs.path.join(allocator, &.{ root, "vendor/lib/dep.go" });
+    defer allocator.free(dep);
+    const deleted = try std.fs.path.join(allocator, &.{ root, "src/gone.go" });
+    defer allocator.free(deleted);
+
+    const paths = [_][]const u8{ main, notes, dep, deleted, "elsewhere/other.go" };
+    var set = try discoverListed(allocator, root, &paths, .{});
+    defer set.deinit();
+
+    try testing.expectEqual(@as(usize, 1), set.files.items.len);
+    try testing.expectEqualStrings(main, set.files.items[0].path);
+    try testing.expectEqual(@as(usize, 1), set.skippedCount(.excluded));
+    try testing.expectEqual(@as(usize, 1), set.skippedCount(.unsupported_language));
+}
+
+test "generated file markers" {
+    const testing = std.testing;
+
+    try testing.expect(isGenerated("mock_store.go", "// Code generated by MockGen. DO NOT EDIT.\npackage store\n"));
+    try testing.expect(isGenerated("api.ts", "/* eslint-disable */\n// This file was automatically generated. Do not modify.\n"));
+    try testing.expect(isGenerated("Schema.cs", "//------\n// <auto-generated>\n//------\n"));
+    try testing.expect(isGenerated("lib.rs", "// @generated by build.rs\n"));
+    try testing.expect(isGenerated("user.pb.go", "package user\n"));
+
+    try testing.expect(!isGenerated("main.go", "package main\n\n// Do not edit this map without updating the docs\n"));
+    try testing.expect(!isGenerated("ids.py", "# IDs are generated at startup\n"));
+}
+
+test "suggest excludes from repository layout" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var set = FileSet.init(testing.allocator);
+    defer set.deinit();
+    try set.addFile("src/main.go", "go");
+    try set.addFile("api/user.pb.go", "go");
+    try set.addFile("third_party/lib/dep.go", "go");
+    try set.addFile("third_party/lib/util.go", "go");
+    try set.addFile("pkg/parse/testdata/input.go", "go");
+
+    const suggestions = try suggestExcludes(arena, &set, ".");
+
+    try testing.expectEqual(@as(usize, 3), suggestions.items.len);
+    try testing.expectEqualStrings("third_party/", suggestions.items[0].pattern);
+    try testing.expectEqual(@as(usize, 2), suggestions.items[0].files);
+    try testing.expectEqualStrings("*.pb.go", suggestions.items[1].pattern);
+    try testing.expectEqualStrings("pkg/parse/testdata/", suggestions.items[2].pattern);
+    try testing.expectEqual(SuggestionCategory.fixtures, suggestions.items[2].category);
+}
@@ -0,0 +1,305 @@
+// Drift reports
+// Compares two extraction runs package by package (a package is a file's
+// directory) and reports which constraints were added, removed, strengthened,
+// or weakened in each, as JSON following docs/schemas/drift-report.schema.json
+// or as a standalone HTML page. Constraints are matched like `ananke diff`
+// matches them, by file, kind, and name.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const constraint_diff = @import("cli_constraint_diff");
+const summary = @import("cli_summary");
+const report = @import("cli_report");
+
+/// Value of the `schema` field; bumped on incompatible changes
+pub const schema = "ananke-drift-report/1";
+
+const ChangeCounts = std.EnumArray(constraint_diff.ChangeKind, usize);
+
+pub const PackageDrift = struct {
+    package: []const u8,
+    counts: ChangeCounts = ChangeCounts.initFill(0),
+    unchanged: usize = 0,
+    /// Changes of the package's files, by file
+    changes: []const constraint_diff.Change = &.{},
+
+    pub fn drifted(self: PackageDrift) bool {
+        return self.changes.len > 0;
+    }
+};
+
+pub const Report = struct {
+    before: []const u8,
+    after: []const u8,
+    /// Every package with constraints in either run, by name
+    packages: []const PackageDrift,
+
+    pub fn total(self: Report, kind: constraint_diff.ChangeKind) usize {
+        var n: usize = 0;
+        for (self.packages) |pkg| n += pkg.counts.get(kind);
+        return n;
+    }
+
+    pub fn unchanged(self: Report) usize {
+        var n: usize = 0;
+        for (self.packages) |pkg| n += pkg.unchanged;
+        return n;
+    }
+
+    pub fn drifted(self: Report) usize {
+        var n: usize = 0;
+        for (self.packages) |pkg| {
+            if (pkg.drifted()) n += 1;
+        }
+        return n;
+    }
+};
+
+fn packageOf(c: constraint.Constraint) []const u8 {
+    return summary.packageOf(c.origin_file orelse "");
+}
+
+/// Drift from `before` to `after`. The report lives in `arena` and borrows
+/// the constraints.
+pub fn compute(
+    arena: std.mem.Allocator,
+    before: []const constraint.Constraint,
+    after: []const constraint.Constraint,
+    before_label: []const u8,
+    after_label: []const u8,
+) !Report {
+    const diff = try constraint_diff.compute(arena, before, after);
+
+    var index = std.StringArrayHashMap(PackageDrift).init(arena);
+    for ([_][]const constraint.Constraint{ before, after }) |side| {
+        for (side) |c| {
+            const gop = try index.getOrPut(packageOf(c));
+            if (!gop.found_existing) gop.value_ptr.* = .{ .package = gop.key_ptr.* };
+        }
+    }
+    // Every constraint of the after run is unchanged unless a change says otherwise
+    for (after) |c| index.getPtr(packageOf(c)).?.unchanged += 1;
+
+    // Changes stay in diff order (by file) within each package
+    var grouped = std.StringHashMap(std.ArrayList(constraint_diff.Change)).init(arena);
+    for (diff.changes.items) |change| {
+        const pkg = index.getPtr(packageOf(change.subject())).?;
+        pkg.counts.getPtr(change.kind).* += 1;
+        if (change.after != null) pkg.unchanged -= 1;
+        const gop = try grouped.getOrPut(pkg.package);
+        if (!gop.found_existing) gop.value_ptr.* = std.ArrayList(constraint_diff.Change){};
+        try gop.value_ptr.append(arena, change);
+    }
+
+    const packages = index.values();
+    for (packages) |*pkg| {
+        if (grouped.get(pkg.package)) |changes| pkg.changes = changes.items;
+    }
+    std.mem.sort(PackageDrift, packages, {}, lessThanPackage);
+    return .{ .before = before_label, .after = after_label, .packages = packages };
+}
+
+fn lessThanPackage(_: void, a: PackageDrift, b: PackageDrift) bool {
+    return std.mem.lessThan(u8, a.package, b.package);
+}
+
+fn writeCounts(writer: anytype, counts: ChangeCounts, unchanged: usize) !void {
+    try writer.print("{{\"added\": {d}, \"removed\": {d}, \"strengthened\": {d}, \"weakened\": {d}, \"unchanged\": {d}}}", .{
+        counts.get(.added),
+        counts.get(.removed),
+        counts.get(.strengthened),
+        counts.get(.weakened),
+        unchanged,
+    });
+}
+
+/// Machine-readable report, per docs/schemas/drift-report.schema.json
+pub fn formatJson(allocator: std.mem.Allocator, drift: Report) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\n  \"schema\": \"{s}\",\n  \"before\": \"", .{schema});
+    try output.writeJsonEscaped(writer, drift.before);
+    try writer.writeAll("\",\n  \"after\": \"");
+    try output.writeJsonEscaped(writer, drift.after);
+    try writer.writeAll("\",\n  \"summary\": ");
+    var totals = ChangeCounts.initFill(0);
+    for (std.enums.values(constraint_diff.ChangeKind)) |kind| totals.set(kind, drift.total(kind));
+    try writeCounts(writer, totals, drift.unchanged());
+    try writer.print(",\n  \"packages_drifted\": {d},\n  \"packages\": [", .{drift.drifted()});
+
+    for (drift.packages, 0..) |pkg, i| {
+        try writer.writeAll(if (i == 0) "\n" else ",\n");
+        try writer.writeAll("    {\"package\": \"");
+        try output.writeJsonEscaped(writer, pkg.package);
+        try writer.writeAll("\", \"summary\": ");
+        try writeCounts(writer, pkg.counts, pkg.unchanged);
+        try writer.writeAll(", \"changes\": [");
+        for (pkg.changes, 0..) |change, j| {
+            try writer.writeAll(if (j == 0) "\n" else ",\n");
+            try writer.print("      {{\"change\": \"{s}\"", .{change.kind.label()});
+            if (change.before) |b| {
+                try writer.writeAll(", \"before\": ");
+                try constraint_diff.writeConstraintJson(writer, b);
+            }
+            if (change.after) |a| {
+                try writer.writeAll(", \"after\": ");
+                try constraint_diff.writeConstraintJson(writer, a);
+            }
+            try writer.writeAll("}");
+        }
+        try writer.writeAll(if (pkg.changes.len == 0) "]}" else "\n    ]}");
+    }
+    try writer.writeAll(if (drift.packages.len == 0) "]\n}\n" else "\n  ]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// Standalone HTML page: a table of every package's counts, then the
+/// changes of each drifted package
+pub fn formatHtml(allocator: std.mem.Allocator, drift: Report) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>Constraint drift: ");
+    try report.writeHtmlEscaped(writer, drift.before);
+    try writer.writeAll(" to ");
+    try report.writeHtmlEscaped(writer, drift.after);
+    try writer.writeAll("</title>\n");
+    try writer.writeAll(html_style);
+    try writer.writeAll("</head>\n<body>\n<h1>Constraint drift</h1>\n<p><code>");
+    try report.writeHtmlEscaped(writer, drift.before);
+    try writer.writeAll("</code> to <code>");
+    try report.writeHtmlEscaped(writer, drift.after);
+    try writer.print("</code>: {d} of {d} packages drifted. {d} added, {d} removed, {d} strengthened, {d} weakened, {d} unchanged.</p>\n", .{
+        drift.drifted(),
+        drift.packages.len,
+        drift.total(.added),
+        drift.total(.removed),
+        drift.total(.strengthened),
+        drift.total(.weakened),
+        drift.unchanged(),
+    });
+
+    try writer.writeAll("<table>\n<thead><tr><th>Package</th><th>Added</th><th>Removed</th><th>Strengthened</th><th>Weakened</th><th>Unchanged</th></tr></thead>\n<tbody>\n");
+    for (drift.packages, 0..) |pkg, i| {
+        try writer.writeAll("<tr><td>");
+        if (pkg.drifted()) try writer.print("<a href=\"#pkg-{d}\">", .{i});
+        try report.writeHtmlEscaped(writer, pkg.package);
+        if (pkg.drifted()) try writer.writeAll("</a>");
+        try writer.print("</td><td>{d}</td><td class=\"removed\">{d}</td><td>{d}</td><td class=\"weakened\">{d}</td><td>{d}</td></tr>\n", .{
+            pkg.counts.get(.added),
+            pkg.counts.get(.removed),
+            pkg.counts.get(.strengthened),
+            pkg.counts.get(.weakened),
+            pkg.unchanged,
+        });
+    }
+    try writer.writeAll("</tbody>\n</table>\n");
+
+    for (drift.packages, 0..) |pkg, i| {
+        if (!pkg.drifted()) continue;
+        try writer.print("<h2 id=\"pkg-{d}\">", .{i});
+        try report.writeHtmlEscaped(writer, pkg.package);
+        try writer.writeAll("</h2>\n<table>\n<thead><tr><th>Change</th><th>File</th><th>Kind</th><th>Constraint</th><th>Severity</th><th>Confidence</th></tr></thead>\n<tbody>\n");
+        for (pkg.changes) |change| {
+            const c = change.subject();
+            try writer.print("<tr class=\"{s}\"><td>{s}</td><td>", .{ change.kind.label(), change.kind.label() });
+            try report.writeHtmlEscaped(writer, c.origin_file orelse "");
+            if (c.origin_line) |line| try writer.print(":{d}", .{line});
+            try writer.print("</td><td>{s}</td><td>", .{@tagName(c.kind)});
+            try report.writeHtmlEscaped(writer, c.name);
+            try writer.writeAll("</td>");
+            if (change.before != null and change.after != null) {
+                const b = change.before.?;
+                const a = change.after.?;
+                try writer.print("<td>{s} &rarr; {s}</td><td>{d:.2} &rarr; {d:.2}</td></tr>\n", .{
+                    constraint_diff.severityLabel(b.severity),
+                    constraint_diff.severityLabel(a.severity),
+                    b.confidence,
+                    a.confidence,
+                });
+            } else {
+                try writer.print("<td>{s}</td><td>{d:.2}</td></tr>\n", .{ constraint_diff.severityLabel(c.severity), c.confidence });
+            }
+        }
+        try writer.writeAll("</tbody>\n</table>\n");
+    }
+
+    try writer.writeAll("</body>\n</html>\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// One line per drifted package
+pub fn formatText(allocator: std.mem.Allocator, drift: Report) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("Constraint drift {s}..{s}: {d} of {d} packages drifted\n", .{ drift.before, drift.after, drift.drifted(), drift.packages.len });
+    for (drift.packages) |pkg| {
+        if (!pkg.drifted()) continue;
+        try writer.print("  {s}: {d} added, {d} removed, {d} strengthened, {d} weakened\n", .{
+            pkg.package,
+            pkg.counts.get(.added),
+            pkg.counts.get(.removed),
+            pkg.counts.get(.strengthened),
+            pkg.counts.get(.weakened),
+        });
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+const html_style =
+    \\<style>
+    \\body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+    \\table { border-collapse: collapse; width: 100%; margin-bottom: 1.5rem; }
+    \\th, td { border-bottom: 1px solid #ddd; padding: 0.4rem 0.6rem; text-align: left; vertical-align: top; }
+    \\h2 { font-family: ui-monospace, monospace; font-size: 1.1rem; }
+    \\tr.removed td:first-child, td.removed { color: #b00020; }
+    \\tr.weakened td:first-child, td.weakened { color: #b26a00; }
+    \\tr.strengthened td:first-child { color: #26a269; }
+    \\tr.added td:first-child { color: #1a5fb4; }
+    \\</style>
+    \\
+;
+
+test "drift groups changes by package" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const before = [_]constraint.Constraint{
+        .{ .name = "sql_params", .description = "x", .kind = .security, .severity = .err, .origin_file = "svc/db/query.go" },
+        .{ .name = "retry_budget", .description = "x", .kind = .operational, .severity = .err, .origin_file = "svc/db/conn.go" },
+        .{ .name = "null_safety", .description = "x", .kind = .type_safety, .severity = .info, .origin_file = "web/app.ts" },
+    };
+    const after = [_]constraint.Constraint{
+        .{ .name = "retry_budget", .description = "x", .kind = .operational, .severity = .warning, .origin_file = "svc/db/conn.go" },
+        .{ .name = "null_safety", .description = "x", .kind = .type_safety, .severity = .info, .origin_file = "web/app.ts" },
+        .{ .name = "auth_required", .description = "x", .kind = .security, .severity = .err, .origin_file = "svc/api/auth.go" },
+    };
+
+    const drift = try compute(arena, &before, &after, "v1.json", "v2.json");
+    try testing.expectEqual(@as(usize, 3), drift.packages.len);
+    try testing.expectEqualStrings("svc/api", drift.packages[0].package);
+    try testing.expectEqual(@as(usize, 1), drift.packages[0].counts.get(.added));
+    const db = drift.packages[1];
+    try testing.expectEqual(@as(usize, 1), db.counts.get(.removed));
+    try testing.expectEqual(@as(usize, 1), db.counts.get(.weakened));
+    try testing.expectEqual(@as(usize, 0), db.unchanged);
+    try testing.expect(!drift.packages[2].drifted());
+    try testing.expectEqual(@as(usize, 1), drift.packages[2].unchanged);
+    try testing.expectEqual(@as(usize, 2), drift.drifted());
+
+    const text = try formatJson(arena, drift);
+    const parsed = try std.json.parseFromSliceLeaky(std.json.Value, arena, text, .{});
+    try testing.expectEqualStrings(schema, parsed.object.get("schema").?.string);
+    try testing.expectEqual(@as(usize, 2), parsed.object.get("packages").?.array.items[1].object.get("changes").?.array.items.len);
+
+    const html = try formatHtml(arena, drift);
+    try testing.expect(std.mem.indexOf(u8, html, "<h2 id=\"pkg-1\">svc/db</h2>") != null);
+}
@@ -0,0 +1,428 @@
+// Cross-service DTO consistency
+// Services often declare the same logical DTO separately, such as a Go
+// request struct and the TypeScript class or zod schema of its client. DTOs
+// of different services are paired by name, ignoring suffixes like Request,
+// Dto, and Schema, or else by their shape (most field names in common).
+// Their fields are aligned by name, ignoring case and separators, and each
+// rule the two sides disagree on is reported: a username of 3-50 characters
+// on one side and 1-30 on the other will reject each other's values.
+const std = @import("std");
+const go_rules = @import("cli_go_rules");
+const output = @import("cli_output");
+
+/// A DTO with rules and where it was found
+pub const Dto = struct {
+    service: []const u8,
+    file: []const u8,
+    value: go_rules.Struct,
+};
+
+pub const FieldMismatch = struct {
+    /// Field name in the first DTO, or in the second when only it has it
+    field: []const u8,
+    /// "length", "range", "required", "email", "pattern", "one of",
+    /// "contains any", or "field" when one side lacks it
+    rule: []const u8,
+    a: []const u8,
+    b: []const u8,
+};
+
+pub const Match = struct {
+    a: Dto,
+    b: Dto,
+    by_name: bool,
+    mismatches: []const FieldMismatch,
+};
+
+pub const Report = struct {
+    dtos: usize,
+    services: usize,
+    /// Ordered by the first DTO's service, file, and line
+    matches: []const Match,
+
+    pub fn mismatchCount(self: Report) usize {
+        var count: usize = 0;
+        for (self.matches) |m| count += m.mismatches.len;
+        return count;
+    }
+};
+
+const name_suffixes = [_][]const u8{ "schema", "dto", "request", "req", "payload", "body", "input", "model" };
+
+/// Lowercase without separators, so `user_name` and `userName` align
+fn normalizeKey(arena: std.mem.Allocator, name: []const u8) ![]const u8 {
+    var list = std.ArrayList(u8){};
+    for (name) |ch| {
+        if (ch == '_' or ch == '-') continue;
+        try list.append(arena, std.ascii.toLower(ch));
+    }
+    return list.items;
+}
+
+/// The logical name of a DTO: `CreateUserRequest`, `CreateUserDto`, and
+/// `CreateUserSchema` are all `createuser`
+fn logicalName(arena: std.mem.Allocator, name: []const u8) ![]const u8 {
+    var key = try normalizeKey(arena, name);
+    var stripped = true;
+    while (stripped) {
+        stripped = false;
+        for (name_suffixes) |suffix| {
+            if (key.len > suffix.len and std.mem.endsWith(u8, key, suffix)) {
+                key = key[0 .. key.len - suffix.len];
+                stripped = true;
+            }
+        }
+    }
+    return key;
+}
+
+fn fieldKey(arena: std.mem.Allocator, field: go_rules.Field) ![]const u8 {
+    return normalizeKey(arena, field.json_name orelse field.name);
+}
+
+fn findField(arena: std.mem.Allocator, s: go_rules.Struct, key: []const u8) !?go_rules.Field {
+    for (s.fields) |field| {
+        if (std.mem.eql(u8, try fieldKey(arena, field), key)) return field;
+    }
+    return null;
+}
+
+/// Share of the field names of `a` and `b` they have in common
+fn similarity(arena: std.mem.Allocator, a: go_rules.Struct, b: go_rules.Struct) !struct { common: usize, ratio: f64 } {
+    var common: usize = 0;
+    for (a.fields) |field| {
+        if (try findField(arena, b, try fieldKey(arena, field)) != null) common += 1;
+    }
+    const all = a.fields.len + b.fields.len - common;
+    if (all == 0) return .{ .common = 0, .ratio = 0 };
+    return .{ .common = common, .ratio = @as(f64, @floatFromInt(common)) / @as(f64, @floatFromInt(all)) };
+}
+
+/// Least shared fields and share of fields for a match by shape
+const min_common_fields = 3;
+const min_shape_ratio = 0.75;
+
+const Rules = struct {
+    any: bool = false,
+    required: bool = false,
+    min_len: ?i64 = null,
+    max_len: ?i64 = null,
+    min: ?i64 = null,
+    max: ?i64 = null,
+    email: bool = false,
+    pattern: ?[]const u8 = null,
+    one_of: ?[]const u8 = null,
+    contains_any: ?[]const u8 = null,
+};
+
+/// Options in a stable order, so `admin member` and `member admin` agree
+fn sortedOptions(arena: std.mem.Allocator, text: []const u8) ![]const u8 {
+    var list = std.ArrayList([]const u8){};
+    var items = std.mem.tokenizeScalar(u8, text, ' ');
+    while (items.next()) |item| try list.append(arena, item);
+    std.mem.sort([]const u8, list.items, {}, struct {
+        fn lessThan(_: void, x: []const u8, y: []const u8) bool {
+            return std.mem.lessThan(u8, x, y);
+        }
+    }.lessThan);
+    return std.mem.join(arena, " ", list.items);
+}
+
+fn rulesOf(arena: std.mem.Allocator, field: go_rules.Field) !Rules {
+    var rules = Rules{ .any = field.checks.len > 0 };
+    for (field.checks) |check| {
+        switch (check.kind) {
+            .required => rules.required = !field.optional,
+            .min_len => rules.min_len = @max(rules.min_len orelse check.bound, check.bound),
+            .max_len => rules.max_len = @min(rules.max_len orelse check.bound, check.bound),
+            .min => rules.min = @max(rules.min orelse check.bound, check.bound),
+            .max => rules.max = @min(rules.max orelse check.bound, check.bound),
+            .email => rules.email = true,
+            .pattern => rules.pattern = check.text,
+            .one_of => rules.one_of = try sortedOptions(arena, check.text),
+            .contains_any => rules.contains_any = check.text,
+        }
+    }
+    return rules;
+}
+
+fn bounds(arena: std.mem.Allocator, min: ?i64, max: ?i64) ![]const u8 {
+    if (min == null and max == null) return "any";
+    if (min != null and max != null) return std.fmt.allocPrint(arena, "{d}..{d}", .{ min.?, max.? });
+    if (min) |n| return std.fmt.allocPrint(arena, "{d}..", .{n});
+    return std.fmt.allocPrint(arena, "..{d}", .{max.?});
+}
+
+fn sameOptional(a: ?[]const u8, b: ?[]const u8) bool {
+    if (a == null or b == null) return a == null and b == null;
+    return std.mem.eql(u8, a.?, b.?);
+}
+
+fn yesNo(value: bool) []const u8 {
+    return if (value) "yes" else "no";
+}
+
+/// Rules two aligned fields disagree on
+fn compareFields(arena: std.mem.Allocator, name: []const u8, a: go_rules.Field, b: go_rules.Field, out: *std.ArrayList(FieldMismatch)) !void {
+    const ra = try rulesOf(arena, a);
+    const rb = try rulesOf(arena, b);
+    if (ra.min_len != rb.min_len or ra.max_len != rb.max_len) {
+        try out.append(arena, .{ .field = name, .rule = "length", .a = try bounds(arena, ra.min_len, ra.max_len), .b = try bounds(arena, rb.min_len, rb.max_len) });
+    }
+    if (ra.min != rb.min or ra.max != rb.max) {
+        try out.append(arena, .{ .field = name, .rule = "range", .a = try bounds(arena, ra.min, ra.max), .b = try bounds(arena, rb.min, rb.max) });
+    }
+    // Whether a field is required is only stated by validated fields
+    if (ra.any and rb.any and ra.required != rb.required) {
+        try out.append(arena, .{ .field = name, .rule = "required", .a = yesNo(ra.required), .b = yesNo(rb.required) });
+    }
+    if (ra.email != rb.email) {
+        try out.append(arena, .{ .field = name, .rule = "email", .a = yesNo(ra.email), .b = yesNo(rb.email) });
+    }
+    const texts = [_]struct { rule: []const u8, a: ?[]const u8, b: ?[]const u8 }{
+        .{ .rule = "pattern", .a = ra.pattern, .b = rb.pattern },
+        .{ .rule = "one of", .a = ra.one_of, .b = rb.one_of },
+        .{ .rule = "contains any", .a = ra.contains_any, .b = rb.contains_any },
+    };
+    for (texts) |t| {
+        if (sameOptional(t.a, t.b)) continue;
+        try out.append(arena, .{ .field = name, .rule = t.rule, .a = t.a orelse "any", .b = t.b orelse "any" });
+    }
+}
+
+fn compareDtos(arena: std.mem.Allocator, a: go_rules.Struct, b: go_rules.Struct) ![]const FieldMismatch {
+    var mismatches = std.ArrayList(FieldMismatch){};
+    for (a.fields) |field| {
+        if (try findField(arena, b, try fieldKey(arena, field))) |other| {
+            try compareFields(arena, field.name, field, other, &mismatches);
+        } else if (field.checks.len > 0) {
+            try mismatches.append(arena, .{ .field = field.name, .rule = "field", .a = "defined", .b = "missing" });
+        }
+    }
+    for (b.fields) |field| {
+        if (field.checks.len == 0) continue;
+        if (try findField(arena, a, try fieldKey(arena, field)) == null) {
+            try mismatches.append(arena, .{ .field = field.name, .rule = "field", .a = "missing", .b = "defined" });
+        }
+    }
+    return mismatches.items;
+}
+
+/// Pair the DTOs of different services and compare the pairs. Everything
+/// lives in `arena` or borrows `dtos`.
+pub fn compare(arena: std.mem.Allocator, dtos: []const Dto) !Report {
+    var services = std.ArrayList([]const u8){};
+    for (dtos) |dto| {
+        for (services.items) |service| {
+            if (std.mem.eql(u8, service, dto.service)) break;
+        } else try services.append(arena, dto.service);
+    }
+
+    const names = try arena.alloc([]const u8, dtos.len);
+    for (dtos, names) |dto, *name| name.* = try logicalName(arena, dto.value.name);
+
+    var matches = std.ArrayList(Match){};
+    const paired = try arena.alloc(bool, dtos.len * dtos.len);
+    @memset(paired, false);
+    for (services.items, 0..) |first, si| {
+        for (services.items[si + 1 ..]) |second| {
+            // By name first, then by shape among what is left
+            const used = try arena.alloc(bool, dtos.len);
+            @memset(used, false);
+            for (dtos, 0..) |a, i| {
+                if (!std.mem.eql(u8, a.service, first)) continue;
+                for (dtos, 0..) |b, j| {
+                    if (used[j] or !std.mem.eql(u8, b.service, second)) continue;
+                    if (!std.mem.eql(u8, names[i], names[j])) continue;
+                    used[j] = true;
+                    paired[i * dtos.len + j] = true;
+                    try matches.append(arena, .{ .a = a, .b = b, .by_name = true, .mismatches = try compareDtos(arena, a.value, b.value) });
+                    break;
+                }
+            }
+            for (dtos, 0..) |a, i| {
+                if (!std.mem.eql(u8, a.service, first)) continue;
+                var matched = false;
+                for (dtos, 0..) |_, j| {
+                    if (paired[i * dtos.len + j]) matched = true;
+                }
+                if (matched) continue;
+                var best: ?usize = null;
+                var best_ratio: f64 = 0;
+                for (dtos, 0..) |b, j| {
+                    if (used[j] or !std.mem.eql(u8, b.service, second)) continue;
+                    const shape = try similarity(arena, a.value, b.value);
+                    if (shape.common < min_common_fields or shape.ratio < min_shape_ratio) continue;
+                    if (shape.ratio > best_ratio) {
+                        best = j;
+                        best_ratio = shape.ratio;
+                    }
+                }
+                const j = best orelse continue;
+                used[j] = true;
+                paired[i * dtos.len + j] = true;
+                try matches.append(arena, .{ .a = a, .b = dtos[j], .by_name = false, .mismatches = try compareDtos(arena, a.value, dtos[j].value) });
+            }
+        }
+    }
+
+    std.mem.sort(Match, matches.items, {}, struct {
+        fn lessThan(_: void, x: Match, y: Match) bool {
+            const by_service = std.mem.order(u8, x.a.service, y.a.service);
+            if (by_service != .eq) return by_service == .lt;
+            const by_file = std.mem.order(u8, x.a.file, y.a.file);
+            if (by_file != .eq) return by_file == .lt;
+            return x.a.value.line < y.a.value.line;
+        }
+    }.lessThan);
+    return .{ .dtos = dtos.len, .services = services.items.len, .matches = matches.items };
+}
+
+/// Matched DTOs with the rules they disagree on; with `show_consistent`,
+/// also the pairs that agree
+pub fn formatText(allocator: std.mem.Allocator, report: Report, show_consistent: bool) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("DTO consistency: {d} DTOs in {d} services, {d} matched pairs, {d} mismatches\n", .{
+        report.dtos,
+        report.services,
+        report.matches.len,
+        report.mismatchCount(),
+    });
+    for (report.matches) |m| {
+        if (m.mismatches.len == 0 and !show_consistent) continue;
+        try writer.print("\n{s} ({s}:{d}) ~ {s} ({s}:{d}), by {s}\n", .{
+            m.a.value.name,
+            m.a.file,
+            m.a.value.line,
+            m.b.value.name,
+            m.b.file,
+            m.b.value.line,
+            if (m.by_name) "name" else "shape",
+        });
+        if (m.mismatches.len == 0) {
+            try writer.writeAll("  consistent\n");
+            continue;
+        }
+        var width: usize = 0;
+        for (m.mismatches) |mm| width = @max(width, mm.field.len + mm.rule.len + 1);
+        for (m.mismatches) |mm| {
+            try writer.print("  {s} {s}", .{ mm.field, mm.rule });
+            try writer.writeByteNTimes(' ', width - (mm.field.len + mm.rule.len + 1) + 2);
+            try writer.print("{s} vs {s}\n", .{ mm.a, mm.b });
+        }
+    }
+    return try list.toOwnedSlice(allocator);
+}
+
+fn writeDto(writer: anytype, dto: Dto) !void {
+    try writer.writeAll("{\"service\": \"");
+    try output.writeJsonEscaped(writer, dto.service);
+    try writer.writeAll("\", \"name\": \"");
+    try output.writeJsonEscaped(writer, dto.value.name);
+    try writer.writeAll("\", \"file\": \"");
+    try output.writeJsonEscaped(writer, dto.file);
+    try writer.print("\", \"line\": {d}}}", .{dto.value.line});
+}
+
+pub fn formatJson(allocator: std.mem.Allocator, report: Report) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\n  \"dtos\": {d},\n  \"services\": {d},\n  \"mismatches\": {d},\n  \"matches\": [", .{
+        report.dtos,
+        report.services,
+        report.mismatchCount(),
+    });
+    for (report.matches, 0..) |m, i| {
+        try writer.writeAll(if (i == 0) "\n    {\"a\": " else ",\n    {\"a\": ");
+        try writeDto(writer, m.a);
+        try writer.writeAll(", \"b\": ");
+        try writeDto(writer, m.b);
+        try writer.print(", \"by\": \"{s}\", \"mismatches\": [", .{if (m.by_name) "name" else "shape"});
+        for (m.mismatches, 0..) |mm, j| {
+            if (j > 0) try writer.writeAll(", ");
+            try writer.writeAll("{\"field\": \"");
+            try output.writeJsonEscaped(writer, mm.field);
+            try writer.print("\", \"rule\": \"{s}\", \"a\": \"", .{mm.rule});
+            try output.writeJsonEscaped(writer, mm.a);
+            try writer.writeAll("\", \"b\": \"");
+            try output.writeJsonEscaped(writer, mm.b);
+            try writer.writeAll("\"}");
+        }
+        try writer.writeAll("]}");
+    }
+    try writer.writeAll(if (report.matches.len > 0) "\n  ]\n}\n" else "]\n}\n");
+    return try list.toOwnedSlice(allocator);
+}
+
+test "match DTOs across services and report rule mismatches" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const go_user = go_rules.Struct{ .name = "CreateUserRequest", .line = 7, .fields = &.{
+        .{ .name = "Username", .go_type = "string", .json_name = "username", .line = 8, .checks = &.{
+            .{ .kind = .required },
+            .{ .kind = .min_len, .bound = 3 },
+            .{ .kind = .max_len, .bound = 50 },
+        } },
+        .{ .name = "Role", .go_type = "string", .json_name = "role", .line = 9, .checks = &.{.{ .kind = .one_of, .text = "admin member" }} },
+        .{ .name = "Nickname", .go_type = "string", .json_name = "nickname", .line = 10, .checks = &.{.{ .kind = .max_len, .bound = 20 }} },
+    } };
+    const ts_user = go_rules.Struct{ .name = "CreateUserDto", .line = 3, .fields = &.{
+        .{ .name = "username", .go_type = "string", .line = 4, .checks = &.{
+            .{ .kind = .required },
+            .{ .kind = .min_len, .bound = 1 },
+            .{ .kind = .max_len, .bound = 30 },
+        } },
+        .{ .name = "role", .go_type = "string", .line = 5, .checks = &.{.{ .kind = .one_of, .text = "member admin" }} },
+    } };
+    const go_order = go_rules.Struct{ .name = "Order", .line = 20, .fields = &.{
+        .{ .name = "ItemID", .go_type = "string", .json_name = "item_id", .line = 21, .checks = &.{.{ .kind = .required }} },
+        .{ .name = "Quantity", .go_type = "int", .json_name = "quantity", .line = 22, .checks = &.{.{ .kind = .min, .bound = 1 }} },
+        .{ .name = "Note", .go_type = "string", .json_name = "note", .line = 23 },
+    } };
+    const ts_order = go_rules.Struct{ .name = "PurchaseSchema", .line = 12, .fields = &.{
+        .{ .name = "itemId", .go_type = "string", .line = 13, .checks = &.{.{ .kind = .required }} },
+        .{ .name = "quantity", .go_type = "number", .line = 14, .checks = &.{
+            .{ .kind = .min, .bound = 1 },
+            .{ .kind = .max, .bound = 99 },
+        } },
+        .{ .name = "note", .go_type = "string", .line = 15, .optional = true },
+    } };
+
+    const report = try compare(arena, &.{
+        .{ .service = "api", .file = "api/users.go", .value = go_user },
+        .{ .service = "api", .file = "api/orders.go", .value = go_order },
+        .{ .service = "web", .file = "web/src/users.dto.ts", .value = ts_user },
+        .{ .service = "web", .file = "web/src/orders.ts", .value = ts_order },
+    });
+    try testing.expectEqual(@as(usize, 2), report.services);
+    try testing.expectEqual(@as(usize, 2), report.matches.len);
+
+    const orders = report.matches[0];
+    try testing.expect(!orders.by_name);
+    try testing.expectEqualStrings("PurchaseSchema", orders.b.value.name);
+    try testing.expectEqual(@as(usize, 1), orders.mismatches.len);
+    try testing.expectEqualStrings("range", orders.mismatches[0].rule);
+    try testing.expectEqualStrings("1..", orders.mismatches[0].a);
+    try testing.expectEqualStrings("1..99", orders.mismatches[0].b);
+
+    const users = report.matches[1];
+    try testing.expect(users.by_name);
+    try testing.expectEqual(@as(usize, 2), users.mismatches.len);
+    try testing.expectEqualStrings("length", users.mismatches[0].rule);
+    try testing.expectEqualStrings("3..50", users.mismatches[0].a);
+    try testing.expectEqualStrings("1..30", users.mismatches[0].b);
+    try testing.expectEqualStrings("Nickname", users.mismatches[1].field);
+    try testing.expectEqualStrings("missing", users.mismatches[1].b);
+
+    const text = try formatText(arena, report, false);
+    try testing.expect(std.mem.startsWith(u8, text, "DTO consistency: 4 DTOs in 2 services, 2 matched pairs, 3 mismatches\n"));
+    try testing.expect(std.mem.indexOf(u8, text, "\nCreateUserRequest (api/users.go:7) ~ CreateUserDto (web/src/users.dto.ts:3), by name\n  Username length  3..50 vs 1..30\n") != null);
+}
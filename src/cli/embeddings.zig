@@ -0,0 +1,471 @@
+// Constraint embeddings for vector stores
+// Splits constraints into self-contained text chunks, embeds them with a
+// pluggable provider (an OpenAI-compatible API, a local Ollama server, or an
+// external command), and writes the vectors to pgvector (as SQL for psql),
+// Chroma, or Qdrant, so retrieval tooling can search constraints by meaning.
+// Chunk keys are derived from constraint ids, so re-exporting a result file
+// updates the same records instead of adding duplicates.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const json_api = @import("cli_json_api");
+
+pub const ExportError = error{
+    ProviderFailed,
+    StoreFailed,
+    InvalidResponse,
+    DimensionMismatch,
+    InvalidCollection,
+};
+
+/// Largest provider response read into memory; vectors for a whole batch
+/// come back in one response
+const max_response_bytes = 64 * 1024 * 1024;
+
+pub const default_collection = "ananke_constraints";
+
+/// A piece of one constraint's text, embedded on its own
+pub const Chunk = struct {
+    /// Stable record key: constraint id and chunk index, e.g. "1234-0"
+    key: []const u8,
+    constraint: constraint.Constraint,
+    index: usize,
+    text: []const u8,
+};
+
+/// Split constraints into chunks of at most `max_chars` characters. Every
+/// chunk starts with the constraint's name, kind, severity, and location, so
+/// a retrieved chunk is readable without the others; long descriptions are
+/// split at whitespace.
+pub fn chunkConstraints(arena: std.mem.Allocator, constraints: []const constraint.Constraint, max_chars: usize) ![]Chunk {
+    var chunks = std.ArrayList(Chunk){};
+    for (constraints) |c| {
+        var header = std.ArrayList(u8){};
+        const writer = header.writer(arena);
+        try writer.print("{s} ({s}, {s})", .{ c.name, @tagName(c.kind), @tagName(c.severity) });
+        if (c.origin_file) |file| {
+            try writer.print("\n{s}", .{file});
+            if (c.origin_line) |line| try writer.print(":{d}", .{line});
+        }
+
+        const room = @max(max_chars -| header.items.len -| 1, 64);
+        var rest = std.mem.trim(u8, c.description, " \t\r\n");
+        var index: usize = 0;
+        while (true) : (index += 1) {
+            var end = @min(rest.len, room);
+            if (end < rest.len) {
+                if (std.mem.lastIndexOfAny(u8, rest[0..end], " \t\n")) |space| {
+                    if (space > 0) end = space;
+                }
+            }
+            const text = if (rest.len == 0)
+                header.items
+            else
+                try std.fmt.allocPrint(arena, "{s}\n{s}", .{ header.items, rest[0..end] });
+            try chunks.append(arena, .{
+                .key = try std.fmt.allocPrint(arena, "{d}-{d}", .{ c.id, index }),
+                .constraint = c,
+                .index = index,
+                .text = text,
+            });
+            rest = std.mem.trimLeft(u8, rest[end..], " \t\r\n");
+            if (rest.len == 0) break;
+        }
+    }
+    return chunks.toOwnedSlice(arena);
+}
+
+pub const ProviderKind = enum {
+    openai,
+    ollama,
+    command,
+
+    pub fn defaultModel(self: ProviderKind) []const u8 {
+        return switch (self) {
+            .openai => "text-embedding-3-small",
+            .ollama => "nomic-embed-text",
+            .command => "",
+        };
+    }
+
+    pub fn defaultEndpoint(self: ProviderKind) ?[]const u8 {
+        return switch (self) {
+            .openai => "https://api.openai.com",
+            .ollama => "http://localhost:11434",
+            .command => null,
+        };
+    }
+};
+
+pub const Provider = struct {
+    kind: ProviderKind,
+    model: []const u8,
+    /// Base URL, or the command line for `.command`
+    endpoint: []const u8,
+    /// Bearer token for `.openai`
+    api_key: ?[]const u8 = null,
+};
+
+/// Request body for a batch of texts: `{"model": ..., "input": [...]}` is
+/// understood by all three providers
+pub fn formatEmbedRequest(allocator: std.mem.Allocator, model: []const u8, texts: []const []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    try writer.writeAll("{\"model\": \"");
+    try output.writeJsonEscaped(writer, model);
+    try writer.writeAll("\", \"input\": [");
+    for (texts, 0..) |text, i| {
+        try writer.writeAll(if (i == 0) "\"" else ", \"");
+        try output.writeJsonEscaped(writer, text);
+        try writer.writeAll("\"");
+    }
+    try writer.writeAll("]}");
+    return list.toOwnedSlice(allocator);
+}
+
+/// Vectors of a provider response, in input order: OpenAI's `data[].embedding`
+/// (ordered by `index`), or `embeddings` from Ollama and commands
+pub fn parseEmbedResponse(arena: std.mem.Allocator, kind: ProviderKind, body: []const u8, expected: usize) ![]const []const f32 {
+    const root = std.json.parseFromSliceLeaky(std.json.Value, arena, body, .{}) catch return ExportError.InvalidResponse;
+    if (root != .object) return ExportError.InvalidResponse;
+
+    const vectors = try arena.alloc([]const f32, expected);
+    if (kind == .openai) {
+        const data = root.object.get("data") orelse return ExportError.InvalidResponse;
+        if (data != .array or data.array.items.len != expected) return ExportError.InvalidResponse;
+        for (data.array.items, 0..) |item, i| {
+            if (item != .object) return ExportError.InvalidResponse;
+            const index: usize = if (item.object.get("index")) |v| switch (v) {
+                .integer => |n| std.math.cast(usize, n) orelse return ExportError.InvalidResponse,
+                else => return ExportError.InvalidResponse,
+            } else i;
+            if (index >= expected) return ExportError.InvalidResponse;
+            vectors[index] = try parseVector(arena, item.object.get("embedding") orelse return ExportError.InvalidResponse);
+        }
+    } else {
+        const embeddings = root.object.get("embeddings") orelse return ExportError.InvalidResponse;
+        if (embeddings != .array or embeddings.array.items.len != expected) return ExportError.InvalidResponse;
+        for (embeddings.array.items, 0..) |item, i| vectors[i] = try parseVector(arena, item);
+    }
+    return vectors;
+}
+
+fn parseVector(arena: std.mem.Allocator, value: std.json.Value) ![]const f32 {
+    if (value != .array or value.array.items.len == 0) return ExportError.InvalidResponse;
+    const vector = try arena.alloc(f32, value.array.items.len);
+    for (value.array.items, 0..) |item, i| {
+        vector[i] = switch (item) {
+            .float => |f| @floatCast(f),
+            .integer => |n| @floatFromInt(n),
+            else => return ExportError.InvalidResponse,
+        };
+    }
+    return vector;
+}
+
+/// Embed a batch of texts
+pub fn embed(arena: std.mem.Allocator, client: *std.http.Client, provider: Provider, texts: []const []const u8) ![]const []const f32 {
+    const payload = try formatEmbedRequest(arena, provider.model, texts);
+    const body = switch (provider.kind) {
+        .openai, .ollama => blk: {
+            const base = std.mem.trimRight(u8, provider.endpoint, "/");
+            const url = try std.fmt.allocPrint(arena, "{s}{s}", .{ base, if (provider.kind == .openai) "/v1/embeddings" else "/api/embed" });
+            const authorization = if (provider.api_key) |key| try std.fmt.allocPrint(arena, "Bearer {s}", .{key}) else null;
+            const auth_header = [_]std.http.Header{.{ .name = "authorization", .value = authorization orelse "" }};
+            const headers: []const std.http.Header = if (authorization != null) &auth_header else &.{};
+            var response = std.ArrayList(u8){};
+            const status = try json_api.send(arena, client, .POST, url, headers, payload, .{ .body = &response, .max_bytes = max_response_bytes });
+            if (status < 200 or status >= 300) return ExportError.ProviderFailed;
+            break :blk response.items;
+        },
+        .command => try runCommand(arena, provider.endpoint, payload),
+    };
+    return parseEmbedResponse(arena, provider.kind, body, texts.len);
+}
+
+/// Run an embedding command: the request on stdin, the response on stdout
+fn runCommand(arena: std.mem.Allocator, command: []const u8, payload: []const u8) ![]const u8 {
+    var argv = std.ArrayList([]const u8){};
+    var words = std.mem.tokenizeAny(u8, command, " \t");
+    while (words.next()) |word| try argv.append(arena, word);
+    if (argv.items.len == 0) return ExportError.ProviderFailed;
+
+    var child = std.process.Child.init(argv.items, arena);
+    child.stdin_behavior = .Pipe;
+    child.stdout_behavior = .Pipe;
+    child.stderr_behavior = .Inherit;
+    try child.spawn();
+
+    child.stdin.?.writeAll(payload) catch {};
+    child.stdin.?.close();
+    child.stdin = null;
+    const stdout = try child.stdout.?.readToEndAlloc(arena, max_response_bytes);
+    const term = try child.wait();
+    if (term != .Exited or term.Exited != 0) return ExportError.ProviderFailed;
+    return stdout;
+}
+
+pub const Store = enum {
+    pgvector,
+    chroma,
+    qdrant,
+
+    pub fn defaultUrl(self: Store) ?[]const u8 {
+        return switch (self) {
+            .pgvector => null,
+            .chroma => "http://localhost:8000",
+            .qdrant => "http://localhost:6333",
+        };
+    }
+};
+
+/// Collection and table names are identifiers, so they are safe in URLs and SQL
+pub fn validCollection(name: []const u8) bool {
+    if (name.len == 0 or name.len > 63 or std.ascii.isDigit(name[0])) return false;
+    for (name) |c| {
+        if (!std.ascii.isAlphanumeric(c) and c != '_') return false;
+    }
+    return true;
+}
+
+fn checkDimensions(vectors: []const []const f32) !usize {
+    const dimensions = vectors[0].len;
+    for (vectors) |vector| {
+        if (vector.len != dimensions) return ExportError.DimensionMismatch;
+    }
+    return dimensions;
+}
+
+fn writeSqlString(writer: anytype, text: []const u8) !void {
+    try writer.writeByte('\'');
+    for (text) |c| {
+        if (c == 0) continue;
+        if (c == '\'') try writer.writeByte('\'');
+        try writer.writeByte(c);
+    }
+    try writer.writeByte('\'');
+}
+
+fn writeVector(writer: anytype, vector: []const f32) !void {
+    try writer.writeByte('[');
+    for (vector, 0..) |x, i| {
+        if (i > 0) try writer.writeByte(',');
+        try writer.print("{d}", .{x});
+    }
+    try writer.writeByte(']');
+}
+
+/// SQL that creates the pgvector table when missing and upserts every chunk,
+/// in one transaction; apply it with `psql -f`
+pub fn formatPgvectorSql(allocator: std.mem.Allocator, table: []const u8, chunks: []const Chunk, vectors: []const []const f32) ![]u8 {
+    if (!validCollection(table)) return ExportError.InvalidCollection;
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("-- Constraint embeddings exported by ananke\nBEGIN;\nCREATE EXTENSION IF NOT EXISTS vector;\n");
+    if (chunks.len == 0) {
+        try writer.writeAll("COMMIT;\n");
+        return list.toOwnedSlice(allocator);
+    }
+    try writer.print(
+        \\CREATE TABLE IF NOT EXISTS {s} (
+        \\    id text PRIMARY KEY,
+        \\    constraint_id numeric(20) NOT NULL,
+        \\    chunk integer NOT NULL,
+        \\    name text NOT NULL,
+        \\    kind text NOT NULL,
+        \\    severity text NOT NULL,
+        \\    file text,
+        \\    line integer,
+        \\    content text NOT NULL,
+        \\    embedding vector({d}) NOT NULL
+        \\);
+        \\
+    , .{ table, try checkDimensions(vectors) });
+
+    for (chunks, vectors) |chunk, vector| {
+        const c = chunk.constraint;
+        try writer.print("INSERT INTO {s} (id, constraint_id, chunk, name, kind, severity, file, line, content, embedding) VALUES (", .{table});
+        try writeSqlString(writer, chunk.key);
+        try writer.print(", {d}, {d}, ", .{ c.id, chunk.index });
+        try writeSqlString(writer, c.name);
+        try writer.print(", '{s}', '{s}', ", .{ @tagName(c.kind), @tagName(c.severity) });
+        if (c.origin_file) |file| try writeSqlString(writer, file) else try writer.writeAll("NULL");
+        if (c.origin_line) |line| try writer.print(", {d}, ", .{line}) else try writer.writeAll(", NULL, ");
+        try writeSqlString(writer, chunk.text);
+        try writer.writeAll(", '");
+        try writeVector(writer, vector);
+        try writer.writeAll("')\n    ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, kind = EXCLUDED.kind, severity = EXCLUDED.severity, file = EXCLUDED.file, line = EXCLUDED.line, content = EXCLUDED.content, embedding = EXCLUDED.embedding;\n");
+    }
+    try writer.writeAll("COMMIT;\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// Constraint fields stored with each vector, as JSON object members
+fn writePayloadFields(writer: anytype, chunk: Chunk) !void {
+    const c = chunk.constraint;
+    try writer.writeAll("\"key\": \"");
+    try output.writeJsonEscaped(writer, chunk.key);
+    try writer.print("\", \"constraint_id\": \"{d}\", \"chunk\": {d}, \"name\": \"", .{ c.id, chunk.index });
+    try output.writeJsonEscaped(writer, c.name);
+    try writer.print("\", \"kind\": \"{s}\", \"severity\": \"{s}\"", .{ @tagName(c.kind), @tagName(c.severity) });
+    // Chroma rejects null metadata values, so absent fields are left out
+    if (c.origin_file) |file| {
+        try writer.writeAll(", \"file\": \"");
+        try output.writeJsonEscaped(writer, file);
+        try writer.writeAll("\"");
+    }
+    if (c.origin_line) |line| try writer.print(", \"line\": {d}", .{line});
+}
+
+/// Remote store connection
+pub const Remote = struct {
+    url: []const u8,
+    collection: []const u8,
+    /// Sent as `api-key` (Qdrant) or `x-chroma-token` (Chroma)
+    api_key: ?[]const u8 = null,
+    /// Chroma tenant and database
+    tenant: []const u8 = "default_tenant",
+    database: []const u8 = "default_database",
+};
+
+fn authHeaders(store: Store, remote: Remote, buf: *[1]std.http.Header) []const std.http.Header {
+    const key = remote.api_key orelse return &.{};
+    buf[0] = .{ .name = if (store == .qdrant) "api-key" else "x-chroma-token", .value = key };
+    return buf[0..1];
+}
+
+/// Create the collection when missing and upsert the chunks. Returns the
+/// number of records written.
+pub fn upsertRemote(
+    arena: std.mem.Allocator,
+    client: *std.http.Client,
+    store: Store,
+    remote: Remote,
+    chunks: []const Chunk,
+    vectors: []const []const f32,
+) !usize {
+    if (!validCollection(remote.collection)) return ExportError.InvalidCollection;
+    if (chunks.len == 0) return 0;
+    const dimensions = try checkDimensions(vectors);
+    const base = std.mem.trimRight(u8, remote.url, "/");
+    var header_buf: [1]std.http.Header = undefined;
+    const headers = authHeaders(store, remote, &header_buf);
+
+    switch (store) {
+        .pgvector => unreachable,
+        .qdrant => {
+            const collection_url = try std.fmt.allocPrint(arena, "{s}/collections/{s}", .{ base, remote.collection });
+            const create = try std.fmt.allocPrint(arena, "{{\"vectors\": {{\"size\": {d}, \"distance\": \"Cosine\"}}}}", .{dimensions});
+            // 409 when the collection already exists
+            const created = try json_api.send(arena, client, .PUT, collection_url, headers, create, null);
+            if ((created < 200 or created >= 300) and created != 409) return ExportError.StoreFailed;
+
+            var body = std.ArrayList(u8){};
+            const writer = body.writer(arena);
+            try writer.writeAll("{\"points\": [");
+            for (chunks, vectors, 0..) |chunk, vector, i| {
+                if (i > 0) try writer.writeAll(", ");
+                // Point ids are unsigned integers or UUIDs; the key hash is stable across exports
+                try writer.print("{{\"id\": {d}, \"vector\": ", .{std.hash.Wyhash.hash(0, chunk.key)});
+                try writeVector(writer, vector);
+                try writer.writeAll(", \"payload\": {");
+                try writePayloadFields(writer, chunk);
+                try writer.writeAll(", \"text\": \"");
+                try output.writeJsonEscaped(writer, chunk.text);
+                try writer.writeAll("\"}}");
+            }
+            try writer.writeAll("]}");
+            const url = try std.fmt.allocPrint(arena, "{s}/points?wait=true", .{collection_url});
+            const status = try json_api.send(arena, client, .PUT, url, headers, body.items, null);
+            if (status < 200 or status >= 300) return ExportError.StoreFailed;
+        },
+        .chroma => {
+            const collections_url = try std.fmt.allocPrint(arena, "{s}/api/v2/tenants/{s}/databases/{s}/collections", .{ base, remote.tenant, remote.database });
+            const create = try std.fmt.allocPrint(arena, "{{\"name\": \"{s}\", \"get_or_create\": true, \"metadata\": {{\"hnsw:space\": \"cosine\"}}}}", .{remote.collection});
+            var created = std.ArrayList(u8){};
+            const status = try json_api.send(arena, client, .POST, collections_url, headers, create, .{ .body = &created });
+            if (status < 200 or status >= 300) return ExportError.StoreFailed;
+            const id = try collectionId(arena, created.items);
+
+            var body = std.ArrayList(u8){};
+            const writer = body.writer(arena);
+            try writer.writeAll("{\"ids\": [");
+            for (chunks, 0..) |chunk, i| {
+                try writer.writeAll(if (i == 0) "\"" else ", \"");
+                try output.writeJsonEscaped(writer, chunk.key);
+                try writer.writeAll("\"");
+            }
+            try writer.writeAll("], \"embeddings\": [");
+            for (vectors, 0..) |vector, i| {
+                if (i > 0) try writer.writeAll(", ");
+                try writeVector(writer, vector);
+            }
+            try writer.writeAll("], \"documents\": [");
+            for (chunks, 0..) |chunk, i| {
+                try writer.writeAll(if (i == 0) "\"" else ", \"");
+                try output.writeJsonEscaped(writer, chunk.text);
+                try writer.writeAll("\"");
+            }
+            try writer.writeAll("], \"metadatas\": [");
+            for (chunks, 0..) |chunk, i| {
+                try writer.writeAll(if (i == 0) "{" else ", {");
+                try writePayloadFields(writer, chunk);
+                try writer.writeAll("}");
+            }
+            try writer.writeAll("]}");
+            const url = try std.fmt.allocPrint(arena, "{s}/{s}/upsert", .{ collections_url, id });
+            const upserted = try json_api.send(arena, client, .POST, url, headers, body.items, null);
+            if (upserted < 200 or upserted >= 300) return ExportError.StoreFailed;
+        },
+    }
+    return chunks.len;
+}
+
+/// The `id` of a Chroma collection response
+fn collectionId(arena: std.mem.Allocator, body: []const u8) ![]const u8 {
+    const root = std.json.parseFromSliceLeaky(std.json.Value, arena, body, .{}) catch return ExportError.InvalidResponse;
+    if (root != .object) return ExportError.InvalidResponse;
+    const id = root.object.get("id") orelse return ExportError.InvalidResponse;
+    if (id != .string) return ExportError.InvalidResponse;
+    return id.string;
+}
+
+test "chunk constraints and write pgvector upserts" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const constraints = [_]constraint.Constraint{
+        .{ .id = 7, .name = "bound_params", .description = "Queries use bound parameters " ** 8, .kind = .security, .severity = .err, .origin_file = "db/o'neil.go", .origin_line = 12 },
+        .{ .id = 8, .name = "naming", .description = "", .kind = .syntactic, .severity = .hint },
+    };
+    const chunks = try chunkConstraints(arena, &constraints, 120);
+    try testing.expect(chunks.len > 2);
+    try testing.expectEqualStrings("7-0", chunks[0].key);
+    try testing.expect(std.mem.startsWith(u8, chunks[1].text, "bound_params (security, err)\ndb/o'neil.go:12\n"));
+    for (chunks) |chunk| try testing.expect(chunk.text.len <= 120);
+    try testing.expectEqualStrings("naming (syntactic, hint)", chunks[chunks.len - 1].text);
+
+    const request = try formatEmbedRequest(arena, "nomic-embed-text", &.{ "a", "b\"" });
+    try testing.expectEqualStrings("{\"model\": \"nomic-embed-text\", \"input\": [\"a\", \"b\\\"\"]}", request);
+    const openai = try parseEmbedResponse(arena, .openai,
+        \\{"data": [{"index": 1, "embedding": [0.5, 1]}, {"index": 0, "embedding": [0.25, -2]}]}
+    , 2);
+    try testing.expectEqual(@as(f32, 0.25), openai[0][0]);
+    try testing.expectEqual(@as(f32, 1.0), openai[1][1]);
+    try testing.expectError(ExportError.InvalidResponse, parseEmbedResponse(arena, .ollama, "{\"embeddings\": [[1]]}", 2));
+
+    const vectors = try arena.alloc([]const f32, chunks.len);
+    for (vectors) |*vector| vector.* = &.{ 0.5, -1 };
+    const sql = try formatPgvectorSql(arena, default_collection, chunks, vectors);
+    try testing.expect(std.mem.indexOf(u8, sql, "embedding vector(2) NOT NULL") != null);
+    try testing.expect(std.mem.indexOf(u8, sql, "'db/o''neil.go', 12, ") != null);
+    try testing.expect(std.mem.indexOf(u8, sql, "'[0.5,-1]')") != null);
+    try testing.expect(std.mem.indexOf(u8, sql, "'naming', 'syntactic', 'hint', NULL, NULL, ") != null);
+    try testing.expectError(ExportError.InvalidCollection, formatPgvectorSql(arena, "x; DROP TABLE y", chunks, vectors));
+}
@@ -0,0 +1,257 @@
+// Extraction cache wiring
+// Cache settings from flags and config, opening and reporting on the cache,
+// and the keys extraction results are stored under. The cache itself lives in
+// cache_store.zig and the remote tier in remote_cache.zig.
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const cache_store = @import("cli_cache_store");
+const remote_cache = @import("cli_remote_cache");
+const rule_history = @import("cli_rule_history");
+const results = @import("cli_results");
+const jobs = @import("cli_jobs");
+const plan = @import("cli_plan");
+const profiling = @import("cli_profiling");
+const version = @import("cli_version");
+
+/// Cache directory from --cache-dir or `[cache] dir`. Claude analysis is not
+/// deterministic, so its results are never cached.
+pub fn parseCacheDir(parsed_args: args_mod.Args, config: config_mod.Config, use_claude: bool) ?[]const u8 {
+    if (use_claude or parsed_args.hasFlag("no-cache") or !config.cache_enabled) return null;
+    return parsed_args.getFlag("cache-dir") orelse config.cache_dir orelse cache_store.default_dir;
+}
+
+/// Remote cache URL from --remote-cache or `[cache] remote`. It backs the
+/// local cache, so it is off whenever the local cache is.
+pub fn parseRemoteCache(parsed_args: args_mod.Args, config: config_mod.Config, use_claude: bool) !?[]const u8 {
+    if (parseCacheDir(parsed_args, config, use_claude) == null) return null;
+    const url = parsed_args.getFlag("remote-cache") orelse config.cache_remote orelse return null;
+    _ = remote_cache.Location.parse(url) catch {
+        cli_error.printError("Invalid remote cache: {s} (expected http(s)://host/path, s3://bucket/prefix, or gs://bucket/prefix)", .{url});
+        return error.InvalidArgument;
+    };
+    return url;
+}
+
+pub fn parseRemoteCacheMode(parsed_args: args_mod.Args, config: config_mod.Config) !remote_cache.Mode {
+    const mode_str = parsed_args.getFlag("remote-cache-mode") orelse config.cache_remote_mode orelse return .read;
+    return remote_cache.Mode.parse(mode_str) orelse {
+        cli_error.printError("Invalid remote cache mode: {s} (expected read or read-write)", .{mode_str});
+        return error.InvalidArgument;
+    };
+}
+
+/// Cache size limit from --cache-max-size or `[cache] max_size`
+pub fn parseCacheMaxSize(parsed_args: args_mod.Args, config: config_mod.Config) !u64 {
+    const size_str = parsed_args.getFlag("cache-max-size") orelse config.cache_max_size orelse return cache_store.default_max_bytes;
+    return jobs.parseByteSize(size_str) orelse {
+        cli_error.printError("Invalid cache size: {s} (expected a byte count, e.g. 2G, or 0 for no limit)", .{size_str});
+        return error.InvalidArgument;
+    };
+}
+
+/// Where the cache lives and how it is shared
+pub const Settings = struct {
+    /// Null disables the cache
+    dir: ?[]const u8 = null,
+    /// Cache size at which least recently used entries are evicted; 0 is unlimited
+    max_bytes: u64 = cache_store.default_max_bytes,
+    /// Shared cache behind the local one (http(s)://, s3://, gs://)
+    remote: ?[]const u8 = null,
+    remote_mode: remote_cache.Mode = .read,
+};
+
+/// Open the extraction cache, or return null (with a warning) when it cannot
+/// be used; extraction then proceeds uncached. A remote cache that cannot be
+/// reached only leaves the local cache without its shared tier.
+pub fn open(allocator: std.mem.Allocator, settings: Settings) ?cache_store.Cache {
+    const dir = settings.dir orelse return null;
+    var cache = cache_store.Cache.open(allocator, dir) catch |err| {
+        cli_error.printWarning("Extraction cache disabled: cannot open {s}: {s}", .{ dir, @errorName(err) });
+        return null;
+    };
+    cache.max_bytes = settings.max_bytes;
+    if (settings.remote) |url| {
+        cache.connectRemote(url, settings.remote_mode) catch |err| {
+            cli_error.printWarning("Remote cache disabled: {s}: {s}", .{ url, @errorName(err) });
+        };
+    }
+    return cache;
+}
+
+/// Record the run's hit and miss counts in the cache statistics
+pub fn finish(cache: *cache_store.Cache, verbose: bool) void {
+    cache.recordRun() catch {};
+    if (verbose) {
+        cli_error.printInfo("Cache: {d} hits, {d} misses, {d} entries written", .{
+            cache.run.hits,
+            cache.run.misses,
+            cache.run.writes,
+        });
+        if (cache.write_errors > 0) {
+            cli_error.printWarning("{d} cache entries could not be written", .{cache.write_errors});
+        }
+        if (cache.evicted.removed > 0) {
+            var freed = std.ArrayList(u8){};
+            defer freed.deinit(cache.allocator);
+            profiling.writeBytes(freed.writer(cache.allocator), cache.evicted.freed_bytes) catch {};
+            cli_error.printInfo("Cache: evicted {d} least recently used entries ({s}) to stay within the size limit", .{
+                cache.evicted.removed,
+                freed.items,
+            });
+        }
+    }
+    if (cache.remote) |remote| {
+        const counters = remote.counters;
+        if (verbose) {
+            cli_error.printInfo("Remote cache: {d} hits, {d} misses, {d} entries uploaded", .{
+                counters.hits,
+                counters.misses,
+                counters.uploads,
+            });
+        }
+        if (counters.rejected > 0) {
+            cli_error.printWarning("{d} remote cache entries failed integrity checks and were ignored", .{counters.rejected});
+        }
+        if (remote.first_error) |err| {
+            cli_error.printWarning("{d} remote cache requests failed ({s}){s}", .{
+                counters.errors,
+                @errorName(err),
+                if (remote.disabled()) "; remote cache skipped for the rest of the run" else "",
+            });
+        }
+    }
+}
+
+/// What one cache handle did during a run. Handles are not thread-safe, so
+/// concurrent targets each open their own and their usage is added to one
+/// handle for the report.
+pub const Usage = struct {
+    run: cache_store.Counters = .{},
+    written_bytes: u64 = 0,
+    write_errors: usize = 0,
+    remote: remote_cache.Counters = .{},
+    remote_error: ?anyerror = null,
+
+    pub fn of(cache: *const cache_store.Cache) Usage {
+        var usage = Usage{ .run = cache.run, .written_bytes = cache.written_bytes, .write_errors = cache.write_errors };
+        if (cache.remote) |remote| {
+            usage.remote = remote.counters;
+            usage.remote_error = remote.first_error;
+        }
+        return usage;
+    }
+
+    pub fn addTo(self: Usage, cache: *cache_store.Cache) void {
+        cache.run.add(self.run);
+        cache.written_bytes += self.written_bytes;
+        cache.write_errors += self.write_errors;
+        const remote = cache.remote orelse return;
+        remote.counters.hits += self.remote.hits;
+        remote.counters.misses += self.remote.misses;
+        remote.counters.uploads += self.remote.uploads;
+        remote.counters.rejected += self.remote.rejected;
+        remote.counters.errors += self.remote.errors;
+        if (remote.first_error == null) remote.first_error = self.remote_error;
+    }
+};
+
+/// Key for `source` extracted under the rule set with `digest`: content,
+/// language, tool version, and rules, so a rule change re-extracts affected
+/// files. A restricted set of kinds is folded into the rules part.
+pub fn computeKey(digest: *const [16]u8, language: []const u8, source: []const u8, kinds: std.EnumSet(ananke.ConstraintKind)) cache_store.Key {
+    if (kinds.eql(std.EnumSet(ananke.ConstraintKind).initFull())) {
+        return cache_store.computeKey(version.VERSION, digest, language, source);
+    }
+    var buf: [32]u8 = undefined;
+    const rules = std.fmt.bufPrint(&buf, "{s}+{x:0>2}", .{ digest, kinds.bits.mask }) catch unreachable;
+    return cache_store.computeKey(version.VERSION, rules, language, source);
+}
+
+/// Cache keys of one extraction. Rule-set digests are computed once per
+/// language; earlier rule sets are read on the first miss.
+pub const Keys = struct {
+    allocator: std.mem.Allocator,
+    rule_digests: std.StringHashMap([16]u8),
+    history: ?rule_history.History = null,
+
+    pub fn init(allocator: std.mem.Allocator) Keys {
+        return .{ .allocator = allocator, .rule_digests = std.StringHashMap([16]u8).init(allocator) };
+    }
+
+    pub fn deinit(self: *Keys) void {
+        self.rule_digests.deinit();
+        if (self.history) |*history| history.deinit();
+    }
+
+    pub fn rulesDigest(self: *Keys, language: []const u8) [16]u8 {
+        return self.rule_digests.get(language) orelse blk: {
+            const computed = plan.rulesDigest(language);
+            self.rule_digests.put(language, computed) catch {};
+            break :blk computed;
+        };
+    }
+
+    /// Key for `source` under the current rules of `language`
+    pub fn key(self: *Keys, language: []const u8, source: []const u8, kinds: std.EnumSet(ananke.ConstraintKind)) cache_store.Key {
+        const digest = self.rulesDigest(language);
+        return computeKey(&digest, language, source, kinds);
+    }
+
+    /// On a miss for `to`, reuse the entry extracted under an earlier rule
+    /// set if no rule changed since then occurs in `source` (see
+    /// rule_history.zig)
+    pub fn carryOver(
+        self: *Keys,
+        cache: *cache_store.Cache,
+        language: []const u8,
+        source: []const u8,
+        kinds: std.EnumSet(ananke.ConstraintKind),
+        to: *const cache_store.Key,
+    ) ?results.ResultFile {
+        if (self.history == null) self.history = rule_history.History.init(self.allocator, cache.dir);
+        const digest = self.rulesDigest(language);
+        for (self.history.?.previous(language, &digest)) |earlier| {
+            if (!earlier.unaffected(source)) continue;
+            const from = computeKey(&earlier.digest, language, source, kinds);
+            if (cache.carryOver(&from, to)) |entry| return entry;
+        }
+        return null;
+    }
+};
+
+/// Store a fresh extraction, through `writer` when one is running; a failed
+/// write only costs a future cache miss
+pub fn store(cache: *cache_store.Cache, writer: ?*cache_store.Writer, key: *const cache_store.Key, set: ananke.ConstraintSet) void {
+    if (writer) |w| {
+        w.submit(key, set) catch {
+            cache.write_errors += 1;
+        };
+        return;
+    }
+    cache.store(key, set) catch {
+        cache.write_errors += 1;
+    };
+}
+
+test "cache keys cover rules and kinds" {
+    const testing = std.testing;
+    const full = std.EnumSet(ananke.ConstraintKind).initFull();
+    const reduced = std.EnumSet(ananke.ConstraintKind).init(.{ .syntactic = true, .security = true });
+    const digest = [_]u8{1} ** 16;
+    const other = [_]u8{2} ** 16;
+
+    const key = computeKey(&digest, "python", "x = 1\n", full);
+    try testing.expectEqualSlices(u8, &key, &computeKey(&digest, "python", "x = 1\n", full));
+    try testing.expect(!std.mem.eql(u8, &key, &computeKey(&other, "python", "x = 1\n", full)));
+    try testing.expect(!std.mem.eql(u8, &key, &computeKey(&digest, "python", "x = 1\n", reduced)));
+    try testing.expect(!std.mem.eql(u8, &key, &computeKey(&digest, "python", "x = 2\n", full)));
+
+    var keys = Keys.init(testing.allocator);
+    defer keys.deinit();
+    const current = keys.rulesDigest("python");
+    try testing.expectEqualSlices(u8, &current, &keys.rulesDigest("python"));
+    try testing.expectEqualSlices(u8, &computeKey(&current, "python", "x = 1\n", full), &keys.key("python", "x = 1\n", full));
+}
@@ -0,0 +1,304 @@
+// Extraction scheduling
+// Worker counts for the extraction stages, and per-language pipelines: with
+// --by-language, the files still to be extracted are grouped by language and
+// each group gets its own workers, sized by its share of the bytes, so a slow
+// extractor only holds up its own files. A pipeline stops claiming files while
+// more than its memory budget of extracted source waits to be merged; results
+// are still consumed in input order, so output does not depend on scheduling.
+const std = @import("std");
+const ananke = @import("ananke");
+const args_mod = @import("cli_args");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const discovery = @import("cli_discovery");
+const jobs = @import("cli_jobs");
+
+/// Bytes of extracted but unmerged source each language pipeline may hold
+pub const default_memory = 256 * 1024 * 1024;
+
+/// Worker counts from -j/--jobs and the per-stage flags, falling back to
+/// `[performance]` in config and then to the number of CPUs
+pub fn parseConcurrency(parsed_args: args_mod.Args, config: config_mod.Config, use_claude: bool) !jobs.Concurrency {
+    const settings = jobs.Settings{
+        .jobs = try parsed_args.getFlagInt("jobs", usize) orelse try parsed_args.getFlagInt("j", usize) orelse config.jobs,
+        .parse = try parsed_args.getFlagInt("parse-jobs", usize) orelse config.parse_jobs,
+        .analyze = try parsed_args.getFlagInt("analyze-jobs", usize) orelse config.analyze_jobs,
+        .render = try parsed_args.getFlagInt("render-jobs", usize) orelse config.render_jobs,
+    };
+    var concurrency = jobs.resolve(settings, jobs.cpuCount());
+    // One Claude client is shared by the run and is not safe to call concurrently
+    if (use_claude) concurrency.analyze = 1;
+    return concurrency;
+}
+
+/// Per-language pipeline budget from --pipeline-memory or `[performance]
+/// pipeline_memory`; 0 unless --by-language or `language_pipelines` is set
+pub fn parsePipelineMemory(parsed_args: args_mod.Args, config: config_mod.Config) !usize {
+    if (!parsed_args.hasFlag("by-language") and !config.language_pipelines) return 0;
+    const size_str = parsed_args.getFlag("pipeline-memory") orelse config.pipeline_memory orelse return default_memory;
+    const size = jobs.parseByteSize(size_str) orelse 0;
+    if (size == 0) {
+        cli_error.printError("Invalid pipeline memory: {s} (expected a byte count, e.g. 256M)", .{size_str});
+        return error.InvalidArgument;
+    }
+    return @intCast(size);
+}
+
+/// Whether the files still to be extracted are in more than one language
+pub fn spansLanguages(files: []const discovery.SourceFile, hits: []const ?ananke.ConstraintSet) bool {
+    var first: ?[]const u8 = null;
+    for (files, hits) |file, hit| {
+        if (hit != null) continue;
+        const language = first orelse {
+            first = file.language;
+            continue;
+        };
+        if (!std.mem.eql(u8, language, file.language)) return true;
+    }
+    return false;
+}
+
+/// Workers for a language pipeline: its share of `workers` by bytes, at
+/// least one and no more than it has files
+pub fn languageWorkers(workers: usize, bytes: usize, total_bytes: usize, files: usize) usize {
+    const share = if (total_bytes == 0) workers else workers * bytes / total_bytes;
+    return @max(@min(share, files), 1);
+}
+
+/// Misses of one language, extracted by their own workers
+const Pipeline = struct {
+    language: []const u8,
+    /// Input indices of the files, claimed in this order
+    indices: std.ArrayList(usize) = .{},
+    bytes: usize = 0,
+    /// This pipeline's workers are numbered from here in the schedule
+    first_worker: usize = 0,
+    workers: usize = 0,
+    /// Workers actually running; 0 means the consuming thread extracts inline
+    spawned: usize = 0,
+    next: usize = 0,
+    /// Source bytes claimed by workers and not yet consumed
+    pending: usize = 0,
+};
+
+/// The language pipelines for one set of files
+pub const Schedule = struct {
+    allocator: std.mem.Allocator,
+    files: []const discovery.SourceFile,
+    pipelines: std.ArrayList(Pipeline) = .{},
+    /// Pipeline of each file; null for files that need no extraction
+    owners: []?usize,
+    /// Workers across every pipeline, numbered from 0
+    workers: usize = 0,
+
+    /// Group the files without a hit by language and share `workers` between
+    /// the groups. Every language gets at least one worker, so the schedule
+    /// may have more than `workers`.
+    pub fn init(
+        allocator: std.mem.Allocator,
+        files: []const discovery.SourceFile,
+        hits: []const ?ananke.ConstraintSet,
+        workers: usize,
+    ) !Schedule {
+        var self = Schedule{ .allocator = allocator, .files = files, .owners = try allocator.alloc(?usize, files.len) };
+        errdefer self.deinit();
+        var total_bytes: usize = 0;
+        for (files, hits, self.owners, 0..) |file, hit, *owner, i| {
+            owner.* = null;
+            if (hit != null) continue;
+            const p = for (self.pipelines.items, 0..) |pipeline, j| {
+                if (std.mem.eql(u8, pipeline.language, file.language)) break j;
+            } else blk: {
+                try self.pipelines.append(allocator, .{ .language = file.language });
+                break :blk self.pipelines.items.len - 1;
+            };
+            owner.* = p;
+            const pipeline = &self.pipelines.items[p];
+            try pipeline.indices.append(allocator, i);
+            pipeline.bytes += file.source.len;
+            total_bytes += file.source.len;
+        }
+
+        for (self.pipelines.items) |*pipeline| {
+            pipeline.first_worker = self.workers;
+            pipeline.workers = languageWorkers(workers, pipeline.bytes, total_bytes, pipeline.indices.items.len);
+            self.workers += pipeline.workers;
+        }
+        return self;
+    }
+
+    pub fn deinit(self: *Schedule) void {
+        for (self.pipelines.items) |*pipeline| pipeline.indices.deinit(self.allocator);
+        self.pipelines.deinit(self.allocator);
+        self.allocator.free(self.owners);
+    }
+
+    /// Call `produce(context, worker, i)` for every file to extract, on a
+    /// worker of its language, and `consume(context, i)` for every file in
+    /// input order on the calling thread. A pipeline claims no more files
+    /// while `budget` bytes of its sources are produced but not consumed; a
+    /// file larger than the budget still runs once the pipeline is drained.
+    /// A pipeline whose threads cannot be spawned produces on the calling
+    /// thread instead.
+    pub fn run(
+        self: *Schedule,
+        budget: usize,
+        context: anytype,
+        comptime produce: fn (@TypeOf(context), usize, usize) void,
+        comptime consume: fn (@TypeOf(context), usize) anyerror!void,
+    ) anyerror!void {
+        const ready = try self.allocator.alloc(bool, self.files.len);
+        defer self.allocator.free(ready);
+        @memset(ready, false);
+
+        const State = struct {
+            context: @TypeOf(context),
+            files: []const discovery.SourceFile,
+            pipelines: []Pipeline,
+            ready: []bool,
+            budget: usize,
+            mutex: std.Thread.Mutex = .{},
+            changed: std.Thread.Condition = .{},
+            cancelled: bool = false,
+
+            fn work(state: *@This(), p: usize, worker: usize) void {
+                const pipeline = &state.pipelines[p];
+                while (true) {
+                    state.mutex.lock();
+                    while (!state.cancelled and pipeline.next < pipeline.indices.items.len and
+                        pipeline.pending > 0 and pipeline.pending >= state.budget)
+                    {
+                        state.changed.wait(&state.mutex);
+                    }
+                    if (state.cancelled or pipeline.next >= pipeline.indices.items.len) {
+                        state.mutex.unlock();
+                        return;
+                    }
+                    const i = pipeline.indices.items[pipeline.next];
+                    pipeline.next += 1;
+                    pipeline.pending += state.files[i].source.len;
+                    state.mutex.unlock();
+
+                    produce(state.context, worker, i);
+                    state.mutex.lock();
+                    state.ready[i] = true;
+                    state.mutex.unlock();
+                    state.changed.broadcast();
+                }
+            }
+
+            fn wait(state: *@This(), i: usize) void {
+                state.mutex.lock();
+                defer state.mutex.unlock();
+                while (!state.ready[i]) state.changed.wait(&state.mutex);
+            }
+
+            fn release(state: *@This(), p: usize, bytes: usize) void {
+                state.mutex.lock();
+                state.pipelines[p].pending -= bytes;
+                state.mutex.unlock();
+                state.changed.broadcast();
+            }
+
+            fn cancel(state: *@This()) void {
+                state.mutex.lock();
+                state.cancelled = true;
+                state.mutex.unlock();
+                state.changed.broadcast();
+            }
+        };
+        var state = State{
+            .context = context,
+            .files = self.files,
+            .pipelines = self.pipelines.items,
+            .ready = ready,
+            .budget = budget,
+        };
+
+        const threads = try self.allocator.alloc(std.Thread, self.workers);
+        defer self.allocator.free(threads);
+        var spawned: usize = 0;
+        defer {
+            state.cancel();
+            for (threads[0..spawned]) |thread| thread.join();
+        }
+        for (self.pipelines.items, 0..) |*pipeline, p| {
+            for (0..pipeline.workers) |w| {
+                threads[spawned] = std.Thread.spawn(.{}, State.work, .{ &state, p, pipeline.first_worker + w }) catch break;
+                spawned += 1;
+                pipeline.spawned += 1;
+            }
+        }
+
+        for (self.owners, 0..) |owner, i| {
+            const p = owner orelse {
+                try consume(context, i);
+                continue;
+            };
+            const pipeline = &self.pipelines.items[p];
+            if (pipeline.spawned == 0) {
+                produce(context, pipeline.first_worker, i);
+            } else {
+                state.wait(i);
+            }
+            try consume(context, i);
+            if (pipeline.spawned > 0) state.release(p, self.files[i].source.len);
+        }
+    }
+};
+
+test "language pipelines share workers by bytes" {
+    const testing = std.testing;
+
+    try testing.expectEqual(@as(usize, 6), languageWorkers(8, 750, 1000, 100));
+    try testing.expectEqual(@as(usize, 1), languageWorkers(8, 10, 1000, 100));
+    try testing.expectEqual(@as(usize, 2), languageWorkers(8, 900, 1000, 2));
+}
+
+test "language pipelines produce on their own workers and consume in order" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const files = [_]discovery.SourceFile{
+        .{ .path = "a.py", .language = "python", .source = "x = 1\n" ** 100 },
+        .{ .path = "b.ts", .language = "typescript", .source = "let x = 1;\n" },
+        .{ .path = "c.py", .language = "python", .source = "y = 2\n" ** 100 },
+        .{ .path = "d.go", .language = "go", .source = "package d\n" },
+    };
+    var cached = ananke.ConstraintSet.init(allocator, "cached");
+    defer cached.deinit();
+    const hits = [_]?ananke.ConstraintSet{ null, null, null, cached };
+    try testing.expect(spansLanguages(&files, &hits));
+    try testing.expect(!spansLanguages(files[2..], hits[2..]));
+
+    var schedule = try Schedule.init(allocator, &files, &hits, 4);
+    defer schedule.deinit();
+    try testing.expectEqual(@as(usize, 2), schedule.pipelines.items.len);
+    try testing.expectEqual(@as(?usize, null), schedule.owners[3]);
+    try testing.expectEqual(schedule.owners[0], schedule.owners[2]);
+    try testing.expectEqual(@as(usize, 4), schedule.workers);
+
+    const Context = struct {
+        workers: [4]?usize = .{null} ** 4,
+        consumed: std.ArrayList(usize) = .{},
+
+        fn produce(ctx: *@This(), worker: usize, i: usize) void {
+            ctx.workers[i] = worker;
+        }
+
+        fn consume(ctx: *@This(), i: usize) anyerror!void {
+            try ctx.consumed.append(testing.allocator, i);
+        }
+    };
+    var context = Context{};
+    defer context.consumed.deinit(allocator);
+    // A one-byte budget lets each pipeline hold a single unconsumed file
+    try schedule.run(1, &context, Context.produce, Context.consume);
+
+    try testing.expectEqualSlices(usize, &.{ 0, 1, 2, 3 }, context.consumed.items);
+    try testing.expectEqual(@as(?usize, null), context.workers[3]);
+    for (context.workers[0..3], schedule.owners[0..3]) |worker, owner| {
+        const pipeline = schedule.pipelines.items[owner.?];
+        try testing.expect(worker.? >= pipeline.first_worker and worker.? < pipeline.first_worker + pipeline.workers);
+    }
+}
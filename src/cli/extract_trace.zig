@@ -0,0 +1,146 @@
+// Extraction stage timing and tracing
+// The stages of an extraction run are timed by the profiler (--verbose,
+// --timings) and traced as spans (--trace-endpoint, --trace-file), with a span
+// per extracted file under its stage. The profiler lives in profiling.zig and
+// the tracer in tracing.zig.
+const std = @import("std");
+const ananke = @import("ananke");
+const cli_error = @import("cli_error");
+const profiling = @import("cli_profiling");
+const tracing = @import("cli_tracing");
+
+/// Pattern rules listed by --rule-timings
+const rule_timing_rows = 10;
+
+/// Where stage boundaries are recorded; either may be null
+pub const Stages = struct {
+    profiler: ?*profiling.Profiler = null,
+    tracer: ?*tracing.Tracer = null,
+
+    /// Start timing stage `name` when stages are being recorded
+    pub fn begin(self: Stages, name: []const u8) void {
+        if (self.profiler) |profiler| profiler.begin(name);
+        if (self.tracer) |tracer| tracer.beginStage(name);
+    }
+
+    pub fn end(self: Stages) !void {
+        if (self.tracer) |tracer| tracer.endStage();
+        if (self.profiler) |profiler| try profiler.end();
+    }
+};
+
+/// Extract `source` with `engine` in a span of the current stage
+pub fn extractFile(
+    tracer: *tracing.Tracer,
+    engine: *ananke.Ananke,
+    path: []const u8,
+    language: []const u8,
+    source: []const u8,
+) !ananke.ConstraintSet {
+    const span = try beginFile(tracer);
+    const constraints = engine.extract(source, language) catch |err| {
+        tracer.end(span, &.{ tracing.Attribute.string("code.filepath", path), tracing.Attribute.string("error.type", @errorName(err)) }, true);
+        return err;
+    };
+    endFile(tracer, span, path, language, source.len, constraints.constraints.items.len, null);
+    return constraints;
+}
+
+/// Start the span of one file, for files extracted in more than one call
+pub fn beginFile(tracer: *tracing.Tracer) !tracing.Handle {
+    return tracer.begin("analyze.file", tracer.stage);
+}
+
+/// End a file's span with what was extracted from it. `mode` marks files
+/// that were not extracted in one piece.
+pub fn endFile(
+    tracer: *tracing.Tracer,
+    span: tracing.Handle,
+    path: []const u8,
+    language: []const u8,
+    bytes: u64,
+    constraints: usize,
+    mode: ?[]const u8,
+) void {
+    const attributes = [_]tracing.Attribute{
+        tracing.Attribute.string("code.filepath", path),
+        tracing.Attribute.string("ananke.language", language),
+        tracing.Attribute.int("ananke.bytes", bytes),
+        tracing.Attribute.int("ananke.constraints", constraints),
+        tracing.Attribute.string("ananke.mode", mode orelse ""),
+    };
+    tracer.end(span, if (mode == null) attributes[0..4] else &attributes, false);
+}
+
+/// Print the stage breakdown recorded during the run
+pub fn reportTimings(profiler: *const profiling.Profiler) void {
+    if (profiler.stages.items.len == 0) return;
+    cli_error.printInfo("Stage timings:", .{});
+    for (profiler.stages.items) |stage| {
+        var buf: [64]u8 = undefined;
+        var fbs = std.io.fixedBufferStream(&buf);
+        profiling.writeDuration(fbs.writer(), stage.elapsed_ns) catch {};
+        fbs.writer().writeAll(", peak ") catch {};
+        profiling.writeBytes(fbs.writer(), stage.peak_bytes) catch {};
+        cli_error.printInfo("  {s:<10} {s}, {d} allocations", .{ stage.name, fbs.getWritten(), stage.allocations });
+    }
+}
+
+/// Print the pattern rules that took the most scan time
+pub fn reportRuleTimings(allocator: std.mem.Allocator) !void {
+    const timings = try ananke.clew.patterns.ruleTimings(allocator);
+    defer allocator.free(timings);
+    if (timings.len == 0) {
+        cli_error.printInfo("No pattern rules were timed (every file came from the cache?)", .{});
+        return;
+    }
+    cli_error.printInfo("Slowest pattern rules:", .{});
+    for (timings[0..@min(timings.len, rule_timing_rows)]) |timing| {
+        var buf: [32]u8 = undefined;
+        var fbs = std.io.fixedBufferStream(&buf);
+        profiling.writeDuration(fbs.writer(), timing.ns) catch {};
+        cli_error.printInfo("  {s:>9}  {d:>10} compares  {d:>7} matches  {s} \"{s}\"", .{
+            fbs.getWritten(),
+            timing.compares,
+            timing.matches,
+            timing.language,
+            timing.rule.pattern,
+        });
+    }
+}
+
+test "stages and extracted files are recorded as spans" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var counting = profiling.CountingAllocator.init(allocator);
+    var profiler = profiling.Profiler.init(allocator, &counting);
+    defer profiler.deinit();
+    var tracer = try tracing.Tracer.init(allocator, "ananke.extract");
+    defer tracer.deinit();
+    var engine = try ananke.Ananke.init(allocator);
+    defer engine.deinit();
+
+    const stages = Stages{ .profiler = &profiler, .tracer = &tracer };
+    stages.begin("analyze");
+    var constraints = try extractFile(&tracer, &engine, "add.py", "python", "def add(a: int, b: int) -> int:\n    return a + b\n");
+    defer constraints.deinit();
+    try stages.end();
+    // Nothing is recorded without a profiler or tracer
+    const unrecorded = Stages{};
+    unrecorded.begin("render");
+    try unrecorded.end();
+
+    try testing.expectEqual(@as(usize, 1), profiler.stages.items.len);
+    try testing.expectEqualStrings("analyze", profiler.stages.items[0].name);
+
+    const spans = tracer.spans.items;
+    try testing.expectEqual(@as(usize, 3), spans.len);
+    try testing.expectEqualStrings("analyze", spans[1].name);
+    try testing.expect(spans[1].end_ns != 0);
+    try testing.expectEqualStrings("analyze.file", spans[2].name);
+    try testing.expectEqual(spans[1].id, spans[2].parent.?);
+    try testing.expectEqual(@as(usize, 4), spans[2].attributes.len);
+    try testing.expectEqualStrings("add.py", spans[2].attributes[0].value.string);
+    try testing.expect(!spans[2].failed);
+}
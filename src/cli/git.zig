@@ -0,0 +1,365 @@
+// Git object database access
+// Reads trees and blobs at an arbitrary commit through git plumbing
+// (`ls-tree`, `cat-file --batch`), so constraints can be extracted at any ref
+// without touching the working tree or requiring a checkout. Also lists the
+// paths changed since a ref, so incremental runs can skip the directory walk.
+const std = @import("std");
+const discovery = @import("cli_discovery");
+
+pub const GitError = error{
+    GitNotFound,
+    GitFailed,
+    InvalidRevision,
+    UnknownRevision,
+    MalformedOutput,
+};
+
+/// Largest `git` command output buffered in memory (tree listings can be big)
+const max_output_bytes = 256 * 1024 * 1024;
+
+/// Run a git command and return its stdout. Stderr is discarded.
+pub fn runGit(allocator: std.mem.Allocator, argv: []const []const u8) ![]u8 {
+    const result = std.process.Child.run(.{
+        .allocator = allocator,
+        .argv = argv,
+        .max_output_bytes = max_output_bytes,
+    }) catch |err| switch (err) {
+        error.FileNotFound => return GitError.GitNotFound,
+        else => return err,
+    };
+    defer allocator.free(result.stderr);
+
+    const ok = switch (result.term) {
+        .Exited => |code| code == 0,
+        else => false,
+    };
+    if (!ok) {
+        allocator.free(result.stdout);
+        return GitError.GitFailed;
+    }
+    return result.stdout;
+}
+
+/// Reject revisions that git would parse as options
+fn validateRevision(rev: []const u8) !void {
+    if (rev.len == 0 or rev[0] == '-' or std.mem.indexOfAny(u8, rev, " \t\n\x00") != null) {
+        return GitError.InvalidRevision;
+    }
+}
+
+/// Resolve a commit-ish (branch, tag, SHA, `HEAD~3`) to a full commit id
+pub fn resolveCommit(allocator: std.mem.Allocator, rev: []const u8) ![]u8 {
+    try validateRevision(rev);
+
+    const spec = try std.fmt.allocPrint(allocator, "{s}^{{commit}}", .{rev});
+    defer allocator.free(spec);
+
+    const stdout = runGit(allocator, &.{ "git", "rev-parse", "--verify", "--quiet", spec }) catch |err| switch (err) {
+        GitError.GitFailed => return GitError.UnknownRevision,
+        else => return err,
+    };
+    defer allocator.free(stdout);
+
+    return allocator.dupe(u8, std.mem.trim(u8, stdout, " \t\r\n"));
+}
+
+/// Files under `dir` that differ from `base` in the working tree (staged or
+/// not), plus untracked files git does not ignore; deleted files are left
+/// out. Paths are relative to the current directory and live in `arena`.
+/// Listing them costs two git commands instead of a walk of the whole tree.
+pub fn changedPaths(
+    allocator: std.mem.Allocator,
+    arena: std.mem.Allocator,
+    base: []const u8,
+    dir: []const u8,
+) !std.ArrayList([]const u8) {
+    const commit = try resolveCommit(arena, base);
+
+    var paths = std.ArrayList([]const u8){};
+    errdefer paths.deinit(allocator);
+    const changed = try runGit(arena, &.{ "git", "diff", "--name-only", "-z", "--relative", "--diff-filter=d", commit, "--", dir });
+    try appendNameList(allocator, &paths, changed);
+    const untracked = try runGit(arena, &.{ "git", "ls-files", "--others", "--exclude-standard", "-z", "--", dir });
+    try appendNameList(allocator, &paths, untracked);
+    return paths;
+}
+
+/// How one path differs between a commit and the working tree
+pub const Change = struct {
+    /// git's status letter (M, A, D, R, C, T, ...), or '?' for untracked files
+    status: u8,
+    path: []const u8,
+    /// Source path of a rename or copy
+    from: ?[]const u8 = null,
+    /// Similarity of a rename or copy to its source, in percent
+    similarity: u8 = 0,
+};
+
+/// Every change under `dir` from `commit` to the working tree, staged or
+/// not, with renames detected, plus untracked files git does not ignore.
+/// Paths are relative to the current directory and live in `arena`.
+pub fn changesSince(
+    allocator: std.mem.Allocator,
+    arena: std.mem.Allocator,
+    commit: []const u8,
+    dir: []const u8,
+) !std.ArrayList(Change) {
+    try validateRevision(commit);
+
+    var changes = std.ArrayList(Change){};
+    errdefer changes.deinit(allocator);
+    const diff = try runGit(arena, &.{ "git", "diff", "--name-status", "-z", "-M", "--relative", commit, "--", dir });
+    try appendNameStatus(allocator, &changes, diff);
+    const untracked = try runGit(arena, &.{ "git", "ls-files", "--others", "--exclude-standard", "-z", "--", dir });
+    var it = std.mem.splitScalar(u8, untracked, 0);
+    while (it.next()) |path| {
+        if (path.len > 0) try changes.append(allocator, .{ .status = '?', .path = path });
+    }
+    return changes;
+}
+
+/// Append the records of a `--name-status -z` listing: a status field and a
+/// path, or for renames and copies ("R087") a status and two paths
+fn appendNameStatus(allocator: std.mem.Allocator, changes: *std.ArrayList(Change), listing: []const u8) !void {
+    var it = std.mem.splitScalar(u8, listing, 0);
+    while (it.next()) |status| {
+        if (status.len == 0) continue;
+        const path = it.next() orelse return GitError.MalformedOutput;
+        if (status[0] != 'R' and status[0] != 'C') {
+            try changes.append(allocator, .{ .status = status[0], .path = path });
+            continue;
+        }
+        const to = it.next() orelse return GitError.MalformedOutput;
+        try changes.append(allocator, .{
+            .status = status[0],
+            .path = to,
+            .from = path,
+            .similarity = std.fmt.parseInt(u8, status[1..], 10) catch return GitError.MalformedOutput,
+        });
+    }
+}
+
+/// Append each path of a NUL-separated `-z` name listing
+fn appendNameList(allocator: std.mem.Allocator, paths: *std.ArrayList([]const u8), listing: []const u8) !void {
+    var it = std.mem.splitScalar(u8, listing, 0);
+    while (it.next()) |path| {
+        if (path.len > 0) try paths.append(allocator, path);
+    }
+}
+
+/// One blob entry from `git ls-tree -r -l -z`
+pub const TreeEntry = struct {
+    oid: []const u8,
+    size: usize,
+    path: []const u8,
+};
+
+/// Parse NUL-separated `ls-tree -r -l -z` records, keeping regular files only
+/// (symlinks and submodules are skipped). Entries borrow from `listing`.
+pub fn parseTreeListing(allocator: std.mem.Allocator, listing: []const u8) !std.ArrayList(TreeEntry) {
+    var entries = std.ArrayList(TreeEntry){};
+    errdefer entries.deinit(allocator);
+
+    var records = std.mem.splitScalar(u8, listing, 0);
+    while (records.next()) |record| {
+        if (record.len == 0) continue;
+        // "<mode> SP <type> SP <oid> SP+ <size> TAB <path>"
+        const tab = std.mem.indexOfScalar(u8, record, '\t') orelse return GitError.MalformedOutput;
+        var fields = std.mem.tokenizeScalar(u8, record[0..tab], ' ');
+        const mode = fields.next() orelse return GitError.MalformedOutput;
+        const kind = fields.next() orelse return GitError.MalformedOutput;
+        const oid = fields.next() orelse return GitError.MalformedOutput;
+        const size_str = fields.next() orelse return GitError.MalformedOutput;
+
+        if (!std.mem.eql(u8, kind, "blob") or std.mem.eql(u8, mode, "120000")) continue;
+
+        try entries.append(allocator, .{
+            .oid = oid,
+            .size = std.fmt.parseInt(usize, size_str, 10) catch return GitError.MalformedOutput,
+            .path = record[tab + 1 ..],
+        });
+    }
+
+    return entries;
+}
+
+/// Streams blob contents through a long-lived `git cat-file --batch` process
+pub const BlobReader = struct {
+    child: std.process.Child,
+    buffer: [64 * 1024]u8 = undefined,
+    reader: std.fs.File.Reader = undefined,
+
+    /// Start the batch process. Call `start` on the final (non-moving) location.
+    pub fn init(allocator: std.mem.Allocator) BlobReader {
+        var child = std.process.Child.init(&.{ "git", "cat-file", "--batch" }, allocator);
+        child.stdin_behavior = .Pipe;
+        child.stdout_behavior = .Pipe;
+        child.stderr_behavior = .Ignore;
+        return .{ .child = child };
+    }
+
+    pub fn start(self: *BlobReader) !void {
+        self.child.spawn() catch |err| switch (err) {
+            error.FileNotFound => return GitError.GitNotFound,
+            else => return err,
+        };
+        self.reader = self.child.stdout.?.readerStreaming(&self.buffer);
+    }
+
+    /// Read one blob by object id into memory owned by `allocator`
+    pub fn read(self: *BlobReader, allocator: std.mem.Allocator, oid: []const u8) ![]u8 {
+        const stdin = self.child.stdin orelse return GitError.GitFailed;
+        try stdin.writeAll(oid);
+        try stdin.writeAll("\n");
+
+        // "<oid> SP <type> SP <size> LF" or "<oid> SP missing LF"
+        const header = std.mem.trimRight(u8, try self.reader.interface.takeDelimiterInclusive('\n'), "\n");
+        var fields = std.mem.splitScalar(u8, header, ' ');
+        _ = fields.next();
+        const kind = fields.next() orelse return GitError.MalformedOutput;
+        if (!std.mem.eql(u8, kind, "blob")) return GitError.MalformedOutput;
+        const size = std.fmt.parseInt(usize, fields.next() orelse "", 10) catch return GitError.MalformedOutput;
+
+        const data = try allocator.alloc(u8, size);
+        errdefer allocator.free(data);
+        try self.reader.interface.readSliceAll(data);
+        _ = try self.reader.interface.takeByte(); // trailing LF
+        return data;
+    }
+
+    /// Stop the batch process. Killing (rather than waiting) avoids blocking
+    /// on a child that still has unread output after an error.
+    pub fn deinit(self: *BlobReader) void {
+        if (self.child.stdin) |stdin| {
+            stdin.close();
+            self.child.stdin = null;
+        }
+        _ = self.child.kill() catch {};
+    }
+};
+
+/// Every supported source file at a commit, held in memory
+pub const Snapshot = struct {
+    allocator: std.mem.Allocator,
+    arena: std.heap.ArenaAllocator,
+    commit: []const u8 = "",
+    files: std.ArrayList(discovery.SourceFile),
+    /// Files above the size limit that were left out
+    oversized: usize = 0,
+
+    pub fn init(allocator: std.mem.Allocator) Snapshot {
+        return .{
+            .allocator = allocator,
+            .arena = std.heap.ArenaAllocator.init(allocator),
+            .files = std.ArrayList(discovery.SourceFile){},
+        };
+    }
+
+    pub fn deinit(self: *Snapshot) void {
+        self.files.deinit(self.allocator);
+        self.arena.deinit();
+    }
+};
+
+/// Load supported source files at `rev`, optionally limited to `pathspec`.
+/// Paths are relative to the current directory, matching working-tree extraction.
+/// `.gitignore` is not consulted: tracked files are what the commit contains.
+pub fn loadSnapshot(
+    allocator: std.mem.Allocator,
+    rev: []const u8,
+    pathspec: ?[]const u8,
+    options: discovery.Options,
+    max_file_bytes: usize,
+) !Snapshot {
+    var snapshot = Snapshot.init(allocator);
+    errdefer snapshot.deinit();
+    const arena = snapshot.arena.allocator();
+
+    snapshot.commit = try resolveCommit(arena, rev);
+
+    var argv = std.ArrayList([]const u8){};
+    defer argv.deinit(allocator);
+    try argv.appendSlice(allocator, &.{ "git", "ls-tree", "-r", "-l", "-z", snapshot.commit });
+    if (pathspec) |path| try argv.appendSlice(allocator, &.{ "--", path });
+
+    const listing = try runGit(arena, argv.items);
+    var entries = try parseTreeListing(allocator, listing);
+    defer entries.deinit(allocator);
+
+    var excludes = try discovery.excludePatterns(allocator, options);
+    defer excludes.deinit(allocator);
+
+    var blobs = BlobReader.init(allocator);
+    try blobs.start();
+    defer blobs.deinit();
+
+    for (entries.items) |entry| {
+        if (discovery.isExcludedPath(excludes.items, entry.path)) continue;
+        const language = discovery.detectLanguage(entry.path);
+        if (!discovery.wantsLanguage(options, language)) continue;
+        if (entry.size > max_file_bytes) {
+            snapshot.oversized += 1;
+            continue;
+        }
+
+        try snapshot.files.append(allocator, .{
+            .path = entry.path,
+            .language = language,
+            .source = try blobs.read(arena, entry.oid),
+        });
+    }
+
+    return snapshot;
+}
+
+test "split name listings" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var paths = std.ArrayList([]const u8){};
+    defer paths.deinit(allocator);
+    try appendNameList(allocator, &paths, "src/a.go\x00src/b c.py\x00");
+    try appendNameList(allocator, &paths, "");
+
+    try testing.expectEqual(@as(usize, 2), paths.items.len);
+    try testing.expectEqualStrings("src/b c.py", paths.items[1]);
+}
+
+test "parse name-status listing with renames" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var changes = std.ArrayList(Change){};
+    defer changes.deinit(allocator);
+    try appendNameStatus(allocator, &changes, "M\x00src/a.go\x00R100\x00src/old.go\x00src/new.go\x00D\x00src/gone.py\x00");
+
+    try testing.expectEqual(@as(usize, 3), changes.items.len);
+    try testing.expectEqual(@as(u8, 'R'), changes.items[1].status);
+    try testing.expectEqualStrings("src/new.go", changes.items[1].path);
+    try testing.expectEqualStrings("src/old.go", changes.items[1].from.?);
+    try testing.expectEqual(@as(u8, 100), changes.items[1].similarity);
+    try testing.expectError(GitError.MalformedOutput, appendNameStatus(allocator, &changes, "R090\x00src/x.go\x00"));
+}
+
+test "parse ls-tree listing" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const listing = "100644 blob 8c7e5a667f1b771847fe88c01c3de34413a1b220     120\tsrc/main.go\x00" ++
+        "120000 blob 1f2e3d4c5b6a79880716253443526170819a0b1c      11\tlink.go\x00" ++
+        "160000 commit 0123456789abcdef0123456789abcdef01234567       -\tthird_party/lib\x00";
+
+    var entries = try parseTreeListing(allocator, listing);
+    defer entries.deinit(allocator);
+
+    try testing.expectEqual(@as(usize, 1), entries.items.len);
+    try testing.expectEqualStrings("src/main.go", entries.items[0].path);
+    try testing.expectEqual(@as(usize, 120), entries.items[0].size);
+}
+
+test "revision validation rejects options" {
+    try std.testing.expectError(GitError.InvalidRevision, validateRevision("--output=/tmp/x"));
+    try std.testing.expectError(GitError.InvalidRevision, validateRevision(""));
+    try validateRevision("v1.2.0");
+    try validateRevision("HEAD~3");
+}
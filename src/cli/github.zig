@@ -0,0 +1,333 @@
+// GitHub pull request reviews
+// Turns a constraint diff into the payload of GitHub's "create a review for a
+// pull request" endpoint (POST /repos/{owner}/{repo}/pulls/{number}/reviews):
+// one review comment per change that sits on a line the pull request
+// touched, anchored to that line on the side it lives on (RIGHT for added,
+// strengthened, and weakened constraints, LEFT for removed ones), and a
+// summary body with the counts and every change that has no changed line to
+// sit on. Weakened checks in the changed code are commented on the same way,
+// ahead of the constraint changes in the summary. GitHub rejects a review
+// whose comments point outside the diff, so the changed lines come from
+// `git diff -U0` between the same two commits.
+const std = @import("std");
+const ananke = @import("ananke");
+const git = @import("cli_git");
+const output = @import("cli_output");
+const constraint_diff = @import("cli_constraint_diff");
+
+const constraint = ananke.types.constraint;
+
+pub const default_api_url = "https://api.github.com";
+
+pub const Side = enum {
+    LEFT,
+    RIGHT,
+};
+
+/// Lines `start` through `start + count - 1`
+pub const Range = struct {
+    start: u32,
+    count: u32,
+};
+
+/// Lines a diff removed (old side) and added (new side), by path
+pub const ChangedLines = struct {
+    old: std.StringHashMapUnmanaged(std.ArrayList(Range)) = .{},
+    new: std.StringHashMapUnmanaged(std.ArrayList(Range)) = .{},
+
+    pub fn contains(self: *const ChangedLines, side: Side, path: []const u8, line: u32) bool {
+        const map = if (side == .LEFT) &self.old else &self.new;
+        const ranges = map.get(normalize(path)) orelse return false;
+        for (ranges.items) |range| {
+            if (line >= range.start and line - range.start < range.count) return true;
+        }
+        return false;
+    }
+
+    fn add(self: *ChangedLines, arena: std.mem.Allocator, side: Side, path: []const u8, range: Range) !void {
+        if (range.count == 0) return;
+        const map = if (side == .LEFT) &self.old else &self.new;
+        const gop = try map.getOrPut(arena, path);
+        if (!gop.found_existing) gop.value_ptr.* = .{};
+        try gop.value_ptr.append(arena, range);
+    }
+};
+
+/// Lines changed between two commits under `pathspec`. Everything lives in `arena`.
+pub fn changedLines(arena: std.mem.Allocator, before_commit: []const u8, after_commit: []const u8, pathspec: ?[]const u8) !ChangedLines {
+    var argv = std.ArrayList([]const u8){};
+    try argv.appendSlice(arena, &.{ "git", "diff", "-U0", "-M", "--no-color", "--no-ext-diff", "--relative", before_commit, after_commit, "--" });
+    if (pathspec) |path| try argv.append(arena, path);
+    return parseUnifiedDiff(arena, try git.runGit(arena, argv.items));
+}
+
+/// Collect the hunk ranges of a unified diff. Quoted paths (names with
+/// characters git escapes) are skipped; changes in them go to the summary.
+pub fn parseUnifiedDiff(arena: std.mem.Allocator, text: []const u8) !ChangedLines {
+    var changed = ChangedLines{};
+    var old_path: ?[]const u8 = null;
+    var new_path: ?[]const u8 = null;
+    // Lines left in the current hunk body, which may itself start with "---"
+    var old_left: u32 = 0;
+    var new_left: u32 = 0;
+
+    var lines = std.mem.splitScalar(u8, text, '\n');
+    while (lines.next()) |line| {
+        if (old_left > 0 or new_left > 0) {
+            if (line.len == 0) continue;
+            switch (line[0]) {
+                '-' => old_left -|= 1,
+                '+' => new_left -|= 1,
+                ' ' => {
+                    old_left -|= 1;
+                    new_left -|= 1;
+                },
+                else => {},
+            }
+            continue;
+        }
+        if (std.mem.startsWith(u8, line, "--- ")) {
+            old_path = diffPath(line[4..], "a/");
+        } else if (std.mem.startsWith(u8, line, "+++ ")) {
+            new_path = diffPath(line[4..], "b/");
+        } else if (std.mem.startsWith(u8, line, "@@ -")) {
+            var fields = std.mem.tokenizeScalar(u8, line[3..], ' ');
+            const old_range = parseRange(fields.next() orelse "") orelse return git.GitError.MalformedOutput;
+            const new_range = parseRange(fields.next() orelse "") orelse return git.GitError.MalformedOutput;
+            if (old_path) |path| try changed.add(arena, .LEFT, path, old_range);
+            if (new_path) |path| try changed.add(arena, .RIGHT, path, new_range);
+            old_left = old_range.count;
+            new_left = new_range.count;
+        }
+    }
+    return changed;
+}
+
+fn diffPath(field: []const u8, prefix: []const u8) ?[]const u8 {
+    const path = std.mem.trimRight(u8, field, "\t\r");
+    if (!std.mem.startsWith(u8, path, prefix)) return null;
+    return path[prefix.len..];
+}
+
+/// "-12,3" or "+7" (a count of one)
+fn parseRange(field: []const u8) ?Range {
+    if (field.len < 2 or (field[0] != '-' and field[0] != '+')) return null;
+    var parts = std.mem.splitScalar(u8, field[1..], ',');
+    const start = std.fmt.parseInt(u32, parts.next().?, 10) catch return null;
+    const count = if (parts.next()) |n| std.fmt.parseInt(u32, n, 10) catch return null else 1;
+    return .{ .start = start, .count = count };
+}
+
+fn normalize(path: []const u8) []const u8 {
+    var rest = path;
+    while (std.mem.startsWith(u8, rest, "./")) rest = rest[2..];
+    return rest;
+}
+
+/// Where a change is commented on, when it sits on a changed line
+fn anchor(change: constraint_diff.Change, changed: *const ChangedLines) ?struct { Side, u32 } {
+    const side: Side = if (change.kind == .removed) .LEFT else .RIGHT;
+    const c = change.subject();
+    const file = c.origin_file orelse return null;
+    const line = c.origin_line orelse return null;
+    if (!changed.contains(side, file, line)) return null;
+    return .{ side, line };
+}
+
+/// Where a weakened check is commented on: the new line of its subject, or
+/// else the old line of the check, whichever the diff touched
+fn anchorWeakening(w: constraint_diff.CodeWeakening, changed: *const ChangedLines) ?struct { Side, u32 } {
+    if (w.after_line) |line| {
+        if (changed.contains(.RIGHT, w.file, line)) return .{ .RIGHT, line };
+    }
+    if (w.before_line) |line| {
+        if (changed.contains(.LEFT, w.file, line)) return .{ .LEFT, line };
+    }
+    return null;
+}
+
+/// Render the review payload for `diff`, posted against `commit_id`
+pub fn formatReview(
+    allocator: std.mem.Allocator,
+    diff: constraint_diff.Diff,
+    changed: *const ChangedLines,
+    commit_id: []const u8,
+    before_label: []const u8,
+    after_label: []const u8,
+) ![]u8 {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var unanchored = std.ArrayList(constraint_diff.Change){};
+    var comments = std.ArrayList(u8){};
+    const comments_writer = comments.writer(arena);
+    var comment_count: usize = 0;
+    var body = std.ArrayList(u8){};
+    for (diff.changes.items) |change| {
+        const side, const line = anchor(change, changed) orelse {
+            try unanchored.append(arena, change);
+            continue;
+        };
+        body.clearRetainingCapacity();
+        try writeComment(body.writer(arena), change);
+        try comments_writer.writeAll(if (comment_count == 0) "\n    {\"path\": \"" else ",\n    {\"path\": \"");
+        try output.writeJsonEscaped(comments_writer, normalize(change.subject().origin_file.?));
+        try comments_writer.print("\", \"line\": {d}, \"side\": \"{s}\", \"body\": \"", .{ line, @tagName(side) });
+        try output.writeJsonEscaped(comments_writer, body.items);
+        try comments_writer.writeAll("\"}");
+        comment_count += 1;
+    }
+
+    var unanchored_weakenings = std.ArrayList(constraint_diff.CodeWeakening){};
+    for (diff.weakened_code) |w| {
+        const side, const line = anchorWeakening(w, changed) orelse {
+            try unanchored_weakenings.append(arena, w);
+            continue;
+        };
+        body.clearRetainingCapacity();
+        try body.writer(arena).print("**Check weakened (high severity):** `{s}`\n\n{s}", .{ w.subject, w.message });
+        try comments_writer.writeAll(if (comment_count == 0) "\n    {\"path\": \"" else ",\n    {\"path\": \"");
+        try output.writeJsonEscaped(comments_writer, normalize(w.file));
+        try comments_writer.print("\", \"line\": {d}, \"side\": \"{s}\", \"body\": \"", .{ line, @tagName(side) });
+        try output.writeJsonEscaped(comments_writer, body.items);
+        try comments_writer.writeAll("\"}");
+        comment_count += 1;
+    }
+
+    body.clearRetainingCapacity();
+    const body_writer = body.writer(arena);
+    try constraint_diff.writeMarkdownSummary(body_writer, diff, before_label, after_label);
+    if (comment_count > 0) {
+        try body_writer.print("\n{d} of {d} changes are commented on the lines they affect.\n", .{ comment_count, diff.changes.items.len + diff.weakened_code.len });
+    }
+    if (unanchored_weakenings.items.len > 0) {
+        try body_writer.writeAll("\n");
+        try constraint_diff.writeMarkdownWeakenings(body_writer, unanchored_weakenings.items);
+    }
+    if (unanchored.items.len > 0) {
+        try body_writer.writeAll("\n#### Outside the changed lines\n\n");
+        try constraint_diff.writeMarkdownTable(body_writer, unanchored.items);
+    }
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    try writer.writeAll("{\n  \"commit_id\": \"");
+    try output.writeJsonEscaped(writer, commit_id);
+    try writer.writeAll("\",\n  \"event\": \"COMMENT\",\n  \"body\": \"");
+    try output.writeJsonEscaped(writer, body.items);
+    try writer.writeAll("\",\n  \"comments\": [");
+    try writer.writeAll(comments.items);
+    try writer.writeAll(if (comment_count > 0) "\n  ]\n}\n" else "]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+fn writeComment(writer: anytype, change: constraint_diff.Change) !void {
+    const c = change.subject();
+    try writer.print("**Constraint {s}:** `{s}` ({s}, {s})\n\n{s}", .{
+        change.kind.label(),
+        c.name,
+        @tagName(c.kind),
+        constraint_diff.severityLabel(c.severity),
+        c.description,
+    });
+    if (change.before != null and change.after != null) {
+        const b = change.before.?;
+        const a = change.after.?;
+        try writer.print("\n\nSeverity {s} → {s}, confidence {d:.2} → {d:.2}", .{
+            constraint_diff.severityLabel(b.severity),
+            constraint_diff.severityLabel(a.severity),
+            b.confidence,
+            a.confidence,
+        });
+    }
+}
+
+/// Post `payload` as a review on pull request `number` of `repository`
+/// ("owner/name"). Returns the HTTP status; the response body is discarded.
+pub fn postReview(
+    allocator: std.mem.Allocator,
+    api_url: []const u8,
+    repository: []const u8,
+    number: u64,
+    token: []const u8,
+    payload: []const u8,
+) !u16 {
+    const url = try std.fmt.allocPrint(allocator, "{s}/repos/{s}/pulls/{d}/reviews", .{
+        std.mem.trimRight(u8, api_url, "/"),
+        repository,
+        number,
+    });
+    defer allocator.free(url);
+    const authorization = try std.fmt.allocPrint(allocator, "Bearer {s}", .{token});
+    defer allocator.free(authorization);
+
+    var client = std.http.Client{ .allocator = allocator };
+    defer client.deinit();
+    const result = try client.fetch(.{
+        .location = .{ .url = url },
+        .method = .POST,
+        .payload = payload,
+        .headers = .{ .content_type = .{ .override = "application/json" } },
+        .extra_headers = &.{
+            .{ .name = "authorization", .value = authorization },
+            .{ .name = "accept", .value = "application/vnd.github+json" },
+            .{ .name = "x-github-api-version", .value = "2022-11-28" },
+        },
+    });
+    return @intFromEnum(result.status);
+}
+
+test "review comments anchor to changed lines" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const changed = try parseUnifiedDiff(arena,
+        \\diff --git a/src/auth.go b/src/auth.go
+        \\index 1111111..2222222 100644
+        \\--- a/src/auth.go
+        \\+++ b/src/auth.go
+        \\@@ -10,2 +10,3 @@ func Login() {
+        \\--- a removed line that looks like a header
+        \\-old
+        \\+new
+        \\+++ an added line that looks like a header
+        \\+more
+        \\@@ -40 +41,0 @@
+        \\-gone
+        \\
+    );
+    try testing.expect(changed.contains(.RIGHT, "./src/auth.go", 12));
+    try testing.expect(!changed.contains(.RIGHT, "src/auth.go", 13));
+    try testing.expect(changed.contains(.LEFT, "src/auth.go", 40));
+    try testing.expect(!changed.contains(.RIGHT, "src/auth.go", 41));
+    try testing.expect(!changed.contains(.RIGHT, "src/other.go", 12));
+
+    const before = [_]constraint.Constraint{
+        .{ .name = "session_ttl", .description = "x", .kind = .security, .severity = .warning, .origin_file = "src/auth.go", .origin_line = 40 },
+    };
+    const after = [_]constraint.Constraint{
+        .{ .name = "csrf_token", .description = "Validate the CSRF token", .kind = .security, .severity = .err, .origin_file = "src/auth.go", .origin_line = 11 },
+        .{ .name = "retry_budget", .description = "x", .kind = .operational, .severity = .info, .origin_file = "src/auth.go", .origin_line = 90 },
+    };
+    var diff = try constraint_diff.compute(allocator, &before, &after);
+    defer diff.deinit();
+
+    const payload = try formatReview(allocator, diff, &changed, "abc123", "main", "HEAD");
+    defer allocator.free(payload);
+    const parsed = try std.json.parseFromSliceLeaky(std.json.Value, arena, payload, .{});
+    try testing.expectEqualStrings("abc123", parsed.object.get("commit_id").?.string);
+    const comments = parsed.object.get("comments").?.array.items;
+    try testing.expectEqual(@as(usize, 2), comments.len);
+    // Removed changes sort first within a file
+    try testing.expectEqual(@as(i64, 40), comments[0].object.get("line").?.integer);
+    try testing.expectEqualStrings("LEFT", comments[0].object.get("side").?.string);
+    try testing.expectEqual(@as(i64, 11), comments[1].object.get("line").?.integer);
+    try testing.expectEqualStrings("RIGHT", comments[1].object.get("side").?.string);
+    // The change off the diff is listed in the summary instead
+    try testing.expect(std.mem.indexOf(u8, parsed.object.get("body").?.string, "retry_budget") != null);
+}
@@ -0,0 +1,789 @@
+// Go API surface
+// Recovers the exported API of Go packages, everything other modules can
+// use: exported functions, methods of exported types, types, struct fields,
+// interface methods, constants, and variables, each with a normalized
+// signature (parameter names and spacing dropped), and compares two surfaces
+// for changes that break their users. Packages under internal/, main
+// packages, and tests cannot be imported and are left out. Parsing is
+// line-based and expects gofmt layout.
+const std = @import("std");
+const output = @import("cli_output");
+
+pub const SymbolKind = enum {
+    func,
+    method,
+    type_decl,
+    field,
+    interface_method,
+    constant,
+    variable,
+
+    pub fn label(self: SymbolKind) []const u8 {
+        return switch (self) {
+            .func => "func",
+            .method => "method",
+            .type_decl => "type",
+            .field => "field",
+            .interface_method => "interface method",
+            .constant => "const",
+            .variable => "var",
+        };
+    }
+};
+
+pub const Symbol = struct {
+    /// Directory of the package
+    package: []const u8,
+    /// `Name`, or `Type.Name` for methods, fields, and interface methods
+    name: []const u8,
+    kind: SymbolKind,
+    /// The signature of functions and methods (methods with their receiver),
+    /// the type of fields, constants, and variables ("" when inferred), and
+    /// what a type is defined as (`struct`, `interface`, or another type)
+    signature: []const u8,
+    file: []const u8,
+    line: u32,
+};
+
+const type_keywords = [_][]const u8{ "chan", "func", "map", "struct", "interface" };
+
+fn isIdent(text: []const u8) bool {
+    if (text.len == 0 or !(std.ascii.isAlphabetic(text[0]) or text[0] == '_')) return false;
+    for (text) |c| {
+        if (!std.ascii.isAlphanumeric(c) and c != '_') return false;
+    }
+    return true;
+}
+
+fn isExported(name: []const u8) bool {
+    return name.len > 0 and std.ascii.isUpper(name[0]);
+}
+
+fn identLen(text: []const u8) usize {
+    var n: usize = 0;
+    while (n < text.len and (std.ascii.isAlphanumeric(text[n]) or text[n] == '_')) n += 1;
+    return n;
+}
+
+/// Whether the Go file at `path` belongs to a package other modules can import
+pub fn isPublicFile(path: []const u8) bool {
+    if (!std.mem.endsWith(u8, path, ".go") or std.mem.endsWith(u8, path, "_test.go")) return false;
+    var parts = std.mem.tokenizeAny(u8, path, "/\\");
+    while (parts.next()) |part| {
+        for ([_][]const u8{ "internal", "testdata", "vendor" }) |hidden| {
+            if (std.mem.eql(u8, part, hidden)) return false;
+        }
+    }
+    return true;
+}
+
+/// Index of the bracket closing the one at `open`
+fn matchClose(text: []const u8, open: usize) ?usize {
+    var depth: usize = 0;
+    for (text[open..], open..) |c, i| {
+        switch (c) {
+            '(', '[', '{' => depth += 1,
+            ')', ']', '}' => {
+                depth -= 1;
+                if (depth == 0) return i;
+            },
+            else => {},
+        }
+    }
+    return null;
+}
+
+/// `text` with runs of whitespace collapsed to one space
+fn collapse(arena: std.mem.Allocator, text: []const u8) ![]const u8 {
+    var out = std.ArrayList(u8){};
+    var words = std.mem.tokenizeAny(u8, text, " \t\r\n");
+    while (words.next()) |word| {
+        if (out.items.len > 0) try out.append(arena, ' ');
+        try out.appendSlice(arena, word);
+    }
+    return out.items;
+}
+
+/// Comma-separated items of `text` outside brackets, trimmed, without empty ones
+fn splitTopLevel(arena: std.mem.Allocator, text: []const u8) ![]const []const u8 {
+    var parts = std.ArrayList([]const u8){};
+    var depth: usize = 0;
+    var start: usize = 0;
+    for (text, 0..) |c, i| {
+        switch (c) {
+            '(', '[', '{' => depth += 1,
+            ')', ']', '}' => depth -|= 1,
+            ',' => if (depth == 0) {
+                const part = std.mem.trim(u8, text[start..i], " \t\r\n");
+                if (part.len > 0) try parts.append(arena, part);
+                start = i + 1;
+            },
+            else => {},
+        }
+    }
+    const last = std.mem.trim(u8, text[start..], " \t\r\n");
+    if (last.len > 0) try parts.append(arena, last);
+    return parts.items;
+}
+
+/// Name of a `name Type` parameter
+fn paramName(part: []const u8) ?[]const u8 {
+    const space = std.mem.indexOfAny(u8, part, " \t") orelse return null;
+    const name = part[0..space];
+    if (!isIdent(name)) return null;
+    for (type_keywords) |keyword| {
+        if (std.mem.eql(u8, name, keyword)) return null;
+    }
+    return name;
+}
+
+/// Types of a parameter list, without names: `a, b int, opts ...Option`
+/// becomes `int, int, ...Option`
+fn listTypes(arena: std.mem.Allocator, list: []const u8) ![]const u8 {
+    const parts = try splitTopLevel(arena, list);
+    var named = false;
+    for (parts) |part| {
+        if (paramName(part) != null) named = true;
+    }
+    var types = std.ArrayList([]const u8){};
+    for (parts, 0..) |part, i| {
+        if (!named) {
+            try types.append(arena, try collapse(arena, part));
+        } else if (paramName(part)) |name| {
+            try types.append(arena, try collapse(arena, part[name.len..]));
+        } else {
+            // A name sharing the type of the next typed parameter
+            var j = i + 1;
+            const shared = while (j < parts.len) : (j += 1) {
+                if (paramName(parts[j])) |name| break parts[j][name.len..];
+            } else part;
+            try types.append(arena, try collapse(arena, shared));
+        }
+    }
+    return std.mem.join(arena, ", ", types.items);
+}
+
+/// Results of a signature without names; `(n int, err error)` becomes `(int, error)`
+fn resultTypes(arena: std.mem.Allocator, results: []const u8) ![]const u8 {
+    if (results.len == 0) return "";
+    if (results[0] == '(') {
+        if (matchClose(results, 0)) |close| {
+            if (close == results.len - 1) return std.fmt.allocPrint(arena, "({s})", .{try listTypes(arena, results[1..close])});
+        }
+    }
+    return collapse(arena, results);
+}
+
+/// Cut the opening brace of a body off the results of a signature, leaving
+/// the braces of `struct{}` and `interface{}` result types
+fn cutBody(results: []const u8) []const u8 {
+    var i: usize = 0;
+    while (i < results.len) : (i += 1) {
+        switch (results[i]) {
+            '(', '[' => i = matchClose(results, i) orelse return results,
+            '{' => {
+                const before = std.mem.trimRight(u8, results[0..i], " ");
+                if (std.mem.endsWith(u8, before, "struct") or std.mem.endsWith(u8, before, "interface")) {
+                    i = matchClose(results, i) orelse return results;
+                    continue;
+                }
+                return std.mem.trimRight(u8, results[0..i], " ");
+            },
+            else => {},
+        }
+    }
+    return results;
+}
+
+const Func = struct {
+    /// Without type arguments, with '*' for pointer receivers
+    receiver: ?[]const u8 = null,
+    name: []const u8,
+    signature: []const u8,
+};
+
+/// `text` is a declaration after `func `, or an interface method
+fn parseFunc(arena: std.mem.Allocator, text: []const u8) !?Func {
+    var rest = text;
+    var func = Func{ .name = "", .signature = "" };
+    if (std.mem.startsWith(u8, rest, "(")) {
+        const close = matchClose(rest, 0) orelse return null;
+        var words = std.mem.tokenizeScalar(u8, rest[1..close], ' ');
+        var receiver = words.next() orelse return null;
+        if (words.next()) |receiver_type| receiver = receiver_type;
+        if (std.mem.indexOfScalar(u8, receiver, '[')) |bracket| receiver = receiver[0..bracket];
+        func.receiver = receiver;
+        rest = std.mem.trimLeft(u8, rest[close + 1 ..], " ");
+    }
+    const name_len = identLen(rest);
+    func.name = rest[0..name_len];
+    rest = rest[name_len..];
+    var type_params: []const u8 = "";
+    if (std.mem.startsWith(u8, rest, "[")) {
+        const close = matchClose(rest, 0) orelse return null;
+        type_params = try collapse(arena, rest[0 .. close + 1]);
+        rest = rest[close + 1 ..];
+    }
+    if (!isIdent(func.name) or !std.mem.startsWith(u8, rest, "(")) return null;
+    const close = matchClose(rest, 0) orelse return null;
+    const params = try listTypes(arena, rest[1..close]);
+    const results = try resultTypes(arena, cutBody(std.mem.trim(u8, rest[close + 1 ..], " \t\r")));
+
+    func.signature = try std.fmt.allocPrint(arena, "{s}{s}{s}func{s}({s}){s}{s}", .{
+        if (func.receiver != null) "(" else "",
+        func.receiver orelse "",
+        if (func.receiver != null) ") " else "",
+        type_params,
+        params,
+        if (results.len > 0) " " else "",
+        results,
+    });
+    return func;
+}
+
+/// `X, Y int` or `Name = value`: the names and what follows them
+const Spec = struct {
+    names: []const []const u8,
+    rest: []const u8,
+};
+
+fn parseSpec(arena: std.mem.Allocator, line: []const u8) !?Spec {
+    var names = std.ArrayList([]const u8){};
+    var i: usize = 0;
+    while (true) {
+        const n = identLen(line[i..]);
+        if (n == 0) return null;
+        try names.append(arena, line[i .. i + n]);
+        i += n;
+        if (!std.mem.startsWith(u8, line[i..], ", ")) break;
+        i += 2;
+    }
+    if (i < line.len and line[i] != ' ' and line[i] != '\t') return null;
+    return .{ .names = names.items, .rest = std.mem.trim(u8, line[i..], " \t\r") };
+}
+
+/// A line without its trailing comment and struct tag
+fn stripLine(line: []const u8) []const u8 {
+    var text = std.mem.trim(u8, line, " \t\r");
+    if (std.mem.indexOf(u8, text, "//")) |at| text = text[0..at];
+    if (std.mem.indexOfScalar(u8, text, '`')) |at| text = text[0..at];
+    return std.mem.trimRight(u8, text, " \t");
+}
+
+const Parser = struct {
+    arena: std.mem.Allocator,
+    package: []const u8,
+    file: []const u8,
+    lines: []const []const u8,
+    symbols: std.ArrayList(Symbol) = .{},
+
+    fn add(self: *Parser, name: []const u8, kind: SymbolKind, signature: []const u8, index: usize) !void {
+        try self.symbols.append(self.arena, .{
+            .package = self.package,
+            .name = name,
+            .kind = kind,
+            .signature = signature,
+            .file = self.file,
+            .line = @intCast(index + 1),
+        });
+    }
+
+    fn member(self: *Parser, owner: []const u8, name: []const u8) ![]const u8 {
+        return std.fmt.allocPrint(self.arena, "{s}.{s}", .{ owner, name });
+    }
+
+    /// A function declaration starting on line `i.*`, whose parameters may
+    /// span several lines
+    fn function(self: *Parser, i: *usize) !void {
+        var text = self.lines[i.*]["func ".len..];
+        var joined = std.ArrayList(u8){};
+        while (std.mem.count(u8, text, "(") > std.mem.count(u8, text, ")") and i.* + 1 < self.lines.len) {
+            if (joined.items.len == 0) try joined.appendSlice(self.arena, text);
+            i.* += 1;
+            try joined.append(self.arena, ' ');
+            try joined.appendSlice(self.arena, std.mem.trim(u8, self.lines[i.*], " \t\r"));
+            text = joined.items;
+        }
+        const start = i.*;
+        const func = try parseFunc(self.arena, text) orelse return;
+        if (!isExported(func.name)) return;
+        if (func.receiver) |receiver| {
+            const owner = std.mem.trimLeft(u8, receiver, "*");
+            if (!isExported(owner)) return;
+            try self.add(try self.member(owner, func.name), .method, func.signature, start);
+        } else {
+            try self.add(func.name, .func, func.signature, start);
+        }
+    }
+
+    /// A type spec on line `i.*`; `close` is the line ending its body
+    fn typeSpec(self: *Parser, spec: []const u8, i: *usize, close: []const u8) !void {
+        const start = i.*;
+        const name_len = identLen(spec);
+        const name = spec[0..name_len];
+        var rest = spec[name_len..];
+        var type_params: []const u8 = "";
+        if (std.mem.startsWith(u8, rest, "[")) {
+            const end = matchClose(rest, 0) orelse return;
+            type_params = try collapse(self.arena, rest[0 .. end + 1]);
+            rest = rest[end + 1 ..];
+        }
+        rest = std.mem.trim(u8, rest, " \t\r");
+
+        const body: ?SymbolKind = if (std.mem.eql(u8, rest, "struct {"))
+            .field
+        else if (std.mem.eql(u8, rest, "interface {"))
+            .interface_method
+        else
+            null;
+        const definition = if (body) |kind|
+            (if (kind == .field) "struct" else "interface")
+        else if (std.mem.eql(u8, rest, "struct{}") or std.mem.eql(u8, rest, "interface{}"))
+            rest[0 .. rest.len - 2]
+        else
+            try collapse(self.arena, rest);
+        const exported = name_len > 0 and isExported(name);
+        if (exported) {
+            const signature = if (type_params.len > 0) try std.fmt.allocPrint(self.arena, "{s} {s}", .{ type_params, definition }) else definition;
+            try self.add(name, .type_decl, signature, start);
+        }
+        const kind = body orelse return;
+
+        // Members, skipping the bodies of nested anonymous structs
+        var depth: usize = 0;
+        while (i.* + 1 < self.lines.len) {
+            i.* += 1;
+            const raw = std.mem.trimRight(u8, self.lines[i.*], " \t\r");
+            if (std.mem.eql(u8, raw, close)) return;
+            const line = stripLine(raw);
+            if (line.len == 0) continue;
+            if (std.mem.startsWith(u8, line, "}")) {
+                depth -|= 1;
+                continue;
+            }
+            const opens = std.mem.endsWith(u8, line, "{");
+            if (depth > 0 or !exported) {
+                if (opens) depth += 1;
+                continue;
+            }
+            // The member's own body follows
+            if (opens) depth += 1;
+
+            if (kind == .interface_method) {
+                if (std.mem.indexOfScalar(u8, line, '(')) |_| {
+                    const func = try parseFunc(self.arena, line) orelse continue;
+                    if (isExported(func.name)) try self.add(try self.member(name, func.name), .interface_method, func.signature, i.*);
+                } else if (std.mem.indexOfAny(u8, line, "|~") == null) {
+                    // An embedded interface
+                    const start_at = if (std.mem.lastIndexOfScalar(u8, line, '.')) |dot| dot + 1 else 0;
+                    const embedded = line[start_at..];
+                    if (isExported(embedded)) try self.add(try self.member(name, embedded), .interface_method, try std.fmt.allocPrint(self.arena, "embedded {s}", .{line}), i.*);
+                }
+                continue;
+            }
+
+            if (try parseSpec(self.arena, line)) |field| {
+                if (field.rest.len > 0) {
+                    const field_type = if (opens) "struct" else try collapse(self.arena, field.rest);
+                    for (field.names) |field_name| {
+                        if (isExported(field_name)) try self.add(try self.member(name, field_name), .field, field_type, i.*);
+                    }
+                    continue;
+                }
+            }
+            // An embedded type, promoted under its own name
+            const base = std.mem.trimLeft(u8, line, "*");
+            const start_at = if (std.mem.lastIndexOfScalar(u8, base, '.')) |dot| dot + 1 else 0;
+            const embedded = base[start_at..];
+            const type_name = embedded[0 .. std.mem.indexOfScalar(u8, embedded, '[') orelse embedded.len];
+            if (isExported(type_name)) try self.add(try self.member(name, type_name), .field, try std.fmt.allocPrint(self.arena, "embedded {s}", .{line}), i.*);
+        }
+    }
+
+    /// A const or var spec; `last_type` carries the type of the previous
+    /// constant of a group to the ones repeating it
+    fn valueSpec(self: *Parser, kind: SymbolKind, line: []const u8, index: usize, last_type: *[]const u8) !void {
+        const spec = try parseSpec(self.arena, stripLine(line)) orelse return;
+        var value_type: []const u8 = "";
+        if (spec.rest.len == 0) {
+            value_type = last_type.*;
+        } else if (!std.mem.startsWith(u8, spec.rest, "=")) {
+            const eq = std.mem.indexOf(u8, spec.rest, " =") orelse spec.rest.len;
+            value_type = try collapse(self.arena, spec.rest[0..eq]);
+        }
+        last_type.* = value_type;
+        for (spec.names) |name| {
+            if (isExported(name)) try self.add(name, kind, value_type, index);
+        }
+    }
+};
+
+/// Exported declarations of the Go `source` at `path`; none for main
+/// packages. Everything lives in `arena` or borrows `path` and `source`.
+pub fn parse(arena: std.mem.Allocator, path: []const u8, source: []const u8) ![]const Symbol {
+    var lines = std.ArrayList([]const u8){};
+    var it = std.mem.splitScalar(u8, source, '\n');
+    while (it.next()) |line| try lines.append(arena, line);
+
+    var parser = Parser{
+        .arena = arena,
+        .package = std.fs.path.dirname(path) orelse ".",
+        .file = path,
+        .lines = lines.items,
+    };
+    var i: usize = 0;
+    while (i < lines.items.len) : (i += 1) {
+        const line = std.mem.trimRight(u8, lines.items[i], " \t\r");
+        if (std.mem.eql(u8, line, "package main")) return &.{};
+        if (std.mem.startsWith(u8, line, "func ")) {
+            try parser.function(&i);
+        } else if (std.mem.eql(u8, line, "type (") or std.mem.eql(u8, line, "const (") or std.mem.eql(u8, line, "var (")) {
+            const group = line[0..std.mem.indexOfScalar(u8, line, ' ').?];
+            var last_type: []const u8 = "";
+            while (i + 1 < lines.items.len) {
+                i += 1;
+                const entry = std.mem.trimRight(u8, lines.items[i], " \t\r");
+                if (std.mem.eql(u8, entry, ")")) break;
+                // Entries sit one tab in; deeper lines continue an entry
+                if (!std.mem.startsWith(u8, entry, "\t") or std.mem.startsWith(u8, entry, "\t\t")) continue;
+                if (std.mem.eql(u8, group, "type")) {
+                    try parser.typeSpec(entry[1..], &i, "\t}");
+                } else {
+                    try parser.valueSpec(if (std.mem.eql(u8, group, "const")) .constant else .variable, entry[1..], i, &last_type);
+                }
+            }
+        } else if (std.mem.startsWith(u8, line, "type ")) {
+            try parser.typeSpec(line["type ".len..], &i, "}");
+        } else if (std.mem.startsWith(u8, line, "const ") or std.mem.startsWith(u8, line, "var ")) {
+            var last_type: []const u8 = "";
+            const is_const = line[0] == 'c';
+            try parser.valueSpec(if (is_const) .constant else .variable, line[if (is_const) "const ".len else "var ".len ..], i, &last_type);
+        }
+    }
+    return parser.symbols.items;
+}
+
+pub const ChangeKind = enum {
+    removed,
+    changed,
+    added,
+
+    pub fn label(self: ChangeKind) []const u8 {
+        return @tagName(self);
+    }
+};
+
+pub const Change = struct {
+    kind: ChangeKind,
+    /// Code using the package can stop compiling: a removal, a changed
+    /// declaration, or a method added to an interface (its implementations
+    /// no longer satisfy it)
+    breaking: bool,
+    before: ?Symbol = null,
+    after: ?Symbol = null,
+
+    pub fn subject(self: Change) Symbol {
+        return self.after orelse self.before.?;
+    }
+
+    fn lessThan(_: void, a: Change, b: Change) bool {
+        const sa = a.subject();
+        const sb = b.subject();
+        const by_package = std.mem.order(u8, sa.package, sb.package);
+        if (by_package != .eq) return by_package == .lt;
+        return std.mem.lessThan(u8, sa.name, sb.name);
+    }
+};
+
+/// Release level a set of changes needs under semantic versioning
+pub const Bump = enum {
+    patch,
+    minor,
+    major,
+};
+
+pub const Report = struct {
+    /// Sorted by package and name
+    changes: []const Change,
+    unchanged: usize,
+
+    pub fn breaking(self: Report) usize {
+        var n: usize = 0;
+        for (self.changes) |change| {
+            if (change.breaking) n += 1;
+        }
+        return n;
+    }
+
+    pub fn bump(self: Report) Bump {
+        if (self.breaking() > 0) return .major;
+        return if (self.changes.len > 0) .minor else .patch;
+    }
+};
+
+fn key(arena: std.mem.Allocator, package: []const u8, name: []const u8) ![]const u8 {
+    return std.fmt.allocPrint(arena, "{s}\x00{s}", .{ package, name });
+}
+
+/// Owner type of a member symbol
+fn owner(s: Symbol) ?[]const u8 {
+    return switch (s.kind) {
+        .method, .field, .interface_method => s.name[0..std.mem.indexOfScalar(u8, s.name, '.').?],
+        else => null,
+    };
+}
+
+/// Changes from `before` to `after`. A removed type is reported once, not
+/// with each of its members.
+pub fn compare(arena: std.mem.Allocator, before: []const Symbol, after: []const Symbol) !Report {
+    var old = std.StringHashMap(Symbol).init(arena);
+    for (before) |s| try old.put(try key(arena, s.package, s.name), s);
+    var new = std.StringHashMap(Symbol).init(arena);
+    for (after) |s| try new.put(try key(arena, s.package, s.name), s);
+
+    var changes = std.ArrayList(Change){};
+    var unchanged: usize = 0;
+    for (after) |s| {
+        if (old.get(try key(arena, s.package, s.name))) |prev| {
+            if (prev.kind == s.kind and std.mem.eql(u8, prev.signature, s.signature)) {
+                unchanged += 1;
+            } else {
+                try changes.append(arena, .{ .kind = .changed, .breaking = true, .before = prev, .after = s });
+            }
+            continue;
+        }
+        const breaking = s.kind == .interface_method and old.contains(try key(arena, s.package, owner(s).?));
+        try changes.append(arena, .{ .kind = .added, .breaking = breaking, .after = s });
+    }
+    for (before) |s| {
+        if (new.contains(try key(arena, s.package, s.name))) continue;
+        if (owner(s)) |type_name| {
+            if (!new.contains(try key(arena, s.package, type_name))) continue;
+        }
+        try changes.append(arena, .{ .kind = .removed, .breaking = true, .before = s });
+    }
+    std.mem.sort(Change, changes.items, {}, Change.lessThan);
+    return .{ .changes = changes.items, .unchanged = unchanged };
+}
+
+/// Human-readable report
+pub fn formatText(allocator: std.mem.Allocator, report: Report, before_label: []const u8, after_label: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    const breaking = report.breaking();
+    try writer.print("API changes {s}..{s}\n", .{ before_label, after_label });
+    try writer.print("  {d} breaking, {d} compatible, {d} unchanged; needs a {s} release\n", .{
+        breaking,
+        report.changes.len - breaking,
+        report.unchanged,
+        @tagName(report.bump()),
+    });
+
+    var current: ?[]const u8 = null;
+    for (report.changes) |change| {
+        const s = change.subject();
+        if (current == null or !std.mem.eql(u8, current.?, s.package)) {
+            try writer.print("\n{s}\n", .{s.package});
+            current = s.package;
+        }
+        try writer.print("  {s} {s} {s} {s} ({s}:{d})\n", .{
+            if (change.breaking) "!" else "+",
+            change.kind.label(),
+            s.kind.label(),
+            s.name,
+            s.file,
+            s.line,
+        });
+        if (change.kind == .changed) {
+            try writer.print("      was: {s}\n      now: {s}\n", .{ change.before.?.signature, change.after.?.signature });
+        }
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+/// Markdown report suitable for a pull request comment
+pub fn formatMarkdown(allocator: std.mem.Allocator, report: Report, before_label: []const u8, after_label: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    const breaking = report.breaking();
+    try writer.print("### API changes `{s}..{s}`\n\n", .{ before_label, after_label });
+    try writer.print("Needs a **{s}** release: {d} breaking, {d} compatible, {d} unchanged.\n", .{
+        @tagName(report.bump()),
+        breaking,
+        report.changes.len - breaking,
+        report.unchanged,
+    });
+    if (report.changes.len == 0) return list.toOwnedSlice(allocator);
+
+    try writer.writeAll("\n| Change | Package | Symbol | Before | After |\n|---|---|---|---|---|\n");
+    for (report.changes) |change| {
+        const s = change.subject();
+        try writer.print("| {s}{s} | `{s}` | {s} `{s}` | ", .{
+            if (change.breaking) "**breaking** " else "",
+            change.kind.label(),
+            s.package,
+            s.kind.label(),
+            s.name,
+        });
+        try writeCodeCell(writer, if (change.before) |b| b.signature else "");
+        try writer.writeAll(" | ");
+        try writeCodeCell(writer, if (change.after) |a| a.signature else "");
+        try writer.writeAll(" |\n");
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+fn writeCodeCell(writer: anytype, s: []const u8) !void {
+    if (s.len == 0) return;
+    try writer.writeByte('`');
+    for (s) |c| {
+        if (c == '|') try writer.writeAll("\\|") else try writer.writeByte(c);
+    }
+    try writer.writeByte('`');
+}
+
+/// Machine-readable report
+pub fn formatJson(allocator: std.mem.Allocator, report: Report, before_label: []const u8, after_label: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    const breaking = report.breaking();
+    try writer.writeAll("{\n  \"before\": \"");
+    try output.writeJsonEscaped(writer, before_label);
+    try writer.writeAll("\",\n  \"after\": \"");
+    try output.writeJsonEscaped(writer, after_label);
+    try writer.print("\",\n  \"bump\": \"{s}\",\n  \"summary\": {{\"breaking\": {d}, \"compatible\": {d}, \"unchanged\": {d}}},\n", .{
+        @tagName(report.bump()),
+        breaking,
+        report.changes.len - breaking,
+        report.unchanged,
+    });
+    try writer.writeAll("  \"changes\": [\n");
+    for (report.changes, 0..) |change, i| {
+        const s = change.subject();
+        try writer.print("    {{\"change\": \"{s}\", \"breaking\": {s}, \"kind\": \"{s}\", \"package\": \"", .{
+            change.kind.label(),
+            if (change.breaking) "true" else "false",
+            s.kind.label(),
+        });
+        try output.writeJsonEscaped(writer, s.package);
+        try writer.writeAll("\", \"name\": \"");
+        try output.writeJsonEscaped(writer, s.name);
+        if (change.before) |b| {
+            try writer.writeAll("\", \"before\": \"");
+            try output.writeJsonEscaped(writer, b.signature);
+        }
+        if (change.after) |a| {
+            try writer.writeAll("\", \"after\": \"");
+            try output.writeJsonEscaped(writer, a.signature);
+        }
+        try writer.writeAll("\", \"file\": \"");
+        try output.writeJsonEscaped(writer, s.file);
+        try writer.print("\", \"line\": {d}}}", .{s.line});
+        if (i + 1 < report.changes.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("  ]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+test "parse exported API and classify changes" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const before_source =
+        \\package client
+        \\
+        \\const DefaultTimeout time.Duration = 30
+        \\
+        \\type Client struct {
+        \\	BaseURL string `json:"base_url"`
+        \\	Retries, Backoff int
+        \\	http    *http.Client
+        \\}
+        \\
+        \\type Doer interface {
+        \\	Do(req *Request) (*Response, error)
+        \\}
+        \\
+        \\type Legacy struct {
+        \\	Name string
+        \\}
+        \\
+        \\func New(baseURL string, opts ...Option) *Client {
+        \\	return &Client{BaseURL: baseURL}
+        \\}
+        \\
+        \\func (c *Client) Do(req *Request) (resp *Response, err error) {
+        \\	return nil, nil
+        \\}
+        \\
+        \\func (c *Client) Close() error {
+        \\	return nil
+        \\}
+        \\
+        \\func helper() {}
+    ;
+    const after_source =
+        \\package client
+        \\
+        \\const DefaultTimeout time.Duration = 30
+        \\
+        \\type Client struct {
+        \\	BaseURL string `json:"url"`
+        \\	Retries, Backoff int
+        \\	Logger  *slog.Logger
+        \\}
+        \\
+        \\type Doer interface {
+        \\	Do(req *Request) (*Response, error)
+        \\	Close() error
+        \\}
+        \\
+        \\func New(url string, opts ...Option) *Client {
+        \\	return &Client{BaseURL: url}
+        \\}
+        \\
+        \\func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
+        \\	return nil, nil
+        \\}
+    ;
+    const before = try parse(arena, "client/client.go", before_source);
+    try testing.expectEqual(@as(usize, 12), before.len);
+    try testing.expectEqualStrings("DefaultTimeout", before[0].name);
+    try testing.expectEqualStrings("time.Duration", before[0].signature);
+    try testing.expectEqualStrings("Client.Backoff", before[4].name);
+    try testing.expectEqualStrings("func(*Request) (*Response, error)", before[6].signature);
+    try testing.expectEqualStrings("func(string, ...Option) *Client", before[9].signature);
+    try testing.expectEqualStrings("(*Client) func(*Request) (*Response, error)", before[10].signature);
+    try testing.expectEqual(@as(usize, 0), (try parse(arena, "cmd/tool/main.go", "package main\n\nfunc Run() {}\n")).len);
+
+    const after = try parse(arena, "client/client.go", after_source);
+    const report = try compare(arena, before, after);
+    // Renamed parameters and struct tags are not API changes
+    try testing.expectEqual(@as(usize, 8), report.unchanged);
+    try testing.expectEqual(@as(usize, 5), report.changes.len);
+    try testing.expectEqual(Bump.major, report.bump());
+    try testing.expectEqual(@as(usize, 4), report.breaking());
+
+    // Sorted by name: Client.Close, Client.Do, Client.Logger, Doer.Close, Legacy
+    try testing.expectEqual(ChangeKind.removed, report.changes[0].kind);
+    try testing.expectEqualStrings("Client.Close", report.changes[0].subject().name);
+    try testing.expectEqual(ChangeKind.changed, report.changes[1].kind);
+    try testing.expect(!report.changes[2].breaking);
+    try testing.expectEqual(SymbolKind.interface_method, report.changes[3].subject().kind);
+    try testing.expect(report.changes[3].breaking);
+    // Legacy.Name goes with its type
+    try testing.expectEqualStrings("Legacy", report.changes[4].subject().name);
+
+    try testing.expect(isPublicFile("client/client.go"));
+    try testing.expect(!isPublicFile("client/client_test.go"));
+    try testing.expect(!isPublicFile("internal/auth/token.go"));
+}
@@ -0,0 +1,425 @@
+// Go HTTP endpoints
+// Recovers the HTTP contract of Go services: route registrations (net/http
+// and Go 1.22 method patterns, gorilla/mux `.Methods`, gin/echo `r.POST`,
+// chi `r.Post`) and, for net/http handlers, what their code enforces before
+// doing any work: the method they accept, the request DTO they decode, whether
+// they validate it, and the status each rejection answers with. Parsing is
+// line-based and expects gofmt layout.
+const std = @import("std");
+const go_rules = @import("cli_go_rules");
+
+pub const Route = struct {
+    /// Upper-case method, when the registration restricts it
+    method: ?[]const u8 = null,
+    path: []const u8,
+    /// Last identifier of the handler expression (`h.CreateUser` → CreateUser)
+    handler: []const u8,
+    line: u32,
+};
+
+/// A `func(w http.ResponseWriter, r *http.Request)` handler
+pub const Handler = struct {
+    name: []const u8,
+    /// Type of the method receiver, for method handlers
+    receiver_type: ?[]const u8 = null,
+    pointer_receiver: bool = false,
+    line: u32,
+    /// Line of the closing brace
+    end_line: u32,
+    /// Method accepted by an `r.Method != ...` check, and the status others get
+    method: ?[]const u8 = null,
+    method_status: ?u16 = null,
+    /// Type decoded from the JSON body, and the status of a malformed body
+    dto: ?[]const u8 = null,
+    decode_status: ?u16 = null,
+    /// Variable the body is decoded into, and the line after which it holds
+    /// the decoded value (the end of the malformed-body branch)
+    dto_var: ?[]const u8 = null,
+    decoded_line: ?u32 = null,
+    /// The decoded DTO is validated, and the status of an invalid one
+    validates: bool = false,
+    validation_status: ?u16 = null,
+    /// Every status the handler writes, in order of first appearance
+    statuses: []const u16 = &.{},
+};
+
+pub const File = struct {
+    routes: []const Route = &.{},
+    handlers: []const Handler = &.{},
+};
+
+const status_names = [_]struct { name: []const u8, code: u16 }{
+    .{ .name = "StatusOK", .code = 200 },
+    .{ .name = "StatusCreated", .code = 201 },
+    .{ .name = "StatusAccepted", .code = 202 },
+    .{ .name = "StatusNoContent", .code = 204 },
+    .{ .name = "StatusMovedPermanently", .code = 301 },
+    .{ .name = "StatusFound", .code = 302 },
+    .{ .name = "StatusNotModified", .code = 304 },
+    .{ .name = "StatusBadRequest", .code = 400 },
+    .{ .name = "StatusUnauthorized", .code = 401 },
+    .{ .name = "StatusForbidden", .code = 403 },
+    .{ .name = "StatusNotFound", .code = 404 },
+    .{ .name = "StatusMethodNotAllowed", .code = 405 },
+    .{ .name = "StatusConflict", .code = 409 },
+    .{ .name = "StatusGone", .code = 410 },
+    .{ .name = "StatusRequestEntityTooLarge", .code = 413 },
+    .{ .name = "StatusUnsupportedMediaType", .code = 415 },
+    .{ .name = "StatusUnprocessableEntity", .code = 422 },
+    .{ .name = "StatusTooManyRequests", .code = 429 },
+    .{ .name = "StatusInternalServerError", .code = 500 },
+    .{ .name = "StatusNotImplemented", .code = 501 },
+    .{ .name = "StatusBadGateway", .code = 502 },
+    .{ .name = "StatusServiceUnavailable", .code = 503 },
+    .{ .name = "StatusGatewayTimeout", .code = 504 },
+};
+
+/// `http.StatusBadRequest` for 400, or null for codes without a constant here
+pub fn statusName(code: u16) ?[]const u8 {
+    for (status_names) |entry| {
+        if (entry.code == code) return entry.name;
+    }
+    return null;
+}
+
+const methods = [_][]const u8{ "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS" };
+
+fn methodOf(text: []const u8) ?[]const u8 {
+    for (methods) |m| {
+        if (std.ascii.eqlIgnoreCase(text, m)) return m;
+    }
+    return null;
+}
+
+/// First status a line writes: `http.StatusX`, or a literal passed to
+/// WriteHeader or http.Error
+fn statusIn(line: []const u8) ?u16 {
+    if (std.mem.indexOf(u8, line, "http.Status")) |at| {
+        const rest = line[at + "http.".len ..];
+        var end: usize = 0;
+        while (end < rest.len and std.ascii.isAlphanumeric(rest[end])) end += 1;
+        for (status_names) |entry| {
+            if (std.mem.eql(u8, rest[0..end], entry.name)) return entry.code;
+        }
+    }
+    for ([_][]const u8{ "WriteHeader(", "Error(" }) |call| {
+        const at = std.mem.indexOf(u8, line, call) orelse continue;
+        const close = std.mem.lastIndexOfScalar(u8, line, ')') orelse continue;
+        if (close <= at) continue;
+        var args = std.mem.splitBackwardsScalar(u8, line[at + call.len .. close], ',');
+        const last = std.mem.trim(u8, args.first(), " ");
+        const code = std.fmt.parseInt(u16, last, 10) catch continue;
+        if (code >= 100 and code < 600) return code;
+    }
+    return null;
+}
+
+/// Status written in the block opened on line `i`
+fn blockStatus(body: []const []const u8, i: usize) ?u16 {
+    var j = i + 1;
+    while (j < body.len and j <= i + 8) : (j += 1) {
+        const line = std.mem.trim(u8, body[j], " \t\r");
+        if (std.mem.eql(u8, line, "}")) break;
+        if (statusIn(line)) |code| return code;
+    }
+    return null;
+}
+
+/// Index of the `}` closing the block opened on body[i], by gofmt
+/// indentation; null when the block continues with an else
+fn blockEnd(body: []const []const u8, i: usize) ?usize {
+    const indent = body[i].len - std.mem.trimLeft(u8, body[i], " \t").len;
+    var j = i + 1;
+    while (j < body.len) : (j += 1) {
+        const trimmed = std.mem.trimLeft(u8, body[j], " \t");
+        if (body[j].len - trimmed.len != indent) continue;
+        return if (std.mem.eql(u8, std.mem.trimRight(u8, trimmed, " \r"), "}")) j else null;
+    }
+    return null;
+}
+
+/// First string literal of `text`
+fn stringLiteral(text: []const u8) ?[]const u8 {
+    const open = std.mem.indexOfScalar(u8, text, '"') orelse return null;
+    const close = std.mem.indexOfScalarPos(u8, text, open + 1, '"') orelse return null;
+    return text[open + 1 .. close];
+}
+
+/// Trailing identifier of an expression: `http.HandlerFunc(h.Create)` → Create
+fn lastIdent(expr: []const u8) []const u8 {
+    const text = std.mem.trimRight(u8, expr, " )");
+    var start = text.len;
+    while (start > 0 and (std.ascii.isAlphanumeric(text[start - 1]) or text[start - 1] == '_')) start -= 1;
+    return text[start..];
+}
+
+/// A route registered on `line`, if any
+fn parseRoute(line: []const u8, line_no: u32) ?Route {
+    const trimmed = std.mem.trim(u8, line, " \t\r");
+    const calls = [_]struct { call: []const u8, method: ?[]const u8 }{
+        .{ .call = ".HandleFunc(", .method = null },
+        .{ .call = ".Handle(", .method = null },
+        .{ .call = ".GET(", .method = "GET" },
+        .{ .call = ".POST(", .method = "POST" },
+        .{ .call = ".PUT(", .method = "PUT" },
+        .{ .call = ".PATCH(", .method = "PATCH" },
+        .{ .call = ".DELETE(", .method = "DELETE" },
+        .{ .call = ".Get(", .method = "GET" },
+        .{ .call = ".Post(", .method = "POST" },
+        .{ .call = ".Put(", .method = "PUT" },
+        .{ .call = ".Patch(", .method = "PATCH" },
+        .{ .call = ".Delete(", .method = "DELETE" },
+    };
+    for (calls) |entry| {
+        const at = std.mem.indexOf(u8, trimmed, entry.call) orelse continue;
+        const args = trimmed[at + entry.call.len ..];
+        if (!std.mem.startsWith(u8, args, "\"")) continue;
+        const pattern = stringLiteral(args) orelse continue;
+        const comma = std.mem.indexOfScalarPos(u8, args, pattern.len + 2, ',') orelse continue;
+
+        // The handler argument ends at the call's closing parenthesis
+        var depth: usize = 1;
+        var end = comma + 1;
+        while (end < args.len) : (end += 1) {
+            switch (args[end]) {
+                '(' => depth += 1,
+                ')' => {
+                    depth -= 1;
+                    if (depth == 0) break;
+                },
+                else => {},
+            }
+        }
+        var route = Route{ .method = entry.method, .path = pattern, .handler = lastIdent(args[comma + 1 .. end]), .line = line_no };
+        if (route.handler.len == 0) continue;
+
+        // Go 1.22 patterns: "POST /users"
+        if (std.mem.indexOfScalar(u8, pattern, ' ')) |space| {
+            if (methodOf(pattern[0..space])) |m| {
+                route.method = m;
+                route.path = std.mem.trimLeft(u8, pattern[space + 1 ..], " ");
+            }
+        }
+        // gorilla/mux: .Methods("POST")
+        if (std.mem.indexOf(u8, args[end..], ".Methods(")) |m_at| {
+            if (stringLiteral(args[end + m_at ..])) |m| route.method = methodOf(m) orelse route.method;
+        }
+        if (!std.mem.startsWith(u8, route.path, "/")) continue;
+        return route;
+    }
+    return null;
+}
+
+/// Names of the ResponseWriter and Request parameters
+fn handlerParams(params: []const u8) ?[]const u8 {
+    var writer_seen = false;
+    var request: ?[]const u8 = null;
+    var parts = std.mem.splitScalar(u8, params, ',');
+    while (parts.next()) |part| {
+        var words = std.mem.tokenizeScalar(u8, part, ' ');
+        const name = words.next() orelse return null;
+        const param_type = words.next() orelse return null;
+        if (std.mem.eql(u8, param_type, "http.ResponseWriter")) {
+            writer_seen = true;
+        } else if (std.mem.eql(u8, param_type, "*http.Request")) {
+            request = name;
+        } else return null;
+    }
+    return if (writer_seen) request else null;
+}
+
+/// `var req T`, `req := T{}`, or `req := &T{}` in `body` before line `until`
+fn declaredType(body: []const []const u8, until: usize, name: []const u8) ?[]const u8 {
+    var i = until;
+    while (i > 0) {
+        i -= 1;
+        const line = std.mem.trim(u8, body[i], " \t\r");
+        if (std.mem.startsWith(u8, line, "var ")) {
+            var words = std.mem.tokenizeScalar(u8, line["var ".len..], ' ');
+            if (std.mem.eql(u8, words.next() orelse continue, name)) return words.next();
+        }
+        const assign = std.mem.indexOf(u8, line, " := ") orelse continue;
+        if (!std.mem.eql(u8, line[0..assign], name)) continue;
+        const value = std.mem.trimLeft(u8, line[assign + 4 ..], "&");
+        if (!std.mem.endsWith(u8, value, "{}")) return null;
+        return value[0 .. value.len - 2];
+    }
+    return null;
+}
+
+/// `first_line` is the 0-based line of body[0] in the file
+fn analyzeBody(arena: std.mem.Allocator, handler: *Handler, request: []const u8, body: []const []const u8, first_line: usize) !void {
+    var statuses = std.ArrayList(u16){};
+    var dto_var: ?[]const u8 = null;
+    const method_field = try std.fmt.allocPrint(arena, "{s}.Method != ", .{request});
+
+    for (body, 0..) |raw, i| {
+        const line = std.mem.trim(u8, raw, " \t\r");
+        if (statusIn(line)) |code| {
+            if (std.mem.indexOfScalar(u16, statuses.items, code) == null) try statuses.append(arena, code);
+        }
+
+        if (handler.method == null and std.mem.startsWith(u8, line, "if ") and std.mem.indexOf(u8, line, method_field) != null) {
+            const at = std.mem.indexOf(u8, line, method_field).? + method_field.len;
+            const rest = line[at..];
+            const method = if (std.mem.startsWith(u8, rest, "http.Method")) blk: {
+                const name = rest["http.Method".len..];
+                break :blk name[0 .. std.mem.indexOfAny(u8, name, " {") orelse name.len];
+            } else stringLiteral(rest) orelse "";
+            if (methodOf(method)) |m| {
+                handler.method = m;
+                handler.method_status = blockStatus(body, i);
+            }
+            continue;
+        }
+
+        if (dto_var == null) {
+            if (std.mem.indexOf(u8, line, ".Decode(&")) |at| {
+                const arg = line[at + ".Decode(&".len ..];
+                const name = arg[0 .. std.mem.indexOfScalar(u8, arg, ')') orelse continue];
+                dto_var = name;
+                handler.dto = declaredType(body, i, name);
+                handler.dto_var = name;
+                var decoded: ?usize = i;
+                if (std.mem.startsWith(u8, line, "if ")) {
+                    handler.decode_status = blockStatus(body, i);
+                    decoded = blockEnd(body, i);
+                } else if (i + 1 < body.len and std.mem.startsWith(u8, std.mem.trim(u8, body[i + 1], " \t\r"), "if err != nil")) {
+                    handler.decode_status = blockStatus(body, i + 1);
+                    decoded = blockEnd(body, i + 1);
+                }
+                if (decoded) |at| handler.decoded_line = @intCast(first_line + at + 1);
+                continue;
+            }
+        }
+
+        if (dto_var) |name| {
+            if (handler.validates or !std.mem.startsWith(u8, line, "if ")) continue;
+            const calls = [_][]const u8{ ".Validate()", ".Struct(" };
+            for (calls) |call| {
+                const at = std.mem.indexOf(u8, line, call) orelse continue;
+                const subject = if (std.mem.eql(u8, call, ".Validate()"))
+                    lastIdent(line[0..at])
+                else
+                    std.mem.trimLeft(u8, line[at + call.len ..][0 .. std.mem.indexOfScalar(u8, line[at + call.len ..], ')') orelse 0], "&");
+                if (!std.mem.eql(u8, subject, name)) continue;
+                handler.validates = true;
+                handler.validation_status = blockStatus(body, i);
+                break;
+            }
+        }
+    }
+    handler.statuses = statuses.items;
+}
+
+/// Routes and handlers of the Go `source`. Everything lives in `arena` or
+/// borrows `source`.
+pub fn parse(arena: std.mem.Allocator, source: []const u8) !File {
+    var lines = std.ArrayList([]const u8){};
+    var it = std.mem.splitScalar(u8, source, '\n');
+    while (it.next()) |line| try lines.append(arena, line);
+
+    var routes = std.ArrayList(Route){};
+    var handlers = std.ArrayList(Handler){};
+    var i: usize = 0;
+    while (i < lines.items.len) : (i += 1) {
+        const line = std.mem.trimRight(u8, lines.items[i], " \t\r");
+        if (parseRoute(line, @intCast(i + 1))) |route| {
+            try routes.append(arena, route);
+            continue;
+        }
+        const header = go_rules.parseFuncHeader(line) orelse continue;
+        const start = i + 1;
+        var end = start;
+        while (end < lines.items.len and !std.mem.eql(u8, std.mem.trimRight(u8, lines.items[end], " \t\r"), "}")) end += 1;
+        const body = lines.items[start..end];
+        const fn_line: u32 = @intCast(i + 1);
+
+        // Routes registered inside the function (a Routes method or main)
+        for (body, start..) |body_line, n| {
+            if (parseRoute(body_line, @intCast(n + 1))) |route| try routes.append(arena, route);
+        }
+        i = end;
+
+        if (header.results.len > 0) continue;
+        const request = handlerParams(header.params) orelse continue;
+        var handler = Handler{
+            .name = header.name,
+            .receiver_type = header.receiver_type,
+            .pointer_receiver = header.pointer_receiver,
+            .line = fn_line,
+            .end_line = @intCast(end + 1),
+        };
+        try analyzeBody(arena, &handler, request, body, start);
+        try handlers.append(arena, handler);
+    }
+    return .{ .routes = routes.items, .handlers = handlers.items };
+}
+
+/// The route serving `handler`, if one of `routes` names it
+pub fn routeOf(routes: []const Route, handler: Handler) ?Route {
+    for (routes) |route| {
+        if (std.mem.eql(u8, route.handler, handler.name)) return route;
+    }
+    return null;
+}
+
+test "parse routes and handler contracts" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const source =
+        \\package api
+        \\
+        \\func (h *Handler) Routes(mux *http.ServeMux) {
+        \\	mux.HandleFunc("POST /users", h.CreateUser)
+        \\	mux.HandleFunc("/users/{id}", h.GetUser)
+        \\	r.HandleFunc("/orders", h.CreateOrder).Methods("POST")
+        \\	g.GET("/health", health)
+        \\}
+        \\
+        \\func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+        \\	if r.Method != http.MethodPost {
+        \\		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        \\		return
+        \\	}
+        \\	var req CreateUserRequest
+        \\	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        \\		http.Error(w, "bad json", http.StatusBadRequest)
+        \\		return
+        \\	}
+        \\	if err := req.Validate(); err != nil {
+        \\		writeError(w, http.StatusUnprocessableEntity, err)
+        \\		return
+        \\	}
+        \\	w.WriteHeader(http.StatusCreated)
+        \\}
+    ;
+    const file = try parse(arena, source);
+    try testing.expectEqual(@as(usize, 4), file.routes.len);
+    try testing.expectEqualStrings("POST", file.routes[0].method.?);
+    try testing.expectEqualStrings("/users", file.routes[0].path);
+    try testing.expectEqualStrings("CreateUser", file.routes[0].handler);
+    try testing.expect(file.routes[1].method == null);
+    try testing.expectEqualStrings("POST", file.routes[2].method.?);
+    try testing.expectEqualStrings("CreateOrder", file.routes[2].handler);
+    try testing.expectEqualStrings("GET", file.routes[3].method.?);
+
+    try testing.expectEqual(@as(usize, 1), file.handlers.len);
+    const h = file.handlers[0];
+    try testing.expectEqualStrings("Handler", h.receiver_type.?);
+    try testing.expectEqualStrings("POST", h.method.?);
+    try testing.expectEqual(@as(?u16, 405), h.method_status);
+    try testing.expectEqualStrings("CreateUserRequest", h.dto.?);
+    try testing.expectEqual(@as(?u16, 400), h.decode_status);
+    try testing.expectEqualStrings("req", h.dto_var.?);
+    try testing.expectEqual(@as(?u32, 19), h.decoded_line);
+    try testing.expectEqual(@as(u32, 25), h.end_line);
+    try testing.expect(h.validates);
+    try testing.expectEqual(@as(?u16, 422), h.validation_status);
+    try testing.expectEqualSlices(u16, &.{ 405, 400, 422, 201 }, h.statuses);
+    try testing.expectEqualStrings("/users", routeOf(file.routes, h).?.path);
+    try testing.expectEqualStrings("StatusUnprocessableEntity", statusName(422).?);
+}
@@ -0,0 +1,603 @@
+// Go validation rules
+// Recovers typed validation rules from Go source, for generating tests and
+// validators that enforce them:
+//   struct fields    tags for go-playground/validator or gin binding, such as
+//                    `validate:"required,min=3,max=50"`, and the checks of the
+//                    struct's own Validate method (`len(u.Name) > 50`)
+//   validator funcs  single-argument functions returning error whose early
+//                    returns reject an empty value, a length or value out of
+//                    range, a regexp mismatch (`!usernameRe.MatchString(s)`),
+//                    or a missing character class (`!strings.ContainsAny(s, "0123456789")`)
+// Bounds may be integer literals or constants declared in the same file.
+// Parsing is line-based and expects gofmt layout.
+const std = @import("std");
+
+pub const CheckKind = enum {
+    required,
+    min_len,
+    max_len,
+    min,
+    max,
+    pattern,
+    email,
+    one_of,
+    contains_any,
+
+    pub fn label(self: CheckKind) []const u8 {
+        return switch (self) {
+            .required => "required",
+            .min_len => "min length",
+            .max_len => "max length",
+            .min => "min",
+            .max => "max",
+            .pattern => "pattern",
+            .email => "email",
+            .one_of => "one of",
+            .contains_any => "contains any of",
+        };
+    }
+};
+
+/// One rule on a value. Bounds are inclusive: min_len 3 accepts 3 characters.
+pub const Check = struct {
+    kind: CheckKind,
+    bound: i64 = 0,
+    /// The regexp of a pattern check, the space-separated options of one_of,
+    /// or the characters of contains_any
+    text: []const u8 = "",
+};
+
+pub const TypeClass = enum {
+    string,
+    integer,
+    float,
+    other,
+
+    pub fn of(go_type: []const u8) TypeClass {
+        if (std.mem.eql(u8, go_type, "string")) return .string;
+        if (std.mem.eql(u8, go_type, "float32") or std.mem.eql(u8, go_type, "float64")) return .float;
+        for ([_][]const u8{ "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64" }) |name| {
+            if (std.mem.eql(u8, go_type, name)) return .integer;
+        }
+        return .other;
+    }
+};
+
+/// Struct tag key the field's rules were declared under
+pub const TagStyle = enum {
+    none,
+    validate,
+    binding,
+};
+
+pub const Field = struct {
+    name: []const u8,
+    go_type: []const u8,
+    /// Name in the `json` tag, if any
+    json_name: ?[]const u8 = null,
+    line: u32,
+    /// The tag allows the zero value (`omitempty`)
+    optional: bool = false,
+    checks: []const Check = &.{},
+
+    pub fn class(self: Field) TypeClass {
+        return TypeClass.of(self.go_type);
+    }
+};
+
+pub const Struct = struct {
+    name: []const u8,
+    line: u32,
+    fields: []const Field,
+    tag_style: TagStyle = .none,
+    /// The package declares `Validate() error` on the struct
+    has_validate: bool = false,
+
+    pub fn hasChecks(self: Struct) bool {
+        for (self.fields) |field| {
+            if (field.checks.len > 0) return true;
+        }
+        return false;
+    }
+};
+
+/// A function that validates its single argument, e.g.
+/// `func ValidateUsername(name string) error`
+pub const Validator = struct {
+    name: []const u8,
+    param: []const u8,
+    param_type: []const u8,
+    line: u32,
+    checks: []const Check,
+};
+
+pub const File = struct {
+    package: []const u8 = "",
+    structs: []const Struct = &.{},
+    validators: []const Validator = &.{},
+};
+
+fn isIdent(text: []const u8) bool {
+    if (text.len == 0) return false;
+    for (text, 0..) |ch, i| {
+        if (!(std.ascii.isAlphanumeric(ch) or ch == '_') or (i == 0 and std.ascii.isDigit(ch))) return false;
+    }
+    return true;
+}
+
+/// Value of `key:"..."` in a struct tag
+fn tagValue(tag: []const u8, key: []const u8) ?[]const u8 {
+    var rest = tag;
+    while (std.mem.indexOf(u8, rest, key)) |at| {
+        const after = rest[at + key.len ..];
+        const boundary = at == 0 or rest[at - 1] == ' ';
+        if (boundary and std.mem.startsWith(u8, after, ":\"")) {
+            const value = after[2..];
+            const end = std.mem.indexOfScalar(u8, value, '"') orelse return null;
+            return value[0..end];
+        }
+        rest = after;
+    }
+    return null;
+}
+
+/// Checks of a validate or binding tag. Length rules apply to strings and
+/// value rules to numbers, as in go-playground/validator.
+fn parseTag(arena: std.mem.Allocator, rules: []const u8, class: TypeClass, field: *Field) !void {
+    var checks = std.ArrayList(Check){};
+    var items = std.mem.splitScalar(u8, rules, ',');
+    while (items.next()) |item| {
+        const eq = std.mem.indexOfScalar(u8, item, '=');
+        const name = if (eq) |i| item[0..i] else item;
+        const arg = if (eq) |i| item[i + 1 ..] else "";
+        if (std.mem.eql(u8, name, "required")) {
+            try checks.append(arena, .{ .kind = .required });
+        } else if (std.mem.eql(u8, name, "omitempty")) {
+            field.optional = true;
+        } else if (std.mem.eql(u8, name, "email")) {
+            try checks.append(arena, .{ .kind = .email });
+        } else if (std.mem.eql(u8, name, "oneof")) {
+            try checks.append(arena, .{ .kind = .one_of, .text = arg });
+        } else if (std.mem.eql(u8, name, "containsany")) {
+            if (class == .string and arg.len > 0) try checks.append(arena, .{ .kind = .contains_any, .text = arg });
+        } else {
+            const bound = std.fmt.parseInt(i64, arg, 10) catch continue;
+            const lower: CheckKind = if (class == .string) .min_len else .min;
+            const upper: CheckKind = if (class == .string) .max_len else .max;
+            if (std.mem.eql(u8, name, "min") or std.mem.eql(u8, name, "gte")) {
+                try checks.append(arena, .{ .kind = lower, .bound = bound });
+            } else if (std.mem.eql(u8, name, "max") or std.mem.eql(u8, name, "lte")) {
+                try checks.append(arena, .{ .kind = upper, .bound = bound });
+            } else if (std.mem.eql(u8, name, "gt")) {
+                try checks.append(arena, .{ .kind = lower, .bound = bound + 1 });
+            } else if (std.mem.eql(u8, name, "lt")) {
+                try checks.append(arena, .{ .kind = upper, .bound = bound - 1 });
+            } else if (std.mem.eql(u8, name, "len")) {
+                try checks.append(arena, .{ .kind = lower, .bound = bound });
+                try checks.append(arena, .{ .kind = upper, .bound = bound });
+            }
+        }
+    }
+    field.checks = checks.items;
+}
+
+/// Integer constants and compiled regexps declared at package level
+const Decls = struct {
+    consts: std.StringHashMap(i64),
+    regexps: std.StringHashMap([]const u8),
+
+    fn intValue(self: Decls, text: []const u8) ?i64 {
+        return std.fmt.parseInt(i64, text, 10) catch self.consts.get(text);
+    }
+};
+
+fn unquote(arena: std.mem.Allocator, literal: []const u8) !?[]const u8 {
+    if (literal.len < 2) return null;
+    if (literal[0] == '`' and literal[literal.len - 1] == '`') return literal[1 .. literal.len - 1];
+    if (literal[0] != '"' or literal[literal.len - 1] != '"') return null;
+    // Only backslash escapes matter in regexps
+    const inner = literal[1 .. literal.len - 1];
+    return try std.mem.replaceOwned(u8, arena, inner, "\\\\", "\\");
+}
+
+/// Contents of a string literal without escapes
+fn plainLiteral(literal: []const u8) ?[]const u8 {
+    if (literal.len < 2) return null;
+    const quote = literal[0];
+    if ((quote != '"' and quote != '`') or literal[literal.len - 1] != quote) return null;
+    const inner = literal[1 .. literal.len - 1];
+    if (quote == '"' and std.mem.indexOfScalar(u8, inner, '\\') != null) return null;
+    return inner;
+}
+
+fn collectDecls(arena: std.mem.Allocator, lines: []const []const u8) !Decls {
+    var decls = Decls{
+        .consts = std.StringHashMap(i64).init(arena),
+        .regexps = std.StringHashMap([]const u8).init(arena),
+    };
+    var block: enum { none, @"const", @"var" } = .none;
+    for (lines) |raw| {
+        const line = std.mem.trim(u8, raw, " \t\r");
+        if (std.mem.eql(u8, line, "const (")) {
+            block = .@"const";
+            continue;
+        }
+        if (std.mem.eql(u8, line, "var (")) {
+            block = .@"var";
+            continue;
+        }
+        if (block != .none and std.mem.eql(u8, line, ")")) {
+            block = .none;
+            continue;
+        }
+        const is_top = raw.len > 0 and raw[0] != ' ' and raw[0] != '\t';
+        var decl = line;
+        if (is_top and std.mem.startsWith(u8, line, "const ")) {
+            decl = line["const ".len..];
+        } else if (is_top and std.mem.startsWith(u8, line, "var ")) {
+            decl = line["var ".len..];
+        } else if (block == .none) {
+            continue;
+        }
+
+        const eq = std.mem.indexOf(u8, decl, " = ") orelse continue;
+        var names = std.mem.tokenizeScalar(u8, decl[0..eq], ' ');
+        const name = names.next() orelse continue;
+        if (!isIdent(name)) continue;
+        const value = std.mem.trim(u8, decl[eq + 3 ..], " ");
+        const compile_call = "regexp.MustCompile(";
+        if (std.mem.startsWith(u8, value, compile_call) and std.mem.endsWith(u8, value, ")")) {
+            if (try unquote(arena, value[compile_call.len .. value.len - 1])) |pattern| try decls.regexps.put(name, pattern);
+        } else if (std.fmt.parseInt(i64, value, 10)) |n| {
+            try decls.consts.put(name, n);
+        } else |_| {}
+    }
+    return decls;
+}
+
+fn unwrapCall(text: []const u8, callee: []const u8) ?[]const u8 {
+    if (std.mem.startsWith(u8, text, callee) and std.mem.endsWith(u8, text, ")")) {
+        return text[callee.len .. text.len - 1];
+    }
+    return null;
+}
+
+/// A variable or a field of one: `name`, `u.Name`
+fn isSubject(text: []const u8) bool {
+    const dot = std.mem.indexOfScalar(u8, text, '.') orelse return isIdent(text);
+    return isIdent(text[0..dot]) and isIdent(text[dot + 1 ..]);
+}
+
+/// The value an expression reads, seeing through TrimSpace
+fn subjectOf(expr: []const u8) []const u8 {
+    return unwrapCall(expr, "strings.TrimSpace(") orelse expr;
+}
+
+const Term = struct {
+    subject: []const u8,
+    check: Check,
+};
+
+/// The rule a rejecting condition term enforces, e.g. `len(name) > 50`
+/// rejects what max_len 50 does not allow
+fn parseTerm(text: []const u8, decls: Decls) ?Term {
+    const term = std.mem.trim(u8, text, " ()");
+    if (std.mem.startsWith(u8, term, "!")) {
+        const call = term[1..];
+        if (unwrapCall(call, "strings.ContainsAny(")) |args| {
+            const comma = std.mem.indexOfScalar(u8, args, ',') orelse return null;
+            const chars = plainLiteral(std.mem.trim(u8, args[comma + 1 ..], " ")) orelse return null;
+            if (chars.len == 0) return null;
+            return .{ .subject = subjectOf(std.mem.trim(u8, args[0..comma], " ")), .check = .{ .kind = .contains_any, .text = chars } };
+        }
+        const dot = std.mem.indexOf(u8, call, ".MatchString(") orelse return null;
+        const pattern = decls.regexps.get(call[0..dot]) orelse return null;
+        const arg = unwrapCall(call[dot..], ".MatchString(") orelse return null;
+        return .{ .subject = subjectOf(arg), .check = .{ .kind = .pattern, .text = pattern } };
+    }
+
+    const ops = [_][]const u8{ "<=", ">=", "==", "<", ">" };
+    for (ops) |op| {
+        const at = std.mem.indexOf(u8, term, op) orelse continue;
+        if (at > 0 and (term[at - 1] == '!' or term[at - 1] == '<' or term[at - 1] == '>' or term[at - 1] == '=')) continue;
+        const lhs = std.mem.trim(u8, term[0..at], " ");
+        const rhs = std.mem.trim(u8, term[at + op.len ..], " ");
+
+        const measured = unwrapCall(lhs, "len(") orelse unwrapCall(lhs, "utf8.RuneCountInString(");
+        const subject = subjectOf(measured orelse lhs);
+        if (!isSubject(subject)) return null;
+
+        if (std.mem.eql(u8, op, "==")) {
+            if (measured != null and std.mem.eql(u8, rhs, "0")) return .{ .subject = subject, .check = .{ .kind = .required } };
+            if (measured == null and std.mem.eql(u8, rhs, "\"\"")) return .{ .subject = subject, .check = .{ .kind = .required } };
+            return null;
+        }
+        const n = decls.intValue(rhs) orelse return null;
+        const lower: CheckKind = if (measured != null) .min_len else .min;
+        const upper: CheckKind = if (measured != null) .max_len else .max;
+        const check: Check = if (std.mem.eql(u8, op, "<"))
+            .{ .kind = lower, .bound = n }
+        else if (std.mem.eql(u8, op, "<="))
+            .{ .kind = lower, .bound = n + 1 }
+        else if (std.mem.eql(u8, op, ">"))
+            .{ .kind = upper, .bound = n }
+        else
+            .{ .kind = upper, .bound = n - 1 };
+        return .{ .subject = subject, .check = check };
+    }
+    return null;
+}
+
+/// Checks of the rejecting `if` statements in a function body: conditions
+/// joined by `||` whose block starts with a non-nil return
+fn scanBody(arena: std.mem.Allocator, body: []const []const u8, decls: Decls) ![]const Term {
+    var terms = std.ArrayList(Term){};
+    for (body, 0..) |raw, i| {
+        const line = std.mem.trim(u8, raw, " \t\r");
+        if (!std.mem.startsWith(u8, line, "if ") or !std.mem.endsWith(u8, line, " {")) continue;
+        if (i + 1 >= body.len) continue;
+        const next = std.mem.trim(u8, body[i + 1], " \t\r");
+        if (!std.mem.startsWith(u8, next, "return ") or std.mem.eql(u8, next, "return nil")) continue;
+
+        var cond = line[3 .. line.len - 2];
+        if (std.mem.lastIndexOfScalar(u8, cond, ';')) |semi| cond = cond[semi + 1 ..];
+        if (std.mem.indexOf(u8, cond, "&&") != null) continue;
+        var parts = std.mem.splitSequence(u8, cond, "||");
+        while (parts.next()) |part| {
+            if (parseTerm(part, decls)) |term| try terms.append(arena, term);
+        }
+    }
+    return terms.items;
+}
+
+fn hasCheck(checks: []const Check, check: Check) bool {
+    for (checks) |c| {
+        if (c.kind == check.kind and c.bound == check.bound and std.mem.eql(u8, c.text, check.text)) return true;
+    }
+    return false;
+}
+
+pub const FuncHeader = struct {
+    receiver: ?[]const u8 = null,
+    /// Without the '*' of a pointer receiver
+    receiver_type: ?[]const u8 = null,
+    pointer_receiver: bool = false,
+    name: []const u8,
+    params: []const u8,
+    results: []const u8,
+};
+
+/// `func (r *T) Name(params) results {` on one line
+pub fn parseFuncHeader(line: []const u8) ?FuncHeader {
+    if (!std.mem.startsWith(u8, line, "func ") or !std.mem.endsWith(u8, line, " {")) return null;
+    var rest = line["func ".len .. line.len - 2];
+    var header = FuncHeader{ .name = "", .params = "", .results = "" };
+    if (std.mem.startsWith(u8, rest, "(")) {
+        const close = std.mem.indexOfScalar(u8, rest, ')') orelse return null;
+        var recv = std.mem.tokenizeScalar(u8, rest[1..close], ' ');
+        header.receiver = recv.next();
+        const receiver_type = recv.next() orelse return null;
+        header.pointer_receiver = std.mem.startsWith(u8, receiver_type, "*");
+        header.receiver_type = std.mem.trimLeft(u8, receiver_type, "*");
+        rest = std.mem.trimLeft(u8, rest[close + 1 ..], " ");
+    }
+    const open = std.mem.indexOfScalar(u8, rest, '(') orelse return null;
+    const close = std.mem.indexOfScalarPos(u8, rest, open, ')') orelse return null;
+    header.name = rest[0..open];
+    header.params = std.mem.trim(u8, rest[open + 1 .. close], " ");
+    header.results = std.mem.trim(u8, rest[close + 1 ..], " ");
+    if (!isIdent(header.name)) return null;
+    return header;
+}
+
+const StructDraft = struct {
+    value: Struct,
+    fields: std.ArrayList(Field),
+};
+
+/// Rules of the Go `source`. Everything lives in `arena` or borrows `source`.
+pub fn parse(arena: std.mem.Allocator, source: []const u8) !File {
+    var lines = std.ArrayList([]const u8){};
+    var it = std.mem.splitScalar(u8, source, '\n');
+    while (it.next()) |line| try lines.append(arena, line);
+    const decls = try collectDecls(arena, lines.items);
+
+    var file = File{};
+    var drafts = std.ArrayList(StructDraft){};
+    var validators = std.ArrayList(Validator){};
+    // Validate methods, checked against the structs once all are known
+    var methods = std.ArrayList(struct { type_name: []const u8, receiver: []const u8, body: []const []const u8 }){};
+
+    var i: usize = 0;
+    while (i < lines.items.len) : (i += 1) {
+        const line = std.mem.trimRight(u8, lines.items[i], " \t\r");
+        if (std.mem.startsWith(u8, line, "package ")) {
+            file.package = std.mem.trim(u8, line["package ".len..], " ");
+            continue;
+        }
+
+        if (std.mem.startsWith(u8, line, "type ") and std.mem.endsWith(u8, line, " struct {")) {
+            const name = std.mem.trim(u8, line["type ".len .. line.len - " struct {".len], " ");
+            var draft = StructDraft{ .value = .{ .name = name, .line = @intCast(i + 1), .fields = &.{} }, .fields = .{} };
+            i += 1;
+            while (i < lines.items.len) : (i += 1) {
+                const field_line = std.mem.trim(u8, lines.items[i], " \t\r");
+                if (std.mem.eql(u8, field_line, "}")) break;
+                try parseField(arena, field_line, @intCast(i + 1), &draft);
+            }
+            try drafts.append(arena, draft);
+            continue;
+        }
+
+        const header = parseFuncHeader(line) orelse continue;
+        const start = i + 1;
+        var end = start;
+        while (end < lines.items.len and !std.mem.eql(u8, std.mem.trimRight(u8, lines.items[end], " \t\r"), "}")) end += 1;
+        const body = lines.items[start..end];
+        const fn_line: u32 = @intCast(i + 1);
+        i = end;
+
+        if (!std.mem.eql(u8, header.results, "error")) continue;
+        if (header.receiver) |receiver| {
+            if (std.mem.eql(u8, header.name, "Validate") and header.params.len == 0) {
+                try methods.append(arena, .{ .type_name = header.receiver_type.?, .receiver = receiver, .body = body });
+            }
+            continue;
+        }
+
+        // One parameter: `name string`
+        var params = std.mem.tokenizeScalar(u8, header.params, ' ');
+        const param = params.next() orelse continue;
+        const param_type = params.next() orelse continue;
+        if (params.next() != null or std.mem.indexOfScalar(u8, header.params, ',') != null) continue;
+        const class = TypeClass.of(param_type);
+        if (class == .other) continue;
+
+        var checks = std.ArrayList(Check){};
+        for (try scanBody(arena, body, decls)) |term| {
+            if (!std.mem.eql(u8, term.subject, param)) continue;
+            if (class == .string and (term.check.kind == .min or term.check.kind == .max)) continue;
+            if (!hasCheck(checks.items, term.check)) try checks.append(arena, term.check);
+        }
+        if (checks.items.len == 0) continue;
+        try validators.append(arena, .{
+            .name = header.name,
+            .param = param,
+            .param_type = param_type,
+            .line = fn_line,
+            .checks = checks.items,
+        });
+    }
+
+    for (methods.items) |method| {
+        for (drafts.items) |*draft| {
+            if (!std.mem.eql(u8, draft.value.name, method.type_name)) continue;
+            draft.value.has_validate = true;
+            for (try scanBody(arena, method.body, decls)) |term| {
+                const dot = std.mem.indexOfScalar(u8, term.subject, '.') orelse continue;
+                if (!std.mem.eql(u8, term.subject[0..dot], method.receiver)) continue;
+                for (draft.fields.items) |*field| {
+                    if (!std.mem.eql(u8, field.name, term.subject[dot + 1 ..])) continue;
+                    if (hasCheck(field.checks, term.check)) continue;
+                    const merged = try arena.alloc(Check, field.checks.len + 1);
+                    @memcpy(merged[0..field.checks.len], field.checks);
+                    merged[field.checks.len] = term.check;
+                    field.checks = merged;
+                }
+            }
+        }
+    }
+
+    const structs = try arena.alloc(Struct, drafts.items.len);
+    for (drafts.items, structs) |draft, *s| {
+        s.* = draft.value;
+        s.fields = draft.fields.items;
+    }
+    file.structs = structs;
+    file.validators = validators.items;
+    return file;
+}
+
+/// `Name Type `tags``; embedded and multi-name fields are skipped
+fn parseField(arena: std.mem.Allocator, line: []const u8, line_no: u32, draft: *StructDraft) !void {
+    if (line.len == 0 or std.mem.startsWith(u8, line, "//")) return;
+    const tag_start = std.mem.indexOfScalar(u8, line, '`');
+    const decl = std.mem.trim(u8, if (tag_start) |at| line[0..at] else line, " ");
+    var parts = std.mem.tokenizeScalar(u8, decl, ' ');
+    const name = parts.next() orelse return;
+    const go_type = parts.next() orelse return;
+    if (!isIdent(name) or parts.next() != null) return;
+
+    var field = Field{ .name = name, .go_type = go_type, .line = line_no };
+    if (tag_start) |at| {
+        const rest = line[at + 1 ..];
+        const tag = rest[0 .. std.mem.indexOfScalar(u8, rest, '`') orelse rest.len];
+        if (tagValue(tag, "json")) |json| {
+            const json_name = json[0 .. std.mem.indexOfScalar(u8, json, ',') orelse json.len];
+            if (json_name.len > 0 and !std.mem.eql(u8, json_name, "-")) field.json_name = json_name;
+        }
+        if (tagValue(tag, "validate")) |rules| {
+            draft.value.tag_style = .validate;
+            try parseTag(arena, rules, field.class(), &field);
+        } else if (tagValue(tag, "binding")) |rules| {
+            draft.value.tag_style = .binding;
+            try parseTag(arena, rules, field.class(), &field);
+        }
+    }
+    try draft.fields.append(arena, field);
+}
+
+test "parse tags, Validate methods, and validator functions" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const source =
+        \\package users
+        \\
+        \\const maxUsername = 50
+        \\
+        \\var usernameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+        \\
+        \\type CreateUser struct {
+        \\	Username string `json:"username" validate:"required,min=3,max=50"`
+        \\	Age      int    `json:"age,omitempty" validate:"omitempty,gte=13,lt=130"`
+        \\	Email    string `json:"email" validate:"required,email"`
+        \\	Bio      string `json:"bio"`
+        \\}
+        \\
+        \\func (u *CreateUser) Validate() error {
+        \\	if len(u.Bio) > 280 {
+        \\		return errors.New("bio too long")
+        \\	}
+        \\	return nil
+        \\}
+        \\
+        \\func ValidateUsername(name string) error {
+        \\	if len(name) < 3 || len(name) > maxUsername {
+        \\		return ErrUsernameLength
+        \\	}
+        \\	if !usernameRe.MatchString(name) {
+        \\		return ErrUsernameChars
+        \\	}
+        \\	return nil
+        \\}
+        \\
+        \\func ValidatePassword(p string) error {
+        \\	if len(p) < 12 || !strings.ContainsAny(p, "0123456789") {
+        \\		return ErrWeakPassword
+        \\	}
+        \\	return nil
+        \\}
+    ;
+    const file = try parse(arena, source);
+    try testing.expectEqualStrings("users", file.package);
+    try testing.expectEqual(@as(usize, 1), file.structs.len);
+
+    const s = file.structs[0];
+    try testing.expect(s.has_validate);
+    try testing.expectEqual(TagStyle.validate, s.tag_style);
+    try testing.expectEqual(@as(usize, 4), s.fields.len);
+    try testing.expectEqual(@as(usize, 3), s.fields[0].checks.len);
+    try testing.expectEqual(CheckKind.max_len, s.fields[0].checks[2].kind);
+    try testing.expectEqual(@as(i64, 50), s.fields[0].checks[2].bound);
+    try testing.expect(s.fields[1].optional);
+    try testing.expectEqual(CheckKind.max, s.fields[1].checks[1].kind);
+    try testing.expectEqual(@as(i64, 129), s.fields[1].checks[1].bound);
+    try testing.expectEqualStrings("email", s.fields[2].json_name.?);
+    try testing.expectEqual(CheckKind.max_len, s.fields[3].checks[0].kind);
+    try testing.expectEqual(@as(i64, 280), s.fields[3].checks[0].bound);
+
+    try testing.expectEqual(@as(usize, 2), file.validators.len);
+    const v = file.validators[0];
+    try testing.expectEqualStrings("ValidateUsername", v.name);
+    try testing.expectEqual(@as(usize, 3), v.checks.len);
+    try testing.expectEqual(CheckKind.min_len, v.checks[0].kind);
+    try testing.expectEqual(@as(i64, 3), v.checks[0].bound);
+    try testing.expectEqual(@as(i64, 50), v.checks[1].bound);
+    try testing.expectEqualStrings("^[a-z0-9_]+$", v.checks[2].text);
+    const password = file.validators[1];
+    try testing.expectEqual(CheckKind.contains_any, password.checks[1].kind);
+    try testing.expectEqualStrings("0123456789", password.checks[1].text);
+}
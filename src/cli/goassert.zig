@@ -0,0 +1,312 @@
+// Go assertion injection
+// Injects runtime checks of the typed rules `go_rules` recovers into the code
+// that handles the constrained values, so violations surface in integration
+// environments rather than only in tests:
+//   decode sites    right after a net/http handler decodes its request DTO
+//                   and handles a malformed body
+//   function entry  of every function taking a struct with rules, by value
+//                   or pointer (service and repository methods)
+// Each site gets one call, `anankeCheckT(&req, "file.go:42")`, marked with a
+// trailing `// ananke:assert` so it can be found and removed again. The calls
+// resolve to two generated files per package: the checks, behind a build tag,
+// and empty stubs without it, so untagged builds carry no assertions and the
+// edited sources need no new imports.
+const std = @import("std");
+const go_rules = @import("cli_go_rules");
+const go_http = @import("cli_go_http");
+const govalidate = @import("cli_govalidate");
+
+pub const default_tag = "ananke_asserts";
+/// Trailing comment of every injected line
+pub const marker = "// ananke:assert";
+/// Files of the checks and of the stubs in each package directory
+pub const helper_file = "ananke_asserts.go";
+pub const stub_file = "ananke_asserts_off.go";
+pub const generated_marker = "// Code generated by ananke inject-asserts";
+
+/// What a failed assertion does
+pub const Mode = enum {
+    panic,
+    log,
+};
+
+fn checkName(arena: std.mem.Allocator, type_name: []const u8) ![]const u8 {
+    return std.fmt.allocPrint(arena, "anankeCheck{s}", .{type_name});
+}
+
+fn isInjected(line: []const u8) bool {
+    return std.mem.endsWith(u8, std.mem.trimRight(u8, line, " \t\r"), marker);
+}
+
+/// `source` without previously injected lines
+pub fn strip(arena: std.mem.Allocator, source: []const u8) ![]const u8 {
+    if (std.mem.indexOf(u8, source, marker) == null) return source;
+    var out = std.ArrayList(u8){};
+    var lines = std.mem.splitScalar(u8, source, '\n');
+    var first = true;
+    while (lines.next()) |line| {
+        if (isInjected(line)) continue;
+        if (!first) try out.append(arena, '\n');
+        first = false;
+        try out.appendSlice(arena, line);
+    }
+    return out.items;
+}
+
+/// Where a value of a checked struct is available
+pub const Site = struct {
+    /// 1-based line the assertion is inserted after
+    after: u32,
+    /// 1-based line of the evidence: the decode or the function header
+    line: u32,
+    type_name: []const u8,
+    /// Pointer to the value
+    arg: []const u8,
+    indent: []const u8,
+};
+
+fn contains(names: []const []const u8, name: []const u8) bool {
+    for (names) |n| {
+        if (std.mem.eql(u8, n, name)) return true;
+    }
+    return false;
+}
+
+/// `name` is declared as a pointer (`req := &T{}`) between lines[from..to]
+fn declaredPointer(lines: []const []const u8, from: usize, to: usize, name: []const u8) bool {
+    for (lines[from..@min(to, lines.len)]) |raw| {
+        const line = std.mem.trim(u8, raw, " \t\r");
+        if (std.mem.startsWith(u8, line, name) and std.mem.startsWith(u8, line[name.len..], " := &")) return true;
+        if (std.mem.startsWith(u8, line, "var ") and std.mem.startsWith(u8, line[4..], name) and
+            std.mem.startsWith(u8, line[4 + name.len ..], " *")) return true;
+    }
+    return false;
+}
+
+/// Sites of `source` where a value of one of the `checked` types is
+/// available. `handlers` are those go_http found in the same source.
+pub fn findSites(arena: std.mem.Allocator, source: []const u8, handlers: []const go_http.Handler, checked: []const []const u8) ![]const Site {
+    var lines = std.ArrayList([]const u8){};
+    var it = std.mem.splitScalar(u8, source, '\n');
+    while (it.next()) |line| try lines.append(arena, line);
+
+    var sites = std.ArrayList(Site){};
+    for (handlers) |h| {
+        const type_name = h.dto orelse continue;
+        const name = h.dto_var orelse continue;
+        const at = h.decoded_line orelse continue;
+        if (!contains(checked, type_name) or at > lines.items.len) continue;
+        const decoded = lines.items[at - 1];
+        try sites.append(arena, .{
+            .after = at,
+            .line = at,
+            .type_name = type_name,
+            .arg = if (declaredPointer(lines.items, h.line, at, name)) name else try std.fmt.allocPrint(arena, "&{s}", .{name}),
+            .indent = decoded[0 .. decoded.len - std.mem.trimLeft(u8, decoded, " \t").len],
+        });
+    }
+
+    for (lines.items, 0..) |raw, i| {
+        const header = go_rules.parseFuncHeader(std.mem.trimRight(u8, raw, " \t\r")) orelse continue;
+        // `a, b T` declares both with the type after the last name
+        var pending = std.ArrayList([]const u8){};
+        var params = std.mem.splitScalar(u8, header.params, ',');
+        while (params.next()) |param| {
+            var words = std.mem.tokenizeScalar(u8, param, ' ');
+            const name = words.next() orelse continue;
+            try pending.append(arena, name);
+            const param_type = words.next() orelse continue;
+            defer pending.clearRetainingCapacity();
+            const pointer = std.mem.startsWith(u8, param_type, "*");
+            const type_name = std.mem.trimLeft(u8, param_type, "*");
+            if (!contains(checked, type_name)) continue;
+            for (pending.items) |var_name| {
+                if (std.mem.eql(u8, var_name, "_")) continue;
+                try sites.append(arena, .{
+                    .after = @intCast(i + 1),
+                    .line = @intCast(i + 1),
+                    .type_name = type_name,
+                    .arg = if (pointer) var_name else try std.fmt.allocPrint(arena, "&{s}", .{var_name}),
+                    .indent = "\t",
+                });
+            }
+        }
+    }
+
+    std.mem.sort(Site, sites.items, {}, struct {
+        fn lessThan(_: void, a: Site, b: Site) bool {
+            return a.after < b.after;
+        }
+    }.lessThan);
+    return sites.items;
+}
+
+/// `source` with a call after each site; `path` names the file in messages
+pub fn inject(arena: std.mem.Allocator, source: []const u8, path: []const u8, sites: []const Site) ![]const u8 {
+    if (sites.len == 0) return source;
+    var out = std.ArrayList(u8){};
+    var lines = std.mem.splitScalar(u8, source, '\n');
+    var next: usize = 0;
+    var n: u32 = 0;
+    while (lines.next()) |line| {
+        n += 1;
+        if (n > 1) try out.append(arena, '\n');
+        try out.appendSlice(arena, line);
+        while (next < sites.len and sites[next].after == n) : (next += 1) {
+            const site = sites[next];
+            try out.writer(arena).print("\n{s}{s}({s}, \"{s}:{d}\") {s}", .{
+                site.indent,
+                try checkName(arena, site.type_name),
+                site.arg,
+                path,
+                site.line,
+                marker,
+            });
+        }
+    }
+    return out.items;
+}
+
+pub const Helpers = struct {
+    /// Checks, built with the tag
+    checks: []const u8,
+    /// Empty stubs, built without it
+    stubs: []const u8,
+    /// Types that have a check
+    types: []const []const u8,
+};
+
+/// Check and stub files for the structs of a package, or null when none has
+/// a rule that can be checked. Everything lives in `arena`.
+pub fn generateHelpers(
+    arena: std.mem.Allocator,
+    package: []const u8,
+    sources: []const govalidate.Source,
+    tag: []const u8,
+    mode: Mode,
+) !?Helpers {
+    var state = govalidate.State{ .violation_type = "anankeViolation", .var_prefix = "ananke" };
+    var checks = std.ArrayList(u8){};
+    var stubs = std.ArrayList(u8){};
+    var types = std.ArrayList([]const u8){};
+    const writer = checks.writer(arena);
+    for (sources) |source| {
+        for (source.structs) |s| {
+            if (!s.hasChecks()) continue;
+            var body = std.ArrayList(u8){};
+            if (!try govalidate.writeChecks(arena, body.writer(arena), s, &state)) continue;
+            const name = try checkName(arena, s.name);
+            try writer.print("\n// {s} asserts the rules of {s} ({s}:{d}).\n", .{ name, s.name, source.path, s.line });
+            try writer.print("func {s}(v *{s}, site string) {{\n\tif v == nil {{\n\t\treturn\n\t}}\n\tvar errs []anankeViolation\n", .{ name, s.name });
+            try writer.writeAll(body.items);
+            try writer.print("\tanankeFail(errs, \"{s}\", site)\n}}\n", .{s.name});
+            try stubs.writer(arena).print("\nfunc {s}(*{s}, string) {{}}\n", .{ name, s.name });
+            try types.append(arena, s.name);
+        }
+    }
+    if (types.items.len == 0) return null;
+
+    var on = std.ArrayList(u8){};
+    const out = on.writer(arena);
+    try out.print("//go:build {s}\n\n{s}; DO NOT EDIT.\n\npackage {s}\n\nimport (\n", .{ tag, generated_marker, package });
+    try out.writeAll(switch (mode) {
+        .panic => "\t\"fmt\"\n",
+        .log => "\t\"log\"\n",
+    });
+    if (state.imports.mail) try out.writeAll("\t\"net/mail\"\n");
+    if (state.imports.regexp) try out.writeAll("\t\"regexp\"\n");
+    if (state.imports.strings) try out.writeAll("\t\"strings\"\n");
+    if (state.imports.utf8) try out.writeAll("\t\"unicode/utf8\"\n");
+    try out.writeAll(")\n");
+    if (state.vars.items.len > 0) {
+        try out.writeAll("\n");
+        try out.writeAll(state.vars.items);
+    }
+    try out.writeAll(
+        \\
+        \\type anankeViolation struct {
+        \\	Field   string
+        \\	Message string
+        \\}
+        \\
+        \\// anankeFail reports the rules a value broke at an injected assertion.
+        \\func anankeFail(errs []anankeViolation, typeName, site string) {
+        \\
+    );
+    try out.writeAll(switch (mode) {
+        .panic =>
+        \\	if len(errs) > 0 {
+        \\		panic(fmt.Sprintf("ananke: %s.%s %s (asserted at %s)", typeName, errs[0].Field, errs[0].Message, site))
+        \\	}
+        \\}
+        \\
+        ,
+        .log =>
+        \\	for _, e := range errs {
+        \\		log.Printf("ananke: %s.%s %s (asserted at %s)", typeName, e.Field, e.Message, site)
+        \\	}
+        \\}
+        \\
+        ,
+    });
+    try out.writeAll(checks.items);
+
+    var off = std.ArrayList(u8){};
+    try off.writer(arena).print("//go:build !{s}\n\n{s}; DO NOT EDIT.\n\npackage {s}\n", .{ tag, generated_marker, package });
+    try off.appendSlice(arena, stubs.items);
+    return .{ .checks = on.items, .stubs = off.items, .types = types.items };
+}
+
+test "inject assertions at decode sites and function entries" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const source =
+        \\package api
+        \\
+        \\type CreateUserRequest struct {
+        \\	Username string `json:"username" validate:"required,max=20"`
+        \\}
+        \\
+        \\func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+        \\	var req CreateUserRequest
+        \\	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        \\		http.Error(w, "bad json", http.StatusBadRequest)
+        \\		return
+        \\	}
+        \\	h.svc.Create(r.Context(), &req)
+        \\}
+        \\
+        \\func (s *Service) Create(ctx context.Context, req *CreateUserRequest) error {
+        \\	return s.store.Insert(ctx, req.Username)
+        \\}
+        \\
+    ;
+    const rules = try go_rules.parse(arena, source);
+    const endpoints = try go_http.parse(arena, source);
+    const helpers = (try generateHelpers(arena, "api", &.{.{ .path = "api/users.go", .structs = rules.structs }}, default_tag, .panic)).?;
+    try testing.expectEqualStrings("CreateUserRequest", helpers.types[0]);
+    try testing.expect(std.mem.startsWith(u8, helpers.checks, "//go:build ananke_asserts\n"));
+    try testing.expect(std.mem.indexOf(u8, helpers.checks, "func anankeCheckCreateUserRequest(v *CreateUserRequest, site string) {") != null);
+    try testing.expect(std.mem.indexOf(u8, helpers.checks, "\tif utf8.RuneCountInString(v.Username) > 20 {\n\t\terrs = append(errs, anankeViolation{\"username\", \"must be at most 20 characters\"})\n") != null);
+    try testing.expect(std.mem.endsWith(u8, helpers.stubs, "package api\n\nfunc anankeCheckCreateUserRequest(*CreateUserRequest, string) {}\n"));
+
+    const sites = try findSites(arena, source, endpoints.handlers, helpers.types);
+    try testing.expectEqual(@as(usize, 2), sites.len);
+    const injected = try inject(arena, source, "api/users.go", sites);
+    try testing.expect(std.mem.indexOf(u8, injected,
+        \\		return
+        \\	}
+        \\	anankeCheckCreateUserRequest(&req, "api/users.go:12") // ananke:assert
+        \\	h.svc.Create(r.Context(), &req)
+    ) != null);
+    try testing.expect(std.mem.indexOf(u8, injected,
+        \\func (s *Service) Create(ctx context.Context, req *CreateUserRequest) error {
+        \\	anankeCheckCreateUserRequest(req, "api/users.go:16") // ananke:assert
+    ) != null);
+
+    // Injecting again starts from the stripped source
+    try testing.expectEqualStrings(source, try strip(arena, injected));
+}
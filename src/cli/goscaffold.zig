@@ -0,0 +1,554 @@
+// Go scaffolding from a type spec
+// The reverse of gen-validators, for spec-first development: a hand-editable
+// JSON file of structs, their fields, and the rules of each field becomes Go
+// struct definitions with json and validate tags and a Validate method per
+// struct. The code is laid out the way `go_rules` reads it back, so a spec
+// recovered from scaffolded code (`formatSpec`) has the rules it came from.
+const std = @import("std");
+const go_rules = @import("cli_go_rules");
+const output = @import("cli_output");
+
+pub const format_version: u32 = 1;
+
+/// Start of the first line of scaffolded files. Unlike generated files they
+/// are meant to be edited, so extraction does not skip them.
+pub const scaffold_marker = "// Code scaffolded by ananke scaffold";
+
+pub const ScaffoldError = error{ UnsupportedVersion, InvalidSpec };
+
+pub const FieldSpec = struct {
+    name: []const u8,
+    @"type": []const u8,
+    /// Name in request bodies; no json tag when null
+    json: ?[]const u8 = null,
+    /// validate-tag rules ("required", "min=3", "oneof=a b", ...) and
+    /// "pattern=<regexp>"
+    rules: []const []const u8 = &.{},
+};
+
+pub const StructSpec = struct {
+    name: []const u8,
+    doc: ?[]const u8 = null,
+    fields: []const FieldSpec = &.{},
+};
+
+pub const Spec = struct {
+    version: u32 = format_version,
+    package: []const u8,
+    structs: []const StructSpec = &.{},
+};
+
+/// Parse a spec; everything lives in `arena`
+pub fn parseSpec(arena: std.mem.Allocator, text: []const u8) !Spec {
+    const spec = try std.json.parseFromSliceLeaky(Spec, arena, text, .{ .ignore_unknown_fields = true });
+    if (spec.version != format_version) return ScaffoldError.UnsupportedVersion;
+    return spec;
+}
+
+fn isIdent(text: []const u8) bool {
+    if (text.len == 0) return false;
+    for (text, 0..) |ch, i| {
+        if (!(std.ascii.isAlphanumeric(ch) or ch == '_') or (i == 0 and std.ascii.isDigit(ch))) return false;
+    }
+    return true;
+}
+
+/// The typed field of a spec entry; `problem` says what is wrong on
+/// error.InvalidSpec. Bounds follow go-playground/validator: lengths for
+/// strings, values for numbers, item counts for slices and maps.
+fn resolveField(arena: std.mem.Allocator, spec: FieldSpec, line: u32, problem: *[]const u8) !go_rules.Field {
+    var field = go_rules.Field{ .name = spec.name, .go_type = spec.@"type", .json_name = spec.json, .line = line };
+    const class = field.class();
+    var checks = std.ArrayList(go_rules.Check){};
+    for (spec.rules) |raw| {
+        const rule = std.mem.trim(u8, raw, " ");
+        const eq = std.mem.indexOfScalar(u8, rule, '=');
+        const name = if (eq) |i| rule[0..i] else rule;
+        const arg = if (eq) |i| rule[i + 1 ..] else "";
+        if (std.mem.eql(u8, name, "required")) {
+            try checks.append(arena, .{ .kind = .required });
+        } else if (std.mem.eql(u8, name, "omitempty")) {
+            field.optional = true;
+        } else if (std.mem.eql(u8, name, "email") and class == .string) {
+            try checks.append(arena, .{ .kind = .email });
+        } else if (std.mem.eql(u8, name, "oneof") and class != .other and arg.len > 0) {
+            try checks.append(arena, .{ .kind = .one_of, .text = arg });
+        } else if (std.mem.eql(u8, name, "containsany") and class == .string and arg.len > 0) {
+            try checks.append(arena, .{ .kind = .contains_any, .text = arg });
+        } else if (std.mem.eql(u8, name, "pattern") and class == .string and arg.len > 0) {
+            try checks.append(arena, .{ .kind = .pattern, .text = arg });
+        } else {
+            const bound = std.fmt.parseInt(i64, arg, 10) catch {
+                problem.* = try std.fmt.allocPrint(arena, "rule \"{s}\" of {s} ({s}) is not supported", .{ rule, spec.name, spec.@"type" });
+                return ScaffoldError.InvalidSpec;
+            };
+            const lower: go_rules.CheckKind = if (class == .string) .min_len else .min;
+            const upper: go_rules.CheckKind = if (class == .string) .max_len else .max;
+            if (std.mem.eql(u8, name, "min") or std.mem.eql(u8, name, "gte")) {
+                try checks.append(arena, .{ .kind = lower, .bound = bound });
+            } else if (std.mem.eql(u8, name, "max") or std.mem.eql(u8, name, "lte")) {
+                try checks.append(arena, .{ .kind = upper, .bound = bound });
+            } else if (std.mem.eql(u8, name, "gt")) {
+                try checks.append(arena, .{ .kind = lower, .bound = bound + 1 });
+            } else if (std.mem.eql(u8, name, "lt")) {
+                try checks.append(arena, .{ .kind = upper, .bound = bound - 1 });
+            } else if (std.mem.eql(u8, name, "len")) {
+                try checks.append(arena, .{ .kind = lower, .bound = bound });
+                try checks.append(arena, .{ .kind = upper, .bound = bound });
+            } else {
+                problem.* = try std.fmt.allocPrint(arena, "rule \"{s}\" of {s} is not supported", .{ rule, spec.name });
+                return ScaffoldError.InvalidSpec;
+            }
+        }
+    }
+    field.checks = checks.items;
+    return field;
+}
+
+/// The structs of a spec with typed rules, in spec order
+pub fn resolve(arena: std.mem.Allocator, spec: Spec, problem: *[]const u8) ![]const go_rules.Struct {
+    if (!isIdent(spec.package)) {
+        problem.* = try std.fmt.allocPrint(arena, "\"{s}\" is not a Go package name", .{spec.package});
+        return ScaffoldError.InvalidSpec;
+    }
+    const structs = try arena.alloc(go_rules.Struct, spec.structs.len);
+    for (spec.structs, structs, 0..) |s, *out, i| {
+        if (!isIdent(s.name)) {
+            problem.* = try std.fmt.allocPrint(arena, "\"{s}\" is not a Go type name", .{s.name});
+            return ScaffoldError.InvalidSpec;
+        }
+        for (spec.structs[0..i]) |earlier| {
+            if (std.mem.eql(u8, earlier.name, s.name)) {
+                problem.* = try std.fmt.allocPrint(arena, "struct {s} is declared twice", .{s.name});
+                return ScaffoldError.InvalidSpec;
+            }
+        }
+        const fields = try arena.alloc(go_rules.Field, s.fields.len);
+        for (s.fields, fields, 0..) |field, *typed, j| {
+            if (!isIdent(field.name) or field.@"type".len == 0) {
+                problem.* = try std.fmt.allocPrint(arena, "field \"{s}\" of {s} needs a Go name and type", .{ field.name, s.name });
+                return ScaffoldError.InvalidSpec;
+            }
+            typed.* = try resolveField(arena, field, @intCast(j + 1), problem);
+        }
+        out.* = .{ .name = s.name, .line = @intCast(i + 1), .fields = fields, .tag_style = .validate };
+    }
+    return structs;
+}
+
+fn writeGoString(writer: anytype, text: []const u8) !void {
+    try writer.writeByte('"');
+    for (text) |ch| {
+        switch (ch) {
+            '"' => try writer.writeAll("\\\""),
+            '\\' => try writer.writeAll("\\\\"),
+            '\n' => try writer.writeAll("\\n"),
+            '\t' => try writer.writeAll("\\t"),
+            else => try writer.writeByte(ch),
+        }
+    }
+    try writer.writeByte('"');
+}
+
+/// Name the field has in messages
+fn fieldKey(field: go_rules.Field) []const u8 {
+    return field.json_name orelse field.name;
+}
+
+fn isCollection(go_type: []const u8) bool {
+    return std.mem.startsWith(u8, go_type, "[]") or std.mem.startsWith(u8, go_type, "map[");
+}
+
+/// Comparison of the field with its zero value, or null for types without
+/// a simple test
+fn zeroTest(arena: std.mem.Allocator, field: go_rules.Field, op: []const u8) !?[]const u8 {
+    return switch (field.class()) {
+        .string => try std.fmt.allocPrint(arena, "v.{s} {s} \"\"", .{ field.name, op }),
+        .integer, .float => try std.fmt.allocPrint(arena, "v.{s} {s} 0", .{ field.name, op }),
+        .other => if (isCollection(field.go_type))
+            try std.fmt.allocPrint(arena, "len(v.{s}) {s} 0", .{ field.name, op })
+        else if (std.mem.startsWith(u8, field.go_type, "*"))
+            try std.fmt.allocPrint(arena, "v.{s} {s} nil", .{ field.name, op })
+        else
+            null,
+    };
+}
+
+/// Tag rule of a check, or null for checks a validate tag cannot carry
+fn tagRule(arena: std.mem.Allocator, check: go_rules.Check) !?[]const u8 {
+    return switch (check.kind) {
+        .required => "required",
+        .min_len, .min => try std.fmt.allocPrint(arena, "min={d}", .{check.bound}),
+        .max_len, .max => try std.fmt.allocPrint(arena, "max={d}", .{check.bound}),
+        .email => "email",
+        .one_of => try std.fmt.allocPrint(arena, "oneof={s}", .{check.text}),
+        .contains_any => if (std.mem.indexOfAny(u8, check.text, ",\"`") == null)
+            try std.fmt.allocPrint(arena, "containsany={s}", .{check.text})
+        else
+            null,
+        .pattern => null,
+    };
+}
+
+const Imports = struct {
+    errors: bool = false,
+    mail: bool = false,
+    regexp: bool = false,
+    strings: bool = false,
+    time: bool = false,
+    utf8: bool = false,
+};
+
+fn writeReject(arena: std.mem.Allocator, writer: anytype, indent: []const u8, cond: []const u8, key: []const u8, message: []const u8) !void {
+    try writer.print("{s}if {s} {{\n{s}\treturn errors.New(", .{ indent, cond, indent });
+    try writeGoString(writer, try std.fmt.allocPrint(arena, "{s} {s}", .{ key, message }));
+    try writer.print(")\n{s}}}\n", .{indent});
+}
+
+/// An early return of Validate for one check other than required, in the
+/// `if <cond> {` / `return ...` shape `go_rules` recovers
+fn writeCheck(
+    arena: std.mem.Allocator,
+    writer: anytype,
+    indent: []const u8,
+    s: go_rules.Struct,
+    field: go_rules.Field,
+    check: go_rules.Check,
+    vars: *std.ArrayList(u8),
+    imports: *Imports,
+) !void {
+    const key = fieldKey(field);
+    const class = field.class();
+    const subject = try std.fmt.allocPrint(arena, "v.{s}", .{field.name});
+    switch (check.kind) {
+        .required => return,
+        .min_len, .max_len => {
+            imports.utf8 = true;
+            const below = check.kind == .min_len;
+            try writeReject(
+                arena,
+                writer,
+                indent,
+                try std.fmt.allocPrint(arena, "utf8.RuneCountInString({s}) {s} {d}", .{ subject, if (below) "<" else ">", check.bound }),
+                key,
+                try std.fmt.allocPrint(arena, "must be at {s} {d} characters", .{ if (below) "least" else "most", check.bound }),
+            );
+        },
+        .min, .max => {
+            const below = check.kind == .min;
+            const op = if (below) "<" else ">";
+            const extent = if (below) "least" else "most";
+            if (class == .integer or class == .float) {
+                try writeReject(
+                    arena,
+                    writer,
+                    indent,
+                    try std.fmt.allocPrint(arena, "{s} {s} {d}", .{ subject, op, check.bound }),
+                    key,
+                    try std.fmt.allocPrint(arena, "must be at {s} {d}", .{ extent, check.bound }),
+                );
+            } else if (isCollection(field.go_type)) {
+                // Through a local, so the item count is not read back as a
+                // character length
+                try writeReject(
+                    arena,
+                    writer,
+                    indent,
+                    try std.fmt.allocPrint(arena, "n := len({s}); n {s} {d}", .{ subject, op, check.bound }),
+                    key,
+                    try std.fmt.allocPrint(arena, "must have at {s} {d} items", .{ extent, check.bound }),
+                );
+            }
+        },
+        .pattern => {
+            imports.regexp = true;
+            const var_name = try std.fmt.allocPrint(arena, "{c}{s}{s}Regexp", .{ std.ascii.toLower(s.name[0]), s.name[1..], field.name });
+            const out = vars.writer(arena);
+            try out.print("var {s} = regexp.MustCompile(", .{var_name});
+            if (std.mem.indexOfScalar(u8, check.text, '`') == null) {
+                try out.print("`{s}`", .{check.text});
+            } else {
+                try writeGoString(out, check.text);
+            }
+            try out.writeAll(")\n");
+            try writeReject(
+                arena,
+                writer,
+                indent,
+                try std.fmt.allocPrint(arena, "!{s}.MatchString({s})", .{ var_name, subject }),
+                key,
+                try std.fmt.allocPrint(arena, "must match {s}", .{check.text}),
+            );
+        },
+        .email => {
+            imports.mail = true;
+            try writeReject(arena, writer, indent, try std.fmt.allocPrint(arena, "_, err := mail.ParseAddress({s}); err != nil", .{subject}), key, "must be an email address");
+        },
+        .one_of => {
+            try writer.print("{s}switch {s} {{\n{s}case ", .{ indent, subject, indent });
+            var options = std.mem.tokenizeScalar(u8, check.text, ' ');
+            var first = true;
+            while (options.next()) |option| {
+                if (!first) try writer.writeAll(", ");
+                first = false;
+                if (class == .string) try writeGoString(writer, option) else try writer.writeAll(option);
+            }
+            try writer.print(":\n{s}default:\n{s}\treturn errors.New(", .{ indent, indent });
+            try writeGoString(writer, try std.fmt.allocPrint(arena, "{s} must be one of {s}", .{ key, check.text }));
+            try writer.print(")\n{s}}}\n", .{indent});
+        },
+        .contains_any => {
+            imports.strings = true;
+            var literal = std.ArrayList(u8){};
+            try writeGoString(literal.writer(arena), check.text);
+            try writeReject(
+                arena,
+                writer,
+                indent,
+                try std.fmt.allocPrint(arena, "!strings.ContainsAny({s}, {s})", .{ subject, literal.items }),
+                key,
+                try std.fmt.allocPrint(arena, "must contain one of {s}", .{check.text}),
+            );
+        },
+    }
+}
+
+fn writeStruct(arena: std.mem.Allocator, writer: anytype, s: go_rules.Struct, doc: ?[]const u8, vars: *std.ArrayList(u8), imports: *Imports) !void {
+    try writer.writeAll("\n");
+    if (doc) |text| {
+        var lines = std.mem.splitScalar(u8, std.mem.trim(u8, text, " \n"), '\n');
+        while (lines.next()) |line| try writer.print("// {s}\n", .{line});
+    } else {
+        try writer.print("// {s} is scaffolded from the spec.\n", .{s.name});
+    }
+
+    // Columns as gofmt aligns them
+    var name_width: usize = 0;
+    var type_width: usize = 0;
+    for (s.fields) |field| {
+        name_width = @max(name_width, field.name.len);
+        type_width = @max(type_width, field.go_type.len);
+    }
+    try writer.print("type {s} struct {{\n", .{s.name});
+    for (s.fields) |field| {
+        if (std.mem.indexOf(u8, field.go_type, "time.") != null) imports.time = true;
+        var tags = std.ArrayList([]const u8){};
+        if (field.json_name) |json| {
+            try tags.append(arena, try std.fmt.allocPrint(arena, "json:\"{s}{s}\"", .{ json, if (field.optional) ",omitempty" else "" }));
+        }
+        var rules = std.ArrayList([]const u8){};
+        if (field.optional) try rules.append(arena, "omitempty");
+        for (field.checks) |check| {
+            if (try tagRule(arena, check)) |rule| try rules.append(arena, rule);
+        }
+        if (rules.items.len > 0) {
+            try tags.append(arena, try std.fmt.allocPrint(arena, "validate:\"{s}\"", .{try std.mem.join(arena, ",", rules.items)}));
+        }
+
+        try writer.print("\t{s}", .{field.name});
+        if (tags.items.len == 0) {
+            try writer.writeByteNTimes(' ', name_width - field.name.len + 1);
+            try writer.print("{s}\n", .{field.go_type});
+            continue;
+        }
+        try writer.writeByteNTimes(' ', name_width - field.name.len + 1);
+        try writer.writeAll(field.go_type);
+        try writer.writeByteNTimes(' ', type_width - field.go_type.len + 1);
+        try writer.print("`{s}`\n", .{try std.mem.join(arena, " ", tags.items)});
+    }
+    try writer.writeAll("}\n");
+
+    if (!s.hasChecks()) return;
+    imports.errors = true;
+    try writer.print("\n// Validate reports the first rule of {s} that v breaks.\n", .{s.name});
+    try writer.print("func (v *{s}) Validate() error {{\n", .{s.name});
+    for (s.fields) |field| {
+        const key = fieldKey(field);
+        var required = false;
+        for (field.checks) |check| {
+            if (check.kind == .required) required = true;
+        }
+        if (required and !field.optional) {
+            if (try zeroTest(arena, field, "==")) |cond| try writeReject(arena, writer, "\t", cond, key, "is required");
+        }
+
+        // Rules of an omitempty field hold only when it is set; nested so
+        // each rule keeps its own `if`
+        var body = std.ArrayList(u8){};
+        const guard = if (field.optional) try zeroTest(arena, field, "!=") else null;
+        const indent = if (guard != null) "\t\t" else "\t";
+        for (field.checks) |check| {
+            try writeCheck(arena, body.writer(arena), indent, s, field, check, vars, imports);
+        }
+        if (body.items.len == 0) continue;
+        if (guard) |cond| {
+            try writer.print("\tif {s} {{\n{s}\t}}\n", .{ cond, body.items });
+        } else {
+            try writer.writeAll(body.items);
+        }
+    }
+    try writer.writeAll("\treturn nil\n}\n");
+}
+
+/// Go source for the structs of `spec`, as resolved by `resolve`
+pub fn scaffold(allocator: std.mem.Allocator, spec: Spec, structs: []const go_rules.Struct, spec_label: []const u8) ![]u8 {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var body = std.ArrayList(u8){};
+    var vars = std.ArrayList(u8){};
+    var imports = Imports{};
+    for (spec.structs, structs) |s_spec, s| {
+        try writeStruct(arena, body.writer(arena), s, s_spec.doc, &vars, &imports);
+    }
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const out = list.writer(allocator);
+    try out.print("{s} from {s}; edit freely.\n\npackage {s}\n", .{ scaffold_marker, spec_label, spec.package });
+    const names = [_][]const u8{ "errors", "net/mail", "regexp", "strings", "time", "unicode/utf8" };
+    const used = [_]bool{ imports.errors, imports.mail, imports.regexp, imports.strings, imports.time, imports.utf8 };
+    if (std.mem.indexOfScalar(bool, &used, true) != null) {
+        try out.writeAll("\nimport (\n");
+        for (names, used) |name, wanted| {
+            if (wanted) try out.print("\t\"{s}\"\n", .{name});
+        }
+        try out.writeAll(")\n");
+    }
+    if (vars.items.len > 0) {
+        try out.writeAll("\n");
+        try out.writeAll(vars.items);
+    }
+    try out.writeAll(body.items);
+    return try list.toOwnedSlice(allocator);
+}
+
+/// Rules of a recovered field in spec syntax
+fn specRules(arena: std.mem.Allocator, field: go_rules.Field) ![]const []const u8 {
+    var rules = std.ArrayList([]const u8){};
+    if (field.optional) try rules.append(arena, "omitempty");
+    for (field.checks) |check| {
+        try rules.append(arena, switch (check.kind) {
+            .pattern => try std.fmt.allocPrint(arena, "pattern={s}", .{check.text}),
+            .contains_any => try std.fmt.allocPrint(arena, "containsany={s}", .{check.text}),
+            else => (try tagRule(arena, check)).?,
+        });
+    }
+    return rules.items;
+}
+
+/// A spec of the structs `go_rules` recovered from a package, the other
+/// half of the round trip
+pub fn formatSpec(allocator: std.mem.Allocator, package: []const u8, structs: []const go_rules.Struct) ![]u8 {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    try writer.print("{{\n  \"version\": {d},\n  \"package\": \"", .{format_version});
+    try output.writeJsonEscaped(writer, package);
+    try writer.writeAll("\",\n  \"structs\": [");
+    for (structs, 0..) |s, i| {
+        try writer.writeAll(if (i == 0) "\n" else ",\n");
+        try writer.writeAll("    {\n      \"name\": \"");
+        try output.writeJsonEscaped(writer, s.name);
+        try writer.writeAll("\",\n      \"fields\": [");
+        for (s.fields, 0..) |field, j| {
+            try writer.writeAll(if (j == 0) "\n" else ",\n");
+            try writer.writeAll("        {\"name\": \"");
+            try output.writeJsonEscaped(writer, field.name);
+            try writer.writeAll("\", \"type\": \"");
+            try output.writeJsonEscaped(writer, field.go_type);
+            try writer.writeAll("\"");
+            if (field.json_name) |json| {
+                try writer.writeAll(", \"json\": \"");
+                try output.writeJsonEscaped(writer, json);
+                try writer.writeAll("\"");
+            }
+            const rules = try specRules(arena, field);
+            if (rules.len > 0) {
+                try writer.writeAll(", \"rules\": [");
+                for (rules, 0..) |rule, k| {
+                    if (k > 0) try writer.writeAll(", ");
+                    try writer.writeAll("\"");
+                    try output.writeJsonEscaped(writer, rule);
+                    try writer.writeAll("\"");
+                }
+                try writer.writeAll("]");
+            }
+            try writer.writeAll("}");
+        }
+        try writer.writeAll(if (s.fields.len > 0) "\n      ]\n    }" else "]\n    }");
+    }
+    try writer.writeAll(if (structs.len > 0) "\n  ]\n}\n" else "]\n}\n");
+    return try list.toOwnedSlice(allocator);
+}
+
+test "scaffold a spec and recover it from the code" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const spec_text =
+        \\{
+        \\  "version": 1,
+        \\  "package": "api",
+        \\  "structs": [
+        \\    {
+        \\      "name": "CreateUserRequest",
+        \\      "doc": "CreateUserRequest is the body of POST /users.",
+        \\      "fields": [
+        \\        {"name": "Username", "type": "string", "json": "username", "rules": ["required", "min=3", "max=50", "pattern=^[a-z0-9_]+$"]},
+        \\        {"name": "Email", "type": "string", "json": "email", "rules": ["required", "email"]},
+        \\        {"name": "Age", "type": "int", "json": "age", "rules": ["omitempty", "min=13", "max=129"]},
+        \\        {"name": "Role", "type": "string", "json": "role", "rules": ["oneof=admin member"]},
+        \\        {"name": "Tags", "type": "[]string", "json": "tags", "rules": ["max=5"]},
+        \\        {"name": "Password", "type": "string", "json": "password", "rules": ["containsany=0123456789"]}
+        \\      ]
+        \\    }
+        \\  ]
+        \\}
+    ;
+    const spec = try parseSpec(arena, spec_text);
+    var problem: []const u8 = "";
+    const structs = try resolve(arena, spec, &problem);
+    const code = try scaffold(arena, spec, structs, "spec.json");
+
+    for ([_][]const u8{
+        "// Code scaffolded by ananke scaffold from spec.json; edit freely.\n\npackage api\n",
+        "var createUserRequestUsernameRegexp = regexp.MustCompile(`^[a-z0-9_]+$`)\n",
+        "// CreateUserRequest is the body of POST /users.\ntype CreateUserRequest struct {\n",
+        "\tUsername string   `json:\"username\" validate:\"required,min=3,max=50\"`\n",
+        "\tAge      int      `json:\"age,omitempty\" validate:\"omitempty,min=13,max=129\"`\n",
+        "\tif v.Username == \"\" {\n\t\treturn errors.New(\"username is required\")\n\t}\n",
+        "\tif v.Age != 0 {\n\t\tif v.Age < 13 {\n\t\t\treturn errors.New(\"age must be at least 13\")\n\t\t}\n",
+        "\tif n := len(v.Tags); n > 5 {\n",
+    }) |expected| {
+        try testing.expect(std.mem.indexOf(u8, code, expected) != null);
+    }
+
+    // The code gives back the rules it was scaffolded from
+    const recovered = try go_rules.parse(arena, code);
+    try testing.expectEqualStrings("api", recovered.package);
+    try testing.expectEqual(@as(usize, 1), recovered.structs.len);
+    try testing.expect(recovered.structs[0].has_validate);
+    for (structs[0].fields, recovered.structs[0].fields) |want, got| {
+        try testing.expectEqual(want.optional, got.optional);
+        try testing.expectEqual(want.checks.len, got.checks.len);
+        for (want.checks, got.checks) |a, b| {
+            try testing.expectEqual(a.kind, b.kind);
+            try testing.expectEqual(a.bound, b.bound);
+            try testing.expectEqualStrings(a.text, b.text);
+        }
+    }
+    const round_trip = try parseSpec(arena, try formatSpec(arena, recovered.package, recovered.structs));
+    try testing.expectEqualStrings("pattern=^[a-z0-9_]+$", round_trip.structs[0].fields[0].rules[3]);
+
+    const bad = try parseSpec(arena, "{\"package\": \"api\", \"structs\": [{\"name\": \"X\", \"fields\": [{\"name\": \"N\", \"type\": \"int\", \"rules\": [\"uuid\"]}]}]}");
+    try testing.expectError(ScaffoldError.InvalidSpec, resolve(arena, bad, &problem));
+    try testing.expectEqualStrings("rule \"uuid\" of N (int) is not supported", problem);
+}
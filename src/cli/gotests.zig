@@ -0,0 +1,687 @@
+// Go test generation
+// Turns the typed rules `go_rules` recovers from a Go file into table-driven
+// tests in the same package: each bound gets a case just outside it that must
+// be rejected and, where a valid value can be built, one on it that must be
+// accepted. Validator functions are called directly; structs through their
+// Validate method, or go-playground/validator or gin's binding validator when
+// only tags declare the rules. Generated files carry a build tag so they run
+// only on request (`go test -tags ananke ./...`).
+const std = @import("std");
+const go_rules = @import("cli_go_rules");
+const go_http = @import("cli_go_http");
+
+pub const default_tag = "ananke";
+/// Suffix of generated files; `users.go` gets `users_ananke_test.go`
+pub const file_suffix = "_ananke_test.go";
+
+pub fn testPath(allocator: std.mem.Allocator, source_path: []const u8) ![]u8 {
+    const stem = if (std.mem.endsWith(u8, source_path, ".go")) source_path[0 .. source_path.len - 3] else source_path;
+    return std.fmt.allocPrint(allocator, "{s}{s}", .{ stem, file_suffix });
+}
+
+/// A case of a table: a Go expression and whether validating it must fail
+const Case = struct {
+    name: []const u8,
+    value: []const u8,
+    want_err: bool,
+};
+
+fn findCheck(checks: []const go_rules.Check, kind: go_rules.CheckKind) ?go_rules.Check {
+    for (checks) |c| {
+        if (c.kind == kind) return c;
+    }
+    return null;
+}
+
+fn writeGoString(writer: anytype, text: []const u8) !void {
+    try writer.writeByte('"');
+    for (text) |ch| {
+        switch (ch) {
+            '"' => try writer.writeAll("\\\""),
+            '\\' => try writer.writeAll("\\\\"),
+            '\n' => try writer.writeAll("\\n"),
+            '\t' => try writer.writeAll("\\t"),
+            else => try writer.writeByte(ch),
+        }
+    }
+    try writer.writeByte('"');
+}
+
+fn goString(arena: std.mem.Allocator, text: []const u8) ![]const u8 {
+    var list = std.ArrayList(u8){};
+    try writeGoString(list.writer(arena), text);
+    return list.items;
+}
+
+/// A string of `len` characters, ending in `tail` if given
+fn stringOfLength(arena: std.mem.Allocator, len: i64, tail: []const u8) ![]const u8 {
+    const fill = len - @as(i64, @intCast(tail.len));
+    if (fill <= 0) return goString(arena, tail);
+    if (fill <= 8) {
+        const text = try arena.alloc(u8, @intCast(fill));
+        @memset(text, 'a');
+        return goString(arena, try std.mem.concat(arena, u8, &.{ text, tail }));
+    }
+    if (tail.len == 0) return std.fmt.allocPrint(arena, "strings.Repeat(\"a\", {d})", .{fill});
+    return std.fmt.allocPrint(arena, "strings.Repeat(\"a\", {d}) + {s}", .{ fill, try goString(arena, tail) });
+}
+
+/// A character a `^[class]quantifier$` pattern rejects, or null when the
+/// pattern is not that simple or the class might allow it
+fn rejectedByPattern(pattern: []const u8) ?[]const u8 {
+    if (!std.mem.startsWith(u8, pattern, "^[") or !std.mem.endsWith(u8, pattern, "$")) return null;
+    const close = std.mem.indexOfScalar(u8, pattern, ']') orelse return null;
+    const class = pattern[2..close];
+    if (class.len == 0 or class[0] == '^') return null;
+    if (std.mem.indexOfAny(u8, class, "!\\") != null) return null;
+    const quantifier = pattern[close + 1 .. pattern.len - 1];
+    if (!(std.mem.eql(u8, quantifier, "+") or std.mem.eql(u8, quantifier, "*") or
+        (quantifier.len > 2 and quantifier[0] == '{' and quantifier[quantifier.len - 1] == '}'))) return null;
+    return "!";
+}
+
+/// One character of each contains_any set but `skip`, none of them in
+/// `skip`; null when a set has no such character
+fn requiredChars(arena: std.mem.Allocator, checks: []const go_rules.Check, skip: []const u8) !?[]const u8 {
+    var chars = std.ArrayList(u8){};
+    for (checks) |c| {
+        if (c.kind != .contains_any or std.mem.eql(u8, c.text, skip)) continue;
+        if (std.mem.indexOfAny(u8, chars.items, c.text) != null) continue;
+        const ch = for (c.text) |candidate| {
+            if (std.mem.indexOfScalar(u8, skip, candidate) == null) break candidate;
+        } else return null;
+        try chars.append(arena, ch);
+    }
+    return chars.items;
+}
+
+/// Cases for a value of `class` under `checks`. `optional` values accept
+/// the zero value, so no case rejects it. Acceptance cases need a value
+/// that satisfies every check, which a pattern, email, or option list
+/// prevents.
+fn casesFor(
+    arena: std.mem.Allocator,
+    class: go_rules.TypeClass,
+    checks: []const go_rules.Check,
+    optional: bool,
+    uses_strings: *bool,
+) ![]const Case {
+    var cases = std.ArrayList(Case){};
+    const min_len = findCheck(checks, .min_len);
+    const max_len = findCheck(checks, .max_len);
+    const min = findCheck(checks, .min);
+    const max = findCheck(checks, .max);
+    const required = findCheck(checks, .required) != null;
+
+    switch (class) {
+        .string => {
+            if (min_len) |c| {
+                if (c.bound > 0 and !(optional and c.bound == 1)) {
+                    try cases.append(arena, .{
+                        .name = try std.fmt.allocPrint(arena, "rejects {d} chars (min length {d})", .{ c.bound - 1, c.bound }),
+                        .value = try stringOfLength(arena, c.bound - 1, ""),
+                        .want_err = true,
+                    });
+                }
+            }
+            if (max_len) |c| {
+                try cases.append(arena, .{
+                    .name = try std.fmt.allocPrint(arena, "rejects {d} chars (max length {d})", .{ c.bound + 1, c.bound }),
+                    .value = try stringOfLength(arena, c.bound + 1, ""),
+                    .want_err = true,
+                });
+            }
+            if (required and (min_len == null or min_len.?.bound <= 0)) {
+                try cases.append(arena, .{ .name = "rejects empty (required)", .value = "\"\"", .want_err = true });
+            }
+            const lower = @max(if (min_len) |c| c.bound else 0, @as(i64, if (required) 1 else 0));
+            if (findCheck(checks, .pattern)) |c| {
+                if (rejectedByPattern(c.text)) |bad| {
+                    const len = @max(lower, 1);
+                    if (max_len == null or len <= max_len.?.bound) {
+                        try cases.append(arena, .{
+                            .name = try std.fmt.allocPrint(arena, "rejects {s} (pattern {s})", .{ bad, c.text }),
+                            .value = try stringOfLength(arena, len, bad),
+                            .want_err = true,
+                        });
+                    }
+                }
+            }
+            if (findCheck(checks, .email) != null) {
+                try cases.append(arena, .{ .name = "rejects a malformed address (email)", .value = "\"not-an-email\"", .want_err = true });
+            }
+            if (findCheck(checks, .one_of)) |c| {
+                try cases.append(arena, .{
+                    .name = try std.fmt.allocPrint(arena, "rejects a value outside {s} (one of)", .{c.text}),
+                    .value = "\"ananke-not-an-option\"",
+                    .want_err = true,
+                });
+            }
+
+            for (checks) |c| {
+                if (c.kind != .contains_any or std.mem.indexOfScalar(u8, c.text, 'a') != null) continue;
+                const others = try requiredChars(arena, checks, c.text) orelse continue;
+                const len = @max(lower, @as(i64, @intCast(@max(others.len, 1))));
+                if (max_len != null and len > max_len.?.bound) continue;
+                try cases.append(arena, .{
+                    .name = try std.fmt.allocPrint(arena, "rejects none of {s} (contains any of)", .{c.text}),
+                    .value = try stringOfLength(arena, len, others),
+                    .want_err = true,
+                });
+            }
+
+            const tail = (try requiredChars(arena, checks, "")).?;
+            const shortest = @max(lower, @as(i64, @intCast(tail.len)));
+            if (findCheck(checks, .pattern) != null or findCheck(checks, .email) != null or findCheck(checks, .one_of) != null) {
+                // No value known to pass
+            } else if (max_len == null or shortest <= max_len.?.bound) {
+                if (min_len != null or required or tail.len > 0) {
+                    try cases.append(arena, .{
+                        .name = try std.fmt.allocPrint(arena, "accepts {d} chars", .{shortest}),
+                        .value = try stringOfLength(arena, shortest, tail),
+                        .want_err = false,
+                    });
+                }
+                if (max_len) |c| {
+                    if (c.bound != shortest) {
+                        try cases.append(arena, .{
+                            .name = try std.fmt.allocPrint(arena, "accepts {d} chars", .{c.bound}),
+                            .value = try stringOfLength(arena, c.bound, tail),
+                            .want_err = false,
+                        });
+                    }
+                }
+            }
+        },
+        .integer, .float => {
+            if (min) |c| {
+                if (!(optional and c.bound - 1 == 0)) {
+                    try cases.append(arena, .{
+                        .name = try std.fmt.allocPrint(arena, "rejects {d} (min {d})", .{ c.bound - 1, c.bound }),
+                        .value = try std.fmt.allocPrint(arena, "{d}", .{c.bound - 1}),
+                        .want_err = true,
+                    });
+                }
+            }
+            if (max) |c| {
+                if (!(optional and c.bound + 1 == 0)) {
+                    try cases.append(arena, .{
+                        .name = try std.fmt.allocPrint(arena, "rejects {d} (max {d})", .{ c.bound + 1, c.bound }),
+                        .value = try std.fmt.allocPrint(arena, "{d}", .{c.bound + 1}),
+                        .want_err = true,
+                    });
+                }
+            }
+            const covers_zero = if (min) |c| c.bound > 0 else false;
+            if (required and !covers_zero) {
+                try cases.append(arena, .{ .name = "rejects 0 (required)", .value = "0", .want_err = true });
+            }
+            if (min == null or max == null or min.?.bound <= max.?.bound) {
+                for ([_]?go_rules.Check{ min, max }) |bound| {
+                    const c = bound orelse continue;
+                    if (required and c.bound == 0) continue;
+                    if (c.kind == .max and min != null and min.?.bound == c.bound) continue;
+                    try cases.append(arena, .{
+                        .name = try std.fmt.allocPrint(arena, "accepts {d}", .{c.bound}),
+                        .value = try std.fmt.allocPrint(arena, "{d}", .{c.bound}),
+                        .want_err = false,
+                    });
+                }
+            }
+        },
+        .other => {},
+    }
+
+    for (cases.items) |case| {
+        if (std.mem.startsWith(u8, case.value, "strings.")) uses_strings.* = true;
+    }
+    return cases.items;
+}
+
+/// A value of `field` that passes its checks, "" for the zero value, or null
+/// when none is known
+fn validValue(arena: std.mem.Allocator, field: go_rules.Field) !?[]const u8 {
+    const checks = field.checks;
+    const required = findCheck(checks, .required) != null;
+    switch (field.class()) {
+        .string => {
+            if (findCheck(checks, .pattern) != null) return null;
+            if (findCheck(checks, .email) != null) return "\"user@example.com\"";
+            if (findCheck(checks, .one_of)) |c| {
+                var options = std.mem.tokenizeScalar(u8, c.text, ' ');
+                return try goString(arena, options.next() orelse return null);
+            }
+            const tail = (try requiredChars(arena, checks, "")).?;
+            const len = @max(
+                if (findCheck(checks, .min_len)) |c| c.bound else 0,
+                @as(i64, if (required) 1 else 0),
+                @as(i64, @intCast(tail.len)),
+            );
+            if (findCheck(checks, .max_len)) |c| {
+                if (len > c.bound) return null;
+            }
+            return if (len == 0) "" else try stringOfLength(arena, len, tail);
+        },
+        .integer, .float => {
+            var value: i64 = if (findCheck(checks, .min)) |c| c.bound else 0;
+            if (required and value == 0) value = 1;
+            if (findCheck(checks, .max)) |c| {
+                if (value > c.bound) return null;
+            }
+            return if (value == 0) "" else try std.fmt.allocPrint(arena, "{d}", .{value});
+        },
+        .other => return if (checks.len == 0) "" else null,
+    }
+}
+
+fn testName(arena: std.mem.Allocator, name: []const u8) ![]const u8 {
+    return std.fmt.allocPrint(arena, "Test{c}{s}Constraints", .{ std.ascii.toUpper(name[0]), name[1..] });
+}
+
+fn writeCases(writer: anytype, cases: []const Case) !void {
+    for (cases) |case| {
+        try writer.writeAll("\t\t{");
+        try writeGoString(writer, case.name);
+        try writer.print(", {s}, {s}}},\n", .{ case.value, if (case.want_err) "true" else "false" });
+    }
+}
+
+fn writeValidatorTest(arena: std.mem.Allocator, writer: anytype, file_name: []const u8, v: go_rules.Validator, uses_strings: *bool) !bool {
+    const class = go_rules.TypeClass.of(v.param_type);
+    const cases = try casesFor(arena, class, v.checks, false, uses_strings);
+    if (cases.len == 0) return false;
+
+    try writer.print("\n// Cases from the checks of {s} ({s}:{d})\n", .{ v.name, file_name, v.line });
+    try writer.print("func {s}(t *testing.T) {{\n", .{try testName(arena, v.name)});
+    try writer.print("\ttests := []struct {{\n\t\tname    string\n\t\tinput   {s}\n\t\twantErr bool\n\t}}{{\n", .{v.param_type});
+    try writeCases(writer, cases);
+    try writer.writeAll("\t}\n\tfor _, tt := range tests {\n\t\tt.Run(tt.name, func(t *testing.T) {\n");
+    try writer.print("\t\t\terr := {s}(tt.input)\n", .{v.name});
+    try writer.writeAll("\t\t\tif (err != nil) != tt.wantErr {\n");
+    try writer.print("\t\t\t\tt.Errorf(\"{s}(%{s}) error = %v, wantErr %v\", tt.input, err, tt.wantErr)\n", .{
+        v.name,
+        if (class == .string) "q" else "v",
+    });
+    try writer.writeAll("\t\t\t}\n\t\t})\n\t}\n}\n");
+    return true;
+}
+
+const Imports = struct {
+    strings: bool = false,
+    validator: bool = false,
+    binding: bool = false,
+    http: bool = false,
+    json: bool = false,
+};
+
+const Entry = struct {
+    name: []const u8,
+    value: []const u8,
+};
+
+/// Cases for a struct: a valid instance to start from and, per case, a func
+/// literal that breaks (or keeps) it by setting one field
+const StructCases = struct {
+    baseline: []const Entry,
+    /// Every field of the baseline is known to pass its checks
+    baseline_known: bool,
+    cases: []const Case,
+    uses_strings: bool,
+};
+
+/// Null when a required field has a type no value can be built for, which
+/// would make every case fail for the same reason
+fn structCases(arena: std.mem.Allocator, s: go_rules.Struct) !?StructCases {
+    var baseline = std.ArrayList(Entry){};
+    var baseline_known = true;
+    var uses_strings = false;
+    for (s.fields) |field| {
+        const value = try validValue(arena, field) orelse {
+            if (field.class() == .other) return null;
+            baseline_known = false;
+            continue;
+        };
+        if (value.len == 0) continue;
+        if (std.mem.startsWith(u8, value, "strings.")) uses_strings = true;
+        try baseline.append(arena, .{ .name = field.name, .value = value });
+    }
+
+    var cases = std.ArrayList(Case){};
+    for (s.fields) |field| {
+        for (try casesFor(arena, field.class(), field.checks, field.optional, &uses_strings)) |case| {
+            if (!case.want_err and !baseline_known) continue;
+            try cases.append(arena, .{
+                .name = try std.fmt.allocPrint(arena, "{s} {s}", .{ field.name, case.name }),
+                .value = try std.fmt.allocPrint(arena, "func(v *{s}) {{ v.{s} = {s} }}", .{ s.name, field.name, case.value }),
+                .want_err = case.want_err,
+            });
+        }
+    }
+    return .{ .baseline = baseline.items, .baseline_known = baseline_known, .cases = cases.items, .uses_strings = uses_strings };
+}
+
+/// `valid := func() T { return T{...} }`, aligned the way gofmt aligns
+/// keyed literals
+fn writeValidFunc(writer: anytype, type_name: []const u8, baseline: []const Entry) !void {
+    try writer.print("\tvalid := func() {s} {{\n\t\treturn {s}{{", .{ type_name, type_name });
+    if (baseline.len > 0) {
+        try writer.writeAll("\n");
+        var width: usize = 0;
+        for (baseline) |entry| width = @max(width, entry.name.len);
+        for (baseline) |entry| {
+            try writer.print("\t\t\t{s}:", .{entry.name});
+            try writer.writeByteNTimes(' ', width - entry.name.len + 1);
+            try writer.print("{s},\n", .{entry.value});
+        }
+        try writer.writeAll("\t\t");
+    }
+    try writer.writeAll("}\n\t}\n");
+}
+
+fn writeStructTest(arena: std.mem.Allocator, writer: anytype, file_name: []const u8, s: go_rules.Struct, imports: *Imports) !bool {
+    if (!s.hasChecks()) return false;
+    const call = if (s.has_validate)
+        "v.Validate()"
+    else switch (s.tag_style) {
+        .validate => "validator.New().Struct(v)",
+        .binding => "binding.Validator.ValidateStruct(v)",
+        .none => return false,
+    };
+    const built = try structCases(arena, s) orelse return false;
+    if (built.cases.len == 0) return false;
+
+    var cases = std.ArrayList(Case){};
+    if (built.baseline_known) {
+        try cases.append(arena, .{ .name = "valid", .value = try std.fmt.allocPrint(arena, "func(*{s}) {{}}", .{s.name}), .want_err = false });
+    }
+    try cases.appendSlice(arena, built.cases);
+
+    try writer.print("\n// Cases from the rules of {s} ({s}:{d})\n", .{ s.name, file_name, s.line });
+    try writer.print("func {s}(t *testing.T) {{\n", .{try testName(arena, s.name)});
+    try writeValidFunc(writer, s.name, built.baseline);
+    try writer.print("\ttests := []struct {{\n\t\tname    string\n\t\tmutate  func(*{s})\n\t\twantErr bool\n\t}}{{\n", .{s.name});
+    try writeCases(writer, cases.items);
+    try writer.writeAll("\t}\n\tfor _, tt := range tests {\n\t\tt.Run(tt.name, func(t *testing.T) {\n");
+    try writer.writeAll("\t\t\tv := valid()\n\t\t\ttt.mutate(&v)\n");
+    try writer.print("\t\t\terr := {s}\n", .{call});
+    try writer.writeAll("\t\t\tif (err != nil) != tt.wantErr {\n");
+    try writer.print("\t\t\t\tt.Errorf(\"{s}: error = %v, wantErr %v\", err, tt.wantErr)\n", .{s.name});
+    try writer.writeAll("\t\t\t}\n\t\t})\n\t}\n}\n");
+
+    if (built.uses_strings) imports.strings = true;
+    if (!s.has_validate) switch (s.tag_style) {
+        .validate => imports.validator = true,
+        .binding => imports.binding = true,
+        .none => {},
+    };
+    return true;
+}
+
+/// `http.StatusBadRequest`, or the number for codes without a name
+fn statusExpr(arena: std.mem.Allocator, code: u16) ![]const u8 {
+    if (go_http.statusName(code)) |name| return std.fmt.allocPrint(arena, "http.{s}", .{name});
+    return std.fmt.allocPrint(arena, "{d}", .{code});
+}
+
+/// `http.MethodPost` for POST
+fn methodExpr(arena: std.mem.Allocator, method: []const u8) ![]const u8 {
+    const rest = try std.ascii.allocLowerString(arena, method[1..]);
+    return std.fmt.allocPrint(arena, "http.Method{c}{s}", .{ method[0], rest });
+}
+
+/// A request path for a route pattern: `{id}` and `:id` segments become "1"
+fn requestPath(arena: std.mem.Allocator, pattern: []const u8) ![]const u8 {
+    var list = std.ArrayList(u8){};
+    var segments = std.mem.splitScalar(u8, pattern, '/');
+    var first = true;
+    while (segments.next()) |segment| {
+        if (!first) try list.append(arena, '/');
+        first = false;
+        const is_param = (std.mem.startsWith(u8, segment, "{") and std.mem.endsWith(u8, segment, "}")) or std.mem.startsWith(u8, segment, ":");
+        try list.appendSlice(arena, if (is_param) "1" else segment);
+    }
+    return list.items;
+}
+
+fn writeStatusCheck(writer: anytype, indent: []const u8, method: []const u8, body: []const u8, status: []const u8) !void {
+    try writer.print("{s}if code := serve({s}, {s}); code != {s} {{\n", .{ indent, method, body, status });
+    try writer.print("{s}\tt.Errorf(\"status = %d, want %d\", code, {s})\n", .{ indent, status });
+    try writer.print("{s}}}\n", .{indent});
+}
+
+/// Contract test of a net/http handler: the rejections its code documents
+/// (wrong method, malformed body, each invalid DTO field), sent through
+/// httptest to a zero-value receiver. Rejections happen before a handler
+/// touches its dependencies, so none are needed; accepted requests are not
+/// sent for the same reason.
+fn writeContractTest(
+    arena: std.mem.Allocator,
+    writer: anytype,
+    file_name: []const u8,
+    h: go_http.Handler,
+    route: ?go_http.Route,
+    dto: ?go_rules.Struct,
+    imports: *Imports,
+) !bool {
+    const method = h.method orelse (if (route) |r| r.method else null) orelse (if (h.dto != null) "POST" else "GET");
+    const method_status = if (h.method != null) h.method_status else null;
+    const dto_cases: ?StructCases = blk: {
+        const s = dto orelse break :blk null;
+        if (!h.validates or h.validation_status == null) break :blk null;
+        const built = try structCases(arena, s) orelse break :blk null;
+        break :blk if (built.cases.len > 0) built else null;
+    };
+    if (method_status == null and h.decode_status == null and dto_cases == null) return false;
+
+    const call = if (h.receiver_type) |recv|
+        try std.fmt.allocPrint(arena, "{s}{s}{{}}{s}.{s}", .{ if (h.pointer_receiver) "(&" else "", recv, if (h.pointer_receiver) ")" else "", h.name })
+    else
+        h.name;
+    const path = try requestPath(arena, if (route) |r| r.path else "/");
+    const method_arg = try methodExpr(arena, method);
+
+    try writer.print("\n// Contract of {s} {s}, served by {s} ({s}:{d})\n", .{ method, if (route) |r| r.path else "(unrouted)", h.name, file_name, h.line });
+    try writer.print("func {s}(t *testing.T) {{\n", .{try std.fmt.allocPrint(arena, "Test{c}{s}Contract", .{ std.ascii.toUpper(h.name[0]), h.name[1..] })});
+    try writer.writeAll("\tserve := func(method, body string) int {\n");
+    try writer.print("\t\treq := httptest.NewRequest(method, \"{s}\", strings.NewReader(body))\n", .{path});
+    try writer.writeAll("\t\treq.Header.Set(\"Content-Type\", \"application/json\")\n");
+    try writer.writeAll("\t\trec := httptest.NewRecorder()\n");
+    try writer.print("\t\t{s}(rec, req)\n\t\treturn rec.Code\n\t}}\n", .{call});
+
+    if (method_status) |code| {
+        const other = if (std.mem.eql(u8, method, "DELETE")) "PUT" else "DELETE";
+        try writer.writeAll("\tt.Run(\"rejects other methods\", func(t *testing.T) {\n");
+        try writeStatusCheck(writer, "\t\t", try methodExpr(arena, other), "\"\"", try statusExpr(arena, code));
+        try writer.writeAll("\t})\n");
+    }
+    if (h.decode_status) |code| {
+        try writer.writeAll("\tt.Run(\"rejects a malformed body\", func(t *testing.T) {\n");
+        try writeStatusCheck(writer, "\t\t", method_arg, "\"{\"", try statusExpr(arena, code));
+        try writer.writeAll("\t})\n");
+    }
+    if (dto_cases) |built| {
+        const s = dto.?;
+        try writeValidFunc(writer, s.name, built.baseline);
+        try writer.print("\ttests := []struct {{\n\t\tname   string\n\t\tmutate func(*{s})\n\t}}{{\n", .{s.name});
+        for (built.cases) |case| {
+            if (!case.want_err) continue;
+            try writer.writeAll("\t\t{");
+            try writeGoString(writer, case.name);
+            try writer.print(", {s}}},\n", .{case.value});
+        }
+        try writer.writeAll("\t}\n\tfor _, tt := range tests {\n\t\tt.Run(tt.name, func(t *testing.T) {\n");
+        try writer.writeAll("\t\t\tv := valid()\n\t\t\ttt.mutate(&v)\n");
+        try writer.writeAll("\t\t\tbody, err := json.Marshal(v)\n\t\t\tif err != nil {\n\t\t\t\tt.Fatal(err)\n\t\t\t}\n");
+        try writeStatusCheck(writer, "\t\t\t", method_arg, "string(body)", try statusExpr(arena, h.validation_status.?));
+        try writer.writeAll("\t\t})\n\t}\n");
+        imports.json = true;
+    }
+    try writer.writeAll("}\n");
+    imports.http = true;
+    imports.strings = true;
+    return true;
+}
+
+pub const Input = struct {
+    /// Path of the source file, for comments
+    file_name: []const u8,
+    rules: go_rules.File,
+    handlers: []const go_http.Handler = &.{},
+    /// Structs of the whole package, for DTOs declared in other files
+    structs: []const go_rules.Struct = &.{},
+    /// Routes registered anywhere, for handlers registered in other files
+    routes: []const go_http.Route = &.{},
+};
+
+fn findStruct(structs: []const go_rules.Struct, name: []const u8) ?go_rules.Struct {
+    for (structs) |s| {
+        if (std.mem.eql(u8, s.name, name)) return s;
+    }
+    return null;
+}
+
+/// Test file for the rules and handlers of one source file, or null when
+/// there is nothing to test
+pub fn generate(allocator: std.mem.Allocator, input: Input, tag: []const u8) !?[]u8 {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var body = std.ArrayList(u8){};
+    const writer = body.writer(arena);
+    var imports = Imports{};
+    var tests: usize = 0;
+    for (input.rules.validators) |v| {
+        if (try writeValidatorTest(arena, writer, input.file_name, v, &imports.strings)) tests += 1;
+    }
+    for (input.rules.structs) |s| {
+        if (try writeStructTest(arena, writer, input.file_name, s, &imports)) tests += 1;
+    }
+    for (input.handlers) |h| {
+        const dto = if (h.dto) |name| findStruct(input.structs, name) orelse findStruct(input.rules.structs, name) else null;
+        if (try writeContractTest(arena, writer, input.file_name, h, go_http.routeOf(input.routes, h), dto, &imports)) tests += 1;
+    }
+    if (tests == 0) return null;
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const out = list.writer(allocator);
+    try out.print("//go:build {s}\n\n// Code generated by ananke gen-tests from {s}; DO NOT EDIT.\n\npackage {s}\n\nimport (\n", .{
+        tag,
+        std.fs.path.basename(input.file_name),
+        input.rules.package,
+    });
+    if (imports.json) try out.writeAll("\t\"encoding/json\"\n");
+    if (imports.http) try out.writeAll("\t\"net/http\"\n\t\"net/http/httptest\"\n");
+    if (imports.strings) try out.writeAll("\t\"strings\"\n");
+    try out.writeAll("\t\"testing\"\n");
+    if (imports.validator or imports.binding) try out.writeAll("\n");
+    if (imports.binding) try out.writeAll("\t\"github.com/gin-gonic/gin/binding\"\n");
+    if (imports.validator) try out.writeAll("\t\"github.com/go-playground/validator/v10\"\n");
+    try out.writeAll(")\n");
+    try out.writeAll(body.items);
+    return try list.toOwnedSlice(allocator);
+}
+
+test "generate boundary cases for a validator function" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const file = go_rules.File{
+        .package = "users",
+        .validators = &.{.{
+            .name = "ValidateUsername",
+            .param = "name",
+            .param_type = "string",
+            .line = 12,
+            .checks = &.{ .{ .kind = .min_len, .bound = 3 }, .{ .kind = .max_len, .bound = 50 } },
+        }},
+    };
+    const text = (try generate(arena, .{ .file_name = "users/users.go", .rules = file }, default_tag)).?;
+    try testing.expectEqualStrings(
+        \\//go:build ananke
+        \\
+        \\// Code generated by ananke gen-tests from users.go; DO NOT EDIT.
+        \\
+        \\package users
+        \\
+        \\import (
+        \\	"strings"
+        \\	"testing"
+        \\)
+        \\
+        \\// Cases from the checks of ValidateUsername (users/users.go:12)
+        \\func TestValidateUsernameConstraints(t *testing.T) {
+        \\	tests := []struct {
+        \\		name    string
+        \\		input   string
+        \\		wantErr bool
+        \\	}{
+        \\		{"rejects 2 chars (min length 3)", "aa", true},
+        \\		{"rejects 51 chars (max length 50)", strings.Repeat("a", 51), true},
+        \\		{"accepts 3 chars", "aaa", false},
+        \\		{"accepts 50 chars", strings.Repeat("a", 50), false},
+        \\	}
+        \\	for _, tt := range tests {
+        \\		t.Run(tt.name, func(t *testing.T) {
+        \\			err := ValidateUsername(tt.input)
+        \\			if (err != nil) != tt.wantErr {
+        \\				t.Errorf("ValidateUsername(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+        \\			}
+        \\		})
+        \\	}
+        \\}
+        \\
+    , text);
+
+    try testing.expectEqualStrings("users/users_ananke_test.go", try testPath(arena, "users/users.go"));
+    try testing.expect(try generate(arena, .{ .file_name = "users.go", .rules = .{ .package = "users" } }, default_tag) == null);
+}
+
+test "contract tests send each documented rejection" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const dto = go_rules.Struct{
+        .name = "CreateUserRequest",
+        .line = 3,
+        .tag_style = .validate,
+        .fields = &.{.{ .name = "Username", .go_type = "string", .line = 4, .checks = &.{.{ .kind = .max_len, .bound = 5 }} }},
+    };
+    const handler = go_http.Handler{
+        .name = "CreateUser",
+        .receiver_type = "Handler",
+        .pointer_receiver = true,
+        .line = 9,
+        .end_line = 24,
+        .method = "POST",
+        .method_status = 405,
+        .dto = "CreateUserRequest",
+        .decode_status = 400,
+        .validates = true,
+        .validation_status = 422,
+    };
+    const text = (try generate(arena, .{
+        .file_name = "api/users.go",
+        .rules = .{ .package = "api" },
+        .handlers = &.{handler},
+        .structs = &.{dto},
+        .routes = &.{.{ .path = "/users/{id}", .handler = "CreateUser", .line = 2 }},
+    }, default_tag)).?;
+
+    for ([_][]const u8{
+        "func TestCreateUserContract(t *testing.T) {",
+        "req := httptest.NewRequest(method, \"/users/1\", strings.NewReader(body))",
+        "(&Handler{}).CreateUser(rec, req)",
+        "if code := serve(http.MethodDelete, \"\"); code != http.StatusMethodNotAllowed {",
+        "if code := serve(http.MethodPost, \"{\"); code != http.StatusBadRequest {",
+        "{\"Username rejects 6 chars (max length 5)\", func(v *CreateUserRequest) { v.Username = \"aaaaaa\" }},",
+        "if code := serve(http.MethodPost, string(body)); code != http.StatusUnprocessableEntity {",
+    }) |line| {
+        try testing.expect(std.mem.indexOf(u8, text, line) != null);
+    }
+}
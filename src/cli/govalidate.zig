@@ -0,0 +1,621 @@
+// Go validator generation
+// Turns the typed rules `go_rules` recovers from a package's structs into
+// code enforcing them at runtime: a CheckConstraints method per struct that
+// reports every field breaking a rule, and a ValidateJSON middleware that
+// decodes a request body into a struct and answers 422 with the broken rules
+// before the handler runs. Each net/http handler of the package also gets a
+// middleware enforcing the request contract `go_http` extracts from its code
+// (method, body DTO, and the status of each rejection), so the validation in
+// front of an endpoint is the one its handler was found to perform. Each
+// package gets one file, regenerated whole.
+const std = @import("std");
+const go_rules = @import("cli_go_rules");
+const go_http = @import("cli_go_http");
+
+/// Name of the generated file in each package directory
+pub const file_name = "ananke_validators.go";
+/// Start of the first line of every generated file, used to tell our files
+/// from hand-written ones before overwriting or removing them
+pub const generated_marker = "// Code generated by ananke gen-validators";
+
+pub fn outputPath(allocator: std.mem.Allocator, dir: []const u8) ![]u8 {
+    return std.fs.path.join(allocator, &.{ dir, file_name });
+}
+
+/// Structs and handlers of one source file of the package
+pub const Source = struct {
+    path: []const u8,
+    structs: []const go_rules.Struct,
+    handlers: []const go_http.Handler = &.{},
+};
+
+pub const Options = struct {
+    /// Emit the ValidateJSON middleware
+    middleware: bool = true,
+    /// Emit a middleware per handler enforcing its request contract; needs
+    /// `middleware`
+    endpoints: bool = true,
+    /// Routes of the whole tree, which give the endpoints their paths
+    routes: []const go_http.Route = &.{},
+};
+
+/// Packages the generated checks use
+pub const Imports = struct {
+    mail: bool = false,
+    regexp: bool = false,
+    strings: bool = false,
+    utf8: bool = false,
+};
+
+pub const State = struct {
+    /// Struct type of the `{field, message}` pairs appended to `errs`
+    violation_type: []const u8 = "FieldViolation",
+    /// Start of the names of regexp variables, which must not clash with
+    /// other generated files of the package
+    var_prefix: []const u8 = "",
+    imports: Imports = .{},
+    /// Package-level regexp declarations of pattern checks
+    vars: std.ArrayList(u8) = .{},
+};
+
+fn writeGoString(writer: anytype, text: []const u8) !void {
+    try writer.writeByte('"');
+    for (text) |ch| {
+        switch (ch) {
+            '"' => try writer.writeAll("\\\""),
+            '\\' => try writer.writeAll("\\\\"),
+            '\n' => try writer.writeAll("\\n"),
+            '\t' => try writer.writeAll("\\t"),
+            else => try writer.writeByte(ch),
+        }
+    }
+    try writer.writeByte('"');
+}
+
+/// Name the field has in request bodies
+fn fieldKey(field: go_rules.Field) []const u8 {
+    if (field.json_name) |name| {
+        if (name.len > 0 and !std.mem.eql(u8, name, "-")) return name;
+    }
+    return field.name;
+}
+
+fn isCollection(go_type: []const u8) bool {
+    return std.mem.startsWith(u8, go_type, "[]") or std.mem.startsWith(u8, go_type, "map[");
+}
+
+fn isNilable(go_type: []const u8) bool {
+    return std.mem.startsWith(u8, go_type, "*") or std.mem.eql(u8, go_type, "any") or
+        std.mem.eql(u8, go_type, "interface{}") or std.mem.eql(u8, go_type, "error");
+}
+
+/// Comparison of the field with its zero value (`op` is "==" or "!="), or
+/// null for types without a simple test
+fn zeroTest(arena: std.mem.Allocator, field: go_rules.Field, op: []const u8) !?[]const u8 {
+    return switch (field.class()) {
+        .string => try std.fmt.allocPrint(arena, "v.{s} {s} \"\"", .{ field.name, op }),
+        .integer, .float => try std.fmt.allocPrint(arena, "v.{s} {s} 0", .{ field.name, op }),
+        .other => if (isCollection(field.go_type))
+            try std.fmt.allocPrint(arena, "len(v.{s}) {s} 0", .{ field.name, op })
+        else if (isNilable(field.go_type))
+            try std.fmt.allocPrint(arena, "v.{s} {s} nil", .{ field.name, op })
+        else
+            null,
+    };
+}
+
+fn writeViolation(writer: anytype, violation_type: []const u8, indent: []const u8, cond: []const u8, key: []const u8, message: []const u8) !void {
+    try writer.print("{s}if {s} {{\n{s}\terrs = append(errs, {s}{{", .{ indent, cond, indent, violation_type });
+    try writeGoString(writer, key);
+    try writer.writeAll(", ");
+    try writeGoString(writer, message);
+    try writer.print("}})\n{s}}}\n", .{indent});
+}
+
+/// One rule of a field other than required; nothing when the field's type
+/// cannot hold it
+fn writeCheck(
+    arena: std.mem.Allocator,
+    writer: anytype,
+    indent: []const u8,
+    s: go_rules.Struct,
+    field: go_rules.Field,
+    check: go_rules.Check,
+    state: *State,
+) !void {
+    const key = fieldKey(field);
+    const class = field.class();
+    const subject = try std.fmt.allocPrint(arena, "v.{s}", .{field.name});
+    switch (check.kind) {
+        .required => return,
+        .min_len, .max_len => {
+            if (class != .string) return;
+            state.imports.utf8 = true;
+            const below = check.kind == .min_len;
+            try writeViolation(
+                writer,
+                state.violation_type,
+                indent,
+                try std.fmt.allocPrint(arena, "utf8.RuneCountInString({s}) {s} {d}", .{ subject, if (below) "<" else ">", check.bound }),
+                key,
+                try std.fmt.allocPrint(arena, "must be at {s} {d} characters", .{ if (below) "least" else "most", check.bound }),
+            );
+        },
+        .min, .max => {
+            const below = check.kind == .min;
+            const op = if (below) "<" else ">";
+            const extent = if (below) "least" else "most";
+            if (class == .integer or class == .float) {
+                try writeViolation(
+                    writer,
+                    state.violation_type,
+                    indent,
+                    try std.fmt.allocPrint(arena, "{s} {s} {d}", .{ subject, op, check.bound }),
+                    key,
+                    try std.fmt.allocPrint(arena, "must be at {s} {d}", .{ extent, check.bound }),
+                );
+            } else if (isCollection(field.go_type)) {
+                try writeViolation(
+                    writer,
+                    state.violation_type,
+                    indent,
+                    try std.fmt.allocPrint(arena, "len({s}) {s} {d}", .{ subject, op, check.bound }),
+                    key,
+                    try std.fmt.allocPrint(arena, "must have at {s} {d} items", .{ extent, check.bound }),
+                );
+            }
+        },
+        .pattern => {
+            if (class != .string) return;
+            state.imports.regexp = true;
+            const var_name = if (state.var_prefix.len == 0)
+                try std.fmt.allocPrint(arena, "{c}{s}{s}Pattern", .{ std.ascii.toLower(s.name[0]), s.name[1..], field.name })
+            else
+                try std.fmt.allocPrint(arena, "{s}{s}{s}Pattern", .{ state.var_prefix, s.name, field.name });
+            const vars = state.vars.writer(arena);
+            try vars.print("var {s} = regexp.MustCompile(", .{var_name});
+            if (std.mem.indexOfScalar(u8, check.text, '`') == null) {
+                try vars.print("`{s}`", .{check.text});
+            } else {
+                try writeGoString(vars, check.text);
+            }
+            try vars.writeAll(")\n");
+            try writeViolation(
+                writer,
+                state.violation_type,
+                indent,
+                try std.fmt.allocPrint(arena, "!{s}.MatchString({s})", .{ var_name, subject }),
+                key,
+                try std.fmt.allocPrint(arena, "must match {s}", .{check.text}),
+            );
+        },
+        .email => {
+            if (class != .string) return;
+            state.imports.mail = true;
+            try writeViolation(
+                writer,
+                state.violation_type,
+                indent,
+                try std.fmt.allocPrint(arena, "addr, err := mail.ParseAddress({s}); err != nil || addr.Address != {s}", .{ subject, subject }),
+                key,
+                "must be an email address",
+            );
+        },
+        .one_of => {
+            if (class == .other) return;
+            try writer.print("{s}switch {s} {{\n{s}case ", .{ indent, subject, indent });
+            var options = std.mem.tokenizeScalar(u8, check.text, ' ');
+            var first = true;
+            while (options.next()) |option| {
+                if (!first) try writer.writeAll(", ");
+                first = false;
+                if (class == .string) try writeGoString(writer, option) else try writer.writeAll(option);
+            }
+            try writer.print(":\n{s}default:\n{s}\terrs = append(errs, {s}{{", .{ indent, indent, state.violation_type });
+            try writeGoString(writer, key);
+            try writer.writeAll(", ");
+            try writeGoString(writer, try std.fmt.allocPrint(arena, "must be one of {s}", .{check.text}));
+            try writer.print("}})\n{s}}}\n", .{indent});
+        },
+        .contains_any => {
+            if (class != .string) return;
+            state.imports.strings = true;
+            var literal = std.ArrayList(u8){};
+            try writeGoString(literal.writer(arena), check.text);
+            try writeViolation(
+                writer,
+                state.violation_type,
+                indent,
+                try std.fmt.allocPrint(arena, "!strings.ContainsAny({s}, {s})", .{ subject, literal.items }),
+                key,
+                try std.fmt.allocPrint(arena, "must contain one of {s}", .{check.text}),
+            );
+        },
+    }
+}
+
+/// Statements appending to `errs` a `state.violation_type` for each rule of
+/// `s` that `v` breaks; false when none of its rules can be checked
+pub fn writeChecks(arena: std.mem.Allocator, writer: anytype, s: go_rules.Struct, state: *State) !bool {
+    var written = false;
+    for (s.fields) |field| {
+        const key = fieldKey(field);
+        var required = false;
+        for (field.checks) |check| {
+            if (check.kind == .required) required = true;
+        }
+        if (required and !field.optional) {
+            if (try zeroTest(arena, field, "==")) |cond| {
+                try writeViolation(writer, state.violation_type, "\t", cond, key, "is required");
+                written = true;
+            }
+        }
+
+        // Rules of an omitempty field hold only when it is set
+        var rules = std.ArrayList(u8){};
+        const rules_out = rules.writer(arena);
+        const guard = if (field.optional) try zeroTest(arena, field, "!=") else null;
+        const indent = if (guard != null) "\t\t" else "\t";
+        for (field.checks) |check| {
+            try writeCheck(arena, rules_out, indent, s, field, check, state);
+        }
+        if (rules.items.len == 0) continue;
+        if (guard) |cond| {
+            try writer.print("\tif {s} {{\n{s}\t}}\n", .{ cond, rules.items });
+        } else {
+            try writer.writeAll(rules.items);
+        }
+        written = true;
+    }
+    return written;
+}
+
+/// CheckConstraints for `s`, or false when none of its rules can be checked
+fn writeStruct(arena: std.mem.Allocator, writer: anytype, path: []const u8, s: go_rules.Struct, state: *State) !bool {
+    var body = std.ArrayList(u8){};
+    if (!try writeChecks(arena, body.writer(arena), s, state)) return false;
+
+    try writer.print("\n// CheckConstraints reports the rules of {s} ({s}:{d}) that v breaks,\n", .{ s.name, path, s.line });
+    try writer.writeAll("// as a *ConstraintError.\n");
+    try writer.print("func (v *{s}) CheckConstraints() error {{\n\tvar errs []FieldViolation\n", .{s.name});
+    try writer.writeAll(body.items);
+    try writer.writeAll("\tif len(errs) > 0 {\n\t\treturn &ConstraintError{Fields: errs}\n\t}\n\treturn nil\n}\n");
+    return true;
+}
+
+const error_types =
+    \\
+    \\// FieldViolation is a rule a field breaks.
+    \\type FieldViolation struct {
+    \\	Field   string `json:"field"`
+    \\	Message string `json:"message"`
+    \\}
+    \\
+    \\// ConstraintError lists the rules a value breaks.
+    \\type ConstraintError struct {
+    \\	Fields []FieldViolation `json:"errors"`
+    \\}
+    \\
+    \\func (e *ConstraintError) Error() string {
+    \\	msgs := make([]string, len(e.Fields))
+    \\	for i, f := range e.Fields {
+    \\		msgs[i] = f.Field + " " + f.Message
+    \\	}
+    \\	return strings.Join(msgs, "; ")
+    \\}
+    \\
+;
+
+const middleware =
+    \\
+    \\// ValidateJSON decodes each request body into a T before calling next and
+    \\// answers 400 when the body is malformed, or 422 with the broken rules as
+    \\// JSON when T's constraints do not hold. next can read the body again:
+    \\//
+    \\//	mux.Handle("POST /users", ValidateJSON[CreateUserRequest](http.HandlerFunc(h.CreateUser)))
+    \\func ValidateJSON[T any, P interface {
+    \\	*T
+    \\	CheckConstraints() error
+    \\}](next http.Handler) http.Handler {
+    \\	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    \\		if checkJSONBody[T, P](w, r, http.StatusBadRequest, http.StatusUnprocessableEntity) {
+    \\			next.ServeHTTP(w, r)
+    \\		}
+    \\	})
+    \\}
+    \\
+    \\// checkJSONBody decodes the body of r into a T, leaving it readable again,
+    \\// and answers malformed when it is not JSON or invalid with the broken rules
+    \\// when T's constraints do not hold. It reports whether the handler may run.
+    \\func checkJSONBody[T any, P interface {
+    \\	*T
+    \\	CheckConstraints() error
+    \\}](w http.ResponseWriter, r *http.Request, malformed, invalid int) bool {
+    \\	body, err := io.ReadAll(r.Body)
+    \\	if err != nil {
+    \\		http.Error(w, "cannot read request body", malformed)
+    \\		return false
+    \\	}
+    \\	r.Body = io.NopCloser(bytes.NewReader(body))
+    \\	var v T
+    \\	if err := json.Unmarshal(body, &v); err != nil {
+    \\		http.Error(w, "malformed JSON body", malformed)
+    \\		return false
+    \\	}
+    \\	if err := P(&v).CheckConstraints(); err != nil {
+    \\		w.Header().Set("Content-Type", "application/json")
+    \\		w.WriteHeader(invalid)
+    \\		json.NewEncoder(w).Encode(err)
+    \\		return false
+    \\	}
+    \\	return true
+    \\}
+    \\
+;
+
+/// `http.StatusBadRequest`, or the bare code when net/http has no constant
+fn writeStatus(writer: anytype, code: u16) !void {
+    if (go_http.statusName(code)) |name| {
+        try writer.print("http.{s}", .{name});
+    } else {
+        try writer.print("{d}", .{code});
+    }
+}
+
+/// Name of the middleware of `handler`, qualified by its receiver type when
+/// another handler of the package has the same name
+fn endpointName(arena: std.mem.Allocator, sources: []const Source, handler: go_http.Handler) ![]const u8 {
+    var same: usize = 0;
+    for (sources) |source| {
+        for (source.handlers) |other| {
+            if (std.mem.eql(u8, other.name, handler.name)) same += 1;
+        }
+    }
+    if (same > 1) {
+        if (handler.receiver_type) |receiver| return std.fmt.allocPrint(arena, "Validate{s}{s}", .{ receiver, handler.name });
+    }
+    return std.fmt.allocPrint(arena, "Validate{s}", .{handler.name});
+}
+
+/// Middleware enforcing what `handler` checks before doing any work, or
+/// false when it checks nothing the generated code can repeat. `checked`
+/// names the structs of the package with a CheckConstraints method.
+fn writeEndpoint(
+    arena: std.mem.Allocator,
+    writer: anytype,
+    name: []const u8,
+    path: []const u8,
+    handler: go_http.Handler,
+    route: ?go_http.Route,
+    checked: []const []const u8,
+) !bool {
+    const method = handler.method orelse if (route) |rt| rt.method else null;
+    var dto: ?[]const u8 = null;
+    if (handler.dto) |t| {
+        for (checked) |s| {
+            if (std.mem.eql(u8, s, t)) dto = t;
+        }
+    }
+    if (method == null and dto == null) return false;
+
+    var contract = std.ArrayList([]const u8){};
+    if (method) |m| {
+        try contract.append(arena, try std.fmt.allocPrint(arena, "method {s} (else {d})", .{ m, handler.method_status orelse 405 }));
+    }
+    if (dto) |t| {
+        try contract.append(arena, try std.fmt.allocPrint(arena, "a JSON {s} body (else {d}) whose constraints hold (else {d})", .{ t, handler.decode_status orelse 400, handler.validation_status orelse 422 }));
+    }
+    const owner = if (handler.receiver_type) |receiver| try std.fmt.allocPrint(arena, "{s}.{s}", .{ receiver, handler.name }) else handler.name;
+    try writer.print("\n// {s} enforces the request contract of {s} ({s}:{d})", .{ name, owner, path, handler.line });
+    if (route) |rt| {
+        if (rt.method) |m| try writer.print(", served at {s} {s}", .{ m, rt.path }) else try writer.print(", served at {s}", .{rt.path});
+    }
+    try writer.writeAll(":\n// ");
+    for (contract.items, 0..) |part, i| {
+        if (i > 0) try writer.writeAll(", and ");
+        try writer.writeAll(part);
+    }
+    try writer.writeAll(".\n");
+    try writer.print("func {s}(next http.Handler) http.Handler {{\n\treturn http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {{\n", .{name});
+    if (method) |m| {
+        try writer.print("\t\tif r.Method != http.Method{c}{s} {{\n\t\t\thttp.Error(w, \"method not allowed\", ", .{ m[0], try std.ascii.allocLowerString(arena, m[1..]) });
+        try writeStatus(writer, handler.method_status orelse 405);
+        try writer.writeAll(")\n\t\t\treturn\n\t\t}\n");
+    }
+    if (dto) |t| {
+        try writer.print("\t\tif !checkJSONBody[{s}](w, r, ", .{t});
+        try writeStatus(writer, handler.decode_status orelse 400);
+        try writer.writeAll(", ");
+        try writeStatus(writer, handler.validation_status orelse 422);
+        try writer.writeAll(") {\n\t\t\treturn\n\t\t}\n");
+    }
+    try writer.writeAll("\t\tnext.ServeHTTP(w, r)\n\t})\n}\n");
+    return true;
+}
+
+/// The validator file of a package, or null when none of its structs has a
+/// rule that can be checked
+pub fn generate(allocator: std.mem.Allocator, package: []const u8, sources: []const Source, options: Options) !?[]u8 {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var body = std.ArrayList(u8){};
+    const writer = body.writer(arena);
+    var state = State{};
+    var checked = std.ArrayList([]const u8){};
+    for (sources) |source| {
+        for (source.structs) |s| {
+            if (!s.hasChecks()) continue;
+            if (try writeStruct(arena, writer, source.path, s, &state)) try checked.append(arena, s.name);
+        }
+    }
+
+    // Endpoints, and the routes to put them in front of
+    var endpoints = std.ArrayList(u8){};
+    var table = std.ArrayList(u8){};
+    var keys = std.StringHashMap(void).init(arena);
+    if (options.middleware and options.endpoints) {
+        for (sources) |source| {
+            for (source.handlers) |handler| {
+                const name = try endpointName(arena, sources, handler);
+                const route = go_http.routeOf(options.routes, handler);
+                if (!try writeEndpoint(arena, endpoints.writer(arena), name, source.path, handler, route, checked.items)) continue;
+                const rt = route orelse continue;
+                const key = if (rt.method) |m| try std.fmt.allocPrint(arena, "{s} {s}", .{ m, rt.path }) else rt.path;
+                if ((try keys.getOrPut(key)).found_existing) continue;
+                try table.writer(arena).print("\t\"{s}\": {s},\n", .{ key, name });
+            }
+        }
+    }
+    if (checked.items.len == 0 and endpoints.items.len == 0) return null;
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const out = list.writer(allocator);
+    try out.print("{s}; DO NOT EDIT.\n\npackage {s}\n\nimport (\n", .{ generated_marker, package });
+    if (options.middleware) try out.writeAll("\t\"bytes\"\n\t\"encoding/json\"\n\t\"io\"\n\t\"net/http\"\n");
+    if (state.imports.mail) try out.writeAll("\t\"net/mail\"\n");
+    if (state.imports.regexp) try out.writeAll("\t\"regexp\"\n");
+    try out.writeAll("\t\"strings\"\n");
+    if (state.imports.utf8) try out.writeAll("\t\"unicode/utf8\"\n");
+    try out.writeAll(")\n");
+    if (state.vars.items.len > 0) {
+        try out.writeAll("\n");
+        try out.writeAll(state.vars.items);
+    }
+    try out.writeAll(error_types);
+    if (options.middleware) try out.writeAll(middleware);
+    try out.writeAll(body.items);
+    try out.writeAll(endpoints.items);
+    if (table.items.len > 0) {
+        try out.writeAll(
+            \\
+            \\// Endpoints maps the route patterns of the package's handlers to the
+            \\// middleware enforcing their request contracts:
+            \\//
+            \\//	mux.Handle("POST /users", Endpoints["POST /users"](http.HandlerFunc(h.CreateUser)))
+            \\var Endpoints = map[string]func(http.Handler) http.Handler{
+            \\
+        );
+        try out.writeAll(table.items);
+        try out.writeAll("}\n");
+    }
+    return try list.toOwnedSlice(allocator);
+}
+
+test "generate CheckConstraints from struct rules" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const dto = go_rules.Struct{
+        .name = "CreateUserRequest",
+        .line = 7,
+        .tag_style = .validate,
+        .fields = &.{
+            .{ .name = "Username", .go_type = "string", .json_name = "username", .line = 8, .checks = &.{
+                .{ .kind = .required },
+                .{ .kind = .min_len, .bound = 3 },
+            } },
+            .{ .name = "Password", .go_type = "string", .json_name = "password", .line = 9, .checks = &.{
+                .{ .kind = .contains_any, .text = "0123456789" },
+            } },
+            .{ .name = "Age", .go_type = "int", .json_name = "age", .line = 10, .optional = true, .checks = &.{
+                .{ .kind = .min, .bound = 13 },
+            } },
+            .{ .name = "Role", .go_type = "string", .line = 11, .checks = &.{
+                .{ .kind = .one_of, .text = "admin member" },
+            } },
+        },
+    };
+    const text = (try generate(arena, "api", &.{.{ .path = "api/users.go", .structs = &.{dto} }}, .{ .middleware = false })).?;
+
+    try testing.expect(std.mem.startsWith(u8, text, "// Code generated by ananke gen-validators; DO NOT EDIT.\n\npackage api\n"));
+    try testing.expect(std.mem.indexOf(u8, text, "\"net/http\"") == null);
+    try testing.expect(std.mem.indexOf(u8, text, "func ValidateJSON") == null);
+    for ([_][]const u8{
+        "\t\"unicode/utf8\"\n",
+        "// CheckConstraints reports the rules of CreateUserRequest (api/users.go:7) that v breaks,\n",
+        "\tif v.Username == \"\" {\n\t\terrs = append(errs, FieldViolation{\"username\", \"is required\"})\n\t}\n",
+        "\tif utf8.RuneCountInString(v.Username) < 3 {\n",
+        "\tif !strings.ContainsAny(v.Password, \"0123456789\") {\n",
+        "\tif v.Age != 0 {\n\t\tif v.Age < 13 {\n\t\t\terrs = append(errs, FieldViolation{\"age\", \"must be at least 13\"})\n\t\t}\n\t}\n",
+        "\tswitch v.Role {\n\tcase \"admin\", \"member\":\n\tdefault:\n\t\terrs = append(errs, FieldViolation{\"Role\", \"must be one of admin member\"})\n\t}\n",
+    }) |line| {
+        try testing.expect(std.mem.indexOf(u8, text, line) != null);
+    }
+
+    const with_middleware = (try generate(arena, "api", &.{.{ .path = "api/users.go", .structs = &.{dto} }}, .{})).?;
+    try testing.expect(std.mem.indexOf(u8, with_middleware, "func ValidateJSON[T any, P interface {") != null);
+
+    const handler = go_http.Handler{
+        .name = "CreateUser",
+        .receiver_type = "Handler",
+        .line = 20,
+        .end_line = 34,
+        .method = "POST",
+        .method_status = 405,
+        .dto = "CreateUserRequest",
+        .decode_status = 400,
+        .validates = true,
+        .validation_status = 400,
+    };
+    const with_endpoints = (try generate(arena, "api", &.{.{ .path = "api/users.go", .structs = &.{dto}, .handlers = &.{handler} }}, .{
+        .routes = &.{.{ .method = "POST", .path = "/users", .handler = "CreateUser", .line = 5 }},
+    })).?;
+    for ([_][]const u8{
+        "// ValidateCreateUser enforces the request contract of Handler.CreateUser (api/users.go:20), served at POST /users:\n",
+        "// method POST (else 405), and a JSON CreateUserRequest body (else 400) whose constraints hold (else 400).\n",
+        "\t\tif r.Method != http.MethodPost {\n\t\t\thttp.Error(w, \"method not allowed\", http.StatusMethodNotAllowed)\n",
+        "\t\tif !checkJSONBody[CreateUserRequest](w, r, http.StatusBadRequest, http.StatusBadRequest) {\n",
+        "\t\"POST /users\": ValidateCreateUser,\n",
+    }) |line| {
+        try testing.expect(std.mem.indexOf(u8, with_endpoints, line) != null);
+    }
+
+    const plain = go_rules.Struct{ .name = "Config", .line = 1, .fields = &.{.{ .name = "Debug", .go_type = "bool", .line = 2 }} };
+    try testing.expect(try generate(arena, "api", &.{.{ .path = "api/config.go", .structs = &.{plain} }}, .{}) == null);
+}
+
+test "endpoint middlewares enforce only what their handlers check" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    // Two handlers of one name, a method check with a status net/http has no
+    // constant for, and handlers checking nothing that can be repeated
+    const handlers = [_]go_http.Handler{
+        .{ .name = "Get", .receiver_type = "UserHandler", .line = 10, .end_line = 20, .method = "GET", .method_status = 499 },
+        .{ .name = "Get", .receiver_type = "OrderHandler", .line = 30, .end_line = 40, .method = "GET" },
+        .{ .name = "Upload", .line = 50, .end_line = 60, .dto = "UploadRequest", .decode_status = 400 },
+        .{ .name = "Health", .line = 70, .end_line = 72 },
+    };
+    const sources = [_]Source{.{ .path = "api/handlers.go", .structs = &.{}, .handlers = &handlers }};
+    const routes = [_]go_http.Route{
+        .{ .path = "/users", .handler = "Get", .line = 3 },
+        .{ .method = "GET", .path = "/users", .handler = "Get", .line = 4 },
+    };
+    const text = (try generate(arena, "api", &sources, .{ .routes = &routes })).?;
+    for ([_][]const u8{
+        "// ValidateUserHandlerGet enforces the request contract of UserHandler.Get (api/handlers.go:10), served at /users:\n",
+        "// ValidateOrderHandlerGet enforces the request contract of OrderHandler.Get (api/handlers.go:30), served at /users:\n",
+        "\t\t\thttp.Error(w, \"method not allowed\", 499)\n",
+        "\t\t\thttp.Error(w, \"method not allowed\", http.StatusMethodNotAllowed)\n",
+        "\t\"/users\": ValidateUserHandlerGet,\n",
+    }) |line| {
+        try testing.expect(std.mem.indexOf(u8, text, line) != null);
+    }
+    // Method checks only; UploadRequest has no CheckConstraints to call
+    try testing.expect(std.mem.indexOf(u8, text, "\t\tif !checkJSONBody[") == null);
+    try testing.expect(std.mem.indexOf(u8, text, "ValidateUpload") == null);
+    try testing.expect(std.mem.indexOf(u8, text, "ValidateHealth") == null);
+    // Both handlers resolve to the first route, which keys the map once
+    try testing.expectEqual(@as(usize, 1), std.mem.count(u8, text, "\t\"/users\": "));
+
+    // Without endpoints a package with no struct rules has nothing to generate
+    try testing.expect(try generate(arena, "api", &sources, .{ .endpoints = false, .routes = &routes }) == null);
+    try testing.expect(try generate(arena, "api", &sources, .{ .middleware = false, .routes = &routes }) == null);
+}
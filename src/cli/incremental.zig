@@ -0,0 +1,264 @@
+// Git-driven incremental extraction
+// After an `extract --incremental` run, the cache directory keeps a manifest
+// of every file the run covered: the commit HEAD pointed at, and for each
+// file that matched that commit, the cache entry holding its constraints.
+// The next run asks git what changed since that commit instead of walking
+// and reading the tree. Unchanged files are merged straight from their cache
+// entries; modified, added, and untracked files are re-extracted; a file
+// renamed without edits keeps its entry under the new path. Constraint ids
+// are computed from a constraint's content, never its path, so they survive
+// the rename.
+//
+// Files that differed from the commit when the manifest was written (dirty
+// or untracked), or that were not cached (oversized, skipped), are listed
+// as volatile and re-read on every run: git can say a file now matches the
+// commit, but not that it matches what the previous run saw.
+//
+//   ananke-incremental 1
+//   commit <sha>
+//   rules <language> <digest>                                rule set per language
+//   e <key> <lines> <sha256|-> <language> <path>            cached file
+//   v <path>                                                 volatile file
+const std = @import("std");
+const cache_store = @import("cli_cache_store");
+const git = @import("cli_git");
+const plan_mod = @import("cli_plan");
+
+const magic = "ananke-incremental 1";
+const max_manifest_bytes = 256 * 1024 * 1024;
+
+pub const Key = [32]u8;
+
+/// One file whose constraints are in the cache
+pub const Entry = struct {
+    key: cache_store.Key,
+    path: []const u8,
+    language: []const u8,
+    line_count: usize,
+    content_hash: ?[]const u8 = null,
+};
+
+pub const RuleSet = struct {
+    language: []const u8,
+    digest: [16]u8,
+};
+
+pub const Manifest = struct {
+    commit: []const u8,
+    rules: []const RuleSet = &.{},
+    entries: []const Entry = &.{},
+    volatile_paths: []const []const u8 = &.{},
+};
+
+/// Identify one incremental configuration: the discovery key of the root
+/// and options (see warm_state.discoveryKey) plus the extraction settings
+/// that change what a file's cache entry holds
+pub fn manifestKey(discovery_key: []const u8, settings: []const u8) Key {
+    var hasher = std.crypto.hash.sha2.Sha256.init(.{});
+    hasher.update(discovery_key);
+    hasher.update(&.{0});
+    hasher.update(settings);
+    return std.fmt.bytesToHex(hasher.finalResult()[0..16].*, .lower);
+}
+
+fn manifestPath(buf: []u8, key: *const Key) []const u8 {
+    return std.fmt.bufPrint(buf, "{s}/{s}.state", .{ cache_store.incremental_dir, key }) catch unreachable;
+}
+
+/// The manifest saved under `key`, or null when there is none or it cannot
+/// be read. Strings live in `arena`.
+pub fn load(arena: std.mem.Allocator, cache_dir: std.fs.Dir, key: *const Key) ?Manifest {
+    var path_buf: [64]u8 = undefined;
+    const text = cache_dir.readFileAlloc(arena, manifestPath(&path_buf, key), max_manifest_bytes) catch return null;
+    return parse(arena, text) catch null;
+}
+
+fn parse(arena: std.mem.Allocator, text: []const u8) !Manifest {
+    var lines = std.mem.splitScalar(u8, text, '\n');
+    if (!std.mem.eql(u8, lines.next() orelse "", magic)) return error.InvalidManifest;
+
+    var manifest = Manifest{ .commit = "" };
+    var rules = std.ArrayList(RuleSet){};
+    var entries = std.ArrayList(Entry){};
+    var volatile_paths = std.ArrayList([]const u8){};
+    while (lines.next()) |line| {
+        if (line.len == 0) continue;
+        var fields = std.mem.splitScalar(u8, line, ' ');
+        const tag = fields.next().?;
+        if (std.mem.eql(u8, tag, "commit")) {
+            manifest.commit = fields.rest();
+        } else if (std.mem.eql(u8, tag, "rules")) {
+            const language = fields.next() orelse return error.InvalidManifest;
+            const digest = fields.rest();
+            if (digest.len != 16) return error.InvalidManifest;
+            try rules.append(arena, .{ .language = language, .digest = digest[0..16].* });
+        } else if (std.mem.eql(u8, tag, "e")) {
+            const key = fields.next() orelse return error.InvalidManifest;
+            if (key.len != @typeInfo(cache_store.Key).array.len) return error.InvalidManifest;
+            const line_count = try std.fmt.parseInt(usize, fields.next() orelse "", 10);
+            const hash = fields.next() orelse return error.InvalidManifest;
+            const language = fields.next() orelse return error.InvalidManifest;
+            try entries.append(arena, .{
+                .key = key[0..@typeInfo(cache_store.Key).array.len].*,
+                .line_count = line_count,
+                .content_hash = if (std.mem.eql(u8, hash, "-")) null else hash,
+                .language = language,
+                .path = fields.rest(),
+            });
+        } else if (std.mem.eql(u8, tag, "v")) {
+            try volatile_paths.append(arena, fields.rest());
+        } else {
+            return error.InvalidManifest;
+        }
+    }
+    if (manifest.commit.len == 0) return error.InvalidManifest;
+    manifest.rules = rules.items;
+    manifest.entries = entries.items;
+    manifest.volatile_paths = volatile_paths.items;
+    return manifest;
+}
+
+/// Save `manifest` under `key`. A path the line format cannot hold leaves
+/// the manifest unsaved, so the next run starts over.
+pub fn save(allocator: std.mem.Allocator, cache_dir: std.fs.Dir, key: *const Key, manifest: Manifest) !void {
+    var text = std.ArrayList(u8){};
+    defer text.deinit(allocator);
+    const writer = text.writer(allocator);
+    try writer.print("{s}\ncommit {s}\n", .{ magic, manifest.commit });
+    for (manifest.rules) |rules| {
+        try writer.print("rules {s} {s}\n", .{ rules.language, &rules.digest });
+    }
+    for (manifest.entries) |entry| {
+        if (std.mem.indexOfScalar(u8, entry.path, '\n') != null) return;
+        try writer.print("e {s} {d} {s} {s} {s}\n", .{
+            &entry.key,
+            entry.line_count,
+            entry.content_hash orelse "-",
+            entry.language,
+            entry.path,
+        });
+    }
+    for (manifest.volatile_paths) |path| {
+        if (std.mem.indexOfScalar(u8, path, '\n') != null) return;
+        try writer.print("v {s}\n", .{path});
+    }
+
+    try cache_dir.makePath(cache_store.incremental_dir);
+    var path_buf: [64]u8 = undefined;
+    try cache_store.writeAtomic(cache_dir, manifestPath(&path_buf, key), text.items);
+}
+
+/// What an incremental run reads and what it reuses
+pub const Plan = struct {
+    /// Files merged from the cache without being read, at their current paths
+    carried: std.ArrayList(Entry) = .{},
+    /// Files to discover and extract
+    extract: std.ArrayList([]const u8) = .{},
+};
+
+/// Split the files of `manifest` by what `changes` (from its commit to the
+/// working tree) did to them. Files in a language whose rules changed since
+/// the manifest are re-extracted, where the cache may still reuse them.
+pub fn plan(arena: std.mem.Allocator, manifest: Manifest, changes: []const git.Change) !Plan {
+    var result = Plan{};
+
+    var changed = std.StringHashMap(void).init(arena);
+    var entries = std.StringHashMap(usize).init(arena);
+    for (manifest.entries, 0..) |entry, i| try entries.put(normalize(entry.path), i);
+
+    for (changes) |change| {
+        try changed.put(normalize(change.path), {});
+        if (change.status == 'D') continue;
+        if (change.from) |from| {
+            // A rename moves the file away from its old path
+            if (change.status == 'R') try changed.put(normalize(from), {});
+            if (change.status == 'R' and change.similarity == 100) {
+                if (entries.get(normalize(from))) |i| {
+                    if (rulesCurrent(manifest, manifest.entries[i].language)) {
+                        var moved = manifest.entries[i];
+                        moved.path = change.path;
+                        try result.carried.append(arena, moved);
+                        continue;
+                    }
+                }
+            }
+        }
+        try result.extract.append(arena, change.path);
+    }
+
+    for (manifest.entries) |entry| {
+        if (changed.contains(normalize(entry.path))) continue;
+        if (rulesCurrent(manifest, entry.language)) {
+            try result.carried.append(arena, entry);
+        } else {
+            try result.extract.append(arena, entry.path);
+        }
+    }
+    for (manifest.volatile_paths) |path| {
+        if (!changed.contains(normalize(path))) try result.extract.append(arena, path);
+    }
+    return result;
+}
+
+/// Whether `language`'s rules are the ones the manifest was written under
+fn rulesCurrent(manifest: Manifest, language: []const u8) bool {
+    for (manifest.rules) |rules| {
+        if (!std.mem.eql(u8, rules.language, language)) continue;
+        return std.mem.eql(u8, &rules.digest, &plan_mod.rulesDigest(language));
+    }
+    return false;
+}
+
+/// Git and discovery spell the same path with and without "./"
+pub fn normalize(path: []const u8) []const u8 {
+    var rest = path;
+    while (std.mem.startsWith(u8, rest, "./")) rest = rest[2..];
+    return rest;
+}
+
+test "incremental plan carries unchanged and purely renamed files" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const key_a: cache_store.Key = @splat('a');
+    const key_b: cache_store.Key = @splat('b');
+    const key_c: cache_store.Key = @splat('c');
+    const manifest = Manifest{
+        .commit = "0123456789abcdef0123456789abcdef01234567",
+        .rules = &.{.{ .language = "go", .digest = plan_mod.rulesDigest("go") }},
+        .entries = &.{
+            .{ .key = key_a, .path = "./src/a.go", .language = "go", .line_count = 3 },
+            .{ .key = key_b, .path = "./src/b.go", .language = "go", .line_count = 9 },
+            .{ .key = key_c, .path = "./src/c.go", .language = "go", .line_count = 4 },
+        },
+        .volatile_paths = &.{"./src/wip.go"},
+    };
+
+    // Round trip through the manifest format
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+    const key = manifestKey("discovery", "settings");
+    try save(testing.allocator, tmp.dir, &key, manifest);
+    const loaded = load(arena, tmp.dir, &key).?;
+    try testing.expectEqualStrings(manifest.commit, loaded.commit);
+    try testing.expectEqual(@as(usize, 3), loaded.entries.len);
+    try testing.expectEqualStrings("./src/b.go", loaded.entries[1].path);
+
+    const changes = [_]git.Change{
+        .{ .status = 'M', .path = "src/a.go" },
+        .{ .status = 'R', .path = "src/d.go", .from = "src/b.go", .similarity = 100 },
+        .{ .status = '?', .path = "src/new.go" },
+    };
+    const result = try plan(arena, loaded, &changes);
+
+    try testing.expectEqual(@as(usize, 2), result.carried.items.len);
+    try testing.expectEqualStrings("src/d.go", result.carried.items[0].path);
+    try testing.expectEqualStrings(&key_b, &result.carried.items[0].key);
+    try testing.expectEqualStrings("./src/c.go", result.carried.items[1].path);
+    try testing.expectEqual(@as(usize, 3), result.extract.items.len);
+    try testing.expectEqualStrings("src/a.go", result.extract.items[0]);
+    try testing.expectEqualStrings("src/new.go", result.extract.items[1]);
+    try testing.expectEqualStrings("./src/wip.go", result.extract.items[2]);
+}
@@ -0,0 +1,390 @@
+// Issue tracker export
+// Files an issue in GitHub Issues or Jira for each critical (error severity)
+// constraint violation, so violations enter the triage workflow a team
+// already has. Issues are deduplicated by constraint ID: the ID is recorded in
+// each issue (a hidden marker in the GitHub body, an `ananke-<id>` label in
+// Jira), and a later run updates the existing issue, reopening a closed
+// GitHub issue, instead of filing another.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const json_api = @import("cli_json_api");
+
+pub const Tracker = enum {
+    github,
+    jira,
+
+    pub fn fromString(text: []const u8) ?Tracker {
+        return std.meta.stringToEnum(Tracker, text);
+    }
+};
+
+/// Label on every exported issue; GitHub lookups list issues by it
+pub const label = "ananke";
+
+/// Issues listed per page when looking up existing GitHub issues
+const page_size = 100;
+/// Pages of existing GitHub issues read at most
+const max_pages = 20;
+
+/// A constraint the validated code violates
+pub const Violation = struct {
+    constraint: constraint.Constraint,
+    /// Validated file
+    file: []const u8,
+
+    /// The constraint's ID, or its content ID when it has none, so the same
+    /// constraint maps to the same issue across runs
+    pub fn id(self: Violation) constraint.ConstraintID {
+        return if (self.constraint.id != 0) self.constraint.id else self.constraint.computeId();
+    }
+};
+
+/// Where and how issues are filed
+pub const Target = struct {
+    tracker: Tracker,
+    /// GitHub API root or Jira site URL
+    api_url: []const u8,
+    /// GitHub "owner/name"
+    repository: ?[]const u8 = null,
+    /// Jira project key
+    project: ?[]const u8 = null,
+    /// Jira issue type
+    issue_type: []const u8 = "Bug",
+    /// Extra labels for created issues
+    labels: []const []const u8 = &.{},
+    /// Value of the Authorization header
+    authorization: []const u8,
+};
+
+pub const Outcome = struct {
+    created: usize = 0,
+    updated: usize = 0,
+    failed: usize = 0,
+};
+
+/// Hidden line in a GitHub issue body that records the constraint ID
+pub fn writeMarker(writer: anytype, id: constraint.ConstraintID) !void {
+    try writer.print("<!-- ananke-constraint-id: {d} -->", .{id});
+}
+
+/// Constraint ID recorded in a GitHub issue body, if any
+pub fn parseMarker(body: []const u8) ?constraint.ConstraintID {
+    const prefix = "<!-- ananke-constraint-id: ";
+    const start = (std.mem.indexOf(u8, body, prefix) orelse return null) + prefix.len;
+    const end = std.mem.indexOfPos(u8, body, start, " -->") orelse return null;
+    return std.fmt.parseInt(constraint.ConstraintID, body[start..end], 10) catch null;
+}
+
+fn writeTitle(writer: anytype, violation: Violation) !void {
+    try writer.print("Constraint violation: {s}", .{violation.constraint.name});
+}
+
+/// Issue text: what the constraint requires and where it was violated.
+/// GitHub bodies are Markdown; Jira descriptions are plain text.
+fn writeBody(writer: anytype, tracker: Tracker, violation: Violation) !void {
+    const c = violation.constraint;
+    const code = if (tracker == .github) "`" else "";
+    try writer.print("Constraint {s}{s}{s} ({s}, {s}) is violated in {s}{s}", .{
+        code,
+        c.name,
+        code,
+        @tagName(c.kind),
+        @tagName(c.severity),
+        code,
+        c.origin_file orelse violation.file,
+    });
+    if (c.origin_line) |line| try writer.print(":{d}", .{line});
+    try writer.print("{s}.\n\n{s}\n\nConfidence: {d:.2}\n", .{ code, c.description, c.confidence });
+    if (tracker == .github) {
+        try writer.writeAll("\n");
+        try writeMarker(writer, violation.id());
+        try writer.writeAll("\n");
+    } else {
+        try writer.print("\nAnanke constraint ID: {d}\n", .{violation.id()});
+    }
+}
+
+/// Body of a GitHub create (POST) or update (PATCH) issue request. Updates
+/// reopen the issue and leave its labels alone.
+pub fn formatGithubIssue(allocator: std.mem.Allocator, violation: Violation, labels: []const []const u8, update: bool) ![]u8 {
+    var text = std.ArrayList(u8){};
+    defer text.deinit(allocator);
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    try writer.writeAll("{\"title\": \"");
+    try writeTitle(text.writer(allocator), violation);
+    try output.writeJsonEscaped(writer, text.items);
+    try writer.writeAll("\", \"body\": \"");
+    text.clearRetainingCapacity();
+    try writeBody(text.writer(allocator), .github, violation);
+    try output.writeJsonEscaped(writer, text.items);
+    if (update) {
+        try writer.writeAll("\", \"state\": \"open\"}\n");
+    } else {
+        try writer.print("\", \"labels\": [\"{s}\", \"{s}\"", .{ label, @tagName(violation.constraint.kind) });
+        try writeLabels(writer, labels);
+        try writer.writeAll("]}\n");
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+/// Body of a Jira create (POST) or update (PUT) issue request
+pub fn formatJiraIssue(allocator: std.mem.Allocator, violation: Violation, target: Target, update: bool) ![]u8 {
+    var text = std.ArrayList(u8){};
+    defer text.deinit(allocator);
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    try writer.writeAll("{\"fields\": {");
+    if (!update) {
+        try writer.writeAll("\"project\": {\"key\": \"");
+        try output.writeJsonEscaped(writer, target.project orelse "");
+        try writer.writeAll("\"}, \"issuetype\": {\"name\": \"");
+        try output.writeJsonEscaped(writer, target.issue_type);
+        try writer.print("\"}}, \"labels\": [\"{s}\", \"{s}-{d}\"", .{ label, label, violation.id() });
+        try writeLabels(writer, target.labels);
+        try writer.writeAll("], ");
+    }
+    try writer.writeAll("\"summary\": \"");
+    try writeTitle(text.writer(allocator), violation);
+    try output.writeJsonEscaped(writer, text.items);
+    try writer.writeAll("\", \"description\": \"");
+    text.clearRetainingCapacity();
+    try writeBody(text.writer(allocator), .jira, violation);
+    try output.writeJsonEscaped(writer, text.items);
+    try writer.writeAll("\"}}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// Body of a Jira search for the issue filed for constraint `id`
+pub fn formatJiraSearch(allocator: std.mem.Allocator, project: []const u8, id: constraint.ConstraintID) ![]u8 {
+    var jql = std.ArrayList(u8){};
+    defer jql.deinit(allocator);
+    try jql.writer(allocator).print("project = \"{s}\" AND labels = \"{s}-{d}\" ORDER BY created ASC", .{ project, label, id });
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    try writer.writeAll("{\"jql\": \"");
+    try output.writeJsonEscaped(writer, jql.items);
+    try writer.writeAll("\", \"fields\": [\"summary\"], \"maxResults\": 1}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+fn writeLabels(writer: anytype, labels: []const []const u8) !void {
+    for (labels) |extra| {
+        try writer.writeAll(", \"");
+        try output.writeJsonEscaped(writer, extra);
+        try writer.writeAll("\"");
+    }
+}
+
+/// Issue number by constraint ID, from one page of GitHub's list issues
+/// response. Pull requests, which the endpoint also lists, are skipped.
+/// Returns the number of entries on the page.
+pub fn collectGithubIssues(arena: std.mem.Allocator, page: []const u8, found: *std.AutoHashMap(constraint.ConstraintID, u64)) !usize {
+    const parsed = try std.json.parseFromSliceLeaky(std.json.Value, arena, page, .{});
+    if (parsed != .array) return error.MalformedResponse;
+    for (parsed.array.items) |item| {
+        if (item != .object or item.object.get("pull_request") != null) continue;
+        const number = item.object.get("number") orelse continue;
+        const body = item.object.get("body") orelse continue;
+        if (number != .integer or body != .string) continue;
+        const n = std.math.cast(u64, number.integer) orelse continue;
+        const id = parseMarker(body.string) orelse continue;
+        // The oldest issue wins when a constraint was filed twice
+        const gop = try found.getOrPut(id);
+        if (!gop.found_existing or gop.value_ptr.* > n) gop.value_ptr.* = n;
+    }
+    return parsed.array.items.len;
+}
+
+/// Key of the first issue in a Jira search response
+pub fn firstJiraKey(arena: std.mem.Allocator, response: []const u8) !?[]const u8 {
+    const parsed = try std.json.parseFromSliceLeaky(std.json.Value, arena, response, .{});
+    if (parsed != .object) return error.MalformedResponse;
+    const found = parsed.object.get("issues") orelse return null;
+    if (found != .array or found.array.items.len == 0) return null;
+    const first = found.array.items[0];
+    if (first != .object) return null;
+    const key = first.object.get("key") orelse return null;
+    return if (key == .string) key.string else null;
+}
+
+/// Authorization header for Jira Cloud: basic auth with an account email and API token
+pub fn jiraBasicAuth(allocator: std.mem.Allocator, email: []const u8, token: []const u8) ![]u8 {
+    const credentials = try std.fmt.allocPrint(allocator, "{s}:{s}", .{ email, token });
+    defer allocator.free(credentials);
+    const encoder = std.base64.standard.Encoder;
+    const header = try allocator.alloc(u8, "Basic ".len + encoder.calcSize(credentials.len));
+    @memcpy(header[0.."Basic ".len], "Basic ");
+    _ = encoder.encode(header["Basic ".len..], credentials);
+    return header;
+}
+
+const Client = struct {
+    allocator: std.mem.Allocator,
+    http: std.http.Client,
+    target: Target,
+
+    /// Issue one request with the tracker's headers and return the status;
+    /// a 2xx response body is read into `response_body`
+    fn send(self: *Client, method: std.http.Method, url: []const u8, payload: ?[]const u8, response_body: ?*std.ArrayList(u8)) !u16 {
+        const github_headers = json_api.githubHeaders(self.target.authorization);
+        const jira_headers = [_]std.http.Header{
+            .{ .name = "authorization", .value = self.target.authorization },
+            .{ .name = "accept", .value = "application/json" },
+        };
+        const headers: []const std.http.Header = if (self.target.tracker == .github) &github_headers else &jira_headers;
+        const response: ?json_api.Response = if (response_body) |body| .{ .body = body } else null;
+        return json_api.send(self.allocator, &self.http, method, url, headers, payload, response);
+    }
+};
+
+/// Create or update one issue per violation. `report` is called with each
+/// violation, the issue it went to (a GitHub number or Jira key, or null
+/// when the request failed), and whether the issue is new.
+pub fn exportViolations(
+    allocator: std.mem.Allocator,
+    target: Target,
+    violations: []const Violation,
+    context: anytype,
+    comptime report: fn (@TypeOf(context), Violation, ?[]const u8, bool) void,
+) !Outcome {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+    const api = std.mem.trimRight(u8, target.api_url, "/");
+
+    var client = Client{ .allocator = arena, .http = .{ .allocator = allocator }, .target = target };
+    defer client.http.deinit();
+
+    var existing = std.AutoHashMap(constraint.ConstraintID, u64).init(arena);
+    if (target.tracker == .github) {
+        var page: usize = 1;
+        while (page <= max_pages) : (page += 1) {
+            const url = try std.fmt.allocPrint(arena, "{s}/repos/{s}/issues?state=all&labels={s}&per_page={d}&page={d}", .{ api, target.repository.?, label, page_size, page });
+            var body = std.ArrayList(u8){};
+            const status = try client.send(.GET, url, null, &body);
+            if (status != 200) return error.LookupFailed;
+            if (try collectGithubIssues(arena, body.items, &existing) < page_size) break;
+        }
+    }
+
+    var outcome = Outcome{};
+    for (violations) |violation| {
+        const filed = fileOne(arena, &client, api, &existing, violation) catch null;
+        if (filed) |result| {
+            if (result.created) outcome.created += 1 else outcome.updated += 1;
+            report(context, violation, result.reference, result.created);
+        } else {
+            outcome.failed += 1;
+            report(context, violation, null, false);
+        }
+    }
+    return outcome;
+}
+
+const Filed = struct {
+    reference: []const u8,
+    created: bool,
+};
+
+fn fileOne(
+    arena: std.mem.Allocator,
+    client: *Client,
+    api: []const u8,
+    existing: *std.AutoHashMap(constraint.ConstraintID, u64),
+    violation: Violation,
+) !Filed {
+    const target = client.target;
+    const id = violation.id();
+    var body = std.ArrayList(u8){};
+    switch (target.tracker) {
+        .github => {
+            const repository = target.repository.?;
+            if (existing.get(id)) |number| {
+                const url = try std.fmt.allocPrint(arena, "{s}/repos/{s}/issues/{d}", .{ api, repository, number });
+                const status = try client.send(.PATCH, url, try formatGithubIssue(arena, violation, target.labels, true), null);
+                if (status != 200) return error.RequestFailed;
+                return .{ .reference = try std.fmt.allocPrint(arena, "#{d}", .{number}), .created = false };
+            }
+            const url = try std.fmt.allocPrint(arena, "{s}/repos/{s}/issues", .{ api, repository });
+            if (try client.send(.POST, url, try formatGithubIssue(arena, violation, target.labels, false), &body) != 201) return error.RequestFailed;
+            const created = try std.json.parseFromSliceLeaky(std.json.Value, arena, body.items, .{});
+            const number = if (created == .object) created.object.get("number") else null;
+            const n: u64 = if (number != null and number.? == .integer) @intCast(number.?.integer) else return error.MalformedResponse;
+            // A second violation of the same constraint updates this issue
+            try existing.put(id, n);
+            return .{ .reference = try std.fmt.allocPrint(arena, "#{d}", .{n}), .created = true };
+        },
+        .jira => {
+            const project = target.project.?;
+            const search_url = try std.fmt.allocPrint(arena, "{s}/rest/api/2/search", .{api});
+            if (try client.send(.POST, search_url, try formatJiraSearch(arena, project, id), &body) != 200) return error.LookupFailed;
+            if (try firstJiraKey(arena, body.items)) |key| {
+                const url = try std.fmt.allocPrint(arena, "{s}/rest/api/2/issue/{s}", .{ api, key });
+                const status = try client.send(.PUT, url, try formatJiraIssue(arena, violation, target, true), null);
+                if (status != 204 and status != 200) return error.RequestFailed;
+                return .{ .reference = key, .created = false };
+            }
+            body.clearRetainingCapacity();
+            const url = try std.fmt.allocPrint(arena, "{s}/rest/api/2/issue", .{api});
+            if (try client.send(.POST, url, try formatJiraIssue(arena, violation, target, false), &body) != 201) return error.RequestFailed;
+            const created = try std.json.parseFromSliceLeaky(std.json.Value, arena, body.items, .{});
+            const key = if (created == .object) created.object.get("key") else null;
+            if (key == null or key.? != .string) return error.MalformedResponse;
+            return .{ .reference = key.?.string, .created = true };
+        },
+    }
+}
+
+test "issue payloads carry the constraint id for deduplication" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const violation = Violation{
+        .constraint = .{ .id = 42, .name = "no_raw_sql", .description = "Use \"prepared\" statements", .kind = .security, .severity = .err, .origin_line = 7 },
+        .file = "src/db.go",
+    };
+    const github_body = try formatGithubIssue(allocator, violation, &.{"triage"}, false);
+    defer allocator.free(github_body);
+    const created = try std.json.parseFromSliceLeaky(std.json.Value, arena, github_body, .{});
+    try testing.expectEqualStrings("Constraint violation: no_raw_sql", created.object.get("title").?.string);
+    try testing.expectEqual(@as(?constraint.ConstraintID, 42), parseMarker(created.object.get("body").?.string));
+    try testing.expectEqualStrings("triage", created.object.get("labels").?.array.items[2].string);
+    try testing.expect(std.mem.indexOf(u8, created.object.get("body").?.string, "`src/db.go:7`") != null);
+
+    const page =
+        \\[{"number": 9, "body": "x\n<!-- ananke-constraint-id: 42 -->\n"},
+        \\ {"number": 3, "body": "<!-- ananke-constraint-id: 42 -->"},
+        \\ {"number": 4, "body": "<!-- ananke-constraint-id: 5 -->", "pull_request": {}},
+        \\ {"number": 5, "body": null}]
+    ;
+    var found = std.AutoHashMap(constraint.ConstraintID, u64).init(arena);
+    try testing.expectEqual(@as(usize, 4), try collectGithubIssues(arena, page, &found));
+    try testing.expectEqual(@as(?u64, 3), found.get(42));
+    try testing.expect(found.get(5) == null);
+
+    const target = Target{ .tracker = .jira, .api_url = "https://example.atlassian.net", .project = "SEC", .authorization = "" };
+    const jira_body = try formatJiraIssue(allocator, violation, target, false);
+    defer allocator.free(jira_body);
+    const jira = try std.json.parseFromSliceLeaky(std.json.Value, arena, jira_body, .{});
+    const fields = jira.object.get("fields").?.object;
+    try testing.expectEqualStrings("SEC", fields.get("project").?.object.get("key").?.string);
+    try testing.expectEqualStrings("ananke-42", fields.get("labels").?.array.items[1].string);
+    try testing.expectEqualStrings("SEC-12", (try firstJiraKey(arena, "{\"issues\": [{\"key\": \"SEC-12\"}]}")).?);
+    try testing.expect((try firstJiraKey(arena, "{\"issues\": []}")) == null);
+
+    const auth = try jiraBasicAuth(allocator, "Aladdin", "open sesame");
+    defer allocator.free(auth);
+    try testing.expectEqualStrings("Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==", auth);
+}
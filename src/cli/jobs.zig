@@ -0,0 +1,379 @@
+// Extraction concurrency
+// Extraction runs in three stages: parse (read sources from disk), analyze
+// (run the Clew extractors), and render (format per-file output). Each stage
+// has its own worker count so CI machines shared with other jobs can cap the
+// expensive stage without serializing the rest.
+const std = @import("std");
+
+/// Render rarely benefits from more workers than this; it is cheap next to analysis
+pub const max_default_render_jobs = 4;
+
+/// Resolved worker count per stage (always at least 1)
+pub const Concurrency = struct {
+    parse: usize = 1,
+    analyze: usize = 1,
+    render: usize = 1,
+};
+
+/// Requested worker counts; 0 means "derive a default"
+pub const Settings = struct {
+    jobs: usize = 0,
+    parse: usize = 0,
+    analyze: usize = 0,
+    render: usize = 0,
+};
+
+/// Number of CPUs available to the process, or 1 if it cannot be determined
+pub fn cpuCount() usize {
+    return std.Thread.getCpuCount() catch 1;
+}
+
+/// Fill in defaults: `jobs` falls back to the CPU count, parse and analyze to
+/// `jobs`, and render to `jobs` capped at `max_default_render_jobs`
+pub fn resolve(settings: Settings, cpus: usize) Concurrency {
+    const jobs = if (settings.jobs > 0) settings.jobs else @max(cpus, 1);
+    return .{
+        .parse = if (settings.parse > 0) settings.parse else jobs,
+        .analyze = if (settings.analyze > 0) settings.analyze else jobs,
+        .render = if (settings.render > 0) settings.render else @min(jobs, max_default_render_jobs),
+    };
+}
+
+/// Share a run-wide worker budget between `units` pipelines running at once
+/// (targets extracted concurrently): each stage gets its share of the
+/// budget, rounded down but never below one worker
+pub fn share(budget: Concurrency, units: usize) Concurrency {
+    const n = @max(units, 1);
+    return .{
+        .parse = @max(budget.parse / n, 1),
+        .analyze = @max(budget.analyze / n, 1),
+        .render = @max(budget.render / n, 1),
+    };
+}
+
+/// Indices of `costs` from most to least expensive, ties in index order.
+/// Claiming work in this order starts the longest items first, so one huge
+/// item picked up at the end cannot leave a single worker running alone.
+/// Caller owns the slice.
+pub fn costOrder(allocator: std.mem.Allocator, costs: []const u64) ![]usize {
+    const order = try allocator.alloc(usize, costs.len);
+    for (order, 0..) |*index, i| index.* = i;
+    std.sort.pdq(usize, order, costs, struct {
+        fn moreExpensive(c: []const u64, a: usize, b: usize) bool {
+            return c[a] > c[b] or (c[a] == c[b] and a < b);
+        }
+    }.moreExpensive);
+    return order;
+}
+
+pub const Range = struct {
+    start: usize,
+    end: usize,
+};
+
+/// Contiguous slice of `len` items handled by worker `index` of `workers`.
+/// Earlier workers take one extra item when `len` does not divide evenly.
+pub fn chunkRange(len: usize, workers: usize, index: usize) Range {
+    const base = len / workers;
+    const extra = len % workers;
+    const start = index * base + @min(index, extra);
+    return .{ .start = start, .end = start + base + @intFromBool(index < extra) };
+}
+
+/// Number of workers actually used for `len` items
+pub fn workerCount(len: usize, workers: usize) usize {
+    return @max(@min(workers, len), 1);
+}
+
+/// Per-item outcome, written by exactly one worker and read once that worker
+/// has finished with it (after the join, or when forEachOrdered consumes it)
+pub fn Slot(comptime T: type) type {
+    return struct {
+        value: ?T = null,
+        err: ?anyerror = null,
+    };
+}
+
+/// Call `func(context, worker, start, end)` for each chunk of `len` items,
+/// one thread per chunk. Worker 0 runs on the calling thread. If threads
+/// cannot be spawned the remaining chunks run inline, so callers never need
+/// a sequential fallback. `func` must not fail; record errors in `context`.
+pub fn forEachChunk(
+    allocator: std.mem.Allocator,
+    len: usize,
+    workers: usize,
+    context: anytype,
+    comptime func: fn (@TypeOf(context), usize, usize, usize) void,
+) void {
+    if (len == 0) return;
+    const n = workerCount(len, workers);
+
+    const threads: []?std.Thread = allocator.alloc(?std.Thread, n) catch &.{};
+    defer allocator.free(threads);
+    for (threads) |*thread| thread.* = null;
+
+    var worker: usize = 1;
+    while (worker < n) : (worker += 1) {
+        const range = chunkRange(len, n, worker);
+        if (threads.len == n) {
+            if (std.Thread.spawn(.{}, func, .{ context, worker, range.start, range.end })) |thread| {
+                threads[worker] = thread;
+                continue;
+            } else |_| {}
+        }
+        func(context, worker, range.start, range.end);
+    }
+
+    const first = chunkRange(len, n, 0);
+    func(context, 0, first.start, first.end);
+
+    for (threads) |maybe_thread| {
+        if (maybe_thread) |thread| thread.join();
+    }
+}
+
+/// Run `produce(context, worker, index)` for every index on a pool of up to
+/// `workers` threads while the calling thread runs `consume(context, index)`
+/// in index order as soon as each item is ready. Workers claim one item at a
+/// time, so a few huge files cannot leave the rest of the pool idle behind a
+/// static chunk, and the consumer can release each result before later ones
+/// finish. If `consume` fails, workers stop claiming new items and the error
+/// is returned after they join; items produced but not consumed are left for
+/// the caller to release. When no thread can be spawned, items are produced
+/// inline by worker 0.
+pub fn forEachOrdered(
+    allocator: std.mem.Allocator,
+    len: usize,
+    workers: usize,
+    context: anytype,
+    comptime produce: fn (@TypeOf(context), usize, usize) void,
+    comptime consume: fn (@TypeOf(context), usize) anyerror!void,
+) anyerror!void {
+    return forEachOrderedBy(allocator, len, workers, null, context, produce, consume);
+}
+
+/// forEachOrdered, with workers claiming items in `claim_order` (a
+/// permutation of the indices, e.g. from costOrder) instead of index order.
+/// Items are still consumed in index order.
+pub fn forEachOrderedBy(
+    allocator: std.mem.Allocator,
+    len: usize,
+    workers: usize,
+    claim_order: ?[]const usize,
+    context: anytype,
+    comptime produce: fn (@TypeOf(context), usize, usize) void,
+    comptime consume: fn (@TypeOf(context), usize) anyerror!void,
+) anyerror!void {
+    if (len == 0) return;
+    const n = workerCount(len, workers);
+
+    const ready = try allocator.alloc(bool, len);
+    defer allocator.free(ready);
+    @memset(ready, false);
+
+    const Pool = struct {
+        context: @TypeOf(context),
+        ready: []bool,
+        order: ?[]const usize,
+        next: std.atomic.Value(usize) = std.atomic.Value(usize).init(0),
+        cancelled: std.atomic.Value(bool) = std.atomic.Value(bool).init(false),
+        mutex: std.Thread.Mutex = .{},
+        done: std.Thread.Condition = .{},
+
+        fn work(pool: *@This(), worker: usize) void {
+            while (!pool.cancelled.load(.acquire)) {
+                const claimed = pool.next.fetchAdd(1, .monotonic);
+                if (claimed >= pool.ready.len) return;
+                const index = if (pool.order) |order| order[claimed] else claimed;
+                produce(pool.context, worker, index);
+                pool.mutex.lock();
+                pool.ready[index] = true;
+                pool.mutex.unlock();
+                pool.done.broadcast();
+            }
+        }
+
+        fn wait(pool: *@This(), index: usize) void {
+            pool.mutex.lock();
+            defer pool.mutex.unlock();
+            while (!pool.ready[index]) pool.done.wait(&pool.mutex);
+        }
+    };
+    var pool = Pool{ .context = context, .ready = ready, .order = claim_order };
+
+    const threads = try allocator.alloc(std.Thread, n);
+    defer allocator.free(threads);
+    var spawned: usize = 0;
+    while (spawned < n) : (spawned += 1) {
+        threads[spawned] = std.Thread.spawn(.{}, Pool.work, .{ &pool, spawned }) catch break;
+    }
+    defer {
+        pool.cancelled.store(true, .release);
+        for (threads[0..spawned]) |thread| thread.join();
+    }
+
+    for (0..len) |index| {
+        if (spawned == 0) {
+            produce(context, 0, index);
+        } else {
+            pool.wait(index);
+        }
+        try consume(context, index);
+    }
+}
+
+/// Caps the combined read throughput of every worker sharing it, so
+/// extraction over NFS or FUSE mounts leaves bandwidth for other jobs.
+/// Readers charge the bytes they read and sleep until the rate allows them;
+/// up to one second of unused budget may be spent as a burst.
+pub const Throttle = struct {
+    bytes_per_sec: u64,
+    start_ns: i128,
+    mutex: std.Thread.Mutex = .{},
+    /// Nanoseconds after start at which every charged byte is paid for
+    paid_until: u64 = 0,
+
+    pub fn init(bytes_per_sec: u64) Throttle {
+        return .{ .bytes_per_sec = bytes_per_sec, .start_ns = std.time.nanoTimestamp() };
+    }
+
+    /// Account for `bytes` just read, sleeping while the reader is ahead of the rate
+    pub fn charge(self: *Throttle, bytes: usize) void {
+        const elapsed: u64 = @intCast(@max(std.time.nanoTimestamp() - self.start_ns, 0));
+        self.mutex.lock();
+        const wait = self.reserve(elapsed, bytes);
+        self.mutex.unlock();
+        if (wait > 0) std.Thread.sleep(wait);
+    }
+
+    /// Book `bytes` at time `now` (ns after start) and return how long the
+    /// reader must wait. Caller holds the mutex.
+    fn reserve(self: *Throttle, now: u64, bytes: usize) u64 {
+        if (self.bytes_per_sec == 0) return 0;
+        const cost = @as(u64, bytes) * std.time.ns_per_s / self.bytes_per_sec;
+        self.paid_until = @max(self.paid_until, now -| std.time.ns_per_s) + cost;
+        return self.paid_until -| now;
+    }
+};
+
+/// Parse a byte count such as `50M`, `512K`, or `1G` (binary units, optional
+/// `B`/`iB` suffix); a bare number is bytes
+pub fn parseByteSize(text: []const u8) ?u64 {
+    var digits = std.mem.trim(u8, text, " \t");
+    for ([_][]const u8{ "iB", "ib", "B", "b" }) |suffix| {
+        if (digits.len > suffix.len and std.mem.endsWith(u8, digits, suffix)) {
+            digits = digits[0 .. digits.len - suffix.len];
+            break;
+        }
+    }
+    if (digits.len == 0) return null;
+    const shift: u6 = switch (std.ascii.toUpper(digits[digits.len - 1])) {
+        'K' => 10,
+        'M' => 20,
+        'G' => 30,
+        else => 0,
+    };
+    if (shift > 0) digits = digits[0 .. digits.len - 1];
+    const value = std.fmt.parseInt(u64, digits, 10) catch return null;
+    return std.math.shlExact(u64, value, shift) catch null;
+}
+
+test "resolve derives stage defaults from jobs" {
+    const testing = std.testing;
+
+    const auto = resolve(.{}, 16);
+    try testing.expectEqual(@as(usize, 16), auto.parse);
+    try testing.expectEqual(@as(usize, 16), auto.analyze);
+    try testing.expectEqual(@as(usize, max_default_render_jobs), auto.render);
+
+    const tuned = resolve(.{ .jobs = 2, .analyze = 6 }, 16);
+    try testing.expectEqual(@as(usize, 2), tuned.parse);
+    try testing.expectEqual(@as(usize, 6), tuned.analyze);
+    try testing.expectEqual(@as(usize, 2), tuned.render);
+}
+
+test "share divides the budget without starving a pipeline" {
+    const testing = std.testing;
+
+    const shared = share(.{ .parse = 16, .analyze = 16, .render = 4 }, 3);
+    try testing.expectEqual(@as(usize, 5), shared.parse);
+    try testing.expectEqual(@as(usize, 5), shared.analyze);
+    try testing.expectEqual(@as(usize, 1), shared.render);
+
+    const tight = share(.{ .parse = 2, .analyze = 2, .render = 1 }, 8);
+    try testing.expectEqual(@as(usize, 1), tight.analyze);
+}
+
+test "throttle spaces reads to the configured rate" {
+    const testing = std.testing;
+
+    var throttle = Throttle.init(1000);
+    // A second of budget is available up front, then reads wait their turn
+    try testing.expectEqual(@as(u64, 0), throttle.reserve(std.time.ns_per_s, 500));
+    try testing.expectEqual(@as(u64, 0), throttle.reserve(std.time.ns_per_s, 500));
+    try testing.expectEqual(@as(u64, std.time.ns_per_s / 2), throttle.reserve(std.time.ns_per_s, 500));
+
+    var unlimited = Throttle.init(0);
+    try testing.expectEqual(@as(u64, 0), unlimited.reserve(0, 1 << 30));
+
+    try testing.expectEqual(@as(?u64, 50 << 20), parseByteSize("50M"));
+    try testing.expectEqual(@as(?u64, 512 << 10), parseByteSize("512KiB"));
+    try testing.expectEqual(@as(?u64, 4096), parseByteSize("4096"));
+    try testing.expectEqual(@as(?u64, null), parseByteSize("fast"));
+}
+
+test "costOrder starts the most expensive items first" {
+    const testing = std.testing;
+
+    const order = try costOrder(testing.allocator, &.{ 10, 5000, 10, 700 });
+    defer testing.allocator.free(order);
+    try testing.expectEqualSlices(usize, &.{ 1, 3, 0, 2 }, order);
+}
+
+test "forEachChunk covers every item exactly once" {
+    const testing = std.testing;
+
+    var hits = [_]std.atomic.Value(u32){std.atomic.Value(u32).init(0)} ** 10;
+    const Ctx = struct {
+        fn visit(items: *[10]std.atomic.Value(u32), _: usize, start: usize, end: usize) void {
+            for (items[start..end]) |*item| _ = item.fetchAdd(1, .monotonic);
+        }
+    };
+    forEachChunk(testing.allocator, hits.len, 3, &hits, Ctx.visit);
+    for (hits) |hit| try testing.expectEqual(@as(u32, 1), hit.load(.monotonic));
+
+    try testing.expectEqual(Range{ .start = 0, .end = 4 }, chunkRange(10, 3, 0));
+    try testing.expectEqual(Range{ .start = 7, .end = 10 }, chunkRange(10, 3, 2));
+}
+
+test "forEachOrdered consumes every item in order" {
+    const testing = std.testing;
+
+    const Ctx = struct {
+        produced: [50]u32 = [_]u32{0} ** 50,
+        consumed: std.ArrayList(usize) = .{},
+
+        fn produce(ctx: *@This(), _: usize, index: usize) void {
+            ctx.produced[index] += 1;
+        }
+
+        fn consume(ctx: *@This(), index: usize) anyerror!void {
+            if (ctx.produced[index] != 1) return error.NotReady;
+            try ctx.consumed.append(testing.allocator, index);
+        }
+    };
+    var ctx = Ctx{};
+    defer ctx.consumed.deinit(testing.allocator);
+    try forEachOrdered(testing.allocator, 50, 4, &ctx, Ctx.produce, Ctx.consume);
+
+    try testing.expectEqual(@as(usize, 50), ctx.consumed.items.len);
+    for (ctx.consumed.items, 0..) |index, i| try testing.expectEqual(i, index);
+
+    // Claiming in reverse still consumes in index order
+    var reversed: [50]usize = undefined;
+    for (&reversed, 0..) |*index, i| index.* = 49 - i;
+    var by_cost = Ctx{};
+    defer by_cost.consumed.deinit(testing.allocator);
+    try forEachOrderedBy(testing.allocator, 50, 4, &reversed, &by_cost, Ctx.produce, Ctx.consume);
+    for (by_cost.consumed.items, 0..) |index, i| try testing.expectEqual(i, index);
+}
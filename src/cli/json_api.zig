@@ -0,0 +1,79 @@
+// JSON requests to HTTP APIs
+// The exporters that talk to a service (GitHub checks and issues, Jira,
+// embedding providers and vector stores) send a JSON body and read back at
+// most a bounded JSON response, so a misbehaving server cannot exhaust memory.
+const std = @import("std");
+
+/// Response bytes read when the caller sets no limit of its own
+pub const default_max_response_bytes = 1024 * 1024;
+
+pub const RequestError = error{ResponseTooLarge};
+
+/// Where a 2xx response body is read to, and how much of it is accepted
+pub const Response = struct {
+    body: *std.ArrayList(u8),
+    max_bytes: usize = default_max_response_bytes,
+};
+
+/// Headers the GitHub REST API expects, authorized with `authorization`
+/// ("Bearer <token>")
+pub fn githubHeaders(authorization: []const u8) [3]std.http.Header {
+    return .{
+        .{ .name = "authorization", .value = authorization },
+        .{ .name = "accept", .value = "application/vnd.github+json" },
+        .{ .name = "x-github-api-version", .value = "2022-11-28" },
+    };
+}
+
+/// Issue one request with an optional JSON `payload` and return the status.
+/// A 2xx response body is appended to `response_body`; one longer than its limit
+/// fails with ResponseTooLarge.
+pub fn send(
+    allocator: std.mem.Allocator,
+    client: *std.http.Client,
+    method: std.http.Method,
+    url: []const u8,
+    headers: []const std.http.Header,
+    payload: ?[]const u8,
+    response_body: ?Response,
+) !u16 {
+    const uri = try std.Uri.parse(url);
+    var req = try client.request(method, uri, .{
+        .headers = .{ .content_type = .{ .override = "application/json" } },
+        .extra_headers = headers,
+    });
+    defer req.deinit();
+
+    if (payload) |data| {
+        req.transfer_encoding = .{ .content_length = data.len };
+        var body_writer = try req.sendBodyUnflushed(&.{});
+        try body_writer.writer.writeAll(data);
+        try body_writer.end();
+        try req.connection.?.flush();
+    } else {
+        try req.sendBodiless();
+    }
+
+    var redirect_buffer: [2048]u8 = undefined;
+    var response = try req.receiveHead(&redirect_buffer);
+    const status: u16 = @intFromEnum(response.head.status);
+    if (status >= 200 and status < 300) {
+        if (response_body) |into| {
+            const reader = response.reader(&.{});
+            reader.appendRemaining(allocator, into.body, .limited(into.max_bytes)) catch |err| switch (err) {
+                error.StreamTooLong => return RequestError.ResponseTooLarge,
+                else => |e| return e,
+            };
+        }
+    }
+    return status;
+}
+
+test "github headers carry the token and API version" {
+    const testing = std.testing;
+
+    const headers = githubHeaders("Bearer ghp_x");
+    try testing.expectEqualStrings("authorization", headers[0].name);
+    try testing.expectEqualStrings("Bearer ghp_x", headers[0].value);
+    try testing.expectEqualStrings("2022-11-28", headers[2].value);
+}
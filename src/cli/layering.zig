@@ -0,0 +1,719 @@
+// Go package layering
+// Recovers how the packages of a Go module depend on each other by layer and
+// checks the architecture rules a team promotes from it. Packages belong to
+// the layers configured under `[architecture] layers` by path, or else to
+// the layer their directory is conventionally named for (api or handlers →
+// handler, service or usecase → service, repository or store → repository).
+// Rules take two forms:
+//
+//   service !-> handler                  no dependency, even through other packages
+//   handler -> repository via interface  only the target's interface types are used
+//
+// and each violation comes with the import lines or type uses causing it.
+// Parsing is line-based and expects gofmt layout.
+const std = @import("std");
+const go_api = @import("cli_go_api");
+const output = @import("cli_output");
+
+pub const LayeringError = error{
+    /// A rule is neither `a !-> b` nor `a -> b via interface`
+    InvalidRule,
+    /// A layer is not `name=path,path`
+    InvalidLayer,
+};
+
+/// Directory names that put a package in a layer when none are configured
+pub const default_layers = [_]struct { name: []const u8, dirs: []const []const u8 }{
+    .{ .name = "handler", .dirs = &.{ "handler", "handlers", "api", "http", "transport", "controller", "controllers", "rest" } },
+    .{ .name = "service", .dirs = &.{ "service", "services", "usecase", "usecases" } },
+    .{ .name = "repository", .dirs = &.{ "repository", "repositories", "repo", "store", "storage", "persistence", "dao" } },
+};
+
+/// A configured layer: the packages under any of `prefixes`
+pub const Layer = struct {
+    name: []const u8,
+    prefixes: []const []const u8,
+};
+
+fn isName(text: []const u8) bool {
+    if (text.len == 0) return false;
+    for (text) |c| {
+        if (!std.ascii.isAlphanumeric(c) and c != '_' and c != '-') return false;
+    }
+    return true;
+}
+
+/// `handler=internal/api,internal/http`
+pub fn parseLayer(arena: std.mem.Allocator, text: []const u8) !Layer {
+    const eq = std.mem.indexOfScalar(u8, text, '=') orelse return LayeringError.InvalidLayer;
+    const name = std.mem.trim(u8, text[0..eq], " ");
+    if (!isName(name)) return LayeringError.InvalidLayer;
+    var prefixes = std.ArrayList([]const u8){};
+    var parts = std.mem.splitScalar(u8, text[eq + 1 ..], ',');
+    while (parts.next()) |part| {
+        var prefix = std.mem.trim(u8, part, " ");
+        while (std.mem.startsWith(u8, prefix, "./")) prefix = prefix[2..];
+        prefix = std.mem.trimRight(u8, prefix, "/");
+        if (prefix.len > 0) try prefixes.append(arena, prefix);
+    }
+    if (prefixes.items.len == 0) return LayeringError.InvalidLayer;
+    return .{ .name = name, .prefixes = prefixes.items };
+}
+
+pub const RuleKind = enum {
+    /// No package of `from` depends on `to`, directly or transitively
+    forbid,
+    /// Packages of `from` use only interface types of `to`
+    via_interface,
+};
+
+pub const Rule = struct {
+    /// The rule as written
+    text: []const u8,
+    kind: RuleKind,
+    from: []const u8,
+    to: []const u8,
+};
+
+pub fn parseRule(text: []const u8) LayeringError!Rule {
+    const rule = std.mem.trim(u8, text, " ");
+    if (std.mem.indexOf(u8, rule, "!->")) |at| {
+        const from = std.mem.trim(u8, rule[0..at], " ");
+        const to = std.mem.trim(u8, rule[at + 3 ..], " ");
+        if (!isName(from) or !isName(to) or std.mem.eql(u8, from, to)) return LayeringError.InvalidRule;
+        return .{ .text = rule, .kind = .forbid, .from = from, .to = to };
+    }
+    const at = std.mem.indexOf(u8, rule, "->") orelse return LayeringError.InvalidRule;
+    const suffix = " via interface";
+    if (!std.mem.endsWith(u8, rule, suffix)) return LayeringError.InvalidRule;
+    const from = std.mem.trim(u8, rule[0..at], " ");
+    const to = std.mem.trim(u8, rule[at + 2 .. rule.len - suffix.len], " ");
+    if (!isName(from) or !isName(to) or std.mem.eql(u8, from, to)) return LayeringError.InvalidRule;
+    return .{ .text = rule, .kind = .via_interface, .from = from, .to = to };
+}
+
+/// The module path declared by a go.mod file
+pub fn modulePath(go_mod: []const u8) ?[]const u8 {
+    var lines = std.mem.splitScalar(u8, go_mod, '\n');
+    while (lines.next()) |raw| {
+        const line = std.mem.trim(u8, raw, " \t\r");
+        if (!std.mem.startsWith(u8, line, "module ")) continue;
+        const path = std.mem.trim(u8, line["module ".len..], " \t\"");
+        if (path.len > 0) return path;
+    }
+    return null;
+}
+
+/// An import of another package of the module
+pub const Import = struct {
+    /// Directory of the imported package
+    dir: []const u8,
+    file: []const u8,
+    line: u32,
+};
+
+/// A reference to an exported name of another package of the module
+pub const Use = struct {
+    /// Directory of the package the name belongs to
+    dir: []const u8,
+    /// `repository.UserStore` as written
+    text: []const u8,
+    name: []const u8,
+    file: []const u8,
+    line: u32,
+};
+
+pub const Package = struct {
+    /// Directory relative to the module root
+    dir: []const u8,
+    imports: std.ArrayList(Import) = .{},
+    uses: std.ArrayList(Use) = .{},
+    /// Exported type declarations
+    types: std.ArrayList(go_api.Symbol) = .{},
+};
+
+/// The packages of one Go module and their imports of each other
+pub const Graph = struct {
+    arena: std.mem.Allocator,
+    /// Directory of go.mod, relative to the working directory
+    root: []const u8,
+    /// Module path from go.mod; imports outside it are ignored
+    module: []const u8,
+    packages: std.ArrayList(Package) = .{},
+    by_dir: std.StringHashMap(usize),
+
+    pub fn init(arena: std.mem.Allocator, root: []const u8, module: []const u8) Graph {
+        return .{ .arena = arena, .root = root, .module = module, .by_dir = std.StringHashMap(usize).init(arena) };
+    }
+
+    fn packageAt(self: *Graph, dir: []const u8) !*Package {
+        const gop = try self.by_dir.getOrPut(dir);
+        if (!gop.found_existing) {
+            gop.value_ptr.* = self.packages.items.len;
+            try self.packages.append(self.arena, .{ .dir = dir });
+        }
+        return &self.packages.items[gop.value_ptr.*];
+    }
+
+    /// Directory of an import path of the module, or null for other imports
+    fn importDir(self: *const Graph, path: []const u8) ?[]const u8 {
+        if (self.module.len == 0 or !std.mem.startsWith(u8, path, self.module)) return null;
+        if (path.len == self.module.len) return ".";
+        if (path[self.module.len] != '/') return null;
+        return path[self.module.len + 1 ..];
+    }
+
+    /// Add the Go file at `path`, under the module root. Test files are the
+    /// caller's to leave out. Everything borrows `path` and `source`.
+    pub fn addFile(self: *Graph, path: []const u8, source: []const u8) !void {
+        var rel = path;
+        if (!std.mem.eql(u8, self.root, ".") and std.mem.startsWith(u8, path, self.root) and
+            path.len > self.root.len and path[self.root.len] == '/')
+        {
+            rel = path[self.root.len + 1 ..];
+        }
+        const dir = std.fs.path.dirname(rel) orelse ".";
+        const pkg = try self.packageAt(dir);
+        for (try go_api.parse(self.arena, path, source)) |symbol| {
+            if (symbol.kind == .type_decl) try pkg.types.append(self.arena, symbol);
+        }
+
+        // Names the file refers to the module's packages by
+        var aliases = std.StringHashMap([]const u8).init(self.arena);
+        var lines = std.ArrayList([]const u8){};
+        var it = std.mem.splitScalar(u8, source, '\n');
+        while (it.next()) |line| try lines.append(self.arena, line);
+
+        var in_block = false;
+        for (lines.items, 0..) |raw, i| {
+            const line = std.mem.trim(u8, raw, " \t\r");
+            const line_no: u32 = @intCast(i + 1);
+            var spec: ?[]const u8 = null;
+            if (in_block) {
+                if (std.mem.eql(u8, line, ")")) {
+                    in_block = false;
+                    continue;
+                }
+                spec = line;
+            } else if (std.mem.eql(u8, line, "import (")) {
+                in_block = true;
+                continue;
+            } else if (std.mem.startsWith(u8, line, "import ")) {
+                spec = line["import ".len..];
+            }
+
+            if (spec) |text| {
+                const open = std.mem.indexOfScalar(u8, text, '"') orelse continue;
+                const close = std.mem.indexOfScalarPos(u8, text, open + 1, '"') orelse continue;
+                const target = self.importDir(text[open + 1 .. close]) orelse continue;
+                if (std.mem.eql(u8, target, dir)) continue;
+                try pkg.imports.append(self.arena, .{ .dir = target, .file = path, .line = line_no });
+                const alias = std.mem.trim(u8, text[0..open], " ");
+                if (std.mem.eql(u8, alias, "_") or std.mem.eql(u8, alias, ".")) continue;
+                try aliases.put(if (alias.len > 0) alias else std.fs.path.basename(target), target);
+                continue;
+            }
+            if (aliases.count() == 0 or std.mem.startsWith(u8, line, "//")) continue;
+            try self.collectUses(pkg, aliases, path, line, line_no);
+        }
+    }
+
+    /// `alias.Name` references on `line`, outside string literals
+    fn collectUses(self: *Graph, pkg: *Package, aliases: std.StringHashMap([]const u8), path: []const u8, line: []const u8, line_no: u32) !void {
+        var quote: ?u8 = null;
+        var i: usize = 0;
+        while (i < line.len) : (i += 1) {
+            const c = line[i];
+            if (quote) |q| {
+                if (c == '\\' and q != '`') {
+                    i += 1;
+                } else if (c == q) quote = null;
+                continue;
+            }
+            if (c == '"' or c == '`' or c == '\'') {
+                quote = c;
+                continue;
+            }
+            if (c == '/' and i + 1 < line.len and line[i + 1] == '/') return;
+            if (!isIdentStart(c) or (i > 0 and (isIdentChar(line[i - 1]) or line[i - 1] == '.'))) continue;
+            const start = i;
+            var end = i;
+            while (end < line.len and isIdentChar(line[end])) end += 1;
+            i = end - 1;
+            if (end + 1 >= line.len or line[end] != '.' or !std.ascii.isUpper(line[end + 1])) continue;
+            const target = aliases.get(line[start..end]) orelse continue;
+            var name_end = end + 1;
+            while (name_end < line.len and isIdentChar(line[name_end])) name_end += 1;
+            try pkg.uses.append(self.arena, .{
+                .dir = target,
+                .text = line[start..name_end],
+                .name = line[end + 1 .. name_end],
+                .file = path,
+                .line = line_no,
+            });
+            i = name_end - 1;
+        }
+    }
+};
+
+fn isIdentStart(c: u8) bool {
+    return std.ascii.isAlphabetic(c) or c == '_';
+}
+
+fn isIdentChar(c: u8) bool {
+    return std.ascii.isAlphanumeric(c) or c == '_';
+}
+
+/// Layer of the package in `dir`: the configured layer with the longest
+/// matching prefix, or with none configured, the layer of its innermost
+/// conventionally named directory
+pub fn layerOf(dir: []const u8, layers: []const Layer) ?[]const u8 {
+    if (layers.len > 0) {
+        var best: ?[]const u8 = null;
+        var best_len: usize = 0;
+        for (layers) |layer| {
+            for (layer.prefixes) |prefix| {
+                const under = std.mem.eql(u8, dir, prefix) or
+                    (std.mem.startsWith(u8, dir, prefix) and dir[prefix.len] == '/');
+                if (under and (best == null or prefix.len > best_len)) {
+                    best = layer.name;
+                    best_len = prefix.len;
+                }
+            }
+        }
+        return best;
+    }
+    var segments = std.mem.splitBackwardsScalar(u8, dir, '/');
+    while (segments.next()) |segment| {
+        for (default_layers) |layer| {
+            for (layer.dirs) |name| {
+                if (std.mem.eql(u8, segment, name)) return layer.name;
+            }
+        }
+    }
+    return null;
+}
+
+/// One link of a violation: an import or a type use and where it is
+pub const Step = struct {
+    file: []const u8,
+    line: u32,
+    text: []const u8,
+};
+
+pub const Violation = struct {
+    /// The rule as written
+    rule: []const u8,
+    /// Directory of the package breaking it
+    package: []const u8,
+    message: []const u8,
+    trace: []const Step,
+};
+
+fn typeOf(pkg: Package, name: []const u8) ?go_api.Symbol {
+    for (pkg.types.items) |symbol| {
+        if (std.mem.eql(u8, symbol.name, name)) return symbol;
+    }
+    return null;
+}
+
+fn isInterface(symbol: go_api.Symbol) bool {
+    return std.mem.endsWith(u8, symbol.signature, "interface");
+}
+
+/// Layer of every package of `graph`, by index
+fn packageLayers(arena: std.mem.Allocator, graph: *const Graph, layers: []const Layer) ![]?[]const u8 {
+    const result = try arena.alloc(?[]const u8, graph.packages.items.len);
+    for (graph.packages.items, result) |pkg, *layer| layer.* = layerOf(pkg.dir, layers);
+    return result;
+}
+
+fn sameLayer(layer: ?[]const u8, name: []const u8) bool {
+    return if (layer) |l| std.mem.eql(u8, l, name) else false;
+}
+
+const Link = struct {
+    from: usize,
+    import: Import,
+};
+
+const Reach = struct {
+    /// Packages reachable by imports, nearest first
+    order: []const usize,
+    /// The import that first reached each package, by index
+    via: []const ?Link,
+};
+
+/// Packages reachable from package `start` by imports, breadth first
+fn reach(arena: std.mem.Allocator, graph: *const Graph, start: usize) !Reach {
+    const via = try arena.alloc(?Link, graph.packages.items.len);
+    @memset(via, null);
+    const seen = try arena.alloc(bool, graph.packages.items.len);
+    @memset(seen, false);
+    seen[start] = true;
+
+    var queue = std.ArrayList(usize){};
+    try queue.append(arena, start);
+    var head: usize = 0;
+    while (head < queue.items.len) : (head += 1) {
+        const p = queue.items[head];
+        for (graph.packages.items[p].imports.items) |imp| {
+            const q = graph.by_dir.get(imp.dir) orelse continue;
+            if (seen[q]) continue;
+            seen[q] = true;
+            via[q] = .{ .from = p, .import = imp };
+            try queue.append(arena, q);
+        }
+    }
+    return .{ .order = queue.items[1..], .via = via };
+}
+
+/// Violations of `rules` in `graph`, in rule order. Everything lives in
+/// `arena` or borrows the graph.
+pub fn check(arena: std.mem.Allocator, graph: *const Graph, layers: []const Layer, rules: []const Rule) ![]const Violation {
+    const layer_of = try packageLayers(arena, graph, layers);
+    var violations = std.ArrayList(Violation){};
+    for (rules) |rule| {
+        for (graph.packages.items, 0..) |pkg, p| {
+            if (!sameLayer(layer_of[p], rule.from)) continue;
+            switch (rule.kind) {
+                .forbid => {
+                    const reached = try reach(arena, graph, p);
+                    for (reached.order) |q| {
+                        if (!sameLayer(layer_of[q], rule.to)) continue;
+                        // Walk back from q to p through the imports reaching it
+                        var steps = std.ArrayList(Step){};
+                        var at = q;
+                        while (reached.via[at]) |link| : (at = link.from) {
+                            try steps.append(arena, .{ .file = link.import.file, .line = link.import.line, .text = try std.fmt.allocPrint(arena, "imports {s}", .{link.import.dir}) });
+                        }
+                        std.mem.reverse(Step, steps.items);
+                        const through = steps.items.len - 1;
+                        try violations.append(arena, .{
+                            .rule = rule.text,
+                            .package = pkg.dir,
+                            .message = if (through == 0)
+                                try std.fmt.allocPrint(arena, "{s} ({s}) imports {s} ({s})", .{ pkg.dir, rule.from, graph.packages.items[q].dir, rule.to })
+                            else
+                                try std.fmt.allocPrint(arena, "{s} ({s}) depends on {s} ({s}) through {d} other package{s}", .{ pkg.dir, rule.from, graph.packages.items[q].dir, rule.to, through, if (through == 1) "" else "s" }),
+                            .trace = steps.items,
+                        });
+                    }
+                },
+                .via_interface => {
+                    var reported = std.StringHashMap(void).init(arena);
+                    for (pkg.uses.items) |use| {
+                        const q = graph.by_dir.get(use.dir) orelse continue;
+                        if (!sameLayer(layer_of[q], rule.to)) continue;
+                        const symbol = typeOf(graph.packages.items[q], use.name) orelse continue;
+                        if (isInterface(symbol)) continue;
+                        const key = try std.fmt.allocPrint(arena, "{s}.{s}", .{ use.dir, use.name });
+                        if ((try reported.getOrPut(key)).found_existing) continue;
+                        const steps = try arena.alloc(Step, 2);
+                        steps[0] = .{ .file = use.file, .line = use.line, .text = try std.fmt.allocPrint(arena, "uses {s}", .{use.text}) };
+                        steps[1] = .{ .file = symbol.file, .line = symbol.line, .text = try std.fmt.allocPrint(arena, "{s} is declared as {s}", .{ use.name, symbol.signature }) };
+                        try violations.append(arena, .{
+                            .rule = rule.text,
+                            .package = pkg.dir,
+                            .message = try std.fmt.allocPrint(arena, "{s} ({s}) uses the concrete type {s} of {s} ({s}) instead of an interface", .{ pkg.dir, rule.from, use.text, use.dir, rule.to }),
+                            .trace = steps,
+                        });
+                    }
+                },
+            }
+        }
+    }
+    return violations.items;
+}
+
+pub const LayerSummary = struct {
+    name: []const u8,
+    packages: usize,
+};
+
+/// Direct dependencies of one layer on another
+pub const Edge = struct {
+    from: []const u8,
+    to: []const u8,
+    imports: usize = 0,
+    /// Uses of the target's interface and other types
+    interface_uses: usize = 0,
+    concrete_uses: usize = 0,
+};
+
+/// The layering the code has, and the rules it already satisfies
+pub const Layering = struct {
+    layers: []const LayerSummary,
+    /// Packages in no layer
+    unlayered: usize,
+    edges: []const Edge,
+    /// `a !-> b` for every layer b depends on that does not depend back, and
+    /// `a -> b via interface` for every dependency using only interfaces
+    proposed: []const []const u8,
+};
+
+pub fn extract(arena: std.mem.Allocator, graph: *const Graph, layers: []const Layer) !Layering {
+    const layer_of = try packageLayers(arena, graph, layers);
+
+    // Layer names in configured or conventional order, when they have packages
+    var names = std.ArrayList([]const u8){};
+    if (layers.len > 0) {
+        for (layers) |layer| try names.append(arena, layer.name);
+    } else {
+        for (default_layers) |layer| try names.append(arena, layer.name);
+    }
+    var summaries = std.ArrayList(LayerSummary){};
+    var unlayered: usize = 0;
+    for (names.items) |name| {
+        var count: usize = 0;
+        for (layer_of) |layer| {
+            if (sameLayer(layer, name)) count += 1;
+        }
+        if (count > 0) try summaries.append(arena, .{ .name = name, .packages = count });
+    }
+    for (layer_of) |layer| {
+        if (layer == null) unlayered += 1;
+    }
+
+    const n = summaries.items.len;
+    const index = struct {
+        fn of(list: []const LayerSummary, layer: ?[]const u8) ?usize {
+            const name = layer orelse return null;
+            for (list, 0..) |s, i| {
+                if (std.mem.eql(u8, s.name, name)) return i;
+            }
+            return null;
+        }
+    }.of;
+
+    // Direct edges, and which layers reach which through any packages
+    const edges = try arena.alloc(Edge, n * n);
+    for (edges, 0..) |*edge, k| edge.* = .{ .from = summaries.items[k / n].name, .to = summaries.items[k % n].name };
+    const reaches = try arena.alloc(bool, n * n);
+    @memset(reaches, false);
+    for (graph.packages.items, 0..) |pkg, p| {
+        const a = index(summaries.items, layer_of[p]) orelse continue;
+        for (pkg.imports.items) |imp| {
+            const q = graph.by_dir.get(imp.dir) orelse continue;
+            const b = index(summaries.items, layer_of[q]) orelse continue;
+            if (a != b) edges[a * n + b].imports += 1;
+        }
+        for (pkg.uses.items) |use| {
+            const q = graph.by_dir.get(use.dir) orelse continue;
+            const b = index(summaries.items, layer_of[q]) orelse continue;
+            if (a == b) continue;
+            const symbol = typeOf(graph.packages.items[q], use.name) orelse continue;
+            if (isInterface(symbol)) edges[a * n + b].interface_uses += 1 else edges[a * n + b].concrete_uses += 1;
+        }
+        const reached = try reach(arena, graph, p);
+        for (reached.order) |q| {
+            const b = index(summaries.items, layer_of[q]) orelse continue;
+            if (a != b) reaches[a * n + b] = true;
+        }
+    }
+
+    var direct = std.ArrayList(Edge){};
+    var proposed = std.ArrayList([]const u8){};
+    for (edges, 0..) |edge, k| {
+        if (edge.imports == 0) continue;
+        try direct.append(arena, edge);
+    }
+    for (0..n) |a| {
+        for (0..n) |b| {
+            if (a == b or !reaches[a * n + b] or reaches[b * n + a]) continue;
+            try proposed.append(arena, try std.fmt.allocPrint(arena, "{s} !-> {s}", .{ summaries.items[b].name, summaries.items[a].name }));
+        }
+    }
+    for (direct.items) |edge| {
+        if (edge.concrete_uses > 0 or edge.interface_uses == 0) continue;
+        try proposed.append(arena, try std.fmt.allocPrint(arena, "{s} -> {s} via interface", .{ edge.from, edge.to }));
+    }
+    return .{ .layers = summaries.items, .unlayered = unlayered, .edges = direct.items, .proposed = proposed.items };
+}
+
+pub fn formatLayering(allocator: std.mem.Allocator, layering: Layering) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("Layers:\n");
+    for (layering.layers) |layer| {
+        try writer.print("  {s: <14} {d} package{s}\n", .{ layer.name, layer.packages, if (layer.packages == 1) "" else "s" });
+    }
+    if (layering.unlayered > 0) try writer.print("  ({d} packages in no layer)\n", .{layering.unlayered});
+
+    try writer.writeAll("\nDependencies:\n");
+    if (layering.edges.len == 0) try writer.writeAll("  (none between layers)\n");
+    for (layering.edges) |edge| {
+        try writer.print("  {s} -> {s}: {d} import{s}, {d} interface and {d} concrete type uses\n", .{
+            edge.from,
+            edge.to,
+            edge.imports,
+            if (edge.imports == 1) "" else "s",
+            edge.interface_uses,
+            edge.concrete_uses,
+        });
+    }
+
+    try writer.writeAll("\nRules the code satisfies:\n");
+    if (layering.proposed.len == 0) try writer.writeAll("  (none)\n");
+    for (layering.proposed) |rule| try writer.print("  {s}\n", .{rule});
+    return list.toOwnedSlice(allocator);
+}
+
+pub fn formatLayeringJson(allocator: std.mem.Allocator, layering: Layering) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("{\n  \"layers\": [");
+    for (layering.layers, 0..) |layer, i| {
+        try writer.print("{s}{{\"name\": \"{s}\", \"packages\": {d}}}", .{ if (i == 0) "" else ", ", layer.name, layer.packages });
+    }
+    try writer.print("],\n  \"unlayered\": {d},\n  \"dependencies\": [", .{layering.unlayered});
+    for (layering.edges, 0..) |edge, i| {
+        try writer.print("{s}\n    {{\"from\": \"{s}\", \"to\": \"{s}\", \"imports\": {d}, \"interface_uses\": {d}, \"concrete_uses\": {d}}}", .{
+            if (i == 0) "" else ",",
+            edge.from,
+            edge.to,
+            edge.imports,
+            edge.interface_uses,
+            edge.concrete_uses,
+        });
+    }
+    try writer.writeAll(if (layering.edges.len == 0) "],\n  \"proposed\": [" else "\n  ],\n  \"proposed\": [");
+    for (layering.proposed, 0..) |rule, i| {
+        try writer.print("{s}\"{s}\"", .{ if (i == 0) "" else ", ", rule });
+    }
+    try writer.writeAll("]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// Each violation with its trace, indented by `indent`
+pub fn writeViolations(writer: anytype, violations: []const Violation, indent: []const u8) !void {
+    for (violations) |v| {
+        try writer.print("{s}x {s}: {s}\n", .{ indent, v.rule, v.message });
+        for (v.trace) |step| {
+            try writer.print("{s}    {s}:{d}: {s}\n", .{ indent, step.file, step.line, step.text });
+        }
+    }
+}
+
+/// Violations as a JSON array, its items on lines indented by `indent`
+pub fn writeViolationsJson(writer: anytype, violations: []const Violation, indent: []const u8) !void {
+    try writer.writeAll("[");
+    for (violations, 0..) |v, i| {
+        try writer.print("{s}\n{s}  {{\"rule\": \"", .{ if (i == 0) "" else ",", indent });
+        try output.writeJsonEscaped(writer, v.rule);
+        try writer.writeAll("\", \"package\": \"");
+        try output.writeJsonEscaped(writer, v.package);
+        try writer.writeAll("\", \"message\": \"");
+        try output.writeJsonEscaped(writer, v.message);
+        try writer.writeAll("\", \"trace\": [");
+        for (v.trace, 0..) |step, j| {
+            try writer.writeAll(if (j == 0) "{\"file\": \"" else ", {\"file\": \"");
+            try output.writeJsonEscaped(writer, step.file);
+            try writer.print("\", \"line\": {d}, \"text\": \"", .{step.line});
+            try output.writeJsonEscaped(writer, step.text);
+            try writer.writeAll("\"}");
+        }
+        try writer.writeAll("]}");
+    }
+    if (violations.len > 0) try writer.print("\n{s}", .{indent});
+    try writer.writeAll("]");
+}
+
+test "extract layering and check architecture rules" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    try testing.expectEqualStrings("github.com/acme/shop", modulePath("module github.com/acme/shop\n\ngo 1.22\n").?);
+    try testing.expectEqual(RuleKind.via_interface, (try parseRule("handler -> repository via interface")).kind);
+    try testing.expectEqualStrings("handler", (try parseRule(" service !-> handler ")).to);
+    try testing.expectError(LayeringError.InvalidRule, parseRule("handler -> repository"));
+    try testing.expectError(LayeringError.InvalidLayer, parseLayer(arena, "handler="));
+
+    var graph = Graph.init(arena, ".", "github.com/acme/shop");
+    try graph.addFile("internal/api/users.go",
+        \\package api
+        \\
+        \\import (
+        \\	"net/http"
+        \\
+        \\	"github.com/acme/shop/internal/repository"
+        \\	"github.com/acme/shop/internal/service"
+        \\)
+        \\
+        \\type Handler struct {
+        \\	users *service.Users
+        \\	repo  *repository.Postgres
+        \\}
+    );
+    try graph.addFile("internal/service/users.go",
+        \\package service
+        \\
+        \\import (
+        \\	"github.com/acme/shop/internal/notify"
+        \\	repo "github.com/acme/shop/internal/repository"
+        \\)
+        \\
+        \\type Users struct {
+        \\	store repo.UserStore
+        \\}
+    );
+    try graph.addFile("internal/notify/mail.go",
+        \\package notify
+        \\
+        \\import "github.com/acme/shop/internal/api"
+    );
+    try graph.addFile("internal/repository/postgres.go",
+        \\package repository
+        \\
+        \\type UserStore interface {
+        \\	Find(id string) (User, error)
+        \\}
+        \\
+        \\type Postgres struct {
+        \\	db *sql.DB
+        \\}
+    );
+
+    try testing.expectEqualStrings("handler", layerOf("internal/api", &.{}).?);
+    try testing.expect(layerOf("internal/notify", &.{}) == null);
+    const configured = [_]Layer{try parseLayer(arena, "handler=internal/api,./internal/notify/")};
+    try testing.expectEqualStrings("handler", layerOf("internal/notify", &configured).?);
+
+    const rules = [_]Rule{
+        try parseRule("service !-> handler"),
+        try parseRule("handler -> repository via interface"),
+        try parseRule("service -> repository via interface"),
+    };
+    const violations = try check(arena, &graph, &.{}, &rules);
+    try testing.expectEqual(@as(usize, 2), violations.len);
+
+    try testing.expectEqualStrings("service !-> handler", violations[0].rule);
+    try testing.expectEqualStrings("internal/service (service) depends on internal/api (handler) through 1 other package", violations[0].message);
+    try testing.expectEqual(@as(usize, 2), violations[0].trace.len);
+    try testing.expectEqualStrings("internal/service/users.go", violations[0].trace[0].file);
+    try testing.expectEqual(@as(u32, 4), violations[0].trace[0].line);
+    try testing.expectEqualStrings("imports internal/api", violations[0].trace[1].text);
+    try testing.expectEqual(@as(u32, 3), violations[0].trace[1].line);
+
+    try testing.expectEqualStrings("handler -> repository via interface", violations[1].rule);
+    try testing.expectEqualStrings("uses repository.Postgres", violations[1].trace[0].text);
+    try testing.expectEqual(@as(u32, 12), violations[1].trace[0].line);
+    try testing.expectEqualStrings("Postgres is declared as struct", violations[1].trace[1].text);
+    try testing.expectEqual(@as(u32, 7), violations[1].trace[1].line);
+
+    const layering = try extract(arena, &graph, &.{});
+    try testing.expectEqual(@as(usize, 3), layering.layers.len);
+    try testing.expectEqual(@as(usize, 1), layering.unlayered);
+    try testing.expectEqual(@as(usize, 3), layering.edges.len);
+    // service reaches handler through notify, so only the repository rules hold
+    try testing.expectEqual(@as(usize, 3), layering.proposed.len);
+    try testing.expectEqualStrings("repository !-> handler", layering.proposed[0]);
+    try testing.expectEqualStrings("repository !-> service", layering.proposed[1]);
+    try testing.expectEqualStrings("service -> repository via interface", layering.proposed[2]);
+}
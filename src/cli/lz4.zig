@@ -0,0 +1,156 @@
+// LZ4 block compression
+// Compresses cache entries, which repeat the same JSON keys and constraint
+// text many times over. Output is a raw LZ4 block (no frame header), so
+// `lz4 -d` does not read it directly but any LZ4 block decoder does; the
+// caller records the uncompressed length. Compression trades ratio for speed:
+// one hash probe per position, no lazy matching.
+const std = @import("std");
+
+const min_match = 4;
+/// The last match must start this many bytes before the end of the input,
+/// and the last `last_literals` bytes are always literals (block format rules)
+const match_limit = 12;
+const last_literals = 5;
+const max_offset = 65535;
+const hash_log = 12;
+
+pub const Error = error{CorruptInput};
+
+/// Largest input `compress` accepts
+pub const max_input = std.math.maxInt(u32);
+
+/// Append the LZ4 block for `src` to `out`
+pub fn compress(allocator: std.mem.Allocator, out: *std.ArrayList(u8), src: []const u8) !void {
+    if (src.len > max_input) return error.InputTooLarge;
+    try out.ensureUnusedCapacity(allocator, src.len + src.len / 255 + 16);
+
+    var table = [_]u32{0} ** (1 << hash_log);
+    var anchor: usize = 0;
+    var i: usize = 0;
+    if (src.len > match_limit) {
+        const end = src.len - match_limit;
+        while (i < end) {
+            const sequence = std.mem.readInt(u32, src[i..][0..4], .little);
+            const slot = hash(sequence);
+            const candidate: usize = table[slot];
+            table[slot] = @intCast(i);
+            if (candidate >= i or i - candidate > max_offset or
+                std.mem.readInt(u32, src[candidate..][0..4], .little) != sequence)
+            {
+                i += 1;
+                continue;
+            }
+
+            var length: usize = min_match;
+            while (i + length < src.len - last_literals and src[candidate + length] == src[i + length]) length += 1;
+            try writeSequence(allocator, out, src[anchor..i], i - candidate, length);
+            i += length;
+            anchor = i;
+        }
+    }
+
+    // The final sequence is literals only
+    const literals = src[anchor..];
+    try out.append(allocator, @as(u8, @intCast(@min(literals.len, 15))) << 4);
+    if (literals.len >= 15) try writeLength(allocator, out, literals.len - 15);
+    try out.appendSlice(allocator, literals);
+}
+
+/// Decompress an LZ4 block that holds exactly `length` bytes
+pub fn decompress(allocator: std.mem.Allocator, src: []const u8, length: usize) ![]u8 {
+    const out = try allocator.alloc(u8, length);
+    errdefer allocator.free(out);
+
+    var i: usize = 0;
+    var o: usize = 0;
+    while (true) {
+        if (i >= src.len) return error.CorruptInput;
+        const token = src[i];
+        i += 1;
+
+        var literals: usize = token >> 4;
+        if (literals == 15) literals += try readLength(src, &i);
+        if (literals > src.len - i or literals > out.len - o) return error.CorruptInput;
+        @memcpy(out[o..][0..literals], src[i..][0..literals]);
+        i += literals;
+        o += literals;
+        if (i == src.len) break;
+
+        if (src.len - i < 2) return error.CorruptInput;
+        const offset = std.mem.readInt(u16, src[i..][0..2], .little);
+        i += 2;
+        if (offset == 0 or offset > o) return error.CorruptInput;
+        var match: usize = (token & 15) + min_match;
+        if (token & 15 == 15) match += try readLength(src, &i);
+        if (match > out.len - o) return error.CorruptInput;
+        // Matches may overlap the bytes they produce, so copy forward
+        for (0..match) |k| out[o + k] = out[o - offset + k];
+        o += match;
+    }
+    if (o != out.len) return error.CorruptInput;
+    return out;
+}
+
+fn hash(sequence: u32) usize {
+    return @as(u32, sequence *% 2654435761) >> (32 - hash_log);
+}
+
+fn writeSequence(allocator: std.mem.Allocator, out: *std.ArrayList(u8), literals: []const u8, offset: usize, length: usize) !void {
+    const extra = length - min_match;
+    const token = (@as(u8, @intCast(@min(literals.len, 15))) << 4) | @as(u8, @intCast(@min(extra, 15)));
+    try out.append(allocator, token);
+    if (literals.len >= 15) try writeLength(allocator, out, literals.len - 15);
+    try out.appendSlice(allocator, literals);
+    var offset_bytes: [2]u8 = undefined;
+    std.mem.writeInt(u16, &offset_bytes, @intCast(offset), .little);
+    try out.appendSlice(allocator, &offset_bytes);
+    if (extra >= 15) try writeLength(allocator, out, extra - 15);
+}
+
+fn writeLength(allocator: std.mem.Allocator, out: *std.ArrayList(u8), length: usize) !void {
+    var rest = length;
+    while (rest >= 255) : (rest -= 255) try out.append(allocator, 255);
+    try out.append(allocator, @intCast(rest));
+}
+
+fn readLength(src: []const u8, i: *usize) Error!usize {
+    var length: usize = 0;
+    while (true) {
+        if (i.* >= src.len) return error.CorruptInput;
+        const byte = src[i.*];
+        i.* += 1;
+        length += byte;
+        if (byte != 255) return length;
+    }
+}
+
+test "lz4 round trips and shrinks repetitive text" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var text = std.ArrayList(u8){};
+    defer text.deinit(allocator);
+    for (0..200) |n| {
+        try text.writer(allocator).print("{{\"kind\": \"syntactic\", \"name\": \"function_{d}\", \"source\": \"ast_pattern\"}},\n", .{n});
+    }
+    // Long literal and match runs need length extension bytes
+    try text.appendNTimes(allocator, 'x', 1000);
+
+    for ([_][]const u8{ text.items, "", "short", "abcabcabcabcabcabcabcabc" }) |input| {
+        var compressed = std.ArrayList(u8){};
+        defer compressed.deinit(allocator);
+        try compress(allocator, &compressed, input);
+        const restored = try decompress(allocator, compressed.items, input.len);
+        defer allocator.free(restored);
+        try testing.expectEqualStrings(input, restored);
+    }
+
+    var compressed = std.ArrayList(u8){};
+    defer compressed.deinit(allocator);
+    try compress(allocator, &compressed, text.items);
+    try testing.expect(compressed.items.len * 4 < text.items.len);
+
+    // Truncated or mislabeled input is rejected, not trusted
+    try testing.expectError(error.CorruptInput, decompress(allocator, compressed.items[0 .. compressed.items.len / 2], text.items.len));
+    try testing.expectError(error.CorruptInput, decompress(allocator, compressed.items, text.items.len + 1));
+}
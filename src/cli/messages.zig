@@ -0,0 +1,183 @@
+// Message catalog for human-readable report strings
+// English strings are built in; a catalog file can override any subset of keys
+// so Markdown/HTML reports can be produced in other languages.
+//
+// Catalog file format (one entry per line, TOML-style):
+//   locale = "es"
+//   report_title = "Informe de restricciones"
+const std = @import("std");
+
+/// Every translatable string used by report renderers
+pub const Key = enum {
+    locale,
+    report_title,
+    total_constraints,
+    generated_by,
+    section_summary,
+    section_constraints,
+    col_file,
+    col_line,
+    col_kind,
+    col_severity,
+    col_name,
+    col_description,
+    col_confidence,
+    col_count,
+    col_percent,
+    label_line,
+    label_search,
+    no_constraints,
+    unknown_file,
+    severity_err,
+    severity_warning,
+    severity_info,
+    severity_hint,
+    kind_syntactic,
+    kind_type_safety,
+    kind_semantic,
+    kind_architectural,
+    kind_operational,
+    kind_security,
+};
+
+const key_count = @typeInfo(Key).@"enum".fields.len;
+
+/// Built-in English catalog
+const english = blk: {
+    var strings: [key_count][]const u8 = undefined;
+    strings[@intFromEnum(Key.locale)] = "en";
+    strings[@intFromEnum(Key.report_title)] = "Constraint Report";
+    strings[@intFromEnum(Key.total_constraints)] = "Total constraints";
+    strings[@intFromEnum(Key.generated_by)] = "Generated by Ananke";
+    strings[@intFromEnum(Key.section_summary)] = "Summary";
+    strings[@intFromEnum(Key.section_constraints)] = "Constraints";
+    strings[@intFromEnum(Key.col_file)] = "File";
+    strings[@intFromEnum(Key.col_line)] = "Line";
+    strings[@intFromEnum(Key.col_kind)] = "Category";
+    strings[@intFromEnum(Key.col_severity)] = "Severity";
+    strings[@intFromEnum(Key.col_name)] = "Name";
+    strings[@intFromEnum(Key.col_description)] = "Description";
+    strings[@intFromEnum(Key.col_confidence)] = "Confidence";
+    strings[@intFromEnum(Key.col_count)] = "Count";
+    strings[@intFromEnum(Key.col_percent)] = "Percent";
+    strings[@intFromEnum(Key.label_line)] = "line";
+    strings[@intFromEnum(Key.label_search)] = "Search";
+    strings[@intFromEnum(Key.no_constraints)] = "No constraints were extracted.";
+    strings[@intFromEnum(Key.unknown_file)] = "(unknown file)";
+    strings[@intFromEnum(Key.severity_err)] = "error";
+    strings[@intFromEnum(Key.severity_warning)] = "warning";
+    strings[@intFromEnum(Key.severity_info)] = "info";
+    strings[@intFromEnum(Key.severity_hint)] = "hint";
+    strings[@intFromEnum(Key.kind_syntactic)] = "syntactic";
+    strings[@intFromEnum(Key.kind_type_safety)] = "type safety";
+    strings[@intFromEnum(Key.kind_semantic)] = "semantic";
+    strings[@intFromEnum(Key.kind_architectural)] = "architectural";
+    strings[@intFromEnum(Key.kind_operational)] = "operational";
+    strings[@intFromEnum(Key.kind_security)] = "security";
+    break :blk strings;
+};
+
+/// A set of report strings. Starts as English; `loadFile`/`parse` override entries.
+pub const Catalog = struct {
+    allocator: ?std.mem.Allocator = null,
+    strings: [key_count][]const u8 = english,
+    owned: [key_count]bool = [_]bool{false} ** key_count,
+
+    /// The built-in English catalog (no allocation, nothing to free)
+    pub fn default() Catalog {
+        return .{};
+    }
+
+    pub fn deinit(self: *Catalog) void {
+        const allocator = self.allocator orelse return;
+        for (self.strings, self.owned) |s, owned| {
+            if (owned) allocator.free(s);
+        }
+    }
+
+    /// Look up a string
+    pub fn get(self: *const Catalog, key: Key) []const u8 {
+        return self.strings[@intFromEnum(key)];
+    }
+
+    /// Localized label for a severity
+    pub fn severity(self: *const Catalog, s: anytype) []const u8 {
+        return switch (s) {
+            .err => self.get(.severity_err),
+            .warning => self.get(.severity_warning),
+            .info => self.get(.severity_info),
+            .hint => self.get(.severity_hint),
+        };
+    }
+
+    /// Localized label for a constraint kind
+    pub fn kind(self: *const Catalog, k: anytype) []const u8 {
+        return switch (k) {
+            .syntactic => self.get(.kind_syntactic),
+            .type_safety => self.get(.kind_type_safety),
+            .semantic => self.get(.kind_semantic),
+            .architectural => self.get(.kind_architectural),
+            .operational => self.get(.kind_operational),
+            .security => self.get(.kind_security),
+        };
+    }
+
+    /// Load overrides from a catalog file
+    pub fn loadFile(allocator: std.mem.Allocator, path: []const u8) !Catalog {
+        const content = try std.fs.cwd().readFileAlloc(allocator, path, 1024 * 1024);
+        defer allocator.free(content);
+
+        var catalog = Catalog{ .allocator = allocator };
+        errdefer catalog.deinit();
+        try catalog.parse(content);
+        return catalog;
+    }
+
+    /// Parse `key = "value"` lines, overriding built-in strings.
+    /// Unknown keys are ignored so catalogs stay forward compatible.
+    pub fn parse(self: *Catalog, content: []const u8) !void {
+        const allocator = self.allocator orelse return error.NoAllocator;
+
+        var lines = std.mem.splitScalar(u8, content, '\n');
+        while (lines.next()) |line| {
+            const trimmed = std.mem.trim(u8, line, " \t\r");
+            if (trimmed.len == 0 or trimmed[0] == '#' or trimmed[0] == '[') continue;
+
+            const eq_pos = std.mem.indexOf(u8, trimmed, "=") orelse continue;
+            const name = std.mem.trim(u8, trimmed[0..eq_pos], " \t");
+            var value = std.mem.trim(u8, trimmed[eq_pos + 1 ..], " \t");
+            if (value.len >= 2 and value[0] == '"' and value[value.len - 1] == '"') {
+                value = value[1 .. value.len - 1];
+            }
+
+            const key = std.meta.stringToEnum(Key, name) orelse continue;
+            const index = @intFromEnum(key);
+            if (self.owned[index]) allocator.free(self.strings[index]);
+            self.strings[index] = try allocator.dupe(u8, value);
+            self.owned[index] = true;
+        }
+    }
+};
+
+test "catalog defaults to english" {
+    const catalog = Catalog.default();
+    try std.testing.expectEqualStrings("Constraint Report", catalog.get(.report_title));
+    try std.testing.expectEqualStrings("en", catalog.get(.locale));
+}
+
+test "catalog overrides subset of keys" {
+    const testing = std.testing;
+    var catalog = Catalog{ .allocator = testing.allocator };
+    defer catalog.deinit();
+
+    try catalog.parse(
+        \\# Spanish
+        \\locale = "es"
+        \\report_title = "Informe de restricciones"
+        \\not_a_key = "ignored"
+    );
+
+    try testing.expectEqualStrings("es", catalog.get(.locale));
+    try testing.expectEqualStrings("Informe de restricciones", catalog.get(.report_title));
+    try testing.expectEqualStrings("Severity", catalog.get(.col_severity));
+}
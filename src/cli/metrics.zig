@@ -0,0 +1,93 @@
+// Prometheus text exposition format
+// Writes metric families for the /metrics endpoints of `ananke daemon` and
+// `ananke rpc`: a HELP and TYPE line per family, then one sample per label
+// set. See https://prometheus.io/docs/instrumenting/exposition_formats/.
+const std = @import("std");
+
+/// Content type of the text format, version 0.0.4
+pub const content_type = "text/plain; version=0.0.4; charset=utf-8";
+
+pub const Type = enum { counter, gauge };
+
+pub const Label = struct {
+    name: []const u8,
+    value: []const u8,
+};
+
+/// Start a metric family. Counter names should end in `_total`.
+pub fn writeHeader(writer: anytype, name: []const u8, metric_type: Type, help: []const u8) !void {
+    try writer.print("# HELP {s} ", .{name});
+    for (help) |c| switch (c) {
+        '\\' => try writer.writeAll("\\\\"),
+        '\n' => try writer.writeAll("\\n"),
+        else => try writer.writeByte(c),
+    };
+    try writer.print("\n# TYPE {s} {s}\n", .{ name, @tagName(metric_type) });
+}
+
+/// Write one sample of the family last started; `value` is an integer or float
+pub fn writeSample(writer: anytype, name: []const u8, labels: []const Label, value: anytype) !void {
+    try writer.writeAll(name);
+    if (labels.len > 0) {
+        try writer.writeByte('{');
+        for (labels, 0..) |label, i| {
+            if (i > 0) try writer.writeByte(',');
+            try writer.print("{s}=\"", .{label.name});
+            for (label.value) |c| switch (c) {
+                '\\' => try writer.writeAll("\\\\"),
+                '"' => try writer.writeAll("\\\""),
+                '\n' => try writer.writeAll("\\n"),
+                else => try writer.writeByte(c),
+            };
+            try writer.writeByte('"');
+        }
+        try writer.writeByte('}');
+    }
+    try writer.writeByte(' ');
+    switch (@typeInfo(@TypeOf(value))) {
+        .int, .comptime_int => try writer.print("{d}", .{value}),
+        .float, .comptime_float => {
+            const float: f64 = value;
+            if (std.math.isNan(float)) {
+                try writer.writeAll("NaN");
+            } else if (std.math.isInf(float)) {
+                try writer.writeAll(if (float > 0) "+Inf" else "-Inf");
+            } else {
+                try writer.print("{d}", .{float});
+            }
+        },
+        else => @compileError("metric values are integers or floats"),
+    }
+    try writer.writeByte('\n');
+}
+
+/// A family with a single unlabeled sample
+pub fn writeMetric(writer: anytype, name: []const u8, metric_type: Type, help: []const u8, value: anytype) !void {
+    try writeHeader(writer, name, metric_type, help);
+    try writeSample(writer, name, &.{}, value);
+}
+
+test "prometheus text format" {
+    const testing = std.testing;
+    var list = std.ArrayList(u8){};
+    defer list.deinit(testing.allocator);
+    const writer = list.writer(testing.allocator);
+
+    try writeHeader(writer, "ananke_requests_total", .counter, "Requests answered, by method");
+    try writeSample(writer, "ananke_requests_total", &.{.{ .name = "method", .value = "query" }}, @as(u64, 12));
+    try writeSample(writer, "ananke_requests_total", &.{.{ .name = "method", .value = "a\"b\\c\nd" }}, 0);
+    try writeMetric(writer, "ananke_cache_hit_ratio", .gauge, "Fraction of cache lookups that hit", @as(f64, 0.75));
+    try writeSample(writer, "ananke_nan", &.{}, std.math.nan(f64));
+
+    try testing.expectEqualStrings(
+        \\# HELP ananke_requests_total Requests answered, by method
+        \\# TYPE ananke_requests_total counter
+        \\ananke_requests_total{method="query"} 12
+        \\ananke_requests_total{method="a\"b\\c\nd"} 0
+        \\# HELP ananke_cache_hit_ratio Fraction of cache lookups that hit
+        \\# TYPE ananke_cache_hit_ratio gauge
+        \\ananke_cache_hit_ratio 0.75
+        \\ananke_nan NaN
+        \\
+    , list.items);
+}
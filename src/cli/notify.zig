@@ -0,0 +1,375 @@
+// Drift notifications
+// When a run checked against a baseline finds more drift than the configured
+// threshold (new constraints plus baseline entries that no longer match) on a
+// watched branch, a digest is sent to each `[notify]` channel: Slack and
+// Microsoft Teams incoming webhooks get a message in their own format, and
+// plain webhooks get a `drift.detected` payload shaped like the completion
+// webhooks (and signed the same way). Delivery never fails the run.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const output = @import("cli_output");
+const git = @import("cli_git");
+const webhook = @import("cli_webhook");
+
+/// Constraints listed in a digest; the rest are counted
+pub const max_listed = 10;
+
+pub const Channel = enum {
+    slack,
+    teams,
+    webhook,
+};
+
+/// Where drift digests go and when
+pub const Notifiers = struct {
+    slack: []const []const u8 = &.{},
+    teams: []const []const u8 = &.{},
+    webhooks: []const []const u8 = &.{},
+    /// Drift (new plus stale) must exceed this to notify
+    threshold: usize = 0,
+    /// Branches to watch; `*` at the end matches a prefix. Empty watches all.
+    branches: []const []const u8 = &.{},
+    /// Signs plain webhook payloads, as for completion webhooks
+    secret: ?[]const u8 = null,
+
+    pub fn fromConfig(config: config_mod.Config) Notifiers {
+        return .{
+            .slack = config.notify_slack,
+            .teams = config.notify_teams,
+            .webhooks = config.notify_webhooks,
+            .threshold = config.notify_drift_threshold,
+            .branches = config.notify_branches,
+            .secret = config.webhook_secret.slice(),
+        };
+    }
+
+    pub fn enabled(self: Notifiers) bool {
+        return self.slack.len + self.teams.len + self.webhooks.len > 0;
+    }
+
+    pub fn watches(self: Notifiers, branch: ?[]const u8) bool {
+        if (self.branches.len == 0) return true;
+        const name = branch orelse return false;
+        for (self.branches) |pattern| {
+            if (std.mem.endsWith(u8, pattern, "*")) {
+                if (std.mem.startsWith(u8, name, pattern[0 .. pattern.len - 1])) return true;
+            } else if (std.mem.eql(u8, pattern, name)) {
+                return true;
+            }
+        }
+        return false;
+    }
+};
+
+/// Drift found by one run against its baseline
+pub const Digest = struct {
+    target: []const u8,
+    baseline: []const u8,
+    branch: ?[]const u8 = null,
+    commit: ?[]const u8 = null,
+    threshold: usize = 0,
+    /// Constraints the baseline does not accept
+    added: []const constraint.Constraint,
+    /// Baseline entries with no matching constraint
+    removed: usize,
+
+    pub fn drift(self: Digest) usize {
+        return self.added.len + self.removed;
+    }
+
+    /// The digest with string literals and snippets masked in its
+    /// constraints (see output.redactText), for runs with --redact. The copies
+    /// are allocated with `allocator`, typically an arena.
+    pub fn redact(self: Digest, allocator: std.mem.Allocator) !Digest {
+        const added = try allocator.dupe(constraint.Constraint, self.added);
+        for (added) |*c| {
+            c.name = try output.redactText(allocator, c.name);
+            c.description = try output.redactText(allocator, c.description);
+        }
+        var redacted = self;
+        redacted.added = added;
+        return redacted;
+    }
+
+    fn title(self: Digest, writer: anytype) !void {
+        try writer.writeAll("Constraint drift");
+        if (self.branch) |branch| try writer.print(" on {s}", .{branch});
+    }
+
+    fn sentence(self: Digest, writer: anytype) !void {
+        try writer.print("{d} new constraints and {d} stale baseline entries in {s} (threshold {d})", .{
+            self.added.len,
+            self.removed,
+            self.target,
+            self.threshold,
+        });
+        if (self.commit) |commit| try writer.print(" at {s}", .{commit});
+    }
+};
+
+/// Branch being checked: $ANANKE_BRANCH, then the branch CI reports
+/// (GitHub Actions, GitLab CI, Buildkite), then git's current branch
+pub fn currentBranch(arena: std.mem.Allocator) ?[]const u8 {
+    for ([_][]const u8{ "ANANKE_BRANCH", "GITHUB_HEAD_REF", "GITHUB_REF_NAME", "CI_COMMIT_REF_NAME", "BUILDKITE_BRANCH" }) |name| {
+        const value = std.process.getEnvVarOwned(arena, name) catch continue;
+        if (value.len > 0) return value;
+    }
+    const stdout = git.runGit(arena, &.{ "git", "rev-parse", "--abbrev-ref", "HEAD" }) catch return null;
+    const branch = std.mem.trim(u8, stdout, " \t\r\n");
+    // A detached HEAD has no branch to watch
+    return if (branch.len == 0 or std.mem.eql(u8, branch, "HEAD")) null else branch;
+}
+
+/// Abbreviated commit of HEAD, when in a git repository
+pub fn currentCommit(arena: std.mem.Allocator) ?[]const u8 {
+    const stdout = git.runGit(arena, &.{ "git", "rev-parse", "--short", "HEAD" }) catch return null;
+    const commit = std.mem.trim(u8, stdout, " \t\r\n");
+    return if (commit.len == 0) null else commit;
+}
+
+/// Slack incoming webhook message: a header, the counts, and a list of the
+/// first new constraints
+pub fn formatSlack(allocator: std.mem.Allocator, digest: Digest) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    var text = std.ArrayList(u8){};
+    defer text.deinit(allocator);
+    try digest.sentence(text.writer(allocator));
+    var heading = std.ArrayList(u8){};
+    defer heading.deinit(allocator);
+    try digest.title(heading.writer(allocator));
+
+    try writer.writeAll("{\"text\": \"");
+    try writeSlackEscaped(writer, heading.items);
+    try writer.writeAll(": ");
+    try writeSlackEscaped(writer, text.items);
+    try writer.writeAll("\", \"blocks\": [{\"type\": \"header\", \"text\": {\"type\": \"plain_text\", \"text\": \"");
+    try output.writeJsonEscaped(writer, heading.items);
+    try writer.writeAll("\"}}, {\"type\": \"section\", \"text\": {\"type\": \"mrkdwn\", \"text\": \"");
+    try writeSlackEscaped(writer, text.items);
+    try writer.writeAll("\"}}");
+    if (digest.added.len > 0) {
+        try writer.writeAll(", {\"type\": \"section\", \"text\": {\"type\": \"mrkdwn\", \"text\": \"");
+        for (digest.added[0..@min(digest.added.len, max_listed)]) |c| {
+            try writer.writeAll("\\u2022 `");
+            try writeLocation(writer, c, writeSlackEscaped);
+            try writer.print("` {s}: ", .{@tagName(c.kind)});
+            try writeSlackEscaped(writer, c.name);
+            try writer.writeAll("\\n");
+        }
+        if (digest.added.len > max_listed) try writer.print("and {d} more", .{digest.added.len - max_listed});
+        try writer.writeAll("\"}}");
+    }
+    try writer.writeAll("]}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// Microsoft Teams incoming webhook message (a MessageCard with the counts
+/// as facts and the first new constraints as text)
+pub fn formatTeams(allocator: std.mem.Allocator, digest: Digest) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    var heading = std.ArrayList(u8){};
+    defer heading.deinit(allocator);
+    try digest.title(heading.writer(allocator));
+
+    try writer.writeAll("{\"@type\": \"MessageCard\", \"@context\": \"https://schema.org/extensions\", \"themeColor\": \"D9534F\", \"summary\": \"");
+    try output.writeJsonEscaped(writer, heading.items);
+    try writer.writeAll("\", \"title\": \"");
+    try output.writeJsonEscaped(writer, heading.items);
+    try writer.writeAll("\", \"sections\": [{\"facts\": [{\"name\": \"Target\", \"value\": \"");
+    try output.writeJsonEscaped(writer, digest.target);
+    try writer.print("\"}}, {{\"name\": \"New constraints\", \"value\": \"{d}\"}}, {{\"name\": \"Stale baseline entries\", \"value\": \"{d}\"}}, {{\"name\": \"Threshold\", \"value\": \"{d}\"}}", .{
+        digest.added.len,
+        digest.removed,
+        digest.threshold,
+    });
+    if (digest.commit) |commit| {
+        try writer.writeAll(", {\"name\": \"Commit\", \"value\": \"");
+        try output.writeJsonEscaped(writer, commit);
+        try writer.writeAll("\"}");
+    }
+    try writer.writeAll("], \"text\": \"");
+    for (digest.added[0..@min(digest.added.len, max_listed)]) |c| {
+        try writer.writeAll("- `");
+        try writeLocation(writer, c, output.writeJsonEscaped);
+        try writer.print("` {s}: ", .{@tagName(c.kind)});
+        try output.writeJsonEscaped(writer, c.name);
+        try writer.writeAll("\\n\\n");
+    }
+    if (digest.added.len > max_listed) try writer.print("and {d} more", .{digest.added.len - max_listed});
+    try writer.writeAll("\"}]}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// Plain webhook payload: a `drift.detected` event whose summary holds the
+/// counts and the first new constraints
+pub fn formatWebhook(allocator: std.mem.Allocator, digest: Digest, tool_version: []const u8, timestamp: i64) ![]u8 {
+    var summary = std.ArrayList(u8){};
+    defer summary.deinit(allocator);
+    const writer = summary.writer(allocator);
+
+    try writer.writeAll("{\"branch\": ");
+    try writeOptionalString(writer, digest.branch);
+    try writer.writeAll(", \"commit\": ");
+    try writeOptionalString(writer, digest.commit);
+    try writer.writeAll(", \"baseline\": \"");
+    try output.writeJsonEscaped(writer, digest.baseline);
+    try writer.print("\", \"threshold\": {d}, \"added\": {d}, \"removed\": {d}, \"constraints\": [", .{
+        digest.threshold,
+        digest.added.len,
+        digest.removed,
+    });
+    for (digest.added[0..@min(digest.added.len, max_listed)], 0..) |c, i| {
+        if (i > 0) try writer.writeAll(", ");
+        try writer.writeAll("{\"file\": ");
+        try writeOptionalString(writer, c.origin_file);
+        if (c.origin_line) |line| {
+            try writer.print(", \"line\": {d}", .{line});
+        } else {
+            try writer.writeAll(", \"line\": null");
+        }
+        try writer.print(", \"kind\": \"{s}\", \"severity\": \"{s}\", \"name\": \"", .{ @tagName(c.kind), @tagName(c.severity) });
+        try output.writeJsonEscaped(writer, c.name);
+        try writer.writeAll("\"}");
+    }
+    try writer.writeAll("]}");
+    return webhook.formatPayload(allocator, .drift, tool_version, timestamp, digest.target, "drift", summary.items);
+}
+
+fn writeOptionalString(writer: anytype, value: ?[]const u8) !void {
+    const text = value orelse return writer.writeAll("null");
+    try writer.writeByte('"');
+    try output.writeJsonEscaped(writer, text);
+    try writer.writeByte('"');
+}
+
+fn writeLocation(writer: anytype, c: constraint.Constraint, comptime escape: anytype) !void {
+    try escape(writer, c.origin_file orelse "(unknown)");
+    if (c.origin_line) |line| try writer.print(":{d}", .{line});
+}
+
+/// JSON-escape `text` for a Slack mrkdwn field, where &, <, and > are entities
+fn writeSlackEscaped(writer: anytype, text: []const u8) !void {
+    for (text, 0..) |c, i| switch (c) {
+        '&' => try writer.writeAll("&amp;"),
+        '<' => try writer.writeAll("&lt;"),
+        '>' => try writer.writeAll("&gt;"),
+        else => try output.writeJsonEscaped(writer, text[i .. i + 1]),
+    };
+}
+
+/// Send `digest` to every channel when its drift exceeds the threshold on a
+/// watched branch. Returns whether it was sent.
+pub fn notifyDrift(allocator: std.mem.Allocator, notifiers: Notifiers, digest: Digest, tool_version: []const u8, verbose: bool) bool {
+    if (!notifiers.enabled() or digest.drift() <= notifiers.threshold) return false;
+    if (!notifiers.watches(digest.branch)) {
+        if (verbose) cli_error.printInfo("Drift of {d} not reported: branch {s} is not watched", .{ digest.drift(), digest.branch orelse "(detached)" });
+        return false;
+    }
+
+    inline for (.{ Channel.slack, Channel.teams, Channel.webhook }) |channel| {
+        const urls = switch (channel) {
+            .slack => notifiers.slack,
+            .teams => notifiers.teams,
+            .webhook => notifiers.webhooks,
+        };
+        if (urls.len > 0) {
+            const payload = switch (channel) {
+                .slack => formatSlack(allocator, digest),
+                .teams => formatTeams(allocator, digest),
+                .webhook => formatWebhook(allocator, digest, tool_version, std.time.timestamp()),
+            } catch |err| {
+                cli_error.printWarning("Could not format {s} drift notification: {s}", .{ @tagName(channel), @errorName(err) });
+                return false;
+            };
+            defer allocator.free(payload);
+            for (urls) |url| deliver(allocator, channel, url, payload, notifiers.secret, verbose);
+        }
+    }
+    return true;
+}
+
+fn deliver(allocator: std.mem.Allocator, channel: Channel, url: []const u8, payload: []const u8, secret: ?[]const u8, verbose: bool) void {
+    // Slack and Teams ignore the event and signature headers
+    const status = webhook.post(allocator, url, .drift, payload, if (channel == .webhook) secret else null) catch |err| {
+        cli_error.printWarning("Drift notification to {s} failed: {s}", .{ url, @errorName(err) });
+        return;
+    };
+    if (status < 200 or status >= 300) {
+        cli_error.printWarning("Drift notification to {s} answered HTTP {d}", .{ url, status });
+    } else if (verbose) {
+        cli_error.printInfo("Sent drift digest to {s} ({s})", .{ url, @tagName(channel) });
+    }
+}
+
+test "drift digests for slack, teams, and webhooks" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const added = [_]constraint.Constraint{
+        .{ .name = "Validate <input> & escape", .description = "", .kind = .security, .severity = .warning, .origin_file = "src/api.go", .origin_line = 12 },
+        .{ .name = "Non-null id", .description = "", .kind = .type_safety, .severity = .info },
+    };
+    const digest = Digest{ .target = "src", .baseline = ".ananke-baseline.json", .branch = "main", .commit = "abc1234", .threshold = 1, .added = &added, .removed = 3 };
+    try testing.expectEqual(@as(usize, 5), digest.drift());
+
+    const slack = try formatSlack(allocator, digest);
+    defer allocator.free(slack);
+    const slack_json = try std.json.parseFromSlice(std.json.Value, allocator, slack, .{});
+    defer slack_json.deinit();
+    const blocks = slack_json.value.object.get("blocks").?.array.items;
+    try testing.expectEqualStrings("Constraint drift on main", blocks[0].object.get("text").?.object.get("text").?.string);
+    const listed = blocks[2].object.get("text").?.object.get("text").?.string;
+    try testing.expect(std.mem.indexOf(u8, listed, "`src/api.go:12` security: Validate &lt;input&gt; &amp; escape") != null);
+
+    const teams = try formatTeams(allocator, digest);
+    defer allocator.free(teams);
+    const teams_json = try std.json.parseFromSlice(std.json.Value, allocator, teams, .{});
+    defer teams_json.deinit();
+    const facts = teams_json.value.object.get("sections").?.array.items[0].object.get("facts").?.array.items;
+    try testing.expectEqualStrings("3", facts[2].object.get("value").?.string);
+
+    const payload = try formatWebhook(allocator, digest, "0.2.1", 1700000000);
+    defer allocator.free(payload);
+    const payload_json = try std.json.parseFromSlice(std.json.Value, allocator, payload, .{});
+    defer payload_json.deinit();
+    try testing.expectEqualStrings("drift.detected", payload_json.value.object.get("event").?.string);
+    const summary = payload_json.value.object.get("summary").?.object;
+    try testing.expectEqual(@as(i64, 2), summary.get("added").?.integer);
+    try testing.expect(summary.get("constraints").?.array.items[1].object.get("file").? == .null);
+
+    const notifiers = Notifiers{ .slack = &.{"https://hooks.slack.com/services/T/B/X"}, .branches = &.{ "main", "release/*" } };
+    try testing.expect(notifiers.watches("release/2.0"));
+    try testing.expect(!notifiers.watches("feature/x"));
+    try testing.expect(!notifiers.watches(null));
+}
+
+test "redacted digests leave string literals out of every payload" {
+    const testing = std.testing;
+    var arena = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena.deinit();
+    const allocator = arena.allocator();
+
+    const added = [_]constraint.Constraint{
+        .{ .name = "Compare token to \"sk_live_51H8\"", .description = "if key == \"sk_live_51H8\"", .kind = .security, .severity = .err, .origin_file = "src/auth.go", .origin_line = 7 },
+    };
+    const digest = try (Digest{ .target = "src", .baseline = ".ananke-baseline.json", .threshold = 0, .added = &added, .removed = 0 }).redact(allocator);
+    try testing.expectEqualStrings("Compare token to \"***\"", digest.added[0].name);
+    try testing.expectEqualStrings("Compare token to \"sk_live_51H8\"", added[0].name);
+
+    const slack = try formatSlack(allocator, digest);
+    try testing.expect(std.mem.indexOf(u8, slack, "sk_live_51H8") == null);
+    const teams = try formatTeams(allocator, digest);
+    try testing.expect(std.mem.indexOf(u8, teams, "sk_live_51H8") == null);
+    const payload = try formatWebhook(allocator, digest, "0.2.1", 1700000000);
+    try testing.expect(std.mem.indexOf(u8, payload, "sk_live_51H8") == null);
+    try testing.expect(std.mem.indexOf(u8, payload, "***") != null);
+}
@@ -1,6 +1,9 @@
 // Output formatting utilities for Ananke CLI
 const std = @import("std");
 const ananke = @import("ananke");
+const profiling = @import("cli_profiling");
+const blame = @import("cli_blame");
+const summarize = @import("cli_summarize");
 const constraint = ananke.types.constraint;
 
 pub const OutputFormat = enum {
@@ -8,14 +11,53 @@ pub const OutputFormat = enum {
     yaml,
     pretty,
     ariadne,
+    stats,
+    stats_json,
+    prompt_pack,
+    cyclonedx,
+    patch,
+    markdown,
+    html,
+    codequality,
+    sonarqube,
+    techdocs,
+    sidecar,
+    requirements,
 
     pub fn fromString(s: []const u8) ?OutputFormat {
         if (std.mem.eql(u8, s, "json")) return .json;
         if (std.mem.eql(u8, s, "yaml")) return .yaml;
         if (std.mem.eql(u8, s, "pretty")) return .pretty;
         if (std.mem.eql(u8, s, "ariadne")) return .ariadne;
+        if (std.mem.eql(u8, s, "stats")) return .stats;
+        if (std.mem.eql(u8, s, "stats-json")) return .stats_json;
+        if (std.mem.eql(u8, s, "prompt-pack")) return .prompt_pack;
+        if (std.mem.eql(u8, s, "cyclonedx")) return .cyclonedx;
+        if (std.mem.eql(u8, s, "patch")) return .patch;
+        if (std.mem.eql(u8, s, "markdown")) return .markdown;
+        if (std.mem.eql(u8, s, "html")) return .html;
+        if (std.mem.eql(u8, s, "codequality")) return .codequality;
+        if (std.mem.eql(u8, s, "sonarqube")) return .sonarqube;
+        if (std.mem.eql(u8, s, "techdocs")) return .techdocs;
+        if (std.mem.eql(u8, s, "sidecar")) return .sidecar;
+        if (std.mem.eql(u8, s, "requirements")) return .requirements;
         return null;
     }
+
+    /// File extension used when output is written per target
+    pub fn extension(self: OutputFormat) []const u8 {
+        return switch (self) {
+            .json, .stats_json, .codequality, .sonarqube => "json",
+            .yaml => "yaml",
+            .pretty, .stats => "txt",
+            .ariadne => "ariadne",
+            .prompt_pack, .markdown, .requirements => "md",
+            .cyclonedx => "cdx.json",
+            .patch => "patch",
+            .html => "html",
+            .techdocs, .sidecar => "",
+        };
+    }
 };
 
 pub const Color = enum {
@@ -59,6 +101,26 @@ pub fn colorize(comptime color: Color, text: []const u8, allocator: std.mem.Allo
 pub fn formatJson(
     allocator: std.mem.Allocator,
     constraint_set: constraint.ConstraintSet,
+) ![]u8 {
+    return formatJsonWith(allocator, constraint_set, .{});
+}
+
+/// Optional additions to the JSON output
+pub const JsonExtras = struct {
+    /// Stages that produced the constraints, written as a `timings` array
+    timings: []const profiling.Stage = &.{},
+    /// Last change to each constraint's line, parallel to the constraints;
+    /// written as a `blame` object when set
+    authorship: []const ?blame.Authorship = &.{},
+    /// Per-package LLM summaries, written as a `summaries` array
+    summaries: []const summarize.Summary = &.{},
+};
+
+/// Format constraints as JSON with the given extras
+pub fn formatJsonWith(
+    allocator: std.mem.Allocator,
+    constraint_set: constraint.ConstraintSet,
+    extras: JsonExtras,
 ) ![]u8 {
     var list = std.ArrayList(u8){};
     errdefer list.deinit(allocator);
@@ -80,10 +142,32 @@ pub fn formatJson(
         try writeJsonEscaped(writer, c.description);
         try writer.writeAll("\",\n");
         try writer.print("      \"source\": \"{s}\",\n", .{@tagName(c.source)});
+        try writer.print("      \"enforcement\": \"{s}\",\n", .{@tagName(c.enforcement)});
         try writer.print("      \"priority\": \"{s}\",\n", .{@tagName(c.priority)});
         try writer.print("      \"confidence\": {d:.2},\n", .{c.confidence});
-        try writer.print("      \"frequency\": {d}\n", .{c.frequency});
-        try writer.writeAll("    }");
+        if (c.origin_file) |file| {
+            try writer.writeAll("      \"file\": \"");
+            try writeJsonEscaped(writer, file);
+            try writer.writeAll("\",\n");
+        }
+        if (c.origin_line) |line| {
+            try writer.print("      \"line\": {d},\n", .{line});
+        }
+        try writer.print("      \"frequency\": {d}", .{c.frequency});
+        if (i < extras.authorship.len) {
+            if (extras.authorship[i]) |a| {
+                try writer.writeAll(",\n      \"blame\": {\"commit\": \"");
+                try writeJsonEscaped(writer, a.commit);
+                try writer.writeAll("\", \"author\": \"");
+                try writeJsonEscaped(writer, a.author);
+                try writer.writeAll("\", \"email\": \"");
+                try writeJsonEscaped(writer, a.email);
+                try writer.print("\", \"time\": {d}, \"date\": \"", .{a.time});
+                try a.writeDate(writer);
+                try writer.writeAll("\"}");
+            }
+        }
+        try writer.writeAll("\n    }");
         // Safe check: use addition instead of subtraction to avoid underflow
         if (i + 1 < constraint_set.constraints.items.len) {
             try writer.writeAll(",");
@@ -91,8 +175,29 @@ pub fn formatJson(
         try writer.writeAll("\n");
     }
 
-    try writer.writeAll("  ]\n");
-    try writer.writeAll("}\n");
+    try writer.writeAll("  ]");
+    if (extras.summaries.len > 0) {
+        try writer.writeAll(",\n  \"summaries\": [\n");
+        for (extras.summaries, 0..) |summary, i| {
+            try writer.writeAll("    {\"package\": \"");
+            try writeJsonEscaped(writer, summary.package);
+            try writer.print("\", \"constraints\": {d}, \"summary\": \"", .{summary.constraints});
+            try writeJsonEscaped(writer, summary.summary);
+            try writer.writeAll("\", \"hints\": [");
+            for (summary.hints, 0..) |hint, j| {
+                try writer.writeAll(if (j == 0) "\"" else ", \"");
+                try writeJsonEscaped(writer, hint);
+                try writer.writeAll("\"");
+            }
+            try writer.writeAll(if (i + 1 < extras.summaries.len) "]},\n" else "]}\n");
+        }
+        try writer.writeAll("  ]");
+    }
+    if (extras.timings.len > 0) {
+        try writer.writeAll(",\n  \"timings\": ");
+        try profiling.writeStagesJson(writer, extras.timings);
+    }
+    try writer.writeAll("\n}\n");
 
     return list.toOwnedSlice(allocator);
 }
@@ -349,7 +454,7 @@ pub const Spinner = struct {
 
 /// Write a JSON-escaped string to the writer
 /// Escapes quotes, backslashes, newlines, tabs, carriage returns, and control characters
-fn writeJsonEscaped(writer: anytype, s: []const u8) !void {
+pub fn writeJsonEscaped(writer: anytype, s: []const u8) !void {
     const hex_digits = "0123456789abcdef";
     for (s) |c| {
         switch (c) {
@@ -369,6 +474,78 @@ fn writeJsonEscaped(writer: anytype, s: []const u8) !void {
     }
 }
 
+/// Placeholder written in place of masked string literal contents
+pub const redacted_marker = "***";
+
+/// Mask string literals and strip code snippets from free text.
+/// Quoted content ("...", '...', `...`) is replaced with the redaction marker,
+/// and fenced code blocks are dropped entirely.
+pub fn redactText(allocator: std.mem.Allocator, text: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+
+    var i: usize = 0;
+    while (i < text.len) {
+        // Fenced code blocks carry verbatim source, drop them wholesale
+        if (std.mem.startsWith(u8, text[i..], "```")) {
+            const end = std.mem.indexOfPos(u8, text, i + 3, "```") orelse text.len;
+            try list.appendSlice(allocator, "[snippet redacted]");
+            i = @min(end + 3, text.len);
+            continue;
+        }
+
+        const c = text[i];
+        const is_quote = c == '"' or c == '`' or
+            // Single quotes only open a literal at a word boundary (skip apostrophes)
+            (c == '\'' and (i == 0 or !std.ascii.isAlphanumeric(text[i - 1])));
+
+        if (is_quote) {
+            if (findClosingQuote(text, i + 1, c)) |close| {
+                try list.append(allocator, c);
+                try list.appendSlice(allocator, redacted_marker);
+                try list.append(allocator, c);
+                i = close + 1;
+                continue;
+            }
+        }
+
+        try list.append(allocator, c);
+        i += 1;
+    }
+
+    return list.toOwnedSlice(allocator);
+}
+
+/// Find the index of the closing quote, honoring backslash escapes.
+/// Double and single quoted literals must close on the same line.
+fn findClosingQuote(text: []const u8, start: usize, quote: u8) ?usize {
+    var i = start;
+    while (i < text.len) : (i += 1) {
+        const c = text[i];
+        if (c == '\\' and quote != '`') {
+            i += 1;
+            continue;
+        }
+        if (c == '\n' and quote != '`') return null;
+        if (c == quote) return i;
+    }
+    return null;
+}
+
+/// Redact every constraint in the set in place.
+/// Replacement strings are allocated with `allocator` (typically an arena owned by
+/// the caller); constraint IDs are left untouched so redacted output stays joinable
+/// with unredacted runs.
+pub fn redactConstraintSet(
+    allocator: std.mem.Allocator,
+    constraint_set: *constraint.ConstraintSet,
+) !void {
+    for (constraint_set.constraints.items) |*c| {
+        c.name = try redactText(allocator, c.name);
+        c.description = try redactText(allocator, c.description);
+    }
+}
+
 /// Print a table of constraints
 pub fn printTable(
     constraint_set: constraint.ConstraintSet,
@@ -392,3 +569,16 @@ pub fn printTable(
     // Footer
     try stdout.writeAll("└────────────────┴──────────┴────────────────────────────────────────┘\n");
 }
+
+test "stats formats parse and JSON strings are escaped" {
+    const testing = std.testing;
+
+    try testing.expectEqual(OutputFormat.stats, OutputFormat.fromString("stats").?);
+    try testing.expectEqual(OutputFormat.stats_json, OutputFormat.fromString("stats-json").?);
+    try testing.expect(OutputFormat.fromString("stats_json") == null);
+
+    var buf = std.ArrayList(u8){};
+    defer buf.deinit(testing.allocator);
+    try writeJsonEscaped(buf.writer(testing.allocator), "say \"hi\"\n\\\x01");
+    try testing.expectEqualStrings("say \\\"hi\\\"\\n\\\\\\u0001", buf.items);
+}
@@ -0,0 +1,186 @@
+// Per-package constraint docs
+// Renders the constraints of an extracted model as one CONSTRAINTS.md per
+// package (a file's directory), to be committed next to the code. The text
+// depends only on the constraints, in a fixed order and without line numbers
+// or timestamps, so regenerating an unchanged model reproduces it byte for
+// byte and a byte comparison tells whether a committed doc is stale.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const messages = @import("cli_messages");
+const report = @import("cli_report");
+const summary = @import("cli_summary");
+
+pub const file_name = "CONSTRAINTS.md";
+/// Start of the first line of every generated doc, used to tell our docs
+/// from hand-written ones
+pub const generated_marker = "<!-- Generated by ananke constraints-doc";
+
+pub const Package = struct {
+    /// Directory of the package's files; "." for files at the root
+    name: []const u8,
+    /// Sorted by file, then severity, kind, and name
+    constraints: []const constraint.Constraint,
+};
+
+fn trimDot(path: []const u8) []const u8 {
+    var rel = path;
+    while (std.mem.startsWith(u8, rel, "./")) rel = rel[2..];
+    return rel;
+}
+
+fn lessThanConstraint(_: void, a: constraint.Constraint, b: constraint.Constraint) bool {
+    const order = std.mem.order(u8, a.origin_file.?, b.origin_file.?);
+    if (order != .eq) return order == .lt;
+    if (a.severity != b.severity) return @intFromEnum(a.severity) < @intFromEnum(b.severity);
+    if (a.kind != b.kind) return @intFromEnum(a.kind) < @intFromEnum(b.kind);
+    const by_name = std.mem.order(u8, a.name, b.name);
+    if (by_name != .eq) return by_name == .lt;
+    return std.mem.lessThan(u8, a.description, b.description);
+}
+
+fn lessThanPackage(_: void, a: Package, b: Package) bool {
+    return std.mem.lessThan(u8, a.name, b.name);
+}
+
+/// Constraints grouped by package, sorted by name. Constraints without an
+/// origin file belong to no package and are left out. Everything lives in
+/// `arena` or borrows `constraints`.
+pub fn group(arena: std.mem.Allocator, constraints: []const constraint.Constraint) ![]Package {
+    var index = std.StringArrayHashMap(std.ArrayList(constraint.Constraint)).init(arena);
+    for (constraints) |c| {
+        const file = trimDot(c.origin_file orelse continue);
+        var copy = c;
+        copy.origin_file = file;
+        const gop = try index.getOrPut(summary.packageOf(file));
+        if (!gop.found_existing) gop.value_ptr.* = std.ArrayList(constraint.Constraint){};
+        try gop.value_ptr.append(arena, copy);
+    }
+
+    const packages = try arena.alloc(Package, index.count());
+    for (index.keys(), index.values(), packages) |name, *list, *pkg| {
+        std.mem.sort(constraint.Constraint, list.items, {}, lessThanConstraint);
+        pkg.* = .{ .name = name, .constraints = list.items };
+    }
+    std.mem.sort(Package, packages, {}, lessThanPackage);
+    return packages;
+}
+
+/// Path of the doc of `package` under `root`
+pub fn docPath(allocator: std.mem.Allocator, root: []const u8, package: []const u8) ![]u8 {
+    return std.fs.path.join(allocator, &.{ root, package, file_name });
+}
+
+/// The CONSTRAINTS.md of a package
+pub fn format(allocator: std.mem.Allocator, pkg: Package, catalog: *const messages.Catalog) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    const items = pkg.constraints;
+
+    try writer.print("{s}; DO NOT EDIT. Regenerate with `ananke constraints-doc`. -->\n\n", .{generated_marker});
+    try writer.print("# {s}: `{s}`\n\n", .{ catalog.get(.report_title), pkg.name });
+    try writer.print("**{s}:** {d}\n\n", .{ catalog.get(.total_constraints), items.len });
+
+    // Counts in enum order, so the tables do not reorder as counts change
+    var severities = std.EnumArray(constraint.Severity, usize).initFill(0);
+    var kinds = std.EnumArray(constraint.ConstraintKind, usize).initFill(0);
+    for (items) |c| {
+        severities.getPtr(c.severity).* += 1;
+        kinds.getPtr(c.kind).* += 1;
+    }
+    try writer.print("## {s}\n\n| {s} | {s} |\n|---|---:|\n", .{ catalog.get(.section_summary), catalog.get(.col_severity), catalog.get(.col_count) });
+    var severity_it = severities.iterator();
+    while (severity_it.next()) |entry| {
+        if (entry.value.* > 0) try writer.print("| {s} | {d} |\n", .{ catalog.severity(entry.key), entry.value.* });
+    }
+    try writer.print("\n| {s} | {s} |\n|---|---:|\n", .{ catalog.get(.col_kind), catalog.get(.col_count) });
+    var kind_it = kinds.iterator();
+    while (kind_it.next()) |entry| {
+        if (entry.value.* > 0) try writer.print("| {s} | {d} |\n", .{ catalog.kind(entry.key), entry.value.* });
+    }
+
+    try writer.print("\n## {s}\n", .{catalog.get(.section_constraints)});
+    var current: ?[]const u8 = null;
+    for (items) |c| {
+        const file = c.origin_file.?;
+        if (current == null or !std.mem.eql(u8, current.?, file)) {
+            current = file;
+            try writer.print("\n### `{s}`\n\n", .{std.fs.path.basename(file)});
+            try writer.print("| {s} | {s} | {s} | {s} |\n|---|---|---|---|\n", .{
+                catalog.get(.col_severity),
+                catalog.get(.col_kind),
+                catalog.get(.col_name),
+                catalog.get(.col_description),
+            });
+        }
+        try writer.print("| {s} | {s} | ", .{ catalog.severity(c.severity), catalog.kind(c.kind) });
+        try report.writeMarkdownCell(writer, c.name);
+        try writer.writeAll(" | ");
+        try report.writeMarkdownCell(writer, c.description);
+        try writer.writeAll(" |\n");
+    }
+
+    try writer.print("\n---\n_{s}_\n", .{catalog.get(.generated_by)});
+    return list.toOwnedSlice(allocator);
+}
+
+test "group constraints by package and render a stable doc" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const constraints = [_]constraint.Constraint{
+        .{ .name = "error_check", .description = "Errors are checked", .kind = .semantic, .severity = .warning, .origin_file = "./api/users.go", .origin_line = 30 },
+        .{ .name = "no_plaintext", .description = "Passwords are hashed", .kind = .security, .severity = .err, .origin_file = "api/users.go", .origin_line = 12 },
+        .{ .name = "main_entry", .description = "Single entry point", .kind = .architectural, .severity = .info, .origin_file = "main.go" },
+        .{ .name = "unplaced", .description = "No file", .kind = .syntactic, .severity = .hint },
+    };
+    const packages = try group(arena, &constraints);
+    try testing.expectEqual(@as(usize, 2), packages.len);
+    try testing.expectEqualStrings(".", packages[0].name);
+    try testing.expectEqualStrings("api", packages[1].name);
+    try testing.expectEqualStrings("no_plaintext", packages[1].constraints[0].name);
+
+    var catalog = messages.Catalog.default();
+    defer catalog.deinit();
+    const text = try format(arena, packages[1], &catalog);
+    try testing.expectEqualStrings(
+        \\<!-- Generated by ananke constraints-doc; DO NOT EDIT. Regenerate with `ananke constraints-doc`. -->
+        \\
+        \\# Constraint Report: `api`
+        \\
+        \\**Total constraints:** 2
+        \\
+        \\## Summary
+        \\
+        \\| Severity | Count |
+        \\|---|---:|
+        \\| error | 1 |
+        \\| warning | 1 |
+        \\
+        \\| Category | Count |
+        \\|---|---:|
+        \\| semantic | 1 |
+        \\| security | 1 |
+        \\
+        \\## Constraints
+        \\
+        \\### `users.go`
+        \\
+        \\| Severity | Category | Name | Description |
+        \\|---|---|---|---|
+        \\| error | security | no_plaintext | Passwords are hashed |
+        \\| warning | semantic | error_check | Errors are checked |
+        \\
+        \\---
+        \\_Generated by Ananke_
+        \\
+    , text);
+
+    // Line moves do not change the doc
+    var moved = constraints;
+    moved[0].origin_line = 31;
+    try testing.expectEqualStrings(text, try format(arena, (try group(arena, &moved))[1], &catalog));
+}
@@ -0,0 +1,197 @@
+// Extraction plans for --dry-run
+// Describes which files an extraction would analyze and with which extractor,
+// and which paths discovery skipped and why, without reading any source. Used
+// to debug exclude patterns, .gitignore rules, and --lang filters.
+const std = @import("std");
+const ananke = @import("ananke");
+const discovery = @import("cli_discovery");
+
+const patterns = ananke.clew.patterns;
+const tree_sitter = ananke.clew.tree_sitter;
+
+/// Paths longer than this are not used to align the skip reasons
+const max_path_column = 48;
+
+/// Number of Clew pattern rules registered for a language
+pub fn ruleCount(language: []const u8) usize {
+    var rules: usize = 0;
+    if (patterns.getPatternsForLanguage(language)) |lang_patterns| {
+        inline for (std.meta.fields(patterns.LanguagePatterns)) |field| {
+            rules += @field(lang_patterns, field.name).len;
+        }
+    }
+    return rules;
+}
+
+/// Short digest of a language's Clew pattern rules, identifying the rule set
+/// version independently of the tool version
+pub fn rulesDigest(language: []const u8) [16]u8 {
+    var hasher = std.crypto.hash.sha2.Sha256.init(.{});
+    if (patterns.getPatternsForLanguage(language)) |lang_patterns| {
+        inline for (std.meta.fields(patterns.LanguagePatterns)) |field| {
+            for (@field(lang_patterns, field.name)) |rule| {
+                hasher.update(field.name);
+                hasher.update(rule.pattern);
+                hasher.update(@tagName(rule.constraint_kind));
+                hasher.update(rule.description);
+                hasher.update(&.{0});
+            }
+        }
+    }
+    return std.fmt.bytesToHex(hasher.finalResult()[0..8].*, .lower);
+}
+
+/// Describe the extractor that handles `language`
+pub fn writeExtractor(writer: anytype, language: []const u8) !void {
+    const rules = ruleCount(language);
+    if (std.meta.stringToEnum(tree_sitter.Language, language) != null) {
+        try writer.print("tree-sitter grammar + {d} pattern rules", .{rules});
+    } else if (rules > 0) {
+        try writer.print("{d} pattern rules (no tree-sitter grammar)", .{rules});
+    } else {
+        try writer.writeAll("no extractor; file will yield no constraints");
+    }
+}
+
+/// Explain why discovery skipped a path. `root` is the walk root, used to
+/// locate the .gitignore a rule came from; `language_filter` is --lang.
+pub fn writeSkipReason(
+    writer: anytype,
+    skipped: discovery.SkippedPath,
+    root: []const u8,
+    language_filter: ?[]const u8,
+) !void {
+    switch (skipped.reason) {
+        .excluded => {
+            const pattern = skipped.pattern orelse return writer.writeAll("excluded");
+            if (isDefaultExclude(pattern.text)) {
+                try writer.print("excluded by default pattern \"{s}\" (--no-default-excludes to include)", .{pattern.text});
+            } else {
+                try writer.print("excluded by pattern \"{s}\"", .{pattern.text});
+            }
+        },
+        .gitignored => {
+            const pattern = skipped.pattern orelse return writer.writeAll("ignored by .gitignore");
+            if (pattern.origin) |origin| return writer.print("ignored by {s} rule \"{s}\"", .{ origin, pattern.text });
+            const dir = std.mem.trimRight(u8, root, "/");
+            try writer.writeAll("ignored by ");
+            if (dir.len > 0 and !std.mem.eql(u8, dir, ".")) try writer.print("{s}/", .{dir});
+            if (pattern.base.len > 0) try writer.print("{s}/", .{pattern.base});
+            try writer.print(".gitignore rule \"{s}\"", .{pattern.text});
+        },
+        .unsupported_language => {
+            const detected = discovery.detectLanguage(skipped.path);
+            if (language_filter) |only| {
+                if (!std.mem.eql(u8, detected, "unknown")) {
+                    return writer.print("{s} file filtered out by --lang {s}", .{ detected, only });
+                }
+            }
+            try writer.writeAll("unsupported file type");
+        },
+        .symlink => try writer.writeAll("symbolic link (not followed)"),
+    }
+}
+
+fn isDefaultExclude(text: []const u8) bool {
+    for (discovery.default_excludes) |pattern| {
+        if (std.mem.eql(u8, pattern, text)) return true;
+    }
+    return false;
+}
+
+fn lessThanString(_: void, a: []const u8, b: []const u8) bool {
+    return std.mem.lessThan(u8, a, b);
+}
+
+/// Render the plan: files to analyze grouped by language with their
+/// extractor, then every skipped path with its reason
+pub fn formatPlan(
+    allocator: std.mem.Allocator,
+    set: *const discovery.FileSet,
+    root: []const u8,
+    language_filter: ?[]const u8,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("Dry run: {d} files would be analyzed, {d} paths skipped\n", .{
+        set.files.items.len,
+        set.skipped.items.len,
+    });
+
+    // Languages in name order; files within a language stay in path order
+    var languages = std.ArrayList([]const u8){};
+    defer languages.deinit(allocator);
+    for (set.files.items) |file| {
+        for (languages.items) |seen| {
+            if (std.mem.eql(u8, seen, file.language)) break;
+        } else try languages.append(allocator, file.language);
+    }
+    std.mem.sort([]const u8, languages.items, {}, lessThanString);
+
+    for (languages.items) |language| {
+        var count: usize = 0;
+        for (set.files.items) |file| {
+            if (std.mem.eql(u8, file.language, language)) count += 1;
+        }
+        try writer.print("\n{s} ({d} files): ", .{ language, count });
+        try writeExtractor(writer, language);
+        try writer.writeAll("\n");
+        for (set.files.items) |file| {
+            if (std.mem.eql(u8, file.language, language)) try writer.print("  {s}\n", .{file.path});
+        }
+    }
+
+    if (set.skipped.items.len > 0) {
+        var width: usize = 0;
+        for (set.skipped.items) |skipped| {
+            const len = skipped.path.len + @intFromBool(skipped.is_dir);
+            if (len <= max_path_column) width = @max(width, len);
+        }
+
+        try writer.print("\nSkipped ({d} paths)\n", .{set.skipped.items.len});
+        for (set.skipped.items) |skipped| {
+            const suffix: []const u8 = if (skipped.is_dir) "/" else "";
+            const len = skipped.path.len + suffix.len;
+            try writer.print("  {s}{s}", .{ skipped.path, suffix });
+            try writer.writeByteNTimes(' ', if (len < width) width - len + 2 else 2);
+            try writeSkipReason(writer, skipped, root, language_filter);
+            try writer.writeAll("\n");
+        }
+    }
+
+    return list.toOwnedSlice(allocator);
+}
+
+test "plan lists extractors and skip reasons" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var set = discovery.FileSet.init(allocator);
+    defer set.deinit();
+    try set.addFile("src/main.go", "go");
+    try set.addFile("src/util.go", "go");
+    try set.skipped.append(allocator, .{
+        .path = "vendor",
+        .is_dir = true,
+        .reason = .excluded,
+        .pattern = discovery.Pattern.parse("", "vendor/"),
+    });
+    try set.skipped.append(allocator, .{
+        .path = "gen/api.go",
+        .is_dir = false,
+        .reason = .gitignored,
+        .pattern = discovery.Pattern.parse("", "gen/"),
+    });
+    try set.skipped.append(allocator, .{ .path = "app.py", .is_dir = false, .reason = .unsupported_language });
+
+    const text = try formatPlan(allocator, &set, ".", "go");
+    defer allocator.free(text);
+
+    try testing.expect(std.mem.startsWith(u8, text, "Dry run: 2 files would be analyzed, 3 paths skipped"));
+    try testing.expect(std.mem.indexOf(u8, text, "go (2 files): tree-sitter grammar") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "excluded by default pattern \"vendor/\"") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "ignored by .gitignore rule \"gen/\"") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "python file filtered out by --lang go") != null);
+}
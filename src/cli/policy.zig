@@ -0,0 +1,227 @@
+// Enforcement policy
+// Budgets cap how many constraint changes of a kind a change set may carry,
+// e.g. no removed error-severity security constraints and at most five
+// weakened constraints per pull request. A budget is written
+// `<change>[:<kind>[:<severity>]]=<max>`, where <change> is added, removed,
+// strengthened, or weakened, and `*` matches any kind.
+// Coverage thresholds set the least share of constraints a package must have
+// linked to tests, written `[<package>=]<percent>`; without a package (or
+// with `*`) the threshold applies to the whole run.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const constraint_diff = @import("cli_constraint_diff");
+
+pub const PolicyError = error{ InvalidBudget, InvalidThreshold };
+
+pub const Budget = struct {
+    /// The budget as written, for reports
+    text: []const u8,
+    change: constraint_diff.ChangeKind,
+    kind: ?constraint.ConstraintKind = null,
+    severity: ?constraint.Severity = null,
+    max: usize,
+
+    /// Whether `change` counts against this budget. Removed and weakened
+    /// constraints are judged by what they were, the others by what they are.
+    pub fn matches(self: Budget, change: constraint_diff.Change) bool {
+        if (change.kind != self.change) return false;
+        const c = switch (change.kind) {
+            .removed, .weakened => change.before.?,
+            .added, .strengthened => change.after.?,
+        };
+        if (self.kind) |kind| {
+            if (c.kind != kind) return false;
+        }
+        if (self.severity) |severity| {
+            if (c.severity != severity) return false;
+        }
+        return true;
+    }
+};
+
+fn parseSeverity(text: []const u8) ?constraint.Severity {
+    if (std.mem.eql(u8, text, "error")) return .err;
+    return std.meta.stringToEnum(constraint.Severity, text);
+}
+
+/// Parse one budget; `text` is borrowed
+pub fn parseBudget(text: []const u8) PolicyError!Budget {
+    const eq = std.mem.indexOfScalar(u8, text, '=') orelse return error.InvalidBudget;
+    const max = std.fmt.parseInt(usize, std.mem.trim(u8, text[eq + 1 ..], " "), 10) catch return error.InvalidBudget;
+
+    var fields = std.mem.splitScalar(u8, std.mem.trim(u8, text[0..eq], " "), ':');
+    const change = std.meta.stringToEnum(constraint_diff.ChangeKind, fields.first()) orelse return error.InvalidBudget;
+    var budget = Budget{ .text = std.mem.trim(u8, text, " "), .change = change, .max = max };
+    if (fields.next()) |kind| {
+        if (!std.mem.eql(u8, kind, "*")) {
+            budget.kind = std.meta.stringToEnum(constraint.ConstraintKind, kind) orelse return error.InvalidBudget;
+        }
+    }
+    if (fields.next()) |severity| {
+        if (!std.mem.eql(u8, severity, "*")) {
+            budget.severity = parseSeverity(severity) orelse return error.InvalidBudget;
+        }
+    }
+    if (fields.next() != null) return error.InvalidBudget;
+    return budget;
+}
+
+pub const CoverageThreshold = struct {
+    /// The threshold as written, for reports
+    text: []const u8,
+    /// Package directory; null for the whole run
+    package: ?[]const u8 = null,
+    /// Least percentage of constraints linked to a test
+    min: f64,
+};
+
+/// Parse one coverage threshold; `text` is borrowed
+pub fn parseCoverageThreshold(text: []const u8) PolicyError!CoverageThreshold {
+    const trimmed = std.mem.trim(u8, text, " ");
+    var threshold = CoverageThreshold{ .text = trimmed, .min = 0 };
+    var percent = trimmed;
+    if (std.mem.lastIndexOfScalar(u8, trimmed, '=')) |eq| {
+        const package = std.mem.trim(u8, trimmed[0..eq], " ");
+        if (package.len == 0) return error.InvalidThreshold;
+        if (!std.mem.eql(u8, package, "*")) threshold.package = package;
+        percent = std.mem.trim(u8, trimmed[eq + 1 ..], " ");
+    }
+    percent = std.mem.trimRight(u8, percent, "%");
+    threshold.min = std.fmt.parseFloat(f64, percent) catch return error.InvalidThreshold;
+    if (threshold.min < 0 or threshold.min > 100) return error.InvalidThreshold;
+    return threshold;
+}
+
+pub const Outcome = struct {
+    budget: Budget,
+    used: usize = 0,
+    /// The changes counted against the budget
+    changes: []const constraint_diff.Change = &.{},
+
+    pub fn exceeded(self: Outcome) bool {
+        return self.used > self.budget.max;
+    }
+};
+
+/// Count `changes` against each budget. Outcomes live in `arena` and borrow
+/// the changes.
+pub fn evaluate(arena: std.mem.Allocator, budgets: []const Budget, changes: []const constraint_diff.Change) ![]Outcome {
+    const outcomes = try arena.alloc(Outcome, budgets.len);
+    for (budgets, outcomes) |budget, *outcome| {
+        var counted = std.ArrayList(constraint_diff.Change){};
+        for (changes) |change| {
+            if (budget.matches(change)) try counted.append(arena, change);
+        }
+        outcome.* = .{ .budget = budget, .used = counted.items.len, .changes = counted.items };
+    }
+    return outcomes;
+}
+
+pub fn countExceeded(outcomes: []const Outcome) usize {
+    var n: usize = 0;
+    for (outcomes) |outcome| {
+        if (outcome.exceeded()) n += 1;
+    }
+    return n;
+}
+
+/// One line per budget; over-budget ones list the changes that exceed it
+pub fn formatText(allocator: std.mem.Allocator, outcomes: []const Outcome) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    for (outcomes) |outcome| {
+        try writer.print("{s}  {s}  {d}/{d}\n", .{
+            if (outcome.exceeded()) "FAIL" else "ok  ",
+            outcome.budget.text,
+            outcome.used,
+            outcome.budget.max,
+        });
+        if (!outcome.exceeded()) continue;
+        for (outcome.changes) |change| {
+            const c = change.subject();
+            try writer.print("        {s} {s} ({s})", .{ change.kind.label(), c.name, @tagName(c.kind) });
+            if (c.origin_file) |file| try writer.print(" {s}", .{file});
+            if (c.origin_line) |line| try writer.print(":{d}", .{line});
+            try writer.writeAll("\n");
+        }
+    }
+    try writer.print("\n{d} of {d} budgets exceeded\n", .{ countExceeded(outcomes), outcomes.len });
+    return list.toOwnedSlice(allocator);
+}
+
+pub fn formatJson(allocator: std.mem.Allocator, outcomes: []const Outcome, before_label: []const u8, after_label: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("{\n  \"before\": \"");
+    try output.writeJsonEscaped(writer, before_label);
+    try writer.writeAll("\",\n  \"after\": \"");
+    try output.writeJsonEscaped(writer, after_label);
+    try writer.print("\",\n  \"passed\": {s},\n  \"budgets\": [", .{if (countExceeded(outcomes) == 0) "true" else "false"});
+    for (outcomes, 0..) |outcome, i| {
+        try writer.writeAll(if (i == 0) "\n" else ",\n");
+        try writer.writeAll("    {\"budget\": \"");
+        try output.writeJsonEscaped(writer, outcome.budget.text);
+        try writer.print("\", \"max\": {d}, \"used\": {d}, \"exceeded\": {s}, \"changes\": [", .{
+            outcome.budget.max,
+            outcome.used,
+            if (outcome.exceeded()) "true" else "false",
+        });
+        for (outcome.changes, 0..) |change, j| {
+            if (j > 0) try writer.writeAll(", ");
+            try constraint_diff.writeConstraintJson(writer, change.subject());
+        }
+        try writer.writeAll("]}");
+    }
+    try writer.writeAll(if (outcomes.len == 0) "]\n}\n" else "\n  ]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+test "budgets count matching changes" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const security = try parseBudget("removed:security:error = 0");
+    try testing.expectEqual(constraint_diff.ChangeKind.removed, security.change);
+    try testing.expectEqual(constraint.ConstraintKind.security, security.kind.?);
+    try testing.expectEqual(constraint.Severity.err, security.severity.?);
+    const weakened = try parseBudget("weakened:*=1");
+    try testing.expect(weakened.kind == null and weakened.severity == null);
+    try testing.expectError(error.InvalidBudget, parseBudget("removed:security"));
+    try testing.expectError(error.InvalidBudget, parseBudget("renamed=1"));
+    try testing.expectError(error.InvalidBudget, parseBudget("added:security:fatal=1"));
+
+    const overall = try parseCoverageThreshold("60");
+    try testing.expect(overall.package == null and overall.min == 60);
+    try testing.expect((try parseCoverageThreshold("*=75%")).package == null);
+    const api = try parseCoverageThreshold("internal/api = 80");
+    try testing.expectEqualStrings("internal/api", api.package.?);
+    try testing.expectEqual(@as(f64, 80), api.min);
+    try testing.expectError(error.InvalidThreshold, parseCoverageThreshold("api=high"));
+    try testing.expectError(error.InvalidThreshold, parseCoverageThreshold("=50"));
+    try testing.expectError(error.InvalidThreshold, parseCoverageThreshold("120"));
+
+    const auth = constraint.Constraint{ .name = "require_auth", .description = "", .kind = .security, .severity = .err, .origin_file = "api/h.go" };
+    const lint = constraint.Constraint{ .name = "doc_comment", .description = "", .kind = .syntactic, .severity = .warning, .origin_file = "api/h.go" };
+    var looser = lint;
+    looser.severity = .hint;
+    const changes = [_]constraint_diff.Change{
+        .{ .kind = .removed, .before = auth },
+        .{ .kind = .weakened, .before = lint, .after = looser },
+    };
+    const outcomes = try evaluate(arena, &.{ security, weakened }, &changes);
+    try testing.expectEqual(@as(usize, 1), outcomes[0].used);
+    try testing.expect(outcomes[0].exceeded());
+    try testing.expect(!outcomes[1].exceeded());
+    try testing.expectEqual(@as(usize, 1), countExceeded(outcomes));
+
+    const text = try formatText(arena, outcomes);
+    try testing.expect(std.mem.indexOf(u8, text, "FAIL  removed:security:error = 0  1/0\n        removed require_auth (security) api/h.go\n") != null);
+}
@@ -0,0 +1,266 @@
+// Profiling support
+// A counting allocator and a stage timer used to attribute wall time and heap
+// usage to each stage of an extraction run.
+const std = @import("std");
+
+/// Allocator wrapper that tracks live bytes, the high-water mark, and call
+/// counts. Safe to share between threads.
+pub const CountingAllocator = struct {
+    parent: std.mem.Allocator,
+    mutex: std.Thread.Mutex = .{},
+    /// Bytes currently allocated
+    live: usize = 0,
+    /// Highest `live` since init or the last `resetPeak`
+    peak: usize = 0,
+    /// Successful allocations (including growing remaps)
+    allocations: usize = 0,
+    /// Bytes requested over the allocator's lifetime
+    total_bytes: usize = 0,
+
+    pub fn init(parent: std.mem.Allocator) CountingAllocator {
+        return .{ .parent = parent };
+    }
+
+    pub fn allocator(self: *CountingAllocator) std.mem.Allocator {
+        return .{
+            .ptr = self,
+            .vtable = &.{
+                .alloc = alloc,
+                .resize = resize,
+                .remap = remap,
+                .free = free,
+            },
+        };
+    }
+
+    /// Start a new high-water mark at the current live size
+    pub fn resetPeak(self: *CountingAllocator) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        self.peak = self.live;
+    }
+
+    fn record(self: *CountingAllocator, old_len: usize, new_len: usize) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        if (new_len > old_len) {
+            self.allocations += 1;
+            self.total_bytes += new_len - old_len;
+        }
+        self.live = self.live - old_len + new_len;
+        self.peak = @max(self.peak, self.live);
+    }
+
+    fn alloc(ctx: *anyopaque, len: usize, alignment: std.mem.Alignment, ret_addr: usize) ?[*]u8 {
+        const self: *CountingAllocator = @ptrCast(@alignCast(ctx));
+        const ptr = self.parent.rawAlloc(len, alignment, ret_addr) orelse return null;
+        self.record(0, len);
+        return ptr;
+    }
+
+    fn resize(ctx: *anyopaque, memory: []u8, alignment: std.mem.Alignment, new_len: usize, ret_addr: usize) bool {
+        const self: *CountingAllocator = @ptrCast(@alignCast(ctx));
+        if (!self.parent.rawResize(memory, alignment, new_len, ret_addr)) return false;
+        self.record(memory.len, new_len);
+        return true;
+    }
+
+    fn remap(ctx: *anyopaque, memory: []u8, alignment: std.mem.Alignment, new_len: usize, ret_addr: usize) ?[*]u8 {
+        const self: *CountingAllocator = @ptrCast(@alignCast(ctx));
+        const ptr = self.parent.rawRemap(memory, alignment, new_len, ret_addr) orelse return null;
+        self.record(memory.len, new_len);
+        return ptr;
+    }
+
+    fn free(ctx: *anyopaque, memory: []u8, alignment: std.mem.Alignment, ret_addr: usize) void {
+        const self: *CountingAllocator = @ptrCast(@alignCast(ctx));
+        self.parent.rawFree(memory, alignment, ret_addr);
+        self.record(memory.len, 0);
+    }
+};
+
+/// Wall time and heap usage of one stage
+pub const Stage = struct {
+    name: []const u8,
+    elapsed_ns: u64,
+    /// Heap high-water mark reached during the stage
+    peak_bytes: usize,
+    allocations: usize,
+};
+
+/// Records consecutive stages. Heap figures come from `counting`, which must
+/// be the allocator the profiled code uses.
+pub const Profiler = struct {
+    allocator: std.mem.Allocator,
+    counting: *CountingAllocator,
+    stages: std.ArrayList(Stage),
+    current: ?[]const u8 = null,
+    timer: ?std.time.Timer = null,
+    allocations_at_start: usize = 0,
+
+    pub fn init(allocator: std.mem.Allocator, counting: *CountingAllocator) Profiler {
+        return .{
+            .allocator = allocator,
+            .counting = counting,
+            .stages = std.ArrayList(Stage){},
+        };
+    }
+
+    pub fn deinit(self: *Profiler) void {
+        self.stages.deinit(self.allocator);
+    }
+
+    pub fn begin(self: *Profiler, name: []const u8) void {
+        self.counting.resetPeak();
+        self.allocations_at_start = self.counting.allocations;
+        self.current = name;
+        self.timer = std.time.Timer.start() catch null;
+    }
+
+    /// Record the stage started by `begin`. A stage run more than once (one
+    /// per target, say) accumulates into its first entry.
+    pub fn end(self: *Profiler) !void {
+        const name = self.current orelse return;
+        const elapsed = if (self.timer) |*timer| timer.read() else 0;
+        self.current = null;
+        const allocations = self.counting.allocations - self.allocations_at_start;
+        for (self.stages.items) |*stage| {
+            if (!std.mem.eql(u8, stage.name, name)) continue;
+            stage.elapsed_ns += elapsed;
+            stage.peak_bytes = @max(stage.peak_bytes, self.counting.peak);
+            stage.allocations += allocations;
+            return;
+        }
+        try self.stages.append(self.allocator, .{
+            .name = name,
+            .elapsed_ns = elapsed,
+            .peak_bytes = self.counting.peak,
+            .allocations = allocations,
+        });
+    }
+
+    /// Sum of stage wall times
+    pub fn totalNs(self: *const Profiler) u64 {
+        var total: u64 = 0;
+        for (self.stages.items) |stage| total += stage.elapsed_ns;
+        return total;
+    }
+};
+
+/// Write stages as a JSON array of
+/// `{"stage", "elapsed_ns", "peak_bytes", "allocations"}` objects
+pub fn writeStagesJson(writer: anytype, stages: []const Stage) !void {
+    try writer.writeAll("[");
+    for (stages, 0..) |stage, i| {
+        if (i > 0) try writer.writeAll(", ");
+        try writer.print("{{\"stage\": \"{s}\", \"elapsed_ns\": {d}, \"peak_bytes\": {d}, \"allocations\": {d}}}", .{
+            stage.name,
+            stage.elapsed_ns,
+            stage.peak_bytes,
+            stage.allocations,
+        });
+    }
+    try writer.writeAll("]");
+}
+
+/// Read an array written by `writeStagesJson`; names are copied into
+/// `arena`. Malformed entries are skipped.
+pub fn parseStagesJson(arena: std.mem.Allocator, value: std.json.Value) ![]Stage {
+    if (value != .array) return &.{};
+    var stages = std.ArrayList(Stage){};
+    for (value.array.items) |item| {
+        if (item != .object) continue;
+        const name = item.object.get("stage") orelse continue;
+        if (name != .string) continue;
+        try stages.append(arena, .{
+            .name = try arena.dupe(u8, name.string),
+            .elapsed_ns = jsonCount(u64, item.object.get("elapsed_ns")),
+            .peak_bytes = jsonCount(usize, item.object.get("peak_bytes")),
+            .allocations = jsonCount(usize, item.object.get("allocations")),
+        });
+    }
+    return stages.toOwnedSlice(arena);
+}
+
+fn jsonCount(comptime T: type, value: ?std.json.Value) T {
+    const v = value orelse return 0;
+    if (v != .integer) return 0;
+    return std.math.cast(T, v.integer) orelse 0;
+}
+
+/// Write a duration with a unit suited to its size
+pub fn writeDuration(writer: anytype, ns: u64) !void {
+    const value: f64 = @floatFromInt(ns);
+    if (ns < std.time.ns_per_ms) {
+        try writer.print("{d:.1} us", .{value / std.time.ns_per_us});
+    } else if (ns < std.time.ns_per_s) {
+        try writer.print("{d:.1} ms", .{value / std.time.ns_per_ms});
+    } else {
+        try writer.print("{d:.2} s", .{value / std.time.ns_per_s});
+    }
+}
+
+/// Write a byte count in B, KiB, MiB, or GiB
+pub fn writeBytes(writer: anytype, bytes: usize) !void {
+    const value: f64 = @floatFromInt(bytes);
+    if (bytes < 1024) {
+        try writer.print("{d} B", .{bytes});
+    } else if (bytes < 1024 * 1024) {
+        try writer.print("{d:.1} KiB", .{value / 1024});
+    } else if (bytes < 1024 * 1024 * 1024) {
+        try writer.print("{d:.1} MiB", .{value / (1024 * 1024)});
+    } else {
+        try writer.print("{d:.2} GiB", .{value / (1024 * 1024 * 1024)});
+    }
+}
+
+test "counting allocator tracks live and peak bytes" {
+    const testing = std.testing;
+
+    var counting = CountingAllocator.init(testing.allocator);
+    const allocator = counting.allocator();
+
+    const a = try allocator.alloc(u8, 100);
+    const b = try allocator.alloc(u8, 50);
+    allocator.free(a);
+    try testing.expectEqual(@as(usize, 50), counting.live);
+    try testing.expectEqual(@as(usize, 150), counting.peak);
+    try testing.expectEqual(@as(usize, 2), counting.allocations);
+
+    counting.resetPeak();
+    try testing.expectEqual(@as(usize, 50), counting.peak);
+    allocator.free(b);
+    try testing.expectEqual(@as(usize, 0), counting.live);
+}
+
+test "profiler accumulates repeated stages and round trips through JSON" {
+    const testing = std.testing;
+
+    var counting = CountingAllocator.init(testing.allocator);
+    var profiler = Profiler.init(testing.allocator, &counting);
+    defer profiler.deinit();
+
+    for (0..2) |_| {
+        profiler.begin("analyze");
+        const buf = try counting.allocator().alloc(u8, 64);
+        counting.allocator().free(buf);
+        try profiler.end();
+    }
+    profiler.begin("render");
+    try profiler.end();
+    try testing.expectEqual(@as(usize, 2), profiler.stages.items.len);
+    try testing.expectEqual(@as(usize, 2), profiler.stages.items[0].allocations);
+    try testing.expectEqual(@as(usize, 64), profiler.stages.items[0].peak_bytes);
+
+    var list = std.ArrayList(u8){};
+    defer list.deinit(testing.allocator);
+    try writeStagesJson(list.writer(testing.allocator), profiler.stages.items);
+
+    var arena = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena.deinit();
+    const value = try std.json.parseFromSliceLeaky(std.json.Value, arena.allocator(), list.items, .{});
+    const stages = try parseStagesJson(arena.allocator(), value);
+    try testing.expectEqual(@as(usize, 2), stages.len);
+    try testing.expectEqualStrings("render", stages[1].name);
+    try testing.expectEqual(profiler.stages.items[0].elapsed_ns, stages[0].elapsed_ns);
+}
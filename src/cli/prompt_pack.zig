@@ -0,0 +1,207 @@
+// Prompt-pack output format
+// Packs ranked constraints into token-budgeted chunks with per-file context
+// headers, ready to be pasted or injected into LLM prompts.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const summary_mod = @import("cli_summary");
+
+/// Options controlling how constraints are packed
+pub const PackOptions = struct {
+    /// Approximate token budget per chunk
+    token_budget: usize = 2000,
+    /// Stop after this many chunks (0 = unlimited)
+    max_chunks: usize = 0,
+};
+
+/// Rough token estimate (~4 bytes per token for English text and code)
+pub fn estimateTokens(len: usize) usize {
+    return (len + 3) / 4;
+}
+
+/// Ranking score: severity dominates, then priority, scaled by confidence
+pub fn score(c: constraint.Constraint) f32 {
+    const severity_weight: f32 = switch (c.severity) {
+        .err => 4.0,
+        .warning => 3.0,
+        .info => 2.0,
+        .hint => 1.0,
+    };
+    const priority_weight: f32 = @floatFromInt(c.priority.toNumeric() + 1);
+    return (severity_weight * 4.0 + priority_weight) * c.confidence;
+}
+
+const Ranked = struct {
+    index: usize,
+    score: f32,
+
+    fn lessThan(_: void, a: Ranked, b: Ranked) bool {
+        if (a.score != b.score) return a.score > b.score;
+        return a.index < b.index;
+    }
+};
+
+/// Tokens reserved for the chunk banner line
+const chunk_header_tokens = 16;
+/// Fixed per-entry overhead for bullet, tags, and line reference
+const entry_overhead_bytes = 32;
+
+/// Format constraints as token-budgeted prompt chunks.
+/// Constraints are ranked globally; each chunk is filled in rank order and then
+/// grouped by file so every file's constraints sit under a single context header.
+pub fn formatPromptPack(
+    allocator: std.mem.Allocator,
+    constraint_set: constraint.ConstraintSet,
+    files: []const summary_mod.FileInfo,
+    options: PackOptions,
+) ![]u8 {
+    const items = constraint_set.constraints.items;
+
+    const ranked = try allocator.alloc(Ranked, items.len);
+    defer allocator.free(ranked);
+    for (items, 0..) |c, i| {
+        ranked[i] = .{ .index = i, .score = score(c) };
+    }
+    std.mem.sort(Ranked, ranked, {}, Ranked.lessThan);
+
+    // Partition ranked constraints into chunks that fit the budget
+    var chunks = std.ArrayList(std.ArrayList(usize)){};
+    defer {
+        for (chunks.items) |*chunk| chunk.deinit(allocator);
+        chunks.deinit(allocator);
+    }
+    var chunk_tokens = std.ArrayList(usize){};
+    defer chunk_tokens.deinit(allocator);
+
+    var current = std.ArrayList(usize){};
+    defer current.deinit(allocator);
+    var current_files = std.StringHashMap(void).init(allocator);
+    defer current_files.deinit();
+    var current_tokens: usize = chunk_header_tokens;
+
+    for (ranked) |r| {
+        const c = items[r.index];
+        const file = c.origin_file orelse "(unknown)";
+        var cost = estimateTokens(c.name.len + c.description.len + entry_overhead_bytes);
+        if (!current_files.contains(file)) cost += estimateTokens(file.len + 16);
+
+        if (current.items.len > 0 and current_tokens + cost > options.token_budget) {
+            try chunks.append(allocator, current);
+            try chunk_tokens.append(allocator, current_tokens);
+            current = std.ArrayList(usize){};
+            current_files.clearRetainingCapacity();
+            current_tokens = chunk_header_tokens;
+            cost = estimateTokens(c.name.len + c.description.len + entry_overhead_bytes) +
+                estimateTokens(file.len + 16);
+        }
+
+        try current.append(allocator, r.index);
+        try current_files.put(file, {});
+        current_tokens += cost;
+    }
+    if (current.items.len > 0) {
+        try chunks.append(allocator, current);
+        try chunk_tokens.append(allocator, current_tokens);
+        current = std.ArrayList(usize){};
+    }
+
+    const chunk_count = if (options.max_chunks > 0) @min(options.max_chunks, chunks.items.len) else chunks.items.len;
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    for (chunks.items[0..chunk_count], 0..) |chunk, chunk_index| {
+        if (chunk_index > 0) try writer.writeAll("\n");
+        try writer.print("## Code constraints (chunk {d} of {d}, ~{d} tokens)\n", .{
+            chunk_index + 1,
+            chunk_count,
+            chunk_tokens.items[chunk_index],
+        });
+
+        // Group the chunk's entries by file, in order of first (highest-ranked) appearance
+        var file_order = std.ArrayList([]const u8){};
+        defer file_order.deinit(allocator);
+        var seen = std.StringHashMap(void).init(allocator);
+        defer seen.deinit();
+        for (chunk.items) |idx| {
+            const file = items[idx].origin_file orelse "(unknown)";
+            if (!seen.contains(file)) {
+                try seen.put(file, {});
+                try file_order.append(allocator, file);
+            }
+        }
+
+        for (file_order.items) |file| {
+            try writer.writeAll("\n### ");
+            try writer.writeAll(file);
+            if (languageOf(files, file)) |language| {
+                try writer.print(" ({s})", .{language});
+            }
+            try writer.writeAll("\n");
+
+            for (chunk.items) |idx| {
+                const c = items[idx];
+                const c_file = c.origin_file orelse "(unknown)";
+                if (!std.mem.eql(u8, c_file, file)) continue;
+
+                try writer.print("- [{s}/{s}] {s}: {s}", .{
+                    severityLabel(c.severity),
+                    @tagName(c.kind),
+                    c.name,
+                    c.description,
+                });
+                if (c.origin_line) |line| {
+                    try writer.print(" (line {d})", .{line});
+                }
+                try writer.writeAll("\n");
+            }
+        }
+    }
+
+    if (chunk_count < chunks.items.len) {
+        var omitted: usize = 0;
+        for (chunks.items[chunk_count..]) |chunk| omitted += chunk.items.len;
+        try writer.print("\n<!-- {d} lower-ranked constraints omitted by --max-chunks -->\n", .{omitted});
+    }
+
+    return list.toOwnedSlice(allocator);
+}
+
+fn severityLabel(severity: constraint.Severity) []const u8 {
+    return switch (severity) {
+        .err => "error",
+        .warning => "warning",
+        .info => "info",
+        .hint => "hint",
+    };
+}
+
+fn languageOf(files: []const summary_mod.FileInfo, path: []const u8) ?[]const u8 {
+    for (files) |info| {
+        if (std.mem.eql(u8, info.path, path)) return info.language;
+    }
+    return null;
+}
+
+test "prompt pack ranks errors first and respects budget" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var set = constraint.ConstraintSet.init(allocator, "test");
+    defer set.deinit();
+    try set.add(.{ .name = "naming", .description = "camelCase identifiers", .kind = .syntactic, .severity = .hint, .origin_file = "a.ts" });
+    try set.add(.{ .name = "sql_params", .description = "Queries must be parameterized", .kind = .security, .severity = .err, .origin_file = "b.ts" });
+
+    const files = [_]summary_mod.FileInfo{.{ .path = "b.ts", .language = "typescript", .line_count = 10 }};
+
+    const text = try formatPromptPack(allocator, set, &files, .{ .token_budget = 40 });
+    defer allocator.free(text);
+
+    // A tiny budget forces one constraint per chunk, highest-ranked first
+    try testing.expect(std.mem.indexOf(u8, text, "chunk 1 of 2") != null);
+    const err_pos = std.mem.indexOf(u8, text, "sql_params").?;
+    const hint_pos = std.mem.indexOf(u8, text, "naming").?;
+    try testing.expect(err_pos < hint_pos);
+    try testing.expect(std.mem.indexOf(u8, text, "### b.ts (typescript)") != null);
+}
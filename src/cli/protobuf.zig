@@ -0,0 +1,173 @@
+// Protocol Buffers wire format
+// Just enough of the binary encoding for the messages in
+// proto/ananke/v1/ananke.proto: varints, doubles, and length-delimited
+// strings and nested messages. Fields at their default value are not
+// written, as proto3 requires for implicit presence.
+const std = @import("std");
+
+pub const WireType = enum(u3) {
+    varint = 0,
+    fixed64 = 1,
+    bytes = 2,
+    fixed32 = 5,
+    _,
+};
+
+pub const Error = error{MalformedMessage};
+
+/// Appends fields to a message
+pub const Encoder = struct {
+    allocator: std.mem.Allocator,
+    buffer: std.ArrayList(u8) = .{},
+
+    pub fn init(allocator: std.mem.Allocator) Encoder {
+        return .{ .allocator = allocator };
+    }
+
+    pub fn deinit(self: *Encoder) void {
+        self.buffer.deinit(self.allocator);
+    }
+
+    fn key(self: *Encoder, field: u32, wire: WireType) !void {
+        try self.varint((@as(u64, field) << 3) | @intFromEnum(wire));
+    }
+
+    fn varint(self: *Encoder, value: u64) !void {
+        var rest = value;
+        while (rest >= 0x80) : (rest >>= 7) {
+            try self.buffer.append(self.allocator, @as(u8, @truncate(rest)) | 0x80);
+        }
+        try self.buffer.append(self.allocator, @intCast(rest));
+    }
+
+    pub fn uint(self: *Encoder, field: u32, value: u64) !void {
+        if (value == 0) return;
+        try self.key(field, .varint);
+        try self.varint(value);
+    }
+
+    pub fn double(self: *Encoder, field: u32, value: f64) !void {
+        if (value == 0) return;
+        try self.key(field, .fixed64);
+        var bytes: [8]u8 = undefined;
+        std.mem.writeInt(u64, &bytes, @bitCast(value), .little);
+        try self.buffer.appendSlice(self.allocator, &bytes);
+    }
+
+    pub fn string(self: *Encoder, field: u32, value: []const u8) !void {
+        if (value.len == 0) return;
+        try self.bytes(field, value);
+    }
+
+    /// A nested message, written even when empty so its presence is kept
+    pub fn message(self: *Encoder, field: u32, encoded: []const u8) !void {
+        try self.bytes(field, encoded);
+    }
+
+    fn bytes(self: *Encoder, field: u32, value: []const u8) !void {
+        try self.key(field, .bytes);
+        try self.varint(value.len);
+        try self.buffer.appendSlice(self.allocator, value);
+    }
+};
+
+pub const Field = struct {
+    number: u32,
+    value: union(enum) {
+        varint: u64,
+        fixed64: u64,
+        bytes: []const u8,
+        fixed32: u32,
+    },
+
+    pub fn asString(self: Field) Error![]const u8 {
+        return switch (self.value) {
+            .bytes => |b| b,
+            else => Error.MalformedMessage,
+        };
+    }
+
+    pub fn asDouble(self: Field) Error!f64 {
+        return switch (self.value) {
+            .fixed64 => |bits| @bitCast(bits),
+            else => Error.MalformedMessage,
+        };
+    }
+};
+
+/// Iterates the fields of an encoded message; strings borrow the input
+pub const Decoder = struct {
+    data: []const u8,
+    pos: usize = 0,
+
+    pub fn init(data: []const u8) Decoder {
+        return .{ .data = data };
+    }
+
+    pub fn next(self: *Decoder) Error!?Field {
+        if (self.pos == self.data.len) return null;
+        const tag = try self.varint();
+        const number = std.math.cast(u32, tag >> 3) orelse return Error.MalformedMessage;
+        if (number == 0) return Error.MalformedMessage;
+        return .{ .number = number, .value = switch (@as(WireType, @enumFromInt(@as(u3, @truncate(tag))))) {
+            .varint => .{ .varint = try self.varint() },
+            .fixed64 => .{ .fixed64 = std.mem.readInt(u64, (try self.take(8))[0..8], .little) },
+            .bytes => blk: {
+                const length = std.math.cast(usize, try self.varint()) orelse return Error.MalformedMessage;
+                break :blk .{ .bytes = try self.take(length) };
+            },
+            .fixed32 => .{ .fixed32 = std.mem.readInt(u32, (try self.take(4))[0..4], .little) },
+            _ => return Error.MalformedMessage,
+        } };
+    }
+
+    fn take(self: *Decoder, n: usize) Error![]const u8 {
+        if (n > self.data.len - self.pos) return Error.MalformedMessage;
+        defer self.pos += n;
+        return self.data[self.pos..][0..n];
+    }
+
+    fn varint(self: *Decoder) Error!u64 {
+        var value: u64 = 0;
+        var shift: u7 = 0;
+        while (shift < 64) : (shift += 7) {
+            const byte = (try self.take(1))[0];
+            value |= @as(u64, byte & 0x7f) << @intCast(shift);
+            if (byte & 0x80 == 0) return value;
+        }
+        return Error.MalformedMessage;
+    }
+};
+
+test "protobuf fields round trip" {
+    const testing = std.testing;
+
+    var inner = Encoder.init(testing.allocator);
+    defer inner.deinit();
+    try inner.string(1, "nested");
+
+    var encoder = Encoder.init(testing.allocator);
+    defer encoder.deinit();
+    try encoder.uint(1, 300);
+    try encoder.uint(2, 0); // default: not written
+    try encoder.string(3, "src/auth.go");
+    try encoder.double(4, 0.75);
+    try encoder.message(5, inner.buffer.items);
+    // 300 is the varint example of the protobuf encoding guide
+    try testing.expectEqualSlices(u8, &.{ 0x08, 0xac, 0x02 }, encoder.buffer.items[0..3]);
+
+    var decoder = Decoder.init(encoder.buffer.items);
+    try testing.expectEqual(@as(u64, 300), (try decoder.next()).?.value.varint);
+    try testing.expectEqualStrings("src/auth.go", try (try decoder.next()).?.asString());
+    try testing.expectEqual(@as(f64, 0.75), try (try decoder.next()).?.asDouble());
+    const nested = (try decoder.next()).?;
+    try testing.expectEqual(@as(u32, 5), nested.number);
+    var nested_decoder = Decoder.init(try nested.asString());
+    try testing.expectEqualStrings("nested", try (try nested_decoder.next()).?.asString());
+    try testing.expect(try decoder.next() == null);
+
+    // Truncated input is rejected
+    var truncated = Decoder.init(encoder.buffer.items[0..5]);
+    _ = try truncated.next();
+    try testing.expectError(Error.MalformedMessage, truncated.next());
+}
@@ -0,0 +1,438 @@
+// Quick fixes
+// Finds Go violations whose remediation is mechanical and describes each fix
+// as text edits shaped like LSP TextEdits (0-based line and character ranges),
+// so editors and review bots can apply them without re-deriving the change:
+//   missing_defer_close  a file, connection, rows, or response opened in a
+//                        function and never closed there; the fix defers the
+//                        Close right after the error check
+//   missing_ctx_param    a function that makes its own context.Background()
+//                        or context.TODO(); the fix takes ctx as the first
+//                        parameter and uses it instead. Callers must then
+//                        pass one, so the fix is not marked safe
+// Detection is line-based and expects gofmt layout. Characters are byte
+// offsets, which equal LSP's UTF-16 offsets on ASCII lines.
+const std = @import("std");
+const output = @import("cli_output");
+
+/// Layout version of the fixes document
+pub const format_version = 1;
+
+pub const Position = struct {
+    line: u32,
+    character: u32,
+};
+
+pub const Range = struct {
+    start: Position,
+    end: Position,
+};
+
+/// Replace `range` with `new_text`; an empty range inserts
+pub const TextEdit = struct {
+    range: Range,
+    new_text: []const u8,
+};
+
+pub const Rule = enum {
+    missing_defer_close,
+    missing_ctx_param,
+};
+
+pub const Fix = struct {
+    rule: Rule,
+    /// 1-based line of the violation
+    line: u32,
+    message: []const u8,
+    /// Label for the editor's quick-fix menu
+    title: []const u8,
+    /// The edits are complete on their own; unsafe fixes need follow-up
+    /// changes elsewhere (such as callers)
+    safe: bool,
+    /// Non-overlapping, in document order
+    edits: []const TextEdit,
+};
+
+const Opener = struct {
+    /// Callee and opening parenthesis; a leading '.' matches any receiver
+    call: []const u8,
+    /// The result is an *http.Response, closed through its Body
+    response: bool = false,
+};
+
+/// Calls whose first result must be closed by the caller
+const openers = [_]Opener{
+    .{ .call = "os.Open(" },
+    .{ .call = "os.Create(" },
+    .{ .call = "os.OpenFile(" },
+    .{ .call = "sql.Open(" },
+    .{ .call = "net.Dial(" },
+    .{ .call = "net.Listen(" },
+    .{ .call = ".Query(" },
+    .{ .call = ".QueryContext(" },
+    .{ .call = "http.Get(", .response = true },
+    .{ .call = "http.Post(", .response = true },
+    .{ .call = "http.Head(", .response = true },
+    .{ .call = ".Do(", .response = true },
+};
+
+const context_calls = [_][]const u8{ "context.Background()", "context.TODO()" };
+
+/// Quick fixes for the Go `source`. Messages and edits live in `arena`.
+pub fn detect(arena: std.mem.Allocator, source: []const u8) ![]const Fix {
+    var lines = std.ArrayList([]const u8){};
+    var it = std.mem.splitScalar(u8, source, '\n');
+    while (it.next()) |line| try lines.append(arena, std.mem.trimRight(u8, line, "\r"));
+
+    var fixes = std.ArrayList(Fix){};
+    var start: usize = 0;
+    while (start < lines.items.len) : (start += 1) {
+        if (!std.mem.startsWith(u8, lines.items[start], "func ")) continue;
+        const end = functionEnd(lines.items, start);
+        try detectUnclosed(arena, lines.items, start, end, &fixes);
+        if (try detectOwnContext(arena, lines.items, start, end)) |fix| try fixes.append(arena, fix);
+        start = end;
+    }
+    std.mem.sort(Fix, fixes.items, {}, lessThanLine);
+    return fixes.items;
+}
+
+fn lessThanLine(_: void, a: Fix, b: Fix) bool {
+    return a.line < b.line;
+}
+
+/// Line of the closing brace of the function declared on line `start`
+fn functionEnd(lines: []const []const u8, start: usize) usize {
+    if (!std.mem.endsWith(u8, lines[start], "{")) return start;
+    var i = start + 1;
+    while (i < lines.len) : (i += 1) {
+        if (std.mem.eql(u8, lines[i], "}")) return i;
+    }
+    return lines.len - 1;
+}
+
+fn indentOf(line: []const u8) []const u8 {
+    return line[0 .. line.len - std.mem.trimLeft(u8, line, " \t").len];
+}
+
+fn isIdentChar(c: u8) bool {
+    return std.ascii.isAlphanumeric(c) or c == '_';
+}
+
+/// Whether `line` uses the identifier `name`
+fn containsWord(line: []const u8, name: []const u8) bool {
+    var from: usize = 0;
+    while (std.mem.indexOfPos(u8, line, from, name)) |at| {
+        const end = at + name.len;
+        const before_ok = at == 0 or !isIdentChar(line[at - 1]);
+        const after_ok = end == line.len or !isIdentChar(line[end]);
+        if (before_ok and after_ok) return true;
+        from = end;
+    }
+    return false;
+}
+
+const Opened = struct {
+    name: []const u8,
+    opener: Opener,
+};
+
+/// The variable that receives an opener's result on `line`, as in
+/// `f, err := os.Open(path)`
+fn openedVariable(line: []const u8) ?Opened {
+    const text = std.mem.trim(u8, line, " \t");
+    const assign = std.mem.indexOf(u8, text, " := ") orelse std.mem.indexOf(u8, text, " = ") orelse return null;
+    const comma = std.mem.indexOfScalar(u8, text[0..assign], ',') orelse return null;
+    const name = std.mem.trim(u8, text[0..comma], " ");
+    if (name.len == 0 or std.mem.eql(u8, name, "_")) return null;
+    for (name) |c| {
+        if (!isIdentChar(c)) return null;
+    }
+
+    const rhs = std.mem.trimLeft(u8, text[assign + 1 ..], ":= ");
+    const paren = std.mem.indexOfScalar(u8, rhs, '(') orelse return null;
+    const callee = rhs[0 .. paren + 1];
+    for (openers) |opener| {
+        const matches = if (opener.call[0] == '.')
+            std.mem.endsWith(u8, callee, opener.call) and callee.len > opener.call.len
+        else
+            std.mem.eql(u8, callee, opener.call);
+        if (matches) return .{ .name = name, .opener = opener };
+    }
+    return null;
+}
+
+/// Openers in the function on lines `start`..`end` whose result is neither
+/// closed nor returned
+fn detectUnclosed(arena: std.mem.Allocator, lines: []const []const u8, start: usize, end: usize, fixes: *std.ArrayList(Fix)) !void {
+    var i = start + 1;
+    while (i < end) : (i += 1) {
+        const opened = openedVariable(lines[i]) orelse continue;
+        const close = try std.fmt.allocPrint(arena, "{s}{s}.Close()", .{ opened.name, if (opened.opener.response) ".Body" else "" });
+
+        const handled = for (lines[i + 1 .. end]) |line| {
+            if (std.mem.indexOf(u8, line, close) != null) break true;
+            if (returns(line, opened.name)) break true;
+        } else false;
+        if (handled) continue;
+
+        const indent = indentOf(lines[i]);
+        const insert = afterErrorCheck(lines, i + 1, end) orelse i + 1;
+        const edits = try arena.alloc(TextEdit, 1);
+        edits[0] = .{
+            .range = .{ .start = .{ .line = @intCast(insert), .character = 0 }, .end = .{ .line = @intCast(insert), .character = 0 } },
+            .new_text = try std.fmt.allocPrint(arena, "{s}defer {s}\n", .{ indent, close }),
+        };
+        try fixes.append(arena, .{
+            .rule = .missing_defer_close,
+            .line = @intCast(i + 1),
+            .message = try std.fmt.allocPrint(arena, "{s} is opened but never closed; it leaks when the function returns", .{opened.name}),
+            .title = try std.fmt.allocPrint(arena, "Add defer {s}", .{close}),
+            .safe = true,
+            .edits = edits,
+        });
+    }
+}
+
+/// Whether `line` returns `name` itself, handing it to the caller
+fn returns(line: []const u8, name: []const u8) bool {
+    const text = std.mem.trim(u8, line, " \t");
+    if (!std.mem.startsWith(u8, text, "return ")) return false;
+    var values = std.mem.splitScalar(u8, text["return ".len..], ',');
+    while (values.next()) |value| {
+        if (std.mem.eql(u8, std.mem.trim(u8, value, " "), name)) return true;
+    }
+    return false;
+}
+
+/// Line after the `if err != nil { ... }` block starting on `line`, so a
+/// deferred Close runs only on a value that was opened
+fn afterErrorCheck(lines: []const []const u8, line: usize, end: usize) ?usize {
+    if (line >= end) return null;
+    const text = std.mem.trim(u8, lines[line], " \t");
+    if (!std.mem.startsWith(u8, text, "if err != nil") or !std.mem.endsWith(u8, text, "{")) return null;
+    const indent = indentOf(lines[line]);
+    var i = line + 1;
+    while (i < end) : (i += 1) {
+        if (std.mem.eql(u8, indentOf(lines[i]), indent) and std.mem.eql(u8, std.mem.trim(u8, lines[i], " \t"), "}")) return i + 1;
+    }
+    return null;
+}
+
+/// Byte offset of the parameter list's opening parenthesis in a func
+/// declaration, and the function's name
+fn parameterList(decl: []const u8) ?struct { name: []const u8, open: usize } {
+    var p: usize = "func ".len;
+    if (p < decl.len and decl[p] == '(') {
+        p = (std.mem.indexOfScalarPos(u8, decl, p, ')') orelse return null) + 1;
+        while (p < decl.len and decl[p] == ' ') p += 1;
+    }
+    const name_start = p;
+    while (p < decl.len and isIdentChar(decl[p])) p += 1;
+    const name = decl[name_start..p];
+    if (name.len == 0) return null;
+    // Type parameters
+    if (p < decl.len and decl[p] == '[') {
+        var depth: usize = 0;
+        while (p < decl.len) : (p += 1) {
+            if (decl[p] == '[') depth += 1;
+            if (decl[p] == ']') {
+                depth -= 1;
+                if (depth == 0) break;
+            }
+        }
+        p += 1;
+    }
+    if (p >= decl.len or decl[p] != '(') return null;
+    return .{ .name = name, .open = p };
+}
+
+/// Entry points and tests, whose signatures are fixed
+fn fixedSignature(name: []const u8) bool {
+    if (std.mem.eql(u8, name, "main") or std.mem.eql(u8, name, "init")) return true;
+    for ([_][]const u8{ "Test", "Benchmark", "Fuzz", "Example" }) |prefix| {
+        if (std.mem.startsWith(u8, name, prefix)) return true;
+    }
+    return false;
+}
+
+/// Local `ctx := context.Background()` (or TODO) declaration
+fn isContextDeclaration(line: []const u8) bool {
+    const text = std.mem.trim(u8, line, " \t");
+    for (context_calls) |call| {
+        if (std.mem.startsWith(u8, text, "ctx := ") and std.mem.eql(u8, text["ctx := ".len..], call)) return true;
+    }
+    return false;
+}
+
+/// A function that makes its own root context instead of taking one
+fn detectOwnContext(arena: std.mem.Allocator, lines: []const []const u8, start: usize, end: usize) !?Fix {
+    if (end == start) return null;
+    const decl = lines[start];
+    const params = parameterList(decl) orelse return null;
+    if (fixedSignature(params.name) or std.mem.indexOf(u8, decl, "context.Context") != null) return null;
+
+    const body = lines[start + 1 .. end];
+    const declares = for (body) |line| {
+        if (isContextDeclaration(line)) break true;
+    } else false;
+    var first: ?usize = null;
+    for (body, start + 1..) |line, i| {
+        const uses_call = for (context_calls) |call| {
+            if (std.mem.indexOf(u8, line, call) != null) break true;
+        } else false;
+        if (uses_call and first == null) first = i;
+        // Without the local declaration, ctx is something else the
+        // parameter would clash with
+        if (!declares and containsWord(line, "ctx")) return null;
+    }
+    const line_no = first orelse return null;
+
+    var edits = std.ArrayList(TextEdit){};
+    const empty = params.open + 1 < decl.len and decl[params.open + 1] == ')';
+    const at = Position{ .line = @intCast(start), .character = @intCast(params.open + 1) };
+    try edits.append(arena, .{ .range = .{ .start = at, .end = at }, .new_text = if (empty) "ctx context.Context" else "ctx context.Context, " });
+
+    for (body, start + 1..) |line, i| {
+        const n: u32 = @intCast(i);
+        if (isContextDeclaration(line)) {
+            try edits.append(arena, .{ .range = .{ .start = .{ .line = n, .character = 0 }, .end = .{ .line = n + 1, .character = 0 } }, .new_text = "" });
+            continue;
+        }
+        var from: usize = 0;
+        while (true) {
+            var next: ?struct { at: usize, len: usize } = null;
+            for (context_calls) |call| {
+                if (std.mem.indexOfPos(u8, line, from, call)) |pos| {
+                    if (next == null or pos < next.?.at) next = .{ .at = pos, .len = call.len };
+                }
+            }
+            const found = next orelse break;
+            try edits.append(arena, .{
+                .range = .{
+                    .start = .{ .line = n, .character = @intCast(found.at) },
+                    .end = .{ .line = n, .character = @intCast(found.at + found.len) },
+                },
+                .new_text = "ctx",
+            });
+            from = found.at + found.len;
+        }
+    }
+
+    return .{
+        .rule = .missing_ctx_param,
+        .line = @intCast(line_no + 1),
+        .message = try std.fmt.allocPrint(arena, "{s} creates its own root context, so callers cannot cancel it or set deadlines", .{params.name}),
+        .title = try std.fmt.allocPrint(arena, "Take ctx context.Context as the first parameter of {s}", .{params.name}),
+        .safe = false,
+        .edits = edits.items,
+    };
+}
+
+/// Fixes document for `file`
+pub fn formatFixes(allocator: std.mem.Allocator, file: []const u8, fixes: []const Fix) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\n  \"version\": {d},\n  \"file\": \"", .{format_version});
+    try output.writeJsonEscaped(writer, file);
+    try writer.writeAll("\",\n  \"fixes\": [");
+    for (fixes, 0..) |fix, i| {
+        try writer.writeAll(if (i == 0) "\n" else ",\n");
+        try writer.print("    {{\"rule\": \"{s}\", \"line\": {d}, \"message\": \"", .{ @tagName(fix.rule), fix.line });
+        try output.writeJsonEscaped(writer, fix.message);
+        try writer.writeAll("\", \"title\": \"");
+        try output.writeJsonEscaped(writer, fix.title);
+        try writer.print("\", \"safe\": {s}, \"edits\": [", .{if (fix.safe) "true" else "false"});
+        for (fix.edits, 0..) |edit, j| {
+            if (j > 0) try writer.writeAll(", ");
+            try writer.print("{{\"range\": {{\"start\": {{\"line\": {d}, \"character\": {d}}}, \"end\": {{\"line\": {d}, \"character\": {d}}}}}, \"newText\": \"", .{
+                edit.range.start.line,
+                edit.range.start.character,
+                edit.range.end.line,
+                edit.range.end.character,
+            });
+            try output.writeJsonEscaped(writer, edit.new_text);
+            try writer.writeAll("\"}");
+        }
+        try writer.writeAll("]}");
+    }
+    try writer.writeAll(if (fixes.len == 0) "]\n}\n" else "\n  ]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// `source` with `edits` applied, for checking fixes
+fn applyEdits(allocator: std.mem.Allocator, source: []const u8, edits: []const TextEdit) ![]u8 {
+    var line_starts = std.ArrayList(usize){};
+    defer line_starts.deinit(allocator);
+    try line_starts.append(allocator, 0);
+    for (source, 0..) |c, i| {
+        if (c == '\n') try line_starts.append(allocator, i + 1);
+    }
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    var copied: usize = 0;
+    for (edits) |edit| {
+        const from = line_starts.items[edit.range.start.line] + edit.range.start.character;
+        const to = line_starts.items[edit.range.end.line] + edit.range.end.character;
+        try list.appendSlice(allocator, source[copied..from]);
+        try list.appendSlice(allocator, edit.new_text);
+        copied = to;
+    }
+    try list.appendSlice(allocator, source[copied..]);
+    return list.toOwnedSlice(allocator);
+}
+
+test "quick fixes for unclosed resources and missing ctx" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const source =
+        \\package store
+        \\
+        \\func Load(path string) ([]byte, error) {
+        \\	f, err := os.Open(path)
+        \\	if err != nil {
+        \\		return nil, err
+        \\	}
+        \\	return io.ReadAll(f)
+        \\}
+        \\
+        \\func Open(path string) (*os.File, error) {
+        \\	f, err := os.Open(path)
+        \\	if err != nil {
+        \\		return nil, err
+        \\	}
+        \\	return f, nil
+        \\}
+        \\
+        \\func (s *Store) Count() (int, error) {
+        \\	ctx := context.Background()
+        \\	rows, err := s.db.QueryContext(ctx, "SELECT id FROM items")
+        \\	if err != nil {
+        \\		return 0, err
+        \\	}
+        \\	defer rows.Close()
+        \\	return count(rows), nil
+        \\}
+        \\
+    ;
+    const fixes = try detect(arena, source);
+    try testing.expectEqual(@as(usize, 2), fixes.len);
+    try testing.expectEqual(Rule.missing_defer_close, fixes[0].rule);
+    try testing.expectEqual(@as(u32, 4), fixes[0].line);
+    try testing.expect(fixes[0].safe);
+    try testing.expectEqual(Rule.missing_ctx_param, fixes[1].rule);
+    try testing.expectEqual(@as(u32, 20), fixes[1].line);
+
+    const fixed = try applyEdits(arena, source, fixes[0].edits);
+    try testing.expect(std.mem.indexOf(u8, fixed, "\t}\n\tdefer f.Close()\n\treturn io.ReadAll(f)\n") != null);
+    const with_ctx = try applyEdits(arena, source, fixes[1].edits);
+    try testing.expect(std.mem.indexOf(u8, with_ctx, "func (s *Store) Count(ctx context.Context) (int, error) {\n\trows, err") != null);
+
+    const text = try formatFixes(arena, "store.go", fixes[0..1]);
+    try testing.expect(std.mem.indexOf(u8, text, "\"edits\": [{\"range\": {\"start\": {\"line\": 7, \"character\": 0}, \"end\": {\"line\": 7, \"character\": 0}}, \"newText\": \"\\tdefer f.Close()\\n\"}]") != null);
+}
@@ -0,0 +1,291 @@
+// Markdown and HTML report renderers
+// All human-readable strings come from the message catalog so reports can be
+// generated in languages other than English.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const messages = @import("cli_messages");
+const summary_mod = @import("cli_summary");
+
+/// Group constraint indices by origin file, preserving first-seen order
+fn groupByFile(
+    allocator: std.mem.Allocator,
+    items: []const constraint.Constraint,
+) !std.StringArrayHashMap(std.ArrayList(usize)) {
+    var groups = std.StringArrayHashMap(std.ArrayList(usize)).init(allocator);
+    errdefer freeGroups(allocator, &groups);
+    for (items, 0..) |c, i| {
+        const gop = try groups.getOrPut(c.origin_file orelse "");
+        if (!gop.found_existing) gop.value_ptr.* = std.ArrayList(usize){};
+        try gop.value_ptr.append(allocator, i);
+    }
+    return groups;
+}
+
+fn freeGroups(allocator: std.mem.Allocator, groups: *std.StringArrayHashMap(std.ArrayList(usize))) void {
+    for (groups.values()) |*list| list.deinit(allocator);
+    groups.deinit();
+}
+
+/// Format constraints as a Markdown report
+pub fn formatMarkdown(
+    allocator: std.mem.Allocator,
+    constraint_set: constraint.ConstraintSet,
+    files: []const summary_mod.FileInfo,
+    catalog: *const messages.Catalog,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    const items = constraint_set.constraints.items;
+
+    try writer.print("# {s}: {s}\n\n", .{ catalog.get(.report_title), constraint_set.name });
+    try writer.print("**{s}:** {d}\n\n", .{ catalog.get(.total_constraints), items.len });
+
+    if (items.len == 0) {
+        try writer.print("{s}\n", .{catalog.get(.no_constraints)});
+        return list.toOwnedSlice(allocator);
+    }
+
+    // Summary by severity and category
+    var summary = try summary_mod.Summary.compute(allocator, items, files, 0);
+    defer summary.deinit();
+
+    try writer.print("## {s}\n\n", .{catalog.get(.section_summary)});
+    try writer.print("| {s} | {s} | {s} |\n|---|---:|---:|\n", .{
+        catalog.get(.col_severity),
+        catalog.get(.col_count),
+        catalog.get(.col_percent),
+    });
+    for (summary.by_severity) |b| {
+        const label = if (std.meta.stringToEnum(constraint.Severity, b.key)) |s| catalog.severity(s) else b.key;
+        try writer.print("| {s} | {d} | {d:.1}% |\n", .{ label, b.count, summary.percent(b.count) });
+    }
+    try writer.writeAll("\n");
+    try writer.print("| {s} | {s} | {s} |\n|---|---:|---:|\n", .{
+        catalog.get(.col_kind),
+        catalog.get(.col_count),
+        catalog.get(.col_percent),
+    });
+    for (summary.by_category) |b| {
+        const label = if (std.meta.stringToEnum(constraint.ConstraintKind, b.key)) |k| catalog.kind(k) else b.key;
+        try writer.print("| {s} | {d} | {d:.1}% |\n", .{ label, b.count, summary.percent(b.count) });
+    }
+    try writer.writeAll("\n");
+
+    // Constraints grouped by file
+    try writer.print("## {s}\n", .{catalog.get(.section_constraints)});
+    var groups = try groupByFile(allocator, items);
+    defer freeGroups(allocator, &groups);
+
+    var it = groups.iterator();
+    while (it.next()) |entry| {
+        const file = if (entry.key_ptr.len > 0) entry.key_ptr.* else catalog.get(.unknown_file);
+        try writer.print("\n### `{s}`\n\n", .{file});
+        try writer.print("| {s} | {s} | {s} | {s} | {s} |\n|---|---|---|---|---:|\n", .{
+            catalog.get(.col_severity),
+            catalog.get(.col_kind),
+            catalog.get(.col_name),
+            catalog.get(.col_description),
+            catalog.get(.col_line),
+        });
+        for (entry.value_ptr.items) |idx| {
+            const c = items[idx];
+            try writer.print("| {s} | {s} | ", .{ catalog.severity(c.severity), catalog.kind(c.kind) });
+            try writeMarkdownCell(writer, c.name);
+            try writer.writeAll(" | ");
+            try writeMarkdownCell(writer, c.description);
+            if (c.origin_line) |line| {
+                try writer.print(" | {d} |\n", .{line});
+            } else {
+                try writer.writeAll(" |  |\n");
+            }
+        }
+    }
+
+    try writer.print("\n---\n_{s}_\n", .{catalog.get(.generated_by)});
+    return list.toOwnedSlice(allocator);
+}
+
+/// Escape text for a Markdown table cell
+pub fn writeMarkdownCell(writer: anytype, s: []const u8) !void {
+    for (s) |c| {
+        switch (c) {
+            '|' => try writer.writeAll("\\|"),
+            '\n', '\r' => try writer.writeByte(' '),
+            else => try writer.writeByte(c),
+        }
+    }
+}
+
+/// Escape text for HTML element content and attribute values
+pub fn writeHtmlEscaped(writer: anytype, s: []const u8) !void {
+    for (s) |c| {
+        switch (c) {
+            '&' => try writer.writeAll("&amp;"),
+            '<' => try writer.writeAll("&lt;"),
+            '>' => try writer.writeAll("&gt;"),
+            '"' => try writer.writeAll("&quot;"),
+            '\'' => try writer.writeAll("&#39;"),
+            else => try writer.writeByte(c),
+        }
+    }
+}
+
+/// Format constraints as a self-contained HTML report.
+/// Every constraint row carries an `id="c-<id>"` anchor for deep linking, and a
+/// small inline script filters rows as the user types in the search box.
+/// The box is a form submitting `q`, prefilled with `search`, so a served
+/// report can change a filter even when it matched nothing.
+pub fn formatHtml(
+    allocator: std.mem.Allocator,
+    constraint_set: constraint.ConstraintSet,
+    catalog: *const messages.Catalog,
+    search: ?[]const u8,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    const items = constraint_set.constraints.items;
+
+    try writer.writeAll("<!DOCTYPE html>\n<html lang=\"");
+    try writeHtmlEscaped(writer, catalog.get(.locale));
+    try writer.writeAll("\">\n<head>\n<meta charset=\"utf-8\">\n<title>");
+    try writeHtmlEscaped(writer, catalog.get(.report_title));
+    try writer.writeAll(": ");
+    try writeHtmlEscaped(writer, constraint_set.name);
+    try writer.writeAll("</title>\n");
+    try writer.writeAll(html_style);
+    try writer.writeAll("</head>\n<body>\n<h1>");
+    try writeHtmlEscaped(writer, catalog.get(.report_title));
+    try writer.writeAll(": ");
+    try writeHtmlEscaped(writer, constraint_set.name);
+    try writer.writeAll("</h1>\n<p>");
+    try writeHtmlEscaped(writer, catalog.get(.total_constraints));
+    try writer.print(": <strong>{d}</strong></p>\n", .{items.len});
+
+    try writer.writeAll("<form id=\"search-form\" method=\"get\"><input id=\"search\" name=\"q\" type=\"search\" placeholder=\"");
+    try writeHtmlEscaped(writer, catalog.get(.label_search));
+    try writer.writeAll("\" value=\"");
+    try writeHtmlEscaped(writer, search orelse "");
+    try writer.writeAll("\"></form>\n");
+
+    if (items.len == 0) {
+        try writer.writeAll("<p>");
+        try writeHtmlEscaped(writer, catalog.get(.no_constraints));
+        try writer.writeAll("</p>\n");
+    } else {
+        try writeHtmlTable(writer, items, catalog);
+    }
+
+    try writer.writeAll("<footer>");
+    try writeHtmlEscaped(writer, catalog.get(.generated_by));
+    try writer.writeAll("</footer>\n");
+    try writer.writeAll(html_script);
+    try writer.writeAll("</body>\n</html>\n");
+
+    return list.toOwnedSlice(allocator);
+}
+
+fn writeHtmlTable(writer: anytype, items: []const constraint.Constraint, catalog: *const messages.Catalog) !void {
+    try writer.writeAll("<table>\n<thead><tr>");
+    const columns = [_]messages.Key{ .col_severity, .col_kind, .col_name, .col_description, .col_file, .col_line };
+    for (columns) |key| {
+        try writer.writeAll("<th>");
+        try writeHtmlEscaped(writer, catalog.get(key));
+        try writer.writeAll("</th>");
+    }
+    try writer.writeAll("</tr></thead>\n<tbody>\n");
+
+    for (items) |c| {
+        try writer.print("<tr id=\"c-{d}\" class=\"sev-{s}\"><td><a href=\"#c-{d}\">", .{ c.id, @tagName(c.severity), c.id });
+        try writeHtmlEscaped(writer, catalog.severity(c.severity));
+        try writer.writeAll("</a></td><td>");
+        try writeHtmlEscaped(writer, catalog.kind(c.kind));
+        try writer.writeAll("</td><td>");
+        try writeHtmlEscaped(writer, c.name);
+        try writer.writeAll("</td><td>");
+        try writeHtmlEscaped(writer, c.description);
+        try writer.writeAll("</td><td>");
+        try writeHtmlEscaped(writer, c.origin_file orelse catalog.get(.unknown_file));
+        try writer.writeAll("</td><td>");
+        if (c.origin_line) |line| try writer.print("{d}", .{line});
+        try writer.writeAll("</td></tr>\n");
+    }
+
+    try writer.writeAll("</tbody>\n</table>\n");
+}
+
+const html_style =
+    \\<style>
+    \\body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+    \\table { border-collapse: collapse; width: 100%; }
+    \\th, td { border-bottom: 1px solid #ddd; padding: 0.4rem 0.6rem; text-align: left; vertical-align: top; }
+    \\tr:target { background: #fff6d5; }
+    \\#search { margin: 1rem 0; padding: 0.4rem; width: 20rem; }
+    \\.sev-err td:first-child a { color: #b00020; }
+    \\.sev-warning td:first-child a { color: #b26a00; }
+    \\.sev-info td:first-child a, .sev-hint td:first-child a { color: #1a5fb4; }
+    \\footer { margin-top: 2rem; color: #777; font-size: 0.85rem; }
+    \\</style>
+    \\
+;
+
+const html_script =
+    \\<script>
+    \\// Opened from disk there is no server to filter; keep filtering in place
+    \\if (location.protocol === 'file:') {
+    \\  document.getElementById('search-form').addEventListener('submit', function (e) { e.preventDefault(); });
+    \\}
+    \\document.getElementById('search').addEventListener('input', function (e) {
+    \\  var q = e.target.value.toLowerCase();
+    \\  document.querySelectorAll('tbody tr').forEach(function (row) {
+    \\    row.style.display = row.textContent.toLowerCase().indexOf(q) === -1 ? 'none' : '';
+    \\  });
+    \\});
+    \\</script>
+    \\
+;
+
+test "markdown report uses catalog strings" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var set = constraint.ConstraintSet.init(allocator, "svc");
+    defer set.deinit();
+    try set.add(.{ .name = "a|b", .description = "desc", .kind = .security, .severity = .err, .origin_file = "x.go", .origin_line = 4 });
+
+    var catalog = messages.Catalog{ .allocator = allocator };
+    defer catalog.deinit();
+    try catalog.parse("report_title = \"Informe\"\nseverity_err = \"error grave\"\n");
+
+    const md = try formatMarkdown(allocator, set, &.{}, &catalog);
+    defer allocator.free(md);
+
+    try testing.expect(std.mem.startsWith(u8, md, "# Informe: svc"));
+    try testing.expect(std.mem.indexOf(u8, md, "| error grave | security | a\\|b | desc | 4 |") != null);
+}
+
+test "html report escapes content and anchors rows" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var set = constraint.ConstraintSet.init(allocator, "svc");
+    defer set.deinit();
+    try set.add(.{ .id = 42, .name = "<script>", .description = "x", .kind = .security, .severity = .err });
+
+    const catalog = messages.Catalog.default();
+    const html = try formatHtml(allocator, set, &catalog, null);
+    defer allocator.free(html);
+
+    try testing.expect(std.mem.indexOf(u8, html, "&lt;script&gt;") != null);
+    try testing.expect(std.mem.indexOf(u8, html, "id=\"c-42\"") != null);
+
+    // A search matching nothing keeps the form, holding the term
+    const empty = constraint.ConstraintSet.init(allocator, "svc");
+    const none = try formatHtml(allocator, empty, &catalog, "no \"such\" rule");
+    defer allocator.free(none);
+    try testing.expect(std.mem.indexOf(u8, none, "name=\"q\"") != null);
+    try testing.expect(std.mem.indexOf(u8, none, "value=\"no &quot;such&quot; rule\"") != null);
+    try testing.expect(std.mem.indexOf(u8, none, "<table>") == null);
+}
@@ -0,0 +1,346 @@
+// Requirements output format
+// Aggregates the constraints of a service into a requirements document: one
+// section per HTTP endpoint, with the contract recovered from its Go handler
+// (accepted method, request DTO and the rules of its fields, statuses) and
+// the constraints found inside the handler, then one section per package for
+// everything else. Requirement levels follow RFC 2119 by severity, so the
+// document can seed the spec of a rewrite or serve as LLM context when
+// generating code against the same contract.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const go_http = @import("cli_go_http");
+const go_rules = @import("cli_go_rules");
+const report = @import("cli_report");
+const summary_mod = @import("cli_summary");
+
+const Endpoint = struct {
+    /// Method of the route, else the one the handler checks
+    method: ?[]const u8 = null,
+    /// Path of the route serving the handler, when one names it
+    path: ?[]const u8 = null,
+    file: []const u8,
+    handler: go_http.Handler,
+    /// Rules of the request DTO, when it is a struct of the service
+    dto: ?go_rules.Struct = null,
+    requirements: std.ArrayList(constraint.Constraint) = .{},
+
+    fn lessThan(_: void, a: Endpoint, b: Endpoint) bool {
+        // Endpoints without a route go last
+        if ((a.path == null) != (b.path == null)) return a.path != null;
+        if (a.path != null) {
+            const order = std.mem.order(u8, a.path.?, b.path.?);
+            if (order != .eq) return order == .lt;
+        }
+        const by_method = std.mem.order(u8, a.method orelse "", b.method orelse "");
+        if (by_method != .eq) return by_method == .lt;
+        return std.mem.lessThan(u8, a.handler.name, b.handler.name);
+    }
+};
+
+const Feature = struct {
+    /// Package directory; "" for constraints without an origin file
+    name: []const u8,
+    requirements: []constraint.Constraint,
+
+    fn lessThan(_: void, a: Feature, b: Feature) bool {
+        if ((a.name.len == 0) != (b.name.len == 0)) return a.name.len > 0;
+        return std.mem.lessThan(u8, a.name, b.name);
+    }
+};
+
+fn trimDot(path: []const u8) []const u8 {
+    var rel = path;
+    while (std.mem.startsWith(u8, rel, "./")) rel = rel[2..];
+    return rel;
+}
+
+fn lessThanRequirement(_: void, a: constraint.Constraint, b: constraint.Constraint) bool {
+    if (a.severity != b.severity) return @intFromEnum(a.severity) < @intFromEnum(b.severity);
+    const by_file = std.mem.order(u8, trimDot(a.origin_file orelse ""), trimDot(b.origin_file orelse ""));
+    if (by_file != .eq) return by_file == .lt;
+    const a_line = a.origin_line orelse 0;
+    const b_line = b.origin_line orelse 0;
+    if (a_line != b_line) return a_line < b_line;
+    return std.mem.lessThan(u8, a.name, b.name);
+}
+
+/// RFC 2119 keyword of a severity
+fn level(severity: constraint.Severity) []const u8 {
+    return switch (severity) {
+        .err => "MUST",
+        .warning => "SHOULD",
+        .info, .hint => "MAY",
+    };
+}
+
+fn findStruct(structs: []const go_rules.Struct, name: []const u8) ?go_rules.Struct {
+    for (structs) |s| {
+        if (std.mem.eql(u8, s.name, name)) return s;
+    }
+    return null;
+}
+
+/// Index of the endpoint whose handler body holds `line` of `file`
+fn endpointAt(endpoints: []const Endpoint, file: []const u8, line: ?u32) ?usize {
+    const at = line orelse return null;
+    for (endpoints, 0..) |endpoint, i| {
+        if (!std.mem.eql(u8, endpoint.file, file)) continue;
+        if (at >= endpoint.handler.line and at <= endpoint.handler.end_line) return i;
+    }
+    return null;
+}
+
+fn writeStatus(writer: anytype, status: ?u16) !void {
+    if (status) |code| try writer.print("get {d}", .{code}) else try writer.writeAll("are rejected");
+}
+
+fn writeRules(writer: anytype, field: go_rules.Field) !void {
+    for (field.checks, 0..) |check, i| {
+        if (i > 0) try writer.writeAll(", ");
+        switch (check.kind) {
+            .required, .email => try writer.writeAll(check.kind.label()),
+            .min_len, .max_len, .min, .max => try writer.print("{s} {d}", .{ check.kind.label(), check.bound }),
+            .one_of => {
+                try writer.print("{s} ", .{check.kind.label()});
+                try report.writeMarkdownCell(writer, check.text);
+            },
+            .pattern, .contains_any => {
+                try writer.print("{s} `", .{check.kind.label()});
+                try report.writeMarkdownCell(writer, check.text);
+                try writer.writeByte('`');
+            },
+        }
+    }
+    if (field.optional) try writer.writeAll(if (field.checks.len > 0) ", optional" else "optional");
+}
+
+fn writeEndpoint(writer: anytype, endpoint: Endpoint) !void {
+    const h = endpoint.handler;
+    if (endpoint.path) |path| {
+        if (endpoint.method) |method| try writer.print("\n### {s} {s}\n\n", .{ method, path }) else try writer.print("\n### {s}\n\n", .{path});
+    } else {
+        try writer.print("\n### {s} (no route found)\n\n", .{h.name});
+    }
+    try writer.writeAll("Handler `");
+    if (h.receiver_type) |receiver| try writer.print("{s}.", .{receiver});
+    try writer.print("{s}` in `{s}`.\n\n", .{ h.name, endpoint.file });
+
+    if (h.method) |method| {
+        try writer.print("- Accepts only {s}; other methods ", .{method});
+        try writeStatus(writer, h.method_status);
+        try writer.writeAll(".\n");
+    }
+    if (h.dto) |dto| {
+        try writer.print("- Decodes a `{s}` JSON body; malformed bodies ", .{dto});
+        try writeStatus(writer, h.decode_status);
+        try writer.writeAll(".\n");
+    }
+    if (h.validates) {
+        try writer.writeAll("- Validates the request; invalid requests ");
+        try writeStatus(writer, h.validation_status);
+        try writer.writeAll(".\n");
+    }
+    if (h.statuses.len > 0) {
+        try writer.writeAll("- Responds with ");
+        for (h.statuses, 0..) |code, i| try writer.print("{s}{d}", .{ if (i > 0) ", " else "", code });
+        try writer.writeAll(".\n");
+    }
+
+    if (endpoint.dto) |dto| {
+        if (dto.hasChecks()) {
+            try writer.print("\nFields of `{s}`:\n\n| Field | Type | Rules |\n|---|---|---|\n", .{dto.name});
+            for (dto.fields) |field| {
+                if (field.checks.len == 0) continue;
+                try writer.print("| `{s}` | `{s}` | ", .{ field.json_name orelse field.name, field.go_type });
+                try writeRules(writer, field);
+                try writer.writeAll(" |\n");
+            }
+        }
+    }
+}
+
+fn writeRequirements(writer: anytype, items: []const constraint.Constraint, next_id: *usize) !void {
+    if (items.len == 0) return;
+    try writer.writeByte('\n');
+    for (items) |c| {
+        next_id.* += 1;
+        try writer.print("- **REQ-{d:0>3}** {s}: ", .{ next_id.*, level(c.severity) });
+        try report.writeMarkdownCell(writer, if (c.description.len > 0) c.description else c.name);
+        try writer.print(" ({s}", .{@tagName(c.kind)});
+        if (c.origin_file) |file| {
+            try writer.print(", `{s}", .{trimDot(file)});
+            if (c.origin_line) |line| try writer.print(":{d}", .{line});
+            try writer.writeByte('`');
+        }
+        try writer.writeAll(")\n");
+    }
+}
+
+/// Format constraints as a requirements document. `sources` is parallel to
+/// `files`; the Go ones are parsed for endpoints and request rules.
+pub fn formatRequirements(
+    allocator: std.mem.Allocator,
+    constraint_set: constraint.ConstraintSet,
+    files: []const summary_mod.FileInfo,
+    sources: []const []const u8,
+) ![]u8 {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var routes = std.ArrayList(go_http.Route){};
+    var structs = std.ArrayList(go_rules.Struct){};
+    var endpoints = std.ArrayList(Endpoint){};
+    for (files, 0..) |info, i| {
+        if (!std.mem.eql(u8, info.language, "go") or i >= sources.len or sources[i].len == 0) continue;
+        const parsed = try go_http.parse(arena, sources[i]);
+        try routes.appendSlice(arena, parsed.routes);
+        try structs.appendSlice(arena, (try go_rules.parse(arena, sources[i])).structs);
+        for (parsed.handlers) |handler| {
+            try endpoints.append(arena, .{ .file = trimDot(info.path), .handler = handler });
+        }
+    }
+    // Routes are usually registered in a different file than their handlers
+    for (endpoints.items) |*endpoint| {
+        endpoint.method = endpoint.handler.method;
+        if (go_http.routeOf(routes.items, endpoint.handler)) |route| {
+            endpoint.path = route.path;
+            if (route.method) |method| endpoint.method = method;
+        }
+        if (endpoint.handler.dto) |name| endpoint.dto = findStruct(structs.items, name);
+    }
+
+    // Constraints inside a handler belong to its endpoint, the rest to their package
+    var packages = std.StringArrayHashMap(std.ArrayList(constraint.Constraint)).init(arena);
+    for (constraint_set.constraints.items) |c| {
+        const file = trimDot(c.origin_file orelse "");
+        if (endpointAt(endpoints.items, file, c.origin_line)) |i| {
+            try endpoints.items[i].requirements.append(arena, c);
+            continue;
+        }
+        const gop = try packages.getOrPut(if (file.len == 0) "" else summary_mod.packageOf(file));
+        if (!gop.found_existing) gop.value_ptr.* = std.ArrayList(constraint.Constraint){};
+        try gop.value_ptr.append(arena, c);
+    }
+
+    std.mem.sort(Endpoint, endpoints.items, {}, Endpoint.lessThan);
+    for (endpoints.items) |endpoint| {
+        std.mem.sort(constraint.Constraint, endpoint.requirements.items, {}, lessThanRequirement);
+    }
+    const features = try arena.alloc(Feature, packages.count());
+    for (packages.keys(), packages.values(), features) |name, list, *feature| {
+        std.mem.sort(constraint.Constraint, list.items, {}, lessThanRequirement);
+        feature.* = .{ .name = name, .requirements = list.items };
+    }
+    std.mem.sort(Feature, features, {}, Feature.lessThan);
+
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("# Requirements\n\n");
+    try writer.print("Synthesized from {d} constraints in {d} files (endpoints: {d}, packages: {d}).\n", .{
+        constraint_set.constraints.items.len,
+        files.len,
+        endpoints.items.len,
+        features.len,
+    });
+    try writer.writeAll("Levels follow RFC 2119: MUST for errors, SHOULD for warnings, MAY for info and hints.\n");
+
+    var next_id: usize = 0;
+    if (endpoints.items.len > 0) {
+        try writer.writeAll("\n## Endpoints\n");
+        for (endpoints.items) |endpoint| {
+            try writeEndpoint(writer, endpoint);
+            try writeRequirements(writer, endpoint.requirements.items, &next_id);
+        }
+    }
+    if (features.len > 0) {
+        try writer.writeAll("\n## Packages\n");
+        for (features) |feature| {
+            if (feature.name.len == 0) try writer.writeAll("\n### Without a file\n") else try writer.print("\n### `{s}`\n", .{feature.name});
+            try writeRequirements(writer, feature.requirements, &next_id);
+        }
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+test "requirements grouped by endpoint and package" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const handlers =
+        \\package api
+        \\
+        \\type CreateUserRequest struct {
+        \\	Username string `json:"username" validate:"required,min=3,max=50"`
+        \\	Nickname string `json:"nickname"`
+        \\}
+        \\
+        \\func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+        \\	var req CreateUserRequest
+        \\	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        \\		http.Error(w, "bad json", http.StatusBadRequest)
+        \\		return
+        \\	}
+        \\	if err := req.Validate(); err != nil {
+        \\		writeError(w, http.StatusUnprocessableEntity, err)
+        \\		return
+        \\	}
+        \\	hash := hashPassword(req.Password)
+        \\	w.WriteHeader(http.StatusCreated)
+        \\}
+    ;
+    const routes =
+        \\package api
+        \\
+        \\func (h *Handler) Routes(mux *http.ServeMux) {
+        \\	mux.HandleFunc("POST /users", h.CreateUser)
+        \\}
+    ;
+
+    var set = constraint.ConstraintSet.init(allocator, "test");
+    defer set.deinit();
+    try set.add(.{ .name = "no_plaintext", .description = "Passwords are hashed", .kind = .security, .severity = .err, .origin_file = "./api/users.go", .origin_line = 18 });
+    try set.add(.{ .name = "tx_scope", .description = "Writes run in a transaction", .kind = .semantic, .severity = .warning, .origin_file = "store/db.go", .origin_line = 7 });
+
+    const files = [_]summary_mod.FileInfo{
+        .{ .path = "./api/users.go", .language = "go", .line_count = 20 },
+        .{ .path = "./api/routes.go", .language = "go", .line_count = 5 },
+        .{ .path = "./store/db.go", .language = "go", .line_count = 30 },
+    };
+    const text = try formatRequirements(allocator, set, &files, &.{ handlers, routes, "" });
+    defer allocator.free(text);
+    try testing.expectEqualStrings(
+        \\# Requirements
+        \\
+        \\Synthesized from 2 constraints in 3 files (endpoints: 1, packages: 1).
+        \\Levels follow RFC 2119: MUST for errors, SHOULD for warnings, MAY for info and hints.
+        \\
+        \\## Endpoints
+        \\
+        \\### POST /users
+        \\
+        \\Handler `Handler.CreateUser` in `api/users.go`.
+        \\
+        \\- Decodes a `CreateUserRequest` JSON body; malformed bodies get 400.
+        \\- Validates the request; invalid requests get 422.
+        \\- Responds with 400, 422, 201.
+        \\
+        \\Fields of `CreateUserRequest`:
+        \\
+        \\| Field | Type | Rules |
+        \\|---|---|---|
+        \\| `username` | `string` | required, min length 3, max length 50 |
+        \\
+        \\- **REQ-001** MUST: Passwords are hashed (security, `api/users.go:18`)
+        \\
+        \\## Packages
+        \\
+        \\### `store`
+        \\
+        \\- **REQ-002** SHOULD: Writes run in a transaction (semantic, `store/db.go:7`)
+        \\
+    , text);
+}
@@ -0,0 +1,259 @@
+// Operational resilience checks
+// Finds Go code that can hang or pile up load when a dependency fails:
+// outbound calls without a timeout or deadline (the default HTTP client, an
+// http.Client without Timeout, requests and database calls without a
+// context, net.Dial, handlers dropping the request context), retry loops
+// that do not back off, and HTTP servers without read and write timeouts.
+// Findings inside a net/http handler name the endpoint it serves. Parsing is
+// line-based and expects gofmt layout.
+const std = @import("std");
+const go_http = @import("cli_go_http");
+
+pub const Kind = enum {
+    /// An outbound call that can wait forever
+    outbound_timeout,
+    /// A retry loop without exponential backoff
+    retry_backoff,
+    /// An HTTP server without read or write timeouts
+    server_timeouts,
+};
+
+pub const Finding = struct {
+    kind: Kind,
+    line: u32,
+    message: []const u8,
+};
+
+fn isIdentChar(c: u8) bool {
+    return std.ascii.isAlphanumeric(c) or c == '_';
+}
+
+/// Index of a call to `name` (such as `http.Get(`) not preceded by an
+/// identifier character
+fn callAt(line: []const u8, name: []const u8) ?usize {
+    var start: usize = 0;
+    while (std.mem.indexOfPos(u8, line, start, name)) |at| : (start = at + 1) {
+        if (at == 0 or !isIdentChar(line[at - 1])) return at;
+    }
+    return null;
+}
+
+fn indentOf(line: []const u8) usize {
+    return line.len - std.mem.trimLeft(u8, line, " \t").len;
+}
+
+/// Lines of the composite literal or block opened by the first `{` at or
+/// after `from` on lines[i], through the line closing it
+fn braced(lines: []const []const u8, i: usize, from: usize) []const []const u8 {
+    var depth: usize = 0;
+    var j = i;
+    var pos = from;
+    while (j < lines.len) : ({
+        j += 1;
+        pos = 0;
+    }) {
+        for (lines[j][pos..]) |c| {
+            if (c == '{') depth += 1;
+            if (c == '}' and depth > 0) {
+                depth -= 1;
+                if (depth == 0) return lines[i .. j + 1];
+            }
+        }
+    }
+    return lines[i..];
+}
+
+fn mentions(lines: []const []const u8, needle: []const u8) bool {
+    for (lines) |line| {
+        if (std.mem.indexOf(u8, line, needle) != null) return true;
+    }
+    return false;
+}
+
+/// The database methods with a Context variant
+const db_calls = [_][]const u8{ "Query", "QueryRow", "Exec", "Prepare", "Begin", "Ping" };
+
+/// Receiver of a database call: `h.db`, `tx`, `s.conn`
+fn isDbReceiver(expr: []const u8) bool {
+    var start = expr.len;
+    while (start > 0 and isIdentChar(expr[start - 1])) start -= 1;
+    const name = expr[start..];
+    if (name.len == 0) return false;
+    return std.ascii.indexOfIgnoreCase(name, "db") != null or std.ascii.eqlIgnoreCase(name, "tx") or
+        std.ascii.eqlIgnoreCase(name, "conn") or std.ascii.eqlIgnoreCase(name, "pool");
+}
+
+/// A single-line outbound call that cannot time out
+fn outboundFinding(arena: std.mem.Allocator, line: []const u8) !?[]const u8 {
+    for ([_][]const u8{ "http.Get(", "http.Post(", "http.PostForm(", "http.Head(" }) |call| {
+        if (callAt(line, call) == null) continue;
+        return try std.fmt.allocPrint(arena, "{s}) uses the default client, which has no timeout; use an http.Client with Timeout or a request with a context deadline", .{call});
+    }
+    if (callAt(line, "http.NewRequest(") != null) {
+        return "request without a context; use http.NewRequestWithContext so the caller's deadline applies";
+    }
+    if (callAt(line, "net.Dial(") != null) {
+        return "net.Dial has no timeout; use net.DialTimeout or a net.Dialer with DialContext";
+    }
+    for (db_calls) |method| {
+        const call = try std.fmt.allocPrint(arena, ".{s}(", .{method});
+        var start: usize = 0;
+        while (std.mem.indexOfPos(u8, line, start, call)) |at| : (start = at + 1) {
+            if (!isDbReceiver(line[0..at])) continue;
+            return try std.fmt.allocPrint(arena, "database call without a context; use {s}Context so the request deadline applies", .{method});
+        }
+    }
+    return null;
+}
+
+/// A retry loop opened on lines[i], judged by its header
+fn isRetryLoop(line: []const u8) bool {
+    if (!std.mem.startsWith(u8, line, "for ")) return false;
+    for ([_][]const u8{ "attempt", "retr", "tries" }) |word| {
+        if (std.ascii.indexOfIgnoreCase(line, word) != null) return true;
+    }
+    return false;
+}
+
+/// Why the retry loop in `body` does not back off, or null when it does
+fn retryFinding(body: []const []const u8) ?[]const u8 {
+    for (body) |line| {
+        if (std.ascii.indexOfIgnoreCase(line, "backoff") != null or std.ascii.indexOfIgnoreCase(line, "jitter") != null) return null;
+        if (std.mem.indexOf(u8, line, "*=") != null or std.mem.indexOf(u8, line, "<<=") != null) return null;
+    }
+    var sleeps = false;
+    for (body) |line| {
+        const at = callAt(line, "time.Sleep(") orelse callAt(line, "time.After(") orelse callAt(line, "time.NewTimer(") orelse continue;
+        sleeps = true;
+        const arg = line[at..];
+        for ([_][]const u8{ "*", "<<", "attempt", "Pow" }) |growth| {
+            if (std.mem.indexOf(u8, arg, growth) != null) return null;
+        }
+    }
+    if (!sleeps) return "retry loop without a delay between attempts; back off exponentially, with jitter";
+    return "retry loop waits a fixed interval; back off exponentially, with jitter, so retries do not pile onto a failing dependency";
+}
+
+/// Missing timeouts of the http.Server literal in `literal`
+fn serverFinding(arena: std.mem.Allocator, literal: []const []const u8) !?[]const u8 {
+    const read = mentions(literal, "ReadTimeout") or mentions(literal, "ReadHeaderTimeout");
+    const write = mentions(literal, "WriteTimeout");
+    if (read and write) return null;
+    const missing = if (!read and !write) "ReadTimeout and WriteTimeout" else if (!read) "ReadTimeout" else "WriteTimeout";
+    return try std.fmt.allocPrint(arena, "http.Server without {s}; slow clients can hold connections open", .{missing});
+}
+
+/// The endpoint or handler containing `line`, for findings inside handlers
+fn handlerAt(file: go_http.File, line: u32) ?go_http.Handler {
+    for (file.handlers) |handler| {
+        if (line > handler.line and line < handler.end_line) return handler;
+    }
+    return null;
+}
+
+fn withEndpoint(arena: std.mem.Allocator, message: []const u8, handler: ?go_http.Handler, routes: []const go_http.Route) ![]const u8 {
+    const h = handler orelse return message;
+    const route = go_http.routeOf(routes, h) orelse
+        return std.fmt.allocPrint(arena, "{s} (handler {s})", .{ message, h.name });
+    return std.fmt.allocPrint(arena, "{s} (endpoint {s}{s}{s}, handler {s})", .{ message, route.method orelse "", if (route.method != null) " " else "", route.path, h.name });
+}
+
+/// Resilience findings in the Go `source`, whose routes and handlers are
+/// `file`; `routes` are the routes of the whole tree. Everything lives in
+/// `arena` or borrows the inputs.
+pub fn analyze(arena: std.mem.Allocator, source: []const u8, file: go_http.File, routes: []const go_http.Route) ![]const Finding {
+    var lines = std.ArrayList([]const u8){};
+    var it = std.mem.splitScalar(u8, source, '\n');
+    while (it.next()) |line| try lines.append(arena, line);
+
+    var findings = std.ArrayList(Finding){};
+    for (lines.items, 0..) |raw, i| {
+        const line = std.mem.trim(u8, raw, " \t\r");
+        if (std.mem.startsWith(u8, line, "//")) continue;
+        const line_no: u32 = @intCast(i + 1);
+        const handler = handlerAt(file, line_no);
+
+        var found: ?struct { kind: Kind, message: []const u8 } = null;
+        if (try outboundFinding(arena, line)) |message| {
+            found = .{ .kind = .outbound_timeout, .message = message };
+        } else if (handler != null and (callAt(line, "context.Background()") != null or callAt(line, "context.TODO()") != null)) {
+            found = .{ .kind = .outbound_timeout, .message = "handler drops the request context; derive from r.Context() so calls end with the request" };
+        } else if (std.mem.indexOf(u8, raw, "http.Client{")) |at| {
+            if (!mentions(braced(lines.items, i, at), "Timeout:")) {
+                found = .{ .kind = .outbound_timeout, .message = "http.Client without Timeout; calls through it can wait forever" };
+            }
+        } else if (callAt(line, "http.ListenAndServe(") != null or callAt(line, "http.ListenAndServeTLS(") != null) {
+            found = .{ .kind = .server_timeouts, .message = "http.ListenAndServe runs a server without read or write timeouts; use an http.Server with ReadTimeout and WriteTimeout" };
+        } else if (std.mem.indexOf(u8, raw, "http.Server{")) |at| {
+            if (try serverFinding(arena, braced(lines.items, i, at))) |message| found = .{ .kind = .server_timeouts, .message = message };
+        } else if (isRetryLoop(line)) {
+            const body = braced(lines.items, i, indentOf(raw));
+            if (retryFinding(body)) |message| found = .{ .kind = .retry_backoff, .message = message };
+        }
+
+        const f = found orelse continue;
+        try findings.append(arena, .{ .kind = f.kind, .line = line_no, .message = try withEndpoint(arena, f.message, handler, routes) });
+    }
+    return findings.items;
+}
+
+test "find missing timeouts and backoff" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const source =
+        \\package api
+        \\
+        \\func main() {
+        \\	mux.HandleFunc("POST /charges", h.Charge)
+        \\	srv := &http.Server{
+        \\		Addr:        ":8080",
+        \\		ReadTimeout: 5 * time.Second,
+        \\	}
+        \\	log.Fatal(srv.ListenAndServe())
+        \\}
+        \\
+        \\var client = &http.Client{Timeout: 10 * time.Second}
+        \\var slow = &http.Client{}
+        \\
+        \\func (h *Handler) Charge(w http.ResponseWriter, r *http.Request) {
+        \\	resp, err := http.Get(h.gatewayURL)
+        \\	rows, err := h.db.Query("SELECT id FROM charges")
+        \\	rows, err = h.db.QueryContext(r.Context(), "SELECT id FROM charges")
+        \\	ctx := context.Background()
+        \\	for attempt := 0; attempt < 3; attempt++ {
+        \\		if err = h.gateway.Charge(ctx); err == nil {
+        \\			break
+        \\		}
+        \\		time.Sleep(time.Second)
+        \\	}
+        \\	for attempt := 0; attempt < 3; attempt++ {
+        \\		if err = h.gateway.Charge(ctx); err == nil {
+        \\			break
+        \\		}
+        \\		time.Sleep(time.Duration(1<<attempt) * 100 * time.Millisecond)
+        \\	}
+        \\}
+    ;
+    const file = try go_http.parse(arena, source);
+    const findings = try analyze(arena, source, file, file.routes);
+
+    const expected = [_]struct { kind: Kind, line: u32 }{
+        .{ .kind = .server_timeouts, .line = 5 },
+        .{ .kind = .outbound_timeout, .line = 13 },
+        .{ .kind = .outbound_timeout, .line = 16 },
+        .{ .kind = .outbound_timeout, .line = 17 },
+        .{ .kind = .outbound_timeout, .line = 19 },
+        .{ .kind = .retry_backoff, .line = 20 },
+    };
+    try testing.expectEqual(expected.len, findings.len);
+    for (expected, findings) |want, got| {
+        try testing.expectEqual(want.kind, got.kind);
+        try testing.expectEqual(want.line, got.line);
+    }
+    try testing.expectEqualStrings("http.Server without WriteTimeout; slow clients can hold connections open", findings[0].message);
+    try testing.expect(std.mem.endsWith(u8, findings[2].message, "(endpoint POST /charges, handler Charge)"));
+    try testing.expect(std.mem.startsWith(u8, findings[3].message, "database call without a context; use QueryContext"));
+}
@@ -0,0 +1,431 @@
+// Disk-backed index over a stored result file
+// query and explain used to parse the whole result file, which for a large
+// monorepo run means holding every constraint in memory at once. `ananke
+// index` scans the file one constraint object at a time and writes a sidecar
+// (`<results>.idx`) with one line per constraint: its id, the fields filters
+// look at, and the byte range of its JSON object. Readers filter on the index
+// and parse only the objects they need.
+//
+// Format (tab-separated, one record per line):
+//   ananke-index 1  <size>  <mtime>  <name>
+//   <id>  <offset>  <length>  <kind>  <severity>  <confidence>  <file>
+const std = @import("std");
+const ananke = @import("ananke");
+const cli_error = @import("cli_error");
+const results = @import("cli_results");
+
+const constraint = ananke.types.constraint;
+
+pub const extension = ".idx";
+const magic = "ananke-index 1";
+
+pub const IndexError = error{
+    InvalidIndex,
+    /// The result file changed after the index was built
+    StaleIndex,
+};
+
+pub const Entry = struct {
+    id: constraint.ConstraintID,
+    offset: u64,
+    len: u64,
+    kind: constraint.ConstraintKind,
+    severity: constraint.Severity,
+    confidence: f32,
+    /// Empty when the constraint has no file
+    file: []const u8,
+
+    /// The indexed fields as a constraint, for filters that do not need the
+    /// name or description
+    pub fn summary(self: Entry) constraint.Constraint {
+        return .{
+            .id = self.id,
+            .name = "",
+            .description = "",
+            .kind = self.kind,
+            .severity = self.severity,
+            .confidence = self.confidence,
+            .origin_file = if (self.file.len > 0) self.file else null,
+        };
+    }
+};
+
+/// Sidecar path for `results_path`. Caller owns the string.
+pub fn indexPath(allocator: std.mem.Allocator, results_path: []const u8) ![]u8 {
+    return std.mem.concat(allocator, u8, &.{ results_path, extension });
+}
+
+/// Scan `results_path` and write its index to `index_path`. Only one
+/// constraint object is held in memory at a time. Returns the number of
+/// constraints indexed.
+pub fn build(allocator: std.mem.Allocator, results_path: []const u8, index_path: []const u8) !usize {
+    const file = try std.fs.cwd().openFile(results_path, .{});
+    defer file.close();
+    const stat = try file.stat();
+
+    var read_buffer: [64 * 1024]u8 = undefined;
+    var file_reader = file.reader(&read_buffer);
+
+    var scanner = Scanner{ .allocator = allocator };
+    defer scanner.deinit();
+
+    // Entries are buffered as text so the header, which needs the set name,
+    // can be written first once the scan is complete
+    var body = std.ArrayList(u8){};
+    defer body.deinit(allocator);
+    const body_writer = body.writer(allocator);
+
+    var arena = std.heap.ArenaAllocator.init(allocator);
+    defer arena.deinit();
+
+    var count: usize = 0;
+    while (try scanner.nextObject(&file_reader.interface)) |object| {
+        _ = arena.reset(.retain_capacity);
+        const value = std.json.parseFromSliceLeaky(std.json.Value, arena.allocator(), object.text, .{}) catch
+            return results.ResultError.InvalidResultFile;
+        if (value != .object) return results.ResultError.InvalidResultFile;
+        const c = try results.parseConstraint(value.object);
+
+        try body_writer.print("{d}\t{d}\t{d}\t{s}\t{s}\t{d}\t", .{
+            if (c.id != 0) c.id else c.computeId(),
+            object.offset,
+            object.text.len,
+            @tagName(c.kind),
+            @tagName(c.severity),
+            c.confidence,
+        });
+        try writeField(body_writer, c.origin_file orelse "");
+        try body_writer.writeByte('\n');
+        count += 1;
+    }
+    if (!scanner.saw_constraints) return results.ResultError.InvalidResultFile;
+
+    var header = std.ArrayList(u8){};
+    defer header.deinit(allocator);
+    try header.writer(allocator).print("{s}\t{d}\t{d}\t", .{ magic, stat.size, stat.mtime });
+    try writeField(header.writer(allocator), scanner.name orelse "");
+    try header.append(allocator, '\n');
+
+    const out = try std.fs.cwd().createFile(index_path, .{});
+    defer out.close();
+    try out.writeAll(header.items);
+    try out.writeAll(body.items);
+    return count;
+}
+
+/// Tabs and newlines would split the record; file names and set names do
+/// not use them meaningfully
+fn writeField(writer: anytype, text: []const u8) !void {
+    for (text) |c| {
+        try writer.writeByte(if (c == '\t' or c == '\n' or c == '\r') ' ' else c);
+    }
+}
+
+/// Finds the objects of the top-level `constraints` array in a result file,
+/// tracking just enough JSON structure (strings, nesting, and the root keys)
+/// to know where each one starts and ends
+const Scanner = struct {
+    allocator: std.mem.Allocator,
+    /// Set name, when the root `name` key precedes the constraints
+    name: ?[]u8 = null,
+    saw_constraints: bool = false,
+
+    offset: u64 = 0,
+    depth: u32 = 0,
+    in_string: bool = false,
+    escaped: bool = false,
+    in_constraints: bool = false,
+    expecting_key: bool = false,
+    /// Raw text of the current root-level string and the last root key
+    token: std.ArrayList(u8) = .{},
+    key: std.ArrayList(u8) = .{},
+    object: std.ArrayList(u8) = .{},
+    object_start: u64 = 0,
+
+    const Object = struct {
+        offset: u64,
+        /// Valid until the next call to nextObject
+        text: []const u8,
+    };
+
+    fn deinit(self: *Scanner) void {
+        if (self.name) |name| self.allocator.free(name);
+        self.token.deinit(self.allocator);
+        self.key.deinit(self.allocator);
+        self.object.deinit(self.allocator);
+    }
+
+    fn nextObject(self: *Scanner, reader: *std.Io.Reader) !?Object {
+        while (true) {
+            const c = reader.takeByte() catch |err| switch (err) {
+                error.EndOfStream => {
+                    if (self.depth != 0 or self.in_string) return results.ResultError.InvalidResultFile;
+                    return null;
+                },
+                else => return err,
+            };
+            const offset = self.offset;
+            self.offset += 1;
+            if (self.in_constraints and self.depth >= 3) try self.object.append(self.allocator, c);
+
+            if (self.in_string) {
+                if (self.escaped) {
+                    self.escaped = false;
+                } else if (c == '\\') {
+                    self.escaped = true;
+                } else if (c == '"') {
+                    self.in_string = false;
+                    if (self.depth == 1) try self.endRootString();
+                    continue;
+                }
+                if (self.depth == 1) try self.token.append(self.allocator, c);
+                continue;
+            }
+
+            switch (c) {
+                '"' => {
+                    self.in_string = true;
+                    self.token.clearRetainingCapacity();
+                },
+                ':' => if (self.depth == 1) {
+                    self.expecting_key = false;
+                },
+                ',' => if (self.depth == 1) {
+                    self.expecting_key = true;
+                },
+                '{', '[' => {
+                    if (self.depth == 0 and c == '{') self.expecting_key = true;
+                    if (self.depth == 1 and c == '[' and std.mem.eql(u8, self.key.items, "constraints")) {
+                        self.in_constraints = true;
+                        self.saw_constraints = true;
+                    }
+                    if (self.in_constraints and self.depth == 2) {
+                        if (c != '{') return results.ResultError.InvalidResultFile;
+                        self.object.clearRetainingCapacity();
+                        try self.object.append(self.allocator, c);
+                        self.object_start = offset;
+                    }
+                    self.depth += 1;
+                },
+                '}', ']' => {
+                    if (self.depth == 0) return results.ResultError.InvalidResultFile;
+                    self.depth -= 1;
+                    if (self.in_constraints and self.depth == 2) {
+                        return .{ .offset = self.object_start, .text = self.object.items };
+                    }
+                    if (self.in_constraints and self.depth == 1) self.in_constraints = false;
+                },
+                else => {},
+            }
+        }
+    }
+
+    fn endRootString(self: *Scanner) !void {
+        if (self.expecting_key) {
+            self.key.clearRetainingCapacity();
+            try self.key.appendSlice(self.allocator, self.token.items);
+        } else if (std.mem.eql(u8, self.key.items, "name") and self.name == null) {
+            // Still JSON-escaped; decode it as a standalone string
+            const quoted = try std.mem.concat(self.allocator, u8, &.{ "\"", self.token.items, "\"" });
+            defer self.allocator.free(quoted);
+            const decoded = std.json.parseFromSlice([]const u8, self.allocator, quoted, .{}) catch
+                return results.ResultError.InvalidResultFile;
+            defer decoded.deinit();
+            self.name = try self.allocator.dupe(u8, decoded.value);
+        }
+    }
+};
+
+/// An open index and its result file. Entries are read in file order.
+pub const Index = struct {
+    allocator: std.mem.Allocator,
+    results_file: std.fs.File,
+    index_file: std.fs.File,
+    buffer: []u8,
+    reader: std.fs.File.Reader,
+    /// Name of the constraint set, as recorded when the index was built
+    name: []const u8,
+    /// Total number of entries, counted on open
+    count: usize,
+
+    /// Open the index for `results_path`. Returns null when there is none;
+    /// an index older than its result file is reported as StaleIndex.
+    pub fn open(allocator: std.mem.Allocator, results_path: []const u8) !?*Index {
+        const path = try indexPath(allocator, results_path);
+        defer allocator.free(path);
+        const index_file = std.fs.cwd().openFile(path, .{}) catch |err| switch (err) {
+            error.FileNotFound => return null,
+            else => return err,
+        };
+        errdefer index_file.close();
+        const results_file = try std.fs.cwd().openFile(results_path, .{});
+        errdefer results_file.close();
+
+        const self = try allocator.create(Index);
+        errdefer allocator.destroy(self);
+        const buffer = try allocator.alloc(u8, 64 * 1024);
+        errdefer allocator.free(buffer);
+        self.* = .{
+            .allocator = allocator,
+            .results_file = results_file,
+            .index_file = index_file,
+            .buffer = buffer,
+            .reader = index_file.reader(buffer),
+            .name = "",
+            .count = 0,
+        };
+
+        // Header: magic, then the result file's size and mtime at build time
+        const header = try self.takeLine() orelse return IndexError.InvalidIndex;
+        var fields = std.mem.splitScalar(u8, header, '\t');
+        if (!std.mem.eql(u8, fields.next() orelse "", magic)) return IndexError.InvalidIndex;
+        const size = std.fmt.parseInt(u64, fields.next() orelse "", 10) catch return IndexError.InvalidIndex;
+        const mtime = std.fmt.parseInt(i128, fields.next() orelse "", 10) catch return IndexError.InvalidIndex;
+        const stat = try results_file.stat();
+        if (stat.size != size or stat.mtime != mtime) return IndexError.StaleIndex;
+        self.name = try allocator.dupe(u8, fields.rest());
+        errdefer allocator.free(self.name);
+
+        while (try self.takeLine()) |_| self.count += 1;
+        try self.rewind();
+        return self;
+    }
+
+    pub fn close(self: *Index) void {
+        self.results_file.close();
+        self.index_file.close();
+        self.allocator.free(self.buffer);
+        self.allocator.free(self.name);
+        self.allocator.destroy(self);
+    }
+
+    /// Next entry; its file name is valid until the following call
+    pub fn next(self: *Index) !?Entry {
+        const line = try self.takeLine() orelse return null;
+        return parseEntry(line) orelse IndexError.InvalidIndex;
+    }
+
+    /// Read and decode the constraint for `entry`; strings are allocated in
+    /// `arena`
+    pub fn load(self: *Index, arena: std.mem.Allocator, entry: Entry) !constraint.Constraint {
+        const len = std.math.cast(usize, entry.len) orelse return IndexError.InvalidIndex;
+        const text = try arena.alloc(u8, len);
+        if (try self.results_file.preadAll(text, entry.offset) != len) return IndexError.StaleIndex;
+        const value = std.json.parseFromSliceLeaky(std.json.Value, arena, text, .{
+            .allocate = .alloc_always,
+        }) catch return IndexError.StaleIndex;
+        if (value != .object) return IndexError.StaleIndex;
+        var c = try results.parseConstraint(value.object);
+        if (c.id == 0) c.id = entry.id;
+        return c;
+    }
+
+    /// Find one constraint by id without loading the others
+    pub fn find(self: *Index, arena: std.mem.Allocator, id: constraint.ConstraintID) !?constraint.Constraint {
+        try self.rewind();
+        while (try self.next()) |entry| {
+            if (entry.id == id) return try self.load(arena, entry);
+        }
+        return null;
+    }
+
+    /// Restart iteration at the first entry
+    pub fn rewind(self: *Index) !void {
+        self.reader = self.index_file.reader(self.buffer);
+        _ = try self.takeLine() orelse return IndexError.InvalidIndex;
+    }
+
+    fn takeLine(self: *Index) !?[]const u8 {
+        const line = self.reader.interface.takeDelimiterInclusive('\n') catch |err| switch (err) {
+            error.EndOfStream => return null,
+            error.StreamTooLong => return IndexError.InvalidIndex,
+            else => return err,
+        };
+        return std.mem.trimRight(u8, line, "\n");
+    }
+};
+
+/// Open the index for `results_path` when there is a current one. A stale or
+/// unreadable index is reported and skipped so the caller reads the full file.
+pub fn openCurrent(allocator: std.mem.Allocator, results_path: []const u8) !?*Index {
+    if (std.mem.eql(u8, results_path, "-")) return null;
+    return Index.open(allocator, results_path) catch |err| switch (err) {
+        IndexError.StaleIndex, IndexError.InvalidIndex => {
+            cli_error.printInfo("Ignoring out-of-date index for {s}; run `ananke index {s}` to rebuild it", .{ results_path, results_path });
+            return null;
+        },
+        else => return err,
+    };
+}
+
+fn parseEntry(line: []const u8) ?Entry {
+    var fields = std.mem.splitScalar(u8, line, '\t');
+    const id = std.fmt.parseInt(constraint.ConstraintID, fields.next() orelse return null, 10) catch return null;
+    const offset = std.fmt.parseInt(u64, fields.next() orelse return null, 10) catch return null;
+    const len = std.fmt.parseInt(u64, fields.next() orelse return null, 10) catch return null;
+    const kind = std.meta.stringToEnum(constraint.ConstraintKind, fields.next() orelse return null) orelse return null;
+    const severity = std.meta.stringToEnum(constraint.Severity, fields.next() orelse return null) orelse return null;
+    const confidence = std.fmt.parseFloat(f32, fields.next() orelse return null) catch return null;
+    return .{
+        .id = id,
+        .offset = offset,
+        .len = len,
+        .kind = kind,
+        .severity = severity,
+        .confidence = confidence,
+        .file = fields.rest(),
+    };
+}
+
+test "index locates each constraint object in a result file" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    const text =
+        \\{
+        \\  "name": "svc \"core\"",
+        \\  "constraints": [
+        \\    {"id": 7, "kind": "security", "severity": "err", "name": "Token {check}",
+        \\     "description": "brackets ] in strings", "confidence": 0.9, "file": "api/auth.go"},
+        \\    {"id": 9, "kind": "operational", "severity": "warning", "name": "Retry",
+        \\     "description": "", "confidence": 0.5}
+        \\  ],
+        \\  "timings": [{"name": "extract", "ns": 10}]
+        \\}
+    ;
+    try tmp.dir.writeFile(.{ .sub_path = "out.json", .data = text });
+    const results_path = try tmp.dir.realpathAlloc(allocator, "out.json");
+    defer allocator.free(results_path);
+    const index_path = try indexPath(allocator, results_path);
+    defer allocator.free(index_path);
+
+    try testing.expectEqual(@as(usize, 2), try build(allocator, results_path, index_path));
+
+    const index = (try Index.open(allocator, results_path)).?;
+    defer index.close();
+    try testing.expectEqual(@as(usize, 2), index.count);
+    try testing.expectEqualStrings("svc \"core\"", index.name);
+
+    const first = (try index.next()).?;
+    try testing.expectEqual(@as(constraint.ConstraintID, 7), first.id);
+    try testing.expectEqual(constraint.Severity.err, first.severity);
+    try testing.expectEqualStrings("api/auth.go", first.file);
+    const second = (try index.next()).?;
+    try testing.expectEqual(constraint.ConstraintKind.operational, second.kind);
+    try testing.expectEqual(@as(usize, 0), second.file.len);
+    try testing.expect((try index.next()) == null);
+
+    var arena = std.heap.ArenaAllocator.init(allocator);
+    defer arena.deinit();
+    const c = (try index.find(arena.allocator(), 7)).?;
+    try testing.expectEqualStrings("Token {check}", c.name);
+    try testing.expectEqualStrings("brackets ] in strings", c.description);
+    try testing.expect((try index.find(arena.allocator(), 8)) == null);
+
+    // Rewriting the result file invalidates the index
+    try tmp.dir.writeFile(.{ .sub_path = "out.json", .data = "{\"name\": \"x\", \"constraints\": []}" });
+    try testing.expectError(IndexError.StaleIndex, Index.open(allocator, results_path));
+}
@@ -0,0 +1,181 @@
+// Stored extraction results
+// Loads the JSON written by `ananke extract --format json` back into a
+// ConstraintSet, preserving ids and provenance, so commands can inspect a
+// previous run without re-extracting.
+const std = @import("std");
+const ananke = @import("ananke");
+const profiling = @import("cli_profiling");
+const constraint = ananke.types.constraint;
+
+/// Largest result file read into memory
+pub const max_result_bytes = 256 * 1024 * 1024;
+
+pub const ResultError = error{
+    InvalidResultFile,
+};
+
+pub const ResultFile = struct {
+    arena: std.heap.ArenaAllocator,
+    constraint_set: constraint.ConstraintSet,
+    /// Stage timings recorded by `extract --timings`; empty when absent
+    timings: []const profiling.Stage = &.{},
+
+    pub fn deinit(self: *ResultFile) void {
+        self.constraint_set.deinit();
+        self.arena.deinit();
+    }
+
+    /// Load a result file, or stdin when `path` is "-"
+    pub fn loadFile(allocator: std.mem.Allocator, path: []const u8) !ResultFile {
+        const text = if (std.mem.eql(u8, path, "-"))
+            try std.fs.File.stdin().readToEndAlloc(allocator, max_result_bytes)
+        else
+            try std.fs.cwd().readFileAlloc(allocator, path, max_result_bytes);
+        defer allocator.free(text);
+        return parse(allocator, text);
+    }
+
+    pub fn parse(allocator: std.mem.Allocator, text: []const u8) !ResultFile {
+        var result = ResultFile{
+            .arena = std.heap.ArenaAllocator.init(allocator),
+            .constraint_set = undefined,
+        };
+        errdefer result.arena.deinit();
+        const arena = result.arena.allocator();
+
+        // Copy strings into the arena: constraints outlive `text`
+        const parsed = std.json.parseFromSliceLeaky(std.json.Value, arena, text, .{
+            .allocate = .alloc_always,
+        }) catch return ResultError.InvalidResultFile;
+        if (parsed != .object) return ResultError.InvalidResultFile;
+        const root = parsed.object;
+
+        const name = if (root.get("name")) |v| stringValue(v) orelse "" else "";
+        const items = (root.get("constraints") orelse return ResultError.InvalidResultFile);
+        if (items != .array) return ResultError.InvalidResultFile;
+
+        result.constraint_set = constraint.ConstraintSet.init(allocator, name);
+        errdefer result.constraint_set.deinit();
+
+        for (items.array.items) |item| {
+            if (item != .object) return ResultError.InvalidResultFile;
+            try result.constraint_set.add(try parseConstraint(item.object));
+        }
+        if (root.get("timings")) |v| result.timings = try profiling.parseStagesJson(arena, v);
+
+        return result;
+    }
+
+    pub fn findById(self: *const ResultFile, id: constraint.ConstraintID) ?constraint.Constraint {
+        for (self.constraint_set.constraints.items) |c| {
+            if (c.id == id) return c;
+        }
+        return null;
+    }
+};
+
+/// Parse a constraint id as printed in reports: decimal, or the `c-<id>`
+/// anchor used by HTML reports
+pub fn parseId(text: []const u8) ?constraint.ConstraintID {
+    const digits = if (std.mem.startsWith(u8, text, "c-")) text[2..] else text;
+    return std.fmt.parseInt(constraint.ConstraintID, digits, 10) catch null;
+}
+
+/// Decode one element of the `constraints` array; strings borrow from `obj`
+pub fn parseConstraint(obj: std.json.ObjectMap) !constraint.Constraint {
+    const name = stringValue(obj.get("name") orelse return ResultError.InvalidResultFile) orelse return ResultError.InvalidResultFile;
+    const kind_str = if (obj.get("kind")) |v| stringValue(v) orelse "" else "";
+
+    var c = constraint.Constraint{
+        .name = name,
+        .description = if (obj.get("description")) |v| stringValue(v) orelse "" else "",
+        .kind = std.meta.stringToEnum(constraint.ConstraintKind, kind_str) orelse .semantic,
+        .severity = .info,
+    };
+
+    if (obj.get("id")) |v| c.id = idValue(v) orelse 0;
+    if (obj.get("severity")) |v| {
+        const s = stringValue(v) orelse "";
+        c.severity = if (std.mem.eql(u8, s, "error")) .err else std.meta.stringToEnum(constraint.Severity, s) orelse .info;
+    }
+    if (obj.get("source")) |v| {
+        if (std.meta.stringToEnum(constraint.ConstraintSource, stringValue(v) orelse "")) |source| c.source = source;
+    }
+    if (obj.get("enforcement")) |v| {
+        if (std.meta.stringToEnum(constraint.EnforcementType, stringValue(v) orelse "")) |enforcement| c.enforcement = enforcement;
+    }
+    if (obj.get("priority")) |v| {
+        if (std.meta.stringToEnum(constraint.ConstraintPriority, stringValue(v) orelse "")) |priority| c.priority = priority;
+    }
+    if (obj.get("confidence")) |v| {
+        c.confidence = switch (v) {
+            .float => |f| @floatCast(f),
+            .integer => |i| @floatFromInt(i),
+            else => c.confidence,
+        };
+    }
+    if (obj.get("frequency")) |v| {
+        if (v == .integer and v.integer >= 0) c.frequency = std.math.cast(u32, v.integer) orelse c.frequency;
+    }
+    if (obj.get("file")) |v| c.origin_file = stringValue(v);
+    if (obj.get("line")) |v| {
+        if (v == .integer and v.integer > 0) c.origin_line = std.math.cast(u32, v.integer);
+    }
+
+    return c;
+}
+
+fn stringValue(v: std.json.Value) ?[]const u8 {
+    return switch (v) {
+        .string => |s| s,
+        else => null,
+    };
+}
+
+/// Ids are u64 and may exceed the JSON integer range std.json decodes as i64
+fn idValue(v: std.json.Value) ?constraint.ConstraintID {
+    return switch (v) {
+        .integer => |i| std.math.cast(constraint.ConstraintID, i),
+        .number_string => |s| std.fmt.parseInt(constraint.ConstraintID, s, 10) catch null,
+        .string => |s| parseId(s),
+        else => null,
+    };
+}
+
+test "result file round trips extract json" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const text =
+        \\{
+        \\  "name": "svc",
+        \\  "constraints": [
+        \\    {
+        \\      "id": 18446744073709551000,
+        \\      "kind": "security",
+        \\      "severity": "err",
+        \\      "name": "Input validation",
+        \\      "description": "Input validation detected at line 4 in go code",
+        \\      "source": "AST_Pattern",
+        \\      "enforcement": "Security",
+        \\      "priority": "High",
+        \\      "confidence": 0.85,
+        \\      "file": "api/handler.go",
+        \\      "line": 4,
+        \\      "frequency": 1
+        \\    }
+        \\  ]
+        \\}
+    ;
+
+    var result = try ResultFile.parse(allocator, text);
+    defer result.deinit();
+
+    const c = result.findById(18446744073709551000).?;
+    try testing.expectEqualStrings("Input validation", c.name);
+    try testing.expectEqual(constraint.Severity.err, c.severity);
+    try testing.expectEqual(constraint.ConstraintPriority.High, c.priority);
+    try testing.expectEqualStrings("api/handler.go", c.origin_file.?);
+    try testing.expectEqual(@as(u32, 4), c.origin_line.?);
+    try testing.expectEqual(@as(?constraint.ConstraintID, 42), parseId("c-42"));
+}
@@ -0,0 +1,224 @@
+// Rule-set history for selective cache invalidation
+// Cache keys include a digest of the language's pattern rules, so any rule
+// change misses every entry of that language. Most of those entries are
+// still right: pattern rules are literal strings, and a rule whose pattern
+// occurs nowhere in a file cannot have matched it under the old rule set or
+// match it under the new one. So the cache keeps the last few rule sets of
+// each language, and on a miss an entry computed under an earlier set is
+// reused when none of the rules added, removed, or edited since occurs in
+// the file. Enabling one new rule then re-extracts only the files that
+// contain its pattern.
+//
+//   <cache>/rules/<language>.history   rule-set digests, newest first
+//   <cache>/rules/<digest>.rules       one line per rule: fingerprint, pattern
+const std = @import("std");
+const ananke = @import("ananke");
+const cache_store = @import("cli_cache_store");
+
+const patterns = ananke.clew.patterns;
+
+const magic = "ananke-rules 1";
+/// Earlier rule sets consulted per language; each costs one extra key
+/// computation on a miss in a file none of its changes touch
+const max_history = 4;
+const max_file_bytes = 4 * 1024 * 1024;
+
+pub const Digest = [16]u8;
+
+/// An earlier rule set and what changed since
+pub const Previous = struct {
+    digest: Digest,
+    /// Patterns of the rules added, removed, or edited since this set
+    changed: []const []const u8,
+
+    /// Whether extracting `source` under this set gave the same result as
+    /// under the current one
+    pub fn unaffected(self: Previous, source: []const u8) bool {
+        for (self.changed) |pattern| {
+            if (std.mem.indexOf(u8, source, pattern) != null) return false;
+        }
+        return true;
+    }
+};
+
+/// Earlier rule sets per language, loaded on first use. Not thread-safe;
+/// used by the thread that owns the cache.
+pub const History = struct {
+    arena: std.heap.ArenaAllocator,
+    dir: std.fs.Dir,
+    languages: std.StringHashMapUnmanaged([]const Previous) = .{},
+
+    pub fn init(allocator: std.mem.Allocator, cache_dir: std.fs.Dir) History {
+        return .{ .arena = std.heap.ArenaAllocator.init(allocator), .dir = cache_dir };
+    }
+
+    pub fn deinit(self: *History) void {
+        self.arena.deinit();
+    }
+
+    /// Earlier rule sets of `language` whose entries may be reused, newest
+    /// first. The first call for a language records `digest` as its current
+    /// set. Unreadable history only means nothing is reused.
+    pub fn previous(self: *History, language: []const u8, digest: *const Digest) []const Previous {
+        if (self.languages.get(language)) |list| return list;
+        const list = self.load(language, digest) catch &.{};
+        const arena = self.arena.allocator();
+        const name = arena.dupe(u8, language) catch return list;
+        self.languages.put(arena, name, list) catch {};
+        return list;
+    }
+
+    fn load(self: *History, language: []const u8, digest: *const Digest) ![]const Previous {
+        const arena = self.arena.allocator();
+        const current = try languageRules(arena, language);
+        // A language without rules takes another extraction path entirely
+        if (current.len == 0) return &.{};
+
+        try self.dir.makePath(cache_store.rules_dir);
+        var path_buf: [std.fs.max_path_bytes]u8 = undefined;
+        const rules_path = try std.fmt.bufPrint(&path_buf, "{s}/{s}.rules", .{ cache_store.rules_dir, digest });
+        self.dir.access(rules_path, .{}) catch try saveRules(arena, self.dir, rules_path, current);
+
+        var history_buf: [std.fs.max_path_bytes]u8 = undefined;
+        const history_path = try std.fmt.bufPrint(&history_buf, "{s}/{s}.history", .{ cache_store.rules_dir, language });
+        const text = self.dir.readFileAlloc(arena, history_path, max_file_bytes) catch "";
+
+        var list = std.ArrayList(Previous){};
+        var digests = std.ArrayList(u8){};
+        const writer = digests.writer(arena);
+        try writer.print("{s}\n", .{digest});
+        var lines = std.mem.tokenizeScalar(u8, text, '\n');
+        var kept: usize = 1;
+        while (lines.next()) |line| {
+            if (line.len != digest.len or std.mem.eql(u8, line, digest)) continue;
+            if (kept == max_history) break;
+            kept += 1;
+            try writer.print("{s}\n", .{line});
+
+            const earlier = loadRules(arena, self.dir, line) orelse continue;
+            if (earlier.len == 0) continue;
+            const changed = try changedPatterns(arena, earlier, current) orelse continue;
+            try list.append(arena, .{ .digest = line[0..digest.len].*, .changed = changed });
+        }
+        if (!std.mem.eql(u8, text, digests.items)) try cache_store.writeAtomic(self.dir, history_path, digests.items);
+        return list.items;
+    }
+};
+
+const Rule = struct {
+    fingerprint: u64,
+    pattern: []const u8,
+};
+
+/// A language's rules in scan order
+fn languageRules(arena: std.mem.Allocator, language: []const u8) ![]const Rule {
+    var rules = std.ArrayList(Rule){};
+    const lang_patterns = patterns.getPatternsForLanguage(language) orelse return rules.items;
+    inline for (std.meta.fields(patterns.LanguagePatterns)) |field| {
+        for (@field(lang_patterns, field.name)) |rule| {
+            var hasher = std.hash.Wyhash.init(0);
+            hasher.update(field.name);
+            hasher.update(&.{0});
+            hasher.update(rule.pattern);
+            hasher.update(&.{0});
+            hasher.update(@tagName(rule.constraint_kind));
+            hasher.update(&.{0});
+            hasher.update(rule.description);
+            try rules.append(arena, .{ .fingerprint = hasher.final(), .pattern = rule.pattern });
+        }
+    }
+    return rules.items;
+}
+
+/// Patterns of the rules in one set but not the other, or null when the sets
+/// hold the same rules in another order, which can change which of two
+/// rules wins a position anywhere
+fn changedPatterns(arena: std.mem.Allocator, earlier: []const Rule, current: []const Rule) !?[]const []const u8 {
+    var changed = std.ArrayList([]const u8){};
+    for ([_][2][]const Rule{ .{ earlier, current }, .{ current, earlier } }) |pair| {
+        for (pair[0]) |rule| {
+            if (containsRule(pair[1], rule.fingerprint)) continue;
+            // An empty pattern never matches
+            if (rule.pattern.len > 0) try changed.append(arena, rule.pattern);
+        }
+    }
+    if (changed.items.len == 0) return null;
+    return changed.items;
+}
+
+fn containsRule(rules: []const Rule, fingerprint: u64) bool {
+    for (rules) |rule| {
+        if (rule.fingerprint == fingerprint) return true;
+    }
+    return false;
+}
+
+fn saveRules(arena: std.mem.Allocator, dir: std.fs.Dir, path: []const u8, rules: []const Rule) !void {
+    var text = std.ArrayList(u8){};
+    const writer = text.writer(arena);
+    try writer.print("{s}\n", .{magic});
+    for (rules) |rule| {
+        // Hex keeps patterns with spaces or newlines on one line
+        try writer.print("{x:0>16} {x}\n", .{ rule.fingerprint, rule.pattern });
+    }
+    try cache_store.writeAtomic(dir, path, text.items);
+}
+
+fn loadRules(arena: std.mem.Allocator, dir: std.fs.Dir, digest: []const u8) ?[]const Rule {
+    var path_buf: [std.fs.max_path_bytes]u8 = undefined;
+    const path = std.fmt.bufPrint(&path_buf, "{s}/{s}.rules", .{ cache_store.rules_dir, digest }) catch return null;
+    const text = dir.readFileAlloc(arena, path, max_file_bytes) catch return null;
+
+    var rules = std.ArrayList(Rule){};
+    var lines = std.mem.splitScalar(u8, text, '\n');
+    if (!std.mem.eql(u8, lines.next() orelse "", magic)) return null;
+    while (lines.next()) |line| {
+        if (line.len == 0) continue;
+        const space = std.mem.indexOfScalar(u8, line, ' ') orelse return null;
+        const hex = line[space + 1 ..];
+        if (hex.len % 2 != 0) return null;
+        const pattern = arena.alloc(u8, hex.len / 2) catch return null;
+        _ = std.fmt.hexToBytes(pattern, hex) catch return null;
+        rules.append(arena, .{
+            .fingerprint = std.fmt.parseInt(u64, line[0..space], 16) catch return null,
+            .pattern = pattern,
+        }) catch return null;
+    }
+    return rules.items;
+}
+
+test "entries from an earlier rule set are reused where no changed rule occurs" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+
+    // Pretend an earlier build had one rule fewer and one rule edited
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+    const current = try languageRules(arena, "go");
+    try testing.expect(current.len > 2);
+    const earlier = try arena.dupe(Rule, current[1..]);
+    earlier[0].fingerprint +%= 1;
+    try tmp.dir.makePath(cache_store.rules_dir);
+    try saveRules(arena, tmp.dir, "rules/0123456789abcdef.rules", earlier);
+    try tmp.dir.writeFile(.{ .sub_path = "rules/go.history", .data = "0123456789abcdef\n" });
+
+    var history = History.init(allocator, tmp.dir);
+    defer history.deinit();
+    const digest: Digest = "fedcba9876543210".*;
+    const list = history.previous("go", &digest);
+    try testing.expectEqual(@as(usize, 1), list.len);
+    try testing.expectEqualStrings("0123456789abcdef", &list[0].digest);
+    // The missing rule, and the edited rule before and after
+    try testing.expectEqual(@as(usize, 3), list[0].changed.len);
+    try testing.expect(list[0].unaffected(""));
+    try testing.expect(!list[0].unaffected(current[0].pattern));
+
+    // The current set is recorded, newest first
+    const text = try tmp.dir.readFileAlloc(allocator, "rules/go.history", max_file_bytes);
+    defer allocator.free(text);
+    try testing.expectEqualStrings("fedcba9876543210\n0123456789abcdef\n", text);
+}
@@ -0,0 +1,609 @@
+// Semgrep rule import
+// Converts Semgrep rules into Ariadne constraints, so existing rulepacks can
+// be reused instead of rewritten. Only the subset that maps onto a single
+// tree-sitter query is converted: rules whose `pattern` (or each alternative
+// of `pattern-either`) is a call of a named function or method, such as
+// `eval(...)` or `$DB.raw(...)`, in JavaScript, TypeScript, Python, Go, or
+// Java. Rules using `patterns`, regexes, taint mode, or other pattern forms
+// are reported as skipped with the reason.
+//
+// Rule files are read with a small YAML reader covering what rulepacks use:
+// block mappings and sequences, flow sequences of scalars, quoted scalars,
+// and `|`/`>` block scalars.
+const std = @import("std");
+
+/// A parsed YAML node. Scalars are kept as text.
+pub const Node = union(enum) {
+    scalar: []const u8,
+    list: []const Node,
+    map: []const Entry,
+
+    pub const Entry = struct {
+        key: []const u8,
+        value: Node,
+    };
+
+    /// Value of `key` in a mapping
+    pub fn get(self: Node, key: []const u8) ?Node {
+        if (self != .map) return null;
+        for (self.map) |entry| {
+            if (std.mem.eql(u8, entry.key, key)) return entry.value;
+        }
+        return null;
+    }
+
+    /// Scalar value of `key` in a mapping
+    pub fn getString(self: Node, key: []const u8) ?[]const u8 {
+        const value = self.get(key) orelse return null;
+        return if (value == .scalar) value.scalar else null;
+    }
+};
+
+pub const ParseError = error{InvalidYaml} || std.mem.Allocator.Error;
+
+const Line = struct {
+    indent: usize,
+    text: []const u8,
+};
+
+/// Parse a YAML document. All memory comes from `arena`.
+pub fn parseYaml(arena: std.mem.Allocator, text: []const u8) ParseError!Node {
+    var lines = std.ArrayList(Line){};
+    var it = std.mem.splitScalar(u8, text, '\n');
+    while (it.next()) |raw| {
+        const line = std.mem.trimRight(u8, raw, " \t\r");
+        const content = std.mem.trimLeft(u8, line, " ");
+        if (std.mem.eql(u8, content, "---")) continue;
+        try lines.append(arena, .{ .indent = line.len - content.len, .text = content });
+    }
+    var reader = Reader{ .arena = arena, .lines = lines.items };
+    if (!reader.skipBlank()) return .{ .map = &.{} };
+    return reader.node(reader.lines[reader.pos].indent);
+}
+
+const Reader = struct {
+    arena: std.mem.Allocator,
+    lines: []Line,
+    pos: usize = 0,
+
+    /// Move to the next line with content; false at the end
+    fn skipBlank(self: *Reader) bool {
+        while (self.pos < self.lines.len) : (self.pos += 1) {
+            const text = self.lines[self.pos].text;
+            if (text.len > 0 and text[0] != '#') return true;
+        }
+        return false;
+    }
+
+    fn node(self: *Reader, indent: usize) ParseError!Node {
+        const text = self.lines[self.pos].text;
+        if (isItem(text)) return self.sequence(indent);
+        return self.mapping(indent);
+    }
+
+    fn sequence(self: *Reader, indent: usize) ParseError!Node {
+        var items = std.ArrayList(Node){};
+        while (self.skipBlank()) {
+            const line = &self.lines[self.pos];
+            if (line.indent != indent or !isItem(line.text)) break;
+            const rest = std.mem.trimLeft(u8, line.text[1..], " ");
+            if (rest.len == 0) {
+                self.pos += 1;
+                if (!self.skipBlank() or self.lines[self.pos].indent <= indent) {
+                    try items.append(self.arena, .{ .scalar = "" });
+                    continue;
+                }
+                try items.append(self.arena, try self.node(self.lines[self.pos].indent));
+            } else if (isItem(rest) or mappingKey(rest) != null) {
+                // "- key: value" opens a mapping (or "- - x" a sequence) at the item's column
+                line.* = .{ .indent = indent + (line.text.len - rest.len), .text = rest };
+                try items.append(self.arena, try self.node(line.indent));
+            } else {
+                self.pos += 1;
+                try items.append(self.arena, try self.scalarNode(rest));
+            }
+        }
+        return .{ .list = try items.toOwnedSlice(self.arena) };
+    }
+
+    fn mapping(self: *Reader, indent: usize) ParseError!Node {
+        var entries = std.ArrayList(Node.Entry){};
+        while (self.skipBlank()) {
+            const line = self.lines[self.pos];
+            if (line.indent < indent) break;
+            if (line.indent > indent or isItem(line.text)) return error.InvalidYaml;
+            const split = mappingKey(line.text) orelse return error.InvalidYaml;
+            self.pos += 1;
+
+            const value: Node = if (split.value.len == 0) blk: {
+                if (!self.skipBlank()) break :blk .{ .scalar = "" };
+                const next = self.lines[self.pos];
+                // A sequence may sit at its key's indentation
+                if (next.indent > indent or (next.indent == indent and isItem(next.text))) {
+                    break :blk try self.node(next.indent);
+                }
+                break :blk .{ .scalar = "" };
+            } else if (split.value[0] == '|' or split.value[0] == '>')
+                .{ .scalar = try self.blockScalar(indent, split.value) }
+            else
+                try self.scalarNode(split.value);
+            try entries.append(self.arena, .{ .key = unquote(split.key), .value = value });
+        }
+        return .{ .map = try entries.toOwnedSlice(self.arena) };
+    }
+
+    /// Lines of a `|` or `>` scalar: everything indented past its key
+    fn blockScalar(self: *Reader, indent: usize, header: []const u8) ParseError![]const u8 {
+        const folded = header[0] == '>';
+        const keep_newline = std.mem.indexOfScalar(u8, header, '-') == null;
+        var text = std.ArrayList(u8){};
+        var block_indent: ?usize = null;
+        var pending_newlines: usize = 0;
+        while (self.pos < self.lines.len) : (self.pos += 1) {
+            const line = self.lines[self.pos];
+            if (line.text.len == 0) {
+                pending_newlines += 1;
+                continue;
+            }
+            if (line.indent <= indent) break;
+            const base = block_indent orelse line.indent;
+            block_indent = base;
+            if (text.items.len > 0) {
+                if (pending_newlines > 0) {
+                    try text.appendNTimes(self.arena, '\n', if (folded) pending_newlines else pending_newlines + 1);
+                } else {
+                    try text.append(self.arena, if (folded) ' ' else '\n');
+                }
+            }
+            pending_newlines = 0;
+            // Deeper lines keep their extra indentation
+            try text.appendNTimes(self.arena, ' ', line.indent -| base);
+            try text.appendSlice(self.arena, line.text);
+        }
+        if (keep_newline and text.items.len > 0) try text.append(self.arena, '\n');
+        return text.items;
+    }
+
+    fn scalarNode(self: *Reader, raw: []const u8) ParseError!Node {
+        const value = stripComment(raw);
+        if (value.len >= 2 and value[0] == '[' and value[value.len - 1] == ']') {
+            var items = std.ArrayList(Node){};
+            var start: usize = 1;
+            var quote: ?u8 = null;
+            for (value[1 .. value.len - 1], 1..) |c, i| {
+                if (quote) |q| {
+                    if (c == q) quote = null;
+                } else if (c == '"' or c == '\'') {
+                    quote = c;
+                } else if (c == ',') {
+                    try items.append(self.arena, .{ .scalar = try self.unescape(value[start..i]) });
+                    start = i + 1;
+                }
+            }
+            const last = std.mem.trim(u8, value[start .. value.len - 1], " ");
+            if (last.len > 0) try items.append(self.arena, .{ .scalar = try self.unescape(last) });
+            return .{ .list = try items.toOwnedSlice(self.arena) };
+        }
+        return .{ .scalar = try self.unescape(value) };
+    }
+
+    /// A scalar without its quotes, with the escapes rules use resolved
+    fn unescape(self: *Reader, raw: []const u8) ParseError![]const u8 {
+        const value = std.mem.trim(u8, raw, " ");
+        if (value.len < 2) return value;
+        if (value[0] == '\'' and value[value.len - 1] == '\'') {
+            return std.mem.replaceOwned(u8, self.arena, value[1 .. value.len - 1], "''", "'");
+        }
+        if (value[0] != '"' or value[value.len - 1] != '"') return value;
+        var text = std.ArrayList(u8){};
+        var i: usize = 1;
+        while (i < value.len - 1) : (i += 1) {
+            if (value[i] != '\\' or i + 1 == value.len - 1) {
+                try text.append(self.arena, value[i]);
+                continue;
+            }
+            i += 1;
+            try text.append(self.arena, switch (value[i]) {
+                'n' => '\n',
+                't' => '\t',
+                else => value[i],
+            });
+        }
+        return text.items;
+    }
+};
+
+fn isItem(text: []const u8) bool {
+    return std.mem.eql(u8, text, "-") or std.mem.startsWith(u8, text, "- ");
+}
+
+/// Split "key: value" outside quotes; null when the line is not a mapping entry
+fn mappingKey(text: []const u8) ?struct { key: []const u8, value: []const u8 } {
+    var quote: ?u8 = null;
+    for (text, 0..) |c, i| {
+        if (quote) |q| {
+            if (c == q) quote = null;
+        } else if ((c == '"' or c == '\'') and i == 0) {
+            quote = c;
+        } else if (c == ':' and (i + 1 == text.len or text[i + 1] == ' ')) {
+            return .{ .key = std.mem.trimRight(u8, text[0..i], " "), .value = std.mem.trim(u8, text[i + 1 ..], " ") };
+        } else if (c == '[' or c == '{' or c == '(') {
+            // Flow collections and code are values, never keys
+            return null;
+        }
+    }
+    return null;
+}
+
+fn unquote(text: []const u8) []const u8 {
+    if (text.len >= 2 and (text[0] == '"' or text[0] == '\'') and text[text.len - 1] == text[0]) return text[1 .. text.len - 1];
+    return text;
+}
+
+/// A plain scalar without its trailing comment
+fn stripComment(raw: []const u8) []const u8 {
+    const value = std.mem.trim(u8, raw, " ");
+    if (value.len > 0 and (value[0] == '"' or value[0] == '\'')) return value;
+    const comment = std.mem.indexOf(u8, value, " #") orelse return value;
+    return std.mem.trimRight(u8, value[0..comment], " ");
+}
+
+/// Languages a call pattern can be converted for, by Semgrep language name
+pub const Language = enum {
+    javascript,
+    typescript,
+    python,
+    go,
+    java,
+
+    pub fn fromSemgrep(name: []const u8) ?Language {
+        const aliases = [_]struct { []const u8, Language }{
+            .{ "js", .javascript },
+            .{ "javascript", .javascript },
+            .{ "ts", .typescript },
+            .{ "typescript", .typescript },
+            .{ "py", .python },
+            .{ "python", .python },
+            .{ "go", .go },
+            .{ "golang", .go },
+            .{ "java", .java },
+        };
+        for (aliases) |alias| {
+            if (std.ascii.eqlIgnoreCase(name, alias[0])) return alias[1];
+        }
+        return null;
+    }
+};
+
+/// Node and field names of call expressions in a language's tree-sitter grammar
+const Grammar = struct {
+    call: []const u8,
+    /// Field holding the callee; null when the call node holds the object and name itself
+    callee: ?[]const u8,
+    member: []const u8 = "",
+    object: []const u8,
+    property: []const u8,
+    property_node: []const u8,
+};
+
+fn grammar(language: Language) Grammar {
+    return switch (language) {
+        .javascript, .typescript => .{ .call = "call_expression", .callee = "function", .member = "member_expression", .object = "object", .property = "property", .property_node = "property_identifier" },
+        .python => .{ .call = "call", .callee = "function", .member = "attribute", .object = "object", .property = "attribute", .property_node = "identifier" },
+        .go => .{ .call = "call_expression", .callee = "function", .member = "selector_expression", .object = "operand", .property = "field", .property_node = "field_identifier" },
+        .java => .{ .call = "method_invocation", .callee = null, .object = "object", .property = "name", .property_node = "identifier" },
+    };
+}
+
+/// A call pattern: `name(...)` or `object.name(...)`; null parts are
+/// metavariables and match anything
+pub const Call = struct {
+    /// Called as a method, `object.name(...)`
+    member: bool = false,
+    object: ?[]const u8 = null,
+    function: ?[]const u8 = null,
+
+    /// Parse a Semgrep pattern; null when it is not a call of a named function
+    pub fn parse(pattern: []const u8) ?Call {
+        const text = std.mem.trim(u8, pattern, " \t\r\n");
+        if (text.len == 0 or text[text.len - 1] != ')') return null;
+        const open = std.mem.indexOfScalar(u8, text, '(') orelse return null;
+        if (!balanced(text[open..])) return null;
+
+        const callee = std.mem.trim(u8, text[0..open], " ");
+        if (callee.len == 0) return null;
+        for (callee) |c| {
+            if (!std.ascii.isAlphanumeric(c) and c != '_' and c != '$' and c != '.') return null;
+        }
+        const dot = std.mem.lastIndexOfScalar(u8, callee, '.');
+        const function = if (dot) |d| callee[d + 1 ..] else callee;
+        if (!isName(function) and !isMetavariable(function)) return null;
+
+        var call = Call{ .member = dot != null };
+        if (!isMetavariable(function)) call.function = function;
+        if (dot) |d| {
+            const object = callee[0..d];
+            if (!isMetavariable(object)) {
+                // Metavariables inside a dotted object cannot be expressed
+                var segments = std.mem.splitScalar(u8, object, '.');
+                while (segments.next()) |segment| {
+                    if (!isName(segment)) return null;
+                }
+                call.object = object;
+            }
+        }
+        // `$F(...)` and `$X.$M(...)` match every call
+        if (call.function == null and call.object == null) return null;
+        return call;
+    }
+
+    /// Write the tree-sitter query for this call, with Ariadne `where`
+    /// conditions on the captured names
+    pub fn writeQuery(self: Call, writer: anytype, language: Language, indent: []const u8) !void {
+        const g = grammar(language);
+        try writer.print("{s}({s}", .{ indent, g.call });
+        if (!self.member) {
+            try writer.print("\n{s}    {s}: (identifier) @function)", .{ indent, g.callee orelse g.property });
+        } else if (g.callee) |callee| {
+            try writer.print("\n{s}    {s}: ({s}\n{s}        {s}: (_) @object\n{s}        {s}: ({s}) @function))", .{
+                indent, callee, g.member, indent, g.object, indent, g.property, g.property_node,
+            });
+        } else {
+            try writer.print("\n{s}    {s}: (_) @object\n{s}    {s}: ({s}) @function)", .{
+                indent, g.object, indent, g.property, g.property_node,
+            });
+        }
+
+        var keyword: []const u8 = "where";
+        if (self.function) |function| {
+            try writer.print("\n{s}{s} @function in [\"{s}\"]", .{ indent, keyword, function });
+            keyword = "and";
+        }
+        if (self.object) |object| {
+            try writer.print("\n{s}{s} @object in [\"{s}\"]", .{ indent, keyword, object });
+        }
+    }
+};
+
+fn isName(text: []const u8) bool {
+    if (text.len == 0 or std.ascii.isDigit(text[0])) return false;
+    for (text) |c| {
+        if (!std.ascii.isAlphanumeric(c) and c != '_') return false;
+    }
+    return true;
+}
+
+fn isMetavariable(text: []const u8) bool {
+    return text.len > 1 and text[0] == '$' and isName(text[1..]);
+}
+
+fn balanced(text: []const u8) bool {
+    var depth: usize = 0;
+    for (text) |c| switch (c) {
+        '(' => depth += 1,
+        ')' => {
+            if (depth == 0) return false;
+            depth -= 1;
+        },
+        else => {},
+    };
+    return depth == 0;
+}
+
+/// Outcome of converting one rule
+pub const Conversion = union(enum) {
+    /// Number of constraints written
+    converted: usize,
+    /// Why the rule was left out
+    skipped: []const u8,
+};
+
+/// Keys whose presence means the rule needs more than one call pattern
+const unsupported_keys = [_][]const u8{ "patterns", "pattern-regex", "pattern-not", "pattern-inside", "pattern-sources", "join", "match" };
+
+/// Write the Ariadne constraints of one Semgrep rule: one per call pattern and language
+pub fn convertRule(arena: std.mem.Allocator, writer: anytype, rule: Node, source_file: []const u8) !Conversion {
+    const rule_id = rule.getString("id") orelse return .{ .skipped = "rule has no id" };
+    if (rule.getString("mode")) |mode| {
+        if (!std.mem.eql(u8, mode, "search")) return .{ .skipped = try std.fmt.allocPrint(arena, "{s} mode is not supported", .{mode}) };
+    }
+    for (unsupported_keys) |key| {
+        if (rule.get(key) != null) return .{ .skipped = try std.fmt.allocPrint(arena, "{s} is not supported", .{key}) };
+    }
+
+    // The call patterns: `pattern`, or every `pattern` of `pattern-either`
+    var patterns = std.ArrayList([]const u8){};
+    if (rule.getString("pattern")) |pattern| {
+        try patterns.append(arena, pattern);
+    } else if (rule.get("pattern-either")) |either| {
+        if (either != .list) return .{ .skipped = "pattern-either is not a list" };
+        for (either.list) |alternative| {
+            const pattern = alternative.getString("pattern") orelse
+                return .{ .skipped = "pattern-either holds more than plain patterns" };
+            try patterns.append(arena, pattern);
+        }
+    } else return .{ .skipped = "rule has no pattern" };
+
+    var calls = std.ArrayList(Call){};
+    for (patterns.items) |pattern| {
+        const call = Call.parse(pattern) orelse return .{
+            .skipped = try std.fmt.allocPrint(arena, "pattern \"{s}\" is not a call of a named function", .{std.mem.trim(u8, pattern, " \t\r\n")}),
+        };
+        try calls.append(arena, call);
+    }
+
+    var languages = std.ArrayList(Language){};
+    if (rule.get("languages")) |list| {
+        const single = [_]Node{list};
+        const names: []const Node = if (list == .list) list.list else &single;
+        for (names) |name| {
+            if (name != .scalar) continue;
+            const language = Language.fromSemgrep(name.scalar) orelse continue;
+            if (std.mem.indexOfScalar(Language, languages.items, language) == null) try languages.append(arena, language);
+        }
+    }
+    if (languages.items.len == 0) return .{ .skipped = "none of its languages can be converted" };
+
+    const message = rule.getString("message") orelse rule_id;
+    const severity = rule.getString("severity") orelse "WARNING";
+    const metadata = rule.get("metadata") orelse Node{ .map = &.{} };
+    const confidence: f32 = if (metadata.getString("confidence")) |level|
+        if (std.ascii.eqlIgnoreCase(level, "HIGH")) 0.9 else if (std.ascii.eqlIgnoreCase(level, "LOW")) 0.5 else 0.7
+    else
+        0.8;
+
+    var written: usize = 0;
+    for (languages.items) |language| {
+        for (calls.items, 0..) |call, i| {
+            try writer.writeAll("\nconstraint ");
+            try writeIdentifier(writer, rule_id);
+            if (languages.items.len > 1) try writer.print("_{s}", .{@tagName(language)});
+            if (calls.items.len > 1) try writer.print("_{d}", .{i + 1});
+            try writer.writeAll(" {\n    id: \"semgrep.");
+            try writeString(writer, rule_id);
+            if (languages.items.len > 1) try writer.print(".{s}", .{@tagName(language)});
+            if (calls.items.len > 1) try writer.print(".{d}", .{i + 1});
+            try writer.writeAll("\",\n    name: \"");
+            try writeIdentifier(writer, rule_id);
+            try writer.writeAll("\",\n    description: \"");
+            try writeString(writer, message);
+            try writer.print("\",\n\n    enforcement: .Structural({{\n        pattern: query({s}) {{\n", .{@tagName(language)});
+            try call.writeQuery(writer, language, "            ");
+            try writer.print("\n        }},\n        action: {s},\n        language: \"{s}\"\n    }}),\n\n", .{
+                if (isError(severity)) ".Block" else ".Warn",
+                @tagName(language),
+            });
+
+            try writer.print("    provenance: {{\n        source: .ManualPolicy,\n        confidence_score: {d:.2},\n        origin_artifact: \"", .{confidence});
+            try writeString(writer, source_file);
+            try writer.writeAll("\",\n        rationale: \"Imported from Semgrep rule ");
+            try writeString(writer, rule_id);
+            try writer.writeAll("\"\n    },\n\n");
+
+            if (isError(severity)) {
+                try writer.writeAll("    failure_mode: .HardBlock,\n\n");
+            } else {
+                try writer.writeAll("    failure_mode: .Warn({\n        message: \"");
+                try writeString(writer, message);
+                try writer.print("\",\n        severity: {s}\n    }}),\n\n", .{
+                    if (std.ascii.eqlIgnoreCase(severity, "INFO") or std.ascii.eqlIgnoreCase(severity, "LOW")) ".Low" else ".Medium",
+                });
+            }
+
+            try writer.writeAll("    metadata: {\n        \"semgrep_id\": \"");
+            try writeString(writer, rule_id);
+            try writer.writeAll("\"");
+            for ([_][]const u8{ "category", "cwe", "owasp", "references" }) |key| {
+                const value = metadata.get(key) orelse continue;
+                // Lists keep their first entry
+                const text = switch (value) {
+                    .scalar => |s| s,
+                    .list => |items| if (items.len > 0 and items[0] == .scalar) items[0].scalar else continue,
+                    .map => continue,
+                };
+                try writer.print(",\n        \"{s}\": \"", .{key});
+                try writeString(writer, text);
+                try writer.writeAll("\"");
+            }
+            try writer.writeAll("\n    }\n}\n");
+            written += 1;
+        }
+    }
+    return .{ .converted = written };
+}
+
+fn isError(severity: []const u8) bool {
+    for ([_][]const u8{ "ERROR", "CRITICAL", "HIGH" }) |level| {
+        if (std.ascii.eqlIgnoreCase(severity, level)) return true;
+    }
+    return false;
+}
+
+/// Write text for an Ariadne string. Strings are not unescaped when parsed,
+/// so text is kept to one line with quotes and backslashes escaped.
+fn writeString(writer: anytype, text: []const u8) !void {
+    var space = false;
+    for (text) |c| switch (c) {
+        '\n', '\r', '\t', ' ' => space = true,
+        else => {
+            if (space) try writer.writeByte(' ');
+            space = false;
+            if (c == '"' or c == '\\') try writer.writeByte('\\');
+            try writer.writeByte(c);
+        },
+    };
+}
+
+/// Rule ids use dashes and dots; constraint names are identifiers
+fn writeIdentifier(writer: anytype, id: []const u8) !void {
+    if (id.len > 0 and std.ascii.isDigit(id[0])) try writer.writeByte('_');
+    for (id) |c| try writer.writeByte(if (std.ascii.isAlphanumeric(c)) std.ascii.toLower(c) else '_');
+}
+
+test "semgrep call rules convert to ariadne constraints" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const doc = try parseYaml(arena,
+        \\rules:
+        \\  - id: no-eval
+        \\    languages: [javascript, ts]
+        \\    severity: ERROR
+        \\    message: >
+        \\      Avoid eval(); it runs
+        \\      arbitrary "code"
+        \\    pattern: eval(...)
+        \\    metadata:
+        \\      category: security
+        \\      cwe:
+        \\        - "CWE-95: Eval Injection"
+        \\      confidence: HIGH
+        \\  - id: shell-exec
+        \\    languages:
+        \\    - python
+        \\    severity: WARNING
+        \\    message: Shell commands run through the shell # shown in findings
+        \\    pattern-either:
+        \\      - pattern: os.system(...)
+        \\      - pattern: $SP.call(..., shell=True)
+        \\  - id: sql-concat
+        \\    languages: [python]
+        \\    message: Use bound parameters
+        \\    patterns:
+        \\      - pattern: $C.execute($Q + $X)
+        \\  - id: assign
+        \\    languages: [go]
+        \\    message: x
+        \\    pattern: $X = nil
+        \\
+    );
+    const rules = doc.get("rules").?.list;
+    try testing.expectEqual(@as(usize, 4), rules.len);
+    try testing.expectEqualStrings("Avoid eval(); it runs arbitrary \"code\"\n", rules[0].getString("message").?);
+    try testing.expectEqualStrings("CWE-95: Eval Injection", rules[0].get("metadata").?.get("cwe").?.list[0].scalar);
+    try testing.expectEqualStrings("python", rules[1].get("languages").?.list[0].scalar);
+
+    const call = Call.parse("$SP.call(..., shell=True)").?;
+    try testing.expect(call.object == null);
+    try testing.expectEqualStrings("call", call.function.?);
+    try testing.expect(Call.parse("$F(...)") == null);
+    try testing.expect(Call.parse("foo + 1") == null);
+
+    var list = std.ArrayList(u8){};
+    const writer = list.writer(arena);
+    try testing.expectEqual(@as(usize, 2), (try convertRule(arena, writer, rules[0], "rules.yaml")).converted);
+    try testing.expectEqual(@as(usize, 2), (try convertRule(arena, writer, rules[1], "rules.yaml")).converted);
+    try testing.expectEqualStrings("patterns is not supported", (try convertRule(arena, writer, rules[2], "rules.yaml")).skipped);
+    try testing.expect((try convertRule(arena, writer, rules[3], "rules.yaml")) == .skipped);
+
+    const text = list.items;
+    try testing.expect(std.mem.indexOf(u8, text, "constraint no_eval_javascript {") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "id: \"semgrep.no-eval.typescript\"") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "description: \"Avoid eval(); it runs arbitrary \\\"code\\\"\"") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "where @function in [\"eval\"]") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "where @function in [\"system\"]\n            and @object in [\"os\"]") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "confidence_score: 0.90") != null);
+    try testing.expect(std.mem.indexOf(u8, text, "\"cwe\": \"CWE-95: Eval Injection\"") != null);
+}
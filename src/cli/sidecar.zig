@@ -0,0 +1,174 @@
+// Annotation sidecar files
+// Writes each extracted file's constraints to `<file>.ananke.json` next to it
+// (or under a mirror directory), for editor plugins and review tools that read
+// files rather than talk to a server. Every constraint carries a line anchor
+// with the text of its line, so a reader can re-anchor it after edits, and the
+// sidecar records the SHA-256 of the source it describes, so a reader can
+// tell when it is stale. Files left without constraints lose their sidecar.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const summary = @import("cli_summary");
+
+pub const extension = ".ananke.json";
+/// Layout version recorded in every sidecar
+pub const format_version = 1;
+
+/// Where the sidecar of `file` goes: next to it, or under `dir` at the same
+/// relative path
+pub fn sidecarPath(allocator: std.mem.Allocator, file: []const u8, dir: ?[]const u8) ![]u8 {
+    if (dir) |root| return std.fmt.allocPrint(allocator, "{s}/{s}{s}", .{ std.mem.trimRight(u8, root, "/"), trimDot(file), extension });
+    return std.fmt.allocPrint(allocator, "{s}{s}", .{ file, extension });
+}
+
+fn trimDot(path: []const u8) []const u8 {
+    var rel = path;
+    while (std.mem.startsWith(u8, rel, "./")) rel = rel[2..];
+    return rel;
+}
+
+/// Text of 1-based line `line` of `source`, without surrounding whitespace
+fn lineText(source: []const u8, line: u32) ?[]const u8 {
+    var lines = std.mem.splitScalar(u8, source, '\n');
+    var n: u32 = 1;
+    while (lines.next()) |text| : (n += 1) {
+        if (n == line) return std.mem.trim(u8, text, " \t\r");
+    }
+    return null;
+}
+
+/// Sidecar for one file: the constraints that originate in it, in line
+/// order. `source` may be empty when the file was not read (cache hits);
+/// anchors then carry no text, and neither do they with `include_text` off.
+pub fn formatSidecar(
+    allocator: std.mem.Allocator,
+    tool_version: []const u8,
+    file: summary.FileInfo,
+    source: []const u8,
+    constraints: []const *const constraint.Constraint,
+    include_text: bool,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\n  \"version\": {d},\n  \"tool\": \"ananke {s}\",\n  \"file\": \"", .{ format_version, tool_version });
+    try output.writeJsonEscaped(writer, file.path);
+    try writer.print("\",\n  \"language\": \"{s}\",\n", .{file.language});
+    if (file.content_hash) |hash| try writer.print("  \"sha256\": \"{s}\",\n", .{hash});
+    try writer.writeAll("  \"constraints\": [");
+
+    for (constraints, 0..) |c, i| {
+        try writer.writeAll(if (i == 0) "\n" else ",\n");
+        try writer.print("    {{\"id\": {d}, \"name\": \"", .{c.id});
+        try output.writeJsonEscaped(writer, c.name);
+        try writer.print("\", \"kind\": \"{s}\", \"severity\": \"{s}\", \"confidence\": {d:.2}, \"description\": \"", .{
+            @tagName(c.kind),
+            @tagName(c.severity),
+            c.confidence,
+        });
+        try output.writeJsonEscaped(writer, c.description);
+        try writer.writeAll("\"");
+        if (c.origin_line) |line| {
+            try writer.print(", \"anchor\": {{\"line\": {d}", .{line});
+            if (include_text) {
+                if (lineText(source, line)) |text| {
+                    try writer.writeAll(", \"text\": \"");
+                    try output.writeJsonEscaped(writer, text);
+                    try writer.writeAll("\"");
+                }
+            }
+            try writer.writeAll("}");
+        }
+        try writer.writeAll("}");
+    }
+    try writer.writeAll(if (constraints.len == 0) "]\n}\n" else "\n  ]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+pub const Written = struct {
+    written: usize = 0,
+    /// Stale sidecars of files that no longer have constraints
+    removed: usize = 0,
+};
+
+fn lessThanLine(_: void, a: *const constraint.Constraint, b: *const constraint.Constraint) bool {
+    return (a.origin_line orelse 0) < (b.origin_line orelse 0);
+}
+
+/// Write a sidecar for every file in `files` with constraints and remove the
+/// sidecars of those without. `sources` is parallel to `files`.
+pub fn writeSidecars(
+    allocator: std.mem.Allocator,
+    tool_version: []const u8,
+    constraint_set: constraint.ConstraintSet,
+    files: []const summary.FileInfo,
+    sources: []const []const u8,
+    dir: ?[]const u8,
+    include_text: bool,
+) !Written {
+    var arena_state = std.heap.ArenaAllocator.init(allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    var by_file = std.StringHashMap(std.ArrayList(*const constraint.Constraint)).init(arena);
+    for (constraint_set.constraints.items) |*c| {
+        const file = c.origin_file orelse continue;
+        const gop = try by_file.getOrPut(trimDot(file));
+        if (!gop.found_existing) gop.value_ptr.* = std.ArrayList(*const constraint.Constraint){};
+        try gop.value_ptr.append(arena, c);
+    }
+
+    var written = Written{};
+    for (files, 0..) |file, i| {
+        const path = try sidecarPath(arena, file.path, dir);
+        const constraints = if (by_file.get(trimDot(file.path))) |list| list.items else &.{};
+        if (constraints.len == 0) {
+            if (std.fs.cwd().deleteFile(path)) |_| {
+                written.removed += 1;
+            } else |err| switch (err) {
+                error.FileNotFound => {},
+                else => return err,
+            }
+            continue;
+        }
+
+        std.mem.sort(*const constraint.Constraint, constraints, {}, lessThanLine);
+        const text = try formatSidecar(arena, tool_version, file, if (i < sources.len) sources[i] else "", constraints, include_text);
+        if (std.fs.path.dirname(path)) |parent| try std.fs.cwd().makePath(parent);
+        try std.fs.cwd().writeFile(.{ .sub_path = path, .data = text });
+        written.written += 1;
+    }
+    return written;
+}
+
+test "sidecar anchors constraints to their lines" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    try testing.expectEqualStrings("src/db.go.ananke.json", try sidecarPath(arena, "src/db.go", null));
+    try testing.expectEqualStrings("out/src/db.go.ananke.json", try sidecarPath(arena, "./src/db.go", "out/"));
+
+    const bound = constraint.Constraint{ .id = 7, .name = "bound_params", .description = "Use \"?\" placeholders", .kind = .security, .severity = .err, .origin_file = "src/db.go", .origin_line = 2 };
+    const file = summary.FileInfo{ .path = "src/db.go", .language = "go", .line_count = 3, .content_hash = "ab12" };
+    const text = try formatSidecar(arena, "1.2.3", file, "package db\n\trows, err := db.Query(q, id)\n", &.{&bound}, true);
+    try testing.expectEqualStrings(
+        \\{
+        \\  "version": 1,
+        \\  "tool": "ananke 1.2.3",
+        \\  "file": "src/db.go",
+        \\  "language": "go",
+        \\  "sha256": "ab12",
+        \\  "constraints": [
+        \\    {"id": 7, "name": "bound_params", "kind": "security", "severity": "err", "confidence": 1.00, "description": "Use \"?\" placeholders", "anchor": {"line": 2, "text": "rows, err := db.Query(q, id)"}}
+        \\  ]
+        \\}
+        \\
+    , text);
+
+    const redacted = try formatSidecar(arena, "1.2.3", file, "package db\n", &.{&bound}, false);
+    try testing.expect(std.mem.indexOf(u8, redacted, "\"anchor\": {\"line\": 2}") != null);
+}
@@ -0,0 +1,163 @@
+// Near-duplicate grouping with MinHash and locality-sensitive hashing
+// Templated code (generated handlers, table-driven tests, copy-pasted
+// endpoints) yields constraints whose text differs only in a line number or
+// an identifier. Comparing every pair of constraints to find them is
+// quadratic. Instead each text gets a MinHash signature over its word
+// bigrams; signatures are cut into bands, and only texts that share a band
+// bucket are compared, so the pass stays close to linear in the number of
+// constraints.
+const std = @import("std");
+
+/// Signature length; more hashes estimate similarity more precisely
+pub const num_hashes = 64;
+/// Bands of `rows` hashes each. Two texts with Jaccard similarity s share at
+/// least one band with probability 1 - (1 - s^rows)^bands: about 0.99 at
+/// s = 0.8 and 0.06 at s = 0.3 for 16 bands of 4 rows.
+const bands = 16;
+const rows = num_hashes / bands;
+
+pub const Signature = [num_hashes]u32;
+
+/// MinHash signature of `text`. Tokens are runs of letters and
+/// underscores; digits are dropped so line numbers and counters do not
+/// make otherwise identical texts differ.
+pub fn signature(text: []const u8) Signature {
+    var sig: Signature = @splat(std.math.maxInt(u32));
+    var previous: ?u64 = null;
+    var tokens: usize = 0;
+    var it = TokenIterator{ .text = text };
+    while (it.next()) |token| {
+        const token_hash = std.hash.Wyhash.hash(0, token);
+        // Bigrams keep some word order; a one-word text uses the word itself
+        if (previous) |prev| addShingle(&sig, prev *% 31 +% token_hash);
+        previous = token_hash;
+        tokens += 1;
+    }
+    if (tokens == 1) addShingle(&sig, previous.?);
+    return sig;
+}
+
+fn addShingle(sig: *Signature, shingle: u64) void {
+    for (sig, 0..) |*min, i| {
+        // One base hash remixed per slot stands in for independent hash functions
+        const h: u32 = @truncate(std.hash.Wyhash.hash(i, std.mem.asBytes(&shingle)));
+        if (h < min.*) min.* = h;
+    }
+}
+
+const TokenIterator = struct {
+    text: []const u8,
+    index: usize = 0,
+
+    fn next(self: *TokenIterator) ?[]const u8 {
+        while (self.index < self.text.len and !isWordByte(self.text[self.index])) self.index += 1;
+        if (self.index == self.text.len) return null;
+        const start = self.index;
+        while (self.index < self.text.len and isWordByte(self.text[self.index])) self.index += 1;
+        return self.text[start..self.index];
+    }
+
+    fn isWordByte(c: u8) bool {
+        return std.ascii.isAlphabetic(c) or c == '_' or c >= 0x80;
+    }
+};
+
+/// Estimated Jaccard similarity: the fraction of slots where both minima agree
+pub fn similarity(a: *const Signature, b: *const Signature) f32 {
+    var same: usize = 0;
+    for (a, b) |x, y| {
+        if (x == y) same += 1;
+    }
+    return @as(f32, @floatFromInt(same)) / num_hashes;
+}
+
+/// Group near-identical texts. `classes` partitions the input (e.g. by
+/// constraint kind); texts in different classes never group. Returns, for
+/// each text, the index of the first text of its group, which is its own
+/// index when it has no earlier near-duplicate. Caller owns the slice.
+pub fn groupSimilar(
+    allocator: std.mem.Allocator,
+    texts: []const []const u8,
+    classes: []const u32,
+    threshold: f32,
+) ![]usize {
+    std.debug.assert(texts.len == classes.len);
+
+    const signatures = try allocator.alloc(Signature, texts.len);
+    defer allocator.free(signatures);
+    for (texts, signatures) |text, *sig| sig.* = signature(text);
+
+    const leaders = try allocator.alloc(usize, texts.len);
+    errdefer allocator.free(leaders);
+    for (leaders, 0..) |*leader, i| leader.* = i;
+
+    // Band bucket -> first text that landed in it. Comparing against that one
+    // text, rather than every earlier member, keeps each band pass linear.
+    var buckets = std.AutoHashMap(u64, usize).init(allocator);
+    defer buckets.deinit();
+    try buckets.ensureTotalCapacity(@intCast(@min(texts.len, std.math.maxInt(u32))));
+
+    for (0..bands) |band| {
+        buckets.clearRetainingCapacity();
+        for (signatures, 0..) |*sig, i| {
+            var hasher = std.hash.Wyhash.init(band);
+            hasher.update(std.mem.asBytes(&classes[i]));
+            hasher.update(std.mem.sliceAsBytes(sig[band * rows .. (band + 1) * rows]));
+            const entry = try buckets.getOrPut(hasher.final());
+            if (!entry.found_existing) {
+                entry.value_ptr.* = i;
+                continue;
+            }
+            const other = entry.value_ptr.*;
+            if (classes[other] != classes[i]) continue;
+            if (similarity(sig, &signatures[other]) < threshold) continue;
+            unite(leaders, other, i);
+        }
+    }
+
+    for (leaders, 0..) |_, i| leaders[i] = find(leaders, i);
+    return leaders;
+}
+
+/// Root of `i`'s group, halving the path on the way
+fn find(leaders: []usize, i: usize) usize {
+    var x = i;
+    while (leaders[x] != x) {
+        leaders[x] = leaders[leaders[x]];
+        x = leaders[x];
+    }
+    return x;
+}
+
+/// Merge two groups under the smaller root, so every group is led by its
+/// earliest text
+fn unite(leaders: []usize, a: usize, b: usize) void {
+    const root_a = find(leaders, a);
+    const root_b = find(leaders, b);
+    if (root_a == root_b) return;
+    leaders[@max(root_a, root_b)] = @min(root_a, root_b);
+}
+
+test "similar texts group under their first occurrence" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const texts = [_][]const u8{
+        "Input validation detected at line 4 in go code",
+        "Retry with exponential backoff around the HTTP client",
+        "Input validation detected at line 97 in go code",
+        "Input validation detected at line 12 in go code",
+        "Input validation detected at line 4 in go code",
+    };
+    const classes = [_]u32{ 0, 0, 0, 0, 1 };
+
+    const leaders = try groupSimilar(allocator, &texts, &classes, 0.8);
+    defer allocator.free(leaders);
+
+    try testing.expectEqualSlices(usize, &.{ 0, 1, 0, 0, 4 }, leaders);
+
+    const a = signature("Token check in auth handler");
+    const b = signature("Rate limit the public endpoints");
+    try testing.expect(similarity(&a, &b) < 0.5);
+    try testing.expectEqual(@as(f32, 1.0), similarity(&a, &a));
+}
@@ -0,0 +1,175 @@
+// SonarQube generic external issues format
+// Emits constraints as a report for `sonar.externalIssuesReportPaths`, so
+// they appear next to SonarQube's own findings and count toward quality
+// gates. Uses the format of SonarQube 10.3 and later: a rule per constraint
+// (name and kind) carrying its clean code attribute and software quality
+// impact, then one issue per constraint pointing at its file and line. The
+// deprecated rule `type` and `severity` are set too, for older servers and
+// for SonarCloud's issue filters.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+
+/// Engine every rule is reported under
+pub const engine_id = "ananke";
+
+/// Report with no issues, written when nothing is left after filtering so a
+/// scan clears the findings of the previous one
+pub const empty_report = "{\"rules\": [], \"issues\": []}\n";
+
+fn cleanCodeAttribute(kind: constraint.ConstraintKind) []const u8 {
+    return switch (kind) {
+        .syntactic => "CONVENTIONAL",
+        .type_safety, .semantic => "LOGICAL",
+        .architectural => "MODULAR",
+        .operational => "EFFICIENT",
+        .security => "TRUSTWORTHY",
+    };
+}
+
+fn softwareQuality(kind: constraint.ConstraintKind) []const u8 {
+    return switch (kind) {
+        .security => "SECURITY",
+        .syntactic, .architectural => "MAINTAINABILITY",
+        .type_safety, .semantic, .operational => "RELIABILITY",
+    };
+}
+
+fn impactSeverity(severity: constraint.Severity) []const u8 {
+    return switch (severity) {
+        .err => "HIGH",
+        .warning => "MEDIUM",
+        .info, .hint => "LOW",
+    };
+}
+
+/// Rule type of the pre-10.3 format
+fn ruleType(kind: constraint.ConstraintKind) []const u8 {
+    return switch (kind) {
+        .security => "VULNERABILITY",
+        .syntactic, .architectural => "CODE_SMELL",
+        .type_safety, .semantic, .operational => "BUG",
+    };
+}
+
+/// Severity of the pre-10.3 format
+pub fn severityName(severity: constraint.Severity) []const u8 {
+    return switch (severity) {
+        .err => "CRITICAL",
+        .warning => "MAJOR",
+        .info => "MINOR",
+        .hint => "INFO",
+    };
+}
+
+/// Rule ID of a constraint: its kind and name, so every occurrence of a
+/// constraint is an issue of the same rule
+fn writeRuleId(writer: anytype, c: constraint.Constraint) !void {
+    try writer.print("{s}:", .{@tagName(c.kind)});
+    try output.writeJsonEscaped(writer, c.name);
+}
+
+/// Format constraints as a SonarQube external issues report. Constraints
+/// without a source file have nothing to attach to and are left out.
+pub fn formatSonarQube(allocator: std.mem.Allocator, constraint_set: constraint.ConstraintSet) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    // A rule is declared once, with the severity of its first occurrence
+    var arena = std.heap.ArenaAllocator.init(allocator);
+    defer arena.deinit();
+    var rules = std.StringHashMap(void).init(arena.allocator());
+    var rule_id = std.ArrayList(u8){};
+    defer rule_id.deinit(allocator);
+
+    try writer.writeAll("{\"rules\": [");
+    for (constraint_set.constraints.items) |c| {
+        if (c.origin_file == null) continue;
+        rule_id.clearRetainingCapacity();
+        try writeRuleId(rule_id.writer(allocator), c);
+        if (rules.contains(rule_id.items)) continue;
+        try rules.put(try arena.allocator().dupe(u8, rule_id.items), {});
+
+        try writer.writeAll(if (rules.count() == 1) "\n  {\"id\": \"" else ",\n  {\"id\": \"");
+        try writer.writeAll(rule_id.items);
+        try writer.writeAll("\", \"name\": \"");
+        try output.writeJsonEscaped(writer, c.name);
+        try writer.writeAll("\", \"description\": \"");
+        try output.writeJsonEscaped(writer, if (c.description.len > 0) c.description else c.name);
+        try writer.print("\", \"engineId\": \"{s}\", \"cleanCodeAttribute\": \"{s}\", \"type\": \"{s}\", \"severity\": \"{s}\", \"impacts\": [{{\"softwareQuality\": \"{s}\", \"severity\": \"{s}\"}}]}}", .{
+            engine_id,
+            cleanCodeAttribute(c.kind),
+            ruleType(c.kind),
+            severityName(c.severity),
+            softwareQuality(c.kind),
+            impactSeverity(c.severity),
+        });
+    }
+
+    try writer.writeAll(if (rules.count() > 0) "\n], \"issues\": [" else "], \"issues\": [");
+    var written: usize = 0;
+    for (constraint_set.constraints.items) |c| {
+        const file = c.origin_file orelse continue;
+        try writer.writeAll(if (written == 0) "\n  {\"ruleId\": \"" else ",\n  {\"ruleId\": \"");
+        try writeRuleId(writer, c);
+        try writer.writeAll("\", \"effortMinutes\": 0, \"primaryLocation\": {\"message\": \"");
+        try output.writeJsonEscaped(writer, if (c.description.len > 0) c.description else c.name);
+        try writer.writeAll("\", \"filePath\": \"");
+        try output.writeJsonEscaped(writer, normalize(file));
+        try writer.writeAll("\"");
+        // Without a line the issue is reported on the file
+        if (c.origin_line) |line| {
+            if (line > 0) try writer.print(", \"textRange\": {{\"startLine\": {d}}}", .{line});
+        }
+        try writer.writeAll("}}");
+        written += 1;
+    }
+    try writer.writeAll(if (written > 0) "\n]}\n" else "]}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// SonarQube resolves paths from the project base directory, without a leading "./"
+fn normalize(path: []const u8) []const u8 {
+    var rest = path;
+    while (std.mem.startsWith(u8, rest, "./")) rest = rest[2..];
+    return rest;
+}
+
+test "sonarqube report declares each rule once" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var set = constraint.ConstraintSet.init(allocator, "test");
+    defer set.deinit();
+    try set.add(.{ .name = "sql_params", .description = "Use bound parameters", .kind = .security, .severity = .err, .origin_file = "./db/query.go", .origin_line = 12 });
+    try set.add(.{ .name = "sql_params", .description = "Use bound parameters", .kind = .security, .severity = .err, .origin_file = "./db/user.go" });
+    try set.add(.{ .name = "naming", .description = "", .kind = .syntactic, .severity = .hint });
+
+    const text = try formatSonarQube(allocator, set);
+    defer allocator.free(text);
+    const parsed = try std.json.parseFromSlice(std.json.Value, allocator, text, .{});
+    defer parsed.deinit();
+
+    const rules = parsed.value.object.get("rules").?.array.items;
+    try testing.expectEqual(@as(usize, 1), rules.len);
+    const rule = rules[0].object;
+    try testing.expectEqualStrings("security:sql_params", rule.get("id").?.string);
+    try testing.expectEqualStrings("TRUSTWORTHY", rule.get("cleanCodeAttribute").?.string);
+    try testing.expectEqualStrings("HIGH", rule.get("impacts").?.array.items[0].object.get("severity").?.string);
+
+    // The constraint without a file has no location and is left out
+    const issues = parsed.value.object.get("issues").?.array.items;
+    try testing.expectEqual(@as(usize, 2), issues.len);
+    const first = issues[0].object.get("primaryLocation").?.object;
+    try testing.expectEqualStrings("db/query.go", first.get("filePath").?.string);
+    try testing.expectEqual(@as(i64, 12), first.get("textRange").?.object.get("startLine").?.integer);
+    try testing.expect(issues[1].object.get("primaryLocation").?.object.get("textRange") == null);
+
+    var empty = constraint.ConstraintSet.init(allocator, "empty");
+    defer empty.deinit();
+    const none = try formatSonarQube(allocator, empty);
+    defer allocator.free(none);
+    try testing.expectEqualStrings(empty_report, none);
+}
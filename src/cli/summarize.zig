@@ -0,0 +1,420 @@
+// LLM summaries of extracted constraints
+// An optional stage after extraction: constraints are grouped by package (the
+// directory of their origin file), and a configured LLM endpoint is asked for
+// a short summary and remediation hints for each package. Replies are cached
+// on disk under a digest of the provider, model, and prompt, so unchanged
+// packages cost nothing on later runs. Uncached requests are spaced to stay
+// under a per-minute rate and capped per run; packages past the cap, or ones
+// the endpoint fails on, are left out rather than failing the extraction.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+
+pub const SummarizeError = error{
+    RequestFailed,
+    RateLimited,
+    InvalidResponse,
+};
+
+/// Constraints listed in one prompt; larger packages are summarized from these
+const max_prompt_constraints = 50;
+/// Description characters quoted per constraint
+const max_description_chars = 300;
+/// Hints kept from a reply
+const max_hints = 5;
+const max_tokens = 512;
+const max_response_bytes = 4 * 1024 * 1024;
+
+/// Subdirectory of the cache directory holding replies
+pub const cache_subdir = "summaries";
+
+const system_prompt =
+    \\You review constraints that a static analyzer extracted from one package of a
+    \\codebase. Reply with a single JSON object and nothing else:
+    \\{"summary": "<two or three sentences on what the constraints enforce and where the risk is>",
+    \\ "hints": ["<one concrete remediation step>", ...]}
+    \\Give at most five hints, most important first. Refer to constraints by name.
+;
+
+pub const Provider = enum {
+    /// OpenAI-compatible chat completions (OpenAI, sglang, vLLM, Ollama, ...)
+    openai,
+    /// Anthropic messages API
+    anthropic,
+
+    pub fn defaultEndpoint(self: Provider) []const u8 {
+        return switch (self) {
+            .openai => "https://api.openai.com",
+            .anthropic => "https://api.anthropic.com",
+        };
+    }
+
+    fn path(self: Provider) []const u8 {
+        return switch (self) {
+            .openai => "/v1/chat/completions",
+            .anthropic => "/v1/messages",
+        };
+    }
+};
+
+pub const Options = struct {
+    provider: Provider = .openai,
+    /// Base URL; the provider's API path is appended
+    endpoint: []const u8,
+    model: []const u8,
+    api_key: ?[]const u8 = null,
+    /// Minimum spacing between uncached requests is a minute divided by this
+    /// (0 = unpaced)
+    requests_per_minute: u32 = 10,
+    /// Uncached requests per run
+    max_requests: usize = 20,
+    /// Cache directory; replies go to its `summaries` subdirectory (null = no caching)
+    cache_dir: ?[]const u8 = null,
+};
+
+/// Summary of one package's constraints
+pub const Summary = struct {
+    package: []const u8,
+    constraints: usize,
+    summary: []const u8,
+    hints: []const []const u8,
+};
+
+pub const Stats = struct {
+    /// Requests sent to the endpoint
+    requested: usize = 0,
+    /// Packages answered from the cache
+    cached: usize = 0,
+    /// Packages left out because the request cap was reached or requests stopped
+    skipped: usize = 0,
+    /// Packages whose request or reply failed
+    failed: usize = 0,
+};
+
+pub const Outcome = struct {
+    summaries: []const Summary,
+    stats: Stats,
+    /// Why requests stopped early: the endpoint throttled or could not be reached
+    stopped: ?anyerror = null,
+};
+
+/// Constraints sharing an origin directory
+pub const Package = struct {
+    name: []const u8,
+    constraints: []const constraint.Constraint,
+};
+
+/// Package of a file: its directory, or "." for top-level files
+pub fn packageOf(file: []const u8) []const u8 {
+    return std.fs.path.dirname(file) orelse ".";
+}
+
+/// Group constraints by package, sorted by name. Constraints without an
+/// origin file are left out.
+pub fn groupByPackage(arena: std.mem.Allocator, constraints: []const constraint.Constraint) ![]Package {
+    var groups = std.StringArrayHashMap(std.ArrayList(constraint.Constraint)).init(arena);
+    for (constraints) |c| {
+        const file = c.origin_file orelse continue;
+        const gop = try groups.getOrPut(packageOf(file));
+        if (!gop.found_existing) gop.value_ptr.* = std.ArrayList(constraint.Constraint){};
+        try gop.value_ptr.append(arena, c);
+    }
+
+    const packages = try arena.alloc(Package, groups.count());
+    for (groups.keys(), groups.values(), packages) |name, list, *package| {
+        package.* = .{ .name = name, .constraints = list.items };
+    }
+    std.mem.sort(Package, packages, {}, lessThanPackage);
+    return packages;
+}
+
+fn lessThanPackage(_: void, a: Package, b: Package) bool {
+    return std.mem.lessThan(u8, a.name, b.name);
+}
+
+/// User prompt listing a package's constraints
+pub fn formatPrompt(arena: std.mem.Allocator, package: Package) ![]const u8 {
+    var list = std.ArrayList(u8){};
+    const writer = list.writer(arena);
+    try writer.print("Package: {s}\nConstraints ({d}):\n", .{ package.name, package.constraints.len });
+    const shown = package.constraints[0..@min(package.constraints.len, max_prompt_constraints)];
+    for (shown) |c| {
+        try writer.print("- [{s}] {s} {s}", .{ @tagName(c.severity), @tagName(c.kind), c.name });
+        if (c.origin_file) |file| {
+            try writer.print(" ({s}", .{std.fs.path.basename(file)});
+            if (c.origin_line) |line| try writer.print(":{d}", .{line});
+            try writer.writeAll(")");
+        }
+        const description = std.mem.trim(u8, c.description, " \t\r\n");
+        if (description.len > 0) {
+            try writer.print(": {s}", .{description[0..@min(description.len, max_description_chars)]});
+        }
+        try writer.writeAll("\n");
+    }
+    if (shown.len < package.constraints.len) {
+        try writer.print("(and {d} more)\n", .{package.constraints.len - shown.len});
+    }
+    return list.items;
+}
+
+const Message = struct {
+    role: []const u8,
+    content: []const u8,
+};
+
+/// Request body for one prompt
+pub fn formatRequest(arena: std.mem.Allocator, provider: Provider, model: []const u8, prompt: []const u8) ![]const u8 {
+    return switch (provider) {
+        .openai => std.json.Stringify.valueAlloc(arena, .{
+            .model = model,
+            .max_tokens = max_tokens,
+            .temperature = 0,
+            .messages = &[_]Message{
+                .{ .role = "system", .content = system_prompt },
+                .{ .role = "user", .content = prompt },
+            },
+        }, .{}),
+        .anthropic => std.json.Stringify.valueAlloc(arena, .{
+            .model = model,
+            .max_tokens = max_tokens,
+            .temperature = 0,
+            .system = system_prompt,
+            .messages = &[_]Message{.{ .role = "user", .content = prompt }},
+        }, .{}),
+    };
+}
+
+/// Reply text of a completion: `choices[0].message.content` (OpenAI) or the
+/// first `content[].text` (Anthropic)
+pub fn parseCompletion(arena: std.mem.Allocator, provider: Provider, body: []const u8) ![]const u8 {
+    const root = std.json.parseFromSliceLeaky(std.json.Value, arena, body, .{}) catch return SummarizeError.InvalidResponse;
+    if (root != .object) return SummarizeError.InvalidResponse;
+    switch (provider) {
+        .openai => {
+            const choices = root.object.get("choices") orelse return SummarizeError.InvalidResponse;
+            if (choices != .array or choices.array.items.len == 0) return SummarizeError.InvalidResponse;
+            const choice = choices.array.items[0];
+            if (choice != .object) return SummarizeError.InvalidResponse;
+            const message = choice.object.get("message") orelse return SummarizeError.InvalidResponse;
+            if (message != .object) return SummarizeError.InvalidResponse;
+            const content = message.object.get("content") orelse return SummarizeError.InvalidResponse;
+            if (content != .string) return SummarizeError.InvalidResponse;
+            return content.string;
+        },
+        .anthropic => {
+            const content = root.object.get("content") orelse return SummarizeError.InvalidResponse;
+            if (content != .array) return SummarizeError.InvalidResponse;
+            for (content.array.items) |block| {
+                if (block != .object) continue;
+                const text = block.object.get("text") orelse continue;
+                if (text == .string) return text.string;
+            }
+            return SummarizeError.InvalidResponse;
+        },
+    }
+}
+
+/// The summary and hints of a reply. Models often wrap JSON in a code fence
+/// or a sentence, so the outermost braces are parsed.
+pub fn parseReply(arena: std.mem.Allocator, reply: []const u8) !struct { summary: []const u8, hints: []const []const u8 } {
+    const start = std.mem.indexOfScalar(u8, reply, '{') orelse return SummarizeError.InvalidResponse;
+    const end = std.mem.lastIndexOfScalar(u8, reply, '}') orelse return SummarizeError.InvalidResponse;
+    if (end < start) return SummarizeError.InvalidResponse;
+    const root = std.json.parseFromSliceLeaky(std.json.Value, arena, reply[start .. end + 1], .{}) catch
+        return SummarizeError.InvalidResponse;
+    if (root != .object) return SummarizeError.InvalidResponse;
+
+    const summary = root.object.get("summary") orelse return SummarizeError.InvalidResponse;
+    if (summary != .string or summary.string.len == 0) return SummarizeError.InvalidResponse;
+
+    var hints = std.ArrayList([]const u8){};
+    if (root.object.get("hints")) |value| {
+        if (value != .array) return SummarizeError.InvalidResponse;
+        for (value.array.items) |hint| {
+            if (hint != .string or hint.string.len == 0) continue;
+            if (hints.items.len == max_hints) break;
+            try hints.append(arena, hint.string);
+        }
+    }
+    return .{ .summary = summary.string, .hints = hints.items };
+}
+
+/// Spaces requests a fixed interval apart. Unlike a token bucket it allows no
+/// burst, so a run never exceeds the configured rate in any window.
+pub const Pacer = struct {
+    interval_ns: u64,
+    /// Earliest time the next request may start
+    next_ns: ?i128 = null,
+
+    pub fn init(requests_per_minute: u32) Pacer {
+        return .{ .interval_ns = if (requests_per_minute == 0) 0 else std.time.ns_per_min / requests_per_minute };
+    }
+
+    /// Book a request at `now` and return how long it must wait
+    pub fn reserve(self: *Pacer, now: i128) u64 {
+        const start = if (self.next_ns) |next| @max(next, now) else now;
+        self.next_ns = start + self.interval_ns;
+        return @intCast(start - now);
+    }
+
+    pub fn wait(self: *Pacer) void {
+        const delay = self.reserve(std.time.nanoTimestamp());
+        if (delay > 0) std.Thread.sleep(delay);
+    }
+};
+
+/// Summarize each package of `constraints`. Strings live in `arena`.
+pub fn summarize(arena: std.mem.Allocator, client: *std.http.Client, constraints: []const constraint.Constraint, options: Options) !Outcome {
+    var cache_dir: ?std.fs.Dir = null;
+    defer if (cache_dir) |*dir| dir.close();
+    if (options.cache_dir) |path| {
+        cache_dir = std.fs.cwd().makeOpenPath(try std.fs.path.join(arena, &.{ path, cache_subdir }), .{}) catch null;
+    }
+
+    var summaries = std.ArrayList(Summary){};
+    var stats = Stats{};
+    var stopped: ?anyerror = null;
+    var pacer = Pacer.init(options.requests_per_minute);
+
+    for (try groupByPackage(arena, constraints)) |package| {
+        const prompt = try formatPrompt(arena, package);
+        const key = cacheKey(options, prompt);
+
+        if (cache_dir) |dir| cached: {
+            const reply = dir.readFileAlloc(arena, &key, max_response_bytes) catch break :cached;
+            const parsed = parseReply(arena, reply) catch break :cached;
+            try summaries.append(arena, .{ .package = package.name, .constraints = package.constraints.len, .summary = parsed.summary, .hints = parsed.hints });
+            stats.cached += 1;
+            continue;
+        }
+
+        if (stopped != null or stats.requested >= options.max_requests) {
+            stats.skipped += 1;
+            continue;
+        }
+
+        pacer.wait();
+        stats.requested += 1;
+        const reply = complete(arena, client, options, prompt) catch |err| switch (err) {
+            SummarizeError.RequestFailed, SummarizeError.InvalidResponse => {
+                stats.failed += 1;
+                continue;
+            },
+            error.OutOfMemory => return err,
+            // Throttled or unreachable: later requests would fare no better
+            else => {
+                stopped = err;
+                stats.failed += 1;
+                continue;
+            },
+        };
+        const parsed = parseReply(arena, reply) catch {
+            stats.failed += 1;
+            continue;
+        };
+        if (cache_dir) |dir| writeCacheEntry(dir, &key, reply);
+        try summaries.append(arena, .{ .package = package.name, .constraints = package.constraints.len, .summary = parsed.summary, .hints = parsed.hints });
+    }
+    return .{ .summaries = summaries.items, .stats = stats, .stopped = stopped };
+}
+
+const CacheKey = [std.crypto.hash.sha2.Sha256.digest_length * 2 + ".json".len]u8;
+
+fn cacheKey(options: Options, prompt: []const u8) CacheKey {
+    var hasher = std.crypto.hash.sha2.Sha256.init(.{});
+    for ([_][]const u8{ @tagName(options.provider), options.model, system_prompt, prompt }) |part| {
+        hasher.update(part);
+        hasher.update(&.{0});
+    }
+    return std.fmt.bytesToHex(hasher.finalResult(), .lower) ++ ".json".*;
+}
+
+/// A cache that cannot be written only costs a request on the next run
+fn writeCacheEntry(dir: std.fs.Dir, name: []const u8, reply: []const u8) void {
+    var tmp_buf: [CacheKey.len + 24]u8 = undefined;
+    const tmp_name = std.fmt.bufPrint(&tmp_buf, "{s}.tmp-{x}", .{ name, std.crypto.random.int(u64) }) catch return;
+    dir.writeFile(.{ .sub_path = tmp_name, .data = reply }) catch return;
+    dir.rename(tmp_name, name) catch {
+        dir.deleteFile(tmp_name) catch {};
+    };
+}
+
+/// Send one prompt and return the reply text
+fn complete(arena: std.mem.Allocator, client: *std.http.Client, options: Options, prompt: []const u8) ![]const u8 {
+    const payload = try formatRequest(arena, options.provider, options.model, prompt);
+    const url = try std.fmt.allocPrint(arena, "{s}{s}", .{ std.mem.trimRight(u8, options.endpoint, "/"), options.provider.path() });
+
+    var headers = std.ArrayList(std.http.Header){};
+    switch (options.provider) {
+        .openai => if (options.api_key) |key| {
+            try headers.append(arena, .{ .name = "authorization", .value = try std.fmt.allocPrint(arena, "Bearer {s}", .{key}) });
+        },
+        .anthropic => {
+            try headers.append(arena, .{ .name = "anthropic-version", .value = "2023-06-01" });
+            if (options.api_key) |key| try headers.append(arena, .{ .name = "x-api-key", .value = key });
+        },
+    }
+
+    const uri = try std.Uri.parse(url);
+    var req = try client.request(.POST, uri, .{
+        .headers = .{ .content_type = .{ .override = "application/json" } },
+        .extra_headers = headers.items,
+    });
+    defer req.deinit();
+
+    req.transfer_encoding = .{ .content_length = payload.len };
+    var body_writer = try req.sendBodyUnflushed(&.{});
+    try body_writer.writer.writeAll(payload);
+    try body_writer.end();
+    try req.connection.?.flush();
+
+    var redirect_buffer: [2048]u8 = undefined;
+    var response = try req.receiveHead(&redirect_buffer);
+    switch (@intFromEnum(response.head.status)) {
+        200...299 => {},
+        429 => return SummarizeError.RateLimited,
+        else => return SummarizeError.RequestFailed,
+    }
+    var body = std.ArrayList(u8){};
+    const reader = response.reader(&.{});
+    try reader.appendRemainingUnlimited(arena, &body);
+    return parseCompletion(arena, options.provider, body.items);
+}
+
+test "group packages and parse summary replies" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const constraints = [_]constraint.Constraint{
+        .{ .id = 1, .name = "bound_params", .description = "Queries use bound parameters", .kind = .security, .severity = .err, .origin_file = "db/query.go", .origin_line = 12 },
+        .{ .id = 2, .name = "main_entry", .description = "", .kind = .architectural, .severity = .info, .origin_file = "main.go" },
+        .{ .id = 3, .name = "close_rows", .description = "Rows are closed", .kind = .operational, .severity = .warning, .origin_file = "db/rows.go" },
+        .{ .id = 4, .name = "no_origin", .description = "", .kind = .syntactic, .severity = .hint },
+    };
+    const packages = try groupByPackage(arena, &constraints);
+    try testing.expectEqual(@as(usize, 2), packages.len);
+    try testing.expectEqualStrings(".", packages[0].name);
+    try testing.expectEqualStrings("db", packages[1].name);
+    try testing.expectEqual(@as(usize, 2), packages[1].constraints.len);
+
+    const prompt = try formatPrompt(arena, packages[1]);
+    try testing.expect(std.mem.indexOf(u8, prompt, "- [err] security bound_params (query.go:12): Queries use bound parameters\n") != null);
+
+    const request = try formatRequest(arena, .anthropic, "claude-sonnet-4-5", "hi");
+    try testing.expect(std.mem.indexOf(u8, request, "\"messages\":[{\"role\":\"user\",\"content\":\"hi\"}]") != null);
+
+    const reply = try parseCompletion(arena, .openai,
+        \\{"choices": [{"message": {"role": "assistant", "content": "```json\n{\"summary\": \"Queries are parameterized.\", \"hints\": [\"Close rows\", 3]}\n```"}}]}
+    );
+    const parsed = try parseReply(arena, reply);
+    try testing.expectEqualStrings("Queries are parameterized.", parsed.summary);
+    try testing.expectEqual(@as(usize, 1), parsed.hints.len);
+    try testing.expectError(SummarizeError.InvalidResponse, parseReply(arena, "No JSON here"));
+
+    var pacer = Pacer.init(2);
+    try testing.expectEqual(@as(u64, 0), pacer.reserve(0));
+    try testing.expectEqual(@as(u64, 20 * std.time.ns_per_s), pacer.reserve(10 * std.time.ns_per_s));
+    try testing.expectEqual(@as(u64, 0), pacer.reserve(90 * std.time.ns_per_s));
+}
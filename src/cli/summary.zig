@@ -0,0 +1,293 @@
+// Summary statistics report for extracted constraints
+// Aggregates counts by category, severity, language, and package, and ranks
+// files by constraint density. Renders as JSON or as a terminal table.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+
+/// Per-file metadata supplied by the caller
+pub const FileInfo = struct {
+    path: []const u8,
+    language: []const u8,
+    line_count: usize,
+    /// Hex-encoded SHA-256 of the file contents, when known
+    content_hash: ?[]const u8 = null,
+};
+
+/// A single aggregated row: a grouping key and how many constraints fall in it
+pub const Bucket = struct {
+    key: []const u8,
+    count: usize,
+
+    fn lessThan(_: void, a: Bucket, b: Bucket) bool {
+        if (a.count != b.count) return a.count > b.count;
+        return std.mem.lessThan(u8, a.key, b.key);
+    }
+};
+
+/// Constraint density for a single file
+pub const FileDensity = struct {
+    path: []const u8,
+    count: usize,
+    line_count: usize,
+    /// Constraints per 100 lines of source
+    density: f64,
+
+    fn lessThan(_: void, a: FileDensity, b: FileDensity) bool {
+        if (a.density != b.density) return a.density > b.density;
+        return std.mem.lessThan(u8, a.path, b.path);
+    }
+};
+
+/// Label used for constraints that carry no origin file
+const unknown_key = "(unknown)";
+
+/// Aggregated statistics over a constraint set.
+/// Keys borrow from the constraints and file infos passed to `compute`.
+pub const Summary = struct {
+    allocator: std.mem.Allocator,
+    total: usize,
+    by_category: []Bucket,
+    by_severity: []Bucket,
+    by_language: []Bucket,
+    by_package: []Bucket,
+    top_files: []FileDensity,
+
+    pub fn compute(
+        allocator: std.mem.Allocator,
+        constraints: []const constraint.Constraint,
+        files: []const FileInfo,
+        top_n: usize,
+    ) !Summary {
+        var categories = std.StringArrayHashMap(usize).init(allocator);
+        defer categories.deinit();
+        var severities = std.StringArrayHashMap(usize).init(allocator);
+        defer severities.deinit();
+        var languages = std.StringArrayHashMap(usize).init(allocator);
+        defer languages.deinit();
+        var packages = std.StringArrayHashMap(usize).init(allocator);
+        defer packages.deinit();
+        var per_file = std.StringArrayHashMap(usize).init(allocator);
+        defer per_file.deinit();
+
+        for (constraints) |c| {
+            try increment(&categories, @tagName(c.kind));
+            try increment(&severities, @tagName(c.severity));
+
+            if (c.origin_file) |path| {
+                const language = if (findFile(files, path)) |info| info.language else unknown_key;
+                try increment(&languages, language);
+                try increment(&packages, packageOf(path));
+                try increment(&per_file, path);
+            } else {
+                try increment(&languages, unknown_key);
+                try increment(&packages, unknown_key);
+            }
+        }
+
+        const by_category = try sortedBuckets(allocator, &categories);
+        errdefer allocator.free(by_category);
+        const by_severity = try sortedBuckets(allocator, &severities);
+        errdefer allocator.free(by_severity);
+        const by_language = try sortedBuckets(allocator, &languages);
+        errdefer allocator.free(by_language);
+        const by_package = try sortedBuckets(allocator, &packages);
+        errdefer allocator.free(by_package);
+
+        var densities = std.ArrayList(FileDensity){};
+        errdefer densities.deinit(allocator);
+
+        var it = per_file.iterator();
+        while (it.next()) |entry| {
+            const line_count = if (findFile(files, entry.key_ptr.*)) |info| info.line_count else 0;
+            const count = entry.value_ptr.*;
+            const density = if (line_count > 0)
+                @as(f64, @floatFromInt(count)) * 100.0 / @as(f64, @floatFromInt(line_count))
+            else
+                @as(f64, @floatFromInt(count));
+            try densities.append(allocator, .{
+                .path = entry.key_ptr.*,
+                .count = count,
+                .line_count = line_count,
+                .density = density,
+            });
+        }
+
+        std.mem.sort(FileDensity, densities.items, {}, FileDensity.lessThan);
+        densities.shrinkRetainingCapacity(@min(top_n, densities.items.len));
+
+        return .{
+            .allocator = allocator,
+            .total = constraints.len,
+            .by_category = by_category,
+            .by_severity = by_severity,
+            .by_language = by_language,
+            .by_package = by_package,
+            .top_files = try densities.toOwnedSlice(allocator),
+        };
+    }
+
+    pub fn deinit(self: *Summary) void {
+        self.allocator.free(self.by_category);
+        self.allocator.free(self.by_severity);
+        self.allocator.free(self.by_language);
+        self.allocator.free(self.by_package);
+        self.allocator.free(self.top_files);
+    }
+
+    /// Percentage of the total represented by `count`
+    pub fn percent(self: *const Summary, count: usize) f64 {
+        if (self.total == 0) return 0.0;
+        return @as(f64, @floatFromInt(count)) * 100.0 / @as(f64, @floatFromInt(self.total));
+    }
+};
+
+/// Package of a file: its directory, or "." for files at the root
+pub fn packageOf(path: []const u8) []const u8 {
+    return std.fs.path.dirname(path) orelse ".";
+}
+
+fn increment(map: *std.StringArrayHashMap(usize), key: []const u8) !void {
+    const gop = try map.getOrPut(key);
+    if (!gop.found_existing) gop.value_ptr.* = 0;
+    gop.value_ptr.* += 1;
+}
+
+fn findFile(files: []const FileInfo, path: []const u8) ?FileInfo {
+    for (files) |info| {
+        if (std.mem.eql(u8, info.path, path)) return info;
+    }
+    return null;
+}
+
+fn sortedBuckets(allocator: std.mem.Allocator, map: *std.StringArrayHashMap(usize)) ![]Bucket {
+    const buckets = try allocator.alloc(Bucket, map.count());
+    var it = map.iterator();
+    var i: usize = 0;
+    while (it.next()) |entry| : (i += 1) {
+        buckets[i] = .{ .key = entry.key_ptr.*, .count = entry.value_ptr.* };
+    }
+    std.mem.sort(Bucket, buckets, {}, Bucket.lessThan);
+    return buckets;
+}
+
+/// Format a summary as JSON
+pub fn formatJson(allocator: std.mem.Allocator, summary: Summary) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("{\n");
+    try writer.print("  \"total\": {d},\n", .{summary.total});
+    try writeBucketsJson(writer, "by_category", summary, summary.by_category);
+    try writeBucketsJson(writer, "by_severity", summary, summary.by_severity);
+    try writeBucketsJson(writer, "by_language", summary, summary.by_language);
+    try writeBucketsJson(writer, "by_package", summary, summary.by_package);
+
+    try writer.writeAll("  \"top_files\": [\n");
+    for (summary.top_files, 0..) |f, i| {
+        try writer.writeAll("    {\"path\": \"");
+        try output.writeJsonEscaped(writer, f.path);
+        try writer.print("\", \"constraints\": {d}, \"lines\": {d}, \"density\": {d:.2}}}", .{
+            f.count,
+            f.line_count,
+            f.density,
+        });
+        if (i + 1 < summary.top_files.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("  ]\n");
+    try writer.writeAll("}\n");
+
+    return list.toOwnedSlice(allocator);
+}
+
+fn writeBucketsJson(writer: anytype, name: []const u8, summary: Summary, buckets: []const Bucket) !void {
+    try writer.print("  \"{s}\": [\n", .{name});
+    for (buckets, 0..) |b, i| {
+        try writer.writeAll("    {\"key\": \"");
+        try output.writeJsonEscaped(writer, b.key);
+        try writer.print("\", \"count\": {d}, \"percent\": {d:.2}}}", .{ b.count, summary.percent(b.count) });
+        if (i + 1 < buckets.len) try writer.writeAll(",");
+        try writer.writeAll("\n");
+    }
+    try writer.writeAll("  ],\n");
+}
+
+/// Format a summary as terminal tables
+pub fn formatTable(allocator: std.mem.Allocator, summary: Summary) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("Constraint Summary ({d} total)\n\n", .{summary.total});
+    try writeBucketTable(writer, "Category", summary, summary.by_category);
+    try writeBucketTable(writer, "Severity", summary, summary.by_severity);
+    try writeBucketTable(writer, "Language", summary, summary.by_language);
+    try writeBucketTable(writer, "Package", summary, summary.by_package);
+
+    try writer.writeAll("┌────────────────────────────────────────┬────────┬────────┬─────────┐\n");
+    try writer.writeAll("│ File (top by density)                  │  Count │  Lines │ /100 LOC│\n");
+    try writer.writeAll("├────────────────────────────────────────┼────────┼────────┼─────────┤\n");
+    for (summary.top_files) |f| {
+        try writer.print("│ {s: <38} │ {d: >6} │ {d: >6} │ {d: >7.2} │\n", .{
+            truncateLeft(f.path, 38),
+            f.count,
+            f.line_count,
+            f.density,
+        });
+    }
+    try writer.writeAll("└────────────────────────────────────────┴────────┴────────┴─────────┘\n");
+
+    return list.toOwnedSlice(allocator);
+}
+
+fn writeBucketTable(writer: anytype, title: []const u8, summary: Summary, buckets: []const Bucket) !void {
+    try writer.writeAll("┌────────────────────────────────────────┬────────┬─────────┐\n");
+    try writer.print("│ {s: <38} │  Count │ Percent │\n", .{title});
+    try writer.writeAll("├────────────────────────────────────────┼────────┼─────────┤\n");
+    for (buckets) |b| {
+        try writer.print("│ {s: <38} │ {d: >6} │ {d: >6.1}% │\n", .{
+            truncateLeft(b.key, 38),
+            b.count,
+            summary.percent(b.count),
+        });
+    }
+    try writer.writeAll("└────────────────────────────────────────┴────────┴─────────┘\n\n");
+}
+
+/// Keep the tail of long paths, which carries the most information
+fn truncateLeft(s: []const u8, max: usize) []const u8 {
+    if (s.len <= max) return s;
+    return s[s.len - max ..];
+}
+
+test "summary counts and percentages" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const constraints = [_]constraint.Constraint{
+        .{ .name = "a", .description = "", .kind = .security, .severity = .err, .origin_file = "svc/auth.go" },
+        .{ .name = "b", .description = "", .kind = .security, .severity = .warning, .origin_file = "svc/auth.go" },
+        .{ .name = "c", .description = "", .kind = .syntactic, .severity = .info, .origin_file = "main.ts" },
+        .{ .name = "d", .description = "", .kind = .syntactic, .severity = .info },
+    };
+    const files = [_]FileInfo{
+        .{ .path = "svc/auth.go", .language = "go", .line_count = 100 },
+        .{ .path = "main.ts", .language = "typescript", .line_count = 10 },
+    };
+
+    var summary = try Summary.compute(allocator, &constraints, &files, 1);
+    defer summary.deinit();
+
+    try testing.expectEqual(@as(usize, 4), summary.total);
+    try testing.expectEqual(@as(usize, 2), summary.by_category.len);
+    try testing.expectEqual(@as(f64, 50.0), summary.percent(summary.by_category[0].count));
+    try testing.expectEqualStrings("go", summary.by_language[0].key);
+    try testing.expectEqualStrings("svc", summary.by_package[0].key);
+
+    // main.ts has 1 constraint in 10 lines, denser than auth.go's 2 in 100
+    try testing.expectEqual(@as(usize, 1), summary.top_files.len);
+    try testing.expectEqualStrings("main.ts", summary.top_files[0].path);
+}
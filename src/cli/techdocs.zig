@@ -0,0 +1,384 @@
+// Backstage TechDocs site output
+// Writes a service's constraints as a TechDocs source directory: mkdocs.yml,
+// a docs/ tree with an overview and one page per constraint kind, and a
+// catalog-info.yaml registering the docs as a documentation component of the
+// service they were extracted from. The owning component is read from the
+// service's own catalog-info.yaml, so Backstage links the two entities.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const messages = @import("cli_messages");
+const output = @import("cli_output");
+const report = @import("cli_report");
+const summary_mod = @import("cli_summary");
+
+/// Annotation on the generated entity naming the component it documents
+pub const component_annotation = "ananke.dev/component";
+
+/// Descriptor file Backstage discovers entities from
+pub const catalog_file = "catalog-info.yaml";
+
+/// A Backstage component, as far as the docs need it
+pub const Component = struct {
+    name: []const u8,
+    namespace: []const u8 = "default",
+    owner: ?[]const u8 = null,
+    system: ?[]const u8 = null,
+
+    /// Entity reference, e.g. `component:default/billing`
+    pub fn writeRef(self: Component, writer: anytype) !void {
+        try writer.print("component:{s}/{s}", .{ self.namespace, self.name });
+    }
+
+    /// Path of the component's catalog page in the Backstage app
+    pub fn writeCatalogPath(self: Component, writer: anytype) !void {
+        try writer.print("/catalog/{s}/component/{s}", .{ self.namespace, self.name });
+    }
+};
+
+/// Read the first `kind: Component` entity of a catalog-info.yaml. Only the
+/// fields the docs link to are read, with a line scan rather than a YAML
+/// parser; returned strings point into `text`.
+pub fn parseCatalogInfo(text: []const u8) ?Component {
+    const Section = enum { none, metadata, spec, other };
+    var is_component = false;
+    var component = Component{ .name = "" };
+    var section: Section = .none;
+    var child_indent: ?usize = null;
+
+    var lines = std.mem.splitScalar(u8, text, '\n');
+    while (lines.next()) |raw| {
+        const line = std.mem.trimRight(u8, raw, " \t\r");
+        if (std.mem.startsWith(line, "---")) {
+            if (is_component and component.name.len > 0) return component;
+            is_component = false;
+            component = .{ .name = "" };
+            section = .none;
+            continue;
+        }
+        const content = std.mem.trimLeft(u8, line, " ");
+        if (content.len == 0 or content[0] == '#') continue;
+        const colon = std.mem.indexOfScalar(u8, content, ':') orelse continue;
+        const key = content[0..colon];
+        const value = scalar(content[colon + 1 ..]);
+
+        const indent = line.len - content.len;
+        if (indent == 0) {
+            if (std.mem.eql(u8, key, "kind")) is_component = std.mem.eql(u8, value, "Component");
+            section = std.meta.stringToEnum(Section, key) orelse .other;
+            child_indent = null;
+            continue;
+        }
+        // Only direct children of metadata and spec; deeper keys such as
+        // annotations and links are skipped
+        if (child_indent == null) child_indent = indent;
+        if (indent != child_indent.? or value.len == 0) continue;
+        switch (section) {
+            .metadata => {
+                if (std.mem.eql(u8, key, "name")) component.name = value;
+                if (std.mem.eql(u8, key, "namespace")) component.namespace = value;
+            },
+            .spec => {
+                if (std.mem.eql(u8, key, "owner")) component.owner = value;
+                if (std.mem.eql(u8, key, "system")) component.system = value;
+            },
+            .none, .other => {},
+        }
+    }
+    return if (is_component and component.name.len > 0) component else null;
+}
+
+/// A YAML scalar without quotes or trailing comment
+fn scalar(raw: []const u8) []const u8 {
+    var value = std.mem.trim(u8, raw, " \t");
+    if (value.len >= 2 and (value[0] == '"' or value[0] == '\'')) {
+        if (std.mem.lastIndexOfScalar(u8, value, value[0])) |end| {
+            if (end > 0) return value[1..end];
+        }
+    }
+    if (std.mem.indexOf(u8, value, " #")) |comment| value = std.mem.trimRight(u8, value[0..comment], " \t");
+    return value;
+}
+
+/// Find the component owning the extracted files: the nearest catalog-info.yaml
+/// in their common directory or above it
+pub fn findComponent(arena: std.mem.Allocator, files: []const summary_mod.FileInfo) !?Component {
+    if (files.len == 0) return null;
+    var dir: []const u8 = std.fs.path.dirname(files[0].path) orelse ".";
+    for (files[1..]) |file| {
+        while (!isWithin(dir, file.path)) dir = std.fs.path.dirname(dir) orelse return null;
+    }
+
+    while (true) {
+        const path = try std.fs.path.join(arena, &.{ dir, catalog_file });
+        if (std.fs.cwd().readFileAlloc(arena, path, 1024 * 1024)) |text| {
+            if (parseCatalogInfo(text)) |component| return component;
+        } else |err| switch (err) {
+            error.FileNotFound => {},
+            else => return err,
+        }
+        if (std.mem.eql(u8, dir, ".")) return null;
+        dir = std.fs.path.dirname(dir) orelse if (std.fs.path.isAbsolute(dir)) return null else ".";
+    }
+}
+
+fn isWithin(dir: []const u8, path: []const u8) bool {
+    if (std.mem.eql(u8, dir, ".")) return !std.fs.path.isAbsolute(path);
+    if (std.mem.eql(u8, dir, "/")) return std.fs.path.isAbsolute(path);
+    return std.mem.startsWith(u8, path, dir) and path.len > dir.len and path[dir.len] == '/';
+}
+
+/// Constraint kinds that have at least one constraint, in declaration order
+fn presentKinds(constraint_set: constraint.ConstraintSet) std.EnumSet(constraint.ConstraintKind) {
+    var kinds = std.EnumSet(constraint.ConstraintKind).initEmpty();
+    for (constraint_set.constraints.items) |c| kinds.insert(c.kind);
+    return kinds;
+}
+
+/// mkdocs.yml of the site: the overview, then a page per constraint kind
+pub fn formatMkdocs(
+    allocator: std.mem.Allocator,
+    constraint_set: constraint.ConstraintSet,
+    component: Component,
+    catalog: *const messages.Catalog,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("site_name: \"");
+    try output.writeJsonEscaped(writer, component.name);
+    try writer.writeAll(" constraints\"\nsite_description: \"");
+    try output.writeJsonEscaped(writer, catalog.get(.report_title));
+    try writer.writeAll("\"\nnav:\n  - \"");
+    try output.writeJsonEscaped(writer, catalog.get(.section_summary));
+    try writer.writeAll("\": index.md\n");
+    const present = presentKinds(constraint_set);
+    var kinds = present.iterator();
+    while (kinds.next()) |kind| {
+        try writer.writeAll("  - \"");
+        try output.writeJsonEscaped(writer, catalog.kind(kind));
+        try writer.print("\": {s}.md\n", .{@tagName(kind)});
+    }
+    try writer.writeAll("plugins:\n  - techdocs-core\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// docs/index.md: the owning component, then counts by severity and kind
+pub fn formatIndex(
+    allocator: std.mem.Allocator,
+    constraint_set: constraint.ConstraintSet,
+    component: Component,
+    catalog: *const messages.Catalog,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+    const items = constraint_set.constraints.items;
+
+    try writer.print("# {s}: {s}\n\n[", .{ catalog.get(.report_title), component.name });
+    try component.writeRef(writer);
+    try writer.writeAll("](");
+    try component.writeCatalogPath(writer);
+    try writer.writeAll(")");
+    if (component.owner) |owner| try writer.print(" · {s}", .{owner});
+    try writer.print("\n\n**{s}:** {d}\n\n", .{ catalog.get(.total_constraints), items.len });
+
+    if (items.len == 0) {
+        try writer.print("{s}\n", .{catalog.get(.no_constraints)});
+    } else {
+        var severities = std.EnumArray(constraint.Severity, usize).initFill(0);
+        var kinds = std.EnumArray(constraint.ConstraintKind, usize).initFill(0);
+        for (items) |c| {
+            severities.getPtr(c.severity).* += 1;
+            kinds.getPtr(c.kind).* += 1;
+        }
+
+        try writer.print("| {s} | {s} |\n|---|---:|\n", .{ catalog.get(.col_severity), catalog.get(.col_count) });
+        for (std.enums.values(constraint.Severity)) |severity| {
+            const count = severities.get(severity);
+            if (count > 0) try writer.print("| {s} | {d} |\n", .{ catalog.severity(severity), count });
+        }
+        try writer.print("\n| {s} | {s} |\n|---|---:|\n", .{ catalog.get(.col_kind), catalog.get(.col_count) });
+        for (std.enums.values(constraint.ConstraintKind)) |kind| {
+            const count = kinds.get(kind);
+            if (count > 0) try writer.print("| [{s}]({s}.md) | {d} |\n", .{ catalog.kind(kind), @tagName(kind), count });
+        }
+    }
+
+    try writer.print("\n---\n_{s}_\n", .{catalog.get(.generated_by)});
+    return list.toOwnedSlice(allocator);
+}
+
+/// docs/<kind>.md: a table of the constraints of one kind
+pub fn formatKindPage(
+    allocator: std.mem.Allocator,
+    constraint_set: constraint.ConstraintSet,
+    kind: constraint.ConstraintKind,
+    catalog: *const messages.Catalog,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("# {s}\n\n| {s} | {s} | {s} | {s} | {s} |\n|---|---|---|---:|---|\n", .{
+        catalog.kind(kind),
+        catalog.get(.col_name),
+        catalog.get(.col_severity),
+        catalog.get(.col_file),
+        catalog.get(.col_confidence),
+        catalog.get(.col_description),
+    });
+    for (constraint_set.constraints.items) |c| {
+        if (c.kind != kind) continue;
+        try writer.writeAll("| ");
+        try report.writeMarkdownCell(writer, c.name);
+        try writer.print(" | {s} | ", .{catalog.severity(c.severity)});
+        if (c.origin_file) |file| {
+            try writer.writeByte('`');
+            try report.writeMarkdownCell(writer, file);
+            if (c.origin_line) |line| try writer.print(":{d}", .{line});
+            try writer.writeByte('`');
+        }
+        try writer.print(" | {d:.2} | ", .{c.confidence});
+        try report.writeMarkdownCell(writer, c.description);
+        try writer.writeAll(" |\n");
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+/// catalog-info.yaml registering the docs as a documentation component that
+/// belongs to, and is owned like, the component it documents
+pub fn formatCatalogInfo(allocator: std.mem.Allocator, component: Component) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("apiVersion: backstage.io/v1alpha1\nkind: Component\nmetadata:\n  name: \"");
+    try output.writeJsonEscaped(writer, component.name);
+    try writer.writeAll("-constraints\"\n  namespace: \"");
+    try output.writeJsonEscaped(writer, component.namespace);
+    try writer.writeAll("\"\n  title: \"");
+    try output.writeJsonEscaped(writer, component.name);
+    try writer.writeAll(" constraints\"\n  annotations:\n    backstage.io/techdocs-ref: dir:.\n    " ++ component_annotation ++ ": \"");
+    try component.writeRef(writer);
+    try writer.writeAll("\"\n  links:\n    - url: \"");
+    try component.writeCatalogPath(writer);
+    try writer.writeAll("\"\n      title: \"");
+    try output.writeJsonEscaped(writer, component.name);
+    try writer.writeAll("\"\nspec:\n  type: documentation\n  lifecycle: production\n  owner: \"");
+    // Backstage requires an owner; without one the docs are unowned like the service
+    try output.writeJsonEscaped(writer, component.owner orelse "unknown");
+    try writer.writeAll("\"\n");
+    if (component.system) |system| {
+        try writer.writeAll("  system: \"");
+        try output.writeJsonEscaped(writer, system);
+        try writer.writeAll("\"\n");
+    }
+    try writer.writeAll("  subcomponentOf: \"");
+    try component.writeRef(writer);
+    try writer.writeAll("\"\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// Write the site to `dir_path`, replacing pages of a previous run. Pages of
+/// kinds that no longer have constraints are removed.
+pub fn writeSite(
+    allocator: std.mem.Allocator,
+    dir_path: []const u8,
+    constraint_set: constraint.ConstraintSet,
+    component: Component,
+    catalog: *const messages.Catalog,
+) !void {
+    try std.fs.cwd().makePath(dir_path);
+    var dir = try std.fs.cwd().openDir(dir_path, .{});
+    defer dir.close();
+    try dir.makePath("docs");
+
+    const mkdocs = try formatMkdocs(allocator, constraint_set, component, catalog);
+    defer allocator.free(mkdocs);
+    try dir.writeFile(.{ .sub_path = "mkdocs.yml", .data = mkdocs });
+
+    const info = try formatCatalogInfo(allocator, component);
+    defer allocator.free(info);
+    try dir.writeFile(.{ .sub_path = catalog_file, .data = info });
+
+    const index = try formatIndex(allocator, constraint_set, component, catalog);
+    defer allocator.free(index);
+    try dir.writeFile(.{ .sub_path = "docs/index.md", .data = index });
+
+    const kinds = presentKinds(constraint_set);
+    for (std.enums.values(constraint.ConstraintKind)) |kind| {
+        var name_buf: [64]u8 = undefined;
+        const page_path = try std.fmt.bufPrint(&name_buf, "docs/{s}.md", .{@tagName(kind)});
+        if (!kinds.contains(kind)) {
+            dir.deleteFile(page_path) catch |err| switch (err) {
+                error.FileNotFound => {},
+                else => return err,
+            };
+            continue;
+        }
+        const page = try formatKindPage(allocator, constraint_set, kind, catalog);
+        defer allocator.free(page);
+        try dir.writeFile(.{ .sub_path = page_path, .data = page });
+    }
+}
+
+test "techdocs site links back to the owning component" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const component = parseCatalogInfo(
+        \\apiVersion: backstage.io/v1alpha1
+        \\kind: Location
+        \\metadata:
+        \\  name: services
+        \\---
+        \\apiVersion: backstage.io/v1alpha1
+        \\kind: Component
+        \\metadata:
+        \\  name: billing
+        \\  namespace: payments # team namespace
+        \\  annotations:
+        \\    name: not-this-one
+        \\spec:
+        \\  type: service
+        \\  owner: 'group:payments/billing-team'
+        \\  system: checkout
+        \\
+    ).?;
+    try testing.expectEqualStrings("billing", component.name);
+    try testing.expectEqualStrings("payments", component.namespace);
+    try testing.expectEqualStrings("group:payments/billing-team", component.owner.?);
+    try testing.expectEqualStrings("checkout", component.system.?);
+    try testing.expect(parseCatalogInfo("kind: API\nmetadata:\n  name: billing-api\n") == null);
+
+    var set = constraint.ConstraintSet.init(allocator, "billing");
+    defer set.deinit();
+    try set.add(.{ .name = "sql_params", .description = "Use bound | parameters", .kind = .security, .severity = .err, .origin_file = "db/query.go", .origin_line = 12 });
+    try set.add(.{ .name = "naming", .description = "", .kind = .syntactic, .severity = .hint });
+
+    const catalog = messages.Catalog.default();
+    const mkdocs = try formatMkdocs(allocator, set, component, &catalog);
+    defer allocator.free(mkdocs);
+    try testing.expect(std.mem.indexOf(u8, mkdocs, "site_name: \"billing constraints\"") != null);
+    try testing.expect(std.mem.indexOf(u8, mkdocs, ": security.md\n") != null);
+    try testing.expect(std.mem.indexOf(u8, mkdocs, "semantic.md") == null);
+
+    const info = try formatCatalogInfo(allocator, component);
+    defer allocator.free(info);
+    try testing.expect(std.mem.indexOf(u8, info, "name: \"billing-constraints\"") != null);
+    try testing.expect(std.mem.indexOf(u8, info, component_annotation ++ ": \"component:payments/billing\"") != null);
+    try testing.expect(std.mem.indexOf(u8, info, "subcomponentOf: \"component:payments/billing\"") != null);
+    try testing.expect(std.mem.indexOf(u8, info, "owner: \"group:payments/billing-team\"") != null);
+
+    const index = try formatIndex(allocator, set, component, &catalog);
+    defer allocator.free(index);
+    try testing.expect(std.mem.indexOf(u8, index, "(/catalog/payments/component/billing)") != null);
+
+    const page = try formatKindPage(allocator, set, .security, &catalog);
+    defer allocator.free(page);
+    try testing.expect(std.mem.indexOf(u8, page, "`db/query.go:12`") != null);
+    try testing.expect(std.mem.indexOf(u8, page, "Use bound \\| parameters") != null);
+    try testing.expect(std.mem.indexOf(u8, page, "naming") == null);
+}
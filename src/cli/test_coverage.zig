@@ -0,0 +1,343 @@
+// Constraint test coverage
+// Measures how many constraints are linked to at least one test, per package
+// (a file's directory). A constraint is linked when a test of its file names
+// it in an `ananke:covers <name>, ...` comment, or uses the function or type
+// declared around the constraint's line. The tests of a file are the test
+// files in its directory and test files elsewhere named after it
+// (`users_test.go`, `test_users.py`, `users.spec.ts` for `users.*`).
+// Constraints found in test files are not counted.
+const std = @import("std");
+const ananke = @import("ananke");
+const constraint = ananke.types.constraint;
+const output = @import("cli_output");
+const summary = @import("cli_summary");
+
+pub const covers_marker = "ananke:covers";
+
+/// A test file and its contents
+pub const TestFile = struct {
+    path: []const u8,
+    source: []const u8,
+};
+
+pub const PackageCoverage = struct {
+    name: []const u8,
+    total: usize = 0,
+    linked: usize = 0,
+    /// Constraints no test is linked to, in input order
+    unlinked: std.ArrayList(constraint.Constraint) = .{},
+
+    pub fn percent(self: PackageCoverage) f64 {
+        return ratio(self.linked, self.total);
+    }
+};
+
+pub const Coverage = struct {
+    /// Sorted by name
+    packages: []const PackageCoverage,
+    total: usize,
+    linked: usize,
+
+    pub fn percent(self: Coverage) f64 {
+        return ratio(self.linked, self.total);
+    }
+
+    pub fn find(self: Coverage, name: []const u8) ?PackageCoverage {
+        for (self.packages) |pkg| {
+            if (std.mem.eql(u8, pkg.name, name)) return pkg;
+        }
+        return null;
+    }
+};
+
+/// Percentage of `part` in `whole`; 100 for an empty whole, which has
+/// nothing left untested
+fn ratio(part: usize, whole: usize) f64 {
+    if (whole == 0) return 100.0;
+    return @as(f64, @floatFromInt(part)) * 100.0 / @as(f64, @floatFromInt(whole));
+}
+
+fn trimDot(path: []const u8) []const u8 {
+    var rel = path;
+    while (std.mem.startsWith(u8, rel, "./")) rel = rel[2..];
+    return rel;
+}
+
+const test_suffixes = [_][]const u8{ "_test", ".test", ".spec", "_spec", "Test", "Tests" };
+
+/// The file name without its extension and test affixes, or null when the
+/// name does not follow a test naming convention
+fn testStem(path: []const u8) ?[]const u8 {
+    const base = std.fs.path.basename(path);
+    const stem = base[0 .. std.mem.lastIndexOfScalar(u8, base, '.') orelse base.len];
+    if (std.mem.startsWith(u8, stem, "test_")) return stem["test_".len..];
+    for (test_suffixes) |suffix| {
+        if (stem.len > suffix.len and std.mem.endsWith(u8, stem, suffix)) return stem[0 .. stem.len - suffix.len];
+    }
+    return null;
+}
+
+/// Whether `path` is a test by its name or a tests/ directory
+pub fn isTestFile(path: []const u8) bool {
+    if (testStem(path) != null) return true;
+    var parts = std.mem.tokenizeAny(u8, path, "/\\");
+    while (parts.next()) |part| {
+        if (std.mem.eql(u8, part, "tests") or std.mem.eql(u8, part, "__tests__")) return true;
+    }
+    return false;
+}
+
+fn isIdentChar(c: u8) bool {
+    return std.ascii.isAlphanumeric(c) or c == '_';
+}
+
+/// Whether `word` occurs in `text` as a whole identifier
+fn containsWord(text: []const u8, word: []const u8) bool {
+    var from: usize = 0;
+    while (std.mem.indexOfPos(u8, text, from, word)) |at| {
+        const end = at + word.len;
+        if ((at == 0 or !isIdentChar(text[at - 1])) and (end == text.len or !isIdentChar(text[end]))) return true;
+        from = at + 1;
+    }
+    return false;
+}
+
+const declaration_keywords = [_][]const u8{ "func", "def", "function", "fn", "fun", "class", "struct", "interface", "type", "trait", "enum" };
+const modifiers = [_][]const u8{ "export", "default", "async", "pub", "public", "private", "protected", "internal", "static", "abstract", "final", "open", "override", "suspend", "inline", "extern" };
+
+/// Name declared by the nearest function or type declaration at or above
+/// `line` (1-based) of `source`
+pub fn enclosingName(source: []const u8, line: u32) ?[]const u8 {
+    var name: ?[]const u8 = null;
+    var lines = std.mem.splitScalar(u8, source, '\n');
+    var n: u32 = 1;
+    while (lines.next()) |text| : (n += 1) {
+        if (n > line) break;
+        if (declaredName(text)) |declared| name = declared;
+    }
+    return name;
+}
+
+/// Name declared by a line starting with a declaration keyword, after any
+/// modifiers (`export async function validate(`, `func (h *H) Create(`)
+fn declaredName(line: []const u8) ?[]const u8 {
+    var words = std.mem.tokenizeAny(u8, line, " \t\r");
+    while (words.next()) |word| {
+        const is_keyword = for (declaration_keywords) |keyword| {
+            if (std.mem.eql(u8, word, keyword)) break true;
+        } else false;
+        if (!is_keyword) {
+            for (modifiers) |modifier| {
+                if (std.mem.eql(u8, word, modifier)) break;
+            } else return null;
+            continue;
+        }
+
+        var rest = std.mem.trimLeft(u8, line[words.index..], " \t");
+        // Go method receiver
+        if (std.mem.startsWith(u8, rest, "(")) {
+            const close = std.mem.indexOfScalar(u8, rest, ')') orelse return null;
+            rest = std.mem.trimLeft(u8, rest[close + 1 ..], " \t");
+        }
+        var len: usize = 0;
+        while (len < rest.len and isIdentChar(rest[len])) len += 1;
+        // Short names like `New` or `run` match too much unrelated test code
+        if (len < 3) return null;
+        return rest[0..len];
+    }
+    return null;
+}
+
+/// Names listed by the `ananke:covers` comments of a test
+fn coveredNames(arena: std.mem.Allocator, source: []const u8) ![]const []const u8 {
+    var names = std.ArrayList([]const u8){};
+    var from: usize = 0;
+    while (std.mem.indexOfPos(u8, source, from, covers_marker)) |at| {
+        const start = at + covers_marker.len;
+        const end = std.mem.indexOfScalarPos(u8, source, start, '\n') orelse source.len;
+        var parts = std.mem.tokenizeAny(u8, source[start..end], " ,\t\r");
+        while (parts.next()) |part| {
+            if (std.mem.eql(u8, part, "*/") or std.mem.eql(u8, part, "-->")) break;
+            try names.append(arena, part);
+        }
+        from = end;
+    }
+    return names.items;
+}
+
+/// Whether `test_path` holds tests of the source file `path`
+fn testsFile(test_path: []const u8, path: []const u8) bool {
+    if (std.mem.eql(u8, summary.packageOf(test_path), summary.packageOf(path))) return true;
+    const stem = testStem(test_path) orelse return false;
+    const base = std.fs.path.basename(path);
+    const source_stem = base[0 .. std.mem.lastIndexOfScalar(u8, base, '.') orelse base.len];
+    return std.mem.eql(u8, stem, source_stem);
+}
+
+/// Coverage of `constraints` by `tests`. `sources` maps a file path (without
+/// a leading "./") to its contents; constraints of files missing from it can
+/// only be linked by name. Everything lives in `arena` or borrows the inputs.
+pub fn compute(
+    arena: std.mem.Allocator,
+    constraints: []const constraint.Constraint,
+    tests: []const TestFile,
+    sources: *const std.StringHashMap([]const u8),
+) !Coverage {
+    const markers = try arena.alloc([]const []const u8, tests.len);
+    for (tests, markers) |t, *names| names.* = try coveredNames(arena, t.source);
+
+    var index = std.StringArrayHashMap(PackageCoverage).init(arena);
+    var total: usize = 0;
+    var linked: usize = 0;
+    for (constraints) |c| {
+        const path = trimDot(c.origin_file orelse continue);
+        if (isTestFile(path)) continue;
+        const name = summary.packageOf(path);
+        const gop = try index.getOrPut(name);
+        if (!gop.found_existing) gop.value_ptr.* = .{ .name = name };
+        const pkg = gop.value_ptr;
+
+        const subject = if (sources.get(path)) |source| enclosingName(source, c.origin_line orelse 0) else null;
+        var is_linked = false;
+        for (tests, markers) |t, names| {
+            const test_path = trimDot(t.path);
+            if (!testsFile(test_path, path)) continue;
+            for (names) |covered| {
+                if (std.mem.eql(u8, covered, c.name)) is_linked = true;
+            }
+            if (subject) |s| {
+                if (containsWord(t.source, s)) is_linked = true;
+            }
+            if (is_linked) break;
+        }
+
+        pkg.total += 1;
+        total += 1;
+        if (is_linked) {
+            pkg.linked += 1;
+            linked += 1;
+        } else {
+            try pkg.unlinked.append(arena, c);
+        }
+    }
+
+    const packages = index.values();
+    std.mem.sort(PackageCoverage, packages, {}, struct {
+        fn lessThan(_: void, a: PackageCoverage, b: PackageCoverage) bool {
+            return std.mem.lessThan(u8, a.name, b.name);
+        }
+    }.lessThan);
+    return .{ .packages = packages, .total = total, .linked = linked };
+}
+
+/// A table of packages; with `show_unlinked`, the constraints no test covers
+pub fn formatText(allocator: std.mem.Allocator, coverage: Coverage, show_unlinked: bool) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("Constraint test coverage: {d}/{d} ({d:.1}%)\n\n", .{ coverage.linked, coverage.total, coverage.percent() });
+    var width: usize = "Package".len;
+    for (coverage.packages) |pkg| width = @max(width, pkg.name.len);
+    try writer.print("  {s}", .{"Package"});
+    try writer.writeByteNTimes(' ', width - "Package".len);
+    try writer.writeAll("  Linked  Total  Coverage\n");
+    for (coverage.packages) |pkg| {
+        try writer.print("  {s}", .{pkg.name});
+        try writer.writeByteNTimes(' ', width - pkg.name.len);
+        try writer.print("  {d:>6}  {d:>5}  {d:>7.1}%\n", .{ pkg.linked, pkg.total, pkg.percent() });
+        if (!show_unlinked) continue;
+        for (pkg.unlinked.items) |c| {
+            try writer.print("      untested {s} ({s}) {s}", .{ c.name, @tagName(c.kind), trimDot(c.origin_file.?) });
+            if (c.origin_line) |line| try writer.print(":{d}", .{line});
+            try writer.writeAll("\n");
+        }
+    }
+    return list.toOwnedSlice(allocator);
+}
+
+/// Machine-readable coverage, with the unlinked constraints of each package
+pub fn formatJson(allocator: std.mem.Allocator, coverage: Coverage) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\n  \"total\": {d},\n  \"linked\": {d},\n  \"coverage\": {d:.1},\n  \"packages\": [", .{
+        coverage.total,
+        coverage.linked,
+        coverage.percent(),
+    });
+    for (coverage.packages, 0..) |pkg, i| {
+        try writer.writeAll(if (i == 0) "\n" else ",\n");
+        try writer.writeAll("    {\"package\": \"");
+        try output.writeJsonEscaped(writer, pkg.name);
+        try writer.print("\", \"total\": {d}, \"linked\": {d}, \"coverage\": {d:.1}, \"unlinked\": [", .{ pkg.total, pkg.linked, pkg.percent() });
+        for (pkg.unlinked.items, 0..) |c, j| {
+            if (j > 0) try writer.writeAll(", ");
+            try writer.writeAll("{\"name\": \"");
+            try output.writeJsonEscaped(writer, c.name);
+            try writer.writeAll("\", \"file\": \"");
+            try output.writeJsonEscaped(writer, trimDot(c.origin_file.?));
+            try writer.print("\", \"line\": {d}}}", .{c.origin_line orelse 0});
+        }
+        try writer.writeAll("]}");
+    }
+    try writer.writeAll(if (coverage.packages.len == 0) "]\n}\n" else "\n  ]\n}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+test "link constraints to tests by marker and subject" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    try testing.expect(isTestFile("api/users_test.go"));
+    try testing.expect(isTestFile("tests/test_users.py"));
+    try testing.expect(isTestFile("web/users.spec.ts"));
+    try testing.expect(!isTestFile("api/users.go"));
+
+    const users =
+        \\package api
+        \\
+        \\func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+        \\	hash := hashPassword(req.Password)
+        \\}
+        \\
+        \\func DeleteUser(id string) error {
+        \\	return nil
+        \\}
+    ;
+    var sources = std.StringHashMap([]const u8).init(arena);
+    try sources.put("api/users.go", users);
+    try testing.expectEqualStrings("CreateUser", enclosingName(users, 4).?);
+
+    const constraints = [_]constraint.Constraint{
+        .{ .name = "no_plaintext", .description = "", .kind = .security, .severity = .err, .origin_file = "./api/users.go", .origin_line = 4 },
+        .{ .name = "error_check", .description = "", .kind = .semantic, .severity = .warning, .origin_file = "api/users.go", .origin_line = 8 },
+        .{ .name = "audit_log", .description = "", .kind = .security, .severity = .warning, .origin_file = "store/db.go", .origin_line = 3 },
+        .{ .name = "assert_status", .description = "", .kind = .semantic, .severity = .info, .origin_file = "api/users_test.go", .origin_line = 5 },
+    };
+    const tests = [_]TestFile{
+        .{ .path = "./api/users_test.go", .source = "func TestCreateUser(t *testing.T) {\n\th.CreateUser(rec, req)\n}\n" },
+        .{ .path = "tests/db_test.go", .source = "// ananke:covers audit_log, tx_scope\nfunc TestAudit(t *testing.T) {}\n" },
+    };
+    const coverage = try compute(arena, &constraints, &tests, &sources);
+    try testing.expectEqual(@as(usize, 3), coverage.total);
+    try testing.expectEqual(@as(usize, 2), coverage.linked);
+    const api = coverage.find("api").?;
+    try testing.expectEqual(@as(usize, 1), api.linked);
+    try testing.expectEqualStrings("error_check", api.unlinked.items[0].name);
+    try testing.expectEqual(@as(f64, 100.0), coverage.find("store").?.percent());
+
+    const text = try formatText(arena, coverage, true);
+    try testing.expectEqualStrings(
+        \\Constraint test coverage: 2/3 (66.7%)
+        \\
+        \\  Package  Linked  Total  Coverage
+        \\  api           1      2     50.0%
+        \\      untested error_check (semantic) api/users.go:8
+        \\  store         1      1    100.0%
+        \\
+    , text);
+}
@@ -0,0 +1,317 @@
+// OpenTelemetry tracing
+// Records the spans of one extraction run (the run itself, each pipeline
+// stage, and each analyzed file) and exports them as an OTLP/HTTP JSON
+// trace request, to a collector or to a file. Spans are buffered and sent
+// once when the run ends, so tracing never slows extraction down with
+// network round trips. Safe to record from extraction workers.
+const std = @import("std");
+const cli_error = @import("cli_error");
+const output = @import("cli_output");
+
+pub const TraceId = [16]u8;
+pub const SpanId = [8]u8;
+
+pub const Attribute = struct {
+    key: []const u8,
+    value: union(enum) {
+        string: []const u8,
+        int: i64,
+    },
+
+    pub fn string(key: []const u8, value: []const u8) Attribute {
+        return .{ .key = key, .value = .{ .string = value } };
+    }
+
+    pub fn int(key: []const u8, value: anytype) Attribute {
+        return .{ .key = key, .value = .{ .int = std.math.cast(i64, value) orelse std.math.maxInt(i64) } };
+    }
+};
+
+pub const Span = struct {
+    name: []const u8,
+    id: SpanId,
+    parent: ?SpanId,
+    /// Unix time in nanoseconds
+    start_ns: u64,
+    /// Zero while the span is open
+    end_ns: u64 = 0,
+    attributes: []const Attribute = &.{},
+    failed: bool = false,
+};
+
+/// Index of a span returned by `begin`, passed back to `end`
+pub const Handle = usize;
+
+pub const Tracer = struct {
+    /// Span names and attributes are copied here, so callers may free theirs
+    arena: std.heap.ArenaAllocator,
+    mutex: std.Thread.Mutex = .{},
+    trace_id: TraceId,
+    spans: std.ArrayList(Span) = .{},
+    /// The span covering the whole run, started by `init`
+    root: Handle = 0,
+    /// Pipeline stage in progress; per-file spans are its children
+    stage: ?Handle = null,
+
+    pub fn init(allocator: std.mem.Allocator, root_name: []const u8) !Tracer {
+        var tracer = Tracer{
+            .arena = std.heap.ArenaAllocator.init(allocator),
+            .trace_id = undefined,
+        };
+        errdefer tracer.arena.deinit();
+        std.crypto.random.bytes(&tracer.trace_id);
+        tracer.root = try tracer.begin(root_name, null);
+        return tracer;
+    }
+
+    pub fn deinit(self: *Tracer) void {
+        self.arena.deinit();
+    }
+
+    /// Start a span under `parent` (the root span when null)
+    pub fn begin(self: *Tracer, name: []const u8, parent: ?Handle) !Handle {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        var id: SpanId = undefined;
+        std.crypto.random.bytes(&id);
+        const allocator = self.arena.allocator();
+        try self.spans.append(allocator, .{
+            .name = try allocator.dupe(u8, name),
+            .id = id,
+            .parent = if (self.spans.items.len == 0) null else self.spans.items[parent orelse self.root].id,
+            .start_ns = now(),
+        });
+        return self.spans.items.len - 1;
+    }
+
+    /// End a span, recording its attributes and whether it failed
+    pub fn end(self: *Tracer, handle: Handle, attributes: []const Attribute, failed: bool) void {
+        self.mutex.lock();
+        defer self.mutex.unlock();
+        const allocator = self.arena.allocator();
+        const span = &self.spans.items[handle];
+        span.end_ns = now();
+        span.failed = failed;
+        // Attributes are best effort: a span without them is still useful
+        const copies = allocator.alloc(Attribute, attributes.len) catch return;
+        for (attributes, copies) |attribute, *copy| {
+            copy.* = attribute;
+            copy.key = allocator.dupe(u8, attribute.key) catch return;
+            if (attribute.value == .string) {
+                copy.value = .{ .string = allocator.dupe(u8, attribute.value.string) catch return };
+            }
+        }
+        span.attributes = copies;
+    }
+
+    /// Start a pipeline stage; it ends at the next `endStage`
+    pub fn beginStage(self: *Tracer, name: []const u8) void {
+        self.stage = self.begin(name, null) catch null;
+    }
+
+    pub fn endStage(self: *Tracer) void {
+        const stage = self.stage orelse return;
+        self.stage = null;
+        self.end(stage, &.{}, false);
+    }
+
+    /// End every span still open, the root last
+    pub fn finish(self: *Tracer, attributes: []const Attribute, failed: bool) void {
+        self.endStage();
+        for (self.spans.items, 0..) |span, i| {
+            if (span.end_ns == 0 and i != self.root) self.end(i, &.{}, false);
+        }
+        self.end(self.root, attributes, failed);
+    }
+};
+
+fn now() u64 {
+    return std.math.cast(u64, std.time.nanoTimestamp()) orelse 0;
+}
+
+/// The spans as an OTLP/HTTP JSON `ExportTraceServiceRequest`
+pub fn formatOtlpJson(allocator: std.mem.Allocator, tracer: *const Tracer, service_name: []const u8, service_version: []const u8) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.writeAll("{\"resourceSpans\": [{\"resource\": {\"attributes\": [");
+    try writeAttribute(writer, Attribute.string("service.name", service_name));
+    try writer.writeAll(", ");
+    try writeAttribute(writer, Attribute.string("service.version", service_version));
+    try writer.writeAll("]}, \"scopeSpans\": [{\"scope\": {\"name\": \"ananke\", \"version\": \"");
+    try output.writeJsonEscaped(writer, service_version);
+    try writer.writeAll("\"}, \"spans\": [");
+    const trace_hex = std.fmt.bytesToHex(tracer.trace_id, .lower);
+    for (tracer.spans.items, 0..) |span, i| {
+        if (i > 0) try writer.writeAll(",");
+        try writer.print("\n  {{\"traceId\": \"{s}\", \"spanId\": \"{s}\"", .{ trace_hex, std.fmt.bytesToHex(span.id, .lower) });
+        if (span.parent) |parent| try writer.print(", \"parentSpanId\": \"{s}\"", .{std.fmt.bytesToHex(parent, .lower)});
+        try writer.writeAll(", \"name\": \"");
+        try output.writeJsonEscaped(writer, span.name);
+        // Kind 1 is SPAN_KIND_INTERNAL; 64-bit integers are strings in OTLP JSON
+        try writer.print("\", \"kind\": 1, \"startTimeUnixNano\": \"{d}\", \"endTimeUnixNano\": \"{d}\", \"attributes\": [", .{
+            span.start_ns,
+            @max(span.end_ns, span.start_ns),
+        });
+        for (span.attributes, 0..) |attribute, j| {
+            if (j > 0) try writer.writeAll(", ");
+            try writeAttribute(writer, attribute);
+        }
+        // Status code 1 is STATUS_CODE_OK, 2 is STATUS_CODE_ERROR
+        try writer.print("], \"status\": {{\"code\": {d}}}}}", .{@as(u8, if (span.failed) 2 else 1)});
+    }
+    try writer.writeAll("\n]}]}]}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+fn writeAttribute(writer: anytype, attribute: Attribute) !void {
+    try writer.writeAll("{\"key\": \"");
+    try output.writeJsonEscaped(writer, attribute.key);
+    switch (attribute.value) {
+        .string => |value| {
+            try writer.writeAll("\", \"value\": {\"stringValue\": \"");
+            try output.writeJsonEscaped(writer, value);
+            try writer.writeAll("\"}}");
+        },
+        .int => |value| try writer.print("\", \"value\": {{\"intValue\": \"{d}\"}}}}", .{value}),
+    }
+}
+
+/// Traces endpoint for a base OTLP/HTTP endpoint, as OTEL_EXPORTER_OTLP_ENDPOINT
+/// is interpreted: the signal path is appended
+pub fn tracesUrl(allocator: std.mem.Allocator, base: []const u8) ![]u8 {
+    return std.fmt.allocPrint(allocator, "{s}/v1/traces", .{std.mem.trimRight(u8, base, "/")});
+}
+
+/// POST an export request to an OTLP/HTTP traces endpoint. `headers` is in
+/// the OTEL_EXPORTER_OTLP_HEADERS format: comma-separated key=value pairs.
+pub fn exportTrace(allocator: std.mem.Allocator, url: []const u8, headers: ?[]const u8, payload: []const u8) !u16 {
+    var extra = std.ArrayList(std.http.Header){};
+    defer extra.deinit(allocator);
+    if (headers) |list| {
+        var pairs = std.mem.splitScalar(u8, list, ',');
+        while (pairs.next()) |pair| {
+            const eq = std.mem.indexOfScalar(u8, pair, '=') orelse continue;
+            const name = std.mem.trim(u8, pair[0..eq], " ");
+            if (name.len == 0) continue;
+            try extra.append(allocator, .{ .name = name, .value = std.mem.trim(u8, pair[eq + 1 ..], " ") });
+        }
+    }
+
+    var client = std.http.Client{ .allocator = allocator };
+    defer client.deinit();
+    const result = try client.fetch(.{
+        .location = .{ .url = url },
+        .method = .POST,
+        .payload = payload,
+        .headers = .{ .content_type = .{ .override = "application/json" } },
+        .extra_headers = extra.items,
+    });
+    return @intFromEnum(result.status);
+}
+
+/// Where a run's spans go, from flags and the standard OTEL_* variables
+pub const Destination = struct {
+    /// Full traces URL (ending in /v1/traces)
+    url: ?[]const u8 = null,
+    /// OTLP JSON is written here
+    file: ?[]const u8 = null,
+    /// OTEL_EXPORTER_OTLP_HEADERS, e.g. "x-honeycomb-team=KEY"
+    headers: ?[]const u8 = null,
+    service_name: []const u8 = "ananke",
+
+    /// `endpoint` (--trace-endpoint) is a base endpoint, like
+    /// OTEL_EXPORTER_OTLP_ENDPOINT; OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is
+    /// used as given. Strings are allocated in `arena`.
+    pub fn resolve(arena: std.mem.Allocator, endpoint: ?[]const u8, file: ?[]const u8) !Destination {
+        var destination = Destination{ .file = file };
+        if (endpoint) |base| {
+            destination.url = try tracesUrl(arena, base);
+        } else if (try getEnv(arena, "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")) |url| {
+            destination.url = url;
+        } else if (try getEnv(arena, "OTEL_EXPORTER_OTLP_ENDPOINT")) |base| {
+            destination.url = try tracesUrl(arena, base);
+        }
+        destination.headers = try getEnv(arena, "OTEL_EXPORTER_OTLP_TRACES_HEADERS") orelse try getEnv(arena, "OTEL_EXPORTER_OTLP_HEADERS");
+        if (try getEnv(arena, "OTEL_SERVICE_NAME")) |name| destination.service_name = name;
+        return destination;
+    }
+
+    pub fn enabled(self: Destination) bool {
+        return self.url != null or self.file != null;
+    }
+};
+
+fn getEnv(arena: std.mem.Allocator, name: []const u8) !?[]const u8 {
+    const value = std.process.getEnvVarOwned(arena, name) catch |err| switch (err) {
+        error.EnvironmentVariableNotFound => return null,
+        else => return err,
+    };
+    return if (value.len == 0) null else value;
+}
+
+/// Write and send the finished trace. Failures are printed as warnings:
+/// tracing never fails the run.
+pub fn flush(allocator: std.mem.Allocator, tracer: *const Tracer, destination: Destination, service_version: []const u8, verbose: bool) void {
+    const payload = formatOtlpJson(allocator, tracer, destination.service_name, service_version) catch |err| {
+        cli_error.printWarning("Could not format trace: {s}", .{@errorName(err)});
+        return;
+    };
+    defer allocator.free(payload);
+
+    if (destination.file) |path| {
+        std.fs.cwd().writeFile(.{ .sub_path = path, .data = payload }) catch |err| {
+            cli_error.printWarning("Could not write trace to {s}: {s}", .{ path, @errorName(err) });
+        };
+    }
+    if (destination.url) |url| {
+        const status = exportTrace(allocator, url, destination.headers, payload) catch |err| {
+            cli_error.printWarning("Trace export to {s} failed: {s}", .{ url, @errorName(err) });
+            return;
+        };
+        if (status < 200 or status >= 300) {
+            cli_error.printWarning("Trace export to {s} answered HTTP {d}", .{ url, status });
+        } else if (verbose) {
+            cli_error.printInfo("Exported {d} spans to {s}", .{ tracer.spans.items.len, url });
+        }
+    }
+}
+
+test "spans nest under stages and export as OTLP JSON" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tracer = try Tracer.init(allocator, "ananke.extract");
+    defer tracer.deinit();
+    tracer.beginStage("analyze");
+    var path = "src/a.go".*;
+    const file = try tracer.begin("analyze.file", tracer.stage);
+    tracer.end(file, &.{ Attribute.string("code.filepath", &path), Attribute.int("ananke.constraints", @as(usize, 4)) }, false);
+    // The caller's buffer may be reused once the span has ended
+    path[0] = 'X';
+    tracer.finish(&.{}, true);
+
+    const text = try formatOtlpJson(allocator, &tracer, "ananke", "0.2.1");
+    defer allocator.free(text);
+    const parsed = try std.json.parseFromSlice(std.json.Value, allocator, text, .{});
+    defer parsed.deinit();
+    const spans = parsed.value.object.get("resourceSpans").?.array.items[0].object.get("scopeSpans").?.array.items[0].object.get("spans").?.array.items;
+    try testing.expectEqual(@as(usize, 3), spans.len);
+
+    const root = spans[0].object;
+    const stage = spans[1].object;
+    const analyzed = spans[2].object;
+    try testing.expect(root.get("parentSpanId") == null);
+    try testing.expectEqual(@as(i64, 2), root.get("status").?.object.get("code").?.integer);
+    try testing.expectEqualStrings(root.get("spanId").?.string, stage.get("parentSpanId").?.string);
+    try testing.expectEqualStrings(stage.get("spanId").?.string, analyzed.get("parentSpanId").?.string);
+    try testing.expectEqual(@as(usize, 32), analyzed.get("traceId").?.string.len);
+    const attribute = analyzed.get("attributes").?.array.items[0].object;
+    try testing.expectEqualStrings("src/a.go", attribute.get("value").?.object.get("stringValue").?.string);
+    try testing.expectEqualStrings("4", analyzed.get("attributes").?.array.items[1].object.get("value").?.object.get("intValue").?.string);
+
+    const url = try tracesUrl(allocator, "http://localhost:4318/");
+    defer allocator.free(url);
+    try testing.expectEqualStrings("http://localhost:4318/v1/traces", url);
+}
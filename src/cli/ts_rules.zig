@@ -0,0 +1,402 @@
+// TypeScript validation rules
+// Recovers the rules of TypeScript DTOs in the shape `go_rules` gives Go
+// structs, so DTOs of services in either language can be compared:
+//   class-validator  classes whose properties carry decorators such as
+//                    @MinLength(3), @Max(120), @IsEmail(), @Matches(/re/)
+//   zod              `const X = z.object({...})` schemas with chains such as
+//                    z.string().min(3).max(50).optional()
+// Bounds must be integer literals. Parsing expects prettier-style layout.
+const std = @import("std");
+const go_rules = @import("cli_go_rules");
+
+const Check = go_rules.Check;
+
+fn isIdentChar(c: u8) bool {
+    return std.ascii.isAlphanumeric(c) or c == '_' or c == '$';
+}
+
+fn identAt(text: []const u8, start: usize) []const u8 {
+    var end = start;
+    while (end < text.len and isIdentChar(text[end])) end += 1;
+    return text[start..end];
+}
+
+fn lineAt(source: []const u8, offset: usize) u32 {
+    return @intCast(std.mem.count(u8, source[0..offset], "\n") + 1);
+}
+
+/// Index of the bracket closing the one at `open`, skipping string
+/// literals, or null when it is not closed
+fn matchingClose(text: []const u8, open: usize) ?usize {
+    var depth: usize = 0;
+    var i = open;
+    while (i < text.len) : (i += 1) {
+        switch (text[i]) {
+            '(', '[', '{' => depth += 1,
+            ')', ']', '}' => {
+                depth -= 1;
+                if (depth == 0) return i;
+            },
+            '"', '\'', '`' => {
+                const quote = text[i];
+                i += 1;
+                while (i < text.len and text[i] != quote) : (i += 1) {
+                    if (text[i] == '\\') i += 1;
+                }
+            },
+            else => {},
+        }
+    }
+    return null;
+}
+
+fn parseBound(text: []const u8) ?i64 {
+    return std.fmt.parseInt(i64, std.mem.trim(u8, text, " \n\r\t"), 10) catch null;
+}
+
+/// Body of a regex literal (`/^[a-z]+$/i`), or of a string passed to
+/// `new RegExp(...)`
+fn regexBody(text: []const u8) ?[]const u8 {
+    const trimmed = std.mem.trim(u8, text, " \n\r\t");
+    if (std.mem.startsWith(u8, trimmed, "/")) {
+        const close = std.mem.lastIndexOfScalar(u8, trimmed, '/') orelse return null;
+        if (close == 0) return null;
+        return trimmed[1..close];
+    }
+    const start = std.mem.indexOfAny(u8, trimmed, "'\"`") orelse return null;
+    const end = std.mem.lastIndexOfScalar(u8, trimmed, trimmed[start]) orelse return null;
+    if (end <= start) return null;
+    return trimmed[start + 1 .. end];
+}
+
+/// Space-separated options of an array literal of strings
+fn options(arena: std.mem.Allocator, text: []const u8) ![]const u8 {
+    var list = std.ArrayList([]const u8){};
+    var items = std.mem.tokenizeAny(u8, text, "[], \n\r\t");
+    while (items.next()) |item| {
+        try list.append(arena, std.mem.trim(u8, item, "'\"`"));
+    }
+    return std.mem.join(arena, " ", list.items);
+}
+
+/// Add the rule of a class-validator decorator; decorators that only
+/// assert a type (@IsString, @IsInt) add nothing
+fn applyDecorator(arena: std.mem.Allocator, name: []const u8, args: []const u8, field: *go_rules.Field, checks: *std.ArrayList(Check)) !void {
+    const Simple = struct { name: []const u8, kind: go_rules.CheckKind };
+    const bounded = [_]Simple{
+        .{ .name = "MinLength", .kind = .min_len },
+        .{ .name = "MaxLength", .kind = .max_len },
+        .{ .name = "Min", .kind = .min },
+        .{ .name = "Max", .kind = .max },
+        .{ .name = "ArrayMinSize", .kind = .min },
+        .{ .name = "ArrayMaxSize", .kind = .max },
+    };
+    for (bounded) |d| {
+        if (!std.mem.eql(u8, name, d.name)) continue;
+        if (parseBound(args)) |bound| try checks.append(arena, .{ .kind = d.kind, .bound = bound });
+        return;
+    }
+    if (std.mem.eql(u8, name, "IsNotEmpty") or std.mem.eql(u8, name, "IsDefined") or std.mem.eql(u8, name, "ArrayNotEmpty")) {
+        try checks.append(arena, .{ .kind = .required });
+    } else if (std.mem.eql(u8, name, "IsOptional")) {
+        field.optional = true;
+    } else if (std.mem.eql(u8, name, "IsEmail")) {
+        try checks.append(arena, .{ .kind = .email });
+    } else if (std.mem.eql(u8, name, "Length")) {
+        var bounds = std.mem.splitScalar(u8, args, ',');
+        if (parseBound(bounds.next() orelse "")) |min| try checks.append(arena, .{ .kind = .min_len, .bound = min });
+        if (parseBound(bounds.next() orelse "")) |max| try checks.append(arena, .{ .kind = .max_len, .bound = max });
+    } else if (std.mem.eql(u8, name, "Matches")) {
+        if (regexBody(args)) |pattern| try checks.append(arena, .{ .kind = .pattern, .text = pattern });
+    } else if (std.mem.eql(u8, name, "IsIn")) {
+        try checks.append(arena, .{ .kind = .one_of, .text = try options(arena, args) });
+    }
+}
+
+/// `name?: type;` of a class body, or null for methods and other members
+fn parseProperty(line: []const u8) ?struct { name: []const u8, ts_type: []const u8, optional: bool } {
+    var rest = line;
+    const modifiers = [_][]const u8{ "public ", "private ", "protected ", "readonly ", "declare " };
+    var stripped = true;
+    while (stripped) {
+        stripped = false;
+        for (modifiers) |modifier| {
+            if (std.mem.startsWith(u8, rest, modifier)) {
+                rest = std.mem.trimLeft(u8, rest[modifier.len..], " ");
+                stripped = true;
+            }
+        }
+    }
+    const name = identAt(rest, 0);
+    if (name.len == 0) return null;
+    var after = rest[name.len..];
+    var optional = false;
+    if (std.mem.startsWith(u8, after, "?")) {
+        optional = true;
+        after = after[1..];
+    } else if (std.mem.startsWith(u8, after, "!")) {
+        after = after[1..];
+    }
+    if (!std.mem.startsWith(u8, after, ":")) return null;
+    const type_end = std.mem.indexOfAny(u8, after, ";=") orelse after.len;
+    const ts_type = std.mem.trim(u8, after[1..type_end], " ");
+    if (ts_type.len == 0) return null;
+    return .{ .name = name, .ts_type = ts_type, .optional = optional };
+}
+
+/// class-validator DTOs: classes with at least one decorated property
+fn parseClasses(arena: std.mem.Allocator, source: []const u8, structs: *std.ArrayList(go_rules.Struct)) !void {
+    var lines = std.ArrayList([]const u8){};
+    var it = std.mem.splitScalar(u8, source, '\n');
+    while (it.next()) |line| try lines.append(arena, line);
+
+    var i: usize = 0;
+    while (i < lines.items.len) : (i += 1) {
+        const line = std.mem.trim(u8, lines.items[i], " \t\r");
+        const at = std.mem.indexOf(u8, line, "class ") orelse continue;
+        if (at > 0 and line[at - 1] != ' ') continue;
+        if (!std.mem.endsWith(u8, line, "{")) continue;
+        const name = identAt(line, at + "class ".len);
+        if (name.len == 0) continue;
+
+        var fields = std.ArrayList(go_rules.Field){};
+        var checks = std.ArrayList(Check){};
+        var draft = go_rules.Field{ .name = "", .go_type = "", .line = 0 };
+        const class_line: u32 = @intCast(i + 1);
+        var depth: usize = 1;
+        i += 1;
+        while (i < lines.items.len and depth > 0) : (i += 1) {
+            var member = std.mem.trim(u8, lines.items[i], " \t\r");
+            if (depth == 1 and std.mem.eql(u8, member, "}")) break;
+            if (depth > 1 or std.mem.startsWith(u8, member, "//")) {
+                depth += std.mem.count(u8, member, "{");
+                depth -|= std.mem.count(u8, member, "}");
+                continue;
+            }
+            // Decorators, possibly on the property's own line
+            while (std.mem.startsWith(u8, member, "@")) {
+                const decorator = identAt(member, 1);
+                const open = 1 + decorator.len;
+                var args: []const u8 = "";
+                var next = open;
+                if (open < member.len and member[open] == '(') {
+                    const close = matchingClose(member, open) orelse member.len - 1;
+                    args = member[open + 1 .. close];
+                    next = close + 1;
+                }
+                try applyDecorator(arena, decorator, args, &draft, &checks);
+                member = std.mem.trimLeft(u8, member[@min(next, member.len)..], " ");
+            }
+            if (member.len == 0) continue;
+            if (parseProperty(member)) |property| {
+                draft.name = property.name;
+                draft.go_type = property.ts_type;
+                draft.line = @intCast(i + 1);
+                draft.optional = draft.optional or property.optional;
+                draft.checks = try checks.toOwnedSlice(arena);
+                try fields.append(arena, draft);
+            } else {
+                depth += std.mem.count(u8, member, "{");
+                depth -|= std.mem.count(u8, member, "}");
+            }
+            draft = .{ .name = "", .go_type = "", .line = 0 };
+            checks = .{};
+        }
+
+        const value = go_rules.Struct{ .name = name, .line = class_line, .fields = fields.items };
+        if (value.hasChecks()) try structs.append(arena, value);
+    }
+}
+
+/// Rules of one zod schema expression, e.g. `z.string().min(3).optional()`
+fn parseZodField(arena: std.mem.Allocator, expr: []const u8, field: *go_rules.Field) !void {
+    var checks = std.ArrayList(Check){};
+    var rest = std.mem.trim(u8, expr, " \n\r\t");
+    if (!std.mem.startsWith(u8, rest, "z")) return;
+    rest = std.mem.trimLeft(u8, rest[1..], " \n\r\t");
+    if (!std.mem.startsWith(u8, rest, ".")) return;
+    rest = rest[1..];
+    var first = true;
+    while (true) {
+        const method = identAt(rest, 0);
+        if (method.len == 0 or method.len >= rest.len or rest[method.len] != '(') break;
+        const close = matchingClose(rest, method.len) orelse break;
+        const args = rest[method.len + 1 .. close];
+        rest = std.mem.trimLeft(u8, rest[close + 1 ..], " \n\r\t");
+
+        if (first) {
+            first = false;
+            field.go_type = if (std.mem.eql(u8, method, "array")) "array" else method;
+            if (std.mem.eql(u8, method, "enum")) {
+                field.go_type = "string";
+                try checks.append(arena, .{ .kind = .one_of, .text = try options(arena, args) });
+            }
+        } else {
+            const is_string = std.mem.eql(u8, field.go_type, "string");
+            const lower: go_rules.CheckKind = if (is_string) .min_len else .min;
+            const upper: go_rules.CheckKind = if (is_string) .max_len else .max;
+            if (std.mem.eql(u8, method, "min") or std.mem.eql(u8, method, "gte")) {
+                if (parseBound(args)) |bound| try checks.append(arena, .{ .kind = lower, .bound = bound });
+            } else if (std.mem.eql(u8, method, "max") or std.mem.eql(u8, method, "lte")) {
+                if (parseBound(args)) |bound| try checks.append(arena, .{ .kind = upper, .bound = bound });
+            } else if (std.mem.eql(u8, method, "gt")) {
+                if (parseBound(args)) |bound| try checks.append(arena, .{ .kind = lower, .bound = bound + 1 });
+            } else if (std.mem.eql(u8, method, "lt")) {
+                if (parseBound(args)) |bound| try checks.append(arena, .{ .kind = upper, .bound = bound - 1 });
+            } else if (std.mem.eql(u8, method, "length")) {
+                if (parseBound(args)) |bound| {
+                    try checks.append(arena, .{ .kind = lower, .bound = bound });
+                    try checks.append(arena, .{ .kind = upper, .bound = bound });
+                }
+            } else if (std.mem.eql(u8, method, "positive")) {
+                try checks.append(arena, .{ .kind = .min, .bound = 1 });
+            } else if (std.mem.eql(u8, method, "nonnegative")) {
+                try checks.append(arena, .{ .kind = .min, .bound = 0 });
+            } else if (std.mem.eql(u8, method, "nonempty")) {
+                try checks.append(arena, .{ .kind = .required });
+            } else if (std.mem.eql(u8, method, "email")) {
+                try checks.append(arena, .{ .kind = .email });
+            } else if (std.mem.eql(u8, method, "regex")) {
+                if (regexBody(args)) |pattern| try checks.append(arena, .{ .kind = .pattern, .text = pattern });
+            } else if (std.mem.eql(u8, method, "optional") or std.mem.eql(u8, method, "nullish") or std.mem.eql(u8, method, "nullable")) {
+                field.optional = true;
+            }
+        }
+        if (!std.mem.startsWith(u8, rest, ".")) break;
+        rest = std.mem.trimLeft(u8, rest[1..], " \n\r\t");
+    }
+    field.checks = checks.items;
+}
+
+/// zod DTOs: `const Name = z.object({ ... })`
+fn parseZod(arena: std.mem.Allocator, source: []const u8, structs: *std.ArrayList(go_rules.Struct)) !void {
+    const marker = "= z.object(";
+    var search: usize = 0;
+    while (std.mem.indexOfPos(u8, source, search, marker)) |at| {
+        search = at + marker.len;
+        const line_start = if (std.mem.lastIndexOfScalar(u8, source[0..at], '\n')) |nl| nl + 1 else 0;
+        const head = std.mem.trim(u8, source[line_start..at], " \t");
+        const const_at = std.mem.lastIndexOf(u8, head, "const ") orelse continue;
+        const name = identAt(head, const_at + "const ".len);
+        if (name.len == 0) continue;
+
+        const open = std.mem.indexOfScalarPos(u8, source, search, '{') orelse continue;
+        const close = matchingClose(source, open) orelse continue;
+        const body = source[open + 1 .. close];
+
+        var fields = std.ArrayList(go_rules.Field){};
+        var start: usize = 0;
+        var i: usize = 0;
+        while (i <= body.len) : (i += 1) {
+            if (i < body.len) {
+                switch (body[i]) {
+                    '(', '[', '{', '"', '\'', '`' => {
+                        i = matchingCloseOrQuote(body, i) orelse body.len - 1;
+                        continue;
+                    },
+                    ',' => {},
+                    else => continue,
+                }
+            }
+            const entry_start = start;
+            const entry = body[entry_start..i];
+            start = i + 1;
+            const trimmed = std.mem.trimLeft(u8, entry, " \n\r\t");
+            const key = identAt(trimmed, 0);
+            if (key.len == 0) continue;
+            const colon_rest = std.mem.trimLeft(u8, trimmed[key.len..], " ");
+            if (!std.mem.startsWith(u8, colon_rest, ":")) continue;
+            const offset = open + 1 + entry_start + (entry.len - trimmed.len);
+            var field = go_rules.Field{ .name = key, .go_type = "", .line = lineAt(source, offset) };
+            try parseZodField(arena, colon_rest[1..], &field);
+            if (field.go_type.len > 0) try fields.append(arena, field);
+        }
+
+        const value = go_rules.Struct{ .name = name, .line = lineAt(source, at), .fields = fields.items };
+        if (value.hasChecks()) try structs.append(arena, value);
+        search = close;
+    }
+}
+
+/// Like `matchingClose`, also skipping over a string literal starting at
+/// `open`
+fn matchingCloseOrQuote(text: []const u8, open: usize) ?usize {
+    const quote = text[open];
+    if (quote != '"' and quote != '\'' and quote != '`') return matchingClose(text, open);
+    var i = open + 1;
+    while (i < text.len and text[i] != quote) : (i += 1) {
+        if (text[i] == '\\') i += 1;
+    }
+    return if (i < text.len) i else null;
+}
+
+/// DTOs with rules in the TypeScript `source`, in the order they appear per
+/// kind. Everything lives in `arena` or borrows `source`.
+pub fn parse(arena: std.mem.Allocator, source: []const u8) ![]const go_rules.Struct {
+    var structs = std.ArrayList(go_rules.Struct){};
+    try parseClasses(arena, source, &structs);
+    try parseZod(arena, source, &structs);
+    return structs.items;
+}
+
+test "parse class-validator and zod DTOs" {
+    const testing = std.testing;
+    var arena_state = std.heap.ArenaAllocator.init(testing.allocator);
+    defer arena_state.deinit();
+    const arena = arena_state.allocator();
+
+    const source =
+        \\import { IsEmail, IsOptional, Length, Matches, Min } from 'class-validator';
+        \\import { z } from 'zod';
+        \\
+        \\export class CreateUserDto {
+        \\  @IsString()
+        \\  @Length(1, 30)
+        \\  @Matches(/^[a-z0-9_]+$/)
+        \\  username: string;
+        \\
+        \\  @IsEmail()
+        \\  email!: string;
+        \\
+        \\  @IsOptional() @Min(18)
+        \\  age?: number;
+        \\
+        \\  describe(): string {
+        \\    return `${this.username}`;
+        \\  }
+        \\}
+        \\
+        \\export const UserSchema = z.object({
+        \\  username: z.string().min(3).max(50),
+        \\  role: z.enum(["admin", "member"]),
+        \\  tags: z
+        \\    .array(z.string())
+        \\    .max(5)
+        \\    .optional(),
+        \\});
+    ;
+    const structs = try parse(arena, source);
+    try testing.expectEqual(@as(usize, 2), structs.len);
+
+    const dto = structs[0];
+    try testing.expectEqualStrings("CreateUserDto", dto.name);
+    try testing.expectEqual(@as(u32, 4), dto.line);
+    try testing.expectEqual(@as(usize, 3), dto.fields.len);
+    try testing.expectEqualStrings("username", dto.fields[0].name);
+    try testing.expectEqual(@as(u32, 8), dto.fields[0].line);
+    try testing.expectEqual(@as(usize, 3), dto.fields[0].checks.len);
+    try testing.expectEqual(@as(i64, 30), dto.fields[0].checks[1].bound);
+    try testing.expectEqualStrings("^[a-z0-9_]+$", dto.fields[0].checks[2].text);
+    try testing.expectEqual(go_rules.CheckKind.email, dto.fields[1].checks[0].kind);
+    try testing.expect(dto.fields[2].optional);
+    try testing.expectEqual(go_rules.CheckKind.min, dto.fields[2].checks[0].kind);
+
+    const schema = structs[1];
+    try testing.expectEqualStrings("UserSchema", schema.name);
+    try testing.expectEqual(@as(usize, 3), schema.fields.len);
+    try testing.expectEqual(go_rules.CheckKind.max_len, schema.fields[0].checks[1].kind);
+    try testing.expectEqual(@as(u32, 22), schema.fields[0].line);
+    try testing.expectEqualStrings("admin member", schema.fields[1].checks[0].text);
+    try testing.expectEqual(go_rules.CheckKind.max, schema.fields[2].checks[0].kind);
+    try testing.expect(schema.fields[2].optional);
+}
@@ -0,0 +1,234 @@
+// Warm-start discovery snapshots
+// On a large tree, walking every directory and parsing every .gitignore is
+// most of a cold `extract`'s startup. After a walk, the file list is saved in
+// the cache directory together with the mtime of each directory read and of
+// its .gitignore. The next run with the same root and discovery options stats
+// those paths instead of listing them: adding, removing, or renaming an entry
+// updates its directory's mtime, and editing a .gitignore updates its own, so
+// an unchanged set of mtimes means the walk would produce the same files.
+//
+// Pattern rules are compiled into the binary and their digests and bucket
+// tables take microseconds to build, so they are not persisted.
+//
+//   ananke-warm 2
+//   d <mtime> <gitignore-mtime|-> <dir>     walked directory, relative to root
+//   a <mtime|-> <path>                      .gitignore above the root, relative to it
+//   f <path>                                discovered file
+//   s <reason> <0|1> <path>                 skipped path and whether it is a dir
+const std = @import("std");
+const cache_store = @import("cli_cache_store");
+const discovery = @import("cli_discovery");
+
+const magic = "ananke-warm 2";
+const max_state_bytes = 256 * 1024 * 1024;
+
+pub const Key = [32]u8;
+
+/// Identify one discovery configuration. Snapshots from another tool version
+/// or with other excludes, gitignore handling, or language filter never match.
+pub fn discoveryKey(tool_version: []const u8, root: []const u8, options: discovery.Options) Key {
+    var hasher = std.crypto.hash.sha2.Sha256.init(.{});
+    hasher.update(tool_version);
+    hasher.update(&.{0});
+    hasher.update(std.mem.trimRight(u8, root, "/"));
+    hasher.update(&.{0});
+    for (options.excludes) |exclude| {
+        hasher.update(exclude);
+        hasher.update(&.{0});
+    }
+    hasher.update(&.{ @intFromBool(options.use_gitignore), @intFromBool(options.use_default_excludes) });
+    hasher.update(options.language orelse "");
+    return std.fmt.bytesToHex(hasher.finalResult()[0..16].*, .lower);
+}
+
+fn statePath(buf: []u8, key: *const Key) []const u8 {
+    return std.fmt.bufPrint(buf, "{s}/{s}.state", .{ cache_store.warm_dir, key }) catch unreachable;
+}
+
+/// The file set saved under `key`, if every directory and .gitignore it was
+/// built from is unchanged. Null on a missing, stale, or unreadable snapshot.
+pub fn loadDiscovery(
+    allocator: std.mem.Allocator,
+    cache_dir: std.fs.Dir,
+    key: *const Key,
+    root: []const u8,
+    use_gitignore: bool,
+) !?discovery.FileSet {
+    var path_buf: [64]u8 = undefined;
+    const text = cache_dir.readFileAlloc(allocator, statePath(&path_buf, key), max_state_bytes) catch return null;
+    defer allocator.free(text);
+
+    var root_dir = std.fs.cwd().openDir(root, .{}) catch return null;
+    defer root_dir.close();
+
+    var set = discovery.FileSet.init(allocator);
+    errdefer set.deinit();
+    const arena = set.arena.allocator();
+
+    var lines = std.mem.splitScalar(u8, text, '\n');
+    if (!std.mem.eql(u8, lines.next() orelse "", magic)) {
+        set.deinit();
+        return null;
+    }
+    while (lines.next()) |line| {
+        if (line.len == 0) continue;
+        const current = parseLine(&set, arena, root_dir, line, use_gitignore) catch |err| switch (err) {
+            error.OutOfMemory => return err,
+            else => false,
+        };
+        if (!current) {
+            set.deinit();
+            return null;
+        }
+    }
+    return set;
+}
+
+/// Apply one snapshot line to `set`. Returns false when a walked directory
+/// or a .gitignore in or above it changed since the snapshot.
+fn parseLine(
+    set: *discovery.FileSet,
+    arena: std.mem.Allocator,
+    root_dir: std.fs.Dir,
+    line: []const u8,
+    use_gitignore: bool,
+) !bool {
+    var fields = std.mem.splitScalar(u8, line, ' ');
+    const tag = fields.next() orelse return error.InvalidState;
+    if (std.mem.eql(u8, tag, "d")) {
+        const mtime = try std.fmt.parseInt(i128, fields.next() orelse "", 10);
+        const ignore_field = fields.next() orelse return error.InvalidState;
+        const gitignore_mtime = if (std.mem.eql(u8, ignore_field, "-")) null else try std.fmt.parseInt(i128, ignore_field, 10);
+        const dir = fields.rest();
+
+        const stat = root_dir.statFile(if (dir.len == 0) "." else dir) catch return false;
+        if (stat.kind != .directory or stat.mtime != mtime) return false;
+        if (use_gitignore) {
+            // A .gitignore that appeared, vanished, or was edited changes the rules
+            var ignore_buf: [std.fs.max_path_bytes]u8 = undefined;
+            const ignore_path = if (dir.len == 0)
+                ".gitignore"
+            else
+                try std.fmt.bufPrint(&ignore_buf, "{s}/.gitignore", .{dir});
+            const ignore_mtime: ?i128 = if (root_dir.statFile(ignore_path)) |ignore_stat| ignore_stat.mtime else |_| null;
+            const unchanged = if (ignore_mtime) |m| gitignore_mtime != null and gitignore_mtime.? == m else gitignore_mtime == null;
+            if (!unchanged) return false;
+        }
+        try set.walked.append(set.allocator, .{
+            .path = try arena.dupe(u8, dir),
+            .mtime = mtime,
+            .gitignore_mtime = gitignore_mtime,
+        });
+    } else if (std.mem.eql(u8, tag, "a")) {
+        const mtime_field = fields.next() orelse return error.InvalidState;
+        const mtime = if (std.mem.eql(u8, mtime_field, "-")) null else try std.fmt.parseInt(i128, mtime_field, 10);
+        const path = fields.rest();
+        if (use_gitignore) {
+            const current: ?i128 = if (root_dir.statFile(path)) |stat| stat.mtime else |_| null;
+            const unchanged = if (current) |m| mtime != null and mtime.? == m else mtime == null;
+            if (!unchanged) return false;
+        }
+        try set.ancestor_ignores.append(set.allocator, .{ .path = try arena.dupe(u8, path), .mtime = mtime });
+    } else if (std.mem.eql(u8, tag, "f")) {
+        const path = fields.rest();
+        try set.files.append(set.allocator, .{
+            .path = try arena.dupe(u8, path),
+            .language = discovery.detectLanguage(path),
+        });
+    } else if (std.mem.eql(u8, tag, "s")) {
+        const reason = std.meta.stringToEnum(discovery.SkipReason, fields.next() orelse "") orelse return error.InvalidState;
+        const is_dir = std.mem.eql(u8, fields.next() orelse "", "1");
+        try set.skipped.append(set.allocator, .{
+            .path = try arena.dupe(u8, fields.rest()),
+            .is_dir = is_dir,
+            .reason = reason,
+        });
+    } else {
+        return error.InvalidState;
+    }
+    return true;
+}
+
+/// Save `set` under `key`. Sets that were not produced by a walk, or with
+/// paths the line format cannot hold, are not saved.
+pub fn saveDiscovery(
+    allocator: std.mem.Allocator,
+    cache_dir: std.fs.Dir,
+    key: *const Key,
+    set: *const discovery.FileSet,
+) !void {
+    if (set.walked.items.len == 0) return;
+
+    var text = std.ArrayList(u8){};
+    defer text.deinit(allocator);
+    const writer = text.writer(allocator);
+    try writer.print("{s}\n", .{magic});
+    for (set.walked.items) |dir| {
+        if (std.mem.indexOfScalar(u8, dir.path, '\n') != null) return;
+        if (dir.gitignore_mtime) |ignore_mtime| {
+            try writer.print("d {d} {d} {s}\n", .{ dir.mtime, ignore_mtime, dir.path });
+        } else {
+            try writer.print("d {d} - {s}\n", .{ dir.mtime, dir.path });
+        }
+    }
+    for (set.ancestor_ignores.items) |ignore| {
+        if (std.mem.indexOfScalar(u8, ignore.path, '\n') != null) return;
+        if (ignore.mtime) |mtime| {
+            try writer.print("a {d} {s}\n", .{ mtime, ignore.path });
+        } else {
+            try writer.print("a - {s}\n", .{ignore.path});
+        }
+    }
+    for (set.files.items) |file| {
+        if (std.mem.indexOfScalar(u8, file.path, '\n') != null) return;
+        try writer.print("f {s}\n", .{file.path});
+    }
+    for (set.skipped.items) |skipped| {
+        if (std.mem.indexOfScalar(u8, skipped.path, '\n') != null) return;
+        try writer.print("s {s} {d} {s}\n", .{ @tagName(skipped.reason), @intFromBool(skipped.is_dir), skipped.path });
+    }
+
+    try cache_dir.makePath(cache_store.warm_dir);
+    var path_buf: [64]u8 = undefined;
+    try cache_store.writeAtomic(cache_dir, statePath(&path_buf, key), text.items);
+}
+
+test "warm discovery is reused until a directory changes" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    var tmp = testing.tmpDir(.{});
+    defer tmp.cleanup();
+    try tmp.dir.makePath("repo/src");
+    try tmp.dir.makePath("cache");
+    try tmp.dir.writeFile(.{ .sub_path = "repo/src/main.go", .data = "package main\n" });
+    try tmp.dir.writeFile(.{ .sub_path = "repo/README.md", .data = "docs\n" });
+
+    const root = try tmp.dir.realpathAlloc(allocator, "repo");
+    defer allocator.free(root);
+    var cache_dir = try tmp.dir.openDir("cache", .{});
+    defer cache_dir.close();
+
+    const options = discovery.Options{};
+    const key = discoveryKey("test", root, options);
+    try testing.expect((try loadDiscovery(allocator, cache_dir, &key, root, true)) == null);
+
+    var walked = try discovery.discover(allocator, root, options);
+    defer walked.deinit();
+    try saveDiscovery(allocator, cache_dir, &key, &walked);
+
+    var warm = (try loadDiscovery(allocator, cache_dir, &key, root, true)).?;
+    defer warm.deinit();
+    try testing.expectEqual(walked.files.items.len, warm.files.items.len);
+    try testing.expectEqualStrings(walked.files.items[0].path, warm.files.items[0].path);
+    try testing.expectEqualStrings("go", warm.files.items[0].language);
+    try testing.expectEqual(walked.skippedCount(.unsupported_language), warm.skippedCount(.unsupported_language));
+
+    // Other options never share a snapshot
+    const other = discoveryKey("test", root, .{ .language = "go" });
+    try testing.expect(!std.mem.eql(u8, &key, &other));
+
+    // A new .gitignore invalidates the snapshot
+    try tmp.dir.writeFile(.{ .sub_path = "repo/src/.gitignore", .data = "*.go\n" });
+    try testing.expect((try loadDiscovery(allocator, cache_dir, &key, root, true)) == null);
+}
@@ -0,0 +1,163 @@
+// Completion webhooks
+// POSTs a JSON payload to each configured URL when an extraction or
+// validation run completes, so downstream systems can react without
+// polling. With a secret configured, the body is signed like GitHub's
+// webhooks: `X-Ananke-Signature: sha256=<hex HMAC-SHA256 of the body>`.
+// Delivery never fails the run; a URL that cannot be reached is reported
+// as a warning.
+const std = @import("std");
+const config_mod = @import("cli_config");
+const cli_error = @import("cli_error");
+const output = @import("cli_output");
+
+const HmacSha256 = std.crypto.auth.hmac.sha2.HmacSha256;
+
+pub const Event = enum {
+    extract,
+    validate,
+    /// Sent by drift notifications (cli_notify), not on completion
+    drift,
+
+    /// Name sent in the payload and the `X-Ananke-Event` header
+    pub fn name(self: Event) []const u8 {
+        return switch (self) {
+            .extract => "extract.completed",
+            .validate => "validate.completed",
+            .drift => "drift.detected",
+        };
+    }
+};
+
+/// Where a run's completion is posted
+pub const Hooks = struct {
+    urls: []const []const u8 = &.{},
+    /// URL given with --webhook, posted to in addition to `urls`
+    extra: ?[]const u8 = null,
+    /// Events to post (e.g. "extract"); empty for all
+    events: []const []const u8 = &.{},
+    secret: ?[]const u8 = null,
+
+    pub fn fromConfig(config: config_mod.Config, flag: ?[]const u8) Hooks {
+        return .{
+            .urls = config.webhook_urls,
+            .extra = flag,
+            .events = config.webhook_events,
+            .secret = config.webhook_secret.slice(),
+        };
+    }
+
+    pub fn wants(self: Hooks, event: Event) bool {
+        if (self.urls.len == 0 and self.extra == null) return false;
+        if (self.events.len == 0) return true;
+        for (self.events) |wanted| {
+            if (std.mem.eql(u8, wanted, @tagName(event)) or std.mem.eql(u8, wanted, event.name())) return true;
+        }
+        return false;
+    }
+};
+
+/// Payload of a completion: the event, the tool version, when it finished,
+/// what ran on, its outcome ("completed", "passed", "failed", or "drift"),
+/// and the run's summary (a JSON object)
+pub fn formatPayload(
+    allocator: std.mem.Allocator,
+    event: Event,
+    tool_version: []const u8,
+    timestamp: i64,
+    target: []const u8,
+    status: []const u8,
+    summary_json: []const u8,
+) ![]u8 {
+    var list = std.ArrayList(u8){};
+    errdefer list.deinit(allocator);
+    const writer = list.writer(allocator);
+
+    try writer.print("{{\"event\": \"{s}\", \"tool\": \"ananke\", \"version\": \"", .{event.name()});
+    try output.writeJsonEscaped(writer, tool_version);
+    try writer.print("\", \"timestamp\": {d}, \"target\": \"", .{timestamp});
+    try output.writeJsonEscaped(writer, target);
+    try writer.print("\", \"status\": \"{s}\", \"summary\": ", .{status});
+    try writer.writeAll(std.mem.trimRight(u8, summary_json, "\n"));
+    try writer.writeAll("}\n");
+    return list.toOwnedSlice(allocator);
+}
+
+/// `sha256=` followed by the hex HMAC-SHA256 of `payload` keyed with `secret`
+pub fn signature(secret: []const u8, payload: []const u8) [7 + HmacSha256.mac_length * 2]u8 {
+    var mac: [HmacSha256.mac_length]u8 = undefined;
+    HmacSha256.create(&mac, payload, secret);
+    var text: [7 + HmacSha256.mac_length * 2]u8 = undefined;
+    @memcpy(text[0..7], "sha256=");
+    @memcpy(text[7..], &std.fmt.bytesToHex(mac, .lower));
+    return text;
+}
+
+/// POST `payload` to one URL and return the HTTP status
+pub fn post(allocator: std.mem.Allocator, url: []const u8, event: Event, payload: []const u8, secret: ?[]const u8) !u16 {
+    var headers: [2]std.http.Header = .{
+        .{ .name = "x-ananke-event", .value = event.name() },
+        undefined,
+    };
+    var signed: [7 + HmacSha256.mac_length * 2]u8 = undefined;
+    var header_count: usize = 1;
+    if (secret) |key| {
+        signed = signature(key, payload);
+        headers[1] = .{ .name = "x-ananke-signature", .value = &signed };
+        header_count = 2;
+    }
+
+    var client = std.http.Client{ .allocator = allocator };
+    defer client.deinit();
+    const result = try client.fetch(.{
+        .location = .{ .url = url },
+        .method = .POST,
+        .payload = payload,
+        .headers = .{ .content_type = .{ .override = "application/json" } },
+        .extra_headers = headers[0..header_count],
+    });
+    return @intFromEnum(result.status);
+}
+
+/// Post a completion to every hook that wants `event`. Failures are
+/// printed as warnings and otherwise ignored.
+pub fn notify(allocator: std.mem.Allocator, hooks: Hooks, event: Event, payload: []const u8, verbose: bool) void {
+    if (!hooks.wants(event)) return;
+    for (hooks.urls) |url| deliver(allocator, url, hooks, event, payload, verbose);
+    if (hooks.extra) |url| deliver(allocator, url, hooks, event, payload, verbose);
+}
+
+fn deliver(allocator: std.mem.Allocator, url: []const u8, hooks: Hooks, event: Event, payload: []const u8, verbose: bool) void {
+    const status = post(allocator, url, event, payload, hooks.secret) catch |err| {
+        cli_error.printWarning("Webhook {s} failed: {s}", .{ url, @errorName(err) });
+        return;
+    };
+    if (status < 200 or status >= 300) {
+        cli_error.printWarning("Webhook {s} answered HTTP {d}", .{ url, status });
+    } else if (verbose) {
+        cli_error.printInfo("Posted {s} to {s}", .{ event.name(), url });
+    }
+}
+
+test "webhook payload and signature" {
+    const testing = std.testing;
+    const allocator = testing.allocator;
+
+    const payload = try formatPayload(allocator, .extract, "0.1.0", 1700000000, "src/\"api\"", "completed", "{\n  \"total\": 3\n}\n");
+    defer allocator.free(payload);
+    const parsed = try std.json.parseFromSlice(std.json.Value, allocator, payload, .{});
+    defer parsed.deinit();
+    const root = parsed.value.object;
+    try testing.expectEqualStrings("extract.completed", root.get("event").?.string);
+    try testing.expectEqualStrings("src/\"api\"", root.get("target").?.string);
+    try testing.expectEqualStrings("completed", root.get("status").?.string);
+    try testing.expectEqual(@as(i64, 3), root.get("summary").?.object.get("total").?.integer);
+
+    // RFC 4231 test case 2
+    const signed = signature("Jefe", "what do ya want for nothing?");
+    try testing.expectEqualStrings("sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843", &signed);
+
+    const hooks = Hooks{ .urls = &.{"http://ci.internal/hook"}, .events = &.{"validate"} };
+    try testing.expect(hooks.wants(.validate));
+    try testing.expect(!hooks.wants(.extract));
+    try testing.expect(!(Hooks{}).wants(.extract));
+}
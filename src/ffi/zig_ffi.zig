@@ -659,6 +659,96 @@ export fn ananke_free_constraint_ir(ir: ?*ConstraintIRFFI) callconv(.c) c_int {
     return @intFromEnum(AnankeError.Success);
 }
 
+/// A constraint passed to an `ananke_extract_stream` callback
+///
+/// Strings are null-terminated and valid only until the callback returns.
+pub const StreamedConstraintFFI = extern struct {
+    /// Constraint name
+    name: [*:0]const u8,
+
+    /// Detailed description
+    description: [*:0]const u8,
+
+    /// Constraint kind name (e.g. "type_safety")
+    kind: [*:0]const u8,
+
+    /// 1-based source line, or 0 when unknown
+    line: u32,
+
+    /// Confidence level (0.0 to 1.0)
+    confidence: f32,
+};
+
+/// Receives each constraint as it is found; return 0 to continue, nonzero to stop
+pub const StreamCallback = *const fn (
+    user_data: ?*anyopaque,
+    source_index: usize,
+    constraint: *const StreamedConstraintFFI,
+) callconv(.c) c_int;
+
+/// Extract constraints from several sources, delivering each one to `callback`
+/// as soon as its source has been analyzed
+///
+/// # Parameters
+/// - sources: Array of `count` source code buffers
+/// - source_lens: Byte length of each source, so sources may hold NUL bytes;
+///   NULL when every source is null-terminated instead
+/// - languages: Array of `count` null-terminated language names
+/// - count: Number of sources
+/// - callback: Called once per constraint with the index of its source
+/// - user_data: Passed through to `callback` unchanged
+///
+/// # Returns
+/// Error code (0 = success, also when the callback stopped the stream)
+///
+/// # Safety
+/// Nothing is allocated for the caller; copy any strings that must outlive
+/// the callback.
+export fn ananke_extract_stream(
+    sources: [*]const [*]const u8,
+    source_lens: ?[*]const usize,
+    languages: [*]const [*:0]const u8,
+    count: usize,
+    callback: ?StreamCallback,
+    user_data: ?*anyopaque,
+) callconv(.c) c_int {
+    const deliver = callback orelse return @intFromEnum(AnankeError.NullPointer);
+
+    var clew = Clew.init(gpa) catch {
+        return @intFromEnum(AnankeError.AllocationFailure);
+    };
+    defer clew.deinit();
+
+    // Null-terminated copies for the callback, reused for every constraint
+    var scratch = std.heap.ArenaAllocator.init(gpa);
+    defer scratch.deinit();
+
+    for (0..count) |index| {
+        const source = if (source_lens) |lens|
+            sources[index][0..lens[index]]
+        else
+            std.mem.span(@as([*:0]const u8, @ptrCast(sources[index])));
+        var constraint_set = clew.extractFromCode(source, std.mem.span(languages[index])) catch {
+            return @intFromEnum(AnankeError.ExtractionFailed);
+        };
+        defer constraint_set.deinit();
+
+        for (constraint_set.constraints.items) |constraint| {
+            _ = scratch.reset(.retain_capacity);
+            const arena = scratch.allocator();
+            const streamed = StreamedConstraintFFI{
+                .name = (arena.dupeZ(u8, constraint.name) catch return @intFromEnum(AnankeError.AllocationFailure)).ptr,
+                .description = (arena.dupeZ(u8, constraint.description) catch return @intFromEnum(AnankeError.AllocationFailure)).ptr,
+                .kind = @tagName(constraint.kind),
+                .line = constraint.origin_line orelse 0,
+                .confidence = constraint.confidence,
+            };
+            if (deliver(user_data, index, &streamed) != 0) return @intFromEnum(AnankeError.Success);
+        }
+    }
+    return @intFromEnum(AnankeError.Success);
+}
+
 /// Get version information
 ///
 /// Returns a null-terminated string with version info.
@@ -908,3 +998,55 @@ test "FFI outstanding allocation tracking" {
 
     ananke_deinit();
 }
+
+test "FFI extract stream delivers constraints per source" {
+    const testing = std.testing;
+
+    const Counter = struct {
+        per_source: [2]usize = .{ 0, 0 },
+        stop_after: usize = std.math.maxInt(usize),
+        seen: usize = 0,
+
+        fn onConstraint(user_data: ?*anyopaque, source_index: usize, constraint: *const StreamedConstraintFFI) callconv(.c) c_int {
+            const self: *@This() = @ptrCast(@alignCast(user_data.?));
+            self.per_source[source_index] += 1;
+            self.seen += 1;
+            if (std.mem.len(constraint.name) == 0) return 1;
+            return @intFromBool(self.seen >= self.stop_after);
+        }
+    };
+
+    const sources = [_][*]const u8{
+        "function greet(name: string): string { return name; }",
+        "def add(a: int, b: int) -> int:\n    return a + b\n",
+    };
+    const languages = [_][*:0]const u8{ "typescript", "python" };
+
+    var counter = Counter{};
+    const result = ananke_extract_stream(&sources, null, &languages, 2, &Counter.onConstraint, &counter);
+    try testing.expectEqual(@intFromEnum(AnankeError.Success), result);
+    try testing.expect(counter.per_source[0] > 0);
+    try testing.expect(counter.per_source[1] > 0);
+
+    var stopping = Counter{ .stop_after = 1 };
+    _ = ananke_extract_stream(&sources, null, &languages, 2, &Counter.onConstraint, &stopping);
+    try testing.expectEqual(@as(usize, 1), stopping.seen);
+
+    try testing.expectEqual(
+        @intFromEnum(AnankeError.NullPointer),
+        ananke_extract_stream(&sources, null, &languages, 2, null, null),
+    );
+
+    // With lengths, code after a NUL byte is extracted too
+    const embedded = "x = 1\x00\ndef add(a: int, b: int) -> int:\n    return a + b\n";
+    const embedded_sources = [_][*]const u8{embedded};
+    const embedded_lens = [_]usize{embedded.len};
+    const python = [_][*:0]const u8{"python"};
+    var truncated = Counter{};
+    _ = ananke_extract_stream(&embedded_sources, null, &python, 1, &Counter.onConstraint, &truncated);
+    var whole = Counter{};
+    const whole_result = ananke_extract_stream(&embedded_sources, &embedded_lens, &python, 1, &Counter.onConstraint, &whole);
+    try testing.expectEqual(@intFromEnum(AnankeError.Success), whole_result);
+    try testing.expect(whole.seen > 0);
+    try testing.expect(whole.seen > truncated.seen);
+}
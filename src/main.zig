@@ -9,6 +9,39 @@ const cli_error = @import("cli/error");
 
 // Import command modules
 const extract = @import("cli/commands/extract");
+const extract_ref = @import("cli/commands/extract_ref");
+const diff = @import("cli/commands/diff");
+const explain = @import("cli/commands/explain");
+const query = @import("cli/commands/query");
+const profile = @import("cli/commands/profile");
+const cache = @import("cli/commands/cache");
+const merge = @import("cli/commands/merge");
+const stats = @import("cli/commands/stats");
+const lint_rules = @import("cli/commands/lint_rules");
+const export_bundle = @import("cli/commands/export_bundle");
+const serve = @import("cli/commands/serve");
+const bench = @import("cli/commands/bench");
+const index = @import("cli/commands/index");
+const daemon = @import("cli/commands/daemon");
+const mcp = @import("cli/commands/mcp");
+const rpc = @import("cli/commands/rpc");
+const import_rules = @import("cli/commands/import_rules");
+const export_embeddings = @import("cli/commands/export_embeddings");
+const verify = @import("cli/commands/verify");
+const drift = @import("cli/commands/drift");
+const enforce = @import("cli/commands/enforce");
+const gen_tests = @import("cli/commands/gen_tests");
+const gen_validators = @import("cli/commands/gen_validators");
+const inject_asserts = @import("cli/commands/inject_asserts");
+const constraints_doc = @import("cli/commands/constraints_doc");
+const api_diff = @import("cli/commands/api_diff");
+const coverage = @import("cli/commands/coverage");
+const scaffold = @import("cli/commands/scaffold");
+const dto_check = @import("cli/commands/dto_check");
+const policy_check = @import("cli/commands/policy_check");
+const review = @import("cli/commands/review");
+const architecture = @import("cli/commands/architecture");
+const genfixture = @import("cli/commands/genfixture");
 const compile = @import("cli/commands/compile");
 const generate = @import("cli/commands/generate");
 const export_spec = @import("cli/commands/export_spec");
@@ -86,6 +119,72 @@ fn runCommand(
 
     if (std.mem.eql(u8, command, "extract")) {
         try extract.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "extract-ref")) {
+        try extract_ref.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "diff")) {
+        try diff.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "explain")) {
+        try explain.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "query")) {
+        try query.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "profile")) {
+        try profile.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "cache")) {
+        try cache.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "merge")) {
+        try merge.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "stats")) {
+        try stats.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "lint-rules")) {
+        try lint_rules.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "export-bundle")) {
+        try export_bundle.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "serve")) {
+        try serve.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "bench")) {
+        try bench.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "index")) {
+        try index.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "daemon")) {
+        try daemon.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "mcp")) {
+        try mcp.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "rpc")) {
+        try rpc.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "import-rules")) {
+        try import_rules.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "export-embeddings")) {
+        try export_embeddings.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "verify")) {
+        try verify.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "drift")) {
+        try drift.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "enforce")) {
+        try enforce.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "gen-tests")) {
+        try gen_tests.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "gen-validators")) {
+        try gen_validators.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "inject-asserts")) {
+        try inject_asserts.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "constraints-doc")) {
+        try constraints_doc.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "api-diff")) {
+        try api_diff.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "coverage")) {
+        try coverage.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "scaffold")) {
+        try scaffold.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "dto-check")) {
+        try dto_check.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "policy-check")) {
+        try policy_check.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "review")) {
+        try review.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "architecture")) {
+        try architecture.run(allocator, parsed_args, config);
+    } else if (std.mem.eql(u8, command, "genfixture")) {
+        try genfixture.run(allocator, parsed_args, config);
     } else if (std.mem.eql(u8, command, "compile")) {
         try compile.run(allocator, parsed_args, config);
     } else if (std.mem.eql(u8, command, "generate")) {
@@ -91,6 +91,13 @@ pub const Ananke = struct {
         return try self.clew_engine.extractRichContext(source, language);
     }
 
+    /// Stream the constraints of several sources, extracting one source at a
+    /// time as the caller asks for more, so the first file's constraints can
+    /// be consumed before later files are analyzed
+    pub fn stream(self: *Ananke, sources: []const StreamSource) ConstraintStream {
+        return .{ .ananke = self, .sources = sources };
+    }
+
     /// Compile constraints to IR
     pub fn compile(
         self: *Ananke,
@@ -111,6 +118,61 @@ pub const Ananke = struct {
     }
 };
 
+/// One input to Ananke.stream
+pub const StreamSource = struct {
+    /// Reported with each constraint and used as its origin_file if unset
+    path: []const u8,
+    language: []const u8,
+    source: []const u8,
+};
+
+/// A constraint produced by a ConstraintStream, with the source it came from
+pub const StreamItem = struct {
+    /// Index into the sources passed to Ananke.stream
+    source_index: usize,
+    constraint: types.constraint.Constraint,
+};
+
+/// Iterator over the constraints of several sources. Each source is
+/// extracted when the previous one's constraints have all been returned;
+/// constraint strings stay valid as long as the engine, as with extract.
+pub const ConstraintStream = struct {
+    ananke: *Ananke,
+    sources: []const StreamSource,
+    next_source: usize = 0,
+    current: ?types.constraint.ConstraintSet = null,
+    next_constraint: usize = 0,
+
+    /// Next constraint, or null once every source is done. An extraction
+    /// error is returned for the failing source; calling next again moves
+    /// on to the following source.
+    pub fn next(self: *ConstraintStream) !?StreamItem {
+        while (true) {
+            if (self.current) |*set| {
+                if (self.next_constraint < set.constraints.items.len) {
+                    var constraint = set.constraints.items[self.next_constraint];
+                    self.next_constraint += 1;
+                    const source_index = self.next_source - 1;
+                    if (constraint.origin_file == null) constraint.origin_file = self.sources[source_index].path;
+                    return .{ .source_index = source_index, .constraint = constraint };
+                }
+                set.deinit();
+                self.current = null;
+            }
+            if (self.next_source >= self.sources.len) return null;
+            const source = self.sources[self.next_source];
+            self.next_source += 1;
+            self.next_constraint = 0;
+            self.current = try self.ananke.extract(source.source, source.language);
+        }
+    }
+
+    pub fn deinit(self: *ConstraintStream) void {
+        if (self.current) |*set| set.deinit();
+        self.current = null;
+    }
+};
+
 test "basic Ananke initialization" {
     var ananke = try Ananke.init(testing.allocator);
     defer ananke.deinit();
@@ -120,3 +182,27 @@ test "basic Ananke initialization" {
     _ = ananke.clew_engine.allocator;
     _ = ananke.braid_engine.allocator;
 }
+
+test "stream yields constraints source by source" {
+    var ananke = try Ananke.init(testing.allocator);
+    defer ananke.deinit();
+
+    const sources = [_]StreamSource{
+        .{ .path = "a.ts", .language = "typescript", .source = "function greet(name: string): string { return name; }\n" },
+        .{ .path = "empty.ts", .language = "typescript", .source = "" },
+        .{ .path = "b.py", .language = "python", .source = "def add(a: int, b: int) -> int:\n    return a + b\n" },
+    };
+    var stream = ananke.stream(&sources);
+    defer stream.deinit();
+
+    var last_index: usize = 0;
+    var count: usize = 0;
+    while (try stream.next()) |item| {
+        try testing.expect(item.source_index >= last_index);
+        try testing.expectEqualStrings(sources[item.source_index].path, item.constraint.origin_file.?);
+        last_index = item.source_index;
+        count += 1;
+    }
+    try testing.expect(count > 0);
+    try testing.expectEqual(@as(usize, 2), last_index);
+}
@@ -151,3 +151,59 @@ test "ConstraintCache: performance improvement verification" {
     // Cache should be faster (or at worst, same speed)
     try testing.expect(avg_cached_time <= @as(f64, @floatFromInt(uncached_time)));
 }
+
+test "ConstraintCache: restricted kinds skip type analysis and key separately" {
+    const allocator = testing.allocator;
+
+    var clew = try clew_mod.Clew.init(allocator);
+    defer clew.deinit();
+
+    const source = "function parse(input: any): string | null { return null; }";
+
+    var full = try clew.extractFromCode(source, "typescript");
+    defer full.deinit();
+    var has_types = false;
+    for (full.constraints.items) |c| {
+        if (c.kind == .type_safety) has_types = true;
+    }
+    try testing.expect(has_types);
+    try testing.expectEqual(@as(usize, 1), clew.type_passes);
+    try testing.expectEqual(@as(usize, 1), clew.cache.misses);
+
+    // Same source, fewer kinds: must not be served the full result from cache
+    clew.config.kinds = std.EnumSet(ananke.ConstraintKind).initOne(.syntactic);
+    var syntactic = try clew.extractFromCode(source, "typescript");
+    defer syntactic.deinit();
+    for (syntactic.constraints.items) |c| {
+        try testing.expectEqual(ananke.ConstraintKind.syntactic, c.kind);
+    }
+    try testing.expectEqual(@as(usize, 2), clew.cache.misses);
+    try testing.expectEqual(@as(usize, 0), clew.cache.hits);
+    try testing.expectEqual(@as(usize, 1), clew.type_passes);
+
+    // The restricted scope has an entry of its own
+    var again = try clew.extractFromCode(source, "typescript");
+    defer again.deinit();
+    try testing.expectEqual(@as(usize, 1), clew.cache.hits);
+    try testing.expectEqual(syntactic.constraints.items.len, again.constraints.items.len);
+}
+
+test "Clew: extraction temporaries are released with the scratch arena" {
+    const allocator = testing.allocator;
+
+    var clew = try clew_mod.Clew.init(allocator);
+    defer clew.deinit();
+
+    var first = try clew.extractFromCode("func A() error {\n\treturn nil\n}\n", "go");
+    defer first.deinit();
+    // Pages are kept for the next file, but nothing is left allocated in them
+    try testing.expectEqual(@as(usize, 0), clew.scratch.state.end_index);
+
+    var second = try clew.extractFromCode("def b(x: int) -> int:\n    return x\n", "python");
+    defer second.deinit();
+    try testing.expectEqual(@as(usize, 0), clew.scratch.state.end_index);
+
+    // Constraint strings outlive the scratch reset
+    try testing.expect(second.constraints.items.len > 0);
+    for (second.constraints.items) |c| try testing.expect(c.name.len > 0);
+}
@@ -5,6 +5,7 @@ const std = @import("std");
 const testing = std.testing;
 const HybridExtractor = @import("clew").hybrid_extractor.HybridExtractor;
 const ExtractionStrategy = @import("clew").hybrid_extractor.ExtractionStrategy;
+const ParsedSource = @import("clew").parsed_source.ParsedSource;
 // ============================================================================
 // Test Data
 const typescript_sample =
@@ -362,3 +363,28 @@ test "HybridExtractor: handles very large source files" {
     // Should handle large files successfully
     try testing.expect(result.constraints.len > 0);
 }
+
+test "HybridExtractor: shared ParsedSource parses and scans once across passes" {
+    const allocator = testing.allocator;
+    var parsed = ParsedSource.init(allocator, typescript_sample);
+    defer parsed.deinit();
+
+    var ast = try HybridExtractor.init(allocator, .tree_sitter_only);
+    defer ast.deinit();
+    ast.shared = &parsed;
+    var first = try ast.extract(typescript_sample, "typescript");
+    defer first.deinitFull(allocator);
+    var second = try ast.extract(typescript_sample, "typescript");
+    defer second.deinitFull(allocator);
+    try testing.expectEqual(first.constraints.len, second.constraints.len);
+    try testing.expectEqual(@as(usize, 1), parsed.parse_count);
+
+    var pattern = try HybridExtractor.init(allocator, .pattern_only);
+    defer pattern.deinit();
+    pattern.shared = &parsed;
+    var scanned = try pattern.extract(typescript_sample, "typescript");
+    defer scanned.deinitFull(allocator);
+    var rescanned = try pattern.extract(typescript_sample, "typescript");
+    defer rescanned.deinitFull(allocator);
+    try testing.expectEqual(@as(usize, 1), parsed.scan_count);
+}
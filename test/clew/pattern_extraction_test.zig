@@ -462,3 +462,63 @@ test "Pattern coverage: All languages extract similar constraint counts" {
     try testing.expect(rust_set.constraints.items.len >= 5);
     try testing.expect(zig_set.constraints.items.len >= 5);
 }
+
+test "Pattern constraints: one per rule and kind, with counts over every match" {
+    const allocator = testing.allocator;
+
+    const function =
+        \\export async function loadUser(id: number): Promise<User> {
+        \\    try {
+        \\        return await fetchUser(id);
+        \\    } catch (error) {
+        \\        throw new Error("cannot load user");
+        \\    }
+        \\}
+        \\
+    ;
+    var repeated = std.ArrayList(u8){};
+    defer repeated.deinit(allocator);
+    for (0..50) |_| try repeated.appendSlice(allocator, function);
+
+    var engine = try Clew.init(allocator);
+    defer engine.deinit();
+
+    var once = try engine.extractFromCode(function, "typescript");
+    defer once.deinit();
+    var many = try engine.extractFromCode(repeated.items, "typescript");
+    defer many.deinit();
+
+    // A rule that matches 50 times still yields one constraint per kind; the
+    // pattern pass describes its constraints as "... in typescript code"
+    const matched = struct {
+        fn count(set: anytype) !usize {
+            var n: usize = 0;
+            for (set.constraints.items, 0..) |c, i| {
+                if (!std.mem.endsWith(u8, c.description, " in typescript code")) continue;
+                n += 1;
+                for (set.constraints.items[i + 1 ..]) |other| {
+                    if (!std.mem.endsWith(u8, other.description, " in typescript code")) continue;
+                    try testing.expect(!(std.mem.eql(u8, c.name, other.name) and c.kind == other.kind));
+                }
+            }
+            return n;
+        }
+
+        /// Frequency of the pattern pass's function summary; the structural
+        /// pass has one of the same name, counting definitions instead
+        fn functions(set: anytype) u32 {
+            for (set.constraints.items) |c| {
+                if (std.mem.endsWith(u8, c.description, " function-related constructs")) return c.frequency;
+            }
+            return 0;
+        }
+    };
+    const rules = try matched.count(once);
+    try testing.expect(rules > 0);
+    try testing.expectEqual(rules, try matched.count(many));
+
+    // Summary counts still see every match
+    const functions = matched.functions(once);
+    try testing.expect(functions > 0);
+    try testing.expectEqual(functions * 50, matched.functions(many));
+}
@@ -107,6 +107,18 @@ test "args: missing positional argument" {
     try testing.expectError(error.MissingArgument, result);
 }
 
+test "args: lone dash is a positional stdin marker" {
+    const allocator = testing.allocator;
+
+    const argv = [_][:0]const u8{ "ananke", "extract", "--verbose", "-", "--lang", "go" };
+    var args = try args_mod.parse(allocator, argv[0..]);
+    defer args.deinit();
+
+    try testing.expectEqualStrings("-", try args.getPositional(0));
+    try testing.expectEqualStrings("true", args.getFlag("verbose").?);
+    try testing.expectEqualStrings("go", args.getFlag("lang").?);
+}
+
 test "config: initialization" {
     const allocator = testing.allocator;
 
@@ -278,3 +290,21 @@ test "output: colorize with colors disabled" {
     try testing.expectEqualStrings("Error", uncolored);
     output.setColorEnabled(true); // Reset for other tests
 }
+
+test "output: redact masks string literals" {
+    const allocator = testing.allocator;
+
+    const redacted = try output.redactText(allocator, "Compares token against \"sk-live-123\" and 'hunter2'");
+    defer allocator.free(redacted);
+
+    try testing.expectEqualStrings("Compares token against \"***\" and '***'", redacted);
+}
+
+test "output: redact keeps apostrophes and strips fenced snippets" {
+    const allocator = testing.allocator;
+
+    const redacted = try output.redactText(allocator, "Don't log secrets: ```db.Exec(\"pw\")``` done");
+    defer allocator.free(redacted);
+
+    try testing.expectEqualStrings("Don't log secrets: [snippet redacted] done", redacted);
+}